@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/configdiff"
+)
+
+// runDiff runs the diff subcommand: sql-proxy diff [-format text|json] old.yaml new.yaml.
+// It returns the exit code: 1 if there are breaking changes.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sql-proxy diff [-format text|json] old.yaml new.yaml")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Invalid -format '%s' (must be text or json)\n", *format)
+		return 2
+	}
+
+	var cfgs [2]*config.Config
+	for i, path := range fs.Args() {
+		cfg, err := config.Load(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error in %s: %v\n", path, err)
+			return 2
+		}
+		cfgs[i] = cfg
+	}
+
+	report := configdiff.Compare(cfgs[0], cfgs[1])
+	if *format == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode report: %v\n", err)
+			return 2
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(report.Text())
+	}
+
+	if report.Breaking() > 0 {
+		return 1
+	}
+	return 0
+}
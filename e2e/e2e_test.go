@@ -637,6 +637,290 @@ func TestE2E_WorkflowEndpoint(t *testing.T) {
 	}
 }
 
+// TestE2E_MockDatabase tests validation and workflow responses of a mock database answering from fixtures
+func TestE2E_MockDatabase(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	binaryPath := buildBinary(t)
+
+	port, err := findFreePort()
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+
+	dir := t.TempDir()
+	fixtures := `responses:
+  - match: "SELECT \\* FROM items WHERE id = @id"
+    params: {id: 1}
+    rows:
+      - {id: 1, name: "widget"}
+  - match: "SELECT \\* FROM items WHERE id = @id"
+    rows: []
+`
+	if err := os.WriteFile(filepath.Join(dir, "fixtures.yaml"), []byte(fixtures), 0644); err != nil {
+		t.Fatalf("failed to write fixtures: %v", err)
+	}
+	config := fmt.Sprintf(`server:
+  host: "127.0.0.1"
+  port: %d
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+
+databases:
+  - name: "test"
+    type: "mock"
+    fixtures: "fixtures.yaml"
+
+logging:
+  level: "error"
+  max_size_mb: 10
+  max_backups: 1
+  max_age_days: 1
+
+workflows:
+  - name: "get_item"
+    triggers:
+      - type: "http"
+        path: "/api/item"
+        method: "GET"
+        parameters:
+          - name: "id"
+            type: "int"
+            required: true
+    steps:
+      - name: "fetch"
+        type: "query"
+        database: "test"
+        sql: "SELECT * FROM items WHERE id = @id"
+      - type: "response"
+        template: '{"count": {{len .steps.fetch.data}}, "data": {{json .steps.fetch.data}}}'
+`, port)
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	output, err := exec.Command(binaryPath, "-validate", "-config", configPath).CombinedOutput()
+	if err != nil {
+		t.Fatalf("validation failed for mock database: %v\nOutput: %s", err, output)
+	}
+
+	ts := startServer(t, binaryPath, configPath, port)
+	defer func() { _ = ts.stop() }()
+
+	var result map[string]any
+	if _, err := ts.getJSON("/api/item?id=1", &result); err != nil {
+		t.Fatalf("workflow request failed: %v", err)
+	}
+	data, ok := result["data"].([]any)
+	if !ok || len(data) != 1 || data[0].(map[string]any)["name"] != "widget" {
+		t.Errorf("expected the fixture row, got %v", result)
+	}
+
+	result = nil
+	if _, err := ts.getJSON("/api/item?id=2", &result); err != nil {
+		t.Fatalf("workflow request failed: %v", err)
+	}
+	if result["count"] != float64(0) {
+		t.Errorf("expected no rows for another id, got %v", result)
+	}
+}
+
+// TestE2E_TestCommand tests the test command passes and fails workflow test cases with mocked databases
+func TestE2E_TestCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	binaryPath := buildBinary(t)
+
+	dir := t.TempDir()
+	config := `server:
+  host: "127.0.0.1"
+  port: 8080
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+
+databases:
+  - name: "test"
+    type: "sqlite"
+    path: ":memory:"
+
+logging:
+  level: "error"
+  max_size_mb: 10
+  max_backups: 1
+  max_age_days: 1
+
+workflows:
+  - name: "get_item"
+    triggers:
+      - type: "http"
+        path: "/api/item"
+        method: "GET"
+        parameters:
+          - name: "id"
+            type: "int"
+            required: true
+    steps:
+      - name: "fetch"
+        type: "query"
+        database: "test"
+        sql: "SELECT * FROM items WHERE id = @id"
+      - type: "response"
+        template: '{"count": {{len .steps.fetch.data}}, "data": {{json .steps.fetch.data}}}'
+`
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	tests := `tests:
+  - name: "item found"
+    request: {path: "/api/item?id=1"}
+    databases:
+      test:
+        - match: "FROM items WHERE id = @id"
+          rows: [{id: 1, name: "widget"}]
+    expect:
+      body: {count: 1, data: [{name: "widget"}]}
+  - name: "item missing"
+    request: {path: "/api/item?id=2"}
+    databases:
+      test:
+        - match: "FROM items"
+          rows: []
+    expect:
+      body: {count: 1}
+`
+	testsDir := filepath.Join(dir, "tests")
+	if err := os.Mkdir(testsDir, 0755); err != nil {
+		t.Fatalf("failed to create tests directory: %v", err)
+	}
+	testsPath := filepath.Join(testsDir, "items.yaml")
+	if err := os.WriteFile(testsPath, []byte(tests), 0644); err != nil {
+		t.Fatalf("failed to write tests: %v", err)
+	}
+
+	output, err := exec.Command(binaryPath, "test", "-config", configPath, "-tests", testsPath, "-run", "found").CombinedOutput()
+	if err != nil {
+		t.Fatalf("passing case failed: %v\nOutput: %s", err, output)
+	}
+	if !strings.Contains(string(output), "1 passed, 0 failed") {
+		t.Errorf("expected one passing case, got: %s", output)
+	}
+
+	output, err = exec.Command(binaryPath, "test", "-config", configPath, "-tests", testsDir).CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1 for a failing case, got %v\nOutput: %s", err, output)
+	}
+	if !strings.Contains(string(output), "FAIL") || !strings.Contains(string(output), "item missing") || !strings.Contains(string(output), "body.count: got 0, want 1") {
+		t.Errorf("expected the failing case to be reported, got: %s", output)
+	}
+}
+
+// TestE2E_GraphCommand tests the graph command draws a workflow and rejects unknown workflows and formats
+func TestE2E_GraphCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	binaryPath := buildBinary(t)
+
+	port, err := findFreePort()
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	configPath := createTestConfig(t, port, dbPath)
+
+	output, err := exec.Command(binaryPath, "graph", "-config", configPath, "-workflow", "list_items").CombinedOutput()
+	if err != nil {
+		t.Fatalf("graph failed: %v\nOutput: %s", err, output)
+	}
+	if !strings.Contains(string(output), `digraph "list_items"`) || !strings.Contains(string(output), `shape=cylinder`) {
+		t.Errorf("expected a DOT graph with the database, got: %s", output)
+	}
+
+	outPath := filepath.Join(t.TempDir(), "graph.mmd")
+	output, err = exec.Command(binaryPath, "graph", "-config", configPath, "-workflow", "list_items", "-format", "mermaid", "-o", outPath).CombinedOutput()
+	if err != nil {
+		t.Fatalf("graph failed: %v\nOutput: %s", err, output)
+	}
+	content, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "flowchart TD") {
+		t.Errorf("expected a Mermaid flowchart, got: %s", content)
+	}
+
+	for _, args := range [][]string{
+		{"-workflow", "missing"},
+		{"-workflow", "list_items", "-format", "svg"},
+	} {
+		output, err = exec.Command(binaryPath, append([]string{"graph", "-config", configPath}, args...)...).CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+			t.Errorf("%v: expected exit code 2, got %v\nOutput: %s", args, err, output)
+		}
+	}
+}
+
+// TestE2E_DiffCommand tests the diff command reports route changes and exits 1 on breaking ones
+func TestE2E_DiffCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	binaryPath := buildBinary(t)
+
+	port, err := findFreePort()
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	oldPath := createTestConfig(t, port, dbPath)
+
+	output, err := exec.Command(binaryPath, "diff", oldPath, oldPath).CombinedOutput()
+	if err != nil {
+		t.Fatalf("diff of identical configs failed: %v\nOutput: %s", err, output)
+	}
+	if !strings.Contains(string(output), "No changes") {
+		t.Errorf("expected no changes, got: %s", output)
+	}
+
+	content, err := os.ReadFile(oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPath := filepath.Join(t.TempDir(), "new.yaml")
+	renamed := strings.Replace(string(content), `"/api/items"`, `"/api/v2/items"`, 1)
+	if err := os.WriteFile(newPath, []byte(renamed), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(binaryPath, "diff", "-format", "json", oldPath, newPath)
+	output, err = cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1 for a removed route, got %v\nOutput: %s", err, output)
+	}
+	var report struct {
+		Changes []struct {
+			Subject  string `json:"subject"`
+			Breaking bool   `json:"breaking"`
+		} `json:"changes"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		t.Fatalf("invalid JSON report: %v\nOutput: %s", err, output)
+	}
+	if len(report.Changes) != 2 || report.Changes[0].Subject != "GET /api/items" || !report.Changes[0].Breaking {
+		t.Errorf("expected the removed and added routes, got: %s", output)
+	}
+}
+
 // TestE2E_ErrorHandling_MissingRequiredParameter tests 400 response for missing required parameters
 func TestE2E_ErrorHandling_MissingRequiredParameter(t *testing.T) {
 	if testing.Short() {
@@ -1170,6 +1454,49 @@ func TestE2E_ConfigValidation(t *testing.T) {
 	}
 }
 
+// TestE2E_Lint tests -lint exits 0 with warnings and 1 once a rule is raised to error
+func TestE2E_Lint(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	binaryPath := buildBinary(t)
+
+	port, err := findFreePort()
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	configPath := createTestConfig(t, port, dbPath)
+
+	output, err := exec.Command(binaryPath, "-lint", "-config", configPath).CombinedOutput()
+	if err != nil {
+		t.Errorf("lint failed with warnings only: %v\nOutput: %s", err, output)
+	}
+	if !strings.Contains(string(output), "(lint: select_star)") {
+		t.Errorf("expected select_star warning in output, got: %s", output)
+	}
+
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = append(content, "\nlint:\n  rules:\n    select_star: error\n"...)
+	if err := os.WriteFile(configPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(binaryPath, "-lint", "-config", configPath)
+	output, err = cmd.CombinedOutput()
+	if cmd.ProcessState == nil || cmd.ProcessState.ExitCode() != 1 {
+		t.Errorf("expected exit code 1 with an error rule, got %v\nOutput: %s", err, output)
+	}
+	if !strings.Contains(string(output), "[ERROR]") {
+		t.Errorf("expected error in output, got: %s", output)
+	}
+}
+
 // TestE2E_InvalidConfig tests server rejects invalid config
 func TestE2E_InvalidConfig(t *testing.T) {
 	if testing.Short() {
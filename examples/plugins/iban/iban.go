@@ -0,0 +1,63 @@
+// Package iban is an example plugin adding IBAN helpers to templates and expressions.
+// It is compiled in by building with -tags plugins (see plugins.go).
+package iban
+
+import (
+	"strings"
+
+	"sql-proxy/funcs"
+)
+
+func init() {
+	funcs.Register("isIBAN", IsValid)
+	funcs.RegisterTemplate("formatIBAN", Format)
+}
+
+// IsValid reports whether s is a well-formed IBAN with a correct check digit.
+// Spaces are ignored and letters may be lower case.
+func IsValid(s string) bool {
+	s = normalize(s)
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case i < 2 && (r < 'A' || r > 'Z'):
+			return false
+		case i >= 2 && i < 4 && (r < '0' || r > '9'):
+			return false
+		case (r < 'A' || r > 'Z') && (r < '0' || r > '9'):
+			return false
+		}
+	}
+
+	// ISO 7064 mod 97-10: move the first four characters to the end,
+	// expand letters to 10-35, and the remainder must be 1
+	rearranged := s[4:] + s[:4]
+	remainder := 0
+	for _, r := range rearranged {
+		if r >= 'A' {
+			remainder = (remainder*100 + int(r-'A'+10)) % 97
+		} else {
+			remainder = (remainder*10 + int(r-'0')) % 97
+		}
+	}
+	return remainder == 1
+}
+
+// Format returns s in the printed form: upper case, in groups of four.
+func Format(s string) string {
+	s = normalize(s)
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func normalize(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+}
@@ -0,0 +1,73 @@
+package iban
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"sql-proxy/internal/workflow"
+)
+
+// TestIsValid tests IBAN structure and check digit validation
+func TestIsValid(t *testing.T) {
+	tests := []struct {
+		iban string
+		want bool
+	}{
+		{"GB82WEST12345698765432", true},
+		{"gb82 west 1234 5698 7654 32", true},
+		{"DE89370400440532013000", true},
+		{"GB83WEST12345698765432", false}, // Wrong check digits
+		{"GB82WEST1234569876543", false},  // Digit dropped
+		{"GB82WEST12345698765432!", false},
+		{"1282WEST12345698765432", false},
+		{"GB8", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValid(tt.iban); got != tt.want {
+			t.Errorf("IsValid(%q) = %v, want %v", tt.iban, got, tt.want)
+		}
+	}
+}
+
+// TestFormat tests grouping into blocks of four
+func TestFormat(t *testing.T) {
+	if got := Format("gb82west12345698765432"); got != "GB82 WEST 1234 5698 7654 32" {
+		t.Errorf("Format() = %q", got)
+	}
+}
+
+// TestWorkflowFuncs verifies the plugin's functions reach workflow conditions and templates
+// regardless of package initialization order
+func TestWorkflowFuncs(t *testing.T) {
+	cw, err := workflow.Compile(&workflow.WorkflowConfig{
+		Name:     "payout",
+		Triggers: []workflow.TriggerConfig{{Type: "http", Path: "/payout", Method: "POST"}},
+		Steps: []workflow.StepConfig{{
+			Name:      "confirm",
+			Condition: "isIBAN(trigger.params.account)",
+			Type:      "response",
+			Template:  "{{formatIBAN .trigger.params.account}}",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	trigger := &workflow.TriggerData{Type: "http", Params: map[string]any{"account": "gb82west12345698765432"}}
+	wfCtx := workflow.NewContext(context.Background(), cw, trigger, "req-1", nil, nil)
+
+	ok, err := workflow.EvalCondition(cw.Steps[0].Condition, wfCtx.BuildExprEnv())
+	if err != nil || !ok {
+		t.Errorf("condition = %v, %v, want true", ok, err)
+	}
+	var buf bytes.Buffer
+	if err := cw.Steps[0].TemplateTmpl.Execute(&buf, wfCtx.BuildTemplateData()); err != nil {
+		t.Fatalf("template failed: %v", err)
+	}
+	if buf.String() != "GB82 WEST 1234 5698 7654 32" {
+		t.Errorf("template = %q", buf.String())
+	}
+}
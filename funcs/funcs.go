@@ -0,0 +1,39 @@
+// Package funcs lets other modules compile domain-specific helpers into the proxy.
+//
+// A plugin is a package that registers its functions from an init function:
+//
+//	package iban
+//
+//	import "sql-proxy/funcs"
+//
+//	func init() {
+//		funcs.Register("isIBAN", IsValid)
+//	}
+//
+// and is linked in with a blank import from a file in package main, typically one
+// behind a build tag (see plugins.go). Registered functions are available to every
+// template (responses, step fields, cache and rate limit keys) and, for
+// Register and RegisterExpr, to expressions (conditions, set values, scripts).
+//
+// Registering a name twice, a name that shadows a built-in function, or a value
+// that is not a usable function panics, so a broken plugin fails at startup.
+package funcs
+
+import "sql-proxy/internal/tmpl"
+
+// Register adds fn as both a template function and an expression function.
+func Register(name string, fn any) {
+	RegisterTemplate(name, fn)
+	RegisterExpr(name, fn)
+}
+
+// RegisterTemplate adds fn as a template function. Like text/template functions,
+// it must return one value, or a value and an error.
+func RegisterTemplate(name string, fn any) {
+	tmpl.RegisterFunc(name, fn)
+}
+
+// RegisterExpr adds fn as an expression function.
+func RegisterExpr(name string, fn any) {
+	tmpl.RegisterExprFunc(name, fn)
+}
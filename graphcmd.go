@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/graph"
+)
+
+// runGraph runs the graph subcommand: sql-proxy graph -workflow name -format dot|mermaid.
+// It returns the exit code.
+func runGraph(args []string) int {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.yaml", "Path to configuration file")
+	name := fs.String("workflow", "", "Workflow to draw (required)")
+	format := fs.String("format", graph.FormatDOT, "Output format: dot or mermaid")
+	output := fs.String("o", "", "Write the graph to this file (default: stdout)")
+	_ = fs.Parse(args)
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		return 2
+	}
+
+	names := make([]string, len(cfg.Workflows))
+	for i, wf := range cfg.Workflows {
+		names[i] = wf.Name
+	}
+	if *name == "" {
+		fmt.Fprintf(os.Stderr, "-workflow is required (workflows: %s)\n", strings.Join(names, ", "))
+		return 2
+	}
+	var wf *config.WorkflowConfig
+	for i := range cfg.Workflows {
+		if cfg.Workflows[i].Name == *name {
+			wf = &cfg.Workflows[i]
+		}
+	}
+	if wf == nil {
+		fmt.Fprintf(os.Stderr, "Unknown workflow '%s' (workflows: %s)\n", *name, strings.Join(names, ", "))
+		return 2
+	}
+
+	out, err := graph.Build(wf).Render(*format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -format: %v\n", err)
+		return 2
+	}
+	if *output == "" {
+		fmt.Print(out)
+		return 0
+	}
+	if err := os.WriteFile(*output, []byte(out), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write graph: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -28,13 +28,20 @@ const (
 
 	// ristrettoBufferItems is the number of keys per Get buffer
 	ristrettoBufferItems = 64
+
+	// minTagPruneSize is the tag index size below which expired entries are not pruned
+	minTagPruneSize = 1024
 )
 
-// Cache wraps Ristretto with per-endpoint tracking and metrics
+// Cache wraps Ristretto with per-endpoint tracking and metrics, optionally
+// backed by a second tier on disk
 type Cache struct {
 	store      *ristretto.Cache
 	maxCost    int64 // Total max size in bytes
 	defaultTTL time.Duration
+	maxTTL     time.Duration // Caps how long entries stay in memory (0 = their TTL)
+	disk       *diskStore    // nil without a disk tier
+	compressor *compressor   // nil without compression
 
 	mu        sync.RWMutex
 	endpoints map[string]*EndpointCache
@@ -45,6 +52,24 @@ type Cache struct {
 	// Global metrics (atomic for lock-free reads)
 	totalHits   atomic.Int64
 	totalMisses atomic.Int64
+
+	// Tag index for cross-endpoint invalidation
+	tagMu      sync.Mutex
+	tagIndex   map[string]map[entryRef]struct{} // tag -> tagged entries
+	entryTags  map[entryRef]*taggedEntry        // entry -> its tags
+	tagPruneAt int                              // entryTags size that triggers pruning of expired entries
+}
+
+// entryRef identifies a cached entry by endpoint and key
+type entryRef struct {
+	endpoint string
+	key      string
+}
+
+// taggedEntry records the tags attached to an entry and when it expires
+type taggedEntry struct {
+	tags      []string
+	expiresAt time.Time
 }
 
 // EndpointCache tracks per-endpoint cache state
@@ -72,10 +97,11 @@ type entryMeta struct {
 
 // Entry is what we store in the cache
 type Entry struct {
-	Data      []map[string]any
-	SizeBytes int64
-	CachedAt  time.Time
-	TTL       time.Duration
+	Data       []map[string]any
+	Compressed []byte // Data compressed by the cache's compressor; Data is nil when set
+	SizeBytes  int64
+	CachedAt   time.Time
+	TTL        time.Duration
 }
 
 // CacheSnapshot represents metrics at a point in time
@@ -88,6 +114,8 @@ type CacheSnapshot struct {
 	TotalMisses    int64                       `json:"total_misses"`
 	HitRatio       float64                     `json:"hit_ratio"`
 	Endpoints      map[string]*EndpointMetrics `json:"endpoints"`
+	Disk           *DiskSnapshot               `json:"disk,omitempty"`        // Only with a disk tier
+	Compression    *CompressionSnapshot        `json:"compression,omitempty"` // Only with compression
 }
 
 // EndpointMetrics contains per-endpoint cache statistics
@@ -129,12 +157,36 @@ func New(cfg config.CacheConfig) (*Cache, error) {
 		return nil, fmt.Errorf("creating ristretto cache: %w", err)
 	}
 
-	return &Cache{
+	c := &Cache{
 		store:      store,
 		maxCost:    maxCost,
 		defaultTTL: ttl,
+		maxTTL:     time.Duration(cfg.MaxTTLSec) * time.Second,
+		compressor: newCompressor(cfg.CompressAboveKB),
 		endpoints:  make(map[string]*EndpointCache),
-	}, nil
+		tagIndex:   make(map[string]map[entryRef]struct{}),
+		entryTags:  make(map[entryRef]*taggedEntry),
+		tagPruneAt: minTagPruneSize,
+	}
+
+	if cfg.Disk != nil {
+		c.disk, err = openDiskStore(cfg.Disk)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		// Entries kept on disk across a restart stay invalidatable by their tags
+		tagged, err := c.disk.tags()
+		if err != nil {
+			store.Close()
+			_ = c.disk.close()
+			return nil, fmt.Errorf("reading disk cache tags: %w", err)
+		}
+		for _, t := range tagged {
+			c.tagEntry(t.ref, t.tags, t.expiresAt)
+		}
+	}
+	return c, nil
 }
 
 // RegisterEndpoint sets up per-endpoint tracking with optional cron eviction
@@ -182,21 +234,29 @@ func (c *Cache) Get(endpoint, key string) ([]map[string]any, bool) {
 
 	ep := c.getEndpoint(endpoint)
 
-	if !found {
-		c.totalMisses.Add(1)
-		if ep != nil {
-			ep.misses.Add(1)
+	entry, ok := val.(*Entry)
+	var data []map[string]any
+	if found && ok {
+		data = entry.Data
+		if entry.Compressed != nil {
+			if data, ok = c.compressor.decompress(entry.Compressed); !ok {
+				c.store.Del(fullKey)
+			}
 		}
-		return nil, false
 	}
-
-	entry, ok := val.(*Entry)
-	if !ok {
-		c.totalMisses.Add(1)
-		if ep != nil {
-			ep.misses.Add(1)
+	if !found || !ok {
+		// Entries evicted from memory, or stored before a restart, may still be on disk
+		var remaining time.Duration
+		var onDisk bool
+		data, remaining, onDisk = c.disk.get(endpoint, key)
+		if !onDisk {
+			c.totalMisses.Add(1)
+			if ep != nil {
+				ep.misses.Add(1)
+			}
+			return nil, false
 		}
-		return nil, false
+		c.setMemory(endpoint, key, data, calculateSize(data), remaining)
 	}
 
 	c.totalHits.Add(1)
@@ -204,7 +264,7 @@ func (c *Cache) Get(endpoint, key string) ([]map[string]any, bool) {
 		ep.hits.Add(1)
 	}
 
-	return entry.Data, true
+	return data, true
 }
 
 // ComputeFunc is the function type for computing a value on cache miss.
@@ -257,12 +317,45 @@ func (c *Cache) GetOrCompute(endpoint, key string, ttl time.Duration, compute Co
 
 // Set stores data in the cache
 func (c *Cache) Set(endpoint, key string, data []map[string]any, ttl time.Duration) bool {
+	return c.set(endpoint, key, data, ttl, nil)
+}
+
+// set stores data in memory and, with a disk tier, on disk. Returns whether either tier took it.
+func (c *Cache) set(endpoint, key string, data []map[string]any, ttl time.Duration, tags []string) bool {
 	if c == nil {
 		return false
 	}
+	if ttl == 0 {
+		ttl = c.defaultTTL
+	}
 
 	// Calculate size
 	sizeBytes := calculateSize(data)
+	inMemory := c.setMemory(endpoint, key, data, sizeBytes, ttl)
+	onDisk := c.disk.set(endpoint, key, data, sizeBytes, ttl, tags)
+	return inMemory || onDisk
+}
+
+// setMemory stores data in the memory tier for ttl, capped by the tier's max TTL.
+// Entries large enough for the compressor are stored compressed, and count
+// against the size limits by their compressed size.
+func (c *Cache) setMemory(endpoint, key string, data []map[string]any, sizeBytes int64, ttl time.Duration) bool {
+	if c.maxTTL > 0 {
+		ttl = min(ttl, c.maxTTL)
+	}
+
+	entry := &Entry{
+		Data:      data,
+		SizeBytes: sizeBytes,
+		CachedAt:  time.Now(),
+		TTL:       ttl,
+	}
+	if blob := c.compressor.compress(data, sizeBytes); blob != nil {
+		entry.Data = nil
+		entry.Compressed = blob
+		entry.SizeBytes = int64(len(blob))
+		sizeBytes = entry.SizeBytes
+	}
 
 	// Check per-endpoint limit under lock to prevent race condition
 	ep := c.getEndpoint(endpoint)
@@ -279,16 +372,6 @@ func (c *Cache) Set(endpoint, key string, data []map[string]any, ttl time.Durati
 	}
 
 	fullKey := endpoint + ":" + key
-	entry := &Entry{
-		Data:      data,
-		SizeBytes: sizeBytes,
-		CachedAt:  time.Now(),
-		TTL:       ttl,
-	}
-
-	if ttl == 0 {
-		ttl = c.defaultTTL
-	}
 
 	// Store in ristretto with TTL
 	// Note: SetWithTTL returns immediately - the write is buffered.
@@ -317,6 +400,96 @@ func (c *Cache) Set(endpoint, key string, data []map[string]any, ttl time.Durati
 	return success
 }
 
+// SetWithTags stores data in the cache and attaches tags to the entry.
+// Tagged entries can be removed across endpoints with InvalidateTag.
+func (c *Cache) SetWithTags(endpoint, key string, data []map[string]any, ttl time.Duration, tags []string) bool {
+	if c == nil {
+		return false
+	}
+
+	success := c.set(endpoint, key, data, ttl, tags)
+	if success {
+		if ttl == 0 {
+			ttl = c.defaultTTL
+		}
+		c.tagEntry(entryRef{endpoint: endpoint, key: key}, tags, time.Now().Add(ttl))
+	}
+	return success
+}
+
+// InvalidateTag removes all entries carrying the tag and returns how many were removed
+func (c *Cache) InvalidateTag(tag string) int {
+	if c == nil {
+		return 0
+	}
+
+	c.tagMu.Lock()
+	refs := make([]entryRef, 0, len(c.tagIndex[tag]))
+	for ref := range c.tagIndex[tag] {
+		refs = append(refs, ref)
+	}
+	c.tagMu.Unlock()
+
+	removed := 0
+	for _, ref := range refs {
+		if _, found := c.store.Get(ref.endpoint + ":" + ref.key); found || c.disk.has(ref.endpoint, ref.key) {
+			removed++
+		}
+		c.Delete(ref.endpoint, ref.key)
+	}
+	return removed
+}
+
+// tagEntry replaces the tags recorded for an entry
+func (c *Cache) tagEntry(ref entryRef, tags []string, expiresAt time.Time) {
+	c.tagMu.Lock()
+	defer c.tagMu.Unlock()
+
+	c.untagLocked(ref)
+	if len(tags) == 0 {
+		return
+	}
+
+	c.entryTags[ref] = &taggedEntry{tags: tags, expiresAt: expiresAt}
+	for _, tag := range tags {
+		refs, ok := c.tagIndex[tag]
+		if !ok {
+			refs = make(map[entryRef]struct{})
+			c.tagIndex[tag] = refs
+		}
+		refs[ref] = struct{}{}
+	}
+
+	// Entries that expire are never deleted explicitly, so sweep them
+	// out whenever the index has doubled since the last sweep
+	if len(c.entryTags) >= c.tagPruneAt {
+		now := time.Now()
+		for r, te := range c.entryTags {
+			if now.After(te.expiresAt) {
+				c.untagLocked(r)
+			}
+		}
+		c.tagPruneAt = max(2*len(c.entryTags), minTagPruneSize)
+	}
+}
+
+// untagLocked removes an entry from the tag index. Caller must hold tagMu.
+func (c *Cache) untagLocked(ref entryRef) {
+	te, ok := c.entryTags[ref]
+	if !ok {
+		return
+	}
+	for _, tag := range te.tags {
+		if refs, ok := c.tagIndex[tag]; ok {
+			delete(refs, ref)
+			if len(refs) == 0 {
+				delete(c.tagIndex, tag)
+			}
+		}
+	}
+	delete(c.entryTags, ref)
+}
+
 // Delete removes a specific key from the cache
 func (c *Cache) Delete(endpoint, key string) {
 	if c == nil {
@@ -325,6 +498,11 @@ func (c *Cache) Delete(endpoint, key string) {
 
 	fullKey := endpoint + ":" + key
 	c.store.Del(fullKey)
+	c.disk.delete(endpoint, key)
+
+	c.tagMu.Lock()
+	c.untagLocked(entryRef{endpoint: endpoint, key: key})
+	c.tagMu.Unlock()
 
 	ep := c.getEndpoint(endpoint)
 	if ep != nil {
@@ -346,6 +524,8 @@ func (c *Cache) Clear(endpoint string) {
 		return
 	}
 
+	c.disk.clear(endpoint)
+
 	ep := c.getEndpoint(endpoint)
 	if ep == nil {
 		return
@@ -370,6 +550,13 @@ func (c *Cache) ClearAll() {
 	}
 
 	c.store.Clear()
+	c.disk.clear("")
+
+	c.tagMu.Lock()
+	c.tagIndex = make(map[string]map[entryRef]struct{})
+	c.entryTags = make(map[entryRef]*taggedEntry)
+	c.tagPruneAt = minTagPruneSize
+	c.tagMu.Unlock()
 
 	c.mu.RLock()
 	for _, ep := range c.endpoints {
@@ -397,6 +584,7 @@ func (c *Cache) Close() {
 	}
 
 	c.store.Close()
+	_ = c.disk.close()
 }
 
 // GetSnapshot returns current cache metrics
@@ -414,6 +602,8 @@ func (c *Cache) GetSnapshot() *CacheSnapshot {
 		TotalHits:    hits,
 		TotalMisses:  misses,
 		Endpoints:    make(map[string]*EndpointMetrics),
+		Disk:         c.disk.snapshot(),
+		Compression:  c.compressor.snapshot(),
 	}
 
 	if hits+misses > 0 {
@@ -450,6 +640,14 @@ func (c *Cache) GetSnapshot() *CacheSnapshot {
 	return snap
 }
 
+// DefaultTTL returns the TTL applied to entries stored with ttl 0
+func (c *Cache) DefaultTTL() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return c.defaultTTL
+}
+
 // GetTTLRemaining returns the remaining TTL for a cached entry
 func (c *Cache) GetTTLRemaining(endpoint, key string) time.Duration {
 	if c == nil {
@@ -466,6 +466,10 @@ func TestCache_DefaultTTL(t *testing.T) {
 	}
 	defer c.Close()
 
+	if c.DefaultTTL() != 2*time.Second {
+		t.Errorf("DefaultTTL() = %v, want 2s", c.DefaultTTL())
+	}
+
 	endpoint := "/api/test"
 	_ = c.RegisterEndpoint(endpoint, &config.EndpointCacheConfig{Enabled: true, Key: "{{.id}}"})
 
@@ -879,3 +883,82 @@ func TestCache_ClearTriggersEvictionMetric(t *testing.T) {
 		t.Errorf("expected 0 bytes after Clear, got %d", snap2.Endpoints[endpoint].SizeBytes)
 	}
 }
+
+// TestCache_InvalidateTag tests removing tagged entries across endpoints
+func TestCache_InvalidateTag(t *testing.T) {
+	c, err := New(config.CacheConfig{Enabled: true, MaxSizeMB: 64})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	data := []map[string]any{{"id": 1}}
+	c.SetWithTags("get_user", "user:1", data, time.Minute, []string{"user:1"})
+	c.SetWithTags("list_orders", "orders:1", data, time.Minute, []string{"user:1", "orders"})
+	c.SetWithTags("get_user", "user:2", data, time.Minute, []string{"user:2"})
+	c.Set("get_user", "untagged", data, time.Minute)
+	time.Sleep(10 * time.Millisecond)
+
+	if n := c.InvalidateTag("user:1"); n != 2 {
+		t.Errorf("InvalidateTag(user:1) = %d, want 2", n)
+	}
+	if _, found := c.Get("get_user", "user:1"); found {
+		t.Error("expected get_user entry to be invalidated")
+	}
+	if _, found := c.Get("list_orders", "orders:1"); found {
+		t.Error("expected list_orders entry to be invalidated")
+	}
+	if _, found := c.Get("get_user", "user:2"); !found {
+		t.Error("expected entry with other tag to remain")
+	}
+	if _, found := c.Get("get_user", "untagged"); !found {
+		t.Error("expected untagged entry to remain")
+	}
+
+	// Deleted entries are dropped from every tag they carried
+	if n := c.InvalidateTag("orders"); n != 0 {
+		t.Errorf("InvalidateTag(orders) = %d, want 0", n)
+	}
+	if n := c.InvalidateTag("user:1"); n != 0 {
+		t.Errorf("second InvalidateTag(user:1) = %d, want 0", n)
+	}
+
+	c.ClearAll()
+	if n := c.InvalidateTag("user:2"); n != 0 {
+		t.Errorf("InvalidateTag after ClearAll = %d, want 0", n)
+	}
+}
+
+// TestCache_TagIndexPrunesExpired tests that expired entries are swept from the tag index
+func TestCache_TagIndexPrunesExpired(t *testing.T) {
+	c, err := New(config.CacheConfig{Enabled: true, MaxSizeMB: 64})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	data := []map[string]any{{"id": 1}}
+	for i := range minTagPruneSize - 1 {
+		c.SetWithTags("wf", fmt.Sprintf("k%d", i), data, time.Millisecond, []string{"t"})
+	}
+	time.Sleep(5 * time.Millisecond)
+	c.SetWithTags("wf", "live", data, time.Minute, []string{"t"})
+
+	c.tagMu.Lock()
+	size := len(c.entryTags)
+	c.tagMu.Unlock()
+	if size != 1 {
+		t.Errorf("tag index size = %d, want 1 after pruning", size)
+	}
+}
+
+// TestCache_NilTagOperations tests tag methods on a nil cache
+func TestCache_NilTagOperations(t *testing.T) {
+	var c *Cache
+	if c.SetWithTags("wf", "k", nil, 0, []string{"t"}) {
+		t.Error("expected SetWithTags on nil cache to return false")
+	}
+	if n := c.InvalidateTag("t"); n != 0 {
+		t.Errorf("InvalidateTag on nil cache = %d, want 0", n)
+	}
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/gob"
+	"sync"
+	"sync/atomic"
+)
+
+// compressor shrinks memory entries at or above a size threshold with
+// DEFLATE, trading CPU on hits for more entries within max_size_mb.
+// A nil *compressor stores every entry as is.
+type compressor struct {
+	threshold int64
+	writers   sync.Pool
+
+	entries         atomic.Int64 // Entries stored compressed
+	skipped         atomic.Int64 // Entries above the threshold that didn't shrink
+	rawBytes        atomic.Int64 // Size of compressed entries before compression
+	compressedBytes atomic.Int64 // Size of compressed entries after compression
+	hits            atomic.Int64 // Hits decompressed
+	errors          atomic.Int64 // Entries that failed to decompress, dropped as misses
+}
+
+// CompressionSnapshot reports how well memory entries compress
+type CompressionSnapshot struct {
+	ThresholdBytes  int64   `json:"threshold_bytes"`
+	Entries         int64   `json:"entries"`
+	Skipped         int64   `json:"skipped"`
+	RawBytes        int64   `json:"raw_bytes"`
+	CompressedBytes int64   `json:"compressed_bytes"`
+	Ratio           float64 `json:"ratio"` // raw_bytes / compressed_bytes
+	Hits            int64   `json:"hits"`
+	Errors          int64   `json:"errors"`
+}
+
+func newCompressor(thresholdKB int) *compressor {
+	if thresholdKB <= 0 {
+		return nil
+	}
+	return &compressor{
+		threshold: int64(thresholdKB) * 1024,
+		writers: sync.Pool{New: func() any {
+			// BestSpeed: entries are compressed on the request path
+			w, _ := flate.NewWriter(nil, flate.BestSpeed)
+			return w
+		}},
+	}
+}
+
+// compress returns data compressed, or nil when it is below the threshold,
+// can't be encoded, or doesn't shrink
+func (z *compressor) compress(data []map[string]any, sizeBytes int64) []byte {
+	if z == nil || sizeBytes < z.threshold {
+		return nil
+	}
+	var buf bytes.Buffer
+	w := z.writers.Get().(*flate.Writer)
+	defer z.writers.Put(w)
+	w.Reset(&buf)
+	if err := gob.NewEncoder(w).Encode(data); err != nil || w.Close() != nil {
+		z.skipped.Add(1)
+		return nil
+	}
+	if int64(buf.Len()) >= sizeBytes {
+		z.skipped.Add(1)
+		return nil
+	}
+	z.entries.Add(1)
+	z.rawBytes.Add(sizeBytes)
+	z.compressedBytes.Add(int64(buf.Len()))
+	return buf.Bytes()
+}
+
+// decompress restores an entry stored by compress
+func (z *compressor) decompress(blob []byte) ([]map[string]any, bool) {
+	r := flate.NewReader(bytes.NewReader(blob))
+	defer func() { _ = r.Close() }()
+	var data []map[string]any
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		z.errors.Add(1)
+		return nil, false
+	}
+	z.hits.Add(1)
+	return data, true
+}
+
+func (z *compressor) snapshot() *CompressionSnapshot {
+	if z == nil {
+		return nil
+	}
+	snap := &CompressionSnapshot{
+		ThresholdBytes:  z.threshold,
+		Entries:         z.entries.Load(),
+		Skipped:         z.skipped.Load(),
+		RawBytes:        z.rawBytes.Load(),
+		CompressedBytes: z.compressedBytes.Load(),
+		Hits:            z.hits.Load(),
+		Errors:          z.errors.Load(),
+	}
+	if snap.CompressedBytes > 0 {
+		snap.Ratio = float64(snap.RawBytes) / float64(snap.CompressedBytes)
+	}
+	return snap
+}
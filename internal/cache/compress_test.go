@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"sql-proxy/internal/config"
+)
+
+// TestCache_Compression tests that large entries are stored compressed and read back intact
+func TestCache_Compression(t *testing.T) {
+	c, err := New(config.CacheConfig{Enabled: true, MaxSizeMB: 64, CompressAboveKB: 1})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer c.Close()
+	_ = c.RegisterEndpoint("report", &config.EndpointCacheConfig{Enabled: true})
+
+	large := make([]map[string]any, 100)
+	for i := range large {
+		large[i] = map[string]any{"id": int64(i), "name": strings.Repeat("x", 50), "active": true}
+	}
+	small := []map[string]any{{"id": int64(1)}}
+	c.Set("report", "large", large, time.Minute)
+	c.Set("report", "small", small, time.Minute)
+	c.store.Wait()
+
+	val, _ := c.store.Get("report:large")
+	if entry := val.(*Entry); entry.Compressed == nil || entry.Data != nil {
+		t.Fatal("expected the large entry to be stored compressed")
+	}
+	val, _ = c.store.Get("report:small")
+	if entry := val.(*Entry); entry.Compressed != nil {
+		t.Error("expected the small entry to be stored as is")
+	}
+
+	data, hit := c.Get("report", "large")
+	if !hit || len(data) != 100 || data[99]["id"] != int64(99) || data[99]["active"] != true {
+		t.Fatalf("unexpected data after decompression: hit=%v len=%d", hit, len(data))
+	}
+
+	snap := c.GetSnapshot()
+	z := snap.Compression
+	if z == nil || z.Entries != 1 || z.Hits != 1 || z.ThresholdBytes != 1024 {
+		t.Fatalf("unexpected compression snapshot: %+v", z)
+	}
+	if z.CompressedBytes >= z.RawBytes || z.Ratio <= 1 {
+		t.Errorf("expected the entry to shrink: %+v", z)
+	}
+	// Size limits count the compressed size
+	if want := z.CompressedBytes + calculateSize(small); snap.Endpoints["report"].SizeBytes != want {
+		t.Errorf("endpoint size = %d, want %d", snap.Endpoints["report"].SizeBytes, want)
+	}
+}
+
+// TestCache_CompressionDisabled tests that no entry is compressed without compress_above_kb
+func TestCache_CompressionDisabled(t *testing.T) {
+	c, err := New(config.CacheConfig{Enabled: true, MaxSizeMB: 64})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("report", "large", []map[string]any{{"blob": strings.Repeat("x", 4096)}}, time.Minute)
+	c.store.Wait()
+	val, _ := c.store.Get("report:large")
+	if entry := val.(*Entry); entry.Compressed != nil {
+		t.Error("expected the entry to be stored as is")
+	}
+	if c.GetSnapshot().Compression != nil {
+		t.Error("expected no compression snapshot")
+	}
+}
+
+// TestCompressor_Skip tests that entries below the threshold or that don't shrink are stored as is
+func TestCompressor_Skip(t *testing.T) {
+	z := newCompressor(1)
+
+	if blob := z.compress([]map[string]any{{"id": int64(1)}}, 10); blob != nil {
+		t.Error("expected an entry below the threshold to be skipped")
+	}
+
+	// Random bytes gain nothing from DEFLATE, and gob adds framing
+	noise := make([]byte, 2048)
+	_, _ = rand.New(rand.NewSource(1)).Read(noise)
+	if blob := z.compress([]map[string]any{{"blob": noise}}, int64(len(noise))); blob != nil {
+		t.Error("expected an entry that doesn't shrink to be skipped")
+	}
+
+	if snap := z.snapshot(); snap.Entries != 0 || snap.Skipped != 1 {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+}
@@ -0,0 +1,313 @@
+package cache
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"sql-proxy/internal/config"
+)
+
+const (
+	// defaultDiskMaxSizeMB is the default size limit of the disk tier
+	defaultDiskMaxSizeMB = 1024
+
+	// tagSeparator joins an entry's tags in the disk tier; tags are rendered from templates and never contain it
+	tagSeparator = "\x1f"
+)
+
+func init() {
+	// Types query and httpcall results carry inside interface values, beyond gob's built-in ones
+	gob.Register(time.Time{})
+	gob.Register(map[string]any{})
+	gob.Register([]any{})
+	gob.Register([]map[string]any{})
+}
+
+// diskStore is the cache's second tier: entries in a local SQLite file, so they
+// survive restarts and memory evictions. A nil *diskStore stores nothing.
+type diskStore struct {
+	conn    *sql.DB
+	path    string
+	maxSize int64
+	maxTTL  time.Duration // Caps how long entries stay on disk (0 = their TTL)
+	minSize int64         // Smaller entries stay in memory only
+
+	mu   sync.Mutex // Serializes writes so size and keys stay in step with the table
+	size int64
+	keys int64
+
+	hits   atomic.Int64
+	errors atomic.Int64 // Failed reads and writes; the memory tier carries on without the disk
+}
+
+// DiskSnapshot reports the disk tier's usage
+type DiskSnapshot struct {
+	Path         string `json:"path"`
+	SizeBytes    int64  `json:"size_bytes"`
+	MaxSizeBytes int64  `json:"max_size_bytes"`
+	Keys         int64  `json:"keys"`
+	Hits         int64  `json:"hits"`   // Memory misses served from disk
+	Errors       int64  `json:"errors"` // Failed reads and writes
+}
+
+// diskTags are the tags of an entry on disk, to rebuild the tag index at startup
+type diskTags struct {
+	ref       entryRef
+	tags      []string
+	expiresAt time.Time
+}
+
+func openDiskStore(cfg *config.DiskCacheConfig) (*diskStore, error) {
+	conn, err := sql.Open("sqlite", cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk cache: %w", err)
+	}
+	// A single connection serializes writes and keeps the pragmas in effect
+	conn.SetMaxOpenConns(1)
+
+	stmts := []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA synchronous = NORMAL",
+		"PRAGMA busy_timeout = 5000",
+		`CREATE TABLE IF NOT EXISTS cache_entries (
+			endpoint TEXT NOT NULL,
+			key TEXT NOT NULL,
+			data BLOB NOT NULL,
+			tags TEXT NOT NULL,
+			stored_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL,
+			PRIMARY KEY (endpoint, key)
+		)`,
+		"CREATE INDEX IF NOT EXISTS cache_entries_stored_at ON cache_entries (stored_at)",
+	}
+	for _, stmt := range stmts {
+		if _, err := conn.Exec(stmt); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to initialize disk cache %s: %w", cfg.Path, err)
+		}
+	}
+
+	maxSize := int64(cfg.MaxSizeMB) * 1024 * 1024
+	if maxSize == 0 {
+		maxSize = defaultDiskMaxSizeMB * 1024 * 1024
+	}
+	d := &diskStore{
+		conn:    conn,
+		path:    cfg.Path,
+		maxSize: maxSize,
+		maxTTL:  time.Duration(cfg.MaxTTLSec) * time.Second,
+		minSize: int64(cfg.MinSizeKB) * 1024,
+	}
+
+	// Entries that expired while the server was down are dropped before counting the rest
+	if _, err := conn.Exec("DELETE FROM cache_entries WHERE expires_at <= ?", time.Now().UnixMilli()); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to initialize disk cache %s: %w", cfg.Path, err)
+	}
+	if err := conn.QueryRow("SELECT COALESCE(SUM(length(data)), 0), COUNT(*) FROM cache_entries").Scan(&d.size, &d.keys); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to initialize disk cache %s: %w", cfg.Path, err)
+	}
+	return d, nil
+}
+
+// get returns an unexpired entry and how long it has left
+func (d *diskStore) get(endpoint, key string) ([]map[string]any, time.Duration, bool) {
+	if d == nil {
+		return nil, 0, false
+	}
+	var blob []byte
+	var expiresAt int64
+	err := d.conn.QueryRow("SELECT data, expires_at FROM cache_entries WHERE endpoint = ? AND key = ? AND expires_at > ?",
+		endpoint, key, time.Now().UnixMilli()).Scan(&blob, &expiresAt)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			d.errors.Add(1)
+		}
+		return nil, 0, false
+	}
+	var data []map[string]any
+	if err := gob.NewDecoder(bytes.NewReader(blob)).Decode(&data); err != nil {
+		d.errors.Add(1)
+		return nil, 0, false
+	}
+	d.hits.Add(1)
+	return data, time.Until(time.UnixMilli(expiresAt)), true
+}
+
+// has reports whether an unexpired entry is stored
+func (d *diskStore) has(endpoint, key string) bool {
+	if d == nil {
+		return false
+	}
+	var n int
+	err := d.conn.QueryRow("SELECT COUNT(*) FROM cache_entries WHERE endpoint = ? AND key = ? AND expires_at > ?",
+		endpoint, key, time.Now().UnixMilli()).Scan(&n)
+	return err == nil && n > 0
+}
+
+// set stores an entry for ttl, capped by the tier's max TTL. Entries below the
+// tier's minimum size are not stored, and replace any older copy on disk.
+// Returns whether the entry was stored.
+func (d *diskStore) set(endpoint, key string, data []map[string]any, sizeBytes int64, ttl time.Duration, tags []string) bool {
+	if d == nil {
+		return false
+	}
+	if sizeBytes < d.minSize {
+		d.delete(endpoint, key)
+		return false
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+		d.errors.Add(1)
+		return false
+	}
+	if int64(buf.Len()) > d.maxSize {
+		d.delete(endpoint, key)
+		return false
+	}
+	if d.maxTTL > 0 {
+		ttl = min(ttl, d.maxTTL)
+	}
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var old int64
+	err := d.conn.QueryRow("SELECT length(data) FROM cache_entries WHERE endpoint = ? AND key = ?", endpoint, key).Scan(&old)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		d.errors.Add(1)
+		return false
+	}
+	_, err = d.conn.Exec(`
+		INSERT INTO cache_entries (endpoint, key, data, tags, stored_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (endpoint, key) DO UPDATE SET
+			data = excluded.data, tags = excluded.tags, stored_at = excluded.stored_at, expires_at = excluded.expires_at`,
+		endpoint, key, buf.Bytes(), strings.Join(tags, tagSeparator), now.UnixMilli(), now.Add(ttl).UnixMilli())
+	if err != nil {
+		d.errors.Add(1)
+		return false
+	}
+	d.size += int64(buf.Len()) - old
+	if old == 0 {
+		d.keys++
+	}
+	d.evictLocked(now)
+	return true
+}
+
+// evictLocked removes expired entries, then the oldest ones, until the tier
+// fits its size limit. Caller must hold mu.
+func (d *diskStore) evictLocked(now time.Time) {
+	if d.size <= d.maxSize {
+		return
+	}
+	d.deleteLocked("DELETE FROM cache_entries WHERE expires_at <= ? RETURNING length(data)", now.UnixMilli())
+	for d.size > d.maxSize && d.keys > 0 {
+		if d.deleteLocked("DELETE FROM cache_entries WHERE rowid = (SELECT rowid FROM cache_entries ORDER BY stored_at LIMIT 1) RETURNING length(data)") == 0 {
+			return
+		}
+	}
+}
+
+// deleteLocked runs a DELETE ... RETURNING length(data) and accounts for the
+// removed entries. Returns how many were removed. Caller must hold mu.
+func (d *diskStore) deleteLocked(query string, args ...any) int {
+	rows, err := d.conn.Query(query, args...)
+	if err != nil {
+		d.errors.Add(1)
+		return 0
+	}
+	defer func() { _ = rows.Close() }()
+	removed := 0
+	for rows.Next() {
+		var n int64
+		if rows.Scan(&n) == nil {
+			d.size -= n
+			d.keys--
+			removed++
+		}
+	}
+	if rows.Err() != nil {
+		d.errors.Add(1)
+	}
+	return removed
+}
+
+// delete removes an entry
+func (d *diskStore) delete(endpoint, key string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deleteLocked("DELETE FROM cache_entries WHERE endpoint = ? AND key = ? RETURNING length(data)", endpoint, key)
+}
+
+// clear removes all entries of an endpoint, or every entry when endpoint is ""
+func (d *diskStore) clear(endpoint string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if endpoint == "" {
+		d.deleteLocked("DELETE FROM cache_entries RETURNING length(data)")
+		return
+	}
+	d.deleteLocked("DELETE FROM cache_entries WHERE endpoint = ? RETURNING length(data)", endpoint)
+}
+
+// tags returns the tags of the unexpired entries that have any
+func (d *diskStore) tags() ([]diskTags, error) {
+	rows, err := d.conn.Query("SELECT endpoint, key, tags, expires_at FROM cache_entries WHERE tags != '' AND expires_at > ?", time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var all []diskTags
+	for rows.Next() {
+		var dt diskTags
+		var tags string
+		var expiresAt int64
+		if err := rows.Scan(&dt.ref.endpoint, &dt.ref.key, &tags, &expiresAt); err != nil {
+			return nil, err
+		}
+		dt.tags = strings.Split(tags, tagSeparator)
+		dt.expiresAt = time.UnixMilli(expiresAt)
+		all = append(all, dt)
+	}
+	return all, rows.Err()
+}
+
+func (d *diskStore) snapshot() *DiskSnapshot {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &DiskSnapshot{
+		Path:         d.path,
+		SizeBytes:    d.size,
+		MaxSizeBytes: d.maxSize,
+		Keys:         d.keys,
+		Hits:         d.hits.Load(),
+		Errors:       d.errors.Load(),
+	}
+}
+
+func (d *diskStore) close() error {
+	if d == nil {
+		return nil
+	}
+	return d.conn.Close()
+}
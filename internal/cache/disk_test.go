@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sql-proxy/internal/config"
+)
+
+func newDiskCache(t *testing.T, path string, disk config.DiskCacheConfig) *Cache {
+	t.Helper()
+	disk.Path = path
+	c, err := New(config.CacheConfig{Enabled: true, MaxSizeMB: 64, Disk: &disk})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	return c
+}
+
+// TestDiskTier_SurvivesRestart tests that entries, their types, and their tags outlive the cache
+func TestDiskTier_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	c := newDiskCache(t, path, config.DiskCacheConfig{})
+	c.SetWithTags("report", "q1", []map[string]any{{"id": int64(1), "created": created, "note": nil}}, time.Hour, []string{"reports"})
+	c.Set("report", "q2", []map[string]any{{"id": int64(2)}}, time.Hour)
+	c.Close()
+
+	c = newDiskCache(t, path, config.DiskCacheConfig{})
+	defer c.Close()
+	data, hit := c.Get("report", "q1")
+	if !hit {
+		t.Fatal("expected a hit from disk after restart")
+	}
+	if data[0]["id"] != int64(1) || data[0]["created"] != created || data[0]["note"] != nil {
+		t.Errorf("unexpected data from disk: %#v", data)
+	}
+	if snap := c.GetSnapshot(); snap.Disk == nil || snap.Disk.Keys != 2 || snap.Disk.Hits != 1 {
+		t.Errorf("unexpected disk snapshot: %+v", snap.Disk)
+	}
+
+	if n := c.InvalidateTag("reports"); n != 1 {
+		t.Errorf("InvalidateTag() = %d, want 1", n)
+	}
+	time.Sleep(10 * time.Millisecond) // Wait for ristretto's async processing
+	if _, hit := c.Get("report", "q1"); hit {
+		t.Error("expected invalidated entry to be gone from both tiers")
+	}
+	if _, hit := c.Get("report", "q2"); !hit {
+		t.Error("expected untagged entry to remain")
+	}
+}
+
+// TestDiskTier_MemoryEviction tests that entries evicted from memory are served from disk and copied back
+func TestDiskTier_MemoryEviction(t *testing.T) {
+	c := newDiskCache(t, filepath.Join(t.TempDir(), "cache.db"), config.DiskCacheConfig{})
+	defer c.Close()
+
+	c.Set("report", "q1", []map[string]any{{"id": int64(1)}}, time.Hour)
+	c.store.Wait()
+	c.store.Del("report:q1")
+	c.store.Wait()
+
+	if _, hit := c.Get("report", "q1"); !hit {
+		t.Fatal("expected a hit from disk")
+	}
+	c.store.Wait()
+	if _, found := c.store.Get("report:q1"); !found {
+		t.Error("expected the disk hit to be copied back into memory")
+	}
+	if snap := c.GetSnapshot(); snap.Disk.Hits != 1 || snap.TotalHits != 1 || snap.TotalMisses != 0 {
+		t.Errorf("unexpected snapshot: %+v, disk %+v", snap, snap.Disk)
+	}
+
+	c.Delete("report", "q1")
+	if _, hit := c.Get("report", "q1"); hit {
+		t.Error("expected deleted entry to be gone from both tiers")
+	}
+}
+
+// TestDiskTier_Limits tests the disk tier's size, TTL, and minimum entry size limits
+func TestDiskTier_Limits(t *testing.T) {
+	c := newDiskCache(t, filepath.Join(t.TempDir(), "cache.db"), config.DiskCacheConfig{MaxTTLSec: 1, MinSizeKB: 1})
+	defer c.Close()
+
+	small := []map[string]any{{"id": int64(1)}}
+	large := []map[string]any{{"blob": string(make([]byte, 2048))}}
+	c.Set("report", "small", small, time.Hour)
+	c.Set("report", "large", large, time.Hour)
+	if c.disk.has("report", "small") || !c.disk.has("report", "large") {
+		t.Error("expected only the entry above min_size_kb on disk")
+	}
+
+	// Entries expire from disk after max_ttl_sec even when their TTL is longer
+	c.disk.mu.Lock()
+	_, err := c.disk.conn.Exec("UPDATE cache_entries SET expires_at = ?", time.Now().Add(-time.Millisecond).UnixMilli())
+	c.disk.mu.Unlock()
+	if err != nil {
+		t.Fatalf("failed to expire entries: %v", err)
+	}
+	if _, _, ok := c.disk.get("report", "large"); ok {
+		t.Error("expected expired entry to be skipped")
+	}
+
+	// Past max_size_mb the oldest entries go first, expired ones before them
+	c.disk.maxSize = 5000
+	for _, key := range []string{"a", "b", "c"} {
+		c.Set("report", key, large, time.Hour)
+		time.Sleep(2 * time.Millisecond) // Distinct stored_at
+	}
+	if c.disk.has("report", "a") || !c.disk.has("report", "b") || !c.disk.has("report", "c") {
+		t.Error("expected the oldest entry to be evicted")
+	}
+	if snap := c.disk.snapshot(); snap.Keys != 2 || snap.SizeBytes > snap.MaxSizeBytes {
+		t.Errorf("unexpected disk snapshot: %+v", snap)
+	}
+
+	c.Clear("report")
+	if snap := c.disk.snapshot(); snap.Keys != 0 || snap.SizeBytes != 0 {
+		t.Errorf("expected Clear to empty the disk tier, got %+v", snap)
+	}
+}
+
+// TestCache_MemoryMaxTTL tests that max_ttl_sec caps how long entries stay in memory
+func TestCache_MemoryMaxTTL(t *testing.T) {
+	c, err := New(config.CacheConfig{Enabled: true, MaxSizeMB: 64, MaxTTLSec: 1})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("report", "q1", []map[string]any{{"id": 1}}, time.Hour)
+	c.store.Wait()
+	if ttl, _ := c.store.GetTTL("report:q1"); ttl > time.Second {
+		t.Errorf("memory TTL = %v, want at most 1s", ttl)
+	}
+}
@@ -0,0 +1,241 @@
+// Package cassette records outgoing HTTP requests and their responses to a
+// file, and answers requests from that file later, so workflows calling
+// third-party APIs can be tested deterministically and offline.
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"sql-proxy/internal/redact"
+)
+
+// Client sends HTTP requests (satisfied by *http.Client)
+type Client interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Cassette is a recording of HTTP interactions
+type Cassette struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+// Interaction is a request and the response it got
+type Interaction struct {
+	Request  Request  `yaml:"request"`
+	Response Response `yaml:"response"`
+}
+
+// Request is a recorded request. Redacted values are stored masked.
+type Request struct {
+	Method  string            `yaml:"method"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+}
+
+// Load reads a cassette file
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var c Cassette
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	for i, in := range c.Interactions {
+		if in.Request.Method == "" || in.Request.URL == "" {
+			return nil, fmt.Errorf("cassette %s: interactions[%d]: request method and url are required", path, i)
+		}
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path, replacing the file at once so a reader
+// never sees part of it
+func (c *Cassette) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Recorder sends requests through a client and appends each interaction to a
+// cassette file, which it starts afresh
+type Recorder struct {
+	client   Client
+	path     string
+	redactor *redact.Redactor
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder records the requests sent through client to the cassette at path,
+// masking what redactor redacts
+func NewRecorder(client Client, path string, redactor *redact.Redactor) *Recorder {
+	return &Recorder{client: client, path: path, redactor: redactor}
+}
+
+// Do sends the request and records it with its response. Requests that get no
+// response are not recorded.
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	in := Interaction{
+		Request: recordRequest(req, reqBody, r.redactor),
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: r.redactor.Headers(resp.Header),
+			Body:    r.redactor.Body(resp.Header.Get("Content-Type"), respBody),
+		},
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	if err := r.cassette.Save(r.path); err != nil {
+		return nil, fmt.Errorf("failed to save cassette: %w", err)
+	}
+	return resp, nil
+}
+
+// Player answers requests from a cassette without sending them
+type Player struct {
+	path     string
+	redactor *redact.Redactor
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewPlayer answers requests from the cassette at path. Requests are compared
+// with the recording after masking what redactor redacts, so it must be the
+// redactor the cassette was recorded with.
+func NewPlayer(path string, redactor *redact.Redactor) (*Player, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Player{path: path, redactor: redactor, cassette: c, used: make([]bool, len(c.Interactions))}, nil
+}
+
+// Do answers with the first unused interaction whose method, URL and body match
+// the request, or, once all of those were used, the last of them. Headers are
+// not compared.
+func (p *Player) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	want := recordRequest(req, body, p.redactor)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	match := -1
+	for i, in := range p.cassette.Interactions {
+		if in.Request.Method != want.Method || in.Request.URL != want.URL || in.Request.Body != want.Body {
+			continue
+		}
+		match = i
+		if !p.used[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("no interaction of cassette %s matches %s %s", p.path, want.Method, want.URL)
+	}
+	p.used[match] = true
+	return p.cassette.Interactions[match].Response.http(req), nil
+}
+
+// recordRequest returns the request as recorded, redacted
+func recordRequest(req *http.Request, body []byte, redactor *redact.Redactor) Request {
+	u := *req.URL
+	u.RawQuery = redactor.Query(u.RawQuery)
+	return Request{
+		Method:  req.Method,
+		URL:     redactURL(&u),
+		Headers: redactor.Headers(req.Header),
+		Body:    redactor.Body(req.Header.Get("Content-Type"), body),
+	}
+}
+
+// redactURL formats u without its password
+func redactURL(u *url.URL) string {
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redact.Mask)
+	}
+	s := u.String()
+	return strings.ReplaceAll(s, url.QueryEscape(redact.Mask), redact.Mask)
+}
+
+// http returns the recorded response as an *http.Response to req
+func (r Response) http(req *http.Request) *http.Response {
+	header := make(http.Header, len(r.Headers))
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// readBody reads a request or response body and replaces it with a copy, so
+// it can still be sent or read
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	_ = (*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
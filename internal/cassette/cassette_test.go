@@ -0,0 +1,122 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sql-proxy/internal/redact"
+)
+
+// TestRecorder verifies interactions are saved to the cassette with sensitive values masked
+func TestRecorder(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		_, _ = w.Write([]byte(`{"echo": ` + string(body) + `, "token": "t-123"}`))
+	}))
+	defer api.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "api.yaml")
+	redactor, err := redact.New(redact.Config{Fields: []string{"token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder(http.DefaultClient, path, redactor)
+
+	req, _ := http.NewRequest("POST", api.URL+"/items?token=abc&page=1", strings.NewReader(`{"name": "x"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := rec.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `"token": "t-123"`) {
+		t.Errorf("caller got %s, want the unmasked response", body)
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 1 {
+		t.Fatalf("got %d interactions, want 1", len(c.Interactions))
+	}
+	in := c.Interactions[0]
+	if in.Request.URL != api.URL+"/items?page=1&token=[REDACTED]" {
+		t.Errorf("url = %s", in.Request.URL)
+	}
+	if in.Request.Headers["Authorization"] != redact.Mask || in.Response.Headers["Set-Cookie"] != redact.Mask {
+		t.Errorf("headers not masked: %v, %v", in.Request.Headers, in.Response.Headers)
+	}
+	if in.Response.Status != 200 || strings.Contains(in.Response.Body, "t-123") || !strings.Contains(in.Response.Body, `"name":"x"`) {
+		t.Errorf("response = %+v", in.Response)
+	}
+}
+
+// TestPlayer verifies requests are answered in recorded order, repeating the last match once all are used
+func TestPlayer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.yaml")
+	cassette := `interactions:
+  - request: {method: GET, url: "https://api.example.com/status?key=[REDACTED]"}
+    response: {status: 200, body: "first"}
+  - request: {method: GET, url: "https://api.example.com/status?key=[REDACTED]"}
+    response: {status: 503, body: "second", headers: {Retry-After: "1"}}
+  - request: {method: POST, url: "https://api.example.com/items", body: '{"a":1}'}
+    response: {status: 201}
+`
+	if err := os.WriteFile(path, []byte(cassette), 0644); err != nil {
+		t.Fatal(err)
+	}
+	redactor, _ := redact.New(redact.Config{Fields: []string{"key"}})
+	p, err := NewPlayer(path, redactor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() (int, string) {
+		req, _ := http.NewRequest("GET", "https://api.example.com/status?key=other", nil)
+		resp, err := p.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	for i, want := range []string{"first", "second", "second"} {
+		if _, body := get(); body != want {
+			t.Errorf("call %d: body = %q, want %q", i+1, body, want)
+		}
+	}
+
+	req, _ := http.NewRequest("POST", "https://api.example.com/items", strings.NewReader(`{"a":1}`))
+	if resp, err := p.Do(req); err != nil || resp.StatusCode != 201 {
+		t.Errorf("post: %v, %v", resp, err)
+	}
+
+	req, _ = http.NewRequest("POST", "https://api.example.com/items", strings.NewReader(`{"a":2}`))
+	if _, err := p.Do(req); err == nil || !strings.Contains(err.Error(), "no interaction of cassette") {
+		t.Errorf("unmatched body: error = %v", err)
+	}
+}
+
+// TestLoad_Invalid verifies cassette files are checked when loaded
+func TestLoad_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected error for a missing cassette")
+	}
+	path := filepath.Join(dir, "cassette.yaml")
+	if err := os.WriteFile(path, []byte("interactions:\n  - request: {method: GET}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "method and url are required") {
+		t.Errorf("error = %v, want method and url required", err)
+	}
+}
@@ -0,0 +1,168 @@
+// Package chaos injects faults into database calls and workflow steps: errors,
+// dropped connections and added latency, at configured rates. It lets retries,
+// fallbacks and error handling be exercised in development and test
+// environments before an outage does it in production.
+package chaos
+
+import (
+	"cmp"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"sql-proxy/internal/logging"
+	"sql-proxy/internal/workflow/step"
+)
+
+// Config lists the faults to inject
+type Config struct {
+	Enabled   bool                        `yaml:"enabled"`   // Nothing is injected unless set
+	Seed      uint64                      `yaml:"seed"`      // Seed of the random rolls, for reproducible runs (0 = random)
+	Databases map[string]Fault            `yaml:"databases"` // Faults by database name, injected into each query and procedure call
+	Steps     map[string]map[string]Fault `yaml:"steps"`     // Faults by workflow then step name, injected into each run of the step (each attempt of httpcall steps)
+}
+
+// Fault is what happens to the calls of a database or step
+type Fault struct {
+	ErrorRate  float64 `yaml:"error_rate"`  // Fraction of calls failing with Error (0-1)
+	Error      string  `yaml:"error"`       // Message of injected errors (default: "injected fault")
+	ErrorClass string  `yaml:"error_class"` // deadlock, constraint_violation or timeout: lets on_error and status_map treat injected errors as such
+	DropRate   float64 `yaml:"drop_rate"`   // Fraction of database calls failing as if the connection dropped, which triggers a reconnect (0-1, databases only)
+	LatencyMs  int     `yaml:"latency_ms"`  // Delay added before each call
+	JitterMs   int     `yaml:"jitter_ms"`   // Random extra delay, up to this much
+}
+
+// DefaultError is the message of injected errors without one of their own
+const DefaultError = "injected fault"
+
+// ErrDropped is returned by calls failing as if the database connection dropped
+var ErrDropped = fmt.Errorf("chaos: connection dropped: %w", driver.ErrBadConn)
+
+// errorClasses are the classes an injected error may have
+var errorClasses = map[string]bool{
+	step.ErrorClassDeadlock:            true,
+	step.ErrorClassConstraintViolation: true,
+	step.ErrorClassTimeout:             true,
+}
+
+// Check validates a fault. Drops are only allowed on database faults.
+func (f Fault) Check(database bool) error {
+	switch {
+	case f.ErrorRate < 0 || f.ErrorRate > 1:
+		return fmt.Errorf("error_rate must be 0-1, got: %g", f.ErrorRate)
+	case f.DropRate < 0 || f.DropRate > 1:
+		return fmt.Errorf("drop_rate must be 0-1, got: %g", f.DropRate)
+	case f.ErrorRate+f.DropRate > 1:
+		return fmt.Errorf("error_rate and drop_rate add up to more than 1")
+	case f.DropRate > 0 && !database:
+		return fmt.Errorf("drop_rate only applies to databases")
+	case f.ErrorClass != "" && !errorClasses[f.ErrorClass]:
+		return fmt.Errorf("invalid error_class '%s' (must be deadlock, constraint_violation, or timeout)", f.ErrorClass)
+	case f.LatencyMs < 0 || f.JitterMs < 0:
+		return fmt.Errorf("latency_ms and jitter_ms cannot be negative")
+	}
+	return nil
+}
+
+// Injector injects the faults of a Config. A nil Injector injects nothing.
+type Injector struct {
+	databases map[string]Fault
+	steps     map[string]map[string]Fault
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New returns the injector of cfg, or nil if cfg is not enabled
+func New(cfg Config) (*Injector, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	for name, f := range cfg.Databases {
+		if err := f.Check(true); err != nil {
+			return nil, fmt.Errorf("databases.%s: %w", name, err)
+		}
+	}
+	for wf, steps := range cfg.Steps {
+		for name, f := range steps {
+			if err := f.Check(false); err != nil {
+				return nil, fmt.Errorf("steps.%s.%s: %w", wf, name, err)
+			}
+		}
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &Injector{
+		databases: cfg.Databases,
+		steps:     cfg.Steps,
+		rng:       rand.New(rand.NewPCG(seed, seed)),
+	}, nil
+}
+
+// Database waits the latency added to a call of the database and returns the
+// error the call fails with, or nil
+func (in *Injector) Database(ctx context.Context, name string) error {
+	if in == nil {
+		return nil
+	}
+	f, ok := in.databases[name]
+	if !ok {
+		return nil
+	}
+	return in.inject(ctx, f, map[string]any{"database": name})
+}
+
+// Step waits the latency added to a run of the step and returns the error it
+// fails with, or nil
+func (in *Injector) Step(ctx context.Context, workflow, name string) error {
+	if in == nil {
+		return nil
+	}
+	f, ok := in.steps[workflow][name]
+	if !ok {
+		return nil
+	}
+	return in.inject(ctx, f, map[string]any{"workflow": workflow, "step": name})
+}
+
+func (in *Injector) inject(ctx context.Context, f Fault, fields map[string]any) error {
+	in.mu.Lock()
+	delay := time.Duration(f.LatencyMs) * time.Millisecond
+	if f.JitterMs > 0 {
+		delay += time.Duration(in.rng.IntN(f.JitterMs+1)) * time.Millisecond
+	}
+	roll := in.rng.Float64()
+	in.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	var err error
+	switch {
+	case roll < f.DropRate:
+		err = ErrDropped
+	case roll < f.DropRate+f.ErrorRate:
+		err = errors.New(cmp.Or(f.Error, DefaultError))
+		if f.ErrorClass != "" {
+			err = &step.ClassifiedError{Class: f.ErrorClass, Err: err}
+		}
+	default:
+		return nil
+	}
+	fields["error"] = err.Error()
+	logging.Debug("chaos_fault_injected", fields)
+	return err
+}
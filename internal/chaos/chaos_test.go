@@ -0,0 +1,107 @@
+package chaos
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+// TestNew verifies disabled configs inject nothing and invalid faults are rejected
+func TestNew(t *testing.T) {
+	in, err := New(Config{Databases: map[string]Fault{"db": {ErrorRate: 1}}})
+	if err != nil || in != nil {
+		t.Fatalf("disabled: injector = %v, error = %v, want nil", in, err)
+	}
+	if err := in.Database(context.Background(), "db"); err != nil {
+		t.Errorf("nil injector: error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"rate above 1", Config{Databases: map[string]Fault{"db": {ErrorRate: 1.5}}}, "error_rate must be 0-1"},
+		{"rates sum above 1", Config{Databases: map[string]Fault{"db": {ErrorRate: 0.6, DropRate: 0.6}}}, "add up to more than 1"},
+		{"drop on step", Config{Steps: map[string]map[string]Fault{"wf": {"s": {DropRate: 0.1}}}}, "steps.wf.s: drop_rate only applies to databases"},
+		{"invalid class", Config{Databases: map[string]Fault{"db": {ErrorClass: "oops"}}}, "invalid error_class"},
+		{"negative latency", Config{Databases: map[string]Fault{"db": {LatencyMs: -1}}}, "cannot be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Enabled = true
+			if _, err := New(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestInjector verifies errors, drops and latency are injected into the configured databases and steps only
+func TestInjector(t *testing.T) {
+	in, err := New(Config{
+		Enabled: true,
+		Seed:    1,
+		Databases: map[string]Fault{
+			"flaky":   {ErrorRate: 1, Error: "boom", ErrorClass: step.ErrorClassDeadlock},
+			"dropped": {DropRate: 1},
+			"slow":    {LatencyMs: 20},
+		},
+		Steps: map[string]map[string]Fault{"wf": {"call": {ErrorRate: 1}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	err = in.Database(ctx, "flaky")
+	var ce *step.ClassifiedError
+	if !errors.As(err, &ce) || ce.Class != step.ErrorClassDeadlock || err.Error() != "boom" {
+		t.Errorf("flaky: error = %v, want classified deadlock boom", err)
+	}
+	if err := in.Database(ctx, "dropped"); !errors.Is(err, driver.ErrBadConn) {
+		t.Errorf("dropped: error = %v, want driver.ErrBadConn", err)
+	}
+	if err := in.Database(ctx, "other"); err != nil {
+		t.Errorf("other: error = %v", err)
+	}
+	if err := in.Step(ctx, "wf", "call"); err == nil || err.Error() != DefaultError {
+		t.Errorf("step: error = %v, want %q", err, DefaultError)
+	}
+	if err := in.Step(ctx, "wf", "other"); err != nil {
+		t.Errorf("other step: error = %v", err)
+	}
+
+	start := time.Now()
+	if err := in.Database(ctx, "slow"); err != nil {
+		t.Errorf("slow: error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("slow: took %s, want at least 20ms", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := in.Database(cancelled, "slow"); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: error = %v, want context.Canceled", err)
+	}
+}
+
+// TestInjector_Rate verifies a seeded injector fails about the configured fraction of calls
+func TestInjector_Rate(t *testing.T) {
+	in, _ := New(Config{Enabled: true, Seed: 42, Databases: map[string]Fault{"db": {ErrorRate: 0.3}}})
+	failed := 0
+	for range 1000 {
+		if in.Database(context.Background(), "db") != nil {
+			failed++
+		}
+	}
+	if failed < 250 || failed > 350 {
+		t.Errorf("%d of 1000 calls failed, want about 300", failed)
+	}
+}
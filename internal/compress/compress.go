@@ -0,0 +1,286 @@
+// Package compress compresses HTTP responses with the encoding the client
+// prefers among brotli, zstd and gzip, as negotiated from Accept-Encoding.
+//
+// A response is held back until it reaches the minimum size, so small bodies
+// go out uncompressed, and responses whose content type is on the skip list
+// (images, archives and other already-compressed data) are never compressed.
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported encodings, as named in Accept-Encoding and Content-Encoding
+const (
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+	EncodingGzip   = "gzip"
+)
+
+// Encodings lists the supported encodings in the default order of preference,
+// used to break ties between encodings the client accepts equally
+var Encodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+
+// brotliLevel trades ratio for speed: responses are compressed on the request path
+const brotliLevel = 4
+
+// ValidEncoding reports whether e is a supported encoding
+func ValidEncoding(e string) bool {
+	return pools[e] != nil
+}
+
+// encoder is what the brotli, zstd and gzip writers have in common
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// pools reuse encoders, whose buffers are large to allocate per response
+var pools = map[string]*sync.Pool{
+	EncodingBrotli: {New: func() any {
+		return brotli.NewWriterLevel(io.Discard, brotliLevel)
+	}},
+	EncodingZstd: {New: func() any {
+		// One goroutine per encoder: responses are already compressed concurrently
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedFastest))
+		return enc
+	}},
+	EncodingGzip: {New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}},
+}
+
+// Config selects what is compressed and how
+type Config struct {
+	Encodings        []string // Offered encodings in order of preference (default: Encodings)
+	MinSizeBytes     int      // Smaller responses are sent uncompressed (0 = compress all)
+	SkipContentTypes []string // Media types never compressed; "image/*" matches a whole type
+}
+
+// Compressor negotiates and applies response compression
+type Compressor struct {
+	encodings []string
+	minSize   int
+	skipExact map[string]bool
+	skipTypes []string // Prefixes like "image/" from "image/*"
+}
+
+// New creates a compressor from cfg
+func New(cfg Config) *Compressor {
+	c := &Compressor{
+		encodings: cfg.Encodings,
+		minSize:   cfg.MinSizeBytes,
+		skipExact: make(map[string]bool),
+	}
+	if len(c.encodings) == 0 {
+		c.encodings = Encodings
+	}
+	for _, ct := range cfg.SkipContentTypes {
+		ct = strings.ToLower(strings.TrimSpace(ct))
+		if prefix, ok := strings.CutSuffix(ct, "/*"); ok {
+			c.skipTypes = append(c.skipTypes, prefix+"/")
+		} else {
+			c.skipExact[ct] = true
+		}
+	}
+	return c
+}
+
+// Negotiate returns the encoding to use for an Accept-Encoding header, or ""
+// when the client accepts none of the offered ones. The highest q-value wins;
+// ties go to the earlier offered encoding.
+func (c *Compressor) Negotiate(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	accepted := make(map[string]float64)
+	wildcard := -1.0
+	for part := range strings.SplitSeq(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name == "*" {
+			wildcard = q
+		} else {
+			accepted[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range c.encodings {
+		q, ok := accepted[enc]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// skip reports whether responses of contentType are sent uncompressed
+func (c *Compressor) skip(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if c.skipExact[mediaType] {
+		return true
+	}
+	for _, prefix := range c.skipTypes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler compresses the responses of next for clients that accept one of the offered encodings
+func (c *Compressor) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := c.Negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &responseWriter{ResponseWriter: w, c: c, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// responseWriter buffers a response until it reaches the minimum size, then
+// decides whether to compress it from its status and headers
+type responseWriter struct {
+	http.ResponseWriter
+	c        *Compressor
+	encoding string
+
+	buf     []byte // Written before the decision
+	status  int    // Set by WriteHeader before the decision
+	started bool   // Decided; headers are sent
+	enc     encoder
+}
+
+func (cw *responseWriter) WriteHeader(status int) {
+	if cw.started {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+	// Informational responses go out as is; the final one follows
+	if status >= 100 && status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		cw.status = 0
+	}
+}
+
+func (cw *responseWriter) Write(b []byte) (int, error) {
+	if !cw.started {
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < cw.c.minSize {
+			return len(b), nil
+		}
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// start sends the headers, compressing the body when compress is set and the
+// response allows it, then writes what was buffered
+func (cw *responseWriter) start(compress bool) error {
+	cw.started = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// Sniff the uncompressed body, as the server would without compression
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	status := cw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if compress && status != http.StatusNoContent && status != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" && !cw.c.skip(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length") // Length changes with compression
+		cw.enc = pools[cw.encoding].Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush sends what was written so far, so streamed responses (server-sent
+// events) reach the client as they are written. A stream flushed before it
+// reaches the minimum size is compressed anyway, as its final size is unknown.
+func (cw *responseWriter) Flush() {
+	if !cw.started {
+		if cw.start(true) != nil {
+			return
+		}
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *responseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close sends a response that is empty or never reached the minimum size
+// uncompressed, and finishes a compressed one
+func (cw *responseWriter) close() {
+	if !cw.started {
+		_ = cw.start(false)
+	}
+	if cw.enc == nil {
+		return
+	}
+	// Only reuse encoders that finished cleanly
+	if err := cw.enc.Close(); err == nil {
+		cw.enc.Reset(io.Discard)
+		pools[cw.encoding].Put(cw.enc)
+	}
+}
@@ -0,0 +1,226 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name      string
+		encodings []string
+		accept    string
+		want      string
+	}{
+		{name: "none", accept: "", want: ""},
+		{name: "gzip only", accept: "gzip, deflate", want: "gzip"},
+		{name: "server preference breaks ties", accept: "gzip, zstd, br", want: "br"},
+		{name: "highest q wins", accept: "br;q=0.5, gzip;q=0.9, zstd;q=0.8", want: "gzip"},
+		{name: "q=0 refuses", accept: "br;q=0, gzip", want: "gzip"},
+		{name: "wildcard", accept: "*", want: "br"},
+		{name: "wildcard below listed", accept: "zstd, *;q=0.1", want: "zstd"},
+		{name: "wildcard refuses rest", accept: "gzip;q=0.5, *;q=0", want: "gzip"},
+		{name: "case and spaces", accept: " GZIP ; q=0.7 ", want: "gzip"},
+		{name: "unsupported only", accept: "deflate, identity", want: ""},
+		{name: "malformed q ignored", accept: "br;q=abc, gzip", want: "gzip"},
+		{name: "configured order", encodings: []string{"gzip", "br"}, accept: "br, gzip, zstd", want: "gzip"},
+		{name: "configured subset", encodings: []string{"gzip"}, accept: "br, zstd", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(Config{Encodings: tt.encodings})
+			if got := c.Negotiate(tt.accept); got != tt.want {
+				t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+// decode reverses a response body's Content-Encoding
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	case EncodingZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("zstd reader: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	case EncodingGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("gzip reader: %v", err)
+		}
+		r = gr
+	default:
+		return string(body)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", encoding, err)
+	}
+	return string(out)
+}
+
+func TestHandler_Encodings(t *testing.T) {
+	body := strings.Repeat(`{"id": 1, "name": "widget"},`, 100)
+	handler := New(Config{}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "2800")
+		_, _ = io.WriteString(w, body[:1000])
+		_, _ = io.WriteString(w, body[1000:])
+	}))
+
+	for _, enc := range Encodings {
+		t.Run(enc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", enc)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Content-Encoding"); got != enc {
+				t.Fatalf("Content-Encoding = %q, want %q", got, enc)
+			}
+			if w.Header().Get("Content-Length") != "" {
+				t.Error("expected Content-Length to be removed")
+			}
+			if w.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Vary = %q", w.Header().Get("Vary"))
+			}
+			if w.Body.Len() >= len(body) {
+				t.Errorf("compressed size %d not below %d", w.Body.Len(), len(body))
+			}
+			if got := decode(t, enc, w.Body.Bytes()); got != body {
+				t.Errorf("decoded body mismatch: %d bytes", len(got))
+			}
+		})
+	}
+}
+
+func TestHandler_Skips(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string // "" lets the body be sniffed
+		status      int
+		encoding    string // Set by the handler
+		body        string
+		compressed  bool
+	}{
+		{name: "above min size", contentType: "application/json", body: strings.Repeat("a", 200), compressed: true},
+		{name: "below min size", contentType: "application/json", body: "small"},
+		{name: "empty", contentType: "application/json"},
+		{name: "skipped type", contentType: "image/png", body: strings.Repeat("a", 200)},
+		{name: "skipped wildcard with params", contentType: "video/mp4; codecs=avc1", body: strings.Repeat("a", 200)},
+		{name: "sniffed skipped type", body: "\x89PNG\x0D\x0A\x1A\x0A" + strings.Repeat("a", 200)},
+		{name: "sniffed text", body: strings.Repeat("a", 200), compressed: true},
+		{name: "already encoded", contentType: "application/json", encoding: "br", body: strings.Repeat("a", 200)},
+		{name: "not modified", status: http.StatusNotModified},
+		{name: "error status", contentType: "application/json", status: http.StatusInternalServerError, body: strings.Repeat("a", 200), compressed: true},
+	}
+	c := New(Config{MinSizeBytes: 100, SkipContentTypes: []string{"image/png", "video/*"}})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if tt.status != 0 && w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if compressed := w.Header().Get("Content-Encoding") == "gzip"; compressed != tt.compressed {
+				t.Fatalf("compressed = %v, want %v", compressed, tt.compressed)
+			}
+			if tt.encoding != "" && w.Header().Get("Content-Encoding") != tt.encoding {
+				t.Errorf("handler's Content-Encoding replaced with %q", w.Header().Get("Content-Encoding"))
+			}
+			got := w.Body.String()
+			if tt.compressed {
+				got = decode(t, EncodingGzip, w.Body.Bytes())
+			}
+			if got != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
+
+func TestHandler_FlushBelowMinSize(t *testing.T) {
+	c := New(Config{MinSizeBytes: 1024})
+	w := httptest.NewRecorder()
+	var flushed []byte
+	handler := c.Handler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(rw, "data: first\n\n")
+		if err := http.NewResponseController(rw).Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+		flushed = append([]byte(nil), w.Body.Bytes()...)
+		_, _ = io.WriteString(rw, "data: second\n\n")
+	}))
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Accept-Encoding", "zstd")
+	handler.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "zstd" {
+		t.Fatalf("expected a flushed stream to be compressed, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if len(flushed) == 0 {
+		t.Error("expected bytes to reach the client at the flush")
+	}
+	if got := decode(t, EncodingZstd, w.Body.Bytes()); got != "data: first\n\ndata: second\n\n" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestHandler_NotNegotiated(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	})
+	for _, tt := range []struct {
+		name, method, accept string
+	}{
+		{"no accept-encoding", "GET", ""},
+		{"unsupported", "GET", "deflate"},
+		{"head", "HEAD", "gzip"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			New(Config{}).Handler(inner).ServeHTTP(w, req)
+			if w.Header().Get("Content-Encoding") != "" {
+				t.Errorf("unexpected Content-Encoding %q", w.Header().Get("Content-Encoding"))
+			}
+			if tt.method == "GET" && w.Body.String() != "hello" {
+				t.Errorf("body = %q", w.Body.String())
+			}
+		})
+	}
+}
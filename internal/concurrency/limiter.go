@@ -0,0 +1,99 @@
+// Package concurrency provides slot-based limits on concurrent work.
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrLimitReached is returned when no slot frees up within the configured wait.
+var ErrLimitReached = errors.New("concurrency limit reached")
+
+// Limiter caps the number of concurrent holders with a counting semaphore.
+// A nil *Limiter imposes no limit, so callers can use it unconditionally.
+type Limiter struct {
+	slots chan struct{}
+	wait  time.Duration // How long Acquire queues for a slot (0 = reject immediately)
+
+	waiting  atomic.Int64
+	rejected atomic.Int64
+}
+
+// Stats is a point-in-time view of a limiter.
+type Stats struct {
+	Limit    int   `json:"limit"`
+	InUse    int   `json:"in_use"`
+	Waiting  int64 `json:"waiting"`
+	Rejected int64 `json:"rejected"` // Total acquisitions that gave up with ErrLimitReached
+}
+
+// New creates a limiter allowing max concurrent holders.
+// Returns nil (unlimited) when max is not positive.
+func New(max int, wait time.Duration) *Limiter {
+	if max <= 0 {
+		return nil
+	}
+	return &Limiter{
+		slots: make(chan struct{}, max),
+		wait:  wait,
+	}
+}
+
+// Acquire takes a slot, queuing up to the limiter's wait time for one to free up.
+// Returns ErrLimitReached if the wait runs out, or the context error if ctx ends first.
+// Every successful Acquire must be paired with a Release.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if l.wait <= 0 {
+		l.rejected.Add(1)
+		return ErrLimitReached
+	}
+
+	l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		l.rejected.Add(1)
+		return ErrLimitReached
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release returns a slot taken by Acquire.
+func (l *Limiter) Release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// Stats returns the limiter's current usage.
+func (l *Limiter) Stats() Stats {
+	if l == nil {
+		return Stats{}
+	}
+	return Stats{
+		Limit:    cap(l.slots),
+		InUse:    len(l.slots),
+		Waiting:  l.waiting.Load(),
+		Rejected: l.rejected.Load(),
+	}
+}
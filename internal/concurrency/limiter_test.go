@@ -0,0 +1,96 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNew_Unlimited(t *testing.T) {
+	l := New(0, time.Second)
+	if l != nil {
+		t.Fatal("expected nil limiter for max 0")
+	}
+
+	// Nil limiter never blocks
+	for range 100 {
+		if err := l.Acquire(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	l.Release()
+	if s := l.Stats(); s != (Stats{}) {
+		t.Errorf("expected zero stats, got %+v", s)
+	}
+}
+
+func TestLimiter_RejectsWithoutWait(t *testing.T) {
+	l := New(2, 0)
+	ctx := context.Background()
+
+	for range 2 {
+		if err := l.Acquire(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := l.Acquire(ctx); !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("expected ErrLimitReached, got %v", err)
+	}
+
+	s := l.Stats()
+	if s.Limit != 2 || s.InUse != 2 || s.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	l.Release()
+	if err := l.Acquire(ctx); err != nil {
+		t.Errorf("expected slot after release, got %v", err)
+	}
+}
+
+func TestLimiter_WaitsForSlot(t *testing.T) {
+	l := New(1, time.Second)
+	ctx := context.Background()
+	_ = l.Acquire(ctx)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		l.Release()
+	}()
+
+	start := time.Now()
+	if err := l.Acquire(ctx); err != nil {
+		t.Fatalf("expected queued acquire to succeed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("expected acquire to wait for release, took %v", elapsed)
+	}
+}
+
+func TestLimiter_WaitTimeout(t *testing.T) {
+	l := New(1, 20*time.Millisecond)
+	ctx := context.Background()
+	_ = l.Acquire(ctx)
+
+	if err := l.Acquire(ctx); !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("expected ErrLimitReached after wait, got %v", err)
+	}
+	if s := l.Stats(); s.Waiting != 0 || s.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestLimiter_ContextCancelled(t *testing.T) {
+	l := New(1, time.Second)
+	_ = l.Acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if s := l.Stats(); s.Rejected != 0 {
+		t.Errorf("cancellation should not count as rejection, got %+v", s)
+	}
+}
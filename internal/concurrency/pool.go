@@ -0,0 +1,161 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when a pool's queue has no room for another caller.
+// It wraps ErrLimitReached, so callers handle both alike.
+var ErrQueueFull = fmt.Errorf("worker queue full: %w", ErrLimitReached)
+
+// Pool caps the number of concurrent holders like a Limiter, but queues the
+// callers that find every slot taken by priority: a freed slot goes to the
+// longest-waiting caller of the highest priority. A nil *Pool imposes no
+// limit, so callers can use it unconditionally.
+type Pool struct {
+	priorities []string // Lowest first
+	workers    int
+	queueSize  int
+	wait       time.Duration
+
+	mu       sync.Mutex
+	busy     int
+	queues   [][]*poolWaiter // Per priority, oldest first
+	queued   int
+	rejected []int64 // Per priority
+}
+
+type poolWaiter struct {
+	ready   chan struct{} // Closed when the waiter is handed a slot
+	granted bool
+}
+
+// PoolStats is a point-in-time view of a pool.
+type PoolStats struct {
+	Workers  int              `json:"workers"`
+	Busy     int              `json:"busy"`
+	Queued   map[string]int   `json:"queued"`   // Callers waiting for a slot per priority
+	Rejected map[string]int64 `json:"rejected"` // Total callers that gave up per priority (queue full or wait expired)
+}
+
+// NewPool creates a pool of workers slots for callers of the given priorities,
+// lowest first. At most queueSize callers wait for a slot (0 = unbounded), each
+// for at most wait (0 = until its context ends).
+func NewPool(workers, queueSize int, wait time.Duration, priorities []string) *Pool {
+	return &Pool{
+		priorities: priorities,
+		queueSize:  queueSize,
+		wait:       wait,
+		workers:    workers,
+		queues:     make([][]*poolWaiter, len(priorities)),
+		rejected:   make([]int64, len(priorities)),
+	}
+}
+
+// level returns the queue index of a priority; unknown priorities queue lowest
+func (p *Pool) level(priority string) int {
+	return max(slices.Index(p.priorities, priority), 0)
+}
+
+// Acquire takes a slot, queuing by priority while none is free. Returns
+// ErrQueueFull if the queue is full, ErrLimitReached if the pool's wait runs
+// out, or the context error if ctx ends first. Every successful Acquire must
+// be paired with a Release.
+func (p *Pool) Acquire(ctx context.Context, priority string) error {
+	if p == nil {
+		return nil
+	}
+	level := p.level(priority)
+
+	p.mu.Lock()
+	if p.busy < p.workers { // Release hands slots to waiters, so none are queued
+		p.busy++
+		p.mu.Unlock()
+		return nil
+	}
+	if p.queueSize > 0 && p.queued >= p.queueSize {
+		p.rejected[level]++
+		p.mu.Unlock()
+		return ErrQueueFull
+	}
+	w := &poolWaiter{ready: make(chan struct{})}
+	p.queues[level] = append(p.queues[level], w)
+	p.queued++
+	p.mu.Unlock()
+
+	var expired <-chan time.Time
+	if p.wait > 0 {
+		timer := time.NewTimer(p.wait)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-w.ready:
+		return nil
+	case <-expired:
+		return p.abandon(w, level, ErrLimitReached)
+	case <-ctx.Done():
+		return p.abandon(w, level, ctx.Err())
+	}
+}
+
+// abandon takes a waiter that gave up out of its queue. A waiter handed a slot
+// in the meantime keeps it and succeeds.
+func (p *Pool) abandon(w *poolWaiter, level int, err error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if w.granted {
+		return nil
+	}
+	if i := slices.Index(p.queues[level], w); i >= 0 {
+		p.queues[level] = slices.Delete(p.queues[level], i, i+1)
+		p.queued--
+	}
+	if errors.Is(err, ErrLimitReached) {
+		p.rejected[level]++
+	}
+	return err
+}
+
+// Release returns a slot taken by Acquire, handing it to the next waiter.
+func (p *Pool) Release() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for level := len(p.queues) - 1; level >= 0; level-- {
+		if len(p.queues[level]) == 0 {
+			continue
+		}
+		w := p.queues[level][0]
+		p.queues[level] = p.queues[level][1:]
+		p.queued--
+		w.granted = true
+		close(w.ready)
+		return
+	}
+	p.busy--
+}
+
+// Stats returns the pool's current usage.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := PoolStats{
+		Workers:  p.workers,
+		Busy:     p.busy,
+		Queued:   make(map[string]int, len(p.priorities)),
+		Rejected: make(map[string]int64, len(p.priorities)),
+	}
+	for i, priority := range p.priorities {
+		stats.Queued[priority] = len(p.queues[i])
+		stats.Rejected[priority] = p.rejected[i]
+	}
+	return stats
+}
@@ -0,0 +1,133 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPriorities = []string{"low", "normal", "high"}
+
+// waitQueued waits until the pool has n callers queued
+func waitQueued(t *testing.T, p *Pool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		p.mu.Lock()
+		queued := p.queued
+		p.mu.Unlock()
+		if queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d queued callers, got %d", n, queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPool_Unlimited(t *testing.T) {
+	var p *Pool
+	for range 100 {
+		if err := p.Acquire(context.Background(), "low"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	p.Release()
+}
+
+func TestPool_PriorityOrder(t *testing.T) {
+	p := NewPool(1, 0, 0, testPriorities)
+	ctx := context.Background()
+	if err := p.Acquire(ctx, "normal"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Queue low, normal, high, low in that order; each waiter records when it runs
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	for i, priority := range []string{"low", "normal", "high", "low"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.Acquire(ctx, priority); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, priority)
+			mu.Unlock()
+			p.Release()
+		}()
+		waitQueued(t, p, i+1)
+	}
+
+	if st := p.Stats(); st.Busy != 1 || st.Queued["low"] != 2 || st.Queued["normal"] != 1 || st.Queued["high"] != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	p.Release()
+	wg.Wait()
+	if want := []string{"high", "normal", "low", "low"}; !slices.Equal(order, want) {
+		t.Errorf("ran in order %v, want %v", order, want)
+	}
+	if st := p.Stats(); st.Busy != 0 || st.Queued["low"] != 0 {
+		t.Errorf("unexpected stats after release: %+v", st)
+	}
+}
+
+func TestPool_QueueFull(t *testing.T) {
+	p := NewPool(1, 1, 0, testPriorities)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_ = p.Acquire(ctx, "high")
+
+	go func() { _ = p.Acquire(ctx, "high") }()
+	waitQueued(t, p, 1)
+
+	err := p.Acquire(ctx, "low")
+	if !errors.Is(err, ErrQueueFull) || !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("expected ErrQueueFull wrapping ErrLimitReached, got %v", err)
+	}
+	if st := p.Stats(); st.Rejected["low"] != 1 || st.Rejected["high"] != 0 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+}
+
+func TestPool_WaitExpires(t *testing.T) {
+	p := NewPool(1, 0, 10*time.Millisecond, testPriorities)
+	ctx := context.Background()
+	_ = p.Acquire(ctx, "normal")
+
+	if err := p.Acquire(ctx, "normal"); !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("expected ErrLimitReached, got %v", err)
+	}
+	st := p.Stats()
+	if st.Queued["normal"] != 0 || st.Rejected["normal"] != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	// The expired waiter left the queue, so the slot goes back to the pool
+	p.Release()
+	if err := p.Acquire(ctx, "low"); err != nil {
+		t.Errorf("expected a free slot, got %v", err)
+	}
+}
+
+func TestPool_ContextCancelled(t *testing.T) {
+	p := NewPool(1, 0, time.Second, testPriorities)
+	_ = p.Acquire(context.Background(), "normal")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Acquire(ctx, "unknown"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if st := p.Stats(); st.Queued["low"] != 0 || st.Rejected["low"] != 0 {
+		t.Errorf("cancelled callers should not count as rejected: %+v", st)
+	}
+}
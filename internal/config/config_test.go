@@ -1,8 +1,11 @@
 package config_test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 
@@ -526,7 +529,7 @@ func TestValidJournalModes(t *testing.T) {
 
 // TestValidDatabaseTypes checks ValidDatabaseTypes contains expected database types
 func TestValidDatabaseTypes(t *testing.T) {
-	valid := []string{"sqlserver", "mysql", "sqlite"}
+	valid := []string{"sqlserver", "mysql", "sqlite", "mock"}
 	invalid := []string{"", "postgres", "SQLite", "SQLSERVER", "MySQL"}
 
 	for _, typ := range valid {
@@ -678,6 +681,740 @@ variables:
 	}
 }
 
+// TestLoad_SchemaPaths verifies body_schema and response schema file paths resolve relative to the config file
+func TestLoad_SchemaPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `
+server:
+  host: "127.0.0.1"
+  port: 8080
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+
+databases:
+  - name: "primary"
+    type: "sqlite"
+    path: ":memory:"
+
+logging:
+  level: "info"
+
+workflows:
+  - name: "file_schema"
+    triggers:
+      - type: http
+        path: /a
+        method: POST
+        body_schema: schemas/order.json
+      - type: http
+        path: /b
+        method: POST
+        body_schema: /etc/schemas/order.json
+      - type: http
+        path: /c
+        method: POST
+        body_schema:
+          type: object
+    steps:
+      - type: response
+        template: "{}"
+        schema: schemas/response.yaml
+`
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	triggers := cfg.Workflows[0].Triggers
+	if got := triggers[0].BodySchema; got != filepath.Join(tmpDir, "schemas/order.json") {
+		t.Errorf("relative body_schema = %v, want resolved against config dir", got)
+	}
+	if got := triggers[1].BodySchema; got != "/etc/schemas/order.json" {
+		t.Errorf("absolute body_schema = %v, want unchanged", got)
+	}
+	if _, ok := triggers[2].BodySchema.(map[string]any); !ok {
+		t.Errorf("inline body_schema = %T, want map", triggers[2].BodySchema)
+	}
+	if got := cfg.Workflows[0].Steps[0].Schema; got != filepath.Join(tmpDir, "schemas/response.yaml") {
+		t.Errorf("response schema = %v, want resolved against config dir", got)
+	}
+}
+
+// TestLoad_DatabaseStateFile verifies registered databases are loaded from the state file
+func TestLoad_DatabaseStateFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `
+server:
+  host: "127.0.0.1"
+  port: 8080
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+  database_state_file: state/databases.yaml
+
+databases:
+  - name: "primary"
+    type: "sqlite"
+    path: ":memory:"
+
+logging:
+  level: "info"
+`
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	// A missing state file means nothing has been registered yet
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	stateFile := filepath.Join(tmpDir, "state/databases.yaml")
+	if cfg.Server.DatabaseStateFile != stateFile {
+		t.Errorf("database_state_file = %s, want resolved against config dir", cfg.Server.DatabaseStateFile)
+	}
+	if len(cfg.Databases) != 1 {
+		t.Fatalf("expected 1 database, got %d", len(cfg.Databases))
+	}
+
+	if err := os.Mkdir(filepath.Dir(stateFile), 0755); err != nil {
+		t.Fatalf("failed to create state dir: %v", err)
+	}
+	registered := []config.DatabaseConfig{{Name: "tenant_a", Type: "sqlite", Path: "/data/a.db", Registered: true}}
+	if err := config.SaveDatabaseState(stateFile, registered); err != nil {
+		t.Fatalf("failed to save state: %v", err)
+	}
+	info, err := os.Stat(stateFile)
+	if err != nil {
+		t.Fatalf("failed to stat state file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("state file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	cfg, err = config.Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if len(cfg.Databases) != 2 {
+		t.Fatalf("expected 2 databases, got %d", len(cfg.Databases))
+	}
+	if cfg.Databases[0].Registered {
+		t.Error("config file database marked as registered")
+	}
+	if db := cfg.Databases[1]; db.Name != "tenant_a" || db.Path != "/data/a.db" || !db.Registered {
+		t.Errorf("unexpected registered database: %+v", db)
+	}
+}
+
+// TestLoad_SQLSnippets verifies {{include}} references expand into query steps, including nested ones
+func TestLoad_SQLSnippets(t *testing.T) {
+	base := `
+server:
+  host: "127.0.0.1"
+  port: 8080
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+
+databases:
+  - name: "primary"
+    type: "sqlite"
+    path: ":memory:"
+
+sql_snippets:
+  active: "u.deleted_at IS NULL"
+  user_join: 'JOIN users u ON u.id = o.user_id AND {{include "active"}}'
+
+workflows:
+  - name: "orders"
+    triggers:
+      - type: http
+        path: /orders
+        method: GET
+    steps:
+      - name: fetch
+        type: query
+        database: primary
+        sql: 'SELECT o.* FROM orders o {{include "user_join"}} WHERE o.id = @id'
+      - name: each
+        iterate:
+          over: "steps.fetch.data"
+          as: "row"
+        steps:
+          - name: lines
+            type: query
+            database: primary
+            sql: 'SELECT * FROM users u WHERE {{ include "active" }}'
+      - type: response
+        template: "{}"
+`
+	load := func(t *testing.T, content string) (*config.Config, error) {
+		t.Helper()
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+		return config.Load(configPath)
+	}
+
+	cfg, err := load(t, base)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	steps := cfg.Workflows[0].Steps
+	if want := "SELECT o.* FROM orders o JOIN users u ON u.id = o.user_id AND u.deleted_at IS NULL WHERE o.id = @id"; steps[0].SQL != want {
+		t.Errorf("sql = %q, want %q", steps[0].SQL, want)
+	}
+	if want := "SELECT * FROM users u WHERE u.deleted_at IS NULL"; steps[1].Steps[0].SQL != want {
+		t.Errorf("nested sql = %q, want %q", steps[1].Steps[0].SQL, want)
+	}
+	if !cfg.UsedSQLSnippets["active"] || !cfg.UsedSQLSnippets["user_join"] {
+		t.Errorf("used snippets = %v, want active and user_join", cfg.UsedSQLSnippets)
+	}
+
+	t.Run("unknown snippet", func(t *testing.T) {
+		_, err := load(t, strings.Replace(base, `{{ include "active" }}`, `{{include "inactive"}}`, 1))
+		if err == nil || !strings.Contains(err.Error(), `workflows[0].steps[1].steps[0].sql: unknown sql snippet "inactive"`) {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		_, err := load(t, strings.Replace(base, `active: "u.deleted_at IS NULL"`, `active: '{{include "user_join"}}'`, 1))
+		if err == nil || !strings.Contains(err.Error(), "sql_snippets:") || !strings.Contains(err.Error(), "includes itself") {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+}
+
+// TestLoad_SQLFiles verifies sql_file is read relative to the config file and can use snippets
+func TestLoad_SQLFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "queries"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	orders := "-- Orders for a customer\nSELECT * FROM orders o WHERE {{include \"open\"}} AND o.customer_id = @id\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "queries", "orders.sql"), []byte(orders), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content := `
+server:
+  host: "127.0.0.1"
+  port: 8080
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+
+databases:
+  - name: "primary"
+    type: "sqlite"
+    path: ":memory:"
+
+sql_snippets:
+  open: "o.closed_at IS NULL"
+
+workflows:
+  - name: "orders"
+    triggers:
+      - type: http
+        path: /orders
+        method: GET
+    steps:
+      - name: each
+        iterate:
+          over: "trigger.params.ids"
+          as: "id"
+        steps:
+          - name: fetch
+            database: primary
+            sql_file: queries/orders.sql
+      - type: response
+        template: "{}"
+`
+	load := func(content string) (*config.Config, error) {
+		configPath := filepath.Join(tmpDir, "config.yaml")
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+		return config.Load(configPath)
+	}
+
+	cfg, err := load(content)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	step := cfg.Workflows[0].Steps[0].Steps[0]
+	if want := "-- Orders for a customer\nSELECT * FROM orders o WHERE o.closed_at IS NULL AND o.customer_id = @id\n"; step.SQL != want {
+		t.Errorf("sql = %q, want %q", step.SQL, want)
+	}
+	if step.SQLFile != filepath.Join(tmpDir, "queries", "orders.sql") {
+		t.Errorf("sql_file = %q, want resolved path", step.SQLFile)
+	}
+	if step.StepType() != "query" {
+		t.Errorf("step type = %q, want query", step.StepType())
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := load(strings.Replace(content, "queries/orders.sql", "queries/missing.sql", 1))
+		if err == nil || !strings.Contains(err.Error(), "workflows[0].steps[0].steps[0].sql_file:") {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+
+	t.Run("sql and sql_file", func(t *testing.T) {
+		_, err := load(strings.Replace(content, "sql_file: queries/orders.sql", "sql_file: queries/orders.sql\n            sql: SELECT 1", 1))
+		if err == nil || !strings.Contains(err.Error(), "sql and sql_file are mutually exclusive") {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+}
+
+// TestLoad_PageFiles verifies template_file and static dirs resolve against the config file
+func TestLoad_PageFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "pages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	page := "<h1>{{.trigger.params.q}}</h1>\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "pages", "lookup.html"), []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content := `
+server:
+  host: "127.0.0.1"
+  port: 8080
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+
+databases:
+  - name: "primary"
+    type: "sqlite"
+    path: ":memory:"
+
+static:
+  - path: /assets/
+    dir: public
+
+workflows:
+  - name: "lookup"
+    triggers:
+      - type: http
+        path: /lookup
+        method: GET
+    steps:
+      - type: page
+        template_file: pages/lookup.html
+`
+	load := func(content string) (*config.Config, error) {
+		configPath := filepath.Join(tmpDir, "config.yaml")
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+		return config.Load(configPath)
+	}
+
+	cfg, err := load(content)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if step := cfg.Workflows[0].Steps[0]; step.Template != page {
+		t.Errorf("template = %q, want %q", step.Template, page)
+	}
+	if want := filepath.Join(tmpDir, "public"); cfg.Static[0].Dir != want {
+		t.Errorf("static dir = %q, want %q", cfg.Static[0].Dir, want)
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := load(strings.Replace(content, "pages/lookup.html", "pages/missing.html", 1))
+		if err == nil || !strings.Contains(err.Error(), "workflows[0].steps[0].template_file:") {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+
+	t.Run("template and template_file", func(t *testing.T) {
+		_, err := load(strings.Replace(content, "template_file: pages/lookup.html", "template_file: pages/lookup.html\n        template: x", 1))
+		if err == nil || !strings.Contains(err.Error(), "template and template_file are mutually exclusive") {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+}
+
+// TestLoad_BasePaths verifies server.base_path and route groups prefix workflow and crud paths
+func TestLoad_BasePaths(t *testing.T) {
+	content := `
+server:
+  host: "127.0.0.1"
+  port: 8080
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+  base_path: /api
+
+databases:
+  - name: "primary"
+    type: "sqlite"
+    path: ":memory:"
+
+route_groups:
+  - name: v2
+    base_path: /v2
+    aliases: [/v1]
+  - name: tenant
+    base_path: "/{tenant}"
+
+crud:
+  - name: items
+    database: primary
+    table: items
+    key: id
+  - name: users
+    database: primary
+    table: users
+    key: id
+    path: /people
+
+workflows:
+  - name: "plain"
+    triggers:
+      - type: http
+        path: /health
+        method: GET
+      - type: cron
+        schedule: "0 * * * *"
+    steps:
+      - type: response
+        template: "{}"
+  - name: "orders"
+    group: v2
+    triggers:
+      - type: http
+        paths: [/orders, "/orders/{id}"]
+        method: GET
+      - type: http
+        path: /orders
+        method: POST
+      - type: websocket
+        path: /orders/live
+    steps:
+      - type: response
+        template: "{}"
+  - name: "report"
+    group: tenant
+    triggers:
+      - type: http
+        path: /report
+        method: GET
+    steps:
+      - type: response
+        template: "{}"
+  - name: "unknown"
+    group: missing
+    triggers:
+      - type: http
+        path: /unknown
+        method: GET
+    steps:
+      - type: response
+        template: "{}"
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	tests := []struct {
+		workflow int
+		trigger  int
+		path     string
+		paths    []string
+	}{
+		{workflow: 0, trigger: 0, path: "/api/health"},
+		{workflow: 0, trigger: 1},
+		{workflow: 1, trigger: 0, paths: []string{"/api/v2/orders", "/api/v2/orders/{id}", "/api/v1/orders", "/api/v1/orders/{id}"}},
+		{workflow: 1, trigger: 1, paths: []string{"/api/v2/orders", "/api/v1/orders"}},
+		{workflow: 1, trigger: 2, path: "/api/v2/orders/live"},
+		{workflow: 2, trigger: 0, path: "/api/{tenant}/report"},
+		{workflow: 3, trigger: 0, path: "/unknown"}, // Reported by validation
+	}
+	for _, tt := range tests {
+		trig := cfg.Workflows[tt.workflow].Triggers[tt.trigger]
+		if trig.Path != tt.path || !slices.Equal(trig.Paths, tt.paths) {
+			t.Errorf("%s trigger %d: path %q, paths %v, want %q, %v", cfg.Workflows[tt.workflow].Name, tt.trigger, trig.Path, trig.Paths, tt.path, tt.paths)
+		}
+	}
+	if cfg.Crud[0].Path != "/api/items" || cfg.Crud[1].Path != "/api/people" {
+		t.Errorf("crud paths = %q, %q, want /api/items, /api/people", cfg.Crud[0].Path, cfg.Crud[1].Path)
+	}
+}
+
+// TestLoad_MessagesDir verifies messages.dir is read relative to the config file and merged with inline languages
+func TestLoad_MessagesDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "messages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "messages", "fr.yaml"), []byte("errors:\n  not_found: Introuvable\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content := `
+server:
+  host: "127.0.0.1"
+  port: 8080
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+
+databases:
+  - name: "primary"
+    type: "sqlite"
+    path: ":memory:"
+
+messages:
+  default: en
+  dir: messages
+  languages:
+    en:
+      errors:
+        not_found: Not found
+`
+	load := func(content string) (*config.Config, error) {
+		configPath := filepath.Join(tmpDir, "config.yaml")
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+		return config.Load(configPath)
+	}
+
+	cfg, err := load(content)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Messages.Dir != filepath.Join(tmpDir, "messages") {
+		t.Errorf("dir = %q, want resolved path", cfg.Messages.Dir)
+	}
+	if len(cfg.Messages.Languages) != 2 || cfg.Messages.Languages["fr"] == nil {
+		t.Errorf("languages = %v, want en and fr", cfg.Messages.Languages)
+	}
+
+	t.Run("language both inline and in dir", func(t *testing.T) {
+		_, err := load(strings.Replace(content, "    en:", "    fr:", 1))
+		if err == nil || !strings.Contains(err.Error(), `language "fr" is also configured inline`) {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+
+	t.Run("missing dir", func(t *testing.T) {
+		_, err := load(strings.Replace(content, "dir: messages", "dir: missing", 1))
+		if err == nil || !strings.Contains(err.Error(), "messages.dir:") {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+}
+
+// TestLoad_ResponseEnvelope verifies response_envelope applies to workflows and crud entries without their own
+func TestLoad_ResponseEnvelope(t *testing.T) {
+	content := `
+server:
+  host: "127.0.0.1"
+  port: 8080
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+
+databases:
+  - name: "primary"
+    type: "sqlite"
+    path: ":memory:"
+
+response_envelope:
+  data: result
+  meta: meta
+
+crud:
+  - name: items
+    database: primary
+    table: items
+    key: id
+  - name: tags
+    database: primary
+    table: tags
+    key: id
+    envelope:
+      flat: true
+
+workflows:
+  - name: "plain"
+    triggers:
+      - type: http
+        path: /plain
+        method: GET
+    steps:
+      - type: response
+        template: "{}"
+  - name: "own"
+    envelope:
+      error: message
+    triggers:
+      - type: http
+        path: /own
+        method: GET
+    steps:
+      - type: response
+        template: "{}"
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if e := cfg.Workflows[0].Envelope; e != cfg.ResponseEnvelope || e.Data != "result" {
+		t.Errorf("plain envelope = %+v, want response_envelope", e)
+	}
+	if e := cfg.Workflows[1].Envelope; e == nil || e.Error != "message" || e.Data != "" {
+		t.Errorf("own envelope = %+v, want its own", e)
+	}
+	if e := cfg.Crud[0].Envelope; e != cfg.ResponseEnvelope {
+		t.Errorf("crud envelope = %+v, want response_envelope", e)
+	}
+	if e := cfg.Crud[1].Envelope; e == nil || !e.Flat {
+		t.Errorf("crud own envelope = %+v, want flat", e)
+	}
+}
+
+// TestLoad_ErrorFormat verifies the global error_format applies to workflows and crud entries without their own
+func TestLoad_ErrorFormat(t *testing.T) {
+	content := `
+server:
+  host: "127.0.0.1"
+  port: 8080
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+
+databases:
+  - name: "primary"
+    type: "sqlite"
+    path: ":memory:"
+
+error_format: problem_json
+
+crud:
+  - name: items
+    database: primary
+    table: items
+    key: id
+
+workflows:
+  - name: "plain"
+    triggers:
+      - type: http
+        path: /plain
+        method: GET
+    steps:
+      - type: response
+        template: "{}"
+  - name: "own"
+    error_format: envelope
+    triggers:
+      - type: http
+        path: /own
+        method: GET
+    steps:
+      - type: response
+        template: "{}"
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if f := cfg.Workflows[0].ErrorFormat; f != "problem_json" {
+		t.Errorf("plain error_format = %q, want problem_json", f)
+	}
+	if f := cfg.Workflows[1].ErrorFormat; f != "envelope" {
+		t.Errorf("own error_format = %q, want envelope", f)
+	}
+	if f := cfg.Crud[0].ErrorFormat; f != "problem_json" {
+		t.Errorf("crud error_format = %q, want problem_json", f)
+	}
+}
+
+// TestLoad_StatusMap verifies the global status_map is merged under each workflow's own
+func TestLoad_StatusMap(t *testing.T) {
+	content := `
+server:
+  host: "127.0.0.1"
+  port: 8080
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+
+databases:
+  - name: "primary"
+    type: "sqlite"
+    path: ":memory:"
+
+status_map:
+  deadlock: {status: 503}
+  timeout: {status: 504, message: "took too long"}
+
+workflows:
+  - name: "plain"
+    triggers:
+      - type: http
+        path: /plain
+        method: GET
+    steps:
+      - type: response
+        template: "{}"
+  - name: "own"
+    status_map:
+      timeout: {status: 503}
+      not_found: {status: 404}
+    triggers:
+      - type: http
+        path: /own
+        method: GET
+    steps:
+      - type: response
+        template: "{}"
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if got := cfg.Workflows[0].StatusMap; !reflect.DeepEqual(got, cfg.StatusMap) {
+		t.Errorf("plain status_map = %v, want %v", got, cfg.StatusMap)
+	}
+	want := map[string]config.StatusMapping{
+		"deadlock":  {Status: 503},
+		"timeout":   {Status: 503},
+		"not_found": {Status: 404},
+	}
+	if got := cfg.Workflows[1].StatusMap; !reflect.DeepEqual(got, want) {
+		t.Errorf("own status_map = %v, want %v", got, want)
+	}
+}
+
 // TestLoad_VariablesEnvOverridesFile verifies actual env vars override env file values
 func TestLoad_VariablesEnvOverridesFile(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -778,6 +1515,85 @@ logging:
 
 // Helper functions
 
+// TestLoadInstances verifies an instances file loads each config relative to it, in order, and a config file loads alone
+func TestLoadInstances(t *testing.T) {
+	tmpDir := t.TempDir()
+	base := `
+server:
+  host: "127.0.0.1"
+  port: %d
+  default_timeout_sec: 30
+  max_timeout_sec: 300
+databases:
+  - name: "primary"
+    type: "sqlite"
+    path: ":memory:"
+logging:
+  level: "info"
+`
+	write := func(name, content string) string {
+		t.Helper()
+		p := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	single := write("public/config.yaml", fmt.Sprintf(base, 8080))
+	write("internal.yaml", fmt.Sprintf(base, 9090))
+
+	cfgs, err := config.LoadInstances(write("instances.yaml", `
+instances:
+  - name: public
+    config: public/config.yaml
+  - name: internal
+    config: internal.yaml
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 2 {
+		t.Fatalf("got %d configs, want 2", len(cfgs))
+	}
+	if cfgs[0].Server.Instance != "public" || cfgs[0].Server.Port != 8080 || cfgs[0].Server.SecondaryInstance {
+		t.Errorf("first instance = %q port %d secondary %v", cfgs[0].Server.Instance, cfgs[0].Server.Port, cfgs[0].Server.SecondaryInstance)
+	}
+	if cfgs[1].Server.Instance != "internal" || cfgs[1].Server.Port != 9090 || !cfgs[1].Server.SecondaryInstance {
+		t.Errorf("second instance = %q port %d secondary %v", cfgs[1].Server.Instance, cfgs[1].Server.Port, cfgs[1].Server.SecondaryInstance)
+	}
+
+	cfgs, err = config.LoadInstances(single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 1 || cfgs[0].Server.Instance != "" || cfgs[0].Server.Port != 8080 {
+		t.Errorf("config file loaded as %d configs, instance %q", len(cfgs), cfgs[0].Server.Instance)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"missing name", "instances:\n  - config: internal.yaml\n", "name is required"},
+		{"invalid name", "instances:\n  - name: a/b\n    config: internal.yaml\n", "cannot contain"},
+		{"duplicate name", "instances:\n  - name: a\n    config: internal.yaml\n  - name: a\n    config: internal.yaml\n", "duplicate name"},
+		{"missing config", "instances:\n  - name: a\n", "config is required"},
+		{"config not found", "instances:\n  - name: a\n    config: missing.yaml\n", "instance a:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.LoadInstances(write("bad.yaml", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func loadFromString(t *testing.T, content string) *config.Config {
 	t.Helper()
 
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// databaseState is the layout of server.database_state_file.
+type databaseState struct {
+	Databases []DatabaseConfig `yaml:"databases"`
+}
+
+// LoadDatabaseState reads the databases registered through the admin API.
+// A missing file means none have been registered yet.
+func LoadDatabaseState(path string) ([]DatabaseConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database state file: %w", err)
+	}
+	var state databaseState
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse database state file %s: %w", path, err)
+	}
+	for i := range state.Databases {
+		state.Databases[i].Registered = true
+	}
+	return state.Databases, nil
+}
+
+// SaveDatabaseState replaces the state file with the given databases. The file is
+// written next to its final path and renamed, so a crash never leaves it partial.
+// It holds connection passwords and is only readable by its owner.
+func SaveDatabaseState(path string, databases []DatabaseConfig) error {
+	data, err := yaml.Marshal(databaseState{Databases: databases})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write database state file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write database state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write database state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write database state file: %w", err)
+	}
+	return nil
+}
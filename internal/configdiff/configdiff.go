@@ -0,0 +1,533 @@
+// Package configdiff compares two configs for change review: workflows added,
+// removed and changed, routes added and removed, changes to the parameters a
+// route accepts, and databases workflows newly read or write. Route and
+// parameter changes that can reject requests existing callers send are marked
+// breaking, so CI can hold them for review.
+package configdiff
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/sqlutil"
+	"sql-proxy/internal/workflow"
+)
+
+// Sections of a report
+const (
+	SectionWorkflows = "workflows"
+	SectionRoutes    = "routes"
+	SectionDatabases = "databases"
+)
+
+// Kinds of change
+const (
+	KindAdded   = "added"
+	KindRemoved = "removed"
+	KindChanged = "changed"
+)
+
+// Change is one difference between the configs
+type Change struct {
+	Section  string `json:"section"`
+	Kind     string `json:"kind"`
+	Subject  string `json:"subject"` // Workflow name, route ("GET /api/users") or database name
+	Message  string `json:"message"`
+	Breaking bool   `json:"breaking"`
+}
+
+// Report lists the changes from one config to another, by section
+type Report struct {
+	Changes []Change `json:"changes"`
+}
+
+// Breaking returns the number of breaking changes
+func (r *Report) Breaking() int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Breaking {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) add(section, kind, subject string, breaking bool, format string, args ...any) {
+	r.Changes = append(r.Changes, Change{
+		Section:  section,
+		Kind:     kind,
+		Subject:  subject,
+		Message:  fmt.Sprintf(format, args...),
+		Breaking: breaking,
+	})
+}
+
+// Compare returns the changes from old to new
+func Compare(old, new *config.Config) *Report {
+	r := &Report{Changes: []Change{}}
+	compareWorkflows(old, new, r)
+	compareRoutes(old, new, r)
+	compareDatabases(old, new, r)
+	return r
+}
+
+// compareWorkflows reports workflows added, removed, and changed in their
+// steps, non-route triggers or settings
+func compareWorkflows(old, new *config.Config, r *Report) {
+	oldWfs, newWfs := workflowsByName(old), workflowsByName(new)
+	for _, name := range sortedKeys(oldWfs) {
+		if _, ok := newWfs[name]; !ok {
+			r.add(SectionWorkflows, KindRemoved, name, false, "workflow removed")
+		}
+	}
+	for _, name := range sortedKeys(newWfs) {
+		oldWf, ok := oldWfs[name]
+		if !ok {
+			r.add(SectionWorkflows, KindAdded, name, false, "workflow added")
+			continue
+		}
+		compareSteps(name, oldWf.Steps, newWfs[name].Steps, r)
+		compareTriggers(name, oldWf.Triggers, newWfs[name].Triggers, r)
+
+		oldSettings, newSettings := *oldWf, *newWfs[name]
+		oldSettings.Steps, oldSettings.Triggers = nil, nil
+		newSettings.Steps, newSettings.Triggers = nil, nil
+		if marshal(oldSettings) != marshal(newSettings) {
+			r.add(SectionWorkflows, KindChanged, name, false, "workflow settings changed")
+		}
+	}
+}
+
+// compareSteps reports top-level steps added, removed, changed and reordered.
+func compareSteps(wf string, old, new []workflow.StepConfig, r *Report) {
+	oldKeys, oldSteps := stepsByKey(old)
+	newKeys, newSteps := stepsByKey(new)
+
+	var common []string
+	for _, key := range oldKeys {
+		if _, ok := newSteps[key]; !ok {
+			r.add(SectionWorkflows, KindChanged, wf, false, "step %s removed", key)
+		} else {
+			common = append(common, key)
+		}
+	}
+	var newCommon []string
+	for _, key := range newKeys {
+		oldStep, ok := oldSteps[key]
+		if !ok {
+			r.add(SectionWorkflows, KindChanged, wf, false, "step %s added", key)
+			continue
+		}
+		newCommon = append(newCommon, key)
+		if marshal(oldStep) != marshal(newSteps[key]) {
+			r.add(SectionWorkflows, KindChanged, wf, false, "step %s changed", key)
+		}
+	}
+	if !slices.Equal(common, newCommon) {
+		r.add(SectionWorkflows, KindChanged, wf, false, "steps reordered")
+	}
+}
+
+// stepsByKey returns the keys of steps in order and the steps by key. Named
+// steps are keyed by name, unnamed ones by type and their count of that type.
+func stepsByKey(steps []workflow.StepConfig) ([]string, map[string]workflow.StepConfig) {
+	keys := make([]string, len(steps))
+	byKey := make(map[string]workflow.StepConfig, len(steps))
+	unnamed := make(map[string]int)
+	for i, step := range steps {
+		keys[i] = fmt.Sprintf("'%s'", step.Name)
+		if step.Name == "" {
+			typ := step.StepType()
+			unnamed[typ]++
+			keys[i] = fmt.Sprintf("(%s)", typ)
+			if unnamed[typ] > 1 {
+				keys[i] = fmt.Sprintf("(%s #%d)", typ, unnamed[typ])
+			}
+		}
+		byKey[keys[i]] = step
+	}
+	return keys, byKey
+}
+
+// compareTriggers reports changes to the triggers that aren't routes (cron,
+// dbwatch, filewatch, mqtt), by type. Routes are compared by compareRoutes.
+func compareTriggers(wf string, old, new []workflow.TriggerConfig, r *Report) {
+	oldByType, newByType := nonRouteTriggers(old), nonRouteTriggers(new)
+	for _, typ := range sortedKeys(oldByType) {
+		if _, ok := newByType[typ]; !ok {
+			r.add(SectionWorkflows, KindChanged, wf, false, "%s trigger removed", typ)
+		}
+	}
+	for _, typ := range sortedKeys(newByType) {
+		oldTriggers, ok := oldByType[typ]
+		switch {
+		case !ok:
+			r.add(SectionWorkflows, KindChanged, wf, false, "%s trigger added", typ)
+		case oldTriggers != newByType[typ]:
+			r.add(SectionWorkflows, KindChanged, wf, false, "%s trigger changed", typ)
+		}
+	}
+}
+
+// nonRouteTriggers returns the marshalled triggers of each type that aren't routes
+func nonRouteTriggers(triggers []workflow.TriggerConfig) map[string]string {
+	byType := make(map[string]string)
+	for _, trig := range triggers {
+		switch trig.Type {
+		case workflow.TriggerTypeHTTP, workflow.TriggerTypeWebSocket, workflow.TriggerTypeGRPC:
+			continue
+		}
+		byType[trig.Type] += marshal(trig)
+	}
+	return byType
+}
+
+// route is an endpoint callers reach a workflow on
+type route struct {
+	workflow string
+	trigger  workflow.TriggerConfig
+}
+
+// routes returns the HTTP, websocket and gRPC routes of a config by key:
+// "METHOD /path", "websocket /path" or "grpc Method"
+func routes(cfg *config.Config) map[string]route {
+	byKey := make(map[string]route)
+	for _, wf := range cfg.Workflows {
+		for _, trig := range wf.Triggers {
+			for _, rt := range trig.Expand() {
+				var key string
+				switch {
+				case rt.Type == workflow.TriggerTypeHTTP && rt.Path != "":
+					key = strings.ToUpper(rt.Method) + " " + rt.Path
+				case rt.Type == workflow.TriggerTypeWebSocket && rt.Path != "":
+					key = "websocket " + rt.Path
+				case rt.Type == workflow.TriggerTypeGRPC && rt.RPC != "":
+					key = "grpc " + rt.RPC
+				default:
+					continue
+				}
+				byKey[key] = route{workflow: wf.Name, trigger: rt}
+			}
+		}
+	}
+	return byKey
+}
+
+// compareRoutes reports routes removed (breaking), added, moved to another
+// workflow, and changes to their parameters
+func compareRoutes(old, new *config.Config, r *Report) {
+	oldRoutes, newRoutes := routes(old), routes(new)
+	for _, key := range sortedKeys(oldRoutes) {
+		if _, ok := newRoutes[key]; !ok {
+			r.add(SectionRoutes, KindRemoved, key, true, "route removed (was workflow '%s')", oldRoutes[key].workflow)
+		}
+	}
+	for _, key := range sortedKeys(newRoutes) {
+		newRoute := newRoutes[key]
+		oldRoute, ok := oldRoutes[key]
+		if !ok {
+			r.add(SectionRoutes, KindAdded, key, false, "route added (workflow '%s')", newRoute.workflow)
+			continue
+		}
+		if oldRoute.workflow != newRoute.workflow {
+			r.add(SectionRoutes, KindChanged, key, false, "now served by workflow '%s' (was '%s')", newRoute.workflow, oldRoute.workflow)
+		}
+		compareParams(key, oldRoute.trigger.Parameters, newRoute.trigger.Parameters, r)
+	}
+}
+
+// compareParams reports the parameter changes of a route. Changes that can
+// reject a request the old route accepted are breaking: new required
+// parameters, narrower types and tighter validation.
+func compareParams(key string, old, new []workflow.ParamConfig, r *Report) {
+	oldParams := make(map[string]workflow.ParamConfig, len(old))
+	for _, p := range old {
+		oldParams[p.Name] = p
+	}
+	newParams := make(map[string]workflow.ParamConfig, len(new))
+	for _, p := range new {
+		newParams[p.Name] = p
+	}
+
+	for _, p := range old {
+		if _, ok := newParams[p.Name]; !ok {
+			r.add(SectionRoutes, KindChanged, key, false, "parameter '%s' removed", p.Name)
+		}
+	}
+	for _, p := range new {
+		o, ok := oldParams[p.Name]
+		switch {
+		case !ok && p.Required:
+			r.add(SectionRoutes, KindChanged, key, true, "required parameter '%s' added", p.Name)
+			continue
+		case !ok:
+			r.add(SectionRoutes, KindChanged, key, false, "optional parameter '%s' added", p.Name)
+			continue
+		}
+
+		if oldType, newType := canonicalType(o.Type), canonicalType(p.Type); oldType != newType {
+			r.add(SectionRoutes, KindChanged, key, !widens(oldType, newType), "parameter '%s' type changed from %s to %s", p.Name, o.Type, p.Type)
+		}
+		switch {
+		case !o.Required && p.Required:
+			r.add(SectionRoutes, KindChanged, key, true, "parameter '%s' is now required", p.Name)
+		case o.Required && !p.Required:
+			r.add(SectionRoutes, KindChanged, key, false, "parameter '%s' is now optional", p.Name)
+		case !p.Required && o.Default != p.Default:
+			r.add(SectionRoutes, KindChanged, key, false, "parameter '%s' default changed from '%s' to '%s'", p.Name, o.Default, p.Default)
+		}
+		compareValidation(key, p.Name, o.Validation, p.Validation, r)
+	}
+}
+
+// canonicalType returns a parameter type with aliases resolved
+func canonicalType(typ string) string {
+	typ = strings.ToLower(typ)
+	for alias, canonical := range map[string]string{"integer": "int", "double": "float", "boolean": "bool", "date": "datetime"} {
+		if strings.TrimSuffix(typ, "[]") == alias {
+			return strings.Replace(typ, alias, canonical, 1)
+		}
+	}
+	return typ
+}
+
+// widens reports whether every value of type from is also a valid to
+func widens(from, to string) bool {
+	if strings.HasSuffix(from, "[]") != strings.HasSuffix(to, "[]") {
+		return false
+	}
+	from, to = strings.TrimSuffix(from, "[]"), strings.TrimSuffix(to, "[]")
+	switch to {
+	case "string":
+		return true
+	case "float":
+		return from == "int"
+	case "decimal":
+		return from == "int" || from == "float"
+	}
+	return false
+}
+
+// compareValidation reports changes to the validation rules of a parameter.
+// Tighter rules are breaking; looser ones are not.
+func compareValidation(key, name string, old, new *workflow.ParamValidation, r *Report) {
+	if old == nil {
+		old = &workflow.ParamValidation{}
+	}
+	if new == nil {
+		new = &workflow.ParamValidation{}
+	}
+	change := func(breaking bool, format string, args ...any) {
+		r.add(SectionRoutes, KindChanged, key, breaking, "parameter '%s' "+format, append([]any{name}, args...)...)
+	}
+
+	compareBound(change, "min", old.Min, new.Min, true)
+	compareBound(change, "max", old.Max, new.Max, false)
+	compareBound(change, "min_length", intBound(old.MinLength), intBound(new.MinLength), true)
+	compareBound(change, "max_length", intBound(old.MaxLength), intBound(new.MaxLength), false)
+
+	if old.Pattern != new.Pattern {
+		if new.Pattern == "" {
+			change(false, "pattern removed")
+		} else {
+			change(true, "pattern changed to '%s'", new.Pattern)
+		}
+	}
+
+	switch {
+	case len(old.Enum) == 0 && len(new.Enum) > 0:
+		change(true, "enum added: %s", strings.Join(new.Enum, ", "))
+	case len(old.Enum) > 0 && len(new.Enum) == 0:
+		change(false, "enum removed")
+	default:
+		var removed, added []string
+		for _, v := range old.Enum {
+			if !slices.Contains(new.Enum, v) {
+				removed = append(removed, v)
+			}
+		}
+		for _, v := range new.Enum {
+			if !slices.Contains(old.Enum, v) {
+				added = append(added, v)
+			}
+		}
+		if len(removed) > 0 {
+			change(true, "enum values removed: %s", strings.Join(removed, ", "))
+		}
+		if len(added) > 0 {
+			change(false, "enum values added: %s", strings.Join(added, ", "))
+		}
+	}
+
+	if old.Expr != new.Expr {
+		if new.Expr == "" {
+			change(false, "expr removed")
+		} else {
+			change(true, "expr changed to '%s'", new.Expr)
+		}
+	}
+}
+
+// compareBound reports a changed validation bound. A lower bound tightens when
+// it rises, an upper bound when it falls; adding either tightens.
+func compareBound(change func(bool, string, ...any), rule string, old, new *float64, lower bool) {
+	switch {
+	case old == nil && new == nil:
+	case old == nil:
+		change(true, "%s %v added", rule, *new)
+	case new == nil:
+		change(false, "%s removed", rule)
+	case *old != *new:
+		change((*new > *old) == lower, "%s changed from %v to %v", rule, *old, *new)
+	}
+}
+
+func intBound(v *int) *float64 {
+	if v == nil {
+		return nil
+	}
+	f := float64(*v)
+	return &f
+}
+
+// Database access levels
+const (
+	accessRead  = "read"
+	accessWrite = "write"
+)
+
+// compareDatabases reports database connections added and removed, and the
+// databases each workflow newly reads or writes
+func compareDatabases(old, new *config.Config, r *Report) {
+	oldDBs, newDBs := make(map[string]bool), make(map[string]bool)
+	for _, db := range old.Databases {
+		oldDBs[db.Name] = true
+	}
+	for _, db := range new.Databases {
+		newDBs[db.Name] = true
+	}
+	for _, name := range sortedKeys(oldDBs) {
+		if !newDBs[name] {
+			r.add(SectionDatabases, KindRemoved, name, false, "database removed")
+		}
+	}
+	for _, name := range sortedKeys(newDBs) {
+		if !oldDBs[name] {
+			r.add(SectionDatabases, KindAdded, name, false, "database added")
+		}
+	}
+
+	oldWfs, newWfs := workflowsByName(old), workflowsByName(new)
+	for _, wf := range sortedKeys(newWfs) {
+		var oldAccess map[string]string
+		if oldWf, ok := oldWfs[wf]; ok {
+			oldAccess = databaseAccess(oldWf)
+		}
+		newAccess := databaseAccess(newWfs[wf])
+		for _, db := range sortedKeys(newAccess) {
+			access := newAccess[db]
+			switch {
+			case oldAccess[db] == "":
+				r.add(SectionDatabases, KindChanged, db, false, "workflow '%s' now %ss it", wf, access)
+			case oldAccess[db] == accessRead && access == accessWrite:
+				r.add(SectionDatabases, KindChanged, db, false, "workflow '%s' now writes it (was read only)", wf)
+			}
+		}
+	}
+}
+
+// databaseAccess returns the databases a workflow uses by its triggers and
+// steps, nested ones included, and whether it only reads them
+func databaseAccess(wf *workflow.WorkflowConfig) map[string]string {
+	access := make(map[string]string)
+	use := func(db, level string) {
+		if db != "" && access[db] != accessWrite {
+			access[db] = level
+		}
+	}
+	for _, trig := range wf.Triggers {
+		if trig.Type == workflow.TriggerTypeDBWatch {
+			use(trig.Database, accessRead)
+		}
+		if trig.Idempotency != nil {
+			use(trig.Idempotency.Database, accessWrite)
+		}
+	}
+	var walk func(steps []workflow.StepConfig)
+	walk = func(steps []workflow.StepConfig) {
+		for _, step := range steps {
+			if step.IsQuery() && step.Proc == nil && !sqlutil.IsWriteQuery(step.SQL) {
+				use(step.Database, accessRead)
+			} else {
+				use(step.Database, accessWrite)
+			}
+			walk(step.Steps)
+		}
+	}
+	walk(wf.Steps)
+	return access
+}
+
+func workflowsByName(cfg *config.Config) map[string]*workflow.WorkflowConfig {
+	byName := make(map[string]*workflow.WorkflowConfig, len(cfg.Workflows))
+	for i := range cfg.Workflows {
+		byName[cfg.Workflows[i].Name] = &cfg.Workflows[i]
+	}
+	return byName
+}
+
+// marshal returns v as YAML, for comparing configs by value
+func marshal(v any) string {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%#v", v)
+	}
+	return string(data)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Text returns the report for reading, one change per line by section,
+// followed by a count of changes
+func (r *Report) Text() string {
+	var b strings.Builder
+	for _, section := range []string{SectionWorkflows, SectionRoutes, SectionDatabases} {
+		header := false
+		for _, c := range r.Changes {
+			if c.Section != section {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(&b, "%s%s:\n", strings.ToUpper(section[:1]), section[1:])
+				header = true
+			}
+			mark := map[string]string{KindAdded: "+", KindRemoved: "-", KindChanged: "~"}[c.Kind]
+			fmt.Fprintf(&b, "  %s %s: %s", mark, c.Subject, c.Message)
+			if c.Breaking {
+				b.WriteString(" [BREAKING]")
+			}
+			b.WriteString("\n")
+		}
+		if header {
+			b.WriteString("\n")
+		}
+	}
+	if len(r.Changes) == 0 {
+		b.WriteString("No changes\n")
+	} else {
+		fmt.Fprintf(&b, "%d changes, %d breaking\n", len(r.Changes), r.Breaking())
+	}
+	return b.String()
+}
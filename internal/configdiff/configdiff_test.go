@@ -0,0 +1,211 @@
+package configdiff
+
+import (
+	"strings"
+	"testing"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/workflow"
+)
+
+// changeLines returns the changes of a section as "subject: message", with " [BREAKING]" on breaking ones
+func changeLines(r *Report, section string) []string {
+	var lines []string
+	for _, c := range r.Changes {
+		if c.Section != section {
+			continue
+		}
+		line := c.Subject + ": " + c.Message
+		if c.Breaking {
+			line += " [BREAKING]"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// httpWorkflow returns a workflow with one http trigger
+func httpWorkflow(name, method, path string, params []workflow.ParamConfig, steps ...workflow.StepConfig) workflow.WorkflowConfig {
+	return workflow.WorkflowConfig{
+		Name:     name,
+		Triggers: []workflow.TriggerConfig{{Type: "http", Method: method, Path: path, Parameters: params}},
+		Steps:    steps,
+	}
+}
+
+// TestCompare_Workflows verifies workflows, steps, non-route triggers and settings are compared
+func TestCompare_Workflows(t *testing.T) {
+	fetch := workflow.StepConfig{Name: "fetch", Type: "query", Database: "main", SQL: "SELECT id FROM orders"}
+	respond := workflow.StepConfig{Type: "response", Template: "{}"}
+	old := &config.Config{Workflows: []workflow.WorkflowConfig{
+		httpWorkflow("orders", "GET", "/orders", nil, fetch, respond),
+		httpWorkflow("legacy", "GET", "/legacy", nil, respond),
+		{Name: "nightly", Triggers: []workflow.TriggerConfig{{Type: "cron", Schedule: "0 0 * * *"}}},
+	}}
+
+	changed := fetch
+	changed.SQL = "SELECT id, total FROM orders"
+	notify := workflow.StepConfig{Name: "notify", Type: "httpcall", URL: "https://hooks.example.com"}
+	orders := httpWorkflow("orders", "GET", "/orders", nil, respond, changed, notify)
+	orders.TimeoutSec = 10
+	new := &config.Config{Workflows: []workflow.WorkflowConfig{
+		orders,
+		httpWorkflow("audit", "GET", "/audit", nil, respond),
+		{Name: "nightly", Triggers: []workflow.TriggerConfig{{Type: "cron", Schedule: "0 1 * * *"}}},
+	}}
+
+	got := changeLines(Compare(old, new), SectionWorkflows)
+	want := []string{
+		"legacy: workflow removed",
+		"audit: workflow added",
+		"nightly: cron trigger changed",
+		"orders: step 'fetch' changed",
+		"orders: step 'notify' added",
+		"orders: steps reordered",
+		"orders: workflow settings changed",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if r := Compare(old, old); len(r.Changes) != 0 {
+		t.Errorf("identical configs: got changes %v", r.Changes)
+	}
+}
+
+// TestCompare_Routes tests route and parameter contract changes and which of them are breaking
+func TestCompare_Routes(t *testing.T) {
+	min1, min5 := 1.0, 5.0
+	maxLen10, maxLen20 := 10, 20
+	tests := []struct {
+		name string
+		old  []workflow.ParamConfig
+		new  []workflow.ParamConfig
+		want string
+	}{
+		{name: "unchanged", old: []workflow.ParamConfig{{Name: "id", Type: "int"}}, new: []workflow.ParamConfig{{Name: "id", Type: "integer"}}},
+		{name: "required added", new: []workflow.ParamConfig{{Name: "id", Type: "int", Required: true}}, want: "required parameter 'id' added [BREAKING]"},
+		{name: "optional added", new: []workflow.ParamConfig{{Name: "id", Type: "int"}}, want: "optional parameter 'id' added"},
+		{name: "removed", old: []workflow.ParamConfig{{Name: "id", Type: "int"}}, want: "parameter 'id' removed"},
+		{name: "now required", old: []workflow.ParamConfig{{Name: "id", Type: "int"}}, new: []workflow.ParamConfig{{Name: "id", Type: "int", Required: true}}, want: "parameter 'id' is now required [BREAKING]"},
+		{name: "now optional", old: []workflow.ParamConfig{{Name: "id", Type: "int", Required: true}}, new: []workflow.ParamConfig{{Name: "id", Type: "int"}}, want: "parameter 'id' is now optional"},
+		{name: "default changed", old: []workflow.ParamConfig{{Name: "n", Type: "int", Default: "1"}}, new: []workflow.ParamConfig{{Name: "n", Type: "int", Default: "2"}}, want: "parameter 'n' default changed from '1' to '2'"},
+		{name: "type widened", old: []workflow.ParamConfig{{Name: "n", Type: "int"}}, new: []workflow.ParamConfig{{Name: "n", Type: "float"}}, want: "parameter 'n' type changed from int to float"},
+		{name: "type narrowed", old: []workflow.ParamConfig{{Name: "n", Type: "string"}}, new: []workflow.ParamConfig{{Name: "n", Type: "int"}}, want: "parameter 'n' type changed from string to int [BREAKING]"},
+		{name: "array to scalar", old: []workflow.ParamConfig{{Name: "n", Type: "int[]"}}, new: []workflow.ParamConfig{{Name: "n", Type: "string"}}, want: "parameter 'n' type changed from int[] to string [BREAKING]"},
+		{
+			name: "min raised",
+			old:  []workflow.ParamConfig{{Name: "n", Type: "int", Validation: &workflow.ParamValidation{Min: &min1}}},
+			new:  []workflow.ParamConfig{{Name: "n", Type: "int", Validation: &workflow.ParamValidation{Min: &min5}}},
+			want: "parameter 'n' min changed from 1 to 5 [BREAKING]",
+		},
+		{
+			name: "max_length raised",
+			old:  []workflow.ParamConfig{{Name: "s", Type: "string", Validation: &workflow.ParamValidation{MaxLength: &maxLen10}}},
+			new:  []workflow.ParamConfig{{Name: "s", Type: "string", Validation: &workflow.ParamValidation{MaxLength: &maxLen20}}},
+			want: "parameter 's' max_length changed from 10 to 20",
+		},
+		{
+			name: "validation added",
+			old:  []workflow.ParamConfig{{Name: "s", Type: "string"}},
+			new:  []workflow.ParamConfig{{Name: "s", Type: "string", Validation: &workflow.ParamValidation{MaxLength: &maxLen10}}},
+			want: "parameter 's' max_length 10 added [BREAKING]",
+		},
+		{
+			name: "enum values",
+			old:  []workflow.ParamConfig{{Name: "s", Type: "string", Validation: &workflow.ParamValidation{Enum: []string{"a", "b"}}}},
+			new:  []workflow.ParamConfig{{Name: "s", Type: "string", Validation: &workflow.ParamValidation{Enum: []string{"b", "c"}}}},
+			want: "parameter 's' enum values removed: a [BREAKING]\nparameter 's' enum values added: c",
+		},
+		{
+			name: "pattern removed",
+			old:  []workflow.ParamConfig{{Name: "s", Type: "string", Validation: &workflow.ParamValidation{Pattern: "^[a-z]+$"}}},
+			new:  []workflow.ParamConfig{{Name: "s", Type: "string"}},
+			want: "parameter 's' pattern removed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := &config.Config{Workflows: []workflow.WorkflowConfig{httpWorkflow("wf", "GET", "/x", tt.old)}}
+			new := &config.Config{Workflows: []workflow.WorkflowConfig{httpWorkflow("wf", "GET", "/x", tt.new)}}
+
+			var got []string
+			for _, line := range changeLines(Compare(old, new), SectionRoutes) {
+				got = append(got, strings.TrimPrefix(line, "GET /x: "))
+			}
+			if strings.Join(got, "\n") != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), tt.want)
+			}
+		})
+	}
+
+	t.Run("routes", func(t *testing.T) {
+		old := &config.Config{Workflows: []workflow.WorkflowConfig{
+			httpWorkflow("users", "GET", "/users", nil),
+			httpWorkflow("orders", "GET", "/orders", nil),
+		}}
+		moved := httpWorkflow("orders_v2", "GET", "/orders", nil)
+		moved.Triggers = append(moved.Triggers, workflow.TriggerConfig{Type: "websocket", Path: "/orders/live"})
+		new := &config.Config{Workflows: []workflow.WorkflowConfig{moved, httpWorkflow("users", "post", "/users", nil)}}
+
+		got := strings.Join(changeLines(Compare(old, new), SectionRoutes), "\n")
+		want := strings.Join([]string{
+			"GET /users: route removed (was workflow 'users') [BREAKING]",
+			"GET /orders: now served by workflow 'orders_v2' (was 'orders')",
+			"POST /users: route added (workflow 'users')",
+			"websocket /orders/live: route added (workflow 'orders_v2')",
+		}, "\n")
+		if got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+// TestCompare_Databases verifies database connections and the databases workflows newly read or write are reported
+func TestCompare_Databases(t *testing.T) {
+	read := workflow.StepConfig{Name: "read", Type: "query", Database: "main", SQL: "SELECT 1"}
+	old := &config.Config{
+		Databases: []config.DatabaseConfig{{Name: "main"}, {Name: "old"}},
+		Workflows: []workflow.WorkflowConfig{httpWorkflow("orders", "GET", "/orders", nil, read)},
+	}
+
+	write := workflow.StepConfig{Name: "write", Type: "query", Database: "main", SQL: "UPDATE orders SET seen = 1"}
+	block := workflow.StepConfig{Name: "each", Iterate: &workflow.IterateConfig{Over: "steps.read.data", As: "o"}, Steps: []workflow.StepConfig{
+		{Name: "audit", Type: "query", Database: "audit", SQL: "SELECT 1"},
+	}}
+	new := &config.Config{
+		Databases: []config.DatabaseConfig{{Name: "main"}, {Name: "audit"}, {Name: "billing"}},
+		Workflows: []workflow.WorkflowConfig{
+			httpWorkflow("orders", "GET", "/orders", nil, read, write, block),
+			httpWorkflow("invoice", "POST", "/invoice", nil, workflow.StepConfig{Name: "charge", Type: "query", Database: "billing", Proc: &workflow.ProcConfig{Name: "charge"}}),
+		},
+	}
+
+	got := strings.Join(changeLines(Compare(old, new), SectionDatabases), "\n")
+	want := strings.Join([]string{
+		"old: database removed",
+		"audit: database added",
+		"billing: database added",
+		"billing: workflow 'invoice' now writes it",
+		"audit: workflow 'orders' now reads it",
+		"main: workflow 'orders' now writes it (was read only)",
+	}, "\n")
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestReport_Text verifies the text report groups changes by section and counts breaking ones
+func TestReport_Text(t *testing.T) {
+	r := &Report{}
+	if got := r.Text(); got != "No changes\n" {
+		t.Errorf("empty report = %q", got)
+	}
+
+	r.add(SectionRoutes, KindRemoved, "GET /x", true, "route removed")
+	r.add(SectionWorkflows, KindAdded, "wf", false, "workflow added")
+	want := "Workflows:\n  + wf: workflow added\n\nRoutes:\n  - GET /x: route removed [BREAKING]\n\n2 changes, 1 breaking\n"
+	if got := r.Text(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
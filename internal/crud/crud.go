@@ -0,0 +1,300 @@
+// Package crud generates list/get/create/update/delete workflows for tables
+// declared in the crud config section.
+package crud
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/db"
+	"sql-proxy/internal/workflow"
+)
+
+const (
+	// DefaultPageSize and MaxPageSize bound the list workflow's limit parameter
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// TableNameRegex matches a table name with optional database and schema qualifiers.
+var TableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$#]*(\.[A-Za-z_][A-Za-z0-9_$#]*){0,2}$`)
+
+// columnNameRegex matches column names usable both unquoted in SQL and as parameter names.
+var columnNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Generate reads the table's columns through driver and builds the workflows of c.
+func Generate(ctx context.Context, c config.CrudConfig, driver db.Driver) ([]workflow.WorkflowConfig, error) {
+	if !TableNameRegex.MatchString(c.Table) {
+		return nil, fmt.Errorf("invalid table name %q", c.Table)
+	}
+	columns, err := driver.TableColumns(ctx, c.Table)
+	if err != nil {
+		return nil, err
+	}
+	return Expand(c, driver.Type(), columns)
+}
+
+// Expand builds the workflows of c from the table's columns on a database of type dbType.
+func Expand(c config.CrudConfig, dbType string, columns []db.Column) ([]workflow.WorkflowConfig, error) {
+	if !TableNameRegex.MatchString(c.Table) {
+		return nil, fmt.Errorf("invalid table name %q", c.Table)
+	}
+	var key *db.Column
+	for i, col := range columns {
+		if !columnNameRegex.MatchString(col.Name) {
+			return nil, fmt.Errorf("column %q of %s cannot be used as a parameter name", col.Name, c.Table)
+		}
+		if col.Name == c.Key {
+			key = &columns[i]
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("key column %q not found in %s", c.Key, c.Table)
+	}
+
+	g := &generator{cfg: c, dbType: dbType, columns: columns, key: *key}
+	var workflows []workflow.WorkflowConfig
+	for _, op := range config.CrudOperations {
+		if !c.HasOperation(op) {
+			continue
+		}
+		var wf workflow.WorkflowConfig
+		switch op {
+		case "list":
+			wf = g.list()
+		case "get":
+			wf = g.get()
+		case "create":
+			var err error
+			if wf, err = g.create(); err != nil {
+				return nil, err
+			}
+		case "update":
+			wf = g.update()
+		case "delete":
+			wf = g.delete()
+		}
+		wf.Name = c.Name + "_" + op
+		wf.Envelope = c.Envelope
+		wf.ErrorFormat = c.ErrorFormat
+		workflows = append(workflows, wf)
+	}
+	return workflows, nil
+}
+
+// generator holds what every generated workflow needs.
+type generator struct {
+	cfg     config.CrudConfig
+	dbType  string
+	columns []db.Column
+	key     db.Column
+}
+
+// notFound is the 404 response of the single-row workflows.
+func (g *generator) notFound() workflow.StepConfig {
+	if g.cfg.ErrorFormat == workflow.ErrorFormatProblemJSON {
+		return workflow.StepConfig{
+			Type:       "response",
+			Condition:  "not_found",
+			StatusCode: 404,
+			Headers:    map[string]string{"Content-Type": workflow.ProblemContentType},
+			Template:   workflow.ProblemTemplate(404, "not found", workflow.CodeNotFound),
+		}
+	}
+	return workflow.StepConfig{
+		Type:       "response",
+		Condition:  "not_found",
+		StatusCode: 404,
+		Template:   g.cfg.Envelope.ErrorTemplate("not found", workflow.CodeNotFound),
+	}
+}
+
+func (g *generator) list() workflow.WorkflowConfig {
+	limit, offset := float64(MaxPageSize), 0.0
+	one := 1.0
+	sql := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", g.selectList(), g.cfg.Table, g.key.Name)
+	if g.dbType == "sqlserver" {
+		sql += " OFFSET @offset ROWS FETCH NEXT @limit ROWS ONLY"
+	} else {
+		sql += " LIMIT @limit OFFSET @offset"
+	}
+	return workflow.WorkflowConfig{
+		Triggers: []workflow.TriggerConfig{{
+			Type:   "http",
+			Path:   g.cfg.BasePath(),
+			Method: "GET",
+			Parameters: []workflow.ParamConfig{
+				{Name: "limit", Type: "int", Default: fmt.Sprint(DefaultPageSize), Validation: &workflow.ParamValidation{Min: &one, Max: &limit}},
+				{Name: "offset", Type: "int", Default: "0", Validation: &workflow.ParamValidation{Min: &offset}},
+			},
+		}},
+		Steps: []workflow.StepConfig{
+			g.query("fetch", sql),
+			{Type: "response", Template: g.cfg.Envelope.SuccessTemplate("{{json .steps.fetch.data}}", "{{.steps.fetch.count}}")},
+		},
+	}
+}
+
+func (g *generator) get() workflow.WorkflowConfig {
+	sql := fmt.Sprintf("SELECT %s FROM %s WHERE %s = @%s", g.selectList(), g.cfg.Table, g.key.Name, g.key.Name)
+	return workflow.WorkflowConfig{
+		Conditions: map[string]string{"found": "steps.fetch.found", "not_found": "!steps.fetch.found"},
+		Triggers:   []workflow.TriggerConfig{g.itemTrigger("GET", nil)},
+		Steps: []workflow.StepConfig{
+			g.query("fetch", sql),
+			g.notFound(),
+			{Type: "response", Condition: "found", Template: g.cfg.Envelope.SuccessTemplate("{{json .steps.fetch.row}}", "")},
+		},
+	}
+}
+
+// create inserts the writable columns without a database default; the database
+// fills in generated and defaulted columns. SQL Server and SQLite return the new row.
+func (g *generator) create() (workflow.WorkflowConfig, error) {
+	var names, values []string
+	var params []workflow.ParamConfig
+	for _, col := range g.columns {
+		if col.Generated || col.HasDefault {
+			continue
+		}
+		names = append(names, col.Name)
+		values = append(values, g.value(col))
+		params = append(params, g.param(col, !col.Nullable))
+	}
+	if len(names) == 0 {
+		return workflow.WorkflowConfig{}, fmt.Errorf("%s has no columns to insert", g.cfg.Table)
+	}
+
+	sql := fmt.Sprintf("INSERT INTO %s (%s)", g.cfg.Table, strings.Join(names, ", "))
+	response := g.cfg.Envelope.SuccessTemplate("", "")
+	switch g.dbType {
+	case "sqlserver":
+		sql += fmt.Sprintf(" OUTPUT %s VALUES (%s)", g.prefixedSelectList("INSERTED."), strings.Join(values, ", "))
+		response = g.cfg.Envelope.SuccessTemplate("{{json .steps.insert.row}}", "")
+	case "sqlite":
+		sql += fmt.Sprintf(" VALUES (%s) RETURNING %s", strings.Join(values, ", "), g.selectList())
+		response = g.cfg.Envelope.SuccessTemplate("{{json .steps.insert.row}}", "")
+	default:
+		sql += fmt.Sprintf(" VALUES (%s)", strings.Join(values, ", "))
+	}
+
+	return workflow.WorkflowConfig{
+		Triggers: []workflow.TriggerConfig{{Type: "http", Path: g.cfg.BasePath(), Method: "POST", Parameters: params}},
+		Steps: []workflow.StepConfig{
+			g.query("insert", sql),
+			{Type: "response", StatusCode: 201, Template: response},
+		},
+	}, nil
+}
+
+// update replaces every writable column of an existing row. The row is looked up
+// first because MySQL reports zero affected rows when no value changes.
+func (g *generator) update() workflow.WorkflowConfig {
+	var sets []string
+	var params []workflow.ParamConfig
+	for _, col := range g.columns {
+		if col.Generated || col.Name == g.key.Name {
+			continue
+		}
+		sets = append(sets, col.Name+" = "+g.value(col))
+		params = append(params, g.param(col, !col.Nullable))
+	}
+
+	find := fmt.Sprintf("SELECT %s FROM %s WHERE %s = @%s", g.key.Name, g.cfg.Table, g.key.Name, g.key.Name)
+	steps := []workflow.StepConfig{g.query("find", find), g.notFound()}
+	if len(sets) > 0 {
+		update := g.query("update", fmt.Sprintf("UPDATE %s SET %s WHERE %s = @%s", g.cfg.Table, strings.Join(sets, ", "), g.key.Name, g.key.Name))
+		update.Condition = "found"
+		steps = append(steps, update)
+	}
+	steps = append(steps, workflow.StepConfig{Type: "response", Condition: "found", Template: g.cfg.Envelope.SuccessTemplate("", "")})
+
+	return workflow.WorkflowConfig{
+		Conditions: map[string]string{"found": "steps.find.found", "not_found": "!steps.find.found"},
+		Triggers:   []workflow.TriggerConfig{g.itemTrigger("PUT", params)},
+		Steps:      steps,
+	}
+}
+
+func (g *generator) delete() workflow.WorkflowConfig {
+	sql := fmt.Sprintf("DELETE FROM %s WHERE %s = @%s", g.cfg.Table, g.key.Name, g.key.Name)
+	return workflow.WorkflowConfig{
+		Conditions: map[string]string{"found": "steps.delete.rows_affected > 0", "not_found": "steps.delete.rows_affected == 0"},
+		Triggers:   []workflow.TriggerConfig{g.itemTrigger("DELETE", nil)},
+		Steps: []workflow.StepConfig{
+			g.query("delete", sql),
+			g.notFound(),
+			{Type: "response", Condition: "found", Template: g.cfg.Envelope.SuccessTemplate("", "")},
+		},
+	}
+}
+
+// itemTrigger is the trigger of a single-row endpoint: <path>/{key} plus extra params.
+func (g *generator) itemTrigger(method string, params []workflow.ParamConfig) workflow.TriggerConfig {
+	return workflow.TriggerConfig{
+		Type:       "http",
+		Path:       g.cfg.BasePath() + "/{" + g.key.Name + "}",
+		Method:     method,
+		Parameters: append([]workflow.ParamConfig{g.param(g.key, true)}, params...),
+	}
+}
+
+func (g *generator) query(name, sql string) workflow.StepConfig {
+	return workflow.StepConfig{Name: name, Type: "query", Database: g.cfg.Database, SQL: sql}
+}
+
+func (g *generator) param(col db.Column, required bool) workflow.ParamConfig {
+	return workflow.ParamConfig{Name: col.Name, Type: ParamType(col.Type), Required: required}
+}
+
+// value is the SQL value of a column's parameter. An omitted optional string
+// parameter is empty, so nullable text columns store NULL instead.
+func (g *generator) value(col db.Column) string {
+	if col.Nullable && ParamType(col.Type) == "string" {
+		return "NULLIF(@" + col.Name + ", '')"
+	}
+	return "@" + col.Name
+}
+
+func (g *generator) selectList() string {
+	return g.prefixedSelectList("")
+}
+
+func (g *generator) prefixedSelectList(prefix string) string {
+	names := make([]string, len(g.columns))
+	for i, col := range g.columns {
+		names[i] = prefix + col.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParamType maps a database column type to a parameter type. SQLite declares
+// arbitrary type names, so types are matched by the words they contain, as
+// SQLite's own type affinity rules do.
+func ParamType(sqlType string) string {
+	t := strings.ToLower(sqlType)
+	if i := strings.IndexByte(t, '('); i >= 0 {
+		t = strings.TrimSpace(t[:i])
+	}
+	switch {
+	case t == "bit" || strings.Contains(t, "bool"):
+		return "bool"
+	case strings.Contains(t, "int"):
+		return "int"
+	case strings.Contains(t, "dec"), strings.Contains(t, "numeric"), strings.Contains(t, "money"):
+		return "decimal"
+	case strings.Contains(t, "real"), strings.Contains(t, "floa"), strings.Contains(t, "doub"):
+		return "float"
+	case t == "date":
+		return "date"
+	case strings.Contains(t, "date"), strings.HasPrefix(t, "timestamp"):
+		return "datetime"
+	case t == "json":
+		return "json"
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,292 @@
+package crud
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/db"
+	"sql-proxy/internal/workflow"
+)
+
+var itemColumns = []db.Column{
+	{Name: "id", Type: "int", Generated: true},
+	{Name: "name", Type: "nvarchar"},
+	{Name: "price", Type: "decimal", Nullable: true},
+	{Name: "note", Type: "nvarchar", Nullable: true},
+	{Name: "status", Type: "varchar", HasDefault: true},
+	{Name: "version", Type: "timestamp", Generated: true},
+}
+
+func TestExpand(t *testing.T) {
+	c := config.CrudConfig{Name: "items", Database: "primary", Table: "dbo.Items", Key: "id"}
+
+	workflows, err := Expand(c, "sqlserver", itemColumns)
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+
+	byName := make(map[string]workflow.WorkflowConfig)
+	for _, wf := range workflows {
+		byName[wf.Name] = wf
+	}
+	tests := []struct {
+		workflow string
+		method   string
+		path     string
+		params   []string // name:type, with ! for required
+		sql      []string
+	}{
+		{
+			"items_list", "GET", "/api/items",
+			[]string{"limit:int", "offset:int"},
+			[]string{"SELECT id, name, price, note, status, version FROM dbo.Items ORDER BY id OFFSET @offset ROWS FETCH NEXT @limit ROWS ONLY"},
+		},
+		{
+			"items_get", "GET", "/api/items/{id}",
+			[]string{"id:int!"},
+			[]string{"SELECT id, name, price, note, status, version FROM dbo.Items WHERE id = @id"},
+		},
+		{
+			"items_create", "POST", "/api/items",
+			[]string{"name:string!", "price:decimal", "note:string"},
+			[]string{"INSERT INTO dbo.Items (name, price, note) OUTPUT INSERTED.id, INSERTED.name, INSERTED.price, INSERTED.note, INSERTED.status, INSERTED.version VALUES (@name, @price, NULLIF(@note, ''))"},
+		},
+		{
+			"items_update", "PUT", "/api/items/{id}",
+			[]string{"id:int!", "name:string!", "price:decimal", "note:string", "status:string!"},
+			[]string{
+				"SELECT id FROM dbo.Items WHERE id = @id",
+				"UPDATE dbo.Items SET name = @name, price = @price, note = NULLIF(@note, ''), status = @status WHERE id = @id",
+			},
+		},
+		{
+			"items_delete", "DELETE", "/api/items/{id}",
+			[]string{"id:int!"},
+			[]string{"DELETE FROM dbo.Items WHERE id = @id"},
+		},
+	}
+	if len(workflows) != len(tests) {
+		t.Errorf("Expand() returned %d workflows, want %d", len(workflows), len(tests))
+	}
+
+	validationCtx := &workflow.ValidationContext{
+		Databases:     map[string]bool{"primary": false},
+		DatabaseTypes: map[string]string{"primary": "sqlserver"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.workflow, func(t *testing.T) {
+			wf, ok := byName[tt.workflow]
+			if !ok {
+				t.Fatalf("workflow %s not generated", tt.workflow)
+			}
+			trigger := wf.Triggers[0]
+			if trigger.Method != tt.method || trigger.Path != tt.path {
+				t.Errorf("route = %s %s, want %s %s", trigger.Method, trigger.Path, tt.method, tt.path)
+			}
+
+			var params []string
+			for _, p := range trigger.Parameters {
+				s := p.Name + ":" + p.Type
+				if p.Required {
+					s += "!"
+				}
+				params = append(params, s)
+			}
+			if !reflect.DeepEqual(params, tt.params) {
+				t.Errorf("params = %v, want %v", params, tt.params)
+			}
+
+			var sql []string
+			for _, s := range wf.Steps {
+				if s.Type == "query" {
+					sql = append(sql, s.SQL)
+				}
+			}
+			if !reflect.DeepEqual(sql, tt.sql) {
+				t.Errorf("sql =\n%s\nwant\n%s", strings.Join(sql, "\n"), strings.Join(tt.sql, "\n"))
+			}
+
+			if result := workflow.Validate(&wf, validationCtx); !result.Valid {
+				t.Errorf("generated workflow is invalid: %v", result.Errors)
+			}
+		})
+	}
+}
+
+func TestExpand_Dialects(t *testing.T) {
+	c := config.CrudConfig{Name: "items", Database: "db", Table: "items", Key: "id", Path: "/v1/items", Operations: []string{"list", "create"}}
+
+	tests := []struct {
+		dbType     string
+		wantList   string
+		wantCreate string
+	}{
+		{
+			"sqlite",
+			"SELECT id, name FROM items ORDER BY id LIMIT @limit OFFSET @offset",
+			"INSERT INTO items (name) VALUES (@name) RETURNING id, name",
+		},
+		{
+			"mysql",
+			"SELECT id, name FROM items ORDER BY id LIMIT @limit OFFSET @offset",
+			"INSERT INTO items (name) VALUES (@name)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dbType, func(t *testing.T) {
+			workflows, err := Expand(c, tt.dbType, []db.Column{{Name: "id", Type: "INTEGER", Generated: true}, {Name: "name", Type: "TEXT"}})
+			if err != nil {
+				t.Fatalf("Expand failed: %v", err)
+			}
+			if len(workflows) != 2 || workflows[0].Name != "items_list" || workflows[1].Name != "items_create" {
+				t.Fatalf("Expand() = %d workflows, want items_list and items_create", len(workflows))
+			}
+			if got := workflows[0].Steps[0].SQL; got != tt.wantList {
+				t.Errorf("list sql = %q, want %q", got, tt.wantList)
+			}
+			if got := workflows[1].Steps[0].SQL; got != tt.wantCreate {
+				t.Errorf("create sql = %q, want %q", got, tt.wantCreate)
+			}
+			if got := workflows[1].Triggers[0].Path; got != "/v1/items" {
+				t.Errorf("path = %q, want /v1/items", got)
+			}
+		})
+	}
+}
+
+func TestExpand_Envelope(t *testing.T) {
+	columns := []db.Column{{Name: "id", Type: "INTEGER", Generated: true}, {Name: "name", Type: "TEXT"}}
+	responses := func(t *testing.T, c config.CrudConfig) []string {
+		t.Helper()
+		workflows, err := Expand(c, "sqlite", columns)
+		if err != nil {
+			t.Fatalf("Expand failed: %v", err)
+		}
+		var templates []string
+		for _, wf := range workflows {
+			if wf.Envelope != c.Envelope {
+				t.Errorf("%s envelope = %v, want %v", wf.Name, wf.Envelope, c.Envelope)
+			}
+			for _, s := range wf.Steps {
+				if s.Type == "response" {
+					templates = append(templates, s.Template)
+				}
+			}
+		}
+		return templates
+	}
+
+	c := config.CrudConfig{Name: "items", Database: "db", Table: "items", Key: "id", Operations: []string{"list", "get"}}
+	want := []string{
+		`{"success": true, "data": {{json .steps.fetch.data}}, "count": {{.steps.fetch.count}}}`,
+		`{"success": false, "error": "not found", "code": "SQLPROXY_NOT_FOUND"}`,
+		`{"success": true, "data": {{json .steps.fetch.row}}}`,
+	}
+	if got := responses(t, c); !reflect.DeepEqual(got, want) {
+		t.Errorf("default templates =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	c.Envelope = &workflow.EnvelopeConfig{Success: "-", Data: "items", Error: "message", Code: "-", Meta: "meta"}
+	want = []string{
+		`{"items": {{json .steps.fetch.data}}, "meta": {"count": {{.steps.fetch.count}}}}`,
+		`{"message": "not found"}`,
+		`{"items": {{json .steps.fetch.row}}}`,
+	}
+	if got := responses(t, c); !reflect.DeepEqual(got, want) {
+		t.Errorf("custom templates =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	c.Envelope = &workflow.EnvelopeConfig{Flat: true}
+	if got := responses(t, c); got[0] != `{{json .steps.fetch.data}}` || got[2] != `{{json .steps.fetch.row}}` {
+		t.Errorf("flat templates = %v", got)
+	}
+
+	c.ErrorFormat = workflow.ErrorFormatProblemJSON
+	workflows, err := Expand(c, "sqlite", columns)
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+	notFound := workflows[1].Steps[1]
+	wantTemplate := `{"type": "about:blank", "title": "Not Found", "status": 404, "detail": "not found", "code": "SQLPROXY_NOT_FOUND", "instance": {{json (printf "urn:request:%s" .workflow.request_id)}}}`
+	if workflows[1].ErrorFormat != workflow.ErrorFormatProblemJSON || notFound.Template != wantTemplate || notFound.Headers["Content-Type"] != workflow.ProblemContentType {
+		t.Errorf("problem_json not found step = %+v", notFound)
+	}
+}
+
+func TestExpand_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.CrudConfig
+		columns []db.Column
+		wantErr string
+	}{
+		{
+			"missing key",
+			config.CrudConfig{Name: "items", Table: "items", Key: "item_id"},
+			itemColumns,
+			`key column "item_id" not found in items`,
+		},
+		{
+			"unusable column name",
+			config.CrudConfig{Name: "items", Table: "items", Key: "id"},
+			[]db.Column{{Name: "id", Type: "int"}, {Name: "unit price", Type: "money"}},
+			`column "unit price" of items cannot be used as a parameter name`,
+		},
+		{
+			"nothing to insert",
+			config.CrudConfig{Name: "items", Table: "items", Key: "id", Operations: []string{"create"}},
+			[]db.Column{{Name: "id", Type: "int", Generated: true}, {Name: "created", Type: "datetime", HasDefault: true}},
+			"items has no columns to insert",
+		},
+		{
+			"invalid table",
+			config.CrudConfig{Name: "items", Table: "items; DROP TABLE x", Key: "id"},
+			itemColumns,
+			"invalid table name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Expand(tt.cfg, "sqlserver", tt.columns)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expand() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParamType(t *testing.T) {
+	tests := map[string]string{
+		"int":               "int",
+		"BIGINT":            "int",
+		"INTEGER":           "int",
+		"tinyint(1)":        "int",
+		"bit":               "bool",
+		"BOOLEAN":           "bool",
+		"decimal":           "decimal",
+		"numeric(10,2)":     "decimal",
+		"money":             "decimal",
+		"smallmoney":        "decimal",
+		"REAL":              "float",
+		"double precision":  "float",
+		"date":              "date",
+		"datetime2":         "datetime",
+		"datetimeoffset":    "datetime",
+		"timestamp":         "datetime",
+		"json":              "json",
+		"nvarchar":          "string",
+		"TEXT":              "string",
+		"uniqueidentifier":  "string",
+		"VARCHAR(255)":      "string",
+		"":                  "string",
+		"smalldatetime":     "datetime",
+		"character varying": "string",
+	}
+	for sqlType, want := range tests {
+		if got := ParamType(sqlType); got != want {
+			t.Errorf("ParamType(%q) = %q, want %q", sqlType, got, want)
+		}
+	}
+}
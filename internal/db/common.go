@@ -1,10 +1,23 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"strconv"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
+	mssql "github.com/microsoft/go-mssqldb"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"sql-proxy/internal/decimal"
 	"sql-proxy/internal/sqlutil"
 	"sql-proxy/internal/workflow/step"
 )
@@ -18,12 +31,263 @@ var ParamRegex = sqlutil.ParamRegex
 // For INSERT/UPDATE/DELETE, RowsAffected contains the number of affected rows.
 type QueryResult = step.QueryResult
 
+// ProcCall describes a stored procedure invocation with its bound parameters.
+type ProcCall = step.ProcCall
+
+// ProcParam is a bound stored procedure parameter.
+type ProcParam = step.ProcParam
+
+// ResultLimit caps the rows a query reads into memory.
+type ResultLimit = step.ResultLimit
+
+type resultLimitKey struct{}
+
+// WithResultLimit returns a context under which drivers stop reading results
+// once limit is reached and mark the QueryResult as Truncated.
+func WithResultLimit(ctx context.Context, limit *ResultLimit) context.Context {
+	return context.WithValue(ctx, resultLimitKey{}, limit)
+}
+
+// OutboxEvent is an event inserted into an outbox table alongside a statement.
+type OutboxEvent = step.OutboxEvent
+
+type outboxKey struct{}
+
+type outbox struct {
+	table  string
+	events []OutboxEvent
+}
+
+// WithOutbox returns a context under which drivers run the statement in a
+// transaction that also inserts events into table, so both commit or neither does.
+func WithOutbox(ctx context.Context, table string, events []OutboxEvent) context.Context {
+	return context.WithValue(ctx, outboxKey{}, &outbox{table: table, events: events})
+}
+
+// OutboxInsertSQL is the statement that records one outbox event.
+func OutboxInsertSQL(table string) string {
+	return "INSERT INTO " + table + " (id, topic, event_key, payload, created_at) VALUES (@id, @topic, @event_key, @payload, @created_at)"
+}
+
+// execer runs statements on a connection or inside a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// binder translates a @param query into the driver's syntax and arguments
+type binder func(query string, params map[string]any) (string, []any)
+
+// runStatement calls run on conn, or, when ctx carries outbox events, inside a
+// transaction that inserts the events after run succeeds.
+func runStatement(ctx context.Context, conn *sql.Conn, bind binder, run func(q execer) (*QueryResult, error)) (*QueryResult, error) {
+	ob, _ := ctx.Value(outboxKey{}).(*outbox)
+	if ob == nil || len(ob.events) == 0 {
+		return run(conn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	qr, err := run(tx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	for _, ev := range ob.events {
+		query, args := bind(OutboxInsertSQL(ob.table), map[string]any{
+			"id": ev.ID, "topic": ev.Topic, "event_key": ev.Key, "payload": ev.Payload, "created_at": now,
+		})
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return nil, fmt.Errorf("outbox insert failed: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit failed: %w", err)
+	}
+	return qr, nil
+}
+
 // IsWriteQuery returns true if the SQL is a write operation.
 var IsWriteQuery = sqlutil.IsWriteQuery
 
 // HasReturningClause returns true if a write query has OUTPUT/RETURNING.
 var HasReturningClause = sqlutil.HasReturningClause
 
+// warmupTimeout bounds all of a connection's warmup statements together
+const warmupTimeout = 60 * time.Second
+
+// probe checks that a pool can reach the database: a bare ping, or the
+// probe_query when one is configured (a ping may only reach a connection pooler).
+func probe(ctx context.Context, conn *sql.DB, probeQuery string) error {
+	if probeQuery == "" {
+		return conn.PingContext(ctx)
+	}
+	rows, err := conn.QueryContext(ctx, probeQuery)
+	if err != nil {
+		return fmt.Errorf("probe query: %w", err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return fmt.Errorf("probe query: %w", err)
+	}
+	return rows.Close()
+}
+
+// openPool opens a connection pool like sql.Open. With warmup statements, each
+// connection runs them in order when it is opened, so whatever they prime holds
+// on every pooled connection, including ones opened to replace expired or
+// broken connections.
+func openPool(driverName, dsn string, warmup []string) (*sql.DB, error) {
+	conn, err := sql.Open(driverName, dsn)
+	if err != nil || len(warmup) == 0 {
+		return conn, err
+	}
+	drv := conn.Driver()
+	_ = conn.Close()
+
+	var connector driver.Connector = dsnConnector{driver: drv, dsn: dsn}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(&warmupConnector{Connector: connector, statements: warmup}), nil
+}
+
+// dsnConnector is the connector of drivers that only open connections by DSN
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// warmupConnector runs the warmup statements on each connection it opens. A
+// failing statement closes the connection and fails the operation that
+// needed it.
+type warmupConnector struct {
+	driver.Connector
+	statements []string
+}
+
+func (c *warmupConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, warmupTimeout)
+	defer cancel()
+	for i, stmt := range c.statements {
+		if err := execConn(ctx, conn, stmt); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("warmup[%d]: %w", i, err)
+		}
+	}
+	return conn, nil
+}
+
+// Close closes the wrapped connector when it holds resources; sql.DB.Close calls it
+func (c *warmupConnector) Close() error {
+	if closer, ok := c.Connector.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// execConn runs a statement without parameters on a driver connection
+func execConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		if !errors.Is(err, driver.ErrSkip) {
+			return err
+		}
+	}
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = conn.Prepare(query)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+	if execer, ok := stmt.(driver.StmtExecContext); ok {
+		_, err = execer.ExecContext(ctx, nil)
+		return err
+	}
+	_, err = stmt.Exec(nil)
+	return err
+}
+
+// IsConnectionError reports whether err means the connection to the database was
+// lost or refused, as opposed to an error in the query itself. Query timeouts and
+// cancellations are not connection errors.
+func IsConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &netErr)
+}
+
+// ErrorClass returns the step.ErrorClass* of a query error, or "" for other errors.
+func ErrorClass(err error) string {
+	var msErr mssql.Error
+	var myErr *mysql.MySQLError
+	var liteErr *sqlite.Error
+	var classified *step.ClassifiedError
+	switch {
+	case errors.As(err, &classified): // Mock database fixtures
+		return classified.Class
+	case errors.Is(err, context.DeadlineExceeded):
+		return step.ErrorClassTimeout
+	case errors.As(err, &msErr):
+		switch msErr.SQLErrorNumber() {
+		case 1205: // Deadlock victim
+			return step.ErrorClassDeadlock
+		case 1222: // Lock request timeout (lock_timeout_ms)
+			return step.ErrorClassTimeout
+		case 2627, 2601, 547, 515: // Unique/primary key, unique index, foreign key/check, NULL
+			return step.ErrorClassConstraintViolation
+		}
+	case errors.As(err, &myErr):
+		switch myErr.Number {
+		case 1213: // ER_LOCK_DEADLOCK
+			return step.ErrorClassDeadlock
+		case 1205: // ER_LOCK_WAIT_TIMEOUT (lock_timeout_ms)
+			return step.ErrorClassTimeout
+		case 1062, 1451, 1452, 1048, 3819: // Duplicate key, foreign key (parent, child), NULL, check
+			return step.ErrorClassConstraintViolation
+		}
+	case errors.As(err, &liteErr):
+		switch liteErr.Code() & 0xff { // Primary result code of an extended code
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return step.ErrorClassDeadlock
+		case sqlite3.SQLITE_CONSTRAINT:
+			return step.ErrorClassConstraintViolation
+		}
+	}
+	return ""
+}
+
 // resolveIsWrite returns the precomputed hint if available, otherwise parses the query.
 func resolveIsWrite(hints *QueryHints, query string) bool {
 	if hints != nil && hints.IsWrite != nil {
@@ -40,9 +304,53 @@ func resolveHasReturning(hints *QueryHints, query string) bool {
 	return HasReturningClause(query)
 }
 
+// scanColumns reads catalog rows of (name, type, nullable, has_default, generated)
+// and closes rows. A table with no columns does not exist.
+func scanColumns(rows *sql.Rows, table string) ([]Column, error) {
+	defer func() { _ = rows.Close() }()
+
+	var columns []Column
+	for rows.Next() {
+		var c Column
+		if err := rows.Scan(&c.Name, &c.Type, &c.Nullable, &c.HasDefault, &c.Generated); err != nil {
+			return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+		columns = append(columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	return columns, nil
+}
+
 // ScanRows converts sql.Rows to []map[string]any.
 // Shared across database drivers.
 func ScanRows(rows *sql.Rows) ([]map[string]any, error) {
+	return (&rowScanner{}).scan(rows)
+}
+
+// rowScanner scans result sets, stopping once the ResultLimit shared by all
+// of them is reached so an unbounded query cannot exhaust memory.
+type rowScanner struct {
+	limit     *ResultLimit
+	skipped   int
+	kept      int
+	bytes     int
+	read      int  // Rows read from the current result set, kept or not
+	truncated bool // Rows were left unread
+}
+
+// newRowScanner returns a scanner for the ResultLimit carried by ctx, if any.
+func newRowScanner(ctx context.Context) *rowScanner {
+	limit, _ := ctx.Value(resultLimitKey{}).(*ResultLimit)
+	return &rowScanner{limit: limit}
+}
+
+// scan reads the current result set of rows.
+func (s *rowScanner) scan(rows *sql.Rows) ([]map[string]any, error) {
 	columns, err := rows.Columns()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get columns: %w", err)
@@ -50,7 +358,14 @@ func ScanRows(rows *sql.Rows) ([]map[string]any, error) {
 
 	var results []map[string]any
 
-	for rows.Next() {
+	s.read = 0
+	for !s.truncated && rows.Next() {
+		s.read++
+		if s.limit != nil && s.skipped < s.limit.Offset {
+			s.skipped++
+			continue
+		}
+
 		values := make([]any, len(columns))
 		valuePtrs := make([]any, len(columns))
 		for i := range values {
@@ -63,15 +378,11 @@ func ScanRows(rows *sql.Rows) ([]map[string]any, error) {
 
 		row := make(map[string]any)
 		for i, col := range columns {
-			val := values[i]
-			switch v := val.(type) {
-			case []byte:
-				row[col] = string(v)
-			case time.Time:
-				row[col] = v.Format(time.RFC3339)
-			default:
-				row[col] = v
-			}
+			row[col] = normalizeValue(values[i])
+		}
+		if s.limit != nil && !s.keep(row) {
+			s.truncated = true
+			break
 		}
 		results = append(results, row)
 	}
@@ -82,3 +393,155 @@ func ScanRows(rows *sql.Rows) ([]map[string]any, error) {
 
 	return results, nil
 }
+
+// keep counts row against the limit, reporting false if it does not fit.
+func (s *rowScanner) keep(row map[string]any) bool {
+	if s.limit.MaxRows > 0 && s.kept >= s.limit.MaxRows {
+		return false
+	}
+	if s.limit.MaxBytes > 0 {
+		// Approximates the row's share of the JSON response, separator included
+		encoded, _ := json.Marshal(row)
+		if s.bytes+len(encoded)+1 > s.limit.MaxBytes {
+			return false
+		}
+		s.bytes += len(encoded) + 1
+	}
+	s.kept++
+	return true
+}
+
+// written counts the rows of the current result set, reading the ones a limit
+// left unread. A write with RETURNING or OUTPUT changed every row it returns,
+// whether or not the result kept it.
+func (s *rowScanner) written(rows *sql.Rows) (int64, error) {
+	n := int64(s.read)
+	for rows.Next() {
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("row iteration error: %w", err)
+	}
+	return n, nil
+}
+
+// normalizeValue converts driver values to their JSON-friendly form.
+func normalizeValue(val any) any {
+	switch v := val.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return v
+	}
+}
+
+// scanSets scans every result set of rows, skipping sets without columns
+// (such as the status of statements inside a procedure). Sets after the
+// limit is reached are not read.
+func (s *rowScanner) scanSets(rows *sql.Rows) ([][]map[string]any, error) {
+	var sets [][]map[string]any
+	for {
+		columns, err := rows.Columns()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get columns: %w", err)
+		}
+		if len(columns) > 0 {
+			set, err := s.scan(rows)
+			if err != nil {
+				return nil, err
+			}
+			if set == nil {
+				set = []map[string]any{}
+			}
+			sets = append(sets, set)
+		}
+		if s.truncated || !rows.NextResultSet() {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("result set iteration error: %w", err)
+	}
+	return sets, nil
+}
+
+// procValue converts a procedure parameter value to its declared type.
+// nil stays nil so NULL can be passed and returned.
+func procValue(typ string, val any) (any, error) {
+	if val == nil {
+		return nil, nil
+	}
+	if b, ok := val.([]byte); ok {
+		val = string(b)
+	}
+	switch typ {
+	case "int":
+		switch v := val.(type) {
+		case int:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case float64:
+			if v != float64(int64(v)) {
+				return nil, fmt.Errorf("%v is not an integer", v)
+			}
+			return int64(v), nil
+		case string:
+			return strconv.ParseInt(v, 10, 64)
+		}
+	case "float":
+		switch v := val.(type) {
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case float32:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			return strconv.ParseFloat(v, 64)
+		}
+	case "decimal":
+		// Kept as a string so no digits are lost on the way to or from the database
+		var d decimal.Decimal
+		var err error
+		switch v := val.(type) {
+		case string:
+			d, err = decimal.Parse(v)
+		case int64:
+			d = decimal.FromInt(v)
+		case int:
+			d = decimal.FromInt(int64(v))
+		case float64:
+			d, err = decimal.FromFloat(v)
+		default:
+			return nil, fmt.Errorf("cannot convert %T to %s", val, typ)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return d.String(), nil
+	case "string":
+		if s, ok := val.(string); ok {
+			return s, nil
+		}
+		return fmt.Sprint(val), nil
+	case "bool":
+		switch v := val.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			return v != 0, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s", val, typ)
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"sql-proxy/internal/config"
 )
@@ -16,13 +17,28 @@ type QueryHints struct {
 
 // PoolStats contains connection pool statistics.
 type PoolStats struct {
-	OpenConnections int
-	IdleConnections int
+	OpenConnections    int
+	IdleConnections    int
+	InUse              int           // Connections currently executing queries
+	MaxOpenConnections int           // Pool limit (0 = unlimited)
+	WaitCount          int64         // Queries that waited for a free connection (since connect)
+	WaitDuration       time.Duration // Total time queries spent waiting for a connection
+}
+
+// Column describes a table column as reported by the database catalog.
+type Column struct {
+	Name       string
+	Type       string // Database type name, e.g. "int", "varchar", "datetime2"
+	Nullable   bool
+	HasDefault bool
+	Generated  bool // Identity, auto-increment, computed or rowversion: not writable
 }
 
 // Driver is the interface all database implementations must satisfy.
 type Driver interface {
 	Query(ctx context.Context, sessCfg config.SessionConfig, query string, params map[string]any, hints *QueryHints) (*QueryResult, error)
+	CallProc(ctx context.Context, sessCfg config.SessionConfig, call ProcCall) (*QueryResult, error)
+	TableColumns(ctx context.Context, table string) ([]Column, error)
 	Ping(ctx context.Context) error
 	Close() error
 	Reconnect() error
@@ -43,6 +59,8 @@ func NewDriver(cfg config.DatabaseConfig) (Driver, error) {
 		return NewSQLiteDriver(cfg)
 	case "mysql":
 		return NewMySQLDriver(cfg)
+	case "mock":
+		return NewMockDriver(cfg)
 	case "postgres":
 		return nil, fmt.Errorf("postgres support not yet implemented")
 	default:
@@ -1,12 +1,23 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"strings"
+	"syscall"
 	"testing"
 
+	"github.com/go-sql-driver/mysql"
+	mssql "github.com/microsoft/go-mssqldb"
+
 	"sql-proxy/internal/config"
+	"sql-proxy/internal/workflow/step"
 )
 
 func envOrDefault(key, fallback string) string {
@@ -321,3 +332,105 @@ func TestNewDriver_AllTypes(t *testing.T) {
 		})
 	}
 }
+
+// TestProcValue verifies procedure parameter values convert to their declared types
+func TestProcValue(t *testing.T) {
+	tests := []struct {
+		typ     string
+		in      any
+		want    any
+		wantErr bool
+	}{
+		{"int", float64(3), int64(3), false},
+		{"int", "12", int64(12), false},
+		{"int", []byte("7"), int64(7), false},
+		{"int", float64(1.5), nil, true},
+		{"float", int64(2), float64(2), false},
+		{"float", "2.5", 2.5, false},
+		{"string", int64(5), "5", false},
+		{"bool", "true", true, false},
+		{"bool", int64(0), false, false},
+		{"bool", float64(1), nil, true},
+		{"int", nil, nil, false},
+		{"decimal", "12.50", "12.50", false},
+		{"decimal", []byte("0.10"), "0.10", false},
+		{"decimal", float64(0.1), "0.1", false},
+		{"decimal", int64(3), "3", false},
+		{"decimal", "abc", nil, true},
+		{"money", "1", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := procValue(tt.typ, tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("procValue(%s, %v) error = %v, wantErr %v", tt.typ, tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("procValue(%s, %v) = %v (%T), want %v (%T)", tt.typ, tt.in, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+// TestIsConnectionError verifies lost connections are told apart from query errors and timeouts
+func TestIsConnectionError(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"bad conn", driver.ErrBadConn, true},
+		{"conn done", sql.ErrConnDone, true},
+		{"mysql invalid conn", fmt.Errorf("query failed: %w", mysql.ErrInvalidConn), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"connection refused", fmt.Errorf("query failed: %w", refused), true},
+		{"query error", errors.New("near \"SELEC\": syntax error"), false},
+		{"no rows", sql.ErrNoRows, false},
+		{"timeout", fmt.Errorf("query failed: %w", context.DeadlineExceeded), false},
+		{"canceled", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsConnectionError(tt.err); got != tt.want {
+				t.Errorf("IsConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestErrorClass verifies deadlocks, timeouts and constraint violations are recognized for each driver
+func TestErrorClass(t *testing.T) {
+	sqliteDriver := createTestSQLiteDriver(t)
+	defer func() { _ = sqliteDriver.Close() }()
+	createTestTable(t, sqliteDriver)
+	_, sqliteErr := sqliteDriver.Query(context.Background(), config.SessionConfig{}, "INSERT INTO test_users (name, email) VALUES (NULL, 'a@example.com')", nil, nil)
+	if sqliteErr == nil {
+		t.Fatal("expected NOT NULL error")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"sqlserver deadlock", fmt.Errorf("query failed: %w", mssql.Error{Number: 1205}), step.ErrorClassDeadlock},
+		{"sqlserver unique", mssql.Error{Number: 2627}, step.ErrorClassConstraintViolation},
+		{"sqlserver syntax", mssql.Error{Number: 102}, ""},
+		{"mysql deadlock", fmt.Errorf("exec failed: %w", &mysql.MySQLError{Number: 1213}), step.ErrorClassDeadlock},
+		{"mysql foreign key", &mysql.MySQLError{Number: 1452}, step.ErrorClassConstraintViolation},
+		{"sqlite not null", sqliteErr, step.ErrorClassConstraintViolation},
+		{"timeout", fmt.Errorf("query failed: %w", context.DeadlineExceeded), step.ErrorClassTimeout},
+		{"sqlserver lock timeout", mssql.Error{Number: 1222}, step.ErrorClassTimeout},
+		{"other", errors.New("near \"SELEC\": syntax error"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorClass(tt.err); got != tt.want {
+				t.Errorf("ErrorClass(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
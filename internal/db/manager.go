@@ -2,36 +2,205 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"sql-proxy/internal/concurrency"
 	"sql-proxy/internal/config"
+	"sql-proxy/internal/policy"
 )
 
+// ErrDatabaseExists is returned when adding a database whose name is already in use.
+var ErrDatabaseExists = errors.New("database already exists")
+
 // Manager manages multiple database connections
 type Manager struct {
 	connections map[string]Driver
-	mu          sync.RWMutex
+	mu          sync.RWMutex // Guards all maps; databases can be added and removed at runtime
+
+	// Concurrent query limits per connection (only for databases with max_concurrent)
+	limiters map[string]*concurrency.Limiter
+
+	// Statement policies per connection (only for databases with a policy)
+	policies map[string]*policy.Policy
+
+	// Tenant-routed databases, whose connections are opened per tenant on first use
+	routers map[string]*TenantRouter
 }
 
 // NewManager creates a new connection manager from database configs
 func NewManager(configs []config.DatabaseConfig) (*Manager, error) {
 	m := &Manager{
 		connections: make(map[string]Driver),
+		limiters:    make(map[string]*concurrency.Limiter),
+		policies:    make(map[string]*policy.Policy),
+		routers:     make(map[string]*TenantRouter),
+	}
+
+	// Tenant-routed databases go last, once their lookup connections exist
+	for _, routed := range []bool{false, true} {
+		for _, cfg := range configs {
+			if (cfg.TenantRouting != nil) != routed {
+				continue
+			}
+			if err := m.Add(cfg); err != nil {
+				// Clean up any connections we've already made
+				_ = m.Close()
+				return nil, err
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// Add connects to a database and makes it available under its name. Regular
+// databases are pinged before they are added; tenant-routed ones connect lazily.
+// Returns ErrDatabaseExists if the name is taken.
+func (m *Manager) Add(cfg config.DatabaseConfig) error {
+	if m.has(cfg.Name) {
+		return fmt.Errorf("%w: %s", ErrDatabaseExists, cfg.Name)
+	}
+	p, err := policy.New(cfg.Policy)
+	if err != nil {
+		return fmt.Errorf("database %s: %w", cfg.Name, err)
 	}
 
-	for _, cfg := range configs {
-		driver, err := NewDriver(cfg)
-		if err != nil {
-			// Clean up any connections we've already made
-			_ = m.Close()
-			return nil, fmt.Errorf("failed to connect to database %s: %w", cfg.Name, err)
+	var driver Driver
+	var router *TenantRouter
+	if cfg.TenantRouting != nil {
+		var lookup Driver
+		if l := cfg.TenantRouting.Lookup; l != nil {
+			m.mu.RLock()
+			lookup = m.connections[l.Database]
+			m.mu.RUnlock()
+			if lookup == nil {
+				return fmt.Errorf("database %s: unknown tenant lookup database %s", cfg.Name, l.Database)
+			}
 		}
+		router = NewTenantRouter(cfg, lookup)
+	} else if driver, err = NewDriver(cfg); err != nil {
+		return fmt.Errorf("failed to connect to database %s: %w", cfg.Name, err)
+	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.connections[cfg.Name] != nil || m.routers[cfg.Name] != nil {
+		// Added concurrently while we were connecting
+		if driver != nil {
+			_ = driver.Close()
+		}
+		return fmt.Errorf("%w: %s", ErrDatabaseExists, cfg.Name)
+	}
+	if router != nil {
+		m.routers[cfg.Name] = router
+	} else {
 		m.connections[cfg.Name] = driver
+		if l := concurrency.New(cfg.MaxConcurrent, time.Duration(cfg.MaxConcurrentWaitMs)*time.Millisecond); l != nil {
+			m.limiters[cfg.Name] = l
+		}
+	}
+	if p != nil {
+		m.policies[cfg.Name] = p
 	}
+	return nil
+}
 
-	return m, nil
+// Remove closes the named database and forgets it. Queries already running on it
+// fail; callers must make sure nothing still routes to it.
+func (m *Manager) Remove(name string) error {
+	m.mu.Lock()
+	driver, router := m.connections[name], m.routers[name]
+	delete(m.connections, name)
+	delete(m.routers, name)
+	delete(m.limiters, name)
+	delete(m.policies, name)
+	m.mu.Unlock()
+
+	switch {
+	case driver != nil:
+		return driver.Close()
+	case router != nil:
+		return router.Close()
+	}
+	return fmt.Errorf("unknown database connection: %s", name)
+}
+
+// has reports whether a database is registered under name
+func (m *Manager) has(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.connections[name] != nil || m.routers[name] != nil
+}
+
+// Acquire takes a query slot on the named database, waiting up to its configured
+// max_concurrent_wait_ms. Returns concurrency.ErrLimitReached when no slot frees up.
+// The returned release func must be called when the query completes.
+func (m *Manager) Acquire(ctx context.Context, name string) (release func(), err error) {
+	m.mu.RLock()
+	l := m.limiters[name]
+	m.mu.RUnlock()
+	if err := l.Acquire(ctx); err != nil {
+		return nil, fmt.Errorf("database %s: %w", name, err)
+	}
+	return l.Release, nil
+}
+
+// AcquireTenant returns the tenant's connection to a tenant-routed database, opening
+// it on first use, and takes one of the tenant's max_concurrent slots. The returned
+// release func must be called when the query completes.
+func (m *Manager) AcquireTenant(ctx context.Context, name, tenant string) (Driver, func(), error) {
+	m.mu.RLock()
+	r := m.routers[name]
+	m.mu.RUnlock()
+	if r == nil {
+		return nil, nil, fmt.Errorf("database %s is not tenant-routed", name)
+	}
+	driver, release, err := r.Acquire(ctx, tenant)
+	if err != nil {
+		return nil, nil, fmt.Errorf("database %s: %w", name, err)
+	}
+	return driver, release, nil
+}
+
+// IsTenantRouted reports whether the named database routes queries per tenant
+func (m *Manager) IsTenantRouted(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.routers[name] != nil
+}
+
+// TenantStats returns usage of tenant-routed databases
+func (m *Manager) TenantStats() map[string]TenantStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make(map[string]TenantStats, len(m.routers))
+	for name, r := range m.routers {
+		stats[name] = r.Stats()
+	}
+	return stats
+}
+
+// Policy returns the statement policy of the named database (nil when unrestricted)
+func (m *Manager) Policy(name string) *policy.Policy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.policies[name]
+}
+
+// ConcurrencyStats returns usage of databases that have a concurrency limit
+func (m *Manager) ConcurrencyStats() map[string]concurrency.Stats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make(map[string]concurrency.Stats, len(m.limiters))
+	for name, l := range m.limiters {
+		stats[name] = l.Stats()
+	}
+	return stats
 }
 
 // Get returns the database driver with the given name
@@ -41,6 +210,9 @@ func (m *Manager) Get(name string) (Driver, error) {
 
 	driver, ok := m.connections[name]
 	if !ok {
+		if m.routers[name] != nil {
+			return nil, fmt.Errorf("database %s is tenant-routed: queries need a tenant", name)
+		}
 		return nil, fmt.Errorf("unknown database connection: %s", name)
 	}
 	return driver, nil
@@ -70,6 +242,12 @@ func (m *Manager) Close() error {
 		}
 	}
 	m.connections = make(map[string]Driver)
+	for name, r := range m.routers {
+		if err := r.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("database %s: %w", name, err)
+		}
+	}
+	m.routers = make(map[string]*TenantRouter)
 	return firstErr
 }
 
@@ -2,10 +2,13 @@ package db
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 
+	"sql-proxy/internal/concurrency"
 	"sql-proxy/internal/config"
+	"sql-proxy/internal/policy"
 )
 
 // TestNewManager_SingleDatabase verifies manager creation with one SQLite database
@@ -423,3 +426,139 @@ func TestManager_MixedDatabaseTypes(t *testing.T) {
 		t.Errorf("expected busy timeout 10000 for readwrite_file")
 	}
 }
+
+// TestManager_Acquire verifies max_concurrent query slots per database
+func TestManager_Acquire(t *testing.T) {
+	cfg := []config.DatabaseConfig{
+		{Name: "limited", Type: "sqlite", Path: ":memory:", MaxConcurrent: 1},
+		{Name: "open", Type: "sqlite", Path: ":memory:"},
+	}
+
+	manager, err := NewManager(cfg)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	ctx := context.Background()
+	release, err := manager.Acquire(ctx, "limited")
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+
+	if _, err := manager.Acquire(ctx, "limited"); !errors.Is(err, concurrency.ErrLimitReached) {
+		t.Errorf("expected ErrLimitReached, got %v", err)
+	}
+
+	// Unlimited databases never block
+	for range 10 {
+		if _, err := manager.Acquire(ctx, "open"); err != nil {
+			t.Fatalf("unexpected error for unlimited database: %v", err)
+		}
+	}
+
+	stats := manager.ConcurrencyStats()
+	if len(stats) != 1 {
+		t.Fatalf("expected stats only for limited database, got %v", stats)
+	}
+	if st := stats["limited"]; st.Limit != 1 || st.InUse != 1 || st.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	release()
+	if _, err := manager.Acquire(ctx, "limited"); err != nil {
+		t.Errorf("expected slot after release, got %v", err)
+	}
+}
+
+// TestManager_Policy verifies statement policies are compiled per database
+func TestManager_Policy(t *testing.T) {
+	cfg := []config.DatabaseConfig{
+		{Name: "restricted", Type: "sqlite", Path: ":memory:", Policy: &config.StatementPolicyConfig{SelectOnly: true}},
+		{Name: "open", Type: "sqlite", Path: ":memory:"},
+	}
+
+	manager, err := NewManager(cfg)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	if err := manager.Policy("restricted").Check("DELETE FROM users"); !errors.Is(err, policy.ErrViolation) {
+		t.Errorf("expected ErrViolation, got %v", err)
+	}
+	if err := manager.Policy("open").Check("DELETE FROM users"); err != nil {
+		t.Errorf("unexpected error for unrestricted database: %v", err)
+	}
+}
+
+// TestNewManager_InvalidPolicy ensures manager rejects a policy that does not compile
+func TestNewManager_InvalidPolicy(t *testing.T) {
+	cfg := []config.DatabaseConfig{
+		{Name: "bad", Type: "sqlite", Path: ":memory:", Policy: &config.StatementPolicyConfig{AllowPatterns: []string{"("}}},
+	}
+	if _, err := NewManager(cfg); err == nil {
+		t.Error("expected error for invalid policy")
+	}
+}
+
+// TestManager_AddRemove verifies databases can be added and removed at runtime
+func TestManager_AddRemove(t *testing.T) {
+	manager, err := NewManager([]config.DatabaseConfig{{Name: "main", Type: "sqlite", Path: ":memory:"}})
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	added := config.DatabaseConfig{
+		Name: "extra", Type: "sqlite", Path: ":memory:", MaxConcurrent: 1,
+		Policy: &config.StatementPolicyConfig{SelectOnly: true},
+	}
+	if err := manager.Add(added); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := manager.Get("extra"); err != nil {
+		t.Errorf("Get after Add failed: %v", err)
+	}
+	if manager.Policy("extra") == nil {
+		t.Error("expected policy for added database")
+	}
+	if _, ok := manager.ConcurrencyStats()["extra"]; !ok {
+		t.Error("expected concurrency limit for added database")
+	}
+	if err := manager.Add(added); !errors.Is(err, ErrDatabaseExists) {
+		t.Errorf("expected ErrDatabaseExists, got %v", err)
+	}
+
+	if err := manager.Remove("extra"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := manager.Get("extra"); err == nil {
+		t.Error("expected Get to fail after Remove")
+	}
+	if manager.Policy("extra") != nil {
+		t.Error("expected policy to be removed")
+	}
+	if err := manager.Remove("extra"); err == nil {
+		t.Error("expected error removing unknown database")
+	}
+	if len(manager.Names()) != 1 {
+		t.Errorf("expected 1 connection, got %v", manager.Names())
+	}
+}
+
+// TestManager_AddConnectFailure ensures a database that can't be opened is not added
+func TestManager_AddConnectFailure(t *testing.T) {
+	manager, err := NewManager(nil)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	if err := manager.Add(config.DatabaseConfig{Name: "bad", Type: "sqlite", Path: "/nonexistent/dir/x.db"}); err == nil {
+		t.Fatal("expected connect error")
+	}
+	if len(manager.Names()) != 0 {
+		t.Errorf("expected no connections, got %v", manager.Names())
+	}
+}
@@ -0,0 +1,282 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/workflow/step"
+)
+
+// MockFixtures is a fixture file of a mock database: the responses to the
+// statements workflows run, and the columns of the tables crud endpoints use.
+type MockFixtures struct {
+	Responses []MockResponse          `yaml:"responses"`
+	Tables    map[string][]MockColumn `yaml:"tables"`
+}
+
+// MockResponse answers the statements matching it. The first matching response
+// in the file is used.
+type MockResponse struct {
+	Match  string         `yaml:"match"`  // Regular expression searched in the statement (case-insensitive, whitespace collapsed)
+	Proc   string         `yaml:"proc"`   // Stored procedure name, matched instead of a statement by proc steps
+	Params map[string]any `yaml:"params"` // Only statements with these parameter values (compared as text)
+
+	Rows         []map[string]any   `yaml:"rows"`          // Rows returned (the first result set)
+	ResultSets   [][]map[string]any `yaml:"result_sets"`   // Every result set, for multi-result statements and procedures
+	RowsAffected *int64             `yaml:"rows_affected"` // Default: the number of rows
+	Outputs      map[string]any     `yaml:"outputs"`       // Procedure output parameters
+	ReturnCode   *int64             `yaml:"return_code"`   // Procedure return status
+
+	Error      string `yaml:"error"`       // Fail with this message instead
+	ErrorClass string `yaml:"error_class"` // deadlock, constraint_violation or timeout: lets retries and on_error treat it as such
+
+	match *regexp.Regexp
+}
+
+// MockColumn describes a column of a mock table
+type MockColumn struct {
+	Name       string `yaml:"name"`
+	Type       string `yaml:"type"`
+	Nullable   bool   `yaml:"nullable"`
+	HasDefault bool   `yaml:"has_default"`
+	Generated  bool   `yaml:"generated"`
+}
+
+// mockErrorClasses are the error classes a fixture may give its error
+var mockErrorClasses = map[string]bool{
+	step.ErrorClassDeadlock:            true,
+	step.ErrorClassConstraintViolation: true,
+	step.ErrorClassTimeout:             true,
+}
+
+// LoadMockFixtures reads and checks a fixture file
+func LoadMockFixtures(path string) (*MockFixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	var f MockFixtures
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures %s: %w", path, err)
+	}
+	for i := range f.Responses {
+		resp := &f.Responses[i]
+		switch {
+		case resp.Match == "" && resp.Proc == "":
+			return nil, fmt.Errorf("fixtures %s: responses[%d]: match or proc is required", path, i)
+		case resp.Match != "" && resp.Proc != "":
+			return nil, fmt.Errorf("fixtures %s: responses[%d]: match and proc are mutually exclusive", path, i)
+		case resp.ErrorClass != "" && !mockErrorClasses[resp.ErrorClass]:
+			return nil, fmt.Errorf("fixtures %s: responses[%d]: invalid error_class '%s' (must be deadlock, constraint_violation, or timeout)", path, i, resp.ErrorClass)
+		case resp.ErrorClass != "" && resp.Error == "":
+			return nil, fmt.Errorf("fixtures %s: responses[%d]: error_class requires error", path, i)
+		}
+		if resp.Match != "" {
+			re, err := regexp.Compile("(?is)" + collapseSpace(resp.Match))
+			if err != nil {
+				return nil, fmt.Errorf("fixtures %s: responses[%d]: invalid match: %w", path, i, err)
+			}
+			resp.match = re
+		}
+	}
+	return &f, nil
+}
+
+// MockDriver implements Driver with responses from a fixture file, so workflows
+// can be validated and tested without a database server
+type MockDriver struct {
+	fixtures atomic.Pointer[MockFixtures] // Reread by Reconnect
+	cfg      config.DatabaseConfig
+	readOnly bool
+}
+
+// NewMockDriver creates a mock database answering from cfg.Fixtures
+func NewMockDriver(cfg config.DatabaseConfig) (*MockDriver, error) {
+	if cfg.Fixtures == "" {
+		return nil, fmt.Errorf("fixtures is required for mock")
+	}
+	f, err := LoadMockFixtures(cfg.Fixtures)
+	if err != nil {
+		return nil, err
+	}
+	d := &MockDriver{cfg: cfg, readOnly: cfg.IsReadOnly()}
+	d.fixtures.Store(f)
+	return d, nil
+}
+
+// Name returns the connection name
+func (d *MockDriver) Name() string {
+	return d.cfg.Name
+}
+
+// Type returns the database type
+func (d *MockDriver) Type() string {
+	return "mock"
+}
+
+// IsReadOnly returns whether this connection is read-only
+func (d *MockDriver) IsReadOnly() bool {
+	return d.readOnly
+}
+
+// Config returns the database configuration
+func (d *MockDriver) Config() config.DatabaseConfig {
+	return d.cfg
+}
+
+// Reconnect rereads the fixture file. A file that fails to load leaves the
+// previous fixtures in place.
+func (d *MockDriver) Reconnect() error {
+	f, err := LoadMockFixtures(d.cfg.Fixtures)
+	if err != nil {
+		return err
+	}
+	d.fixtures.Store(f)
+	return nil
+}
+
+func (d *MockDriver) Close() error {
+	return nil
+}
+
+// Query answers with the first response matching the statement and parameters.
+// Session settings, warmup statements and outbox inserts do not apply.
+func (d *MockDriver) Query(ctx context.Context, sessCfg config.SessionConfig, query string, params map[string]any, hints *QueryHints) (*QueryResult, error) {
+	isWrite := resolveIsWrite(hints, query)
+	if isWrite && d.readOnly {
+		return nil, fmt.Errorf("query failed: mock database %s is read-only", d.cfg.Name)
+	}
+	stmt := collapseSpace(query)
+	for _, resp := range d.fixtures.Load().Responses {
+		if resp.match != nil && resp.match.MatchString(stmt) && paramsMatch(resp.Params, params) {
+			qr, err := resp.result(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("query failed: %w", err)
+			}
+			if isWrite && resp.RowsAffected == nil {
+				qr.RowsAffected = int64(len(qr.Rows))
+			}
+			return qr, nil
+		}
+	}
+	return nil, fmt.Errorf("query failed: no fixture of mock database %s matches: %s", d.cfg.Name, stmt)
+}
+
+// CallProc answers with the first response naming the procedure and matching
+// its input parameters
+func (d *MockDriver) CallProc(ctx context.Context, sessCfg config.SessionConfig, call ProcCall) (*QueryResult, error) {
+	params := make(map[string]any, len(call.Params))
+	for _, p := range call.Params {
+		params[p.Name] = p.Value
+	}
+	for _, resp := range d.fixtures.Load().Responses {
+		if resp.Proc != "" && strings.EqualFold(resp.Proc, call.Name) && paramsMatch(resp.Params, params) {
+			qr, err := resp.result(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("procedure call failed: %w", err)
+			}
+			return qr, nil
+		}
+	}
+	return nil, fmt.Errorf("procedure call failed: no fixture of mock database %s matches procedure %s", d.cfg.Name, call.Name)
+}
+
+// TableColumns returns the columns of a table listed in the fixtures
+func (d *MockDriver) TableColumns(ctx context.Context, table string) ([]Column, error) {
+	cols, ok := d.fixtures.Load().Tables[table]
+	if !ok || len(cols) == 0 {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	columns := make([]Column, len(cols))
+	for i, c := range cols {
+		columns[i] = Column(c)
+	}
+	return columns, nil
+}
+
+// Ping always succeeds: the fixtures were loaded when the driver was created
+func (d *MockDriver) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (d *MockDriver) PoolStats() PoolStats {
+	return PoolStats{}
+}
+
+// result builds the response's result, or its error, applying the ResultLimit
+// carried by ctx like a scan of database rows would
+func (resp MockResponse) result(ctx context.Context) (*QueryResult, error) {
+	if resp.Error != "" {
+		err := errors.New(resp.Error)
+		if resp.ErrorClass != "" {
+			return nil, &step.ClassifiedError{Class: resp.ErrorClass, Err: err}
+		}
+		return nil, err
+	}
+
+	sets := resp.ResultSets
+	if len(sets) == 0 && resp.Rows != nil {
+		sets = [][]map[string]any{resp.Rows}
+	}
+	scanner := newRowScanner(ctx)
+	qr := &QueryResult{Outputs: maps.Clone(resp.Outputs), ReturnCode: resp.ReturnCode}
+	for _, set := range sets {
+		if scanner.truncated {
+			break
+		}
+		qr.ResultSets = append(qr.ResultSets, scanner.take(set))
+	}
+	if len(qr.ResultSets) > 0 {
+		qr.Rows = qr.ResultSets[0]
+	}
+	if resp.RowsAffected != nil {
+		qr.RowsAffected = *resp.RowsAffected
+	}
+	qr.Truncated = scanner.truncated
+	return qr, nil
+}
+
+// take returns copies of the rows within the limit, like scan does for database rows
+func (s *rowScanner) take(rows []map[string]any) []map[string]any {
+	results := []map[string]any{}
+	for _, row := range rows {
+		if s.limit != nil && s.skipped < s.limit.Offset {
+			s.skipped++
+			continue
+		}
+		row = maps.Clone(row)
+		if s.limit != nil && !s.keep(row) {
+			s.truncated = true
+			break
+		}
+		results = append(results, row)
+	}
+	return results
+}
+
+// paramsMatch reports whether params has every value of want, compared as text
+// so fixture numbers match parameters of any numeric type
+func paramsMatch(want, params map[string]any) bool {
+	for name, v := range want {
+		got, ok := params[name]
+		if !ok || fmt.Sprint(got) != fmt.Sprint(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// whitespace matches runs of whitespace, collapsed to one space in statements
+var whitespace = regexp.MustCompile(`\s+`)
+
+func collapseSpace(s string) string {
+	return strings.TrimSpace(whitespace.ReplaceAllString(s, " "))
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sql-proxy/internal/config"
+)
+
+const testFixtures = `
+responses:
+  - match: "SELECT \\* FROM users WHERE id = @id"
+    params: {id: 1}
+    rows:
+      - {id: 1, name: "alice"}
+  - match: "select \\* from users where id"
+    rows: []
+  - match: "^SELECT \\* FROM users$"
+    rows:
+      - {id: 1, name: "alice"}
+      - {id: 2, name: "bob"}
+      - {id: 3, name: "carol"}
+  - match: "^UPDATE users"
+    rows_affected: 2
+  - match: "^INSERT INTO orders"
+    error: "duplicate key"
+    error_class: constraint_violation
+  - proc: "dbo.GetTotals"
+    params: {year: 2024}
+    result_sets:
+      - [{total: 10}]
+      - [{month: 1}, {month: 2}]
+    outputs: {count: 2}
+    return_code: 0
+tables:
+  users:
+    - {name: id, type: int, generated: true}
+    - {name: name, type: varchar, nullable: true}
+`
+
+// newTestMockDriver writes fixtures to a file and opens a read-write mock database on it
+func newTestMockDriver(t *testing.T, fixtures string) *MockDriver {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixtures.yaml")
+	if err := os.WriteFile(path, []byte(fixtures), 0644); err != nil {
+		t.Fatal(err)
+	}
+	readOnly := false
+	d, err := NewMockDriver(config.DatabaseConfig{Name: "mock", Type: "mock", Fixtures: path, ReadOnly: &readOnly})
+	if err != nil {
+		t.Fatalf("NewMockDriver: %v", err)
+	}
+	return d
+}
+
+// TestMockDriver_Query verifies statements get the first response matching them and their parameters
+func TestMockDriver_Query(t *testing.T) {
+	d := newTestMockDriver(t, testFixtures)
+	ctx := context.Background()
+
+	qr, err := d.Query(ctx, config.SessionConfig{}, "SELECT *\n  FROM users WHERE id = @id", map[string]any{"id": int64(1)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(qr.Rows) != 1 || qr.Rows[0]["name"] != "alice" {
+		t.Errorf("rows = %v", qr.Rows)
+	}
+
+	// Another id falls through to the response without params
+	qr, err = d.Query(ctx, config.SessionConfig{}, "SELECT * FROM users WHERE id = @id", map[string]any{"id": 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(qr.Rows) != 0 {
+		t.Errorf("rows = %v, want none", qr.Rows)
+	}
+
+	qr, err = d.Query(ctx, config.SessionConfig{}, "UPDATE users SET name = @name", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qr.RowsAffected != 2 {
+		t.Errorf("RowsAffected = %d, want 2", qr.RowsAffected)
+	}
+
+	_, err = d.Query(ctx, config.SessionConfig{}, "DELETE FROM users", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "no fixture of mock database mock matches: DELETE FROM users") {
+		t.Errorf("unmatched statement error = %v", err)
+	}
+
+	_, err = d.Query(ctx, config.SessionConfig{}, "INSERT INTO orders (id) VALUES (1)", nil, nil)
+	if err == nil || ErrorClass(err) != "constraint_violation" {
+		t.Errorf("error = %v, class %q; want constraint_violation", err, ErrorClass(err))
+	}
+}
+
+// TestMockDriver_Query_ResultLimit verifies the result limit applies to fixture rows
+func TestMockDriver_Query_ResultLimit(t *testing.T) {
+	d := newTestMockDriver(t, testFixtures)
+	ctx := WithResultLimit(context.Background(), &ResultLimit{MaxRows: 1, Offset: 1})
+
+	qr, err := d.Query(ctx, config.SessionConfig{}, "SELECT * FROM users", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(qr.Rows) != 1 || qr.Rows[0]["name"] != "bob" || !qr.Truncated {
+		t.Errorf("rows = %v, truncated %v; want bob and truncated", qr.Rows, qr.Truncated)
+	}
+}
+
+// TestMockDriver_ReadOnly verifies a read-only mock database refuses writes
+func TestMockDriver_ReadOnly(t *testing.T) {
+	d := newTestMockDriver(t, testFixtures)
+	d.readOnly = true
+	_, err := d.Query(context.Background(), config.SessionConfig{}, "UPDATE users SET name = 'x'", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("error = %v, want read-only", err)
+	}
+}
+
+// TestMockDriver_CallProc verifies procedure calls get result sets, outputs and return code by procedure name
+func TestMockDriver_CallProc(t *testing.T) {
+	d := newTestMockDriver(t, testFixtures)
+	call := ProcCall{Name: "dbo.gettotals", Params: []ProcParam{{Name: "year", Direction: "in", Value: 2024}}}
+	qr, err := d.CallProc(context.Background(), config.SessionConfig{}, call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(qr.ResultSets) != 2 || len(qr.ResultSets[1]) != 2 || qr.Rows[0]["total"] != 10 {
+		t.Errorf("result sets = %v", qr.ResultSets)
+	}
+	if qr.Outputs["count"] != 2 || qr.ReturnCode == nil || *qr.ReturnCode != 0 {
+		t.Errorf("outputs = %v, return code %v", qr.Outputs, qr.ReturnCode)
+	}
+
+	call.Params[0].Value = 2023
+	if _, err := d.CallProc(context.Background(), config.SessionConfig{}, call); err == nil {
+		t.Error("expected no fixture for another year")
+	}
+}
+
+// TestMockDriver_TableColumns verifies table columns come from the fixtures
+func TestMockDriver_TableColumns(t *testing.T) {
+	d := newTestMockDriver(t, testFixtures)
+	cols, err := d.TableColumns(context.Background(), "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 2 || !cols[0].Generated || cols[1].Name != "name" || !cols[1].Nullable {
+		t.Errorf("columns = %+v", cols)
+	}
+	if _, err := d.TableColumns(context.Background(), "orders"); err == nil {
+		t.Error("expected error for a table without columns")
+	}
+}
+
+// TestMockDriver_Reconnect verifies Reconnect rereads the fixtures and keeps them when the file is invalid
+func TestMockDriver_Reconnect(t *testing.T) {
+	d := newTestMockDriver(t, testFixtures)
+	if err := os.WriteFile(d.cfg.Fixtures, []byte("responses:\n  - match: \"^DELETE\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Query(context.Background(), config.SessionConfig{}, "DELETE FROM users", nil, nil); err != nil {
+		t.Errorf("reloaded fixture not used: %v", err)
+	}
+
+	if err := os.WriteFile(d.cfg.Fixtures, []byte("responses:\n  - match: \"(\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Reconnect(); err == nil {
+		t.Error("expected error for an invalid fixture file")
+	}
+	if _, err := d.Query(context.Background(), config.SessionConfig{}, "DELETE FROM users", nil, nil); err != nil {
+		t.Errorf("previous fixtures not kept: %v", err)
+	}
+}
+
+// TestLoadMockFixtures_Invalid verifies fixture files are checked when loaded
+func TestLoadMockFixtures_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixtures string
+		wantErr  string
+	}{
+		{"missing match", "responses:\n  - rows: []\n", "match or proc is required"},
+		{"match and proc", "responses:\n  - match: a\n    proc: b\n", "mutually exclusive"},
+		{"invalid regexp", "responses:\n  - match: \"(\"\n", "invalid match"},
+		{"invalid error class", "responses:\n  - match: a\n    error: x\n    error_class: other\n", "invalid error_class"},
+		{"error class without error", "responses:\n  - match: a\n    error_class: timeout\n", "error_class requires error"},
+		{"not yaml", "responses: [", "failed to parse fixtures"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fixtures.yaml")
+			if err := os.WriteFile(path, []byte(tt.fixtures), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadMockFixtures(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewMockDriver(config.DatabaseConfig{Name: "mock", Type: "mock"}); err == nil {
+		t.Error("expected error without fixtures")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -36,7 +37,7 @@ const (
 
 // MySQLDriver implements Driver for MySQL
 type MySQLDriver struct {
-	conn     *sql.DB
+	conn     atomic.Pointer[sql.DB] // Swapped by Reconnect while queries run
 	dsn      string
 	cfg      config.DatabaseConfig
 	readOnly bool
@@ -87,7 +88,7 @@ func NewMySQLDriver(cfg config.DatabaseConfig) (*MySQLDriver, error) {
 
 	dsn := buildMySQLDSN(cfg)
 
-	conn, err := sql.Open("mysql", dsn)
+	conn, err := openPool("mysql", dsn, cfg.Warmup)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open mysql database: %w", err)
 	}
@@ -99,12 +100,14 @@ func NewMySQLDriver(cfg config.DatabaseConfig) (*MySQLDriver, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), mysqlPingTimeout)
 	defer cancel()
 
-	if err := conn.PingContext(ctx); err != nil {
+	if err := probe(ctx, conn, cfg.ProbeQuery); err != nil {
 		_ = conn.Close()
 		return nil, fmt.Errorf("failed to ping mysql database: %w", err)
 	}
 
-	return &MySQLDriver{conn: conn, dsn: dsn, cfg: cfg, readOnly: readOnly}, nil
+	d := &MySQLDriver{dsn: dsn, cfg: cfg, readOnly: readOnly}
+	d.conn.Store(conn)
+	return d, nil
 }
 
 func configureMySQLPool(conn *sql.DB, cfg config.DatabaseConfig) {
@@ -153,12 +156,9 @@ func (d *MySQLDriver) Config() config.DatabaseConfig {
 
 // Reconnect attempts to re-establish the database connection
 func (d *MySQLDriver) Reconnect() error {
-	if d.conn != nil {
-		_ = d.conn.Close()
-		d.conn = nil
-	}
-
-	conn, err := sql.Open("mysql", d.dsn)
+	// The old pool stays in place until the new one works, so a failed
+	// reconnect leaves the driver usable (and retryable)
+	conn, err := openPool("mysql", d.dsn, d.cfg.Warmup)
 	if err != nil {
 		return fmt.Errorf("failed to open mysql database: %w", err)
 	}
@@ -168,17 +168,20 @@ func (d *MySQLDriver) Reconnect() error {
 	ctx, cancel := context.WithTimeout(context.Background(), mysqlPingTimeout)
 	defer cancel()
 
-	if err := conn.PingContext(ctx); err != nil {
+	if err := probe(ctx, conn, d.cfg.ProbeQuery); err != nil {
 		_ = conn.Close()
 		return fmt.Errorf("failed to ping mysql database: %w", err)
 	}
 
-	d.conn = conn
+	old := d.conn.Swap(conn)
+	if old != nil {
+		_ = old.Close()
+	}
 	return nil
 }
 
 func (d *MySQLDriver) Close() error {
-	return d.conn.Close()
+	return d.conn.Load().Close()
 }
 
 // configureSession sets MySQL session options based on the provided session config.
@@ -231,11 +234,11 @@ func mysqlIsolationToSQL(isolation string) string {
 // SQL uses @param syntax which is translated to ? positional placeholders for MySQL.
 // params is a map of parameter name -> value.
 // hints carries precomputed SQL classification; falls back to parsing if nil.
-// For SELECT queries, returns rows in QueryResult.Rows.
+// For SELECT queries, returns rows in QueryResult.Rows and every result set in QueryResult.ResultSets.
 // For INSERT/UPDATE/DELETE, returns affected count in QueryResult.RowsAffected.
 func (d *MySQLDriver) Query(ctx context.Context, sessCfg config.SessionConfig, query string, params map[string]any, hints *QueryHints) (*QueryResult, error) {
 	// Get a dedicated connection from the pool
-	conn, err := d.conn.Conn(ctx)
+	conn, err := d.conn.Load().Conn(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get connection: %w", err)
 	}
@@ -253,32 +256,45 @@ func (d *MySQLDriver) Query(ctx context.Context, sessCfg config.SessionConfig, q
 	isWrite := resolveIsWrite(hints, query)
 	hasReturning := resolveHasReturning(hints, query)
 
-	// Route: writes without RETURNING use ExecContext (for RowsAffected),
-	// everything else uses QueryContext (SELECTs and writes with RETURNING).
-	if isWrite && !hasReturning {
-		result, err := conn.ExecContext(ctx, translatedQuery, args...)
-		if err != nil {
-			return nil, fmt.Errorf("exec failed: %w", err)
+	// Run the statement, in a transaction with any outbox inserts
+	return runStatement(ctx, conn, d.translateQuery, func(q execer) (*QueryResult, error) {
+		// Route: writes without RETURNING use ExecContext (for RowsAffected),
+		// everything else uses QueryContext (SELECTs and writes with RETURNING).
+		if isWrite && !hasReturning {
+			result, err := q.ExecContext(ctx, translatedQuery, args...)
+			if err != nil {
+				return nil, fmt.Errorf("exec failed: %w", err)
+			}
+			rowsAffected, _ := result.RowsAffected()
+			return &QueryResult{RowsAffected: rowsAffected}, nil
 		}
-		rowsAffected, _ := result.RowsAffected()
-		return &QueryResult{RowsAffected: rowsAffected}, nil
-	}
 
-	rows, err := conn.QueryContext(ctx, translatedQuery, args...)
-	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
-	}
-	defer func() { _ = rows.Close() }()
+		rows, err := q.QueryContext(ctx, translatedQuery, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query failed: %w", err)
+		}
+		defer func() { _ = rows.Close() }()
 
-	scannedRows, err := ScanRows(rows)
-	if err != nil {
-		return nil, err
-	}
-	qr := &QueryResult{Rows: scannedRows}
-	if isWrite {
-		qr.RowsAffected = int64(len(scannedRows))
-	}
-	return qr, nil
+		scanner := newRowScanner(ctx)
+		sets, err := scanner.scanSets(rows)
+		if err != nil {
+			return nil, err
+		}
+		qr := &QueryResult{ResultSets: sets, Truncated: scanner.truncated}
+		if len(sets) > 0 {
+			qr.Rows = sets[0]
+		}
+		if isWrite {
+			qr.RowsAffected = int64(len(qr.Rows))
+			// A limit reached in the first result set leaves rows of it unread
+			if scanner.truncated && len(sets) == 1 {
+				if qr.RowsAffected, err = scanner.written(rows); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return qr, nil
+	})
 }
 
 // translateQuery converts @param syntax to ? positional placeholders for MySQL.
@@ -317,15 +333,165 @@ func (d *MySQLDriver) translateQuery(query string, params map[string]any) (strin
 	return translated.String(), args
 }
 
+// CallProc calls a stored procedure with CALL, binding parameters positionally in
+// declaration order. Out and inout parameters pass through session variables that are
+// read back after the call. MySQL procedures have no return status.
+func (d *MySQLDriver) CallProc(ctx context.Context, sessCfg config.SessionConfig, call ProcCall) (*QueryResult, error) {
+	stmts, err := buildMySQLProcCall(call)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := d.conn.Load().Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err := d.configureSession(ctx, conn, sessCfg); err != nil {
+		return nil, fmt.Errorf("failed to configure session: %w", err)
+	}
+
+	if stmts.set != "" {
+		if _, err := conn.ExecContext(ctx, stmts.set, stmts.setArgs...); err != nil {
+			return nil, fmt.Errorf("failed to set inout parameters: %w", err)
+		}
+	}
+
+	rows, err := conn.QueryContext(ctx, stmts.call, stmts.callArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("procedure call failed: %w", err)
+	}
+	scanner := newRowScanner(ctx)
+	sets, err := scanner.scanSets(rows)
+	_ = rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	qr := &QueryResult{ResultSets: sets, Outputs: map[string]any{}, Truncated: scanner.truncated}
+	if len(sets) > 0 {
+		qr.Rows = sets[0]
+	}
+	if stmts.selectOuts == "" {
+		return qr, nil
+	}
+
+	outRows, err := conn.QueryContext(ctx, stmts.selectOuts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output parameters: %w", err)
+	}
+	defer func() { _ = outRows.Close() }()
+	scanned, err := ScanRows(outRows)
+	if err != nil {
+		return nil, err
+	}
+	if len(scanned) == 1 {
+		// Session variables come back untyped, so convert to the declared types
+		for _, p := range call.Params {
+			if p.Direction != "out" && p.Direction != "inout" {
+				continue
+			}
+			val, err := procValue(p.Type, scanned[0][p.Name])
+			if err != nil {
+				return nil, fmt.Errorf("output parameter %s: %w", p.Name, err)
+			}
+			qr.Outputs[p.Name] = val
+		}
+	}
+	return qr, nil
+}
+
+// mysqlProcStatements are the statements that make up a MySQL procedure call.
+type mysqlProcStatements struct {
+	set        string // Initializes inout session variables (empty when there are none)
+	setArgs    []any
+	call       string
+	callArgs   []any
+	selectOuts string // Reads out and inout session variables (empty when there are none)
+}
+
+// buildMySQLProcCall builds the CALL statement for a procedure, with the statements
+// that initialize and read the session variables standing in for out and inout parameters.
+func buildMySQLProcCall(call ProcCall) (*mysqlProcStatements, error) {
+	stmts := &mysqlProcStatements{}
+	var placeholders, sets, selects []string
+	for _, p := range call.Params {
+		val := p.Value
+		if p.Direction != "out" && p.Type != "" {
+			v, err := procValue(p.Type, p.Value)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s: %w", p.Name, err)
+			}
+			val = v
+		}
+
+		if p.Direction != "out" && p.Direction != "inout" {
+			placeholders = append(placeholders, "?")
+			stmts.callArgs = append(stmts.callArgs, val)
+			continue
+		}
+
+		variable := "@sqlproxy_out_" + p.Name
+		placeholders = append(placeholders, variable)
+		selects = append(selects, fmt.Sprintf("%s AS `%s`", variable, p.Name))
+		if p.Direction == "inout" {
+			sets = append(sets, variable+" = ?")
+			stmts.setArgs = append(stmts.setArgs, val)
+		}
+	}
+
+	stmts.call = fmt.Sprintf("CALL %s(%s)", call.Name, strings.Join(placeholders, ", "))
+	if len(sets) > 0 {
+		stmts.set = "SET " + strings.Join(sets, ", ")
+	}
+	if len(selects) > 0 {
+		stmts.selectOuts = "SELECT " + strings.Join(selects, ", ")
+	}
+	return stmts, nil
+}
+
+// mysqlColumnsQuery reads a table's columns from information_schema. The schema
+// defaults to the connection's database.
+const mysqlColumnsQuery = `SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE = 'YES',
+	COLUMN_DEFAULT IS NOT NULL OR EXTRA LIKE '%DEFAULT_GENERATED%',
+	EXTRA LIKE '%auto_increment%' OR EXTRA LIKE '%VIRTUAL GENERATED%' OR EXTRA LIKE '%STORED GENERATED%'
+FROM information_schema.COLUMNS
+WHERE TABLE_SCHEMA = COALESCE(?, DATABASE()) AND TABLE_NAME = ?
+ORDER BY ORDINAL_POSITION`
+
+// TableColumns lists a table's columns in declaration order. table may be
+// qualified with a database name.
+func (d *MySQLDriver) TableColumns(ctx context.Context, table string) ([]Column, error) {
+	var schema any
+	name := table
+	if before, after, ok := strings.Cut(table, "."); ok {
+		schema, name = before, after
+	}
+	rows, err := d.conn.Load().QueryContext(ctx, mysqlColumnsQuery, schema, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	return scanColumns(rows, table)
+}
+
 // Ping checks database connectivity
 func (d *MySQLDriver) Ping(ctx context.Context) error {
-	return d.conn.PingContext(ctx)
+	return probe(ctx, d.conn.Load(), d.cfg.ProbeQuery)
 }
 
 func (d *MySQLDriver) PoolStats() PoolStats {
-	if d.conn == nil {
+	conn := d.conn.Load()
+	if conn == nil {
 		return PoolStats{}
 	}
-	s := d.conn.Stats()
-	return PoolStats{OpenConnections: s.OpenConnections, IdleConnections: s.Idle}
+	s := conn.Stats()
+	return PoolStats{
+		OpenConnections:    s.OpenConnections,
+		IdleConnections:    s.Idle,
+		InUse:              s.InUse,
+		MaxOpenConnections: s.MaxOpenConnections,
+		WaitCount:          s.WaitCount,
+		WaitDuration:       s.WaitDuration,
+	}
 }
@@ -864,3 +864,43 @@ func insertMySQLTestData(t *testing.T, driver *MySQLDriver) {
 		}
 	}
 }
+
+// TestBuildMySQLProcCall verifies CALL statement construction with session variables for out parameters
+func TestBuildMySQLProcCall(t *testing.T) {
+	stmts, err := buildMySQLProcCall(ProcCall{Name: "shop.place_order", Params: []ProcParam{
+		{Name: "customer_id", Direction: "in", Value: float64(42), Type: "int"},
+		{Name: "order_id", Direction: "out", Type: "int"},
+		{Name: "attempts", Direction: "inout", Type: "int", Value: float64(1)},
+		{Name: "note", Direction: "in", Value: "rush"},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "CALL shop.place_order(?, @sqlproxy_out_order_id, @sqlproxy_out_attempts, ?)"; stmts.call != want {
+		t.Errorf("call = %q, want %q", stmts.call, want)
+	}
+	if len(stmts.callArgs) != 2 || stmts.callArgs[0] != int64(42) || stmts.callArgs[1] != "rush" {
+		t.Errorf("callArgs = %v, want [42 rush]", stmts.callArgs)
+	}
+	if want := "SET @sqlproxy_out_attempts = ?"; stmts.set != want {
+		t.Errorf("set = %q, want %q", stmts.set, want)
+	}
+	if len(stmts.setArgs) != 1 || stmts.setArgs[0] != int64(1) {
+		t.Errorf("setArgs = %v, want [1]", stmts.setArgs)
+	}
+	if want := "SELECT @sqlproxy_out_order_id AS `order_id`, @sqlproxy_out_attempts AS `attempts`"; stmts.selectOuts != want {
+		t.Errorf("selectOuts = %q, want %q", stmts.selectOuts, want)
+	}
+}
+
+// TestBuildMySQLProcCall_InOnly verifies no session variable statements without out parameters
+func TestBuildMySQLProcCall_InOnly(t *testing.T) {
+	stmts, err := buildMySQLProcCall(ProcCall{Name: "refresh_stats"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stmts.call != "CALL refresh_stats()" || stmts.set != "" || stmts.selectOuts != "" {
+		t.Errorf("stmts = %+v, want a bare CALL", stmts)
+	}
+}
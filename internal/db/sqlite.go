@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -42,7 +43,7 @@ const (
 
 // SQLiteDriver implements Driver for SQLite
 type SQLiteDriver struct {
-	conn     *sql.DB
+	conn     atomic.Pointer[sql.DB] // Swapped by Reconnect while queries run
 	path     string
 	cfg      config.DatabaseConfig
 	readOnly bool
@@ -88,7 +89,7 @@ func NewSQLiteDriver(cfg config.DatabaseConfig) (*SQLiteDriver, error) {
 		return nil, err
 	}
 
-	conn, err := sql.Open("sqlite", dsn)
+	conn, err := openPool("sqlite", dsn, cfg.Warmup)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
 	}
@@ -98,11 +99,11 @@ func NewSQLiteDriver(cfg config.DatabaseConfig) (*SQLiteDriver, error) {
 
 	// Apply initial PRAGMA settings
 	driver := &SQLiteDriver{
-		conn:     conn,
 		path:     cfg.Path,
 		cfg:      cfg,
 		readOnly: readOnly,
 	}
+	driver.conn.Store(conn)
 
 	if err := driver.applyInitialPragmas(); err != nil {
 		_ = conn.Close()
@@ -112,7 +113,7 @@ func NewSQLiteDriver(cfg config.DatabaseConfig) (*SQLiteDriver, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), sqlitePingTimeout)
 	defer cancel()
 
-	if err := conn.PingContext(ctx); err != nil {
+	if err := probe(ctx, conn, cfg.ProbeQuery); err != nil {
 		_ = conn.Close()
 		return nil, fmt.Errorf("failed to ping sqlite database: %w", err)
 	}
@@ -200,7 +201,7 @@ func (d *SQLiteDriver) applyInitialPragmas() error {
 
 	// Execute all pragmas
 	for _, pragma := range pragmas {
-		if _, err := d.conn.Exec(pragma); err != nil {
+		if _, err := d.conn.Load().Exec(pragma); err != nil {
 			return fmt.Errorf("failed to execute %s: %w", pragma, err)
 		}
 	}
@@ -230,17 +231,14 @@ func (d *SQLiteDriver) Config() config.DatabaseConfig {
 
 // Reconnect attempts to re-establish the database connection
 func (d *SQLiteDriver) Reconnect() error {
-	if d.conn != nil {
-		_ = d.conn.Close()
-		d.conn = nil
-	}
-
+	// The old pool stays in place until the new one works, so a failed
+	// reconnect leaves the driver usable (and retryable)
 	dsn, err := buildSQLiteDSN(d.path, d.readOnly)
 	if err != nil {
 		return err
 	}
 
-	conn, err := sql.Open("sqlite", dsn)
+	conn, err := openPool("sqlite", dsn, d.cfg.Warmup)
 	if err != nil {
 		return fmt.Errorf("failed to open sqlite database: %w", err)
 	}
@@ -248,7 +246,8 @@ func (d *SQLiteDriver) Reconnect() error {
 	configureSQLitePool(conn, d.cfg)
 
 	// Create a temporary driver to apply pragmas (using the new conn)
-	tempDriver := &SQLiteDriver{conn: conn, path: d.path, cfg: d.cfg, readOnly: d.readOnly}
+	tempDriver := &SQLiteDriver{path: d.path, cfg: d.cfg, readOnly: d.readOnly}
+	tempDriver.conn.Store(conn)
 	if err := tempDriver.applyInitialPragmas(); err != nil {
 		_ = conn.Close()
 		return fmt.Errorf("failed to apply initial pragmas: %w", err)
@@ -258,18 +257,21 @@ func (d *SQLiteDriver) Reconnect() error {
 	ctx, cancel := context.WithTimeout(context.Background(), sqlitePingTimeout)
 	defer cancel()
 
-	if err := conn.PingContext(ctx); err != nil {
+	if err := probe(ctx, conn, d.cfg.ProbeQuery); err != nil {
 		_ = conn.Close()
 		return fmt.Errorf("failed to ping sqlite database: %w", err)
 	}
 
-	// Only assign to d.conn after all checks pass
-	d.conn = conn
+	// Only swap after all checks pass
+	old := d.conn.Swap(conn)
+	if old != nil {
+		_ = old.Close()
+	}
 	return nil
 }
 
 func (d *SQLiteDriver) Close() error {
-	return d.conn.Close()
+	return d.conn.Load().Close()
 }
 
 // configureSession sets SQLite session options via PRAGMA on the specific connection.
@@ -319,7 +321,7 @@ func (d *SQLiteDriver) configureSession(ctx context.Context, conn *sql.Conn, ses
 // For INSERT/UPDATE/DELETE, returns affected count in QueryResult.RowsAffected.
 func (d *SQLiteDriver) Query(ctx context.Context, sessCfg config.SessionConfig, query string, params map[string]any, hints *QueryHints) (*QueryResult, error) {
 	// Get a dedicated connection from the pool
-	conn, err := d.conn.Conn(ctx)
+	conn, err := d.conn.Load().Conn(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get connection: %w", err)
 	}
@@ -337,32 +339,38 @@ func (d *SQLiteDriver) Query(ctx context.Context, sessCfg config.SessionConfig,
 	isWrite := resolveIsWrite(hints, query)
 	hasReturning := resolveHasReturning(hints, query)
 
-	// Route: writes without RETURNING use ExecContext (for RowsAffected),
-	// everything else uses QueryContext (SELECTs and writes with RETURNING).
-	if isWrite && !hasReturning {
-		result, err := conn.ExecContext(ctx, translatedQuery, args...)
-		if err != nil {
-			return nil, fmt.Errorf("exec failed: %w", err)
+	// Run the statement, in a transaction with any outbox inserts
+	return runStatement(ctx, conn, d.translateQuery, func(q execer) (*QueryResult, error) {
+		// Route: writes without RETURNING use ExecContext (for RowsAffected),
+		// everything else uses QueryContext (SELECTs and writes with RETURNING).
+		if isWrite && !hasReturning {
+			result, err := q.ExecContext(ctx, translatedQuery, args...)
+			if err != nil {
+				return nil, fmt.Errorf("exec failed: %w", err)
+			}
+			rowsAffected, _ := result.RowsAffected()
+			return &QueryResult{RowsAffected: rowsAffected}, nil
 		}
-		rowsAffected, _ := result.RowsAffected()
-		return &QueryResult{RowsAffected: rowsAffected}, nil
-	}
 
-	rows, err := conn.QueryContext(ctx, translatedQuery, args...)
-	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
-	}
-	defer func() { _ = rows.Close() }()
+		rows, err := q.QueryContext(ctx, translatedQuery, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query failed: %w", err)
+		}
+		defer func() { _ = rows.Close() }()
 
-	scannedRows, err := ScanRows(rows)
-	if err != nil {
-		return nil, err
-	}
-	qr := &QueryResult{Rows: scannedRows}
-	if isWrite {
-		qr.RowsAffected = int64(len(scannedRows))
-	}
-	return qr, nil
+		scanner := newRowScanner(ctx)
+		scannedRows, err := scanner.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		qr := &QueryResult{Rows: scannedRows, Truncated: scanner.truncated}
+		if isWrite {
+			if qr.RowsAffected, err = scanner.written(rows); err != nil {
+				return nil, err
+			}
+		}
+		return qr, nil
+	})
 }
 
 // translateQuery keeps @param syntax for SQLite and builds args.
@@ -391,14 +399,83 @@ func (d *SQLiteDriver) translateQuery(query string, params map[string]any) (stri
 	return query, args
 }
 
+// CallProc always fails: SQLite has no stored procedures.
+func (d *SQLiteDriver) CallProc(ctx context.Context, sessCfg config.SessionConfig, call ProcCall) (*QueryResult, error) {
+	return nil, fmt.Errorf("stored procedures are not supported by sqlite")
+}
+
+// TableColumns lists a table's columns in declaration order. An INTEGER PRIMARY KEY
+// column aliases the rowid, so it is reported as generated.
+func (d *SQLiteDriver) TableColumns(ctx context.Context, table string) ([]Column, error) {
+	// PRAGMA arguments cannot be bound; table_xinfo takes the schema as a prefix
+	pragma := "PRAGMA table_xinfo(" + quoteSQLiteString(table) + ")"
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		pragma = "PRAGMA " + schema + ".table_xinfo(" + quoteSQLiteString(name) + ")"
+	}
+	rows, err := d.conn.Load().QueryContext(ctx, pragma)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var columns []Column
+	var pkColumns []int
+	for rows.Next() {
+		var (
+			cid, notNull, pk, hidden int
+			name, typ                string
+			dflt                     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk, &hidden); err != nil {
+			return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+		if hidden == 1 {
+			continue // Virtual table hidden column
+		}
+		if pk > 0 {
+			pkColumns = append(pkColumns, len(columns))
+		}
+		columns = append(columns, Column{
+			Name:       name,
+			Type:       typ,
+			Nullable:   notNull == 0 && pk == 0,
+			HasDefault: dflt.Valid,
+			Generated:  hidden == 2 || hidden == 3, // Generated virtual or stored
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	if len(pkColumns) == 1 && strings.EqualFold(columns[pkColumns[0]].Type, "INTEGER") {
+		columns[pkColumns[0]].Generated = true
+	}
+	return columns, nil
+}
+
+// quoteSQLiteString quotes s as an SQLite string literal.
+func quoteSQLiteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
 func (d *SQLiteDriver) Ping(ctx context.Context) error {
-	return d.conn.PingContext(ctx)
+	return probe(ctx, d.conn.Load(), d.cfg.ProbeQuery)
 }
 
 func (d *SQLiteDriver) PoolStats() PoolStats {
-	if d.conn == nil {
+	conn := d.conn.Load()
+	if conn == nil {
 		return PoolStats{}
 	}
-	s := d.conn.Stats()
-	return PoolStats{OpenConnections: s.OpenConnections, IdleConnections: s.Idle}
+	s := conn.Stats()
+	return PoolStats{
+		OpenConnections:    s.OpenConnections,
+		IdleConnections:    s.Idle,
+		InUse:              s.InUse,
+		MaxOpenConnections: s.MaxOpenConnections,
+		WaitCount:          s.WaitCount,
+		WaitDuration:       s.WaitDuration,
+	}
 }
@@ -2,6 +2,8 @@ package db
 
 import (
 	"context"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
@@ -375,6 +377,122 @@ func TestSQLiteDriver_Query_LargeResult(t *testing.T) {
 	}
 }
 
+// TestSQLiteDriver_Query_ResultLimit verifies rows beyond a context's ResultLimit are not read
+func TestSQLiteDriver_Query_ResultLimit(t *testing.T) {
+	driver := createTestSQLiteDriver(t)
+	defer func() { _ = driver.Close() }()
+
+	// Ten rows of {"i":N}: 8 bytes each with a separator, 9 for i = 10
+	query := "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 10) SELECT i FROM n"
+
+	tests := []struct {
+		name      string
+		limit     *ResultLimit
+		wantFirst int64
+		wantRows  int
+		truncated bool
+	}{
+		{name: "no limit", wantFirst: 1, wantRows: 10},
+		{name: "max rows", limit: &ResultLimit{MaxRows: 3}, wantFirst: 1, wantRows: 3, truncated: true},
+		{name: "exactly max rows", limit: &ResultLimit{MaxRows: 10}, wantFirst: 1, wantRows: 10},
+		{name: "offset", limit: &ResultLimit{MaxRows: 3, Offset: 3}, wantFirst: 4, wantRows: 3, truncated: true},
+		{name: "offset last page", limit: &ResultLimit{MaxRows: 3, Offset: 8}, wantFirst: 9, wantRows: 2},
+		{name: "max bytes", limit: &ResultLimit{MaxBytes: 20}, wantFirst: 1, wantRows: 2, truncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.limit != nil {
+				ctx = WithResultLimit(ctx, tt.limit)
+			}
+			result, err := driver.Query(ctx, config.SessionConfig{}, query, nil, nil)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if len(result.Rows) != tt.wantRows {
+				t.Fatalf("got %d rows, want %d", len(result.Rows), tt.wantRows)
+			}
+			if first := result.Rows[0]["i"]; first != tt.wantFirst {
+				t.Errorf("first row i = %v, want %d", first, tt.wantFirst)
+			}
+			if result.Truncated != tt.truncated {
+				t.Errorf("Truncated = %v, want %v", result.Truncated, tt.truncated)
+			}
+		})
+	}
+}
+
+// TestSQLiteDriver_Query_ResultLimitReturning verifies a write with RETURNING reports every row it changed when a limit cuts its result
+func TestSQLiteDriver_Query_ResultLimitReturning(t *testing.T) {
+	driver := createTestSQLiteDriver(t)
+	defer func() { _ = driver.Close() }()
+	createTestTable(t, driver)
+
+	ctx := context.Background()
+	sessCfg := config.SessionConfig{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := driver.Query(ctx, sessCfg, "INSERT INTO test_users (name, email) VALUES (@name, 'x@example.com')", map[string]any{"name": name}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := driver.Query(WithResultLimit(ctx, &ResultLimit{MaxRows: 2}), sessCfg, "UPDATE test_users SET status = 'inactive' RETURNING id", nil, nil)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(result.Rows) != 2 || !result.Truncated || result.RowsAffected != 5 {
+		t.Errorf("rows = %d, truncated = %v, RowsAffected = %d; want 2 rows of 5 changed", len(result.Rows), result.Truncated, result.RowsAffected)
+	}
+}
+
+// TestSQLiteDriver_Query_Outbox verifies outbox events commit with the statement and roll back with it
+func TestSQLiteDriver_Query_Outbox(t *testing.T) {
+	driver := createTestSQLiteDriver(t)
+	defer func() { _ = driver.Close() }()
+	createTestTable(t, driver)
+
+	ctx := context.Background()
+	sessCfg := config.SessionConfig{}
+	if _, err := driver.Query(ctx, sessCfg, "CREATE TABLE outbox (id TEXT PRIMARY KEY, topic TEXT NOT NULL, event_key TEXT, payload TEXT NOT NULL, created_at TEXT NOT NULL)", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	count := func(table string) int64 {
+		t.Helper()
+		result, err := driver.Query(ctx, sessCfg, "SELECT COUNT(*) AS n FROM "+table, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Rows[0]["n"].(int64)
+	}
+
+	insert := "INSERT INTO test_users (name, email) VALUES (@name, 'a@example.com')"
+	events := []OutboxEvent{{ID: "e1", Topic: "user.created", Key: "alice", Payload: `{"name":"alice"}`}}
+	result, err := driver.Query(WithOutbox(ctx, "outbox", events), sessCfg, insert, map[string]any{"name": "alice"}, nil)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if result.RowsAffected != 1 || count("test_users") != 1 || count("outbox") != 1 {
+		t.Fatalf("RowsAffected = %d, users = %d, events = %d", result.RowsAffected, count("test_users"), count("outbox"))
+	}
+	row, _ := driver.Query(ctx, sessCfg, "SELECT topic, event_key, payload FROM outbox WHERE id = 'e1'", nil, nil)
+	if got := row.Rows[0]; got["topic"] != "user.created" || got["event_key"] != "alice" || got["payload"] != `{"name":"alice"}` {
+		t.Errorf("event = %v", got)
+	}
+
+	// A duplicate event id fails the insert, which rolls back the statement
+	if _, err := driver.Query(WithOutbox(ctx, "outbox", events), sessCfg, insert, map[string]any{"name": "bob"}, nil); err == nil || !strings.Contains(err.Error(), "outbox insert failed") {
+		t.Fatalf("expected outbox insert error, got %v", err)
+	}
+	// A failed statement records no events
+	if _, err := driver.Query(WithOutbox(ctx, "outbox", []OutboxEvent{{ID: "e2", Topic: "t"}}), sessCfg, insert, nil, nil); err == nil {
+		t.Fatal("expected NOT NULL error")
+	}
+	if count("test_users") != 1 || count("outbox") != 1 {
+		t.Errorf("users = %d, events = %d after rollbacks", count("test_users"), count("outbox"))
+	}
+}
+
 // TestSQLiteDriver_Query_Timeout verifies context deadline expiration stops query
 func TestSQLiteDriver_Query_Timeout(t *testing.T) {
 	driver := createTestSQLiteDriver(t)
@@ -470,6 +588,92 @@ func TestSQLiteDriver_Reconnect(t *testing.T) {
 	}
 }
 
+// TestSQLiteDriver_Warmup verifies warmup statements run on every new connection, after a reconnect too
+func TestSQLiteDriver_Warmup(t *testing.T) {
+	readOnly := false
+	cfg := config.DatabaseConfig{
+		Name:     "test",
+		Type:     "sqlite",
+		Path:     filepath.Join(t.TempDir(), "warm.db"),
+		ReadOnly: &readOnly,
+		Warmup: []string{
+			// Attached databases only exist on the connection that attached them
+			"ATTACH DATABASE ':memory:' AS warm",
+			"CREATE TABLE warm.runs (n INTEGER)",
+			"INSERT INTO warm.runs (n) VALUES (1)",
+		},
+	}
+	driver, err := NewSQLiteDriver(cfg)
+	if err != nil {
+		t.Fatalf("failed to create driver: %v", err)
+	}
+	defer func() { _ = driver.Close() }()
+
+	// Holds two connections at once, so the second is a new one
+	checkConns := func() {
+		t.Helper()
+		ctx := context.Background()
+		for i := range 2 {
+			conn, err := driver.conn.Load().Conn(ctx)
+			if err != nil {
+				t.Fatalf("conn %d: %v", i, err)
+			}
+			defer func() { _ = conn.Close() }()
+			var n int
+			if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM warm.runs").Scan(&n); err != nil || n != 1 {
+				t.Errorf("conn %d: expected one warmup row, got %d (%v)", i, n, err)
+			}
+		}
+	}
+	checkConns()
+	if err := driver.Reconnect(); err != nil {
+		t.Fatalf("reconnect failed: %v", err)
+	}
+	checkConns()
+
+	// A failing warmup fails the connection
+	cfg.Warmup = []string{"SELECT * FROM missing_table"}
+	if _, err := NewSQLiteDriver(cfg); err == nil || !strings.Contains(err.Error(), "warmup[0]") {
+		t.Errorf("expected warmup error, got %v", err)
+	}
+}
+
+// TestSQLiteDriver_ProbeQuery verifies health checks run the probe query instead of a bare ping
+func TestSQLiteDriver_ProbeQuery(t *testing.T) {
+	readOnly := false
+	cfg := config.DatabaseConfig{
+		Name:     "test",
+		Type:     "sqlite",
+		Path:     filepath.Join(t.TempDir(), "probe.db"),
+		ReadOnly: &readOnly,
+		Warmup:   []string{"CREATE TABLE IF NOT EXISTS heartbeat (id INTEGER)"},
+	}
+	setup, err := NewSQLiteDriver(cfg)
+	if err != nil {
+		t.Fatalf("failed to create heartbeat table: %v", err)
+	}
+	_ = setup.Close()
+
+	cfg.Warmup = nil
+	cfg.ProbeQuery = "SELECT id FROM heartbeat"
+	driver, err := NewSQLiteDriver(cfg)
+	if err != nil {
+		t.Fatalf("failed to create driver: %v", err)
+	}
+	defer func() { _ = driver.Close() }()
+
+	ctx := context.Background()
+	if err := driver.Ping(ctx); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+	if _, err := driver.Query(ctx, config.SessionConfig{}, "DROP TABLE heartbeat", nil, nil); err != nil {
+		t.Fatalf("drop failed: %v", err)
+	}
+	if err := driver.Ping(ctx); err == nil {
+		t.Error("expected ping to fail when the probe query fails")
+	}
+}
+
 // TestSQLiteDriver_Config verifies Config() returns original configuration
 func TestSQLiteDriver_Config(t *testing.T) {
 	cfg := config.DatabaseConfig{
@@ -730,3 +934,62 @@ func insertTestData(t *testing.T, driver *SQLiteDriver) {
 		}
 	}
 }
+
+// TestSQLiteDriver_CallProc confirms stored procedure calls are rejected
+func TestSQLiteDriver_CallProc(t *testing.T) {
+	driver, err := NewSQLiteDriver(config.DatabaseConfig{Name: "test", Type: "sqlite", Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("failed to create driver: %v", err)
+	}
+	defer func() { _ = driver.Close() }()
+
+	_, err = driver.CallProc(context.Background(), config.SessionConfig{}, ProcCall{Name: "p"})
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("expected not supported error, got %v", err)
+	}
+}
+
+// TestSQLiteDriver_TableColumns tests column introspection
+func TestSQLiteDriver_TableColumns(t *testing.T) {
+	driver, err := NewSQLiteDriver(config.DatabaseConfig{Name: "test", Type: "sqlite", Path: ":memory:", MaxOpenConns: intPtr(1)})
+	if err != nil {
+		t.Fatalf("failed to create driver: %v", err)
+	}
+	defer func() { _ = driver.Close() }()
+
+	ctx := context.Background()
+	_, err = driver.Query(ctx, config.SessionConfig{}, `
+		CREATE TABLE items (
+			id INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			price REAL,
+			status TEXT NOT NULL DEFAULT 'active',
+			total REAL GENERATED ALWAYS AS (price * 2) VIRTUAL
+		)
+	`, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	columns, err := driver.TableColumns(ctx, "items")
+	if err != nil {
+		t.Fatalf("TableColumns failed: %v", err)
+	}
+	want := []Column{
+		{Name: "id", Type: "INTEGER", Generated: true},
+		{Name: "name", Type: "TEXT"},
+		{Name: "price", Type: "REAL", Nullable: true},
+		{Name: "status", Type: "TEXT", HasDefault: true},
+		{Name: "total", Type: "REAL", Nullable: true, Generated: true},
+	}
+	if !reflect.DeepEqual(columns, want) {
+		t.Errorf("TableColumns() = %+v, want %+v", columns, want)
+	}
+
+	if qualified, err := driver.TableColumns(ctx, "main.items"); err != nil || len(qualified) != len(want) {
+		t.Errorf("TableColumns(main.items) = %v, %v", qualified, err)
+	}
+	if _, err := driver.TableColumns(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "table missing not found") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
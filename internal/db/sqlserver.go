@@ -3,10 +3,12 @@ package db
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/microsoft/go-mssqldb"
+	mssql "github.com/microsoft/go-mssqldb"
 	"sql-proxy/internal/config"
 )
 
@@ -32,7 +34,7 @@ const (
 
 // SQLServerDriver implements Driver for Microsoft SQL Server
 type SQLServerDriver struct {
-	conn     *sql.DB
+	conn     atomic.Pointer[sql.DB] // Swapped by Reconnect while queries run
 	connStr  string
 	cfg      config.DatabaseConfig
 	readOnly bool
@@ -64,7 +66,7 @@ func NewSQLServerDriver(cfg config.DatabaseConfig) (*SQLServerDriver, error) {
 		)
 	}
 
-	conn, err := sql.Open("sqlserver", connStr)
+	conn, err := openPool("sqlserver", connStr, cfg.Warmup)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
@@ -76,12 +78,14 @@ func NewSQLServerDriver(cfg config.DatabaseConfig) (*SQLServerDriver, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), sqlserverPingTimeout)
 	defer cancel()
 
-	if err := conn.PingContext(ctx); err != nil {
+	if err := probe(ctx, conn, cfg.ProbeQuery); err != nil {
 		_ = conn.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &SQLServerDriver{conn: conn, connStr: connStr, cfg: cfg, readOnly: readOnly}, nil
+	d := &SQLServerDriver{connStr: connStr, cfg: cfg, readOnly: readOnly}
+	d.conn.Store(conn)
+	return d, nil
 }
 
 func configureSQLServerPool(conn *sql.DB, cfg config.DatabaseConfig) {
@@ -130,12 +134,9 @@ func (d *SQLServerDriver) Config() config.DatabaseConfig {
 
 // Reconnect attempts to re-establish the database connection
 func (d *SQLServerDriver) Reconnect() error {
-	if d.conn != nil {
-		_ = d.conn.Close()
-		d.conn = nil
-	}
-
-	conn, err := sql.Open("sqlserver", d.connStr)
+	// The old pool stays in place until the new one works, so a failed
+	// reconnect leaves the driver usable (and retryable)
+	conn, err := openPool("sqlserver", d.connStr, d.cfg.Warmup)
 	if err != nil {
 		return fmt.Errorf("failed to open database connection: %w", err)
 	}
@@ -146,17 +147,20 @@ func (d *SQLServerDriver) Reconnect() error {
 	ctx, cancel := context.WithTimeout(context.Background(), sqlserverPingTimeout)
 	defer cancel()
 
-	if err := conn.PingContext(ctx); err != nil {
+	if err := probe(ctx, conn, d.cfg.ProbeQuery); err != nil {
 		_ = conn.Close()
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	d.conn = conn
+	old := d.conn.Swap(conn)
+	if old != nil {
+		_ = old.Close()
+	}
 	return nil
 }
 
 func (d *SQLServerDriver) Close() error {
-	return d.conn.Close()
+	return d.conn.Load().Close()
 }
 
 // configureSession sets SQL Server session options based on the provided session config.
@@ -213,11 +217,11 @@ func deadlockPriorityToSQL(priority string) string {
 // SQL uses @param syntax which is native to SQL Server.
 // params is a map of parameter name -> value.
 // hints carries precomputed SQL classification; falls back to parsing if nil.
-// For SELECT queries, returns rows in QueryResult.Rows.
+// For SELECT queries, returns rows in QueryResult.Rows and every result set in QueryResult.ResultSets.
 // For INSERT/UPDATE/DELETE, returns affected count in QueryResult.RowsAffected.
 func (d *SQLServerDriver) Query(ctx context.Context, sessCfg config.SessionConfig, query string, params map[string]any, hints *QueryHints) (*QueryResult, error) {
 	// Get a dedicated connection from the pool
-	conn, err := d.conn.Conn(ctx)
+	conn, err := d.conn.Load().Conn(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get connection: %w", err)
 	}
@@ -236,32 +240,50 @@ func (d *SQLServerDriver) Query(ctx context.Context, sessCfg config.SessionConfi
 	isWrite := resolveIsWrite(hints, query)
 	hasReturning := resolveHasReturning(hints, query)
 
-	// Route: writes without RETURNING use ExecContext (for RowsAffected),
-	// everything else uses QueryContext (SELECTs and writes with OUTPUT).
-	if isWrite && !hasReturning {
-		result, err := conn.ExecContext(ctx, query, args...)
+	// Run the statement, in a transaction with any outbox inserts
+	return runStatement(ctx, conn, d.bindArgs, func(q execer) (*QueryResult, error) {
+		// Route: writes without RETURNING use ExecContext (for RowsAffected),
+		// everything else uses QueryContext (SELECTs and writes with OUTPUT).
+		if isWrite && !hasReturning {
+			result, err := q.ExecContext(ctx, query, args...)
+			if err != nil {
+				return nil, fmt.Errorf("exec failed: %w", err)
+			}
+			rowsAffected, _ := result.RowsAffected()
+			return &QueryResult{RowsAffected: rowsAffected}, nil
+		}
+
+		rows, err := q.QueryContext(ctx, query, args...)
 		if err != nil {
-			return nil, fmt.Errorf("exec failed: %w", err)
+			return nil, fmt.Errorf("query failed: %w", err)
 		}
-		rowsAffected, _ := result.RowsAffected()
-		return &QueryResult{RowsAffected: rowsAffected}, nil
-	}
+		defer func() { _ = rows.Close() }()
 
-	rows, err := conn.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
-	}
-	defer func() { _ = rows.Close() }()
+		scanner := newRowScanner(ctx)
+		sets, err := scanner.scanSets(rows)
+		if err != nil {
+			return nil, err
+		}
+		qr := &QueryResult{ResultSets: sets, Truncated: scanner.truncated}
+		if len(sets) > 0 {
+			qr.Rows = sets[0]
+		}
+		if isWrite {
+			qr.RowsAffected = int64(len(qr.Rows))
+			// A limit reached in the first result set leaves rows of it unread
+			if scanner.truncated && len(sets) == 1 {
+				if qr.RowsAffected, err = scanner.written(rows); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return qr, nil
+	})
+}
 
-	scannedRows, err := ScanRows(rows)
-	if err != nil {
-		return nil, err
-	}
-	qr := &QueryResult{Rows: scannedRows}
-	if isWrite {
-		qr.RowsAffected = int64(len(scannedRows))
-	}
-	return qr, nil
+// bindArgs is buildArgs in binder form; the query needs no translation.
+func (d *SQLServerDriver) bindArgs(query string, params map[string]any) (string, []any) {
+	return query, d.buildArgs(query, params)
 }
 
 // buildArgs builds sql.Named arguments from the params map.
@@ -287,15 +309,136 @@ func (d *SQLServerDriver) buildArgs(query string, params map[string]any) []any {
 	return args
 }
 
+// CallProc calls a stored procedure over RPC, binding parameters by name.
+// Returns every result set, the out and inout parameter values, and the return status.
+func (d *SQLServerDriver) CallProc(ctx context.Context, sessCfg config.SessionConfig, call ProcCall) (*QueryResult, error) {
+	args, outs, err := sqlserverProcArgs(call)
+	if err != nil {
+		return nil, err
+	}
+	var status mssql.ReturnStatus
+	args = append(args, &status)
+
+	conn, err := d.conn.Load().Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err := d.configureSession(ctx, conn, sessCfg); err != nil {
+		return nil, fmt.Errorf("failed to configure session: %w", err)
+	}
+
+	// A bare procedure name makes the driver send an RPC call instead of a batch
+	rows, err := conn.QueryContext(ctx, call.Name, args...)
+	if err != nil {
+		return nil, fmt.Errorf("procedure call failed: %w", err)
+	}
+	scanner := newRowScanner(ctx)
+	sets, err := scanner.scanSets(rows)
+	_ = rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Output parameters and the return status arrive after the last result set
+	outputs := make(map[string]any, len(outs))
+	for name, dest := range outs {
+		val, err := dest.Value()
+		if err != nil {
+			return nil, fmt.Errorf("output parameter %s: %w", name, err)
+		}
+		outputs[name] = val
+	}
+	code := int64(status)
+	qr := &QueryResult{ResultSets: sets, Outputs: outputs, ReturnCode: &code, Truncated: scanner.truncated}
+	if len(sets) > 0 {
+		qr.Rows = sets[0]
+	}
+	return qr, nil
+}
+
+// sqlserverProcArgs builds named procedure arguments. Out and inout parameters bind
+// typed nullable destinations, returned by name so their values can be read after the call.
+func sqlserverProcArgs(call ProcCall) ([]any, map[string]driver.Valuer, error) {
+	args := make([]any, 0, len(call.Params)+1)
+	outs := make(map[string]driver.Valuer)
+	for _, p := range call.Params {
+		var val any
+		if p.Direction != "out" && p.Type != "" {
+			v, err := procValue(p.Type, p.Value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parameter %s: %w", p.Name, err)
+			}
+			val = v
+		} else {
+			val = p.Value
+		}
+
+		if p.Direction != "out" && p.Direction != "inout" {
+			args = append(args, sql.Named(p.Name, val))
+			continue
+		}
+
+		var dest driver.Valuer
+		switch p.Type {
+		case "int":
+			v, ok := val.(int64)
+			dest = &sql.NullInt64{Int64: v, Valid: ok}
+		case "float":
+			v, ok := val.(float64)
+			dest = &sql.NullFloat64{Float64: v, Valid: ok}
+		case "string", "decimal":
+			v, ok := val.(string)
+			dest = &sql.NullString{String: v, Valid: ok}
+		case "bool":
+			v, ok := val.(bool)
+			dest = &sql.NullBool{Bool: v, Valid: ok}
+		default:
+			return nil, nil, fmt.Errorf("parameter %s: unknown type %q", p.Name, p.Type)
+		}
+		outs[p.Name] = dest
+		args = append(args, sql.Named(p.Name, sql.Out{Dest: dest, In: p.Direction == "inout"}))
+	}
+	return args, outs, nil
+}
+
+// sqlServerColumnsQuery reads a table's columns from the catalog. timestamp is the
+// legacy name of rowversion, which the server fills in.
+const sqlServerColumnsQuery = `SELECT c.name, t.name, c.is_nullable,
+	CASE WHEN c.default_object_id <> 0 THEN 1 ELSE 0 END,
+	CASE WHEN c.is_identity = 1 OR c.is_computed = 1 OR t.name IN ('timestamp', 'rowversion') THEN 1 ELSE 0 END
+FROM sys.columns c
+JOIN sys.types t ON t.user_type_id = c.user_type_id
+WHERE c.object_id = OBJECT_ID(@table)
+ORDER BY c.column_id`
+
+// TableColumns lists a table's columns in declaration order.
+func (d *SQLServerDriver) TableColumns(ctx context.Context, table string) ([]Column, error) {
+	rows, err := d.conn.Load().QueryContext(ctx, sqlServerColumnsQuery, sql.Named("table", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	return scanColumns(rows, table)
+}
+
 // Ping checks database connectivity
 func (d *SQLServerDriver) Ping(ctx context.Context) error {
-	return d.conn.PingContext(ctx)
+	return probe(ctx, d.conn.Load(), d.cfg.ProbeQuery)
 }
 
 func (d *SQLServerDriver) PoolStats() PoolStats {
-	if d.conn == nil {
+	conn := d.conn.Load()
+	if conn == nil {
 		return PoolStats{}
 	}
-	s := d.conn.Stats()
-	return PoolStats{OpenConnections: s.OpenConnections, IdleConnections: s.Idle}
+	s := conn.Stats()
+	return PoolStats{
+		OpenConnections:    s.OpenConnections,
+		IdleConnections:    s.Idle,
+		InUse:              s.InUse,
+		MaxOpenConnections: s.MaxOpenConnections,
+		WaitCount:          s.WaitCount,
+		WaitDuration:       s.WaitDuration,
+	}
 }
@@ -193,3 +193,53 @@ func TestSQLServerDriver_BuildArgs_NilValue(t *testing.T) {
 		t.Errorf("value = %v, want nil", named.Value)
 	}
 }
+
+// TestSQLServerProcArgs verifies procedure arguments bind by name with typed out destinations
+func TestSQLServerProcArgs(t *testing.T) {
+	call := ProcCall{Name: "dbo.PlaceOrder", Params: []ProcParam{
+		{Name: "CustomerId", Direction: "in", Value: float64(42), Type: "int"},
+		{Name: "Note", Direction: "in", Value: "rush"},
+		{Name: "OrderId", Direction: "out", Type: "int"},
+		{Name: "Attempts", Direction: "inout", Type: "int", Value: "3"},
+	}}
+
+	args, outs, err := sqlserverProcArgs(call)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(args) != 4 {
+		t.Fatalf("expected 4 args, got %d", len(args))
+	}
+
+	if named := args[0].(sql.NamedArg); named.Name != "CustomerId" || named.Value != int64(42) {
+		t.Errorf("arg[0] = %+v, want CustomerId=int64(42)", named)
+	}
+	if named := args[1].(sql.NamedArg); named.Name != "Note" || named.Value != "rush" {
+		t.Errorf("arg[1] = %+v, want Note=rush", named)
+	}
+
+	out := args[2].(sql.NamedArg).Value.(sql.Out)
+	if out.In {
+		t.Error("out parameter should not send its value")
+	}
+	if dest := out.Dest.(*sql.NullInt64); dest.Valid {
+		t.Errorf("out destination = %+v, want NULL", dest)
+	}
+
+	inout := args[3].(sql.NamedArg).Value.(sql.Out)
+	if dest := inout.Dest.(*sql.NullInt64); !inout.In || !dest.Valid || dest.Int64 != 3 {
+		t.Errorf("inout = %+v, want input value 3", inout)
+	}
+
+	if len(outs) != 2 || outs["OrderId"] == nil || outs["Attempts"] == nil {
+		t.Errorf("outs = %v, want OrderId and Attempts", outs)
+	}
+}
+
+// TestSQLServerProcArgs_InvalidValue verifies values that cannot be converted to the declared type fail
+func TestSQLServerProcArgs_InvalidValue(t *testing.T) {
+	call := ProcCall{Name: "p", Params: []ProcParam{{Name: "Id", Direction: "in", Type: "int", Value: "abc"}}}
+	if _, _, err := sqlserverProcArgs(call); err == nil {
+		t.Error("expected error for non-numeric int parameter")
+	}
+}
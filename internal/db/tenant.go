@@ -0,0 +1,270 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"sql-proxy/internal/concurrency"
+	"sql-proxy/internal/config"
+)
+
+// ErrUnknownTenant is returned for tenants found in neither the static registry nor the lookup.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// ErrTenantLimitReached is returned when max_tenants connections are open and all are in use.
+var ErrTenantLimitReached = errors.New("tenant connection limit reached")
+
+// defaultTenantIdleTimeout is how long an unused tenant connection stays open
+const defaultTenantIdleTimeout = 10 * time.Minute
+
+// TenantRouter opens and caches one connection per tenant of a tenant-routed database.
+type TenantRouter struct {
+	cfg         config.DatabaseConfig // Shared settings; tenants override the connection fields
+	lookup      Driver                // Registry connection (nil without lookup)
+	idleTimeout time.Duration
+	connect     func(config.DatabaseConfig) (Driver, error)
+
+	mu      sync.Mutex
+	tenants map[string]*tenantConn
+	opening singleflight.Group
+}
+
+// tenantConn is an open tenant connection.
+type tenantConn struct {
+	driver   Driver
+	limiter  *concurrency.Limiter
+	active   int       // Queries holding the connection (guarded by TenantRouter.mu)
+	lastUsed time.Time // Guarded by TenantRouter.mu
+}
+
+// TenantStats is a point-in-time view of a tenant-routed database.
+type TenantStats struct {
+	Open       int `json:"open"`   // Open tenant connections
+	Active     int `json:"active"` // Tenant connections with queries in flight
+	MaxTenants int `json:"max_tenants"`
+}
+
+// NewTenantRouter creates a router for cfg, whose TenantRouting must be set.
+// lookup is the registry connection when TenantRouting.Lookup is configured.
+func NewTenantRouter(cfg config.DatabaseConfig, lookup Driver) *TenantRouter {
+	idle := defaultTenantIdleTimeout
+	if cfg.TenantRouting.IdleTimeoutSec > 0 {
+		idle = time.Duration(cfg.TenantRouting.IdleTimeoutSec) * time.Second
+	}
+	return &TenantRouter{
+		cfg:         cfg,
+		lookup:      lookup,
+		idleTimeout: idle,
+		connect:     NewDriver,
+		tenants:     make(map[string]*tenantConn),
+	}
+}
+
+// Acquire returns the tenant's connection, opening it on first use, and takes one
+// of its max_concurrent slots. The returned release func must be called when the
+// query completes; until then the connection is not closed.
+func (r *TenantRouter) Acquire(ctx context.Context, tenant string) (driver Driver, release func(), err error) {
+	if tenant == "" {
+		return nil, nil, errors.New("tenant is empty")
+	}
+
+	var tc *tenantConn
+	for tc == nil {
+		r.mu.Lock()
+		if tc = r.tenants[tenant]; tc != nil {
+			tc.active++
+			tc.lastUsed = time.Now()
+		}
+		r.mu.Unlock()
+		if tc != nil {
+			break
+		}
+
+		// Concurrent first requests for a tenant share one connection attempt
+		if _, err, _ := r.opening.Do(tenant, func() (any, error) {
+			return nil, r.open(ctx, tenant)
+		}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	done := func() {
+		r.mu.Lock()
+		tc.active--
+		tc.lastUsed = time.Now()
+		r.mu.Unlock()
+	}
+	if err := tc.limiter.Acquire(ctx); err != nil {
+		done()
+		return nil, nil, err
+	}
+	return tc.driver, func() {
+		tc.limiter.Release()
+		done()
+	}, nil
+}
+
+// open resolves the tenant's settings, connects and registers the connection.
+func (r *TenantRouter) open(ctx context.Context, tenant string) error {
+	r.mu.Lock()
+	_, opened := r.tenants[tenant] // By a previous attempt that finished after our lookup
+	r.mu.Unlock()
+	if opened {
+		return nil
+	}
+
+	settings, err := r.resolve(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	cfg := r.cfg
+	cfg.Name = fmt.Sprintf("%s[%s]", r.cfg.Name, tenant)
+	cfg.TenantRouting = nil
+	applyTenant(&cfg, settings)
+
+	r.mu.Lock()
+	r.closeIdle()
+	if max := r.cfg.TenantRouting.MaxTenants; max > 0 && len(r.tenants) >= max && !r.evictOne() {
+		r.mu.Unlock()
+		return fmt.Errorf("%w (%d)", ErrTenantLimitReached, max)
+	}
+	r.mu.Unlock()
+
+	driver, err := r.connect(cfg)
+	if err != nil {
+		return fmt.Errorf("tenant %s: %w", tenant, err)
+	}
+
+	r.mu.Lock()
+	r.tenants[tenant] = &tenantConn{
+		driver:   driver,
+		limiter:  concurrency.New(cfg.MaxConcurrent, time.Duration(cfg.MaxConcurrentWaitMs)*time.Millisecond),
+		lastUsed: time.Now(),
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// resolve returns the tenant's connection overrides from the static registry, or
+// else from the lookup query.
+func (r *TenantRouter) resolve(ctx context.Context, tenant string) (config.TenantConfig, error) {
+	if t, ok := r.cfg.TenantRouting.Tenants[tenant]; ok {
+		return t, nil
+	}
+	if r.lookup == nil {
+		return config.TenantConfig{}, fmt.Errorf("%w: %s", ErrUnknownTenant, tenant)
+	}
+
+	result, err := r.lookup.Query(ctx, config.SessionConfig{}, r.cfg.TenantRouting.Lookup.SQL, map[string]any{"tenant": tenant}, nil)
+	if err != nil {
+		return config.TenantConfig{}, fmt.Errorf("tenant lookup: %w", err)
+	}
+	if len(result.Rows) == 0 {
+		return config.TenantConfig{}, fmt.Errorf("%w: %s", ErrUnknownTenant, tenant)
+	}
+	row := result.Rows[0]
+	str := func(col string) string {
+		if v, ok := row[col]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	t := config.TenantConfig{
+		Host:     str("host"),
+		User:     str("user"),
+		Password: str("password"),
+		Database: str("database"),
+		Path:     str("path"),
+	}
+	if port := str("port"); port != "" {
+		if t.Port, err = strconv.Atoi(port); err != nil {
+			return config.TenantConfig{}, fmt.Errorf("tenant lookup: invalid port %q", port)
+		}
+	}
+	return t, nil
+}
+
+// applyTenant overrides cfg's connection settings with the tenant's non-empty ones.
+func applyTenant(cfg *config.DatabaseConfig, t config.TenantConfig) {
+	if t.Host != "" {
+		cfg.Host = t.Host
+	}
+	if t.Port != 0 {
+		cfg.Port = t.Port
+	}
+	if t.User != "" {
+		cfg.User = t.User
+	}
+	if t.Password != "" {
+		cfg.Password = t.Password
+	}
+	if t.Database != "" {
+		cfg.Database = t.Database
+	}
+	if t.Path != "" {
+		cfg.Path = t.Path
+	}
+}
+
+// closeIdle closes connections unused for the idle timeout. Caller holds r.mu.
+func (r *TenantRouter) closeIdle() {
+	cutoff := time.Now().Add(-r.idleTimeout)
+	for tenant, tc := range r.tenants {
+		if tc.active == 0 && tc.lastUsed.Before(cutoff) {
+			_ = tc.driver.Close()
+			delete(r.tenants, tenant)
+		}
+	}
+}
+
+// evictOne closes the least recently used connection without queries in flight.
+// Returns false when every connection is in use. Caller holds r.mu.
+func (r *TenantRouter) evictOne() bool {
+	var oldest string
+	for tenant, tc := range r.tenants {
+		if tc.active == 0 && (oldest == "" || tc.lastUsed.Before(r.tenants[oldest].lastUsed)) {
+			oldest = tenant
+		}
+	}
+	if oldest == "" {
+		return false
+	}
+	_ = r.tenants[oldest].driver.Close()
+	delete(r.tenants, oldest)
+	return true
+}
+
+// Stats returns the router's current usage.
+func (r *TenantRouter) Stats() TenantStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := TenantStats{Open: len(r.tenants), MaxTenants: r.cfg.TenantRouting.MaxTenants}
+	for _, tc := range r.tenants {
+		if tc.active > 0 {
+			stats.Active++
+		}
+	}
+	return stats
+}
+
+// Close closes every tenant connection.
+func (r *TenantRouter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var firstErr error
+	for tenant, tc := range r.tenants {
+		if err := tc.driver.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close tenant %s: %w", tenant, err)
+		}
+	}
+	r.tenants = make(map[string]*tenantConn)
+	return firstErr
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"sql-proxy/internal/config"
+)
+
+// newTenantFile creates a SQLite database at dir/name.db whose tenant table holds name
+func newTenantFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name+".db")
+	readOnly := false
+	driver, err := NewSQLiteDriver(config.DatabaseConfig{Name: name, Type: "sqlite", Path: path, ReadOnly: &readOnly})
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+	defer func() { _ = driver.Close() }()
+	ctx := context.Background()
+	if _, err := driver.Query(ctx, config.SessionConfig{}, "CREATE TABLE tenant (name TEXT)", nil, nil); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	if _, err := driver.Query(ctx, config.SessionConfig{}, "INSERT INTO tenant (name) VALUES (@name)", map[string]any{"name": name}, nil); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	return path
+}
+
+// tenantName queries the tenant table through the router
+func tenantName(t *testing.T, r *TenantRouter, tenant string) string {
+	t.Helper()
+	driver, release, err := r.Acquire(context.Background(), tenant)
+	if err != nil {
+		t.Fatalf("Acquire(%s) failed: %v", tenant, err)
+	}
+	defer release()
+	result, err := driver.Query(context.Background(), config.SessionConfig{}, "SELECT name FROM tenant", nil, nil)
+	if err != nil {
+		t.Fatalf("query for %s failed: %v", tenant, err)
+	}
+	return result.Rows[0]["name"].(string)
+}
+
+// TestTenantRouter_Static verifies tenants from the static registry get their own connections
+func TestTenantRouter_Static(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.DatabaseConfig{
+		Name: "tenants",
+		Type: "sqlite",
+		TenantRouting: &config.TenantRoutingConfig{
+			Tenants: map[string]config.TenantConfig{
+				"acme":   {Path: newTenantFile(t, dir, "acme")},
+				"globex": {Path: newTenantFile(t, dir, "globex")},
+			},
+		},
+	}
+	r := NewTenantRouter(cfg, nil)
+	defer func() { _ = r.Close() }()
+
+	if got := tenantName(t, r, "acme"); got != "acme" {
+		t.Errorf("acme query returned %q", got)
+	}
+	if got := tenantName(t, r, "globex"); got != "globex" {
+		t.Errorf("globex query returned %q", got)
+	}
+	if got := tenantName(t, r, "acme"); got != "acme" {
+		t.Errorf("second acme query returned %q", got)
+	}
+	if st := r.Stats(); st.Open != 2 || st.Active != 0 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	if _, _, err := r.Acquire(context.Background(), "initech"); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("expected ErrUnknownTenant, got %v", err)
+	}
+	if _, _, err := r.Acquire(context.Background(), ""); err == nil {
+		t.Error("expected error for empty tenant")
+	}
+}
+
+// TestTenantRouter_Lookup verifies tenant settings read from a registry table
+func TestTenantRouter_Lookup(t *testing.T) {
+	dir := t.TempDir()
+	registry := newTenantFile(t, dir, "registry")
+	acme := newTenantFile(t, dir, "acme")
+
+	readOnly := false
+	lookup, err := NewSQLiteDriver(config.DatabaseConfig{Name: "registry", Type: "sqlite", Path: registry, ReadOnly: &readOnly})
+	if err != nil {
+		t.Fatalf("failed to open registry: %v", err)
+	}
+	defer func() { _ = lookup.Close() }()
+	ctx := context.Background()
+	if _, err := lookup.Query(ctx, config.SessionConfig{}, "CREATE TABLE tenants (id TEXT, path TEXT)", nil, nil); err != nil {
+		t.Fatalf("create registry: %v", err)
+	}
+	if _, err := lookup.Query(ctx, config.SessionConfig{}, "INSERT INTO tenants VALUES ('acme', @path)", map[string]any{"path": acme}, nil); err != nil {
+		t.Fatalf("insert registry: %v", err)
+	}
+
+	cfg := config.DatabaseConfig{
+		Name: "tenants",
+		Type: "sqlite",
+		TenantRouting: &config.TenantRoutingConfig{
+			Lookup: &config.TenantLookupConfig{Database: "registry", SQL: "SELECT path FROM tenants WHERE id = @tenant"},
+		},
+	}
+	r := NewTenantRouter(cfg, lookup)
+	defer func() { _ = r.Close() }()
+
+	if got := tenantName(t, r, "acme"); got != "acme" {
+		t.Errorf("acme query returned %q", got)
+	}
+	if _, _, err := r.Acquire(ctx, "globex"); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("expected ErrUnknownTenant, got %v", err)
+	}
+}
+
+// TestTenantRouter_MaxTenants verifies idle connections are closed to make room and busy ones are kept
+func TestTenantRouter_MaxTenants(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.DatabaseConfig{
+		Name: "tenants",
+		Type: "sqlite",
+		TenantRouting: &config.TenantRoutingConfig{
+			MaxTenants: 1,
+			Tenants: map[string]config.TenantConfig{
+				"acme":   {Path: newTenantFile(t, dir, "acme")},
+				"globex": {Path: newTenantFile(t, dir, "globex")},
+			},
+		},
+	}
+	r := NewTenantRouter(cfg, nil)
+	defer func() { _ = r.Close() }()
+	ctx := context.Background()
+
+	// acme is idle, so globex replaces it
+	tenantName(t, r, "acme")
+	if got := tenantName(t, r, "globex"); got != "globex" {
+		t.Errorf("globex query returned %q", got)
+	}
+	if st := r.Stats(); st.Open != 1 {
+		t.Errorf("expected 1 open connection, got %+v", st)
+	}
+
+	// globex is in use, so acme can't open
+	_, release, err := r.Acquire(ctx, "globex")
+	if err != nil {
+		t.Fatalf("Acquire(globex) failed: %v", err)
+	}
+	if _, _, err := r.Acquire(ctx, "acme"); !errors.Is(err, ErrTenantLimitReached) {
+		t.Errorf("expected ErrTenantLimitReached, got %v", err)
+	}
+	release()
+	if got := tenantName(t, r, "acme"); got != "acme" {
+		t.Errorf("acme query after release returned %q", got)
+	}
+}
+
+// TestManager_TenantRouting verifies tenant-routed databases are opened lazily through the manager
+func TestManager_TenantRouting(t *testing.T) {
+	dir := t.TempDir()
+	cfg := []config.DatabaseConfig{
+		{
+			Name: "tenants",
+			Type: "sqlite",
+			TenantRouting: &config.TenantRoutingConfig{
+				Tenants: map[string]config.TenantConfig{"acme": {Path: newTenantFile(t, dir, "acme")}},
+			},
+		},
+		{Name: "main", Type: "sqlite", Path: ":memory:"},
+	}
+	manager, err := NewManager(cfg)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	if !manager.IsTenantRouted("tenants") || manager.IsTenantRouted("main") {
+		t.Error("IsTenantRouted mismatch")
+	}
+	if _, err := manager.Get("tenants"); err == nil {
+		t.Error("expected Get to fail for a tenant-routed database")
+	}
+	if st := manager.TenantStats()["tenants"]; st.Open != 0 {
+		t.Errorf("expected no connections before first use, got %+v", st)
+	}
+
+	driver, release, err := manager.AcquireTenant(context.Background(), "tenants", "acme")
+	if err != nil {
+		t.Fatalf("AcquireTenant failed: %v", err)
+	}
+	defer release()
+	if driver.Name() != "tenants[acme]" {
+		t.Errorf("expected tenant driver name, got %s", driver.Name())
+	}
+	if _, _, err := manager.AcquireTenant(context.Background(), "main", "acme"); err == nil {
+		t.Error("expected AcquireTenant to fail for a regular database")
+	}
+}
+
+// TestNewManager_UnknownTenantLookup ensures the lookup database must be configured
+func TestNewManager_UnknownTenantLookup(t *testing.T) {
+	cfg := []config.DatabaseConfig{{
+		Name: "tenants",
+		Type: "sqlite",
+		TenantRouting: &config.TenantRoutingConfig{
+			Lookup: &config.TenantLookupConfig{Database: "missing", SQL: "SELECT 1"},
+		},
+	}}
+	if _, err := NewManager(cfg); err == nil {
+		t.Error("expected error for unknown lookup database")
+	}
+}
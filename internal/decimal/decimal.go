@@ -0,0 +1,251 @@
+// Package decimal implements exact base-10 arithmetic for money and database
+// DECIMAL values, which float64 cannot represent exactly.
+package decimal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxScale bounds the digits after the point so a hostile exponent ("1e-999999")
+// cannot allocate huge numbers
+const MaxScale = 1000
+
+// ErrDivisionByZero is returned by Div when the divisor is zero
+var ErrDivisionByZero = errors.New("division by zero")
+
+// Decimal is an arbitrary-precision decimal: unscaled * 10^-scale.
+// The scale is kept, so "12.50" stays "12.50".
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+// RoundingMode selects how Round discards digits
+type RoundingMode int
+
+const (
+	HalfUp   RoundingMode = iota // Ties away from zero (commercial rounding)
+	HalfEven                     // Ties to the even digit (banker's rounding)
+	Down                         // Toward zero (truncate)
+	Up                           // Away from zero
+	Floor                        // Toward negative infinity
+	Ceil                         // Toward positive infinity
+)
+
+var roundingModes = map[string]RoundingMode{
+	"half_up": HalfUp, "half_even": HalfEven, "down": Down, "up": Up, "floor": Floor, "ceil": Ceil,
+}
+
+// ParseRoundingMode parses half_up, half_even, down, up, floor or ceil
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	if m, ok := roundingModes[strings.ToLower(s)]; ok {
+		return m, nil
+	}
+	return 0, fmt.Errorf("unknown rounding mode %q (use half_up, half_even, down, up, floor or ceil)", s)
+}
+
+// Parse parses a plain or exponent decimal string such as "12.50", "-0.1" or "1.5e3"
+func Parse(s string) (Decimal, error) {
+	str := strings.TrimSpace(s)
+	mantissa, exp := str, 0
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		e, err := strconv.Atoi(str[i+1:])
+		if err != nil || e > MaxScale || e < -MaxScale {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		mantissa, exp = str[:i], e
+	}
+
+	sign := ""
+	if mantissa != "" && (mantissa[0] == '-' || mantissa[0] == '+') {
+		if mantissa[0] == '-' {
+			sign = "-"
+		}
+		mantissa = mantissa[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(mantissa, ".")
+	if intPart == "" && fracPart == "" || !allDigits(intPart) || !allDigits(fracPart) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	unscaled, _ := new(big.Int).SetString(sign+intPart+fracPart, 10)
+	scale := len(fracPart) - exp
+	if scale < 0 {
+		unscaled.Mul(unscaled, pow10(-scale))
+		scale = 0
+	}
+	if scale > MaxScale {
+		return Decimal{}, fmt.Errorf("decimal %q has more than %d digits after the point", s, MaxScale)
+	}
+	return Decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FromInt returns the decimal for i
+func FromInt(i int64) Decimal {
+	return Decimal{unscaled: big.NewInt(i)}
+}
+
+// FromFloat returns the decimal for the shortest representation of f, so 0.1
+// becomes exactly 0.1 rather than the binary approximation float64 holds
+func FromFloat(f float64) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, fmt.Errorf("invalid decimal %v", f)
+	}
+	return Parse(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// Scale returns the number of digits after the point
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Sign returns -1, 0 or 1
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// int returns the unscaled value, treating the zero Decimal as 0
+func (d Decimal) int() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+// rescale returns the unscaled value at a larger scale
+func (d Decimal) rescale(scale int) *big.Int {
+	if scale == d.scale {
+		return d.int()
+	}
+	return new(big.Int).Mul(d.int(), pow10(scale-d.scale))
+}
+
+// Add returns d + o with the larger of the two scales
+func (d Decimal) Add(o Decimal) Decimal {
+	scale := max(d.scale, o.scale)
+	return Decimal{unscaled: new(big.Int).Add(d.rescale(scale), o.rescale(scale)), scale: scale}
+}
+
+// Sub returns d - o with the larger of the two scales
+func (d Decimal) Sub(o Decimal) Decimal {
+	scale := max(d.scale, o.scale)
+	return Decimal{unscaled: new(big.Int).Sub(d.rescale(scale), o.rescale(scale)), scale: scale}
+}
+
+// Mul returns d * o exactly; the scale is the sum of the two scales
+func (d Decimal) Mul(o Decimal) (Decimal, error) {
+	scale := d.scale + o.scale
+	if scale > MaxScale {
+		return Decimal{}, fmt.Errorf("product has more than %d digits after the point", MaxScale)
+	}
+	return Decimal{unscaled: new(big.Int).Mul(d.int(), o.int()), scale: scale}, nil
+}
+
+// Div returns d / o rounded to places digits after the point
+func (d Decimal) Div(o Decimal, places int, mode RoundingMode) (Decimal, error) {
+	if o.Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+	if places < 0 || places > MaxScale {
+		return Decimal{}, fmt.Errorf("places must be between 0 and %d", MaxScale)
+	}
+	// d/o = (d.unscaled * 10^o.scale) / (o.unscaled * 10^d.scale); one extra
+	// scale of 10^places keeps the wanted digits in the integer quotient
+	num := new(big.Int).Mul(d.int(), pow10(o.scale+places))
+	den := new(big.Int).Mul(o.int(), pow10(d.scale))
+	return Decimal{unscaled: divRound(num, den, mode), scale: places}, nil
+}
+
+// Round returns d with exactly places digits after the point
+func (d Decimal) Round(places int, mode RoundingMode) Decimal {
+	if places >= d.scale {
+		return Decimal{unscaled: d.rescale(places), scale: places}
+	}
+	return Decimal{unscaled: divRound(d.int(), pow10(d.scale-places), mode), scale: places}
+}
+
+// Trim removes trailing zeros after the point, keeping at least minScale digits
+func (d Decimal) Trim(minScale int) Decimal {
+	u, scale := new(big.Int).Set(d.int()), d.scale
+	ten, r := big.NewInt(10), new(big.Int)
+	for scale > minScale {
+		q, m := new(big.Int).QuoRem(u, ten, r)
+		if m.Sign() != 0 {
+			break
+		}
+		u, scale = q, scale-1
+	}
+	return Decimal{unscaled: u, scale: scale}
+}
+
+// Cmp compares d and o, returning -1, 0 or 1
+func (d Decimal) Cmp(o Decimal) int {
+	scale := max(d.scale, o.scale)
+	return d.rescale(scale).Cmp(o.rescale(scale))
+}
+
+// String formats d without an exponent, keeping its scale ("12.50", "-0.05")
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.int()).String()
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	point := len(digits) - d.scale
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// divRound divides num by den (den != 0), rounding the quotient per mode
+func divRound(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	// The sign of the exact quotient; QuoRem truncates toward zero
+	neg := (num.Sign() < 0) != (den.Sign() < 0)
+	away := false
+	switch mode {
+	case Up:
+		away = true
+	case Floor:
+		away = neg
+	case Ceil:
+		away = !neg
+	case HalfUp, HalfEven:
+		// Compare twice the remainder with the divisor
+		c := new(big.Int).Abs(new(big.Int).Lsh(r, 1)).Cmp(new(big.Int).Abs(den))
+		away = c > 0 || c == 0 && (mode == HalfUp || q.Bit(0) == 1)
+	}
+	if away {
+		if neg {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
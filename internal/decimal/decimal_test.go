@@ -0,0 +1,159 @@
+package decimal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) Decimal {
+	t.Helper()
+	d, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", s, err)
+	}
+	return d
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"12.50", "12.50"},
+		{"-0.05", "-0.05"},
+		{"+7", "7"},
+		{".5", "0.5"},
+		{"3.", "3"},
+		{" 42 ", "42"},
+		{"1.5e3", "1500"},
+		{"1.5E-3", "0.0015"},
+		{"-0", "0"},
+		{"123456789012345678901234567890.123456789", "123456789012345678901234567890.123456789"},
+	}
+	for _, tt := range tests {
+		if got := mustParse(t, tt.in).String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", ".", "-", "1.2.3", "abc", "1e", "1e5000", "0x10", "1,000", "1e-2000"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	for f, want := range map[float64]string{0.1: "0.1", 19.99: "19.99", 1e21: "1000000000000000000000", -2.5: "-2.5"} {
+		d, err := FromFloat(f)
+		if err != nil || d.String() != want {
+			t.Errorf("FromFloat(%v) = %s, %v, want %s", f, d, err, want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, b := mustParse(t, "0.1"), mustParse(t, "0.2")
+	if got := a.Add(b).String(); got != "0.3" {
+		t.Errorf("0.1 + 0.2 = %s, want 0.3", got)
+	}
+	if got := mustParse(t, "10.00").Sub(mustParse(t, "0.015")).String(); got != "9.985" {
+		t.Errorf("Sub = %s, want 9.985", got)
+	}
+	if got := FromInt(3).Sub(mustParse(t, "3.50")).String(); got != "-0.50" {
+		t.Errorf("Sub = %s, want -0.50", got)
+	}
+	p, err := mustParse(t, "19.99").Mul(FromInt(3))
+	if err != nil || p.String() != "59.97" {
+		t.Errorf("Mul = %s, %v, want 59.97", p, err)
+	}
+	p, _ = mustParse(t, "1.10").Mul(mustParse(t, "0.075"))
+	if p.String() != "0.08250" {
+		t.Errorf("Mul = %s, want 0.08250", p)
+	}
+	if mustParse(t, "1.50").Cmp(mustParse(t, "1.5")) != 0 || mustParse(t, "-1").Cmp(mustParse(t, "0.001")) != -1 {
+		t.Error("Cmp mismatch")
+	}
+	var zero Decimal
+	if zero.Add(FromInt(1)).String() != "1" || zero.String() != "0" || zero.Sign() != 0 {
+		t.Error("zero value should behave as 0")
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		places int
+		mode   RoundingMode
+		want   string
+	}{
+		{"10", "3", 2, HalfUp, "3.33"},
+		{"20", "3", 2, HalfUp, "6.67"},
+		{"-20", "3", 2, HalfUp, "-6.67"},
+		{"1", "8", 2, HalfEven, "0.12"},
+		{"1", "8", 2, HalfUp, "0.13"},
+		{"100.00", "0.25", 0, HalfUp, "400"},
+		{"1", "-3", 4, Down, "-0.3333"},
+		{"0.5", "0.25", 3, HalfUp, "2.000"},
+	}
+	for _, tt := range tests {
+		got, err := mustParse(t, tt.a).Div(mustParse(t, tt.b), tt.places, tt.mode)
+		if err != nil || got.String() != tt.want {
+			t.Errorf("%s / %s = %s, %v, want %s", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+
+	if _, err := FromInt(1).Div(mustParse(t, "0.00"), 2, HalfUp); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("expected ErrDivisionByZero, got %v", err)
+	}
+	if _, err := FromInt(1).Div(FromInt(3), -1, HalfUp); err == nil || !strings.Contains(err.Error(), "places") {
+		t.Errorf("expected places error, got %v", err)
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		in     string
+		places int
+		mode   string
+		want   string
+	}{
+		{"2.345", 2, "half_up", "2.35"},
+		{"-2.345", 2, "half_up", "-2.35"},
+		{"2.345", 2, "half_even", "2.34"},
+		{"2.355", 2, "half_even", "2.36"},
+		{"2.349", 2, "down", "2.34"},
+		{"2.341", 2, "up", "2.35"},
+		{"-2.341", 2, "floor", "-2.35"},
+		{"-2.349", 2, "ceil", "-2.34"},
+		{"2.5", 0, "half_even", "2"},
+		{"3.5", 0, "half_even", "4"},
+		{"7", 2, "half_up", "7.00"},
+		{"0.004", 2, "half_up", "0.00"},
+	}
+	for _, tt := range tests {
+		mode, err := ParseRoundingMode(tt.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := mustParse(t, tt.in).Round(tt.places, mode).String(); got != tt.want {
+			t.Errorf("Round(%s, %d, %s) = %s, want %s", tt.in, tt.places, tt.mode, got, tt.want)
+		}
+	}
+
+	if _, err := ParseRoundingMode("nearest"); err == nil {
+		t.Error("expected error for unknown rounding mode")
+	}
+}
+
+func TestTrim(t *testing.T) {
+	for in, want := range map[string]string{"1.2500": "1.25", "3.000": "3", "10": "10", "0.000": "0"} {
+		if got := mustParse(t, in).Trim(0).String(); got != want {
+			t.Errorf("Trim(%s) = %s, want %s", in, got, want)
+		}
+	}
+	if got := mustParse(t, "3.000").Trim(2).String(); got != "3.00" {
+		t.Errorf("Trim(3.000, 2) = %s, want 3.00", got)
+	}
+}
@@ -0,0 +1,299 @@
+// Package errtrack reports panics, step failures, and template errors to Sentry
+// or a generic error-collector webhook. Reports are queued and sent in the
+// background; when the queue is full they are dropped rather than slowing
+// down requests.
+package errtrack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"sql-proxy/internal/logging"
+)
+
+// Kinds of reported errors
+const (
+	KindPanic         = "panic"
+	KindStepFailure   = "step_failure"
+	KindTemplateError = "template_error" // A step failed executing one of its templates
+)
+
+// Levels of reported errors
+const (
+	LevelFatal   = "fatal"   // Panics
+	LevelError   = "error"   // Failures that aborted a workflow (default)
+	LevelWarning = "warning" // Failures the workflow continued past
+)
+
+// DefaultTimeout bounds a single report when the config sets no timeout
+const DefaultTimeout = 5 * time.Second
+
+// queueSize is how many reports can wait to be sent before new ones are dropped
+const queueSize = 256
+
+// maxStackFrames caps the frames captured for a panic
+const maxStackFrames = 64
+
+// Event is an error to report
+type Event struct {
+	Kind      string            `json:"kind"`
+	Level     string            `json:"level"`
+	Message   string            `json:"message"`
+	Workflow  string            `json:"workflow,omitempty"`
+	Step      string            `json:"step,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`  // Extra context, such as the HTTP method and path
+	Stack     []Frame           `json:"stack,omitempty"` // Panics only, innermost call first
+}
+
+// Frame is one call of a panic's stack
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Config selects where reports go. Either or both of SentryDSN and Webhook may be set.
+type Config struct {
+	SentryDSN      string
+	Webhook        string            // Each report is POSTed as JSON
+	WebhookHeaders map[string]string // Extra webhook request headers, e.g. Authorization
+	Environment    string
+	Release        string
+	Timeout        time.Duration // Per report and sink (default: DefaultTimeout)
+}
+
+// report is a queued event with the details every sink sends
+type report struct {
+	Event
+	ID          string    `json:"id"`
+	Timestamp   time.Time `json:"timestamp"`
+	ServerName  string    `json:"server_name,omitempty"`
+	Environment string    `json:"environment,omitempty"`
+	Release     string    `json:"release,omitempty"`
+}
+
+// sink delivers a report to one destination
+type sink interface {
+	send(ctx context.Context, r *report) error
+}
+
+type tracker struct {
+	sinks   []sink
+	queue   chan *report
+	done    chan struct{}
+	timeout time.Duration
+	env     string
+	release string
+	host    string
+	dropped atomic.Int64 // Reports that found the queue full
+}
+
+var (
+	mu     sync.RWMutex
+	active *tracker
+)
+
+// Init starts reporting to the configured destinations, replacing an earlier
+// Init. Without a DSN or webhook, reporting stays off.
+func Init(cfg Config) error {
+	var sinks []sink
+	if cfg.SentryDSN != "" {
+		s, err := newSentrySink(cfg.SentryDSN)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, s)
+	}
+	if cfg.Webhook != "" {
+		sinks = append(sinks, &webhookSink{url: cfg.Webhook, headers: cfg.WebhookHeaders})
+	}
+	Close()
+	if len(sinks) == 0 {
+		return nil
+	}
+
+	t := &tracker{
+		sinks:   sinks,
+		queue:   make(chan *report, queueSize),
+		done:    make(chan struct{}),
+		timeout: cfg.Timeout,
+		env:     cfg.Environment,
+		release: cfg.Release,
+	}
+	if t.timeout <= 0 {
+		t.timeout = DefaultTimeout
+	}
+	t.host, _ = os.Hostname()
+	go t.run()
+
+	mu.Lock()
+	active = t
+	mu.Unlock()
+	return nil
+}
+
+// Enabled reports whether Init configured a destination
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return active != nil
+}
+
+// Report queues an event. It never blocks: without Init, or with a full queue, the event is dropped.
+func Report(ev Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if active == nil {
+		return
+	}
+	if ev.Level == "" {
+		ev.Level = LevelError
+	}
+	r := &report{
+		Event:       ev,
+		ID:          strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Timestamp:   time.Now().UTC(),
+		ServerName:  active.host,
+		Environment: active.env,
+		Release:     active.release,
+	}
+	select {
+	case active.queue <- r:
+	default:
+		active.dropped.Add(1)
+	}
+}
+
+// ReportedPanic carries a panic value on after it was reported, so outer
+// recovery points that see it again don't report it twice.
+type ReportedPanic struct {
+	Value any
+}
+
+func (p *ReportedPanic) Error() string {
+	return fmt.Sprint(p.Value)
+}
+
+// ReportPanic reports a recovered panic value with the stack of the panicking
+// goroutine; call it from the deferred function that recovered. It returns
+// the value to re-panic with, if the caller does: a *ReportedPanic once the
+// panic was reported. A *ReportedPanic is not reported again, nor is
+// http.ErrAbortHandler, which net/http uses to abort a response silently.
+func ReportPanic(p any, ev Event) any {
+	if _, ok := p.(*ReportedPanic); ok || p == http.ErrAbortHandler || !Enabled() {
+		return p
+	}
+	ev.Kind = KindPanic
+	ev.Level = LevelFatal
+	ev.Message = fmt.Sprint(p)
+	ev.Stack = callers(3)
+	Report(ev)
+	return &ReportedPanic{Value: p}
+}
+
+// callers returns the stack above skip frames, leaving out the runtime's own frames
+func callers(skip int) []Frame {
+	pcs := make([]uintptr, maxStackFrames)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var stack []Frame
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, "runtime.") {
+			stack = append(stack, Frame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+		if !more {
+			return stack
+		}
+	}
+}
+
+// Close sends the queued reports, waiting at most one report timeout, and stops reporting.
+func Close() {
+	mu.Lock()
+	t := active
+	active = nil
+	mu.Unlock()
+	if t == nil {
+		return
+	}
+	close(t.queue)
+	select {
+	case <-t.done:
+	case <-time.After(t.timeout):
+	}
+	if n := t.dropped.Load(); n > 0 {
+		logging.Warn("error_reports_dropped", map[string]any{"count": n})
+	}
+}
+
+func (t *tracker) run() {
+	defer close(t.done)
+	for r := range t.queue {
+		for _, s := range t.sinks {
+			ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+			if err := s.send(ctx, r); err != nil {
+				logging.Warn("error_report_failed", map[string]any{
+					"kind":  r.Kind,
+					"error": err.Error(),
+				})
+			}
+			cancel()
+		}
+	}
+}
+
+// client is shared by the sinks; each send has its own deadline
+var client = &http.Client{}
+
+// post sends body and checks for a 2xx response
+func post(ctx context.Context, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookSink POSTs each report as a JSON object
+type webhookSink struct {
+	url     string
+	headers map[string]string
+}
+
+func (s *webhookSink) send(ctx context.Context, r *report) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range s.headers {
+		headers[k] = v
+	}
+	return post(ctx, s.url, body, headers)
+}
@@ -0,0 +1,192 @@
+package errtrack
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// collector records the requests of an httptest server
+type collector struct {
+	mu      sync.Mutex
+	bodies  []string
+	headers []http.Header
+	paths   []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	c.bodies = append(c.bodies, string(body))
+	c.headers = append(c.headers, r.Header.Clone())
+	c.paths = append(c.paths, r.URL.Path)
+	c.mu.Unlock()
+}
+
+func TestNewSentrySink(t *testing.T) {
+	tests := []struct {
+		name         string
+		dsn          string
+		wantEndpoint string
+		wantErr      string
+	}{
+		{name: "sentry.io", dsn: "https://abc123@o1.ingest.sentry.io/42", wantEndpoint: "https://o1.ingest.sentry.io/api/42/envelope/"},
+		{name: "self-hosted with path", dsn: "http://abc123@sentry.local:9000/errors/7", wantEndpoint: "http://sentry.local:9000/errors/api/7/envelope/"},
+		{name: "missing key", dsn: "https://o1.ingest.sentry.io/42", wantErr: "missing public key"},
+		{name: "missing project", dsn: "https://abc123@o1.ingest.sentry.io/", wantErr: "missing project id"},
+		{name: "bad scheme", dsn: "ftp://abc123@host/1", wantErr: "scheme must be http or https"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newSentrySink(tt.dsn)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.endpoint != tt.wantEndpoint {
+				t.Errorf("endpoint = %s, want %s", s.endpoint, tt.wantEndpoint)
+			}
+			if s.auth != "Sentry sentry_version=7, sentry_client=sql-proxy/1.0, sentry_key=abc123" {
+				t.Errorf("auth = %s", s.auth)
+			}
+		})
+	}
+}
+
+func TestReport_Webhook(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	if err := Init(Config{Webhook: srv.URL, WebhookHeaders: map[string]string{"Authorization": "Bearer t"}, Environment: "test", Release: "1.2.3"}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	Report(Event{Kind: KindStepFailure, Message: "deadlock", Workflow: "orders", Step: "load", RequestID: "req-1"})
+	Close()
+
+	if len(c.bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(c.bodies))
+	}
+	if got := c.headers[0].Get("Authorization"); got != "Bearer t" {
+		t.Errorf("Authorization = %q", got)
+	}
+	var r map[string]any
+	if err := json.Unmarshal([]byte(c.bodies[0]), &r); err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	for key, want := range map[string]any{
+		"kind": "step_failure", "level": "error", "message": "deadlock", "workflow": "orders",
+		"step": "load", "request_id": "req-1", "environment": "test", "release": "1.2.3",
+	} {
+		if r[key] != want {
+			t.Errorf("%s = %v, want %v", key, r[key], want)
+		}
+	}
+	if id, _ := r["id"].(string); len(id) != 32 {
+		t.Errorf("id = %q, want 32 hex characters", id)
+	}
+}
+
+func TestReport_Sentry(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "http://", "http://pubkey@", 1) + "/42"
+	if err := Init(Config{SentryDSN: dsn}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	Report(Event{Kind: KindTemplateError, Level: LevelWarning, Message: "bad", Workflow: "orders", Step: "render", Tags: map[string]string{"error_code": "SQLPROXY_STEP_FAILED"}})
+	Close()
+
+	if len(c.bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(c.bodies))
+	}
+	if c.paths[0] != "/api/42/envelope/" {
+		t.Errorf("path = %s", c.paths[0])
+	}
+	if got := c.headers[0].Get("X-Sentry-Auth"); !strings.Contains(got, "sentry_key=pubkey") {
+		t.Errorf("X-Sentry-Auth = %q", got)
+	}
+	lines := strings.Split(strings.TrimSpace(c.bodies[0]), "\n")
+	if len(lines) != 3 || lines[1] != `{"type":"event"}` {
+		t.Fatalf("envelope = %q", c.bodies[0])
+	}
+	var ev sentryEvent
+	if err := json.Unmarshal([]byte(lines[2]), &ev); err != nil {
+		t.Fatalf("event: %v", err)
+	}
+	if ev.Level != "warning" || ev.Transaction != "orders" || ev.Tags["step"] != "render" || ev.Tags["kind"] != "template_error" || ev.Tags["error_code"] != "SQLPROXY_STEP_FAILED" {
+		t.Errorf("event = %+v", ev)
+	}
+	if strings.Join(ev.Fingerprint, ",") != "template_error,orders,render" {
+		t.Errorf("fingerprint = %v", ev.Fingerprint)
+	}
+	if len(ev.Exception.Values) != 1 || ev.Exception.Values[0].Value != "bad" {
+		t.Errorf("exception = %+v", ev.Exception)
+	}
+}
+
+func TestReportPanic(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	if err := Init(Config{Webhook: srv.URL}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	var repanic any
+	func() {
+		defer func() {
+			repanic = ReportPanic(recover(), Event{Workflow: "orders", Step: "script"})
+		}()
+		panic("boom")
+	}()
+	if p, ok := repanic.(*ReportedPanic); !ok || p.Value != "boom" || p.Error() != "boom" {
+		t.Fatalf("ReportPanic returned %#v, want a *ReportedPanic", repanic)
+	}
+	if again := ReportPanic(repanic, Event{}); again != repanic {
+		t.Errorf("ReportPanic(*ReportedPanic) = %#v, want it unchanged", again)
+	}
+	if got := ReportPanic(http.ErrAbortHandler, Event{}); got != http.ErrAbortHandler {
+		t.Errorf("ReportPanic(ErrAbortHandler) = %#v, want it unchanged", got)
+	}
+	Close()
+
+	if len(c.bodies) != 1 {
+		t.Fatalf("got %d requests, want the panic reported once", len(c.bodies))
+	}
+	var r report
+	if err := json.Unmarshal([]byte(c.bodies[0]), &r); err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	if r.Kind != KindPanic || r.Level != LevelFatal || r.Message != "boom" || r.Step != "script" {
+		t.Errorf("report = %+v", r)
+	}
+	if len(r.Stack) == 0 || !strings.Contains(r.Stack[0].Function, "TestReportPanic") {
+		t.Errorf("stack = %+v, want the panicking function first", r.Stack)
+	}
+}
+
+func TestReport_Disabled(t *testing.T) {
+	if err := Init(Config{}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if Enabled() {
+		t.Fatal("Enabled() = true without a destination")
+	}
+	Report(Event{Kind: KindStepFailure, Message: "ignored"})
+	if got := ReportPanic("boom", Event{}); got != "boom" {
+		t.Errorf("ReportPanic() = %#v, want the value unchanged", got)
+	}
+}
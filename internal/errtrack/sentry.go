@@ -0,0 +1,150 @@
+package errtrack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sentryClient identifies sql-proxy to Sentry
+const sentryClient = "sql-proxy/1.0"
+
+// sentrySink sends reports to a Sentry project's envelope endpoint
+type sentrySink struct {
+	dsn      string
+	endpoint string // https://host/api/<project>/envelope/
+	auth     string // X-Sentry-Auth header
+}
+
+// newSentrySink parses a DSN of the form https://<public_key>@<host>[/<path>]/<project_id>
+func newSentrySink(dsn string) (*sentrySink, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sentry dsn: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid sentry dsn: scheme must be http or https")
+	}
+	key := u.User.Username()
+	if key == "" {
+		return nil, fmt.Errorf("invalid sentry dsn: missing public key")
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	project := path[i+1:]
+	if project == "" {
+		return nil, fmt.Errorf("invalid sentry dsn: missing project id")
+	}
+	return &sentrySink{
+		dsn:      dsn,
+		endpoint: fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path[:i], project),
+		auth:     fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", sentryClient, key),
+	}, nil
+}
+
+// ValidateSentryDSN checks that dsn is a Sentry DSN
+func ValidateSentryDSN(dsn string) error {
+	_, err := newSentrySink(dsn)
+	return err
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type sentryException struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Stacktrace *struct {
+		Frames []sentryFrame `json:"frames"`
+	} `json:"stacktrace,omitempty"`
+}
+
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Transaction string            `json:"transaction,omitempty"`
+	Tags        map[string]string `json:"tags"`
+	Fingerprint []string          `json:"fingerprint,omitempty"`
+	Exception   struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+}
+
+// event converts a report to a Sentry event. Step failures are grouped by
+// workflow and step rather than by message, which often holds values.
+func (s *sentrySink) event(r *report) *sentryEvent {
+	ev := &sentryEvent{
+		EventID:     r.ID,
+		Timestamp:   r.Timestamp.Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       r.Level,
+		Logger:      "sql-proxy",
+		ServerName:  r.ServerName,
+		Environment: r.Environment,
+		Release:     r.Release,
+		Transaction: r.Workflow,
+		Tags:        map[string]string{"kind": r.Kind},
+	}
+	maps.Copy(ev.Tags, r.Tags)
+	for k, v := range map[string]string{"workflow": r.Workflow, "step": r.Step, "request_id": r.RequestID} {
+		if v != "" {
+			ev.Tags[k] = v
+		}
+	}
+	if r.Kind != KindPanic {
+		ev.Fingerprint = []string{r.Kind, r.Workflow, r.Step}
+	}
+
+	exc := sentryException{Type: r.Kind, Value: r.Message}
+	if len(r.Stack) > 0 {
+		exc.Stacktrace = &struct {
+			Frames []sentryFrame `json:"frames"`
+		}{}
+		// Sentry lists frames oldest first
+		for i := len(r.Stack) - 1; i >= 0; i-- {
+			f := r.Stack[i]
+			exc.Stacktrace.Frames = append(exc.Stacktrace.Frames, sentryFrame{
+				Function: f.Function,
+				Filename: f.File,
+				Lineno:   f.Line,
+				InApp:    strings.HasPrefix(f.Function, "sql-proxy/"),
+			})
+		}
+	}
+	ev.Exception.Values = []sentryException{exc}
+	return ev
+}
+
+func (s *sentrySink) send(ctx context.Context, r *report) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	header := map[string]string{"event_id": r.ID, "dsn": s.dsn, "sent_at": time.Now().UTC().Format(time.RFC3339Nano)}
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	if err := enc.Encode(map[string]string{"type": "event"}); err != nil {
+		return err
+	}
+	if err := enc.Encode(s.event(r)); err != nil {
+		return err
+	}
+	return post(ctx, s.endpoint, buf.Bytes(), map[string]string{
+		"Content-Type":  "application/x-sentry-envelope",
+		"X-Sentry-Auth": s.auth,
+	})
+}
@@ -0,0 +1,253 @@
+// Package graph draws the steps of a workflow as a graph: what triggers it,
+// the order steps run in and their conditions, iterate blocks, the databases
+// steps query and the hosts httpcall steps call. It renders the graph as
+// Graphviz DOT or Mermaid, so a long workflow can be reviewed at a glance.
+package graph
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"sql-proxy/internal/sqlutil"
+	"sql-proxy/internal/workflow"
+)
+
+// Node kinds
+const (
+	KindTrigger  = "trigger"
+	KindStep     = "step"
+	KindBlock    = "block"
+	KindDatabase = "database"
+	KindHTTP     = "http"
+)
+
+// Graph is the step graph of a workflow
+type Graph struct {
+	Workflow string
+	Nodes    []Node
+	Edges    []Edge
+	Groups   []Group // Iterate blocks and plain blocks, holding their nested steps
+}
+
+// Node is a trigger, step, database or HTTP target
+type Node struct {
+	ID    string
+	Kind  string
+	Label string
+	Group string // ID of the block holding the step, empty at the top level
+}
+
+// Edge connects two nodes. Dashed edges are data and resource use rather than
+// the order steps run in.
+type Edge struct {
+	From, To string
+	Label    string
+	Dashed   bool
+}
+
+// Group is the box drawn around the nested steps of a block
+type Group struct {
+	ID     string
+	Label  string
+	Parent string // ID of the enclosing group, empty at the top level
+}
+
+var (
+	stepRefRegex = regexp.MustCompile(`\bsteps\.(\w+)`)
+	hostRegex    = regexp.MustCompile(`^\w+://([^/?#{}\s]+)`)
+)
+
+// builder tracks the nodes of a graph as steps are added
+type builder struct {
+	g         *Graph
+	databases map[string]string // Database name -> node ID
+	hosts     map[string]string // HTTP target -> node ID
+	steps     map[string]string // Step name -> node ID, in the scope being built
+}
+
+// Build returns the graph of a workflow
+func Build(wf *workflow.WorkflowConfig) *Graph {
+	b := &builder{
+		g:         &Graph{Workflow: wf.Name},
+		databases: make(map[string]string),
+		hosts:     make(map[string]string),
+		steps:     make(map[string]string),
+	}
+
+	var triggers []string
+	for i, trig := range wf.Triggers {
+		id := fmt.Sprintf("trigger_%d", i)
+		b.g.Nodes = append(b.g.Nodes, Node{ID: id, Kind: KindTrigger, Label: triggerLabel(trig)})
+		triggers = append(triggers, id)
+	}
+	b.addSteps(wf.Steps, "s", "", triggers, "")
+	return b.g
+}
+
+// addSteps adds steps in the order they run, each linked from the nodes in
+// prev, and returns the IDs of the last step
+func (b *builder) addSteps(steps []workflow.StepConfig, prefix, group string, prev []string, firstLabel string) []string {
+	for i := range steps {
+		step := &steps[i]
+		id := fmt.Sprintf("%s_%d", prefix, i)
+		label := stepLabel(step, i)
+		kind := KindStep
+		if step.IsBlock() {
+			kind = KindBlock
+		}
+		b.g.Nodes = append(b.g.Nodes, Node{ID: id, Kind: kind, Label: label, Group: group})
+
+		edgeLabel := ""
+		if i == 0 {
+			edgeLabel = firstLabel
+		}
+		if step.Condition != "" {
+			edgeLabel = strings.TrimSpace(edgeLabel + " if " + step.Condition)
+		}
+		for _, from := range prev {
+			b.g.Edges = append(b.g.Edges, Edge{From: from, To: id, Label: edgeLabel})
+		}
+
+		b.addDataEdges(step, id, prev)
+		b.addResources(step, id)
+		if step.Name != "" {
+			b.steps[step.Name] = id
+		}
+
+		if step.IsBlock() {
+			groupID := "g_" + id
+			groupLabel := "block"
+			nestedLabel := ""
+			if step.Iterate != nil {
+				groupLabel = fmt.Sprintf("for each %s in %s", step.Iterate.As, step.Iterate.Over)
+				nestedLabel = "each " + step.Iterate.As
+			}
+			b.g.Groups = append(b.g.Groups, Group{ID: groupID, Label: groupLabel, Parent: group})
+
+			// Nested steps see the names of the enclosing scope, and their own
+			outer := b.steps
+			b.steps = make(map[string]string, len(outer))
+			for name, sid := range outer {
+				b.steps[name] = sid
+			}
+			last := b.addSteps(step.Steps, id, groupID, []string{id}, nestedLabel)
+			b.steps = outer
+			if step.Iterate != nil && len(step.Steps) > 0 {
+				for _, from := range last {
+					b.g.Edges = append(b.g.Edges, Edge{From: from, To: id, Label: "next", Dashed: true})
+				}
+			}
+		}
+		prev = []string{id}
+	}
+	return prev
+}
+
+// addDataEdges links the steps whose results a step reads, unless it runs
+// right after them
+func (b *builder) addDataEdges(step *workflow.StepConfig, id string, prev []string) {
+	own := *step
+	own.Steps = nil
+	data, err := yaml.Marshal(&own)
+	if err != nil {
+		return
+	}
+	var seen []string
+	for _, m := range stepRefRegex.FindAllStringSubmatch(string(data), -1) {
+		from, ok := b.steps[m[1]]
+		if !ok || slices.Contains(seen, from) || slices.Contains(prev, from) {
+			continue
+		}
+		seen = append(seen, from)
+		b.g.Edges = append(b.g.Edges, Edge{From: from, To: id, Label: "data", Dashed: true})
+	}
+}
+
+// addResources links a step to the database it uses or the host it calls
+func (b *builder) addResources(step *workflow.StepConfig, id string) {
+	if step.Database != "" {
+		dbID, ok := b.databases[step.Database]
+		if !ok {
+			dbID = fmt.Sprintf("db_%d", len(b.databases))
+			b.databases[step.Database] = dbID
+			b.g.Nodes = append(b.g.Nodes, Node{ID: dbID, Kind: KindDatabase, Label: step.Database})
+		}
+		b.g.Edges = append(b.g.Edges, Edge{From: id, To: dbID, Label: databaseAccess(step), Dashed: true})
+	}
+	if step.IsHTTPCall() && step.URL != "" {
+		target := httpTarget(step.URL)
+		hostID, ok := b.hosts[target]
+		if !ok {
+			hostID = fmt.Sprintf("http_%d", len(b.hosts))
+			b.hosts[target] = hostID
+			b.g.Nodes = append(b.g.Nodes, Node{ID: hostID, Kind: KindHTTP, Label: target})
+		}
+		method := strings.ToUpper(step.HTTPMethod)
+		if method == "" {
+			method = "GET"
+		}
+		b.g.Edges = append(b.g.Edges, Edge{From: id, To: hostID, Label: method, Dashed: true})
+	}
+}
+
+// databaseAccess describes what a step does with its database
+func databaseAccess(step *workflow.StepConfig) string {
+	switch {
+	case step.Proc != nil:
+		return "call " + step.Proc.Name
+	case step.StepType() == workflow.StepTypeQuery && sqlutil.IsWriteQuery(step.SQL):
+		return "write"
+	case step.StepType() == workflow.StepTypeQuery:
+		return "read"
+	}
+	return step.StepType()
+}
+
+// httpTarget returns the host a URL template calls, or the template itself
+// if the host is templated
+func httpTarget(url string) string {
+	if m := hostRegex.FindStringSubmatch(url); m != nil {
+		return m[1]
+	}
+	return url
+}
+
+// triggerLabel describes what starts the workflow
+func triggerLabel(t workflow.TriggerConfig) string {
+	switch t.Type {
+	case workflow.TriggerTypeHTTP:
+		return strings.Join(t.HTTPMethods(), ",") + " " + strings.Join(t.HTTPPaths(), ", ")
+	case workflow.TriggerTypeWebSocket:
+		return "websocket " + t.Path
+	case workflow.TriggerTypeCron:
+		return "cron " + t.Schedule
+	case workflow.TriggerTypeDBWatch:
+		return fmt.Sprintf("dbwatch %s.%s", t.Database, t.Table)
+	case workflow.TriggerTypeFileWatch:
+		return "filewatch " + t.Dir
+	case workflow.TriggerTypeMQTT:
+		return fmt.Sprintf("mqtt %s %s", t.Broker, strings.Join(t.Topics, ", "))
+	case workflow.TriggerTypeGRPC:
+		if t.RPC != "" {
+			return "grpc " + t.RPC
+		}
+	}
+	return t.Type
+}
+
+// stepLabel names a step and its type
+func stepLabel(step *workflow.StepConfig, index int) string {
+	name := step.Name
+	if name == "" {
+		name = fmt.Sprintf("#%d", index)
+	}
+	label := name + "\n" + step.StepType()
+	if step.OnError != "" && step.OnError != "abort" {
+		label += "\non_error: " + step.OnError
+	}
+	return label
+}
@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"sql-proxy/internal/workflow"
+)
+
+// testWorkflow returns a workflow with a condition, an iterate block, a database and an HTTP target
+func testWorkflow() *workflow.WorkflowConfig {
+	return &workflow.WorkflowConfig{
+		Name: "sync_orders",
+		Triggers: []workflow.TriggerConfig{
+			{Type: "http", Path: "/sync", Methods: []string{"POST", "PUT"}},
+			{Type: "cron", Schedule: "0 * * * *"},
+		},
+		Steps: []workflow.StepConfig{
+			{Name: "fetch", Type: "query", Database: "orders", SQL: "SELECT id FROM orders"},
+			{Name: "token", Type: "httpcall", URL: "https://auth.example.com/token", HTTPMethod: "post"},
+			{Name: "each", Condition: `steps.fetch.count > 0`, Iterate: &workflow.IterateConfig{Over: "steps.fetch.data", As: "order"}, Steps: []workflow.StepConfig{
+				{Name: "push", Type: "httpcall", URL: "https://api.example.com/orders/{{.order.id}}", HTTPMethod: "PUT", OnError: "continue"},
+				{Name: "mark", Type: "query", Database: "orders", SQL: "UPDATE orders SET synced = 1 WHERE id = @id"},
+			}},
+			{Type: "response", Template: `{"count": {{.steps.fetch.count}}}`},
+		},
+	}
+}
+
+// TestBuild verifies steps are linked in order, with conditions, blocks, data use, databases and HTTP targets
+func TestBuild(t *testing.T) {
+	g := Build(testWorkflow())
+
+	labels := make(map[string]string)
+	for _, n := range g.Nodes {
+		labels[n.ID] = n.Kind + ":" + n.Label
+	}
+	for id, want := range map[string]string{
+		"trigger_0": "trigger:POST,PUT /sync",
+		"trigger_1": "trigger:cron 0 * * * *",
+		"s_0":       "step:fetch\nquery",
+		"s_2":       "block:each\nblock",
+		"s_2_0":     "step:push\nhttpcall\non_error: continue",
+		"s_3":       "step:#3\nresponse",
+		"db_0":      "database:orders",
+		"http_0":    "http:auth.example.com",
+		"http_1":    "http:api.example.com",
+	} {
+		if labels[id] != want {
+			t.Errorf("node %s = %q, want %q", id, labels[id], want)
+		}
+	}
+	if len(labels) != 11 {
+		t.Errorf("got %d nodes, want 11 (one database node shared by both queries)", len(labels))
+	}
+
+	edges := make([]string, len(g.Edges))
+	for i, e := range g.Edges {
+		edges[i] = e.From + "->" + e.To + ":" + e.Label
+		if e.Dashed {
+			edges[i] += " (dashed)"
+		}
+	}
+	for _, want := range []string{
+		"trigger_0->s_0:",
+		"trigger_1->s_0:",
+		"s_0->db_0:read (dashed)",
+		"s_1->http_0:POST (dashed)",
+		"s_1->s_2:if steps.fetch.count > 0",
+		"s_0->s_2:data (dashed)",
+		"s_2->s_2_0:each order",
+		"s_2_1->db_0:write (dashed)",
+		"s_2_1->s_2:next (dashed)",
+		"s_2->s_3:",
+		"s_0->s_3:data (dashed)",
+	} {
+		if !slices.Contains(edges, want) {
+			t.Errorf("missing edge %q in %v", want, edges)
+		}
+	}
+
+	if len(g.Groups) != 1 || g.Groups[0].Label != "for each order in steps.fetch.data" {
+		t.Errorf("groups = %+v", g.Groups)
+	}
+}
+
+// TestRender verifies the DOT and Mermaid output and the rejection of unknown formats
+func TestRender(t *testing.T) {
+	g := Build(testWorkflow())
+
+	dot, err := g.Render(FormatDOT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`digraph "sync_orders" {`,
+		`db_0 [label="orders", shape=cylinder];`,
+		`subgraph cluster_g_s_2 {`,
+		`s_1 -> s_2 [label="if steps.fetch.count > 0"];`,
+		`s_3 [label="#3\nresponse", shape=box];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("dot output missing %q:\n%s", want, dot)
+		}
+	}
+
+	mermaid, err := g.Render(FormatMermaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"flowchart TD",
+		`db_0[("orders")]`,
+		`subgraph g_s_2["for each order in steps.fetch.data"]`,
+		`s_0 -.->|"read"| db_0`,
+		`s_2_0["push<br/>httpcall<br/>on_error: continue"]`,
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("mermaid output missing %q:\n%s", want, mermaid)
+		}
+	}
+
+	if got := mermaidQuote(`say "hi"`); got != `"say #quot;hi#quot;"` {
+		t.Errorf("mermaidQuote = %s", got)
+	}
+	if _, err := g.Render("svg"); err == nil {
+		t.Error("expected error for an unknown format")
+	}
+}
@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Formats the graph renders to
+const (
+	FormatDOT     = "dot"
+	FormatMermaid = "mermaid"
+)
+
+// Render returns the graph in format
+func (g *Graph) Render(format string) (string, error) {
+	switch format {
+	case FormatDOT:
+		return g.DOT(), nil
+	case FormatMermaid:
+		return g.Mermaid(), nil
+	}
+	return "", fmt.Errorf("unknown format '%s' (must be dot or mermaid)", format)
+}
+
+// dotShapes are the DOT node shapes of each kind
+var dotShapes = map[string]string{
+	KindTrigger:  "oval",
+	KindStep:     "box",
+	KindBlock:    "box3d",
+	KindDatabase: "cylinder",
+	KindHTTP:     "component",
+}
+
+// DOT returns the graph in Graphviz DOT
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(g.Workflow))
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [fontname=\"Helvetica\", fontsize=10];\n")
+	b.WriteString("  edge [fontname=\"Helvetica\", fontsize=9];\n")
+	g.dotGroup(&b, "", "  ")
+	for _, e := range g.Edges {
+		var attrs []string
+		if e.Label != "" {
+			attrs = append(attrs, "label="+dotQuote(e.Label))
+		}
+		if e.Dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&b, "  %s -> %s", e.From, e.To)
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotGroup writes the nodes and nested clusters of a group
+func (g *Graph) dotGroup(b *strings.Builder, group, indent string) {
+	for _, n := range g.Nodes {
+		if n.Group == group {
+			fmt.Fprintf(b, "%s%s [label=%s, shape=%s];\n", indent, n.ID, dotQuote(n.Label), dotShapes[n.Kind])
+		}
+	}
+	for _, sub := range g.Groups {
+		if sub.Parent != group {
+			continue
+		}
+		fmt.Fprintf(b, "%ssubgraph cluster_%s {\n", indent, sub.ID)
+		fmt.Fprintf(b, "%s  label=%s;\n", indent, dotQuote(sub.Label))
+		fmt.Fprintf(b, "%s  style=rounded;\n", indent)
+		g.dotGroup(b, sub.ID, indent+"  ")
+		fmt.Fprintf(b, "%s}\n", indent)
+	}
+}
+
+// dotQuote quotes a DOT string, keeping line breaks
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// mermaidShapes are the opening and closing brackets of each kind
+var mermaidShapes = map[string][2]string{
+	KindTrigger:  {"([", "])"},
+	KindStep:     {"[", "]"},
+	KindBlock:    {"[[", "]]"},
+	KindDatabase: {"[(", ")]"},
+	KindHTTP:     {"{{", "}}"},
+}
+
+// Mermaid returns the graph as a Mermaid flowchart
+func (g *Graph) Mermaid() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ntitle: %s\n---\n", g.Workflow)
+	b.WriteString("flowchart TD\n")
+	g.mermaidGroup(&b, "", "  ")
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Dashed {
+			arrow = "-.->"
+		}
+		if e.Label != "" {
+			fmt.Fprintf(&b, "  %s %s|%s| %s\n", e.From, arrow, mermaidQuote(e.Label), e.To)
+		} else {
+			fmt.Fprintf(&b, "  %s %s %s\n", e.From, arrow, e.To)
+		}
+	}
+	return b.String()
+}
+
+// mermaidGroup writes the nodes and nested subgraphs of a group
+func (g *Graph) mermaidGroup(b *strings.Builder, group, indent string) {
+	for _, n := range g.Nodes {
+		if n.Group == group {
+			shape := mermaidShapes[n.Kind]
+			fmt.Fprintf(b, "%s%s%s%s%s\n", indent, n.ID, shape[0], mermaidQuote(n.Label), shape[1])
+		}
+	}
+	for _, sub := range g.Groups {
+		if sub.Parent != group {
+			continue
+		}
+		fmt.Fprintf(b, "%ssubgraph %s[%s]\n", indent, sub.ID, mermaidQuote(sub.Label))
+		g.mermaidGroup(b, sub.ID, indent+"  ")
+		fmt.Fprintf(b, "%send\n", indent)
+	}
+}
+
+// mermaidQuote quotes a Mermaid label, escaping quotes as entities
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "\n", "<br/>")
+	return `"` + s + `"`
+}
@@ -0,0 +1,173 @@
+// Package grpc serves workflow grpc triggers with grpc-go. The service is
+// described at runtime by a Schema, so requests and responses are dynamic
+// protobuf messages; grpc-go handles framing, compression, deadlines, and
+// status trailers, and its reflection service describes the schema.
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	grpcgo "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // Accept gzip-compressed requests
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// DefaultMaxMessageBytes is the largest request message accepted when none is configured
+const DefaultMaxMessageBytes = 4 << 20
+
+// CodeFromHTTP maps an HTTP status to the closest gRPC code.
+func CodeFromHTTP(status int) codes.Code {
+	switch status {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusRequestedRangeNotSatisfiable:
+		return codes.OutOfRange
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499: // Client closed request
+		return codes.Canceled
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	switch {
+	case status >= 200 && status < 400:
+		return codes.OK
+	case status < 500:
+		return codes.InvalidArgument
+	case status < 600:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// Handler serves one method. r carries the call's metadata as headers, the
+// peer as its remote address, and the call's deadline in its context; fields
+// are the decoded request fields. It returns the status code and body of the
+// workflow response, which the server sends as an ExecuteResponse or, for an
+// error status, as the matching gRPC status.
+type Handler func(r *http.Request, fields map[string]any) (int, []byte, error)
+
+// Server serves the methods of a Schema. It is an http.Handler and must be
+// served over HTTP/2; plain-text servers need unencrypted HTTP/2 enabled in
+// their http.Protocols.
+type Server struct {
+	schema   *Schema
+	server   *grpcgo.Server
+	handlers map[string]Handler // "/package.Service/Method" -> handler
+}
+
+// NewServer creates a server for a schema's service, accepting request
+// messages up to maxMessageBytes (0 = DefaultMaxMessageBytes). Methods
+// without a handler answer Unimplemented.
+func NewServer(schema *Schema, maxMessageBytes int) *Server {
+	if maxMessageBytes <= 0 {
+		maxMessageBytes = DefaultMaxMessageBytes
+	}
+	s := &Server{
+		schema:   schema,
+		server:   grpcgo.NewServer(grpcgo.MaxRecvMsgSize(maxMessageBytes)),
+		handlers: make(map[string]Handler),
+	}
+	desc := &grpcgo.ServiceDesc{
+		ServiceName: ServiceName,
+		HandlerType: (*any)(nil),
+		Metadata:    schemaPath,
+	}
+	methods := schema.file.Services().Get(0).Methods()
+	for i := 0; i < methods.Len(); i++ {
+		m := methods.Get(i)
+		desc.Methods = append(desc.Methods, grpcgo.MethodDesc{
+			MethodName: string(m.Name()),
+			Handler:    s.method("/"+ServiceName+"/"+string(m.Name()), m.Input()),
+		})
+	}
+	s.server.RegisterService(desc, s)
+	return s
+}
+
+// Handle registers h for a method, named "/package.Service/Method". Call it
+// before serving.
+func (s *Server) Handle(method string, h Handler) {
+	s.handlers[method] = h
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.server.ServeHTTP(w, r)
+}
+
+// method returns the grpc-go handler of a method, decoding its request as a
+// dynamic message of the input type
+func (s *Server) method(path string, input protoreflect.MessageDescriptor) grpcgo.MethodHandler {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpcgo.UnaryServerInterceptor) (any, error) {
+		req := dynamicpb.NewMessage(input)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		handle := func(ctx context.Context, req any) (any, error) {
+			h, ok := s.handlers[path]
+			if !ok {
+				return nil, status.Errorf(codes.Unimplemented, "method %s not implemented", path)
+			}
+			fields, err := decode(req.(*dynamicpb.Message))
+			if err != nil {
+				return nil, err
+			}
+			statusCode, body, err := h(request(ctx, path), fields)
+			if err == nil {
+				err = HTTPError(statusCode, body)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return s.schema.response(statusCode, body)
+		}
+		if interceptor == nil {
+			return handle(ctx, req)
+		}
+		return interceptor(ctx, req, &grpcgo.UnaryServerInfo{Server: srv, FullMethod: path}, handle)
+	}
+}
+
+// request presents a call as the HTTP request it arrived on, for handlers
+// that run workflows the way HTTP triggers do
+func request(ctx context.Context, method string) *http.Request {
+	r, _ := http.NewRequestWithContext(ctx, http.MethodPost, method, http.NoBody)
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/2.0", 2, 0
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, values := range md {
+		if k == ":authority" && len(values) > 0 {
+			r.Host = values[0]
+		}
+		if strings.HasPrefix(k, ":") {
+			continue
+		}
+		for _, v := range values {
+			r.Header.Add(k, v)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r
+}
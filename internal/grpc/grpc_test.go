@@ -0,0 +1,315 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	grpcgo "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newTestServer serves s over unencrypted HTTP/2 and returns a client connection to it
+func newTestServer(t *testing.T, s *Server) (*httptest.Server, *grpcgo.ClientConn) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(s)
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	conn, err := grpcgo.NewClient(strings.TrimPrefix(srv.URL, "http://"), grpcgo.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, conn
+}
+
+// message creates a schema message and sets its fields
+func message(t *testing.T, schema *Schema, name string, fields map[string]any) *dynamicpb.Message {
+	t.Helper()
+	msg := dynamicpb.NewMessage(schema.File().Messages().ByName(protoreflect.Name(name)))
+	for field, value := range fields {
+		fd := msg.Descriptor().Fields().ByName(protoreflect.Name(field))
+		switch v := value.(type) {
+		case []int64:
+			list := msg.Mutable(fd).List()
+			for _, n := range v {
+				list.Append(protoreflect.ValueOfInt64(n))
+			}
+		case proto.Message:
+			msg.Set(fd, protoreflect.ValueOfMessage(v.ProtoReflect()))
+		default:
+			msg.Set(fd, protoreflect.ValueOf(value))
+		}
+	}
+	return msg
+}
+
+// call invokes a method and returns the ExecuteResponse's status code and body
+func call(t *testing.T, ctx context.Context, conn *grpcgo.ClientConn, schema *Schema, method string, req proto.Message) (int32, any, error) {
+	t.Helper()
+	resp := dynamicpb.NewMessage(schema.File().Messages().ByName("ExecuteResponse"))
+	if err := conn.Invoke(ctx, method, req, resp); err != nil {
+		return 0, nil, err
+	}
+	fields, err := decode(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := fields["status_code"].(int32)
+	return code, fields["body"], nil
+}
+
+// TestServer_Unary tests calls to a handler: fields, metadata, status mapping, and limits
+func TestServer_Unary(t *testing.T) {
+	schema, err := NewSchema([]RPC{{Name: "Greet", Fields: []Field{{Name: "name", Type: "string"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(schema, 64)
+	s.Handle("/sqlproxy.v1.Workflows/Greet", func(r *http.Request, fields map[string]any) (int, []byte, error) {
+		if fields["name"] == "fail" {
+			body, _ := json.Marshal(map[string]any{"error": "no access for " + r.Header.Get("X-User")})
+			return http.StatusForbidden, body, nil
+		}
+		body, _ := json.Marshal(map[string]any{"msg": "hello " + fields["name"].(string), "peer": r.RemoteAddr != ""})
+		return http.StatusCreated, body, nil
+	})
+	srv, conn := newTestServer(t, s)
+	ctx := context.Background()
+
+	code, body, err := call(t, ctx, conn, schema, "/sqlproxy.v1.Workflows/Greet", message(t, schema, "GreetRequest", map[string]any{"name": "grpc"}))
+	if err != nil || code != 201 || body.(map[string]any)["msg"] != "hello grpc" || body.(map[string]any)["peer"] != true {
+		t.Errorf("Greet = %d %v, %v", code, body, err)
+	}
+
+	// Metadata arrives as request headers; error responses become statuses
+	md := metadata.AppendToOutgoingContext(ctx, "x-user", "bob 100%")
+	_, _, err = call(t, md, conn, schema, "/sqlproxy.v1.Workflows/Greet", message(t, schema, "GreetRequest", map[string]any{"name": "fail"}))
+	if st := status.Convert(err); st.Code() != codes.PermissionDenied || st.Message() != "no access for bob 100%" {
+		t.Errorf("status %v", err)
+	}
+
+	// Execute has no handler
+	_, _, err = call(t, ctx, conn, schema, ExecuteMethod, message(t, schema, "ExecuteRequest", nil))
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("Execute status = %v, want Unimplemented", err)
+	}
+	_, _, err = call(t, ctx, conn, schema, "/sqlproxy.v1.Workflows/Greet", message(t, schema, "GreetRequest", map[string]any{"name": strings.Repeat("x", 65)}))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("oversized message status = %v, want ResourceExhausted", err)
+	}
+
+	// HTTP/1 requests are turned away before any gRPC handling
+	resp, err := http.Post(srv.URL+"/sqlproxy.v1.Workflows/Greet", "application/grpc", bytes.NewReader([]byte{0, 0, 0, 0, 0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusHTTPVersionNotSupported {
+		t.Errorf("HTTP/1 status = %d", resp.StatusCode)
+	}
+}
+
+// TestServer_Reflection tests listing the service and fetching its file with imports
+func TestServer_Reflection(t *testing.T) {
+	schema, err := NewSchema([]RPC{{Name: "GetMachine", Fields: []Field{{Name: "id", Type: "int"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(schema, 0)
+	if err := s.EnableReflection(); err != nil {
+		t.Fatal(err)
+	}
+	_, conn := newTestServer(t, s)
+
+	// One stream carries several requests
+	stream, err := reflectionv1alpha.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(req *reflectionv1alpha.ServerReflectionRequest) *reflectionv1alpha.ServerReflectionResponse {
+		t.Helper()
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := send(&reflectionv1alpha.ServerReflectionRequest{MessageRequest: &reflectionv1alpha.ServerReflectionRequest_ListServices{}})
+	var services []string
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		services = append(services, svc.GetName())
+	}
+	if !strings.Contains(strings.Join(services, ","), ServiceName) {
+		t.Errorf("list_services = %v", services)
+	}
+
+	resp = send(&reflectionv1alpha.ServerReflectionRequest{MessageRequest: &reflectionv1alpha.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "sqlproxy.v1.GetMachineRequest"}})
+	var names []string
+	for _, data := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		var fdp descriptorpb.FileDescriptorProto
+		if err := proto.Unmarshal(data, &fdp); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, fdp.GetName())
+	}
+	if strings.Join(names, ",") != "sqlproxy/v1/workflows.proto,google/protobuf/struct.proto" {
+		t.Errorf("files = %v", names)
+	}
+
+	resp = send(&reflectionv1alpha.ServerReflectionRequest{MessageRequest: &reflectionv1alpha.ServerReflectionRequest_FileByFilename{FileByFilename: "missing.proto"}})
+	if e := resp.GetErrorResponse(); codes.Code(e.GetErrorCode()) != codes.NotFound {
+		t.Errorf("error_response = %v", e)
+	}
+}
+
+// TestServer_Fields tests the fields handlers receive for typed and Execute requests
+func TestServer_Fields(t *testing.T) {
+	schema, err := NewSchema([]RPC{{Name: "FindOrders", Fields: []Field{
+		{Name: "customer_id", Type: "int"},
+		{Name: "status", Type: "string"},
+		{Name: "ids", Type: "int[]"},
+		{Name: "filter", Type: "json"},
+		{Name: "amount", Type: "decimal"},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := schema.Methods(); len(got) != 1 || got[0] != "/sqlproxy.v1.Workflows/FindOrders" {
+		t.Errorf("Methods() = %v", got)
+	}
+
+	fdp := protodesc.ToFileDescriptorProto(schema.File())
+	var fields []*descriptorpb.FieldDescriptorProto
+	for _, m := range fdp.MessageType {
+		if m.GetName() == "FindOrdersRequest" {
+			fields = m.Field
+		}
+	}
+	if len(fields) != 5 || !fields[0].GetProto3Optional() || fields[2].GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		t.Fatalf("fields = %v", fields)
+	}
+
+	received := make(map[string]map[string]any)
+	s := NewServer(schema, 0)
+	for _, method := range []string{ExecuteMethod, "/sqlproxy.v1.Workflows/FindOrders"} {
+		s.Handle(method, func(r *http.Request, fields map[string]any) (int, []byte, error) {
+			received[method] = fields
+			return http.StatusOK, []byte("ok"), nil
+		})
+	}
+	_, conn := newTestServer(t, s)
+	ctx := context.Background()
+
+	filter, _ := structpb.NewValue(map[string]any{"region": "EU"})
+	req := message(t, schema, "FindOrdersRequest", map[string]any{
+		"customer_id": int64(0), // Set to zero: present
+		"ids":         []int64{7, 9},
+		"filter":      filter,
+	})
+	code, body, err := call(t, ctx, conn, schema, "/sqlproxy.v1.Workflows/FindOrders", req)
+	if err != nil || code != 200 || body != "ok" {
+		t.Fatalf("FindOrders = %d %v, %v", code, body, err)
+	}
+	got := received["/sqlproxy.v1.Workflows/FindOrders"]
+	if len(got) != 3 || got["customer_id"] != int64(0) || got["filter"].(map[string]any)["region"] != "EU" {
+		t.Errorf("fields = %v", got)
+	}
+	if ids := got["ids"].([]any); len(ids) != 2 || ids[1] != int64(9) {
+		t.Errorf("ids = %v", got["ids"])
+	}
+
+	params, _ := structpb.NewStruct(map[string]any{"id": 42.0})
+	req = message(t, schema, "ExecuteRequest", map[string]any{"workflow": "get_machine", "params": params})
+	if _, _, err := call(t, ctx, conn, schema, ExecuteMethod, req); err != nil {
+		t.Fatal(err)
+	}
+	got = received[ExecuteMethod]
+	if got["workflow"] != "get_machine" || got["params"].(map[string]any)["id"] != 42.0 {
+		t.Errorf("Execute fields = %v", got)
+	}
+
+	if _, err := NewSchema([]RPC{{Name: "Bad", Fields: []Field{{Name: "x", Type: "json[]"}}}}); err == nil {
+		t.Error("expected error for an unsupported type")
+	}
+}
+
+// TestSchema_Response tests that JSON bodies become values and other bodies strings
+func TestSchema_Response(t *testing.T) {
+	schema, err := NewSchema(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for body, want := range map[string]any{
+		`{"success":true,"data":[{"id":1}]}`: map[string]any{"success": true, "data": []any{map[string]any{"id": 1.0}}},
+		`not json`:                           "not json",
+	} {
+		resp, err := schema.response(201, []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields, err := decode(resp.ProtoReflect())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := json.Marshal(fields["body"])
+		wantJSON, _ := json.Marshal(want)
+		if fields["status_code"] != int32(201) || !bytes.Equal(got, wantJSON) {
+			t.Errorf("%s: response = %v", body, fields)
+		}
+	}
+}
+
+// TestHTTPError tests mapping workflow error responses to gRPC statuses
+func TestHTTPError(t *testing.T) {
+	tests := []struct {
+		status  int
+		body    string
+		code    codes.Code
+		message string
+	}{
+		{200, `{}`, codes.OK, ""},
+		{400, `{"success":false,"error":"missing required parameter: id"}`, codes.InvalidArgument, "missing required parameter: id"},
+		{401, ``, codes.Unauthenticated, "Unauthorized"},
+		{404, `not here`, codes.NotFound, "not here"},
+		{422, `{}`, codes.InvalidArgument, "{}"},
+		{429, `{"error":"rate limit exceeded"}`, codes.ResourceExhausted, "rate limit exceeded"},
+		{500, `{"error":"workflow execution failed"}`, codes.Internal, "workflow execution failed"},
+		{503, ``, codes.Unavailable, "Service Unavailable"},
+	}
+	for _, tt := range tests {
+		err := HTTPError(tt.status, []byte(tt.body))
+		if tt.code == codes.OK {
+			if err != nil {
+				t.Errorf("%d: unexpected error %v", tt.status, err)
+			}
+			continue
+		}
+		s, ok := status.FromError(err)
+		if !ok || s.Code() != tt.code || s.Message() != tt.message {
+			t.Errorf("%d: got %v, want code %s %q", tt.status, err, tt.code, tt.message)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"google.golang.org/grpc/reflection"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// EnableReflection serves the gRPC server reflection protocol, which lets
+// tools such as grpcurl list and call the schema's service without the
+// .proto sources. Both v1 and v1alpha, which most deployed tools still call,
+// are served. Call it before serving.
+func (s *Server) EnableReflection() error {
+	files := new(protoregistry.Files)
+	if err := files.RegisterFile(s.schema.file); err != nil {
+		return err
+	}
+	opts := reflection.ServerOptions{Services: s.server, DescriptorResolver: resolver{files}}
+	reflectionv1.RegisterServerReflectionServer(s.server, reflection.NewServerV1(opts))
+	reflectionv1alpha.RegisterServerReflectionServer(s.server, reflection.NewServer(opts))
+	return nil
+}
+
+// resolver finds descriptors in the schema's file and then in the linked-in
+// files, which hold its imports and the reflection service's own
+type resolver struct {
+	files *protoregistry.Files
+}
+
+func (r resolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.files.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r resolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := r.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
@@ -0,0 +1,269 @@
+package grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The service workflows are exposed as
+const (
+	ServiceName   = "sqlproxy.v1.Workflows"
+	ExecuteMethod = "/" + ServiceName + "/Execute"
+	schemaPath    = "sqlproxy/v1/workflows.proto"
+)
+
+// Field is a workflow parameter exposed as a request message field.
+type Field struct {
+	Name string
+	Type string // Workflow parameter type: string, int, float, decimal, bool, date, datetime, json, or an array type
+}
+
+// RPC is a generated method whose request message has one field per
+// workflow parameter.
+type RPC struct {
+	Name   string // Method name, e.g. GetMachine
+	Fields []Field
+}
+
+// Schema describes the sqlproxy.v1.Workflows service: Execute, which runs any
+// exposed workflow by name with its parameters as a google.protobuf.Struct,
+// and one method per RPC with typed request fields. All methods return an
+// ExecuteResponse holding the workflow response's HTTP status and body.
+type Schema struct {
+	file protoreflect.FileDescriptor
+}
+
+// NewSchema builds the service for the given generated methods.
+func NewSchema(rpcs []RPC) (*Schema, error) {
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String(schemaPath),
+		Package:    proto.String("sqlproxy.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/struct.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("ExecuteRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					scalarField("workflow", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, false),
+					messageField("params", 2, ".google.protobuf.Struct", false),
+				},
+			},
+			{
+				Name: proto.String("ExecuteResponse"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					scalarField("status_code", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, false),
+					messageField("body", 2, ".google.protobuf.Value", false),
+				},
+			},
+		},
+	}
+	service := &descriptorpb.ServiceDescriptorProto{
+		Name:   proto.String("Workflows"),
+		Method: []*descriptorpb.MethodDescriptorProto{method("Execute", "ExecuteRequest")},
+	}
+
+	for _, rpc := range rpcs {
+		msg := &descriptorpb.DescriptorProto{Name: proto.String(rpc.Name + "Request")}
+		for i, f := range rpc.Fields {
+			field, err := paramField(f, int32(i+1))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rpc.Name, err)
+			}
+			if field.Proto3Optional != nil {
+				// Optional scalars get a synthetic oneof so that unset fields stay unset
+				field.OneofIndex = proto.Int32(int32(len(msg.OneofDecl)))
+				msg.OneofDecl = append(msg.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + f.Name)})
+			}
+			msg.Field = append(msg.Field, field)
+		}
+		file.MessageType = append(file.MessageType, msg)
+		service.Method = append(service.Method, method(rpc.Name, rpc.Name+"Request"))
+	}
+	file.Service = []*descriptorpb.ServiceDescriptorProto{service}
+
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{file: fd}, nil
+}
+
+func scalarField(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, repeated bool) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    label.Enum(),
+		Type:     typ.Enum(),
+	}
+}
+
+func messageField(name string, number int32, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	f := scalarField(name, number, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated)
+	f.TypeName = proto.String(typeName)
+	return f
+}
+
+func method(name, input string) *descriptorpb.MethodDescriptorProto {
+	return &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String(name),
+		InputType:  proto.String(".sqlproxy.v1." + input),
+		OutputType: proto.String(".sqlproxy.v1.ExecuteResponse"),
+	}
+}
+
+// paramField maps a workflow parameter type to a field. Decimals, dates, and
+// datetimes are strings, as in JSON request bodies; json parameters are
+// google.protobuf.Value.
+func paramField(f Field, number int32) (*descriptorpb.FieldDescriptorProto, error) {
+	typ := strings.ToLower(f.Type)
+	repeated := strings.HasSuffix(typ, "[]")
+	typ = strings.TrimSuffix(typ, "[]")
+
+	var pt descriptorpb.FieldDescriptorProto_Type
+	switch typ {
+	case "", "string", "decimal", "date", "datetime":
+		pt = descriptorpb.FieldDescriptorProto_TYPE_STRING
+	case "int", "integer":
+		pt = descriptorpb.FieldDescriptorProto_TYPE_INT64
+	case "float", "double":
+		pt = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+	case "bool", "boolean":
+		pt = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+	case "json":
+		if repeated {
+			return nil, fmt.Errorf("unsupported parameter type %q", f.Type)
+		}
+		return messageField(f.Name, number, ".google.protobuf.Value", false), nil
+	default:
+		return nil, fmt.Errorf("unsupported parameter type %q", f.Type)
+	}
+	field := scalarField(f.Name, number, pt, repeated)
+	if !repeated {
+		field.Proto3Optional = proto.Bool(true)
+	}
+	return field, nil
+}
+
+// File returns the service's file descriptor.
+func (s *Schema) File() protoreflect.FileDescriptor {
+	return s.file
+}
+
+// Methods returns the paths of the generated methods, without Execute.
+func (s *Schema) Methods() []string {
+	var paths []string
+	methods := s.file.Services().Get(0).Methods()
+	for i := 1; i < methods.Len(); i++ {
+		paths = append(paths, "/"+ServiceName+"/"+string(methods.Get(i).Name()))
+	}
+	return paths
+}
+
+// decode converts a request message into its set fields. Struct and Value
+// fields decode to their JSON equivalents; for Execute, params is a map.
+func decode(msg protoreflect.Message) (map[string]any, error) {
+	fields := make(map[string]any)
+	var err error
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		var value any
+		if value, err = fieldValue(fd, v); err != nil {
+			return false
+		}
+		fields[string(fd.Name())] = value
+		return true
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", msg.Descriptor().Name(), err)
+	}
+	return fields, nil
+}
+
+func fieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) (any, error) {
+	if fd.IsList() {
+		list := v.List()
+		values := make([]any, list.Len())
+		for i := range values {
+			values[i] = list.Get(i).Interface()
+		}
+		return values, nil
+	}
+	if fd.Kind() != protoreflect.MessageKind {
+		return v.Interface(), nil
+	}
+
+	// Struct and Value arrive as dynamic messages; decode them into the well-known types
+	data, err := proto.Marshal(v.Message().Interface())
+	if err != nil {
+		return nil, err
+	}
+	switch fd.Message().FullName() {
+	case "google.protobuf.Struct":
+		var st structpb.Struct
+		if err := proto.Unmarshal(data, &st); err != nil {
+			return nil, err
+		}
+		return st.AsMap(), nil
+	default:
+		var val structpb.Value
+		if err := proto.Unmarshal(data, &val); err != nil {
+			return nil, err
+		}
+		return val.AsInterface(), nil
+	}
+}
+
+// response builds an ExecuteResponse. A JSON body becomes its value; any
+// other body a string.
+func (s *Schema) response(statusCode int, body []byte) (proto.Message, error) {
+	var value *structpb.Value
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		if value, err = structpb.NewValue(decoded); err != nil {
+			return nil, err
+		}
+	} else {
+		value = structpb.NewStringValue(string(body))
+	}
+	msg := dynamicpb.NewMessage(s.file.Messages().ByName("ExecuteResponse"))
+	fields := msg.Descriptor().Fields()
+	msg.Set(fields.ByName("status_code"), protoreflect.ValueOfInt32(int32(statusCode)))
+	msg.Set(fields.ByName("body"), protoreflect.ValueOfMessage(value.ProtoReflect()))
+	return msg, nil
+}
+
+// HTTPError converts an error response of a workflow into a status error with the
+// matching code, or returns nil for a successful one. The message is the
+// body's "error" field, or the body itself when it is not such JSON.
+func HTTPError(statusCode int, body []byte) error {
+	if statusCode < http.StatusBadRequest {
+		return nil
+	}
+	var resp struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &resp) == nil && resp.Error != "" {
+		message = resp.Error
+	}
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	return status.Error(CodeFromHTTP(statusCode), message)
+}
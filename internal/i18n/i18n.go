@@ -0,0 +1,247 @@
+// Package i18n holds per-language message catalogs for localizing response
+// text, and picks a catalog language from an Accept-Language header.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"sql-proxy/internal/locale"
+)
+
+// Catalog looks up messages by key and language. Lookups fall back from a
+// regional language to its base language and then to the default language
+// ("fr-CA" -> "fr" -> "en"), so a catalog only needs the strings that differ.
+type Catalog struct {
+	def      string
+	messages map[string]map[string]string // language -> key -> message
+	langs    []string                     // Configured languages, sorted
+}
+
+// New creates a catalog. languages maps a language tag to its messages; nested
+// maps are flattened into dotted keys (errors: {not_found: ...} is
+// "errors.not_found"). def must be one of the languages.
+func New(def string, languages map[string]map[string]any) (*Catalog, error) {
+	if len(languages) == 0 {
+		return nil, fmt.Errorf("at least one language is required")
+	}
+
+	c := &Catalog{messages: make(map[string]map[string]string, len(languages))}
+	for tag, msgs := range languages {
+		lang, err := normalize(tag)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := c.messages[lang]; dup {
+			return nil, fmt.Errorf("language %q is configured more than once", lang)
+		}
+		flat := make(map[string]string)
+		if err := flatten("", msgs, flat); err != nil {
+			return nil, fmt.Errorf("language %q: %w", lang, err)
+		}
+		c.messages[lang] = flat
+		c.langs = append(c.langs, lang)
+	}
+	sort.Strings(c.langs)
+
+	if def == "" {
+		return nil, fmt.Errorf("default language is required")
+	}
+	lang, err := normalize(def)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := c.messages[lang]; !ok {
+		return nil, fmt.Errorf("default language %q has no messages", def)
+	}
+	c.def = lang
+	return c, nil
+}
+
+// LoadDir reads one YAML file of messages per language from dir, named by the
+// language tag (fr.yaml, pt-BR.yml)
+func LoadDir(dir string) (map[string]map[string]any, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	languages := make(map[string]map[string]any)
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var msgs map[string]any
+		if err := yaml.Unmarshal(data, &msgs); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		tag := strings.TrimSuffix(e.Name(), ext)
+		if _, dup := languages[tag]; dup {
+			return nil, fmt.Errorf("%s: language %q is already loaded", e.Name(), tag)
+		}
+		languages[tag] = msgs
+	}
+	return languages, nil
+}
+
+// Default returns the default language
+func (c *Catalog) Default() string {
+	return c.def
+}
+
+// Languages returns the configured languages, sorted
+func (c *Catalog) Languages() []string {
+	return c.langs
+}
+
+// Has reports whether key is defined in any language
+func (c *Catalog) Has(key string) bool {
+	for _, msgs := range c.messages {
+		if _, ok := msgs[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the message for key in lang, following the fallback chain.
+// An empty or invalid lang uses the default language.
+func (c *Catalog) Lookup(key, lang string) (string, bool) {
+	for _, l := range c.chain(lang) {
+		if msg, ok := c.messages[l][key]; ok {
+			return msg, true
+		}
+	}
+	return "", false
+}
+
+// Translate returns the message for key in lang, formatted with args as by
+// fmt.Sprintf when there are any. A key missing from every language in the
+// fallback chain is returned as-is, so a typo shows up in the response instead
+// of failing it.
+func (c *Catalog) Translate(key, lang string, args ...any) string {
+	msg, ok := c.Lookup(key, lang)
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// chain returns the languages to try for lang, most specific first and ending
+// with the default language
+func (c *Catalog) chain(lang string) []string {
+	return append(candidates(lang), c.def)
+}
+
+// candidates returns lang and its base languages ("zh-Hant-TW", "zh-Hant",
+// "zh"), or nothing for an empty or invalid tag
+func candidates(lang string) []string {
+	if lang == "" {
+		return nil
+	}
+	l, err := normalize(lang)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for {
+		out = append(out, l)
+		i := strings.LastIndexByte(l, '-')
+		if i < 0 {
+			return out
+		}
+		l = l[:i]
+	}
+}
+
+// Negotiate picks the configured language that best matches an
+// Accept-Language header, honouring q-values and falling back to base
+// languages ("de-AT" matches "de"). Returns the default language when nothing
+// matches.
+func (c *Catalog) Negotiate(header string) string {
+	type pref struct {
+		lang string
+		q    float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if q <= 0 {
+			continue
+		}
+		prefs = append(prefs, pref{lang: tag, q: q})
+	}
+	// Stable so equal q-values keep the client's order
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	for _, p := range prefs {
+		if p.lang == "*" {
+			return c.def
+		}
+		for _, l := range candidates(p.lang) {
+			if _, ok := c.messages[l]; ok {
+				return l
+			}
+		}
+	}
+	return c.def
+}
+
+// normalize canonicalizes a language tag ("pt_br" -> "pt-BR")
+func normalize(tag string) (string, error) {
+	t, err := locale.Parse(tag)
+	if err != nil {
+		return "", fmt.Errorf("invalid language %q", tag)
+	}
+	return t.String(), nil
+}
+
+// flatten copies messages into out under dotted keys
+func flatten(prefix string, msgs map[string]any, out map[string]string) error {
+	for k, v := range msgs {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch val := v.(type) {
+		case string:
+			out[key] = val
+		case map[string]any:
+			if err := flatten(key, val, out); err != nil {
+				return err
+			}
+		case nil:
+			return fmt.Errorf("message %q is empty", key)
+		case []any:
+			return fmt.Errorf("message %q must be a string, got a list", key)
+		default:
+			out[key] = fmt.Sprint(val)
+		}
+	}
+	return nil
+}
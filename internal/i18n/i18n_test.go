@@ -0,0 +1,156 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	c, err := New("en", map[string]map[string]any{
+		"en": {
+			"errors":   map[string]any{"not_found": "Not found", "forbidden": "Forbidden"},
+			"greeting": "Hello, %s",
+			"limit":    10,
+		},
+		"fr": {
+			"errors":   map[string]any{"not_found": "Introuvable"},
+			"greeting": "Bonjour, %s",
+		},
+		"fr-CA": {
+			"errors": map[string]any{"not_found": "Pas trouvé"},
+		},
+		"pt_BR": {
+			"greeting": "Olá, %s",
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		def       string
+		languages map[string]map[string]any
+		wantErr   string
+	}{
+		{name: "no languages", def: "en", wantErr: "at least one language"},
+		{name: "no default", languages: map[string]map[string]any{"en": {"a": "b"}}, wantErr: "default language is required"},
+		{name: "default without messages", def: "de", languages: map[string]map[string]any{"en": {"a": "b"}}, wantErr: `default language "de" has no messages`},
+		{name: "invalid tag", def: "en", languages: map[string]map[string]any{"en": {"a": "b"}, "not a tag": {"a": "b"}}, wantErr: "invalid language"},
+		{name: "duplicate after normalizing", def: "en", languages: map[string]map[string]any{"en": {"a": "b"}, "pt_BR": {"a": "b"}, "pt-br": {"a": "b"}}, wantErr: "more than once"},
+		{name: "empty message", def: "en", languages: map[string]map[string]any{"en": {"a": nil}}, wantErr: `message "a" is empty`},
+		{name: "list message", def: "en", languages: map[string]map[string]any{"en": {"a": []any{"x"}}}, wantErr: "got a list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.def, tt.languages)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	c := testCatalog(t)
+
+	tests := []struct {
+		name string
+		key  string
+		lang string
+		args []any
+		want string
+	}{
+		{"exact language", "errors.not_found", "fr", nil, "Introuvable"},
+		{"regional override", "errors.not_found", "fr-CA", nil, "Pas trouvé"},
+		{"regional falls back to base", "greeting", "fr-CA", []any{"Ana"}, "Bonjour, Ana"},
+		{"base falls back to default", "errors.forbidden", "fr-CA", nil, "Forbidden"},
+		{"unknown language uses default", "errors.not_found", "de", nil, "Not found"},
+		{"empty language uses default", "errors.not_found", "", nil, "Not found"},
+		{"invalid language uses default", "errors.not_found", "??", nil, "Not found"},
+		{"underscore tag", "greeting", "pt_BR", []any{"Ana"}, "Olá, Ana"},
+		{"non-string message", "limit", "en", nil, "10"},
+		{"missing key", "errors.gone", "fr", nil, "errors.gone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Translate(tt.key, tt.lang, tt.args...); got != tt.want {
+				t.Errorf("Translate(%q, %q) = %q, want %q", tt.key, tt.lang, got, tt.want)
+			}
+		})
+	}
+
+	if !c.Has("errors.forbidden") || c.Has("errors") {
+		t.Error("Has should report flattened message keys only")
+	}
+	if got := strings.Join(c.Languages(), ","); got != "en,fr,fr-CA,pt-BR" {
+		t.Errorf("Languages() = %s", got)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	c := testCatalog(t)
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"fr", "fr"},
+		{"fr-CA,fr;q=0.9,en;q=0.8", "fr-CA"},
+		{"fr-BE", "fr"},
+		{"de-DE,de;q=0.9,fr;q=0.5", "fr"},
+		{"en;q=0.5, fr;q=0.8", "fr"},
+		{"pt-br", "pt-BR"},
+		{"fr;q=0, en", "en"},
+		{"ja, *;q=0.1", "en"},
+		{"fr;q=abc, pt-BR", "pt-BR"},
+	}
+	for _, tt := range tests {
+		if got := c.Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"en.yaml":   "errors:\n  not_found: Not found\n",
+		"fr-CA.yml": "errors:\n  not_found: Pas trouvé\n",
+		"notes.txt": "ignored",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	languages, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	if len(languages) != 2 {
+		t.Fatalf("expected 2 languages, got %v", languages)
+	}
+	c, err := New("en", languages)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := c.Translate("errors.not_found", "fr-CA"); got != "Pas trouvé" {
+		t.Errorf("got %q", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "de.yaml"), []byte("- not a map\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDir(dir); err == nil || !strings.Contains(err.Error(), "de.yaml") {
+		t.Errorf("expected error naming de.yaml, got %v", err)
+	}
+}
@@ -0,0 +1,2023 @@
+// Package jq evaluates a subset of the jq language against decoded JSON values.
+// It covers what templates need to reshape JSON: paths (.a.b, .[0], .[], .[1:3],
+// ..), pipes and commas, array and object construction, arithmetic, comparisons,
+// and/or/not, //, if/elif/else, ? and the common builtins (map, select, sort_by,
+// group_by, to_entries, ...). Variables, reduce/foreach, string interpolation,
+// path updates (|=, del) and user-defined functions are not supported and fail
+// to parse.
+package jq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDepth bounds recursion when evaluating deeply nested values with ..
+const maxDepth = 1000
+
+// Query is a parsed jq program.
+type Query struct {
+	root node
+}
+
+// Parse parses a jq program.
+func Parse(src string) (*Query, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at offset %d", t, t.pos)
+	}
+	return &Query{root: root}, nil
+}
+
+// Run evaluates the query against v and returns every output. Values of any Go
+// type are first converted to their JSON form (maps, slices, numbers, strings).
+func (q *Query) Run(v any) ([]any, error) {
+	in, err := normalize(v)
+	if err != nil {
+		return nil, err
+	}
+	return q.root.eval(in)
+}
+
+// ============================================================================
+// Lexer
+// ============================================================================
+
+type tokKind int
+
+const (
+	tokEOF    tokKind = iota
+	tokOp             // punctuation and operators
+	tokIdent          // names and keywords
+	tokField          // .name
+	tokString         // "..."
+	tokNumber
+)
+
+type token struct {
+	kind tokKind
+	text string
+	val  any // decoded string or number
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators, longest first so "//" wins over "/"
+var operators = []string{"..", "//", "==", "!=", "<=", ">=", "|", ",", "<", ">", "+", "-", "*", "/", "%", "(", ")", "[", "]", "{", "}", ":", ";", "?", "."}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			end, s, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{kind: tokString, text: src[i:end], val: s, pos: i})
+			i = end
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.' || src[i] == 'e' || src[i] == 'E' ||
+				((src[i] == '+' || src[i] == '-') && (src[i-1] == 'e' || src[i-1] == 'E'))) {
+				i++
+			}
+			f, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at offset %d", src[start:i], start)
+			}
+			toks = append(toks, token{kind: tokNumber, text: src[start:i], val: number(f), pos: start})
+		case c == '.' && i+1 < len(src) && isIdentStart(src[i+1]):
+			start := i
+			i++
+			for i < len(src) && isIdentChar(src[i]) {
+				i++
+			}
+			toks = append(toks, token{kind: tokField, text: src[start:i], val: src[start+1 : i], pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentChar(src[i]) {
+				i++
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[start:i], pos: start})
+		case c == '$':
+			return nil, fmt.Errorf("variables are not supported (offset %d)", i)
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					toks = append(toks, token{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString reads the JSON string starting at src[start] and returns the offset after it
+func lexString(src string, start int) (int, string, error) {
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if i+1 < len(src) && src[i+1] == '(' {
+				return 0, "", fmt.Errorf("string interpolation is not supported (offset %d)", i)
+			}
+			i++
+		case '"':
+			var s string
+			if err := json.Unmarshal([]byte(src[start:i+1]), &s); err != nil {
+				return 0, "", fmt.Errorf("invalid string at offset %d: %v", start, err)
+			}
+			return i + 1, s, nil
+		}
+	}
+	return 0, "", fmt.Errorf("unterminated string at offset %d", start)
+}
+
+// ============================================================================
+// Parser
+// ============================================================================
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(text string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == text
+}
+
+func (p *parser) isKeyword(text string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && t.text == text
+}
+
+func (p *parser) expectOp(text string) error {
+	if !p.isOp(text) {
+		t := p.peek()
+		return fmt.Errorf("expected %q, got %s at offset %d", text, t, t.pos)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) expectKeyword(text string) error {
+	if !p.isKeyword(text) {
+		t := p.peek()
+		return fmt.Errorf("expected %q, got %s at offset %d", text, t, t.pos)
+	}
+	p.next()
+	return nil
+}
+
+// parsePipe parses a | b; noComma stops at commas (object values)
+func (p *parser) parsePipe(noComma bool) (node, error) {
+	left, err := p.parseComma(noComma)
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("|") {
+		p.next()
+		right, err := p.parsePipe(noComma)
+		if err != nil {
+			return nil, err
+		}
+		return &pipeNode{left, right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseComma(noComma bool) (node, error) {
+	left, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+	for !noComma && p.isOp(",") {
+		p.next()
+		right, err := p.parseAlt()
+		if err != nil {
+			return nil, err
+		}
+		left = &commaNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAlt() (node, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("//") {
+		p.next()
+		right, err := p.parseAlt()
+		if err != nil {
+			return nil, err
+		}
+		return &altNode{left, right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.isOp(op) {
+			p.next()
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &binaryNode{op, left, right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op, left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") || p.isOp("%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op, left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negNode{operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokField:
+			p.next()
+			n = &indexNode{target: n, key: &literalNode{t.val}}
+		case p.isOp(".") && p.toks[p.pos+1].kind == tokString:
+			p.next()
+			n = &indexNode{target: n, key: &literalNode{p.next().val}}
+		case p.isOp(".") && p.toks[p.pos+1].kind == tokOp && p.toks[p.pos+1].text == "[":
+			p.next()
+		case p.isOp("["):
+			if n, err = p.parseBracket(n); err != nil {
+				return nil, err
+			}
+		case p.isOp("?"):
+			p.next()
+			n = &tryNode{n}
+		default:
+			return n, nil
+		}
+	}
+}
+
+// parseBracket parses [], [i], [a:b] applied to target
+func (p *parser) parseBracket(target node) (node, error) {
+	p.next() // [
+	if p.isOp("]") {
+		p.next()
+		return &iterateNode{target}, nil
+	}
+	var from, to node
+	var err error
+	if !p.isOp(":") {
+		if from, err = p.parsePipe(false); err != nil {
+			return nil, err
+		}
+	}
+	if p.isOp(":") {
+		p.next()
+		if !p.isOp("]") {
+			if to, err = p.parsePipe(false); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expectOp("]"); err != nil {
+			return nil, err
+		}
+		return &sliceNode{target: target, from: from, to: to}, nil
+	}
+	if err := p.expectOp("]"); err != nil {
+		return nil, err
+	}
+	return &indexNode{target: target, key: from}, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber, tokString:
+		return &literalNode{t.val}, nil
+	case tokField:
+		return &indexNode{target: identity{}, key: &literalNode{t.val}}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		case "null":
+			return &literalNode{nil}, nil
+		case "if":
+			return p.parseIf()
+		case "and", "or", "then", "elif", "else", "end":
+			return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+		case "reduce", "foreach", "def", "as", "label", "import", "include", "try", "catch":
+			return nil, fmt.Errorf("%q is not supported (offset %d)", t.text, t.pos)
+		}
+		return p.parseCall(t)
+	case tokOp:
+		switch t.text {
+		case ".":
+			if p.peek().kind == tokString {
+				return &indexNode{target: identity{}, key: &literalNode{p.next().val}}, nil
+			}
+			return identity{}, nil
+		case "..":
+			return recurseNode{}, nil
+		case "(":
+			n, err := p.parsePipe(false)
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expectOp(")")
+		case "[":
+			if p.isOp("]") {
+				p.next()
+				return &arrayNode{}, nil
+			}
+			n, err := p.parsePipe(false)
+			if err != nil {
+				return nil, err
+			}
+			return &arrayNode{n}, p.expectOp("]")
+		case "{":
+			return p.parseObject()
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s at offset %d", t, t.pos)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	var args []node
+	if p.isOp("(") {
+		p.next()
+		for {
+			arg, err := p.parsePipe(false)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if !p.isOp(";") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+	}
+	key := fmt.Sprintf("%s/%d", name.text, len(args))
+	if _, ok := builtins[key]; !ok {
+		return nil, fmt.Errorf("unknown function %s at offset %d", key, name.pos)
+	}
+	return &callNode{name: key, args: args}, nil
+}
+
+func (p *parser) parseIf() (node, error) {
+	cond, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+	n := &ifNode{cond: cond, then: then, els: identity{}}
+	switch {
+	case p.isKeyword("elif"):
+		p.next()
+		if n.els, err = p.parseIf(); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case p.isKeyword("else"):
+		p.next()
+		if n.els, err = p.parsePipe(false); err != nil {
+			return nil, err
+		}
+	}
+	return n, p.expectKeyword("end")
+}
+
+// parseObject parses {a: f, "b": g, (k): v, c}
+func (p *parser) parseObject() (node, error) {
+	obj := &objectNode{}
+	for !p.isOp("}") {
+		var entry objectEntry
+		t := p.next()
+		switch {
+		case t.kind == tokIdent || t.kind == tokString:
+			name, _ := t.val.(string)
+			if t.kind == tokIdent {
+				name = t.text
+			}
+			entry.key = &literalNode{name}
+			entry.value = &indexNode{target: identity{}, key: &literalNode{name}}
+		case t.kind == tokOp && t.text == "(":
+			key, err := p.parsePipe(false)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			entry.key = key
+		default:
+			return nil, fmt.Errorf("unexpected %s in object at offset %d", t, t.pos)
+		}
+		if p.isOp(":") {
+			p.next()
+			value, err := p.parseObjectValue()
+			if err != nil {
+				return nil, err
+			}
+			entry.value = value
+		} else if entry.value == nil {
+			return nil, fmt.Errorf("expected \":\" after computed key at offset %d", p.peek().pos)
+		}
+		obj.entries = append(obj.entries, entry)
+		if !p.isOp(",") {
+			break
+		}
+		p.next()
+	}
+	return obj, p.expectOp("}")
+}
+
+// parseObjectValue parses an object value: a pipe without top-level commas
+func (p *parser) parseObjectValue() (node, error) {
+	return p.parsePipe(true)
+}
+
+// ============================================================================
+// Evaluation
+// ============================================================================
+
+type node interface {
+	eval(in any) ([]any, error)
+}
+
+type identity struct{}
+
+func (identity) eval(in any) ([]any, error) { return []any{in}, nil }
+
+type recurseNode struct{}
+
+func (recurseNode) eval(in any) ([]any, error) {
+	var out []any
+	return out, recurse(in, 0, &out)
+}
+
+func recurse(v any, depth int, out *[]any) error {
+	if depth > maxDepth {
+		return errors.New("value is nested too deeply")
+	}
+	*out = append(*out, v)
+	switch x := v.(type) {
+	case []any:
+		for _, item := range x {
+			if err := recurse(item, depth+1, out); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(x) {
+			if err := recurse(x[k], depth+1, out); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type literalNode struct{ value any }
+
+func (n *literalNode) eval(any) ([]any, error) { return []any{n.value}, nil }
+
+type pipeNode struct{ left, right node }
+
+func (n *pipeNode) eval(in any) ([]any, error) {
+	lefts, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, v := range lefts {
+		rights, err := n.right.eval(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rights...)
+	}
+	return out, nil
+}
+
+type commaNode struct{ left, right node }
+
+func (n *commaNode) eval(in any) ([]any, error) {
+	lefts, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	rights, err := n.right.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	return append(lefts, rights...), nil
+}
+
+// altNode is a // b: the truthy outputs of a, or else the outputs of b
+type altNode struct{ left, right node }
+
+func (n *altNode) eval(in any) ([]any, error) {
+	lefts, err := n.left.eval(in)
+	var out []any
+	if err == nil {
+		for _, v := range lefts {
+			if truthy(v) {
+				out = append(out, v)
+			}
+		}
+	}
+	if len(out) > 0 {
+		return out, nil
+	}
+	return n.right.eval(in)
+}
+
+type logicNode struct {
+	and         bool
+	left, right node
+}
+
+func (n *logicNode) eval(in any) ([]any, error) {
+	lefts, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, l := range lefts {
+		if n.and && !truthy(l) || !n.and && truthy(l) {
+			out = append(out, truthy(l))
+			continue
+		}
+		rights, err := n.right.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rights {
+			out = append(out, truthy(r))
+		}
+	}
+	return out, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) eval(in any) ([]any, error) {
+	rights, err := n.right.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	lefts, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, r := range rights {
+		for _, l := range lefts {
+			v, err := binary(n.op, l, r)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+type negNode struct{ operand node }
+
+func (n *negNode) eval(in any) ([]any, error) {
+	vals, err := n.operand.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]any, len(vals))
+	for i, v := range vals {
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("%s cannot be negated", describe(v))
+		}
+		out[i] = number(-f)
+	}
+	return out, nil
+}
+
+type indexNode struct{ target, key node }
+
+func (n *indexNode) eval(in any) ([]any, error) {
+	targets, err := n.target.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, t := range targets {
+		keys, err := n.key.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			v, err := index(t, k)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+func index(v, key any) (any, error) {
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		if k, ok := key.(string); ok {
+			return x[k], nil
+		}
+	case []any:
+		if f, ok := toFloat(key); ok {
+			i := int(math.Floor(f))
+			if i < 0 {
+				i += len(x)
+			}
+			if i < 0 || i >= len(x) {
+				return nil, nil
+			}
+			return x[i], nil
+		}
+	}
+	return nil, fmt.Errorf("cannot index %s with %s", typeName(v), describe(key))
+}
+
+type sliceNode struct{ target, from, to node }
+
+func (n *sliceNode) eval(in any) ([]any, error) {
+	targets, err := n.target.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	bound := func(b node) (any, error) {
+		if b == nil {
+			return nil, nil
+		}
+		vals, err := b.eval(in)
+		if err != nil || len(vals) == 0 {
+			return nil, err
+		}
+		return vals[0], nil
+	}
+	from, err := bound(n.from)
+	if err != nil {
+		return nil, err
+	}
+	to, err := bound(n.to)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, t := range targets {
+		v, err := slice(t, from, to)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func slice(v, from, to any) (any, error) {
+	var length int
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		length = len(x)
+	case string:
+		length = utf8.RuneCountInString(x)
+	default:
+		return nil, fmt.Errorf("cannot slice %s", typeName(v))
+	}
+	clamp := func(b any, def int) (int, error) {
+		if b == nil {
+			return def, nil
+		}
+		f, ok := toFloat(b)
+		if !ok {
+			return 0, fmt.Errorf("slice bounds must be numbers, got %s", describe(b))
+		}
+		i := int(math.Floor(f))
+		if i < 0 {
+			i += length
+		}
+		return max(0, min(i, length)), nil
+	}
+	start, err := clamp(from, 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := clamp(to, length)
+	if err != nil {
+		return nil, err
+	}
+	end = max(start, end)
+	if s, ok := v.(string); ok {
+		return string([]rune(s)[start:end]), nil
+	}
+	return append([]any{}, v.([]any)[start:end]...), nil
+}
+
+type iterateNode struct{ target node }
+
+func (n *iterateNode) eval(in any) ([]any, error) {
+	targets, err := n.target.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, t := range targets {
+		switch x := t.(type) {
+		case []any:
+			out = append(out, x...)
+		case map[string]any:
+			for _, k := range sortedKeys(x) {
+				out = append(out, x[k])
+			}
+		default:
+			return nil, fmt.Errorf("cannot iterate over %s", describe(t))
+		}
+	}
+	return out, nil
+}
+
+// tryNode is f?: the outputs of f, or none if it fails
+type tryNode struct{ body node }
+
+func (n *tryNode) eval(in any) ([]any, error) {
+	out, err := n.body.eval(in)
+	if err != nil {
+		return nil, nil
+	}
+	return out, nil
+}
+
+type arrayNode struct{ body node }
+
+func (n *arrayNode) eval(in any) ([]any, error) {
+	if n.body == nil {
+		return []any{[]any{}}, nil
+	}
+	items, err := n.body.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []any{}
+	}
+	return []any{items}, nil
+}
+
+type objectEntry struct{ key, value node }
+
+type objectNode struct{ entries []objectEntry }
+
+// eval builds one object per combination of the entries' outputs
+func (n *objectNode) eval(in any) ([]any, error) {
+	objs := []map[string]any{{}}
+	for _, e := range n.entries {
+		keys, err := e.key.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		values, err := e.value.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		var next []map[string]any
+		for _, obj := range objs {
+			for _, k := range keys {
+				name, ok := k.(string)
+				if !ok {
+					return nil, fmt.Errorf("object keys must be strings, got %s", describe(k))
+				}
+				for _, v := range values {
+					o := make(map[string]any, len(obj)+1)
+					for ok, ov := range obj {
+						o[ok] = ov
+					}
+					o[name] = v
+					next = append(next, o)
+				}
+			}
+		}
+		objs = next
+	}
+	out := make([]any, len(objs))
+	for i, o := range objs {
+		out[i] = o
+	}
+	return out, nil
+}
+
+type ifNode struct{ cond, then, els node }
+
+func (n *ifNode) eval(in any) ([]any, error) {
+	conds, err := n.cond.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, c := range conds {
+		branch := n.els
+		if truthy(c) {
+			branch = n.then
+		}
+		vals, err := branch.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vals...)
+	}
+	return out, nil
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n *callNode) eval(in any) ([]any, error) {
+	out, err := builtins[n.name](in, n.args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name[:strings.IndexByte(n.name, '/')], err)
+	}
+	return out, nil
+}
+
+// ============================================================================
+// Builtins
+// ============================================================================
+
+type builtin func(in any, args []node) ([]any, error)
+
+// value adapts a function of the input to a builtin with one output
+func value(f func(v any) (any, error)) builtin {
+	return func(in any, _ []node) ([]any, error) {
+		v, err := f(in)
+		if err != nil {
+			return nil, err
+		}
+		return []any{v}, nil
+	}
+}
+
+// withArg adapts a function of the input and each output of the argument
+func withArg(f func(v, arg any) (any, error)) builtin {
+	return func(in any, args []node) ([]any, error) {
+		argVals, err := args[0].eval(in)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, 0, len(argVals))
+		for _, a := range argVals {
+			v, err := f(in, a)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+}
+
+// arrayFunc adapts a function of an array input
+func arrayFunc(f func(arr []any) (any, error)) builtin {
+	return value(func(v any) (any, error) {
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an array", describe(v))
+		}
+		return f(arr)
+	})
+}
+
+// byKey adapts a function of an array and the first output of f for each element
+func byKey(f func(arr, keys []any) (any, error)) builtin {
+	return func(in any, args []node) ([]any, error) {
+		arr, ok := in.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an array", describe(in))
+		}
+		keys := make([]any, len(arr))
+		for i, item := range arr {
+			vals, err := args[0].eval(item)
+			if err != nil {
+				return nil, err
+			}
+			keys[i] = vals // all outputs, compared as an array like jq
+		}
+		v, err := f(arr, keys)
+		if err != nil {
+			return nil, err
+		}
+		return []any{v}, nil
+	}
+}
+
+// stringFunc adapts a function of a string input and a string argument
+func stringFunc(f func(s, arg string) any) builtin {
+	return withArg(func(v, arg any) (any, error) {
+		s, ok1 := v.(string)
+		a, ok2 := arg.(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%s and %s cannot both be strings", describe(v), describe(arg))
+		}
+		return f(s, a), nil
+	})
+}
+
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{
+		"empty/0": func(any, []node) ([]any, error) { return nil, nil },
+		"not/0":   value(func(v any) (any, error) { return !truthy(v), nil }),
+		"length/0": value(func(v any) (any, error) {
+			switch x := v.(type) {
+			case nil:
+				return int64(0), nil
+			case string:
+				return int64(utf8.RuneCountInString(x)), nil
+			case []any:
+				return int64(len(x)), nil
+			case map[string]any:
+				return int64(len(x)), nil
+			}
+			if f, ok := toFloat(v); ok {
+				return number(math.Abs(f)), nil
+			}
+			return nil, fmt.Errorf("%s has no length", describe(v))
+		}),
+		"type/0":     value(func(v any) (any, error) { return typeName(v), nil }),
+		"keys/0":     value(keys),
+		"has/1":      withArg(has),
+		"add/0":      arrayFunc(add),
+		"any/0":      arrayFunc(func(arr []any) (any, error) { return anyTruthy(arr), nil }),
+		"all/0":      arrayFunc(func(arr []any) (any, error) { return allTruthy(arr), nil }),
+		"first/0":    value(func(v any) (any, error) { return index(v, int64(0)) }),
+		"last/0":     value(func(v any) (any, error) { return index(v, int64(-1)) }),
+		"reverse/0":  value(reverse),
+		"sort/0":     arrayFunc(func(arr []any) (any, error) { return sortBy(arr, arr), nil }),
+		"unique/0":   arrayFunc(func(arr []any) (any, error) { return uniqueBy(arr, arr), nil }),
+		"min/0":      arrayFunc(func(arr []any) (any, error) { return extreme(arr, arr, -1), nil }),
+		"max/0":      arrayFunc(func(arr []any) (any, error) { return extreme(arr, arr, 1), nil }),
+		"flatten/0":  arrayFunc(func(arr []any) (any, error) { return flatten(arr, -1), nil }),
+		"flatten/1":  withArg(flattenDepth),
+		"sort_by/1":  byKey(func(arr, keys []any) (any, error) { return sortBy(arr, keys), nil }),
+		"group_by/1": byKey(groupBy),
+		"unique_by/1": byKey(func(arr, keys []any) (any, error) {
+			return uniqueBy(arr, keys), nil
+		}),
+		"min_by/1":         byKey(func(arr, keys []any) (any, error) { return extreme(arr, keys, -1), nil }),
+		"max_by/1":         byKey(func(arr, keys []any) (any, error) { return extreme(arr, keys, 1), nil }),
+		"map/1":            mapFunc,
+		"map_values/1":     mapValues,
+		"select/1":         selectFunc,
+		"recurse/0":        func(in any, _ []node) ([]any, error) { return recurseNode{}.eval(in) },
+		"first/1":          firstOf,
+		"limit/2":          limit,
+		"any/1":            anyAll(true),
+		"all/1":            anyAll(false),
+		"range/1":          rangeFunc,
+		"range/2":          rangeFunc,
+		"to_entries/0":     value(toEntries),
+		"from_entries/0":   arrayFunc(fromEntries),
+		"with_entries/1":   withEntries,
+		"tostring/0":       value(func(v any) (any, error) { return toString(v) }),
+		"tonumber/0":       value(toNumber),
+		"tojson/0":         value(func(v any) (any, error) { b, err := json.Marshal(v); return string(b), err }),
+		"fromjson/0":       value(fromJSON),
+		"ascii_downcase/0": value(func(v any) (any, error) { return mapString(v, strings.ToLower) }),
+		"ascii_upcase/0":   value(func(v any) (any, error) { return mapString(v, strings.ToUpper) }),
+		"floor/0":          value(func(v any) (any, error) { return mapNumber(v, math.Floor) }),
+		"ceil/0":           value(func(v any) (any, error) { return mapNumber(v, math.Ceil) }),
+		"round/0":          value(func(v any) (any, error) { return mapNumber(v, math.Round) }),
+		"abs/0":            value(func(v any) (any, error) { return mapNumber(v, math.Abs) }),
+		"join/1":           withArg(join),
+		"split/1":          stringFunc(func(s, sep string) any { return toAnySlice(strings.Split(s, sep)) }),
+		"startswith/1":     stringFunc(func(s, prefix string) any { return strings.HasPrefix(s, prefix) }),
+		"endswith/1":       stringFunc(func(s, suffix string) any { return strings.HasSuffix(s, suffix) }),
+		"ltrimstr/1":       withArg(trimStr(strings.TrimPrefix)),
+		"rtrimstr/1":       withArg(trimStr(strings.TrimSuffix)),
+		"test/1":           withArg(test),
+		"contains/1":       withArg(func(v, arg any) (any, error) { return contains(v, arg) }),
+		"in/1":             withArg(func(v, arg any) (any, error) { return has(arg, v) }),
+	}
+}
+
+func keys(v any) (any, error) {
+	switch x := v.(type) {
+	case map[string]any:
+		return toAnySlice(sortedKeys(x)), nil
+	case []any:
+		out := make([]any, len(x))
+		for i := range x {
+			out[i] = int64(i)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("%s has no keys", describe(v))
+}
+
+func has(v, key any) (any, error) {
+	switch x := v.(type) {
+	case map[string]any:
+		if k, ok := key.(string); ok {
+			_, found := x[k]
+			return found, nil
+		}
+	case []any:
+		if f, ok := toFloat(key); ok {
+			return f >= 0 && int(f) < len(x), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot check whether %s has %s", typeName(v), describe(key))
+}
+
+func add(arr []any) (any, error) {
+	var sum any
+	for _, v := range arr {
+		var err error
+		if sum, err = binary("+", sum, v); err != nil {
+			return nil, err
+		}
+	}
+	return sum, nil
+}
+
+func anyTruthy(arr []any) bool {
+	for _, v := range arr {
+		if truthy(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func allTruthy(arr []any) bool {
+	for _, v := range arr {
+		if !truthy(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func reverse(v any) (any, error) {
+	switch x := v.(type) {
+	case nil:
+		return []any{}, nil
+	case string:
+		r := []rune(x)
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return string(r), nil
+	case []any:
+		out := make([]any, len(x))
+		for i, item := range x {
+			out[len(x)-1-i] = item
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("cannot reverse %s", describe(v))
+}
+
+// sortBy stably sorts arr by keys (parallel to arr)
+func sortBy(arr, keys []any) []any {
+	idx := make([]int, len(arr))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return compare(keys[idx[a]], keys[idx[b]]) < 0 })
+	out := make([]any, len(arr))
+	for i, j := range idx {
+		out[i] = arr[j]
+	}
+	return out
+}
+
+func groupBy(arr, keys []any) (any, error) {
+	sorted := sortBy(arr, keys)
+	sortedKeys := sortBy(keys, keys)
+	out := []any{}
+	for i, item := range sorted {
+		if i == 0 || compare(sortedKeys[i], sortedKeys[i-1]) != 0 {
+			out = append(out, []any{})
+		}
+		last := len(out) - 1
+		out[last] = append(out[last].([]any), item)
+	}
+	return out, nil
+}
+
+func uniqueBy(arr, keys []any) []any {
+	sorted := sortBy(arr, keys)
+	sortedKeys := sortBy(keys, keys)
+	out := []any{}
+	for i, item := range sorted {
+		if i == 0 || compare(sortedKeys[i], sortedKeys[i-1]) != 0 {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// extreme returns the element with the smallest (dir -1) or largest (dir 1) key
+func extreme(arr, keys []any, dir int) any {
+	if len(arr) == 0 {
+		return nil
+	}
+	best := 0
+	for i := 1; i < len(arr); i++ {
+		c := compare(keys[i], keys[best])
+		if dir < 0 && c < 0 || dir > 0 && c >= 0 {
+			best = i
+		}
+	}
+	return arr[best]
+}
+
+func flatten(arr []any, depth int) []any {
+	out := []any{}
+	for _, v := range arr {
+		if inner, ok := v.([]any); ok && depth != 0 {
+			out = append(out, flatten(inner, depth-1)...)
+		} else {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func flattenDepth(v, depth any) (any, error) {
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an array", describe(v))
+	}
+	d, ok := toFloat(depth)
+	if !ok || d < 0 {
+		return nil, fmt.Errorf("flatten depth must be a non-negative number")
+	}
+	return flatten(arr, int(d)), nil
+}
+
+func mapFunc(in any, args []node) ([]any, error) {
+	items, err := (&iterateNode{identity{}}).eval(in)
+	if err != nil {
+		return nil, err
+	}
+	out := []any{}
+	for _, item := range items {
+		vals, err := args[0].eval(item)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vals...)
+	}
+	return []any{out}, nil
+}
+
+func mapValues(in any, args []node) ([]any, error) {
+	first := func(v any) (any, bool, error) {
+		vals, err := args[0].eval(v)
+		if err != nil || len(vals) == 0 {
+			return nil, false, err
+		}
+		return vals[0], true, nil
+	}
+	switch x := in.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, v := range x {
+			nv, ok, err := first(v)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				out[k] = nv
+			}
+		}
+		return []any{out}, nil
+	case []any:
+		out := []any{}
+		for _, v := range x {
+			nv, ok, err := first(v)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				out = append(out, nv)
+			}
+		}
+		return []any{out}, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", describe(in))
+}
+
+func selectFunc(in any, args []node) ([]any, error) {
+	conds, err := args[0].eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, c := range conds {
+		if truthy(c) {
+			out = append(out, in)
+		}
+	}
+	return out, nil
+}
+
+func firstOf(in any, args []node) ([]any, error) {
+	vals, err := args[0].eval(in)
+	if err != nil || len(vals) == 0 {
+		return nil, err
+	}
+	return vals[:1], nil
+}
+
+func limit(in any, args []node) ([]any, error) {
+	ns, err := args[0].eval(in)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := args[1].eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, n := range ns {
+		f, ok := toFloat(n)
+		if !ok {
+			return nil, fmt.Errorf("limit must be a number, got %s", describe(n))
+		}
+		out = append(out, vals[:max(0, min(int(f), len(vals)))]...)
+	}
+	return out, nil
+}
+
+// anyAll returns any(f) (isAny) or all(f): whether f is truthy for any or all elements
+func anyAll(isAny bool) builtin {
+	return func(in any, args []node) ([]any, error) {
+		items, err := (&iterateNode{identity{}}).eval(in)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			vals, err := args[0].eval(item)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range vals {
+				if truthy(v) == isAny {
+					return []any{isAny}, nil
+				}
+			}
+		}
+		return []any{!isAny}, nil
+	}
+}
+
+// rangeFunc is range(upto) and range(from; upto)
+func rangeFunc(in any, args []node) ([]any, error) {
+	bounds := make([]float64, len(args))
+	for i, arg := range args {
+		vals, err := arg.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != 1 {
+			return nil, fmt.Errorf("bounds must be single numbers")
+		}
+		f, ok := toFloat(vals[0])
+		if !ok {
+			return nil, fmt.Errorf("bounds must be numbers, got %s", describe(vals[0]))
+		}
+		bounds[i] = f
+	}
+	from, upto := 0.0, bounds[0]
+	if len(bounds) == 2 {
+		from, upto = bounds[0], bounds[1]
+	}
+	if upto-from > 1e6 {
+		return nil, fmt.Errorf("range of %v values is too large", upto-from)
+	}
+	var out []any
+	for f := from; f < upto; f++ {
+		out = append(out, number(f))
+	}
+	return out, nil
+}
+
+func toEntries(v any) (any, error) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an object", describe(v))
+	}
+	out := make([]any, 0, len(obj))
+	for _, k := range sortedKeys(obj) {
+		out = append(out, map[string]any{"key": k, "value": obj[k]})
+	}
+	return out, nil
+}
+
+func fromEntries(arr []any) (any, error) {
+	out := make(map[string]any, len(arr))
+	for _, e := range arr {
+		entry, ok := e.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("entries must be objects, got %s", describe(e))
+		}
+		var key any
+		for _, name := range []string{"key", "k", "name", "Name", "Key", "K"} {
+			if k, found := entry[name]; found && k != nil {
+				key = k
+				break
+			}
+		}
+		var val any
+		for _, name := range []string{"value", "v", "Value", "V"} {
+			if v, found := entry[name]; found {
+				val = v
+				break
+			}
+		}
+		switch k := key.(type) {
+		case string:
+			out[k] = val
+		case bool:
+			out[strconv.FormatBool(k)] = val
+		default:
+			f, isNum := toFloat(key)
+			if !isNum {
+				return nil, fmt.Errorf("entry key must be a string, got %s", describe(key))
+			}
+			out[formatNumber(f)] = val
+		}
+	}
+	return out, nil
+}
+
+func withEntries(in any, args []node) ([]any, error) {
+	entries, err := toEntries(in)
+	if err != nil {
+		return nil, err
+	}
+	mapped, err := mapFunc(entries, args)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := fromEntries(mapped[0].([]any))
+	if err != nil {
+		return nil, err
+	}
+	return []any{obj}, nil
+}
+
+func toString(v any) (any, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func toNumber(v any) (any, error) {
+	if s, ok := v.(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q as a number", s)
+		}
+		return number(f), nil
+	}
+	if _, ok := toFloat(v); ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s cannot be parsed as a number", describe(v))
+}
+
+func fromJSON(v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a string", describe(v))
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return normalize(out)
+}
+
+func mapString(v any, f func(string) string) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a string", describe(v))
+	}
+	return f(s), nil
+}
+
+func mapNumber(v any, f func(float64) float64) (any, error) {
+	n, ok := toFloat(v)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a number", describe(v))
+	}
+	return number(f(n)), nil
+}
+
+func join(v, sep any) (any, error) {
+	arr, ok := v.([]any)
+	s, ok2 := sep.(string)
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("cannot join %s with %s", describe(v), describe(sep))
+	}
+	parts := make([]string, len(arr))
+	for i, item := range arr {
+		switch x := item.(type) {
+		case nil:
+		case string:
+			parts[i] = x
+		case bool, int64, float64:
+			str, _ := toString(x)
+			parts[i] = str.(string)
+		default:
+			return nil, fmt.Errorf("cannot join %s", describe(item))
+		}
+	}
+	return strings.Join(parts, s), nil
+}
+
+func trimStr(trim func(s, affix string) string) func(v, arg any) (any, error) {
+	return func(v, arg any) (any, error) {
+		s, ok1 := v.(string)
+		a, ok2 := arg.(string)
+		if !ok1 || !ok2 {
+			return v, nil
+		}
+		return trim(s, a), nil
+	}
+}
+
+func test(v, pattern any) (any, error) {
+	s, ok1 := v.(string)
+	p, ok2 := pattern.(string)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%s cannot be matched against %s", describe(v), describe(pattern))
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString(s), nil
+}
+
+// contains reports whether b is contained in a: substrings, array elements, and object subsets
+func contains(a, b any) (bool, error) {
+	switch x := a.(type) {
+	case string:
+		s, ok := b.(string)
+		if !ok {
+			break
+		}
+		return strings.Contains(x, s), nil
+	case []any:
+		sub, ok := b.([]any)
+		if !ok {
+			break
+		}
+		for _, want := range sub {
+			found := false
+			for _, have := range x {
+				if c, err := contains(have, want); err == nil && c {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false, nil
+			}
+		}
+		return true, nil
+	case map[string]any:
+		sub, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		for k, want := range sub {
+			have, found := x[k]
+			if !found {
+				return false, nil
+			}
+			if c, err := contains(have, want); err != nil || !c {
+				return false, err
+			}
+		}
+		return true, nil
+	default:
+		if typeName(a) == typeName(b) {
+			return compare(a, b) == 0, nil
+		}
+	}
+	return false, fmt.Errorf("%s and %s cannot have their containment checked", describe(a), describe(b))
+}
+
+// ============================================================================
+// Values
+// ============================================================================
+
+// normalize converts v to the values encoding/json produces, except that
+// integers stay int64 so large IDs keep their precision
+func normalize(v any) (any, error) {
+	switch x := v.(type) {
+	case nil, bool, string, int64, float64:
+		return x, nil
+	case int:
+		return int64(x), nil
+	case int32:
+		return int64(x), nil
+	case float32:
+		return float64(x), nil
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i, nil
+		}
+		return x.Float64()
+	case []any:
+		out := make([]any, len(x))
+		for i, item := range x {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n
+		}
+		return out, nil
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, item := range x {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = n
+		}
+		return out, nil
+	case []map[string]any:
+		out := make([]any, len(x))
+		for i, item := range x {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n
+		}
+		return out, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u), nil
+		}
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+
+	// Everything else (time.Time, structs, typed slices and maps) takes its JSON form
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return normalize(out)
+}
+
+// number returns f as an int64 when it is a whole number that fits, else as f
+func number(f float64) any {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f)
+	}
+	return f
+}
+
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func toAnySlice(s []string) []any {
+	out := make([]any, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	}
+	return true
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int64, float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// describe names a value in errors, like jq: string ("abc")
+func describe(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return typeName(v)
+	}
+	s := string(b)
+	if len(s) > 30 {
+		s = s[:27] + "..."
+	}
+	return fmt.Sprintf("%s (%s)", typeName(v), s)
+}
+
+// typeOrder is jq's ordering of types: null < false < true < numbers < strings < arrays < objects
+func typeOrder(v any) int {
+	switch x := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if x {
+			return 2
+		}
+		return 1
+	case int64, float64:
+		return 3
+	case string:
+		return 4
+	case []any:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// compare orders two values like jq's sort
+func compare(a, b any) int {
+	if ta, tb := typeOrder(a), typeOrder(b); ta != tb {
+		return ta - tb
+	}
+	switch x := a.(type) {
+	case int64:
+		if y, ok := b.(int64); ok {
+			return cmpOrdered(x, y)
+		}
+		fb, _ := toFloat(b)
+		return cmpOrdered(float64(x), fb)
+	case float64:
+		fb, _ := toFloat(b)
+		return cmpOrdered(x, fb)
+	case string:
+		return strings.Compare(x, b.(string))
+	case []any:
+		y := b.([]any)
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := compare(x[i], y[i]); c != 0 {
+				return c
+			}
+		}
+		return len(x) - len(y)
+	case map[string]any:
+		y := b.(map[string]any)
+		kx, ky := toAnySlice(sortedKeys(x)), toAnySlice(sortedKeys(y))
+		if c := compare(kx, ky); c != 0 {
+			return c
+		}
+		for _, k := range sortedKeys(x) {
+			if c := compare(x[k], y[k]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+func cmpOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func binary(op string, l, r any) (any, error) {
+	switch op {
+	case "==":
+		return compare(l, r) == 0, nil
+	case "!=":
+		return compare(l, r) != 0, nil
+	case "<":
+		return compare(l, r) < 0, nil
+	case "<=":
+		return compare(l, r) <= 0, nil
+	case ">":
+		return compare(l, r) > 0, nil
+	case ">=":
+		return compare(l, r) >= 0, nil
+	}
+
+	li, lInt := l.(int64)
+	ri, rInt := r.(int64)
+	lf, lNum := toFloat(l)
+	rf, rNum := toFloat(r)
+	switch op {
+	case "+":
+		switch {
+		case l == nil:
+			return r, nil
+		case r == nil:
+			return l, nil
+		case lInt && rInt && (ri >= 0 && li <= math.MaxInt64-ri || ri < 0 && li >= math.MinInt64-ri):
+			return li + ri, nil
+		case lNum && rNum:
+			return number(lf + rf), nil
+		}
+		switch x := l.(type) {
+		case string:
+			if y, ok := r.(string); ok {
+				return x + y, nil
+			}
+		case []any:
+			if y, ok := r.([]any); ok {
+				return append(append([]any{}, x...), y...), nil
+			}
+		case map[string]any:
+			if y, ok := r.(map[string]any); ok {
+				out := make(map[string]any, len(x)+len(y))
+				for k, v := range x {
+					out[k] = v
+				}
+				for k, v := range y {
+					out[k] = v
+				}
+				return out, nil
+			}
+		}
+	case "-":
+		if lNum && rNum {
+			return number(lf - rf), nil
+		}
+		if x, ok := l.([]any); ok {
+			if y, ok := r.([]any); ok {
+				out := []any{}
+				for _, item := range x {
+					remove := false
+					for _, drop := range y {
+						if compare(item, drop) == 0 {
+							remove = true
+							break
+						}
+					}
+					if !remove {
+						out = append(out, item)
+					}
+				}
+				return out, nil
+			}
+		}
+	case "*":
+		if lNum && rNum {
+			return number(lf * rf), nil
+		}
+	case "/":
+		if lNum && rNum {
+			if rf == 0 {
+				return nil, fmt.Errorf("%s and %s cannot be divided because the divisor is zero", describe(l), describe(r))
+			}
+			return number(lf / rf), nil
+		}
+		if x, ok := l.(string); ok {
+			if y, ok := r.(string); ok {
+				return toAnySlice(strings.Split(x, y)), nil
+			}
+		}
+	case "%":
+		if lNum && rNum {
+			if int64(rf) == 0 {
+				return nil, fmt.Errorf("%s and %s cannot be divided because the divisor is zero", describe(l), describe(r))
+			}
+			return int64(lf) % int64(rf), nil
+		}
+	}
+	verbs := map[string]string{"+": "added", "-": "subtracted", "*": "multiplied", "/": "divided", "%": "divided"}
+	return nil, fmt.Errorf("%s and %s cannot be %s", describe(l), describe(r), verbs[op])
+}
@@ -0,0 +1,239 @@
+package jq
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDoc = `{
+	"user": {"name": "ada", "id": 9007199254740993, "tags": ["a", "b"]},
+	"orders": [
+		{"id": 1, "status": "paid", "total": 30.5, "items": [{"sku": "x"}, {"sku": "y"}]},
+		{"id": 2, "status": "open", "total": 12, "items": []},
+		{"id": 3, "status": "paid", "total": 7, "items": [{"sku": "x"}]}
+	],
+	"empty": null
+}`
+
+func run(t *testing.T, query string, input any) []any {
+	t.Helper()
+	q, err := Parse(query)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", query, err)
+	}
+	out, err := q.Run(input)
+	if err != nil {
+		t.Fatalf("Run(%q): %v", query, err)
+	}
+	return out
+}
+
+func decode(t *testing.T, doc string) any {
+	t.Helper()
+	var v any
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("bad JSON: %v", err)
+	}
+	return v
+}
+
+func TestQuery_Run(t *testing.T) {
+	doc := decode(t, testDoc)
+	tests := []struct {
+		query string
+		want  string // outputs as a JSON array
+	}{
+		{`.`, `[` + `{"empty":null,"orders":[{"id":1,"items":[{"sku":"x"},{"sku":"y"}],"status":"paid","total":30.5},{"id":2,"items":[],"status":"open","total":12},{"id":3,"items":[{"sku":"x"}],"status":"paid","total":7}],"user":{"id":9007199254740993,"name":"ada","tags":["a","b"]}}` + `]`},
+		{`.user.name`, `["ada"]`},
+		{`.user.id`, `[9007199254740993]`},
+		{`.user["name"]`, `["ada"]`},
+		{`."user".tags[1]`, `["b"]`},
+		{`.user.tags[-1]`, `["b"]`},
+		{`.user.tags.[0]`, `["a"]`},
+		{`.missing.deeper`, `[null]`},
+		{`.empty[0]`, `[null]`},
+		{`.orders[].id`, `[1,2,3]`},
+		{`.orders[1:].[].id`, `[2,3]`},
+		{`.orders[:1] | length`, `[1]`},
+		{`.user.name[1:]`, `["da"]`},
+		{`.user.tags | keys`, `[[0,1]]`},
+		{`.user | keys`, `[["id","name","tags"]]`},
+		{`.user.name, .user.tags[0]`, `["ada","a"]`},
+		{`[.orders[] | select(.status == "paid") | .id]`, `[[1,3]]`},
+		{`.orders | map(.total) | add`, `[49.5]`},
+		{`.orders | map(.id * 2 + 1)`, `[[3,5,7]]`},
+		{`.orders | map(.items | length) | max`, `[2]`},
+		{`.orders | sort_by(.total) | map(.id)`, `[[3,2,1]]`},
+		{`.orders | sort_by(-.total) | first | .id`, `[1]`},
+		{`.orders | group_by(.status) | map({status: .[0].status, count: length})`, `[[{"count":1,"status":"open"},{"count":2,"status":"paid"}]]`},
+		{`.orders | unique_by(.status) | map(.id)`, `[[2,1]]`},
+		{`.orders | min_by(.total) | .id`, `[3]`},
+		{`[.orders[].items[].sku] | unique`, `[["x","y"]]`},
+		{`{name: .user.name, ids: [.orders[].id]}`, `[{"ids":[1,2,3],"name":"ada"}]`},
+		{`.user | {name, "first tag": .tags[0], (.name): true}`, `[{"ada":true,"first tag":"a","name":"ada"}]`},
+		{`{id: .orders[].id}`, `[{"id":1},{"id":2},{"id":3}]`},
+		{`{a: 1 | . + 1}`, `[{"a":2}]`},
+		{`.user | to_entries | map(.key)`, `[["id","name","tags"]]`},
+		{`.user | with_entries(select(.key != "id"))`, `[{"name":"ada","tags":["a","b"]}]`},
+		{`[{key: "a", value: 1}] | from_entries`, `[{"a":1}]`},
+		{`.orders[0] | map_values(type)`, `[{"id":"number","items":"array","status":"string","total":"number"}]`},
+		{`.orders[] | if .total > 20 then "big" elif .total > 10 then "medium" else "small" end`, `["big","medium","small"]`},
+		{`.orders[0] | if .id == 1 then "one" end`, `["one"]`},
+		{`.empty // "default"`, `["default"]`},
+		{`.user.name // "default"`, `["ada"]`},
+		{`(.missing | length) // 0`, `[0]`},
+		{`.orders | any(.status == "open")`, `[true]`},
+		{`.orders | all(.total > 10)`, `[false]`},
+		{`[true, false] | any, all`, `[true,false]`},
+		{`.user.tags | join(",")`, `["a,b"]`},
+		{`"a-b-c" | split("-")`, `[["a","b","c"]]`},
+		{`.user.name | ascii_upcase`, `["ADA"]`},
+		{`.user.name | test("^a")`, `[true]`},
+		{`.user.name | startswith("ad"), endswith("x")`, `[true,false]`},
+		{`"v1.2" | ltrimstr("v") | rtrimstr(".2")`, `["1"]`},
+		{`"42" | tonumber + 1`, `[43]`},
+		{`42 | tostring`, `["42"]`},
+		{`.user.tags | tojson`, `["[\"a\",\"b\"]"]`},
+		{`"{\"a\":1}" | fromjson | .a`, `[1]`},
+		{`[1, [2, [3]]] | flatten, flatten(1)`, `[[1,2,3],[1,2,[3]]]`},
+		{`[3, 1, 2] | sort, reverse, min`, `[[1,2,3],[2,1,3],1]`},
+		{`[null, true, false, 1, "a", [], {}] | sort`, `[[null,false,true,1,"a",[],{}]]`},
+		{`[limit(2; .orders[].id)]`, `[[1,2]]`},
+		{`first(.orders[].id)`, `[1]`},
+		{`[range(3)], [range(1; 3)]`, `[[0,1,2],[1,2]]`},
+		{`.user | has("name"), has("nope")`, `[true,false]`},
+		{`"name" | in({"name": 1})`, `[true]`},
+		{`.user | contains({tags: ["a"]})`, `[true]`},
+		{`[.[] | type]`, `[["null","array","object"]]`},
+		{`.user.name | not`, `[false]`},
+		{`1 == 1 and (2 < 1 or true)`, `[true]`},
+		{`[.orders[] | .id | select(. % 2 == 1)]`, `[[1,3]]`},
+		{`10 / 4, 7 % 3, -(1 + 2)`, `[2.5,1,-3]`},
+		{`[1, 2, 3] - [2]`, `[[1,3]]`},
+		{`{a: 1} + {b: 2}`, `[{"a":1,"b":2}]`},
+		{`"a" + "b", null + 1`, `["ab",1]`},
+		{`[.[]?]`, `[[null,[{"id":1,"items":[{"sku":"x"},{"sku":"y"}],"status":"paid","total":30.5},{"id":2,"items":[],"status":"open","total":12},{"id":3,"items":[{"sku":"x"}],"status":"paid","total":7}],{"id":9007199254740993,"name":"ada","tags":["a","b"]}]]`},
+		{`.user.name[]?`, `[]`},
+		{`[.. | .sku? | select(. != null)]`, `[["x","y","x"]]`},
+		{`.orders | length, (.[0].items | length)`, `[3,2]`},
+		{`[.orders[] | .total | floor]`, `[[30,12,7]]`},
+		{`empty`, `[]`},
+		{`[]`, `[[]]`},
+		{`{}`, `[{}]`},
+		{`.orders[0].id # a comment`, `[1]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			out := run(t, tt.query, doc)
+			got, err := json.Marshal(out)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if out == nil {
+				got = []byte("[]")
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery_RunGoValues(t *testing.T) {
+	type row struct {
+		Name string `json:"name"`
+	}
+	input := map[string]any{
+		"rows":  []map[string]any{{"id": 1}, {"id": 2}},
+		"ints":  []int{3, 1, 2},
+		"row":   row{Name: "ada"},
+		"when":  time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		"count": uint8(4),
+	}
+	tests := []struct {
+		query string
+		want  any
+	}{
+		{`[.rows[].id] | add`, int64(3)},
+		{`.ints | sort | first`, int64(1)},
+		{`.row.name`, "ada"},
+		{`.when`, "2024-03-01T10:00:00Z"},
+		{`.count + 1`, int64(5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			out := run(t, tt.query, input)
+			if len(out) != 1 || out[0] != tt.want {
+				t.Errorf("got %#v, want %#v", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr string
+	}{
+		{`.a |`, "unexpected end of query"},
+		{`.a[`, "unexpected end of query"},
+		{`(.a`, `expected ")"`},
+		{`{a: 1`, `expected "}"`},
+		{`{(.a)}`, "computed key"},
+		{`if . then 1`, `expected "end"`},
+		{`nope`, "unknown function nope/0"},
+		{`map`, "unknown function map/0"},
+		{`.a as $x | $x`, "variables are not supported"},
+		{`"\(.a)"`, "string interpolation is not supported"},
+		{`def f: 1; f`, `"def" is not supported`},
+		{`"abc`, "unterminated string"},
+		{`.a ^ 1`, "unexpected character"},
+		{`.a 1`, `unexpected "1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestQuery_RunErrors(t *testing.T) {
+	doc := decode(t, testDoc)
+	tests := []struct {
+		query   string
+		wantErr string
+	}{
+		{`.user.name.first`, `cannot index string with string ("first")`},
+		{`.user.tags.x`, `cannot index array with string ("x")`},
+		{`.user.name[]`, `cannot iterate over string ("ada")`},
+		{`.user + 1`, "cannot be added"},
+		{`1 / 0`, "divisor is zero"},
+		{`.user | sort`, "sort: object"},
+		{`"x" | tonumber`, `cannot parse "x" as a number`},
+		{`.user.name | test("(")`, "missing closing )"},
+		{`{(1): 2}`, "object keys must be strings"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			_, err = q.Run(doc)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,701 @@
+// Package jsonschema validates decoded JSON values against JSON Schema documents.
+// It implements the validation keywords of draft 2020-12 that request and response
+// schemas commonly use (types, objects, arrays, strings, numbers, enum/const, the
+// combinators and local $ref). Keywords it does not implement are rejected when the
+// schema is compiled, so a schema never silently validates less than it says.
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxDepth bounds $ref recursion during validation
+const maxDepth = 64
+
+// annotationKeywords carry no validation and are accepted as-is
+var annotationKeywords = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "$defs": true, "definitions": true,
+	"title": true, "description": true, "default": true, "examples": true,
+	"deprecated": true, "readOnly": true, "writeOnly": true, "contentMediaType": true, "contentEncoding": true,
+}
+
+// validTypes are the JSON Schema type names
+var validTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true, "number": true, "integer": true, "string": true,
+}
+
+// Error is one validation failure.
+type Error struct {
+	Path    string // JSON pointer to the failing value ("" for the root)
+	Keyword string // Schema keyword that failed (type, required, minimum, ...)
+	Message string
+}
+
+func (e Error) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Schema is a compiled schema.
+type Schema struct {
+	doc  any // Normalized document
+	root *node
+}
+
+// Compile compiles a schema document: a decoded JSON or YAML value (object or boolean).
+func Compile(doc any) (*Schema, error) {
+	// Round-trip through JSON so YAML-decoded numbers and maps take JSON's types
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("schema is not JSON-compatible: %w", err)
+	}
+	var normalized any
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return nil, err
+	}
+
+	c := &compiler{doc: normalized, nodes: make(map[string]*node)}
+	root, err := c.compileAt("")
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{doc: normalized, root: root}, nil
+}
+
+// Validate checks a decoded JSON value (as produced by encoding/json) and returns every failure.
+func (s *Schema) Validate(v any) []Error {
+	var errs []Error
+	s.root.validate(v, "", 0, &errs)
+	return errs
+}
+
+// Inline returns the schema document with local $refs replaced by their targets and
+// definitions removed, for embedding in documents that cannot resolve them (OpenAPI).
+// Recursive references are cut off with an empty (accept-anything) schema.
+func (s *Schema) Inline() any {
+	return s.inline(s.doc, map[string]bool{})
+}
+
+func (s *Schema) inline(v any, active map[string]bool) any {
+	switch val := v.(type) {
+	case map[string]any:
+		if ref, ok := val["$ref"].(string); ok {
+			target, err := resolvePointer(s.doc, ref)
+			if err != nil || active[ref] {
+				return map[string]any{}
+			}
+			active[ref] = true
+			defer delete(active, ref)
+			return s.inline(target, active)
+		}
+		out := make(map[string]any, len(val))
+		for k, child := range val {
+			if k == "$defs" || k == "definitions" || k == "$schema" || k == "$id" || k == "$comment" {
+				continue
+			}
+			out[k] = s.inline(child, active)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, child := range val {
+			out[i] = s.inline(child, active)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// node is a compiled (sub)schema.
+type node struct {
+	always *bool // Boolean schema
+
+	types    []string
+	enum     []any
+	hasConst bool
+	constVal any
+
+	minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf *float64
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	format               string
+
+	items              *node
+	minItems, maxItems *int
+	uniqueItems        bool
+
+	properties          map[string]*node
+	propertyNames       []string // Sorted, for deterministic error order
+	required            []string
+	additional          *node
+	patternProperties   []patternProperty
+	minProps, maxProps  *int
+	allOf, anyOf, oneOf []*node
+	not                 *node
+	ref                 *node
+}
+
+type patternProperty struct {
+	re   *regexp.Regexp
+	node *node
+}
+
+type compiler struct {
+	doc   any
+	nodes map[string]*node // By JSON pointer into doc
+}
+
+// compileAt compiles the schema at a JSON pointer. Nodes are cached before they are
+// filled in, so recursive references resolve to the node being built.
+func (c *compiler) compileAt(pointer string) (*node, error) {
+	if n, ok := c.nodes[pointer]; ok {
+		return n, nil
+	}
+	v, err := resolvePointer(c.doc, "#"+pointer)
+	if err != nil {
+		return nil, err
+	}
+	n := &node{}
+	c.nodes[pointer] = n
+	if err := c.fill(n, v, pointer); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (c *compiler) fill(n *node, v any, pointer string) error {
+	at := func(keyword string) string {
+		return pointer + "/" + keyword
+	}
+	fail := func(keyword, format string, args ...any) error {
+		return fmt.Errorf("%s: %s", at(keyword), fmt.Sprintf(format, args...))
+	}
+
+	if b, ok := v.(bool); ok {
+		n.always = &b
+		return nil
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("schema at %q must be an object or boolean", "#"+pointer)
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		val := m[k]
+		switch k {
+		case "type":
+			switch t := val.(type) {
+			case string:
+				n.types = []string{t}
+			case []any:
+				for _, item := range t {
+					s, ok := item.(string)
+					if !ok {
+						return fail(k, "must be a string or array of strings")
+					}
+					n.types = append(n.types, s)
+				}
+			default:
+				return fail(k, "must be a string or array of strings")
+			}
+			for _, t := range n.types {
+				if !validTypes[t] {
+					return fail(k, "unknown type %q", t)
+				}
+			}
+		case "enum":
+			list, ok := val.([]any)
+			if !ok || len(list) == 0 {
+				return fail(k, "must be a non-empty array")
+			}
+			n.enum = list
+		case "const":
+			n.hasConst, n.constVal = true, val
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			f, ok := val.(float64)
+			if !ok {
+				return fail(k, "must be a number")
+			}
+			if k == "multipleOf" && f <= 0 {
+				return fail(k, "must be greater than 0")
+			}
+			*map[string]**float64{
+				"minimum": &n.minimum, "maximum": &n.maximum,
+				"exclusiveMinimum": &n.exclusiveMinimum, "exclusiveMaximum": &n.exclusiveMaximum,
+				"multipleOf": &n.multipleOf,
+			}[k] = &f
+		case "minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties":
+			f, ok := val.(float64)
+			if !ok || f < 0 || f != math.Trunc(f) {
+				return fail(k, "must be a non-negative integer")
+			}
+			i := int(f)
+			*map[string]**int{
+				"minLength": &n.minLength, "maxLength": &n.maxLength,
+				"minItems": &n.minItems, "maxItems": &n.maxItems,
+				"minProperties": &n.minProps, "maxProperties": &n.maxProps,
+			}[k] = &i
+		case "pattern":
+			s, ok := val.(string)
+			if !ok {
+				return fail(k, "must be a string")
+			}
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return fail(k, "invalid pattern: %v", err)
+			}
+			n.pattern = re
+		case "format":
+			s, ok := val.(string)
+			if !ok {
+				return fail(k, "must be a string")
+			}
+			n.format = s
+		case "items":
+			if _, isArray := val.([]any); isArray {
+				return fail(k, "array form is not supported")
+			}
+			sub, err := c.compileAt(at(k))
+			if err != nil {
+				return err
+			}
+			n.items = sub
+		case "uniqueItems":
+			b, ok := val.(bool)
+			if !ok {
+				return fail(k, "must be a boolean")
+			}
+			n.uniqueItems = b
+		case "properties", "patternProperties":
+			props, ok := val.(map[string]any)
+			if !ok {
+				return fail(k, "must be an object")
+			}
+			names := make([]string, 0, len(props))
+			for name := range props {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				sub, err := c.compileAt(at(k) + "/" + escapePointer(name))
+				if err != nil {
+					return err
+				}
+				if k == "properties" {
+					if n.properties == nil {
+						n.properties = make(map[string]*node)
+					}
+					n.properties[name] = sub
+					n.propertyNames = append(n.propertyNames, name)
+					continue
+				}
+				re, err := regexp.Compile(name)
+				if err != nil {
+					return fail(k, "invalid pattern %q: %v", name, err)
+				}
+				n.patternProperties = append(n.patternProperties, patternProperty{re: re, node: sub})
+			}
+		case "required":
+			list, ok := val.([]any)
+			if !ok {
+				return fail(k, "must be an array of strings")
+			}
+			for _, item := range list {
+				s, ok := item.(string)
+				if !ok {
+					return fail(k, "must be an array of strings")
+				}
+				n.required = append(n.required, s)
+			}
+		case "additionalProperties", "not":
+			sub, err := c.compileAt(at(k))
+			if err != nil {
+				return err
+			}
+			if k == "not" {
+				n.not = sub
+			} else {
+				n.additional = sub
+			}
+		case "allOf", "anyOf", "oneOf":
+			list, ok := val.([]any)
+			if !ok || len(list) == 0 {
+				return fail(k, "must be a non-empty array")
+			}
+			subs := make([]*node, len(list))
+			for i := range list {
+				sub, err := c.compileAt(at(k) + "/" + strconv.Itoa(i))
+				if err != nil {
+					return err
+				}
+				subs[i] = sub
+			}
+			switch k {
+			case "allOf":
+				n.allOf = subs
+			case "anyOf":
+				n.anyOf = subs
+			default:
+				n.oneOf = subs
+			}
+		case "$ref":
+			s, ok := val.(string)
+			if !ok || !strings.HasPrefix(s, "#") {
+				return fail(k, "only local references (#/...) are supported")
+			}
+			target := strings.TrimPrefix(s, "#")
+			if _, err := resolvePointer(c.doc, s); err != nil {
+				return fail(k, "%v", err)
+			}
+			sub, err := c.compileAt(target)
+			if err != nil {
+				return err
+			}
+			n.ref = sub
+		default:
+			if !annotationKeywords[k] {
+				return fail(k, "keyword is not supported")
+			}
+		}
+	}
+	return nil
+}
+
+func (n *node) validate(v any, path string, depth int, errs *[]Error) {
+	add := func(keyword, format string, args ...any) {
+		*errs = append(*errs, Error{Path: path, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if depth > maxDepth {
+		add("$ref", "schema recursion too deep")
+		return
+	}
+	if n.always != nil {
+		if !*n.always {
+			add("false", "no value is allowed")
+		}
+		return
+	}
+	if n.ref != nil {
+		n.ref.validate(v, path, depth+1, errs)
+	}
+
+	if len(n.types) > 0 && !matchesType(v, n.types) {
+		// Other checks would only repeat the mismatch
+		add("type", "must be %s, got %s", strings.Join(n.types, " or "), typeOf(v))
+		return
+	}
+	if n.enum != nil && !containsValue(n.enum, v) {
+		add("enum", "must be one of: %s", joinJSON(n.enum))
+	}
+	if n.hasConst && !reflect.DeepEqual(n.constVal, v) {
+		add("const", "must be %s", toJSON(n.constVal))
+	}
+
+	switch val := v.(type) {
+	case float64:
+		n.validateNumber(val, add)
+	case string:
+		n.validateString(val, add)
+	case []any:
+		n.validateArray(val, path, depth, errs, add)
+	case map[string]any:
+		n.validateObject(val, path, depth, errs, add)
+	}
+
+	for _, sub := range n.allOf {
+		sub.validate(v, path, depth+1, errs)
+	}
+	if n.anyOf != nil {
+		matched := false
+		for _, sub := range n.anyOf {
+			if sub.matches(v, depth) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			add("anyOf", "must match at least one of the anyOf schemas")
+		}
+	}
+	if n.oneOf != nil {
+		matched := 0
+		for _, sub := range n.oneOf {
+			if sub.matches(v, depth) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			add("oneOf", "must match exactly one of the oneOf schemas (matched %d)", matched)
+		}
+	}
+	if n.not != nil && n.not.matches(v, depth) {
+		add("not", "must not match the schema in not")
+	}
+}
+
+func (n *node) matches(v any, depth int) bool {
+	var errs []Error
+	n.validate(v, "", depth+1, &errs)
+	return len(errs) == 0
+}
+
+func (n *node) validateNumber(f float64, add func(keyword, format string, args ...any)) {
+	if n.minimum != nil && f < *n.minimum {
+		add("minimum", "must be >= %s", formatNumber(*n.minimum))
+	}
+	if n.maximum != nil && f > *n.maximum {
+		add("maximum", "must be <= %s", formatNumber(*n.maximum))
+	}
+	if n.exclusiveMinimum != nil && f <= *n.exclusiveMinimum {
+		add("exclusiveMinimum", "must be > %s", formatNumber(*n.exclusiveMinimum))
+	}
+	if n.exclusiveMaximum != nil && f >= *n.exclusiveMaximum {
+		add("exclusiveMaximum", "must be < %s", formatNumber(*n.exclusiveMaximum))
+	}
+	if n.multipleOf != nil {
+		q := f / *n.multipleOf
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			add("multipleOf", "must be a multiple of %s", formatNumber(*n.multipleOf))
+		}
+	}
+}
+
+func (n *node) validateString(s string, add func(keyword, format string, args ...any)) {
+	length := utf8.RuneCountInString(s)
+	if n.minLength != nil && length < *n.minLength {
+		add("minLength", "must be at least %d characters", *n.minLength)
+	}
+	if n.maxLength != nil && length > *n.maxLength {
+		add("maxLength", "must be at most %d characters", *n.maxLength)
+	}
+	if n.pattern != nil && !n.pattern.MatchString(s) {
+		add("pattern", "must match pattern %s", n.pattern.String())
+	}
+	if n.format != "" && !validFormat(n.format, s) {
+		add("format", "must be a valid %s", n.format)
+	}
+}
+
+func (n *node) validateArray(arr []any, path string, depth int, errs *[]Error, add func(keyword, format string, args ...any)) {
+	if n.minItems != nil && len(arr) < *n.minItems {
+		add("minItems", "must have at least %d items", *n.minItems)
+	}
+	if n.maxItems != nil && len(arr) > *n.maxItems {
+		add("maxItems", "must have at most %d items", *n.maxItems)
+	}
+	if n.uniqueItems {
+		for i := 1; i < len(arr); i++ {
+			if containsValue(arr[:i], arr[i]) {
+				add("uniqueItems", "must not contain duplicate items")
+				break
+			}
+		}
+	}
+	if n.items != nil {
+		for i, item := range arr {
+			n.items.validate(item, path+"/"+strconv.Itoa(i), depth+1, errs)
+		}
+	}
+}
+
+func (n *node) validateObject(obj map[string]any, path string, depth int, errs *[]Error, add func(keyword, format string, args ...any)) {
+	if n.minProps != nil && len(obj) < *n.minProps {
+		add("minProperties", "must have at least %d properties", *n.minProps)
+	}
+	if n.maxProps != nil && len(obj) > *n.maxProps {
+		add("maxProperties", "must have at most %d properties", *n.maxProps)
+	}
+	for _, name := range n.required {
+		if _, ok := obj[name]; !ok {
+			*errs = append(*errs, Error{Path: path + "/" + escapePointer(name), Keyword: "required", Message: "is required"})
+		}
+	}
+	for _, name := range n.propertyNames {
+		if val, ok := obj[name]; ok {
+			n.properties[name].validate(val, path+"/"+escapePointer(name), depth+1, errs)
+		}
+	}
+
+	if n.patternProperties == nil && n.additional == nil {
+		return
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		childPath := path + "/" + escapePointer(name)
+		_, known := n.properties[name]
+		for _, pp := range n.patternProperties {
+			if pp.re.MatchString(name) {
+				known = true
+				pp.node.validate(obj[name], childPath, depth+1, errs)
+			}
+		}
+		if !known && n.additional != nil {
+			if n.additional.always != nil && !*n.additional.always {
+				*errs = append(*errs, Error{Path: childPath, Keyword: "additionalProperties", Message: "is not allowed"})
+				continue
+			}
+			n.additional.validate(obj[name], childPath, depth+1, errs)
+		}
+	}
+}
+
+func matchesType(v any, types []string) bool {
+	actual := typeOf(v)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type of a decoded value; whole numbers are integers.
+func typeOf(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if val == math.Trunc(val) && !math.IsInf(val, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+var (
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	timePattern = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`)
+)
+
+// validFormat checks the common string formats. Unknown formats are annotations and always pass.
+func validFormat(format, s string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	case "time":
+		return timePattern.MatchString(s)
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	case "uuid":
+		return uuidPattern.MatchString(s)
+	default:
+		return true
+	}
+}
+
+func containsValue(list []any, v any) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinJSON(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = toJSON(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func toJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// resolvePointer returns the value a local reference ("#/a/b") points to.
+func resolvePointer(doc any, ref string) (any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported reference %q", ref)
+	}
+	pointer, err := url.PathUnescape(strings.TrimPrefix(ref, "#"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	if pointer == "" {
+		return doc, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("unsupported reference %q (anchors are not supported)", ref)
+	}
+
+	current := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch val := current.(type) {
+		case map[string]any:
+			next, ok := val[token]
+			if !ok {
+				return nil, fmt.Errorf("reference %q not found", ref)
+			}
+			current = next
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(val) {
+				return nil, fmt.Errorf("reference %q not found", ref)
+			}
+			current = val[i]
+		default:
+			return nil, errors.New("reference " + strconv.Quote(ref) + " not found")
+		}
+	}
+	return current, nil
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
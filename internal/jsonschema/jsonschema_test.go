@@ -0,0 +1,196 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustCompile(t *testing.T, schema string) *Schema {
+	t.Helper()
+	var doc any
+	if err := json.Unmarshal([]byte(schema), &doc); err != nil {
+		t.Fatalf("bad schema JSON: %v", err)
+	}
+	s, err := Compile(doc)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	return s
+}
+
+func TestSchema_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		want   []string // "path keyword: message"
+	}{
+		{"type ok", `{"type":"object"}`, `{}`, nil},
+		{"type mismatch", `{"type":"object"}`, `[]`, []string{" type: must be object, got array"}},
+		{"integer accepts whole float", `{"type":"integer"}`, `3.0`, nil},
+		{"integer rejects fraction", `{"type":"integer"}`, `3.5`, []string{" type: must be integer, got number"}},
+		{"number accepts integer", `{"type":"number"}`, `3`, nil},
+		{"nullable", `{"type":["string","null"]}`, `null`, nil},
+		{"enum", `{"enum":["a",1,null]}`, `"b"`, []string{` enum: must be one of: "a", 1, null`}},
+		{"const", `{"const":{"a":1}}`, `{"a":1}`, nil},
+		{"const mismatch", `{"const":true}`, `false`, []string{" const: must be true"}},
+		{"number bounds", `{"minimum":1,"exclusiveMaximum":10,"multipleOf":0.5}`, `10`, []string{" exclusiveMaximum: must be < 10"}},
+		{"multipleOf", `{"multipleOf":0.1}`, `0.3`, nil},
+		{"multipleOf miss", `{"multipleOf":2}`, `3`, []string{" multipleOf: must be a multiple of 2"}},
+		{"string length runes", `{"minLength":3}`, `"日本"`, []string{" minLength: must be at least 3 characters"}},
+		{"pattern", `{"pattern":"^[a-z]+$"}`, `"abc1"`, []string{" pattern: must match pattern ^[a-z]+$"}},
+		{"format date-time", `{"format":"date-time"}`, `"2024-01-02T03:04:05Z"`, nil},
+		{"format email", `{"format":"email"}`, `"not-an-email"`, []string{" format: must be a valid email"}},
+		{"format unknown passes", `{"format":"hostname-ish"}`, `"anything"`, nil},
+		{"keywords ignore other types", `{"minLength":3,"minimum":5}`, `true`, nil},
+		{
+			"object",
+			`{"type":"object","required":["id","name"],"properties":{"id":{"type":"integer"},"name":{"type":"string"}},"additionalProperties":false}`,
+			`{"id":"x","extra":1}`,
+			[]string{"/name required: is required", "/id type: must be integer, got string", "/extra additionalProperties: is not allowed"},
+		},
+		{
+			"additionalProperties schema",
+			`{"properties":{"id":{}},"additionalProperties":{"type":"string"}}`,
+			`{"id":1,"a":"ok","b":2}`,
+			[]string{"/b type: must be string, got integer"},
+		},
+		{
+			"patternProperties",
+			`{"patternProperties":{"^x-":{"type":"string"}},"additionalProperties":false}`,
+			`{"x-a":"ok","x-b":1,"y":1}`,
+			[]string{"/x-b type: must be string, got integer", "/y additionalProperties: is not allowed"},
+		},
+		{"property count", `{"maxProperties":1}`, `{"a":1,"b":2}`, []string{" maxProperties: must have at most 1 properties"}},
+		{"pointer escaping", `{"required":["a/b"]}`, `{}`, []string{"/a~1b required: is required"}},
+		{
+			"array",
+			`{"type":"array","items":{"type":"integer","minimum":0},"minItems":1,"maxItems":3,"uniqueItems":true}`,
+			`[1,-1,1,2]`,
+			[]string{" maxItems: must have at most 3 items", " uniqueItems: must not contain duplicate items", "/1 minimum: must be >= 0"},
+		},
+		{"allOf", `{"allOf":[{"minimum":1},{"maximum":2}]}`, `3`, []string{" maximum: must be <= 2"}},
+		{"anyOf", `{"anyOf":[{"type":"string"},{"type":"integer"}]}`, `1.5`, []string{" anyOf: must match at least one of the anyOf schemas"}},
+		{"oneOf", `{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `1`, []string{" oneOf: must match exactly one of the oneOf schemas (matched 2)"}},
+		{"not", `{"not":{"type":"null"}}`, `null`, []string{" not: must not match the schema in not"}},
+		{"false schema", `{"properties":{"a":false}}`, `{"a":1}`, []string{"/a false: no value is allowed"}},
+		{
+			"ref",
+			`{"$defs":{"id":{"type":"integer","minimum":1}},"properties":{"ids":{"type":"array","items":{"$ref":"#/$defs/id"}}}}`,
+			`{"ids":[1,0]}`,
+			[]string{"/ids/1 minimum: must be >= 1"},
+		},
+		{
+			"recursive ref",
+			`{"type":"object","properties":{"name":{"type":"string"},"children":{"type":"array","items":{"$ref":"#"}}}}`,
+			`{"name":"a","children":[{"name":"b","children":[{"name":3}]}]}`,
+			[]string{"/children/0/children/0/name type: must be string, got integer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mustCompile(t, tt.schema)
+			var value any
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatalf("bad value JSON: %v", err)
+			}
+
+			var got []string
+			for _, e := range s.Validate(value) {
+				got = append(got, e.Path+" "+e.Keyword+": "+e.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSchema_Validate_SelfReference(t *testing.T) {
+	// A reference cycle that never descends into the value must not recurse forever
+	s := mustCompile(t, `{"$defs":{"a":{"$ref":"#/$defs/b"},"b":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`)
+	errs := s.Validate(1)
+	if len(errs) == 0 || errs[0].Message != "schema recursion too deep" {
+		t.Errorf("Validate() = %v, want recursion error", errs)
+	}
+}
+
+func TestCompile_YAMLValues(t *testing.T) {
+	// YAML decoding yields ints and map[string]interface{}; Compile normalizes them
+	s, err := Compile(map[string]any{
+		"type":     "object",
+		"required": []any{"n"},
+		"properties": map[string]any{
+			"n": map[string]any{"type": "integer", "maximum": 10},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if errs := s.Validate(map[string]any{"n": float64(11)}); len(errs) != 1 || errs[0].Keyword != "maximum" {
+		t.Errorf("Validate() = %v, want maximum violation", errs)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{"not an object", `"string"`, "must be an object or boolean"},
+		{"unknown type", `{"type":"text"}`, `type: unknown type "text"`},
+		{"bad pattern", `{"properties":{"a":{"pattern":"("}}}`, "/properties/a/pattern: invalid pattern"},
+		{"negative length", `{"minLength":-1}`, "minLength: must be a non-negative integer"},
+		{"zero multipleOf", `{"multipleOf":0}`, "multipleOf: must be greater than 0"},
+		{"empty enum", `{"enum":[]}`, "enum: must be a non-empty array"},
+		{"tuple items", `{"items":[{"type":"string"}]}`, "items: array form is not supported"},
+		{"remote ref", `{"$ref":"https://example.com/schema.json"}`, "only local references"},
+		{"missing ref", `{"$ref":"#/$defs/nope"}`, "not found"},
+		{"unsupported keyword", `{"if":{"type":"string"},"then":{"minLength":1}}`, "if: keyword is not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc any
+			if err := json.Unmarshal([]byte(tt.schema), &doc); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Compile(doc)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Compile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchema_Inline(t *testing.T) {
+	s := mustCompile(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$defs": {
+			"item": {"type": "object", "properties": {"sku": {"type": "string"}, "parts": {"type": "array", "items": {"$ref": "#/$defs/item"}}}}
+		},
+		"type": "object",
+		"properties": {"item": {"$ref": "#/$defs/item"}}
+	}`)
+
+	want := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"item": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"sku":   map[string]any{"type": "string"},
+					"parts": map[string]any{"type": "array", "items": map[string]any{}},
+				},
+			},
+		},
+	}
+	if got := s.Inline(); !reflect.DeepEqual(got, want) {
+		g, _ := json.Marshal(got)
+		t.Errorf("Inline() = %s", g)
+	}
+}
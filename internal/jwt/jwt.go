@@ -0,0 +1,342 @@
+// Package jwt signs and verifies compact JSON Web Tokens with HS256 or RS256.
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Supported algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+// minHMACKeyLen is the minimum HS256 secret length in bytes (RFC 7518 section 3.2)
+const minHMACKeyLen = 32
+
+// minRSABits is the minimum RSA modulus size
+const minRSABits = 2048
+
+// ErrInvalidKey is returned (wrapped) when a key cannot be used, as opposed to a
+// token that fails verification
+var ErrInvalidKey = errors.New("invalid key")
+
+// Sign creates a token for claims. exp and nbf claims given as Go durations
+// ("15m") are relative to now; iat is set to now unless present.
+func Sign(claims map[string]any, key, alg string) (string, error) {
+	var signer func(signingInput []byte) ([]byte, error)
+	switch alg {
+	case HS256:
+		secret, err := hmacKey(key)
+		if err != nil {
+			return "", err
+		}
+		signer = func(in []byte) ([]byte, error) { return hmacSHA256(secret, in), nil }
+	case RS256:
+		priv, err := rsaPrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+		signer = func(in []byte) ([]byte, error) {
+			digest := sha256.Sum256(in)
+			return rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+		}
+	default:
+		return "", fmt.Errorf("unsupported algorithm %q (use HS256 or RS256)", alg)
+	}
+
+	now := time.Now()
+	payload := make(map[string]any, len(claims)+1)
+	for k, v := range claims {
+		payload[k] = v
+	}
+	for _, name := range []string{"exp", "nbf"} {
+		if s, ok := payload[name].(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return "", fmt.Errorf("claim %s: %q is not a number or duration", name, s)
+			}
+			payload[name] = now.Add(d).Unix()
+		}
+	}
+	if _, ok := payload["iat"]; !ok {
+		payload["iat"] = now.Unix()
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("encoding claims: %w", err)
+	}
+	signingInput := encode(header) + "." + encode(body)
+	sig, err := signer([]byte(signingInput))
+	if err != nil {
+		return "", fmt.Errorf("signing: %w", err)
+	}
+	return signingInput + "." + encode(sig), nil
+}
+
+// Verify checks the token's signature and its exp and nbf claims, and returns the
+// claims. The key decides the algorithm: a PEM public key, certificate or private
+// key accepts only RS256 tokens, anything else is an HS256 secret. A leading
+// "Bearer " is ignored so Authorization headers can be passed as-is.
+func Verify(token, key string) (map[string]any, error) {
+	token = strings.TrimSpace(token)
+	if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJSON(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature")
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+
+	if isPEM(key) {
+		pub, err := rsaPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if header.Alg != RS256 {
+			return nil, fmt.Errorf("algorithm %q does not match the RSA key", header.Alg)
+		}
+		digest := sha256.Sum256(signingInput)
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return nil, fmt.Errorf("invalid signature")
+		}
+	} else {
+		secret, err := hmacKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if header.Alg != HS256 {
+			return nil, fmt.Errorf("algorithm %q does not match the HMAC key", header.Alg)
+		}
+		if !hmac.Equal(sig, hmacSHA256(secret, signingInput)) {
+			return nil, fmt.Errorf("invalid signature")
+		}
+	}
+
+	var claims map[string]any
+	if err := decodeJSON(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+	if claims == nil {
+		return nil, fmt.Errorf("malformed claims: not an object")
+	}
+	now := time.Now().Unix()
+	if exp, ok, err := numericClaim(claims, "exp"); err != nil {
+		return nil, err
+	} else if ok && now >= exp {
+		return nil, fmt.Errorf("token expired")
+	}
+	if nbf, ok, err := numericClaim(claims, "nbf"); err != nil {
+		return nil, err
+	} else if ok && now < nbf {
+		return nil, fmt.Errorf("token not valid yet")
+	}
+	return claims, nil
+}
+
+// numericClaim returns a NumericDate claim in whole seconds
+func numericClaim(claims map[string]any, name string) (int64, bool, error) {
+	v, ok := claims[name]
+	if !ok {
+		return 0, false, nil
+	}
+	switch n := v.(type) {
+	case int64:
+		return n, true, nil
+	case float64:
+		return int64(math.Floor(n)), true, nil
+	}
+	return 0, false, fmt.Errorf("claim %s is not a number", name)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeJSON decodes a base64url JSON segment, keeping whole numbers as int64
+func decodeJSON(segment string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if m, ok := v.(*map[string]any); ok {
+		for k, val := range *m {
+			(*m)[k] = normalizeNumbers(val)
+		}
+	}
+	return nil
+}
+
+// normalizeNumbers converts json.Number to int64 when whole, else float64
+func normalizeNumbers(v any) any {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]any:
+		for k, val := range x {
+			x[k] = normalizeNumbers(val)
+		}
+	case []any:
+		for i, val := range x {
+			x[i] = normalizeNumbers(val)
+		}
+	}
+	return v
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hmacKey(key string) ([]byte, error) {
+	if len(key) < minHMACKeyLen {
+		return nil, fmt.Errorf("%w: HS256 secret must be at least %d bytes, got %d", ErrInvalidKey, minHMACKeyLen, len(key))
+	}
+	return []byte(key), nil
+}
+
+func isPEM(key string) bool {
+	return strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN ")
+}
+
+// keyCache caches parsed RSA keys by PEM text, bounded like the template regex cache
+var (
+	keyCache     sync.Map
+	keyCacheSize int64
+)
+
+const keyCacheMaxSize = 100
+
+func cachedKey(pemText string, parse func(*pem.Block) (any, error)) (any, error) {
+	if k, ok := keyCache.Load(pemText); ok {
+		return k, nil
+	}
+	block, _ := pem.Decode([]byte(strings.TrimSpace(pemText)))
+	if block == nil {
+		return nil, fmt.Errorf("%w: not a PEM block", ErrInvalidKey)
+	}
+	k, err := parse(block)
+	if err != nil {
+		return nil, err
+	}
+	if atomic.LoadInt64(&keyCacheSize) < keyCacheMaxSize {
+		if _, loaded := keyCache.LoadOrStore(pemText, k); !loaded {
+			atomic.AddInt64(&keyCacheSize, 1)
+		}
+	}
+	return k, nil
+}
+
+// rsaPrivateKey parses a PKCS#1 or PKCS#8 PEM private key
+func rsaPrivateKey(pemText string) (*rsa.PrivateKey, error) {
+	if !isPEM(pemText) {
+		return nil, fmt.Errorf("%w: RS256 needs a PEM private key", ErrInvalidKey)
+	}
+	k, err := cachedKey(pemText, parsePEMKey)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: RS256 signing needs an RSA private key", ErrInvalidKey)
+	}
+	return priv, nil
+}
+
+// rsaPublicKey parses a PEM public key, certificate or private key
+func rsaPublicKey(pemText string) (*rsa.PublicKey, error) {
+	k, err := cachedKey(pemText, parsePEMKey)
+	if err != nil {
+		return nil, err
+	}
+	switch key := k.(type) {
+	case *rsa.PublicKey:
+		return key, nil
+	case *rsa.PrivateKey:
+		return &key.PublicKey, nil
+	}
+	return nil, fmt.Errorf("%w: not an RSA key", ErrInvalidKey)
+}
+
+// parsePEMKey parses the key types RS256 accepts and checks the modulus size
+func parsePEMKey(block *pem.Block) (any, error) {
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported PEM block %q", ErrInvalidKey, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	var bits int
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		bits = k.N.BitLen()
+	case *rsa.PublicKey:
+		bits = k.N.BitLen()
+	default:
+		return nil, fmt.Errorf("%w: not an RSA key", ErrInvalidKey)
+	}
+	if bits < minRSABits {
+		return nil, fmt.Errorf("%w: RSA key must be at least %d bits, got %d", ErrInvalidKey, minRSABits, bits)
+	}
+	return key, nil
+}
@@ -0,0 +1,174 @@
+package kafka
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// newCluster starts an in-process Kafka cluster with the topic "orders"
+func newCluster(t *testing.T, partitions int32, opts ...kfake.Opt) *kfake.Cluster {
+	t.Helper()
+	c, err := kfake.NewCluster(append([]kfake.Opt{kfake.NumBrokers(2), kfake.SeedTopics(partitions, "orders")}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// consume reads n records of a topic from the start
+func consume(t *testing.T, brokers []string, topic string, n int, opts ...kgo.Opt) []*kgo.Record {
+	t.Helper()
+	client, err := kgo.NewClient(append([]kgo.Opt{
+		kgo.SeedBrokers(brokers...),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var records []*kgo.Record
+	for len(records) < n {
+		fetches := client.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("consumed %d of %d records: %v", len(records), n, err)
+		}
+		records = append(records, fetches.Records()...)
+	}
+	return records
+}
+
+func TestProducer_Produce(t *testing.T) {
+	cluster := newCluster(t, 4)
+	p, err := New(Config{Brokers: cluster.ListenAddrs()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	rec := Record{Topic: "orders", Key: []byte("foobar"), Value: []byte(`{"id":42}`), Headers: []Header{{Key: "idempotency-key", Value: []byte("abc")}}}
+	for range 2 {
+		if err := p.Produce(ctx, rec); err != nil {
+			t.Fatalf("Produce: %v", err)
+		}
+	}
+
+	records := consume(t, cluster.ListenAddrs(), "orders", 2)
+	if len(records) != 2 {
+		t.Fatalf("records = %d", len(records))
+	}
+	// The Java client's partitioner: murmur2("foobar") & 0x7fffffff % 4
+	for _, got := range records {
+		if got.Partition != 2 || string(got.Key) != "foobar" || string(got.Value) != `{"id":42}` {
+			t.Errorf("record = partition %d key %s value %s, want partition 2", got.Partition, got.Key, got.Value)
+		}
+		if len(got.Headers) != 1 || got.Headers[0].Key != "idempotency-key" || string(got.Headers[0].Value) != "abc" {
+			t.Errorf("headers = %+v", got.Headers)
+		}
+	}
+	if records[0].Offset+1 != records[1].Offset {
+		t.Errorf("offsets = %d, %d", records[0].Offset, records[1].Offset)
+	}
+}
+
+func TestProducer_Errors(t *testing.T) {
+	cluster := newCluster(t, 1)
+	p, _ := New(Config{Brokers: cluster.ListenAddrs()})
+	defer p.Close()
+	ctx := context.Background()
+
+	if err := p.Produce(ctx, Record{Topic: "missing", Value: []byte("x")}); err == nil || !strings.Contains(err.Error(), "UNKNOWN_TOPIC_OR_PARTITION") {
+		t.Errorf("missing topic err = %v", err)
+	}
+
+	unreachable, _ := New(Config{Brokers: []string{"127.0.0.1:1"}, Timeout: time.Second})
+	defer unreachable.Close()
+	if err := unreachable.Produce(ctx, Record{Topic: "orders", Value: []byte("x")}); err == nil {
+		t.Error("expected connection error")
+	}
+}
+
+func TestProducer_SASL(t *testing.T) {
+	cluster := newCluster(t, 1, kfake.EnableSASL(), kfake.Superuser("PLAIN", "proxy", "secret"))
+
+	p, _ := New(Config{Brokers: cluster.ListenAddrs(), Username: "proxy", Password: "secret"})
+	if err := p.Produce(context.Background(), Record{Topic: "orders", Value: []byte("x")}); err != nil {
+		t.Fatalf("Produce: %v", err)
+	}
+	p.Close()
+
+	p, _ = New(Config{Brokers: cluster.ListenAddrs(), Username: "proxy", Password: "wrong", Timeout: time.Second})
+	defer p.Close()
+	// Authentication failures are retried like connection errors until the record times out
+	if err := p.Produce(context.Background(), Record{Topic: "orders", Value: []byte("x")}); err == nil {
+		t.Error("expected authentication error")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Error("expected error without brokers")
+	}
+	if _, err := New(Config{Brokers: []string{"kafka.local"}}); err == nil {
+		t.Error("expected error for broker without port")
+	}
+}
+
+// TestProducer_Broker produces to a real cluster when KAFKA_BROKERS is set
+// (comma-separated host:port). The topic is created on first use.
+func TestProducer_Broker(t *testing.T) {
+	brokers := os.Getenv("KAFKA_BROKERS")
+	if brokers == "" {
+		t.Skip("KAFKA_BROKERS not set, skipping Kafka integration test")
+	}
+	seeds := strings.Split(brokers, ",")
+	topic := "sql-proxy-test-" + time.Now().Format("20060102150405")
+
+	// The producer doesn't create topics; a client that does creates this one, with
+	// a record of the same key so all of them are on one partition, in order
+	setup, err := kgo.NewClient(kgo.SeedBrokers(seeds...), kgo.AllowAutoTopicCreation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := setup.ProduceSync(ctx, &kgo.Record{Topic: topic, Key: []byte("order-42"), Value: []byte("setup")}).FirstErr(); err != nil {
+		t.Fatalf("create topic: %v", err)
+	}
+	setup.Close()
+
+	p, err := New(Config{Brokers: seeds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	for i := range 3 {
+		rec := Record{Topic: topic, Key: []byte("order-42"), Value: []byte{byte('0' + i)}, Headers: []Header{{Key: "topic", Value: []byte("orders")}}}
+		if err := p.Produce(ctx, rec); err != nil {
+			t.Fatalf("Produce: %v", err)
+		}
+	}
+
+	records := consume(t, seeds, topic, 4)
+	var values []string
+	for _, r := range records[1:] {
+		values = append(values, string(r.Value))
+		if string(r.Key) != "order-42" || len(r.Headers) != 1 {
+			t.Errorf("record = key %s headers %+v", r.Key, r.Headers)
+		}
+	}
+	if strings.Join(values, ",") != "0,1,2" {
+		t.Errorf("values = %v, want 0,1,2 in order", values)
+	}
+}
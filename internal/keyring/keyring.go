@@ -0,0 +1,135 @@
+// Package keyring provides AES-256-GCM encryption with named, rotatable keys so
+// workflows can store sensitive columns encrypted and decrypt them on demand.
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Prefix marks values produced by Encrypt: enc:<key id>:<base64 nonce+ciphertext>
+const Prefix = "enc:"
+
+// KeySize is the required key length in bytes (AES-256)
+const KeySize = 32
+
+// KeyConfig represents configuration for one key
+type KeyConfig struct {
+	ID  string `yaml:"id"`  // Stored with each ciphertext to select the key on decrypt
+	Key string `yaml:"key"` // Base64-encoded 32-byte key
+}
+
+// Keyring encrypts with the active key and decrypts with any configured key
+type Keyring struct {
+	active string
+	aeads  map[string]cipher.AEAD
+}
+
+var keyIDRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// New creates a keyring. active names the key used by Encrypt; older keys stay
+// configured so values encrypted before a rotation still decrypt.
+func New(active string, keys []KeyConfig) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one key is required")
+	}
+
+	k := &Keyring{
+		active: active,
+		aeads:  make(map[string]cipher.AEAD, len(keys)),
+	}
+	for _, kc := range keys {
+		if !keyIDRegex.MatchString(kc.ID) {
+			return nil, fmt.Errorf("invalid key id %q: use letters, digits, '_', '.' and '-'", kc.ID)
+		}
+		if _, dup := k.aeads[kc.ID]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", kc.ID)
+		}
+		raw, err := base64.StdEncoding.DecodeString(kc.Key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: not valid base64: %w", kc.ID, err)
+		}
+		if len(raw) != KeySize {
+			return nil, fmt.Errorf("key %q: must decode to %d bytes, got %d", kc.ID, KeySize, len(raw))
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kc.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kc.ID, err)
+		}
+		k.aeads[kc.ID] = aead
+	}
+
+	if _, ok := k.aeads[active]; !ok {
+		return nil, fmt.Errorf("active key %q is not configured", active)
+	}
+	return k, nil
+}
+
+// Encrypt encrypts plaintext with the active key. The empty string is returned
+// unchanged so optional columns stay empty.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	aead := k.aeads[k.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+	// The key id is authenticated so a ciphertext cannot be relabeled with another key
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.active))
+	return Prefix + k.active + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt with whichever key encrypted it.
+// The empty string is returned unchanged.
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	rest, ok := strings.CutPrefix(ciphertext, Prefix)
+	if !ok {
+		return "", fmt.Errorf("value is not encrypted (missing %q prefix)", Prefix)
+	}
+	id, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", fmt.Errorf("invalid encrypted value format")
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("unknown key id %q", id)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value encoding: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("encrypted value is too short")
+	}
+	nonce, body := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, body, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("decryption failed: value was modified or encrypted with a different key")
+	}
+	return string(plaintext), nil
+}
+
+// Active returns the id of the key used by Encrypt
+func (k *Keyring) Active() string {
+	return k.active
+}
+
+// HasKey checks if a key id is configured
+func (k *Keyring) HasKey(id string) bool {
+	_, ok := k.aeads[id]
+	return ok
+}
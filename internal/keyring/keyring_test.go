@@ -0,0 +1,188 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var (
+	key2023 = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	key2024 = base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		active  string
+		keys    []KeyConfig
+		wantErr string
+	}{
+		{
+			name:   "valid configuration",
+			active: "pii-2024",
+			keys:   []KeyConfig{{ID: "pii-2024", Key: key2024}, {ID: "pii-2023", Key: key2023}},
+		},
+		{
+			name:    "no keys",
+			active:  "pii-2024",
+			wantErr: "at least one key",
+		},
+		{
+			name:    "active key missing",
+			active:  "pii-2025",
+			keys:    []KeyConfig{{ID: "pii-2024", Key: key2024}},
+			wantErr: `active key "pii-2025" is not configured`,
+		},
+		{
+			name:    "invalid id",
+			active:  "a:b",
+			keys:    []KeyConfig{{ID: "a:b", Key: key2024}},
+			wantErr: "invalid key id",
+		},
+		{
+			name:    "duplicate id",
+			active:  "k",
+			keys:    []KeyConfig{{ID: "k", Key: key2024}, {ID: "k", Key: key2023}},
+			wantErr: "duplicate key id",
+		},
+		{
+			name:    "key not base64",
+			active:  "k",
+			keys:    []KeyConfig{{ID: "k", Key: "not base64!"}},
+			wantErr: "not valid base64",
+		},
+		{
+			name:    "key too short",
+			active:  "k",
+			keys:    []KeyConfig{{ID: "k", Key: base64.StdEncoding.EncodeToString([]byte("short"))}},
+			wantErr: "must decode to 32 bytes, got 5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.active, tt.keys)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("New() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k, err := New("pii-2024", []KeyConfig{{ID: "pii-2024", Key: key2024}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for _, plaintext := range []string{"123-45-6789", "ünïcödé", strings.Repeat("x", 10000)} {
+		enc, err := k.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt() error = %v", err)
+		}
+		if !strings.HasPrefix(enc, "enc:pii-2024:") {
+			t.Errorf("Encrypt() = %q, want enc:pii-2024: prefix", enc)
+		}
+		if strings.Contains(enc, plaintext) {
+			t.Errorf("Encrypt() output contains the plaintext")
+		}
+		dec, err := k.Decrypt(enc)
+		if err != nil {
+			t.Fatalf("Decrypt() error = %v", err)
+		}
+		if dec != plaintext {
+			t.Errorf("Decrypt() = %q, want %q", dec, plaintext)
+		}
+	}
+
+	// Random nonces: the same plaintext encrypts differently each time
+	a, _ := k.Encrypt("same")
+	b, _ := k.Encrypt("same")
+	if a == b {
+		t.Error("Encrypt() should not be deterministic")
+	}
+
+	// Empty values pass through
+	if enc, err := k.Encrypt(""); enc != "" || err != nil {
+		t.Errorf("Encrypt(\"\") = %q, %v, want empty", enc, err)
+	}
+	if dec, err := k.Decrypt(""); dec != "" || err != nil {
+		t.Errorf("Decrypt(\"\") = %q, %v, want empty", dec, err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old, err := New("pii-2023", []KeyConfig{{ID: "pii-2023", Key: key2023}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	legacy, err := old.Encrypt("secret")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	rotated, err := New("pii-2024", []KeyConfig{{ID: "pii-2024", Key: key2024}, {ID: "pii-2023", Key: key2023}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if dec, err := rotated.Decrypt(legacy); err != nil || dec != "secret" {
+		t.Errorf("Decrypt(legacy) = %q, %v, want secret", dec, err)
+	}
+	fresh, _ := rotated.Encrypt("secret")
+	if !strings.HasPrefix(fresh, "enc:pii-2024:") {
+		t.Errorf("Encrypt() after rotation = %q, want the active key", fresh)
+	}
+	if rotated.Active() != "pii-2024" || !rotated.HasKey("pii-2023") || rotated.HasKey("pii-2022") {
+		t.Error("Active()/HasKey() mismatch")
+	}
+
+	// A keyring without the key cannot decrypt values encrypted with it
+	if _, err := old.Decrypt(fresh); err == nil || !strings.Contains(err.Error(), `unknown key id "pii-2024"`) {
+		t.Errorf("Decrypt() with missing key error = %v", err)
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	k, err := New("k1", []KeyConfig{{ID: "k1", Key: key2024}, {ID: "k2", Key: key2023}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	enc, _ := k.Encrypt("secret")
+	payload := strings.TrimPrefix(enc, "enc:k1:")
+	tampered := []byte(payload)
+	if tampered[20] == 'A' {
+		tampered[20] = 'B'
+	} else {
+		tampered[20] = 'A'
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{"plaintext", "secret", "not encrypted"},
+		{"no key id", "enc:abc", "invalid encrypted value format"},
+		{"unknown key", "enc:k9:" + payload, `unknown key id "k9"`},
+		{"bad base64", "enc:k1:***", "invalid encrypted value encoding"},
+		{"too short", "enc:k1:AAAA", "too short"},
+		{"tampered", "enc:k1:" + string(tampered), "decryption failed"},
+		{"relabeled key", "enc:k2:" + payload, "decryption failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := k.Decrypt(tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Decrypt() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Package loadshed rejects low-priority requests while the process is
+// overloaded, so the requests that matter keep running instead of everything
+// slowing down until the process runs out of memory.
+//
+// Load is measured against configured limits on heap usage, in-flight requests,
+// and the time queries queue for a database connection. Each limit marks where
+// shedding starts: at the limit low-priority requests are rejected, at 125% of
+// it normal-priority ones too, and at 150% high-priority ones. Critical
+// requests are never shed.
+package loadshed
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sql-proxy/internal/logging"
+)
+
+// Request priorities, lowest first
+const (
+	PriorityLow      = "low"
+	PriorityNormal   = "normal" // Default
+	PriorityHigh     = "high"
+	PriorityCritical = "critical"
+)
+
+// Priorities lists the priorities that can be shed, lowest first
+var Priorities = []string{PriorityLow, PriorityNormal, PriorityHigh}
+
+// thresholds is the load, as a fraction of a limit, at which each priority is shed
+var thresholds = map[string]float64{
+	PriorityLow:    1.0,
+	PriorityNormal: 1.25,
+	PriorityHigh:   1.5,
+}
+
+// ValidPriority reports whether p is a priority ("" is normal)
+func ValidPriority(p string) bool {
+	return p == "" || p == PriorityCritical || thresholds[p] > 0
+}
+
+// DefaultRetryAfterSec is the Retry-After of rejected requests when the config sets none
+const DefaultRetryAfterSec = 5
+
+// sampleInterval is how often heap usage and queue wait are sampled
+const sampleInterval = time.Second
+
+// heapMetric is the heap memory occupied by objects, live or not yet swept
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// Config sets the limits at which shedding starts. A zero limit is not checked.
+type Config struct {
+	MaxHeapBytes  uint64
+	MaxInFlight   int64
+	MaxQueueWait  time.Duration
+	RetryAfterSec int // Default: DefaultRetryAfterSec
+}
+
+// QueueWaitFunc returns the average time queries waited for a connection since
+// its previous call, or 0 if none waited.
+type QueueWaitFunc func() time.Duration
+
+// Shedder admits or rejects requests by priority
+type Shedder struct {
+	cfg       Config
+	queueWait QueueWaitFunc
+	heapBytes func() uint64
+
+	inFlight atomic.Int64
+	sampled  atomic.Uint64            // math.Float64bits of the load from heap usage and queue wait at the last sample
+	rejected map[string]*atomic.Int64 // Per sheddable priority
+
+	mu        sync.Mutex // Guards the sampled values below, for Stats
+	heap      uint64
+	wait      time.Duration
+	shedding  bool // At the last sample, for logging changes
+	sampledAt time.Time
+}
+
+// Stats is a point-in-time view of the shedder
+type Stats struct {
+	Load          float64          `json:"load"`          // Highest of the measures as a fraction of their limits
+	Shedding      []string         `json:"shedding"`      // Priorities currently rejected
+	InFlight      int64            `json:"inflight"`      // Requests admitted and not yet finished
+	HeapBytes     uint64           `json:"heap_bytes"`    // At the last sample
+	QueueWaitMs   int64            `json:"queue_wait_ms"` // At the last sample
+	Rejected      map[string]int64 `json:"rejected"`      // Total rejected requests per priority
+	SampledAt     time.Time        `json:"sampled_at"`    // Heap usage and queue wait are sampled every second
+	RetryAfterSec int              `json:"retry_after_sec"`
+}
+
+// New creates a shedder. queueWait may be nil when the queue wait is not limited.
+func New(cfg Config, queueWait QueueWaitFunc) *Shedder {
+	if cfg.RetryAfterSec <= 0 {
+		cfg.RetryAfterSec = DefaultRetryAfterSec
+	}
+	s := &Shedder{
+		cfg:       cfg,
+		queueWait: queueWait,
+		heapBytes: readHeapBytes,
+		rejected:  make(map[string]*atomic.Int64, len(Priorities)),
+	}
+	for _, p := range Priorities {
+		s.rejected[p] = &atomic.Int64{}
+	}
+	return s
+}
+
+func readHeapBytes() uint64 {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// Run samples heap usage and queue wait every second until ctx is cancelled
+func (s *Shedder) Run(ctx context.Context) {
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.sample(now)
+		}
+	}
+}
+
+// sample measures heap usage and queue wait and logs when shedding starts or stops
+func (s *Shedder) sample(now time.Time) {
+	var heap uint64
+	var wait time.Duration
+	var load float64
+	if s.cfg.MaxHeapBytes > 0 {
+		heap = s.heapBytes()
+		load = float64(heap) / float64(s.cfg.MaxHeapBytes)
+	}
+	if s.cfg.MaxQueueWait > 0 && s.queueWait != nil {
+		wait = s.queueWait()
+		load = max(load, float64(wait)/float64(s.cfg.MaxQueueWait))
+	}
+	s.sampled.Store(math.Float64bits(load))
+
+	s.mu.Lock()
+	s.heap, s.wait, s.sampledAt = heap, wait, now
+	shedding := load >= thresholds[PriorityLow]
+	changed := shedding != s.shedding
+	s.shedding = shedding
+	s.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	fields := map[string]any{
+		"load":          load,
+		"heap_bytes":    heap,
+		"queue_wait_ms": wait.Milliseconds(),
+		"inflight":      s.inFlight.Load(),
+	}
+	if shedding {
+		logging.Warn("load_shedding_started", fields)
+	} else {
+		logging.Info("load_shedding_stopped", fields)
+	}
+}
+
+// load returns the current load with n requests in flight
+func (s *Shedder) load(n int64) float64 {
+	load := math.Float64frombits(s.sampled.Load())
+	if s.cfg.MaxInFlight > 0 {
+		load = max(load, float64(n)/float64(s.cfg.MaxInFlight))
+	}
+	return load
+}
+
+// Admit decides whether a request of the priority may run. An admitted request
+// must call release when it finishes; a rejected one gets the seconds after
+// which to retry.
+func (s *Shedder) Admit(priority string) (release func(), retryAfterSec int, ok bool) {
+	if priority == "" {
+		priority = PriorityNormal
+	}
+	n := s.inFlight.Add(1) - 1 // Requests already in flight
+	if threshold, sheddable := thresholds[priority]; sheddable && s.load(n) >= threshold {
+		s.inFlight.Add(-1)
+		s.rejected[priority].Add(1)
+		return nil, s.cfg.RetryAfterSec, false
+	}
+	return func() { s.inFlight.Add(-1) }, 0, true
+}
+
+// Stats returns the current load and the requests rejected so far
+func (s *Shedder) Stats() Stats {
+	n := s.inFlight.Load()
+	stats := Stats{
+		Load:          s.load(n),
+		Shedding:      []string{},
+		InFlight:      n,
+		Rejected:      make(map[string]int64, len(s.rejected)),
+		RetryAfterSec: s.cfg.RetryAfterSec,
+	}
+	for _, p := range Priorities {
+		if stats.Load >= thresholds[p] {
+			stats.Shedding = append(stats.Shedding, p)
+		}
+		stats.Rejected[p] = s.rejected[p].Load()
+	}
+	s.mu.Lock()
+	stats.HeapBytes, stats.QueueWaitMs, stats.SampledAt = s.heap, s.wait.Milliseconds(), s.sampledAt
+	s.mu.Unlock()
+	return stats
+}
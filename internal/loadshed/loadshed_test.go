@@ -0,0 +1,110 @@
+package loadshed
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// admitted reports which priorities a shedder admits now, releasing them again
+func admitted(s *Shedder) []string {
+	var got []string
+	for _, p := range []string{PriorityLow, PriorityNormal, PriorityHigh, PriorityCritical} {
+		if release, _, ok := s.Admit(p); ok {
+			release()
+			got = append(got, p)
+		}
+	}
+	return got
+}
+
+func TestShedder_InFlight(t *testing.T) {
+	s := New(Config{MaxInFlight: 4}, nil)
+
+	var releases []func()
+	hold := func(n int) {
+		for range n {
+			release, _, ok := s.Admit(PriorityCritical)
+			if !ok {
+				t.Fatal("critical request rejected")
+			}
+			releases = append(releases, release)
+		}
+	}
+
+	tests := []struct {
+		inFlight int
+		want     []string
+	}{
+		{3, []string{"low", "normal", "high", "critical"}},
+		{4, []string{"normal", "high", "critical"}}, // At the limit
+		{5, []string{"high", "critical"}},           // 125%
+		{6, []string{"critical"}},                   // 150%
+	}
+	for _, tt := range tests {
+		hold(tt.inFlight - len(releases))
+		if got := admitted(s); !slices.Equal(got, tt.want) {
+			t.Errorf("with %d in flight admitted %v, want %v", tt.inFlight, got, tt.want)
+		}
+	}
+
+	for _, release := range releases {
+		release()
+	}
+	if got := admitted(s); len(got) != 4 {
+		t.Errorf("after release admitted %v, want all", got)
+	}
+	stats := s.Stats()
+	if stats.InFlight != 0 || stats.Rejected["low"] != 3 || stats.Rejected["normal"] != 2 || stats.Rejected["high"] != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestShedder_Sample(t *testing.T) {
+	heap := uint64(0)
+	wait := time.Duration(0)
+	s := New(Config{MaxHeapBytes: 1000, MaxQueueWait: 100 * time.Millisecond, RetryAfterSec: 3}, func() time.Duration { return wait })
+	s.heapBytes = func() uint64 { return heap }
+
+	heap = 1300 // 130% of max_heap
+	s.sample(time.Now())
+	if got := admitted(s); !slices.Equal(got, []string{"high", "critical"}) {
+		t.Errorf("over the heap limit admitted %v", got)
+	}
+	if _, retryAfter, ok := s.Admit(PriorityLow); ok || retryAfter != 3 {
+		t.Errorf("Admit(low) = %d, %v; want rejected with retry after 3", retryAfter, ok)
+	}
+
+	heap, wait = 100, 100*time.Millisecond // Queue wait at its limit
+	s.sample(time.Now())
+	if got := admitted(s); !slices.Equal(got, []string{"normal", "high", "critical"}) {
+		t.Errorf("at the queue wait limit admitted %v", got)
+	}
+	stats := s.Stats()
+	if stats.Load != 1 || stats.HeapBytes != 100 || stats.QueueWaitMs != 100 || !slices.Equal(stats.Shedding, []string{"low"}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	wait = 0
+	s.sample(time.Now())
+	if got := admitted(s); len(got) != 4 {
+		t.Errorf("without pressure admitted %v, want all", got)
+	}
+}
+
+func TestReadHeapBytes(t *testing.T) {
+	if readHeapBytes() == 0 {
+		t.Error("expected a non-zero heap size")
+	}
+}
+
+func TestValidPriority(t *testing.T) {
+	for _, p := range []string{"", "low", "normal", "high", "critical"} {
+		if !ValidPriority(p) {
+			t.Errorf("ValidPriority(%q) = false", p)
+		}
+	}
+	if ValidPriority("urgent") {
+		t.Error("ValidPriority(urgent) = true")
+	}
+}
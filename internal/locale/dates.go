@@ -0,0 +1,105 @@
+package locale
+
+import "golang.org/x/text/language"
+
+// dateLocale holds the Gregorian date formats and names of one locale
+type dateLocale struct {
+	short, medium, long, full string
+	months, monthsAbbr        [12]string // Format (not stand-alone) forms
+	weekdays                  [7]string  // Wide, starting with Sunday
+}
+
+var (
+	enMonths   = [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	enAbbr     = [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+	enWeekdays = [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	cjkMonths  = [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"}
+)
+
+// dateTags and dateLocales are parallel: the bundled locales, from CLDR 44.
+// The first entry is the fallback.
+var dateTags = []language.Tag{
+	language.English,
+	language.BritishEnglish,
+	language.German,
+	language.French,
+	language.Spanish,
+	language.Italian,
+	language.Portuguese,
+	language.Dutch,
+	language.Swedish,
+	language.Polish,
+	language.Japanese,
+	language.Chinese,
+}
+
+var dateLocales = []*dateLocale{
+	{ // en
+		short: "M/d/yy", medium: "MMM d, y", long: "MMMM d, y", full: "EEEE, MMMM d, y",
+		months: enMonths, monthsAbbr: enAbbr, weekdays: enWeekdays,
+	},
+	{ // en-GB
+		short: "dd/MM/y", medium: "d MMM y", long: "d MMMM y", full: "EEEE d MMMM y",
+		months: enMonths, monthsAbbr: enAbbr, weekdays: enWeekdays,
+	},
+	{ // de
+		short: "dd.MM.yy", medium: "dd.MM.y", long: "d. MMMM y", full: "EEEE, d. MMMM y",
+		months:     [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		monthsAbbr: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		weekdays:   [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+	},
+	{ // fr
+		short: "dd/MM/y", medium: "d MMM y", long: "d MMMM y", full: "EEEE d MMMM y",
+		months:     [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		monthsAbbr: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		weekdays:   [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+	},
+	{ // es
+		short: "d/M/yy", medium: "d MMM y", long: "d 'de' MMMM 'de' y", full: "EEEE, d 'de' MMMM 'de' y",
+		months:     [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		monthsAbbr: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		weekdays:   [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+	},
+	{ // it
+		short: "dd/MM/yy", medium: "d MMM y", long: "d MMMM y", full: "EEEE d MMMM y",
+		months:     [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		monthsAbbr: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		weekdays:   [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+	},
+	{ // pt (Brazil)
+		short: "dd/MM/y", medium: "d 'de' MMM 'de' y", long: "d 'de' MMMM 'de' y", full: "EEEE, d 'de' MMMM 'de' y",
+		months:     [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		monthsAbbr: [12]string{"jan.", "fev.", "mar.", "abr.", "mai.", "jun.", "jul.", "ago.", "set.", "out.", "nov.", "dez."},
+		weekdays:   [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+	},
+	{ // nl
+		short: "dd-MM-y", medium: "d MMM y", long: "d MMMM y", full: "EEEE d MMMM y",
+		months:     [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		monthsAbbr: [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		weekdays:   [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+	},
+	{ // sv
+		short: "y-MM-dd", medium: "d MMM y", long: "d MMMM y", full: "EEEE d MMMM y",
+		months:     [12]string{"januari", "februari", "mars", "april", "maj", "juni", "juli", "augusti", "september", "oktober", "november", "december"},
+		monthsAbbr: [12]string{"jan.", "feb.", "mars", "apr.", "maj", "juni", "juli", "aug.", "sep.", "okt.", "nov.", "dec."},
+		weekdays:   [7]string{"söndag", "måndag", "tisdag", "onsdag", "torsdag", "fredag", "lördag"},
+	},
+	{ // pl (genitive month names, as used in dates)
+		short: "d.MM.y", medium: "d MMM y", long: "d MMMM y", full: "EEEE, d MMMM y",
+		months:     [12]string{"stycznia", "lutego", "marca", "kwietnia", "maja", "czerwca", "lipca", "sierpnia", "września", "października", "listopada", "grudnia"},
+		monthsAbbr: [12]string{"sty", "lut", "mar", "kwi", "maj", "cze", "lip", "sie", "wrz", "paź", "lis", "gru"},
+		weekdays:   [7]string{"niedziela", "poniedziałek", "wtorek", "środa", "czwartek", "piątek", "sobota"},
+	},
+	{ // ja
+		short: "y/MM/dd", medium: "y/MM/dd", long: "y年M月d日", full: "y年M月d日EEEE",
+		months: cjkMonths, monthsAbbr: cjkMonths,
+		weekdays: [7]string{"日曜日", "月曜日", "火曜日", "水曜日", "木曜日", "金曜日", "土曜日"},
+	},
+	{ // zh
+		short: "y/M/d", medium: "y年M月d日", long: "y年M月d日", full: "y年M月d日EEEE",
+		months: cjkMonths, monthsAbbr: cjkMonths,
+		weekdays: [7]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+	},
+}
+
+var dateMatcher = language.NewMatcher(dateTags)
@@ -0,0 +1,141 @@
+// Package locale formats numbers and dates by the conventions of a locale,
+// using Unicode CLDR data.
+package locale
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// Date styles, as in CLDR
+const (
+	Short  = "short"  // 3/1/24
+	Medium = "medium" // Mar 1, 2024
+	Long   = "long"   // March 1, 2024
+	Full   = "full"   // Friday, March 1, 2024
+)
+
+// printerCache caches number printers by locale tag, bounded like the template
+// regex cache since tags can come from request data
+var (
+	printerCache     sync.Map
+	printerCacheSize int64
+)
+
+const printerCacheMaxSize = 100
+
+// Parse parses a BCP 47 locale tag such as "de-DE" or "pt_BR"
+func Parse(tag string) (language.Tag, error) {
+	t, err := language.Parse(strings.ReplaceAll(tag, "_", "-"))
+	if err != nil {
+		return language.Und, fmt.Errorf("invalid locale %q", tag)
+	}
+	return t, nil
+}
+
+// FormatNumber formats n with exactly decimals fraction digits and the
+// locale's decimal and grouping separators ("1.234,50" in de-DE). Ties round
+// to even.
+func FormatNumber(n float64, decimals int, tag language.Tag) string {
+	return printer(tag).Sprint(number.Decimal(n, number.MinFractionDigits(decimals), number.MaxFractionDigits(decimals)))
+}
+
+func printer(tag language.Tag) *message.Printer {
+	key := tag.String()
+	if p, ok := printerCache.Load(key); ok {
+		return p.(*message.Printer)
+	}
+	p := message.NewPrinter(tag)
+	if atomic.LoadInt64(&printerCacheSize) < printerCacheMaxSize {
+		if _, loaded := printerCache.LoadOrStore(key, p); !loaded {
+			atomic.AddInt64(&printerCacheSize, 1)
+		}
+	}
+	return p
+}
+
+// FormatDate formats the date of t in a CLDR style (short, medium, long or
+// full). Locales without date data use the closest supported locale, falling
+// back to English.
+func FormatDate(t time.Time, style string, tag language.Tag) (string, error) {
+	_, i, _ := dateMatcher.Match(tag)
+	loc := dateLocales[i]
+	var pattern string
+	switch strings.ToLower(style) {
+	case Short:
+		pattern = loc.short
+	case Medium:
+		pattern = loc.medium
+	case Long:
+		pattern = loc.long
+	case Full:
+		pattern = loc.full
+	default:
+		return "", fmt.Errorf("unknown date style %q (use short, medium, long or full)", style)
+	}
+	return formatPattern(t, pattern, loc), nil
+}
+
+// formatPattern expands a CLDR date pattern. Only the fields the bundled
+// patterns use are supported: y, yy, M, MM, MMM, MMMM, d, dd, EEEE and quoted
+// literals.
+func formatPattern(t time.Time, pattern string, loc *dateLocale) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		if c == '\'' {
+			end := strings.IndexByte(pattern[i+1:], '\'')
+			if end < 0 {
+				b.WriteString(pattern[i+1:])
+				break
+			}
+			b.WriteString(pattern[i+1 : i+1+end])
+			i += end + 2
+			continue
+		}
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		n := 1
+		for i+n < len(pattern) && pattern[i+n] == c {
+			n++
+		}
+		switch c {
+		case 'y':
+			if n == 2 {
+				fmt.Fprintf(&b, "%02d", t.Year()%100)
+			} else {
+				b.WriteString(strconv.Itoa(t.Year()))
+			}
+		case 'M':
+			switch n {
+			case 1:
+				b.WriteString(strconv.Itoa(int(t.Month())))
+			case 2:
+				fmt.Fprintf(&b, "%02d", int(t.Month()))
+			case 3:
+				b.WriteString(loc.monthsAbbr[t.Month()-1])
+			default:
+				b.WriteString(loc.months[t.Month()-1])
+			}
+		case 'd':
+			fmt.Fprintf(&b, "%0*d", n, t.Day())
+		case 'E':
+			b.WriteString(loc.weekdays[t.Weekday()])
+		default:
+			b.WriteString(pattern[i : i+n])
+		}
+		i += n
+	}
+	return b.String()
+}
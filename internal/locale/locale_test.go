@@ -0,0 +1,95 @@
+package locale
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		n        float64
+		decimals int
+		tag      string
+		want     string
+	}{
+		{1234.5, 2, "en-US", "1,234.50"},
+		{1234.5, 2, "de-DE", "1.234,50"},
+		{-1234567.456, 2, "de-CH", "-1’234’567.46"},
+		{1234567.891, 0, "en-IN", "12,34,568"},
+		{0.5, 1, "pt_BR", "0,5"},
+		{1234.5, 0, "en", "1,234"}, // Ties round to even
+	}
+	for _, tt := range tests {
+		tag, err := Parse(tt.tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := FormatNumber(tt.n, tt.decimals, tag); got != tt.want {
+			t.Errorf("FormatNumber(%v, %d, %s) = %q, want %q", tt.n, tt.decimals, tt.tag, got, tt.want)
+		}
+	}
+
+	// French groups with a (narrow) no-break space
+	fr, _ := Parse("fr-FR")
+	if got := FormatNumber(1234.5, 2, fr); strings.ContainsAny(got, ". ") || !strings.HasSuffix(got, "234,50") {
+		t.Errorf("FormatNumber(fr-FR) = %q", got)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, tag := range []string{"", "not a locale", "en-US-!"} {
+		if _, err := Parse(tag); err == nil || !strings.Contains(err.Error(), "invalid locale") {
+			t.Errorf("Parse(%q) error = %v", tag, err)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(2024, 3, 1, 23, 30, 0, 0, time.FixedZone("", 3600))
+	tests := []struct {
+		tag   string
+		style string
+		want  string
+	}{
+		{"en-US", "short", "3/1/24"},
+		{"en-US", "medium", "Mar 1, 2024"},
+		{"en-US", "long", "March 1, 2024"},
+		{"en-US", "full", "Friday, March 1, 2024"},
+		{"en-GB", "short", "01/03/2024"},
+		{"en-AU", "long", "1 March 2024"}, // Closest bundled locale: en-GB
+		{"de-DE", "short", "01.03.24"},
+		{"de-AT", "long", "1. März 2024"},
+		{"de-DE", "full", "Freitag, 1. März 2024"},
+		{"fr-FR", "long", "1 mars 2024"},
+		{"fr-CA", "medium", "1 mars 2024"},
+		{"fr-FR", "FULL", "vendredi 1 mars 2024"},
+		{"es-ES", "long", "1 de marzo de 2024"},
+		{"it-IT", "medium", "1 mar 2024"},
+		{"pt-BR", "full", "sexta-feira, 1 de março de 2024"},
+		{"nl-NL", "short", "01-03-2024"},
+		{"sv-SE", "short", "2024-03-01"},
+		{"pl-PL", "long", "1 marca 2024"},
+		{"ja-JP", "full", "2024年3月1日金曜日"},
+		{"zh-CN", "short", "2024/3/1"},
+		{"fi-FI", "medium", "Mar 1, 2024"}, // No bundled data: English
+	}
+	for _, tt := range tests {
+		tag, err := Parse(tt.tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := FormatDate(date, tt.style, tag)
+		if err != nil {
+			t.Fatalf("FormatDate(%s, %s): %v", tt.tag, tt.style, err)
+		}
+		if got != tt.want {
+			t.Errorf("FormatDate(%s, %s) = %q, want %q", tt.tag, tt.style, got, tt.want)
+		}
+	}
+
+	en, _ := Parse("en")
+	if _, err := FormatDate(date, "iso", en); err == nil || !strings.Contains(err.Error(), "unknown date style") {
+		t.Errorf("expected unknown style error, got %v", err)
+	}
+}
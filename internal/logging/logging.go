@@ -1,6 +1,8 @@
 package logging
 
 import (
+	"context"
+	"errors"
 	"io"
 	"log/slog"
 	"os"
@@ -19,7 +21,7 @@ var (
 // Init initializes the global logger
 // If filePath is empty, logs to stdout; otherwise logs to file with rotation
 func Init(level, filePath string, maxSizeMB, maxBackups, maxAgeDays int) error {
-	levelVar.Set(parseLevel(level))
+	levelVar.Set(ParseLevel(level))
 
 	var w io.Writer
 	if filePath == "" {
@@ -59,7 +61,7 @@ func Init(level, filePath string, maxSizeMB, maxBackups, maxAgeDays int) error {
 
 // SetLevel changes log level at runtime
 func SetLevel(level string) {
-	levelVar.Set(parseLevel(level))
+	levelVar.Set(ParseLevel(level))
 }
 
 // GetLevel returns the current log level as a string
@@ -76,6 +78,50 @@ func GetLevel() string {
 	}
 }
 
+// AddSink sends log records to h as well as to the output set up by Init.
+// h filters records by its own level; call it after Init.
+func AddSink(h slog.Handler) {
+	slog.SetDefault(slog.New(teeHandler{slog.Default().Handler(), h}))
+}
+
+// teeHandler passes each record to every handler enabled for its level
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
 // Close closes the log file if any
 func Close() error {
 	if closer != nil {
@@ -84,7 +130,8 @@ func Close() error {
 	return nil
 }
 
-func parseLevel(s string) slog.Level {
+// ParseLevel returns the level named by s (debug, info, warn or error), or info
+func ParseLevel(s string) slog.Level {
 	switch strings.ToLower(s) {
 	case "debug":
 		return slog.LevelDebug
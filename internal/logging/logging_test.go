@@ -125,8 +125,8 @@ func TestParseLevel(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
-			if got := parseLevel(tt.input); got != tt.want {
-				t.Errorf("parseLevel(%q) = %v, want %v", tt.input, got, tt.want)
+			if got := ParseLevel(tt.input); got != tt.want {
+				t.Errorf("ParseLevel(%q) = %v, want %v", tt.input, got, tt.want)
 			}
 		})
 	}
@@ -188,6 +188,29 @@ func TestLogFunctions(t *testing.T) {
 	}
 }
 
+// TestAddSink verifies records reach both the output and a sink, each filtered by its own level
+func TestAddSink(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	var out, sink bytes.Buffer
+	levelVar.Set(slog.LevelInfo)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: levelVar})))
+	AddSink(slog.NewJSONHandler(&sink, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	Info("info message", map[string]any{"key": "info_value"})
+	slog.With("request_id", "abc").Warn("warn message")
+
+	if !strings.Contains(out.String(), "info message") || !strings.Contains(out.String(), "warn message") {
+		t.Errorf("output missing messages: %s", out.String())
+	}
+	if strings.Contains(sink.String(), "info message") {
+		t.Errorf("sink received a record below its level: %s", sink.String())
+	}
+	if !strings.Contains(sink.String(), `"msg":"warn message","request_id":"abc"`) {
+		t.Errorf("sink missing warn message with attributes: %s", sink.String())
+	}
+}
+
 // TestLogFunctions_NilFields verifies log functions handle nil field maps without panic
 func TestLogFunctions_NilFields(t *testing.T) {
 	var buf bytes.Buffer
@@ -0,0 +1,293 @@
+// Package mail sends email through an SMTP server for workflow email steps.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TLS modes for the SMTP connection (smtp.tls)
+const (
+	// TLSStartTLS connects in plain text and upgrades with STARTTLS (default, usually port 587)
+	TLSStartTLS = "starttls"
+	// TLSImplicit connects over TLS from the start (usually port 465)
+	TLSImplicit = "tls"
+	// TLSNone sends without encryption; only for local relays
+	TLSNone = "none"
+)
+
+// ValidTLSModes lists the accepted smtp.tls values
+var ValidTLSModes = []string{TLSStartTLS, TLSImplicit, TLSNone}
+
+// DefaultPort is the SMTP submission port used when none is configured
+const DefaultPort = 587
+
+// DefaultTimeout bounds connecting to the server and sending one message
+const DefaultTimeout = 30 * time.Second
+
+// Config describes the SMTP server to send through.
+type Config struct {
+	Host     string
+	Port     int // 0 = DefaultPort
+	Username string
+	Password string
+	From     string        // Default sender address
+	TLS      string        // TLS mode ("" = TLSStartTLS)
+	Timeout  time.Duration // 0 = DefaultTimeout
+}
+
+// Message is an email to send. At least one of Text and HTML should be set;
+// when both are, the message is sent as multipart/alternative.
+type Message struct {
+	From    string // Overrides Config.From when set
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender sends messages through one SMTP server.
+type Sender struct {
+	cfg Config
+}
+
+// New creates a sender, applying defaults for unset port, TLS mode and timeout.
+func New(cfg Config) *Sender {
+	if cfg.Port == 0 {
+		cfg.Port = DefaultPort
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = TLSStartTLS
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &Sender{cfg: cfg}
+}
+
+// ParseAddressList parses a comma-separated address list, ignoring empty entries.
+func ParseAddressList(list string) ([]*mail.Address, error) {
+	var addrs []*mail.Address
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", part, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// Send delivers a message. The context and the configured timeout both bound the whole exchange.
+func (s *Sender) Send(ctx context.Context, msg Message) error {
+	from := msg.From
+	if from == "" {
+		from = s.cfg.From
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("invalid from address %q: %w", from, err)
+	}
+
+	var rcpts []string
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		for _, entry := range list {
+			addrs, err := ParseAddressList(entry)
+			if err != nil {
+				return err
+			}
+			for _, a := range addrs {
+				rcpts = append(rcpts, a.Address)
+			}
+		}
+	}
+	if len(rcpts) == 0 {
+		return errors.New("no recipients")
+	}
+
+	body, err := buildMessage(sender, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := s.deliver(client, sender.Address, rcpts, body); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("smtp: %w", ctx.Err())
+		}
+		return err
+	}
+	return nil
+}
+
+// dial connects and negotiates TLS and authentication.
+func (s *Sender) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: s.cfg.Host}
+
+	var conn net.Conn
+	var err error
+	if s.cfg.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("smtp connect %s: %w", addr, err)
+	}
+
+	// net/smtp has no context support, so the deadline and cancellation act on the connection
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	context.AfterFunc(ctx, func() { _ = conn.Close() })
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("smtp handshake: %w", err)
+	}
+
+	if s.cfg.TLS == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			_ = client.Close()
+			return nil, errors.New("smtp server does not support STARTTLS (set tls: none for a local relay)")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			_ = client.Close()
+			return nil, fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			_ = client.Close()
+			return nil, fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	return client, nil
+}
+
+func (s *Sender) deliver(client *smtp.Client, from string, rcpts []string, body []byte) error {
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	for _, rcpt := range rcpts {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage renders the RFC 5322 message. Bcc recipients are not listed in the headers.
+func buildMessage(from *mail.Address, msg Message, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+
+	header("From", from.String())
+	for _, h := range []struct {
+		name string
+		list []string
+	}{{"To", msg.To}, {"Cc", msg.Cc}} {
+		var formatted []string
+		for _, entry := range h.list {
+			addrs, err := ParseAddressList(entry)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range addrs {
+				formatted = append(formatted, a.String())
+			}
+		}
+		if len(formatted) > 0 {
+			header(h.name, strings.Join(formatted, ", "))
+		}
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", strings.Join(strings.Fields(msg.Subject), " ")))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if msg.Text != "" && msg.HTML != "" {
+		mw := multipart.NewWriter(&buf)
+		header("Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", mw.Boundary()))
+		buf.WriteString("\r\n")
+		for _, part := range []struct{ contentType, body string }{
+			{"text/plain; charset=utf-8", msg.Text},
+			{"text/html; charset=utf-8", msg.HTML},
+		} {
+			pw, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {part.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := writeQuotedPrintable(pw, part.body); err != nil {
+				return nil, err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	contentType, body := "text/plain; charset=utf-8", msg.Text
+	if msg.HTML != "" {
+		contentType, body = "text/html; charset=utf-8", msg.HTML
+	}
+	header("Content-Type", contentType)
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+	if err := writeQuotedPrintable(&buf, body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
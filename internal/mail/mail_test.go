@@ -0,0 +1,260 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is a minimal SMTP server that records one transaction per connection
+type fakeSMTP struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	from  string
+	rcpts []string
+	data  string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTP{ln: ln}
+	t.Cleanup(func() { _ = ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		upper := strings.ToUpper(cmd)
+		switch {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = strings.Trim(cmd[len("MAIL FROM:"):], "<> ")
+			s.mu.Unlock()
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, strings.Trim(cmd[len("RCPT TO:"):], "<> "))
+			s.mu.Unlock()
+			reply("250 OK")
+		case upper == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 queued")
+		case upper == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unsupported")
+		}
+	}
+}
+
+func TestSender_Send(t *testing.T) {
+	srv := newFakeSMTP(t)
+	sender := New(Config{Host: "127.0.0.1", Port: srv.port(), From: "Reports <reports@example.com>", TLS: TLSNone})
+
+	err := sender.Send(context.Background(), Message{
+		To:      []string{"a@example.com, Bob <b@example.com>"},
+		Bcc:     []string{"audit@example.com"},
+		Subject: "Daily report",
+		Text:    "12 new orders",
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.from != "reports@example.com" {
+		t.Errorf("MAIL FROM = %q", srv.from)
+	}
+	if strings.Join(srv.rcpts, ",") != "a@example.com,b@example.com,audit@example.com" {
+		t.Errorf("RCPT TO = %v", srv.rcpts)
+	}
+	if !strings.Contains(srv.data, "Subject: Daily report") || !strings.Contains(srv.data, "12 new orders") {
+		t.Errorf("unexpected message:\n%s", srv.data)
+	}
+	if strings.Contains(srv.data, "audit@example.com") {
+		t.Error("Bcc recipient should not appear in headers")
+	}
+}
+
+func TestSender_Send_Errors(t *testing.T) {
+	srv := newFakeSMTP(t)
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		msg     Message
+		wantErr string
+	}{
+		{
+			name:    "no recipients",
+			cfg:     Config{Host: "127.0.0.1", Port: srv.port(), From: "a@example.com", TLS: TLSNone},
+			msg:     Message{To: []string{" , "}, Text: "x"},
+			wantErr: "no recipients",
+		},
+		{
+			name:    "invalid recipient",
+			cfg:     Config{Host: "127.0.0.1", Port: srv.port(), From: "a@example.com", TLS: TLSNone},
+			msg:     Message{To: []string{"not an address"}, Text: "x"},
+			wantErr: "invalid address",
+		},
+		{
+			name:    "invalid from",
+			cfg:     Config{Host: "127.0.0.1", Port: srv.port(), TLS: TLSNone},
+			msg:     Message{To: []string{"b@example.com"}, Text: "x"},
+			wantErr: "invalid from address",
+		},
+		{
+			name:    "starttls unsupported",
+			cfg:     Config{Host: "127.0.0.1", Port: srv.port(), From: "a@example.com"},
+			msg:     Message{To: []string{"b@example.com"}, Text: "x"},
+			wantErr: "does not support STARTTLS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.cfg).Send(context.Background(), tt.msg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Send() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSender_Send_Timeout(t *testing.T) {
+	// A server that accepts but never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer func() { _ = conn.Close() }()
+			time.Sleep(2 * time.Second)
+		}
+	}()
+
+	sender := New(Config{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port, From: "a@example.com", TLS: TLSNone, Timeout: 100 * time.Millisecond})
+
+	start := time.Now()
+	err = sender.Send(context.Background(), Message{To: []string{"b@example.com"}, Text: "x"})
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Send took %v, want it bounded by the timeout", time.Since(start))
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	from := &mail.Address{Name: "Reports", Address: "reports@example.com"}
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	t.Run("text and html", func(t *testing.T) {
+		raw, err := buildMessage(from, Message{
+			To:      []string{"a@example.com"},
+			Cc:      []string{"c@example.com"},
+			Subject: "Résumé\r\nBcc: injected@example.com",
+			Text:    "plain",
+			HTML:    "<p>rich</p>",
+		}, now)
+		if err != nil {
+			t.Fatalf("buildMessage failed: %v", err)
+		}
+		msg := string(raw)
+		for _, want := range []string{
+			"From: \"Reports\" <reports@example.com>\r\n",
+			"To: <a@example.com>\r\n",
+			"Cc: <c@example.com>\r\n",
+			"Subject: =?utf-8?q?",
+			"Content-Type: multipart/alternative",
+			"text/plain; charset=utf-8",
+			"text/html; charset=utf-8",
+		} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("message missing %q:\n%s", want, msg)
+			}
+		}
+		if strings.Contains(msg, "\r\nBcc:") {
+			t.Error("subject line breaks must not create headers")
+		}
+	})
+
+	t.Run("html only", func(t *testing.T) {
+		raw, err := buildMessage(from, Message{To: []string{"a@example.com"}, Subject: "Hi", HTML: "<b>x</b>"}, now)
+		if err != nil {
+			t.Fatalf("buildMessage failed: %v", err)
+		}
+		if !strings.Contains(string(raw), "Content-Type: text/html; charset=utf-8\r\n") {
+			t.Errorf("expected single html part:\n%s", raw)
+		}
+	})
+}
+
+func TestParseAddressList(t *testing.T) {
+	addrs, err := ParseAddressList("a@example.com,  , Bob <b@example.com>")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(addrs) != 2 || addrs[1].Name != "Bob" {
+		t.Errorf("ParseAddressList() = %v", addrs)
+	}
+	if _, err := ParseAddressList("a@example.com, nope"); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
@@ -2,7 +2,10 @@ package metrics
 
 import (
 	"context"
+	"fmt"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -22,6 +25,7 @@ type RequestAccumulator struct {
 	RowCount      int
 	Error         string
 	ErrorType     string
+	ErrorCode     string
 }
 
 // NewRequestContext returns a context with an attached RequestAccumulator.
@@ -53,6 +57,7 @@ type RequestMetrics struct {
 	StatusCode    int
 	Error         string
 	ErrorType     string // timeout, query_failed, rate_limited, etc.
+	ErrorCode     string // Stable error code of the response, e.g. SQLPROXY_DB_TIMEOUT
 	CacheHit      bool
 }
 
@@ -153,6 +158,9 @@ type Collector struct {
 	promDBHealthy     *prometheus.GaugeVec
 	promDBConnsOpen   *prometheus.GaugeVec
 	promDBConnsIdle   *prometheus.GaugeVec
+	promDBConnsInUse  *prometheus.GaugeVec
+	promDBWaitCount   *prometheus.GaugeVec
+	promDBWaitSeconds *prometheus.GaugeVec
 	promCacheHits     *prometheus.CounterVec
 	promCacheMisses   *prometheus.CounterVec
 	promCacheSize     *prometheus.GaugeVec
@@ -161,6 +169,20 @@ type Collector struct {
 	promRLDenied      *prometheus.CounterVec
 	promRLBuckets     *prometheus.GaugeVec
 	promCronPanics    *prometheus.CounterVec
+	promWFRunning     *prometheus.GaugeVec
+	promConcInUse     *prometheus.GaugeVec
+	promConcWaiting   *prometheus.GaugeVec
+	promConcRejected  *prometheus.CounterVec
+	promLoadShed      *prometheus.CounterVec
+	promWorkersBusy   prometheus.Gauge
+	promWorkerQueued  *prometheus.GaugeVec
+	promWorkerReject  *prometheus.CounterVec
+	promStepErrors    *prometheus.CounterVec
+	promDeprecated    *prometheus.CounterVec
+
+	// Metrics of workflow metric steps, by name, created on first use
+	workflowMu      sync.Mutex
+	workflowMetrics map[string]*workflowMetric
 }
 
 var defaultCollector *Collector
@@ -178,6 +200,7 @@ func Init(dbHealthChecker func() bool, version, buildTime string) {
 		buildTime:       buildTime,
 		dbHealthChecker: dbHealthChecker,
 		endpoints:       make(map[string]*endpointData),
+		workflowMetrics: make(map[string]*workflowMetric),
 		promRegistry:    prometheus.NewRegistry(),
 	}
 
@@ -259,7 +282,7 @@ func (c *Collector) initPrometheusMetrics() {
 			Name: "sqlproxy_errors_total",
 			Help: "Total errors by type",
 		},
-		[]string{"endpoint", "error_type"},
+		[]string{"endpoint", "error_type", "code"},
 	)
 	c.promRegistry.MustRegister(c.promErrors)
 
@@ -292,6 +315,34 @@ func (c *Collector) initPrometheusMetrics() {
 	)
 	c.promRegistry.MustRegister(c.promDBConnsIdle)
 
+	c.promDBConnsInUse = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sqlproxy_db_connections_in_use",
+			Help: "Current database connections running queries",
+		},
+		[]string{"database"},
+	)
+	c.promRegistry.MustRegister(c.promDBConnsInUse)
+
+	// Pool wait totals reset when a database reconnects, so they are gauges
+	c.promDBWaitCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sqlproxy_db_wait_count",
+			Help: "Queries that waited for a free database connection since connecting",
+		},
+		[]string{"database"},
+	)
+	c.promRegistry.MustRegister(c.promDBWaitCount)
+
+	c.promDBWaitSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sqlproxy_db_wait_duration_seconds",
+			Help: "Total time queries waited for a free database connection since connecting",
+		},
+		[]string{"database"},
+	)
+	c.promRegistry.MustRegister(c.promDBWaitSeconds)
+
 	// Cache metrics
 	c.promCacheHits = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -366,6 +417,96 @@ func (c *Collector) initPrometheusMetrics() {
 		[]string{"workflow"},
 	)
 	c.promRegistry.MustRegister(c.promCronPanics)
+
+	// Concurrency metrics
+	c.promWFRunning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sqlproxy_workflow_running",
+			Help: "Workflow executions currently running",
+		},
+		[]string{"workflow"},
+	)
+	c.promRegistry.MustRegister(c.promWFRunning)
+
+	c.promConcInUse = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sqlproxy_concurrency_in_use",
+			Help: "Slots in use for max_concurrent limits",
+		},
+		[]string{"scope", "name"},
+	)
+	c.promRegistry.MustRegister(c.promConcInUse)
+
+	c.promConcWaiting = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sqlproxy_concurrency_waiting",
+			Help: "Callers queued for a max_concurrent slot",
+		},
+		[]string{"scope", "name"},
+	)
+	c.promRegistry.MustRegister(c.promConcWaiting)
+
+	c.promConcRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sqlproxy_concurrency_rejected_total",
+			Help: "Executions rejected by max_concurrent limits",
+		},
+		[]string{"scope", "name"},
+	)
+	c.promRegistry.MustRegister(c.promConcRejected)
+
+	c.promLoadShed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sqlproxy_load_shed_total",
+			Help: "HTTP requests rejected by load shedding",
+		},
+		[]string{"workflow", "priority"},
+	)
+	c.promRegistry.MustRegister(c.promLoadShed)
+
+	c.promWorkersBusy = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "sqlproxy_workers_busy",
+			Help: "Worker pool workers running a workflow",
+		},
+	)
+	c.promRegistry.MustRegister(c.promWorkersBusy)
+
+	c.promWorkerQueued = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sqlproxy_worker_queue_depth",
+			Help: "Workflow executions waiting for a worker",
+		},
+		[]string{"priority"},
+	)
+	c.promRegistry.MustRegister(c.promWorkerQueued)
+
+	c.promWorkerReject = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sqlproxy_worker_queue_rejected_total",
+			Help: "Workflow executions rejected by a full worker queue or an expired queue wait",
+		},
+		[]string{"priority"},
+	)
+	c.promRegistry.MustRegister(c.promWorkerReject)
+
+	c.promStepErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sqlproxy_step_errors_total",
+			Help: "Failed workflow steps by reason (timeout or error)",
+		},
+		[]string{"workflow", "step", "reason"},
+	)
+	c.promRegistry.MustRegister(c.promStepErrors)
+
+	c.promDeprecated = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sqlproxy_deprecated_requests_total",
+			Help: "Requests to endpoints marked deprecated",
+		},
+		[]string{"workflow", "method", "path"},
+	)
+	c.promRegistry.MustRegister(c.promDeprecated)
 }
 
 // Registry returns the Prometheus registry for use with promhttp.Handler
@@ -462,9 +603,9 @@ func Record(m RequestMetrics) {
 	c.promRows.WithLabelValues(m.Endpoint).Add(float64(m.RowCount))
 
 	if m.ErrorType != "" {
-		c.promErrors.WithLabelValues(m.Endpoint, m.ErrorType).Inc()
+		c.promErrors.WithLabelValues(m.Endpoint, m.ErrorType, m.ErrorCode).Inc()
 	} else if m.Error != "" {
-		c.promErrors.WithLabelValues(m.Endpoint, "unknown").Inc()
+		c.promErrors.WithLabelValues(m.Endpoint, "unknown", m.ErrorCode).Inc()
 	}
 
 	if m.CacheHit {
@@ -508,13 +649,25 @@ func UpdateDBHealth(database string, healthy bool) {
 	defaultCollector.promDBHealthy.WithLabelValues(database).Set(val)
 }
 
+// DBPoolStats is a reading of a database connection pool
+type DBPoolStats struct {
+	Open         int
+	Idle         int
+	InUse        int
+	WaitCount    int64
+	WaitDuration time.Duration
+}
+
 // UpdateDBPoolStats updates database connection pool gauges for Prometheus
-func UpdateDBPoolStats(database string, open, idle int) {
+func UpdateDBPoolStats(database string, ps DBPoolStats) {
 	if defaultCollector == nil {
 		return
 	}
-	defaultCollector.promDBConnsOpen.WithLabelValues(database).Set(float64(open))
-	defaultCollector.promDBConnsIdle.WithLabelValues(database).Set(float64(idle))
+	defaultCollector.promDBConnsOpen.WithLabelValues(database).Set(float64(ps.Open))
+	defaultCollector.promDBConnsIdle.WithLabelValues(database).Set(float64(ps.Idle))
+	defaultCollector.promDBConnsInUse.WithLabelValues(database).Set(float64(ps.InUse))
+	defaultCollector.promDBWaitCount.WithLabelValues(database).Set(float64(ps.WaitCount))
+	defaultCollector.promDBWaitSeconds.WithLabelValues(database).Set(ps.WaitDuration.Seconds())
 }
 
 // UpdateCacheStats updates cache gauges for Prometheus
@@ -542,6 +695,172 @@ func RecordCronPanic(workflow string) {
 	defaultCollector.promCronPanics.WithLabelValues(workflow).Inc()
 }
 
+// UpdateWorkflowRunning updates the running executions gauge for a workflow
+func UpdateWorkflowRunning(workflow string, running int64) {
+	if defaultCollector == nil {
+		return
+	}
+	defaultCollector.promWFRunning.WithLabelValues(workflow).Set(float64(running))
+}
+
+// UpdateConcurrency updates max_concurrent gauges. Scope is "workflow" or "database".
+func UpdateConcurrency(scope, name string, inUse int, waiting int64) {
+	if defaultCollector == nil {
+		return
+	}
+	defaultCollector.promConcInUse.WithLabelValues(scope, name).Set(float64(inUse))
+	defaultCollector.promConcWaiting.WithLabelValues(scope, name).Set(float64(waiting))
+}
+
+// RecordConcurrencyRejected records an execution rejected by a max_concurrent limit
+func RecordConcurrencyRejected(scope, name string) {
+	if defaultCollector == nil {
+		return
+	}
+	defaultCollector.promConcRejected.WithLabelValues(scope, name).Inc()
+}
+
+// RecordLoadShed records an HTTP request rejected by load shedding
+func RecordLoadShed(workflow, priority string) {
+	if defaultCollector == nil {
+		return
+	}
+	defaultCollector.promLoadShed.WithLabelValues(workflow, priority).Inc()
+}
+
+// UpdateWorkerPool updates the worker pool gauges
+func UpdateWorkerPool(busy int, queued map[string]int) {
+	if defaultCollector == nil {
+		return
+	}
+	defaultCollector.promWorkersBusy.Set(float64(busy))
+	for priority, n := range queued {
+		defaultCollector.promWorkerQueued.WithLabelValues(priority).Set(float64(n))
+	}
+}
+
+// RecordWorkerPoolRejected records an execution rejected by the worker pool queue
+func RecordWorkerPoolRejected(priority string) {
+	if defaultCollector == nil {
+		return
+	}
+	defaultCollector.promWorkerReject.WithLabelValues(priority).Inc()
+}
+
+// RecordStepError records a failed workflow step, separating timeouts from other errors
+func RecordStepError(workflow, step string, timedOut bool) {
+	if defaultCollector == nil {
+		return
+	}
+	reason := "error"
+	if timedOut {
+		reason = "timeout"
+	}
+	defaultCollector.promStepErrors.WithLabelValues(workflow, step, reason).Inc()
+}
+
+// RecordDeprecatedCall records a request to a deprecated endpoint
+func RecordDeprecatedCall(workflow, method, path string) {
+	if defaultCollector == nil {
+		return
+	}
+	defaultCollector.promDeprecated.WithLabelValues(workflow, method, path).Inc()
+}
+
+// Kinds of workflow metrics
+const (
+	KindGauge     = "gauge"
+	KindCounter   = "counter"
+	KindHistogram = "histogram"
+)
+
+// WorkflowMetric describes a metric recorded by workflow metric steps
+type WorkflowMetric struct {
+	Name    string
+	Kind    string    // KindGauge, KindCounter, or KindHistogram
+	Help    string    // Default "Recorded by workflow metric steps"
+	Labels  []string  // Label names
+	Buckets []float64 // Histogram buckets (default: prometheus.DefBuckets)
+}
+
+// workflowMetric is a registered workflow metric
+type workflowMetric struct {
+	kind      string
+	labels    []string // Sorted
+	gauge     *prometheus.GaugeVec
+	counter   *prometheus.CounterVec
+	histogram *prometheus.HistogramVec
+}
+
+// RecordWorkflowMetric sets a gauge, adds to a counter, or observes a histogram
+// value. The metric is registered on first use; later uses must have the same
+// kind and label names. labels maps label names to values.
+func RecordWorkflowMetric(def WorkflowMetric, labels map[string]string, value float64) error {
+	if defaultCollector == nil {
+		return nil
+	}
+	m, err := defaultCollector.workflowMetric(def)
+	if err != nil {
+		return err
+	}
+	values := make([]string, len(m.labels))
+	for i, name := range m.labels {
+		values[i] = labels[name]
+	}
+	switch m.kind {
+	case KindGauge:
+		m.gauge.WithLabelValues(values...).Set(value)
+	case KindCounter:
+		if value < 0 {
+			return fmt.Errorf("counter %s cannot decrease (value %g)", def.Name, value)
+		}
+		m.counter.WithLabelValues(values...).Add(value)
+	case KindHistogram:
+		m.histogram.WithLabelValues(values...).Observe(value)
+	}
+	return nil
+}
+
+// workflowMetric returns the registered metric of def, registering it on first use
+func (c *Collector) workflowMetric(def WorkflowMetric) (*workflowMetric, error) {
+	labels := slices.Clone(def.Labels)
+	slices.Sort(labels)
+
+	c.workflowMu.Lock()
+	defer c.workflowMu.Unlock()
+	if m, ok := c.workflowMetrics[def.Name]; ok {
+		if m.kind != def.Kind || !slices.Equal(m.labels, labels) {
+			return nil, fmt.Errorf("metric %s is already a %s with labels [%s]", def.Name, m.kind, strings.Join(m.labels, ", "))
+		}
+		return m, nil
+	}
+
+	help := def.Help
+	if help == "" {
+		help = "Recorded by workflow metric steps"
+	}
+	m := &workflowMetric{kind: def.Kind, labels: labels}
+	var collector prometheus.Collector
+	switch def.Kind {
+	case KindGauge:
+		m.gauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: def.Name, Help: help}, labels)
+		collector = m.gauge
+	case KindCounter:
+		m.counter = prometheus.NewCounterVec(prometheus.CounterOpts{Name: def.Name, Help: help}, labels)
+		collector = m.counter
+	case KindHistogram:
+		m.histogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: def.Name, Help: help, Buckets: def.Buckets}, labels)
+		collector = m.histogram
+	default:
+		return nil, fmt.Errorf("unknown metric kind %q", def.Kind)
+	}
+	if err := c.promRegistry.Register(collector); err != nil {
+		return nil, fmt.Errorf("metric %s: %w", def.Name, err)
+	}
+	c.workflowMetrics[def.Name] = m
+	return m, nil
+}
+
 // getOrCreateEndpoint returns existing endpoint data or creates new one
 func (c *Collector) getOrCreateEndpoint(endpoint, queryName string) *endpointData {
 	c.mu.RLock()
@@ -277,6 +277,44 @@ func TestGetSnapshot_DBHealth(t *testing.T) {
 	}
 }
 
+// TestUpdateDBPoolStats verifies pool readings are exported as per-database gauges
+func TestUpdateDBPoolStats(t *testing.T) {
+	defaultCollector = nil
+	UpdateDBPoolStats("primary", DBPoolStats{}) // No collector: no-op
+
+	Init(nil, "", "")
+	UpdateDBPoolStats("primary", DBPoolStats{Open: 5, Idle: 1, InUse: 4, WaitCount: 12, WaitDuration: 1500 * time.Millisecond})
+
+	families, err := defaultCollector.promRegistry.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %v", err)
+	}
+	want := map[string]float64{
+		"sqlproxy_db_connections_open":      5,
+		"sqlproxy_db_connections_idle":      1,
+		"sqlproxy_db_connections_in_use":    4,
+		"sqlproxy_db_wait_count":            12,
+		"sqlproxy_db_wait_duration_seconds": 1.5,
+	}
+	for _, mf := range families {
+		expected, ok := want[mf.GetName()]
+		if !ok {
+			continue
+		}
+		delete(want, mf.GetName())
+		m := mf.GetMetric()[0]
+		if got := m.GetGauge().GetValue(); got != expected {
+			t.Errorf("%s = %v, want %v", mf.GetName(), got, expected)
+		}
+		if label := m.GetLabel()[0]; label.GetName() != "database" || label.GetValue() != "primary" {
+			t.Errorf("%s has label %s=%s", mf.GetName(), label.GetName(), label.GetValue())
+		}
+	}
+	for name := range want {
+		t.Errorf("missing metric %s", name)
+	}
+}
+
 // TestRecord_Concurrent tests thread-safe metric recording with 100 goroutines
 func TestRecord_Concurrent(t *testing.T) {
 	defaultCollector = nil
@@ -495,3 +533,97 @@ func TestSnapshot_BothCacheAndRateLimits(t *testing.T) {
 		t.Errorf("expected rate limit total_allowed=500, got %v", rateLimits["total_allowed"])
 	}
 }
+
+// TestRecordWorkflowMetric verifies metric step metrics are registered on first use and keep their kind and labels
+func TestRecordWorkflowMetric(t *testing.T) {
+	defaultCollector = nil
+	if err := RecordWorkflowMetric(WorkflowMetric{Name: "jobs_total", Kind: KindCounter}, nil, 1); err != nil {
+		t.Errorf("expected no error without a collector, got %v", err)
+	}
+
+	Init(nil, "test", "")
+	defer Clear()
+
+	depth := WorkflowMetric{Name: "queue_depth", Kind: KindGauge, Labels: []string{"queue", "region"}}
+	for _, v := range []float64{5, 3} {
+		if err := RecordWorkflowMetric(depth, map[string]string{"queue": "exports", "region": "eu"}, v); err != nil {
+			t.Fatalf("record gauge: %v", err)
+		}
+	}
+	jobs := WorkflowMetric{Name: "jobs_total", Kind: KindCounter}
+	for range 2 {
+		if err := RecordWorkflowMetric(jobs, nil, 1); err != nil {
+			t.Fatalf("record counter: %v", err)
+		}
+	}
+	if err := RecordWorkflowMetric(jobs, nil, -1); err == nil {
+		t.Error("expected an error for a decreasing counter")
+	}
+	if err := RecordWorkflowMetric(WorkflowMetric{Name: "export_seconds", Kind: KindHistogram, Buckets: []float64{1, 10}}, nil, 4); err != nil {
+		t.Fatalf("record histogram: %v", err)
+	}
+
+	if err := RecordWorkflowMetric(WorkflowMetric{Name: "queue_depth", Kind: KindCounter, Labels: []string{"queue", "region"}}, nil, 1); err == nil {
+		t.Error("expected an error for a kind conflict")
+	}
+	if err := RecordWorkflowMetric(WorkflowMetric{Name: "queue_depth", Kind: KindGauge, Labels: []string{"queue"}}, nil, 1); err == nil {
+		t.Error("expected an error for a label conflict")
+	}
+	if err := RecordWorkflowMetric(WorkflowMetric{Name: "sqlproxy_requests_total", Kind: KindCounter, Labels: []string{"endpoint", "method", "status"}}, nil, 1); err == nil {
+		t.Error("expected an error for a built-in metric name")
+	}
+
+	families, err := Registry().Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	got := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			switch {
+			case m.GetGauge() != nil:
+				got[f.GetName()] = m.GetGauge().GetValue()
+			case m.GetCounter() != nil:
+				got[f.GetName()] = m.GetCounter().GetValue()
+			case m.GetHistogram() != nil:
+				got[f.GetName()] = m.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+	if got["queue_depth"] != 3 || got["jobs_total"] != 2 || got["export_seconds"] != 4 {
+		t.Errorf("values = queue_depth %v, jobs_total %v, export_seconds %v; want 3, 2, 4", got["queue_depth"], got["jobs_total"], got["export_seconds"])
+	}
+}
+
+func TestRecordDeprecatedCall(t *testing.T) {
+	defaultCollector = nil
+	RecordDeprecatedCall("orders", "GET", "/v1/orders") // No collector: no-op
+
+	Init(nil, "", "")
+	defer Clear()
+	RecordDeprecatedCall("orders", "GET", "/v1/orders")
+	RecordDeprecatedCall("orders", "GET", "/v1/orders")
+
+	families, err := Registry().Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != "sqlproxy_deprecated_requests_total" {
+			continue
+		}
+		m := mf.GetMetric()[0]
+		labels := make(map[string]string)
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["workflow"] != "orders" || labels["method"] != "GET" || labels["path"] != "/v1/orders" {
+			t.Errorf("labels = %v", labels)
+		}
+		if got := m.GetCounter().GetValue(); got != 2 {
+			t.Errorf("count = %v, want 2", got)
+		}
+		return
+	}
+	t.Error("missing metric sqlproxy_deprecated_requests_total")
+}
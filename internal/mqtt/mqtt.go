@@ -0,0 +1,305 @@
+// Package mqtt connects to MQTT brokers for workflow mqtt triggers and steps.
+// It wraps an Eclipse Paho MQTT 3.1.1 client, which reconnects with backoff
+// when the connection drops; a Client subscribes again on every connection.
+package mqtt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Default ports per URL scheme
+const (
+	DefaultPort    = 1883
+	DefaultTLSPort = 8883
+)
+
+// DefaultKeepAlive is the keep-alive interval used when none is configured
+const DefaultKeepAlive = 60 * time.Second
+
+// DefaultTimeout bounds connecting, subscribing, and waiting for a publish to be acknowledged
+const DefaultTimeout = 10 * time.Second
+
+// maxReconnectBackoff caps the wait between connection attempts
+const maxReconnectBackoff = 30 * time.Second
+
+// subAckFailure is the SUBACK return code of a refused subscription
+const subAckFailure = 0x80
+
+// ErrNotConnected is returned by Publish when no connection came up within the timeout.
+var ErrNotConnected = errors.New("mqtt: not connected")
+
+// Config describes one broker.
+type Config struct {
+	URL                string // mqtt://host:port or tcp://; mqtts://, ssl:// or tls:// for TLS
+	ClientID           string // Default: a random ID, kept across reconnects
+	Username           string
+	Password           string
+	KeepAlive          time.Duration // 0 = DefaultKeepAlive
+	Timeout            time.Duration // 0 = DefaultTimeout
+	CleanSession       bool          // Discard the broker-side session (subscriptions, unacknowledged messages) on connect
+	InsecureSkipVerify bool          // Skip TLS certificate verification (testing only)
+	OnMessage          Handler       // Receives the messages of all subscriptions
+	OnError            func(error)   // Called when a connection attempt fails or a connection drops
+}
+
+// Message is a message received on a subscription.
+type Message struct {
+	Topic    string
+	Payload  []byte
+	QoS      byte
+	Retained bool
+}
+
+// Handler processes received messages. Messages are handled one at a time in
+// the order they arrive; QoS 1 messages are acknowledged when it returns.
+type Handler func(ctx context.Context, msg Message)
+
+// Subscription is a topic filter and the highest QoS to receive it with.
+type Subscription struct {
+	Filter string
+	QoS    byte
+}
+
+// Client keeps a connection to one broker.
+type Client struct {
+	cfg    Config
+	addr   string
+	tls    *tls.Config // nil for plain TCP
+	subs   []Subscription
+	client paho.Client
+	ctx    context.Context // Of Run, passed to OnMessage
+
+	mu    sync.Mutex
+	ready chan struct{} // closed while connected
+}
+
+// New creates a client for a broker. It connects in Run.
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("url has no host")
+	}
+	c := &Client{cfg: cfg, ctx: context.Background(), ready: make(chan struct{})}
+	scheme, port := "tcp", DefaultPort
+	switch u.Scheme {
+	case "mqtt", "tcp":
+	case "mqtts", "ssl", "tls":
+		scheme, port = "ssl", DefaultTLSPort
+		c.tls = &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: cfg.InsecureSkipVerify, MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("unsupported url scheme %q (use mqtt, tcp, mqtts, ssl, or tls)", u.Scheme)
+	}
+	c.addr = u.Host
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), fmt.Sprint(port))
+	}
+	if c.cfg.KeepAlive <= 0 {
+		c.cfg.KeepAlive = DefaultKeepAlive
+	}
+	if c.cfg.Timeout <= 0 {
+		c.cfg.Timeout = DefaultTimeout
+	}
+	if c.cfg.ClientID == "" {
+		b := make([]byte, 6)
+		_, _ = rand.Read(b)
+		c.cfg.ClientID = "sql-proxy-" + hex.EncodeToString(b)
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(scheme + "://" + c.addr).
+		SetClientID(c.cfg.ClientID).
+		SetUsername(c.cfg.Username).
+		SetPassword(c.cfg.Password).
+		SetProtocolVersion(4).
+		SetCleanSession(c.cfg.CleanSession).
+		SetKeepAlive(c.cfg.KeepAlive).
+		SetPingTimeout(c.cfg.Timeout).
+		SetConnectTimeout(c.cfg.Timeout).
+		SetWriteTimeout(c.cfg.Timeout).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(time.Second).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(maxReconnectBackoff).
+		SetOrderMatters(true).
+		SetDefaultPublishHandler(c.deliver).
+		SetOnConnectHandler(c.onConnect).
+		SetConnectionNotificationHandler(c.onNotification)
+	if c.tls != nil {
+		opts.SetTLSConfig(c.tls)
+	}
+	c.client = paho.NewClient(opts)
+	return c, nil
+}
+
+// Subscribe adds a subscription, made on every connection. Call it before Run.
+// Subscribing to a filter twice keeps the higher QoS.
+func (c *Client) Subscribe(filter string, qos byte) {
+	for i := range c.subs {
+		if c.subs[i].Filter == filter {
+			c.subs[i].QoS = max(c.subs[i].QoS, qos)
+			return
+		}
+	}
+	c.subs = append(c.subs, Subscription{Filter: filter, QoS: qos})
+}
+
+// Run connects and keeps reconnecting until ctx is cancelled, then disconnects.
+func (c *Client) Run(ctx context.Context) {
+	c.ctx = ctx
+	c.client.Connect()
+	<-ctx.Done()
+	c.client.Disconnect(250)
+	c.setReady(false)
+}
+
+func (c *Client) report(err error) {
+	if c.cfg.OnError != nil && err != nil {
+		c.cfg.OnError(err)
+	}
+}
+
+func (c *Client) setReady(connected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.ready:
+		if !connected {
+			c.ready = make(chan struct{})
+		}
+	default:
+		if connected {
+			close(c.ready)
+		}
+	}
+}
+
+// onConnect subscribes on every connection; Publish waits until it is done.
+func (c *Client) onConnect(client paho.Client) {
+	defer c.setReady(true)
+	if len(c.subs) == 0 {
+		return
+	}
+	filters := make(map[string]byte, len(c.subs))
+	for _, s := range c.subs {
+		filters[s.Filter] = s.QoS
+	}
+	token := client.SubscribeMultiple(filters, nil)
+	if !token.WaitTimeout(c.cfg.Timeout) {
+		c.report(errors.New("mqtt: subscribe timed out"))
+		return
+	}
+	if err := token.Error(); err != nil {
+		c.report(err)
+		return
+	}
+	for _, s := range c.subs {
+		if token.(*paho.SubscribeToken).Result()[s.Filter] == subAckFailure {
+			c.report(fmt.Errorf("mqtt: subscription to %q refused", s.Filter))
+		}
+	}
+}
+
+func (c *Client) onNotification(_ paho.Client, n paho.ConnectionNotification) {
+	switch n := n.(type) {
+	case paho.ConnectionNotificationFailed:
+		c.report(fmt.Errorf("mqtt: connect: %w", n.Reason))
+	case paho.ConnectionNotificationLost:
+		c.setReady(false)
+		if n.Reason == nil {
+			c.report(errors.New("mqtt: connection lost"))
+		} else {
+			c.report(fmt.Errorf("mqtt: connection lost: %w", n.Reason))
+		}
+	}
+}
+
+// deliver passes a received message to OnMessage. Paho acknowledges QoS 1
+// messages once it returns.
+func (c *Client) deliver(_ paho.Client, m paho.Message) {
+	if c.cfg.OnMessage != nil {
+		c.cfg.OnMessage(c.ctx, Message{Topic: m.Topic(), Payload: m.Payload(), QoS: m.Qos(), Retained: m.Retained()})
+	}
+}
+
+// Publish sends a message. With QoS 1 it returns once the broker acknowledged
+// it. While reconnecting it waits for the connection up to the timeout.
+func (c *Client) Publish(ctx context.Context, topic string, payload []byte, qos byte, retain bool) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	c.mu.Lock()
+	ready := c.ready
+	c.mu.Unlock()
+	select {
+	case <-ready:
+	case <-ctx.Done():
+		return ErrNotConnected
+	}
+	token := c.client.Publish(topic, qos, retain, payload)
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Match reports whether a topic matches a filter with + and # wildcards.
+// Topics starting with $ are only matched by filters naming their first level.
+func Match(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	levels := strings.Split(topic, "/")
+	for i, part := range strings.Split(filter, "/") {
+		if part == "#" {
+			return true
+		}
+		if i >= len(levels) || (part != "+" && part != levels[i]) {
+			return false
+		}
+	}
+	return len(strings.Split(filter, "/")) == len(levels)
+}
+
+// ValidateFilter checks a subscription topic filter.
+func ValidateFilter(filter string) error {
+	if filter == "" {
+		return errors.New("topic filter is empty")
+	}
+	parts := strings.Split(filter, "/")
+	for i, part := range parts {
+		if strings.Contains(part, "#") && (part != "#" || i != len(parts)-1) {
+			return errors.New("# must be the whole last level")
+		}
+		if strings.Contains(part, "+") && part != "+" {
+			return errors.New("+ must be a whole level")
+		}
+	}
+	return nil
+}
+
+// ValidateTopic checks a topic to publish to.
+func ValidateTopic(topic string) error {
+	if topic == "" {
+		return errors.New("topic is empty")
+	}
+	if strings.ContainsAny(topic, "+#") {
+		return errors.New("wildcards are not allowed when publishing")
+	}
+	return nil
+}
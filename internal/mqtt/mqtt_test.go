@@ -0,0 +1,298 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// testBroker accepts one connection at a time and speaks enough MQTT for the tests
+type testBroker struct {
+	t        *testing.T
+	ln       net.Listener
+	connects chan *packets.ConnectPacket
+	packets  chan packets.ControlPacket // Other packets from the client
+	conns    chan net.Conn
+	refuse   byte // CONNACK return code
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &testBroker{
+		t:        t,
+		ln:       ln,
+		connects: make(chan *packets.ConnectPacket, 4),
+		packets:  make(chan packets.ControlPacket, 16),
+		conns:    make(chan net.Conn, 4),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go b.serve()
+	return b
+}
+
+func (b *testBroker) url() string { return "mqtt://" + b.ln.Addr().String() }
+
+func (b *testBroker) serve() {
+	for {
+		nc, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.conns <- nc
+		go b.handle(nc)
+	}
+}
+
+func (b *testBroker) handle(nc net.Conn) {
+	defer nc.Close()
+	for {
+		cp, err := packets.ReadPacket(nc)
+		if err != nil {
+			return
+		}
+		switch p := cp.(type) {
+		case *packets.ConnectPacket:
+			b.connects <- p
+			ack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+			ack.ReturnCode = b.refuse
+			_ = ack.Write(nc)
+		case *packets.SubscribePacket:
+			// Grant QoS 1 to everything except filters starting with "denied"
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			for _, topic := range p.Topics {
+				if strings.HasPrefix(topic, "denied") {
+					ack.ReturnCodes = append(ack.ReturnCodes, subAckFailure)
+				} else {
+					ack.ReturnCodes = append(ack.ReturnCodes, 1)
+				}
+			}
+			_ = ack.Write(nc)
+		case *packets.PublishPacket:
+			b.packets <- p
+			if p.Qos == 1 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				_ = ack.Write(nc)
+			}
+		case *packets.PingreqPacket:
+			_ = packets.NewControlPacket(packets.Pingresp).Write(nc)
+		default:
+			b.packets <- cp
+		}
+	}
+}
+
+func (b *testBroker) nextPacket() packets.ControlPacket {
+	b.t.Helper()
+	select {
+	case p := <-b.packets:
+		return p
+	case <-time.After(5 * time.Second):
+		b.t.Fatal("timed out waiting for a packet")
+		return nil
+	}
+}
+
+// TestClient_PublishAndReceive tests connecting, publishing, acknowledging received messages, and disconnecting
+func TestClient_PublishAndReceive(t *testing.T) {
+	broker := newTestBroker(t)
+	received := make(chan Message, 4)
+	c, err := New(Config{
+		URL:       broker.url(),
+		ClientID:  "line-1",
+		Username:  "plc",
+		Password:  "secret",
+		OnMessage: func(ctx context.Context, msg Message) { received <- msg },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Subscribe("factory/+/temperature", 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { c.Run(ctx); close(done) }()
+
+	connect := <-broker.connects
+	if connect.ProtocolVersion != 4 || connect.ClientIdentifier != "line-1" || connect.Username != "plc" || string(connect.Password) != "secret" {
+		t.Errorf("connect = version %d, client %q, user %q", connect.ProtocolVersion, connect.ClientIdentifier, connect.Username)
+	}
+	nc := <-broker.conns
+
+	// QoS 1 publishes wait for the broker's acknowledgement
+	if err := c.Publish(ctx, "factory/line-1/setpoint", []byte(`{"c": 21}`), 1, true); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	pub, ok := broker.nextPacket().(*packets.PublishPacket)
+	if !ok || pub.TopicName != "factory/line-1/setpoint" || pub.Qos != 1 || !pub.Retain || string(pub.Payload) != `{"c": 21}` {
+		t.Errorf("published %v", pub)
+	}
+
+	// Received QoS 1 messages are acknowledged after the handler ran
+	msg := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	msg.TopicName, msg.Qos, msg.MessageID, msg.Payload = "factory/line-2/temperature", 1, 7, []byte("23.5")
+	_ = msg.Write(nc)
+	select {
+	case m := <-received:
+		if m.Topic != "factory/line-2/temperature" || string(m.Payload) != "23.5" || m.QoS != 1 {
+			t.Errorf("received %+v", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered")
+	}
+	if ack, ok := broker.nextPacket().(*packets.PubackPacket); !ok || ack.MessageID != 7 {
+		t.Errorf("expected PUBACK 7, got %v", ack)
+	}
+
+	// Cancelling Run disconnects
+	cancel()
+	<-done
+	if p, ok := broker.nextPacket().(*packets.DisconnectPacket); !ok {
+		t.Errorf("expected DISCONNECT, got %v", p)
+	}
+}
+
+// TestClient_Reconnect tests that a publish waits for the connection to come back
+func TestClient_Reconnect(t *testing.T) {
+	broker := newTestBroker(t)
+	c, err := New(Config{URL: broker.url(), Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	<-broker.connects
+	(<-broker.conns).Close()
+
+	// Publishing waits for the new connection
+	<-broker.connects
+	if err := c.Publish(ctx, "status", []byte("up"), 0, false); err != nil {
+		t.Fatalf("Publish after reconnect: %v", err)
+	}
+	if pub, ok := broker.nextPacket().(*packets.PublishPacket); !ok || string(pub.Payload) != "up" {
+		t.Errorf("published %v", pub)
+	}
+}
+
+// TestClient_Refused tests that a refused connection is reported and publishing fails
+func TestClient_Refused(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.refuse = packets.ErrRefusedNotAuthorised
+	errs := make(chan error, 4)
+	c, _ := New(Config{URL: broker.url(), Timeout: 200 * time.Millisecond, OnError: func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	if err := <-errs; !errors.Is(err, packets.ErrorRefusedNotAuthorised) {
+		t.Errorf("expected not authorized, got %v", err)
+	}
+	if err := c.Publish(context.Background(), "x", nil, 0, false); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Publish without connection = %v", err)
+	}
+}
+
+// TestClient_SubscriptionRefused tests that a subscription the broker refuses is reported
+func TestClient_SubscriptionRefused(t *testing.T) {
+	broker := newTestBroker(t)
+	errs := make(chan error, 4)
+	c, _ := New(Config{URL: broker.url(), OnError: func(err error) { errs <- err }})
+	c.Subscribe("denied/#", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	if err := <-errs; err == nil || err.Error() != `mqtt: subscription to "denied/#" refused` {
+		t.Errorf("expected refused subscription, got %v", err)
+	}
+}
+
+// TestNew_URL tests broker addresses, default ports and TLS per URL scheme
+func TestNew_URL(t *testing.T) {
+	tests := []struct {
+		url, addr string
+		tls       bool
+		wantErr   bool
+	}{
+		{url: "mqtt://broker.local", addr: "broker.local:1883"},
+		{url: "tcp://10.0.0.5:1884", addr: "10.0.0.5:1884"},
+		{url: "mqtts://broker.local", addr: "broker.local:8883", tls: true},
+		{url: "ssl://broker.local:9000", addr: "broker.local:9000", tls: true},
+		{url: "ws://broker.local", wantErr: true},
+		{url: "mqtt://", wantErr: true},
+	}
+	for _, tt := range tests {
+		c, err := New(Config{URL: tt.url})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+			continue
+		}
+		if c.addr != tt.addr || (c.tls != nil) != tt.tls {
+			t.Errorf("%s: addr %s tls %v", tt.url, c.addr, c.tls != nil)
+		}
+	}
+}
+
+// TestMatch tests topic filter matching with wildcards and $ topics
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/b/c", "a/b/c", true},
+		{"a/b/c", "a/b", false},
+		{"a/+/c", "a/x/c", true},
+		{"a/+/c", "a/x/y/c", false},
+		{"a/#", "a/x/y", true},
+		{"a/#", "a", true},
+		{"+/+", "a/b", true},
+		{"+", "a/b", false},
+		{"#", "$SYS/uptime", false},
+		{"$SYS/#", "$SYS/uptime", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+// TestValidateFilter tests subscription filter and publish topic validation
+func TestValidateFilter(t *testing.T) {
+	for _, f := range []string{"a/b", "a/+/c", "a/#", "#", "+"} {
+		if err := ValidateFilter(f); err != nil {
+			t.Errorf("ValidateFilter(%q) = %v", f, err)
+		}
+	}
+	for _, f := range []string{"", "a/#/c", "a/b#", "a/b+/c"} {
+		if err := ValidateFilter(f); err == nil {
+			t.Errorf("ValidateFilter(%q) accepted", f)
+		}
+	}
+	if err := ValidateTopic("a/+/c"); err == nil {
+		t.Error("ValidateTopic accepted a wildcard")
+	}
+}
@@ -0,0 +1,266 @@
+// Package objstore uploads objects to S3-compatible storage for workflow storage steps.
+// It speaks the S3 REST API directly with Signature Version 4 signing, so it works with
+// AWS S3 as well as MinIO, Cloudflare R2, Backblaze B2 and other compatible services.
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Server-side encryption modes (encryption on storage steps)
+const (
+	// EncryptionS3 uses keys managed by the storage service (SSE-S3)
+	EncryptionS3 = "AES256"
+	// EncryptionKMS uses AWS KMS, optionally with a specific key (SSE-KMS)
+	EncryptionKMS = "aws:kms"
+)
+
+// ValidEncryptions lists the accepted encryption values
+var ValidEncryptions = []string{EncryptionS3, EncryptionKMS}
+
+// DefaultTimeout bounds one upload
+const DefaultTimeout = 60 * time.Second
+
+// maxErrorBody caps how much of an error response is read
+const maxErrorBody = 4096
+
+// Config describes one storage target.
+type Config struct {
+	Endpoint        string // Base URL ("" = AWS S3 for Region)
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string        // Optional, for temporary credentials
+	PathStyle       bool          // Address buckets as endpoint/bucket instead of bucket.endpoint
+	Timeout         time.Duration // 0 = DefaultTimeout
+}
+
+// PutInput is an object to upload.
+type PutInput struct {
+	Bucket      string
+	Key         string
+	Body        []byte
+	ContentType string
+	Encryption  string // "" | EncryptionS3 | EncryptionKMS
+	KMSKeyID    string // Only with EncryptionKMS
+}
+
+// PutOutput describes an uploaded object.
+type PutOutput struct {
+	URL  string // Object URL (not presigned)
+	ETag string
+}
+
+// Client uploads objects to one storage target.
+type Client struct {
+	cfg        Config
+	endpoint   *url.URL
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// New creates a client. The endpoint must be an absolute http or https URL when set.
+func New(cfg Config, httpClient *http.Client) (*Client, error) {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q: must be an http or https URL", cfg.Endpoint)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{cfg: cfg, endpoint: endpoint, httpClient: httpClient, now: time.Now}, nil
+}
+
+// ObjectURL returns the URL of an object.
+func (c *Client) ObjectURL(bucket, key string) string {
+	u := *c.endpoint
+	path := strings.TrimSuffix(u.Path, "/")
+	if c.cfg.PathStyle {
+		path += "/" + bucket
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+	u.Path = path + "/" + key
+	u.RawPath = path + "/" + encodePath(key)
+	u.RawQuery = ""
+	return u.String()
+}
+
+// Put uploads an object, replacing any object with the same key.
+func (c *Client) Put(ctx context.Context, in PutInput) (*PutOutput, error) {
+	if in.Bucket == "" || in.Key == "" {
+		return nil, errors.New("bucket and key are required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	objectURL := c.ObjectURL(in.Bucket, in.Key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL, bytes.NewReader(in.Body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.ContentLength = int64(len(in.Body))
+	if in.ContentType != "" {
+		req.Header.Set("Content-Type", in.ContentType)
+	}
+	if in.Encryption != "" {
+		req.Header.Set("X-Amz-Server-Side-Encryption", in.Encryption)
+		if in.KMSKeyID != "" {
+			req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", in.KMSKeyID)
+		}
+	}
+	c.sign(req, in.Body, c.now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("put %s/%s: %w", in.Bucket, in.Key, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, fmt.Errorf("put %s/%s: %s", in.Bucket, in.Key, describeError(resp.StatusCode, body))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return &PutOutput{URL: objectURL, ETag: strings.Trim(resp.Header.Get("ETag"), `"`)}, nil
+}
+
+// describeError extracts the code and message from an S3 XML error response.
+func describeError(status int, body []byte) string {
+	var s3err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &s3err) == nil && s3err.Code != "" {
+		return fmt.Sprintf("status %d: %s: %s", status, s3err.Code, s3err.Message)
+	}
+	return fmt.Sprintf("status %d", status)
+}
+
+// sign adds Signature Version 4 headers. Host, Content-Type, Content-MD5, Date and all
+// X-Amz-* headers are signed.
+func (c *Client) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "content-md5" || lower == "date" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, c.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), values[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, encodeComponent(k)+"="+encodeComponent(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// encodePath percent-encodes an object key for the request path, keeping slashes.
+func encodePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = encodeComponent(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// encodeComponent percent-encodes everything except the RFC 3986 unreserved characters, as SigV4 requires.
+func encodeComponent(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z') || ('0' <= ch && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -2,8 +2,13 @@ package openapi
 
 import (
 	"strconv"
+	"strings"
+	"time"
 
 	"sql-proxy/internal/config"
+	"sql-proxy/internal/loadshed"
+	"sql-proxy/internal/ratelimit"
+	"sql-proxy/internal/types"
 	"sql-proxy/internal/workflow"
 )
 
@@ -204,11 +209,27 @@ func buildPaths(cfg *config.Config) map[string]any {
 		},
 	}
 
-	// Add workflow HTTP trigger endpoints
+	// Add workflow HTTP trigger endpoints (one operation per method/path route)
 	for _, wf := range cfg.Workflows {
+		routes := 0
 		for _, trigger := range wf.Triggers {
-			if trigger.Type == "http" && trigger.Path != "" {
-				paths[trigger.Path] = buildWorkflowPath(wf, trigger, cfg.Server)
+			for _, route := range trigger.Expand() {
+				if route.Type != "http" || route.Path == "" {
+					continue
+				}
+				item, ok := paths[route.Path].(map[string]any)
+				if !ok {
+					item = make(map[string]any)
+					paths[route.Path] = item
+				}
+				for method, op := range buildWorkflowPath(wf, route, cfg.Server) {
+					// operationId must be unique: suffix additional routes of the same workflow
+					if routes > 0 {
+						op.(map[string]any)["operationId"] = wf.Name + "_" + strconv.Itoa(routes+1)
+					}
+					item[method] = op
+				}
+				routes++
 			}
 		}
 	}
@@ -217,9 +238,9 @@ func buildPaths(cfg *config.Config) map[string]any {
 }
 
 func buildWorkflowPath(wf workflow.WorkflowConfig, trigger workflow.TriggerConfig, serverCfg config.ServerConfig) map[string]any {
-	method := "get"
-	if trigger.Method == "POST" {
-		method = "post"
+	method := strings.ToLower(trigger.Method)
+	if method == "" {
+		method = "get"
 	}
 
 	// Build parameters
@@ -253,7 +274,7 @@ func buildWorkflowPath(wf workflow.WorkflowConfig, trigger workflow.TriggerConfi
 			"in":          "query",
 			"required":    p.Required,
 			"description": buildParamDescription(p),
-			"schema":      paramTypeToSchema(p.Type, p.Default),
+			"schema":      paramSchema(p),
 		}
 		params = append(params, param)
 	}
@@ -263,6 +284,19 @@ func buildWorkflowPath(wf workflow.WorkflowConfig, trigger workflow.TriggerConfi
 		effectiveTimeout = wf.TimeoutSec
 	}
 
+	// Error responses of problem_json workflows are RFC 7807 documents
+	errorContent := func(schema string) map[string]any {
+		contentType := "application/json"
+		if wf.ErrorFormat == workflow.ErrorFormatProblemJSON {
+			contentType, schema = workflow.ProblemContentType, "ProblemDetails"
+		}
+		return map[string]any{
+			contentType: map[string]any{
+				"schema": map[string]any{"$ref": "#/components/schemas/" + schema},
+			},
+		}
+	}
+
 	responses := map[string]any{
 		"200": map[string]any{
 			"description": "Successful workflow execution",
@@ -274,59 +308,181 @@ func buildWorkflowPath(wf workflow.WorkflowConfig, trigger workflow.TriggerConfi
 		},
 		"400": map[string]any{
 			"description": "Bad request (missing or invalid parameters)",
-			"content": map[string]any{
-				"application/json": map[string]any{
-					"schema": map[string]any{"$ref": "#/components/schemas/ErrorResponse"},
-				},
-			},
+			"content":     errorContent("ErrorResponse"),
 		},
 		"500": map[string]any{
 			"description": "Workflow execution failed",
-			"content": map[string]any{
-				"application/json": map[string]any{
-					"schema": map[string]any{"$ref": "#/components/schemas/ErrorResponse"},
-				},
-			},
+			"content":     errorContent("ErrorResponse"),
 		},
 		"504": map[string]any{
 			"description": "Workflow timeout",
-			"content": map[string]any{
-				"application/json": map[string]any{
-					"schema": map[string]any{"$ref": "#/components/schemas/ErrorResponse"},
-				},
-			},
+			"content":     errorContent("ErrorResponse"),
 		},
 	}
 
-	// Add 429 response if rate limiting is configured for this trigger
+	// Add 429 response and quota headers if rate limiting is configured for this trigger
 	if len(trigger.RateLimit) > 0 {
+		responses["200"].(map[string]any)["headers"] = rateLimitHeaders(serverCfg.RateLimitHeaders)
+		deniedHeaders := rateLimitHeaders(serverCfg.RateLimitHeaders)
+		deniedHeaders["Retry-After"] = map[string]any{
+			"description": "Seconds to wait before retrying",
+			"schema": map[string]any{
+				"type": "integer",
+			},
+		}
 		responses["429"] = map[string]any{
 			"description": "Rate limit exceeded",
+			"headers":     deniedHeaders,
+			"content":     errorContent("RateLimitErrorResponse"),
+		}
+	}
+
+	// Add 503 response if load shedding can reject this trigger
+	if serverCfg.LoadShedding != nil && trigger.Priority != loadshed.PriorityCritical {
+		responses["503"] = map[string]any{
+			"description": "Server overloaded",
 			"headers": map[string]any{
 				"Retry-After": map[string]any{
 					"description": "Seconds to wait before retrying",
-					"schema": map[string]any{
-						"type": "integer",
-					},
+					"schema":      map[string]any{"type": "integer"},
 				},
 			},
-			"content": map[string]any{
-				"application/json": map[string]any{
-					"schema": map[string]any{"$ref": "#/components/schemas/RateLimitErrorResponse"},
+			"content": errorContent("RateLimitErrorResponse"),
+		}
+	}
+
+	operation := map[string]any{
+		"summary":     wf.Name,
+		"description": "Workflow endpoint (default timeout: " + strconv.Itoa(effectiveTimeout) + "s)",
+		"tags":        []string{"Workflows"},
+		"operationId": wf.Name,
+		"parameters":  params,
+		"responses":   responses,
+	}
+
+	if trigger.Deprecated {
+		operation["deprecated"] = true
+		if trigger.Sunset != "" {
+			operation["description"] = operation["description"].(string) + "; deprecated, removed after " + trigger.Sunset
+		}
+	}
+
+	// A body schema that fails to compile is reported by config validation; leave it out here
+	if trigger.BodySchema != nil {
+		if schema, err := workflow.CompileSchema(trigger.BodySchema); err == nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{
+						"schema": toOpenAPISchema(schema.Inline()),
+					},
 				},
-			},
+			}
+		}
+	}
+
+	return map[string]any{method: operation}
+}
+
+// toOpenAPISchema converts JSON Schema 2020-12 constructs to their OpenAPI 3.0 equivalents:
+// null in type arrays becomes nullable, const becomes a single-value enum, numeric
+// exclusive bounds become boolean flags, and examples becomes example.
+// Keywords OpenAPI 3.0 lacks (patternProperties) are dropped.
+func toOpenAPISchema(v any) map[string]any {
+	switch val := v.(type) {
+	case bool:
+		if val {
+			return map[string]any{}
 		}
+		return map[string]any{"not": map[string]any{}}
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, child := range val {
+			switch k {
+			case "type":
+				types, ok := child.([]any)
+				if !ok {
+					out[k] = child
+					continue
+				}
+				var nonNull []any
+				for _, t := range types {
+					if t == "null" {
+						out["nullable"] = true
+					} else {
+						nonNull = append(nonNull, t)
+					}
+				}
+				if len(nonNull) == 1 {
+					out[k] = nonNull[0]
+				} else if len(nonNull) > 1 {
+					alternatives := make([]any, len(nonNull))
+					for i, t := range nonNull {
+						alternatives[i] = map[string]any{"type": t}
+					}
+					out["anyOf"] = alternatives
+				}
+			case "const":
+				out["enum"] = []any{child}
+			case "exclusiveMinimum", "exclusiveMaximum":
+				bound := "minimum"
+				if k == "exclusiveMaximum" {
+					bound = "maximum"
+				}
+				out[bound] = child
+				out[k] = true
+			case "examples":
+				if list, ok := child.([]any); ok && len(list) > 0 {
+					out["example"] = list[0]
+				}
+			case "patternProperties":
+			case "properties":
+				props := make(map[string]any)
+				if m, ok := child.(map[string]any); ok {
+					for name, prop := range m {
+						props[name] = toOpenAPISchema(prop)
+					}
+				}
+				out[k] = props
+			case "items", "not":
+				out[k] = toOpenAPISchema(child)
+			case "additionalProperties":
+				// OpenAPI 3.0 allows a boolean here
+				if _, isBool := child.(bool); isBool {
+					out[k] = child
+				} else {
+					out[k] = toOpenAPISchema(child)
+				}
+			case "allOf", "anyOf", "oneOf":
+				list, _ := child.([]any)
+				subs := make([]any, len(list))
+				for i, sub := range list {
+					subs[i] = toOpenAPISchema(sub)
+				}
+				out[k] = subs
+			default:
+				out[k] = child
+			}
+		}
+		return out
+	default:
+		return map[string]any{}
 	}
+}
 
+// rateLimitHeaders describes the quota headers sent on rate limited triggers
+func rateLimitHeaders(style string) map[string]any {
+	prefix := "X-RateLimit-"
+	resetDesc := "Unix time (seconds) when the quota is fully replenished"
+	if style == ratelimit.HeaderStyleIETF {
+		prefix = "RateLimit-"
+		resetDesc = "Seconds until the quota is fully replenished"
+	}
+	intSchema := map[string]any{"type": "integer"}
 	return map[string]any{
-		method: map[string]any{
-			"summary":     wf.Name,
-			"description": "Workflow endpoint (default timeout: " + strconv.Itoa(effectiveTimeout) + "s)",
-			"tags":        []string{"Workflows"},
-			"operationId": wf.Name,
-			"parameters":  params,
-			"responses":   responses,
-		},
+		prefix + "Limit":     map[string]any{"description": "Maximum requests allowed in a burst", "schema": intSchema},
+		prefix + "Remaining": map[string]any{"description": "Requests remaining in the current quota", "schema": intSchema},
+		prefix + "Reset":     map[string]any{"description": resetDesc, "schema": intSchema},
 	}
 }
 
@@ -338,6 +494,64 @@ func buildParamDescription(p workflow.ParamConfig) string {
 	return desc
 }
 
+// paramSchema returns the schema of a parameter, including its validation rules.
+// Rules on array parameters bound the item count and apply to the items.
+func paramSchema(p workflow.ParamConfig) map[string]any {
+	schema := paramTypeToSchema(p.Type, p.Default)
+	v := p.Validation
+	if v == nil {
+		return schema
+	}
+
+	target := schema
+	if items, ok := schema["items"].(map[string]any); ok {
+		target = items
+		if v.MinLength != nil {
+			schema["minItems"] = *v.MinLength
+		}
+		if v.MaxLength != nil {
+			schema["maxItems"] = *v.MaxLength
+		}
+	} else {
+		if v.MinLength != nil {
+			schema["minLength"] = *v.MinLength
+		}
+		if v.MaxLength != nil {
+			schema["maxLength"] = *v.MaxLength
+		}
+	}
+	if v.Min != nil {
+		target["minimum"] = *v.Min
+	}
+	if v.Max != nil {
+		target["maximum"] = *v.Max
+	}
+	if v.Pattern != "" {
+		target["pattern"] = v.Pattern
+	}
+	if len(v.Enum) > 0 {
+		baseType := types.ArrayBaseType(strings.ToLower(p.Type))
+		enum := make([]any, 0, len(v.Enum))
+		for _, allowed := range v.Enum {
+			value, err := types.ConvertValue(allowed, baseType)
+			if _, isTime := value.(time.Time); err != nil || isTime {
+				value = allowed
+			}
+			enum = append(enum, value)
+		}
+		target["enum"] = enum
+	}
+	if v.Expr != "" {
+		rule := v.Expr
+		if v.Message != "" {
+			rule = v.Message
+		}
+		desc, _ := schema["description"].(string)
+		schema["description"] = strings.TrimSpace(desc + " Must satisfy: " + rule)
+	}
+	return schema
+}
+
 func paramTypeToSchema(typeName string, defaultVal string) map[string]any {
 	schema := make(map[string]any)
 
@@ -359,6 +573,13 @@ func paramTypeToSchema(typeName string, defaultVal string) map[string]any {
 				schema["default"] = v
 			}
 		}
+	case "decimal":
+		// Documented as a string so clients send every digit
+		schema["type"] = "string"
+		schema["format"] = "decimal"
+		if defaultVal != "" {
+			schema["default"] = defaultVal
+		}
 	case "bool", "boolean":
 		schema["type"] = "boolean"
 		if defaultVal != "" {
@@ -444,10 +665,27 @@ func buildComponents() map[string]any {
 						"type":        "string",
 						"description": "Error message",
 					},
+					"code": map[string]any{
+						"type":        "string",
+						"description": "Stable machine-readable error code, e.g. SQLPROXY_PARAM_MISSING",
+					},
 					"request_id": map[string]any{
 						"type":        "string",
 						"description": "Unique request ID for tracing",
 					},
+					"violations": map[string]any{
+						"type":        "array",
+						"description": "Failed parameter validation rules or request body schema checks (400 responses only)",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"parameter": map[string]any{"type": "string", "description": "Parameter that failed a validation rule"},
+								"path":      map[string]any{"type": "string", "description": "JSON pointer to the body value that failed the schema"},
+								"rule":      map[string]any{"type": "string", "description": "Parameter rule (min, max, min_length, max_length, pattern, enum, expr) or JSON Schema keyword"},
+								"message":   map[string]any{"type": "string"},
+							},
+						},
+					},
 				},
 			},
 			"HealthResponse": map[string]any{
@@ -455,7 +693,7 @@ func buildComponents() map[string]any {
 				"properties": map[string]any{
 					"status": map[string]any{
 						"type": "string",
-						"enum": []string{"healthy", "degraded", "unhealthy"},
+						"enum": []string{"healthy", "warming", "degraded", "unhealthy"},
 					},
 					"databases": map[string]any{
 						"type":        "object",
@@ -484,7 +722,7 @@ func buildComponents() map[string]any {
 					},
 					"type": map[string]any{
 						"type":        "string",
-						"description": "Database type (sqlserver, mysql, sqlite)",
+						"description": "Database type (sqlserver, mysql, sqlite, mock)",
 					},
 					"readonly": map[string]any{
 						"type":        "boolean",
@@ -546,6 +784,22 @@ func buildComponents() map[string]any {
 								"burst": map[string]any{
 									"type": "integer",
 								},
+								"algorithm": map[string]any{
+									"type": "string",
+									"enum": []string{"token_bucket", "sliding_window", "leaky_bucket", "fixed_window"},
+								},
+								"window": map[string]any{
+									"type":        "string",
+									"description": "Quota window (quota pools only)",
+								},
+								"limit": map[string]any{
+									"type":        "integer",
+									"description": "Requests allowed per key per window (quota pools only)",
+								},
+								"persistent": map[string]any{
+									"type":        "boolean",
+									"description": "Quota counters are stored on disk and survive restarts",
+								},
 								"allowed": map[string]any{
 									"type": "integer",
 								},
@@ -561,6 +815,24 @@ func buildComponents() map[string]any {
 					},
 				},
 			},
+			"ProblemDetails": map[string]any{
+				"type":        "object",
+				"description": "RFC 7807 problem document of workflows with error_format: problem_json",
+				"properties": map[string]any{
+					"type":     map[string]any{"type": "string", "example": "about:blank"},
+					"title":    map[string]any{"type": "string", "description": "Text of the HTTP status"},
+					"status":   map[string]any{"type": "integer", "description": "HTTP status"},
+					"detail":   map[string]any{"type": "string", "description": "Error message"},
+					"code":     map[string]any{"type": "string", "description": "Stable machine-readable error code, e.g. SQLPROXY_PARAM_MISSING"},
+					"instance": map[string]any{"type": "string", "description": "Request ID of the occurrence, as urn:request:<id>"},
+					"violations": map[string]any{
+						"type":        "array",
+						"description": "Failed parameter validation rules or request body schema checks",
+						"items":       map[string]any{"type": "object"},
+					},
+					"retry_after_sec": map[string]any{"type": "integer", "description": "Seconds to wait before retrying (429 and 503 responses)"},
+				},
+			},
 			"RateLimitErrorResponse": map[string]any{
 				"type": "object",
 				"properties": map[string]any{
@@ -572,6 +844,10 @@ func buildComponents() map[string]any {
 						"type":    "string",
 						"example": "rate limit exceeded",
 					},
+					"code": map[string]any{
+						"type":    "string",
+						"example": "SQLPROXY_RATE_LIMITED",
+					},
 					"retry_after_sec": map[string]any{
 						"type":        "integer",
 						"description": "Seconds to wait before retrying",
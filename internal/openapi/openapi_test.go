@@ -2,6 +2,8 @@ package openapi
 
 import (
 	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 
 	"sql-proxy/internal/config"
@@ -171,6 +173,56 @@ func TestSpec_WorkflowEndpoints(t *testing.T) {
 	}
 }
 
+// TestSpec_MultiRouteTrigger tests triggers with methods/paths lists produce one operation per route
+func TestSpec_MultiRouteTrigger(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			DefaultTimeoutSec: 30,
+			MaxTimeoutSec:     300,
+		},
+		Workflows: []workflow.WorkflowConfig{
+			{
+				Name: "items",
+				Triggers: []workflow.TriggerConfig{
+					{
+						Type:    "http",
+						Paths:   []string{"/api/items", "/api/v2/items"},
+						Methods: []string{"GET", "PUT"},
+					},
+				},
+				Steps: []workflow.StepConfig{
+					{Type: "response", Template: "{}"},
+				},
+			},
+		},
+	}
+
+	spec := Spec(cfg)
+	paths := spec["paths"].(map[string]any)
+
+	operationIDs := make(map[string]bool)
+	for _, path := range []string{"/api/items", "/api/v2/items"} {
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			t.Fatalf("expected %s path", path)
+		}
+		for _, method := range []string{"get", "put"} {
+			op, ok := item[method].(map[string]any)
+			if !ok {
+				t.Fatalf("expected %s operation for %s", method, path)
+			}
+			id := op["operationId"].(string)
+			if operationIDs[id] {
+				t.Errorf("duplicate operationId %q", id)
+			}
+			operationIDs[id] = true
+		}
+	}
+	if !operationIDs["items"] {
+		t.Errorf("expected first route to keep operationId 'items', got %v", operationIDs)
+	}
+}
+
 // TestSpec_SkipsCronOnlyWorkflows verifies cron-only workflows are excluded from paths
 func TestSpec_SkipsCronOnlyWorkflows(t *testing.T) {
 	cfg := &config.Config{
@@ -344,6 +396,100 @@ func TestBuildWorkflowPath_Responses(t *testing.T) {
 	}
 }
 
+// TestBuildWorkflowPath_ProblemJSON tests error responses of problem_json workflows are problem documents
+func TestBuildWorkflowPath_ProblemJSON(t *testing.T) {
+	wf := workflow.WorkflowConfig{Name: "test", ErrorFormat: workflow.ErrorFormatProblemJSON}
+	trigger := workflow.TriggerConfig{Type: "http", Path: "/api/test", Method: "GET"}
+
+	path := buildWorkflowPath(wf, trigger, config.ServerConfig{DefaultTimeoutSec: 30, MaxTimeoutSec: 300})
+	responses := path["get"].(map[string]any)["responses"].(map[string]any)
+	content := responses["400"].(map[string]any)["content"].(map[string]any)
+	problem, ok := content["application/problem+json"].(map[string]any)
+	if !ok {
+		t.Fatalf("400 content = %v, want application/problem+json", content)
+	}
+	if ref := problem["schema"].(map[string]any)["$ref"]; ref != "#/components/schemas/ProblemDetails" {
+		t.Errorf("400 schema = %v, want ProblemDetails", ref)
+	}
+	if _, ok := responses["200"].(map[string]any)["content"].(map[string]any)["application/json"]; !ok {
+		t.Error("200 should stay application/json")
+	}
+}
+
+// TestBuildWorkflowPath_RateLimitHeaders tests quota headers are documented in the configured style
+func TestBuildWorkflowPath_RateLimitHeaders(t *testing.T) {
+	wf := workflow.WorkflowConfig{Name: "test"}
+	trigger := workflow.TriggerConfig{
+		Type:      "http",
+		Path:      "/api/test",
+		Method:    "GET",
+		RateLimit: []workflow.RateLimitRefConfig{{Pool: "default"}},
+	}
+
+	for style, header := range map[string]string{"": "X-RateLimit-Remaining", "ietf": "RateLimit-Remaining"} {
+		serverCfg := config.ServerConfig{DefaultTimeoutSec: 30, MaxTimeoutSec: 300, RateLimitHeaders: style}
+		responses := buildWorkflowPath(wf, trigger, serverCfg)["get"].(map[string]any)["responses"].(map[string]any)
+
+		okHeaders := responses["200"].(map[string]any)["headers"].(map[string]any)
+		if okHeaders[header] == nil {
+			t.Errorf("style %q: expected %s on 200 response", style, header)
+		}
+		deniedHeaders := responses["429"].(map[string]any)["headers"].(map[string]any)
+		if deniedHeaders[header] == nil || deniedHeaders["Retry-After"] == nil {
+			t.Errorf("style %q: expected %s and Retry-After on 429 response", style, header)
+		}
+		if okHeaders["Retry-After"] != nil {
+			t.Errorf("style %q: Retry-After should only be documented on 429", style)
+		}
+	}
+}
+
+// TestBuildWorkflowPath_LoadShedding tests 503 is documented for triggers load shedding can reject
+func TestBuildWorkflowPath_LoadShedding(t *testing.T) {
+	wf := workflow.WorkflowConfig{Name: "test"}
+	serverCfg := config.ServerConfig{DefaultTimeoutSec: 30, MaxTimeoutSec: 300, LoadShedding: &config.LoadSheddingConfig{MaxInFlight: 100}}
+
+	tests := []struct {
+		priority string
+		want503  bool
+	}{
+		{"", true},
+		{"low", true},
+		{"critical", false},
+	}
+	for _, tt := range tests {
+		trigger := workflow.TriggerConfig{Type: "http", Path: "/api/test", Method: "GET", Priority: tt.priority}
+		responses := buildWorkflowPath(wf, trigger, serverCfg)["get"].(map[string]any)["responses"].(map[string]any)
+		if _, ok := responses["503"]; ok != tt.want503 {
+			t.Errorf("priority %q: 503 documented = %v, want %v", tt.priority, ok, tt.want503)
+		}
+	}
+
+	responses := buildWorkflowPath(wf, workflow.TriggerConfig{Type: "http", Path: "/api/test", Method: "GET"}, config.ServerConfig{DefaultTimeoutSec: 30, MaxTimeoutSec: 300})["get"].(map[string]any)["responses"].(map[string]any)
+	if _, ok := responses["503"]; ok {
+		t.Error("503 documented without load shedding")
+	}
+}
+
+// TestBuildWorkflowPath_Deprecated tests deprecated triggers are marked deprecated with their sunset
+func TestBuildWorkflowPath_Deprecated(t *testing.T) {
+	wf := workflow.WorkflowConfig{Name: "test"}
+	serverCfg := config.ServerConfig{DefaultTimeoutSec: 30, MaxTimeoutSec: 300}
+
+	op := buildWorkflowPath(wf, workflow.TriggerConfig{Type: "http", Path: "/v1/test", Method: "GET", Deprecated: true, Sunset: "2025-06-30"}, serverCfg)["get"].(map[string]any)
+	if op["deprecated"] != true {
+		t.Errorf("deprecated = %v, want true", op["deprecated"])
+	}
+	if desc := op["description"].(string); !strings.HasSuffix(desc, "; deprecated, removed after 2025-06-30") {
+		t.Errorf("description = %q", desc)
+	}
+
+	op = buildWorkflowPath(wf, workflow.TriggerConfig{Type: "http", Path: "/v2/test", Method: "GET"}, serverCfg)["get"].(map[string]any)
+	if _, ok := op["deprecated"]; ok {
+		t.Error("current trigger marked deprecated")
+	}
+}
+
 // TestBuildParamDescription tests parameter description includes type and default
 func TestBuildParamDescription(t *testing.T) {
 	tests := []struct {
@@ -396,6 +542,8 @@ func TestParamTypeToSchema(t *testing.T) {
 		{"float", "", "number", "double", nil},
 		{"float", "3.14", "number", "double", 3.14}, // float defaults are parsed
 		{"double", "", "number", "double", nil},
+		{"decimal", "", "string", "decimal", nil},
+		{"decimal", "10.50", "string", "decimal", "10.50"}, // decimal defaults keep their digits
 		{"bool", "", "boolean", "", nil},
 		{"bool", "true", "boolean", "", true}, // bool defaults are parsed
 		{"boolean", "", "boolean", "", nil},
@@ -449,6 +597,7 @@ func TestBuildComponents(t *testing.T) {
 	requiredSchemas := []string{
 		"WorkflowResponse",
 		"ErrorResponse",
+		"ProblemDetails",
 		"HealthResponse",
 		"MetricsResponse",
 	}
@@ -685,6 +834,89 @@ func TestParamTypeToSchema_JSONType(t *testing.T) {
 	}
 }
 
+// TestParamSchema_Validation tests that parameter validation rules become schema constraints
+func TestParamSchema_Validation(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	n := func(v int) *int { return &v }
+
+	t.Run("scalar", func(t *testing.T) {
+		schema := paramSchema(workflow.ParamConfig{Name: "code", Type: "string", Validation: &workflow.ParamValidation{
+			MinLength: n(2), MaxLength: n(3), Pattern: "^[A-Z]+$", Enum: []string{"US", "DE"},
+		}})
+		want := map[string]any{"type": "string", "minLength": 2, "maxLength": 3, "pattern": "^[A-Z]+$", "enum": []any{"US", "DE"}}
+		if !reflect.DeepEqual(schema, want) {
+			t.Errorf("schema = %v, want %v", schema, want)
+		}
+	})
+
+	t.Run("array", func(t *testing.T) {
+		schema := paramSchema(workflow.ParamConfig{Name: "ids", Type: "int[]", Validation: &workflow.ParamValidation{
+			MaxLength: n(10), Min: f(1), Enum: []string{"1", "2"},
+		}})
+		items := schema["items"].(map[string]any)
+		if schema["maxItems"] != 10 || items["minimum"] != 1.0 || !reflect.DeepEqual(items["enum"], []any{1, 2}) {
+			t.Errorf("schema = %v", schema)
+		}
+	})
+
+	t.Run("expr", func(t *testing.T) {
+		schema := paramSchema(workflow.ParamConfig{Name: "end", Type: "int", Validation: &workflow.ParamValidation{
+			Expr: "value >= params.start", Message: "end must not be before start",
+		}})
+		if schema["description"] != "Must satisfy: end must not be before start" {
+			t.Errorf("description = %v", schema["description"])
+		}
+	})
+}
+
+// TestBuildWorkflowPath_BodySchema tests that a trigger's body schema becomes an OpenAPI 3.0 request body
+func TestBuildWorkflowPath_BodySchema(t *testing.T) {
+	var bodySchema any
+	_ = json.Unmarshal([]byte(`{
+		"$defs": {"qty": {"type": "integer", "exclusiveMinimum": 0}},
+		"type": "object",
+		"required": ["sku"],
+		"properties": {
+			"sku": {"type": "string", "examples": ["A-1"]},
+			"qty": {"$ref": "#/$defs/qty"},
+			"note": {"type": ["string", "null"]},
+			"kind": {"const": "order"}
+		},
+		"additionalProperties": false
+	}`), &bodySchema)
+	trigger := workflow.TriggerConfig{Type: "http", Path: "/orders", Method: "POST", BodySchema: bodySchema}
+
+	path := buildWorkflowPath(workflow.WorkflowConfig{Name: "create_order"}, trigger, config.ServerConfig{DefaultTimeoutSec: 30, MaxTimeoutSec: 300})
+
+	body, ok := path["post"].(map[string]any)["requestBody"].(map[string]any)
+	if !ok || body["required"] != true {
+		t.Fatalf("requestBody = %v", path["post"].(map[string]any)["requestBody"])
+	}
+	got := body["content"].(map[string]any)["application/json"].(map[string]any)["schema"]
+	want := map[string]any{
+		"type":     "object",
+		"required": []any{"sku"},
+		"properties": map[string]any{
+			"sku":  map[string]any{"type": "string", "example": "A-1"},
+			"qty":  map[string]any{"type": "integer", "minimum": 0.0, "exclusiveMinimum": true},
+			"note": map[string]any{"type": "string", "nullable": true},
+			"kind": map[string]any{"enum": []any{"order"}},
+		},
+		"additionalProperties": false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		g, _ := json.Marshal(got)
+		t.Errorf("schema = %s", g)
+	}
+
+	// Triggers without a body schema have no request body
+	trigger.BodySchema = nil
+	path = buildWorkflowPath(workflow.WorkflowConfig{Name: "create_order"}, trigger, config.ServerConfig{})
+	if _, ok := path["post"].(map[string]any)["requestBody"]; ok {
+		t.Error("expected no requestBody without body_schema")
+	}
+}
+
 // TestBuildWorkflowPath_DefaultTimeout tests server default timeout used when workflow has none
 func TestBuildWorkflowPath_DefaultTimeout(t *testing.T) {
 	// Workflow without custom timeout uses server default
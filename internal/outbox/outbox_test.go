@@ -0,0 +1,264 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/db"
+	"sql-proxy/internal/workflow/step"
+)
+
+// sqliteDB runs relay statements on an in-memory SQLite database
+type sqliteDB struct {
+	driver *db.SQLiteDriver
+}
+
+func (d *sqliteDB) ExecuteQuery(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+	if len(opts.Outbox) > 0 {
+		ctx = db.WithOutbox(ctx, DefaultTable, opts.Outbox)
+	}
+	return d.driver.Query(ctx, config.SessionConfig{}, sql, params, nil)
+}
+
+func newSQLiteDB(t *testing.T) *sqliteDB {
+	t.Helper()
+	readOnly := false
+	driver, err := db.NewSQLiteDriver(config.DatabaseConfig{Name: "app", Type: "sqlite", Path: ":memory:", ReadOnly: &readOnly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = driver.Close() })
+	d := &sqliteDB{driver: driver}
+	_, err = d.ExecuteQuery(context.Background(), "app", `CREATE TABLE outbox (
+		id TEXT PRIMARY KEY,
+		topic TEXT NOT NULL,
+		event_key TEXT,
+		payload TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		published_at TIMESTAMP,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT
+	)`, nil, step.QueryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// recordEvents inserts events the way a query step does, alongside a statement
+func (d *sqliteDB) recordEvents(t *testing.T, events ...Event) {
+	t.Helper()
+	if _, err := d.ExecuteQuery(context.Background(), "app", "SELECT 1", nil, step.QueryOptions{Outbox: events}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (d *sqliteDB) rows(t *testing.T) []map[string]any {
+	t.Helper()
+	result, err := d.ExecuteQuery(context.Background(), "app", "SELECT id, published_at, attempts, last_error FROM outbox ORDER BY id", nil, step.QueryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Rows
+}
+
+// fakeSink records published events and fails while err is set
+type fakeSink struct {
+	mu        sync.Mutex
+	published []Event
+	err       error
+	closed    bool
+}
+
+func (s *fakeSink) Publish(ctx context.Context, ev Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.published = append(s.published, ev)
+	return nil
+}
+
+func (s *fakeSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+type testLogger struct{}
+
+func (testLogger) Debug(string, map[string]any) {}
+func (testLogger) Info(string, map[string]any)  {}
+func (testLogger) Warn(string, map[string]any)  {}
+func (testLogger) Error(string, map[string]any) {}
+
+func TestRelay_PublishesInOrder(t *testing.T) {
+	d := newSQLiteDB(t)
+	d.recordEvents(t,
+		Event{ID: "01", Topic: "order.created", Key: "42", Payload: `{"id":42}`},
+		Event{ID: "02", Topic: "order.paid", Key: "42", Payload: `{"id":42}`},
+		Event{ID: "03", Topic: "order.created", Payload: `{"id":43}`},
+	)
+
+	sink := &fakeSink{}
+	relay := NewRelay(Config{Database: "app", DBType: "sqlite", BatchSize: 2}, d, sink, testLogger{})
+	relay.poll(context.Background())
+
+	if len(sink.published) != 3 {
+		t.Fatalf("published %d events, want 3 across two batches", len(sink.published))
+	}
+	for i, id := range []string{"01", "02", "03"} {
+		if sink.published[i].ID != id {
+			t.Errorf("published[%d] = %s, want %s", i, sink.published[i].ID, id)
+		}
+	}
+	if ev := sink.published[0]; ev.Topic != "order.created" || ev.Key != "42" || ev.Payload != `{"id":42}` {
+		t.Errorf("event = %+v", ev)
+	}
+	for _, row := range d.rows(t) {
+		if row["published_at"] == nil {
+			t.Errorf("event %v not marked published", row["id"])
+		}
+	}
+
+	// Published events are not sent again
+	relay.poll(context.Background())
+	if len(sink.published) != 3 {
+		t.Errorf("published %d events after a second poll", len(sink.published))
+	}
+}
+
+func TestRelay_FailureStopsAndRetries(t *testing.T) {
+	d := newSQLiteDB(t)
+	d.recordEvents(t, Event{ID: "01", Topic: "a", Payload: "{}"}, Event{ID: "02", Topic: "b", Payload: "{}"})
+
+	sink := &fakeSink{err: errors.New("broker unavailable")}
+	relay := NewRelay(Config{Database: "app", DBType: "sqlite"}, d, sink, testLogger{})
+	relay.poll(context.Background())
+	relay.poll(context.Background())
+
+	rows := d.rows(t)
+	if rows[0]["attempts"] != int64(2) || rows[0]["last_error"] != "broker unavailable" || rows[0]["published_at"] != nil {
+		t.Errorf("failed event = %v", rows[0])
+	}
+	// Later events wait for the failed one
+	if rows[1]["attempts"] != int64(0) {
+		t.Errorf("second event was attempted: %v", rows[1])
+	}
+
+	sink.err = nil
+	relay.poll(context.Background())
+	if len(sink.published) != 2 || sink.published[0].ID != "01" {
+		t.Errorf("published after recovery = %+v", sink.published)
+	}
+}
+
+func TestRelay_Cleanup(t *testing.T) {
+	d := newSQLiteDB(t)
+	d.recordEvents(t, Event{ID: "old", Topic: "a", Payload: "{}"}, Event{ID: "new", Topic: "a", Payload: "{}"}, Event{ID: "pending", Topic: "a", Payload: "{}"})
+	ctx := context.Background()
+	for id, at := range map[string]time.Time{"old": time.Now().UTC().Add(-48 * time.Hour), "new": time.Now().UTC()} {
+		if _, err := d.ExecuteQuery(ctx, "app", publishedSQL(DefaultTable), map[string]any{"id": id, "now": at}, step.QueryOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sink := &fakeSink{err: errors.New("down")}
+	relay := NewRelay(Config{Database: "app", DBType: "sqlite", Retention: 24 * time.Hour}, d, sink, testLogger{})
+	relay.poll(ctx)
+
+	var ids []string
+	for _, row := range d.rows(t) {
+		ids = append(ids, row["id"].(string))
+	}
+	if strings.Join(ids, ",") != "new,pending" {
+		t.Errorf("remaining events = %v, want new,pending", ids)
+	}
+}
+
+func TestRelay_RunClosesSink(t *testing.T) {
+	d := newSQLiteDB(t)
+	sink := &fakeSink{}
+	relay := NewRelay(Config{Database: "app", DBType: "sqlite", PollInterval: time.Millisecond}, d, sink, testLogger{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+	d.recordEvents(t, Event{ID: "01", Topic: "a", Payload: "{}"})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sink.mu.Lock()
+		n := len(sink.published)
+		sink.mu.Unlock()
+		if n == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if len(sink.published) != 1 || !sink.closed {
+		t.Errorf("published = %d, closed = %v", len(sink.published), sink.closed)
+	}
+}
+
+func TestPendingSQL(t *testing.T) {
+	if got := pendingSQL("sqlserver", "dbo.outbox"); !strings.HasPrefix(got, "SELECT TOP (@batch_size) ") || !strings.Contains(got, "FROM dbo.outbox WHERE published_at IS NULL") {
+		t.Errorf("sqlserver: %s", got)
+	}
+	if got := pendingSQL("mysql", "outbox"); !strings.HasSuffix(got, "ORDER BY created_at, id LIMIT @batch_size") {
+		t.Errorf("mysql: %s", got)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got *http.Request
+	var body string
+	status := http.StatusAccepted
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink(srv.URL, map[string]string{"Authorization": "Bearer abc"}, 0, false)
+	ev := Event{ID: "0192", Topic: "order.created", Key: "42", Payload: `{"id":42}`}
+	if err := sink.Publish(context.Background(), ev); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if got.Method != http.MethodPost || body != ev.Payload {
+		t.Errorf("request = %s %q", got.Method, body)
+	}
+	for header, want := range map[string]string{
+		"Idempotency-Key": "0192",
+		"X-Outbox-Topic":  "order.created",
+		"X-Outbox-Key":    "42",
+		"Authorization":   "Bearer abc",
+		"Content-Type":    "application/json",
+	} {
+		if v := got.Header.Get(header); v != want {
+			t.Errorf("%s = %q, want %q", header, v, want)
+		}
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := sink.Publish(context.Background(), ev); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("err = %v, want status 503", err)
+	}
+}
@@ -0,0 +1,225 @@
+// Package outbox relays the events that query steps record in a database's
+// outbox table to a webhook, Kafka, or AMQP. Events are inserted in the same
+// transaction as the statement that caused them, so they exist exactly when
+// the change committed; the relay publishes them at least once, in order, with
+// the event id as idempotency key for consumers to drop redeliveries.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sql-proxy/internal/db"
+	"sql-proxy/internal/workflow/step"
+)
+
+// Defaults for outboxes that leave them unset
+const (
+	DefaultTable        = "outbox"
+	DefaultPollInterval = 5 * time.Second
+	DefaultBatchSize    = 100
+	DefaultRetention    = 7 * 24 * time.Hour
+)
+
+// cleanupInterval is how often published events past retention are deleted
+const cleanupInterval = time.Hour
+
+// maxErrorLength bounds the publish error stored in last_error
+const maxErrorLength = 1000
+
+// Event is an outbox row. ID is the idempotency key sent with it.
+type Event = step.OutboxEvent
+
+// Sink publishes events. Publish returns once the event is durably accepted.
+type Sink interface {
+	Publish(ctx context.Context, ev Event) error
+	Close() error
+}
+
+// Config is an outbox relay's database table and polling settings.
+type Config struct {
+	Database     string
+	DBType       string // sqlserver, mysql, or sqlite
+	Table        string // Default: DefaultTable
+	PollInterval time.Duration
+	BatchSize    int
+	Retention    time.Duration
+}
+
+// Relay polls an outbox table and publishes unpublished events to a sink in
+// insertion order. An event is marked published only after the sink accepts
+// it; a failure stops the batch, is recorded on the row, and is retried on the
+// next poll, so later events never overtake an earlier one.
+type Relay struct {
+	cfg    Config
+	db     step.DBManager
+	sink   Sink
+	logger step.Logger
+
+	lastCleanup time.Time
+}
+
+// NewRelay creates a relay for cfg's table, reached through dbm.
+func NewRelay(cfg Config, dbm step.DBManager, sink Sink, logger step.Logger) *Relay {
+	if cfg.Table == "" {
+		cfg.Table = DefaultTable
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+	return &Relay{cfg: cfg, db: dbm, sink: sink, logger: logger}
+}
+
+// Run relays events until ctx is cancelled, starting right away, then closes the sink.
+func (r *Relay) Run(ctx context.Context) {
+	defer func() { _ = r.sink.Close() }()
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		r.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll relays full batches until the table is drained or a publish fails,
+// then deletes old published events once per cleanupInterval
+func (r *Relay) poll(ctx context.Context) {
+	defer func() {
+		if p := recover(); p != nil {
+			r.logger.Error("outbox_panic", map[string]any{
+				"database": r.cfg.Database,
+				"panic":    fmt.Sprintf("%v", p),
+			})
+		}
+	}()
+
+	for ctx.Err() == nil {
+		n, err := r.relayBatch(ctx)
+		if err != nil || n < r.cfg.BatchSize {
+			break
+		}
+	}
+	if ctx.Err() == nil && time.Since(r.lastCleanup) >= cleanupInterval {
+		r.cleanup(ctx)
+	}
+}
+
+// relayBatch publishes the next batch of events and returns their number
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	result, err := r.db.ExecuteQuery(ctx, r.cfg.Database, pendingSQL(r.cfg.DBType, r.cfg.Table), map[string]any{"batch_size": r.cfg.BatchSize}, step.QueryOptions{})
+	if err != nil {
+		r.logError("outbox_query_failed", "", err)
+		return 0, err
+	}
+
+	for _, row := range result.Rows {
+		ev := Event{ID: text(row["id"]), Topic: text(row["topic"]), Key: text(row["event_key"]), Payload: text(row["payload"])}
+		if err := r.sink.Publish(ctx, ev); err != nil {
+			r.logError("outbox_publish_failed", ev.ID, err)
+			msg := err.Error()
+			if len(msg) > maxErrorLength {
+				msg = msg[:maxErrorLength]
+			}
+			if _, uerr := r.db.ExecuteQuery(ctx, r.cfg.Database, failedSQL(r.cfg.Table),
+				map[string]any{"id": ev.ID, "error": msg}, step.QueryOptions{}); uerr != nil {
+				r.logError("outbox_update_failed", ev.ID, uerr)
+			}
+			return 0, err
+		}
+		// A failure here republishes the event on the next poll; the id lets consumers drop it
+		if _, err := r.db.ExecuteQuery(ctx, r.cfg.Database, publishedSQL(r.cfg.Table),
+			map[string]any{"id": ev.ID, "now": time.Now().UTC()}, step.QueryOptions{}); err != nil {
+			r.logError("outbox_update_failed", ev.ID, err)
+			return 0, err
+		}
+		r.logger.Debug("outbox_event_published", map[string]any{
+			"database": r.cfg.Database,
+			"id":       ev.ID,
+			"topic":    ev.Topic,
+		})
+	}
+	return len(result.Rows), nil
+}
+
+// cleanup deletes events published longer than the retention period ago
+func (r *Relay) cleanup(ctx context.Context) {
+	result, err := r.db.ExecuteQuery(ctx, r.cfg.Database, cleanupSQL(r.cfg.Table),
+		map[string]any{"cutoff": time.Now().UTC().Add(-r.cfg.Retention)}, step.QueryOptions{})
+	if err != nil {
+		r.logError("outbox_cleanup_failed", "", err)
+		return
+	}
+	r.lastCleanup = time.Now()
+	if result.RowsAffected > 0 {
+		r.logger.Info("outbox_cleanup", map[string]any{
+			"database": r.cfg.Database,
+			"deleted":  result.RowsAffected,
+		})
+	}
+}
+
+func (r *Relay) logError(event, id string, err error) {
+	fields := map[string]any{
+		"database": r.cfg.Database,
+		"table":    r.cfg.Table,
+		"error":    err.Error(),
+	}
+	if id != "" {
+		fields["id"] = id
+	}
+	r.logger.Error(event, fields)
+}
+
+// Statements returns the statements an outbox on table runs, for checking them
+// against the database's statement policy.
+func Statements(dbType, table string) []string {
+	return []string{db.OutboxInsertSQL(table), pendingSQL(dbType, table), failedSQL(table), publishedSQL(table), cleanupSQL(table)}
+}
+
+// pendingSQL selects the oldest unpublished events
+func pendingSQL(dbType, table string) string {
+	if dbType == "sqlserver" {
+		return "SELECT TOP (@batch_size) id, topic, event_key, payload FROM " + table + " WHERE published_at IS NULL ORDER BY created_at, id"
+	}
+	return "SELECT id, topic, event_key, payload FROM " + table + " WHERE published_at IS NULL ORDER BY created_at, id LIMIT @batch_size"
+}
+
+// failedSQL records a failed publish attempt
+func failedSQL(table string) string {
+	return "UPDATE " + table + " SET attempts = attempts + 1, last_error = @error WHERE id = @id"
+}
+
+// publishedSQL marks an event as published
+func publishedSQL(table string) string {
+	return "UPDATE " + table + " SET published_at = @now WHERE id = @id"
+}
+
+// cleanupSQL deletes events published before the cutoff
+func cleanupSQL(table string) string {
+	return "DELETE FROM " + table + " WHERE published_at < @cutoff"
+}
+
+// text returns a column value as a string, "" for NULL
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,135 @@
+package outbox
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"sql-proxy/internal/amqp"
+	"sql-proxy/internal/kafka"
+)
+
+// DefaultTimeout bounds a single publish when the sink sets no timeout
+const DefaultTimeout = 10 * time.Second
+
+// WebhookSink POSTs each event's payload to a URL. The event id is sent as
+// Idempotency-Key; any 2xx status accepts the event.
+type WebhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhookSink creates a sink posting to url with the extra headers.
+func NewWebhookSink(url string, headers map[string]string, timeout time.Duration, insecureSkipVerify bool) *WebhookSink {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+	if insecureSkipVerify {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		client.Transport = transport
+	}
+	return &WebhookSink{url: url, headers: headers, client: client}
+}
+
+// Publish posts ev and checks the response status.
+func (s *WebhookSink) Publish(ctx context.Context, ev Event) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(ev.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Idempotency-Key", ev.ID)
+	req.Header.Set("X-Outbox-Topic", ev.Topic)
+	if ev.Key != "" {
+		req.Header.Set("X-Outbox-Key", ev.Key)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close is a no-op; the sink holds no connection of its own.
+func (s *WebhookSink) Close() error { return nil }
+
+// KafkaSink produces each event as a record keyed by the event key, so events
+// with the same key stay in order on one partition.
+type KafkaSink struct {
+	producer *kafka.Producer
+	topic    string
+}
+
+// NewKafkaSink creates a sink producing to topic, or to each event's topic when empty.
+func NewKafkaSink(producer *kafka.Producer, topic string) *KafkaSink {
+	return &KafkaSink{producer: producer, topic: topic}
+}
+
+// Publish produces ev with the idempotency-key and topic headers.
+func (s *KafkaSink) Publish(ctx context.Context, ev Event) error {
+	rec := kafka.Record{
+		Topic: s.topic,
+		Value: []byte(ev.Payload),
+		Headers: []kafka.Header{
+			{Key: "idempotency-key", Value: []byte(ev.ID)},
+			{Key: "topic", Value: []byte(ev.Topic)},
+		},
+	}
+	if rec.Topic == "" {
+		rec.Topic = ev.Topic
+	}
+	if ev.Key != "" {
+		rec.Key = []byte(ev.Key)
+	}
+	return s.producer.Produce(ctx, rec)
+}
+
+// Close closes the producer's broker connections.
+func (s *KafkaSink) Close() error { return s.producer.Close() }
+
+// AMQPSink publishes each event to an exchange with the event's topic as
+// routing key and its id as message id.
+type AMQPSink struct {
+	publisher *amqp.Publisher
+	exchange  string
+}
+
+// NewAMQPSink creates a sink publishing to exchange ("" = the default exchange).
+func NewAMQPSink(publisher *amqp.Publisher, exchange string) *AMQPSink {
+	return &AMQPSink{publisher: publisher, exchange: exchange}
+}
+
+// Publish publishes ev and waits for the broker's confirm.
+func (s *AMQPSink) Publish(ctx context.Context, ev Event) error {
+	headers := map[string]string{"topic": ev.Topic}
+	if ev.Key != "" {
+		headers["key"] = ev.Key
+	}
+	return s.publisher.Publish(ctx, amqp.Message{
+		Exchange:    s.exchange,
+		RoutingKey:  ev.Topic,
+		Body:        []byte(ev.Payload),
+		ContentType: "application/json",
+		MessageID:   ev.ID,
+		Headers:     headers,
+	})
+}
+
+// Close closes the broker connection.
+func (s *AMQPSink) Close() error { return s.publisher.Close() }
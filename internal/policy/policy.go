@@ -0,0 +1,128 @@
+// Package policy restricts the statements allowed to run on a database.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/sqlutil"
+)
+
+// ErrViolation is returned for statements the policy does not allow.
+var ErrViolation = errors.New("statement policy violation")
+
+// Policy is a compiled statement policy.
+// A nil *Policy allows everything, so callers can use it unconditionally.
+type Policy struct {
+	selectOnly    bool
+	patterns      []*regexp.Regexp
+	tables        [][]string // Allowed names split into parts; "*" matches any part
+	defaultSchema string
+}
+
+// New compiles a statement policy. Returns nil (unrestricted) when cfg is nil.
+func New(cfg *config.StatementPolicyConfig) (*Policy, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	p := &Policy{selectOnly: cfg.SelectOnly, defaultSchema: cfg.DefaultSchema}
+	for _, pattern := range cfg.AllowPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allow_patterns entry %q: %w", pattern, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+	for _, table := range cfg.AllowedTables {
+		parts, err := ParseTable(table)
+		if err != nil {
+			return nil, err
+		}
+		p.tables = append(p.tables, parts)
+	}
+	return p, nil
+}
+
+// ParseTable splits an allowed_tables entry into its parts: one to three
+// non-empty names separated by dots, each possibly "*".
+func ParseTable(table string) ([]string, error) {
+	parts := strings.Split(table, ".")
+	if len(parts) > 3 || slices.Contains(parts, "") {
+		return nil, fmt.Errorf("invalid allowed_tables entry %q (use table, schema.table or database.schema.table)", table)
+	}
+	return parts, nil
+}
+
+// Check returns an error wrapping ErrViolation if the policy does not allow sql.
+func (p *Policy) Check(sql string) error {
+	if p == nil {
+		return nil
+	}
+	if p.selectOnly && !sqlutil.IsSelectOnly(sql) {
+		return fmt.Errorf("%w: only SELECT statements are allowed", ErrViolation)
+	}
+	if len(p.patterns) > 0 && !slices.ContainsFunc(p.patterns, func(re *regexp.Regexp) bool { return re.MatchString(sql) }) {
+		return fmt.Errorf("%w: statement matches no allowed pattern", ErrViolation)
+	}
+	if len(p.tables) == 0 {
+		return nil
+	}
+	refs, err := sqlutil.TableReferences(sql)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrViolation, err)
+	}
+	for _, ref := range refs {
+		if !p.allowsTable(ref) {
+			return fmt.Errorf("%w: %s is not an allowed table", ErrViolation, ref)
+		}
+	}
+	return nil
+}
+
+// CheckProc returns an error wrapping ErrViolation if the policy does not allow
+// calling the named stored procedure. Procedures are never SELECT-only and their
+// calls have no SQL text to match, so select_only and allow_patterns reject them;
+// under allowed_tables the procedure itself must be listed.
+func (p *Policy) CheckProc(name string) error {
+	switch {
+	case p == nil:
+		return nil
+	case p.selectOnly:
+		return fmt.Errorf("%w: stored procedure calls are not allowed (select_only)", ErrViolation)
+	case len(p.patterns) > 0:
+		return fmt.Errorf("%w: stored procedure calls are not allowed (allow_patterns)", ErrViolation)
+	case len(p.tables) > 0 && !p.allowsTable(name):
+		return fmt.Errorf("%w: %s is not an allowed procedure", ErrViolation, name)
+	}
+	return nil
+}
+
+// allowsTable reports whether a reference matches an allowed_tables entry: same
+// number of parts, each equal ignoring case or "*". Unqualified references are
+// qualified with the default schema first.
+func (p *Policy) allowsTable(ref string) bool {
+	parts := strings.Split(ref, ".")
+	if len(parts) == 1 && p.defaultSchema != "" {
+		parts = []string{p.defaultSchema, parts[0]}
+	}
+	for _, allowed := range p.tables {
+		if len(allowed) != len(parts) {
+			continue
+		}
+		match := true
+		for i, part := range allowed {
+			if part != "*" && !strings.EqualFold(part, parts[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
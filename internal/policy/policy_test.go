@@ -0,0 +1,119 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"sql-proxy/internal/config"
+)
+
+func TestNew_Unrestricted(t *testing.T) {
+	p, err := New(nil)
+	if err != nil || p != nil {
+		t.Fatalf("New(nil) = %v, %v; want nil, nil", p, err)
+	}
+
+	// Nil policy allows everything
+	if err := p.Check("DELETE FROM users"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := p.CheckProc("dbo.Purge"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.StatementPolicyConfig
+	}{
+		{"bad pattern", config.StatementPolicyConfig{AllowPatterns: []string{"(unclosed"}}},
+		{"empty part", config.StatementPolicyConfig{AllowedTables: []string{"dbo..Orders"}}},
+		{"too many parts", config.StatementPolicyConfig{AllowedTables: []string{"a.b.c.d"}}},
+		{"empty entry", config.StatementPolicyConfig{AllowedTables: []string{""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(&tt.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   config.StatementPolicyConfig
+		sql   string
+		allow bool
+	}{
+		{"select only allows select", config.StatementPolicyConfig{SelectOnly: true}, "SELECT * FROM users", true},
+		{"select only rejects insert", config.StatementPolicyConfig{SelectOnly: true}, "INSERT INTO users (id) VALUES (1)", false},
+		{"select only rejects select into", config.StatementPolicyConfig{SelectOnly: true}, "SELECT * INTO copy FROM users", false},
+
+		{"pattern match", config.StatementPolicyConfig{AllowPatterns: []string{`^SELECT .* FROM reports_\w+`}}, "SELECT id FROM reports_daily", true},
+		{"second pattern match", config.StatementPolicyConfig{AllowPatterns: []string{`^UPDATE`, `^SELECT`}}, "SELECT 1", true},
+		{"no pattern match", config.StatementPolicyConfig{AllowPatterns: []string{`^SELECT .* FROM reports_\w+`}}, "SELECT * FROM users", false},
+
+		{"allowed table", config.StatementPolicyConfig{AllowedTables: []string{"users"}}, "SELECT * FROM users", true},
+		{"allowed table ignores case", config.StatementPolicyConfig{AllowedTables: []string{"dbo.Users"}}, "SELECT * FROM [DBO].[users]", true},
+		{"table not allowed", config.StatementPolicyConfig{AllowedTables: []string{"users"}}, "SELECT * FROM users JOIN salaries ON 1 = 1", false},
+		{"subquery table not allowed", config.StatementPolicyConfig{AllowedTables: []string{"users"}}, "SELECT * FROM users WHERE id IN (SELECT id FROM salaries)", false},
+		{"schema wildcard", config.StatementPolicyConfig{AllowedTables: []string{"reporting.*"}}, "SELECT * FROM reporting.daily JOIN reporting.weekly ON 1 = 1", true},
+		{"cross-schema read", config.StatementPolicyConfig{AllowedTables: []string{"reporting.*"}}, "SELECT * FROM hr.salaries", false},
+		{"cross-database read", config.StatementPolicyConfig{AllowedTables: []string{"reporting.*"}}, "SELECT * FROM other.reporting.daily", false},
+		{"unqualified without default schema", config.StatementPolicyConfig{AllowedTables: []string{"reporting.*"}}, "SELECT * FROM daily", false},
+		{"default schema", config.StatementPolicyConfig{AllowedTables: []string{"reporting.*"}, DefaultSchema: "reporting"}, "SELECT * FROM daily", true},
+		{"cte is not a table", config.StatementPolicyConfig{AllowedTables: []string{"users"}}, "WITH u AS (SELECT * FROM users) SELECT * FROM u", true},
+		{"dynamic sql", config.StatementPolicyConfig{AllowedTables: []string{"*"}}, "EXEC (@sql)", false},
+
+		{"all rules pass", config.StatementPolicyConfig{SelectOnly: true, AllowPatterns: []string{`^SELECT`}, AllowedTables: []string{"users"}}, "SELECT * FROM users", true},
+		{"one rule fails", config.StatementPolicyConfig{SelectOnly: true, AllowPatterns: []string{`^SELECT`}, AllowedTables: []string{"users"}}, "SELECT * FROM orders", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(&tt.cfg)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			err = p.Check(tt.sql)
+			if tt.allow && err != nil {
+				t.Errorf("Check(%q) = %v, want allowed", tt.sql, err)
+			}
+			if !tt.allow && !errors.Is(err, ErrViolation) {
+				t.Errorf("Check(%q) = %v, want ErrViolation", tt.sql, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_CheckProc(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   config.StatementPolicyConfig
+		proc  string
+		allow bool
+	}{
+		{"select only", config.StatementPolicyConfig{SelectOnly: true}, "dbo.GetUsers", false},
+		{"patterns", config.StatementPolicyConfig{AllowPatterns: []string{".*"}}, "dbo.GetUsers", false},
+		{"listed", config.StatementPolicyConfig{AllowedTables: []string{"dbo.GetUsers"}}, "dbo.GetUsers", true},
+		{"not listed", config.StatementPolicyConfig{AllowedTables: []string{"dbo.Users"}}, "dbo.GetUsers", false},
+		{"empty policy", config.StatementPolicyConfig{}, "dbo.GetUsers", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(&tt.cfg)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			err = p.CheckProc(tt.proc)
+			if tt.allow && err != nil {
+				t.Errorf("CheckProc(%q) = %v, want allowed", tt.proc, err)
+			}
+			if !tt.allow && !errors.Is(err, ErrViolation) {
+				t.Errorf("CheckProc(%q) = %v, want ErrViolation", tt.proc, err)
+			}
+		})
+	}
+}
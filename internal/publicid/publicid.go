@@ -7,40 +7,108 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 )
 
-// Encoder handles encoding/decoding of public IDs using XTEA encryption
+// Encoder handles encoding/decoding of public IDs using XTEA encryption.
+// IDs made with a versioned key carry its id ("usr_k2_Xk9mPqR3vL2n") so they
+// keep decoding after the active key changes; IDs made with the unversioned
+// secret have none ("usr_Xk9mPqR3vL2n").
 type Encoder struct {
-	secret     string // Retained for future key rotation support (incoming/outgoing keys)
+	active     string // Key id new IDs are encoded with; "" for the unversioned secret
 	namespaces map[string]*Namespace
 }
 
 // Namespace represents a configured namespace for public ID generation
 type Namespace struct {
-	Name   string
-	Prefix string
-	key    [4]uint32 // XTEA 128-bit key as 4 uint32s
+	Name      string
+	Prefix    string
+	Format    string
+	minLength int
+	key       *keyMaterial            // From the unversioned secret, nil if none
+	keys      map[string]*keyMaterial // From versioned secrets, by key id
+}
+
+// keyMaterial holds what one secret derives for one namespace
+type keyMaterial struct {
+	xtea     [4]uint32 // XTEA 128-bit key as 4 uint32s
+	mac      []byte    // Authenticates the ciphertext in uuid IDs
+	alphabet string    // Shuffled alphabet for sqids IDs
 }
 
 // NamespaceConfig represents configuration for a namespace
 type NamespaceConfig struct {
-	Name   string `yaml:"name"`
-	Prefix string `yaml:"prefix"`
+	Name      string `yaml:"name"`
+	Prefix    string `yaml:"prefix"`
+	Format    string `yaml:"format"`     // xtea (default), sqids, or uuid
+	MinLength int    `yaml:"min_length"` // Minimum length of sqids IDs (default 8)
+}
+
+// KeyConfig represents configuration for one versioned secret
+type KeyConfig struct {
+	ID     string `yaml:"id"`     // Embedded in each public ID encoded with this secret
+	Secret string `yaml:"secret"` // 32+ character secret
 }
 
+// Public ID formats
+const (
+	FormatXTEA  = "xtea"  // 11 base62 characters of the XTEA-encrypted ID
+	FormatSqids = "sqids" // Short, variable-length Sqids ID over a secret alphabet
+	FormatUUID  = "uuid"  // UUIDv7-shaped encrypted and authenticated ID
+)
+
+// ValidFormats lists the accepted namespace formats
+var ValidFormats = []string{FormatXTEA, FormatSqids, FormatUUID}
+
+// DefaultSqidsMinLength pads short sqids IDs so small ids do not stand out
+const DefaultSqidsMinLength = 8
+
+var keyIDRegex = regexp.MustCompile(`^[A-Za-z0-9]{1,16}$`)
+
 // Base62Alphabet is the character set used for base62 encoding.
 // Exported for use by template functions.
 const Base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
 
 // NewEncoder creates encoder with derived keys per namespace
 func NewEncoder(secret string, namespaces []NamespaceConfig) (*Encoder, error) {
-	if len(secret) < 32 {
+	return NewEncoderWithKeys(secret, "", nil, namespaces)
+}
+
+// NewEncoderWithKeys creates an encoder that also decodes IDs made with any of
+// the versioned keys. active names the key new IDs are encoded with; when it is
+// empty they use secret, as with NewEncoder. secret may be empty once no IDs
+// made with it need to decode.
+func NewEncoderWithKeys(secret, active string, keys []KeyConfig, namespaces []NamespaceConfig) (*Encoder, error) {
+	if secret == "" && len(keys) == 0 {
+		return nil, fmt.Errorf("secret key or at least one versioned key is required")
+	}
+	if secret != "" && len(secret) < 32 {
 		return nil, fmt.Errorf("secret key must be at least 32 characters")
 	}
+	secrets := make(map[string]string, len(keys))
+	for _, k := range keys {
+		if !keyIDRegex.MatchString(k.ID) {
+			return nil, fmt.Errorf("invalid key id %q: use 1 to 16 letters and digits", k.ID)
+		}
+		if _, dup := secrets[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		if len(k.Secret) < 32 {
+			return nil, fmt.Errorf("key %q: secret must be at least 32 characters", k.ID)
+		}
+		secrets[k.ID] = k.Secret
+	}
+	if active == "" && secret == "" {
+		return nil, fmt.Errorf("active key is required without a secret key")
+	}
+	if _, ok := secrets[active]; active != "" && !ok {
+		return nil, fmt.Errorf("active key %q is not configured", active)
+	}
 
 	e := &Encoder{
-		secret:     secret,
+		active:     active,
 		namespaces: make(map[string]*Namespace),
 	}
 
@@ -48,12 +116,40 @@ func NewEncoder(secret string, namespaces []NamespaceConfig) (*Encoder, error) {
 		if ns.Name == "" {
 			return nil, fmt.Errorf("namespace name cannot be empty")
 		}
-		key := deriveKey(secret, ns.Name)
-		e.namespaces[ns.Name] = &Namespace{
-			Name:   ns.Name,
-			Prefix: ns.Prefix,
-			key:    key,
+		format := ns.Format
+		if format == "" {
+			format = FormatXTEA
+		}
+		if !slices.Contains(ValidFormats, format) {
+			return nil, fmt.Errorf("namespace %s: invalid format %q (valid: %s)", ns.Name, ns.Format, strings.Join(ValidFormats, ", "))
+		}
+		if format == FormatUUID && ns.Prefix != "" {
+			return nil, fmt.Errorf("namespace %s: uuid IDs cannot have a prefix", ns.Name)
+		}
+		if ns.MinLength != 0 && format != FormatSqids {
+			return nil, fmt.Errorf("namespace %s: min_length only applies to the sqids format", ns.Name)
 		}
+		minLength := ns.MinLength
+		if minLength == 0 {
+			minLength = DefaultSqidsMinLength
+		}
+		if minLength < 1 || minLength > 64 {
+			return nil, fmt.Errorf("namespace %s: min_length must be between 1 and 64", ns.Name)
+		}
+		n := &Namespace{
+			Name:      ns.Name,
+			Prefix:    ns.Prefix,
+			Format:    format,
+			minLength: minLength,
+			keys:      make(map[string]*keyMaterial, len(secrets)),
+		}
+		if secret != "" {
+			n.key = deriveKeyMaterial(secret, ns.Name)
+		}
+		for id, s := range secrets {
+			n.keys[id] = deriveKeyMaterial(s, ns.Name)
+		}
+		e.namespaces[ns.Name] = n
 	}
 
 	return e, nil
@@ -72,6 +168,18 @@ func deriveKey(secret, namespace string) [4]uint32 {
 	return key
 }
 
+// deriveKeyMaterial derives the keys of every format for a namespace. The MAC
+// key and alphabet use their own labels so no two formats share key material.
+func deriveKeyMaterial(secret, namespace string) *keyMaterial {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("uuid-mac:" + namespace))
+	return &keyMaterial{
+		xtea:     deriveKey(secret, namespace),
+		mac:      mac.Sum(nil),
+		alphabet: shuffleAlphabet(secret, namespace),
+	}
+}
+
 // Encode converts internal ID to public ID
 func (e *Encoder) Encode(namespace string, id int64) (string, error) {
 	ns, ok := e.namespaces[namespace]
@@ -79,11 +187,27 @@ func (e *Encoder) Encode(namespace string, id int64) (string, error) {
 		return "", fmt.Errorf("unknown namespace: %s", namespace)
 	}
 
-	// XTEA encrypt the 64-bit ID
-	encrypted := xteaEncrypt(uint64(id), ns.key)
+	key := ns.key
+	keyPrefix := ""
+	if e.active != "" {
+		key = ns.keys[e.active]
+		keyPrefix = e.active + "_"
+	}
 
-	// Encode to base62
-	encoded := encodeBase62(encrypted)
+	var encoded string
+	switch ns.Format {
+	case FormatUUID:
+		// UUIDs have no room for a key id; Decode finds the key by its MAC
+		return encodeUUID(id, key), nil
+	case FormatSqids:
+		if id < 0 {
+			return "", fmt.Errorf("sqids public IDs cannot encode negative id %d", id)
+		}
+		encoded = keyPrefix + encodeSqids(uint64(id), key.alphabet, ns.minLength)
+	default:
+		// XTEA encrypt the 64-bit ID and encode to base62
+		encoded = keyPrefix + encodeBase62(xteaEncrypt(uint64(id), key.xtea))
+	}
 
 	// Add prefix if configured
 	if ns.Prefix != "" {
@@ -99,6 +223,10 @@ func (e *Encoder) Decode(namespace string, publicID string) (int64, error) {
 		return 0, fmt.Errorf("unknown namespace: %s", namespace)
 	}
 
+	if ns.Format == FormatUUID {
+		return decodeUUID(publicID, ns.key, ns.keys)
+	}
+
 	// Strip prefix if present
 	encoded := publicID
 	if ns.Prefix != "" {
@@ -109,6 +237,27 @@ func (e *Encoder) Decode(namespace string, publicID string) (int64, error) {
 		encoded = strings.TrimPrefix(publicID, expectedPrefix)
 	}
 
+	// Select the key named before the encrypted value, if any
+	key := ns.key
+	if keyID, rest, versioned := strings.Cut(encoded, "_"); versioned {
+		k, ok := ns.keys[keyID]
+		if !ok {
+			return 0, fmt.Errorf("invalid public ID format: unknown key %q", keyID)
+		}
+		key = k
+		encoded = rest
+	} else if key == nil {
+		return 0, fmt.Errorf("invalid public ID format: missing key id")
+	}
+
+	if ns.Format == FormatSqids {
+		id, err := decodeSqids(encoded, key.alphabet, ns.minLength)
+		if err != nil {
+			return 0, fmt.Errorf("invalid public ID format: %w", err)
+		}
+		return int64(id), nil
+	}
+
 	// Decode from base62
 	encrypted, err := decodeBase62(encoded)
 	if err != nil {
@@ -116,11 +265,17 @@ func (e *Encoder) Decode(namespace string, publicID string) (int64, error) {
 	}
 
 	// XTEA decrypt
-	decrypted := xteaDecrypt(encrypted, ns.key)
+	decrypted := xteaDecrypt(encrypted, key.xtea)
 
 	return int64(decrypted), nil
 }
 
+// ActiveKey returns the id of the key new IDs are encoded with, or "" for the
+// unversioned secret
+func (e *Encoder) ActiveKey() string {
+	return e.active
+}
+
 // HasNamespace checks if a namespace is configured
 func (e *Encoder) HasNamespace(namespace string) bool {
 	_, ok := e.namespaces[namespace]
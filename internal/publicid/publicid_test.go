@@ -1,7 +1,10 @@
 package publicid
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/google/uuid"
 )
 
 func TestNewEncoder(t *testing.T) {
@@ -351,3 +354,235 @@ func TestDifferentSecretsProduceDifferentOutput(t *testing.T) {
 		t.Error("Different secrets should produce different encoded values")
 	}
 }
+
+func TestNewEncoderWithKeys(t *testing.T) {
+	secret := "this-is-a-secret-key-that-is-32chars"
+	k1 := KeyConfig{ID: "k1", Secret: "key-one-secret-that-is-32-chars!!"}
+	tests := []struct {
+		name    string
+		secret  string
+		active  string
+		keys    []KeyConfig
+		wantErr string
+	}{
+		{name: "secret and active key", secret: secret, active: "k1", keys: []KeyConfig{k1}},
+		{name: "keys staged without active", secret: secret, keys: []KeyConfig{k1}},
+		{name: "keys only", active: "k1", keys: []KeyConfig{k1}},
+		{name: "nothing configured", wantErr: "secret key or at least one versioned key"},
+		{name: "keys only without active", keys: []KeyConfig{k1}, wantErr: "active key is required"},
+		{name: "unknown active", secret: secret, active: "k2", keys: []KeyConfig{k1}, wantErr: `active key "k2" is not configured`},
+		{name: "invalid id", secret: secret, keys: []KeyConfig{{ID: "k_1", Secret: k1.Secret}}, wantErr: "invalid key id"},
+		{name: "duplicate id", secret: secret, keys: []KeyConfig{k1, k1}, wantErr: "duplicate key id"},
+		{name: "short key secret", secret: secret, keys: []KeyConfig{{ID: "k1", Secret: "short"}}, wantErr: `key "k1": secret must be at least 32`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEncoderWithKeys(tt.secret, tt.active, tt.keys, []NamespaceConfig{{Name: "user"}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewEncoderWithKeys() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewEncoderWithKeys() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	secret := "this-is-a-secret-key-that-is-32chars"
+	k1 := KeyConfig{ID: "k1", Secret: "key-one-secret-that-is-32-chars!!"}
+	k2 := KeyConfig{ID: "k2", Secret: "key-two-secret-that-is-32-chars!!"}
+	namespaces := []NamespaceConfig{{Name: "user", Prefix: "usr"}, {Name: "noprefix"}}
+
+	legacy, err := NewEncoder(secret, namespaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := NewEncoderWithKeys(secret, "k1", []KeyConfig{k1}, namespaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotatedAgain, err := NewEncoderWithKeys("", "k2", []KeyConfig{k1, k2}, namespaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldID, _ := legacy.Encode("user", 42)
+	k1ID, err := rotated.Encode("user", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(k1ID, "usr_k1_") || len(k1ID) != len("usr_k1_")+11 {
+		t.Errorf("Encode() = %q, want usr_k1_ and 11 characters", k1ID)
+	}
+	if rotated.ActiveKey() != "k1" || legacy.ActiveKey() != "" {
+		t.Errorf("ActiveKey() = %q, %q", rotated.ActiveKey(), legacy.ActiveKey())
+	}
+	noPrefix, _ := rotated.Encode("noprefix", 42)
+	if !strings.HasPrefix(noPrefix, "k1_") {
+		t.Errorf("Encode() without prefix = %q, want k1_ key id", noPrefix)
+	}
+
+	// IDs handed out before each rotation keep decoding while their key is configured
+	for _, tc := range []struct {
+		enc *Encoder
+		id  string
+	}{
+		{rotated, oldID}, {rotated, k1ID}, {rotated, noPrefix}, {rotatedAgain, k1ID},
+	} {
+		ns := "user"
+		if tc.id == noPrefix {
+			ns = "noprefix"
+		}
+		got, err := tc.enc.Decode(ns, tc.id)
+		if err != nil || got != 42 {
+			t.Errorf("Decode(%q) = %d, %v, want 42", tc.id, got, err)
+		}
+	}
+
+	k2ID, _ := rotatedAgain.Encode("user", 42)
+	if k2ID == k1ID || !strings.HasPrefix(k2ID, "usr_k2_") {
+		t.Errorf("Encode() after second rotation = %q", k2ID)
+	}
+
+	// Without the secret, unversioned IDs no longer decode; unknown keys never do
+	if _, err := rotatedAgain.Decode("user", oldID); err == nil || !strings.Contains(err.Error(), "missing key id") {
+		t.Errorf("Decode(unversioned) error = %v, want missing key id", err)
+	}
+	if _, err := legacy.Decode("user", k1ID); err == nil || !strings.Contains(err.Error(), `unknown key "k1"`) {
+		t.Errorf("Decode(k1 ID) without k1 error = %v, want unknown key", err)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	secret := "this-is-a-secret-key-that-is-32chars"
+	enc, err := NewEncoder(secret, []NamespaceConfig{
+		{Name: "user", Prefix: "usr", Format: FormatSqids},
+		{Name: "short", Format: FormatSqids, MinLength: 1},
+		{Name: "order", Format: FormatUUID},
+		{Name: "invoice", Format: FormatUUID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []int64{0, 1, 2, 42, 61, 62, 1000000, 1<<53 + 1, 9223372036854775807}
+	for _, ns := range []string{"user", "short", "order"} {
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			encoded, err := enc.Encode(ns, id)
+			if err != nil {
+				t.Fatalf("Encode(%s, %d): %v", ns, id, err)
+			}
+			if seen[encoded] {
+				t.Errorf("Encode(%s, %d) = %q repeats an earlier ID", ns, id, encoded)
+			}
+			seen[encoded] = true
+			decoded, err := enc.Decode(ns, encoded)
+			if err != nil || decoded != id {
+				t.Errorf("Decode(%s, %q) = %d, %v, want %d", ns, encoded, decoded, err, id)
+			}
+		}
+	}
+
+	small, _ := enc.Encode("user", 42)
+	if !strings.HasPrefix(small, "usr_") || len(small) != len("usr_")+DefaultSqidsMinLength {
+		t.Errorf("sqids Encode(42) = %q, want usr_ and %d characters", small, DefaultSqidsMinLength)
+	}
+	if short, _ := enc.Encode("short", 42); len(short) > 3 {
+		t.Errorf("sqids Encode(42) with min_length 1 = %q, want at most 3 characters", short)
+	}
+	if _, err := enc.Encode("user", -1); err == nil {
+		t.Error("expected error encoding a negative id as sqids")
+	}
+
+	u, _ := enc.Encode("order", 42)
+	parsed, err := uuid.Parse(u)
+	if err != nil || parsed.Version() != 7 || parsed.Variant() != uuid.RFC4122 {
+		t.Errorf("uuid Encode(42) = %q, want a version 7 UUID (%v)", u, err)
+	}
+	if neg, _ := enc.Encode("order", -5); neg == "" {
+		t.Error("uuid format should encode negative ids")
+	} else if got, err := enc.Decode("order", neg); err != nil || got != -5 {
+		t.Errorf("Decode(uuid of -5) = %d, %v", got, err)
+	}
+	if got, err := enc.Decode("order", strings.ToUpper(u)); err != nil || got != 42 {
+		t.Errorf("Decode(upper-case uuid) = %d, %v, want 42", got, err)
+	}
+
+	altered := []byte(u)
+	if altered[35] == '0' {
+		altered[35] = '1'
+	} else {
+		altered[35] = '0'
+	}
+
+	invalid := []struct {
+		ns, id string
+	}{
+		{"user", "usr_"},
+		{"user", "usr_!!!!!!!!"},
+		{"user", "usr_" + small[len(small)-1:] + small[4:len(small)-1]},
+		{"order", "not-a-uuid"},
+		{"order", "018f3c1e-7b2a-4c3d-8e4f-0123456789ab"}, // version 4
+		{"order", string(altered)},                        // altered MAC
+		{"invoice", u},                                    // another namespace's key
+	}
+	for _, tc := range invalid {
+		if got, err := enc.Decode(tc.ns, tc.id); err == nil {
+			t.Errorf("Decode(%s, %q) = %d, expected error", tc.ns, tc.id, got)
+		}
+	}
+
+	for _, tc := range []struct {
+		ns      NamespaceConfig
+		wantErr string
+	}{
+		{NamespaceConfig{Name: "a", Format: "base64"}, `invalid format "base64"`},
+		{NamespaceConfig{Name: "a", Prefix: "x", Format: FormatUUID}, "cannot have a prefix"},
+		{NamespaceConfig{Name: "a", MinLength: 10}, "min_length only applies"},
+		{NamespaceConfig{Name: "a", Format: FormatSqids, MinLength: 65}, "between 1 and 64"},
+	} {
+		if _, err := NewEncoder(secret, []NamespaceConfig{tc.ns}); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("NewEncoder(%+v) error = %v, want %q", tc.ns, err, tc.wantErr)
+		}
+	}
+}
+
+func TestFormatsKeyRotation(t *testing.T) {
+	secret := "this-is-a-secret-key-that-is-32chars"
+	k1 := KeyConfig{ID: "k1", Secret: "key-one-secret-that-is-32-chars!!"}
+	namespaces := []NamespaceConfig{{Name: "order", Format: FormatUUID}, {Name: "user", Prefix: "usr", Format: FormatSqids}}
+
+	legacy, err := NewEncoder(secret, namespaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := NewEncoderWithKeys(secret, "k1", []KeyConfig{k1}, namespaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ns := range []string{"order", "user"} {
+		oldID, _ := legacy.Encode(ns, 7)
+		newID, _ := rotated.Encode(ns, 7)
+		if oldID == newID {
+			t.Errorf("%s: rotated key produced the same ID %q", ns, newID)
+		}
+		for _, id := range []string{oldID, newID} {
+			if got, err := rotated.Decode(ns, id); err != nil || got != 7 {
+				t.Errorf("%s: Decode(%q) = %d, %v, want 7", ns, id, got, err)
+			}
+		}
+		if _, err := legacy.Decode(ns, newID); err == nil {
+			t.Errorf("%s: Decode(%q) without the new key should fail", ns, newID)
+		}
+	}
+	if id, _ := rotated.Encode("user", 7); !strings.HasPrefix(id, "usr_k1_") {
+		t.Errorf("sqids ID with versioned key = %q, want usr_k1_ prefix", id)
+	}
+}
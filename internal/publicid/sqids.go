@@ -0,0 +1,122 @@
+package publicid
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Sqids (https://sqids.org) encoding of a single number. The alphabet is
+// shuffled with the namespace's secret instead of the fixed Sqids shuffle, so
+// IDs cannot be decoded or predicted without the secret. Unlike xtea IDs they
+// are not encrypted: consecutive ids give unrelated-looking but related IDs.
+// There is no blocklist.
+
+// shuffleAlphabet returns the base62 alphabet in an order derived from the
+// secret and namespace (Fisher-Yates over an HMAC-SHA256 stream)
+func shuffleAlphabet(secret, namespace string) string {
+	a := []byte(Base62Alphabet)
+	var stream []byte
+	for counter := uint32(0); len(stream) < 4*len(a); counter++ {
+		h := hmac.New(sha256.New, []byte(secret))
+		h.Write([]byte("sqids-alphabet:" + namespace))
+		_ = binary.Write(h, binary.BigEndian, counter)
+		stream = h.Sum(stream)
+	}
+	for i := len(a) - 1; i > 0; i-- {
+		j := int(binary.BigEndian.Uint32(stream[4*i:]) % uint32(i+1))
+		a[i], a[j] = a[j], a[i]
+	}
+	return string(a)
+}
+
+// sqidsShuffle is the Sqids alphabet shuffle, used between padding chunks
+func sqidsShuffle(a []byte) {
+	for i, j := 0, len(a)-1; j > 0; i, j = i+1, j-1 {
+		r := (i*j + int(a[i]) + int(a[j])) % len(a)
+		a[i], a[r] = a[r], a[i]
+	}
+}
+
+// encodeSqids encodes n as a Sqids ID of at least minLength characters
+func encodeSqids(n uint64, alphabet string, minLength int) string {
+	// Rotate the alphabet by an offset that depends on n; its first character
+	// becomes the ID's prefix so decoding can undo the rotation
+	offset := (1 + int(alphabet[n%uint64(len(alphabet))])) % len(alphabet)
+	a := []byte(alphabet[offset:] + alphabet[:offset])
+	prefix := a[0]
+	for i, j := 0, len(a)-1; i < j; i, j = i+1, j-1 {
+		a[i], a[j] = a[j], a[i]
+	}
+
+	id := []byte{prefix}
+	id = append(id, sqidsToID(n, a[1:])...)
+	if len(id) < minLength {
+		// a[0] separates the number from the padding
+		id = append(id, a[0])
+		for len(id) < minLength {
+			sqidsShuffle(a)
+			id = append(id, a[:min(minLength-len(id), len(a))]...)
+		}
+	}
+	return string(id)
+}
+
+// decodeSqids decodes an ID made by encodeSqids. IDs that decode but would
+// not be produced by encodeSqids are rejected, so each id has one public ID.
+func decodeSqids(id, alphabet string, minLength int) (uint64, error) {
+	if id == "" {
+		return 0, fmt.Errorf("empty ID")
+	}
+	offset := strings.IndexByte(alphabet, id[0])
+	if offset < 0 {
+		return 0, fmt.Errorf("invalid character: %c", id[0])
+	}
+	a := []byte(alphabet[offset:] + alphabet[:offset])
+	for i, j := 0, len(a)-1; i < j; i, j = i+1, j-1 {
+		a[i], a[j] = a[j], a[i]
+	}
+
+	chunk, _, _ := strings.Cut(id[1:], string(a[0]))
+	if chunk == "" {
+		return 0, fmt.Errorf("missing number")
+	}
+	n, err := sqidsToNumber(chunk, string(a[1:]))
+	if err != nil {
+		return 0, err
+	}
+	if encodeSqids(n, alphabet, minLength) != id {
+		return 0, fmt.Errorf("not a canonical ID")
+	}
+	return n, nil
+}
+
+func sqidsToID(n uint64, a []byte) []byte {
+	var id []byte
+	base := uint64(len(a))
+	for {
+		id = append([]byte{a[n%base]}, id...)
+		n /= base
+		if n == 0 {
+			return id
+		}
+	}
+}
+
+func sqidsToNumber(s, a string) (uint64, error) {
+	base := uint64(len(a))
+	var n uint64
+	for i := 0; i < len(s); i++ {
+		idx := strings.IndexByte(a, s[i])
+		if idx < 0 {
+			return 0, fmt.Errorf("invalid character: %c", s[i])
+		}
+		if n > (^uint64(0)-uint64(idx))/base {
+			return 0, fmt.Errorf("overflow during decode")
+		}
+		n = n*base + uint64(idx)
+	}
+	return n, nil
+}
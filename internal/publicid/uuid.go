@@ -0,0 +1,84 @@
+package publicid
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// UUID public IDs are version 7, variant RFC 4122 UUIDs whose remaining 122
+// bits hold the 64-bit XTEA ciphertext followed by a 58-bit MAC of it:
+//
+//	unix_ts_ms (48) = ciphertext[63:16]
+//	rand_a     (12) = ciphertext[15:4]
+//	rand_b     (62) = ciphertext[3:0] + MAC
+//
+// The timestamp field is therefore not a creation time. The MAC rejects
+// guessed or altered IDs and identifies the key an ID was made with, since a
+// UUID has no room for a key id.
+
+const uuidMACBits = 58
+
+// encodeUUID encodes id as a UUIDv7-shaped public ID
+func encodeUUID(id int64, key *keyMaterial) string {
+	c := xteaEncrypt(uint64(id), key.xtea)
+	hi := (c>>16)<<16 | 0x7<<12 | (c>>4)&0xfff
+	lo := uint64(0b10)<<62 | (c&0xf)<<uuidMACBits | uuidMAC(c, key)
+
+	var u uuid.UUID
+	binary.BigEndian.PutUint64(u[:8], hi)
+	binary.BigEndian.PutUint64(u[8:], lo)
+	return u.String()
+}
+
+// decodeUUID decodes a UUID public ID made with the unversioned key or any
+// versioned key
+func decodeUUID(publicID string, key *keyMaterial, keys map[string]*keyMaterial) (int64, error) {
+	if len(publicID) != 36 {
+		return 0, fmt.Errorf("invalid public ID format: expected a UUID")
+	}
+	u, err := uuid.Parse(publicID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid public ID format: %w", err)
+	}
+	if u.Version() != 7 || u.Variant() != uuid.RFC4122 {
+		return 0, fmt.Errorf("invalid public ID format: not a version 7 UUID")
+	}
+
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+	c := (hi>>16)<<16 | (hi&0xfff)<<4 | (lo>>uuidMACBits)&0xf
+	mac := lo & (1<<uuidMACBits - 1)
+
+	candidates := make([]*keyMaterial, 0, len(keys)+1)
+	if key != nil {
+		candidates = append(candidates, key)
+	}
+	for _, k := range keys {
+		candidates = append(candidates, k)
+	}
+	for _, k := range candidates {
+		if hmac.Equal(macBytes(uuidMAC(c, k)), macBytes(mac)) {
+			return int64(xteaDecrypt(c, k.xtea)), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid public ID: not issued with a configured key")
+}
+
+// uuidMAC returns the 58-bit MAC of a ciphertext
+func uuidMAC(c uint64, key *keyMaterial) uint64 {
+	h := hmac.New(sha256.New, key.mac)
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], c)
+	h.Write(buf[:])
+	return binary.BigEndian.Uint64(h.Sum(nil)) >> (64 - uuidMACBits)
+}
+
+func macBytes(mac uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], mac)
+	return buf[:]
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Rate limiting algorithms for named pools (rate_limits[].algorithm)
+const (
+	// AlgorithmTokenBucket refills requests_per_second tokens up to burst; allows bursts (default)
+	AlgorithmTokenBucket = "token_bucket"
+	// AlgorithmSlidingWindow allows requests_per_second requests in any rolling one-second window
+	AlgorithmSlidingWindow = "sliding_window"
+	// AlgorithmLeakyBucket queues up to burst requests and releases them evenly at requests_per_second
+	AlgorithmLeakyBucket = "leaky_bucket"
+	// AlgorithmFixedWindow is reported for quota pools (window + limit)
+	AlgorithmFixedWindow = "fixed_window"
+)
+
+// ValidAlgorithms lists the values accepted for a pool's algorithm option
+var ValidAlgorithms = []string{AlgorithmTokenBucket, AlgorithmSlidingWindow, AlgorithmLeakyBucket}
+
+// slidingWindowSize is the rolling window over which sliding_window pools count requests
+const slidingWindowSize = time.Second
+
+// slidingWindow approximates a rolling window from the current and previous fixed windows,
+// weighting the previous count by how much of it still overlaps the rolling window
+type slidingWindow struct {
+	mu       sync.Mutex
+	start    time.Time // Start of the current fixed window
+	current  int
+	previous int
+}
+
+// take counts a request if fewer than limit requests fall in the rolling window ending at now
+func (w *slidingWindow) take(limit int, now time.Time) Decision {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.advance(now)
+	elapsed := now.Sub(w.start)
+	weight := 1 - float64(elapsed)/float64(slidingWindowSize)
+	used := float64(w.previous)*weight + float64(w.current)
+
+	d := Decision{Limit: limit, Reset: ceilSecond(slidingWindowSize - elapsed)}
+	if used+1 <= float64(limit) {
+		w.current++
+		d.Allowed = true
+		used++
+	} else {
+		d.RetryAfter = ceilSecond(w.wait(limit, elapsed))
+	}
+	if w.previous > 0 {
+		// The previous window still counts until it has fully slid out
+		d.Reset = ceilSecond(2*slidingWindowSize - elapsed)
+	}
+	if remaining := float64(limit) - used; remaining > 0 {
+		d.Remaining = int(remaining)
+	}
+	return d
+}
+
+// advance rolls the fixed windows forward to the one containing now
+func (w *slidingWindow) advance(now time.Time) {
+	start := now.Truncate(slidingWindowSize)
+	switch {
+	case start.Equal(w.start):
+	case start.Sub(w.start) == slidingWindowSize:
+		w.previous, w.current = w.current, 0
+		w.start = start
+	default:
+		w.previous, w.current = 0, 0
+		w.start = start
+	}
+}
+
+// wait returns how long until one more request fits under limit
+func (w *slidingWindow) wait(limit int, elapsed time.Duration) time.Duration {
+	free := float64(limit - 1 - w.current)
+	if free >= 0 && w.previous > 0 {
+		// Wait for enough of the previous window to slide out
+		need := 1 - free/float64(w.previous)
+		return time.Duration(need*float64(slidingWindowSize)) - elapsed
+	}
+	// The current window alone is full; wait for it to become the previous window and decay
+	next := 1 - float64(limit-1)/float64(w.current)
+	return slidingWindowSize - elapsed + time.Duration(next*float64(slidingWindowSize))
+}
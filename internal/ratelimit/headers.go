@@ -0,0 +1,34 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Rate limit header styles (server.rate_limit_headers)
+const (
+	// HeaderStyleX emits X-RateLimit-Limit/Remaining/Reset, with Reset as a Unix timestamp
+	HeaderStyleX = "x"
+	// HeaderStyleIETF emits RateLimit-Limit/Remaining/Reset per the IETF httpapi draft, with Reset in seconds
+	HeaderStyleIETF = "ietf"
+)
+
+// SetHeaders writes the quota headers for a decision in the given style.
+// Retry-After is not included; callers set it on denied responses.
+func SetHeaders(h http.Header, style string, d Decision, now time.Time) {
+	if d.Limit <= 0 {
+		return
+	}
+
+	prefix := "X-RateLimit-"
+	reset := strconv.FormatInt(now.Add(d.Reset).Unix(), 10)
+	if style == HeaderStyleIETF {
+		prefix = "RateLimit-"
+		reset = strconv.Itoa(int(d.Reset / time.Second))
+	}
+
+	h.Set(prefix+"Limit", strconv.Itoa(d.Limit))
+	h.Set(prefix+"Remaining", strconv.Itoa(d.Remaining))
+	h.Set(prefix+"Reset", reset)
+}
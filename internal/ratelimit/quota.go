@@ -0,0 +1,250 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// WindowMonth is the calendar-month quota window (UTC), since months vary in length
+const WindowMonth = "month"
+
+// Window is a fixed quota window. Duration windows are aligned to the Unix epoch,
+// so "24h" resets at midnight UTC; the calendar month window resets on the 1st.
+type Window struct {
+	every   time.Duration
+	monthly bool
+}
+
+// ParseWindow parses a quota window: a Go duration of at least one second ("1h", "24h") or "month"
+func ParseWindow(s string) (Window, error) {
+	if strings.EqualFold(s, WindowMonth) {
+		return Window{monthly: true}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: must be a duration (e.g. 24h) or %q", s, WindowMonth)
+	}
+	if d < time.Second || d%time.Second != 0 {
+		return Window{}, fmt.Errorf("invalid window %q: must be a whole number of seconds", s)
+	}
+	return Window{every: d}, nil
+}
+
+// Bounds returns the start and end of the window containing now
+func (w Window) Bounds(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	if w.monthly {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := now.Truncate(w.every)
+	return start, start.Add(w.every)
+}
+
+// quota is the fixed-window policy of a quota pool
+type quota struct {
+	window Window
+	spec   string // Window as configured, for display
+	limit  int
+	store  quotaStore
+}
+
+// take counts one request for key in the current window, denying it once the limit is used up
+func (q *quota) take(pool, key string, now time.Time) (Decision, error) {
+	start, end := q.window.Bounds(now)
+	used, allowed, err := q.store.take(pool, key, start.Unix(), q.limit)
+	if err != nil {
+		return Decision{}, fmt.Errorf("quota store: %w", err)
+	}
+
+	d := Decision{
+		Allowed:   allowed,
+		Pool:      pool,
+		Limit:     q.limit,
+		Remaining: max(q.limit-used, 0),
+		Reset:     ceilSecond(end.Sub(now)),
+	}
+	if !allowed {
+		d.RetryAfter = d.Reset
+	}
+	return d, nil
+}
+
+// quotaStore keeps per-key request counters for the current window of each quota pool
+type quotaStore interface {
+	// take increments the counter for (pool, key) unless it has reached limit.
+	// A counter from an earlier window starts over. Returns the count after this request.
+	take(pool, key string, windowStart int64, limit int) (used int, allowed bool, err error)
+	// reset deletes counters for a pool, or a single key when key is non-empty
+	reset(pool, key string) (int, error)
+	// purge deletes a pool's counters from windows before windowStart
+	purge(pool string, windowStart int64) error
+	// count returns the number of keys with a counter in the pool
+	count(pool string) (int, error)
+	close() error
+}
+
+// quotaCounter is a per-key counter in the in-memory store
+type quotaCounter struct {
+	windowStart int64
+	used        int
+}
+
+// memoryQuotaStore keeps counters in memory; they are lost on restart
+type memoryQuotaStore struct {
+	mu       sync.Mutex
+	counters map[string]map[string]*quotaCounter // pool -> key -> counter
+}
+
+func newMemoryQuotaStore() *memoryQuotaStore {
+	return &memoryQuotaStore{counters: make(map[string]map[string]*quotaCounter)}
+}
+
+func (s *memoryQuotaStore) take(pool, key string, windowStart int64, limit int) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.counters[pool]
+	if keys == nil {
+		keys = make(map[string]*quotaCounter)
+		s.counters[pool] = keys
+	}
+	c := keys[key]
+	if c == nil || c.windowStart != windowStart {
+		c = &quotaCounter{windowStart: windowStart}
+		keys[key] = c
+	}
+	if c.used >= limit {
+		return c.used, false, nil
+	}
+	c.used++
+	return c.used, true, nil
+}
+
+func (s *memoryQuotaStore) reset(pool, key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.counters[pool]
+	if key == "" {
+		delete(s.counters, pool)
+		return len(keys), nil
+	}
+	if _, ok := keys[key]; !ok {
+		return 0, nil
+	}
+	delete(keys, key)
+	return 1, nil
+}
+
+func (s *memoryQuotaStore) purge(pool string, windowStart int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, c := range s.counters[pool] {
+		if c.windowStart < windowStart {
+			delete(s.counters[pool], key)
+		}
+	}
+	return nil
+}
+
+func (s *memoryQuotaStore) count(pool string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.counters[pool]), nil
+}
+
+func (s *memoryQuotaStore) close() error {
+	return nil
+}
+
+// sqliteQuotaStore persists counters in a local SQLite file so quotas survive restarts
+type sqliteQuotaStore struct {
+	conn *sql.DB
+}
+
+func openSQLiteQuotaStore(path string) (*sqliteQuotaStore, error) {
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quota store: %w", err)
+	}
+	// A single connection serializes counter updates and keeps the pragmas in effect
+	conn.SetMaxOpenConns(1)
+
+	stmts := []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA busy_timeout = 5000",
+		`CREATE TABLE IF NOT EXISTS quota_counters (
+			pool TEXT NOT NULL,
+			key TEXT NOT NULL,
+			window_start INTEGER NOT NULL,
+			used INTEGER NOT NULL,
+			PRIMARY KEY (pool, key)
+		)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := conn.Exec(stmt); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to initialize quota store %s: %w", path, err)
+		}
+	}
+	return &sqliteQuotaStore{conn: conn}, nil
+}
+
+func (s *sqliteQuotaStore) take(pool, key string, windowStart int64, limit int) (int, bool, error) {
+	// The upsert only touches the row when the window rolled over or there is quota left,
+	// so no row comes back for a request over the limit
+	var used int
+	err := s.conn.QueryRow(`
+		INSERT INTO quota_counters (pool, key, window_start, used) VALUES (?, ?, ?, 1)
+		ON CONFLICT (pool, key) DO UPDATE SET
+			used = CASE WHEN window_start = excluded.window_start THEN used + 1 ELSE 1 END,
+			window_start = excluded.window_start
+		WHERE window_start != excluded.window_start OR used < ?
+		RETURNING used`,
+		pool, key, windowStart, limit).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return limit, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return used, true, nil
+}
+
+func (s *sqliteQuotaStore) reset(pool, key string) (int, error) {
+	var res sql.Result
+	var err error
+	if key == "" {
+		res, err = s.conn.Exec("DELETE FROM quota_counters WHERE pool = ?", pool)
+	} else {
+		res, err = s.conn.Exec("DELETE FROM quota_counters WHERE pool = ? AND key = ?", pool, key)
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *sqliteQuotaStore) purge(pool string, windowStart int64) error {
+	_, err := s.conn.Exec("DELETE FROM quota_counters WHERE pool = ? AND window_start < ?", pool, windowStart)
+	return err
+}
+
+func (s *sqliteQuotaStore) count(pool string) (int, error) {
+	var n int
+	err := s.conn.QueryRow("SELECT COUNT(*) FROM quota_counters WHERE pool = ?", pool).Scan(&n)
+	return n, err
+}
+
+func (s *sqliteQuotaStore) close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,201 @@
+package ratelimit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/tmpl"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		window  string
+		wantErr bool
+	}{
+		{"24h", false},
+		{"1h", false},
+		{"month", false},
+		{"Month", false},
+		{"", true},
+		{"daily", true},
+		{"500ms", true},
+		{"1.5s", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			_, err := ParseWindow(tt.window)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseWindow(%q) error = %v, wantErr %v", tt.window, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWindow_Bounds(t *testing.T) {
+	now := time.Date(2024, 2, 15, 13, 45, 0, 0, time.UTC)
+
+	day, _ := ParseWindow("24h")
+	start, end := day.Bounds(now)
+	if !start.Equal(time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 2, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("daily bounds = %v - %v", start, end)
+	}
+
+	month, _ := ParseWindow("month")
+	start, end = month.Bounds(now)
+	if !start.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("monthly bounds = %v - %v", start, end)
+	}
+}
+
+func TestQuotaPool(t *testing.T) {
+	engine := tmpl.New()
+	pools := []config.RateLimitPoolConfig{
+		{Name: "daily", Window: "24h", Limit: 3, Key: "{{.trigger.client_ip}}"},
+	}
+	l, err := New(pools, engine)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer func() { _ = l.Close() }()
+
+	ctx := &tmpl.Context{Trigger: &tmpl.TriggerContext{ClientIP: "10.0.0.1"}}
+	other := &tmpl.Context{Trigger: &tmpl.TriggerContext{ClientIP: "10.0.0.2"}}
+	limits := []config.RateLimitConfig{{Pool: "daily"}}
+
+	for i := 0; i < 3; i++ {
+		d, err := l.Check(limits, ctx)
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+		if !d.Allowed || d.Limit != 3 || d.Remaining != 2-i {
+			t.Errorf("request %d: unexpected decision %+v", i, d)
+		}
+	}
+
+	d, err := l.Check(limits, ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Allowed || d.Remaining != 0 {
+		t.Errorf("expected quota exhausted, got %+v", d)
+	}
+	if d.RetryAfter <= 0 || d.RetryAfter > 24*time.Hour || d.RetryAfter != d.Reset {
+		t.Errorf("expected retry after the window ends, got retry=%v reset=%v", d.RetryAfter, d.Reset)
+	}
+
+	// Keys are counted separately
+	if allowed, _, _, _ := l.Allow(limits, other); !allowed {
+		t.Error("expected other key to be allowed")
+	}
+
+	pool := l.GetPool("daily")
+	if pool.Window() != "24h" || pool.Limit() != 3 || pool.Persistent() {
+		t.Errorf("unexpected pool info: window=%q limit=%d persistent=%v", pool.Window(), pool.Limit(), pool.Persistent())
+	}
+	if got := l.Snapshot().Pools["daily"].ActiveBuckets; got != 2 {
+		t.Errorf("expected 2 active keys, got %d", got)
+	}
+
+	// Resetting the key restores its quota
+	if cleared, _ := l.ResetKey("daily", "10.0.0.1"); !cleared {
+		t.Error("expected key to be cleared")
+	}
+	if allowed, _, _, _ := l.Allow(limits, ctx); !allowed {
+		t.Error("expected request to be allowed after reset")
+	}
+}
+
+func TestQuotaPool_Persistent(t *testing.T) {
+	engine := tmpl.New()
+	store := filepath.Join(t.TempDir(), "quotas.db")
+	pools := []config.RateLimitPoolConfig{
+		{Name: "partner", Window: "month", Limit: 2, Key: "{{.trigger.client_ip}}", Store: store},
+		{Name: "daily", Window: "24h", Limit: 5, Key: "{{.trigger.client_ip}}", Store: store},
+	}
+	ctx := &tmpl.Context{Trigger: &tmpl.TriggerContext{ClientIP: "10.0.0.1"}}
+	limits := []config.RateLimitConfig{{Pool: "partner"}}
+
+	l, err := New(pools, engine)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	if len(l.stores) != 1 {
+		t.Errorf("expected pools to share one store, got %d", len(l.stores))
+	}
+	if !l.GetPool("partner").Persistent() {
+		t.Error("expected persistent pool")
+	}
+	for i := 0; i < 2; i++ {
+		if allowed, _, _, err := l.Allow(limits, ctx); err != nil || !allowed {
+			t.Fatalf("request %d: allowed=%v err=%v", i, allowed, err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	// Counters survive a restart
+	l, err = New(pools, engine)
+	if err != nil {
+		t.Fatalf("failed to reopen limiter: %v", err)
+	}
+	defer func() { _ = l.Close() }()
+
+	d, err := l.Check(limits, ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Allowed {
+		t.Error("expected quota to still be exhausted after restart")
+	}
+
+	// Other pools in the same store are unaffected
+	d, err = l.Check([]config.RateLimitConfig{{Pool: "daily"}}, ctx)
+	if err != nil || !d.Allowed || d.Remaining != 4 {
+		t.Errorf("unexpected daily decision %+v, err=%v", d, err)
+	}
+
+	if n := l.GetPool("partner").Reset(); n != 1 {
+		t.Errorf("expected 1 counter reset, got %d", n)
+	}
+	if allowed, _, _, _ := l.Allow(limits, ctx); !allowed {
+		t.Error("expected request to be allowed after pool reset")
+	}
+}
+
+func TestQuotaStore_WindowRollover(t *testing.T) {
+	stores := map[string]quotaStore{"memory": newMemoryQuotaStore()}
+	sqliteStore, err := openSQLiteQuotaStore(filepath.Join(t.TempDir(), "quotas.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite store: %v", err)
+	}
+	stores["sqlite"] = sqliteStore
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			defer func() { _ = store.close() }()
+
+			if _, allowed, _ := store.take("p", "k", 100, 1); !allowed {
+				t.Fatal("expected first request allowed")
+			}
+			if _, allowed, _ := store.take("p", "k", 100, 1); allowed {
+				t.Fatal("expected second request in the same window denied")
+			}
+			used, allowed, err := store.take("p", "k", 200, 1)
+			if err != nil || !allowed || used != 1 {
+				t.Fatalf("expected counter to restart in new window, got used=%d allowed=%v err=%v", used, allowed, err)
+			}
+
+			_, _, _ = store.take("p", "stale", 100, 1)
+			if err := store.purge("p", 200); err != nil {
+				t.Fatalf("purge failed: %v", err)
+			}
+			if n, _ := store.count("p"); n != 1 {
+				t.Errorf("expected 1 counter after purge, got %d", n)
+			}
+		})
+	}
+}
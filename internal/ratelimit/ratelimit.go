@@ -3,6 +3,7 @@ package ratelimit
 
 import (
 	"fmt"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,6 +20,7 @@ type Limiter struct {
 	inlinePools map[string]*Pool // Keyed by config hash for inline rate limits
 	engine      *tmpl.Engine
 	metrics     *Metrics
+	stores      map[string]quotaStore // Quota stores keyed by file path ("" = in memory)
 	mu          sync.RWMutex
 }
 
@@ -28,6 +30,8 @@ type Pool struct {
 	requestsPerSecond int
 	burst             int
 	keyTemplate       string
+	algorithm         string // One of ValidAlgorithms (bucket-based pools)
+	quota             *quota // Set for fixed-window quota pools, which don't use buckets
 
 	buckets    map[string]*bucket
 	bucketsMu  sync.RWMutex
@@ -35,10 +39,11 @@ type Pool struct {
 	cleanEvery time.Duration
 }
 
-// bucket wraps a rate.Limiter with last-used tracking for cleanup
+// bucket holds the per-key state of a pool's algorithm with last-used tracking for cleanup
 type bucket struct {
-	limiter  *rate.Limiter
-	lastUsed atomic.Int64 // Unix timestamp
+	limiter  *rate.Limiter  // token_bucket and leaky_bucket
+	window   *slidingWindow // sliding_window
+	lastUsed atomic.Int64   // Unix timestamp
 }
 
 // Metrics tracks rate limiting statistics
@@ -73,42 +78,122 @@ func New(pools []config.RateLimitPoolConfig, engine *tmpl.Engine) (*Limiter, err
 		metrics: &Metrics{
 			Pools: make(map[string]*PoolMetrics),
 		},
+		stores: make(map[string]quotaStore),
 	}
 
 	for _, cfg := range pools {
-		if cfg.Name == "" {
-			return nil, fmt.Errorf("rate limit pool missing name")
+		if err := l.addPool(cfg); err != nil {
+			_ = l.Close()
+			return nil, err
 		}
-		if _, exists := l.pools[cfg.Name]; exists {
-			return nil, fmt.Errorf("duplicate rate limit pool name: %s", cfg.Name)
+	}
+
+	return l, nil
+}
+
+// addPool validates a named pool configuration and registers it
+func (l *Limiter) addPool(cfg config.RateLimitPoolConfig) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("rate limit pool missing name")
+	}
+	if _, exists := l.pools[cfg.Name]; exists {
+		return fmt.Errorf("duplicate rate limit pool name: %s", cfg.Name)
+	}
+	if cfg.Key == "" {
+		return fmt.Errorf("pool %q: key template required", cfg.Name)
+	}
+
+	// Validate the key template
+	if err := l.engine.Validate(cfg.Key, tmpl.UsagePreQuery); err != nil {
+		return fmt.Errorf("pool %q: invalid key template: %w", cfg.Name, err)
+	}
+
+	pool := &Pool{
+		name:        cfg.Name,
+		keyTemplate: cfg.Key,
+		buckets:     make(map[string]*bucket),
+		cleanEvery:  5 * time.Minute,
+	}
+	if cfg.IsQuota() {
+		q, err := l.newQuota(cfg)
+		if err != nil {
+			return fmt.Errorf("pool %q: %w", cfg.Name, err)
 		}
-		if cfg.RequestsPerSecond <= 0 {
-			return nil, fmt.Errorf("pool %q: requests_per_second must be positive", cfg.Name)
+		pool.quota = q
+	} else {
+		pool.algorithm = cfg.Algorithm
+		if pool.algorithm == "" {
+			pool.algorithm = AlgorithmTokenBucket
+		}
+		if !slices.Contains(ValidAlgorithms, pool.algorithm) {
+			return fmt.Errorf("pool %q: unknown algorithm %q", cfg.Name, cfg.Algorithm)
 		}
-		if cfg.Burst <= 0 {
-			return nil, fmt.Errorf("pool %q: burst must be positive", cfg.Name)
+		if cfg.RequestsPerSecond <= 0 {
+			return fmt.Errorf("pool %q: requests_per_second must be positive", cfg.Name)
 		}
-		if cfg.Key == "" {
-			return nil, fmt.Errorf("pool %q: key template required", cfg.Name)
+		// Sliding windows cap requests per second directly and have no burst
+		if cfg.Burst <= 0 && pool.algorithm != AlgorithmSlidingWindow {
+			return fmt.Errorf("pool %q: burst must be positive", cfg.Name)
 		}
+		pool.requestsPerSecond = cfg.RequestsPerSecond
+		pool.burst = cfg.Burst
+	}
 
-		// Validate the key template
-		if err := engine.Validate(cfg.Key, tmpl.UsagePreQuery); err != nil {
-			return nil, fmt.Errorf("pool %q: invalid key template: %w", cfg.Name, err)
+	l.pools[cfg.Name] = pool
+	l.metrics.Pools[cfg.Name] = &PoolMetrics{}
+	return nil
+}
+
+// newQuota builds the quota policy for a pool, sharing one store per file
+func (l *Limiter) newQuota(cfg config.RateLimitPoolConfig) (*quota, error) {
+	window, err := ParseWindow(cfg.Window)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+	if cfg.Algorithm != "" {
+		return nil, fmt.Errorf("algorithm cannot be combined with window")
+	}
+
+	store := l.stores[cfg.Store]
+	if store == nil {
+		if cfg.Store == "" {
+			store = newMemoryQuotaStore()
+		} else if store, err = openSQLiteQuotaStore(cfg.Store); err != nil {
+			return nil, err
 		}
+		l.stores[cfg.Store] = store
+	}
 
-		l.pools[cfg.Name] = &Pool{
-			name:              cfg.Name,
-			requestsPerSecond: cfg.RequestsPerSecond,
-			burst:             cfg.Burst,
-			keyTemplate:       cfg.Key,
-			buckets:           make(map[string]*bucket),
-			cleanEvery:        5 * time.Minute,
+	return &quota{window: window, spec: cfg.Window, limit: cfg.Limit, store: store}, nil
+}
+
+// Close releases quota stores. The limiter must not be used afterwards.
+func (l *Limiter) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var firstErr error
+	for path, store := range l.stores {
+		if err := store.close(); err != nil && firstErr == nil {
+			firstErr = err
 		}
-		l.metrics.Pools[cfg.Name] = &PoolMetrics{}
+		delete(l.stores, path)
 	}
+	return firstErr
+}
 
-	return l, nil
+// Decision is the outcome of a rate limit check, with the quota details reported in response headers
+type Decision struct {
+	Allowed    bool
+	Pool       string        // Pool that denied the request, or the most constrained pool when allowed
+	Limit      int           // Bucket capacity (burst)
+	Remaining  int           // Requests left in the bucket after this one
+	Reset      time.Duration // Time until the bucket is full again
+	RetryAfter time.Duration // How long to wait before retrying (only set when denied)
+	Delay      time.Duration // How long to hold an allowed request before serving it (leaky_bucket)
 }
 
 // Allow checks if a request should be allowed based on the configured rate limits.
@@ -117,22 +202,39 @@ func New(pools []config.RateLimitPoolConfig, engine *tmpl.Engine) (*Limiter, err
 // denyingPool is the name of the pool that denied the request (empty when allowed).
 // An error indicates a template evaluation failure (config bug, should not happen at runtime).
 func (l *Limiter) Allow(limits []config.RateLimitConfig, ctx *tmpl.Context) (bool, time.Duration, string, error) {
-	if len(limits) == 0 {
-		return true, 0, "", nil
+	d, err := l.Check(limits, ctx)
+	if err != nil {
+		return false, 0, "", err
+	}
+	if !d.Allowed {
+		return false, d.RetryAfter, d.Pool, nil
 	}
+	return true, 0, "", nil
+}
+
+// Check is like Allow but returns the full decision for building rate limit headers.
+// When several limits apply and all pass, the decision reports the one with the fewest remaining requests.
+func (l *Limiter) Check(limits []config.RateLimitConfig, ctx *tmpl.Context) (Decision, error) {
+	result := Decision{Allowed: true}
+	var delay time.Duration
 
 	// All limits must pass
 	for _, limit := range limits {
-		allowed, retryAfter, poolName, err := l.allowOne(limit, ctx)
+		d, err := l.allowOne(limit, ctx)
 		if err != nil {
-			return false, 0, "", err
+			return Decision{}, err
 		}
-		if !allowed {
-			return false, retryAfter, poolName, nil
+		if !d.Allowed {
+			return d, nil
+		}
+		delay = max(delay, d.Delay)
+		if d.Limit > 0 && (result.Limit == 0 || d.Remaining < result.Remaining) {
+			result = d
 		}
 	}
 
-	return true, 0, "", nil
+	result.Delay = delay
+	return result, nil
 }
 
 // inlinePoolKey generates a unique key for an inline rate limit config
@@ -145,8 +247,7 @@ func inlinePoolKey(limit config.RateLimitConfig) string {
 }
 
 // allowOne checks a single rate limit configuration.
-// Returns (allowed, retryAfter, poolName, error) where retryAfter is set when denied.
-func (l *Limiter) allowOne(limit config.RateLimitConfig, ctx *tmpl.Context) (bool, time.Duration, string, error) {
+func (l *Limiter) allowOne(limit config.RateLimitConfig, ctx *tmpl.Context) (Decision, error) {
 	var pool *Pool
 	var keyTemplate string
 
@@ -157,7 +258,7 @@ func (l *Limiter) allowOne(limit config.RateLimitConfig, ctx *tmpl.Context) (boo
 		l.mu.RUnlock()
 
 		if pool == nil {
-			return false, 0, "", fmt.Errorf("rate limit pool %q not found", limit.Pool)
+			return Decision{}, fmt.Errorf("rate limit pool %q not found", limit.Pool)
 		}
 		keyTemplate = pool.keyTemplate
 	} else if limit.IsInline() {
@@ -183,6 +284,7 @@ func (l *Limiter) allowOne(limit config.RateLimitConfig, ctx *tmpl.Context) (boo
 					requestsPerSecond: limit.RequestsPerSecond,
 					burst:             limit.Burst,
 					keyTemplate:       keyTemplate,
+					algorithm:         AlgorithmTokenBucket,
 					buckets:           make(map[string]*bucket),
 					cleanEvery:        5 * time.Minute,
 				}
@@ -194,40 +296,27 @@ func (l *Limiter) allowOne(limit config.RateLimitConfig, ctx *tmpl.Context) (boo
 		}
 	} else {
 		// Empty config - no rate limiting
-		return true, 0, "", nil
+		return Decision{Allowed: true}, nil
 	}
 
 	// Evaluate key template
 	key, err := l.engine.ExecuteInline(keyTemplate, ctx, tmpl.UsagePreQuery)
 	if err != nil {
-		return false, 0, "", fmt.Errorf("failed to evaluate rate limit key: %w", err)
+		return Decision{}, fmt.Errorf("failed to evaluate rate limit key: %w", err)
 	}
 
-	// Get or create bucket
-	b := pool.getOrCreateBucket(key)
-	b.lastUsed.Store(time.Now().Unix())
-
-	// Use Reserve() to get the delay information
-	reservation := b.limiter.Reserve()
-	delay := reservation.Delay()
-
-	var allowed bool
-	var retryAfter time.Duration
-
-	if delay == 0 {
-		// Token available immediately
-		allowed = true
+	var d Decision
+	if pool.quota != nil {
+		if d, err = pool.quota.take(pool.name, key, time.Now()); err != nil {
+			return Decision{}, err
+		}
 	} else {
-		// Would need to wait - deny the request and cancel the reservation
-		reservation.Cancel()
-		allowed = false
-		// Round up to next second for Retry-After header (HTTP spec uses seconds)
-		retryAfter = delay.Truncate(time.Second) + time.Second
+		d = pool.take(key)
 	}
 
 	// Update metrics
 	l.mu.Lock()
-	if allowed {
+	if d.Allowed {
 		l.metrics.TotalAllowed++
 		if poolMetrics, ok := l.metrics.Pools[pool.name]; ok {
 			poolMetrics.Allowed++
@@ -243,7 +332,88 @@ func (l *Limiter) allowOne(limit config.RateLimitConfig, ctx *tmpl.Context) (boo
 	// Periodic cleanup
 	pool.maybeCleanup()
 
-	return allowed, retryAfter, pool.name, nil
+	return d, nil
+}
+
+// take applies the pool's algorithm to the key's bucket
+func (p *Pool) take(key string) Decision {
+	// Get or create bucket
+	b := p.getOrCreateBucket(key)
+	b.lastUsed.Store(time.Now().Unix())
+
+	now := time.Now()
+	var d Decision
+	switch p.algorithm {
+	case AlgorithmSlidingWindow:
+		d = b.window.take(p.requestsPerSecond, now)
+	case AlgorithmLeakyBucket:
+		d = p.takeLeaky(b, now)
+	default:
+		d = p.takeToken(b, now)
+	}
+	d.Pool = p.name
+	return d
+}
+
+// takeToken takes a token from the bucket if one is available
+func (p *Pool) takeToken(b *bucket, now time.Time) Decision {
+	// Use Reserve() to get the delay information
+	reservation := b.limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+
+	d := Decision{Limit: p.burst}
+
+	if delay == 0 {
+		// Token available immediately
+		d.Allowed = true
+	} else {
+		// Would need to wait - deny the request and cancel the reservation
+		reservation.CancelAt(now)
+		// Round up to whole seconds for Retry-After header (HTTP spec uses seconds)
+		d.RetryAfter = ceilSecond(delay)
+	}
+
+	tokens := b.limiter.TokensAt(now)
+	if tokens > 0 {
+		d.Remaining = int(tokens)
+	}
+	if missing := float64(p.burst) - tokens; missing > 0 {
+		d.Reset = ceilSecond(time.Duration(missing / float64(p.requestsPerSecond) * float64(time.Second)))
+	}
+
+	return d
+}
+
+// takeLeaky schedules the request on the bucket's single-token limiter, so requests leave evenly
+// spaced. Up to burst requests may wait for their slot; the request is denied when the queue is full.
+func (p *Pool) takeLeaky(b *bucket, now time.Time) Decision {
+	interval := time.Second / time.Duration(p.requestsPerSecond)
+	maxDelay := time.Duration(p.burst) * interval
+
+	reservation := b.limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+
+	d := Decision{Limit: p.burst}
+	if delay > maxDelay {
+		reservation.CancelAt(now)
+		d.RetryAfter = ceilSecond(delay - maxDelay)
+		return d
+	}
+
+	d.Allowed = true
+	d.Delay = delay
+	d.Reset = ceilSecond(delay)
+	queued := int((delay + interval - 1) / interval)
+	d.Remaining = max(p.burst-queued, 0)
+	return d
+}
+
+// ceilSecond rounds a positive duration up to the next whole second
+func ceilSecond(d time.Duration) time.Duration {
+	if r := d % time.Second; r != 0 {
+		d += time.Second - r
+	}
+	return d
 }
 
 // RequestsPerSecond returns the configured rate limit
@@ -256,6 +426,50 @@ func (p *Pool) Burst() int {
 	return p.burst
 }
 
+// Algorithm returns the pool's rate limiting algorithm
+func (p *Pool) Algorithm() string {
+	if p.quota != nil {
+		return AlgorithmFixedWindow
+	}
+	return p.algorithm
+}
+
+// Window returns the quota window as configured (empty for token bucket pools)
+func (p *Pool) Window() string {
+	if p.quota == nil {
+		return ""
+	}
+	return p.quota.spec
+}
+
+// Limit returns the requests allowed per key per quota window (0 for token bucket pools)
+func (p *Pool) Limit() int {
+	if p.quota == nil {
+		return 0
+	}
+	return p.quota.limit
+}
+
+// Persistent returns true if quota counters are stored on disk
+func (p *Pool) Persistent() bool {
+	if p.quota == nil {
+		return false
+	}
+	_, inMemory := p.quota.store.(*memoryQuotaStore)
+	return !inMemory
+}
+
+// activeKeys returns the number of keys currently tracked by the pool
+func (p *Pool) activeKeys() int64 {
+	if p.quota != nil {
+		n, _ := p.quota.store.count(p.name)
+		return int64(n)
+	}
+	p.bucketsMu.RLock()
+	defer p.bucketsMu.RUnlock()
+	return int64(len(p.buckets))
+}
+
 // getOrCreateBucket returns an existing bucket or creates a new one
 func (p *Pool) getOrCreateBucket(key string) *bucket {
 	p.bucketsMu.RLock()
@@ -274,8 +488,15 @@ func (p *Pool) getOrCreateBucket(key string) *bucket {
 		return b
 	}
 
-	b = &bucket{
-		limiter: rate.NewLimiter(rate.Limit(p.requestsPerSecond), p.burst),
+	b = &bucket{}
+	switch p.algorithm {
+	case AlgorithmSlidingWindow:
+		b.window = &slidingWindow{}
+	case AlgorithmLeakyBucket:
+		// A single token means nothing goes through faster than the leak rate
+		b.limiter = rate.NewLimiter(rate.Limit(p.requestsPerSecond), 1)
+	default:
+		b.limiter = rate.NewLimiter(rate.Limit(p.requestsPerSecond), p.burst)
 	}
 	b.lastUsed.Store(time.Now().Unix())
 	p.buckets[key] = b
@@ -302,6 +523,14 @@ func (p *Pool) maybeCleanup() {
 		return
 	}
 
+	if p.quota != nil {
+		// Drop counters from past windows
+		start, _ := p.quota.window.Bounds(now)
+		_ = p.quota.store.purge(p.name, start.Unix())
+		p.lastClean = now
+		return
+	}
+
 	// Remove buckets not used in the last 10 minutes
 	threshold := now.Add(-10 * time.Minute).Unix()
 	for key, b := range p.buckets {
@@ -326,14 +555,11 @@ func (l *Limiter) Snapshot() *Snapshot {
 
 	for name, pool := range l.pools {
 		poolMetrics := l.metrics.Pools[name]
-		pool.bucketsMu.RLock()
-		activeBuckets := int64(len(pool.buckets))
-		pool.bucketsMu.RUnlock()
 
 		snap.Pools[name] = &PoolMetrics{
 			Allowed:       poolMetrics.Allowed,
 			Denied:        poolMetrics.Denied,
-			ActiveBuckets: activeBuckets,
+			ActiveBuckets: pool.activeKeys(),
 		}
 	}
 
@@ -425,8 +651,13 @@ func (l *Limiter) ResetKey(poolName, key string) (bool, error) {
 	return pool.ResetKey(key), nil
 }
 
-// Reset clears all buckets in this pool, returning count of buckets cleared
+// Reset clears all buckets (or quota counters) in this pool, returning count of buckets cleared
 func (p *Pool) Reset() int {
+	if p.quota != nil {
+		n, _ := p.quota.store.reset(p.name, "")
+		return n
+	}
+
 	p.bucketsMu.Lock()
 	defer p.bucketsMu.Unlock()
 
@@ -436,8 +667,13 @@ func (p *Pool) Reset() int {
 	return count
 }
 
-// ResetKey clears a specific bucket key, returning true if it existed
+// ResetKey clears a specific bucket key (or quota counter), returning true if it existed
 func (p *Pool) ResetKey(key string) bool {
+	if p.quota != nil {
+		n, _ := p.quota.store.reset(p.name, key)
+		return n > 0
+	}
+
 	p.bucketsMu.Lock()
 	defer p.bucketsMu.Unlock()
 
@@ -1,6 +1,7 @@
 package ratelimit
 
 import (
+	"net/http"
 	"testing"
 	"time"
 
@@ -687,3 +688,207 @@ func TestReset(t *testing.T) {
 		}
 	})
 }
+
+func TestCheck_Decision(t *testing.T) {
+	engine := tmpl.New()
+	pools := []config.RateLimitPoolConfig{
+		{Name: "wide", RequestsPerSecond: 10, Burst: 10, Key: "{{.trigger.client_ip}}"},
+		{Name: "narrow", RequestsPerSecond: 1, Burst: 2, Key: "{{.trigger.client_ip}}"},
+	}
+	l, err := New(pools, engine)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	ctx := &tmpl.Context{Trigger: &tmpl.TriggerContext{ClientIP: "10.0.0.1"}}
+	limits := []config.RateLimitConfig{{Pool: "wide"}, {Pool: "narrow"}}
+
+	d, err := l.Check(limits, ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The narrow pool has fewer requests left, so it is the one reported
+	if !d.Allowed || d.Pool != "narrow" || d.Limit != 2 || d.Remaining != 1 {
+		t.Errorf("unexpected first decision: %+v", d)
+	}
+	if d.Reset != time.Second {
+		t.Errorf("expected reset of 1s, got %v", d.Reset)
+	}
+
+	_, _ = l.Check(limits, ctx)
+	d, err = l.Check(limits, ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Allowed || d.Pool != "narrow" || d.Remaining != 0 {
+		t.Errorf("expected narrow pool to deny, got %+v", d)
+	}
+	if d.RetryAfter != time.Second {
+		t.Errorf("expected retry after 1s, got %v", d.RetryAfter)
+	}
+	if d.Reset != 2*time.Second {
+		t.Errorf("expected reset of 2s, got %v", d.Reset)
+	}
+
+	d, err = l.Check(nil, ctx)
+	if err != nil || !d.Allowed || d.Limit != 0 {
+		t.Errorf("expected empty allow decision, got %+v, %v", d, err)
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	d := Decision{Allowed: true, Limit: 10, Remaining: 7, Reset: 3 * time.Second}
+
+	t.Run("x style", func(t *testing.T) {
+		h := make(http.Header)
+		SetHeaders(h, HeaderStyleX, d, now)
+		if h.Get("X-RateLimit-Limit") != "10" || h.Get("X-RateLimit-Remaining") != "7" {
+			t.Errorf("unexpected headers: %v", h)
+		}
+		if h.Get("X-RateLimit-Reset") != "1700000003" {
+			t.Errorf("expected Unix reset time, got %q", h.Get("X-RateLimit-Reset"))
+		}
+	})
+
+	t.Run("default is x style", func(t *testing.T) {
+		h := make(http.Header)
+		SetHeaders(h, "", d, now)
+		if h.Get("X-RateLimit-Limit") != "10" {
+			t.Errorf("unexpected headers: %v", h)
+		}
+	})
+
+	t.Run("ietf style", func(t *testing.T) {
+		h := make(http.Header)
+		SetHeaders(h, HeaderStyleIETF, d, now)
+		if h.Get("RateLimit-Limit") != "10" || h.Get("RateLimit-Remaining") != "7" || h.Get("RateLimit-Reset") != "3" {
+			t.Errorf("unexpected headers: %v", h)
+		}
+		if h.Get("X-RateLimit-Limit") != "" {
+			t.Error("ietf style should not emit X- headers")
+		}
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		h := make(http.Header)
+		SetHeaders(h, HeaderStyleX, Decision{Allowed: true}, now)
+		if len(h) != 0 {
+			t.Errorf("expected no headers, got %v", h)
+		}
+	})
+}
+
+func TestAlgorithms(t *testing.T) {
+	engine := tmpl.New()
+	pools := []config.RateLimitPoolConfig{
+		{Name: "default", RequestsPerSecond: 5, Burst: 5, Key: "k"},
+		{Name: "sliding", Algorithm: AlgorithmSlidingWindow, RequestsPerSecond: 3, Key: "k"},
+		{Name: "leaky", Algorithm: AlgorithmLeakyBucket, RequestsPerSecond: 10, Burst: 2, Key: "k"},
+		{Name: "quota", Window: "1h", Limit: 5, Key: "k"},
+	}
+	l, err := New(pools, engine)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer func() { _ = l.Close() }()
+
+	want := map[string]string{
+		"default": AlgorithmTokenBucket,
+		"sliding": AlgorithmSlidingWindow,
+		"leaky":   AlgorithmLeakyBucket,
+		"quota":   AlgorithmFixedWindow,
+	}
+	for name, algorithm := range want {
+		if got := l.GetPool(name).Algorithm(); got != algorithm {
+			t.Errorf("pool %s: algorithm = %q, want %q", name, got, algorithm)
+		}
+	}
+
+	_, err = New([]config.RateLimitPoolConfig{{Name: "bad", Algorithm: "gcra", RequestsPerSecond: 1, Burst: 1, Key: "k"}}, engine)
+	if err == nil {
+		t.Error("expected error for unknown algorithm")
+	}
+	_, err = New([]config.RateLimitPoolConfig{{Name: "bad", Algorithm: AlgorithmLeakyBucket, Window: "1h", Limit: 1, Key: "k"}}, engine)
+	if err == nil {
+		t.Error("expected error for algorithm on a quota pool")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	w := &slidingWindow{}
+	start := time.Unix(1700000000, 0)
+
+	// Three requests fit in the first second, the fourth does not
+	for i := 0; i < 3; i++ {
+		if d := w.take(3, start.Add(time.Duration(i)*100*time.Millisecond)); !d.Allowed || d.Remaining != 2-i {
+			t.Fatalf("request %d: unexpected decision %+v", i, d)
+		}
+	}
+	d := w.take(3, start.Add(500*time.Millisecond))
+	if d.Allowed {
+		t.Fatal("expected fourth request in the same second to be denied")
+	}
+	if d.RetryAfter != time.Second {
+		t.Errorf("expected retry after 1s, got %v", d.RetryAfter)
+	}
+
+	// Unlike a token bucket, the previous second still counts at the start of the next one
+	if d := w.take(3, start.Add(1100*time.Millisecond)); d.Allowed {
+		t.Errorf("expected request just after the window boundary to be denied, got %+v", d)
+	}
+	// Two thirds of the way through, one previous request is left in the rolling window
+	if d := w.take(3, start.Add(1700*time.Millisecond)); !d.Allowed {
+		t.Errorf("expected request once the previous window has slid out to be allowed, got %+v", d)
+	}
+
+	// Long idle periods clear both windows
+	if d := w.take(3, start.Add(time.Minute)); !d.Allowed || d.Remaining != 2 {
+		t.Errorf("expected fresh window after idle, got %+v", d)
+	}
+}
+
+func TestLeakyBucket(t *testing.T) {
+	engine := tmpl.New()
+	pools := []config.RateLimitPoolConfig{
+		{Name: "leaky", Algorithm: AlgorithmLeakyBucket, RequestsPerSecond: 10, Burst: 2, Key: "k"},
+	}
+	l, err := New(pools, engine)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	ctx := &tmpl.Context{Trigger: &tmpl.TriggerContext{ClientIP: "10.0.0.1"}}
+	limits := []config.RateLimitConfig{{Pool: "leaky"}}
+
+	// The first request goes straight through; the next two queue 100ms apart
+	var delays []time.Duration
+	for i := 0; i < 3; i++ {
+		d, err := l.Check(limits, ctx)
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+		if !d.Allowed {
+			t.Fatalf("request %d: expected allowed, got %+v", i, d)
+		}
+		delays = append(delays, d.Delay)
+	}
+	if delays[0] != 0 {
+		t.Errorf("expected no delay for first request, got %v", delays[0])
+	}
+	if delays[1] < 90*time.Millisecond || delays[1] > 100*time.Millisecond {
+		t.Errorf("expected ~100ms delay for second request, got %v", delays[1])
+	}
+	if delays[2] < 190*time.Millisecond || delays[2] > 200*time.Millisecond {
+		t.Errorf("expected ~200ms delay for third request, got %v", delays[2])
+	}
+
+	// The queue is full
+	d, err := l.Check(limits, ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Allowed || d.RetryAfter <= 0 {
+		t.Errorf("expected denial with full queue, got %+v", d)
+	}
+}
@@ -0,0 +1,245 @@
+// Package redact masks sensitive values in HTTP payloads before they are
+// logged: named headers, JSON fields and form values by path, and anything
+// matching a regular expression.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Mask replaces every redacted value
+const Mask = "[REDACTED]"
+
+// DefaultHeaders are always redacted, whatever the configuration adds
+var DefaultHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// Config lists what to redact.
+type Config struct {
+	Headers  []string `yaml:"headers"`  // Header names (case-insensitive), in addition to DefaultHeaders
+	Fields   []string `yaml:"fields"`   // JSON field paths: "password" matches at any depth, "card.number" from the root, "*" any key
+	Patterns []string `yaml:"patterns"` // Regular expressions masked anywhere in bodies, query strings, and header values
+}
+
+// Redactor applies a Config. A nil Redactor only redacts DefaultHeaders.
+type Redactor struct {
+	headers  map[string]bool
+	fields   [][]string
+	patterns []*regexp.Regexp
+}
+
+// New compiles cfg.
+func New(cfg Config) (*Redactor, error) {
+	r := &Redactor{headers: make(map[string]bool)}
+	for _, h := range cfg.Headers {
+		if strings.TrimSpace(h) == "" {
+			return nil, fmt.Errorf("headers: empty header name")
+		}
+		r.headers[http.CanonicalHeaderKey(strings.TrimSpace(h))] = true
+	}
+	for _, f := range cfg.Fields {
+		path := strings.Split(f, ".")
+		if slices.Contains(path, "") {
+			return nil, fmt.Errorf("fields: invalid path '%s'", f)
+		}
+		r.fields = append(r.fields, path)
+	}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("patterns: invalid regular expression '%s': %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Headers returns h with one value per name, redacted headers masked and
+// patterns masked in the others.
+func (r *Redactor) Headers(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if r.sensitiveHeader(name) {
+			out[name] = Mask
+			continue
+		}
+		out[name] = r.Text(strings.Join(values, ", "))
+	}
+	return out
+}
+
+// Query redacts an encoded query string or form body: values of fields named
+// by a single-segment path are masked, then patterns.
+func (r *Redactor) Query(raw string) string {
+	if raw == "" || r == nil {
+		return raw
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return r.Text(raw)
+	}
+	for name, vs := range values {
+		if r.matches([]string{name}) {
+			for i := range vs {
+				vs[i] = Mask
+			}
+		}
+	}
+	return r.Text(values.Encode())
+}
+
+// Body redacts a payload of the given content type. JSON fields are masked by
+// path and form values by name; JSON that cannot be parsed is dropped rather
+// than logged with fields unmasked.
+func (r *Redactor) Body(contentType string, body []byte) string {
+	if len(body) == 0 || r == nil {
+		return string(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return r.Query(string(body))
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if len(r.fields) == 0 {
+			break
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return "[unparseable JSON omitted]"
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(r.redactJSON(v, nil)); err != nil {
+			return "[unparseable JSON omitted]"
+		}
+		body = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	}
+	return r.Text(string(body))
+}
+
+// URL masks the credentials a URL can carry: its userinfo, its query values,
+// and with secretPath its path, for webhooks whose path is the token. It works
+// on the string, so templates in the URL are kept as written.
+func (r *Redactor) URL(raw string, secretPath bool) string {
+	rest, fragment, hasFragment := strings.Cut(raw, "#")
+	rest, query, hasQuery := strings.Cut(rest, "?")
+
+	prefix, path := "", rest
+	if scheme, after, ok := strings.Cut(rest, "://"); ok {
+		authority := after
+		path = ""
+		if i := strings.IndexByte(after, '/'); i >= 0 {
+			authority, path = after[:i], after[i:]
+		}
+		if i := strings.LastIndexByte(authority, '@'); i >= 0 {
+			authority = Mask + authority[i:]
+		}
+		prefix = scheme + "://" + authority
+	}
+	if secretPath && strings.Trim(path, "/") != "" {
+		path = Mask
+		if prefix != "" {
+			path = "/" + Mask
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix + path)
+	if hasQuery {
+		b.WriteByte('?')
+		for i, pair := range strings.Split(query, "&") {
+			if i > 0 {
+				b.WriteByte('&')
+			}
+			if name, _, ok := strings.Cut(pair, "="); ok {
+				b.WriteString(name + "=" + Mask)
+			} else if pair != "" {
+				b.WriteString(Mask)
+			}
+		}
+	}
+	if hasFragment {
+		b.WriteString("#" + fragment)
+	}
+	return r.Text(b.String())
+}
+
+// Text masks pattern matches in s.
+func (r *Redactor) Text(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllLiteralString(s, Mask)
+	}
+	return s
+}
+
+// redactJSON masks the object fields below path that match a field path.
+// Arrays do not add a path segment, so "items.card" covers every item.
+func (r *Redactor) redactJSON(v any, path []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, child := range v {
+			childPath := append(slices.Clip(path), key)
+			if r.matches(childPath) {
+				v[key] = Mask
+			} else {
+				v[key] = r.redactJSON(child, childPath)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = r.redactJSON(child, path)
+		}
+	}
+	return v
+}
+
+// matches reports whether path is redacted by a field path
+func (r *Redactor) matches(path []string) bool {
+	for _, field := range r.fields {
+		if len(field) == 1 {
+			if segmentMatches(field[0], path[len(path)-1]) {
+				return true
+			}
+			continue
+		}
+		if len(field) != len(path) {
+			continue
+		}
+		matched := true
+		for i, seg := range field {
+			if !segmentMatches(seg, path[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func segmentMatches(pattern, key string) bool {
+	return pattern == "*" || strings.EqualFold(pattern, key)
+}
+
+func (r *Redactor) sensitiveHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if slices.Contains(DefaultHeaders, name) {
+		return true
+	}
+	return r != nil && r.headers[name]
+}
@@ -1,16 +1,24 @@
 package server
 
 import (
-	"compress/gzip"
+	"cmp"
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"maps"
+	mrand "math/rand/v2"
+	"net"
 	"net/http"
 	"net/http/pprof"
+	"os"
+	"runtime"
 	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -18,16 +26,41 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/robfig/cron/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
 
+	"sql-proxy/internal/amqp"
 	"sql-proxy/internal/cache"
+	"sql-proxy/internal/cassette"
+	"sql-proxy/internal/chaos"
+	"sql-proxy/internal/compress"
+	"sql-proxy/internal/concurrency"
 	"sql-proxy/internal/config"
+	"sql-proxy/internal/crud"
 	"sql-proxy/internal/db"
+	"sql-proxy/internal/errtrack"
+	"sql-proxy/internal/grpc"
+	"sql-proxy/internal/i18n"
+	"sql-proxy/internal/kafka"
+	"sql-proxy/internal/keyring"
+	"sql-proxy/internal/loadshed"
 	"sql-proxy/internal/logging"
+	"sql-proxy/internal/mail"
 	"sql-proxy/internal/metrics"
+	"sql-proxy/internal/mqtt"
+	"sql-proxy/internal/objstore"
 	"sql-proxy/internal/openapi"
-	"sql-proxy/internal/publicid"
+	"sql-proxy/internal/outbox"
 	"sql-proxy/internal/ratelimit"
+	"sql-proxy/internal/redact"
+	"sql-proxy/internal/sftp"
+	"sql-proxy/internal/static"
+	"sql-proxy/internal/systemd"
 	"sql-proxy/internal/tmpl"
+	"sql-proxy/internal/upgrade"
+	"sql-proxy/internal/validate"
+	"sql-proxy/internal/websocket"
 	"sql-proxy/internal/workflow"
 	"sql-proxy/internal/workflow/step"
 )
@@ -42,6 +75,12 @@ const (
 	// healthCheckFailuresBeforeReconnect is how many consecutive failures before attempting reconnect
 	healthCheckFailuresBeforeReconnect = 3
 
+	// reconnectBackoffMax caps the delay between reconnect attempts to a failing database
+	reconnectBackoffMax = 5 * time.Minute
+
+	// reconnectRequestQueue is how many query-triggered reconnect checks can be pending
+	reconnectRequestQueue = 16
+
 	// httpReadTimeout is the timeout for reading the entire request
 	httpReadTimeout = 15 * time.Second
 
@@ -58,12 +97,23 @@ const (
 // fallbackIDCounter provides unique IDs when crypto/rand fails
 var fallbackIDCounter atomic.Uint64
 
+// listener is an additional HTTP listener from server.listeners
+type listener struct {
+	name   string
+	server *http.Server
+}
+
 type Server struct {
 	httpServer  *http.Server
 	debugServer *http.Server // Separate debug server (pprof) if configured on different port
+	adminServer *http.Server // Separate admin listener if server.admin_auth.port is configured
+	grpcServer  *http.Server // gRPC listener for workflow grpc triggers if server.grpc is configured
+	listeners   []listener   // Additional listeners from server.listeners
 	dbManager   *db.Manager
 	cache       *cache.Cache
 	rateLimiter *ratelimit.Limiter
+	compressor  *compress.Compressor // Response compression, tuned by server.compression
+	chaos       *chaos.Injector      // Fault injection into databases and steps, nil unless chaos is enabled
 	ctxBuilder  *tmpl.ContextBuilder
 	config      *config.Config
 	createdAt   time.Time
@@ -72,14 +122,88 @@ type Server struct {
 	dbHealthy     atomic.Bool
 	healthChecker context.CancelFunc
 
+	// Set while server.cache.preload requests run; /_/health reports "warming"
+	cacheWarming atomic.Bool
+
+	// Databases whose queries lost their connection, checked by the health checker right away
+	reconnectRequests chan string
+
 	// Cron job scheduler for workflow triggers
 	cron       *cron.Cron
 	cronCtx    context.Context    // Context for cron job execution
 	cronCancel context.CancelFunc // Cancel function for graceful shutdown
+	cronActive atomic.Int64       // Cron executions started and not yet finished
 
 	// Workflow execution (written once during initialization, read-only after)
 	workflowExecutor *workflow.Executor
 	workflows        []*workflow.CompiledWorkflow
+	sftpServers      workflowFileUploaderAdapter        // Pooled SFTP connections, closed on shutdown
+	mqttBrokers      workflowPublisherAdapter           // Broker connections, run with the watchers
+	mqttHandlers     map[string][]*workflow.MQTTHandler // Broker name -> its mqtt triggers, built in addWorkflowWatchers
+
+	// Serializes /_/databases changes and guards config.Databases against them
+	databasesMu sync.Mutex
+
+	// In-flight HTTP requests per route ("METHOD /path"), built in setupRoutes, read-only after.
+	// For websocket routes this is the number of open connections.
+	inFlight map[string]*atomic.Int64
+
+	// Websocket trigger handlers, whose connections are closed on shutdown
+	webSockets []*workflow.WebSocketHandler
+
+	// Failure rate and latency alerts, nil unless configured; evaluated with the watchers
+	alerts *workflow.AlertMonitor
+
+	// Rejects low-priority HTTP requests under load, nil unless configured; samples with the watchers
+	loadShedder *loadshed.Shedder
+
+	// Watchers for dbwatch and filewatch triggers, mqtt broker connections, and outbox relays, run from Start until Shutdown
+	watchers    []watcher
+	watchCancel context.CancelFunc
+	watchWG     sync.WaitGroup
+
+	// systemd watchdog notifications, nil unless WatchdogSec= is set; run with the watchers, also during an upgrade
+	watchdog *watchdog
+
+	// Listening sockets by listener name, handed to the new process by an upgrade
+	socketsMu sync.Mutex
+	sockets   map[string]net.Listener
+}
+
+// watchdog sends systemd watchdog notifications at half the WatchdogSec=
+// interval, each after a /_/health request through the main handler chain
+// returned. A server too wedged to answer stops the notifications and is
+// restarted by systemd.
+type watchdog struct {
+	handler  http.Handler
+	interval time.Duration
+}
+
+func (d *watchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/_/health", nil)
+		if err != nil {
+			continue
+		}
+		req.RemoteAddr = "127.0.0.1:0"
+		// Any status counts: an unhealthy database is reported by /_/health, not fixed by a restart
+		d.handler.ServeHTTP(&preloadResponseWriter{header: make(http.Header)}, req)
+		if _, err := systemd.Notify(systemd.Watchdog); err != nil {
+			logging.Warn("systemd_watchdog_failed", map[string]any{"error": err.Error()})
+		}
+	}
+}
+
+// watcher polls a source for a workflow trigger until its context is cancelled
+type watcher interface {
+	Run(ctx context.Context)
 }
 
 // Response types for JSON encoding
@@ -103,11 +227,18 @@ type cacheClearResponse struct {
 	Endpoint string `json:"endpoint,omitempty"`
 }
 
+type cacheInvalidateResponse struct {
+	Status      string `json:"status"`
+	Tag         string `json:"tag"`
+	Invalidated int    `json:"invalidated"`
+}
+
 type dbHealthResponse struct {
-	Database string `json:"database"`
-	Status   string `json:"status"`
-	Type     string `json:"type"`
-	ReadOnly bool   `json:"readonly"`
+	Database string      `json:"database"`
+	Status   string      `json:"status"`
+	Type     string      `json:"type"`
+	ReadOnly bool        `json:"readonly"`
+	Pool     dbPoolStats `json:"pool"`
 }
 
 type errorResponse struct {
@@ -126,22 +257,53 @@ func writeJSON(w http.ResponseWriter, v any) {
 }
 
 func New(cfg *config.Config, interactive bool) (*Server, error) {
-	// Initialize logging
-	// Interactive: stdout, Service: file
-	logFile := ""
-	if !interactive {
-		logFile = cfg.Logging.FilePath
-	}
-	if err := logging.Init(cfg.Logging.Level, logFile, cfg.Logging.MaxSizeMB, cfg.Logging.MaxBackups, cfg.Logging.MaxAgeDays); err != nil {
-		return nil, fmt.Errorf("failed to initialize logging: %w", err)
+	// Configs built in code skip config.Load, which normally enables these
+	if cfg.Server.SprigFunctions {
+		tmpl.EnableSprig()
+	}
+
+	// Logging and error tracking are process-wide: later instances of an
+	// instances file use those of the first
+	if !cfg.Server.SecondaryInstance {
+		// Initialize logging
+		// Interactive: stdout, Service: file
+		logFile := ""
+		if !interactive {
+			logFile = cfg.Logging.FilePath
+		}
+		if err := logging.Init(cfg.Logging.Level, logFile, cfg.Logging.MaxSizeMB, cfg.Logging.MaxBackups, cfg.Logging.MaxAgeDays); err != nil {
+			return nil, fmt.Errorf("failed to initialize logging: %w", err)
+		}
+		redactor, err := redact.New(cfg.Logging.Redact)
+		if err != nil {
+			return nil, fmt.Errorf("invalid logging.redact: %w", err)
+		}
+		workflow.SetPayloadRedactor(redactor)
+
+		// Error tracking is off unless a Sentry DSN or webhook is configured
+		obs := cfg.Observability
+		if err := errtrack.Init(errtrack.Config{
+			SentryDSN:      obs.SentryDSN,
+			Webhook:        obs.ErrorWebhook,
+			WebhookHeaders: obs.ErrorWebhookHeaders,
+			Environment:    obs.Environment,
+			Release:        cfg.Server.Version,
+			Timeout:        time.Duration(obs.TimeoutSec) * time.Second,
+		}); err != nil {
+			return nil, fmt.Errorf("invalid observability: %w", err)
+		}
 	}
 
-	logging.Info("service_starting", map[string]any{
+	startFields := map[string]any{
 		"version":   cfg.Server.Version,
 		"log_level": cfg.Logging.Level,
 		"workflows": len(cfg.Workflows),
 		"databases": len(cfg.Databases),
-	})
+	}
+	if cfg.Server.Instance != "" {
+		startFields["instance"] = cfg.Server.Instance
+	}
+	logging.Info("service_starting", startFields)
 
 	// Connect to all databases
 	dbManager, err := db.NewManager(cfg.Databases)
@@ -158,8 +320,14 @@ func New(cfg *config.Config, interactive bool) (*Server, error) {
 			"type":     dbCfg.Type,
 			"readonly": dbCfg.IsReadOnly(),
 		}
+		if dbCfg.TenantRouting != nil {
+			logging.Info("database_tenant_routed", logFields) // Connections open per tenant on first use
+			continue
+		}
 		if dbCfg.Type == "sqlite" {
 			logFields["path"] = dbCfg.Path
+		} else if dbCfg.Type == "mock" {
+			logFields["fixtures"] = dbCfg.Fixtures
 		} else {
 			logFields["host"] = dbCfg.Host
 			logFields["database"] = dbCfg.Database
@@ -174,6 +342,26 @@ func New(cfg *config.Config, interactive bool) (*Server, error) {
 	}
 	s.dbHealthy.Store(true)
 
+	var compressCfg compress.Config
+	if cc := cfg.Server.Compression; cc != nil {
+		compressCfg = compress.Config{
+			Encodings:        cc.Encodings,
+			MinSizeBytes:     cc.MinSizeBytes,
+			SkipContentTypes: cc.SkipContentTypes,
+		}
+	}
+	s.compressor = compress.New(compressCfg)
+
+	if s.chaos, err = chaos.New(cfg.Chaos); err != nil {
+		return nil, fmt.Errorf("invalid chaos: %w", err)
+	}
+	if s.chaos != nil {
+		logging.Warn("chaos_enabled", map[string]any{
+			"databases": slices.Sorted(maps.Keys(cfg.Chaos.Databases)),
+			"workflows": slices.Sorted(maps.Keys(cfg.Chaos.Steps)),
+		})
+	}
+
 	// Initialize cache if enabled
 	if cfg.Server.Cache != nil && cfg.Server.Cache.Enabled {
 		var err error
@@ -184,10 +372,15 @@ func New(cfg *config.Config, interactive bool) (*Server, error) {
 			})
 			return nil, fmt.Errorf("failed to initialize cache: %w", err)
 		}
-		logging.Info("cache_initialized", map[string]any{
+		cacheFields := map[string]any{
 			"max_size_mb":     cfg.Server.Cache.MaxSizeMB,
 			"default_ttl_sec": cfg.Server.Cache.DefaultTTLSec,
-		})
+		}
+		if disk := cfg.Server.Cache.Disk; disk != nil {
+			cacheFields["disk_path"] = disk.Path
+			cacheFields["disk_max_size_mb"] = disk.MaxSizeMB
+		}
+		logging.Info("cache_initialized", cacheFields)
 	}
 
 	// Initialize template engine and context builder (for rate limiting, webhooks, etc.)
@@ -195,8 +388,8 @@ func New(cfg *config.Config, interactive bool) (*Server, error) {
 	s.ctxBuilder = tmpl.NewContextBuilder(cfg.Server.TrustProxyHeaders, cfg.Server.Version)
 
 	// Initialize public ID encoder if configured
-	if cfg.PublicIDs != nil && cfg.PublicIDs.SecretKey != "" {
-		enc, err := publicid.NewEncoder(cfg.PublicIDs.SecretKey, cfg.PublicIDs.Namespaces)
+	if cfg.PublicIDs != nil && (cfg.PublicIDs.SecretKey != "" || len(cfg.PublicIDs.Keys) > 0) {
+		enc, err := cfg.PublicIDs.Encoder()
 		if err != nil {
 			logging.Error("public_id_encoder_init_failed", map[string]any{
 				"error": err.Error(),
@@ -207,6 +400,42 @@ func New(cfg *config.Config, interactive bool) (*Server, error) {
 		workflow.SetTemplateEncoder(enc)
 		logging.Info("public_id_encoder_initialized", map[string]any{
 			"namespaces": len(cfg.PublicIDs.Namespaces),
+			"active_key": enc.ActiveKey(),
+			"keys":       len(cfg.PublicIDs.Keys),
+		})
+	}
+
+	// Initialize crypto keys if configured
+	if cfg.CryptoKeys != nil {
+		k, err := keyring.New(cfg.CryptoKeys.Active, cfg.CryptoKeys.Keys)
+		if err != nil {
+			logging.Error("crypto_keys_init_failed", map[string]any{
+				"error": err.Error(),
+			})
+			return nil, fmt.Errorf("failed to initialize crypto keys: %w", err)
+		}
+		tmplEngine.SetKeyring(k)
+		workflow.SetTemplateKeyring(k)
+		logging.Info("crypto_keys_initialized", map[string]any{
+			"active": k.Active(),
+			"keys":   len(cfg.CryptoKeys.Keys),
+		})
+	}
+
+	// Initialize message catalog if configured
+	if cfg.Messages != nil {
+		c, err := i18n.New(cfg.Messages.Default, cfg.Messages.Languages)
+		if err != nil {
+			logging.Error("messages_init_failed", map[string]any{
+				"error": err.Error(),
+			})
+			return nil, fmt.Errorf("failed to initialize messages: %w", err)
+		}
+		tmplEngine.SetMessages(c)
+		workflow.SetTemplateMessages(c)
+		logging.Info("messages_initialized", map[string]any{
+			"default":   c.Default(),
+			"languages": c.Languages(),
 		})
 	}
 
@@ -225,8 +454,8 @@ func New(cfg *config.Config, interactive bool) (*Server, error) {
 		})
 	}
 
-	// Initialize metrics
-	if cfg.Metrics.Enabled {
+	// Initialize metrics (process-wide, like logging)
+	if cfg.Metrics.Enabled && !cfg.Server.SecondaryInstance {
 		metrics.Init(s.checkDBHealth, cfg.Server.Version, cfg.Server.BuildTime)
 		// Set cache snapshot provider for metrics
 		if s.cache != nil {
@@ -243,6 +472,34 @@ func New(cfg *config.Config, interactive bool) (*Server, error) {
 		logging.Info("metrics_initialized", nil)
 	}
 
+	// Generate CRUD workflows now that their tables can be read
+	for _, c := range cfg.Crud {
+		driver, err := dbManager.Get(c.Database)
+		if err != nil {
+			return nil, fmt.Errorf("crud[%s]: %w", c.Name, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		generated, err := crud.Generate(ctx, c, driver)
+		cancel()
+		if err != nil {
+			logging.Error("crud_generation_failed", map[string]any{
+				"name":  c.Name,
+				"table": c.Table,
+				"error": err.Error(),
+			})
+			return nil, fmt.Errorf("crud[%s]: %w", c.Name, err)
+		}
+		for i := range generated {
+			generated[i].StatusMap = cfg.StatusMap
+		}
+		cfg.Workflows = append(cfg.Workflows, generated...)
+		logging.Info("crud_workflows_generated", map[string]any{
+			"name":      c.Name,
+			"table":     c.Table,
+			"workflows": len(generated),
+		})
+	}
+
 	// Initialize workflows if configured
 	if len(cfg.Workflows) > 0 {
 		if err := s.initWorkflows(cfg); err != nil {
@@ -253,22 +510,63 @@ func New(cfg *config.Config, interactive bool) (*Server, error) {
 		if err := s.addWorkflowCronJobs(); err != nil {
 			return nil, err
 		}
+
+		// Create watchers for dbwatch and filewatch triggers and register mqtt subscriptions
+		if err := s.addWorkflowWatchers(); err != nil {
+			return nil, err
+		}
 	}
 
 	// Start background health checker
+	s.reconnectRequests = make(chan string, reconnectRequestQueue)
 	healthCtx, healthCancel := context.WithCancel(context.Background())
 	s.healthChecker = healthCancel
 	go s.runHealthChecker(healthCtx)
 
-	// Setup routes
-	mux := http.NewServeMux()
-	s.setupRoutes(mux)
+	// Setup routes: one mux per listener, serving the workflows that name it
+	muxes := map[string]*http.ServeMux{workflow.MainListener: http.NewServeMux()}
+	for _, l := range cfg.Server.Listeners {
+		muxes[l.Name] = http.NewServeMux()
+	}
+	s.setupRoutes(muxes)
+	mux := muxes[workflow.MainListener]
+	if cfg.Server.GRPC != nil {
+		if err := s.setupGRPC(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Admin endpoints share the main mux unless a separate admin listener is configured,
+	// or a listener from server.listeners is marked admin
+	separateAdmin := cfg.Server.AdminAuth.SeparateListener(cfg.Server.Port)
+	adminMux := mux
+	adminListener := workflow.MainListener
+	if separateAdmin {
+		adminMux = http.NewServeMux()
+		adminListener = ""
+	}
+	for _, l := range cfg.Server.Listeners {
+		if l.Admin {
+			adminMux = muxes[l.Name]
+			adminListener = l.Name
+		}
+	}
+	s.setupAdminRoutes(adminMux)
 
 	// Calculate write timeout based on max query timeout + buffer
 	writeTimeout := time.Duration(cfg.Server.MaxTimeoutSec)*time.Second + writeTimeoutBuffer
 
-	// Middleware chain: recovery -> bodyLimit -> gzip -> routes
-	handler := s.recoveryMiddleware(s.bodySizeLimitMiddleware(s.gzipMiddleware(mux)))
+	// Middleware chain: recovery -> bodyLimit -> adminAuth -> compress -> routes
+	// Listeners that carry no admin routes skip auth
+	handlers := make(map[string]http.Handler, len(muxes))
+	for name, m := range muxes {
+		h := s.compressMiddleware(m)
+		if name == adminListener {
+			h = s.adminAuthMiddleware(h)
+		}
+		handlers[name] = s.recoveryMiddleware(s.bodySizeLimitMiddleware(h))
+	}
+	handler := handlers[workflow.MainListener]
 
 	s.httpServer = &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
@@ -277,19 +575,83 @@ func New(cfg *config.Config, interactive bool) (*Server, error) {
 		WriteTimeout: writeTimeout,
 		IdleTimeout:  httpIdleTimeout,
 	}
+	configureHTTP2(s.httpServer, cfg.Server.HTTP2)
+
+	for _, l := range cfg.Server.Listeners {
+		host := l.Host
+		if host == "" {
+			host = cfg.Server.Host
+		}
+		s.listeners = append(s.listeners, listener{name: l.Name, server: &http.Server{
+			Addr:         fmt.Sprintf("%s:%d", host, l.Port),
+			Handler:      handlers[l.Name],
+			ReadTimeout:  httpReadTimeout,
+			WriteTimeout: writeTimeout,
+			IdleTimeout:  httpIdleTimeout,
+		}})
+		logging.Info("listener_configured", map[string]any{
+			"name":  l.Name,
+			"host":  host,
+			"port":  l.Port,
+			"admin": l.Admin,
+		})
+	}
+
+	// Preload requests go through the full handler chain and run with the watchers
+	if s.cache != nil && len(cfg.Server.Cache.Preload) > 0 {
+		preloader, err := s.newCachePreloader(cfg.Server.Cache.Preload, handlers)
+		if err != nil {
+			return nil, err
+		}
+		s.cacheWarming.Store(true)
+		s.watchers = append(s.watchers, preloader)
+	}
+
+	// With WatchdogSec= set, systemd restarts the service unless it is told the
+	// server still answers requests
+	if interval := systemd.WatchdogInterval(); interval > 0 {
+		s.watchdog = &watchdog{handler: handler, interval: interval}
+	}
+
+	// Setup separate admin server if configured
+	if separateAdmin {
+		adminHost := cfg.Server.AdminAuth.Host
+		if adminHost == "" {
+			adminHost = "localhost" // Default to localhost for security
+		}
+
+		s.adminServer = &http.Server{
+			Addr:         fmt.Sprintf("%s:%d", adminHost, cfg.Server.AdminAuth.Port),
+			Handler:      s.recoveryMiddleware(s.bodySizeLimitMiddleware(s.adminAuthMiddleware(s.compressMiddleware(adminMux)))),
+			ReadTimeout:  httpReadTimeout,
+			WriteTimeout: writeTimeout,
+			IdleTimeout:  httpIdleTimeout,
+		}
+
+		logging.Info("admin_server_configured", map[string]any{
+			"host": adminHost,
+			"port": cfg.Server.AdminAuth.Port,
+		})
+	}
+	if cfg.Server.AdminAuth != nil {
+		logging.Info("admin_auth_enabled", map[string]any{
+			"bearer": cfg.Server.AdminAuth.Token != "",
+			"basic":  cfg.Server.AdminAuth.Username != "",
+		})
+	}
 
 	// Setup debug server (pprof) if enabled
 	if cfg.Debug.Enabled {
 		debugPort := cfg.Debug.Port
 
 		if debugPort == 0 || debugPort == cfg.Server.Port {
-			// Same port as main server - add pprof routes to main mux
+			// Same port as main server - add pprof routes to the admin mux (main mux unless admin is separate)
 			// Note: debug.host is not allowed in this case (caught by config validation)
-			mux.HandleFunc("/_/debug/pprof/", pprof.Index)
-			mux.HandleFunc("/_/debug/pprof/cmdline", pprof.Cmdline)
-			mux.HandleFunc("/_/debug/pprof/profile", pprof.Profile)
-			mux.HandleFunc("/_/debug/pprof/symbol", pprof.Symbol)
-			mux.HandleFunc("/_/debug/pprof/trace", pprof.Trace)
+			adminMux.HandleFunc("/_/debug/pprof/", pprof.Index)
+			adminMux.HandleFunc("/_/debug/pprof/cmdline", pprof.Cmdline)
+			adminMux.HandleFunc("/_/debug/pprof/profile", pprof.Profile)
+			adminMux.HandleFunc("/_/debug/pprof/symbol", pprof.Symbol)
+			adminMux.HandleFunc("/_/debug/pprof/trace", pprof.Trace)
 
 			logging.Info("debug_endpoints_enabled", map[string]any{
 				"host": cfg.Server.Host,
@@ -329,24 +691,102 @@ func New(cfg *config.Config, interactive bool) (*Server, error) {
 	return s, nil
 }
 
+// healthSchedule is the resolved server.health_check configuration
+type healthSchedule struct {
+	interval                time.Duration
+	timeout                 time.Duration
+	failuresBeforeReconnect int
+	backoffMax              time.Duration
+}
+
+// newHealthSchedule applies defaults to the optional health check config
+func newHealthSchedule(hc *config.HealthCheckConfig) healthSchedule {
+	hs := healthSchedule{
+		interval:                healthCheckInterval,
+		timeout:                 healthCheckTimeout,
+		failuresBeforeReconnect: healthCheckFailuresBeforeReconnect,
+		backoffMax:              reconnectBackoffMax,
+	}
+	if hc == nil {
+		return hs
+	}
+	if hc.IntervalSec > 0 {
+		hs.interval = time.Duration(hc.IntervalSec) * time.Second
+	}
+	if hc.TimeoutSec > 0 {
+		hs.timeout = time.Duration(hc.TimeoutSec) * time.Second
+	}
+	if hc.FailuresBeforeReconnect > 0 {
+		hs.failuresBeforeReconnect = hc.FailuresBeforeReconnect
+	}
+	if hc.ReconnectBackoffMaxSec > 0 {
+		hs.backoffMax = time.Duration(hc.ReconnectBackoffMaxSec) * time.Second
+	}
+	return hs
+}
+
+// reconnectDelay returns how long to wait after the given number of consecutive
+// failed reconnects: the interval doubled per failure, capped at backoffMax, with
+// "equal jitter" (a random point in the upper half) so that databases failing
+// together don't retry in lockstep.
+func (hs healthSchedule) reconnectDelay(failures int) time.Duration {
+	d := hs.interval
+	for i := 1; i < failures && d < hs.backoffMax; i++ {
+		d *= 2
+	}
+	d = min(d, hs.backoffMax)
+	half := d / 2
+	return half + mrand.N(half+1)
+}
+
+// dbHealthState tracks a database's failures between health checks
+type dbHealthState struct {
+	failedPings      int       // Consecutive failed pings
+	failedReconnects int       // Consecutive failed reconnects (drives the backoff)
+	nextReconnect    time.Time // No reconnect before this time
+}
+
 // runHealthChecker periodically checks database connectivity for all connections.
-// Runs immediately on startup to populate gauges, then on each tick.
+// Runs immediately on startup to populate gauges, then on each tick. Queries that
+// lose their connection trigger an out-of-schedule check (see requestReconnect).
 func (s *Server) runHealthChecker(ctx context.Context) {
-	ticker := time.NewTicker(healthCheckInterval)
+	hs := newHealthSchedule(s.config.Server.HealthCheck)
+	ticker := time.NewTicker(hs.interval)
 	defer ticker.Stop()
 
-	consecutiveFailures := make(map[string]int)
+	states := make(map[string]*dbHealthState)
+	state := func(name string) *dbHealthState {
+		if states[name] == nil {
+			states[name] = &dbHealthState{}
+		}
+		return states[name]
+	}
 
 	for first := true; ; first = false {
 		if !first {
 			select {
 			case <-ctx.Done():
 				return
+			case name := <-s.reconnectRequests:
+				// A query failed with a connection error: reconnect now if the
+				// database really is down, without waiting for more failed pings
+				driver, err := s.dbManager.Get(name)
+				if err != nil {
+					continue // Removed or tenant-routed
+				}
+				pingCtx, cancel := context.WithTimeout(ctx, hs.timeout)
+				err = driver.Ping(pingCtx)
+				cancel()
+				if err != nil {
+					s.dbHealthy.Store(false)
+					s.handlePingFailure(name, err, state(name), hs, true)
+				}
+				continue
 			case <-ticker.C:
 			}
 		}
 
-		pingCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		pingCtx, cancel := context.WithTimeout(ctx, hs.timeout)
 		results := s.dbManager.Ping(pingCtx)
 		cancel()
 
@@ -355,40 +795,23 @@ func (s *Server) runHealthChecker(ctx context.Context) {
 
 		for name, err := range results {
 			metrics.UpdateDBHealth(name, err == nil)
+			st := state(name)
 			if err != nil {
 				allHealthy = false
-				consecutiveFailures[name]++
-
-				logging.Warn("health_check_failed", map[string]any{
-					"database":             name,
-					"error":                err.Error(),
-					"consecutive_failures": consecutiveFailures[name],
+				s.handlePingFailure(name, err, st, hs, false)
+				continue
+			}
+			if st.failedPings > 0 {
+				logging.Info("health_restored", map[string]any{
+					"database":       name,
+					"after_failures": st.failedPings,
 				})
-
-				if consecutiveFailures[name] >= healthCheckFailuresBeforeReconnect {
-					logging.Info("attempting_reconnect", map[string]any{
-						"database": name,
-					})
-					if err := s.dbManager.Reconnect(name); err != nil {
-						logging.Error("reconnect_failed", map[string]any{
-							"database": name,
-							"error":    err.Error(),
-						})
-					} else {
-						logging.Info("reconnect_successful", map[string]any{
-							"database": name,
-						})
-						consecutiveFailures[name] = 0
-					}
-				}
-			} else {
-				if consecutiveFailures[name] > 0 {
-					logging.Info("health_restored", map[string]any{
-						"database":       name,
-						"after_failures": consecutiveFailures[name],
-					})
-				}
-				consecutiveFailures[name] = 0
+			}
+			*st = dbHealthState{}
+		}
+		for name := range states {
+			if _, ok := results[name]; !ok {
+				delete(states, name) // Database was removed
 			}
 		}
 
@@ -398,25 +821,86 @@ func (s *Server) runHealthChecker(ctx context.Context) {
 			logging.Info("all_databases_healthy", nil)
 		}
 
-		for _, name := range s.dbManager.Names() {
-			if driver, err := s.dbManager.Get(name); err == nil {
-				ps := driver.PoolStats()
-				metrics.UpdateDBPoolStats(name, ps.OpenConnections, ps.IdleConnections)
-			}
+		s.updateGauges()
+	}
+}
+
+// handlePingFailure records a failed ping and reconnects once failures_before_reconnect
+// pings have failed in a row (or right away when immediate), unless the database is
+// still backing off from a failed reconnect.
+func (s *Server) handlePingFailure(name string, err error, st *dbHealthState, hs healthSchedule, immediate bool) {
+	st.failedPings++
+	logging.Warn("health_check_failed", map[string]any{
+		"database":             name,
+		"error":                err.Error(),
+		"consecutive_failures": st.failedPings,
+	})
+
+	if !immediate && st.failedPings < hs.failuresBeforeReconnect {
+		return
+	}
+	if time.Now().Before(st.nextReconnect) {
+		return
+	}
+
+	logging.Info("attempting_reconnect", map[string]any{
+		"database":  name,
+		"attempt":   st.failedReconnects + 1,
+		"immediate": immediate,
+	})
+	if err := s.dbManager.Reconnect(name); err != nil {
+		st.failedReconnects++
+		delay := hs.reconnectDelay(st.failedReconnects)
+		st.nextReconnect = time.Now().Add(delay)
+		logging.Error("reconnect_failed", map[string]any{
+			"database": name,
+			"error":    err.Error(),
+			"retry_in": delay.Round(time.Second).String(),
+		})
+		return
+	}
+	logging.Info("reconnect_successful", map[string]any{
+		"database": name,
+	})
+	*st = dbHealthState{}
+}
+
+// requestReconnect asks the health checker to check the named database now,
+// reconnecting it if it is down. Never blocks; requests beyond the queue are
+// dropped, as one pending check per outage is enough.
+func (s *Server) requestReconnect(name string) {
+	select {
+	case s.reconnectRequests <- name:
+	default:
+	}
+}
+
+// updateGauges refreshes the pool, cache and rate limit gauges for Prometheus
+func (s *Server) updateGauges() {
+	for _, name := range s.dbManager.Names() {
+		if driver, err := s.dbManager.Get(name); err == nil {
+			ps := driver.PoolStats()
+			metrics.UpdateDBPoolStats(name, metrics.DBPoolStats{
+				Open:         ps.OpenConnections,
+				Idle:         ps.IdleConnections,
+				InUse:        ps.InUse,
+				WaitCount:    ps.WaitCount,
+				WaitDuration: ps.WaitDuration,
+			})
 		}
+	}
 
-		if s.cache != nil {
-			snap := s.cache.GetSnapshot()
-			for endpoint, ep := range snap.Endpoints {
-				metrics.UpdateCacheStats(endpoint, ep.SizeBytes, ep.KeyCount)
-			}
+	if s.cache != nil {
+		snap := s.cache.GetSnapshot()
+		for endpoint, ep := range snap.Endpoints {
+			metrics.UpdateCacheStats(endpoint, ep.SizeBytes, ep.KeyCount)
 		}
+	}
 
-		if s.rateLimiter != nil {
-			snap := s.rateLimiter.Snapshot()
-			for pool, pm := range snap.Pools {
-				metrics.UpdateRateLimitBuckets(pool, pm.ActiveBuckets)
-			}
+	if s.rateLimiter != nil {
+		snap := s.rateLimiter.Snapshot()
+		for pool, pm := range snap.Pools {
+			metrics.UpdateRateLimitBuckets(pool, pm.ActiveBuckets)
 		}
 	}
 }
@@ -426,50 +910,53 @@ func (s *Server) checkDBHealth() bool {
 	return s.dbHealthy.Load()
 }
 
-func (s *Server) setupRoutes(mux *http.ServeMux) {
-	// Internal endpoints (/_/ prefix is reserved, user queries cannot use it)
-	// Health check endpoints
-	mux.HandleFunc("/_/health", s.healthHandler)    // Aggregate health
-	mux.HandleFunc("/_/health/", s.dbHealthHandler) // Per-database health: /_/health/{dbname}
-
-	// Metrics endpoints
-	mux.HandleFunc("/_/metrics.json", s.metricsJSONHandler)  // Human-readable JSON metrics
-	mux.HandleFunc("/_/metrics", s.metricsPrometheusHandler) // Prometheus format
-
-	// OpenAPI spec endpoint
-	mux.HandleFunc("/_/openapi.json", s.openAPIHandler)
-
-	// Runtime config endpoint
-	mux.HandleFunc("/_/config/loglevel", s.logLevelHandler)
-
-	// Cache management endpoint
-	mux.HandleFunc("/_/cache/clear", s.cacheClearHandler)
+func (s *Server) setupRoutes(muxes map[string]*http.ServeMux) {
+	for name, mux := range muxes {
+		// Internal endpoints (/_/ prefix is reserved, user queries cannot use it)
+		// Health check endpoints, on every listener for load balancer probes
+		mux.HandleFunc("/_/health", s.healthHandler)    // Aggregate health
+		mux.HandleFunc("/_/health/", s.dbHealthHandler) // Per-database health: /_/health/{dbname}
 
-	// Rate limit observability and management endpoints
-	mux.HandleFunc("/_/ratelimits", s.rateLimitsHandler)
-	mux.HandleFunc("/_/ratelimits/reset", s.rateLimitsResetHandler)
-
-	// List available endpoints
-	mux.HandleFunc("/", s.listEndpointsHandler)
-
-	// Create rate limiter adapter for workflows
-	var rateLimiterAdapter workflow.RateLimiter
-	if s.rateLimiter != nil {
-		rateLimiterAdapter = &workflowRateLimiterAdapter{
-			limiter:    s.rateLimiter,
-			ctxBuilder: s.ctxBuilder,
-		}
+		// List the endpoints available on this listener
+		mux.HandleFunc("/", s.listEndpoints(name))
 	}
 
+	rateLimiterAdapter := s.workflowRateLimiter()
+
 	// Create trigger cache adapter for workflows
 	var triggerCache workflow.TriggerCache
 	if s.cache != nil {
 		triggerCache = &triggerCacheAdapter{cache: s.cache}
 	}
 
-	// Register workflow HTTP triggers
+	// Register workflow HTTP and websocket triggers
+	s.inFlight = make(map[string]*atomic.Int64)
 	for _, wf := range s.workflows {
 		for _, trigger := range wf.Triggers {
+			if trigger.Config.Type == "websocket" {
+				h := workflow.NewWebSocketHandler(
+					s.workflowExecutor,
+					wf,
+					trigger,
+					rateLimiterAdapter,
+					s.config.Server.TrustProxyHeaders,
+					s.config.Server.Version,
+					s.config.Server.BuildTime,
+					s.config.Variables.Values,
+				)
+				s.webSockets = append(s.webSockets, h)
+				// Counts open connections; there is no per-request status to record metrics for
+				pattern := "GET " + trigger.Config.Path
+				inFlight := &atomic.Int64{}
+				s.inFlight[pattern] = inFlight
+				handleOn(muxes, wf.Config, pattern, trackInFlight(inFlight, h))
+
+				logging.Info("workflow_websocket_registered", map[string]any{
+					"workflow": wf.Config.Name,
+					"path":     trigger.Config.Path,
+				})
+				continue
+			}
 			if trigger.Config.Type != "http" {
 				continue
 			}
@@ -486,7 +973,9 @@ func (s *Server) setupRoutes(mux *http.ServeMux) {
 				s.config.Variables.Values,
 			)
 			pattern := trigger.Config.Method + " " + trigger.Config.Path
-			mux.Handle(pattern, s.metricsMiddleware(wf.Config.Name, trigger.Config.Method, h))
+			inFlight := &atomic.Int64{}
+			s.inFlight[pattern] = inFlight
+			handleOn(muxes, wf.Config, pattern, s.metricsMiddleware(wf.Config.Name, trigger.Config.Method, trackInFlight(inFlight, h)))
 
 			logging.Info("workflow_endpoint_registered", map[string]any{
 				"workflow": wf.Config.Name,
@@ -495,67 +984,274 @@ func (s *Server) setupRoutes(mux *http.ServeMux) {
 			})
 		}
 	}
-}
-
-func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 
-	// Check each database individually
-	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
-	defer cancel()
-	dbResults := s.dbManager.Ping(ctx)
-
-	// Build per-database status and count healthy/unhealthy
-	databases := make(map[string]string)
-	healthyCount := 0
-	totalCount := 0
-	for name, err := range dbResults {
-		totalCount++
-		if err != nil {
-			databases[name] = "disconnected"
-		} else {
-			databases[name] = "connected"
-			healthyCount++
+	// Register static directories
+	for _, st := range s.config.Static {
+		h := static.Handler(static.Config{Prefix: st.Path, Dir: st.Dir, MaxAgeSec: st.MaxAgeSec})
+		for name, mux := range muxes {
+			if st.ServedOn(name) {
+				mux.Handle("GET "+st.Path, h)
+			}
 		}
+		logging.Info("static_registered", map[string]any{
+			"path": st.Path,
+			"dir":  st.Dir,
+		})
 	}
+}
 
-	// Determine overall status:
-	// - "healthy" = all DBs connected
-	// - "degraded" = some DBs connected, some disconnected
-	// - "unhealthy" = all DBs disconnected
-	status := "healthy"
-	if healthyCount == 0 && totalCount > 0 {
-		status = "unhealthy"
-	} else if healthyCount < totalCount {
-		status = "degraded"
+// handleOn registers a workflow route on the listeners serving the workflow
+func handleOn(muxes map[string]*http.ServeMux, wf *workflow.WorkflowConfig, pattern string, h http.Handler) {
+	for name, mux := range muxes {
+		if wf.ServedOn(name) {
+			mux.Handle(pattern, h)
+		}
 	}
-
-	// Always return 200 - clients should parse the status field
-	// This avoids confusion with proxy/middleware 503s
-	writeJSON(w, healthResponse{
-		Status:    status,
-		Databases: databases,
-		Uptime:    time.Since(s.startTime()).String(),
-	})
 }
 
-// dbHealthHandler handles per-database health checks: /_/health/{dbname}
-func (s *Server) dbHealthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Extract database name from path: /_/health/{dbname}
-	dbName := strings.TrimPrefix(r.URL.Path, "/_/health/")
-	if dbName == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		writeJSON(w, errorResponse{
-			Error: "database name required: /_/health/{dbname}",
-		})
-		return
+// workflowRateLimiter returns the rate limiter adapter for workflow handlers (nil without rate limits)
+func (s *Server) workflowRateLimiter() workflow.RateLimiter {
+	if s.rateLimiter == nil {
+		return nil
+	}
+	return &workflowRateLimiterAdapter{
+		limiter:     s.rateLimiter,
+		ctxBuilder:  s.ctxBuilder,
+		headerStyle: s.config.Server.RateLimitHeaders,
 	}
+}
 
-	// Get the database driver
-	driver, err := s.dbManager.Get(dbName)
-	if err != nil {
+// setupGRPC builds the gRPC listener for workflow grpc triggers: Execute runs any
+// of them by name, and triggers with an rpc name also get their own method.
+func (s *Server) setupGRPC() error {
+	grpcCfg := s.config.Server.GRPC
+	rateLimiterAdapter := s.workflowRateLimiter()
+	handlers := make(map[string]grpc.Handler) // Workflow name -> call handler, for Execute
+	var rpcs []grpc.RPC
+	rpcHandlers := make(map[string]grpc.Handler) // Method path -> call handler
+	for _, wf := range s.workflows {
+		for _, trigger := range wf.Triggers {
+			if trigger.Config.Type != workflow.TriggerTypeGRPC {
+				continue
+			}
+			h := workflow.NewGRPCHandler(
+				s.workflowExecutor,
+				wf,
+				trigger,
+				rateLimiterAdapter,
+				s.config.Server.TrustProxyHeaders,
+				s.config.Server.Version,
+				s.config.Server.BuildTime,
+				s.config.Variables.Values,
+			)
+			route := "GRPC " + wf.Config.Name
+			inFlight := &atomic.Int64{}
+			s.inFlight[route] = inFlight
+			call := s.grpcCall(wf.Config.Name, h, inFlight)
+			handlers[wf.Config.Name] = call
+
+			if trigger.Config.RPC != "" {
+				rpc := grpc.RPC{Name: trigger.Config.RPC}
+				for _, p := range trigger.Config.Parameters {
+					rpc.Fields = append(rpc.Fields, grpc.Field{Name: p.Name, Type: p.Type})
+				}
+				rpcs = append(rpcs, rpc)
+				rpcHandlers["/"+grpc.ServiceName+"/"+rpc.Name] = call
+			}
+
+			logging.Info("workflow_grpc_registered", map[string]any{
+				"workflow": wf.Config.Name,
+				"rpc":      trigger.Config.RPC,
+			})
+		}
+	}
+
+	schema, err := grpc.NewSchema(rpcs)
+	if err != nil {
+		return fmt.Errorf("grpc: %w", err)
+	}
+	srv := grpc.NewServer(schema, maxRequestBodySize)
+	srv.Handle(grpc.ExecuteMethod, func(r *http.Request, fields map[string]any) (int, []byte, error) {
+		name, _ := fields["workflow"].(string)
+		call, ok := handlers[name]
+		if !ok {
+			return 0, nil, status.Errorf(codes.NotFound, "workflow %q is not exposed over gRPC", name)
+		}
+		params, _ := fields["params"].(map[string]any)
+		return call(r, params)
+	})
+	for _, method := range schema.Methods() {
+		srv.Handle(method, rpcHandlers[method])
+	}
+	if grpcCfg.Reflection {
+		if err := srv.EnableReflection(); err != nil {
+			return fmt.Errorf("grpc reflection: %w", err)
+		}
+	}
+
+	host := grpcCfg.Host
+	if host == "" {
+		host = s.config.Server.Host
+	}
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true) // Answered with an error, so misdirected HTTP/1 clients see why
+	protocols.SetUnencryptedHTTP2(true)
+	s.grpcServer = &http.Server{
+		Addr:        fmt.Sprintf("%s:%d", host, grpcCfg.Port),
+		Handler:     s.recoveryMiddleware(srv),
+		Protocols:   protocols,
+		ReadTimeout: httpReadTimeout,
+		IdleTimeout: httpIdleTimeout,
+	}
+
+	logging.Info("grpc_server_configured", map[string]any{
+		"host":       host,
+		"port":       grpcCfg.Port,
+		"methods":    len(rpcs) + 1,
+		"reflection": grpcCfg.Reflection,
+	})
+	return nil
+}
+
+// grpcCall returns the handler running a workflow for a gRPC call, recording
+// metrics per call. grpc-go runs calls on their own goroutines, out of reach of
+// recoveryMiddleware, so a panic is recovered here and ends the call as Internal.
+func (s *Server) grpcCall(workflowName string, h *workflow.GRPCHandler, inFlight *atomic.Int64) grpc.Handler {
+	return func(r *http.Request, params map[string]any) (statusCode int, body []byte, err error) {
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+		defer func() {
+			if v := recover(); v != nil {
+				errtrack.ReportPanic(v, errtrack.Event{
+					Tags: map[string]string{"method": "GRPC", "path": r.URL.Path},
+				})
+				logging.Error("panic_recovered", map[string]any{
+					"error":  fmt.Sprintf("%v", v),
+					"path":   r.URL.Path,
+					"method": "GRPC",
+					"stack":  string(debug.Stack()),
+				})
+				err = status.Error(codes.Internal, "internal server error")
+			}
+		}()
+
+		start := time.Now()
+		ctx, acc := metrics.NewRequestContext(r.Context())
+		statusCode, body = h.Call(r.WithContext(ctx), params)
+		metrics.Record(metrics.RequestMetrics{
+			Endpoint:      workflowName,
+			QueryName:     acc.QueryName,
+			Database:      acc.Database,
+			Method:        "GRPC",
+			TotalDuration: time.Since(start),
+			QueryDuration: acc.QueryDuration,
+			RowCount:      acc.RowCount,
+			StatusCode:    statusCode,
+			Error:         acc.Error,
+			ErrorType:     acc.ErrorType,
+			ErrorCode:     acc.ErrorCode,
+		})
+		return statusCode, body, nil
+	}
+}
+
+// setupAdminRoutes registers the /_/ admin endpoints (protected by admin_auth when configured)
+func (s *Server) setupAdminRoutes(mux *http.ServeMux) {
+	// Metrics endpoints
+	mux.HandleFunc("/_/metrics.json", s.metricsJSONHandler)  // Human-readable JSON metrics
+	mux.HandleFunc("/_/metrics", s.metricsPrometheusHandler) // Prometheus format
+
+	// OpenAPI spec endpoint
+	mux.HandleFunc("/_/openapi.json", s.openAPIHandler)
+
+	// Runtime config endpoint
+	mux.HandleFunc("/_/config/loglevel", s.logLevelHandler)
+
+	// Cache management endpoint
+	mux.HandleFunc("/_/cache/clear", s.cacheClearHandler)
+	mux.HandleFunc("/_/cache/invalidate", s.cacheInvalidateHandler)
+
+	// Rate limit observability and management endpoints
+	mux.HandleFunc("/_/ratelimits", s.rateLimitsHandler)
+	mux.HandleFunc("/_/ratelimits/reset", s.rateLimitsResetHandler)
+
+	// Live gauges for lightweight dashboards
+	mux.HandleFunc("/_/stats", s.statsHandler)
+
+	// Failure rate and latency alert states
+	mux.HandleFunc("/_/alerts", s.alertsHandler)
+
+	// Runtime database registration (requires server.database_state_file)
+	mux.HandleFunc("/_/databases", s.databasesHandler)
+	mux.HandleFunc("/_/databases/", s.databaseHandler) // DELETE /_/databases/{name}
+
+	// Running workflow definitions, and validation of candidates before a deploy
+	mux.HandleFunc("/_/workflows", s.workflowsHandler)
+	mux.HandleFunc("/_/workflows/validate", s.workflowsValidateHandler)
+}
+
+func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Check each database individually
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+	dbResults := s.dbManager.Ping(ctx)
+
+	// Build per-database status and count healthy/unhealthy
+	databases := make(map[string]string)
+	healthyCount := 0
+	totalCount := 0
+	for name, err := range dbResults {
+		totalCount++
+		if err != nil {
+			databases[name] = "disconnected"
+		} else {
+			databases[name] = "connected"
+			healthyCount++
+		}
+	}
+
+	// Determine overall status:
+	// - "healthy" = all DBs connected
+	// - "degraded" = some DBs connected, some disconnected
+	// - "unhealthy" = all DBs disconnected
+	// - "warming" = all DBs connected, cache preload still running
+	status := "healthy"
+	if healthyCount == 0 && totalCount > 0 {
+		status = "unhealthy"
+	} else if healthyCount < totalCount {
+		status = "degraded"
+	} else if s.cacheWarming.Load() {
+		status = "warming"
+	}
+
+	// Always return 200 - clients should parse the status field
+	// This avoids confusion with proxy/middleware 503s
+	writeJSON(w, healthResponse{
+		Status:    status,
+		Databases: databases,
+		Uptime:    time.Since(s.startTime()).String(),
+	})
+}
+
+// dbHealthHandler handles per-database health checks: /_/health/{dbname}
+func (s *Server) dbHealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract database name from path: /_/health/{dbname}
+	dbName := strings.TrimPrefix(r.URL.Path, "/_/health/")
+	if dbName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, errorResponse{
+			Error: "database name required: /_/health/{dbname}",
+		})
+		return
+	}
+
+	// Get the database driver
+	driver, err := s.dbManager.Get(dbName)
+	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		writeJSON(w, errorResponse{
 			Error: "database not found: " + dbName,
@@ -579,6 +1275,7 @@ func (s *Server) dbHealthHandler(w http.ResponseWriter, r *http.Request) {
 		Status:   status,
 		Type:     driver.Type(),
 		ReadOnly: driver.IsReadOnly(),
+		Pool:     newDBPoolStats(driver.PoolStats()),
 	})
 }
 
@@ -607,14 +1304,35 @@ func (s *Server) metricsPrometheusHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Running/in-use counts change per request, so refresh them at scrape time
+	s.updateConcurrencyGauges()
+
 	// Use promhttp handler with our custom registry
-	// DisableCompression: true because our gzip middleware handles compression
+	// DisableCompression: true because our compression middleware handles it
 	promhttp.HandlerFor(registry, promhttp.HandlerOpts{
 		EnableOpenMetrics:  true,
 		DisableCompression: true,
 	}).ServeHTTP(w, r)
 }
 
+// updateConcurrencyGauges copies running workflow and max_concurrent usage into Prometheus gauges
+func (s *Server) updateConcurrencyGauges() {
+	if s.workflowExecutor != nil {
+		for name, running := range s.workflowExecutor.RunningWorkflows() {
+			metrics.UpdateWorkflowRunning(name, running)
+		}
+		for name, st := range s.workflowExecutor.ConcurrencyStats() {
+			metrics.UpdateConcurrency("workflow", name, st.InUse, st.Waiting)
+		}
+		if st := s.workflowExecutor.WorkerPoolStats(); st != nil {
+			metrics.UpdateWorkerPool(st.Busy, st.Queued)
+		}
+	}
+	for name, st := range s.dbManager.ConcurrencyStats() {
+		metrics.UpdateConcurrency("database", name, st.InUse, st.Waiting)
+	}
+}
+
 func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*") // Allow Swagger UI from anywhere
@@ -700,6 +1418,48 @@ func (s *Server) cacheClearHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// cacheInvalidateHandler removes all cache entries carrying a tag (?tag=...)
+func (s *Server) cacheInvalidateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only allow POST/DELETE methods
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeJSON(w, errorResponse{
+			Error: "method not allowed, use POST or DELETE",
+		})
+		return
+	}
+
+	if s.cache == nil {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, errorResponse{
+			Error: "cache not enabled",
+		})
+		return
+	}
+
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, errorResponse{
+			Error: "tag parameter is required",
+		})
+		return
+	}
+
+	invalidated := s.cache.InvalidateTag(tag)
+	logging.Info("cache_tag_invalidated", map[string]any{
+		"tag":         tag,
+		"invalidated": invalidated,
+	})
+	writeJSON(w, cacheInvalidateResponse{
+		Status:      "ok",
+		Tag:         tag,
+		Invalidated: invalidated,
+	})
+}
+
 // rateLimitsHandler returns rate limit pool status and metrics
 func (s *Server) rateLimitsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -719,6 +1479,10 @@ func (s *Server) rateLimitsHandler(w http.ResponseWriter, r *http.Request) {
 		Name              string `json:"name"`
 		RequestsPerSecond int    `json:"requests_per_second"`
 		Burst             int    `json:"burst"`
+		Algorithm         string `json:"algorithm"`
+		Window            string `json:"window,omitempty"`     // Quota pools only
+		Limit             int    `json:"limit,omitempty"`      // Quota pools only
+		Persistent        bool   `json:"persistent,omitempty"` // Quota counters survive restarts
 		Allowed           int64  `json:"allowed"`
 		Denied            int64  `json:"denied"`
 		ActiveBuckets     int64  `json:"active_buckets"`
@@ -750,6 +1514,10 @@ func (s *Server) rateLimitsHandler(w http.ResponseWriter, r *http.Request) {
 			Name:              name,
 			RequestsPerSecond: pool.RequestsPerSecond(),
 			Burst:             pool.Burst(),
+			Algorithm:         pool.Algorithm(),
+			Window:            pool.Window(),
+			Limit:             pool.Limit(),
+			Persistent:        pool.Persistent(),
 			Allowed:           poolMetrics.Allowed,
 			Denied:            poolMetrics.Denied,
 			ActiveBuckets:     poolMetrics.ActiveBuckets,
@@ -759,6 +1527,213 @@ func (s *Server) rateLimitsHandler(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, resp)
 }
 
+// statsResponse is a point-in-time view of live gauges
+type statsResponse struct {
+	Timestamp        string                    `json:"timestamp"`
+	InFlightRequests map[string]int64          `json:"inflight_requests"` // Per route ("METHOD /path")
+	RunningWorkflows map[string]int64          `json:"running_workflows"` // Per workflow name (HTTP and cron)
+	Databases        map[string]dbPoolStats    `json:"databases"`
+	Cache            *cacheStats               `json:"cache,omitempty"`
+	RateLimitBuckets map[string]int64          `json:"rate_limit_buckets,omitempty"` // Active buckets per pool
+	Concurrency      *concurrencyStats         `json:"concurrency,omitempty"`        // Only resources with max_concurrent
+	Tenants          map[string]db.TenantStats `json:"tenants,omitempty"`            // Open connections per tenant-routed database
+	Runtime          runtimeStats              `json:"runtime"`
+	Cron             *cronStats                `json:"cron,omitempty"`          // Only with cron triggers
+	LoadShedding     *loadshed.Stats           `json:"load_shedding,omitempty"` // Only with server.load_shedding
+	WorkerPool       *concurrency.PoolStats    `json:"worker_pool,omitempty"`   // Only with server.worker_pool
+}
+
+// runtimeStats is the process health pprof would otherwise be needed for
+type runtimeStats struct {
+	Goroutines         int    `json:"goroutines"`
+	HeapAllocBytes     uint64 `json:"heap_alloc_bytes"`
+	HeapInuseBytes     uint64 `json:"heap_inuse_bytes"`
+	HeapSysBytes       uint64 `json:"heap_sys_bytes"`
+	HeapObjects        uint64 `json:"heap_objects"`
+	NextGCBytes        uint64 `json:"next_gc_bytes"` // Heap size that triggers the next GC
+	GCRuns             uint32 `json:"gc_runs"`
+	GCPauseTotalNs     uint64 `json:"gc_pause_total_ns"`
+	GCLastPauseNs      uint64 `json:"gc_last_pause_ns"`
+	GCMaxRecentPauseNs uint64 `json:"gc_max_recent_pause_ns"` // Longest of the last 256 pauses
+}
+
+func newRuntimeStats() runtimeStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	stats := runtimeStats{
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: ms.HeapAlloc,
+		HeapInuseBytes: ms.HeapInuse,
+		HeapSysBytes:   ms.HeapSys,
+		HeapObjects:    ms.HeapObjects,
+		NextGCBytes:    ms.NextGC,
+		GCRuns:         ms.NumGC,
+		GCPauseTotalNs: ms.PauseTotalNs,
+	}
+	if ms.NumGC > 0 {
+		stats.GCLastPauseNs = ms.PauseNs[(ms.NumGC+255)%256]
+	}
+	for i := range min(ms.NumGC, 256) {
+		stats.GCMaxRecentPauseNs = max(stats.GCMaxRecentPauseNs, ms.PauseNs[i])
+	}
+	return stats
+}
+
+// cronStats describes the cron scheduler. Scheduled runs don't wait for each
+// other, so the executions still running are its queue.
+type cronStats struct {
+	Jobs    int    `json:"jobs"`
+	Running int64  `json:"running"`            // Cron executions in progress
+	NextRun string `json:"next_run,omitempty"` // Earliest scheduled run
+}
+
+type concurrencyStats struct {
+	Workflows map[string]concurrency.Stats `json:"workflows,omitempty"`
+	Databases map[string]concurrency.Stats `json:"databases,omitempty"`
+}
+
+type dbPoolStats struct {
+	Open           int     `json:"open"`
+	Idle           int     `json:"idle"`
+	InUse          int     `json:"in_use"`
+	MaxOpen        int     `json:"max_open"`
+	Utilization    float64 `json:"utilization"`      // in_use / max_open (0 if unlimited)
+	WaitCount      int64   `json:"wait_count"`       // Queries that waited for a free connection
+	WaitDurationMs int64   `json:"wait_duration_ms"` // Total time spent waiting
+}
+
+func newDBPoolStats(ps db.PoolStats) dbPoolStats {
+	stats := dbPoolStats{
+		Open:           ps.OpenConnections,
+		Idle:           ps.IdleConnections,
+		InUse:          ps.InUse,
+		MaxOpen:        ps.MaxOpenConnections,
+		WaitCount:      ps.WaitCount,
+		WaitDurationMs: ps.WaitDuration.Milliseconds(),
+	}
+	if ps.MaxOpenConnections > 0 {
+		stats.Utilization = float64(ps.InUse) / float64(ps.MaxOpenConnections)
+	}
+	return stats
+}
+
+type cacheStats struct {
+	SizeBytes    int64   `json:"size_bytes"`
+	MaxSizeBytes int64   `json:"max_size_bytes"`
+	Keys         int64   `json:"keys"`
+	Utilization  float64 `json:"utilization"` // size_bytes / max_size_bytes
+
+	Disk        *cache.DiskSnapshot        `json:"disk,omitempty"`
+	Compression *cache.CompressionSnapshot `json:"compression,omitempty"`
+}
+
+// statsHandler returns live gauges as a single JSON document (no Prometheus needed)
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	resp := statsResponse{
+		Timestamp:        time.Now().UTC().Format(time.RFC3339),
+		InFlightRequests: make(map[string]int64, len(s.inFlight)),
+		RunningWorkflows: make(map[string]int64),
+		Databases:        make(map[string]dbPoolStats),
+	}
+
+	for route, counter := range s.inFlight {
+		resp.InFlightRequests[route] = counter.Load()
+	}
+
+	if s.workflowExecutor != nil {
+		resp.RunningWorkflows = s.workflowExecutor.RunningWorkflows()
+	}
+
+	for _, name := range s.dbManager.Names() {
+		driver, err := s.dbManager.Get(name)
+		if err != nil {
+			continue
+		}
+		resp.Databases[name] = newDBPoolStats(driver.PoolStats())
+	}
+
+	if s.cache != nil {
+		snap := s.cache.GetSnapshot()
+		resp.Cache = &cacheStats{
+			SizeBytes:    snap.TotalSizeBytes,
+			MaxSizeBytes: snap.MaxSizeBytes,
+			Keys:         snap.TotalKeys,
+			Disk:         snap.Disk,
+			Compression:  snap.Compression,
+		}
+		if snap.MaxSizeBytes > 0 {
+			resp.Cache.Utilization = float64(snap.TotalSizeBytes) / float64(snap.MaxSizeBytes)
+		}
+	}
+
+	if s.rateLimiter != nil {
+		snap := s.rateLimiter.Snapshot()
+		resp.RateLimitBuckets = make(map[string]int64, len(snap.Pools))
+		for pool, pm := range snap.Pools {
+			resp.RateLimitBuckets[pool] = pm.ActiveBuckets
+		}
+	}
+
+	conc := &concurrencyStats{Databases: s.dbManager.ConcurrencyStats()}
+	if s.workflowExecutor != nil {
+		conc.Workflows = s.workflowExecutor.ConcurrencyStats()
+	}
+	if len(conc.Workflows) > 0 || len(conc.Databases) > 0 {
+		resp.Concurrency = conc
+	}
+	if tenants := s.dbManager.TenantStats(); len(tenants) > 0 {
+		resp.Tenants = tenants
+	}
+
+	resp.Runtime = newRuntimeStats()
+	if s.loadShedder != nil {
+		stats := s.loadShedder.Stats()
+		resp.LoadShedding = &stats
+	}
+	if s.workflowExecutor != nil {
+		resp.WorkerPool = s.workflowExecutor.WorkerPoolStats()
+	}
+	if s.cron != nil {
+		entries := s.cron.Entries()
+		resp.Cron = &cronStats{Jobs: len(entries), Running: s.cronActive.Load()}
+		var next time.Time
+		for _, e := range entries {
+			if !e.Next.IsZero() && (next.IsZero() || e.Next.Before(next)) {
+				next = e.Next
+			}
+		}
+		if !next.IsZero() {
+			resp.Cron.NextRun = next.UTC().Format(time.RFC3339)
+		}
+	}
+
+	writeJSON(w, resp)
+}
+
+// alertsResponse lists the alert states at their last evaluation
+type alertsResponse struct {
+	Firing int                   `json:"firing"`
+	Alerts []workflow.AlertState `json:"alerts"`
+}
+
+// alertsHandler returns the state of each configured alert
+func (s *Server) alertsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	resp := alertsResponse{Alerts: []workflow.AlertState{}}
+	if s.alerts != nil {
+		resp.Alerts = s.alerts.States()
+	}
+	for _, a := range resp.Alerts {
+		if a.State == workflow.AlertStateFiring {
+			resp.Firing++
+		}
+	}
+	writeJSON(w, resp)
+}
+
 // rateLimitsResetHandler resets rate limit buckets for test isolation
 func (s *Server) rateLimitsResetHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -865,23 +1840,418 @@ func (s *Server) rateLimitsResetHandler(w http.ResponseWriter, r *http.Request)
 	})
 }
 
-func (s *Server) startTime() time.Time {
-	return s.createdAt
+// databaseInfo describes a database in /_/databases responses
+type databaseInfo struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	ReadOnly     bool   `json:"readonly"`
+	Registered   bool   `json:"registered"` // Added through /_/databases rather than the config file
+	TenantRouted bool   `json:"tenant_routed"`
 }
 
-func (s *Server) listEndpointsHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
+type databaseResponse struct {
+	Status   string       `json:"status"`
+	Database databaseInfo `json:"database"`
+}
+
+func newDatabaseInfo(dbCfg config.DatabaseConfig) databaseInfo {
+	return databaseInfo{
+		Name:         dbCfg.Name,
+		Type:         dbCfg.Type,
+		ReadOnly:     dbCfg.IsReadOnly(),
+		Registered:   dbCfg.Registered,
+		TenantRouted: dbCfg.TenantRouting != nil,
 	}
+}
 
+// databasesHandler lists databases (GET) and registers new ones (POST) on /_/databases
+func (s *Server) databasesHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	type endpointInfo struct {
-		Name       string                 `json:"name"`
-		Path       string                 `json:"path"`
-		Method     string                 `json:"method"`
-		Parameters []workflow.ParamConfig `json:"parameters,omitempty"`
+	if s.config.Server.DatabaseStateFile == "" {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, errorResponse{
+			Error: "dynamic databases not enabled (set server.database_state_file)",
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.databasesMu.Lock()
+		infos := make([]databaseInfo, 0, len(s.config.Databases))
+		for _, dbCfg := range s.config.Databases {
+			infos = append(infos, newDatabaseInfo(dbCfg))
+		}
+		s.databasesMu.Unlock()
+		writeJSON(w, infos)
+	case http.MethodPost:
+		s.registerDatabase(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeJSON(w, errorResponse{
+			Error: "method not allowed, use GET or POST",
+		})
+	}
+}
+
+// registerDatabase validates and connects to the database in the request body,
+// then persists it to the state file. Nothing changes unless all three succeed.
+func (s *Server) registerDatabase(w http.ResponseWriter, r *http.Request) {
+	// The body uses the config file's field names; JSON is valid YAML
+	var dbCfg config.DatabaseConfig
+	dec := yaml.NewDecoder(r.Body)
+	dec.KnownFields(true)
+	if err := dec.Decode(&dbCfg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, errorResponse{
+			Error: "invalid database config: " + err.Error(),
+		})
+		return
+	}
+
+	s.databasesMu.Lock()
+	defer s.databasesMu.Unlock()
+
+	if slices.ContainsFunc(s.config.Databases, func(d config.DatabaseConfig) bool { return d.Name == dbCfg.Name }) {
+		w.WriteHeader(http.StatusConflict)
+		writeJSON(w, errorResponse{
+			Error: "database already exists: " + dbCfg.Name,
+		})
+		return
+	}
+	if result := validate.Database(s.config, dbCfg); !result.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, errorResponse{
+			Error: strings.Join(result.Errors, "; "),
+		})
+		return
+	}
+
+	dbCfg.Registered = true
+	if err := s.dbManager.Add(dbCfg); err != nil {
+		logging.Warn("database_register_failed", map[string]any{
+			"database": dbCfg.Name,
+			"error":    err.Error(),
+		})
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		writeJSON(w, errorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	databases := append(slices.Clone(s.config.Databases), dbCfg)
+	if err := s.saveDatabaseState(databases); err != nil {
+		_ = s.dbManager.Remove(dbCfg.Name)
+		logging.Error("database_state_save_failed", map[string]any{
+			"database": dbCfg.Name,
+			"error":    err.Error(),
+		})
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, errorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	s.config.Databases = databases
+
+	logging.Info("database_registered", map[string]any{
+		"database":      dbCfg.Name,
+		"type":          dbCfg.Type,
+		"readonly":      dbCfg.IsReadOnly(),
+		"tenant_routed": dbCfg.TenantRouting != nil,
+	})
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, databaseResponse{
+		Status:   "ok",
+		Database: newDatabaseInfo(dbCfg),
+	})
+}
+
+// databaseHandler removes a registered database: DELETE /_/databases/{name}.
+// Databases from the config file and databases still in use can't be removed.
+func (s *Server) databaseHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.config.Server.DatabaseStateFile == "" {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, errorResponse{
+			Error: "dynamic databases not enabled (set server.database_state_file)",
+		})
+		return
+	}
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeJSON(w, errorResponse{
+			Error: "method not allowed, use DELETE",
+		})
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/_/databases/")
+	s.databasesMu.Lock()
+	defer s.databasesMu.Unlock()
+
+	i := slices.IndexFunc(s.config.Databases, func(d config.DatabaseConfig) bool { return d.Name == name })
+	if i < 0 {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, errorResponse{
+			Error: "database not found: " + name,
+		})
+		return
+	}
+	dbCfg := s.config.Databases[i]
+	if !dbCfg.Registered {
+		w.WriteHeader(http.StatusConflict)
+		writeJSON(w, errorResponse{
+			Error: "database " + name + " is defined in the config file",
+		})
+		return
+	}
+	if user := s.databaseUser(name); user != "" {
+		w.WriteHeader(http.StatusConflict)
+		writeJSON(w, errorResponse{
+			Error: "database " + name + " is used by " + user,
+		})
+		return
+	}
+
+	// Persist first: if that fails, the database stays fully registered
+	databases := slices.Delete(slices.Clone(s.config.Databases), i, i+1)
+	if err := s.saveDatabaseState(databases); err != nil {
+		logging.Error("database_state_save_failed", map[string]any{
+			"database": name,
+			"error":    err.Error(),
+		})
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, errorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	s.config.Databases = databases
+	if err := s.dbManager.Remove(name); err != nil {
+		logging.Warn("database_close_failed", map[string]any{
+			"database": name,
+			"error":    err.Error(),
+		})
+	}
+
+	logging.Info("database_removed", map[string]any{
+		"database": name,
+	})
+	writeJSON(w, databaseResponse{
+		Status:   "ok",
+		Database: newDatabaseInfo(dbCfg),
+	})
+}
+
+// saveDatabaseState writes the registered databases among databases to the state file
+func (s *Server) saveDatabaseState(databases []config.DatabaseConfig) error {
+	var registered []config.DatabaseConfig
+	for _, dbCfg := range databases {
+		if dbCfg.Registered {
+			registered = append(registered, dbCfg)
+		}
+	}
+	return config.SaveDatabaseState(s.config.Server.DatabaseStateFile, registered)
+}
+
+// workflowDefinitions holds workflow definitions in /_/workflows requests and responses
+type workflowDefinitions struct {
+	Workflows []workflow.WorkflowConfig `yaml:"workflows"`
+}
+
+// workflowsValidateResponse is the result of POST /_/workflows/validate
+type workflowsValidateResponse struct {
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+}
+
+// workflowsHandler exports the running workflow definitions, CRUD workflows
+// included: GET /_/workflows, or ?format=yaml for a config file's workflows
+// section. Values of sensitive headers and the credentials in step URLs are
+// masked.
+func (s *Server) workflowsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeJSON(w, errorResponse{
+			Error: "method not allowed, use GET",
+		})
+		return
+	}
+
+	// The redact rules were checked when the server started
+	redactor, _ := redact.New(s.config.Logging.Redact)
+	defs := workflowDefinitions{Workflows: make([]workflow.WorkflowConfig, len(s.config.Workflows))}
+	for i, wf := range s.config.Workflows {
+		wf.Steps = maskSteps(wf.Steps, redactor)
+		defs.Workflows[i] = wf
+	}
+
+	// Definitions use the config file's field names, in JSON too
+	data, err := yaml.Marshal(defs)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, errorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	if r.URL.Query().Get("format") == "yaml" {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(data)
+		return
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, errorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, doc)
+}
+
+// maskSteps returns a copy of steps, nested ones included, with the values of
+// sensitive headers masked and the credentials in their URLs: userinfo, query
+// values, and the path of notify webhooks
+func maskSteps(steps []workflow.StepConfig, redactor *redact.Redactor) []workflow.StepConfig {
+	if steps == nil {
+		return nil
+	}
+	masked := slices.Clone(steps)
+	for i := range masked {
+		step := &masked[i]
+		if len(step.Headers) > 0 {
+			h := make(http.Header, len(step.Headers))
+			for name, value := range step.Headers {
+				h[name] = []string{value}
+			}
+			step.Headers = redactor.Headers(h)
+		}
+		if step.URL != "" {
+			step.URL = redactor.URL(step.URL, step.IsNotify())
+		}
+		step.Steps = maskSteps(step.Steps, redactor)
+	}
+	return masked
+}
+
+// workflowsValidateHandler validates candidate workflows against the running
+// server: POST /_/workflows/validate with a workflows list, in the shape GET
+// /_/workflows returns (JSON or YAML). Answers 200 when they are valid and 422
+// with their errors when not.
+func (s *Server) workflowsValidateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeJSON(w, errorResponse{
+			Error: "method not allowed, use POST",
+		})
+		return
+	}
+
+	// JSON is valid YAML
+	var defs workflowDefinitions
+	dec := yaml.NewDecoder(r.Body)
+	dec.KnownFields(true)
+	if err := dec.Decode(&defs); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, errorResponse{
+			Error: "invalid workflows: " + err.Error(),
+		})
+		return
+	}
+
+	// Databases registered at run time count; hold them still while validating
+	s.databasesMu.Lock()
+	var result *validate.Result
+	if err := config.PrepareWorkflows(s.config, defs.Workflows); err != nil {
+		result = &validate.Result{Errors: []string{err.Error()}}
+	} else {
+		result = validate.Workflows(s.config, defs.Workflows)
+	}
+	s.databasesMu.Unlock()
+
+	resp := workflowsValidateResponse{
+		Valid:    result.Valid,
+		Errors:   result.Errors,
+		Warnings: result.Warnings,
+	}
+	if resp.Errors == nil {
+		resp.Errors = []string{}
+	}
+	if resp.Warnings == nil {
+		resp.Warnings = []string{}
+	}
+	if !resp.Valid {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	writeJSON(w, resp)
+}
+
+// databaseUser returns a workflow or tenant-routed database that uses the named
+// database, or "" when nothing does. Caller holds s.databasesMu.
+func (s *Server) databaseUser(name string) string {
+	for _, wf := range s.config.Workflows {
+		if stepsUseDatabase(wf.Steps, name) {
+			return "workflow " + wf.Name
+		}
+	}
+	for _, dbCfg := range s.config.Databases {
+		if tr := dbCfg.TenantRouting; tr != nil && tr.Lookup != nil && tr.Lookup.Database == name {
+			return "the tenant lookup of database " + dbCfg.Name
+		}
+	}
+	return ""
+}
+
+// stepsUseDatabase reports whether any step, including nested ones, runs on the named database
+func stepsUseDatabase(steps []workflow.StepConfig, name string) bool {
+	return slices.ContainsFunc(steps, func(step workflow.StepConfig) bool {
+		return step.Database == name || stepsUseDatabase(step.Steps, name)
+	})
+}
+
+func (s *Server) startTime() time.Time {
+	return s.createdAt
+}
+
+func (s *Server) listEndpointsHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeEndpoints(w, r, workflow.MainListener)
+}
+
+// listEndpoints returns the root handler of a listener, listing the workflows it serves
+func (s *Server) listEndpoints(listener string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.writeEndpoints(w, r, listener)
+	}
+}
+
+// writeEndpoints writes the service info and the workflows served on a listener
+func (s *Server) writeEndpoints(w http.ResponseWriter, r *http.Request, listener string) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	type endpointInfo struct {
+		Name       string                 `json:"name"`
+		Path       string                 `json:"path"`
+		Method     string                 `json:"method"`
+		WebSocket  bool                   `json:"websocket,omitempty"`
+		Parameters []workflow.ParamConfig `json:"parameters,omitempty"`
 		TimeoutSec int                    `json:"timeout_sec"`
 	}
 
@@ -894,17 +2264,21 @@ func (s *Server) listEndpointsHandler(w http.ResponseWriter, r *http.Request) {
 	scheduled := make([]scheduledInfo, 0)
 
 	for _, wf := range s.workflows {
+		if !wf.Config.ServedOn(listener) {
+			continue
+		}
 		effectiveTimeout := s.config.Server.DefaultTimeoutSec
 		if wf.Config.TimeoutSec > 0 {
 			effectiveTimeout = wf.Config.TimeoutSec
 		}
 
 		for _, trigger := range wf.Triggers {
-			if trigger.Config.Type == "http" && trigger.Config.Path != "" {
+			if (trigger.Config.Type == "http" || trigger.Config.Type == "websocket") && trigger.Config.Path != "" {
 				endpoints = append(endpoints, endpointInfo{
 					Name:       wf.Config.Name,
 					Path:       trigger.Config.Path,
 					Method:     trigger.Config.Method,
+					WebSocket:  trigger.Config.Type == "websocket",
 					Parameters: trigger.Config.Parameters,
 					TimeoutSec: effectiveTimeout,
 				})
@@ -939,8 +2313,10 @@ func (s *Server) listEndpointsHandler(w http.ResponseWriter, r *http.Request) {
 func (s *Server) initWorkflows(cfg *config.Config) error {
 	// Build validation context
 	databases := make(map[string]bool)
+	databaseTypes := make(map[string]string)
 	for _, dbCfg := range cfg.Databases {
 		databases[dbCfg.Name] = dbCfg.IsReadOnly()
+		databaseTypes[dbCfg.Name] = dbCfg.Type
 	}
 	rateLimitPools := make(map[string]bool)
 	for _, rl := range cfg.RateLimits {
@@ -948,15 +2324,128 @@ func (s *Server) initWorkflows(cfg *config.Config) error {
 	}
 	validationCtx := &workflow.ValidationContext{
 		Databases:      databases,
+		DatabaseTypes:  databaseTypes,
 		RateLimitPools: rateLimitPools,
+		SMTP:           cfg.SMTP != nil,
+		Stores:         make(map[string]bool),
+		SFTPServers:    make(map[string]bool),
+		MQTTBrokers:    make(map[string]bool),
+		Outboxes:       make(map[string]bool),
+	}
+	stores := make(workflowObjectStoreAdapter)
+	for _, sc := range cfg.Storage {
+		client, err := objstore.New(objstore.Config{
+			Endpoint:        sc.Endpoint,
+			Region:          sc.Region,
+			AccessKeyID:     sc.AccessKeyID,
+			SecretAccessKey: sc.SecretAccessKey,
+			SessionToken:    sc.SessionToken,
+			PathStyle:       sc.PathStyle,
+			Timeout:         time.Duration(sc.TimeoutSec) * time.Second,
+		}, http.DefaultClient)
+		if err != nil {
+			return fmt.Errorf("storage %q: %w", sc.Name, err)
+		}
+		stores[sc.Name] = client
+		validationCtx.Stores[sc.Name] = true
+	}
+	s.sftpServers = make(workflowFileUploaderAdapter)
+	for _, sc := range cfg.SFTP {
+		key, err := sc.LoadPrivateKey()
+		if err != nil {
+			return fmt.Errorf("sftp %q: private_key_file: %w", sc.Name, err)
+		}
+		client, err := sftp.New(sftp.Config{
+			Host:                  sc.Host,
+			Port:                  sc.Port,
+			Username:              sc.Username,
+			Password:              sc.Password,
+			PrivateKey:            key,
+			Passphrase:            sc.Passphrase,
+			HostKey:               sc.HostKey,
+			InsecureIgnoreHostKey: sc.InsecureIgnoreHostKey,
+			Timeout:               time.Duration(sc.TimeoutSec) * time.Second,
+			MaxConnections:        sc.MaxConnections,
+		})
+		if err != nil {
+			return fmt.Errorf("sftp %q: %w", sc.Name, err)
+		}
+		s.sftpServers[sc.Name] = client
+		validationCtx.SFTPServers[sc.Name] = true
+	}
+	s.mqttBrokers = make(workflowPublisherAdapter)
+	for _, bc := range cfg.MQTT {
+		name := bc.Name
+		client, err := mqtt.New(mqtt.Config{
+			URL:                bc.URL,
+			ClientID:           bc.ClientID,
+			Username:           bc.Username,
+			Password:           bc.Password,
+			KeepAlive:          time.Duration(bc.KeepAliveSec) * time.Second,
+			Timeout:            time.Duration(bc.TimeoutSec) * time.Second,
+			CleanSession:       bc.CleanSession,
+			InsecureSkipVerify: bc.InsecureSkipVerify,
+			OnMessage: func(ctx context.Context, msg mqtt.Message) {
+				for _, h := range s.mqttHandlers[name] {
+					if h.Matches(msg.Topic) {
+						h.HandleMessage(ctx, msg)
+					}
+				}
+			},
+			OnError: func(err error) {
+				logging.Warn("mqtt_connection_error", map[string]any{
+					"broker": name,
+					"error":  err.Error(),
+				})
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("mqtt %q: %w", bc.Name, err)
+		}
+		s.mqttBrokers[name] = client
+		validationCtx.MQTTBrokers[name] = true
+	}
+	outboxTables := make(map[string]string, len(cfg.Outbox))
+	for _, oc := range cfg.Outbox {
+		outboxTables[oc.Database] = cmp.Or(oc.Table, outbox.DefaultTable)
+		validationCtx.Outboxes[oc.Database] = true
 	}
 
 	// Create DB manager adapter for workflow execution
 	dbAdapter := workflow.NewDBManagerAdapter(func(ctx context.Context, database, sqlQuery string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
-		driver, err := s.dbManager.Get(database)
+		// Enforce the database's statement policy before taking a slot
+		var err error
+		if opts.Proc != nil {
+			err = s.dbManager.Policy(database).CheckProc(opts.Proc.Name)
+		} else {
+			err = s.dbManager.Policy(database).Check(sqlQuery)
+		}
+		if err == nil && len(opts.Outbox) > 0 {
+			err = s.dbManager.Policy(database).Check(db.OutboxInsertSQL(outboxTables[database]))
+		}
 		if err != nil {
+			return nil, fmt.Errorf("database %s: %w", database, err)
+		}
+
+		// Enforce the database's max_concurrent before touching the pool.
+		// Tenant-routed databases have a connection and limit per tenant.
+		var driver db.Driver
+		var release func()
+		if opts.Tenant != "" {
+			driver, release, err = s.dbManager.AcquireTenant(ctx, database, opts.Tenant)
+		} else {
+			if driver, err = s.dbManager.Get(database); err != nil {
+				return nil, err
+			}
+			release, err = s.dbManager.Acquire(ctx, database)
+		}
+		if err != nil {
+			if errors.Is(err, concurrency.ErrLimitReached) {
+				metrics.RecordConcurrencyRejected("database", database)
+			}
 			return nil, err
 		}
+		defer release()
 
 		session := config.SessionConfig{
 			Isolation:        opts.Isolation,
@@ -974,15 +2463,45 @@ func (s *Server) initWorkflows(cfg *config.Config) error {
 			}
 		}
 
-		dbResult, err := driver.Query(ctx, session, sqlQuery, params, hints)
+		if opts.Limit != nil {
+			ctx = db.WithResultLimit(ctx, opts.Limit)
+		}
+		if len(opts.Outbox) > 0 {
+			ctx = db.WithOutbox(ctx, outboxTables[database], opts.Outbox)
+		}
+
+		// Injected faults are handled like errors of the database
+		var dbResult *db.QueryResult
+		if err = s.chaos.Database(ctx, database); err == nil {
+			if opts.Proc != nil {
+				dbResult, err = driver.CallProc(ctx, session, *opts.Proc)
+			} else {
+				if opts.Comment != nil && driver.Config().SQLComments {
+					sqlQuery = opts.Comment.String() + " " + sqlQuery
+				}
+				dbResult, err = driver.Query(ctx, session, sqlQuery, params, hints)
+			}
+		}
 		if err != nil {
+			if opts.Tenant == "" && db.IsConnectionError(err) {
+				s.requestReconnect(database)
+			}
+			if class := db.ErrorClass(err); class != "" {
+				err = &step.ClassifiedError{Class: class, Err: err}
+			}
 			return nil, err
 		}
 
-		// Parse JSON columns if specified
+		// Parse JSON columns if specified (Rows shares its maps with the first result set)
 		if len(opts.JSONColumns) > 0 {
-			if err := parseJSONColumns(dbResult.Rows, opts.JSONColumns); err != nil {
-				return nil, err
+			sets := dbResult.ResultSets
+			if sets == nil {
+				sets = [][]map[string]any{dbResult.Rows}
+			}
+			for _, rows := range sets {
+				if err := parseJSONColumns(rows, opts.JSONColumns); err != nil {
+					return nil, err
+				}
 			}
 		}
 
@@ -994,11 +2513,116 @@ func (s *Server) initWorkflows(cfg *config.Config) error {
 
 	// Create workflow executor with cache (cache may be nil if not enabled)
 	s.workflowExecutor = workflow.NewExecutor(dbAdapter, http.DefaultClient, s.cache, loggerAdapter)
+	if hr := cfg.HTTPRecording; hr != nil {
+		client, err := newRecordingClient(hr)
+		if err != nil {
+			return fmt.Errorf("http_recording: %w", err)
+		}
+		s.workflowExecutor.SetHTTPClient(client)
+		logging.Warn("http_recording_enabled", map[string]any{
+			"mode":     hr.Mode,
+			"cassette": hr.Cassette,
+		})
+	}
+	if cfg.SMTP != nil {
+		s.workflowExecutor.SetMailer(&workflowMailerAdapter{sender: mail.New(mail.Config{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+			TLS:      cfg.SMTP.TLS,
+			Timeout:  time.Duration(cfg.SMTP.TimeoutSec) * time.Second,
+		})})
+	}
+	if len(stores) > 0 {
+		s.workflowExecutor.SetObjectStore(stores)
+	}
+	if len(s.sftpServers) > 0 {
+		s.workflowExecutor.SetFileUploader(s.sftpServers)
+	}
+	if len(s.mqttBrokers) > 0 {
+		s.workflowExecutor.SetMessagePublisher(s.mqttBrokers)
+	}
+	s.workflowExecutor.SetStrictResponses(cfg.Server.StrictResponses)
+	if s.chaos != nil {
+		s.workflowExecutor.SetFaultInjector(s.chaos)
+	}
+
+	// Outbox relays run with the watchers
+	for _, oc := range cfg.Outbox {
+		sink, err := newOutboxSink(oc.Sink)
+		if err != nil {
+			return fmt.Errorf("outbox %q: %w", oc.Database, err)
+		}
+		relayCfg := outbox.Config{
+			Database:     oc.Database,
+			Table:        oc.Table,
+			PollInterval: time.Duration(oc.PollIntervalSec) * time.Second,
+			BatchSize:    oc.BatchSize,
+			Retention:    time.Duration(oc.RetentionHours) * time.Hour,
+		}
+		for _, dbCfg := range cfg.Databases {
+			if dbCfg.Name == oc.Database {
+				relayCfg.DBType = dbCfg.Type
+			}
+		}
+		s.watchers = append(s.watchers, outbox.NewRelay(relayCfg, dbAdapter, sink, loggerAdapter))
+		logging.Info("outbox_relay_added", map[string]any{
+			"database": oc.Database,
+			"table":    outboxTables[oc.Database],
+			"sink":     oc.Sink.Type,
+		})
+	}
+
+	tenantKeys := make(map[string]string)
+	for _, dbCfg := range cfg.Databases {
+		if dbCfg.TenantRouting != nil {
+			tenantKeys[dbCfg.Name] = dbCfg.TenantRouting.Key
+		}
+	}
+	if err := s.workflowExecutor.SetTenantKeys(tenantKeys); err != nil {
+		return err
+	}
+
+	// Alerts are evaluated with the watchers
+	if len(cfg.Alerts) > 0 {
+		monitor, err := workflow.NewAlertMonitor(s.workflowExecutor, cfg.Alerts)
+		if err != nil {
+			return err
+		}
+		s.alerts = monitor
+		s.watchers = append(s.watchers, s.alerts)
+	}
+
+	// Load shedding samples heap usage and queue wait with the watchers
+	if ls := cfg.Server.LoadShedding; ls != nil {
+		s.loadShedder = loadshed.New(loadshed.Config{
+			MaxHeapBytes:  uint64(ls.MaxHeapMB) << 20,
+			MaxInFlight:   int64(ls.MaxInFlight),
+			MaxQueueWait:  time.Duration(ls.MaxQueueWaitMs) * time.Millisecond,
+			RetryAfterSec: ls.RetryAfterSec,
+		}, s.dbQueueWait())
+		s.watchers = append(s.watchers, s.loadShedder)
+		s.workflowExecutor.SetLoadShedder(s.loadShedder)
+	}
+
+	// Executions across all workflows share the worker pool, queued by trigger priority
+	if wp := cfg.Server.WorkerPool; wp != nil {
+		s.workflowExecutor.SetWorkerPool(wp.Workers, wp.QueueSize, time.Duration(wp.QueueTimeoutMs)*time.Millisecond)
+	}
+
+	// Shared templates are parsed once and cloned into each workflow's templates
+	partials, err := workflow.CompilePartials(cfg.Templates)
+	if err != nil {
+		return fmt.Errorf("templates: %w", err)
+	}
 
 	// Validate and compile each workflow
 	s.workflows = make([]*workflow.CompiledWorkflow, 0, len(cfg.Workflows))
 	for _, wfCfg := range cfg.Workflows {
 		wfCfgCopy := wfCfg // Copy to avoid closure issues
+		wfCfgCopy.Partials = partials
 
 		// Validate
 		result := workflow.Validate(&wfCfgCopy, validationCtx)
@@ -1045,10 +2669,15 @@ func (s *Server) initWorkflows(cfg *config.Config) error {
 	routes := make(map[string]string) // "METHOD /path" -> workflow name
 	for _, wf := range s.workflows {
 		for _, trigger := range wf.Triggers {
-			if trigger.Config.Type != "http" {
+			var route string
+			switch trigger.Config.Type {
+			case "http":
+				route = trigger.Config.Method + " " + trigger.Config.Path
+			case "websocket":
+				route = "GET " + trigger.Config.Path
+			default:
 				continue
 			}
-			route := trigger.Config.Method + " " + trigger.Config.Path
 			if existingWorkflow, exists := routes[route]; exists {
 				return fmt.Errorf("route clash: %s is defined in both %q and %q", route, existingWorkflow, wf.Config.Name)
 			}
@@ -1111,15 +2740,141 @@ func (s *Server) addWorkflowCronJobs() error {
 			})
 		}
 	}
-
+
+	return nil
+}
+
+// newOutboxSink creates the sink an outbox relay publishes to
+func newOutboxSink(sc config.OutboxSinkConfig) (outbox.Sink, error) {
+	timeout := time.Duration(sc.TimeoutSec) * time.Second
+	switch sc.Type {
+	case "kafka":
+		producer, err := kafka.New(kafka.Config{
+			Brokers:            sc.Brokers,
+			Username:           sc.Username,
+			Password:           sc.Password,
+			TLS:                sc.TLS,
+			InsecureSkipVerify: sc.InsecureSkipVerify,
+			Timeout:            timeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return outbox.NewKafkaSink(producer, sc.Topic), nil
+	case "amqp":
+		publisher, err := amqp.New(amqp.Config{
+			URL:                sc.URL,
+			Timeout:            timeout,
+			InsecureSkipVerify: sc.InsecureSkipVerify,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return outbox.NewAMQPSink(publisher, sc.Exchange), nil
+	default:
+		return outbox.NewWebhookSink(sc.URL, sc.Headers, timeout, sc.InsecureSkipVerify), nil
+	}
+}
+
+// addWorkflowWatchers creates a watcher for every dbwatch and filewatch trigger
+// and subscribes the mqtt brokers to the topics of mqtt triggers. Table watchers
+// share one watermark file. All start in Start, brokers as watchers too.
+func (s *Server) addWorkflowWatchers() error {
+	var store workflow.WatermarkStore
+	s.mqttHandlers = make(map[string][]*workflow.MQTTHandler)
+	for _, wf := range s.workflows {
+		for _, trigger := range wf.Triggers {
+			if trigger.Config.Type == workflow.TriggerTypeMQTT {
+				broker := trigger.Config.Broker
+				s.mqttHandlers[broker] = append(s.mqttHandlers[broker], workflow.NewMQTTHandler(s.workflowExecutor, wf, trigger, s.config.Variables.Values))
+				for _, filter := range trigger.Config.Topics {
+					s.mqttBrokers[broker].Subscribe(filter, byte(trigger.Config.QoS))
+				}
+				logging.Info("workflow_mqtt_subscribed", map[string]any{
+					"workflow": wf.Config.Name,
+					"broker":   broker,
+					"topics":   trigger.Config.Topics,
+				})
+				continue
+			}
+			if trigger.Config.Type == workflow.TriggerTypeFileWatch {
+				s.watchers = append(s.watchers, workflow.NewFileWatcher(s.workflowExecutor, wf, trigger, s.config.Variables.Values))
+				logging.Info("workflow_filewatch_added", map[string]any{
+					"workflow": wf.Config.Name,
+					"dir":      trigger.Config.Dir,
+					"pattern":  trigger.Config.Pattern,
+				})
+				continue
+			}
+			if trigger.Config.Type != workflow.TriggerTypeDBWatch {
+				continue
+			}
+			if store == nil {
+				fileStore, err := workflow.NewFileWatermarkStore(s.config.Server.DBWatchStateFile)
+				if err != nil {
+					return err
+				}
+				store = fileStore
+			}
+
+			dbType := ""
+			for _, dbCfg := range s.config.Databases {
+				if dbCfg.Name == trigger.Config.Database {
+					dbType = dbCfg.Type
+				}
+			}
+			s.watchers = append(s.watchers, workflow.NewDBWatcher(s.workflowExecutor, wf, trigger, dbType, store, s.config.Variables.Values))
+
+			logging.Info("workflow_dbwatch_added", map[string]any{
+				"workflow": wf.Config.Name,
+				"database": trigger.Config.Database,
+				"table":    trigger.Config.Table,
+				"column":   trigger.Config.Column,
+			})
+		}
+	}
+	for _, client := range s.mqttBrokers {
+		s.watchers = append(s.watchers, client)
+	}
 	return nil
 }
 
+// dbQueueWait returns the average time queries waited for a pooled connection,
+// across all databases, since its previous call. A reconnect starts a database's
+// counts over, which reads as no wait for that call.
+func (s *Server) dbQueueWait() loadshed.QueueWaitFunc {
+	var lastCount int64
+	var lastDuration time.Duration
+	return func() time.Duration {
+		var count int64
+		var duration time.Duration
+		for _, name := range s.dbManager.Names() {
+			driver, err := s.dbManager.Get(name)
+			if err != nil {
+				continue
+			}
+			ps := driver.PoolStats()
+			count += ps.WaitCount
+			duration += ps.WaitDuration
+		}
+		waits, waited := count-lastCount, duration-lastDuration
+		lastCount, lastDuration = count, duration
+		if waits <= 0 || waited <= 0 {
+			return 0
+		}
+		return waited / time.Duration(waits)
+	}
+}
+
 // executeWorkflowCron executes a workflow for a cron trigger
 func (s *Server) executeWorkflowCron(wf *workflow.CompiledWorkflow, trigger *workflow.CompiledTrigger) {
+	s.cronActive.Add(1)
+	defer s.cronActive.Add(-1)
+
 	// Recover from panics to prevent crashing the cron goroutine
 	defer func() {
 		if r := recover(); r != nil {
+			errtrack.ReportPanic(r, errtrack.Event{Workflow: wf.Config.Name, Tags: map[string]string{"trigger": "cron"}})
 			logging.Error("workflow_cron_panic", map[string]any{
 				"workflow": wf.Config.Name,
 				"panic":    fmt.Sprintf("%v", r),
@@ -1133,6 +2888,8 @@ func (s *Server) executeWorkflowCron(wf *workflow.CompiledWorkflow, trigger *wor
 	// Build trigger data for cron execution
 	triggerData := &workflow.TriggerData{
 		Type:         "cron",
+		Lang:         workflow.DefaultLanguage(),
+		Priority:     trigger.Config.Priority,
 		Params:       make(map[string]any),
 		CronExpr:     trigger.Config.Schedule,
 		ScheduleTime: time.Now(),
@@ -1226,11 +2983,21 @@ func (s *Server) metricsMiddleware(workflowName, method string, next http.Handle
 			StatusCode:    sw.status,
 			Error:         acc.Error,
 			ErrorType:     acc.ErrorType,
-			CacheHit:      sw.Header().Get("X-Cache") == "HIT",
+			ErrorCode:     acc.ErrorCode,
+			CacheHit:      sw.Header().Get("X-Cache") == "HIT" || sw.Header().Get("X-Cache") == "STALE",
 		})
 	})
 }
 
+// trackInFlight counts requests currently being served by next
+func trackInFlight(counter *atomic.Int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter.Add(1)
+		defer counter.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
 type statusWriter struct {
 	http.ResponseWriter
 	status int
@@ -1309,6 +3076,10 @@ func (s *Server) recoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
+				errtrack.ReportPanic(err, errtrack.Event{
+					RequestID: w.Header().Get("X-Request-ID"),
+					Tags:      map[string]string{"method": r.Method, "path": r.URL.Path},
+				})
 				stack := debug.Stack()
 				logging.Error("panic_recovered", map[string]any{
 					"error":  fmt.Sprintf("%v", err),
@@ -1340,63 +3111,115 @@ func (s *Server) bodySizeLimitMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// gzipResponseWriter wraps http.ResponseWriter with gzip compression
-type gzipResponseWriter struct {
-	io.Writer
-	http.ResponseWriter
+// adminAuthMiddleware requires admin credentials for /_/ endpoints when server.admin_auth is configured.
+// Health endpoints are exempt so load balancer probes keep working.
+func (s *Server) adminAuthMiddleware(next http.Handler) http.Handler {
+	auth := s.config.Server.AdminAuth
+	if auth == nil {
+		return next
+	}
+
+	challenge := `Bearer realm="sql-proxy"`
+	if auth.Username != "" {
+		challenge = `Basic realm="sql-proxy"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdminPath(r.URL.Path) || adminAuthorized(auth, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logging.Warn("admin_auth_failed", map[string]any{
+			"path":        r.URL.Path,
+			"method":      r.Method,
+			"remote_addr": r.RemoteAddr,
+		})
+
+		w.Header().Set("WWW-Authenticate", challenge)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		writeJSON(w, errorResponse{
+			Success: false,
+			Error:   "unauthorized",
+		})
+	})
+}
+
+// isAdminPath returns true for /_/ paths that require admin auth (everything except health)
+func isAdminPath(path string) bool {
+	if !strings.HasPrefix(path, "/_/") {
+		return false
+	}
+	return path != "/_/health" && !strings.HasPrefix(path, "/_/health/")
 }
 
-func (grw *gzipResponseWriter) Write(b []byte) (int, error) {
-	return grw.Writer.Write(b)
+// adminAuthorized checks the request's bearer token or basic credentials in constant time
+func adminAuthorized(auth *config.AdminAuthConfig, r *http.Request) bool {
+	if auth.Token != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureEqual(token, auth.Token) {
+			return true
+		}
+	}
+	if auth.Username != "" {
+		if user, pass, ok := r.BasicAuth(); ok {
+			// Evaluate both comparisons to avoid leaking which one failed
+			userOK := secureEqual(user, auth.Username)
+			passOK := secureEqual(pass, auth.Password)
+			return userOK && passOK
+		}
+	}
+	return false
 }
 
-// gzip writer pool to reduce allocations
-var gzipWriterPool = sync.Pool{
-	New: func() any {
-		return gzip.NewWriter(io.Discard)
-	},
+// secureEqual compares two strings in constant time
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
 }
 
-// gzipMiddleware compresses responses for clients that accept gzip
-func (s *Server) gzipMiddleware(next http.Handler) http.Handler {
+// compressMiddleware compresses responses with the encoding the client prefers
+func (s *Server) compressMiddleware(next http.Handler) http.Handler {
+	compressed := s.compressor.Handler(next)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Check if client accepts gzip
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		// Websocket upgrades take over the connection
+		if websocket.IsUpgrade(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
-
-		// Get a gzip writer from pool
-		gz := gzipWriterPool.Get().(*gzip.Writer)
-		gz.Reset(w)
-		defer func() {
-			// Close flushes the buffer; only return to pool if successful
-			if err := gz.Close(); err != nil {
-				logging.Debug("gzip_close_error", map[string]any{
-					"error": err.Error(),
-					"path":  r.URL.Path,
-				})
-				return // Don't put broken writer back in pool
-			}
-			gzipWriterPool.Put(gz)
-		}()
-
-		w.Header().Set("Content-Encoding", "gzip")
-		w.Header().Del("Content-Length") // Length changes with compression
-
-		grw := &gzipResponseWriter{Writer: gz, ResponseWriter: w}
-		next.ServeHTTP(grw, r)
+		compressed.ServeHTTP(w, r)
 	})
 }
 
 // Start begins listening for HTTP requests and starts the cron scheduler
 func (s *Server) Start() error {
-	// Start cron scheduler if configured
-	if s.cron != nil {
-		s.cron.Start()
-		logging.Info("cron_scheduler_started", map[string]any{
-			"jobs": len(s.cron.Entries()),
-		})
+	// Sockets passed by systemd socket activation, or by the process this one
+	// upgrades, replace the configured addresses
+	sockets, err := inheritedSockets()
+	if err != nil {
+		return err
+	}
+	if instance := s.config.Server.Instance; instance != "" {
+		sockets = instanceSockets(sockets, instance)
+	}
+	activated, err := s.assignSockets(sockets)
+	if err != nil {
+		return err
+	}
+
+	var ctx context.Context
+	ctx, s.watchCancel = context.WithCancel(context.Background())
+	if s.watchdog != nil {
+		s.watchWG.Add(1)
+		go func() {
+			defer s.watchWG.Done()
+			s.watchdog.Run(ctx)
+		}()
+	}
+
+	// During an upgrade the old process runs the cron schedule and watchers until it exits
+	upgrading := upgrade.Upgrading()
+	if !upgrading {
+		s.startBackground(ctx)
 	}
 
 	// Start debug server if configured on separate port
@@ -1405,7 +3228,7 @@ func (s *Server) Start() error {
 			logging.Info("debug_server_starting", map[string]any{
 				"addr": s.debugServer.Addr,
 			})
-			if err := s.debugServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			if err := s.listenAndServe("debug", s.debugServer, activated["debug"]); err != nil && err != http.ErrServerClosed {
 				logging.Error("debug_server_error", map[string]any{
 					"error": err.Error(),
 				})
@@ -1413,15 +3236,296 @@ func (s *Server) Start() error {
 		}()
 	}
 
+	// Start admin server if configured on separate port
+	if s.adminServer != nil {
+		go func() {
+			logging.Info("admin_server_starting", map[string]any{
+				"addr": s.adminServer.Addr,
+			})
+			if err := s.listenAndServe("admin", s.adminServer, activated["admin"]); err != nil && err != http.ErrServerClosed {
+				logging.Error("admin_server_error", map[string]any{
+					"error": err.Error(),
+				})
+			}
+		}()
+	}
+
+	// Start additional listeners
+	for _, l := range s.listeners {
+		go func() {
+			logging.Info("listener_starting", map[string]any{
+				"name": l.name,
+				"addr": l.server.Addr,
+			})
+			if err := s.listenAndServe(l.name, l.server, activated[l.name]); err != nil && err != http.ErrServerClosed {
+				logging.Error("listener_error", map[string]any{
+					"name":  l.name,
+					"error": err.Error(),
+				})
+			}
+		}()
+	}
+
+	// Start gRPC server if configured
+	if s.grpcServer != nil {
+		go func() {
+			logging.Info("grpc_server_starting", map[string]any{
+				"addr": s.grpcServer.Addr,
+			})
+			if err := s.listenAndServe("grpc", s.grpcServer, activated["grpc"]); err != nil && err != http.ErrServerClosed {
+				logging.Error("grpc_server_error", map[string]any{
+					"error": err.Error(),
+				})
+			}
+		}()
+	}
+
+	ln := activated[workflow.MainListener]
+	if ln == nil {
+		ln, err = net.Listen("tcp", s.httpServer.Addr)
+		if err != nil {
+			return err
+		}
+	}
 	logging.Info("server_starting", map[string]any{
-		"addr": s.httpServer.Addr,
+		"addr":      ln.Addr().String(),
+		"tls":       s.config.Server.TLS != nil,
+		"h2c":       s.config.Server.HTTP2 != nil && s.config.Server.HTTP2.H2C,
+		"activated": len(activated) > 0,
+	})
+	s.trackSocket(workflow.MainListener, ln)
+	if upgrading {
+		s.takeOver(ctx)
+	}
+	if _, err := systemd.Notify(systemd.Ready + "\nSTATUS=Serving on " + ln.Addr().String()); err != nil {
+		logging.Warn("systemd_notify_failed", map[string]any{"error": err.Error()})
+	}
+	return s.serve(ln)
+}
+
+// inherited holds the sockets this process was started with, shared by the
+// instances of an instances file
+var inherited struct {
+	once    sync.Once
+	sockets []systemd.Listener
+	err     error
+}
+
+// inheritedSockets returns the sockets passed by systemd socket activation,
+// or else those handed over by the process this one upgrades
+func inheritedSockets() ([]systemd.Listener, error) {
+	inherited.once.Do(func() {
+		inherited.sockets, inherited.err = systemd.Listeners()
+		if inherited.err != nil {
+			inherited.err = fmt.Errorf("systemd socket activation: %w", inherited.err)
+			return
+		}
+		if len(inherited.sockets) == 0 {
+			if inherited.sockets, inherited.err = upgrade.Inherited(); inherited.err != nil {
+				inherited.err = fmt.Errorf("upgrade: %w", inherited.err)
+			}
+		}
 	})
-	return s.httpServer.ListenAndServe()
+	return inherited.sockets, inherited.err
+}
+
+// instanceSockets returns the sockets named "<instance>/<listener>", named
+// after their listener
+func instanceSockets(sockets []systemd.Listener, instance string) []systemd.Listener {
+	var result []systemd.Listener
+	for _, sock := range sockets {
+		if name, ok := strings.CutPrefix(sock.Name, instance+"/"); ok {
+			result = append(result, systemd.Listener{Listener: sock.Listener, Name: name})
+		}
+	}
+	return result
+}
+
+// assignSockets matches sockets passed by systemd to the servers by name: a
+// socket named after a listener in server.listeners, or admin, debug or grpc
+// for those servers, goes to it, and the one socket left is the main listener.
+func (s *Server) assignSockets(sockets []systemd.Listener) (map[string]net.Listener, error) {
+	if len(sockets) == 0 {
+		return nil, nil
+	}
+	named := make(map[string]bool)
+	for _, l := range s.listeners {
+		named[l.name] = true
+	}
+	named["debug"] = s.debugServer != nil
+	named["admin"] = s.adminServer != nil
+	named["grpc"] = s.grpcServer != nil
+
+	assigned := make(map[string]net.Listener, len(sockets))
+	for _, sock := range sockets {
+		name := sock.Name
+		if !named[name] {
+			name = workflow.MainListener
+		}
+		if assigned[name] != nil {
+			for _, ln := range sockets {
+				_ = ln.Close()
+			}
+			return nil, fmt.Errorf("systemd socket activation: more than one socket for the %s listener (name sockets with FileDescriptorName=)", name)
+		}
+		assigned[name] = sock.Listener
+		logging.Info("systemd_socket_activated", map[string]any{
+			"listener": name,
+			"addr":     sock.Addr().String(),
+		})
+	}
+	return assigned, nil
+}
+
+// listenAndServe serves hs on ln, or on a new listener on hs.Addr when ln is nil
+func (s *Server) listenAndServe(name string, hs *http.Server, ln net.Listener) error {
+	if ln == nil {
+		var err error
+		if ln, err = net.Listen("tcp", hs.Addr); err != nil {
+			return err
+		}
+	}
+	s.trackSocket(name, ln)
+	return hs.Serve(ln)
+}
+
+// trackSocket records the socket a listener serves, for Sockets
+func (s *Server) trackSocket(name string, ln net.Listener) {
+	s.socketsMu.Lock()
+	defer s.socketsMu.Unlock()
+	if s.sockets == nil {
+		s.sockets = make(map[string]net.Listener)
+	}
+	s.sockets[name] = ln
+}
+
+// newRecordingClient returns the client of httpcall steps recording to the
+// cassette, or replaying it
+func newRecordingClient(hr *config.HTTPRecordingConfig) (step.HTTPClient, error) {
+	redactor, err := redact.New(hr.Redact)
+	if err != nil {
+		return nil, fmt.Errorf("redact: %w", err)
+	}
+	switch hr.Mode {
+	case "record":
+		return cassette.NewRecorder(http.DefaultClient, hr.Cassette, redactor), nil
+	case "replay":
+		player, err := cassette.NewPlayer(hr.Cassette, redactor)
+		if err != nil {
+			return nil, err
+		}
+		return player, nil
+	default:
+		return nil, fmt.Errorf("unknown mode '%s'", hr.Mode)
+	}
+}
+
+// Handler returns the handler chain of the main listener, to serve requests
+// without listening
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
+// SetHTTPClient replaces the client of httpcall steps and notifications. Call
+// it before serving requests.
+func (s *Server) SetHTTPClient(client step.HTTPClient) {
+	if s.workflowExecutor != nil {
+		s.workflowExecutor.SetHTTPClient(client)
+	}
+}
+
+// Sockets returns the listening sockets by listener name (main, admin, debug,
+// grpc or a server.listeners entry), to hand over to an upgraded process. The
+// names of an instance's sockets are "<instance>/<listener>".
+func (s *Server) Sockets() []systemd.Listener {
+	s.socketsMu.Lock()
+	defer s.socketsMu.Unlock()
+	prefix := ""
+	if s.config.Server.Instance != "" {
+		prefix = s.config.Server.Instance + "/"
+	}
+	result := make([]systemd.Listener, 0, len(s.sockets))
+	for name, ln := range s.sockets {
+		result = append(result, systemd.Listener{Listener: ln, Name: prefix + name})
+	}
+	slices.SortFunc(result, func(a, b systemd.Listener) int { return strings.Compare(a.Name, b.Name) })
+	return result
+}
+
+// startBackground starts the cron scheduler and the watchers
+func (s *Server) startBackground(ctx context.Context) {
+	if s.cron != nil && s.cronCtx.Err() == nil {
+		s.cron.Start()
+		logging.Info("cron_scheduler_started", map[string]any{
+			"jobs": len(s.cron.Entries()),
+		})
+	}
+
+	// Start watchers for dbwatch and filewatch triggers, connect to mqtt brokers, and start outbox relays
+	for _, w := range s.watchers {
+		s.watchWG.Add(1)
+		go func() {
+			defer s.watchWG.Done()
+			w.Run(ctx)
+		}()
+	}
+}
+
+// takeOver completes an upgrade once this process serves requests: systemd
+// tracks this process from now on, the old one drains its requests and exits,
+// and then the cron schedule and watchers start here
+func (s *Server) takeOver(ctx context.Context) {
+	if _, err := systemd.Notify("MAINPID=" + strconv.Itoa(os.Getpid())); err != nil {
+		logging.Warn("systemd_notify_failed", map[string]any{"error": err.Error()})
+	}
+	oldExited, err := upgrade.Ready()
+	if err != nil {
+		logging.Error("upgrade_takeover_failed", map[string]any{"error": err.Error()})
+	}
+	logging.Info("upgrade_taking_over", map[string]any{"old_pid": os.Getppid()})
+
+	s.watchWG.Add(1)
+	go func() {
+		defer s.watchWG.Done()
+		select {
+		case <-oldExited:
+		case <-ctx.Done():
+			return
+		}
+		logging.Info("upgrade_completed", nil)
+		s.startBackground(ctx)
+	}()
+}
+
+// serve accepts connections on the main listener, over TLS when configured
+func (s *Server) serve(ln net.Listener) error {
+	if t := s.config.Server.TLS; t != nil {
+		return s.httpServer.ServeTLS(ln, t.CertFile, t.KeyFile)
+	}
+	return s.httpServer.Serve(ln)
+}
+
+// configureHTTP2 applies server.http2 to a listener. net/http serves HTTP/2
+// over TLS by default; cleartext HTTP/2 (h2c) has to be enabled.
+func configureHTTP2(hs *http.Server, cfg *config.HTTP2Config) {
+	if cfg == nil {
+		return
+	}
+	if cfg.MaxConcurrentStreams > 0 {
+		hs.HTTP2 = &http.HTTP2Config{MaxConcurrentStreams: cfg.MaxConcurrentStreams}
+	}
+	if cfg.H2C {
+		hs.Protocols = new(http.Protocols)
+		hs.Protocols.SetHTTP1(true)
+		hs.Protocols.SetHTTP2(true)
+		hs.Protocols.SetUnencryptedHTTP2(true)
+	}
 }
 
 // Shutdown gracefully stops the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	logging.Info("server_shutting_down", nil)
+	_, _ = systemd.Notify(systemd.Stopping)
 
 	// Cancel cron job context to stop in-flight cron executions
 	if s.cronCancel != nil {
@@ -1435,6 +3539,12 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		logging.Info("cron_scheduler_stopped", nil)
 	}
 
+	// Stop watchers, cancelling their in-flight executions
+	if s.watchCancel != nil {
+		s.watchCancel()
+		s.watchWG.Wait()
+	}
+
 	// Stop health checker
 	if s.healthChecker != nil {
 		s.healthChecker()
@@ -1449,6 +3559,39 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		}
 	}
 
+	// Shutdown admin server if running
+	if s.adminServer != nil {
+		if err := s.adminServer.Shutdown(ctx); err != nil {
+			logging.Error("admin_server_shutdown_error", map[string]any{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	// Shutdown additional listeners
+	for _, l := range s.listeners {
+		if err := l.server.Shutdown(ctx); err != nil {
+			logging.Error("listener_shutdown_error", map[string]any{
+				"name":  l.name,
+				"error": err.Error(),
+			})
+		}
+	}
+
+	// Shutdown gRPC server if running
+	if s.grpcServer != nil {
+		if err := s.grpcServer.Shutdown(ctx); err != nil {
+			logging.Error("grpc_server_shutdown_error", map[string]any{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	// Close websocket connections; the HTTP server does not track upgraded connections
+	for _, h := range s.webSockets {
+		h.Shutdown()
+	}
+
 	// Shutdown HTTP server
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		logging.Error("http_shutdown_error", map[string]any{
@@ -1463,6 +3606,20 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		logging.Info("cache_closed", nil)
 	}
 
+	// Close quota stores
+	if s.rateLimiter != nil {
+		if err := s.rateLimiter.Close(); err != nil {
+			logging.Error("ratelimit_close_error", map[string]any{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	// Close pooled SFTP connections
+	for _, client := range s.sftpServers {
+		_ = client.Close()
+	}
+
 	// Close database connections
 	if err := s.dbManager.Close(); err != nil {
 		logging.Error("database_close_error", map[string]any{
@@ -1471,6 +3628,9 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		return err
 	}
 
+	// Send queued error reports
+	errtrack.Close()
+
 	// Close logging last
 	logging.Info("server_stopped", nil)
 	_ = logging.Close()
@@ -1480,14 +3640,15 @@ func (s *Server) Shutdown(ctx context.Context) error {
 
 // workflowRateLimiterAdapter implements workflow.RateLimiter using ratelimit.Limiter.
 type workflowRateLimiterAdapter struct {
-	limiter    *ratelimit.Limiter
-	ctxBuilder *tmpl.ContextBuilder
+	limiter     *ratelimit.Limiter
+	ctxBuilder  *tmpl.ContextBuilder
+	headerStyle string
 }
 
 // CheckTriggerLimits implements workflow.RateLimiter.
-func (a *workflowRateLimiterAdapter) CheckTriggerLimits(limits []*workflow.CompiledRateLimit, rlCtx *workflow.RateLimitContext) (bool, int, error) {
+func (a *workflowRateLimiterAdapter) CheckTriggerLimits(limits []*workflow.CompiledRateLimit, rlCtx *workflow.RateLimitContext) (workflow.RateLimitResult, error) {
 	if a.limiter == nil || len(limits) == 0 {
-		return true, 0, nil
+		return workflow.RateLimitResult{Allowed: true}, nil
 	}
 
 	rateLimitConfigs := make([]config.RateLimitConfig, 0, len(limits))
@@ -1497,12 +3658,12 @@ func (a *workflowRateLimiterAdapter) CheckTriggerLimits(limits []*workflow.Compi
 
 	ctx := a.ctxBuilder.BuildForRateLimit(rlCtx)
 
-	allowed, retryAfter, denyingPool, err := a.limiter.Allow(rateLimitConfigs, ctx)
+	decision, err := a.limiter.Check(rateLimitConfigs, ctx)
 	if err != nil {
-		return false, 0, err
+		return workflow.RateLimitResult{}, err
 	}
 
-	if allowed {
+	if decision.Allowed {
 		for _, rl := range limits {
 			pool := "inline"
 			if rl.Config.Pool != "" {
@@ -1511,33 +3672,196 @@ func (a *workflowRateLimiterAdapter) CheckTriggerLimits(limits []*workflow.Compi
 			metrics.RecordRateLimitAllowed(pool)
 		}
 	} else {
-		metrics.RecordRateLimitDenied(denyingPool)
+		metrics.RecordRateLimitDenied(decision.Pool)
+	}
+
+	result := workflow.RateLimitResult{Allowed: decision.Allowed, Delay: decision.Delay, Headers: make(http.Header)}
+	ratelimit.SetHeaders(result.Headers, a.headerStyle, decision, time.Now())
+	if !decision.Allowed {
+		result.RetryAfterSec = max(int(decision.RetryAfter/time.Second), 1)
+	}
+
+	return result, nil
+}
+
+// workflowMailerAdapter implements step.Mailer using mail.Sender.
+type workflowMailerAdapter struct {
+	sender *mail.Sender
+}
+
+// SendEmail implements step.Mailer.
+func (a *workflowMailerAdapter) SendEmail(ctx context.Context, msg step.EmailMessage) error {
+	return a.sender.Send(ctx, mail.Message{
+		From:    msg.From,
+		To:      msg.To,
+		Cc:      msg.Cc,
+		Bcc:     msg.Bcc,
+		Subject: msg.Subject,
+		Text:    msg.Text,
+		HTML:    msg.HTML,
+	})
+}
+
+// workflowObjectStoreAdapter implements step.ObjectStore with one objstore.Client per storage target.
+type workflowObjectStoreAdapter map[string]*objstore.Client
+
+// PutObject implements step.ObjectStore.
+func (a workflowObjectStoreAdapter) PutObject(ctx context.Context, store string, obj step.StorageObject) (*step.StoredObject, error) {
+	client, ok := a[store]
+	if !ok {
+		return nil, fmt.Errorf("unknown store %q", store)
+	}
+	out, err := client.Put(ctx, objstore.PutInput{
+		Bucket:      obj.Bucket,
+		Key:         obj.Key,
+		Body:        obj.Body,
+		ContentType: obj.ContentType,
+		Encryption:  obj.Encryption,
+		KMSKeyID:    obj.KMSKeyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &step.StoredObject{URL: out.URL, ETag: out.ETag}, nil
+}
+
+// workflowFileUploaderAdapter implements step.FileUploader with one sftp.Client per server.
+type workflowFileUploaderAdapter map[string]*sftp.Client
+
+// Upload implements step.FileUploader.
+func (a workflowFileUploaderAdapter) Upload(ctx context.Context, server, remotePath string, data []byte, createDirs bool) error {
+	client, ok := a[server]
+	if !ok {
+		return fmt.Errorf("unknown sftp server %q", server)
+	}
+	return client.Upload(ctx, remotePath, data, createDirs)
+}
+
+// workflowPublisherAdapter implements step.MessagePublisher with one mqtt.Client per broker.
+type workflowPublisherAdapter map[string]*mqtt.Client
+
+// Publish implements step.MessagePublisher.
+func (a workflowPublisherAdapter) Publish(ctx context.Context, broker, topic string, payload []byte, qos byte, retain bool) error {
+	client, ok := a[broker]
+	if !ok {
+		return fmt.Errorf("unknown mqtt broker %q", broker)
+	}
+	return client.Publish(ctx, topic, payload, qos, retain)
+}
+
+// cachePreloader warms the trigger cache at startup by sending each
+// server.cache.preload request through the server's own handler, one at a time.
+type cachePreloader struct {
+	server   *Server
+	requests []preloadRequest
+}
+
+type preloadRequest struct {
+	workflow string
+	target   string       // Path and query string
+	handler  http.Handler // Handler chain of a listener serving the workflow
+}
+
+// newCachePreloader resolves the preload entries to request targets
+func (s *Server) newCachePreloader(entries []config.CachePreloadConfig, handlers map[string]http.Handler) (*cachePreloader, error) {
+	p := &cachePreloader{server: s}
+	for i, entry := range entries {
+		idx := slices.IndexFunc(s.workflows, func(wf *workflow.CompiledWorkflow) bool { return wf.Config.Name == entry.Workflow })
+		if idx < 0 {
+			return nil, fmt.Errorf("server.cache.preload[%d]: unknown workflow %q", i, entry.Workflow)
+		}
+		wf := s.workflows[idx].Config
+		route := wf.PreloadRoute()
+		if route == nil {
+			return nil, fmt.Errorf("server.cache.preload[%d]: workflow %q has no cached GET http trigger", i, entry.Workflow)
+		}
+		params := entry.Params
+		if len(params) == 0 {
+			params = []map[string]string{nil}
+		}
+		handler := handlers[workflow.MainListener]
+		if len(wf.Listeners) > 0 {
+			handler = handlers[wf.Listeners[0]]
+		}
+		for _, set := range params {
+			target, err := workflow.PreloadTarget(route, set)
+			if err != nil {
+				return nil, fmt.Errorf("server.cache.preload[%d]: %w", i, err)
+			}
+			p.requests = append(p.requests, preloadRequest{workflow: entry.Workflow, target: target, handler: handler})
+		}
+	}
+	return p, nil
+}
+
+// Run sends the preload requests, then marks the server warm
+func (p *cachePreloader) Run(ctx context.Context) {
+	defer p.server.cacheWarming.Store(false)
+	start := time.Now()
+	failed := 0
+	for _, pr := range p.requests {
+		if ctx.Err() != nil {
+			return
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, pr.target, nil)
+		if err != nil {
+			failed++
+			continue
+		}
+		req.RemoteAddr = "127.0.0.1:0"
+		w := &preloadResponseWriter{header: make(http.Header)}
+		pr.handler.ServeHTTP(w, req)
+		if w.status >= http.StatusBadRequest {
+			failed++
+			logging.Warn("cache_preload_failed", map[string]any{
+				"workflow": pr.workflow,
+				"target":   pr.target,
+				"status":   w.status,
+			})
+		}
 	}
+	logging.Info("cache_preload_completed", map[string]any{
+		"requests":    len(p.requests),
+		"failed":      failed,
+		"duration_ms": time.Since(start).Milliseconds(),
+	})
+}
 
-	retryAfterSec := int(retryAfter.Seconds())
-	if retryAfterSec < 1 && !allowed {
-		retryAfterSec = 1
+// preloadResponseWriter discards the response to a preload or watchdog request, keeping its status
+type preloadResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *preloadResponseWriter) Header() http.Header { return w.header }
+
+func (w *preloadResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
 	}
+}
 
-	return allowed, retryAfterSec, nil
+func (w *preloadResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(b), nil
 }
 
 // triggerCacheAdapter implements workflow.TriggerCache using cache.Cache.
-// It stores response body and status code in the cache using a special format.
+// It stores response body, status code, and freshness deadline in the cache using a special format.
 type triggerCacheAdapter struct {
 	cache *cache.Cache
 }
 
 // Get retrieves a cached trigger response.
-func (a *triggerCacheAdapter) Get(workflowName, key string) ([]byte, int, bool) {
+func (a *triggerCacheAdapter) Get(workflowName, key string) ([]byte, int, bool, bool) {
 	if a.cache == nil {
-		return nil, 0, false
+		return nil, 0, false, false
 	}
 
 	data, hit := a.cache.Get(workflowName, key)
 	if !hit || len(data) == 0 {
 		metrics.RecordCacheMiss(workflowName)
-		return nil, 0, false
+		return nil, 0, false, false
 	}
 
 	// Extract response from stored format
@@ -1545,27 +3869,39 @@ func (a *triggerCacheAdapter) Get(workflowName, key string) ([]byte, int, bool)
 	bodyStr, ok := entry["__body__"].(string)
 	if !ok {
 		metrics.RecordCacheMiss(workflowName)
-		return nil, 0, false
+		return nil, 0, false, false
 	}
 	statusFloat, ok := entry["__status__"].(float64)
 	if !ok {
 		metrics.RecordCacheMiss(workflowName)
-		return nil, 0, false
+		return nil, 0, false, false
+	}
+
+	// Entries stored with a stale window carry their freshness deadline
+	stale := false
+	if freshUntil, ok := entry["__fresh_until__"].(float64); ok {
+		stale = float64(time.Now().UnixMilli()) > freshUntil
 	}
 
-	return []byte(bodyStr), int(statusFloat), true
+	return []byte(bodyStr), int(statusFloat), stale, true
 }
 
 // Set stores a trigger response in the cache.
-func (a *triggerCacheAdapter) Set(workflowName, key string, body []byte, statusCode int, ttl time.Duration) bool {
+// With a stale window the entry is kept for ttl+staleTTL and marked stale after ttl.
+func (a *triggerCacheAdapter) Set(workflowName, key string, body []byte, statusCode int, ttl, staleTTL time.Duration, tags []string) bool {
 	if a.cache == nil {
 		return false
 	}
 
 	// Store in the format expected by cache.Cache ([]map[string]any)
-	data := []map[string]any{
-		{"__body__": string(body), "__status__": float64(statusCode)},
+	row := map[string]any{"__body__": string(body), "__status__": float64(statusCode)}
+	if staleTTL > 0 {
+		if ttl == 0 {
+			ttl = a.cache.DefaultTTL()
+		}
+		row["__fresh_until__"] = float64(time.Now().Add(ttl).UnixMilli())
+		ttl += staleTTL
 	}
 
-	return a.cache.Set(workflowName, key, data, ttl)
+	return a.cache.SetWithTags(workflowName, key, []map[string]any{row}, ttl, tags)
 }
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"sql-proxy/internal/logging"
+)
+
+// eventID is the event ID of every entry written to the Windows Event Log
+const eventID = 1
+
+// eventWriter is the part of the Windows Event Log API the event log handler uses
+type eventWriter interface {
+	Info(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Error(eid uint32, msg string) error
+}
+
+// eventLogHandler is a slog.Handler writing records at or above its level to
+// the Windows Event Log, as the message followed by key=value attributes
+type eventLogHandler struct {
+	w     eventWriter
+	level slog.Level
+	text  slog.Handler // Formats the attributes into buf
+	mu    *sync.Mutex
+	buf   *bytes.Buffer
+}
+
+// newEventLogHandler returns a handler writing to w. An empty level means warn.
+func newEventLogHandler(w eventWriter, level string) *eventLogHandler {
+	if level == "" {
+		level = "warn"
+	}
+	buf := &bytes.Buffer{}
+	text := slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// The event log records the time and level itself, and the message leads the entry
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return &eventLogHandler{w: w, level: logging.ParseLevel(level), text: text, mu: &sync.Mutex{}, buf: buf}
+}
+
+func (h *eventLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *eventLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	h.buf.Reset()
+	err := h.text.Handle(ctx, r)
+	attrs := strings.TrimSpace(h.buf.String())
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	msg := r.Message
+	if attrs != "" {
+		msg += " " + attrs
+	}
+	switch {
+	case r.Level >= slog.LevelError:
+		return h.w.Error(eventID, msg)
+	case r.Level >= slog.LevelWarn:
+		return h.w.Warning(eventID, msg)
+	default:
+		return h.w.Info(eventID, msg)
+	}
+}
+
+func (h *eventLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.text = h.text.WithAttrs(attrs)
+	return &clone
+}
+
+func (h *eventLogHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.text = h.text.WithGroup(name)
+	return &clone
+}
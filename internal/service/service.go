@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/server"
+	"sql-proxy/internal/systemd"
+	"sql-proxy/internal/validate"
+)
+
+// Restart delays and failure count reset period used when service.recovery
+// leaves them unset
+var defaultRestartDelaysSec = []int{5, 10, 30}
+
+const defaultResetAfterSec = 86400
+
+// InstallOptions are the settings Install applies to the service besides its
+// name, executable and config file
+type InstallOptions struct {
+	Account  string                       // Account the service runs as (empty: LocalSystem on Windows, root elsewhere)
+	Password string                       // Password of Account (Windows only)
+	EventLog bool                         // Start the service with --event-log
+	Recovery config.ServiceRecoveryConfig // Restarts after the service fails
+}
+
+// restartDelays returns the delay before each restart after a failure, or nil
+// when restarts are disabled
+func (o InstallOptions) restartDelays() []time.Duration {
+	if o.Recovery.Disabled {
+		return nil
+	}
+	secs := o.Recovery.RestartDelaysSec
+	if len(secs) == 0 {
+		secs = defaultRestartDelaysSec
+	}
+	delays := make([]time.Duration, len(secs))
+	for i, s := range secs {
+		delays[i] = time.Duration(s) * time.Second
+	}
+	return delays
+}
+
+// resetPeriod returns how long the service must run without failing before
+// the failure count starts over
+func (o InstallOptions) resetPeriod() time.Duration {
+	if o.Recovery.ResetAfterSec > 0 {
+		return time.Duration(o.Recovery.ResetAfterSec) * time.Second
+	}
+	return defaultResetAfterSec * time.Second
+}
+
+// serviceArgs returns the arguments the service manager starts the executable with
+func serviceArgs(name, configPath string, opts InstallOptions) []string {
+	args := []string{"--daemon", "--service-name", name, "--config", configPath}
+	if opts.EventLog {
+		args = append(args, "--event-log")
+	}
+	return args
+}
+
+// newServers validates the configs, one per instance of an instances file, and
+// creates their servers
+func newServers(cfgs []*config.Config, interactive bool) ([]*server.Server, error) {
+	result := validate.Instances(cfgs)
+	if !result.Valid {
+		return nil, fmt.Errorf("configuration validation failed:\n  %s", joinErrors(result.Errors))
+	}
+
+	servers := make([]*server.Server, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		srv, err := server.New(cfg, interactive)
+		if err != nil {
+			if cfg.Server.Instance != "" {
+				err = fmt.Errorf("instance '%s': %w", cfg.Server.Instance, err)
+			}
+			return nil, err
+		}
+		servers = append(servers, srv)
+	}
+	return servers, nil
+}
+
+// startServers starts the servers and returns a channel receiving the result of
+// each as it stops
+func startServers(servers []*server.Server) <-chan error {
+	errChan := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() {
+			errChan <- srv.Start()
+		}()
+	}
+	return errChan
+}
+
+// shutdownServers shuts the servers down concurrently
+func shutdownServers(ctx context.Context, servers []*server.Server) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, srv := range servers {
+		wg.Go(func() {
+			if err := srv.Shutdown(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// serverSockets returns the listening sockets of the servers
+func serverSockets(servers []*server.Server) []systemd.Listener {
+	var sockets []systemd.Listener
+	for _, srv := range servers {
+		sockets = append(sockets, srv.Sockets()...)
+	}
+	return sockets
+}
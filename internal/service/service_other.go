@@ -6,20 +6,25 @@ import (
 	"bytes"
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"text/template"
 	"time"
 
 	"sql-proxy/internal/config"
+	"sql-proxy/internal/logging"
 	"sql-proxy/internal/server"
-	"sql-proxy/internal/validate"
+	"sql-proxy/internal/upgrade"
 )
 
 //go:embed templates/systemd.unit.tmpl
@@ -34,6 +39,38 @@ type serviceTemplateData struct {
 	ExePath    string
 	ConfigPath string
 	Label      string // For launchd
+	User       string // Account the service runs as (empty: root)
+	Restart    bool   // Restart the service when it fails
+	RestartSec int    // Delay before a restart
+}
+
+// newTemplateData returns the template data of a service installed with opts
+func newTemplateData(name, exePath, configPath string, opts InstallOptions) serviceTemplateData {
+	data := serviceTemplateData{
+		Name:       name,
+		ExePath:    exePath,
+		ConfigPath: configPath,
+		User:       opts.Account,
+	}
+	// systemd and launchd wait the same time before every restart: use the first delay
+	if delays := opts.restartDelays(); len(delays) > 0 {
+		data.Restart = true
+		data.RestartSec = int(delays[0].Seconds())
+	}
+	return data
+}
+
+// renderServiceFile executes a service file template
+func renderServiceFile(name, text string, data serviceTemplateData) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute %s template: %w", name, err)
+	}
+	return buf.String(), nil
 }
 
 const shutdownTimeout = 30 * time.Second
@@ -45,45 +82,151 @@ func SetServiceName(_ string) {
 	// No-op on non-Windows platforms
 }
 
-// Run starts the server.
+// Run starts a server per config: one, or one per instance of an instances file.
 // If interactive is true, runs in foreground with signal handling and output.
 // If interactive is false (daemon mode), runs quietly for systemd/launchd.
-func Run(cfg *config.Config, interactive bool) error {
+func Run(cfgs []*config.Config, interactive bool) error {
 	// Validate configuration before starting
-	result := validate.Run(cfg)
-	if !result.Valid {
-		return fmt.Errorf("configuration validation failed:\n  %s", joinErrors(result.Errors))
-	}
-
-	srv, err := server.New(cfg, interactive)
+	servers, err := newServers(cfgs, interactive)
 	if err != nil {
 		return err
 	}
 
-	// Handle graceful shutdown
+	// The process is shared by the instances: its pid file is that of the first
+	pidFile := cfgs[0].Server.PIDFile
+
+	// An upgraded process is recorded by the process it replaces, once it took over
+	if !upgrade.Upgrading() {
+		if err := writePIDFile(pidFile, os.Getpid()); err != nil {
+			return err
+		}
+	}
+
+	// Handle graceful shutdown, and upgrades on SIGUSR2
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2)
+
+	errChan := startServers(servers)
+
+	u := &upgrader{servers: servers, pidFile: pidFile}
+	for {
+		select {
+		case err := <-errChan:
+			if len(servers) == 1 {
+				return err
+			}
+			// One instance stopped: stop the others with it
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			return errors.Join(err, shutdownServers(ctx, servers))
+		case sig := <-sigChan:
+			if sig == syscall.SIGUSR2 {
+				u.start()
+				continue
+			}
+			u.handOver()
+			if interactive {
+				log.Printf("Received %v, shutting down...", sig)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			return shutdownServers(ctx, servers)
+		}
+	}
+}
+
+// upgrader runs at most one upgraded process at a time
+type upgrader struct {
+	servers []*server.Server
+	pidFile string
+
+	mu  sync.Mutex
+	cmd *exec.Cmd // Upgraded process that has not exited, nil if none
+}
+
+// start starts a new process from the executable and config on disk with the
+// servers' sockets. It sends SIGTERM to this process once it serves requests.
+func (u *upgrader) start() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.cmd != nil {
+		logging.Warn("upgrade_in_progress", map[string]any{"pid": u.cmd.Process.Pid})
+		return
+	}
+	cmd, err := upgrade.Spawn(serverSockets(u.servers))
+	if err != nil {
+		logging.Error("upgrade_failed", map[string]any{"error": err.Error()})
+		return
+	}
+	u.cmd = cmd
+	logging.Info("upgrade_started", map[string]any{"pid": cmd.Process.Pid})
 
-	errChan := make(chan error, 1)
 	go func() {
-		errChan <- srv.Start()
+		// The new process outlives this one unless the upgrade failed
+		err := cmd.Wait()
+		u.mu.Lock()
+		u.cmd = nil
+		u.mu.Unlock()
+		fields := map[string]any{"pid": cmd.Process.Pid}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		logging.Error("upgrade_failed", fields)
 	}()
+}
 
-	select {
-	case err := <-errChan:
-		return err
-	case sig := <-sigChan:
-		if interactive {
-			log.Printf("Received %v, shutting down...", sig)
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
-		defer cancel()
-		return srv.Shutdown(ctx)
+// handOver records a running upgraded process in the pid file: the signal to
+// stop came from it, once it took over
+func (u *upgrader) handOver() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.cmd == nil {
+		return
+	}
+	if err := writePIDFile(u.pidFile, u.cmd.Process.Pid); err != nil {
+		logging.Error("pid_file_error", map[string]any{"error": err.Error()})
+	}
+}
+
+// writePIDFile writes pid to path, if set
+func writePIDFile(path string, pid int) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write pid file: %w", err)
+	}
+	return nil
+}
+
+// Upgrade asks the running server to replace itself with the executable and
+// config on disk, by sending SIGUSR2 to the process in server.pid_file
+func Upgrade(pidFile string) error {
+	if pidFile == "" {
+		return fmt.Errorf("server.pid_file is not set (under systemd, use: systemctl reload <service>)")
+	}
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		return fmt.Errorf("failed to read pid file: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid pid file %s: %w", pidFile, err)
 	}
+	proc, err := os.FindProcess(pid)
+	if err == nil {
+		err = proc.Signal(syscall.SIGUSR2)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to signal process %d: %w", pid, err)
+	}
+	fmt.Printf("Upgrade requested from process %d\n", pid)
+	return nil
 }
 
-// Install outputs the service file and instructions for the current platform
-func Install(name, exePath, configPath string) error {
+// Install outputs the service file and instructions for the current platform.
+// opts.Password and opts.EventLog only apply to Windows services.
+func Install(name, exePath, configPath string, opts InstallOptions) error {
 	if name == "" {
 		name = defaultServiceName
 	}
@@ -100,9 +243,9 @@ func Install(name, exePath, configPath string) error {
 
 	switch runtime.GOOS {
 	case "linux":
-		return installLinux(name, absExePath, absConfigPath)
+		return installLinux(newTemplateData(name, absExePath, absConfigPath, opts))
 	case "darwin":
-		return installDarwin(name, absExePath, absConfigPath)
+		return installDarwin(newTemplateData(name, absExePath, absConfigPath, opts))
 	default:
 		fmt.Printf("Service installation is not supported on %s.\n", runtime.GOOS)
 		fmt.Println("Run the binary directly with --daemon flag for background operation.")
@@ -110,24 +253,13 @@ func Install(name, exePath, configPath string) error {
 	}
 }
 
-func installLinux(name, exePath, configPath string) error {
+func installLinux(data serviceTemplateData) error {
+	name := data.Name
 	// Generate systemd unit file from template
-	tmpl, err := template.New("systemd").Parse(systemdUnitTemplate)
+	unitFile, err := renderServiceFile("systemd", systemdUnitTemplate, data)
 	if err != nil {
-		return fmt.Errorf("failed to parse systemd template: %w", err)
-	}
-
-	data := serviceTemplateData{
-		Name:       name,
-		ExePath:    exePath,
-		ConfigPath: configPath,
-	}
-
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("failed to execute systemd template: %w", err)
+		return err
 	}
-	unitFile := buf.String()
 
 	unitPath := fmt.Sprintf("/etc/systemd/system/%s.service", name)
 
@@ -150,26 +282,16 @@ func installLinux(name, exePath, configPath string) error {
 	return nil
 }
 
-func installDarwin(name, exePath, configPath string) error {
-	// Generate launchd plist file from template
-	tmpl, err := template.New("launchd").Parse(launchdPlistTemplate)
-	if err != nil {
-		return fmt.Errorf("failed to parse launchd template: %w", err)
-	}
-
+func installDarwin(data serviceTemplateData) error {
+	name := data.Name
 	plistLabel := fmt.Sprintf("com.sqlproxy.%s", name)
-	data := serviceTemplateData{
-		Name:       name,
-		ExePath:    exePath,
-		ConfigPath: configPath,
-		Label:      plistLabel,
-	}
+	data.Label = plistLabel
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("failed to execute launchd template: %w", err)
+	// Generate launchd plist file from template
+	plistFile, err := renderServiceFile("launchd", launchdPlistTemplate, data)
+	if err != nil {
+		return err
 	}
-	plistFile := buf.String()
 
 	plistPath := fmt.Sprintf("/Library/LaunchDaemons/%s.plist", plistLabel)
 
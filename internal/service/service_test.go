@@ -3,7 +3,17 @@
 package service
 
 import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"sql-proxy/internal/config"
 )
 
 // TestDefaultServiceName verifies the default service name constant
@@ -23,3 +33,175 @@ func TestJoinErrors(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, result)
 	}
 }
+
+// TestInstallOptions_Recovery verifies restart delays and the reset period default and can be disabled
+func TestInstallOptions_Recovery(t *testing.T) {
+	var opts InstallOptions
+	if got, want := opts.restartDelays(), []time.Duration{5 * time.Second, 10 * time.Second, 30 * time.Second}; !reflect.DeepEqual(got, want) {
+		t.Errorf("default restartDelays() = %v, want %v", got, want)
+	}
+	if got := opts.resetPeriod(); got != 24*time.Hour {
+		t.Errorf("default resetPeriod() = %v", got)
+	}
+
+	opts.Recovery = config.ServiceRecoveryConfig{RestartDelaysSec: []int{1, 120}, ResetAfterSec: 600}
+	if got, want := opts.restartDelays(), []time.Duration{time.Second, 2 * time.Minute}; !reflect.DeepEqual(got, want) {
+		t.Errorf("restartDelays() = %v, want %v", got, want)
+	}
+	if got := opts.resetPeriod(); got != 10*time.Minute {
+		t.Errorf("resetPeriod() = %v", got)
+	}
+
+	opts.Recovery.Disabled = true
+	if got := opts.restartDelays(); got != nil {
+		t.Errorf("disabled restartDelays() = %v, want nil", got)
+	}
+}
+
+// TestServiceArgs verifies --event-log is passed to the service only when requested
+func TestServiceArgs(t *testing.T) {
+	got := serviceArgs("sql-proxy", "/etc/sql-proxy.yaml", InstallOptions{})
+	want := []string{"--daemon", "--service-name", "sql-proxy", "--config", "/etc/sql-proxy.yaml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("serviceArgs() = %v, want %v", got, want)
+	}
+	got = serviceArgs("sql-proxy", "/etc/sql-proxy.yaml", InstallOptions{EventLog: true})
+	if got[len(got)-1] != "--event-log" {
+		t.Errorf("serviceArgs() = %v, want --event-log last", got)
+	}
+}
+
+// TestRenderServiceFile verifies the account and recovery options reach the systemd and launchd files
+func TestRenderServiceFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     InstallOptions
+		systemd  []string
+		launchd  []string
+		excluded []string
+	}{
+		{
+			name:     "defaults",
+			systemd:  []string{"Type=notify\n", "ExecReload=/bin/kill -USR2 $MAINPID\n", "Restart=on-failure\nRestartSec=5\n", "WatchdogSec=30\n"},
+			launchd:  []string{"<key>KeepAlive</key>\n    <true/>", "<integer>5</integer>"},
+			excluded: []string{"User=", "UserName"},
+		},
+		{
+			name:    "account and delays",
+			opts:    InstallOptions{Account: "sqlproxy", Recovery: config.ServiceRecoveryConfig{RestartDelaysSec: []int{15, 60}}},
+			systemd: []string{"User=sqlproxy\n", "RestartSec=15\n"},
+			launchd: []string{"<key>UserName</key>\n    <string>sqlproxy</string>", "<integer>15</integer>"},
+		},
+		{
+			name:     "recovery disabled",
+			opts:     InstallOptions{Recovery: config.ServiceRecoveryConfig{Disabled: true}},
+			systemd:  []string{"Restart=no\n"},
+			launchd:  []string{"<key>KeepAlive</key>\n    <false/>"},
+			excluded: []string{"RestartSec", "ThrottleInterval"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := newTemplateData("sql-proxy", "/opt/sql-proxy/sql-proxy", "/opt/sql-proxy/config.yaml", tt.opts)
+			unit, err := renderServiceFile("systemd", systemdUnitTemplate, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data.Label = "com.sqlproxy.sql-proxy"
+			plist, err := renderServiceFile("launchd", launchdPlistTemplate, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.systemd {
+				if !strings.Contains(unit, want) {
+					t.Errorf("unit file missing %q:\n%s", want, unit)
+				}
+			}
+			for _, want := range tt.launchd {
+				if !strings.Contains(plist, want) {
+					t.Errorf("plist missing %q:\n%s", want, plist)
+				}
+			}
+			for _, unwanted := range tt.excluded {
+				if strings.Contains(unit, unwanted) || strings.Contains(plist, unwanted) {
+					t.Errorf("service files contain %q:\n%s\n%s", unwanted, unit, plist)
+				}
+			}
+		})
+	}
+}
+
+// fakeEventLog records the entries written to it by type
+type fakeEventLog struct {
+	entries []string
+}
+
+func (f *fakeEventLog) Info(_ uint32, msg string) error {
+	f.entries = append(f.entries, "info: "+msg)
+	return nil
+}
+
+func (f *fakeEventLog) Warning(_ uint32, msg string) error {
+	f.entries = append(f.entries, "warning: "+msg)
+	return nil
+}
+
+func (f *fakeEventLog) Error(_ uint32, msg string) error {
+	f.entries = append(f.entries, "error: "+msg)
+	return nil
+}
+
+// TestEventLogHandler verifies records at or above the level reach the event log with their attributes
+func TestEventLogHandler(t *testing.T) {
+	el := &fakeEventLog{}
+	logger := slog.New(newEventLogHandler(el, ""))
+
+	logger.Info("request_completed", "request_id", "abc")
+	logger.Warn("slow_query", "workflow", "orders", "duration_ms", 950)
+	logger.With("database", "primary").Error("db_unhealthy", "error", "connection refused")
+	logger.WithGroup("step").Error("step_failed", "name", "fetch")
+
+	want := []string{
+		"warning: slow_query workflow=orders duration_ms=950",
+		`error: db_unhealthy database=primary error="connection refused"`,
+		"error: step_failed step.name=fetch",
+	}
+	if !reflect.DeepEqual(el.entries, want) {
+		t.Errorf("entries = %q, want %q", el.entries, want)
+	}
+
+	el.entries = nil
+	logger = slog.New(newEventLogHandler(el, "info"))
+	logger.Debug("params_parsed")
+	logger.Info("server_started", "port", 8080)
+	if want := []string{"info: server_started port=8080"}; !reflect.DeepEqual(el.entries, want) {
+		t.Errorf("entries = %q, want %q", el.entries, want)
+	}
+}
+
+// TestUpgrade verifies -upgrade sends SIGUSR2 to the process in the pid file
+func TestUpgrade(t *testing.T) {
+	if err := Upgrade(""); err == nil || !strings.Contains(err.Error(), "server.pid_file is not set") {
+		t.Errorf("expected missing pid file error, got %v", err)
+	}
+
+	pidFile := filepath.Join(t.TempDir(), "sql-proxy.pid")
+	if err := Upgrade(pidFile); err == nil {
+		t.Error("expected error for a missing pid file")
+	}
+
+	if err := writePIDFile(pidFile, os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR2)
+	defer signal.Stop(sig)
+	if err := Upgrade(pidFile); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sig:
+	case <-time.After(5 * time.Second):
+		t.Error("SIGUSR2 not received")
+	}
+}
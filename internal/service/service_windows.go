@@ -4,6 +4,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -17,8 +18,8 @@ import (
 	"golang.org/x/sys/windows/svc/mgr"
 
 	"sql-proxy/internal/config"
+	"sql-proxy/internal/logging"
 	"sql-proxy/internal/server"
-	"sql-proxy/internal/validate"
 )
 
 const defaultServiceName = "sql-proxy"
@@ -34,7 +35,7 @@ func SetServiceName(name string) {
 }
 
 type windowsService struct {
-	server *server.Server
+	servers []*server.Server
 }
 
 func (ws *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (ssec bool, errno uint32) {
@@ -42,17 +43,19 @@ func (ws *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, cha
 
 	changes <- svc.Status{State: svc.StartPending}
 
-	// Start the HTTP server in a goroutine
-	errChan := make(chan error, 1)
-	go func() {
-		errChan <- ws.server.Start()
-	}()
+	// Start the HTTP servers in goroutines
+	errChan := startServers(ws.servers)
 
 	changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
 
 	for {
 		select {
 		case err := <-errChan:
+			if len(ws.servers) > 1 {
+				ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+				err = errors.Join(err, shutdownServers(ctx, ws.servers))
+				cancel()
+			}
 			if err != nil {
 				log.Printf("Server error: %v", err)
 				return true, 1
@@ -67,7 +70,7 @@ func (ws *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, cha
 			case svc.Stop, svc.Shutdown:
 				changes <- svc.Status{State: svc.StopPending}
 				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-				ws.server.Shutdown(ctx)
+				shutdownServers(ctx, ws.servers)
 				cancel()
 				return false, 0
 
@@ -78,30 +81,32 @@ func (ws *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, cha
 	}
 }
 
-// Run starts the service.
+// Run starts the service, with a server per config: one, or one per instance
+// of an instances file.
 // If interactive is true, runs in foreground with Ctrl+C handling.
 // If interactive is false (daemon mode), runs as Windows service or background process.
-func Run(cfg *config.Config, interactive bool) error {
+func Run(cfgs []*config.Config, interactive bool) error {
 	// Validate configuration before starting
-	result := validate.Run(cfg)
-	if !result.Valid {
-		return fmt.Errorf("configuration validation failed:\n  %s", joinErrors(result.Errors))
-	}
-
-	srv, err := server.New(cfg, interactive)
+	servers, err := newServers(cfgs, interactive)
 	if err != nil {
 		return err
 	}
+	cfg := cfgs[0] // The event log is process-wide, set up by the first instance
 
 	if !interactive {
 		// Daemon mode - check if we're actually running as a Windows service
 		isWindowsService, _ := svc.IsWindowsService()
 		if isWindowsService && runningServiceName != "" {
 			// Running as a Windows service via SCM
-			ws := &windowsService{server: srv}
+			ws := &windowsService{servers: servers}
 			elog, err := eventlog.Open(runningServiceName)
 			if err == nil {
 				defer elog.Close()
+				if cfg.Logging.EventLog.Enabled {
+					logging.AddSink(newEventLogHandler(elog, cfg.Logging.EventLog.Level))
+				}
+			} else if cfg.Logging.EventLog.Enabled {
+				logging.Warn("event_log_unavailable", map[string]any{"error": err.Error()})
 			}
 			return svc.Run(runningServiceName, ws)
 		}
@@ -112,26 +117,30 @@ func Run(cfg *config.Config, interactive bool) error {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt)
 
-	errChan := make(chan error, 1)
-	go func() {
-		errChan <- srv.Start()
-	}()
+	errChan := startServers(servers)
 
 	select {
 	case err := <-errChan:
-		return err
+		if len(servers) == 1 {
+			return err
+		}
+		// One instance stopped: stop the others with it
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return errors.Join(err, shutdownServers(ctx, servers))
 	case <-sigChan:
 		if interactive {
 			log.Println("Received interrupt, shutting down...")
 		}
 		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
-		return srv.Shutdown(ctx)
+		return shutdownServers(ctx, servers)
 	}
 }
 
-// Install installs the service with the given name
-func Install(name, exePath, configPath string) error {
+// Install installs the service with the given name, running as opts.Account
+// and restarted by the service control manager after failures
+func Install(name, exePath, configPath string, opts InstallOptions) error {
 	if name == "" {
 		name = defaultServiceName
 	}
@@ -176,28 +185,33 @@ func Install(name, exePath, configPath string) error {
 
 	// Include --daemon and --service-name flags for proper daemon mode
 	s, err = m.CreateService(name, exePath, mgr.Config{
-		DisplayName: displayName,
-		Description: desc,
-		StartType:   mgr.StartAutomatic,
-	}, "--daemon", "--service-name", name, "--config", configPath)
+		DisplayName:      displayName,
+		Description:      desc,
+		StartType:        mgr.StartAutomatic,
+		ServiceStartName: opts.Account, // Empty runs as LocalSystem
+		Password:         opts.Password,
+	}, serviceArgs(name, configPath, opts)...)
 	if err != nil {
 		return fmt.Errorf("failed to create service: %w", err)
 	}
 	defer s.Close()
 
-	// Configure recovery actions - restart on failure
-	// First failure: restart after 5 seconds
-	// Second failure: restart after 10 seconds
-	// Subsequent failures: restart after 30 seconds
-	recoveryActions := []mgr.RecoveryAction{
-		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
-		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
-		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
-	}
-	err = s.SetRecoveryActions(recoveryActions, 86400) // Reset failure count after 24 hours
-	if err != nil {
-		log.Printf("Warning: failed to set recovery actions: %v", err)
-		// Non-fatal - continue without recovery configuration
+	// Configure recovery actions - restart after each failure with the
+	// configured delays (default 5s, 10s, then 30s for subsequent failures)
+	if delays := opts.restartDelays(); len(delays) > 0 {
+		recoveryActions := make([]mgr.RecoveryAction, len(delays))
+		for i, d := range delays {
+			recoveryActions[i] = mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: d}
+		}
+		err = s.SetRecoveryActions(recoveryActions, uint32(opts.resetPeriod().Seconds()))
+		if err == nil {
+			// Also restart when the service stops with an error rather than crashing
+			err = s.SetRecoveryActionsOnNonCrashFailures(true)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to set recovery actions: %v", err)
+			// Non-fatal - continue without recovery configuration
+		}
 	}
 
 	// Setup event logging
@@ -208,10 +222,19 @@ func Install(name, exePath, configPath string) error {
 	}
 
 	fmt.Printf("Service '%s' installed successfully\n", name)
+	if opts.Account != "" {
+		fmt.Printf("Runs as: %s\n", opts.Account)
+	}
 	fmt.Printf("Start with: sc start %s\n", name)
 	return nil
 }
 
+// Upgrade is not supported on Windows: Windows services cannot hand their
+// sockets to another process
+func Upgrade(_ string) error {
+	return fmt.Errorf("zero-downtime upgrade is not supported on Windows; use -restart")
+}
+
 // Uninstall removes the service with the given name
 func Uninstall(name string) error {
 	if name == "" {
@@ -0,0 +1,272 @@
+// Package sftp uploads files to SFTP servers for workflow sftp steps.
+// It runs github.com/pkg/sftp over golang.org/x/crypto/ssh connections, which
+// it pools per server.
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultPort is the SSH port used when none is configured
+const DefaultPort = 22
+
+// DefaultTimeout bounds connecting and one upload
+const DefaultTimeout = 30 * time.Second
+
+// DefaultMaxConnections is the pool size used when none is configured
+const DefaultMaxConnections = 2
+
+// Config describes one SFTP server.
+type Config struct {
+	Host                  string
+	Port                  int // 0 = DefaultPort
+	Username              string
+	Password              string
+	PrivateKey            []byte // PEM-encoded private key
+	Passphrase            string // For an encrypted PrivateKey
+	HostKey               string // Server public key in authorized_keys format
+	InsecureIgnoreHostKey bool   // Skip host key verification (testing only)
+	Timeout               time.Duration
+	MaxConnections        int // 0 = DefaultMaxConnections
+}
+
+// Client uploads files to one server over a pool of connections.
+type Client struct {
+	cfg       Config
+	addr      string
+	sshConfig *ssh.ClientConfig
+
+	slots  chan struct{} // Limits open connections to MaxConnections
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// New creates a client. Connections are opened on first use.
+func New(cfg Config) (*Client, error) {
+	if cfg.Port == 0 {
+		cfg.Port = DefaultPort
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MaxConnections <= 0 {
+		cfg.MaxConnections = DefaultMaxConnections
+	}
+
+	var auth []ssh.AuthMethod
+	if len(cfg.PrivateKey) > 0 {
+		var signer ssh.Signer
+		var err error
+		if cfg.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(cfg.PrivateKey, []byte(cfg.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(cfg.PrivateKey)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("password or private key is required")
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case cfg.HostKey != "":
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("host key: %w", err)
+		}
+		hostKeyCallback = ssh.FixedHostKey(key)
+	case cfg.InsecureIgnoreHostKey:
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, errors.New("host key is required (or set insecure_ignore_host_key)")
+	}
+
+	return &Client{
+		cfg:  cfg,
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		sshConfig: &ssh.ClientConfig{
+			User:            cfg.Username,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         cfg.Timeout,
+		},
+		slots: make(chan struct{}, cfg.MaxConnections),
+	}, nil
+}
+
+// Upload writes data to remotePath, replacing any existing file. With createDirs,
+// missing parent directories are created first. An upload that fails other than
+// with a status from the server discards its connection, so a retry starts on a
+// fresh one.
+func (c *Client) Upload(ctx context.Context, remotePath string, data []byte, createDirs bool) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	// Wait for a connection slot
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("sftp %s: waiting for a connection: %w", c.addr, ctx.Err())
+	}
+	defer func() { <-c.slots }()
+
+	cn, reused, err := c.get(ctx)
+	if err != nil {
+		return err
+	}
+	err = c.uploadOn(ctx, cn, remotePath, data, createDirs)
+
+	// An idle connection may have been dropped by the server; retry once on a fresh one
+	if err != nil && reused && ctx.Err() == nil && !isStatus(err) {
+		if cn, err = c.dial(ctx); err != nil {
+			return err
+		}
+		err = c.uploadOn(ctx, cn, remotePath, data, createDirs)
+	}
+	return err
+}
+
+// uploadOn runs one upload on cn. It returns cn to the pool when the upload succeeds or the
+// server refuses it with a status, and closes it otherwise.
+func (c *Client) uploadOn(ctx context.Context, cn *conn, remotePath string, data []byte, createDirs bool) error {
+	// The SSH library has no context support, so the deadline and cancellation act on the connection
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = cn.netConn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = cn.netConn.Close() })
+
+	err := cn.upload(remotePath, data, createDirs)
+	if !stop() || (err != nil && !isStatus(err)) {
+		cn.close()
+		if ctx.Err() != nil {
+			return fmt.Errorf("sftp %s: %w", c.addr, ctx.Err())
+		}
+		return err
+	}
+	_ = cn.netConn.SetDeadline(time.Time{})
+	c.put(cn)
+	return err
+}
+
+// Close closes idle connections. Uploads in progress finish on their own connections.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.closed = true
+	c.mu.Unlock()
+	for _, cn := range idle {
+		cn.close()
+	}
+	return nil
+}
+
+// get returns an idle connection (reused = true) or dials a new one.
+func (c *Client) get(ctx context.Context) (*conn, bool, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, true, nil
+	}
+	c.mu.Unlock()
+	cn, err := c.dial(ctx)
+	return cn, false, err
+}
+
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		cn.close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+// isStatus reports whether err is a status the server answered with, which
+// leaves the connection usable.
+func isStatus(err error) bool {
+	var statusErr *sftp.StatusError
+	return errors.As(err, &statusErr) || errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission)
+}
+
+// conn is one SSH connection running the sftp subsystem.
+type conn struct {
+	netConn net.Conn
+	client  *ssh.Client
+	sftp    *sftp.Client
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	netConn, err := (&net.Dialer{}).DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("sftp connect %s: %w", c.addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = netConn.SetDeadline(deadline)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, c.addr, c.sshConfig)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, fmt.Errorf("ssh handshake %s: %w", c.addr, err)
+	}
+	cn := &conn{netConn: netConn, client: ssh.NewClient(sshConn, chans, reqs)}
+	if cn.sftp, err = sftp.NewClient(cn.client); err != nil {
+		cn.close()
+		return nil, fmt.Errorf("sftp %s: %w", c.addr, err)
+	}
+	return cn, nil
+}
+
+func (cn *conn) close() {
+	if cn.sftp != nil {
+		_ = cn.sftp.Close()
+	}
+	_ = cn.client.Close()
+}
+
+func (cn *conn) upload(remotePath string, data []byte, createDirs bool) error {
+	if createDirs {
+		// Failures are ignored: a real problem surfaces when the file is opened
+		if dir := path.Dir(remotePath); dir != "." && dir != "/" {
+			_ = cn.sftp.MkdirAll(dir)
+		}
+	}
+
+	f, err := cn.sftp.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", remotePath, err)
+	}
+	if _, err := f.ReadFrom(bytes.NewReader(data)); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write %s: %w", remotePath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", remotePath, err)
+	}
+	return nil
+}
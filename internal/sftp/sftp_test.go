@@ -0,0 +1,268 @@
+package sftp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeServer is an SSH server whose sftp subsystem serves an in-memory file system.
+type fakeServer struct {
+	t        *testing.T
+	listener net.Listener
+	config   *ssh.ServerConfig
+	hostKey  string
+	handlers sftp.Handlers
+	denied   map[string]bool // Paths whose OPEN fails with permission denied
+	accepts  atomic.Int32
+}
+
+// deniedWriter refuses to open the server's denied paths for writing
+type deniedWriter struct {
+	sftp.FileWriter
+	denied map[string]bool
+}
+
+func (d deniedWriter) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	if d.denied[r.Filepath] {
+		return nil, sftp.ErrSSHFxPermissionDenied
+	}
+	return d.FileWriter.Filewrite(r)
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if meta.User() == "deploy" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("access denied")
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{
+		t:        t,
+		listener: l,
+		config:   config,
+		hostKey:  string(ssh.MarshalAuthorizedKey(signer.PublicKey())),
+		handlers: sftp.InMemHandler(),
+		denied:   make(map[string]bool),
+	}
+	s.handlers.FilePut = deniedWriter{FileWriter: s.handlers.FilePut, denied: s.denied}
+	t.Cleanup(func() { _ = l.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) clientConfig() Config {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return Config{
+		Host:     "127.0.0.1",
+		Port:     addr.Port,
+		Username: "deploy",
+		Password: "secret",
+		HostKey:  s.hostKey,
+		Timeout:  5 * time.Second,
+	}
+}
+
+func (s *fakeServer) file(name string) ([]byte, bool) {
+	req := sftp.NewRequest("Get", name)
+	req.Flags = 1 // SSH_FXF_READ
+	r, err := s.handlers.FileGet.Fileread(req)
+	if err != nil {
+		return nil, false
+	}
+	data, err := io.ReadAll(io.NewSectionReader(r, 0, 1<<30))
+	return data, err == nil
+}
+
+func (s *fakeServer) isDir(name string) bool {
+	l, err := s.handlers.FileList.Filelist(sftp.NewRequest("Stat", name))
+	if err != nil {
+		return false
+	}
+	infos := make([]os.FileInfo, 1)
+	n, _ := l.ListAt(infos, 0)
+	return n == 1 && infos[0].IsDir()
+}
+
+func (s *fakeServer) serve() {
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.accepts.Add(1)
+		go s.handleConn(nc)
+	}
+}
+
+func (s *fakeServer) handleConn(nc net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, s.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			_ = newCh.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range chReqs {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if ok {
+					go func() {
+						server := sftp.NewRequestServer(ch, s.handlers)
+						_ = server.Serve()
+						_ = server.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+func TestClient_Upload(t *testing.T) {
+	srv := newFakeServer(t)
+	c, err := New(srv.clientConfig())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// Larger than one WRITE packet
+	data := []byte(strings.Repeat("id,name\n", 10000))
+	if err := c.Upload(context.Background(), "/exports/daily/orders.csv", data, true); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if got, _ := srv.file("/exports/daily/orders.csv"); string(got) != string(data) {
+		t.Errorf("uploaded %d bytes, want %d", len(got), len(data))
+	}
+	if !srv.isDir("/exports") || !srv.isDir("/exports/daily") {
+		t.Error("parent directories were not created")
+	}
+
+	// Existing directories and an empty file
+	if err := c.Upload(context.Background(), "/exports/daily/empty.txt", nil, true); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if got, ok := srv.file("/exports/daily/empty.txt"); !ok || len(got) != 0 {
+		t.Errorf("empty file = %q, exists %v", got, ok)
+	}
+
+	if n := srv.accepts.Load(); n != 1 {
+		t.Errorf("opened %d connections, want 1 (pooled)", n)
+	}
+}
+
+func TestClient_Upload_StatusError(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.denied["/readonly/file.txt"] = true
+	c, _ := New(srv.clientConfig())
+	defer func() { _ = c.Close() }()
+
+	err := c.Upload(context.Background(), "/readonly/file.txt", []byte("x"), false)
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Upload() error = %v, want permission denied", err)
+	}
+	if err.Error() != "open /readonly/file.txt: permission denied" {
+		t.Errorf("Upload() error = %v", err)
+	}
+
+	// A refused upload leaves the connection usable
+	if err := c.Upload(context.Background(), "/file.txt", []byte("x"), false); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if n := srv.accepts.Load(); n != 1 {
+		t.Errorf("opened %d connections, want 1", n)
+	}
+}
+
+func TestClient_Upload_StaleConnection(t *testing.T) {
+	srv := newFakeServer(t)
+	c, _ := New(srv.clientConfig())
+	defer func() { _ = c.Close() }()
+
+	if err := c.Upload(context.Background(), "/a.txt", []byte("a"), false); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	// Simulate the server dropping the idle connection
+	c.mu.Lock()
+	_ = c.idle[0].netConn.Close()
+	c.mu.Unlock()
+
+	if err := c.Upload(context.Background(), "/b.txt", []byte("b"), false); err != nil {
+		t.Fatalf("Upload after dropped connection failed: %v", err)
+	}
+	if got, _ := srv.file("/b.txt"); string(got) != "b" {
+		t.Errorf("/b.txt = %q", got)
+	}
+}
+
+func TestClient_Upload_WrongHostKey(t *testing.T) {
+	srv := newFakeServer(t)
+	other := newFakeServer(t)
+	cfg := srv.clientConfig()
+	cfg.HostKey = other.hostKey
+	c, _ := New(cfg)
+
+	err := c.Upload(context.Background(), "/a.txt", []byte("a"), false)
+	if err == nil || !strings.Contains(err.Error(), "ssh handshake") {
+		t.Errorf("Upload() error = %v, want handshake failure", err)
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"no credentials", Config{Host: "h", Username: "u", InsecureIgnoreHostKey: true}, "password or private key is required"},
+		{"no host key", Config{Host: "h", Username: "u", Password: "p"}, "host key is required"},
+		{"bad host key", Config{Host: "h", Username: "u", Password: "p", HostKey: "not a key"}, "host key:"},
+		{"bad private key", Config{Host: "h", Username: "u", PrivateKey: []byte("junk"), InsecureIgnoreHostKey: true}, "private key:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package sqlutil
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// token is a lexical unit of SQL: an identifier or keyword, a quoted identifier,
+// or a single punctuation character. String literals, numbers and comments are dropped.
+type token struct {
+	text   string
+	quoted bool // Quoted identifier: never a keyword
+	punct  bool
+}
+
+// is reports whether t is the unquoted keyword or punctuation s.
+func (t token) is(s string) bool {
+	return !t.quoted && strings.EqualFold(t.text, s)
+}
+
+// word reports whether t is an identifier or keyword.
+func (t token) word() bool {
+	return !t.punct
+}
+
+// tokenize splits SQL into tokens, skipping comments, string literals and numbers.
+func tokenize(sql string) []token {
+	var tokens []token
+	for i := 0; i < len(sql); {
+		ch := sql[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case ch == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case ch == '\'':
+			_, i = readQuoted(sql, i, '\'')
+		case ch == '"' || ch == '`':
+			var text string
+			text, i = readQuoted(sql, i, ch)
+			tokens = append(tokens, token{text: text, quoted: true})
+		case ch == '[':
+			end := strings.IndexByte(sql[i:], ']')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			tokens = append(tokens, token{text: sql[i+1 : i+end], quoted: true})
+			i += end + 1
+		case ch >= '0' && ch <= '9':
+			for i < len(sql) && (isIdentChar(sql[i]) || sql[i] == '.') {
+				i++
+			}
+		case isIdentChar(ch) || ch == '@' || ch == '#' || ch == '$':
+			start := i
+			for i < len(sql) && (isIdentChar(sql[i]) || sql[i] == '@' || sql[i] == '#' || sql[i] == '$') {
+				i++
+			}
+			tokens = append(tokens, token{text: sql[start:i]})
+		default:
+			tokens = append(tokens, token{text: string(ch), punct: true})
+			i++
+		}
+	}
+	return tokens
+}
+
+// readQuoted reads a quoted string or identifier starting at sql[i] and returns its
+// unescaped content and the index after the closing quote. A doubled quote is an escape.
+func readQuoted(sql string, i int, quote byte) (string, int) {
+	var buf strings.Builder
+	for i++; i < len(sql); i++ {
+		if sql[i] != quote {
+			buf.WriteByte(sql[i])
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == quote {
+			buf.WriteByte(quote)
+			i++
+			continue
+		}
+		return buf.String(), i + 1
+	}
+	return buf.String(), i
+}
+
+// referenceKeywords are followed by the name of a table, view or routine.
+var referenceKeywords = []string{
+	"FROM", "JOIN", "INTO", "INSERT", "UPDATE", "DELETE", "MERGE", "TABLE", "TRUNCATE",
+	"APPLY", "USING", "EXEC", "EXECUTE", "CALL", "USE",
+}
+
+// clauseKeywords end a table reference; any other bare word after a name is its alias.
+var clauseKeywords = []string{
+	"WHERE", "JOIN", "INNER", "LEFT", "RIGHT", "FULL", "CROSS", "OUTER", "NATURAL", "STRAIGHT_JOIN",
+	"ON", "USING", "GROUP", "ORDER", "HAVING", "UNION", "EXCEPT", "INTERSECT", "MINUS", "LIMIT",
+	"OFFSET", "FETCH", "WITH", "SET", "VALUES", "SELECT", "OUTPUT", "FOR", "WINDOW", "WHEN",
+	"RETURNING", "OPTION", "PIVOT", "UNPIVOT", "TABLESAMPLE", "DEFAULT", "FROM", "INTO", "AS",
+	"USE", "FORCE", "IGNORE", "INDEXED", "NOT", "PARTITION", "LOCK", "PROCEDURE", "QUALIFY",
+}
+
+// fromFunctions take FROM inside their arguments (EXTRACT(YEAR FROM d)), where it names no table.
+var fromFunctions = []string{"EXTRACT", "TRIM", "SUBSTRING", "SUBSTR", "OVERLAY", "POSITION"}
+
+// ErrUncheckableStatement is returned for statements whose references cannot be
+// determined statically, such as dynamic SQL.
+var ErrUncheckableStatement = errors.New("statement references cannot be determined")
+
+// TableReferences returns the tables, views and routines the SQL refers to, as written
+// (quotes removed, parts joined with "."). Common table expression names, variables
+// and temporary tables (#name) are omitted. The scan errs towards reporting too much:
+// a word it cannot tell from a table name is returned as one. Dynamic SQL, whose
+// target is only known at run time, returns ErrUncheckableStatement.
+func TableReferences(sql string) ([]string, error) {
+	var refs []string
+	for _, stmt := range splitStatements(tokenize(sql)) {
+		ctes := cteNames(stmt)
+		add := func(name string) {
+			if strings.HasPrefix(name, "@") || strings.HasPrefix(name, "#") {
+				return
+			}
+			if !strings.Contains(name, ".") && slices.Contains(ctes, strings.ToLower(name)) {
+				return
+			}
+			if !slices.Contains(refs, name) {
+				refs = append(refs, name)
+			}
+		}
+		if err := statementReferences(stmt, add); err != nil {
+			return nil, err
+		}
+	}
+	return refs, nil
+}
+
+// splitStatements splits tokens at semicolons.
+func splitStatements(tokens []token) [][]token {
+	var stmts [][]token
+	start := 0
+	for i, t := range tokens {
+		if t.is(";") {
+			stmts = append(stmts, tokens[start:i])
+			start = i + 1
+		}
+	}
+	return append(stmts, tokens[start:])
+}
+
+// statementReferences calls add for every name following a reference keyword.
+func statementReferences(tokens []token, add func(string)) error {
+	// funcs holds, for each open parenthesis, the function it calls ("" for none)
+	var funcs []string
+	for i, t := range tokens {
+		switch {
+		case t.is("("):
+			fn := ""
+			if i > 0 && tokens[i-1].word() && !tokens[i-1].quoted {
+				fn = strings.ToUpper(tokens[i-1].text)
+			}
+			funcs = append(funcs, fn)
+			continue
+		case t.is(")"):
+			if len(funcs) > 0 {
+				funcs = funcs[:len(funcs)-1]
+			}
+			continue
+		case !isKeyword(t, referenceKeywords):
+			continue
+		}
+		prev, next := tokenAt(tokens, i-1), tokenAt(tokens, i+1)
+
+		switch {
+		case t.is("FROM") && len(funcs) > 0 && slices.Contains(fromFunctions, funcs[len(funcs)-1]):
+			continue // EXTRACT(YEAR FROM d), TRIM(x FROM s), SUBSTRING(s FROM 1)
+		case (t.is("UPDATE") || t.is("DELETE")) && (prev.is("KEY") || prev.is("FOR") || prev.is("ON")):
+			continue // ON DUPLICATE KEY UPDATE, FOR UPDATE, ON DELETE CASCADE
+		case isKeyword(t, []string{"INSERT", "DELETE", "MERGE"}) && (next.is("INTO") || next.is("FROM")):
+			continue // The name follows INTO or FROM
+		case t.is("INSERT") && isKeyword(next, []string{"OR", "IGNORE", "LOW_PRIORITY", "HIGH_PRIORITY", "DELAYED"}):
+			continue // INSERT OR REPLACE INTO, INSERT IGNORE INTO
+		case t.is("TRUNCATE") && next.is("TABLE"):
+			continue
+		case t.is("USE") && (next.is("INDEX") || next.is("KEY")):
+			continue // MySQL index hint
+		case t.is("TABLE") && !isKeyword(prev, []string{"CREATE", "ALTER", "DROP", "TRUNCATE", "LOCK", "TEMPORARY", "TEMP"}):
+			continue // RETURNS TABLE, table types
+		}
+
+		j := i + 1
+		if isKeyword(t, []string{"EXEC", "EXECUTE"}) {
+			// EXEC @status = proc: skip the return status variable
+			if tokenAt(tokens, j+1).is("=") && strings.HasPrefix(tokenAt(tokens, j).text, "@") {
+				j += 2
+			}
+			if next := tokenAt(tokens, j); next.is("(") || strings.HasPrefix(next.text, "@") {
+				return fmt.Errorf("%w: dynamic SQL in %s", ErrUncheckableStatement, strings.ToUpper(t.text))
+			}
+		}
+		if tokenAt(tokens, j).is("IF") {
+			// CREATE TABLE IF NOT EXISTS, DROP TABLE IF EXISTS
+			for j < len(tokens) && !tokens[j].is("EXISTS") {
+				j++
+			}
+			j++
+		}
+		for {
+			name, end := readName(tokens, j)
+			if name == "" {
+				if !tokenAt(tokens, j).is("(") {
+					break
+				}
+				end = skipGroup(tokens, j) // Derived table: its contents are scanned in turn
+			} else {
+				add(name)
+				if tokenAt(tokens, end).is("(") {
+					end = skipGroup(tokens, end) // Table-valued function arguments or column list
+				}
+			}
+			if !t.is("FROM") {
+				break
+			}
+			// FROM a x, b y: skip the alias and hints, continue after a comma
+			end = skipAlias(tokens, end)
+			if !tokenAt(tokens, end).is(",") {
+				break
+			}
+			j = end + 1
+		}
+	}
+	return nil
+}
+
+// tokenAt returns tokens[i], or an empty punctuation token when i is out of range.
+func tokenAt(tokens []token, i int) token {
+	if i < 0 || i >= len(tokens) {
+		return token{punct: true}
+	}
+	return tokens[i]
+}
+
+// readName reads a possibly qualified name starting at tokens[i]. Returns "" when
+// tokens[i] does not start a name.
+func readName(tokens []token, i int) (string, int) {
+	if i >= len(tokens) || !tokens[i].word() || (!tokens[i].quoted && isKeyword(tokens[i], clauseKeywords)) {
+		return "", i
+	}
+	parts := []string{tokens[i].text}
+	i++
+	for i < len(tokens) && tokens[i].is(".") {
+		i++
+		if i < len(tokens) && tokens[i].word() {
+			parts = append(parts, tokens[i].text)
+			i++
+		} else {
+			parts = append(parts, "") // db..table (default schema)
+		}
+	}
+	return strings.Join(parts, "."), i
+}
+
+// skipAlias skips an alias and table hints following a table reference.
+func skipAlias(tokens []token, i int) int {
+	if i < len(tokens) && tokens[i].is("AS") {
+		i++
+	}
+	if i < len(tokens) && tokens[i].word() && (tokens[i].quoted || !isKeyword(tokens[i], clauseKeywords)) {
+		i++
+	}
+	if i+1 < len(tokens) && tokens[i].is("WITH") && tokens[i+1].is("(") {
+		i = skipGroup(tokens, i+1) // WITH (NOLOCK)
+	}
+	return i
+}
+
+// skipGroup returns the index after the parenthesis matching tokens[i].
+func skipGroup(tokens []token, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch {
+		case tokens[i].is("("):
+			depth++
+		case tokens[i].is(")"):
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// cteNames returns the lowercased names defined by WITH clauses.
+func cteNames(tokens []token) []string {
+	var names []string
+	for i, t := range tokens {
+		if !t.is("WITH") || (i+1 < len(tokens) && tokens[i+1].is("(")) {
+			continue // WITH (NOLOCK) is a table hint
+		}
+		j := i + 1
+		if j < len(tokens) && tokens[j].is("RECURSIVE") {
+			j++
+		}
+		for j < len(tokens) && tokens[j].word() {
+			name := tokens[j].text
+			j++
+			if j < len(tokens) && tokens[j].is("(") {
+				j = skipGroup(tokens, j) // Column list
+			}
+			if j >= len(tokens) || !tokens[j].is("AS") {
+				break
+			}
+			names = append(names, strings.ToLower(name))
+			for j < len(tokens) && !tokens[j].is("(") {
+				j++ // [NOT] MATERIALIZED
+			}
+			j = skipGroup(tokens, j)
+			if j >= len(tokens) || !tokens[j].is(",") {
+				break
+			}
+			j++
+		}
+	}
+	return names
+}
+
+func isKeyword(t token, keywords []string) bool {
+	if t.quoted || t.punct {
+		return false
+	}
+	return slices.Contains(keywords, strings.ToUpper(t.text))
+}
+
+// IsSelectOnly reports whether every statement in the SQL is a query: each starts
+// with SELECT, WITH or a parenthesis, none can modify the database (see
+// RequiresWriteAccess), and none selects INTO a table or variable.
+func IsSelectOnly(sql string) bool {
+	if RequiresWriteAccess(sql) {
+		return false
+	}
+	start := true
+	for _, t := range tokenize(sql) {
+		if t.is(";") {
+			start = true
+			continue
+		}
+		if start && !t.is("SELECT") && !t.is("WITH") && !t.is("(") {
+			return false
+		}
+		start = false
+		if t.is("INTO") {
+			return false
+		}
+	}
+	return true
+}
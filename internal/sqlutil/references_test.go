@@ -0,0 +1,121 @@
+package sqlutil
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestTableReferences verifies extraction of referenced tables, views and routines
+func TestTableReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		// Queries
+		{"simple select", "SELECT * FROM users WHERE id = @id", []string{"users"}},
+		{"qualified", "SELECT * FROM dbo.Orders o", []string{"dbo.Orders"}},
+		{"three part", "SELECT * FROM sales.dbo.Orders", []string{"sales.dbo.Orders"}},
+		{"default schema", "SELECT * FROM sales..Orders", []string{"sales..Orders"}},
+		{"quoted", `SELECT * FROM [dbo].[Order Items] JOIN "audit" ON 1 = 1 JOIN ` + "`log`", []string{"dbo.Order Items", "audit", "log"}},
+		{"joins", "SELECT * FROM a INNER JOIN b ON a.id = b.id LEFT OUTER JOIN c x ON x.id = a.id", []string{"a", "b", "c"}},
+		{"comma list", "SELECT * FROM a x, b AS y, c WITH (NOLOCK) WHERE x.id = y.id", []string{"a", "b", "c"}},
+		{"subquery", "SELECT * FROM a WHERE id IN (SELECT a_id FROM b)", []string{"a", "b"}},
+		{"derived table", "SELECT * FROM (SELECT * FROM a) t, b", []string{"b", "a"}},
+		{"cte", "WITH recent AS (SELECT * FROM orders) SELECT * FROM recent JOIN users ON 1 = 1", []string{"orders", "users"}},
+		{"cte scoped to statement", "WITH x AS (SELECT 1 AS v) SELECT * FROM x; SELECT * FROM x", []string{"x"}},
+		{"cross apply", "SELECT * FROM a CROSS APPLY dbo.fn(a.id) f", []string{"a", "dbo.fn"}},
+		{"extract", "SELECT EXTRACT(YEAR FROM created) FROM orders", []string{"orders"}},
+		{"trim", "SELECT TRIM(' ' FROM name) FROM users", []string{"users"}},
+		{"count subquery", "SELECT COUNT(*), (SELECT MAX(id) FROM b) FROM a", []string{"b", "a"}},
+		{"top subquery", "SELECT TOP (SELECT n FROM limits) * FROM a", []string{"limits", "a"}},
+		{"for update", "SELECT * FROM a FOR UPDATE", []string{"a"}},
+		{"index hint", "SELECT * FROM a USE INDEX (ix) WHERE 1 = 1", []string{"a"}},
+		{"literals and comments", "SELECT 'FROM secret' FROM a -- JOIN secret\n/* FROM secret */", []string{"a"}},
+		{"variables and temp tables", "SELECT * FROM @rows r JOIN #work w ON 1 = 1", nil},
+		{"no tables", "SELECT 1", nil},
+
+		// Modifications
+		{"insert", "INSERT INTO orders (id) VALUES (@id)", []string{"orders"}},
+		{"insert without into", "INSERT orders VALUES (1)", []string{"orders"}},
+		{"insert or replace", "INSERT OR REPLACE INTO orders (id) VALUES (1)", []string{"orders"}},
+		{"insert select", "INSERT INTO archive SELECT * FROM orders", []string{"archive", "orders"}},
+		{"upsert", "INSERT INTO t (id) VALUES (1) ON DUPLICATE KEY UPDATE id = 1", []string{"t"}},
+		{"update", "UPDATE orders SET status = @status WHERE id = @id", []string{"orders"}},
+		{"update from", "UPDATE o SET o.x = s.x FROM orders o JOIN staging s ON o.id = s.id", []string{"o", "orders", "staging"}},
+		{"delete", "DELETE FROM orders WHERE id = @id", []string{"orders"}},
+		{"merge", "MERGE INTO target t USING source s ON t.id = s.id WHEN MATCHED THEN UPDATE SET t.x = s.x", []string{"target", "source"}},
+		{"truncate", "TRUNCATE TABLE logs; TRUNCATE audit", []string{"logs", "audit"}},
+		{"create if not exists", "CREATE TABLE IF NOT EXISTS t (id INT REFERENCES u ON DELETE CASCADE)", []string{"t"}},
+		{"drop if exists", "DROP TABLE IF EXISTS t", []string{"t"}},
+		{"select into", "SELECT * INTO backup FROM orders", []string{"backup", "orders"}},
+
+		// Routines
+		{"exec", "EXEC dbo.GetOrders @id = 1", []string{"dbo.GetOrders"}},
+		{"exec return status", "EXEC @rc = dbo.GetOrders", []string{"dbo.GetOrders"}},
+		{"sp_executesql", "EXEC sp_executesql @sql", []string{"sp_executesql"}},
+		{"call", "CALL get_orders(1)", []string{"get_orders"}},
+		{"use", "USE other; SELECT 1", []string{"other"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TableReferences(tt.sql)
+			if err != nil {
+				t.Fatalf("TableReferences(%q) error: %v", tt.sql, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TableReferences(%q)\n  got:  %q\n  want: %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTableReferences_DynamicSQL verifies dynamic SQL is reported as uncheckable
+func TestTableReferences_DynamicSQL(t *testing.T) {
+	for _, sql := range []string{
+		"EXEC (@sql)",
+		"EXECUTE('SELECT * FROM secret')",
+		"EXEC @proc",
+		"SELECT 1; EXEC @rc = @proc",
+	} {
+		t.Run(sql, func(t *testing.T) {
+			_, err := TableReferences(sql)
+			if !errors.Is(err, ErrUncheckableStatement) {
+				t.Errorf("TableReferences(%q) error = %v, want ErrUncheckableStatement", sql, err)
+			}
+		})
+	}
+}
+
+// TestIsSelectOnly verifies detection of read-only queries
+func TestIsSelectOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want bool
+	}{
+		{"select", "SELECT * FROM users", true},
+		{"cte", "WITH x AS (SELECT 1 AS v) SELECT * FROM x", true},
+		{"parenthesized union", "(SELECT 1) UNION (SELECT 2)", true},
+		{"multiple selects", "SELECT 1; SELECT 2;", true},
+		{"keyword in literal", "SELECT 'DELETE FROM users'", true},
+		{"insert", "INSERT INTO users (name) VALUES ('x')", false},
+		{"select then delete", "SELECT 1; DELETE FROM users", false},
+		{"select into", "SELECT * INTO backup FROM users", false},
+		{"select into variable", "SELECT id INTO @id FROM users", false},
+		{"cte with delete", "WITH x AS (SELECT 1) DELETE FROM users", false},
+		{"exec", "EXEC dbo.GetUsers", false},
+		{"pragma", "PRAGMA table_info(users)", false},
+		{"set", "SET NOCOUNT ON; SELECT 1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSelectOnly(tt.sql); got != tt.want {
+				t.Errorf("IsSelectOnly(%q) = %v, want %v", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
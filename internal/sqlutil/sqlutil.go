@@ -1,6 +1,7 @@
 package sqlutil
 
 import (
+	"fmt"
 	"regexp"
 	"slices"
 	"strings"
@@ -9,10 +10,10 @@ import (
 // ParamRegex matches @param style named parameters in SQL queries.
 var ParamRegex = regexp.MustCompile(`@(\w+)`)
 
-// stripLiterals removes string literals, comments, and quoted identifiers from SQL,
+// StripLiterals removes string literals, comments, and quoted identifiers from SQL,
 // replacing them with spaces. This allows keyword detection without false matches
 // on content inside strings or comments.
-func stripLiterals(sql string) string {
+func StripLiterals(sql string) string {
 	var buf strings.Builder
 	buf.Grow(len(sql))
 	i := 0
@@ -127,7 +128,7 @@ var writeKeywords = []string{"INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "AL
 // IsWriteQuery returns true if the SQL is a write operation (INSERT, UPDATE, DELETE, etc.).
 // Uses literal-aware parsing to avoid false matches on keywords inside strings or comments.
 func IsWriteQuery(sql string) bool {
-	stripped := stripLiterals(sql)
+	stripped := StripLiterals(sql)
 	upper := strings.ToUpper(strings.TrimSpace(stripped))
 	if upper == "" {
 		return false
@@ -174,7 +175,7 @@ var mutatingKeywords = slices.Concat(writeKeywords, procedureKeywords)
 // quoted identifier requires write access. All of these words are reserved in SQL,
 // so a genuine read-only statement has no legitimate reason to contain one.
 func RequiresWriteAccess(sql string) bool {
-	upper := strings.ToUpper(stripLiterals(sql))
+	upper := strings.ToUpper(StripLiterals(sql))
 
 	for i := 0; i < len(upper); {
 		if !isIdentChar(upper[i]) {
@@ -197,7 +198,7 @@ func RequiresWriteAccess(sql string) bool {
 // OUTPUT DELETED (SQL Server), or RETURNING (PostgreSQL/SQLite 3.35+).
 // These indicate the write statement returns rows that should be captured.
 func HasReturningClause(sql string) bool {
-	stripped := stripLiterals(sql)
+	stripped := StripLiterals(sql)
 	upper := strings.ToUpper(stripped)
 	if strings.Contains(upper, "OUTPUT INSERTED") ||
 		strings.Contains(upper, "OUTPUT DELETED") {
@@ -223,3 +224,69 @@ func HasReturningClause(sql string) bool {
 func isIdentChar(c byte) bool {
 	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_'
 }
+
+// IncludeRegex matches {{include "name"}} snippet references in SQL.
+var IncludeRegex = regexp.MustCompile(`\{\{\s*include\s+"([^"]*)"\s*\}\}`)
+
+// ExpandSnippets resolves includes inside a snippet library so each snippet is plain SQL.
+// Returns an error for references to unknown snippets and for include cycles.
+func ExpandSnippets(snippets map[string]string) (map[string]string, error) {
+	expanded := make(map[string]string, len(snippets))
+	var resolve func(name string, chain []string) (string, error)
+	resolve = func(name string, chain []string) (string, error) {
+		if sql, ok := expanded[name]; ok {
+			return sql, nil
+		}
+		if slices.Contains(chain, name) {
+			return "", fmt.Errorf("sql snippet %q includes itself (%s)", name, strings.Join(append(chain, name), " -> "))
+		}
+		sql, ok := snippets[name]
+		if !ok {
+			return "", fmt.Errorf("unknown sql snippet %q", name)
+		}
+		chain = append(chain, name)
+
+		var resolveErr error
+		sql = IncludeRegex.ReplaceAllStringFunc(sql, func(match string) string {
+			body, err := resolve(IncludeRegex.FindStringSubmatch(match)[1], chain)
+			if err != nil && resolveErr == nil {
+				resolveErr = err
+			}
+			return body
+		})
+		if resolveErr != nil {
+			return "", resolveErr
+		}
+		expanded[name] = sql
+		return sql, nil
+	}
+
+	names := make([]string, 0, len(snippets))
+	for name := range snippets {
+		names = append(names, name)
+	}
+	slices.Sort(names) // Deterministic error reporting
+	for _, name := range names {
+		if _, err := resolve(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return expanded, nil
+}
+
+// ExpandIncludes replaces {{include "name"}} references in SQL with snippets resolved by ExpandSnippets.
+func ExpandIncludes(sql string, snippets map[string]string) (string, error) {
+	var err error
+	sql = IncludeRegex.ReplaceAllStringFunc(sql, func(match string) string {
+		name := IncludeRegex.FindStringSubmatch(match)[1]
+		body, ok := snippets[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("unknown sql snippet %q", name)
+		}
+		return body
+	})
+	if err != nil {
+		return "", err
+	}
+	return sql, nil
+}
@@ -30,9 +30,9 @@ func TestStripLiterals(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := stripLiterals(tt.input)
+			got := StripLiterals(tt.input)
 			if got != tt.want {
-				t.Errorf("stripLiterals(%q)\n  got:  %q\n  want: %q", tt.input, got, tt.want)
+				t.Errorf("StripLiterals(%q)\n  got:  %q\n  want: %q", tt.input, got, tt.want)
 			}
 		})
 	}
@@ -234,3 +234,52 @@ func TestParamRegex(t *testing.T) {
 		})
 	}
 }
+
+// TestExpandSnippets verifies nested includes resolve and cycles or unknown names are rejected
+func TestExpandSnippets(t *testing.T) {
+	got, err := ExpandSnippets(map[string]string{
+		"active":      "u.deleted_at IS NULL",
+		"active_user": `JOIN users u ON u.id = o.user_id AND {{include "active"}}`,
+		"orders":      `FROM orders o {{include "active_user"}}`,
+	})
+	if err != nil {
+		t.Fatalf("ExpandSnippets failed: %v", err)
+	}
+	if want := "FROM orders o JOIN users u ON u.id = o.user_id AND u.deleted_at IS NULL"; got["orders"] != want {
+		t.Errorf("orders = %q, want %q", got["orders"], want)
+	}
+
+	tests := []struct {
+		name     string
+		snippets map[string]string
+		wantErr  string
+	}{
+		{"unknown", map[string]string{"a": `{{include "missing"}}`}, `unknown sql snippet "missing"`},
+		{"self", map[string]string{"a": `{{include "a"}}`}, `sql snippet "a" includes itself (a -> a)`},
+		{"cycle", map[string]string{"a": `{{include "b"}}`, "b": `{{include "a"}}`}, `sql snippet "a" includes itself (a -> b -> a)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ExpandSnippets(tt.snippets); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ExpandSnippets() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestExpandIncludes verifies include references in SQL are replaced with snippets
+func TestExpandIncludes(t *testing.T) {
+	snippets := map[string]string{"active": "deleted_at IS NULL"}
+
+	got, err := ExpandIncludes(`SELECT * FROM users WHERE {{ include "active" }} AND id = @id`, snippets)
+	if err != nil {
+		t.Fatalf("ExpandIncludes failed: %v", err)
+	}
+	if want := "SELECT * FROM users WHERE deleted_at IS NULL AND id = @id"; got != want {
+		t.Errorf("ExpandIncludes() = %q, want %q", got, want)
+	}
+
+	if _, err := ExpandIncludes(`SELECT {{include "nope"}}`, snippets); err == nil || err.Error() != `unknown sql snippet "nope"` {
+		t.Errorf("ExpandIncludes() error = %v, want unknown snippet", err)
+	}
+}
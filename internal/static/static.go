@@ -0,0 +1,99 @@
+// Package static serves a directory of files (stylesheets, scripts, images and
+// plain HTML pages) under a URL prefix.
+//
+// Directories are served through their index.html and are never listed, and
+// files or directories whose name starts with a dot (.git, .env) are hidden.
+package static
+
+import (
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Config describes a directory served under a URL prefix
+type Config struct {
+	Prefix    string // URL prefix ending in "/", stripped before looking up files
+	Dir       string // Directory the files are read from
+	MaxAgeSec int    // Cache-Control max-age of successful responses (0 = no header)
+}
+
+// Handler serves the files of cfg.Dir under cfg.Prefix
+func Handler(cfg Config) http.Handler {
+	files := http.FileServerFS(hiddenFS{os.DirFS(cfg.Dir)})
+	cacheControl := ""
+	if cfg.MaxAgeSec > 0 {
+		cacheControl = "public, max-age=" + strconv.Itoa(cfg.MaxAgeSec)
+	}
+	return http.StripPrefix(strings.TrimSuffix(cfg.Prefix, "/"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cacheControl != "" {
+			w = &cacheWriter{ResponseWriter: w, value: cacheControl}
+		}
+		files.ServeHTTP(w, r)
+	}))
+}
+
+// hiddenFS hides dotfiles and directories without an index.html
+type hiddenFS struct {
+	fsys fs.FS
+}
+
+func (h hiddenFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for part := range strings.SplitSeq(name, "/") {
+		if strings.HasPrefix(part, ".") && part != "." {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		// The file server serves index.html itself; anything else would be a listing
+		if _, err := fs.Stat(h.fsys, path.Join(name, "index.html")); err != nil {
+			_ = f.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	return f, nil
+}
+
+// cacheWriter sets Cache-Control on successful and not-modified responses only,
+// so errors and redirects are not cached by clients
+type cacheWriter struct {
+	http.ResponseWriter
+	value   string
+	written bool
+}
+
+func (cw *cacheWriter) WriteHeader(status int) {
+	if !cw.written {
+		cw.written = true
+		if status < 300 || status == http.StatusNotModified {
+			cw.Header().Set("Cache-Control", cw.value)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cacheWriter) Write(b []byte) (int, error) {
+	if !cw.written {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *cacheWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
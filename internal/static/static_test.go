@@ -0,0 +1,102 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files (path -> content) under a temporary directory
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestHandler(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"index.html":       "<h1>home</h1>",
+		"css/app.css":      "body{}",
+		"docs/index.html":  "<h1>docs</h1>",
+		".env":             "SECRET=1",
+		".git/config":      "[core]",
+		"img/.hidden.png":  "png",
+		"img/logo.svg":     "<svg/>",
+		"nested/deep/a.js": "let a",
+	})
+	h := Handler(Config{Prefix: "/ui/", Dir: dir})
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{name: "root index", path: "/ui/", status: http.StatusOK, body: "<h1>home</h1>"},
+		{name: "file", path: "/ui/css/app.css", status: http.StatusOK, body: "body{}"},
+		{name: "nested file", path: "/ui/nested/deep/a.js", status: http.StatusOK, body: "let a"},
+		{name: "directory index", path: "/ui/docs/", status: http.StatusOK, body: "<h1>docs</h1>"},
+		{name: "directory without index", path: "/ui/css/", status: http.StatusNotFound},
+		{name: "dotfile", path: "/ui/.env", status: http.StatusNotFound},
+		{name: "dot directory", path: "/ui/.git/config", status: http.StatusNotFound},
+		{name: "dotfile in directory", path: "/ui/img/.hidden.png", status: http.StatusNotFound},
+		{name: "missing", path: "/ui/missing.txt", status: http.StatusNotFound},
+		{name: "traversal", path: "/ui/../../etc/passwd", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if strings.Contains(w.Body.String(), "SECRET") || strings.Contains(w.Body.String(), "[core]") {
+				t.Error("hidden file content served")
+			}
+		})
+	}
+}
+
+func TestHandler_CacheControl(t *testing.T) {
+	dir := writeTree(t, map[string]string{"app.js": "let a"})
+
+	h := Handler(Config{Prefix: "/assets/", Dir: dir, MaxAgeSec: 3600})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/assets/app.js", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/assets/missing.js", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "" {
+		t.Errorf("Cache-Control on a 404 = %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	Handler(Config{Prefix: "/assets/", Dir: dir}).ServeHTTP(w, httptest.NewRequest("GET", "/assets/app.js", nil))
+	if got := w.Header().Get("Cache-Control"); got != "" {
+		t.Errorf("Cache-Control without max_age_sec = %q", got)
+	}
+}
@@ -0,0 +1,114 @@
+// Package systemd implements the parts of the systemd service protocol the
+// server uses: socket activation (LISTEN_FDS), readiness and status
+// notifications (sd_notify) and the service watchdog (WATCHDOG_USEC).
+//
+// Each function is a no-op returning zero values when the process was not
+// started by systemd with the corresponding feature enabled.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notification states sent with Notify
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// listenFDsStart is the first file descriptor passed by socket activation
+const listenFDsStart = 3
+
+// Listener is a socket passed by systemd socket activation
+type Listener struct {
+	net.Listener
+	Name string // FileDescriptorName= of the socket unit (default: the unit name)
+}
+
+// Listeners returns the sockets systemd passed to this process, in the order
+// of the socket unit's Listen*= lines. The environment variables are cleared
+// so child processes do not inherit them.
+func Listeners() ([]Listener, error) {
+	return listeners(listenFDsStart)
+}
+
+func listeners(first int) ([]Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil // Not for this process
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	if len(names) < count {
+		names = append(names, make([]string, count-len(names))...)
+	}
+	return FileListeners(first, names[:count])
+}
+
+// FileListeners returns listeners for the sockets open on file descriptors
+// first, first+1, ..., one per name
+func FileListeners(first int, names []string) ([]Listener, error) {
+	var result []Listener
+	for i, name := range names {
+		f := os.NewFile(uintptr(first+i), name)
+		ln, err := net.FileListener(f)
+		_ = f.Close() // FileListener holds its own duplicate
+		if err != nil {
+			for _, l := range result {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("socket %d (%s): %w", first+i, name, err)
+		}
+		result = append(result, Listener{Listener: ln, Name: name})
+	}
+	return result, nil
+}
+
+// Notify sends state to the service manager. It reports false when the
+// process is not run by systemd with Type=notify.
+func Notify(state string) (bool, error) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return false, nil
+	}
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:] // Abstract socket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the WatchdogSec= of the service, or 0 when the
+// watchdog is disabled. Watchdog notifications are due at least this often;
+// sending them at half the interval leaves room for delays.
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
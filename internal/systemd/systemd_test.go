@@ -0,0 +1,120 @@
+//go:build !windows
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestListeners verifies passed sockets are returned with their names and the environment is cleared
+func TestListeners(t *testing.T) {
+	var files []*os.File
+	for range 2 {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := ln.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = ln.Close()
+		files = append(files, f)
+	}
+	first := int(files[0].Fd())
+	if int(files[1].Fd()) != first+1 {
+		t.Skip("listener files do not have consecutive descriptors")
+	}
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "2")
+	t.Setenv("LISTEN_FDNAMES", "http:admin")
+	got, err := listeners(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "http" || got[1].Name != "admin" {
+		t.Fatalf("listeners = %+v", got)
+	}
+	for _, l := range got {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Errorf("dial %s: %v", l.Name, err)
+			continue
+		}
+		_ = conn.Close()
+		_ = l.Close()
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("LISTEN_FDS not cleared")
+	}
+}
+
+// TestListeners_OtherProcess verifies sockets passed to another process are ignored
+func TestListeners_OtherProcess(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	got, err := listeners(listenFDsStart)
+	if err != nil || got != nil {
+		t.Errorf("listeners() = %v, %v; want nil, nil", got, err)
+	}
+}
+
+// TestNotify verifies notifications are sent to NOTIFY_SOCKET when it is set
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := Notify(Ready); sent || err != nil {
+		t.Errorf("Notify() without NOTIFY_SOCKET = %v, %v", sent, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if sent, err := Notify(Ready); !sent || err != nil {
+		t.Fatalf("Notify() = %v, %v", sent, err)
+	}
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != Ready {
+		t.Errorf("received %q, want %q", got, Ready)
+	}
+}
+
+// TestWatchdogInterval tests WATCHDOG_USEC parsing and the WATCHDOG_PID check
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		usec string
+		pid  string
+		want time.Duration
+	}{
+		{name: "disabled", want: 0},
+		{name: "enabled", usec: "30000000", want: 30 * time.Second},
+		{name: "for this process", usec: "10000000", pid: strconv.Itoa(os.Getpid()), want: 10 * time.Second},
+		{name: "for another process", usec: "10000000", pid: "1", want: 0},
+		{name: "invalid", usec: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+			if got := WatchdogInterval(); got != tt.want {
+				t.Errorf("WatchdogInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -27,9 +29,18 @@ import (
 	"text/template"
 	"text/template/parse"
 	"time"
+	_ "time/tzdata" // Zone names for nowIn and friends work without a system zoneinfo database
 
 	"github.com/google/uuid"
-
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"sql-proxy/internal/decimal"
+	"sql-proxy/internal/i18n"
+	"sql-proxy/internal/jq"
+	"sql-proxy/internal/jwt"
+	"sql-proxy/internal/keyring"
+	"sql-proxy/internal/locale"
 	"sql-proxy/internal/publicid"
 )
 
@@ -47,6 +58,8 @@ type Engine struct {
 	templates map[string]*compiledTemplate
 	funcs     template.FuncMap
 	publicIDs *publicid.Encoder // Optional public ID encoder
+	keyring   *keyring.Keyring  // Optional keys for encrypt/decrypt
+	messages  *i18n.Catalog     // Optional message catalog for t
 }
 
 type compiledTemplate struct {
@@ -58,7 +71,15 @@ type compiledTemplate struct {
 // This can be used by other packages (like workflow compile) that need
 // the same functions at compile time. Note: publicID and privateID
 // require an engine instance and are not included here.
+// Functions registered by plugins are included.
 func BaseFuncMap() template.FuncMap {
+	fm := baseFuncMap()
+	addPluginFuncs(fm)
+	return fm
+}
+
+// baseFuncMap returns the built-in template functions.
+func baseFuncMap() template.FuncMap {
 	return template.FuncMap{
 		// Strict access - errors if key missing or empty
 		"require": requireFunc,
@@ -119,6 +140,14 @@ func BaseFuncMap() template.FuncMap {
 			return math.Max(toNumber(a), toNumber(b))
 		},
 
+		// Decimal arithmetic - exact, for money and DECIMAL columns
+		"decAdd":   decAddFunc,
+		"decSub":   decSubFunc,
+		"decMul":   decMulFunc,
+		"decDiv":   decDivFunc,
+		"decRound": decRoundFunc,
+		"decCmp":   decCmpFunc,
+
 		// Numeric conversion/formatting
 		"int64": func(v any) int64 {
 			return int64(toNumber(v))
@@ -206,6 +235,16 @@ func BaseFuncMap() template.FuncMap {
 		"md5":            md5Func,
 		"hmacSHA256":     hmacSHA256Func,
 
+		// Password hashing
+		"bcryptHash":   bcryptHashFunc,
+		"bcryptVerify": bcryptVerifyFunc,
+		"argon2Hash":   argon2HashFunc,
+		"argon2Verify": argon2VerifyFunc,
+
+		// JWT
+		"jwtSign":   jwtSignFunc,
+		"jwtVerify": jwtVerifyFunc,
+
 		// String helpers
 		"truncate": truncateFunc,
 		"split":    splitFunc,
@@ -218,10 +257,19 @@ func BaseFuncMap() template.FuncMap {
 		// Date/time functions
 		"now":         nowFunc,
 		"formatTime":  formatTimeFunc,
+		"formatDate":  formatDateFunc,
 		"parseTime":   parseTimeFunc,
 		"parseTimeOr": parseTimeOrFunc,
 		"unixTime":    unixTimeFunc,
 
+		// Time zone aware date/time functions (zones are IANA names like "Europe/Berlin")
+		"nowIn":        nowInFunc,
+		"formatTimeIn": formatTimeInFunc,
+		"dateAdd":      dateAddFunc,
+		"dateDiff":     dateDiffFunc,
+		"startOf":      startOfFunc,
+		"endOf":        endOfFunc,
+
 		// JSON helpers
 		"pick":  pickFunc,
 		"omit":  omitFunc,
@@ -233,6 +281,7 @@ func BaseFuncMap() template.FuncMap {
 
 		// Safe navigation
 		"dig": digFunc,
+		"jq":  jqFunc,
 
 		// Debug helpers
 		"typeOf": typeOfFunc,
@@ -240,9 +289,10 @@ func BaseFuncMap() template.FuncMap {
 		"values": valuesFunc,
 
 		// Numeric formatting
-		"formatNumber":  formatNumberFunc,
-		"formatPercent": formatPercentFunc,
-		"formatBytes":   formatBytesFunc,
+		"formatNumber":   formatNumberFunc,
+		"formatPercent":  formatPercentFunc,
+		"formatBytes":    formatBytesFunc,
+		"formatCurrency": formatCurrencyFunc,
 
 		// Comparison operators for templates
 		"eq": reflect.DeepEqual,
@@ -265,7 +315,15 @@ func BaseFuncMap() template.FuncMap {
 // ExprFuncs returns functions suitable for expr condition evaluation.
 // These are a subset of template functions that work well in expr conditions.
 // Does not include workflow-specific functions like isValidPublicID.
+// Functions registered by plugins are included.
 func ExprFuncs() map[string]any {
+	m := baseExprFuncs()
+	addPluginExprFuncs(m)
+	return m
+}
+
+// baseExprFuncs returns the built-in expression functions.
+func baseExprFuncs() map[string]any {
 	return map[string]any{
 		// Safe division/modulo (prevents divide-by-zero panics)
 		"divOr": func(a, b, defaultVal any) float64 {
@@ -283,6 +341,15 @@ func ExprFuncs() map[string]any {
 			return math.Mod(toNumber(a), bv)
 		},
 
+		// Decimal arithmetic
+		"decAdd":         decAddFunc,
+		"decSub":         decSubFunc,
+		"decMul":         decMulFunc,
+		"decDiv":         decDivFunc,
+		"decRound":       decRoundFunc,
+		"decCmp":         decCmpFunc,
+		"formatCurrency": formatCurrencyFunc,
+
 		// String functions
 		// Note: "contains" and "matches" are built-in expr operators, not functions
 		// Use: s contains "substr" and s matches "^pattern$"
@@ -297,6 +364,7 @@ func ExprFuncs() map[string]any {
 		"isEmpty": isEmptyFunc,
 		"first":   firstFunc,
 		"last":    lastFunc,
+		"jq":      jqFunc,
 
 		// Validation helpers
 		// Note: "matches" is a built-in expr operator: s matches "^pattern$"
@@ -308,6 +376,16 @@ func ExprFuncs() map[string]any {
 		"isIPv6":    isIPv6Func,
 		"isNumeric": isNumericFunc,
 
+		// Password hashing
+		"bcryptHash":   bcryptHashFunc,
+		"bcryptVerify": bcryptVerifyFunc,
+		"argon2Hash":   argon2HashFunc,
+		"argon2Verify": argon2VerifyFunc,
+
+		// JWT
+		"jwtSign":   jwtSignFunc,
+		"jwtVerify": jwtVerifyFunc,
+
 		// Coalesce - first non-empty string
 		"coalesce": coalesceFunc,
 	}
@@ -323,6 +401,9 @@ func New() *Engine {
 	// Add engine-specific functions (these need the engine instance)
 	e.funcs["publicID"] = e.publicIDFunc
 	e.funcs["privateID"] = e.privateIDFunc
+	e.funcs["encrypt"] = e.encryptFunc
+	e.funcs["decrypt"] = e.decryptFunc
+	e.funcs["t"] = e.translateFunc
 	return e
 }
 
@@ -371,6 +452,79 @@ func (e *Engine) privateIDFunc(namespace string, publicID string) (int64, error)
 	return enc.Decode(namespace, publicID)
 }
 
+// SetKeyring configures the keys for encrypt/decrypt functions
+func (e *Engine) SetKeyring(k *keyring.Keyring) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.keyring = k
+}
+
+// encryptFunc encrypts a value with the active crypto key
+func (e *Engine) encryptFunc(value any) (string, error) {
+	e.mu.RLock()
+	k := e.keyring
+	e.mu.RUnlock()
+
+	if k == nil {
+		return "", fmt.Errorf("encrypt: crypto_keys not configured")
+	}
+	return k.Encrypt(CipherText(value))
+}
+
+// decryptFunc decrypts a value produced by encrypt
+func (e *Engine) decryptFunc(value any) (string, error) {
+	e.mu.RLock()
+	k := e.keyring
+	e.mu.RUnlock()
+
+	if k == nil {
+		return "", fmt.Errorf("decrypt: crypto_keys not configured")
+	}
+	return k.Decrypt(CipherText(value))
+}
+
+// SetMessages configures the message catalog for the t function
+func (e *Engine) SetMessages(c *i18n.Catalog) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.messages = c
+}
+
+// translateFunc returns the catalog message for key in lang (see i18n.Catalog.Translate)
+func (e *Engine) translateFunc(key string, lang any, args ...any) (string, error) {
+	e.mu.RLock()
+	c := e.messages
+	e.mu.RUnlock()
+
+	if c == nil {
+		return "", fmt.Errorf("t: messages not configured")
+	}
+	return c.Translate(key, LanguageArg(lang), args...), nil
+}
+
+// LanguageArg converts the language argument of t to a tag; nil (a missing
+// trigger field) selects the default language
+func LanguageArg(lang any) string {
+	if lang == nil {
+		return ""
+	}
+	return fmt.Sprint(lang)
+}
+
+// CipherText converts a template value to the string encrypt/decrypt operate on:
+// nil becomes "" (so NULL columns stay empty) and []byte is used as-is
+func CipherText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(value)
+}
+
 // requireFunc returns value from map, errors if missing or empty
 func requireFunc(m map[string]string, key string) (string, error) {
 	if m == nil {
@@ -1177,6 +1331,160 @@ func hmacSHA256Func(key, message string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// ============================================================================
+// Password hashing
+// ============================================================================
+
+// argon2id parameters for argon2Hash (OWASP minimum: 19 MiB, 2 iterations, 1 thread)
+const (
+	argon2Memory  = 19 * 1024
+	argon2Time    = 2
+	argon2Threads = 1
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+// bcryptHashFunc hashes a password with bcrypt at the given cost (default 10)
+func bcryptHashFunc(password string, cost ...int) (string, error) {
+	c := bcrypt.DefaultCost
+	if len(cost) > 0 {
+		c = cost[0]
+	}
+	if c < bcrypt.MinCost || c > bcrypt.MaxCost {
+		return "", fmt.Errorf("bcryptHash: cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, c)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), c)
+	if err != nil {
+		return "", fmt.Errorf("bcryptHash: %w", err)
+	}
+	return string(hash), nil
+}
+
+// bcryptVerifyFunc reports whether password matches a bcrypt hash.
+// Returns false for missing or malformed hashes.
+func bcryptVerifyFunc(hash any, password string) bool {
+	h, ok := hashBytes(hash)
+	return ok && bcrypt.CompareHashAndPassword(h, []byte(password)) == nil
+}
+
+// argon2HashFunc hashes a password with argon2id and a random salt, encoded in
+// the PHC string format: $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+func argon2HashFunc(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("argon2Hash: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// argon2MaxMemory bounds the memory a stored hash may ask argon2Verify to use (256 MiB)
+const argon2MaxMemory = 256 * 1024
+
+// argon2VerifyFunc reports whether password matches an argon2id or argon2i PHC hash,
+// using the parameters stored in the hash. Returns false for missing or malformed hashes.
+func argon2VerifyFunc(hash any, password string) bool {
+	h, ok := hashBytes(hash)
+	if !ok {
+		return false
+	}
+	// "", variant, version, params, salt, key
+	parts := strings.Split(string(h), "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false
+	}
+	if memory == 0 || memory > argon2MaxMemory || iterations == 0 || threads == 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+
+	var got []byte
+	switch parts[1] {
+	case "argon2id":
+		got = argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(want)))
+	case "argon2i":
+		got = argon2.Key([]byte(password), salt, iterations, memory, threads, uint32(len(want)))
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// hashBytes converts a stored hash (string or []byte column) to bytes
+func hashBytes(hash any) ([]byte, bool) {
+	switch h := hash.(type) {
+	case string:
+		return []byte(h), h != ""
+	case []byte:
+		return h, len(h) > 0
+	}
+	return nil, false
+}
+
+// ============================================================================
+// JWT
+// ============================================================================
+
+// jwtSignFunc signs claims (a map or a JSON object string) with key using alg
+// (HS256 or RS256). exp and nbf may be durations relative to now: "15m".
+func jwtSignFunc(claims any, key, alg string) (string, error) {
+	var m map[string]any
+	switch c := claims.(type) {
+	case map[string]any:
+		m = c
+	case map[string]string:
+		m = make(map[string]any, len(c))
+		for k, v := range c {
+			m[k] = v
+		}
+	case string:
+		if err := json.Unmarshal([]byte(c), &m); err != nil || m == nil {
+			return "", fmt.Errorf("jwtSign: claims must be a JSON object")
+		}
+	default:
+		return "", fmt.Errorf("jwtSign: claims must be a map or JSON object string, got %T", claims)
+	}
+	token, err := jwt.Sign(m, key, alg)
+	if err != nil {
+		return "", fmt.Errorf("jwtSign: %w", err)
+	}
+	return token, nil
+}
+
+// jwtVerifyFunc returns the claims of a valid token, or nil when the token is
+// missing, malformed, expired or not signed by key. Unusable keys are errors.
+func jwtVerifyFunc(token any, key string) (map[string]any, error) {
+	s, ok := token.(string)
+	if !ok || s == "" {
+		return nil, nil
+	}
+	claims, err := jwt.Verify(s, key)
+	if errors.Is(err, jwt.ErrInvalidKey) {
+		return nil, fmt.Errorf("jwtVerify: %w", err)
+	}
+	if err != nil {
+		return nil, nil
+	}
+	return claims, nil
+}
+
 // ============================================================================
 // String helpers
 // ============================================================================
@@ -1276,6 +1584,34 @@ func formatTimeFunc(t any, format string) string {
 	return tm.UTC().Format(convertTimeFormat(format))
 }
 
+// formatDateFunc formats the date of a time value in a CLDR style (short,
+// medium, long or full) for a locale, default en-US. Times keep their own
+// offset, so the date is the one in the zone they were written in; Unix
+// timestamps are UTC.
+func formatDateFunc(t any, style string, loc ...string) (string, error) {
+	tm, err := toTime(t, time.UTC)
+	if err != nil {
+		return "", fmt.Errorf("formatDate: %w", err)
+	}
+	switch t.(type) {
+	case int, int32, int64, float64:
+		tm = tm.UTC()
+	}
+	tag := "en-US"
+	if len(loc) > 0 && loc[0] != "" {
+		tag = loc[0]
+	}
+	lt, err := locale.Parse(tag)
+	if err != nil {
+		return "", fmt.Errorf("formatDate: %w", err)
+	}
+	s, err := locale.FormatDate(tm, style, lt)
+	if err != nil {
+		return "", fmt.Errorf("formatDate: %w", err)
+	}
+	return s, nil
+}
+
 // parseTimeFunc parses time string to Unix timestamp
 // Returns 0 on parse error - use parseTimeOr for explicit default
 func parseTimeFunc(s string, format ...string) int64 {
@@ -1308,6 +1644,226 @@ func unixTimeFunc() int64 {
 	return time.Now().Unix()
 }
 
+// nowInFunc returns the current time in zone, in RFC3339 (with the zone's offset)
+// or the specified format
+func nowInFunc(zone string, format ...string) (string, error) {
+	loc, err := loadZone(zone)
+	if err != nil {
+		return "", err
+	}
+	f := time.RFC3339
+	if len(format) > 0 {
+		f = convertTimeFormat(format[0])
+	}
+	return time.Now().In(loc).Format(f), nil
+}
+
+// formatTimeInFunc formats a time value in zone. Strings without an offset
+// (e.g. "2024-03-01 12:00:00") are taken to be in zone already.
+func formatTimeInFunc(t any, zone, format string) (string, error) {
+	loc, err := loadZone(zone)
+	if err != nil {
+		return "", err
+	}
+	tm, err := toTime(t, loc)
+	if err != nil {
+		return "", err
+	}
+	return tm.In(loc).Format(convertTimeFormat(format)), nil
+}
+
+// dateAddFunc adds amount to a time value and returns RFC3339. Amounts are Go
+// durations ("90m", "-1h30m") or a count of days, weeks, months, or years ("1d",
+// "-2w", "3M", "1y"). Calendar amounts keep the wall clock time in the optional
+// zone (default UTC), so adding "1d" across a DST change still lands on midnight.
+func dateAddFunc(t any, amount string, zone ...string) (string, error) {
+	loc, err := loadZone(zone...)
+	if err != nil {
+		return "", err
+	}
+	tm, err := toTime(t, loc)
+	if err != nil {
+		return "", err
+	}
+	tm = tm.In(loc)
+	if m := calendarAmountRegex.FindStringSubmatch(amount); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return addCalendar(tm, n, m[2]).Format(time.RFC3339), nil
+	}
+	d, err := time.ParseDuration(amount)
+	if err != nil {
+		return "", fmt.Errorf("dateAdd: invalid amount %q (use a duration like \"2h\" or a count like \"1d\", \"2w\", \"3M\", \"1y\")", amount)
+	}
+	return tm.Add(d).Format(time.RFC3339), nil
+}
+
+// dateDiffFunc returns the number of whole units (s, m, h, d, w, M, y) from a
+// to b, negative when b is earlier. Days and longer are counted on the calendar
+// of the optional zone (default UTC), so a DST day still counts as one day.
+func dateDiffFunc(a, b any, unit string, zone ...string) (int64, error) {
+	loc, err := loadZone(zone...)
+	if err != nil {
+		return 0, err
+	}
+	from, err := toTime(a, loc)
+	if err != nil {
+		return 0, err
+	}
+	to, err := toTime(b, loc)
+	if err != nil {
+		return 0, err
+	}
+	switch unit {
+	case "s":
+		return int64(to.Sub(from) / time.Second), nil
+	case "m":
+		return int64(to.Sub(from) / time.Minute), nil
+	case "h":
+		return int64(to.Sub(from) / time.Hour), nil
+	case "d", "w", "M", "y":
+		return calendarDiff(from.In(loc), to.In(loc), unit), nil
+	default:
+		return 0, fmt.Errorf("dateDiff: unknown unit %q (use s, m, h, d, w, M, or y)", unit)
+	}
+}
+
+// startOfFunc returns the start of the day, week (Monday), or month containing
+// t in the optional zone (default UTC), in RFC3339
+func startOfFunc(t any, unit string, zone ...string) (string, error) {
+	start, _, err := periodBounds(t, unit, zone)
+	if err != nil {
+		return "", err
+	}
+	return start.Format(time.RFC3339), nil
+}
+
+// endOfFunc returns the last second of the day, week, or month containing t in
+// the optional zone (default UTC), in RFC3339. For SQL ranges, prefer
+// "< startOf (dateAdd t "1d") "day"" over "<= endOf t "day"" to include
+// fractional seconds.
+func endOfFunc(t any, unit string, zone ...string) (string, error) {
+	_, end, err := periodBounds(t, unit, zone)
+	if err != nil {
+		return "", err
+	}
+	return end.Add(-time.Second).Format(time.RFC3339), nil
+}
+
+var calendarAmountRegex = regexp.MustCompile(`^([+-]?\d+)([dwMy])$`)
+
+// addCalendar adds n days, weeks, months, or years to t
+func addCalendar(t time.Time, n int, unit string) time.Time {
+	switch unit {
+	case "d":
+		return t.AddDate(0, 0, n)
+	case "w":
+		return t.AddDate(0, 0, 7*n)
+	case "M":
+		return t.AddDate(0, n, 0)
+	default:
+		return t.AddDate(n, 0, 0)
+	}
+}
+
+// calendarDiff counts the whole calendar units between from and to. It starts
+// from the difference in calendar fields and corrects for partial units.
+func calendarDiff(from, to time.Time, unit string) int64 {
+	if to.Before(from) {
+		return -calendarDiff(to, from, unit)
+	}
+	var n int
+	switch unit {
+	case "d", "w":
+		fy, fm, fd := from.Date()
+		ty, tm, td := to.Date()
+		days := int(time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC).Sub(time.Date(fy, fm, fd, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+		n = days
+		if unit == "w" {
+			n = days / 7
+		}
+	case "M":
+		n = (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+	default:
+		n = to.Year() - from.Year()
+	}
+	for n > 0 && addCalendar(from, n, unit).After(to) {
+		n--
+	}
+	return int64(n)
+}
+
+// periodBounds returns the start of the day, week, or month containing t and
+// the start of the next one, in zone
+func periodBounds(t any, unit string, zone []string) (time.Time, time.Time, error) {
+	loc, err := loadZone(zone...)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	tm, err := toTime(t, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	y, m, d := tm.In(loc).Date()
+	switch unit {
+	case "day":
+		start := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 0, 1), nil
+	case "week":
+		// Weeks start on Monday (ISO 8601)
+		offset := (int(tm.In(loc).Weekday()) + 6) % 7
+		start := time.Date(y, m, d-offset, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 0, 7), nil
+	case "month":
+		start := time.Date(y, m, 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0), nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown period %q (use day, week, or month)", unit)
+	}
+}
+
+// loadZone returns the named IANA zone, or UTC if no zone is given
+func loadZone(zone ...string) (*time.Location, error) {
+	if len(zone) == 0 || zone[0] == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(zone[0])
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", zone[0])
+	}
+	return loc, nil
+}
+
+// timeLayouts are the string forms toTime accepts after RFC3339
+var timeLayouts = []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// toTime converts a time.Time, a time string, or Unix seconds to a time.
+// Strings without an offset ("2024-03-01", "2024-03-01 12:00:00") are in loc.
+func toTime(v any, loc *time.Location) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, fmt.Errorf("nil time")
+		}
+		return *t, nil
+	case string:
+		if tm, err := time.Parse(time.RFC3339, t); err == nil {
+			return tm, nil
+		}
+		for _, layout := range timeLayouts {
+			if tm, err := time.ParseInLocation(layout, t, loc); err == nil {
+				return tm, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse %q as a time (use RFC3339 or YYYY-MM-DD)", t)
+	case int, int32, int64, float64:
+		return time.Unix(int64(toNumber(t)), 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("cannot use %T as a time", v)
+	}
+}
+
 // convertTimeFormat converts common format strings to Go format
 // Replacements are ordered longest-first to prevent partial matches
 func convertTimeFormat(format string) string {
@@ -1477,6 +2033,48 @@ func toInt(v any) (int, bool) {
 	return 0, false
 }
 
+// jqCache caches parsed jq queries, bounded like regexCache
+var jqCache sync.Map
+
+// jqCacheMaxSize is the maximum number of queries to cache
+const jqCacheMaxSize = 1000
+
+// jqCacheSize tracks the approximate size of jqCache
+var jqCacheSize int64
+
+// jqFunc evaluates a jq query against data and returns its single output, or nil
+// when the query produces none. Queries that produce several outputs are an error;
+// wrap them in [ ] to collect an array.
+func jqFunc(query string, data any) (any, error) {
+	var q *jq.Query
+	if cached, ok := jqCache.Load(query); ok {
+		q = cached.(*jq.Query)
+	} else {
+		parsed, err := jq.Parse(query)
+		if err != nil {
+			return nil, fmt.Errorf("jq %q: %w", query, err)
+		}
+		q = parsed
+		if atomic.LoadInt64(&jqCacheSize) < jqCacheMaxSize {
+			if _, loaded := jqCache.LoadOrStore(query, q); !loaded {
+				atomic.AddInt64(&jqCacheSize, 1)
+			}
+		}
+	}
+
+	out, err := q.Run(data)
+	if err != nil {
+		return nil, fmt.Errorf("jq %q: %w", query, err)
+	}
+	switch len(out) {
+	case 0:
+		return nil, nil
+	case 1:
+		return out[0], nil
+	}
+	return nil, fmt.Errorf("jq %q: produced %d results, wrap the query in [ ] to collect them", query, len(out))
+}
+
 // ============================================================================
 // Debug helpers
 // ============================================================================
@@ -1531,12 +2129,29 @@ func valuesFunc(m any) []any {
 // Numeric formatting
 // ============================================================================
 
-// formatNumberFunc formats a number with thousand separators
-func formatNumberFunc(v any, decimals ...int) string {
+// formatNumberFunc formats a number with thousand separators. The optional
+// decimals are followed by an optional locale, whose CLDR separators are used
+// instead of commas: formatNumber 1234.5 2 "de-DE" = "1.234,50"
+func formatNumberFunc(v any, opts ...any) (string, error) {
 	n := toNumber(v)
 	dec := 0
-	if len(decimals) > 0 {
-		dec = decimals[0]
+	if len(opts) > 0 {
+		dec = int(toNumber(opts[0]))
+	}
+	switch len(opts) {
+	case 0, 1:
+	case 2:
+		tag, ok := opts[1].(string)
+		if !ok {
+			return "", fmt.Errorf("formatNumber: locale must be a string, got %T", opts[1])
+		}
+		lt, err := locale.Parse(tag)
+		if err != nil {
+			return "", fmt.Errorf("formatNumber: %w", err)
+		}
+		return locale.FormatNumber(n, dec, lt), nil
+	default:
+		return "", fmt.Errorf("formatNumber: expected a number, decimals and locale, got %d arguments", len(opts)+1)
 	}
 
 	// Format with decimals
@@ -1554,23 +2169,14 @@ func formatNumberFunc(v any, decimals ...int) string {
 		intPart = intPart[1:]
 	}
 
-	// Add commas
-	var result strings.Builder
-	for i, c := range intPart {
-		if i > 0 && (len(intPart)-i)%3 == 0 {
-			result.WriteRune(',')
-		}
-		result.WriteRune(c)
-	}
-
-	formatted := result.String()
+	formatted := groupThousands(intPart)
 	if negative {
 		formatted = "-" + formatted
 	}
 	if len(parts) > 1 {
 		formatted += "." + parts[1]
 	}
-	return formatted
+	return formatted, nil
 }
 
 // formatPercentFunc formats a decimal as percentage
@@ -1610,6 +2216,189 @@ func formatBytesFunc(v any) string {
 	return result
 }
 
+// groupThousands inserts commas between groups of three digits
+func groupThousands(digits string) string {
+	var result strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			result.WriteRune(',')
+		}
+		result.WriteRune(c)
+	}
+	return result.String()
+}
+
+// ============================================================================
+// Decimal arithmetic
+// ============================================================================
+
+// defaultDivPlaces is the precision of decDiv when no places are given
+const defaultDivPlaces = 10
+
+// toDecimal converts a value to a decimal. Strings keep their exact digits, so
+// DECIMAL columns (which drivers return as strings) lose nothing; floats use
+// their shortest form, so 0.1 is 0.1. NULL is an error rather than a silent 0.
+func toDecimal(v any) (decimal.Decimal, error) {
+	switch n := v.(type) {
+	case string:
+		return decimal.Parse(n)
+	case json.Number:
+		return decimal.Parse(n.String())
+	case int, int8, int16, int32, int64:
+		return decimal.FromInt(reflect.ValueOf(n).Int()), nil
+	case uint, uint8, uint16, uint32, uint64:
+		return decimal.Parse(strconv.FormatUint(reflect.ValueOf(n).Uint(), 10))
+	case float32:
+		return decimal.Parse(strconv.FormatFloat(float64(n), 'g', -1, 32))
+	case float64:
+		return decimal.FromFloat(n)
+	case nil:
+		return decimal.Decimal{}, fmt.Errorf("value is null")
+	}
+	return decimal.Decimal{}, fmt.Errorf("cannot convert %T to a decimal", v)
+}
+
+// decimalPair converts both operands of a decimal function
+func decimalPair(name string, a, b any) (decimal.Decimal, decimal.Decimal, error) {
+	x, err := toDecimal(a)
+	if err != nil {
+		return x, x, fmt.Errorf("%s: %w", name, err)
+	}
+	y, err := toDecimal(b)
+	if err != nil {
+		return x, y, fmt.Errorf("%s: %w", name, err)
+	}
+	return x, y, nil
+}
+
+// decAddFunc adds exactly, keeping the larger scale: decAdd "10.50" "0.25" = "10.75"
+func decAddFunc(a, b any) (string, error) {
+	x, y, err := decimalPair("decAdd", a, b)
+	if err != nil {
+		return "", err
+	}
+	return x.Add(y).String(), nil
+}
+
+// decSubFunc subtracts exactly, keeping the larger scale
+func decSubFunc(a, b any) (string, error) {
+	x, y, err := decimalPair("decSub", a, b)
+	if err != nil {
+		return "", err
+	}
+	return x.Sub(y).String(), nil
+}
+
+// decMulFunc multiplies exactly; round the result with decRound
+func decMulFunc(a, b any) (string, error) {
+	x, y, err := decimalPair("decMul", a, b)
+	if err != nil {
+		return "", err
+	}
+	p, err := x.Mul(y)
+	if err != nil {
+		return "", fmt.Errorf("decMul: %w", err)
+	}
+	return p.String(), nil
+}
+
+// decDivFunc divides, rounding half up to places digits. Without places the
+// quotient has up to 10 digits with trailing zeros removed. Division by zero
+// is an error.
+func decDivFunc(a, b any, places ...int) (string, error) {
+	x, y, err := decimalPair("decDiv", a, b)
+	if err != nil {
+		return "", err
+	}
+	p := defaultDivPlaces
+	if len(places) > 0 {
+		p = places[0]
+	}
+	q, err := x.Div(y, p, decimal.HalfUp)
+	if err != nil {
+		return "", fmt.Errorf("decDiv: %w", err)
+	}
+	if len(places) == 0 {
+		q = q.Trim(0)
+	}
+	return q.String(), nil
+}
+
+// decRoundFunc rounds to exactly places digits. The optional mode is half_up
+// (default), half_even, down, up, floor or ceil.
+func decRoundFunc(v any, places int, mode ...string) (string, error) {
+	d, err := toDecimal(v)
+	if err != nil {
+		return "", fmt.Errorf("decRound: %w", err)
+	}
+	if places < 0 || places > decimal.MaxScale {
+		return "", fmt.Errorf("decRound: places must be between 0 and %d", decimal.MaxScale)
+	}
+	m := decimal.HalfUp
+	if len(mode) > 0 {
+		if m, err = decimal.ParseRoundingMode(mode[0]); err != nil {
+			return "", fmt.Errorf("decRound: %w", err)
+		}
+	}
+	return d.Round(places, m).String(), nil
+}
+
+// decCmpFunc compares exactly, returning -1, 0 or 1
+func decCmpFunc(a, b any) (int, error) {
+	x, y, err := decimalPair("decCmp", a, b)
+	if err != nil {
+		return 0, err
+	}
+	return x.Cmp(y), nil
+}
+
+// currency is the symbol and minor-unit digits of an ISO 4217 currency
+type currency struct {
+	symbol string
+	digits int
+}
+
+// currencies lists common currencies; others are formatted with their code and 2 digits
+var currencies = map[string]currency{
+	"USD": {"$", 2}, "EUR": {"€", 2}, "GBP": {"£", 2}, "JPY": {"¥", 0},
+	"CNY": {"CN¥", 2}, "INR": {"₹", 2}, "CAD": {"CA$", 2}, "AUD": {"A$", 2},
+	"NZD": {"NZ$", 2}, "HKD": {"HK$", 2}, "MXN": {"MX$", 2}, "BRL": {"R$", 2},
+	"KRW": {"₩", 0}, "ILS": {"₪", 2}, "VND": {"₫", 0}, "CLP": {"CLP ", 0},
+	"ISK": {"ISK ", 0}, "BHD": {"BHD ", 3}, "KWD": {"KWD ", 3}, "OMR": {"OMR ", 3},
+	"JOD": {"JOD ", 3}, "TND": {"TND ", 3},
+}
+
+var currencyCodeRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// formatCurrencyFunc formats an amount in a currency, rounded half up to the
+// currency's minor unit: formatCurrency "-1234.5" "USD" = "-$1,234.50"
+func formatCurrencyFunc(v any, code string) (string, error) {
+	d, err := toDecimal(v)
+	if err != nil {
+		return "", fmt.Errorf("formatCurrency: %w", err)
+	}
+	code = strings.ToUpper(code)
+	if !currencyCodeRegex.MatchString(code) {
+		return "", fmt.Errorf("formatCurrency: invalid currency code %q", code)
+	}
+	c, ok := currencies[code]
+	if !ok {
+		c = currency{symbol: code + " ", digits: 2}
+	}
+
+	s := d.Round(c.digits, decimal.HalfUp).String()
+	sign := ""
+	if rest, neg := strings.CutPrefix(s, "-"); neg {
+		sign, s = "-", rest
+	}
+	intPart, frac, _ := strings.Cut(s, ".")
+	formatted := sign + c.symbol + groupThousands(intPart)
+	if frac != "" {
+		formatted += "." + frac
+	}
+	return formatted, nil
+}
+
 // Template path validation
 // ============================================================================
 
@@ -1,9 +1,14 @@
 package tmpl
 
 import (
+	"encoding/base64"
 	"strings"
 	"testing"
 
+	"golang.org/x/crypto/argon2"
+
+	"sql-proxy/internal/i18n"
+	"sql-proxy/internal/keyring"
 	"sql-proxy/internal/publicid"
 )
 
@@ -1868,6 +1873,104 @@ func TestPublicIDInTemplates(t *testing.T) {
 	}
 }
 
+// TestEncryptDecryptFuncs tests the encrypt and decrypt template functions
+func TestEncryptDecryptFuncs(t *testing.T) {
+	e := New()
+	ctx := &Context{
+		Trigger: &TriggerContext{
+			Params: map[string]any{"ssn": "123-45-6789", "code": 42, "missing": nil},
+		},
+	}
+
+	// Without keys configured both functions fail
+	for _, tmplStr := range []string{`{{encrypt .trigger.params.ssn}}`, `{{decrypt .trigger.params.ssn}}`} {
+		_, err := e.ExecuteInline(tmplStr, ctx, UsagePreQuery)
+		if err == nil || !strings.Contains(err.Error(), "crypto_keys not configured") {
+			t.Errorf("%s: expected crypto_keys not configured error, got %v", tmplStr, err)
+		}
+	}
+
+	k, err := keyring.New("k1", []keyring.KeyConfig{{ID: "k1", Key: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}})
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+	e.SetKeyring(k)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"round trip", `{{encrypt .trigger.params.ssn | decrypt}}`, "123-45-6789"},
+		{"number", `{{decrypt (encrypt .trigger.params.code)}}`, "42"},
+		{"nil stays empty", `[{{encrypt .trigger.params.missing}}]`, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err != nil {
+				t.Fatalf("template error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %q, want %q", result, tt.want)
+			}
+		})
+	}
+
+	enc, err := e.ExecuteInline(`{{encrypt .trigger.params.ssn}}`, ctx, UsagePreQuery)
+	if err != nil || !strings.HasPrefix(enc, "enc:k1:") {
+		t.Errorf("encrypt = %q, %v, want enc:k1: prefix", enc, err)
+	}
+	if _, err := e.ExecuteInline(`{{decrypt .trigger.params.ssn}}`, ctx, UsagePreQuery); err == nil || !strings.Contains(err.Error(), "not encrypted") {
+		t.Errorf("decrypt of plaintext: expected not encrypted error, got %v", err)
+	}
+}
+
+// TestTranslateFunc tests the t template function
+func TestTranslateFunc(t *testing.T) {
+	e := New()
+	ctx := &Context{
+		Trigger: &TriggerContext{
+			Params: map[string]any{"lang": "fr-CA", "name": "Ana", "none": nil},
+		},
+	}
+
+	if _, err := e.ExecuteInline(`{{t "greeting" .trigger.params.lang}}`, ctx, UsagePreQuery); err == nil || !strings.Contains(err.Error(), "messages not configured") {
+		t.Errorf("expected messages not configured error, got %v", err)
+	}
+
+	c, err := i18n.New("en", map[string]map[string]any{
+		"en": {"greeting": "Hello, %s", "bye": "Goodbye"},
+		"fr": {"greeting": "Bonjour, %s"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create catalog: %v", err)
+	}
+	e.SetMessages(c)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"base language with args", `{{t "greeting" .trigger.params.lang .trigger.params.name}}`, "Bonjour, Ana"},
+		{"default fallback", `{{t "bye" .trigger.params.lang}}`, "Goodbye"},
+		{"nil language", `{{t "bye" .trigger.params.none}}`, "Goodbye"},
+		{"missing key", `{{t "nope" "fr"}}`, "nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err != nil {
+				t.Fatalf("template error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %q, want %q", result, tt.want)
+			}
+		})
+	}
+}
+
 // ============================================================================
 // Phase 10: Validation helpers tests
 // ============================================================================
@@ -2198,6 +2301,70 @@ func TestDigFunc(t *testing.T) {
 	}
 }
 
+// TestJqFunc tests jq queries against step-shaped data
+func TestJqFunc(t *testing.T) {
+	e := New()
+	ctx := &Context{
+		Trigger: &TriggerContext{
+			Params: map[string]any{
+				"body": map[string]any{
+					"data": map[string]any{
+						"users": []any{
+							map[string]any{"id": float64(1), "name": "ada", "active": true},
+							map[string]any{"id": float64(2), "name": "bob", "active": false},
+						},
+					},
+				},
+				"rows": []map[string]any{{"id": 1, "total": 10}, {"id": 2, "total": 5}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"path", `{{jq ".data.users[0].name" .trigger.params.body}}`, "ada"},
+		{"pipeline", `{{.trigger.params.body | jq ".data.users | length"}}`, "2"},
+		{"restructure", `{{jq "[.data.users[] | select(.active) | {id, label: .name}]" .trigger.params.body | json}}`, `[{"id":1,"label":"ada"}]`},
+		{"rows", `{{jq "map(.total) | add" .trigger.params.rows}}`, "15"},
+		{"no output is nil", `{{jq ".data.users[] | select(.id > 5)" .trigger.params.body}}`, "<no value>"},
+		{"alternative", `{{jq ".data.missing // \"none\"" .trigger.params.body}}`, "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err != nil {
+				t.Fatalf("template error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %q, want %q", result, tt.want)
+			}
+		})
+	}
+
+	errTests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{"parse error", `{{jq ".data[" .trigger.params.body}}`, "unexpected end of query"},
+		{"runtime error", `{{jq ".data.users.name" .trigger.params.body}}`, "cannot index array"},
+		{"multiple outputs", `{{jq ".data.users[].id" .trigger.params.body}}`, "produced 2 results"},
+	}
+
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 // ============================================================================
 // Phase 20: Debug helpers tests
 // ============================================================================
@@ -2256,6 +2423,8 @@ func TestNumericFormatting(t *testing.T) {
 		{"formatNumber int", `{{formatNumber 1234567}}`, "1,234,567"},
 		{"formatNumber decimals", `{{formatNumber 1234.5678 2}}`, "1,234.57"},
 		{"formatNumber negative", `{{formatNumber -1234567}}`, "-1,234,567"},
+		{"formatNumber locale", `{{formatNumber 1234.5 2 "de-DE"}}`, "1.234,50"},
+		{"formatNumber locale no decimals", `{{formatNumber 1234567 0 "en-IN"}}`, "12,34,567"},
 
 		{"formatPercent", `{{formatPercent 0.1234}}`, "12.3%"},
 		{"formatPercent 2 decimals", `{{formatPercent 0.1234 2}}`, "12.34%"},
@@ -2285,6 +2454,66 @@ func TestNumericFormatting(t *testing.T) {
 	}
 }
 
+// TestLocaleFormattingErrors tests invalid locale arguments
+func TestLocaleFormattingErrors(t *testing.T) {
+	e := New()
+	ctx := &Context{Trigger: &TriggerContext{ClientIP: "127.0.0.1", Method: "GET", Path: "/test"}}
+
+	tests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{"formatNumber invalid locale", `{{formatNumber 1 2 "not a locale"}}`, `formatNumber: invalid locale "not a locale"`},
+		{"formatNumber locale not string", `{{formatNumber 1 2 3}}`, "locale must be a string"},
+		{"formatNumber too many args", `{{formatNumber 1 2 "de" "x"}}`, "got 4 arguments"},
+		{"formatDate invalid locale", `{{formatDate "2024-03-01" "long" "??"}}`, "formatDate: invalid locale"},
+		{"formatDate bad style", `{{formatDate "2024-03-01" "iso"}}`, "unknown date style"},
+		{"formatDate bad time", `{{formatDate "yesterday" "long"}}`, "cannot parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestFormatDate tests locale-aware date formatting
+func TestFormatDate(t *testing.T) {
+	e := New()
+	ctx := &Context{Trigger: &TriggerContext{Params: map[string]any{
+		"created": "2024-03-01T23:30:00-05:00",
+		"unix":    int64(1709251200), // 2024-03-01T00:00:00Z
+	}}}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"default locale", `{{formatDate .trigger.params.created "medium"}}`, "Mar 1, 2024"},
+		{"keeps offset", `{{formatDate .trigger.params.created "long" "fr-FR"}}`, "1 mars 2024"},
+		{"full german", `{{formatDate .trigger.params.created "full" "de-DE"}}`, "Freitag, 1. März 2024"},
+		{"short british", `{{formatDate .trigger.params.unix "short" "en-GB"}}`, "01/03/2024"},
+		{"date only", `{{formatDate "2024-12-25" "long" "es"}}`, "25 de diciembre de 2024"},
+		{"zoned time", `{{formatDate (formatTimeIn .trigger.params.created "Asia/Tokyo" "YYYY-MM-DDTHH:mm:ss+09:00") "long" "ja"}}`, "2024年3月2日"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err != nil {
+				t.Fatalf("template error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %q, want %q", result, tt.want)
+			}
+		})
+	}
+}
+
 // ============================================================================
 // Additional coverage tests for edge cases and negative tests
 // ============================================================================
@@ -2418,6 +2647,82 @@ func TestFormatTimeEdgeCases(t *testing.T) {
 	}
 }
 
+func TestTimeZoneFuncs(t *testing.T) {
+	e := New()
+	ctx := &Context{Trigger: &TriggerContext{ClientIP: "127.0.0.1", Method: "GET", Path: "/test"}}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"formatTimeIn", `{{formatTimeIn "2024-01-01T23:30:00Z" "Europe/Berlin" "YYYY-MM-DD HH:mm"}}`, "2024-01-02 00:30"},
+		{"formatTimeIn unix", `{{formatTimeIn 1704067200 "America/New_York" "YYYY-MM-DD"}}`, "2023-12-31"},
+		{"formatTimeIn local string", `{{formatTimeIn "2024-07-01 08:00:00" "Asia/Tokyo" "HH:mm"}}`, "08:00"},
+		{"dateAdd duration", `{{dateAdd "2024-01-01T00:00:00Z" "90m"}}`, "2024-01-01T01:30:00Z"},
+		{"dateAdd days", `{{dateAdd "2024-01-31T10:00:00Z" "-2d"}}`, "2024-01-29T10:00:00Z"},
+		{"dateAdd months", `{{dateAdd "2024-01-15" "1M"}}`, "2024-02-15T00:00:00Z"},
+		{"dateAdd day across DST", `{{dateAdd "2024-03-30T00:00:00+01:00" "1d" "Europe/Berlin"}}`, "2024-03-31T00:00:00+01:00"},
+		{"dateAdd day after DST", `{{dateAdd "2024-03-31T00:00:00+01:00" "1d" "Europe/Berlin"}}`, "2024-04-01T00:00:00+02:00"},
+		{"dateDiff hours", `{{dateDiff "2024-01-01T00:00:00Z" "2024-01-01T05:59:00Z" "h"}}`, "5"},
+		{"dateDiff days negative", `{{dateDiff "2024-01-10" "2024-01-03" "d"}}`, "-7"},
+		{"dateDiff DST day", `{{dateDiff "2024-03-31" "2024-04-01" "d" "Europe/Berlin"}}`, "1"},
+		{"dateDiff partial day", `{{dateDiff "2024-01-01T12:00:00Z" "2024-01-02T11:00:00Z" "d"}}`, "0"},
+		{"dateDiff weeks", `{{dateDiff "2024-01-01" "2024-01-20" "w"}}`, "2"},
+		{"dateDiff months", `{{dateDiff "2024-01-31" "2024-03-30" "M"}}`, "1"},
+		{"dateDiff years", `{{dateDiff "2020-02-29" "2024-02-29" "y"}}`, "4"},
+		{"startOf day in zone", `{{startOf "2024-01-01T23:30:00Z" "day" "Europe/Berlin"}}`, "2024-01-02T00:00:00+01:00"},
+		{"startOf day UTC", `{{startOf "2024-01-01T23:30:00Z" "day"}}`, "2024-01-01T00:00:00Z"},
+		{"startOf week", `{{startOf "2024-01-07" "week"}}`, "2024-01-01T00:00:00Z"},
+		{"startOf month", `{{startOf "2024-02-29T10:00:00Z" "month" "America/New_York"}}`, "2024-02-01T00:00:00-05:00"},
+		{"endOf day", `{{endOf "2024-03-31T12:00:00Z" "day" "Europe/Berlin"}}`, "2024-03-31T23:59:59+02:00"},
+		{"endOf month", `{{endOf "2024-02-10" "month"}}`, "2024-02-29T23:59:59Z"},
+		{"endOf week", `{{endOf "2024-01-03" "week"}}`, "2024-01-07T23:59:59Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err != nil {
+				t.Fatalf("template error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %q, want %q", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("nowIn", func(t *testing.T) {
+		result, err := e.ExecuteInline(`{{nowIn "Asia/Kolkata"}}`, ctx, UsagePreQuery)
+		if err != nil {
+			t.Fatalf("template error: %v", err)
+		}
+		if !strings.HasSuffix(result, "+05:30") {
+			t.Errorf("got %q, want an offset of +05:30", result)
+		}
+	})
+
+	errTests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{"unknown zone", `{{nowIn "Mars/Olympus"}}`, "unknown time zone"},
+		{"bad amount", `{{dateAdd "2024-01-01" "1q"}}`, "invalid amount"},
+		{"bad unit", `{{dateDiff "2024-01-01" "2024-01-02" "q"}}`, "unknown unit"},
+		{"bad period", `{{startOf "2024-01-01" "year"}}`, "unknown period"},
+		{"bad time", `{{startOf "soon" "day"}}`, "cannot parse"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 func TestDigFuncEdgeCases(t *testing.T) {
 	e := New()
 	ctx := &Context{
@@ -3176,6 +3481,244 @@ func TestHmacSHA256Func(t *testing.T) {
 	}
 }
 
+// TestBcryptFuncs tests bcrypt hashing and verification
+func TestBcryptFuncs(t *testing.T) {
+	hash, err := bcryptHashFunc("s3cret", 4)
+	if err != nil {
+		t.Fatalf("bcryptHash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$2a$04$") {
+		t.Errorf("bcryptHash = %q, want $2a$04$ prefix", hash)
+	}
+	if !bcryptVerifyFunc(hash, "s3cret") || !bcryptVerifyFunc([]byte(hash), "s3cret") {
+		t.Error("bcryptVerify should accept the right password")
+	}
+	if bcryptVerifyFunc(hash, "wrong") {
+		t.Error("bcryptVerify should reject the wrong password")
+	}
+	for _, bad := range []any{nil, "", "not-a-hash", 42} {
+		if bcryptVerifyFunc(bad, "s3cret") {
+			t.Errorf("bcryptVerify(%v) should be false", bad)
+		}
+	}
+
+	if hash, err := bcryptHashFunc("s3cret"); err != nil || !strings.HasPrefix(hash, "$2a$10$") {
+		t.Errorf("bcryptHash default cost = %q, %v", hash, err)
+	}
+	if _, err := bcryptHashFunc("s3cret", 99); err == nil || !strings.Contains(err.Error(), "cost must be between") {
+		t.Errorf("expected cost error, got %v", err)
+	}
+	if _, err := bcryptHashFunc(strings.Repeat("x", 73), 4); err == nil {
+		t.Error("expected error for password over 72 bytes")
+	}
+}
+
+// TestArgon2Funcs tests argon2 hashing and verification
+func TestArgon2Funcs(t *testing.T) {
+	hash, err := argon2HashFunc("s3cret")
+	if err != nil {
+		t.Fatalf("argon2Hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("argon2Hash = %q", hash)
+	}
+	if other, _ := argon2HashFunc("s3cret"); other == hash {
+		t.Error("argon2Hash should use a random salt")
+	}
+	if !argon2VerifyFunc(hash, "s3cret") || !argon2VerifyFunc([]byte(hash), "s3cret") {
+		t.Error("argon2Verify should accept the right password")
+	}
+	if argon2VerifyFunc(hash, "wrong") {
+		t.Error("argon2Verify should reject the wrong password")
+	}
+
+	// argon2i hashes and other parameters are verified with the stored parameters
+	salt := []byte("somesaltsomesalt")
+	key := argon2.Key([]byte("s3cret"), salt, 1, 8*1024, 2, 16)
+	argon2i := "$argon2i$v=19$m=8192,t=1,p=2$" + base64.RawStdEncoding.EncodeToString(salt) + "$" + base64.RawStdEncoding.EncodeToString(key)
+	if !argon2VerifyFunc(argon2i, "s3cret") {
+		t.Error("argon2Verify should accept argon2i hashes")
+	}
+
+	parts := strings.Split(hash, "$")
+	for name, bad := range map[string]any{
+		"nil":             nil,
+		"empty":           "",
+		"bcrypt hash":     "$2a$04$abcdefghijklmnopqrstuu",
+		"unknown variant": strings.Replace(hash, "argon2id", "argon2d", 1),
+		"wrong version":   strings.Replace(hash, "v=19", "v=16", 1),
+		"huge memory":     strings.Replace(hash, "m=19456", "m=99999999", 1),
+		"bad salt":        strings.Join([]string{"", parts[1], parts[2], parts[3], "!!", parts[5]}, "$"),
+		"missing key":     strings.Join(parts[:5], "$"),
+	} {
+		if argon2VerifyFunc(bad, "s3cret") {
+			t.Errorf("argon2Verify(%s) should be false", name)
+		}
+	}
+}
+
+// TestPasswordHashFuncs_InTemplatesAndExpr tests the password functions from templates and expressions
+func TestPasswordHashFuncs_InTemplatesAndExpr(t *testing.T) {
+	e := New()
+	ctx := &Context{Trigger: &TriggerContext{Params: map[string]any{"password": "s3cret"}}}
+
+	hash, err := e.ExecuteInline(`{{argon2Hash .trigger.params.password}}`, ctx, UsagePreQuery)
+	if err != nil {
+		t.Fatalf("template error: %v", err)
+	}
+	ctx.Trigger.Params["hash"] = hash
+	got, err := e.ExecuteInline(`{{argon2Verify .trigger.params.hash .trigger.params.password}}`, ctx, UsagePreQuery)
+	if err != nil || got != "true" {
+		t.Errorf("argon2Verify in template = %q, %v, want true", got, err)
+	}
+
+	verify := ExprFuncs()["bcryptVerify"].(func(any, string) bool)
+	bcryptHash, err := ExprFuncs()["bcryptHash"].(func(string, ...int) (string, error))("s3cret", 4)
+	if err != nil || !verify(bcryptHash, "s3cret") {
+		t.Errorf("bcrypt expr functions failed: %v", err)
+	}
+}
+
+// TestJWTFuncs tests jwtSign and jwtVerify from templates and expressions
+func TestJWTFuncs(t *testing.T) {
+	const key = "0123456789abcdef0123456789abcdef"
+	e := New()
+	ctx := &Context{Trigger: &TriggerContext{Params: map[string]any{"id": "file-7"}}}
+
+	token, err := e.ExecuteInline(`{{jwtSign (printf "{\"sub\":%q,\"exp\":\"5m\"}" .trigger.params.id) "`+key+`" "HS256"}}`, ctx, UsagePreQuery)
+	if err != nil {
+		t.Fatalf("jwtSign: %v", err)
+	}
+	ctx.Trigger.Params["token"] = "Bearer " + token
+	ctx.Trigger.Params["empty"] = ""
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"valid token", `{{(jwtVerify .trigger.params.token "` + key + `").sub}}`, "file-7"},
+		{"wrong key", `{{with jwtVerify .trigger.params.token "` + key + `x"}}ok{{else}}invalid{{end}}`, "invalid"},
+		{"missing token", `{{with jwtVerify .trigger.params.empty "` + key + `"}}ok{{else}}invalid{{end}}`, "invalid"},
+		{"map claims", `{{$t := jwtSign .trigger.params "` + key + `" "HS256"}}{{(jwtVerify $t "` + key + `").id}}`, "file-7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err != nil {
+				t.Fatalf("template error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %q, want %q", result, tt.want)
+			}
+		})
+	}
+
+	errTests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{"short key", `{{jwtVerify .trigger.params.token "short"}}`, "jwtVerify: invalid key"},
+		{"bad claims", `{{jwtSign "[1]" "` + key + `" "HS256"}}`, "claims must be a JSON object"},
+		{"bad alg", `{{jwtSign "{}" "` + key + `" "none"}}`, "unsupported algorithm"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	verify := ExprFuncs()["jwtVerify"].(func(any, string) (map[string]any, error))
+	if claims, err := verify(token, key); err != nil || claims["sub"] != "file-7" {
+		t.Errorf("jwtVerify expr = %v, %v", claims, err)
+	}
+}
+
+// TestDecimalFuncs tests exact decimal arithmetic and currency formatting
+func TestDecimalFuncs(t *testing.T) {
+	e := New()
+	ctx := &Context{Trigger: &TriggerContext{Params: map[string]any{
+		"price": "19.99", // DECIMAL columns arrive as strings
+		"qty":   int64(3),
+		"rate":  0.075,
+		"a":     0.1,
+		"b":     0.2,
+		"null":  nil,
+	}}}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"add floats exactly", `{{decAdd .trigger.params.a .trigger.params.b}}`, "0.3"},
+		{"float add for contrast", `{{add .trigger.params.a .trigger.params.b}}`, "0.30000000000000004"},
+		{"add keeps scale", `{{decAdd "10.50" "0.25"}}`, "10.75"},
+		{"sub", `{{decSub "10.00" "0.015"}}`, "9.985"},
+		{"mul", `{{decMul .trigger.params.price .trigger.params.qty}}`, "59.97"},
+		{"tax", `{{decRound (decMul (decMul .trigger.params.price .trigger.params.qty) .trigger.params.rate) 2}}`, "4.50"},
+		{"div default places", `{{decDiv "10" "4"}}`, "2.5"},
+		{"div repeating", `{{decDiv "1" "3"}}`, "0.3333333333"},
+		{"div places", `{{decDiv "100" "3" 2}}`, "33.33"},
+		{"round half up", `{{decRound "2.345" 2}}`, "2.35"},
+		{"round half even", `{{decRound "2.345" 2 "half_even"}}`, "2.34"},
+		{"round pads", `{{decRound 7 2}}`, "7.00"},
+		{"cmp", `{{decCmp "1.50" "1.5"}} {{decCmp "0.1" .trigger.params.b}}`, "0 -1"},
+		{"usd", `{{formatCurrency "1234567.891" "USD"}}`, "$1,234,567.89"},
+		{"negative eur", `{{formatCurrency "-0.5" "eur"}}`, "-€0.50"},
+		{"jpy no minor unit", `{{formatCurrency 1234.5 "JPY"}}`, "¥1,235"},
+		{"three digit currency", `{{formatCurrency "12.3456" "KWD"}}`, "KWD 12.346"},
+		{"unknown currency", `{{formatCurrency .trigger.params.price "SEK"}}`, "SEK 19.99"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err != nil {
+				t.Fatalf("template error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %q, want %q", result, tt.want)
+			}
+		})
+	}
+
+	errTests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{"null operand", `{{decAdd .trigger.params.null "1"}}`, "decAdd: value is null"},
+		{"not a number", `{{decMul "abc" "1"}}`, `decMul: invalid decimal "abc"`},
+		{"division by zero", `{{decDiv "1" "0.00"}}`, "decDiv: division by zero"},
+		{"negative places", `{{decRound "1.5" -1}}`, "places must be between 0"},
+		{"bad mode", `{{decRound "1.5" 0 "nearest"}}`, "unknown rounding mode"},
+		{"bad currency", `{{formatCurrency "1" "dollars"}}`, "invalid currency code"},
+		{"unsupported type", `{{decCmp .trigger "1"}}`, "cannot convert"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.ExecuteInline(tt.template, ctx, UsagePreQuery)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// Expressions take the same functions; expr numbers are float64
+	cmp := ExprFuncs()["decCmp"].(func(any, any) (int, error))
+	if c, err := cmp("100.10", 100.1); err != nil || c != 0 {
+		t.Errorf("decCmp expr = %d, %v, want 0", c, err)
+	}
+	format := ExprFuncs()["formatCurrency"].(func(any, string) (string, error))
+	if got, err := format("200.2", "GBP"); err != nil || got != "£200.20" {
+		t.Errorf("formatCurrency expr = %q, %v", got, err)
+	}
+}
+
 // ============================================================================
 // Additional IP function edge case tests
 // ============================================================================
@@ -3293,12 +3836,18 @@ func TestExprFuncs(t *testing.T) {
 	expected := []string{
 		// Safe division/modulo
 		"divOr", "modOr",
+		// Decimal arithmetic
+		"decAdd", "decSub", "decMul", "decDiv", "decRound", "decCmp", "formatCurrency",
 		// String functions
 		"upper", "lower", "trim", "hasPrefix", "hasSuffix",
 		// Collection helpers
-		"len", "isEmpty", "first", "last",
+		"len", "isEmpty", "first", "last", "jq",
 		// Validation helpers
 		"isEmail", "isUUID", "isURL", "isIP", "isIPv4", "isIPv6", "isNumeric",
+		// Password hashing
+		"bcryptHash", "bcryptVerify", "argon2Hash", "argon2Verify",
+		// JWT
+		"jwtSign", "jwtVerify",
 		// Coalesce
 		"coalesce",
 	}
@@ -0,0 +1,154 @@
+package tmpl
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"text/template"
+)
+
+// Functions registered by plugins (see the public sql-proxy/funcs package).
+// Registration happens from init functions, before any template is parsed.
+var (
+	pluginMu        sync.RWMutex
+	pluginFuncs     = template.FuncMap{}
+	pluginExprFuncs = map[string]any{}
+	funcHooks       []func(name string, fn any)
+	exprFuncHooks   []func(name string, fn any)
+)
+
+// reservedFuncNames are provided outside BaseFuncMap and ExprFuncs (by engines,
+// workflows, and text/template itself) but cannot be overridden either
+var reservedFuncNames = map[string]bool{
+	"publicID": true, "privateID": true, "isValidPublicID": true, "encrypt": true, "decrypt": true, "t": true,
+	"and": true, "call": true, "html": true, "index": true, "slice": true, "js": true,
+	"len": true, "not": true, "or": true, "print": true, "printf": true, "println": true,
+	"urlquery": true, "eq": true, "ge": true, "gt": true, "le": true, "lt": true, "ne": true,
+}
+
+// RegisterFunc adds a template function to BaseFuncMap.
+// It panics if name is taken or fn is not a valid template function,
+// mirroring template.Funcs, so a bad plugin fails at startup.
+func RegisterFunc(name string, fn any) {
+	if err := checkFunc(name, fn, true); err != nil {
+		panic(fmt.Sprintf("tmpl: RegisterFunc(%q): %v", name, err))
+	}
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	if _, dup := pluginFuncs[name]; dup {
+		panic(fmt.Sprintf("tmpl: RegisterFunc(%q): already registered", name))
+	}
+	pluginFuncs[name] = fn
+	for _, hook := range funcHooks {
+		hook(name, fn)
+	}
+}
+
+// RegisterExprFunc adds an expression function to ExprFuncs.
+// It panics if name is taken or fn is not a function.
+func RegisterExprFunc(name string, fn any) {
+	if err := checkFunc(name, fn, false); err != nil {
+		panic(fmt.Sprintf("tmpl: RegisterExprFunc(%q): %v", name, err))
+	}
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	if _, dup := pluginExprFuncs[name]; dup {
+		panic(fmt.Sprintf("tmpl: RegisterExprFunc(%q): already registered", name))
+	}
+	pluginExprFuncs[name] = fn
+	for _, hook := range exprFuncHooks {
+		hook(name, fn)
+	}
+}
+
+// OnRegisterFunc calls hook for every registered template function, now and as
+// more are registered. Packages that copy BaseFuncMap at init use it to pick up
+// plugins initialized after them.
+func OnRegisterFunc(hook func(name string, fn any)) {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	for name, fn := range pluginFuncs {
+		hook(name, fn)
+	}
+	funcHooks = append(funcHooks, hook)
+}
+
+// OnRegisterExprFunc is OnRegisterFunc for expression functions.
+func OnRegisterExprFunc(hook func(name string, fn any)) {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	for name, fn := range pluginExprFuncs {
+		hook(name, fn)
+	}
+	exprFuncHooks = append(exprFuncHooks, hook)
+}
+
+// addPluginFuncs copies the registered template functions into fm.
+func addPluginFuncs(fm template.FuncMap) {
+	pluginMu.RLock()
+	defer pluginMu.RUnlock()
+	for name, fn := range pluginFuncs {
+		fm[name] = fn
+	}
+}
+
+// addPluginExprFuncs copies the registered expression functions into m.
+func addPluginExprFuncs(m map[string]any) {
+	pluginMu.RLock()
+	defer pluginMu.RUnlock()
+	for name, fn := range pluginExprFuncs {
+		m[name] = fn
+	}
+}
+
+// checkFunc rejects names that would shadow a built-in function and values that
+// are not functions. Template functions must also return one value, or a value and an error.
+func checkFunc(name string, fn any, isTemplate bool) error {
+	if !isIdentifier(name) {
+		return fmt.Errorf("name must be a letter or underscore followed by letters, digits, or underscores")
+	}
+	if reservedFuncNames[name] || builtinFuncs[name] || builtinExprFuncs[name] {
+		return fmt.Errorf("shadows a built-in function")
+	}
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		return fmt.Errorf("not a function: %T", fn)
+	}
+	if isTemplate {
+		errType := reflect.TypeFor[error]()
+		switch {
+		case t.NumOut() == 1:
+		case t.NumOut() == 2 && t.Out(1) == errType:
+		default:
+			return fmt.Errorf("must return one value, or a value and an error")
+		}
+	}
+	return nil
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		letter := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !letter && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Names of the built-in functions, computed before any plugin registers
+var (
+	builtinFuncs     = funcNames(baseFuncMap())
+	builtinExprFuncs = funcNames(baseExprFuncs())
+)
+
+func funcNames[M ~map[string]any](m M) map[string]bool {
+	names := make(map[string]bool, len(m))
+	for name := range m {
+		names[name] = true
+	}
+	return names
+}
@@ -0,0 +1,95 @@
+package tmpl
+
+import (
+	"strings"
+	"testing"
+)
+
+// resetPlugins removes functions registered by a test
+func resetPlugins(t *testing.T) {
+	t.Cleanup(func() {
+		pluginMu.Lock()
+		defer pluginMu.Unlock()
+		pluginFuncs = map[string]any{}
+		pluginExprFuncs = map[string]any{}
+		funcHooks = nil
+		exprFuncHooks = nil
+	})
+}
+
+// TestRegisterFunc verifies plugin functions reach BaseFuncMap, ExprFuncs, and hooks
+func TestRegisterFunc(t *testing.T) {
+	resetPlugins(t)
+
+	double := func(n int) int { return n * 2 }
+	RegisterFunc("double", double)
+	RegisterExprFunc("triple", func(n int) int { return n * 3 })
+
+	// Hooks replay earlier registrations and see later ones
+	var seen []string
+	OnRegisterFunc(func(name string, fn any) { seen = append(seen, name) })
+	RegisterFunc("half", func(n int) int { return n / 2 })
+	if strings.Join(seen, ",") != "double,half" {
+		t.Errorf("hook saw %v, want [double half]", seen)
+	}
+
+	fm := BaseFuncMap()
+	if fm["double"] == nil || fm["half"] == nil {
+		t.Error("expected plugin functions in BaseFuncMap")
+	}
+	if fm["triple"] != nil {
+		t.Error("expression functions should not be template functions")
+	}
+	if ExprFuncs()["triple"] == nil {
+		t.Error("expected plugin function in ExprFuncs")
+	}
+	if ExprFuncs()["double"] != nil {
+		t.Error("template functions should not be expression functions")
+	}
+
+	e := New()
+	if err := e.Register("doubled", "{{double 21}}", UsagePreQuery); err != nil {
+		t.Fatalf("register template: %v", err)
+	}
+	out, err := e.Execute("doubled", &Context{})
+	if err != nil || out != "42" {
+		t.Errorf("Execute() = %q, %v, want 42", out, err)
+	}
+}
+
+// TestRegisterFunc_Rejects verifies invalid registrations panic
+func TestRegisterFunc_Rejects(t *testing.T) {
+	resetPlugins(t)
+	RegisterFunc("taken", strings.ToUpper)
+
+	tests := []struct {
+		name    string
+		fn      any
+		wantErr string
+	}{
+		{name: "taken", fn: strings.ToLower, wantErr: "already registered"},
+		{name: "getOr", fn: strings.ToLower, wantErr: "shadows a built-in function"},
+		{name: "upper", fn: strings.ToLower, wantErr: "shadows a built-in function"},
+		{name: "index", fn: strings.ToLower, wantErr: "shadows a built-in function"},
+		{name: "publicID", fn: strings.ToLower, wantErr: "shadows a built-in function"},
+		{name: "is-iban", fn: strings.ToLower, wantErr: "name must be"},
+		{name: "nothing", fn: nil, wantErr: "not a function"},
+		{name: "value", fn: 42, wantErr: "not a function"},
+		{name: "pair", fn: func() (int, int) { return 1, 2 }, wantErr: "must return one value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Fatal("expected panic")
+				}
+				if msg, _ := r.(string); !strings.Contains(msg, tt.wantErr) {
+					t.Errorf("panic = %v, want %q", r, tt.wantErr)
+				}
+			}()
+			RegisterFunc(tt.name, tt.fn)
+		})
+	}
+}
@@ -0,0 +1,649 @@
+package tmpl
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// SprigFuncMap returns the functions of the sprig library (as used by Helm) that
+// the engine does not already provide, with sprig's names and argument order.
+// Where both define a name (split, substr, contains, merge, ...), the built-in
+// function is kept, so enabling sprig never changes existing templates.
+// Functions that panic in sprig return an error here instead.
+func SprigFuncMap() template.FuncMap {
+	return template.FuncMap{
+		// Strings
+		"trimAll":         func(cutset, s string) string { return strings.Trim(s, cutset) },
+		"trimPrefix":      func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix":      func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"title":           sprigTitle,
+		"untitle":         sprigUntitle,
+		"squote":          sprigSquote,
+		"cat":             sprigCat,
+		"indent":          sprigIndent,
+		"nindent":         func(n int, s string) string { return "\n" + sprigIndent(n, s) },
+		"nospace":         sprigNospace,
+		"abbrev":          sprigAbbrev,
+		"initials":        sprigInitials,
+		"camelcase":       sprigCamelcase,
+		"snakecase":       func(s string) string { return sprigDelimit(s, '_') },
+		"kebabcase":       func(s string) string { return sprigDelimit(s, '-') },
+		"swapcase":        sprigSwapcase,
+		"plural":          sprigPlural,
+		"splitList":       func(sep, s string) []string { return strings.Split(s, sep) },
+		"toString":        sprigToString,
+		"toStrings":       sprigToStrings,
+		"regexMatch":      sprigRegexMatch,
+		"regexFind":       sprigRegexFind,
+		"regexFindAll":    sprigRegexFindAll,
+		"regexReplaceAll": sprigRegexReplaceAll,
+		"regexSplit":      sprigRegexSplit,
+
+		// Lists
+		"list":      func(items ...any) []any { return items },
+		"append":    sprigAppend,
+		"prepend":   sprigPrepend,
+		"concat":    sprigConcat,
+		"rest":      func(list any) ([]any, error) { return sprigListOp(list, sprigRest) },
+		"initial":   func(list any) ([]any, error) { return sprigListOp(list, sprigInitial) },
+		"reverse":   func(list any) ([]any, error) { return sprigListOp(list, sprigReverse) },
+		"uniq":      func(list any) ([]any, error) { return sprigListOp(list, sprigUniq) },
+		"compact":   func(list any) ([]any, error) { return sprigListOp(list, sprigCompact) },
+		"without":   sprigWithout,
+		"sortAlpha": sprigSortAlpha,
+		"chunk":     sprigChunk,
+		"until":     func(n int) []int { return sprigUntilStep(0, n, 1) },
+		"untilStep": sprigUntilStep,
+
+		// Dictionaries
+		"dict":           sprigDict,
+		"get":            sprigGet,
+		"set":            func(d map[string]any, key string, v any) map[string]any { d[key] = v; return d },
+		"unset":          func(d map[string]any, key string) map[string]any { delete(d, key); return d },
+		"hasKey":         func(d map[string]any, key string) bool { _, ok := d[key]; return ok },
+		"mergeOverwrite": sprigMergeOverwrite,
+
+		// Numbers
+		"add1": func(v any) int64 { return int64(toNumber(v)) + 1 },
+		"atoi": func(s string) int { i, _ := strconv.Atoi(s); return i },
+		"int":  func(v any) int { return int(toNumber(v)) },
+
+		// Dates (values may be time.Time, RFC3339 strings such as now returns, or Unix seconds)
+		"date":       sprigDate,
+		"dateInZone": sprigDateInZone,
+		"dateModify": sprigDateModify,
+		"toDate":     func(layout, s string) (time.Time, error) { return time.ParseInLocation(layout, s, time.Local) },
+		"duration":   func(sec any) string { return (time.Duration(toNumber(sec)) * time.Second).String() },
+		"ago":        sprigAgo,
+		"unixEpoch":  func(t any) (string, error) { tm, err := sprigTime(t); return strconv.FormatInt(tm.Unix(), 10), err },
+		"htmlDate":   func(t any) (string, error) { return sprigDate("2006-01-02", t) },
+
+		// Encoding
+		"toJson":       sprigToJSON,
+		"toRawJson":    sprigToRawJSON,
+		"toPrettyJson": sprigToPrettyJSON,
+		"fromJson":     sprigFromJSON,
+		"b64enc":       func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec":       func(s string) (string, error) { b, err := base64.StdEncoding.DecodeString(s); return string(b), err },
+		"b32enc":       func(s string) string { return base32.StdEncoding.EncodeToString([]byte(s)) },
+		"b32dec":       func(s string) (string, error) { b, err := base32.StdEncoding.DecodeString(s); return string(b), err },
+		"sha1sum":      func(s string) string { sum := sha1.Sum([]byte(s)); return hex.EncodeToString(sum[:]) },
+		"sha256sum":    func(s string) string { sum := sha256.Sum256([]byte(s)); return hex.EncodeToString(sum[:]) },
+
+		// Defaults, failures and types
+		"empty":     isEmptyFunc,
+		"required":  sprigRequired,
+		"fail":      func(msg string) (string, error) { return "", errors.New(msg) },
+		"kindOf":    sprigKindOf,
+		"kindIs":    func(kind string, v any) bool { return sprigKindOf(v) == kind },
+		"typeIs":    func(typ string, v any) bool { return typeOfFunc(v) == typ },
+		"deepEqual": reflect.DeepEqual,
+	}
+}
+
+var sprigOnce sync.Once
+
+// EnableSprig registers SprigFuncMap for all templates. It is process-wide and
+// cannot be undone; calling it again has no effect.
+func EnableSprig() {
+	sprigOnce.Do(func() {
+		for name, fn := range SprigFuncMap() {
+			RegisterFunc(name, fn)
+		}
+	})
+}
+
+// sprigNames are the names in SprigFuncMap, for pointing out disabled sprig functions
+var sprigNames = funcNames(SprigFuncMap())
+
+// IsSprigFunc reports whether name is one of the functions EnableSprig adds.
+func IsSprigFunc(name string) bool {
+	return sprigNames[name]
+}
+
+// ============================================================================
+// Sprig string helpers
+// ============================================================================
+
+func sprigTitle(s string) string {
+	return mapWordStarts(s, unicode.ToTitle)
+}
+
+func sprigUntitle(s string) string {
+	return mapWordStarts(s, unicode.ToLower)
+}
+
+// mapWordStarts applies f to the first rune of every whitespace-separated word
+func mapWordStarts(s string, f func(rune) rune) string {
+	prevSpace := true
+	return strings.Map(func(r rune) rune {
+		start := prevSpace
+		prevSpace = unicode.IsSpace(r)
+		if start {
+			return f(r)
+		}
+		return r
+	}, s)
+}
+
+func sprigSquote(args ...any) string {
+	out := make([]string, 0, len(args))
+	for _, a := range args {
+		if a != nil {
+			out = append(out, "'"+sprigToString(a)+"'")
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func sprigCat(args ...any) string {
+	out := make([]string, 0, len(args))
+	for _, a := range args {
+		if a != nil {
+			out = append(out, sprigToString(a))
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func sprigIndent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func sprigNospace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// sprigAbbrev shortens s to width runes with a trailing "..."; widths under 4 leave s unchanged
+func sprigAbbrev(width int, s string) string {
+	runes := []rune(s)
+	if width < 4 || len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+func sprigInitials(s string) string {
+	var b strings.Builder
+	for _, word := range strings.Fields(s) {
+		r := []rune(word)
+		b.WriteRune(r[0])
+	}
+	return b.String()
+}
+
+// sprigWords splits s into words at spaces, underscores, hyphens, and lower-to-upper case changes
+func sprigWords(s string) []string {
+	var words []string
+	var cur []rune
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || unicode.IsSpace(r):
+			if len(cur) > 0 {
+				words = append(words, string(cur))
+				cur = nil
+			}
+			continue
+		case unicode.IsUpper(r) && len(cur) > 0:
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				words = append(words, string(cur))
+				cur = nil
+			}
+		}
+		cur = append(cur, r)
+	}
+	if len(cur) > 0 {
+		words = append(words, string(cur))
+	}
+	return words
+}
+
+// sprigCamelcase converts to UpperCamelCase, as sprig does ("http_server" -> "HttpServer")
+func sprigCamelcase(s string) string {
+	var b strings.Builder
+	for _, w := range sprigWords(s) {
+		r := []rune(strings.ToLower(w))
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	return b.String()
+}
+
+func sprigDelimit(s string, sep rune) string {
+	words := sprigWords(s)
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+	return strings.Join(words, string(sep))
+}
+
+func sprigSwapcase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+func sprigPlural(one, many string, count any) string {
+	if toNumber(count) == 1 {
+		return one
+	}
+	return many
+}
+
+// sprigToString converts v to a string: strings and byte slices as-is, nil as "", others with %v
+func sprigToString(v any) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case error:
+		return s.Error()
+	case fmt.Stringer:
+		return s.String()
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func sprigToStrings(list any) ([]string, error) {
+	items, err := sprigList(list)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = sprigToString(item)
+	}
+	return out, nil
+}
+
+func sprigRegexMatch(pattern, s string) (bool, error) {
+	return regexp.MatchString(pattern, s)
+}
+
+func sprigRegexFind(pattern, s string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	return re.FindString(s), nil
+}
+
+func sprigRegexFindAll(pattern, s string, n int) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re.FindAllString(s, n), nil
+}
+
+func sprigRegexReplaceAll(pattern, s, repl string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(s, repl), nil
+}
+
+func sprigRegexSplit(pattern, s string, n int) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re.Split(s, n), nil
+}
+
+// ============================================================================
+// Sprig list helpers
+// ============================================================================
+
+// sprigList copies a slice or array of any element type into a []any
+func sprigList(list any) ([]any, error) {
+	if list == nil {
+		return []any{}, nil
+	}
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", list)
+	}
+	out := make([]any, v.Len())
+	for i := range out {
+		out[i] = v.Index(i).Interface()
+	}
+	return out, nil
+}
+
+// sprigListOp applies op to a copy of list, so the caller's list is never modified
+func sprigListOp(list any, op func([]any) []any) ([]any, error) {
+	items, err := sprigList(list)
+	if err != nil {
+		return nil, err
+	}
+	return op(items), nil
+}
+
+func sprigAppend(list any, v any) ([]any, error) {
+	return sprigListOp(list, func(l []any) []any { return append(l, v) })
+}
+
+func sprigPrepend(list any, v any) ([]any, error) {
+	return sprigListOp(list, func(l []any) []any { return append([]any{v}, l...) })
+}
+
+func sprigRest(l []any) []any {
+	if len(l) == 0 {
+		return l
+	}
+	return l[1:]
+}
+
+func sprigInitial(l []any) []any {
+	if len(l) == 0 {
+		return l
+	}
+	return l[:len(l)-1]
+}
+
+func sprigReverse(l []any) []any {
+	for i, j := 0, len(l)-1; i < j; i, j = i+1, j-1 {
+		l[i], l[j] = l[j], l[i]
+	}
+	return l
+}
+
+func sprigUniq(l []any) []any {
+	out := make([]any, 0, len(l))
+	for _, item := range l {
+		if !sprigContains(out, item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func sprigCompact(l []any) []any {
+	out := make([]any, 0, len(l))
+	for _, item := range l {
+		if !isEmptyFunc(item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func sprigContains(l []any, item any) bool {
+	for _, v := range l {
+		if reflect.DeepEqual(v, item) {
+			return true
+		}
+	}
+	return false
+}
+
+func sprigConcat(lists ...any) ([]any, error) {
+	var out []any
+	for _, list := range lists {
+		items, err := sprigList(list)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, items...)
+	}
+	return out, nil
+}
+
+func sprigWithout(list any, omit ...any) ([]any, error) {
+	return sprigListOp(list, func(l []any) []any {
+		out := make([]any, 0, len(l))
+		for _, item := range l {
+			if !sprigContains(omit, item) {
+				out = append(out, item)
+			}
+		}
+		return out
+	})
+}
+
+func sprigSortAlpha(list any) ([]string, error) {
+	out, err := sprigToStrings(list)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func sprigChunk(size int, list any) ([][]any, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("chunk size must be positive, got %d", size)
+	}
+	items, err := sprigList(list)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([][]any, 0, (len(items)+size-1)/size)
+	for len(items) > size {
+		chunks = append(chunks, items[:size])
+		items = items[size:]
+	}
+	if len(items) > 0 {
+		chunks = append(chunks, items)
+	}
+	return chunks, nil
+}
+
+// sprigUntilStep returns the integers from start up to (not including) stop
+func sprigUntilStep(start, stop, step int) []int {
+	var out []int
+	switch {
+	case step > 0:
+		for i := start; i < stop; i += step {
+			out = append(out, i)
+		}
+	case step < 0:
+		for i := start; i > stop; i += step {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// ============================================================================
+// Sprig dictionary helpers
+// ============================================================================
+
+// sprigDict builds a map from key/value pairs; a missing last value is ""
+func sprigDict(pairs ...any) map[string]any {
+	d := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key := sprigToString(pairs[i])
+		if i+1 < len(pairs) {
+			d[key] = pairs[i+1]
+		} else {
+			d[key] = ""
+		}
+	}
+	return d
+}
+
+func sprigGet(d map[string]any, key string) any {
+	if v, ok := d[key]; ok {
+		return v
+	}
+	return ""
+}
+
+// sprigMergeOverwrite merges srcs into dst, later values winning; nested maps are merged recursively
+func sprigMergeOverwrite(dst map[string]any, srcs ...map[string]any) map[string]any {
+	for _, src := range srcs {
+		for k, v := range src {
+			dstMap, dstOK := dst[k].(map[string]any)
+			srcMap, srcOK := v.(map[string]any)
+			if dstOK && srcOK {
+				dst[k] = sprigMergeOverwrite(dstMap, srcMap)
+			} else {
+				dst[k] = v
+			}
+		}
+	}
+	return dst
+}
+
+// ============================================================================
+// Sprig date helpers
+// ============================================================================
+
+// sprigTime converts a time.Time, an RFC3339 string, or Unix seconds to a time
+func sprigTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, errors.New("nil time")
+		}
+		return *t, nil
+	case string:
+		return time.Parse(time.RFC3339, t)
+	case int, int32, int64, float64:
+		return time.Unix(int64(toNumber(t)), 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("cannot use %T as a time", v)
+	}
+}
+
+func sprigDate(layout string, t any) (string, error) {
+	return sprigDateInZone(layout, t, "Local")
+}
+
+func sprigDateInZone(layout string, t any, zone string) (string, error) {
+	tm, err := sprigTime(t)
+	if err != nil {
+		return "", err
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return "", err
+	}
+	return tm.In(loc).Format(layout), nil
+}
+
+// sprigDateModify adds a duration such as "-1.5h" or "30m" to t
+func sprigDateModify(mod string, t any) (time.Time, error) {
+	tm, err := sprigTime(t)
+	if err != nil {
+		return time.Time{}, err
+	}
+	d, err := time.ParseDuration(mod)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return tm.Add(d), nil
+}
+
+func sprigAgo(t any) (string, error) {
+	tm, err := sprigTime(t)
+	if err != nil {
+		return "", err
+	}
+	return time.Since(tm).Round(time.Second).String(), nil
+}
+
+// ============================================================================
+// Sprig encoding, default and type helpers
+// ============================================================================
+
+func sprigToJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func sprigToRawJSON(v any) (string, error) {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func sprigToPrettyJSON(v any) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	return string(b), err
+}
+
+func sprigFromJSON(s string) (any, error) {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func sprigRequired(msg string, v any) (any, error) {
+	if v == nil {
+		return nil, errors.New(msg)
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+func sprigKindOf(v any) string {
+	if v == nil {
+		return "invalid"
+	}
+	return reflect.ValueOf(v).Kind().String()
+}
@@ -0,0 +1,158 @@
+package tmpl
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+)
+
+// TestSprigFuncMap verifies sprig functions with sprig's argument order
+func TestSprigFuncMap(t *testing.T) {
+	data := map[string]any{
+		"name":  "HTTPServer error_count",
+		"items": []any{"b", "a", "b", nil, "c"},
+		"nums":  []int{1, 2, 3, 4, 5},
+		"row":   map[string]any{"id": float64(7), "tags": "x,y"},
+		"ts":    "2024-03-01T10:00:00Z",
+	}
+	tests := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{"trimAll", `{{trimAll "-" "--a--"}}`, "a"},
+		{"trimPrefix", `{{"v1.2" | trimPrefix "v"}}`, "1.2"},
+		{"title", `{{title "hello big world"}}`, "Hello Big World"},
+		{"squote", `{{squote "a" 1}}`, "'a' '1'"},
+		{"cat", `{{cat "a" nil 2}}`, "a 2"},
+		{"indent", `{{indent 2 "a\nb"}}`, "  a\n  b"},
+		{"nindent", `{{nindent 1 "a"}}`, "\n a"},
+		{"nospace", `{{nospace " a b\tc "}}`, "abc"},
+		{"abbrev", `{{abbrev 6 "hello world"}}`, "hel..."},
+		{"initials", `{{initials "John Ronald Tolkien"}}`, "JRT"},
+		{"camelcase", `{{camelcase "http_server"}}`, "HttpServer"},
+		{"snakecase", `{{snakecase .name}}`, "http_server_error_count"},
+		{"kebabcase", `{{kebabcase "fooBar"}}`, "foo-bar"},
+		{"swapcase", `{{swapcase "aB"}}`, "Ab"},
+		{"plural", `{{plural "item" "items" 2}}`, "items"},
+		{"splitList", `{{splitList "," .row.tags | last}}`, "y"},
+		{"regexFind", `{{regexFind "[0-9]+" "abc123def"}}`, "123"},
+		{"regexReplaceAll", `{{regexReplaceAll "a(x*)b" "-ab-axxb-" "${1}W"}}`, "-W-xxW-"},
+		{"regexSplit", `{{regexSplit "[,;]" "a,b;c" -1 | len}}`, "3"},
+		{"list and append", `{{append (list 1 2) 3 | len}}`, "3"},
+		{"prepend", `{{prepend (list 2) 1}}`, "[1 2]"},
+		{"concat", `{{concat .nums (list 6)}}`, "[1 2 3 4 5 6]"},
+		{"rest", `{{rest .nums}}`, "[2 3 4 5]"},
+		{"initial", `{{initial .nums}}`, "[1 2 3 4]"},
+		{"reverse", `{{reverse .nums}}`, "[5 4 3 2 1]"},
+		{"uniq and compact", `{{.items | compact | uniq}}`, "[b a c]"},
+		{"without", `{{without .items "b" nil}}`, "[a c]"},
+		{"sortAlpha", `{{.items | compact | sortAlpha}}`, "[a b b c]"},
+		{"chunk", `{{chunk 2 .nums}}`, "[[1 2] [3 4] [5]]"},
+		{"until", `{{until 3}}`, "[0 1 2]"},
+		{"untilStep", `{{untilStep 10 0 -4}}`, "[10 6 2]"},
+		{"dict and get", `{{$d := dict "a" 1 "b"}}{{get $d "a"}}/{{get $d "b"}}/{{get $d "c"}}`, "1//"},
+		{"set and hasKey", `{{$d := dict}}{{$_ := set $d "k" 1}}{{hasKey $d "k"}}`, "true"},
+		{"unset", `{{$d := dict "k" 1}}{{$_ := unset $d "k"}}{{hasKey $d "k"}}`, "false"},
+		{"mergeOverwrite", `{{$d := mergeOverwrite (dict "a" 1 "n" (dict "x" 1)) (dict "a" 2 "n" (dict "y" 2))}}{{toJson $d}}`, `{"a":2,"n":{"x":1,"y":2}}`},
+		{"add1", `{{add1 .row.id}}`, "8"},
+		{"atoi", `{{atoi "42"}}`, "42"},
+		{"int", `{{int .row.id}}`, "7"},
+		{"dateInZone", `{{dateInZone "2006-01-02 15:04" .ts "UTC"}}`, "2024-03-01 10:00"},
+		{"dateModify", `{{dateInZone "15:04" (dateModify "-90m" .ts) "UTC"}}`, "08:30"},
+		{"unix timestamp", `{{dateInZone "2006-01-02" 86400 "UTC"}}`, "1970-01-02"},
+		{"unixEpoch", `{{unixEpoch .ts}}`, "1709287200"},
+		{"duration", `{{duration 95}}`, "1m35s"},
+		{"toJson", `{{toJson .row}}`, `{"id":7,"tags":"x,y"}`},
+		{"toRawJson", `{{toRawJson "<a>"}}`, `"<a>"`},
+		{"fromJson", `{{(fromJson "{\"a\":[1,2]}").a | len}}`, "2"},
+		{"b64", `{{b64enc "hi" | b64dec}}`, "hi"},
+		{"b32", `{{b32enc "hi"}}`, "NBUQ===="},
+		{"sha1sum", `{{sha1sum "abc"}}`, "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"empty", `{{empty ""}} {{empty "x"}}`, "true false"},
+		{"kindOf", `{{kindOf .nums}} {{kindIs "map" .row}}`, "slice true"},
+		{"deepEqual", `{{deepEqual (list 1 2) (list 1 2)}}`, "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runSprig(tt.tmpl, data)
+			if err != nil {
+				t.Fatalf("execute: %v", err)
+			}
+			if out != tt.want {
+				t.Errorf("got %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+// TestSprigFuncMap_Errors verifies failing sprig functions return errors instead of panicking
+func TestSprigFuncMap_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		wantErr string
+	}{
+		{"bad regex", `{{regexFind "(" "x"}}`, "missing closing )"},
+		{"bad json", `{{fromJson "{"}}`, "unexpected end of JSON input"},
+		{"not a list", `{{rest "abc"}}`, "expected a list"},
+		{"bad chunk size", `{{chunk 0 (list 1)}}`, "chunk size must be positive"},
+		{"bad time", `{{date "2006" "yesterday"}}`, "cannot parse"},
+		{"required", `{{required "name is required" ""}}`, "name is required"},
+		{"fail", `{{fail "stop here"}}`, "stop here"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runSprig(tt.tmpl, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestSprigFuncMap_NoOverrides verifies sprig never replaces a built-in or reserved function
+func TestSprigFuncMap_NoOverrides(t *testing.T) {
+	for name, fn := range SprigFuncMap() {
+		if err := checkFunc(name, fn, true); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if !IsSprigFunc(name) {
+			t.Errorf("IsSprigFunc(%q) = false", name)
+		}
+	}
+	if IsSprigFunc("upper") {
+		t.Error("IsSprigFunc should be false for built-in functions")
+	}
+}
+
+// TestEnableSprig verifies EnableSprig makes sprig functions available to engines
+func TestEnableSprig(t *testing.T) {
+	resetPlugins(t)
+	t.Cleanup(func() { sprigOnce = sync.Once{} })
+
+	EnableSprig()
+	EnableSprig() // second call is a no-op rather than a duplicate registration panic
+
+	e := New()
+	if err := e.Register("sprig", `{{snakecase "fooBar"}}`, UsagePreQuery); err != nil {
+		t.Fatalf("register template: %v", err)
+	}
+	out, err := e.Execute("sprig", &Context{})
+	if err != nil || out != "foo_bar" {
+		t.Errorf("Execute() = %q, %v, want foo_bar", out, err)
+	}
+}
+
+func runSprig(text string, data any) (string, error) {
+	t, err := template.New("test").Funcs(BaseFuncMap()).Funcs(SprigFuncMap()).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	err = t.Execute(&buf, data)
+	return buf.String(), err
+}
@@ -7,14 +7,32 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"sql-proxy/internal/decimal"
 )
 
 // ParamConfig defines a parameter for queries or workflow triggers.
 type ParamConfig struct {
 	Name     string `yaml:"name"`
-	Type     string `yaml:"type"` // string, int, integer, float, double, bool, boolean, datetime, date, json, int[], string[], float[], bool[]
+	Type     string `yaml:"type"` // string, int, integer, float, double, decimal, bool, boolean, datetime, date, json, int[], string[], float[], bool[]
 	Required bool   `yaml:"required"`
 	Default  string `yaml:"default"`
+
+	// Validation rules checked before any step runs (optional)
+	Validation *ParamValidation `yaml:"validation,omitempty" json:",omitempty"`
+}
+
+// ParamValidation defines the rules a parameter value must satisfy. For array types,
+// min_length/max_length bound the number of elements and the other rules apply to each element.
+type ParamValidation struct {
+	Min       *float64 `yaml:"min,omitempty"`        // Smallest allowed number (numeric types)
+	Max       *float64 `yaml:"max,omitempty"`        // Largest allowed number (numeric types)
+	MinLength *int     `yaml:"min_length,omitempty"` // Minimum characters (string) or elements (arrays)
+	MaxLength *int     `yaml:"max_length,omitempty"` // Maximum characters (string) or elements (arrays)
+	Pattern   string   `yaml:"pattern,omitempty"`    // Regular expression the value must match (string types)
+	Enum      []string `yaml:"enum,omitempty"`       // Allowed values
+	Expr      string   `yaml:"expr,omitempty"`       // Boolean expression over value and params
+	Message   string   `yaml:"message,omitempty"`    // Error message when expr is false
 }
 
 // ValidParamTypes defines all valid parameter types
@@ -24,6 +42,7 @@ var ValidParamTypes = map[string]bool{
 	"integer":  true,
 	"float":    true,
 	"double":   true,
+	"decimal":  true,
 	"bool":     true,
 	"boolean":  true,
 	"datetime": true,
@@ -101,6 +120,8 @@ func ConvertValue(value, typeName string) (any, error) {
 		return nil, fmt.Errorf("invalid datetime format")
 	case "float", "double":
 		return strconv.ParseFloat(value, 64)
+	case "decimal":
+		return parseDecimal(value)
 	default:
 		return value, nil
 	}
@@ -152,6 +173,19 @@ func ConvertJSONValue(v any, typeName string) (any, error) {
 		default:
 			return nil, fmt.Errorf("expected number, got %T", v)
 		}
+	case "decimal":
+		switch val := v.(type) {
+		case float64:
+			d, err := decimal.FromFloat(val)
+			if err != nil {
+				return nil, err
+			}
+			return d.String(), nil
+		case string:
+			return parseDecimal(val)
+		default:
+			return nil, fmt.Errorf("expected decimal, got %T", v)
+		}
 	case "bool", "boolean":
 		switch val := v.(type) {
 		case bool:
@@ -194,6 +228,16 @@ func ConvertJSONValue(v any, typeName string) (any, error) {
 	}
 }
 
+// parseDecimal validates a decimal and returns it as a string, so the exact
+// digits reach the database instead of a float64 approximation
+func parseDecimal(value string) (any, error) {
+	d, err := decimal.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return d.String(), nil
+}
+
 // ValidateArrayElements validates and converts array elements to the appropriate type.
 func ValidateArrayElements(arr []any, baseType string) ([]any, error) {
 	result := make([]any, len(arr))
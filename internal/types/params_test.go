@@ -103,6 +103,23 @@ func TestConvertValue(t *testing.T) {
 			typeName: "int",
 			wantErr:  true,
 		},
+		{
+			name:     "decimal keeps digits",
+			value:    "12345678901234567890.10",
+			typeName: "decimal",
+			wantErr:  false,
+			checkFunc: func(t *testing.T, got any) {
+				if got != "12345678901234567890.10" {
+					t.Errorf("expected exact decimal string, got %v", got)
+				}
+			},
+		},
+		{
+			name:     "invalid decimal",
+			value:    "12,50",
+			typeName: "decimal",
+			wantErr:  true,
+		},
 		{
 			name:     "bool true",
 			value:    "true",
@@ -314,6 +331,34 @@ func TestConvertJSONValue(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:     "decimal from float64",
+			value:    float64(0.1),
+			typeName: "decimal",
+			wantErr:  false,
+			checkFunc: func(t *testing.T, got any) {
+				if got != "0.1" {
+					t.Errorf("expected \"0.1\", got %v", got)
+				}
+			},
+		},
+		{
+			name:     "decimal from string",
+			value:    "19.990",
+			typeName: "decimal",
+			wantErr:  false,
+			checkFunc: func(t *testing.T, got any) {
+				if got != "19.990" {
+					t.Errorf("expected \"19.990\", got %v", got)
+				}
+			},
+		},
+		{
+			name:     "decimal from invalid type",
+			value:    true,
+			typeName: "decimal",
+			wantErr:  true,
+		},
 		{
 			name:     "float from invalid type",
 			value:    "notanumber",
@@ -566,7 +611,7 @@ func TestValidateArrayElements(t *testing.T) {
 
 func TestValidParamTypes(t *testing.T) {
 	expectedTypes := []string{
-		"string", "int", "integer", "float", "double",
+		"string", "int", "integer", "float", "double", "decimal",
 		"bool", "boolean", "datetime", "date", "json",
 		"int[]", "string[]", "float[]", "bool[]",
 	}
@@ -0,0 +1,171 @@
+// Package upgrade replaces a running server with a new process started from
+// the executable and config on disk, without refusing a connection.
+//
+// The old process starts the new one with its listening sockets. Both accept
+// connections on them until the new process is serving and signals the old one
+// with SIGTERM, which then drains its requests and exits. Work that must not
+// run twice (cron schedules, watchers) starts in the new process once the old
+// one has exited, which the new process sees as the end of a pipe.
+package upgrade
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"sql-proxy/internal/systemd"
+)
+
+// Environment of the new process
+const (
+	envListeners = "SQL_PROXY_UPGRADE_LISTENERS" // Names of the inherited sockets, from fd 3, ':'-separated
+	envParent    = "SQL_PROXY_UPGRADE_PARENT"    // Fd of the pipe closed when the old process exits
+)
+
+// firstFD is the file descriptor of the first inherited socket (ExtraFiles start at 3)
+const firstFD = 3
+
+// exitPipe holds the write ends of the pipes of spawned processes, closed by
+// the operating system when this process exits
+var exitPipe []*os.File
+
+// Spawn starts the executable on disk with the arguments of this process,
+// handing it the sockets. The returned process signals this one with SIGTERM
+// once it serves requests; if it exits before that, the upgrade failed.
+func Spawn(sockets []systemd.Listener) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("find executable: %w", err)
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	names := make([]string, len(sockets))
+	for i, sock := range sockets {
+		fl, ok := sock.Listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, fmt.Errorf("listener %s cannot be handed over", sock.Name)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return nil, fmt.Errorf("listener %s: %w", sock.Name, err)
+		}
+		files = append(files, f)
+		names[i] = sock.Name
+	}
+
+	// The new process holds the read end; it reaches EOF when this process,
+	// the only holder of the write end, exits
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, r)
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(childEnv(os.Environ()),
+		envListeners+"="+strings.Join(names, ":"),
+		envParent+"="+strconv.Itoa(firstFD+len(sockets)),
+	)
+	if err := cmd.Start(); err != nil {
+		_ = w.Close()
+		return nil, fmt.Errorf("start %s: %w", exe, err)
+	}
+	exitPipe = append(exitPipe, w) // Kept open until this process exits
+	return cmd, nil
+}
+
+// childEnv drops the variables describing this process's sockets and upgrade
+func childEnv(env []string) []string {
+	out := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		switch name {
+		case envListeners, envParent, "LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES":
+			continue
+		}
+		out = append(out, kv)
+	}
+	return out
+}
+
+// env holds the upgrade variables this process started with, read once so
+// every server of the process sees the same upgrade
+var env = sync.OnceValues(func() (parent, listeners string) {
+	parent, listeners = os.Getenv(envParent), os.Getenv(envListeners)
+	_ = os.Unsetenv(envParent)
+	_ = os.Unsetenv(envListeners)
+	return parent, listeners
+})
+
+// Upgrading reports whether this process was started by Spawn
+func Upgrading() bool {
+	parent, _ := env()
+	return parent != ""
+}
+
+// Inherited returns the sockets handed over by the old process
+func Inherited() ([]systemd.Listener, error) {
+	_, names := env()
+	if !Upgrading() || names == "" {
+		return nil, nil
+	}
+	return systemd.FileListeners(firstFD, strings.Split(names, ":"))
+}
+
+// ready holds the result of the first call to Ready
+var ready struct {
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// Ready tells the old process to drain and exit, and returns a channel closed
+// once it has. Outside an upgrade the channel is closed already. Later calls
+// return the same channel.
+func Ready() (<-chan struct{}, error) {
+	ready.once.Do(func() {
+		ready.done, ready.err = signalParent()
+	})
+	return ready.done, ready.err
+}
+
+// signalParent sends SIGTERM to the old process and watches for its exit
+func signalParent() (chan struct{}, error) {
+	done := make(chan struct{})
+	parentFD, _ := env()
+	fd, err := strconv.Atoi(parentFD)
+	if err != nil {
+		close(done)
+		return done, nil
+	}
+
+	pipe := os.NewFile(uintptr(fd), "upgrade-parent")
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(io.Discard, pipe)
+		_ = pipe.Close()
+	}()
+
+	parent, err := os.FindProcess(os.Getppid())
+	if err == nil {
+		err = parent.Signal(syscall.SIGTERM)
+	}
+	if err != nil {
+		return done, fmt.Errorf("signal old process: %w", err)
+	}
+	return done, nil
+}
@@ -0,0 +1,112 @@
+//go:build !windows
+
+package upgrade
+
+import (
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"sql-proxy/internal/systemd"
+)
+
+// TestSpawn verifies the new process serves the handed over socket, then signals the old one to stop
+func TestSpawn(t *testing.T) {
+	if Upgrading() {
+		runChild()
+		return
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestSpawn$"}
+	defer func() { os.Args = args }()
+	cmd, err := Spawn([]systemd.Listener{{Listener: ln, Name: "main"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the new process accepts connections on the shared socket
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	got, err := io.ReadAll(conn)
+	_ = conn.Close()
+	if err != nil || string(got) != "main" {
+		t.Errorf("response = %q, %v; want the socket name from the new process", got, err)
+	}
+
+	select {
+	case <-stop:
+	case <-time.After(10 * time.Second):
+		t.Error("new process did not signal the old one")
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("new process: %v", err)
+	}
+}
+
+// runChild is the new process of TestSpawn
+func runChild() {
+	sockets, err := Inherited()
+	if err != nil || len(sockets) != 1 {
+		os.Exit(2)
+	}
+	conn, err := sockets[0].Accept()
+	if err != nil {
+		os.Exit(3)
+	}
+	_, _ = conn.Write([]byte(sockets[0].Name))
+	_ = conn.Close()
+	if _, err := Ready(); err != nil {
+		os.Exit(4)
+	}
+	os.Exit(0)
+}
+
+// TestChildEnv verifies the socket and upgrade variables of this process are not passed on
+func TestChildEnv(t *testing.T) {
+	env := []string{"PATH=/usr/bin", "LISTEN_FDS=2", "LISTEN_PID=10", "LISTEN_FDNAMES=a:b", envListeners + "=main", envParent + "=4", "DB_PASSWORD=x"}
+	if got, want := childEnv(env), []string{"PATH=/usr/bin", "DB_PASSWORD=x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("childEnv() = %v, want %v", got, want)
+	}
+}
+
+// TestReady_NotUpgrading verifies Ready outside an upgrade signals nothing and returns the same closed channel
+func TestReady_NotUpgrading(t *testing.T) {
+	t.Setenv(envParent, "")
+	if Upgrading() {
+		t.Fatal("Upgrading() = true")
+	}
+	if sockets, err := Inherited(); sockets != nil || err != nil {
+		t.Errorf("Inherited() = %v, %v", sockets, err)
+	}
+	done, err := Ready()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Error("channel not closed")
+	}
+	if again, _ := Ready(); again != done {
+		t.Error("second call to Ready returned another channel")
+	}
+}
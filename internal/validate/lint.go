@@ -0,0 +1,427 @@
+package validate
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/sqlutil"
+	"sql-proxy/internal/workflow"
+)
+
+// Lint rule severities
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityOff     = "off"
+)
+
+// finding is a best-practice rule broken by the config
+type finding struct {
+	workflow string // Workflow the finding is in, empty for top-level sections
+	step     string // Step the finding is in, empty for the whole workflow
+	location string // Where the finding is, as printed
+	message  string
+}
+
+// lintRule is a best-practice check of the config
+type lintRule struct {
+	name  string
+	check func(cfg *config.Config) []finding
+}
+
+// lintRules are the rules applied to every config, reported as warnings unless
+// lint.rules says otherwise
+var lintRules = []lintRule{
+	{"select_star", lintSelectStar},
+	{"missing_timeout", lintMissingTimeout},
+	{"write_without_rate_limit", lintWriteWithoutRateLimit},
+	{"unbounded_iterate", lintUnboundedIterate},
+	{"unused_parameter", lintUnusedParameter},
+	{"unused_condition", lintUnusedCondition},
+	{"unused_snippet", lintUnusedSnippet},
+}
+
+// LintRules returns the names of the lint rules
+func LintRules() []string {
+	names := make([]string, len(lintRules))
+	for i, rule := range lintRules {
+		names[i] = rule.name
+	}
+	return names
+}
+
+// validateLint checks the severities and suppressions of the lint section
+func validateLint(cfg *config.Config, r *Result) {
+	rules := LintRules()
+	for _, name := range slices.Sorted(maps.Keys(cfg.Lint.Rules)) {
+		if !slices.Contains(rules, name) {
+			r.addError("lint.rules.%s: unknown rule (must be one of: %s)", name, strings.Join(rules, ", "))
+		}
+		switch cfg.Lint.Rules[name] {
+		case severityError, severityWarning, severityOff:
+		default:
+			r.addError("lint.rules.%s: invalid severity '%s' (must be error, warning, or off)", name, cfg.Lint.Rules[name])
+		}
+	}
+	for i, s := range cfg.Lint.Suppress {
+		prefix := fmt.Sprintf("lint.suppress[%d]", i)
+		if s.Rule == "" {
+			r.addError("%s: rule is required", prefix)
+		} else if !slices.Contains(rules, s.Rule) {
+			r.addError("%s: unknown rule '%s'", prefix, s.Rule)
+		}
+		if _, err := path.Match(s.Workflow, ""); err != nil {
+			r.addError("%s: invalid workflow pattern: %v", prefix, err)
+		}
+		if _, err := path.Match(s.Step, ""); err != nil {
+			r.addError("%s: invalid step pattern: %v", prefix, err)
+		}
+		if s.Step != "" && s.Workflow == "" {
+			r.addError("%s: step requires workflow", prefix)
+		}
+	}
+}
+
+// lint applies the lint rules, adding their findings as warnings or errors by
+// the severity of each rule
+func lint(cfg *config.Config, r *Result) {
+	for _, rule := range lintRules {
+		severity := cfg.Lint.Rules[rule.name]
+		if severity == severityOff {
+			continue
+		}
+		for _, f := range rule.check(cfg) {
+			if suppressed(cfg.Lint.Suppress, rule.name, f) {
+				continue
+			}
+			if severity == severityError {
+				r.addError("%s: %s (lint: %s)", f.location, f.message, rule.name)
+			} else {
+				r.addWarning("%s: %s (lint: %s)", f.location, f.message, rule.name)
+			}
+		}
+	}
+}
+
+// suppressed reports whether a suppression covers the finding of rule
+func suppressed(suppressions []config.LintSuppression, rule string, f finding) bool {
+	for _, s := range suppressions {
+		if s.Rule != rule {
+			continue
+		}
+		if s.Workflow == "" {
+			return true
+		}
+		if ok, _ := path.Match(s.Workflow, f.workflow); !ok || f.workflow == "" {
+			continue
+		}
+		if s.Step == "" {
+			return true
+		}
+		if ok, _ := path.Match(s.Step, f.step); ok && f.step != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// walkSteps calls fn for each step, nested steps included, with the name
+// findings use for it
+func walkSteps(steps []workflow.StepConfig, fn func(step *workflow.StepConfig, name string)) {
+	for i := range steps {
+		name := steps[i].Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		fn(&steps[i], name)
+		walkSteps(steps[i].Steps, fn)
+	}
+}
+
+// stepFinding returns a finding about a step of a workflow
+func stepFinding(wf *workflow.WorkflowConfig, step, format string, args ...any) finding {
+	return finding{
+		workflow: wf.Name,
+		step:     step,
+		location: fmt.Sprintf("workflow[%s].steps[%s]", wf.Name, step),
+		message:  fmt.Sprintf(format, args...),
+	}
+}
+
+var selectStarRegex = regexp.MustCompile(`(?is)\bselect\s+(?:distinct\s+|all\s+)?(?:top\s*\(?\s*\d+\s*\)?\s+)?(?:\w+\.)?\*`)
+
+// lintSelectStar flags queries selecting every column, which leak columns
+// added later and defeat covering indexes
+func lintSelectStar(cfg *config.Config) []finding {
+	var findings []finding
+	for i := range cfg.Workflows {
+		wf := &cfg.Workflows[i]
+		walkSteps(wf.Steps, func(step *workflow.StepConfig, name string) {
+			if step.IsQuery() && selectStarRegex.MatchString(sqlutil.StripLiterals(step.SQL)) {
+				findings = append(findings, stepFinding(wf, name, "SELECT * returns every column, including ones added later; list the columns needed"))
+			}
+		})
+	}
+	return findings
+}
+
+// backgroundTriggers run workflows outside of a request, so no request timeout bounds them
+var backgroundTriggers = map[string]bool{
+	workflow.TriggerTypeCron:      true,
+	workflow.TriggerTypeDBWatch:   true,
+	workflow.TriggerTypeFileWatch: true,
+	workflow.TriggerTypeMQTT:      true,
+}
+
+// lintMissingTimeout flags httpcall steps and background workflows that could
+// wait forever
+func lintMissingTimeout(cfg *config.Config) []finding {
+	var findings []finding
+	for i := range cfg.Workflows {
+		wf := &cfg.Workflows[i]
+		if wf.TimeoutSec == 0 {
+			for _, trig := range wf.Triggers {
+				if backgroundTriggers[trig.Type] {
+					findings = append(findings, finding{
+						workflow: wf.Name,
+						location: fmt.Sprintf("workflow[%s]", wf.Name),
+						message:  fmt.Sprintf("%s trigger without timeout_sec; a hung step holds the run forever", trig.Type),
+					})
+					break
+				}
+			}
+		}
+		walkSteps(wf.Steps, func(step *workflow.StepConfig, name string) {
+			if step.IsHTTPCall() && step.TimeoutSec == 0 {
+				findings = append(findings, stepFinding(wf, name, "httpcall without timeout_sec waits on the remote API as long as the workflow allows"))
+			}
+		})
+	}
+	return findings
+}
+
+// writeMethods are the HTTP methods of endpoints that change data
+var writeMethods = map[string]bool{"POST": true, "PUT": true, "PATCH": true, "DELETE": true}
+
+// lintWriteWithoutRateLimit flags HTTP endpoints accepting writes without a
+// rate limit
+func lintWriteWithoutRateLimit(cfg *config.Config) []finding {
+	var findings []finding
+	for i := range cfg.Workflows {
+		wf := &cfg.Workflows[i]
+		for j, trig := range wf.Triggers {
+			if trig.Type != workflow.TriggerTypeHTTP || len(trig.RateLimit) > 0 {
+				continue
+			}
+			methods := append([]string{trig.Method}, trig.Methods...)
+			if slices.ContainsFunc(methods, func(m string) bool { return writeMethods[strings.ToUpper(m)] }) {
+				findings = append(findings, finding{
+					workflow: wf.Name,
+					location: fmt.Sprintf("workflow[%s].triggers[%d]", wf.Name, j),
+					message:  "write endpoint without rate_limit; one client can flood the database",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+var (
+	stepRefRegex    = regexp.MustCompile(`\bsteps\.(\w+)`)
+	paramRefRegex   = regexp.MustCompile(`\btrigger\.params\.(\w+)`)
+	sqlBoundRegex   = regexp.MustCompile(`(?i)\b(limit|top|fetch\s+(first|next))\b`)
+	triggerRefRegex = regexp.MustCompile(`\btrigger\.`)
+)
+
+// lintUnboundedIterate flags blocks iterating over collections of unbounded
+// size: query results without a row limit, HTTP responses and request input
+// without a max_length
+func lintUnboundedIterate(cfg *config.Config) []finding {
+	var findings []finding
+	for i := range cfg.Workflows {
+		wf := &cfg.Workflows[i]
+		steps := make(map[string]*workflow.StepConfig)
+		walkSteps(wf.Steps, func(step *workflow.StepConfig, name string) {
+			steps[name] = step
+		})
+		walkSteps(wf.Steps, func(step *workflow.StepConfig, name string) {
+			if step.Iterate == nil {
+				return
+			}
+			if reason := unboundedReason(wf, steps, step.Iterate.Over); reason != "" {
+				findings = append(findings, stepFinding(wf, name, "iterate.over '%s' is unbounded: %s", step.Iterate.Over, reason))
+			}
+		})
+	}
+	return findings
+}
+
+// unboundedReason returns why over can yield any number of items, or "" if
+// its size is bounded
+func unboundedReason(wf *workflow.WorkflowConfig, steps map[string]*workflow.StepConfig, over string) string {
+	if m := stepRefRegex.FindStringSubmatch(over); m != nil {
+		source, ok := steps[m[1]]
+		switch {
+		case !ok:
+			return ""
+		case source.IsQuery():
+			if source.MaxRows > 0 || wf.MaxRows > 0 || sqlBoundRegex.MatchString(sqlutil.StripLiterals(source.SQL)) {
+				return ""
+			}
+			return fmt.Sprintf("set max_rows on step '%s' or limit its query", m[1])
+		case source.IsHTTPCall():
+			return fmt.Sprintf("step '%s' returns whatever the remote API sends", m[1])
+		}
+		return ""
+	}
+	if m := paramRefRegex.FindStringSubmatch(over); m != nil {
+		for _, trig := range wf.Triggers {
+			for _, p := range trig.Parameters {
+				if p.Name == m[1] && p.Validation != nil && p.Validation.MaxLength != nil {
+					return ""
+				}
+			}
+		}
+		return fmt.Sprintf("set validation.max_length on parameter '%s'", m[1])
+	}
+	if triggerRefRegex.MatchString(over) {
+		return "request input can have any number of items"
+	}
+	return ""
+}
+
+// paramUseRegex finds parameter references: trigger.params.name, params.name,
+// params["name"], index .trigger.params "name", or the whole params map
+var paramUseRegex = regexp.MustCompile(`\bparams(?:\.(\w+)|\[\s*"(\w+)"\s*\]|\s+"(\w+)")?`)
+
+// lintUnusedParameter flags trigger parameters no step, template or
+// expression of the workflow reads
+func lintUnusedParameter(cfg *config.Config) []finding {
+	var findings []finding
+	for i := range cfg.Workflows {
+		wf := &cfg.Workflows[i]
+		used, all := usedParams(wf)
+		if all {
+			continue
+		}
+		for j, trig := range wf.Triggers {
+			for _, p := range trig.Parameters {
+				if p.Name != "" && !used[p.Name] {
+					findings = append(findings, finding{
+						workflow: wf.Name,
+						location: fmt.Sprintf("workflow[%s].triggers[%d]", wf.Name, j),
+						message:  fmt.Sprintf("parameter '%s' is never used", p.Name),
+					})
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// usedParams returns the parameters the workflow refers to by name, and
+// whether it reads the whole params map, using them all
+func usedParams(wf *workflow.WorkflowConfig) (map[string]bool, bool) {
+	data, err := yaml.Marshal(wf)
+	if err != nil {
+		return nil, true
+	}
+	text := string(data)
+	used := make(map[string]bool)
+	for _, m := range paramUseRegex.FindAllStringSubmatchIndex(text, -1) {
+		if name := firstGroup(text, m); name != "" {
+			used[name] = true
+			continue
+		}
+		// A params: key of the YAML (cron params, proc params) is not a reference
+		if end := m[1]; end < len(text) && text[end] == ':' {
+			continue
+		}
+		return nil, true
+	}
+	for _, m := range sqlutil.ParamRegex.FindAllStringSubmatch(text, -1) {
+		used[m[1]] = true
+	}
+	// Procedure parameters without a value read the parameter of their name
+	walkSteps(wf.Steps, func(step *workflow.StepConfig, _ string) {
+		if step.Proc == nil {
+			return
+		}
+		for _, p := range step.Proc.Params {
+			if p.Value == "" {
+				used[p.Name] = true
+			}
+		}
+	})
+	return used, false
+}
+
+// firstGroup returns the first matched capture group of a submatch index
+func firstGroup(text string, m []int) string {
+	for g := 2; g+1 < len(m); g += 2 {
+		if m[g] >= 0 {
+			return text[m[g]:m[g+1]]
+		}
+	}
+	return ""
+}
+
+// lintUnusedCondition flags condition aliases no condition refers to
+func lintUnusedCondition(cfg *config.Config) []finding {
+	var findings []finding
+	for i := range cfg.Workflows {
+		wf := &cfg.Workflows[i]
+		used := workflow.UsedConditions(wf)
+		for _, name := range slices.Sorted(maps.Keys(wf.Conditions)) {
+			if !used[name] {
+				findings = append(findings, finding{
+					workflow: wf.Name,
+					location: fmt.Sprintf("workflow[%s].conditions[%s]", wf.Name, name),
+					message:  "condition is never used",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// lintUnusedSnippet flags sql_snippets no query step includes
+func lintUnusedSnippet(cfg *config.Config) []finding {
+	used := maps.Clone(cfg.UsedSQLSnippets)
+	if used == nil {
+		used = make(map[string]bool)
+	}
+	// Includes not expanded yet, in configs that were not loaded from a file
+	var mark func(sql string)
+	mark = func(sql string) {
+		for _, m := range sqlutil.IncludeRegex.FindAllStringSubmatch(sql, -1) {
+			if !used[m[1]] {
+				used[m[1]] = true
+				mark(cfg.SQLSnippets[m[1]])
+			}
+		}
+	}
+	for i := range cfg.Workflows {
+		walkSteps(cfg.Workflows[i].Steps, func(step *workflow.StepConfig, _ string) {
+			mark(step.SQL)
+		})
+	}
+
+	var findings []finding
+	for _, name := range slices.Sorted(maps.Keys(cfg.SQLSnippets)) {
+		if !used[name] {
+			findings = append(findings, finding{
+				location: fmt.Sprintf("sql_snippets[%s]", name),
+				message:  "snippet is never included",
+			})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,151 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/workflow"
+)
+
+// lintConfig returns a config breaking every lint rule once
+func lintConfig() *config.Config {
+	maxLen := 50
+	return &config.Config{
+		SQLSnippets: map[string]string{"active": "deleted_at IS NULL", "stale": "1 = 1"},
+		Workflows: []workflow.WorkflowConfig{
+			{
+				Name:       "orders",
+				Conditions: map[string]string{"found": "steps.fetch.count > 0", "unused": "true"},
+				Triggers: []workflow.TriggerConfig{{
+					Type:   "http",
+					Path:   "/orders",
+					Method: "POST",
+					Parameters: []workflow.ParamConfig{
+						{Name: "status", Type: "string"},
+						{Name: "note", Type: "string"},
+						{Name: "tag", Type: "string"},
+						{Name: "ids", Type: "int[]", Validation: &workflow.ParamValidation{MaxLength: &maxLen}},
+					},
+				}},
+				Steps: []workflow.StepConfig{
+					{Name: "fetch", Type: "query", SQL: `SELECT * FROM orders WHERE status = @status AND {{include "active"}}`},
+					{Name: "limited", Type: "query", SQL: "SELECT id FROM orders LIMIT 10"},
+					{Name: "each", Condition: "found", Iterate: &workflow.IterateConfig{Over: "steps.fetch.data", As: "o"}, Steps: []workflow.StepConfig{
+						{Name: "call", Type: "httpcall", URL: `https://api.example.com/{{index .trigger.params "tag"}}`},
+					}},
+					{Name: "bounded", Iterate: &workflow.IterateConfig{Over: "steps.limited.data", As: "o"}, Steps: []workflow.StepConfig{}},
+					{Name: "input", Iterate: &workflow.IterateConfig{Over: "trigger.params.ids", As: "id"}, Steps: []workflow.StepConfig{}},
+					{Type: "response", Template: "{}"},
+				},
+			},
+			{
+				Name:     "nightly",
+				Triggers: []workflow.TriggerConfig{{Type: "cron", Schedule: "0 0 * * *", Params: map[string]string{"x": "1"}}},
+				Steps:    []workflow.StepConfig{{Name: "purge", Type: "query", SQL: "DELETE FROM sessions"}},
+			},
+			{
+				Name: "dump",
+				Triggers: []workflow.TriggerConfig{{
+					Type:       "http",
+					Path:       "/dump",
+					Method:     "GET",
+					Parameters: []workflow.ParamConfig{{Name: "a", Type: "string"}, {Name: "b", Type: "string"}},
+				}},
+				Steps: []workflow.StepConfig{{Type: "response", Template: "{{json .trigger.params}}"}},
+			},
+		},
+	}
+}
+
+// TestLint verifies each rule reports what it checks and nothing else
+func TestLint(t *testing.T) {
+	r := &Result{Valid: true}
+	lint(lintConfig(), r)
+	if !r.Valid {
+		t.Fatalf("findings are warnings by default, got errors: %v", r.Errors)
+	}
+	warnings := strings.Join(r.Warnings, "\n")
+
+	want := []string{
+		"workflow[orders].steps[fetch]: SELECT * returns every column, including ones added later; list the columns needed (lint: select_star)",
+		"workflow[orders].steps[call]: httpcall without timeout_sec",
+		"workflow[nightly]: cron trigger without timeout_sec",
+		"workflow[orders].triggers[0]: write endpoint without rate_limit",
+		"workflow[orders].steps[each]: iterate.over 'steps.fetch.data' is unbounded: set max_rows on step 'fetch' or limit its query",
+		"workflow[orders].triggers[0]: parameter 'note' is never used (lint: unused_parameter)",
+		"workflow[orders].conditions[unused]: condition is never used (lint: unused_condition)",
+		"sql_snippets[stale]: snippet is never included (lint: unused_snippet)",
+	}
+	for _, w := range want {
+		if !strings.Contains(warnings, w) {
+			t.Errorf("missing warning %q", w)
+		}
+	}
+	if len(r.Warnings) != len(want) {
+		t.Errorf("got %d warnings, want %d:\n%s", len(r.Warnings), len(want), warnings)
+	}
+}
+
+// TestLint_SeverityAndSuppress verifies rules can be raised to errors, turned off, and suppressed per workflow and step
+func TestLint_SeverityAndSuppress(t *testing.T) {
+	cfg := lintConfig()
+	cfg.Lint = config.LintConfig{
+		Rules: map[string]string{"select_star": "error", "unused_snippet": "off"},
+		Suppress: []config.LintSuppression{
+			{Rule: "missing_timeout", Workflow: "orders", Step: "call", Reason: "the API answers fast"},
+			{Rule: "missing_timeout", Workflow: "night*"},
+			{Rule: "unused_condition"},
+		},
+	}
+	r := &Result{Valid: true}
+	lint(cfg, r)
+
+	if r.Valid || len(r.Errors) != 1 || !strings.Contains(r.Errors[0], "(lint: select_star)") {
+		t.Errorf("errors = %v, want the select_star finding", r.Errors)
+	}
+	for _, w := range r.Warnings {
+		for _, rule := range []string{"select_star", "unused_snippet", "missing_timeout", "unused_condition"} {
+			if strings.Contains(w, "(lint: "+rule+")") {
+				t.Errorf("unexpected warning %q", w)
+			}
+		}
+	}
+	if len(r.Warnings) != 3 {
+		t.Errorf("got warnings %v, want write_without_rate_limit, unbounded_iterate and unused_parameter", r.Warnings)
+	}
+}
+
+// TestValidateLint tests rule names, severities, and suppressions of the lint section
+func TestValidateLint(t *testing.T) {
+	tests := []struct {
+		name    string
+		lint    config.LintConfig
+		wantErr string
+	}{
+		{name: "valid", lint: config.LintConfig{
+			Rules:    map[string]string{"select_star": "error", "unused_parameter": "off"},
+			Suppress: []config.LintSuppression{{Rule: "missing_timeout", Workflow: "sync_*", Step: "call"}},
+		}},
+		{name: "unknown rule", lint: config.LintConfig{Rules: map[string]string{"tabs": "error"}}, wantErr: "lint.rules.tabs: unknown rule"},
+		{name: "invalid severity", lint: config.LintConfig{Rules: map[string]string{"select_star": "fatal"}}, wantErr: "invalid severity 'fatal'"},
+		{name: "suppress without rule", lint: config.LintConfig{Suppress: []config.LintSuppression{{Workflow: "x"}}}, wantErr: "lint.suppress[0]: rule is required"},
+		{name: "suppress unknown rule", lint: config.LintConfig{Suppress: []config.LintSuppression{{Rule: "tabs"}}}, wantErr: "unknown rule 'tabs'"},
+		{name: "invalid pattern", lint: config.LintConfig{Suppress: []config.LintSuppression{{Rule: "select_star", Workflow: "["}}}, wantErr: "invalid workflow pattern"},
+		{name: "step without workflow", lint: config.LintConfig{Suppress: []config.LintSuppression{{Rule: "select_star", Step: "fetch"}}}, wantErr: "step requires workflow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Result{Valid: true}
+			validateLint(&config.Config{Lint: tt.lint}, r)
+
+			if tt.wantErr == "" {
+				if !r.Valid {
+					t.Errorf("expected validation to pass, got errors: %v", r.Errors)
+				}
+			} else if r.Valid || !strings.Contains(strings.Join(r.Errors, "\n"), tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErr, r.Errors)
+			}
+		})
+	}
+}
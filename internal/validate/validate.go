@@ -1,15 +1,39 @@
 package validate
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
 	"fmt"
+	"maps"
+	netmail "net/mail"
+	"os"
+	"reflect"
 	"regexp"
+	"slices"
 	"strings"
+	"text/template"
 	"time"
 
+	"sql-proxy/internal/amqp"
+	"sql-proxy/internal/cassette"
+	"sql-proxy/internal/compress"
 	"sql-proxy/internal/config"
+	"sql-proxy/internal/crud"
 	"sql-proxy/internal/db"
-	"sql-proxy/internal/publicid"
+	"sql-proxy/internal/errtrack"
+	"sql-proxy/internal/i18n"
+	"sql-proxy/internal/kafka"
+	"sql-proxy/internal/keyring"
+	"sql-proxy/internal/mail"
+	"sql-proxy/internal/mqtt"
+	"sql-proxy/internal/objstore"
+	"sql-proxy/internal/outbox"
+	"sql-proxy/internal/policy"
+	"sql-proxy/internal/ratelimit"
+	"sql-proxy/internal/redact"
+	"sql-proxy/internal/sftp"
+	"sql-proxy/internal/sqlutil"
 	"sql-proxy/internal/tmpl"
 	"sql-proxy/internal/workflow"
 )
@@ -32,31 +56,235 @@ func (r *Result) addWarning(format string, args ...any) {
 
 // Run validates config format, then tests DB connections if config is complete
 func Run(cfg *config.Config) *Result {
+	r := Check(cfg)
+
+	// If format is valid, test database connections
+	if r.Valid {
+		testDBConnections(cfg, r)
+	}
+
+	return r
+}
+
+// Check validates config format and applies the lint rules, without
+// connecting to databases
+func Check(cfg *config.Config) *Result {
 	r := &Result{Valid: true}
 
 	// Validate format
 	validateServer(cfg, r)
 	validateDatabase(cfg, r)
+	validateTenantRouting(cfg, r)
 	validateLogging(cfg, r)
+	validateService(cfg, r)
 	validateDebug(cfg, r)
+	validateObservability(cfg, r)
+	validateAdminAuth(cfg, r)
+	validateGRPC(cfg, r)
+	validateListeners(cfg, r)
 	validateRateLimits(cfg, r)
 	validatePublicIDs(cfg, r)
+	validateCryptoKeys(cfg, r)
+	validateMessages(cfg, r)
+	validateSMTP(cfg, r)
+	validateStorage(cfg, r)
+	validateSFTP(cfg, r)
+	validateMQTT(cfg, r)
+	validateOutbox(cfg, r)
+	validateSQLSnippets(cfg, r)
+	validateTemplates(cfg, r)
+	validateCrud(cfg, r)
+	validateStatic(cfg, r)
+	validateRouteGroups(cfg, r)
+	validateAlerts(cfg, r)
+	validateCachePreload(cfg, r)
+	validateHTTPRecording(cfg, r)
+	validateChaos(cfg, r)
 
 	// Validate workflows
-	if len(cfg.Workflows) == 0 {
+	if len(cfg.Workflows) == 0 && len(cfg.Crud) == 0 {
 		r.addWarning("No workflows configured - service will have no endpoints")
-	} else {
+	} else if len(cfg.Workflows) > 0 {
 		validateWorkflows(cfg, r)
+		validateStatementPolicies(cfg, r)
+		validateMetricSteps(cfg, r)
 	}
 
-	// If format is valid, test database connections
-	if r.Valid {
-		testDBConnections(cfg, r)
+	// Best-practice rules
+	validateLint(cfg, r)
+	lint(cfg, r)
+
+	return r
+}
+
+// Database validates a database being added to a running server alongside the
+// configured ones. It checks the format only; connecting is left to the caller.
+func Database(cfg *config.Config, dbCfg config.DatabaseConfig) *Result {
+	r := &Result{Valid: true}
+	withDB := *cfg
+	withDB.Databases = append(slices.Clone(cfg.Databases), dbCfg)
+	validateDatabase(&withDB, r)
+	validateTenantRouting(&withDB, r)
+	return r
+}
+
+// Workflows validates workflows about to be deployed to a running server: each
+// one replaces the running workflow of the same name or joins the others. They
+// are checked against the server's databases, rate limit pools and other named
+// resources, and must not take the routes or gRPC methods of the workflows
+// they don't replace. Workflows must already be prepared by config.PrepareWorkflows.
+func Workflows(cfg *config.Config, workflows []workflow.WorkflowConfig) *Result {
+	r := &Result{Valid: true}
+	if len(workflows) == 0 {
+		r.addError("workflows: at least one workflow is required")
+		return r
+	}
+
+	candidates := *cfg
+	candidates.Workflows = workflows
+	validateWorkflows(&candidates, r)
+	validateStatementPolicies(&candidates, r)
+	validateMetricSteps(&candidates, r)
+
+	names := make(map[string]bool)
+	for i, wf := range workflows {
+		if names[wf.Name] {
+			r.addError("workflows[%d]: duplicate workflow name '%s'", i, wf.Name)
+		}
+		names[wf.Name] = true
 	}
 
+	// Routes and gRPC methods of the running workflows that stay
+	taken := make(map[string]string)
+	for _, wf := range cfg.Workflows {
+		if names[wf.Name] {
+			continue
+		}
+		for _, route := range workflowRoutes(&wf) {
+			taken[route] = wf.Name
+		}
+	}
+	for i, wf := range workflows {
+		for _, route := range workflowRoutes(&wf) {
+			if other, ok := taken[route]; ok {
+				r.addError("workflows[%d]: %s is already served by workflow '%s'", i, route, other)
+			}
+		}
+	}
 	return r
 }
 
+// workflowRoutes returns the routes and gRPC methods a workflow is reached on
+func workflowRoutes(wf *workflow.WorkflowConfig) []string {
+	var routes []string
+	for _, trig := range wf.Triggers {
+		for _, route := range trig.Expand() {
+			switch {
+			case route.Type == workflow.TriggerTypeHTTP && route.Path != "":
+				routes = append(routes, fmt.Sprintf("route %s %s", strings.ToUpper(route.Method), route.Path))
+			case route.Type == workflow.TriggerTypeWebSocket && route.Path != "":
+				routes = append(routes, "websocket route "+route.Path)
+			case route.Type == workflow.TriggerTypeGRPC && route.RPC != "":
+				routes = append(routes, fmt.Sprintf("rpc '%s'", route.RPC))
+			}
+		}
+	}
+	return routes
+}
+
+// Instances validates the configs of an instances file: each config on its own,
+// with its errors prefixed by the instance name, and the settings the
+// instances share
+func Instances(cfgs []*config.Config) *Result {
+	return instances(cfgs, Run)
+}
+
+// CheckInstances validates the configs of an instances file like Instances,
+// without connecting to databases
+func CheckInstances(cfgs []*config.Config) *Result {
+	return instances(cfgs, Check)
+}
+
+func instances(cfgs []*config.Config, run func(*config.Config) *Result) *Result {
+	if len(cfgs) == 1 {
+		return run(cfgs[0])
+	}
+	r := &Result{Valid: true}
+	for _, cfg := range cfgs {
+		sub := run(cfg)
+		for _, e := range sub.Errors {
+			r.addError("instance '%s': %s", cfg.Server.Instance, e)
+		}
+		for _, w := range sub.Warnings {
+			r.addWarning("instance '%s': %s", cfg.Server.Instance, w)
+		}
+	}
+	validateInstances(cfgs, r)
+	return r
+}
+
+// validateInstances checks that instances listen on distinct ports and agree on
+// the settings that are process-wide
+func validateInstances(cfgs []*config.Config, r *Result) {
+	ports := map[int]string{}
+	for _, cfg := range cfgs {
+		name := cfg.Server.Instance
+		own := map[int]string{cfg.Server.Port: "server.port"}
+		if cfg.Server.AdminAuth.SeparateListener(cfg.Server.Port) {
+			own[cfg.Server.AdminAuth.Port] = "server.admin_auth.port"
+		}
+		if cfg.Debug.Enabled && cfg.Debug.Port != 0 {
+			own[cfg.Debug.Port] = "debug.port"
+		}
+		if cfg.Server.GRPC != nil {
+			own[cfg.Server.GRPC.Port] = "server.grpc.port"
+		}
+		for i, l := range cfg.Server.Listeners {
+			own[l.Port] = fmt.Sprintf("server.listeners[%d].port", i)
+		}
+		for _, port := range slices.Sorted(maps.Keys(own)) {
+			field := fmt.Sprintf("instance '%s' %s", name, own[port])
+			if other, ok := ports[port]; ok {
+				r.addError("%s: port %d conflicts with %s", field, port, other)
+				continue
+			}
+			ports[port] = field
+		}
+	}
+
+	// Template functions share one encoder, keyring and message catalog
+	first := cfgs[0]
+	for _, cfg := range cfgs[1:] {
+		name := cfg.Server.Instance
+		if !reflect.DeepEqual(cfg.PublicIDs, first.PublicIDs) {
+			r.addError("instance '%s': public_ids must match instance '%s'", name, first.Server.Instance)
+		}
+		if !reflect.DeepEqual(cfg.CryptoKeys, first.CryptoKeys) {
+			r.addError("instance '%s': crypto_keys must match instance '%s'", name, first.Server.Instance)
+		}
+		if !reflect.DeepEqual(cfg.Messages, first.Messages) {
+			r.addError("instance '%s': messages must match instance '%s'", name, first.Server.Instance)
+		}
+
+		// Set up once per process, from the first instance
+		shared := []struct {
+			field string
+			same  bool
+		}{
+			{"logging", reflect.DeepEqual(cfg.Logging, first.Logging)},
+			{"metrics", reflect.DeepEqual(cfg.Metrics, first.Metrics)},
+			{"observability", reflect.DeepEqual(cfg.Observability, first.Observability)},
+			{"server.pid_file", cfg.Server.PIDFile == first.Server.PIDFile},
+			{"service", reflect.DeepEqual(cfg.Service, first.Service)},
+		}
+		for _, sh := range shared {
+			if !sh.same {
+				r.addWarning("instance '%s': %s is ignored, the process uses that of instance '%s'", name, sh.field, first.Server.Instance)
+			}
+		}
+	}
+}
+
 func validateServer(cfg *config.Config, r *Result) {
 	// Host validation
 	if cfg.Server.Host == "" {
@@ -83,6 +311,100 @@ func validateServer(cfg *config.Config, r *Result) {
 			cfg.Server.MaxTimeoutSec, cfg.Server.DefaultTimeoutSec)
 	}
 
+	// Health check schedule
+	if hc := cfg.Server.HealthCheck; hc != nil {
+		for _, setting := range []struct {
+			name  string
+			value int
+		}{
+			{"interval_sec", hc.IntervalSec},
+			{"timeout_sec", hc.TimeoutSec},
+			{"failures_before_reconnect", hc.FailuresBeforeReconnect},
+			{"reconnect_backoff_max_sec", hc.ReconnectBackoffMaxSec},
+		} {
+			if setting.value < 0 {
+				r.addError("server.health_check.%s cannot be negative", setting.name)
+			}
+		}
+		if hc.IntervalSec > 0 && hc.TimeoutSec > hc.IntervalSec {
+			r.addWarning("server.health_check.timeout_sec (%d) exceeds interval_sec (%d)", hc.TimeoutSec, hc.IntervalSec)
+		}
+		if hc.IntervalSec > 0 && hc.ReconnectBackoffMaxSec > 0 && hc.ReconnectBackoffMaxSec < hc.IntervalSec {
+			r.addWarning("server.health_check.reconnect_backoff_max_sec (%d) is below interval_sec (%d): reconnects are attempted at most once per interval", hc.ReconnectBackoffMaxSec, hc.IntervalSec)
+		}
+	}
+
+	// Load shedding limits
+	if ls := cfg.Server.LoadShedding; ls != nil {
+		for _, setting := range []struct {
+			name  string
+			value int
+		}{
+			{"max_heap_mb", ls.MaxHeapMB},
+			{"max_inflight", ls.MaxInFlight},
+			{"max_queue_wait_ms", ls.MaxQueueWaitMs},
+			{"retry_after_sec", ls.RetryAfterSec},
+		} {
+			if setting.value < 0 {
+				r.addError("server.load_shedding.%s cannot be negative", setting.name)
+			}
+		}
+		if ls.MaxHeapMB == 0 && ls.MaxInFlight == 0 && ls.MaxQueueWaitMs == 0 {
+			r.addError("server.load_shedding requires max_heap_mb, max_inflight, or max_queue_wait_ms")
+		}
+	}
+
+	// TLS and HTTP/2 on the main listener
+	if t := cfg.Server.TLS; t != nil {
+		if t.CertFile == "" || t.KeyFile == "" {
+			r.addError("server.tls requires cert_file and key_file")
+		} else if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+			r.addError("server.tls: %v", err)
+		}
+	}
+	if h2 := cfg.Server.HTTP2; h2 != nil {
+		if h2.MaxConcurrentStreams < 0 {
+			r.addError("server.http2.max_concurrent_streams cannot be negative")
+		}
+		if h2.H2C && cfg.Server.TLS != nil {
+			r.addWarning("server.http2.h2c has no effect with server.tls: HTTP/2 is negotiated over TLS")
+		}
+	}
+
+	// Response compression
+	if cc := cfg.Server.Compression; cc != nil {
+		seen := make(map[string]bool)
+		for _, enc := range cc.Encodings {
+			if !compress.ValidEncoding(enc) {
+				r.addError("server.compression.encodings: unknown encoding %q (valid: %s)", enc, strings.Join(compress.Encodings, ", "))
+			} else if seen[enc] {
+				r.addError("server.compression.encodings: duplicate encoding %q", enc)
+			}
+			seen[enc] = true
+		}
+		if cc.MinSizeBytes < 0 {
+			r.addError("server.compression.min_size_bytes cannot be negative")
+		}
+		for _, ct := range cc.SkipContentTypes {
+			if !strings.Contains(ct, "/") {
+				r.addError("server.compression.skip_content_types: %q is not a media type (e.g. image/png or image/*)", ct)
+			}
+		}
+	}
+
+	// Worker pool size and queue
+	if wp := cfg.Server.WorkerPool; wp != nil {
+		if wp.Workers <= 0 {
+			r.addError("server.worker_pool.workers must be positive")
+		}
+		if wp.QueueSize < 0 {
+			r.addError("server.worker_pool.queue_size cannot be negative")
+		}
+		if wp.QueueTimeoutMs < 0 {
+			r.addError("server.worker_pool.queue_timeout_ms cannot be negative")
+		}
+	}
+
 	// Validate cache configuration
 	if cfg.Server.Cache != nil && cfg.Server.Cache.Enabled {
 		if cfg.Server.Cache.MaxSizeMB < 0 {
@@ -91,6 +413,34 @@ func validateServer(cfg *config.Config, r *Result) {
 		if cfg.Server.Cache.DefaultTTLSec < 0 {
 			r.addError("server.cache.default_ttl_sec cannot be negative")
 		}
+		if cfg.Server.Cache.CompressAboveKB < 0 {
+			r.addError("server.cache.compress_above_kb cannot be negative")
+		}
+		if cfg.Server.Cache.MaxTTLSec < 0 {
+			r.addError("server.cache.max_ttl_sec cannot be negative")
+		}
+		if disk := cfg.Server.Cache.Disk; disk != nil {
+			if disk.Path == "" {
+				r.addError("server.cache.disk.path is required")
+			}
+			if disk.MaxSizeMB < 0 {
+				r.addError("server.cache.disk.max_size_mb cannot be negative")
+			}
+			if disk.MaxTTLSec < 0 {
+				r.addError("server.cache.disk.max_ttl_sec cannot be negative")
+			}
+			if disk.MinSizeKB < 0 {
+				r.addError("server.cache.disk.min_size_kb cannot be negative")
+			}
+		}
+	}
+
+	// Rate limit header style
+	switch cfg.Server.RateLimitHeaders {
+	case "", ratelimit.HeaderStyleX, ratelimit.HeaderStyleIETF:
+	default:
+		r.addError("server.rate_limit_headers must be %q or %q, got: %q",
+			ratelimit.HeaderStyleX, ratelimit.HeaderStyleIETF, cfg.Server.RateLimitHeaders)
 	}
 }
 
@@ -116,31 +466,35 @@ func validateDatabase(cfg *config.Config, r *Result) {
 
 		// Validate database type
 		if dbCfg.Type == "" {
-			r.addError("%s: type is required (must be sqlserver, mysql, or sqlite)", prefix)
+			r.addError("%s: type is required (must be sqlserver, mysql, sqlite, or mock)", prefix)
 			continue
 		}
 		if !config.ValidDatabaseTypes[dbCfg.Type] {
-			r.addError("%s: invalid type '%s' (must be sqlserver, mysql, or sqlite)", prefix, dbCfg.Type)
+			r.addError("%s: invalid type '%s' (must be sqlserver, mysql, sqlite, or mock)", prefix, dbCfg.Type)
 			continue
 		}
 
-		// Type-specific validation
+		// Type-specific validation. Tenant-routed databases may leave connection
+		// settings to their tenants (see validateTenantRouting).
+		routed := dbCfg.TenantRouting != nil
 		switch dbCfg.Type {
 		case "sqlserver":
-			if dbCfg.Host == "" {
-				r.addError("%s: host is required for sqlserver", prefix)
-			}
-			if dbCfg.Port == 0 {
-				r.addError("%s: port is required for sqlserver", prefix)
-			}
-			if dbCfg.User == "" {
-				r.addError("%s: user is required for sqlserver", prefix)
-			}
-			if dbCfg.Password == "" {
-				r.addError("%s: password is required for sqlserver", prefix)
-			}
-			if dbCfg.Database == "" {
-				r.addError("%s: database is required for sqlserver", prefix)
+			if !routed {
+				if dbCfg.Host == "" {
+					r.addError("%s: host is required for sqlserver", prefix)
+				}
+				if dbCfg.Port == 0 {
+					r.addError("%s: port is required for sqlserver", prefix)
+				}
+				if dbCfg.User == "" {
+					r.addError("%s: user is required for sqlserver", prefix)
+				}
+				if dbCfg.Password == "" {
+					r.addError("%s: password is required for sqlserver", prefix)
+				}
+				if dbCfg.Database == "" {
+					r.addError("%s: database is required for sqlserver", prefix)
+				}
 			}
 
 			// Check for unresolved env vars
@@ -163,20 +517,22 @@ func validateDatabase(cfg *config.Config, r *Result) {
 			}
 
 		case "mysql":
-			if dbCfg.Host == "" {
-				r.addError("%s: host is required for mysql", prefix)
-			}
-			if dbCfg.Port == 0 {
-				r.addError("%s: port is required for mysql", prefix)
-			}
-			if dbCfg.User == "" {
-				r.addError("%s: user is required for mysql", prefix)
-			}
-			if dbCfg.Password == "" {
-				r.addError("%s: password is required for mysql", prefix)
-			}
-			if dbCfg.Database == "" {
-				r.addError("%s: database is required for mysql", prefix)
+			if !routed {
+				if dbCfg.Host == "" {
+					r.addError("%s: host is required for mysql", prefix)
+				}
+				if dbCfg.Port == 0 {
+					r.addError("%s: port is required for mysql", prefix)
+				}
+				if dbCfg.User == "" {
+					r.addError("%s: user is required for mysql", prefix)
+				}
+				if dbCfg.Password == "" {
+					r.addError("%s: password is required for mysql", prefix)
+				}
+				if dbCfg.Database == "" {
+					r.addError("%s: database is required for mysql", prefix)
+				}
 			}
 
 			// Check for unresolved env vars
@@ -204,7 +560,7 @@ func validateDatabase(cfg *config.Config, r *Result) {
 			}
 
 		case "sqlite":
-			if dbCfg.Path == "" {
+			if dbCfg.Path == "" && !routed {
 				r.addError("%s: path is required for sqlite", prefix)
 			}
 
@@ -215,6 +571,131 @@ func validateDatabase(cfg *config.Config, r *Result) {
 			if dbCfg.BusyTimeoutMs != nil && *dbCfg.BusyTimeoutMs < 0 {
 				r.addError("%s: busy_timeout_ms cannot be negative", prefix)
 			}
+
+		case "mock":
+			if routed {
+				r.addError("%s: tenant_routing is not supported for mock", prefix)
+			}
+			if dbCfg.Fixtures == "" {
+				r.addError("%s: fixtures is required for mock", prefix)
+			} else if _, err := db.LoadMockFixtures(dbCfg.Fixtures); err != nil {
+				r.addError("%s: %v", prefix, err)
+			}
+		}
+
+		// Connection pool (all types)
+		for _, setting := range []struct {
+			name  string
+			value *int
+		}{
+			{"max_open_conns", dbCfg.MaxOpenConns},
+			{"max_idle_conns", dbCfg.MaxIdleConns},
+			{"conn_max_lifetime", dbCfg.ConnMaxLifetime},
+			{"conn_max_idle_time", dbCfg.ConnMaxIdleTime},
+		} {
+			if setting.value != nil && *setting.value < 0 {
+				r.addError("%s: %s cannot be negative", prefix, setting.name)
+			}
+		}
+		if dbCfg.MaxOpenConns != nil && dbCfg.MaxIdleConns != nil && *dbCfg.MaxOpenConns > 0 && *dbCfg.MaxIdleConns > *dbCfg.MaxOpenConns {
+			r.addWarning("%s: max_idle_conns (%d) is capped at max_open_conns (%d)", prefix, *dbCfg.MaxIdleConns, *dbCfg.MaxOpenConns)
+		}
+
+		// Connection checks (all types). Neither runs with parameters.
+		for j, stmt := range dbCfg.Warmup {
+			switch {
+			case strings.TrimSpace(stmt) == "":
+				r.addError("%s: warmup[%d] is empty", prefix, j)
+			case db.ParamRegex.MatchString(stmt):
+				r.addError("%s: warmup[%d] cannot use @parameters", prefix, j)
+			case dbCfg.IsReadOnly() && db.IsWriteQuery(stmt):
+				r.addWarning("%s: warmup[%d] modifies data on a read-only connection and may fail", prefix, j)
+			}
+		}
+		if q := dbCfg.ProbeQuery; q != "" {
+			if db.IsWriteQuery(q) {
+				r.addError("%s: probe_query must not modify data (it runs on every health check)", prefix)
+			}
+			if db.ParamRegex.MatchString(q) {
+				r.addError("%s: probe_query cannot use @parameters", prefix)
+			}
+		}
+
+		// Concurrency limit (all types)
+		if dbCfg.MaxConcurrent < 0 {
+			r.addError("%s: max_concurrent cannot be negative", prefix)
+		}
+		if dbCfg.MaxConcurrentWaitMs < 0 {
+			r.addError("%s: max_concurrent_wait_ms cannot be negative", prefix)
+		}
+		if dbCfg.MaxConcurrentWaitMs > 0 && dbCfg.MaxConcurrent == 0 {
+			r.addWarning("%s: max_concurrent_wait_ms has no effect without max_concurrent", prefix)
+		}
+
+		// Statement policy (all types)
+		if pc := dbCfg.Policy; pc != nil {
+			if _, err := policy.New(pc); err != nil {
+				r.addError("%s: policy: %v", prefix, err)
+			}
+			if !pc.SelectOnly && len(pc.AllowPatterns) == 0 && len(pc.AllowedTables) == 0 {
+				r.addWarning("%s: policy has no rules (set select_only, allow_patterns or allowed_tables)", prefix)
+			}
+			if pc.DefaultSchema != "" && len(pc.AllowedTables) == 0 {
+				r.addWarning("%s: policy.default_schema has no effect without allowed_tables", prefix)
+			}
+		}
+	}
+}
+
+// validateTenantRouting checks the tenant_routing settings of databases.
+func validateTenantRouting(cfg *config.Config, r *Result) {
+	databases := make(map[string]config.DatabaseConfig)
+	for _, dbCfg := range cfg.Databases {
+		databases[dbCfg.Name] = dbCfg
+	}
+
+	for i, dbCfg := range cfg.Databases {
+		tr := dbCfg.TenantRouting
+		if tr == nil {
+			continue
+		}
+		prefix := fmt.Sprintf("databases[%d] (%s): tenant_routing", i, dbCfg.Name)
+
+		if tr.Key == "" {
+			r.addError("%s: key is required", prefix)
+		} else if _, err := template.New("tenant_key").Funcs(workflow.TemplateFuncs).Parse(tr.Key); err != nil {
+			r.addError("%s: invalid key template: %v", prefix, err)
+		}
+		if len(tr.Tenants) == 0 && tr.Lookup == nil {
+			r.addError("%s: tenants or lookup is required", prefix)
+		}
+		for tenant, t := range tr.Tenants {
+			if tenant == "" {
+				r.addError("%s: tenants: empty tenant name", prefix)
+			}
+			if t.Port < 0 {
+				r.addError("%s: tenants[%s]: port cannot be negative", prefix, tenant)
+			}
+		}
+		if l := tr.Lookup; l != nil {
+			if l.Database == "" {
+				r.addError("%s: lookup.database is required", prefix)
+			} else if lookupDB, ok := databases[l.Database]; !ok {
+				r.addError("%s: lookup.database: unknown database '%s'", prefix, l.Database)
+			} else if lookupDB.TenantRouting != nil {
+				r.addError("%s: lookup.database '%s' cannot itself be tenant-routed", prefix, l.Database)
+			}
+			if l.SQL == "" {
+				r.addError("%s: lookup.sql is required", prefix)
+			} else if !strings.Contains(l.SQL, "@tenant") {
+				r.addWarning("%s: lookup.sql does not use @tenant; every tenant gets the same row", prefix)
+			}
+		}
+		if tr.MaxTenants < 0 {
+			r.addError("%s: max_tenants cannot be negative", prefix)
+		}
+		if tr.IdleTimeoutSec < 0 {
+			r.addError("%s: idle_timeout_sec cannot be negative", prefix)
 		}
 	}
 }
@@ -241,29 +722,632 @@ func validateLogging(cfg *config.Config, r *Result) {
 	} else if cfg.Logging.MaxBackups < 0 {
 		r.addError("logging.max_backups cannot be negative")
 	}
-	if cfg.Logging.MaxAgeDays == 0 {
-		r.addError("logging.max_age_days is required")
-	} else if cfg.Logging.MaxAgeDays < 0 {
-		r.addError("logging.max_age_days cannot be negative")
+	if cfg.Logging.MaxAgeDays == 0 {
+		r.addError("logging.max_age_days is required")
+	} else if cfg.Logging.MaxAgeDays < 0 {
+		r.addError("logging.max_age_days cannot be negative")
+	}
+
+	if _, err := redact.New(cfg.Logging.Redact); err != nil {
+		r.addError("logging.redact: %v", err)
+	}
+
+	if lvl := cfg.Logging.EventLog.Level; lvl != "" {
+		validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+		if !validLevels[strings.ToLower(lvl)] {
+			r.addError("logging.event_log.level must be debug, info, warn, or error, got: %s", lvl)
+		}
+	}
+}
+
+func validateService(cfg *config.Config, r *Result) {
+	rec := cfg.Service.Recovery
+	for i, d := range rec.RestartDelaysSec {
+		if d < 0 {
+			r.addError("service.recovery.restart_delays_sec[%d] cannot be negative", i)
+		}
+	}
+	if rec.ResetAfterSec < 0 {
+		r.addError("service.recovery.reset_after_sec cannot be negative")
+	}
+	if rec.Disabled && (len(rec.RestartDelaysSec) > 0 || rec.ResetAfterSec > 0) {
+		r.addWarning("service.recovery: restart_delays_sec and reset_after_sec are ignored when disabled")
+	}
+}
+
+func validateDebug(cfg *config.Config, r *Result) {
+	if !cfg.Debug.Enabled {
+		return // Skip validation if debug is disabled
+	}
+
+	// Port validation
+	if cfg.Debug.Port < 0 || cfg.Debug.Port > 65535 {
+		r.addError("debug.port must be 0-65535, got: %d", cfg.Debug.Port)
+	}
+
+	// If debug.host is set but port is shared with main server, it's an error
+	// because the host setting will be silently ignored
+	sharesMainPort := cfg.Debug.Port == 0 || cfg.Debug.Port == cfg.Server.Port
+	if cfg.Debug.Host != "" && sharesMainPort {
+		r.addError("debug.host cannot be set when debug endpoints share the main server port (debug.port is 0 or same as server.port); debug.host only applies when using a separate debug port")
+	}
+}
+
+func validateObservability(cfg *config.Config, r *Result) {
+	o := cfg.Observability
+	if o.SentryDSN != "" {
+		if err := errtrack.ValidateSentryDSN(o.SentryDSN); err != nil {
+			r.addError("observability.sentry_dsn: %v", err)
+		}
+	}
+	if o.ErrorWebhook != "" && !strings.HasPrefix(o.ErrorWebhook, "http://") && !strings.HasPrefix(o.ErrorWebhook, "https://") {
+		r.addError("observability.error_webhook must start with http:// or https://")
+	}
+	if len(o.ErrorWebhookHeaders) > 0 && o.ErrorWebhook == "" {
+		r.addError("observability.error_webhook_headers requires observability.error_webhook")
+	}
+	if o.TimeoutSec < 0 {
+		r.addError("observability.timeout_sec cannot be negative")
+	}
+}
+
+func validateGRPC(cfg *config.Config, r *Result) {
+	g := cfg.Server.GRPC
+	if g == nil {
+		return // gRPC is optional
+	}
+
+	if g.Port < 1 || g.Port > 65535 {
+		r.addError("server.grpc.port must be 1-65535, got: %d", g.Port)
+		return
+	}
+	if g.Port == cfg.Server.Port {
+		r.addError("server.grpc.port (%d) conflicts with server.port", g.Port)
+	}
+	if cfg.Server.AdminAuth.SeparateListener(cfg.Server.Port) && cfg.Server.AdminAuth.Port == g.Port {
+		r.addError("server.grpc.port (%d) conflicts with server.admin_auth.port", g.Port)
+	}
+	if cfg.Debug.Enabled && cfg.Debug.Port == g.Port {
+		r.addError("server.grpc.port (%d) conflicts with debug.port", g.Port)
+	}
+}
+
+// validateListeners checks the additional listeners and the workflows' references to them
+func validateListeners(cfg *config.Config, r *Result) {
+	names := map[string]bool{workflow.MainListener: true}
+	ports := map[int]string{cfg.Server.Port: "server.port"}
+	if cfg.Server.AdminAuth.SeparateListener(cfg.Server.Port) {
+		ports[cfg.Server.AdminAuth.Port] = "server.admin_auth.port"
+	}
+	if cfg.Debug.Enabled && cfg.Debug.Port != 0 {
+		ports[cfg.Debug.Port] = "debug.port"
+	}
+	if cfg.Server.GRPC != nil {
+		ports[cfg.Server.GRPC.Port] = "server.grpc.port"
+	}
+
+	admin := ""
+	for i, l := range cfg.Server.Listeners {
+		prefix := fmt.Sprintf("server.listeners[%d]", i)
+		switch {
+		case l.Name == "":
+			r.addError("%s: name is required", prefix)
+		case l.Name == workflow.MainListener:
+			r.addError("%s: name '%s' is reserved for the main listener", prefix, l.Name)
+		case names[l.Name]:
+			r.addError("%s: duplicate listener name '%s'", prefix, l.Name)
+		}
+		names[l.Name] = true
+
+		if l.Port < 1 || l.Port > 65535 {
+			r.addError("%s: port must be 1-65535, got: %d", prefix, l.Port)
+		} else if other, ok := ports[l.Port]; ok {
+			r.addError("%s: port %d conflicts with %s", prefix, l.Port, other)
+		} else {
+			ports[l.Port] = prefix + ".port"
+		}
+
+		if l.Admin {
+			if admin != "" {
+				r.addError("%s: only one listener can serve the admin endpoints (also: %s)", prefix, admin)
+			} else if cfg.Server.AdminAuth.SeparateListener(cfg.Server.Port) {
+				r.addError("%s: admin cannot be combined with server.admin_auth.port", prefix)
+			}
+			admin = l.Name
+		}
+	}
+
+	for _, wf := range cfg.Workflows {
+		for _, name := range wf.Listeners {
+			if !names[name] {
+				r.addError("workflow '%s': unknown listener '%s'", wf.Name, name)
+			}
+		}
+	}
+}
+
+// minAdminTokenLength is the shortest admin bearer token accepted without a warning
+const minAdminTokenLength = 16
+
+func validateAdminAuth(cfg *config.Config, r *Result) {
+	auth := cfg.Server.AdminAuth
+	if auth == nil {
+		return // Admin auth is optional
+	}
+
+	if auth.Token == "" && auth.Username == "" && auth.Password == "" {
+		r.addError("server.admin_auth requires token or username/password")
+	}
+	if (auth.Username == "") != (auth.Password == "") {
+		r.addError("server.admin_auth.username and server.admin_auth.password must be set together")
+	}
+	if auth.Token != "" && len(auth.Token) < minAdminTokenLength {
+		r.addWarning("server.admin_auth.token is shorter than %d characters", minAdminTokenLength)
+	}
+
+	// Port validation (same rules as debug.port)
+	if auth.Port < 0 || auth.Port > 65535 {
+		r.addError("server.admin_auth.port must be 0-65535, got: %d", auth.Port)
+	}
+	if auth.Host != "" && !auth.SeparateListener(cfg.Server.Port) {
+		r.addError("server.admin_auth.host cannot be set when admin endpoints share the main server port (server.admin_auth.port is 0 or same as server.port)")
+	}
+	if auth.SeparateListener(cfg.Server.Port) && cfg.Debug.Enabled && cfg.Debug.Port == auth.Port {
+		r.addError("server.admin_auth.port (%d) conflicts with debug.port", auth.Port)
+	}
+}
+
+func validateSMTP(cfg *config.Config, r *Result) {
+	s := cfg.SMTP
+	if s == nil {
+		return // SMTP is optional
+	}
+
+	if s.Host == "" {
+		r.addError("smtp.host is required")
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		r.addError("smtp.port must be 0-65535, got: %d", s.Port)
+	}
+	if s.From == "" {
+		r.addError("smtp.from is required")
+	} else if _, err := netmail.ParseAddress(s.From); err != nil {
+		r.addError("smtp.from is not a valid address: %v", err)
+	}
+	if s.TLS != "" && !slices.Contains(mail.ValidTLSModes, s.TLS) {
+		r.addError("smtp.tls must be one of %v, got: %s", mail.ValidTLSModes, s.TLS)
+	}
+	if s.TimeoutSec < 0 {
+		r.addError("smtp.timeout_sec cannot be negative")
+	}
+	if s.Username == "" && s.Password != "" {
+		r.addError("smtp.password requires smtp.username")
+	}
+	if s.Username != "" && s.TLS == mail.TLSNone {
+		r.addWarning("smtp credentials are sent unencrypted with tls: none")
+	}
+}
+
+func validateStorage(cfg *config.Config, r *Result) {
+	names := make(map[string]bool)
+	for i, s := range cfg.Storage {
+		prefix := fmt.Sprintf("storage[%d]", i)
+
+		if s.Name == "" {
+			r.addError("%s: name is required", prefix)
+		} else {
+			if names[s.Name] {
+				r.addError("%s: duplicate storage name '%s'", prefix, s.Name)
+			}
+			names[s.Name] = true
+			prefix = fmt.Sprintf("storage[%s]", s.Name)
+		}
+
+		if s.Region == "" {
+			r.addError("%s: region is required", prefix)
+		}
+		if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+			r.addError("%s: access_key_id and secret_access_key are required", prefix)
+		}
+		if s.TimeoutSec < 0 {
+			r.addError("%s: timeout_sec cannot be negative", prefix)
+		}
+		if _, err := objstore.New(objstore.Config{Endpoint: s.Endpoint, Region: s.Region}, nil); err != nil {
+			r.addError("%s: %v", prefix, err)
+		}
+		if strings.HasPrefix(s.Endpoint, "http://") {
+			r.addWarning("%s: endpoint uses plain http; credentials are signed but data is sent unencrypted", prefix)
+		}
+	}
+}
+
+// storeNames returns the configured storage target names for workflow validation
+func storeNames(cfg *config.Config) map[string]bool {
+	names := make(map[string]bool, len(cfg.Storage))
+	for _, s := range cfg.Storage {
+		names[s.Name] = true
+	}
+	return names
+}
+
+func validateSFTP(cfg *config.Config, r *Result) {
+	names := make(map[string]bool)
+	for i, s := range cfg.SFTP {
+		prefix := fmt.Sprintf("sftp[%d]", i)
+
+		if s.Name == "" {
+			r.addError("%s: name is required", prefix)
+		} else {
+			if names[s.Name] {
+				r.addError("%s: duplicate sftp name '%s'", prefix, s.Name)
+			}
+			names[s.Name] = true
+			prefix = fmt.Sprintf("sftp[%s]", s.Name)
+		}
+
+		if s.Host == "" {
+			r.addError("%s: host is required", prefix)
+		}
+		if s.Port < 0 || s.Port > 65535 {
+			r.addError("%s: port must be between 1 and 65535", prefix)
+		}
+		if s.Username == "" {
+			r.addError("%s: username is required", prefix)
+		}
+		if s.PrivateKey != "" && s.PrivateKeyFile != "" {
+			r.addError("%s: private_key and private_key_file are mutually exclusive", prefix)
+		}
+		if s.TimeoutSec < 0 {
+			r.addError("%s: timeout_sec cannot be negative", prefix)
+		}
+		if s.MaxConnections < 0 {
+			r.addError("%s: max_connections cannot be negative", prefix)
+		}
+		if s.InsecureIgnoreHostKey {
+			if s.HostKey != "" {
+				r.addError("%s: host_key and insecure_ignore_host_key are mutually exclusive", prefix)
+			} else {
+				r.addWarning("%s: insecure_ignore_host_key disables host key verification; use only for testing", prefix)
+			}
+		}
+
+		// Building the client checks credentials, key parsing and the host key
+		key, err := s.LoadPrivateKey()
+		if err != nil {
+			r.addError("%s: private_key_file: %v", prefix, err)
+			continue
+		}
+		if _, err := sftp.New(sftp.Config{
+			Host:                  s.Host,
+			Username:              s.Username,
+			Password:              s.Password,
+			PrivateKey:            key,
+			Passphrase:            s.Passphrase,
+			HostKey:               s.HostKey,
+			InsecureIgnoreHostKey: s.InsecureIgnoreHostKey,
+		}); err != nil {
+			r.addError("%s: %v", prefix, err)
+		}
+	}
+}
+
+func validateMQTT(cfg *config.Config, r *Result) {
+	names := make(map[string]bool)
+	for i, b := range cfg.MQTT {
+		prefix := fmt.Sprintf("mqtt[%d]", i)
+
+		if b.Name == "" {
+			r.addError("%s: name is required", prefix)
+		} else {
+			if names[b.Name] {
+				r.addError("%s: duplicate mqtt name '%s'", prefix, b.Name)
+			}
+			names[b.Name] = true
+			prefix = fmt.Sprintf("mqtt[%s]", b.Name)
+		}
+
+		if b.URL == "" {
+			r.addError("%s: url is required", prefix)
+		} else if _, err := mqtt.New(mqtt.Config{URL: b.URL}); err != nil {
+			r.addError("%s: %v", prefix, err)
+		}
+		if b.Password != "" && b.Username == "" {
+			r.addError("%s: password requires username", prefix)
+		}
+		if b.KeepAliveSec < 0 || b.KeepAliveSec > 65535 {
+			r.addError("%s: keep_alive_sec must be between 0 and 65535", prefix)
+		}
+		if b.TimeoutSec < 0 {
+			r.addError("%s: timeout_sec cannot be negative", prefix)
+		}
+		if b.InsecureSkipVerify {
+			r.addWarning("%s: insecure_skip_verify disables TLS certificate verification; use only for testing", prefix)
+		}
+	}
+}
+
+func validateOutbox(cfg *config.Config, r *Result) {
+	databases := make(map[string]config.DatabaseConfig, len(cfg.Databases))
+	for _, dbCfg := range cfg.Databases {
+		databases[dbCfg.Name] = dbCfg
+	}
+	policies := statementPolicies(cfg)
+	seen := make(map[string]bool)
+	for i, ob := range cfg.Outbox {
+		prefix := fmt.Sprintf("outbox[%d]", i)
+
+		table := ob.Table
+		if table == "" {
+			table = outbox.DefaultTable
+		} else if !crud.TableNameRegex.MatchString(table) {
+			r.addError("%s: invalid table name '%s'", prefix, table)
+		}
+
+		if ob.Database == "" {
+			r.addError("%s: database is required", prefix)
+		} else {
+			if seen[ob.Database] {
+				r.addError("%s: duplicate outbox for database '%s'", prefix, ob.Database)
+			}
+			seen[ob.Database] = true
+			prefix = fmt.Sprintf("outbox[%s]", ob.Database)
+			if dbCfg, ok := databases[ob.Database]; !ok {
+				r.addError("%s: unknown database '%s'", prefix, ob.Database)
+			} else if dbCfg.IsReadOnly() {
+				r.addError("%s: database '%s' is read-only", prefix, ob.Database)
+			} else if dbCfg.TenantRouting != nil {
+				r.addError("%s: database '%s' is tenant-routed, which the relay does not support", prefix, ob.Database)
+			} else if p := policies[ob.Database]; p != nil {
+				for _, stmt := range outbox.Statements(dbCfg.Type, table) {
+					if err := p.Check(stmt); err != nil {
+						r.addError("%s: database '%s': %v", prefix, ob.Database, err)
+						break
+					}
+				}
+			}
+		}
+
+		if ob.PollIntervalSec < 0 {
+			r.addError("%s: poll_interval_sec cannot be negative", prefix)
+		}
+		if ob.BatchSize < 0 {
+			r.addError("%s: batch_size cannot be negative", prefix)
+		}
+		if ob.RetentionHours < 0 {
+			r.addError("%s: retention_hours cannot be negative", prefix)
+		}
+
+		sink := ob.Sink
+		switch sink.Type {
+		case "webhook":
+			if sink.URL == "" {
+				r.addError("%s: sink.url is required for webhook sinks", prefix)
+			} else if !strings.HasPrefix(sink.URL, "http://") && !strings.HasPrefix(sink.URL, "https://") {
+				r.addError("%s: sink.url must start with http:// or https://", prefix)
+			}
+		case "kafka":
+			if producer, err := kafka.New(kafka.Config{Brokers: sink.Brokers}); err != nil {
+				r.addError("%s: sink: %v", prefix, err)
+			} else {
+				_ = producer.Close()
+			}
+			if sink.Password != "" && sink.Username == "" {
+				r.addError("%s: sink.password requires sink.username", prefix)
+			}
+		case "amqp":
+			if sink.URL == "" {
+				r.addError("%s: sink.url is required for amqp sinks", prefix)
+			} else if _, err := amqp.New(amqp.Config{URL: sink.URL}); err != nil {
+				r.addError("%s: sink: %v", prefix, err)
+			}
+		case "":
+			r.addError("%s: sink.type is required", prefix)
+		default:
+			r.addError("%s: invalid sink.type '%s' (must be webhook, kafka, or amqp)", prefix, sink.Type)
+		}
+		if sink.TimeoutSec < 0 {
+			r.addError("%s: sink.timeout_sec cannot be negative", prefix)
+		}
+		if sink.InsecureSkipVerify {
+			r.addWarning("%s: sink.insecure_skip_verify disables TLS certificate verification; use only for testing", prefix)
+		}
+	}
+}
+
+// validateAlerts checks the alerts against the configured workflows, generated CRUD workflows included
+func validateAlerts(cfg *config.Config, r *Result) {
+	if len(cfg.Alerts) == 0 {
+		return
+	}
+	workflows := make(map[string]bool)
+	for _, wf := range cfg.Workflows {
+		workflows[wf.Name] = true
+	}
+	for _, c := range cfg.Crud {
+		for _, op := range config.CrudOperations {
+			if c.HasOperation(op) {
+				workflows[c.Name+"_"+op] = true
+			}
+		}
+	}
+	result := workflow.ValidateAlerts(cfg.Alerts, workflows)
+	for _, err := range result.Errors {
+		r.addError("%s", err)
+	}
+	for _, warning := range result.Warnings {
+		r.addWarning("%s", warning)
+	}
+}
+
+// validateCachePreload checks that each preload entry requests a cached GET route
+func validateCachePreload(cfg *config.Config, r *Result) {
+	c := cfg.Server.Cache
+	if c == nil || len(c.Preload) == 0 {
+		return
+	}
+	if !c.Enabled {
+		r.addError("server.cache.preload requires the cache to be enabled")
+		return
+	}
+	workflows := make(map[string]*workflow.WorkflowConfig, len(cfg.Workflows))
+	for i := range cfg.Workflows {
+		workflows[cfg.Workflows[i].Name] = &cfg.Workflows[i]
+	}
+	for i, p := range c.Preload {
+		prefix := fmt.Sprintf("server.cache.preload[%d]", i)
+		wf := workflows[p.Workflow]
+		if wf == nil {
+			r.addError("%s: unknown workflow '%s'", prefix, p.Workflow)
+			continue
+		}
+		route := wf.PreloadRoute()
+		if route == nil {
+			r.addError("%s: workflow '%s' has no cached GET http trigger", prefix, p.Workflow)
+			continue
+		}
+		for j, params := range p.Params {
+			if _, err := workflow.PreloadTarget(route, params); err != nil {
+				r.addError("%s.params[%d]: %v", prefix, j, err)
+			}
+		}
+		if len(p.Params) == 0 {
+			if _, err := workflow.PreloadTarget(route, nil); err != nil {
+				r.addError("%s: %v (set params)", prefix, err)
+			}
+		}
+	}
+}
+
+func validateHTTPRecording(cfg *config.Config, r *Result) {
+	hr := cfg.HTTPRecording
+	if hr == nil {
+		return
+	}
+	if _, err := redact.New(hr.Redact); err != nil {
+		r.addError("http_recording.redact: %v", err)
+	}
+	if hr.Cassette == "" {
+		r.addError("http_recording.cassette is required")
+		return
+	}
+	switch hr.Mode {
+	case "record":
+		r.addWarning("http_recording: outgoing HTTP calls are recorded to %s; use record mode in development only", hr.Cassette)
+	case "replay":
+		if _, err := cassette.Load(hr.Cassette); err != nil {
+			r.addError("http_recording.cassette: %v", err)
+		}
+	default:
+		r.addError("http_recording.mode must be record or replay, got: %s", hr.Mode)
+	}
+}
+
+func validateChaos(cfg *config.Config, r *Result) {
+	c := cfg.Chaos
+	if !c.Enabled {
+		return
+	}
+	r.addWarning("chaos is enabled: faults are injected into %d databases and the steps of %d workflows; use it in development and testing only", len(c.Databases), len(c.Steps))
+	if env := strings.ToLower(cfg.Observability.Environment); env == "production" || env == "prod" {
+		r.addError("chaos cannot be enabled when observability.environment is %s", cfg.Observability.Environment)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Databases)) {
+		if !slices.ContainsFunc(cfg.Databases, func(d config.DatabaseConfig) bool { return d.Name == name }) {
+			r.addError("chaos.databases.%s: unknown database", name)
+		}
+		if err := c.Databases[name].Check(true); err != nil {
+			r.addError("chaos.databases.%s: %v", name, err)
+		}
+	}
+
+	var names func(steps []workflow.StepConfig, found map[string]bool)
+	names = func(steps []workflow.StepConfig, found map[string]bool) {
+		for _, step := range steps {
+			if step.Name != "" {
+				found[step.Name] = true
+			}
+			names(step.Steps, found)
+		}
+	}
+	for _, wfName := range slices.Sorted(maps.Keys(c.Steps)) {
+		idx := slices.IndexFunc(cfg.Workflows, func(wf workflow.WorkflowConfig) bool { return wf.Name == wfName })
+		if idx < 0 {
+			r.addError("chaos.steps.%s: unknown workflow", wfName)
+			continue
+		}
+		found := make(map[string]bool)
+		names(cfg.Workflows[idx].Steps, found)
+		steps := c.Steps[wfName]
+		for _, name := range slices.Sorted(maps.Keys(steps)) {
+			if !found[name] {
+				r.addError("chaos.steps.%s.%s: workflow '%s' has no step named '%s'", wfName, name, wfName, name)
+			}
+			if err := steps[name].Check(false); err != nil {
+				r.addError("chaos.steps.%s.%s: %v", wfName, name, err)
+			}
+		}
+	}
+}
+
+// outboxDatabases returns the databases with an outbox for workflow validation
+func outboxDatabases(cfg *config.Config) map[string]bool {
+	names := make(map[string]bool, len(cfg.Outbox))
+	for _, ob := range cfg.Outbox {
+		names[ob.Database] = true
 	}
+	return names
 }
 
-func validateDebug(cfg *config.Config, r *Result) {
-	if !cfg.Debug.Enabled {
-		return // Skip validation if debug is disabled
+// snippetNameRegex matches names usable in {{include "name"}}
+var snippetNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// validateSQLSnippets checks the snippet library. Unknown includes and cycles already fail config.Load.
+func validateSQLSnippets(cfg *config.Config, r *Result) {
+	for _, name := range slices.Sorted(maps.Keys(cfg.SQLSnippets)) {
+		sql := cfg.SQLSnippets[name]
+		prefix := fmt.Sprintf("sql_snippets[%s]", name)
+		if !snippetNameRegex.MatchString(name) {
+			r.addError("%s: name may only contain letters, digits, '_', '.' and '-'", prefix)
+		}
+		if strings.TrimSpace(sql) == "" {
+			r.addError("%s: snippet is empty", prefix)
+		}
+		// Includes are expanded at load time; any other template syntax would reach the query
+		if strings.Contains(sqlutil.IncludeRegex.ReplaceAllString(sql, ""), "{{") {
+			r.addError("%s: snippet contains template interpolation ({{...}}) - only {{include \"name\"}} is allowed", prefix)
+		}
 	}
+}
 
-	// Port validation
-	if cfg.Debug.Port < 0 || cfg.Debug.Port > 65535 {
-		r.addError("debug.port must be 0-65535, got: %d", cfg.Debug.Port)
+// validateTemplates checks the shared templates that workflows invoke with {{template "name" .}}
+func validateTemplates(cfg *config.Config, r *Result) {
+	for _, name := range slices.Sorted(maps.Keys(cfg.Templates)) {
+		if strings.TrimSpace(cfg.Templates[name]) == "" {
+			r.addError("templates[%s]: template is empty", name)
+		}
 	}
+	if _, err := workflow.CompilePartials(cfg.Templates); err != nil {
+		r.addError("templates: %v", err)
+	}
+}
 
-	// If debug.host is set but port is shared with main server, it's an error
-	// because the host setting will be silently ignored
-	sharesMainPort := cfg.Debug.Port == 0 || cfg.Debug.Port == cfg.Server.Port
-	if cfg.Debug.Host != "" && sharesMainPort {
-		r.addError("debug.host cannot be set when debug endpoints share the main server port (debug.port is 0 or same as server.port); debug.host only applies when using a separate debug port")
+// mqttBrokerNames returns the configured MQTT broker names for workflow validation
+func mqttBrokerNames(cfg *config.Config) map[string]bool {
+	names := make(map[string]bool, len(cfg.MQTT))
+	for _, b := range cfg.MQTT {
+		names[b.Name] = true
+	}
+	return names
+}
+
+// sftpServerNames returns the configured SFTP server names for workflow validation
+func sftpServerNames(cfg *config.Config) map[string]bool {
+	names := make(map[string]bool, len(cfg.SFTP))
+	for _, s := range cfg.SFTP {
+		names[s.Name] = true
 	}
+	return names
 }
 
 func validateRateLimits(cfg *config.Config, r *Result) {
@@ -295,12 +1379,39 @@ func validateRateLimits(cfg *config.Config, r *Result) {
 			r.addError("%s: pool name cannot start with '_inline:' (reserved for internal use)", prefix)
 		}
 
-		// RequestsPerSecond and Burst are required
-		if pool.RequestsPerSecond <= 0 {
-			r.addError("%s: requests_per_second must be positive", prefix)
-		}
-		if pool.Burst <= 0 {
-			r.addError("%s: burst must be positive", prefix)
+		if pool.IsQuota() {
+			// Quota pools count requests per fixed window instead of refilling tokens
+			if _, err := ratelimit.ParseWindow(pool.Window); err != nil {
+				r.addError("%s: %v", prefix, err)
+			}
+			if pool.Limit <= 0 {
+				r.addError("%s: limit must be positive", prefix)
+			}
+			if pool.RequestsPerSecond != 0 || pool.Burst != 0 || pool.Algorithm != "" {
+				r.addError("%s: requests_per_second, burst and algorithm cannot be combined with window", prefix)
+			}
+			if pool.Store == "" {
+				r.addWarning("%s: quota counters are kept in memory and reset on restart (set store to persist them)", prefix)
+			}
+		} else {
+			if pool.Algorithm != "" && !slices.Contains(ratelimit.ValidAlgorithms, pool.Algorithm) {
+				r.addError("%s: algorithm must be one of %s, got: %q", prefix, strings.Join(ratelimit.ValidAlgorithms, ", "), pool.Algorithm)
+			}
+
+			// RequestsPerSecond and Burst are required (sliding windows have no burst)
+			if pool.RequestsPerSecond <= 0 {
+				r.addError("%s: requests_per_second must be positive", prefix)
+			}
+			if pool.Algorithm == ratelimit.AlgorithmSlidingWindow {
+				if pool.Burst != 0 {
+					r.addWarning("%s: burst is ignored by sliding_window (requests_per_second is the limit per rolling second)", prefix)
+				}
+			} else if pool.Burst <= 0 {
+				r.addError("%s: burst must be positive", prefix)
+			}
+			if pool.Limit != 0 || pool.Store != "" {
+				r.addError("%s: limit and store require window", prefix)
+			}
 		}
 
 		// Key template is required
@@ -321,6 +1432,11 @@ func testDBConnections(cfg *config.Config, r *Result) {
 		dbType := dbCfg.Type
 
 		// Skip if config incomplete (unresolved env vars) - only for sqlserver
+		// Tenant-routed databases connect per tenant at run time
+		if dbCfg.TenantRouting != nil {
+			continue
+		}
+
 		if dbType == "sqlserver" {
 			if strings.HasPrefix(dbCfg.Host, "${") {
 				continue
@@ -339,10 +1455,265 @@ func testDBConnections(cfg *config.Config, r *Result) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		err = driver.Ping(ctx)
 		cancel()
-		_ = driver.Close()
 
 		if err != nil {
 			r.addError("databases[%s]: ping failed: %v", dbCfg.Name, err)
+		} else {
+			validateCrudTables(cfg, driver, r)
+		}
+		_ = driver.Close()
+	}
+}
+
+// crudNameRegex matches crud names, which become workflow names and the default path
+var crudNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateCrud checks the crud entries. Their tables are checked once the databases
+// are reachable (see validateCrudTables).
+func validateCrud(cfg *config.Config, r *Result) {
+	databases := make(map[string]config.DatabaseConfig)
+	for _, dbCfg := range cfg.Databases {
+		databases[dbCfg.Name] = dbCfg
+	}
+	workflowNames := make(map[string]bool)
+	for _, wf := range cfg.Workflows {
+		workflowNames[wf.Name] = true
+	}
+
+	names := make(map[string]bool)
+	for i, c := range cfg.Crud {
+		prefix := fmt.Sprintf("crud[%d]", i)
+
+		if c.Name == "" {
+			r.addError("%s: name is required", prefix)
+		} else {
+			if !crudNameRegex.MatchString(c.Name) {
+				r.addError("%s: name must start with a letter or underscore and contain only letters, digits and underscores", prefix)
+			}
+			if names[c.Name] {
+				r.addError("%s: duplicate crud name '%s'", prefix, c.Name)
+			}
+			names[c.Name] = true
+			prefix = fmt.Sprintf("crud[%s]", c.Name)
+		}
+
+		if c.Database == "" {
+			r.addError("%s: database is required", prefix)
+		} else if dbCfg, ok := databases[c.Database]; !ok {
+			r.addError("%s: unknown database '%s'", prefix, c.Database)
+		} else if dbCfg.TenantRouting != nil {
+			r.addError("%s: database '%s' is tenant-routed; crud needs a fixed connection to read the table", prefix, c.Database)
+		} else if dbCfg.IsReadOnly() && (c.HasOperation("create") || c.HasOperation("update") || c.HasOperation("delete")) {
+			r.addError("%s: database '%s' is read-only; limit operations to [list, get] or use a writable connection", prefix, c.Database)
+		}
+		if c.Table == "" {
+			r.addError("%s: table is required", prefix)
+		} else if !crud.TableNameRegex.MatchString(c.Table) {
+			r.addError("%s: invalid table name '%s'", prefix, c.Table)
+		}
+		if c.Key == "" {
+			r.addError("%s: key is required", prefix)
+		}
+		if c.Path != "" && !strings.HasPrefix(c.Path, "/") {
+			r.addError("%s: path must start with '/'", prefix)
+		}
+		if c.ErrorFormat != "" && !slices.Contains(workflow.ValidErrorFormats, c.ErrorFormat) {
+			r.addError("%s: invalid error_format '%s' (must be one of: %s)", prefix, c.ErrorFormat, strings.Join(workflow.ValidErrorFormats, ", "))
+		}
+
+		seen := make(map[string]bool)
+		for _, op := range c.Operations {
+			if !slices.Contains(config.CrudOperations, op) {
+				r.addError("%s: invalid operation '%s' (must be one of: %s)", prefix, op, strings.Join(config.CrudOperations, ", "))
+			} else if seen[op] {
+				r.addError("%s: duplicate operation '%s'", prefix, op)
+			}
+			seen[op] = true
+		}
+		for _, op := range config.CrudOperations {
+			if c.Name != "" && c.HasOperation(op) && workflowNames[c.Name+"_"+op] {
+				r.addError("%s: generated workflow '%s_%s' conflicts with a configured workflow", prefix, c.Name, op)
+			}
+		}
+	}
+}
+
+// validateStatic checks the static directories and their routes against the workflows' http routes
+func validateStatic(cfg *config.Config, r *Result) {
+	listeners := []string{workflow.MainListener}
+	for _, l := range cfg.Server.Listeners {
+		listeners = append(listeners, l.Name)
+	}
+
+	paths := make(map[string]bool)
+	for i, st := range cfg.Static {
+		prefix := fmt.Sprintf("static[%d]", i)
+
+		switch {
+		case st.Path == "":
+			r.addError("%s: path is required", prefix)
+		case !strings.HasPrefix(st.Path, "/") || !strings.HasSuffix(st.Path, "/"):
+			r.addError("%s: path must start and end with '/', got: %s", prefix, st.Path)
+		case st.Path == "/":
+			r.addError("%s: path cannot be '/' (it would replace the endpoint listing and unmatched routes)", prefix)
+		case strings.HasPrefix(st.Path, "/_/"):
+			r.addError("%s: path cannot start with /_/ (reserved for internal endpoints)", prefix)
+		case strings.ContainsAny(st.Path, "{}"):
+			r.addError("%s: path cannot contain path parameters", prefix)
+		case paths[st.Path]:
+			r.addError("%s: duplicate path '%s'", prefix, st.Path)
+		}
+		paths[st.Path] = true
+
+		if st.Dir == "" {
+			r.addError("%s: dir is required", prefix)
+		} else if info, err := os.Stat(st.Dir); err != nil {
+			r.addError("%s: dir: %v", prefix, err)
+		} else if !info.IsDir() {
+			r.addError("%s: dir '%s' is not a directory", prefix, st.Dir)
+		}
+		if st.MaxAgeSec < 0 {
+			r.addError("%s: max_age_sec cannot be negative, got: %d", prefix, st.MaxAgeSec)
+		}
+		for _, name := range st.Listeners {
+			if !slices.Contains(listeners, name) {
+				r.addError("%s: unknown listener '%s'", prefix, name)
+			}
+		}
+
+		if !strings.HasPrefix(st.Path, "/") || !strings.HasSuffix(st.Path, "/") {
+			continue
+		}
+		for _, wf := range cfg.Workflows {
+			shared := slices.ContainsFunc(listeners, func(name string) bool {
+				return st.ServedOn(name) && wf.ServedOn(name)
+			})
+			if !shared {
+				continue
+			}
+			for _, trig := range wf.Triggers {
+				for _, route := range trig.Expand() {
+					method := route.Method
+					switch route.Type {
+					case workflow.TriggerTypeHTTP:
+					case workflow.TriggerTypeWebSocket:
+						method = "GET"
+					default:
+						continue
+					}
+					if (method != "GET" && method != "HEAD") || !strings.HasPrefix(route.Path, st.Path) {
+						continue
+					}
+					if route.Path == st.Path {
+						r.addError("%s: path '%s' conflicts with GET %s of workflow '%s'", prefix, st.Path, route.Path, wf.Name)
+					} else {
+						r.addWarning("%s: %s %s of workflow '%s' takes precedence over files under '%s'", prefix, method, route.Path, wf.Name, st.Path)
+					}
+				}
+			}
+		}
+	}
+}
+
+// basePathRegex matches route prefixes: one or more literal or {param} segments, without a trailing slash
+var basePathRegex = regexp.MustCompile(`^(/([A-Za-z0-9._~-]+|\{[A-Za-z_][A-Za-z0-9_]*\}))+$`)
+
+// validateBasePath checks a route prefix of server.base_path or a route group
+func validateBasePath(field, path string, r *Result) {
+	switch {
+	case !basePathRegex.MatchString(path):
+		r.addError("%s: '%s' must start with '/', not end with '/', and contain only literal or {param} segments", field, path)
+	case path == "/_" || strings.HasPrefix(path, "/_/"):
+		r.addError("%s: '%s' cannot start with /_ (reserved for internal endpoints)", field, path)
+	}
+}
+
+// validateRouteGroups checks server.base_path, the route groups and the workflows' references to them
+func validateRouteGroups(cfg *config.Config, r *Result) {
+	if cfg.Server.BasePath != "" {
+		validateBasePath("server.base_path", cfg.Server.BasePath, r)
+	}
+
+	groups := make(map[string]*config.RouteGroupConfig)
+	for i := range cfg.RouteGroups {
+		g := &cfg.RouteGroups[i]
+		prefix := fmt.Sprintf("route_groups[%d]", i)
+		switch {
+		case g.Name == "":
+			r.addError("%s: name is required", prefix)
+		case groups[g.Name] != nil:
+			r.addError("%s: duplicate route group name '%s'", prefix, g.Name)
+		default:
+			groups[g.Name] = g
+		}
+
+		if g.BasePath == "" && len(g.Aliases) == 0 {
+			r.addError("%s: base_path or aliases is required", prefix)
+		}
+		if g.BasePath != "" {
+			validateBasePath(prefix+".base_path", g.BasePath, r)
+		}
+		seen := map[string]bool{g.BasePath: true}
+		for j, alias := range g.Aliases {
+			field := fmt.Sprintf("%s.aliases[%d]", prefix, j)
+			if seen[alias] {
+				r.addError("%s: '%s' duplicates the group's base_path or another alias", field, alias)
+				continue
+			}
+			seen[alias] = true
+			validateBasePath(field, alias, r)
+		}
+	}
+
+	for _, wf := range cfg.Workflows {
+		if wf.Group == "" {
+			continue
+		}
+		g, ok := groups[wf.Group]
+		if !ok {
+			r.addError("workflow '%s': unknown route group '%s'", wf.Name, wf.Group)
+			continue
+		}
+		if len(g.Aliases) == 0 {
+			continue
+		}
+		for _, trig := range wf.Triggers {
+			if trig.Type == workflow.TriggerTypeWebSocket {
+				r.addWarning("workflow '%s': websocket trigger %s is not served under the aliases of route group '%s'", wf.Name, trig.Path, g.Name)
+			}
+		}
+	}
+}
+
+// validateCrudTables generates the workflows of the crud entries on driver's database
+// and validates them. Only errors are reported: warnings about generated workflows
+// are not actionable.
+func validateCrudTables(cfg *config.Config, driver db.Driver, r *Result) {
+	var validationCtx *workflow.ValidationContext
+	policies := statementPolicies(cfg)
+	for _, c := range cfg.Crud {
+		if c.Database != driver.Name() {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		workflows, err := crud.Generate(ctx, c, driver)
+		cancel()
+		if err != nil {
+			r.addError("crud[%s]: %v", c.Name, err)
+			continue
+		}
+
+		if validationCtx == nil {
+			validationCtx = &workflow.ValidationContext{
+				Databases:     map[string]bool{driver.Name(): driver.IsReadOnly()},
+				DatabaseTypes: map[string]string{driver.Name(): driver.Type()},
+			}
+		}
+		for _, wf := range workflows {
+			for _, err := range workflow.Validate(&wf, validationCtx).Errors {
+				r.addError("crud[%s]: %s", c.Name, err)
+			}
+			checkStepPolicies(wf.Steps, policies, fmt.Sprintf("crud[%s] %s", c.Name, wf.Name), r)
 		}
 	}
 }
@@ -350,8 +1721,10 @@ func testDBConnections(cfg *config.Config, r *Result) {
 func validateWorkflows(cfg *config.Config, r *Result) {
 	// Build validation context for workflows
 	databases := make(map[string]bool)
+	databaseTypes := make(map[string]string)
 	for _, dbCfg := range cfg.Databases {
 		databases[dbCfg.Name] = dbCfg.IsReadOnly()
+		databaseTypes[dbCfg.Name] = dbCfg.Type
 	}
 	rateLimitPools := make(map[string]bool)
 	for _, rl := range cfg.RateLimits {
@@ -359,47 +1732,234 @@ func validateWorkflows(cfg *config.Config, r *Result) {
 	}
 	validationCtx := &workflow.ValidationContext{
 		Databases:      databases,
+		DatabaseTypes:  databaseTypes,
 		RateLimitPools: rateLimitPools,
+		SMTP:           cfg.SMTP != nil,
+		Stores:         storeNames(cfg),
+		SFTPServers:    sftpServerNames(cfg),
+		MQTTBrokers:    mqttBrokerNames(cfg),
+		Outboxes:       outboxDatabases(cfg),
 	}
 
+	// Partials that fail to parse are reported by validateTemplates
+	partials, partialsErr := workflow.CompilePartials(cfg.Templates)
+
 	// Validate each workflow
+	rpcNames := make(map[string]string) // gRPC method -> workflow name
 	for i, wfCfg := range cfg.Workflows {
 		wfCfgCopy := wfCfg // Copy to avoid closure issues
+		wfCfgCopy.Partials = partials
 		result := workflow.Validate(&wfCfgCopy, validationCtx)
 
+		if cfg.Server.DBWatchStateFile == "" {
+			for j, trig := range wfCfg.Triggers {
+				if trig.Type == workflow.TriggerTypeDBWatch {
+					r.addError("workflows[%d].triggers[%d]: dbwatch triggers require server.dbwatch_state_file", i, j)
+				}
+			}
+		}
+		for j, trig := range wfCfg.Triggers {
+			if trig.Type != workflow.TriggerTypeGRPC {
+				continue
+			}
+			if cfg.Server.GRPC == nil {
+				r.addError("workflows[%d].triggers[%d]: grpc triggers require server.grpc", i, j)
+			}
+			if trig.RPC == "" {
+				continue
+			}
+			if other, ok := rpcNames[trig.RPC]; ok {
+				r.addError("workflows[%d].triggers[%d]: rpc '%s' is already used by workflow '%s'", i, j, trig.RPC, other)
+			} else {
+				rpcNames[trig.RPC] = wfCfg.Name
+			}
+		}
+
 		// Add workflow validation errors to our result
 		for _, err := range result.Errors {
-			r.addError("workflows[%d]: %s", i, err)
+			r.addError("workflows[%d]: %s%s", i, err, sprigHint(err))
 		}
 		for _, warning := range result.Warnings {
 			r.addWarning("workflows[%d]: %s", i, warning)
 		}
+
+		// Compiling parses the templates Validate does not (response bodies, step fields)
+		if len(result.Errors) == 0 && partialsErr == nil {
+			if _, err := workflow.Compile(&wfCfgCopy); err != nil {
+				r.addError("workflows[%d]: %v%s", i, err, sprigHint(err.Error()))
+			}
+		}
+	}
+}
+
+var undefinedFuncRegex = regexp.MustCompile(`function "(\w+)" not defined`)
+
+// sprigHint points out undefined functions that server.sprig_functions would provide
+func sprigHint(msg string) string {
+	m := undefinedFuncRegex.FindStringSubmatch(msg)
+	if m == nil || !tmpl.IsSprigFunc(m[1]) {
+		return ""
+	}
+	return fmt.Sprintf(" (%s is a sprig function; set server.sprig_functions: true to enable it)", m[1])
+}
+
+// validateStatementPolicies checks the workflows' query steps and idempotency
+// tables against the policies of their databases.
+func validateStatementPolicies(cfg *config.Config, r *Result) {
+	policies := statementPolicies(cfg)
+	if len(policies) == 0 {
+		return
+	}
+	for i, wf := range cfg.Workflows {
+		for j, trig := range wf.Triggers {
+			idem := trig.Idempotency
+			if idem == nil || policies[idem.Database] == nil {
+				continue
+			}
+			for _, stmt := range workflow.IdempotencyStatements(cmp.Or(idem.Table, workflow.DefaultIdempotencyTable)) {
+				if err := policies[idem.Database].Check(stmt); err != nil {
+					r.addError("workflows[%d].triggers[%d].idempotency: database '%s': %v", i, j, idem.Database, err)
+					break
+				}
+			}
+		}
+		checkStepPolicies(wf.Steps, policies, fmt.Sprintf("workflows[%d]", i), r)
+	}
+}
+
+// statementPolicies compiles the databases' statement policies. Policies that do
+// not compile are reported by validateDatabase.
+func statementPolicies(cfg *config.Config) map[string]*policy.Policy {
+	policies := make(map[string]*policy.Policy)
+	for _, dbCfg := range cfg.Databases {
+		if p, err := policy.New(dbCfg.Policy); err == nil && p != nil {
+			policies[dbCfg.Name] = p
+		}
+	}
+	return policies
+}
+
+// checkStepPolicies checks query steps, including those nested in blocks, against
+// the policy of their database. SQL containing templates is only known at run
+// time and is checked then.
+func checkStepPolicies(steps []workflow.StepConfig, policies map[string]*policy.Policy, prefix string, r *Result) {
+	for i, step := range steps {
+		stepPrefix := fmt.Sprintf("%s.steps[%d]", prefix, i)
+		if step.Name != "" {
+			stepPrefix = fmt.Sprintf("%s (%s)", stepPrefix, step.Name)
+		}
+		if p := policies[step.Database]; p != nil && step.IsQuery() {
+			var err error
+			if step.Proc != nil {
+				if !strings.Contains(step.Proc.Name, "{{") {
+					err = p.CheckProc(step.Proc.Name)
+				}
+			} else if !strings.Contains(step.SQL, "{{") {
+				err = p.Check(step.SQL)
+			}
+			if err != nil {
+				r.addError("%s: database '%s': %v", stepPrefix, step.Database, err)
+			}
+		}
+		checkStepPolicies(step.Steps, policies, stepPrefix, r)
+	}
+}
+
+// validateMetricSteps checks that metric steps sharing a metric name, in any
+// workflow, agree on its type, label names and buckets: they record into one metric.
+func validateMetricSteps(cfg *config.Config, r *Result) {
+	type metricStep struct {
+		prefix string
+		metric *workflow.MetricConfig
+	}
+	first := make(map[string]metricStep)
+	var walk func(steps []workflow.StepConfig, prefix string)
+	walk = func(steps []workflow.StepConfig, prefix string) {
+		for i, step := range steps {
+			stepPrefix := fmt.Sprintf("%s.steps[%d]", prefix, i)
+			if step.Name != "" {
+				stepPrefix = fmt.Sprintf("%s (%s)", stepPrefix, step.Name)
+			}
+			if m := step.Metric; m != nil && m.Name != "" {
+				prev, seen := first[m.Name]
+				if !seen {
+					first[m.Name] = metricStep{stepPrefix, m}
+				} else if prev.metric.Type != m.Type || !slices.Equal(slices.Sorted(maps.Keys(prev.metric.Labels)), slices.Sorted(maps.Keys(m.Labels))) || !slices.Equal(prev.metric.Buckets, m.Buckets) {
+					r.addError("%s: metric '%s' must have the same type, labels and buckets as in %s", stepPrefix, m.Name, prev.prefix)
+				}
+			}
+			walk(step.Steps, stepPrefix)
+		}
+	}
+	for i, wf := range cfg.Workflows {
+		walk(wf.Steps, fmt.Sprintf("workflows[%d]", i))
 	}
 }
 
-// publicIDUsage tracks where a public ID function is used
-type publicIDUsage struct {
+// funcUsage tracks where a function that needs configuration is used
+type funcUsage struct {
 	workflow string
 	function string
 }
 
+// publicIDColumn is a public_id_columns entry of a workflow or one of its steps
+type publicIDColumn struct {
+	workflow  string
+	column    string
+	namespace string
+}
+
+// findPublicIDColumns returns the public_id_columns entries of all workflows,
+// including nested block steps. Entries that clear a workflow default are skipped.
+func findPublicIDColumns(workflows []workflow.WorkflowConfig) []publicIDColumn {
+	var out []publicIDColumn
+	add := func(wf string, columns map[string]string) {
+		for _, col := range slices.Sorted(maps.Keys(columns)) {
+			if ns := columns[col]; ns != "" {
+				out = append(out, publicIDColumn{workflow: wf, column: col, namespace: ns})
+			}
+		}
+	}
+	var walk func(wf string, steps []workflow.StepConfig)
+	walk = func(wf string, steps []workflow.StepConfig) {
+		for _, s := range steps {
+			add(wf, s.PublicIDColumns)
+			walk(wf, s.Steps)
+		}
+	}
+	for _, wf := range workflows {
+		add(wf.Name, wf.PublicIDColumns)
+		walk(wf.Name, wf.Steps)
+	}
+	return out
+}
+
 // publicIDFuncPattern matches publicID, privateID, or isValidPublicID function calls in templates
 var publicIDFuncPattern = regexp.MustCompile(`\b(publicID|privateID|isValidPublicID)\b`)
 
-// findPublicIDUsages scans all workflow templates for public ID function usage
-func findPublicIDUsages(workflows []workflow.WorkflowConfig) []publicIDUsage {
-	var usages []publicIDUsage
+// cryptoFuncPattern matches encrypt or decrypt function calls inside template
+// actions, since both are also ordinary words in response text
+var cryptoFuncPattern = regexp.MustCompile(`\{\{[^}]*?\b(encrypt|decrypt)\b`)
+
+// translateFuncPattern matches calls of the t function inside template actions,
+// capturing a literal message key when there is one
+var translateFuncPattern = regexp.MustCompile(`\{\{(?:[^}]*?[\s(|-])?(t)\s+(?:"([^"]*)"|[$.(])`)
+
+// findFuncUsages scans all workflow templates for calls matching pattern, whose
+// first group is the function name
+func findFuncUsages(workflows []workflow.WorkflowConfig, pattern *regexp.Regexp) []funcUsage {
+	var usages []funcUsage
 
 	for _, wf := range workflows {
 		templates := collectWorkflowTemplates(&wf)
 		for _, tmplStr := range templates {
-			if matches := publicIDFuncPattern.FindAllString(tmplStr, -1); len(matches) > 0 {
+			if matches := pattern.FindAllStringSubmatch(tmplStr, -1); len(matches) > 0 {
 				// Deduplicate function names for this workflow
 				seen := make(map[string]bool)
-				for _, fn := range matches {
-					if !seen[fn] {
+				for _, m := range matches {
+					if fn := m[1]; !seen[fn] {
 						seen[fn] = true
-						usages = append(usages, publicIDUsage{workflow: wf.Name, function: fn})
+						usages = append(usages, funcUsage{workflow: wf.Name, function: fn})
 					}
 				}
 			}
@@ -415,8 +1975,11 @@ func collectWorkflowTemplates(wf *workflow.WorkflowConfig) []string {
 
 	// Trigger-level templates
 	for _, t := range wf.Triggers {
-		if t.Cache != nil && t.Cache.Key != "" {
-			templates = append(templates, t.Cache.Key)
+		if t.Cache != nil {
+			if t.Cache.Key != "" {
+				templates = append(templates, t.Cache.Key)
+			}
+			templates = append(templates, t.Cache.Tags...)
 		}
 		for _, rl := range t.RateLimit {
 			if rl.Key != "" {
@@ -448,10 +2011,16 @@ func collectStepTemplates(steps []workflow.StepConfig) []string {
 		for _, v := range s.Params {
 			templates = append(templates, v)
 		}
-		if s.Cache != nil && s.Cache.Key != "" {
-			templates = append(templates, s.Cache.Key)
+		if s.Cache != nil {
+			if s.Cache.Key != "" {
+				templates = append(templates, s.Cache.Key)
+			}
+			templates = append(templates, s.Cache.Tags...)
 		}
 
+		// Cache invalidate tags
+		templates = append(templates, s.Tags...)
+
 		// HTTPCall templates
 		if s.URL != "" {
 			templates = append(templates, s.URL)
@@ -468,6 +2037,13 @@ func collectStepTemplates(steps []workflow.StepConfig) []string {
 			templates = append(templates, s.Template)
 		}
 
+		// Metric labels
+		if s.Metric != nil {
+			for _, v := range s.Metric.Labels {
+				templates = append(templates, v)
+			}
+		}
+
 		// Block inputs/outputs
 		for _, v := range s.Inputs {
 			templates = append(templates, v)
@@ -487,25 +2063,51 @@ func collectStepTemplates(steps []workflow.StepConfig) []string {
 
 func validatePublicIDs(cfg *config.Config, r *Result) {
 	// Check if any workflow uses public ID functions
-	usages := findPublicIDUsages(cfg.Workflows)
+	usages := findFuncUsages(cfg.Workflows, publicIDFuncPattern)
+	columns := findPublicIDColumns(cfg.Workflows)
 
 	if cfg.PublicIDs == nil {
 		// No config but workflows use public ID functions - that's an error
 		for _, usage := range usages {
 			r.addError("workflow %q uses %s function but public_ids is not configured", usage.workflow, usage.function)
 		}
+		reported := make(map[string]bool)
+		for _, c := range columns {
+			if !reported[c.workflow] {
+				reported[c.workflow] = true
+				r.addError("workflow %q uses public_id_columns but public_ids is not configured", c.workflow)
+			}
+		}
 		return
 	}
 
 	// Validate secret key length
-	if cfg.PublicIDs.SecretKey == "" {
+	if cfg.PublicIDs.SecretKey == "" && len(cfg.PublicIDs.Keys) == 0 {
 		r.addError("public_ids.secret_key is required when public_ids is configured")
 		return
 	}
-	if len(cfg.PublicIDs.SecretKey) < 32 {
+	if cfg.PublicIDs.SecretKey != "" && len(cfg.PublicIDs.SecretKey) < 32 {
 		r.addError("public_ids.secret_key must be at least 32 characters, got %d", len(cfg.PublicIDs.SecretKey))
 		return // Don't continue validation - NewEncoder will fail anyway
 	}
+	invalidKeys := false
+	for i, k := range cfg.PublicIDs.Keys {
+		if k.ID == "" {
+			r.addError("public_ids.keys[%d]: id is required", i)
+			invalidKeys = true
+		}
+		if len(k.Secret) < 32 {
+			r.addError("public_ids.keys[%d]: secret must be at least 32 characters, got %d", i, len(k.Secret))
+			invalidKeys = true
+		}
+	}
+	if cfg.PublicIDs.SecretKey == "" && cfg.PublicIDs.ActiveKey == "" {
+		r.addError("public_ids.active_key is required when public_ids.secret_key is not set")
+		invalidKeys = true
+	}
+	if invalidKeys {
+		return
+	}
 
 	// Validate namespaces
 	if len(cfg.PublicIDs.Namespaces) == 0 {
@@ -535,9 +2137,94 @@ func validatePublicIDs(cfg *config.Config, r *Result) {
 		}
 	}
 
+	for _, c := range columns {
+		if !seenNames[c.namespace] {
+			r.addError("workflow %q: public_id_columns maps %s to unknown namespace %q", c.workflow, c.column, c.namespace)
+		}
+	}
+
 	// Test encoder creation to catch any other issues
-	_, err := publicid.NewEncoder(cfg.PublicIDs.SecretKey, cfg.PublicIDs.Namespaces)
+	_, err := cfg.PublicIDs.Encoder()
 	if err != nil {
 		r.addError("public_ids: %v", err)
 	}
 }
+
+func validateCryptoKeys(cfg *config.Config, r *Result) {
+	usages := findFuncUsages(cfg.Workflows, cryptoFuncPattern)
+
+	if cfg.CryptoKeys == nil {
+		for _, usage := range usages {
+			r.addError("workflow %q uses %s function but crypto_keys is not configured", usage.workflow, usage.function)
+		}
+		return
+	}
+
+	if len(cfg.CryptoKeys.Keys) == 0 {
+		r.addError("crypto_keys.keys must have at least one key")
+		return
+	}
+	if cfg.CryptoKeys.Active == "" {
+		r.addError("crypto_keys.active is required")
+		return
+	}
+	missing := false
+	for i, k := range cfg.CryptoKeys.Keys {
+		if k.ID == "" {
+			r.addError("crypto_keys.keys[%d]: id is required", i)
+			missing = true
+		}
+		if k.Key == "" {
+			r.addError("crypto_keys.keys[%d]: key is required", i)
+			missing = true
+		}
+	}
+	if missing {
+		return
+	}
+
+	// Test keyring creation to catch bad ids, duplicates, encodings and lengths
+	if _, err := keyring.New(cfg.CryptoKeys.Active, cfg.CryptoKeys.Keys); err != nil {
+		r.addError("crypto_keys: %v", err)
+	}
+}
+
+func validateMessages(cfg *config.Config, r *Result) {
+	usages := findFuncUsages(cfg.Workflows, translateFuncPattern)
+
+	if cfg.Messages == nil {
+		for _, usage := range usages {
+			r.addError("workflow %q uses %s function but messages is not configured", usage.workflow, usage.function)
+		}
+		return
+	}
+
+	if cfg.Messages.Default == "" {
+		r.addError("messages.default is required")
+		return
+	}
+	if len(cfg.Messages.Languages) == 0 {
+		r.addError("messages must have at least one language in languages or dir")
+		return
+	}
+
+	// Test catalog creation to catch bad tags and message values
+	catalog, err := i18n.New(cfg.Messages.Default, cfg.Messages.Languages)
+	if err != nil {
+		r.addError("messages: %v", err)
+		return
+	}
+
+	// Missing keys render as the key itself, so a typo would only show up in responses
+	for _, wf := range cfg.Workflows {
+		seen := make(map[string]bool)
+		for _, tmplStr := range collectWorkflowTemplates(&wf) {
+			for _, m := range translateFuncPattern.FindAllStringSubmatch(tmplStr, -1) {
+				if key := m[2]; key != "" && !seen[key] && !catalog.Has(key) {
+					seen[key] = true
+					r.addWarning("workflow %q: message %q is not defined in any language", wf.Name, key)
+				}
+			}
+		}
+	}
+}
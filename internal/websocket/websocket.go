@@ -0,0 +1,423 @@
+// Package websocket implements the server side of the WebSocket protocol
+// (RFC 6455) for workflow websocket triggers: the opening handshake, text and
+// binary messages (including fragmented ones), ping/pong, and the closing
+// handshake. Extensions such as permessage-deflate are not negotiated.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message and control frame opcodes
+const (
+	OpText   = 1
+	OpBinary = 2
+	OpClose  = 8
+	OpPing   = 9
+	OpPong   = 10
+
+	opContinuation = 0
+)
+
+// Close codes (RFC 6455 section 7.4.1)
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// DefaultMaxMessageSize is the largest message read when none is configured
+const DefaultMaxMessageSize = 64 * 1024
+
+// DefaultWriteTimeout bounds writing one frame
+const DefaultWriteTimeout = 10 * time.Second
+
+// acceptGUID is appended to the client's key to compute Sec-WebSocket-Accept
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxControlPayload is the largest payload a control frame may carry
+const maxControlPayload = 125
+
+// CloseError is returned by ReadMessage when the peer closed the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket closed: %d", e.Code)
+	}
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Reason)
+}
+
+// HandshakeError is returned by Upgrade for requests that are not a valid
+// WebSocket handshake; Upgrade has already answered them with Status
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket handshake: " + e.Message
+}
+
+// IsUpgrade reports whether r asks to switch to the WebSocket protocol
+func IsUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") && headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// CheckOrigin reports whether the request's Origin header is allowed. With no
+// allowed origins, only browsers on the same host may connect; "*" allows any
+// origin. Requests without an Origin header (non-browser clients) are allowed.
+func CheckOrigin(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(allowed) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a server-side WebSocket connection. ReadMessage must be called from
+// one goroutine at a time; writes may come from any goroutine.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	maxMessageSize int64
+	readTimeout    time.Duration
+
+	wmu          sync.Mutex
+	writeTimeout time.Duration
+	closeSent    bool
+}
+
+// Upgrade completes the WebSocket handshake for r and takes over its
+// connection. On a *HandshakeError the response has already been written.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	fail := func(status int, msg string) (*Conn, error) {
+		if status == http.StatusUpgradeRequired {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+		}
+		http.Error(w, msg, status)
+		return nil, &HandshakeError{Status: status, Message: msg}
+	}
+
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "method must be GET")
+	}
+	if !IsUpgrade(r) {
+		return fail(http.StatusBadRequest, "not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, "connection cannot be upgraded")
+	}
+	// The server's read and write deadlines still apply to a hijacked connection
+	_ = netConn.SetDeadline(time.Time{})
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	_ = netConn.SetWriteDeadline(time.Now().Add(DefaultWriteTimeout))
+	if _, err := netConn.Write([]byte(resp)); err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	_ = netConn.SetWriteDeadline(time.Time{})
+
+	return &Conn{
+		conn:           netConn,
+		br:             brw.Reader,
+		maxMessageSize: DefaultMaxMessageSize,
+		writeTimeout:   DefaultWriteTimeout,
+	}, nil
+}
+
+// acceptKey computes Sec-WebSocket-Accept for a client's Sec-WebSocket-Key
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// SetMaxMessageSize limits the size of messages read; larger messages close
+// the connection with CloseMessageTooBig. 0 means DefaultMaxMessageSize.
+func (c *Conn) SetMaxMessageSize(n int64) {
+	if n <= 0 {
+		n = DefaultMaxMessageSize
+	}
+	c.maxMessageSize = n
+}
+
+// SetReadTimeout closes the connection when no frame at all, including pongs,
+// arrives for d. 0 disables the timeout.
+func (c *Conn) SetReadTimeout(d time.Duration) {
+	c.readTimeout = d
+}
+
+// RemoteAddr returns the peer's network address
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage returns the next text or binary message, answering pings and
+// reassembling fragments on the way. When the peer closes the connection it
+// replies to the close frame and returns a *CloseError. Protocol violations
+// close the connection with the matching close code.
+func (c *Conn) ReadMessage() (op int, data []byte, err error) {
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch f.op {
+		case OpPing:
+			if err := c.writeFrame(OpPong, f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			return 0, nil, c.handleClose(f.payload)
+		case OpText, OpBinary:
+			if op != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "new message before the previous one finished")
+			}
+			op = f.op
+		case opContinuation:
+			if op == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "continuation frame without a message")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", f.op))
+		}
+
+		if int64(len(data))+int64(len(f.payload)) > c.maxMessageSize {
+			return 0, nil, c.fail(CloseMessageTooBig, fmt.Sprintf("message exceeds %d bytes", c.maxMessageSize))
+		}
+		data = append(data, f.payload...)
+		if !f.fin {
+			continue
+		}
+		if op == OpText && !utf8.Valid(data) {
+			return 0, nil, c.fail(CloseInvalidPayload, "text message is not valid UTF-8")
+		}
+		return op, data, nil
+	}
+}
+
+type frame struct {
+	fin     bool
+	op      int
+	payload []byte
+}
+
+// readFrame reads and unmasks one frame
+func (c *Conn) readFrame() (frame, error) {
+	if c.readTimeout > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{fin: head[0]&0x80 != 0, op: int(head[0] & 0x0f)}
+	if head[0]&0x70 != 0 {
+		return f, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return f, c.fail(CloseProtocolError, "client frames must be masked")
+	}
+
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		n := binary.BigEndian.Uint64(ext[:])
+		if n > 1<<62 {
+			return f, c.fail(CloseMessageTooBig, "frame too large")
+		}
+		length = int64(n)
+	}
+
+	if f.op >= OpClose {
+		if !f.fin || length > maxControlPayload {
+			return f, c.fail(CloseProtocolError, "invalid control frame")
+		}
+	} else if length > c.maxMessageSize {
+		return f, c.fail(CloseMessageTooBig, fmt.Sprintf("message exceeds %d bytes", c.maxMessageSize))
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return f, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return f, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// handleClose answers a close frame and returns the peer's close status
+func (c *Conn) handleClose(payload []byte) error {
+	ce := &CloseError{Code: CloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		ce.Code = int(binary.BigEndian.Uint16(payload))
+		ce.Reason = string(payload[2:])
+		if !utf8.ValidString(ce.Reason) {
+			return c.fail(CloseProtocolError, "invalid close reason")
+		}
+	}
+	code := ce.Code
+	if code == CloseNoStatus {
+		code = CloseNormal
+	}
+	_ = c.Close(code, "")
+	return ce
+}
+
+// fail closes the connection after a protocol violation
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.Close(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// WriteMessage sends a text or binary message in a single frame
+func (c *Conn) WriteMessage(op int, data []byte) error {
+	if op != OpText && op != OpBinary {
+		return fmt.Errorf("websocket: invalid message opcode %d", op)
+	}
+	return c.writeFrame(op, data)
+}
+
+// Ping sends a ping; the peer's pong keeps the read timeout from expiring
+func (c *Conn) Ping() error {
+	return c.writeFrame(OpPing, nil)
+}
+
+// writeFrame sends one unmasked, final frame
+func (c *Conn) writeFrame(op int, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return net.ErrClosed
+	}
+	return c.writeFrameLocked(op, payload)
+}
+
+func (c *Conn) writeFrameLocked(op int, payload []byte) error {
+	buf := make([]byte, 0, len(payload)+10)
+	buf = append(buf, 0x80|byte(op))
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	buf = append(buf, payload...)
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// Close sends a close frame with code and reason, unless one was already
+// sent, and closes the connection
+func (c *Conn) Close(code int, reason string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	err := c.writeFrameLocked(OpClose, payload)
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// headerHasToken reports whether a comma-separated header contains token
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsClosed reports whether err means the connection is gone
+func IsClosed(err error) bool {
+	var ce *CloseError
+	return errors.As(err, &ce) || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,283 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClient is a minimal client side of the protocol
+type testClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dial(t *testing.T, srv *httptest.Server, header http.Header) (*testClient, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("write handshake: %v", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	return &testClient{conn: conn, br: br}, resp
+}
+
+func (c *testClient) writeFrame(t *testing.T, fin bool, op int, payload []byte) {
+	t.Helper()
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	buf := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, 0x80|byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 0x80|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 0x80|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	buf = append(buf, mask...)
+	for i, b := range payload {
+		buf = append(buf, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		t.Fatalf("write frame: %v", err)
+	}
+}
+
+func (c *testClient) readFrame(t *testing.T) (int, []byte) {
+	t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	if head[1]&0x80 != 0 {
+		t.Fatal("server frames must not be masked")
+	}
+	n := int(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	return int(head[0] & 0x0f), payload
+}
+
+func (c *testClient) expectClose(t *testing.T, code int) {
+	t.Helper()
+	op, payload := c.readFrame(t)
+	if op != OpClose || len(payload) < 2 {
+		t.Fatalf("expected close frame, got op %d %q", op, payload)
+	}
+	if got := int(binary.BigEndian.Uint16(payload)); got != code {
+		t.Errorf("close code = %d, want %d (%s)", got, code, payload[2:])
+	}
+}
+
+// echoServer echoes every message and reports how ReadMessage ended
+func echoServer(t *testing.T, maxSize int64) (*httptest.Server, chan error) {
+	t.Helper()
+	done := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		conn.SetMaxMessageSize(maxSize)
+		for {
+			op, data, err := conn.ReadMessage()
+			if err != nil {
+				done <- err
+				return
+			}
+			if err := conn.WriteMessage(op, data); err != nil {
+				done <- err
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, done
+}
+
+func TestUpgrade_Handshake(t *testing.T) {
+	srv, _ := echoServer(t, 0)
+
+	c, resp := dial(t, srv, nil)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	// Example from RFC 6455 section 1.3
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q", got)
+	}
+	c.writeFrame(t, true, OpText, []byte("hello"))
+	if op, data := c.readFrame(t); op != OpText || string(data) != "hello" {
+		t.Errorf("echo = %d %q", op, data)
+	}
+}
+
+func TestUpgrade_Rejected(t *testing.T) {
+	srv, _ := echoServer(t, 0)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		status int
+	}{
+		{"wrong version", http.Header{"Sec-Websocket-Version": {"8"}}, http.StatusUpgradeRequired},
+		{"bad key", http.Header{"Sec-Websocket-Key": {"short"}}, http.StatusBadRequest},
+		{"not an upgrade", http.Header{"Upgrade": {"h2c"}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, resp := dial(t, srv, tt.header)
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestConn_FragmentsAndControlFrames(t *testing.T) {
+	srv, _ := echoServer(t, 0)
+	c, _ := dial(t, srv, nil)
+
+	c.writeFrame(t, false, OpText, []byte("hel"))
+	c.writeFrame(t, true, OpPing, []byte("p"))
+	c.writeFrame(t, true, opContinuation, []byte("lo"))
+
+	if op, data := c.readFrame(t); op != OpPong || string(data) != "p" {
+		t.Errorf("expected pong, got %d %q", op, data)
+	}
+	if op, data := c.readFrame(t); op != OpText || string(data) != "hello" {
+		t.Errorf("expected reassembled message, got %d %q", op, data)
+	}
+
+	// Needs the 16-bit extended length
+	c.writeFrame(t, true, OpBinary, []byte(strings.Repeat("x", 1000)))
+	if op, data := c.readFrame(t); op != OpBinary || len(data) != 1000 {
+		t.Errorf("binary echo = %d len %d", op, len(data))
+	}
+}
+
+func TestConn_CloseHandshake(t *testing.T) {
+	srv, done := echoServer(t, 0)
+	c, _ := dial(t, srv, nil)
+
+	c.writeFrame(t, true, OpClose, binary.BigEndian.AppendUint16(nil, CloseGoingAway))
+	c.expectClose(t, CloseGoingAway)
+
+	err := <-done
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != CloseGoingAway {
+		t.Errorf("ReadMessage error = %v", err)
+	}
+	if !IsClosed(err) {
+		t.Error("IsClosed should report a close error")
+	}
+}
+
+func TestConn_ProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(t *testing.T, c *testClient)
+		code  int
+	}{
+		{"too big", func(t *testing.T, c *testClient) {
+			c.writeFrame(t, true, OpText, []byte(strings.Repeat("x", 20)))
+		}, CloseMessageTooBig},
+		{"too big across fragments", func(t *testing.T, c *testClient) {
+			c.writeFrame(t, false, OpText, []byte("0123456789"))
+			c.writeFrame(t, true, opContinuation, []byte("0123456789"))
+		}, CloseMessageTooBig},
+		{"invalid utf-8", func(t *testing.T, c *testClient) {
+			c.writeFrame(t, true, OpText, []byte{0xff, 0xfe})
+		}, CloseInvalidPayload},
+		{"unmasked frame", func(t *testing.T, c *testClient) {
+			c.conn.Write([]byte{0x81, 0x01, 'x'})
+		}, CloseProtocolError},
+		{"continuation without message", func(t *testing.T, c *testClient) {
+			c.writeFrame(t, true, opContinuation, []byte("x"))
+		}, CloseProtocolError},
+		{"fragmented control frame", func(t *testing.T, c *testClient) {
+			c.writeFrame(t, false, OpPing, nil)
+		}, CloseProtocolError},
+		{"unknown opcode", func(t *testing.T, c *testClient) {
+			c.writeFrame(t, true, 3, nil)
+		}, CloseProtocolError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, done := echoServer(t, 16)
+			c, _ := dial(t, srv, nil)
+			tt.write(t, c)
+			c.expectClose(t, tt.code)
+			if err := <-done; !IsClosed(err) {
+				t.Errorf("ReadMessage error = %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		origin  string
+		allowed []string
+		want    bool
+	}{
+		{"", nil, true},
+		{"http://example.com", nil, true},
+		{"http://evil.com", nil, false},
+		{"http://evil.com", []string{"*"}, true},
+		{"https://app.example.org", []string{"https://app.example.org/"}, true},
+		{"https://other.example.org", []string{"https://app.example.org"}, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := CheckOrigin(r, tt.allowed); got != tt.want {
+			t.Errorf("CheckOrigin(%q, %v) = %v, want %v", tt.origin, tt.allowed, got, tt.want)
+		}
+	}
+}
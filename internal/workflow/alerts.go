@@ -0,0 +1,324 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+// Alert defaults
+const (
+	DefaultAlertWindowSec     = 300
+	DefaultAlertMinExecutions = 10
+)
+
+// Alert states
+const (
+	AlertStateOK     = "ok"
+	AlertStateFiring = "firing"
+)
+
+// alertEvalInterval is how often alerts are evaluated
+const alertEvalInterval = 10 * time.Second
+
+// maxAlertSamples caps the executions an alert keeps; beyond it the oldest are
+// dropped, so the window of a very busy workflow is shorter than configured.
+const maxAlertSamples = 100_000
+
+// AlertState is the state of an alert at its last evaluation
+type AlertState struct {
+	Name         string    `json:"name"`
+	Workflow     string    `json:"workflow"`
+	State        string    `json:"state"`            // ok or firing
+	Reason       string    `json:"reason,omitempty"` // Why a firing alert fired
+	Since        time.Time `json:"since"`            // When the state last changed
+	EvaluatedAt  time.Time `json:"evaluated_at"`
+	WindowSec    int       `json:"window_sec"`
+	Executions   int       `json:"executions"` // In the window
+	Failures     int       `json:"failures"`
+	FailureRate  float64   `json:"failure_rate"`
+	P95LatencyMs int64     `json:"p95_latency_ms"`
+}
+
+// ValidateAlerts checks alert definitions. workflows holds the names of every
+// configured workflow.
+func ValidateAlerts(alerts []AlertConfig, workflows map[string]bool) *ValidationResult {
+	r := &ValidationResult{Valid: true}
+	seen := make(map[string]bool, len(alerts))
+	for i, a := range alerts {
+		prefix := fmt.Sprintf("alerts[%d]", i)
+		if a.Name == "" {
+			r.addError("%s: name is required", prefix)
+		} else {
+			prefix = fmt.Sprintf("alerts[%s]", a.Name)
+			if seen[a.Name] {
+				r.addError("%s: duplicate alert name", prefix)
+			}
+			seen[a.Name] = true
+		}
+
+		if a.Workflow == "" {
+			r.addError("%s: workflow is required", prefix)
+		} else if !workflows[a.Workflow] {
+			r.addError("%s: unknown workflow '%s'", prefix, a.Workflow)
+		}
+		if a.WindowSec < 0 {
+			r.addError("%s: window_sec cannot be negative", prefix)
+		}
+		if a.MinExecutions < 0 {
+			r.addError("%s: min_executions cannot be negative", prefix)
+		}
+		if a.FailureRate < 0 || a.FailureRate > 1 {
+			r.addError("%s: failure_rate must be between 0 and 1", prefix)
+		}
+		if a.P95LatencyMs < 0 {
+			r.addError("%s: p95_latency_ms cannot be negative", prefix)
+		}
+		if a.FailureRate == 0 && a.P95LatencyMs == 0 {
+			r.addError("%s: failure_rate or p95_latency_ms is required", prefix)
+		}
+
+		if n := a.Notify; n == nil {
+			r.addWarning("%s: no notify step; the alert is only visible at /_/alerts", prefix)
+		} else {
+			if n.Type != "" && n.Type != StepTypeNotify {
+				r.addError("%s.notify: type must be notify", prefix)
+			}
+			if n.When != "" {
+				r.addError("%s.notify: when is not supported for alert notifications", prefix)
+			}
+			if n.Condition != "" {
+				r.addError("%s.notify: condition is not supported for alert notifications", prefix)
+			}
+			validateNotifyStep(n, prefix+".notify", r)
+		}
+	}
+	return r
+}
+
+// AlertMonitor evaluates the alerts of an executor's workflows. Executions are
+// recorded as they finish; Run evaluates the alerts periodically and sends
+// their notifications.
+type AlertMonitor struct {
+	exec       *Executor
+	alerts     []*alert
+	byWorkflow map[string][]*alert
+}
+
+type alert struct {
+	cfg    AlertConfig
+	window time.Duration
+	notify *CompiledStep // nil without a notify step
+
+	mu      sync.Mutex
+	samples []alertSample // Oldest first
+	state   AlertState
+}
+
+// alertSample is one finished execution
+type alertSample struct {
+	at         time.Time
+	durationMs int64
+	failed     bool
+}
+
+// NewAlertMonitor compiles alerts and attaches them to the executor, which
+// records the executions of the watched workflows from then on.
+func NewAlertMonitor(exec *Executor, alerts []AlertConfig) (*AlertMonitor, error) {
+	m := &AlertMonitor{exec: exec, byWorkflow: make(map[string][]*alert)}
+	now := time.Now()
+	for _, cfg := range alerts {
+		if cfg.WindowSec == 0 {
+			cfg.WindowSec = DefaultAlertWindowSec
+		}
+		if cfg.MinExecutions == 0 {
+			cfg.MinExecutions = DefaultAlertMinExecutions
+		}
+		a := &alert{
+			cfg:    cfg,
+			window: time.Duration(cfg.WindowSec) * time.Second,
+			state: AlertState{
+				Name:      cfg.Name,
+				Workflow:  cfg.Workflow,
+				State:     AlertStateOK,
+				Since:     now,
+				WindowSec: cfg.WindowSec,
+			},
+		}
+		if cfg.Notify != nil {
+			notify := *cfg.Notify
+			notify.Type = StepTypeNotify
+			if notify.Name == "" {
+				notify.Name = cfg.Name
+			}
+			cs, err := compileStep(&notify, 0, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("alert %s: notify: %w", cfg.Name, err)
+			}
+			a.notify = cs
+		}
+		m.alerts = append(m.alerts, a)
+		m.byWorkflow[cfg.Workflow] = append(m.byWorkflow[cfg.Workflow], a)
+	}
+	exec.alerts = m
+	return m, nil
+}
+
+// Run evaluates the alerts every alertEvalInterval until ctx is cancelled
+func (m *AlertMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(alertEvalInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.evaluate(ctx, now)
+		}
+	}
+}
+
+// States returns the state of each alert at its last evaluation, in config order
+func (m *AlertMonitor) States() []AlertState {
+	states := make([]AlertState, len(m.alerts))
+	for i, a := range m.alerts {
+		a.mu.Lock()
+		states[i] = a.state
+		a.mu.Unlock()
+	}
+	return states
+}
+
+// observe records a finished execution of a workflow
+func (m *AlertMonitor) observe(workflow string, result *ExecuteResult) {
+	alerts := m.byWorkflow[workflow]
+	if len(alerts) == 0 {
+		return
+	}
+	sample := alertSample{at: time.Now(), durationMs: result.DurationMs, failed: !result.Success}
+	for _, a := range alerts {
+		a.mu.Lock()
+		if len(a.samples) >= maxAlertSamples {
+			a.samples = slices.Delete(a.samples, 0, len(a.samples)-maxAlertSamples+1)
+		}
+		a.samples = append(a.samples, sample)
+		a.mu.Unlock()
+	}
+}
+
+// evaluate updates every alert as of now and notifies the ones that changed state
+func (m *AlertMonitor) evaluate(ctx context.Context, now time.Time) {
+	for _, a := range m.alerts {
+		state, changed := a.update(now)
+		if !changed {
+			continue
+		}
+		fields := map[string]any{
+			"alert":          state.Name,
+			"workflow":       state.Workflow,
+			"executions":     state.Executions,
+			"failure_rate":   state.FailureRate,
+			"p95_latency_ms": state.P95LatencyMs,
+		}
+		if state.State == AlertStateFiring {
+			fields["reason"] = state.Reason
+			m.exec.logger.Warn("alert_firing", fields)
+		} else {
+			m.exec.logger.Info("alert_resolved", fields)
+		}
+		if a.notify != nil {
+			m.notify(ctx, a, state)
+		}
+	}
+}
+
+// update drops the samples outside the window and recomputes the state,
+// reporting whether it changed between ok and firing
+func (a *alert) update(now time.Time) (AlertState, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := now.Add(-a.window)
+	keep := 0
+	for keep < len(a.samples) && !a.samples[keep].at.After(cutoff) {
+		keep++
+	}
+	a.samples = slices.Delete(a.samples, 0, keep)
+
+	s := &a.state
+	s.EvaluatedAt = now
+	s.Executions = len(a.samples)
+	s.Failures = 0
+	durations := make([]int64, len(a.samples))
+	for i, sample := range a.samples {
+		if sample.failed {
+			s.Failures++
+		}
+		durations[i] = sample.durationMs
+	}
+	s.FailureRate, s.P95LatencyMs = 0, 0
+	if s.Executions > 0 {
+		s.FailureRate = float64(s.Failures) / float64(s.Executions)
+		slices.Sort(durations)
+		s.P95LatencyMs = durations[int(math.Ceil(0.95*float64(len(durations))))-1]
+	}
+
+	var reasons []string
+	if s.Executions >= a.cfg.MinExecutions {
+		if a.cfg.FailureRate > 0 && s.FailureRate > a.cfg.FailureRate {
+			reasons = append(reasons, fmt.Sprintf("failure rate %.3f above %.3f", s.FailureRate, a.cfg.FailureRate))
+		}
+		if a.cfg.P95LatencyMs > 0 && s.P95LatencyMs > a.cfg.P95LatencyMs {
+			reasons = append(reasons, fmt.Sprintf("p95 latency %dms above %dms", s.P95LatencyMs, a.cfg.P95LatencyMs))
+		}
+	}
+	s.Reason = strings.Join(reasons, "; ")
+
+	next := AlertStateOK
+	if len(reasons) > 0 {
+		next = AlertStateFiring
+	}
+	changed := next != s.State
+	if changed {
+		s.State = next
+		s.Since = now
+	}
+	return *s, changed
+}
+
+// notify sends an alert's notify step. Its templates see the alert state as
+// .alert and the workflow name as .workflow.name.
+func (m *AlertMonitor) notify(ctx context.Context, a *alert, state AlertState) {
+	data := map[string]any{
+		"alert": map[string]any{
+			"name":           state.Name,
+			"workflow":       state.Workflow,
+			"state":          state.State,
+			"reason":         state.Reason,
+			"since":          state.Since.UTC().Format(time.RFC3339),
+			"window_sec":     state.WindowSec,
+			"executions":     state.Executions,
+			"failures":       state.Failures,
+			"failure_rate":   state.FailureRate,
+			"p95_latency_ms": state.P95LatencyMs,
+		},
+		"workflow": map[string]any{"name": state.Workflow},
+	}
+
+	notifyCtx, cancel := context.WithTimeout(ctx, failureNotifyTimeout)
+	defer cancel()
+	result, _ := m.exec.executeNotifyStep(notifyCtx, a.notify, step.ExecutionData{TemplateData: data})
+	if !result.Success {
+		m.exec.logger.Error("alert_notify_failed", map[string]any{
+			"alert": state.Name,
+			"state": state.State,
+			"error": fmt.Sprint(result.Error),
+		})
+	}
+}
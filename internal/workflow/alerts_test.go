@@ -0,0 +1,217 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+func TestAlertMonitor(t *testing.T) {
+	var sent []map[string]any
+	client := &mockHTTPClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			var payload map[string]any
+			body, _ := io.ReadAll(req.Body)
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Errorf("payload: %v", err)
+			}
+			sent = append(sent, payload)
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("ok")), Header: make(http.Header)}, nil
+		},
+	}
+	fail := false
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			if fail {
+				return nil, errors.New("deadlock")
+			}
+			return &step.QueryResult{}, nil
+		},
+	}
+	exec := NewExecutor(db, client, nil, &testLogger{})
+	monitor, err := NewAlertMonitor(exec, []AlertConfig{{
+		Name:          "orders_failing",
+		Workflow:      "orders",
+		WindowSec:     60,
+		MinExecutions: 4,
+		FailureRate:   0.5,
+		Notify: &StepConfig{
+			Provider: "webhook",
+			URL:      "https://hooks.example.com",
+			Text:     "{{.alert.name}} is {{.alert.state}}: {{.alert.reason}}",
+		},
+	}})
+	if err != nil {
+		t.Fatalf("NewAlertMonitor: %v", err)
+	}
+	orders := mustCompile(t, &WorkflowConfig{
+		Name:  "orders",
+		Steps: []StepConfig{{Name: "load", Type: "query", Database: "db", SQL: "SELECT 1"}},
+	})
+	other := mustCompile(t, &WorkflowConfig{
+		Name:  "other",
+		Steps: []StepConfig{{Name: "load", Type: "query", Database: "db", SQL: "SELECT 1"}},
+	})
+	run := func(wf *CompiledWorkflow, n int) {
+		for range n {
+			exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+		}
+	}
+	state := func() AlertState {
+		return monitor.States()[0]
+	}
+
+	// Too few executions for the threshold to apply
+	fail = true
+	run(orders, 3)
+	run(other, 5)
+	monitor.evaluate(context.Background(), time.Now())
+	if s := state(); s.State != AlertStateOK || s.Executions != 3 || s.Failures != 3 {
+		t.Fatalf("state = %+v, want ok with 3 failed executions", s)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("sent = %v, want nothing below min_executions", sent)
+	}
+
+	// 3 of 4 failed
+	fail = false
+	run(orders, 1)
+	monitor.evaluate(context.Background(), time.Now())
+	s := state()
+	if s.State != AlertStateFiring || s.FailureRate != 0.75 || s.Reason != "failure rate 0.750 above 0.500" {
+		t.Fatalf("state = %+v, want firing at 0.75", s)
+	}
+	if len(sent) != 1 || sent[0]["text"] != "orders_failing is firing: failure rate 0.750 above 0.500" {
+		t.Fatalf("sent = %v, want one firing notification", sent)
+	}
+	if alert, _ := sent[0]["alert"].(map[string]any); alert["workflow"] != "orders" || alert["executions"] != float64(4) {
+		t.Errorf("payload alert = %v", sent[0]["alert"])
+	}
+
+	// Still firing: no repeat notification
+	monitor.evaluate(context.Background(), time.Now())
+	if len(sent) != 1 {
+		t.Fatalf("sent %d notifications, want no repeat while firing", len(sent))
+	}
+
+	// The failures leave the window
+	monitor.evaluate(context.Background(), time.Now().Add(61*time.Second))
+	if s := state(); s.State != AlertStateOK || s.Executions != 0 {
+		t.Fatalf("state = %+v, want ok with an empty window", s)
+	}
+	if len(sent) != 2 || sent[1]["text"] != "orders_failing is ok: " {
+		t.Fatalf("sent = %v, want a resolved notification", sent)
+	}
+}
+
+func TestAlertUpdate_P95Latency(t *testing.T) {
+	now := time.Now()
+	a := &alert{
+		cfg:    AlertConfig{Name: "slow", Workflow: "orders", MinExecutions: 1, P95LatencyMs: 500},
+		window: time.Minute,
+		state:  AlertState{State: AlertStateOK},
+	}
+	for i := 1; i <= 20; i++ {
+		a.samples = append(a.samples, alertSample{at: now, durationMs: int64(i * 100)})
+	}
+
+	s, changed := a.update(now)
+	if !changed || s.State != AlertStateFiring || s.P95LatencyMs != 1900 {
+		t.Fatalf("update() = %+v, %v; want firing with p95 1900", s, changed)
+	}
+	if s.Reason != "p95 latency 1900ms above 500ms" {
+		t.Errorf("Reason = %q", s.Reason)
+	}
+}
+
+func TestValidateAlerts(t *testing.T) {
+	workflows := map[string]bool{"orders": true}
+	notify := &StepConfig{Provider: "slack", URL: "https://hooks.slack.com/x", Text: "{{.alert.reason}}"}
+	tests := []struct {
+		name    string
+		alerts  []AlertConfig
+		wantErr string
+		warning string
+	}{
+		{
+			name:   "valid",
+			alerts: []AlertConfig{{Name: "a", Workflow: "orders", FailureRate: 0.1, Notify: notify}},
+		},
+		{
+			name:    "without notify",
+			alerts:  []AlertConfig{{Name: "a", Workflow: "orders", P95LatencyMs: 100}},
+			warning: "no notify step",
+		},
+		{
+			name:    "missing name",
+			alerts:  []AlertConfig{{Workflow: "orders", FailureRate: 0.1}},
+			wantErr: "alerts[0]: name is required",
+		},
+		{
+			name: "duplicate name",
+			alerts: []AlertConfig{
+				{Name: "a", Workflow: "orders", FailureRate: 0.1},
+				{Name: "a", Workflow: "orders", FailureRate: 0.2},
+			},
+			wantErr: "alerts[a]: duplicate alert name",
+		},
+		{
+			name:    "unknown workflow",
+			alerts:  []AlertConfig{{Name: "a", Workflow: "missing", FailureRate: 0.1}},
+			wantErr: "unknown workflow 'missing'",
+		},
+		{
+			name:    "no threshold",
+			alerts:  []AlertConfig{{Name: "a", Workflow: "orders"}},
+			wantErr: "failure_rate or p95_latency_ms is required",
+		},
+		{
+			name:    "failure rate above 1",
+			alerts:  []AlertConfig{{Name: "a", Workflow: "orders", FailureRate: 5}},
+			wantErr: "failure_rate must be between 0 and 1",
+		},
+		{
+			name:    "negative window",
+			alerts:  []AlertConfig{{Name: "a", Workflow: "orders", FailureRate: 0.1, WindowSec: -1}},
+			wantErr: "window_sec cannot be negative",
+		},
+		{
+			name:    "notify without url",
+			alerts:  []AlertConfig{{Name: "a", Workflow: "orders", FailureRate: 0.1, Notify: &StepConfig{Provider: "slack", Text: "x"}}},
+			wantErr: "alerts[a].notify: url is required for notify step",
+		},
+		{
+			name:    "notify with when",
+			alerts:  []AlertConfig{{Name: "a", Workflow: "orders", FailureRate: 0.1, Notify: &StepConfig{Provider: "slack", URL: "https://x", Text: "x", When: "failure"}}},
+			wantErr: "when is not supported for alert notifications",
+		},
+		{
+			name:    "notify of another type",
+			alerts:  []AlertConfig{{Name: "a", Workflow: "orders", FailureRate: 0.1, Notify: &StepConfig{Type: "httpcall", Provider: "slack", URL: "https://x", Text: "x"}}},
+			wantErr: "alerts[a].notify: type must be notify",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ValidateAlerts(tt.alerts, workflows)
+			if tt.wantErr == "" {
+				if !r.Valid {
+					t.Fatalf("errors = %v", r.Errors)
+				}
+			} else if !containsError(r.Errors, tt.wantErr) {
+				t.Fatalf("errors = %v, want %q", r.Errors, tt.wantErr)
+			}
+			if tt.warning != "" && !containsWarning(r.Warnings, tt.warning) {
+				t.Errorf("warnings = %v, want %q", r.Warnings, tt.warning)
+			}
+		})
+	}
+}
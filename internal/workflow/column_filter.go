@@ -0,0 +1,48 @@
+package workflow
+
+import (
+	"path"
+	"strings"
+)
+
+// filterColumns removes the columns of rows that include (when set) does not
+// match or exclude matches, in place. Patterns are case-insensitive globs.
+func filterColumns(rows []map[string]any, include, exclude []string) {
+	for _, row := range rows {
+		for col := range row {
+			if !columnKept(col, include, exclude) {
+				delete(row, col)
+			}
+		}
+	}
+}
+
+// columnKept reports whether a column survives include_columns and exclude_columns
+func columnKept(col string, include, exclude []string) bool {
+	if len(include) > 0 && !matchesColumn(include, col) {
+		return false
+	}
+	return !matchesColumn(exclude, col)
+}
+
+// matchesColumn reports whether any pattern matches the column name
+func matchesColumn(patterns []string, col string) bool {
+	col = strings.ToLower(col)
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), col); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveColumnFilters returns a query step's column filters: its own
+// include_columns, else the workflow's, and the exclude_columns of both
+func resolveColumnFilters(wf *WorkflowConfig, cfg *StepConfig) (include, exclude []string) {
+	include = cfg.IncludeColumns
+	if len(include) == 0 {
+		include = wf.IncludeColumns
+	}
+	exclude = append(append([]string(nil), wf.ExcludeColumns...), cfg.ExcludeColumns...)
+	return include, exclude
+}
@@ -2,17 +2,24 @@ package workflow
 
 import (
 	"fmt"
+	"io"
+	"maps"
 	"math"
+	"net/http"
+	"strings"
 	"sync/atomic"
 	"text/template"
 
+	"github.com/dop251/goja"
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/ast"
 	"github.com/expr-lang/expr/parser"
 	"github.com/expr-lang/expr/vm"
 
+	"sql-proxy/internal/jsonschema"
 	"sql-proxy/internal/sqlutil"
 	"sql-proxy/internal/tmpl"
+	"sql-proxy/internal/workflow/step"
 )
 
 // CompiledCondition holds a condition alias with its source expression.
@@ -28,13 +35,21 @@ type CompiledWorkflow struct {
 	Conditions map[string]*CompiledCondition // Named condition aliases
 	Triggers   []*CompiledTrigger
 	Steps      []*CompiledStep
+	Streams    bool // Has response_sse steps, so responses may be sent as an event stream
 }
 
 // CompiledTrigger holds a trigger with pre-compiled templates.
 type CompiledTrigger struct {
 	Config     *TriggerConfig
-	CacheKey   *template.Template // For HTTP triggers with caching
+	CacheKey   *template.Template   // For HTTP triggers with caching
+	CacheTags  []*template.Template // Tags attached to cached responses
 	RateLimits []*CompiledRateLimit
+	ParamRules []*CompiledParamRules // Parameters with validation rules
+	BodySchema *jsonschema.Schema    // Validates the JSON request body before parameter extraction
+	PathParams []*CompiledPathParam  // Parameters the rewrite rules extract from the request path
+
+	ResponseHeaders map[string]*template.Template // Header name -> template from response_headers
+	Sunset          string                        // Sunset header of deprecated triggers (HTTP date)
 }
 
 // CompiledRateLimit holds a rate limit with pre-compiled key template.
@@ -49,30 +64,114 @@ type CompiledStep struct {
 	Condition *vm.Program // Compiled condition expression
 	Index     int         // Step index in workflow
 
-	// Cache key template (for query and httpcall steps)
-	CacheKeyTmpl *template.Template
+	// Cache key and tag templates (for query and httpcall steps)
+	CacheKeyTmpl  *template.Template
+	CacheTagTmpls []*template.Template
 
 	// Computed params templates (available for all step types)
 	ParamTmpls map[string]*template.Template
 
+	// Fallback data template (for steps with on_error: fallback)
+	FallbackTmpl *template.Template
+
 	// Query step templates and classification
 	SQLTmpl      *template.Template
 	IsWrite      bool // Precomputed: SQL is INSERT/UPDATE/DELETE/etc.
 	HasReturning bool // Precomputed: SQL has OUTPUT INSERTED/DELETED or RETURNING
 
-	// HTTPCall step templates
+	// Result limit for query steps: the step's own max_rows, max_response_bytes and
+	// on_limit, else the workflow's. Nil when the step has no limit.
+	ResultLimit    *step.ResultLimit
+	OnLimit        string
+	PageOffsetTmpl *template.Template
+
+	// Public ID columns of query steps: the workflow's public_id_columns overlaid
+	// with the step's, without entries the step clears. Nil when there are none.
+	PublicIDColumns map[string]string
+
+	// Masked columns of query steps, resolved like PublicIDColumns, and the
+	// step's or else the workflow's unmask_when. MaskColumns is nil when there are none.
+	MaskColumns map[string]string
+	UnmaskWhen  *vm.Program
+
+	// Column filters of query steps: the step's include_columns or else the
+	// workflow's, and the exclude_columns of both. Nil when unset.
+	IncludeColumns []string
+	ExcludeColumns []string
+
+	// Stored procedure parameter values, indexed like Proc.Params.
+	// Parameters with neither an expression nor a template are looked up by name like @params.
+	ProcExprs []*vm.Program
+	ProcTmpls []*template.Template
+
+	// Outbox event templates of query steps, indexed like Config.Outbox
+	OutboxTmpls []CompiledOutboxEvent
+
+	// HTTPCall step templates (redirect steps keep their location in URLTmpl)
 	URLTmpl     *template.Template
 	BodyTmpl    *template.Template
 	HeaderTmpls map[string]*template.Template
 
-	// Response step templates
-	TemplateTmpl *template.Template
+	// Response step templates and the schema the rendered body must match
+	TemplateTmpl   responseTemplate
+	ResponseSchema *jsonschema.Schema
+
+	// Cache invalidate step templates
+	TagTmpls []*template.Template
+
+	// Set step values: expressions, and templates for values containing {{
+	SetExprs map[string]*vm.Program
+	SetTmpls map[string]*template.Template
+
+	// Script step program, evaluating to the function that runs the script
+	ScriptProg *goja.Program
+
+	// Email step templates
+	EmailTmpls *CompiledEmail
+
+	// Notify step templates (also uses URLTmpl and HeaderTmpls)
+	TitleTmpl  *template.Template
+	TextTmpl   *template.Template
+	BlocksTmpl *template.Template
+
+	// Storage step templates and source (content comes from BodyTmpl or SourceProg); bulk_insert and response_sse also use SourceProg
+	BucketTmpl *template.Template
+	KeyTmpl    *template.Template
+	SourceProg *vm.Program
+
+	// SFTP step template (also uses BodyTmpl and SourceProg)
+	RemotePathTmpl *template.Template
+
+	// MQTT step template (also uses BodyTmpl and SourceProg)
+	TopicTmpl *template.Template
+
+	// Metric step value and label templates
+	MetricValueProg  *vm.Program
+	MetricLabelTmpls map[string]*template.Template
 
 	// Block step (nested steps)
 	Iterate    *CompiledIterate
 	BlockSteps []*CompiledStep
 }
 
+// CompiledEmail holds the templates of an email step.
+type CompiledEmail struct {
+	From    *template.Template
+	To      []*template.Template
+	Cc      []*template.Template
+	Bcc     []*template.Template
+	Subject *template.Template
+	Text    *template.Template
+	HTML    *template.Template
+}
+
+// CompiledOutboxEvent holds the templates of a query step's outbox event.
+type CompiledOutboxEvent struct {
+	Topic   *template.Template
+	Key     *template.Template // Nil without a key
+	Payload *template.Template // Nil renders {}
+}
+
 // CompiledIterate holds compiled iteration config.
 type CompiledIterate struct {
 	Config   *IterateConfig
@@ -134,6 +233,65 @@ func getTemplateEncoder() PublicIDEncoder {
 	return v.(encoderWrapper).enc
 }
 
+// templateKeyring holds the keys for encrypt/decrypt functions, like templateEncoder.
+var templateKeyring atomic.Value
+
+// Cipher provides encryption with the configured crypto keys for templates.
+type Cipher interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+}
+
+// cipherWrapper wraps a cipher to allow storing nil via atomic.Value.
+type cipherWrapper struct {
+	c Cipher
+}
+
+// SetTemplateKeyring sets the keys for encrypt/decrypt template functions.
+// Thread-safe via atomic.Value. Pass nil to clear the keys.
+func SetTemplateKeyring(c Cipher) {
+	templateKeyring.Store(cipherWrapper{c: c})
+}
+
+// getTemplateKeyring returns the current keys, or nil if not set.
+func getTemplateKeyring() Cipher {
+	v := templateKeyring.Load()
+	if v == nil {
+		return nil
+	}
+	return v.(cipherWrapper).c
+}
+
+// templateMessages holds the message catalog for the t function, like templateEncoder.
+var templateMessages atomic.Value
+
+// Messages provides localized strings for templates.
+type Messages interface {
+	Translate(key, lang string, args ...any) string
+	Negotiate(acceptLanguage string) string
+	Default() string
+}
+
+// messagesWrapper wraps a catalog to allow storing nil via atomic.Value.
+type messagesWrapper struct {
+	m Messages
+}
+
+// SetTemplateMessages sets the message catalog for the t template function and
+// the trigger.lang field. Thread-safe via atomic.Value. Pass nil to clear it.
+func SetTemplateMessages(m Messages) {
+	templateMessages.Store(messagesWrapper{m: m})
+}
+
+// getTemplateMessages returns the current catalog, or nil if not set.
+func getTemplateMessages() Messages {
+	v := templateMessages.Load()
+	if v == nil {
+		return nil
+	}
+	return v.(messagesWrapper).m
+}
+
 // toInt64 converts various numeric types to int64.
 func toInt64(v any) (int64, error) {
 	switch n := v.(type) {
@@ -162,7 +320,9 @@ func toInt64(v any) (int64, error) {
 
 // TemplateFuncs provides template functions for workflow templates.
 // Built from tmpl.BaseFuncMap() with workflow-specific overrides for map[string]any handling.
-// Note: publicID/privateID require an encoder to be set via SetTemplateEncoder.
+// Note: publicID/privateID require an encoder to be set via SetTemplateEncoder,
+// encrypt/decrypt require keys set via SetTemplateKeyring, and t requires a
+// catalog set via SetTemplateMessages.
 var TemplateFuncs template.FuncMap
 
 func init() {
@@ -214,6 +374,34 @@ func init() {
 		}
 		return enc.Decode(namespace, publicID)
 	}
+
+	// Add crypto key functions (require SetTemplateKeyring to be called)
+	TemplateFuncs["encrypt"] = func(value any) (string, error) {
+		c := getTemplateKeyring()
+		if c == nil {
+			return "", fmt.Errorf("encrypt: crypto_keys not configured")
+		}
+		return c.Encrypt(tmpl.CipherText(value))
+	}
+	TemplateFuncs["decrypt"] = func(value any) (string, error) {
+		c := getTemplateKeyring()
+		if c == nil {
+			return "", fmt.Errorf("decrypt: crypto_keys not configured")
+		}
+		return c.Decrypt(tmpl.CipherText(value))
+	}
+
+	// Add message catalog lookup (requires SetTemplateMessages to be called)
+	TemplateFuncs["t"] = func(key string, lang any, args ...any) (string, error) {
+		m := getTemplateMessages()
+		if m == nil {
+			return "", fmt.Errorf("t: messages not configured")
+		}
+		return m.Translate(key, tmpl.LanguageArg(lang), args...), nil
+	}
+
+	// Plugins may register functions after this package initializes
+	tmpl.OnRegisterFunc(func(name string, fn any) { TemplateFuncs[name] = fn })
 }
 
 // exprFuncs contains custom functions for expr evaluation in conditions.
@@ -240,6 +428,8 @@ func init() {
 		_, err := enc.Decode(namespace, pidStr)
 		return err == nil
 	}
+
+	tmpl.OnRegisterExprFunc(func(name string, fn any) { exprFuncs[name] = fn })
 }
 
 // Compile compiles a workflow configuration into an executable form.
@@ -268,18 +458,20 @@ func Compile(cfg *WorkflowConfig) (*CompiledWorkflow, error) {
 		}
 	}
 
-	// Compile triggers
+	// Compile triggers (HTTP triggers with methods/paths lists expand to one trigger per route)
 	for i, trigCfg := range cfg.Triggers {
-		ct, err := compileTrigger(&trigCfg)
-		if err != nil {
-			return nil, fmt.Errorf("triggers[%d]: %w", i, err)
+		for _, routeCfg := range trigCfg.Expand() {
+			ct, err := compileTrigger(&routeCfg)
+			if err != nil {
+				return nil, fmt.Errorf("triggers[%d]: %w", i, err)
+			}
+			cw.Triggers = append(cw.Triggers, ct)
 		}
-		cw.Triggers = append(cw.Triggers, ct)
 	}
 
 	// Compile steps
 	for i, stepCfg := range cfg.Steps {
-		cs, err := compileStep(&stepCfg, i, aliasASTs)
+		cs, err := compileStep(&stepCfg, i, aliasASTs, cfg.Partials)
 		if err != nil {
 			name := stepCfg.Name
 			if name == "" {
@@ -289,10 +481,124 @@ func Compile(cfg *WorkflowConfig) (*CompiledWorkflow, error) {
 		}
 		cw.Steps = append(cw.Steps, cs)
 	}
+	applyResultLimits(cw.Steps, cfg)
+	applyPublicIDColumns(cw.Steps, cfg)
+	var unmaskWhen *vm.Program
+	if cfg.UnmaskWhen != "" {
+		prog, err := compileConditionWithAliases(cfg.UnmaskWhen, aliasASTs)
+		if err != nil {
+			return nil, fmt.Errorf("unmask_when: %w", err)
+		}
+		unmaskWhen = prog
+	}
+	applyMaskColumns(cw.Steps, cfg, unmaskWhen)
+	applyColumnFilters(cw.Steps, cfg)
+	cw.Streams = hasStepType(cfg.Steps, StepTypeResponseSSE)
 
 	return cw, nil
 }
 
+// applyResultLimits resolves the result limit of every reading query step,
+// including those nested in blocks. Writes are never limited.
+func applyResultLimits(steps []*CompiledStep, wf *WorkflowConfig) {
+	for _, cs := range steps {
+		applyResultLimits(cs.BlockSteps, wf)
+		cfg := cs.Config
+		if cfg.StepType() != "query" || cs.IsWrite {
+			continue
+		}
+		limit := &step.ResultLimit{MaxRows: cfg.MaxRows, MaxBytes: cfg.MaxResponseBytes}
+		if limit.MaxRows == 0 {
+			limit.MaxRows = wf.MaxRows
+		}
+		if limit.MaxBytes == 0 {
+			limit.MaxBytes = wf.MaxResponseBytes
+		}
+		if limit.MaxRows == 0 && limit.MaxBytes == 0 {
+			continue
+		}
+		cs.ResultLimit = limit
+		cs.OnLimit = cfg.OnLimit
+		if cs.OnLimit == "" {
+			cs.OnLimit = wf.OnLimit
+		}
+		if cs.OnLimit == "" {
+			cs.OnLimit = "error"
+		}
+	}
+}
+
+// hasStepType reports whether any step, including those nested in blocks, has the type
+func hasStepType(steps []StepConfig, stepType string) bool {
+	for i := range steps {
+		if steps[i].StepType() == stepType || hasStepType(steps[i].Steps, stepType) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPublicIDColumns resolves the public ID columns of every query step,
+// including those nested in blocks. A step maps a column to "" to opt out of a
+// workflow default.
+func applyPublicIDColumns(steps []*CompiledStep, wf *WorkflowConfig) {
+	for _, cs := range steps {
+		applyPublicIDColumns(cs.BlockSteps, wf)
+		cfg := cs.Config
+		if cfg.StepType() != "query" || (len(wf.PublicIDColumns) == 0 && len(cfg.PublicIDColumns) == 0) {
+			continue
+		}
+		columns := make(map[string]string, len(wf.PublicIDColumns)+len(cfg.PublicIDColumns))
+		maps.Copy(columns, wf.PublicIDColumns)
+		maps.Copy(columns, cfg.PublicIDColumns)
+		maps.DeleteFunc(columns, func(_, ns string) bool { return ns == "" })
+		if len(columns) > 0 {
+			cs.PublicIDColumns = columns
+		}
+	}
+}
+
+// applyMaskColumns resolves the masked columns of every query step like
+// applyPublicIDColumns. Steps without their own unmask_when use unmaskWhen.
+func applyMaskColumns(steps []*CompiledStep, wf *WorkflowConfig, unmaskWhen *vm.Program) {
+	for _, cs := range steps {
+		applyMaskColumns(cs.BlockSteps, wf, unmaskWhen)
+		cfg := cs.Config
+		if cfg.StepType() != "query" || (len(wf.MaskColumns) == 0 && len(cfg.MaskColumns) == 0) {
+			continue
+		}
+		columns := make(map[string]string, len(wf.MaskColumns)+len(cfg.MaskColumns))
+		maps.Copy(columns, wf.MaskColumns)
+		maps.Copy(columns, cfg.MaskColumns)
+		maps.DeleteFunc(columns, func(_, strategy string) bool { return strategy == "" })
+		if len(columns) == 0 {
+			continue
+		}
+		cs.MaskColumns = columns
+		if cs.UnmaskWhen == nil {
+			cs.UnmaskWhen = unmaskWhen
+		}
+	}
+}
+
+// applyColumnFilters resolves the include_columns and exclude_columns of every
+// query step, including those nested in blocks
+func applyColumnFilters(steps []*CompiledStep, wf *WorkflowConfig) {
+	for _, cs := range steps {
+		applyColumnFilters(cs.BlockSteps, wf)
+		if cs.Config.StepType() != "query" {
+			continue
+		}
+		include, exclude := resolveColumnFilters(wf, cs.Config)
+		if len(include) > 0 {
+			cs.IncludeColumns = include
+		}
+		if len(exclude) > 0 {
+			cs.ExcludeColumns = exclude
+		}
+	}
+}
+
 func compileTrigger(cfg *TriggerConfig) (*CompiledTrigger, error) {
 	ct := &CompiledTrigger{Config: cfg}
 
@@ -304,6 +610,13 @@ func compileTrigger(cfg *TriggerConfig) (*CompiledTrigger, error) {
 		}
 		ct.CacheKey = tmpl
 	}
+	if cfg.Cache != nil {
+		tags, err := compileTagTemplates("cache.tags", cfg.Cache.Tags)
+		if err != nil {
+			return nil, err
+		}
+		ct.CacheTags = tags
+	}
 
 	// Compile rate limit key templates
 	for i, rl := range cfg.RateLimit {
@@ -318,10 +631,51 @@ func compileTrigger(cfg *TriggerConfig) (*CompiledTrigger, error) {
 		ct.RateLimits = append(ct.RateLimits, crl)
 	}
 
+	rules, err := compileParamRules(cfg.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	ct.ParamRules = rules
+
+	if len(cfg.ResponseHeaders) > 0 {
+		ct.ResponseHeaders = make(map[string]*template.Template, len(cfg.ResponseHeaders))
+		for name, val := range cfg.ResponseHeaders {
+			tmpl, err := template.New("response_header_" + name).Funcs(TemplateFuncs).Parse(val)
+			if err != nil {
+				return nil, fmt.Errorf("response_headers[%s] template: %w", name, err)
+			}
+			ct.ResponseHeaders[name] = tmpl
+		}
+	}
+
+	if cfg.Sunset != "" {
+		sunset, err := cfg.SunsetTime()
+		if err != nil {
+			return nil, fmt.Errorf("sunset: %w", err)
+		}
+		ct.Sunset = sunset.UTC().Format(http.TimeFormat)
+	}
+
+	if cfg.Rewrite != nil {
+		pathParams, err := compilePathParams(cfg.Rewrite.PathParams)
+		if err != nil {
+			return nil, err
+		}
+		ct.PathParams = pathParams
+	}
+
+	if cfg.BodySchema != nil {
+		schema, err := CompileSchema(cfg.BodySchema)
+		if err != nil {
+			return nil, fmt.Errorf("body_schema: %w", err)
+		}
+		ct.BodySchema = schema
+	}
+
 	return ct, nil
 }
 
-func compileStep(cfg *StepConfig, index int, aliasASTs map[string]ast.Node) (*CompiledStep, error) {
+func compileStep(cfg *StepConfig, index int, aliasASTs map[string]ast.Node, partials *Partials) (*CompiledStep, error) {
 	cs := &CompiledStep{
 		Config: cfg,
 		Index:  index,
@@ -336,6 +690,14 @@ func compileStep(cfg *StepConfig, index int, aliasASTs map[string]ast.Node) (*Co
 		cs.Condition = prog
 	}
 
+	if cfg.UnmaskWhen != "" {
+		prog, err := compileConditionWithAliases(cfg.UnmaskWhen, aliasASTs)
+		if err != nil {
+			return nil, fmt.Errorf("unmask_when: %w", err)
+		}
+		cs.UnmaskWhen = prog
+	}
+
 	// Compile cache key template if present (for query and httpcall steps)
 	if cfg.Cache != nil && cfg.Cache.Key != "" {
 		tmpl, err := template.New("cache_key").Funcs(TemplateFuncs).Parse(cfg.Cache.Key)
@@ -344,6 +706,13 @@ func compileStep(cfg *StepConfig, index int, aliasASTs map[string]ast.Node) (*Co
 		}
 		cs.CacheKeyTmpl = tmpl
 	}
+	if cfg.Cache != nil {
+		tags, err := compileTagTemplates("cache.tags", cfg.Cache.Tags)
+		if err != nil {
+			return nil, err
+		}
+		cs.CacheTagTmpls = tags
+	}
 
 	// Compile params templates if present (available for all step types)
 	if len(cfg.Params) > 0 {
@@ -357,17 +726,73 @@ func compileStep(cfg *StepConfig, index int, aliasASTs map[string]ast.Node) (*Co
 		}
 	}
 
+	// Compile fallback template if present (used when on_error is "fallback")
+	if cfg.Fallback != "" {
+		tmpl, err := template.New("fallback").Funcs(TemplateFuncs).Parse(cfg.Fallback)
+		if err != nil {
+			return nil, fmt.Errorf("fallback template: %w", err)
+		}
+		cs.FallbackTmpl = tmpl
+	}
+
 	// Compile type-specific templates
 	switch cfg.StepType() {
 	case "query":
 		if cfg.SQL != "" {
-			tmpl, err := template.New("sql").Funcs(TemplateFuncs).Parse(cfg.SQL)
+			tmpl, err := partials.parseText("sql", cfg.SQL)
 			if err != nil {
 				return nil, fmt.Errorf("sql template: %w", err)
 			}
 			cs.SQLTmpl = tmpl
-			cs.IsWrite = sqlutil.IsWriteQuery(cfg.SQL)
-			cs.HasReturning = sqlutil.HasReturningClause(cfg.SQL)
+			sql := partials.expandText(cfg.SQL)
+			cs.IsWrite = sqlutil.IsWriteQuery(sql)
+			cs.HasReturning = sqlutil.HasReturningClause(sql)
+		}
+		if cfg.PageOffset != "" {
+			tmpl, err := template.New("page_offset").Funcs(TemplateFuncs).Parse(cfg.PageOffset)
+			if err != nil {
+				return nil, fmt.Errorf("page_offset template: %w", err)
+			}
+			cs.PageOffsetTmpl = tmpl
+		}
+		if cfg.Proc != nil {
+			cs.ProcExprs = make([]*vm.Program, len(cfg.Proc.Params))
+			cs.ProcTmpls = make([]*template.Template, len(cfg.Proc.Params))
+			for i, p := range cfg.Proc.Params {
+				switch {
+				case p.Value == "":
+				case isSetTemplate(p.Value):
+					tmpl, err := template.New("proc_param_" + p.Name).Funcs(TemplateFuncs).Parse(p.Value)
+					if err != nil {
+						return nil, fmt.Errorf("proc.params[%s] template: %w", p.Name, err)
+					}
+					cs.ProcTmpls[i] = tmpl
+				default:
+					prog, err := compileExpression(p.Value)
+					if err != nil {
+						return nil, fmt.Errorf("proc.params[%s]: %w", p.Name, err)
+					}
+					cs.ProcExprs[i] = prog
+				}
+			}
+		}
+		for i, ev := range cfg.Outbox {
+			var compiled CompiledOutboxEvent
+			var err error
+			if compiled.Topic, err = template.New("outbox_topic").Funcs(TemplateFuncs).Parse(ev.Topic); err != nil {
+				return nil, fmt.Errorf("outbox[%d].topic template: %w", i, err)
+			}
+			if ev.Key != "" {
+				if compiled.Key, err = template.New("outbox_key").Funcs(TemplateFuncs).Parse(ev.Key); err != nil {
+					return nil, fmt.Errorf("outbox[%d].key template: %w", i, err)
+				}
+			}
+			if ev.Payload != "" {
+				if compiled.Payload, err = partials.parseText("outbox_payload", ev.Payload); err != nil {
+					return nil, fmt.Errorf("outbox[%d].payload template: %w", i, err)
+				}
+			}
+			cs.OutboxTmpls = append(cs.OutboxTmpls, compiled)
 		}
 
 	case "httpcall":
@@ -396,9 +821,13 @@ func compileStep(cfg *StepConfig, index int, aliasASTs map[string]ast.Node) (*Co
 			}
 		}
 
-	case "response":
+	case "response", "page":
+		templateType := cfg.TemplateType
+		if cfg.Type == StepTypePage {
+			templateType = "html"
+		}
 		if cfg.Template != "" {
-			tmpl, err := template.New("response").Funcs(TemplateFuncs).Parse(cfg.Template)
+			tmpl, err := compileResponseTemplate(cfg.Template, templateType, partials)
 			if err != nil {
 				return nil, fmt.Errorf("template: %w", err)
 			}
@@ -414,6 +843,191 @@ func compileStep(cfg *StepConfig, index int, aliasASTs map[string]ast.Node) (*Co
 				cs.HeaderTmpls[name] = tmpl
 			}
 		}
+		if cfg.Schema != nil {
+			schema, err := CompileSchema(cfg.Schema)
+			if err != nil {
+				return nil, fmt.Errorf("schema: %w", err)
+			}
+			cs.ResponseSchema = schema
+		}
+
+	case "redirect":
+		if cfg.Location != "" {
+			tmpl, err := template.New("location").Funcs(TemplateFuncs).Parse(cfg.Location)
+			if err != nil {
+				return nil, fmt.Errorf("location template: %w", err)
+			}
+			cs.URLTmpl = tmpl
+		}
+		if len(cfg.Headers) > 0 {
+			cs.HeaderTmpls = make(map[string]*template.Template)
+			for name, val := range cfg.Headers {
+				tmpl, err := template.New("header_" + name).Funcs(TemplateFuncs).Parse(val)
+				if err != nil {
+					return nil, fmt.Errorf("headers[%s] template: %w", name, err)
+				}
+				cs.HeaderTmpls[name] = tmpl
+			}
+		}
+
+	case "response_sse":
+		if cfg.Template != "" {
+			tmpl, err := compileResponseTemplate(cfg.Template, "", partials)
+			if err != nil {
+				return nil, fmt.Errorf("template: %w", err)
+			}
+			cs.TemplateTmpl = tmpl
+		}
+		if cfg.Source != "" {
+			prog, err := compileExpression(cfg.Source)
+			if err != nil {
+				return nil, fmt.Errorf("source: %w", err)
+			}
+			cs.SourceProg = prog
+		}
+
+	case "cache_invalidate":
+		tags, err := compileTagTemplates("tags", cfg.Tags)
+		if err != nil {
+			return nil, err
+		}
+		cs.TagTmpls = tags
+
+	case "set":
+		for name, val := range cfg.Values {
+			if isSetTemplate(val) {
+				tmpl, err := template.New("value_" + name).Funcs(TemplateFuncs).Parse(val)
+				if err != nil {
+					return nil, fmt.Errorf("values[%s] template: %w", name, err)
+				}
+				if cs.SetTmpls == nil {
+					cs.SetTmpls = make(map[string]*template.Template)
+				}
+				cs.SetTmpls[name] = tmpl
+				continue
+			}
+			prog, err := compileExpression(val)
+			if err != nil {
+				return nil, fmt.Errorf("values[%s]: %w", name, err)
+			}
+			if cs.SetExprs == nil {
+				cs.SetExprs = make(map[string]*vm.Program)
+			}
+			cs.SetExprs[name] = prog
+		}
+
+	case "notify":
+		if cfg.URL != "" {
+			tmpl, err := template.New("url").Funcs(TemplateFuncs).Parse(cfg.URL)
+			if err != nil {
+				return nil, fmt.Errorf("url template: %w", err)
+			}
+			cs.URLTmpl = tmpl
+		}
+		if len(cfg.Headers) > 0 {
+			cs.HeaderTmpls = make(map[string]*template.Template)
+			for name, val := range cfg.Headers {
+				tmpl, err := template.New("header_" + name).Funcs(TemplateFuncs).Parse(val)
+				if err != nil {
+					return nil, fmt.Errorf("headers[%s] template: %w", name, err)
+				}
+				cs.HeaderTmpls[name] = tmpl
+			}
+		}
+		if cfg.Title != "" {
+			tmpl, err := template.New("title").Funcs(TemplateFuncs).Parse(cfg.Title)
+			if err != nil {
+				return nil, fmt.Errorf("title template: %w", err)
+			}
+			cs.TitleTmpl = tmpl
+		}
+		if cfg.Text != "" {
+			tmpl, err := template.New("text").Funcs(TemplateFuncs).Parse(cfg.Text)
+			if err != nil {
+				return nil, fmt.Errorf("text template: %w", err)
+			}
+			cs.TextTmpl = tmpl
+		}
+		if cfg.Blocks != "" {
+			tmpl, err := template.New("blocks").Funcs(TemplateFuncs).Parse(cfg.Blocks)
+			if err != nil {
+				return nil, fmt.Errorf("blocks template: %w", err)
+			}
+			cs.BlocksTmpl = tmpl
+		}
+
+	case "email":
+		email, err := compileEmail(cfg)
+		if err != nil {
+			return nil, err
+		}
+		cs.EmailTmpls = email
+
+	case "metric":
+		if cfg.Metric == nil {
+			return nil, fmt.Errorf("metric is required")
+		}
+		if cfg.Metric.Value != "" {
+			prog, err := compileExpression(cfg.Metric.Value)
+			if err != nil {
+				return nil, fmt.Errorf("metric.value: %w", err)
+			}
+			cs.MetricValueProg = prog
+		}
+		cs.MetricLabelTmpls = make(map[string]*template.Template, len(cfg.Metric.Labels))
+		for name, val := range cfg.Metric.Labels {
+			tmpl, err := template.New("label_" + name).Funcs(TemplateFuncs).Parse(val)
+			if err != nil {
+				return nil, fmt.Errorf("metric.labels[%s] template: %w", name, err)
+			}
+			cs.MetricLabelTmpls[name] = tmpl
+		}
+
+	case "storage", "sftp", "mqtt":
+		for _, t := range []struct {
+			name, text string
+			dst        **template.Template
+		}{
+			{"bucket", cfg.Bucket, &cs.BucketTmpl},
+			{"key", cfg.Key, &cs.KeyTmpl},
+			{"remote_path", cfg.RemotePath, &cs.RemotePathTmpl},
+			{"topic", cfg.Topic, &cs.TopicTmpl},
+			{"body", cfg.Body, &cs.BodyTmpl},
+		} {
+			if t.text == "" {
+				continue
+			}
+			tmpl, err := template.New(t.name).Funcs(TemplateFuncs).Parse(t.text)
+			if err != nil {
+				return nil, fmt.Errorf("%s template: %w", t.name, err)
+			}
+			*t.dst = tmpl
+		}
+		if cfg.Source != "" {
+			prog, err := compileExpression(cfg.Source)
+			if err != nil {
+				return nil, fmt.Errorf("source: %w", err)
+			}
+			cs.SourceProg = prog
+		}
+
+	case "bulk_insert":
+		if cfg.Source != "" {
+			prog, err := compileExpression(cfg.Source)
+			if err != nil {
+				return nil, fmt.Errorf("source: %w", err)
+			}
+			cs.SourceProg = prog
+		}
+
+	case "script":
+		if cfg.Script != "" {
+			prog, err := compileScript(cfg.Name, cfg.Script)
+			if err != nil {
+				return nil, fmt.Errorf("script: %w", err)
+			}
+			cs.ScriptProg = prog
+		}
 
 	case "block":
 		// Compile iterate expression
@@ -431,7 +1045,7 @@ func compileStep(cfg *StepConfig, index int, aliasASTs map[string]ast.Node) (*Co
 
 		// Compile nested steps
 		for i, nestedCfg := range cfg.Steps {
-			nested, err := compileStep(&nestedCfg, i, aliasASTs)
+			nested, err := compileStep(&nestedCfg, i, aliasASTs, partials)
 			if err != nil {
 				name := nestedCfg.Name
 				if name == "" {
@@ -446,6 +1060,103 @@ func compileStep(cfg *StepConfig, index int, aliasASTs map[string]ast.Node) (*Co
 	return cs, nil
 }
 
+// compileTagTemplates compiles a list of cache tag templates.
+// field names the config field for error messages (e.g., "cache.tags").
+func compileTagTemplates(field string, tags []string) ([]*template.Template, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	tmpls := make([]*template.Template, 0, len(tags))
+	for i, tag := range tags {
+		tmpl, err := template.New("cache_tag").Funcs(TemplateFuncs).Parse(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d] template: %w", field, i, err)
+		}
+		tmpls = append(tmpls, tmpl)
+	}
+	return tmpls, nil
+}
+
+// compileEmail parses the templates of an email step; unset fields stay nil.
+func compileEmail(cfg *StepConfig) (*CompiledEmail, error) {
+	email := &CompiledEmail{}
+	parse := func(name, text string) (*template.Template, error) {
+		if text == "" {
+			return nil, nil
+		}
+		tmpl, err := template.New(name).Funcs(TemplateFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s template: %w", name, err)
+		}
+		return tmpl, nil
+	}
+	parseList := func(name string, list []string) ([]*template.Template, error) {
+		tmpls := make([]*template.Template, 0, len(list))
+		for i, text := range list {
+			tmpl, err := parse(fmt.Sprintf("%s[%d]", name, i), text)
+			if err != nil {
+				return nil, err
+			}
+			if tmpl != nil {
+				tmpls = append(tmpls, tmpl)
+			}
+		}
+		return tmpls, nil
+	}
+
+	var err error
+	if email.From, err = parse("from", cfg.From); err != nil {
+		return nil, err
+	}
+	if email.To, err = parseList("to", cfg.To); err != nil {
+		return nil, err
+	}
+	if email.Cc, err = parseList("cc", cfg.Cc); err != nil {
+		return nil, err
+	}
+	if email.Bcc, err = parseList("bcc", cfg.Bcc); err != nil {
+		return nil, err
+	}
+	if email.Subject, err = parse("subject", cfg.Subject); err != nil {
+		return nil, err
+	}
+	if email.Text, err = parse("text", cfg.Text); err != nil {
+		return nil, err
+	}
+	if email.HTML, err = parse("html", cfg.HTML); err != nil {
+		return nil, err
+	}
+	return email, nil
+}
+
+// responseTemplate renders a response body; it is a text/template, or an
+// html/template for steps with template_type: html.
+type responseTemplate interface {
+	Execute(w io.Writer, data any) error
+}
+
+// compileResponseTemplate parses a response template. HTML templates escape
+// values for the context they appear in (element text, attributes, URLs, scripts).
+func compileResponseTemplate(text, templateType string, partials *Partials) (responseTemplate, error) {
+	if templateType == "html" {
+		tmpl, err := partials.parseHTML("response", text)
+		if err != nil {
+			return nil, err
+		}
+		return tmpl, nil
+	}
+	tmpl, err := partials.parseText("response", text)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// isSetTemplate reports whether a set step value is a template rather than an expression.
+func isSetTemplate(val string) bool {
+	return strings.Contains(val, "{{")
+}
+
 func compileCondition(exprStr string) (*vm.Program, error) {
 	return compileExprWithType(exprStr, true)
 }
@@ -454,6 +1165,13 @@ func compileExpression(exprStr string) (*vm.Program, error) {
 	return compileExprWithType(exprStr, false)
 }
 
+// compileScript compiles a script step's JavaScript in strict mode as the body of a
+// function, so the script can return its result. The body starts on the wrapper's
+// line, keeping line numbers in errors the script's own.
+func compileScript(name, src string) (*goja.Program, error) {
+	return goja.Compile(name, "(function() {"+src+"\n})", true)
+}
+
 func compileExprWithType(exprStr string, asBool bool) (*vm.Program, error) {
 	opts := []expr.Option{
 		expr.AllowUndefinedVariables(), // Allow forward references to be checked at runtime
@@ -530,6 +1248,44 @@ func extractAliasRefs(exprStr string, aliasNames map[string]bool) ([]string, err
 	return finder.refs, nil
 }
 
+// UsedConditions returns the condition aliases the workflow's conditions and
+// unmask_when expressions reference, directly or through other aliases.
+// Expressions that fail to parse are skipped; Validate reports them.
+func UsedConditions(cfg *WorkflowConfig) map[string]bool {
+	used := make(map[string]bool)
+	if len(cfg.Conditions) == 0 {
+		return used
+	}
+	names := make(map[string]bool, len(cfg.Conditions))
+	for name := range cfg.Conditions {
+		names[name] = true
+	}
+	var mark func(exprStr string)
+	mark = func(exprStr string) {
+		if exprStr == "" {
+			return
+		}
+		refs, _ := extractAliasRefs(exprStr, names)
+		for _, ref := range refs {
+			if !used[ref] {
+				used[ref] = true
+				mark(cfg.Conditions[ref])
+			}
+		}
+	}
+	var markSteps func(steps []StepConfig)
+	markSteps = func(steps []StepConfig) {
+		for _, step := range steps {
+			mark(step.Condition)
+			mark(step.UnmaskWhen)
+			markSteps(step.Steps)
+		}
+	}
+	mark(cfg.UnmaskWhen)
+	markSteps(cfg.Steps)
+	return used
+}
+
 // topoSortAliases returns aliases in dependency order (dependencies first).
 // Returns an error if a circular dependency is detected.
 func topoSortAliases(aliases map[string]string) ([]string, error) {
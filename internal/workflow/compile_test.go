@@ -2,11 +2,16 @@ package workflow
 
 import (
 	"bytes"
+	"context"
+	"reflect"
 	"strings"
 	"testing"
 	"text/template"
 
+	"sql-proxy/internal/i18n"
+	"sql-proxy/internal/keyring"
 	"sql-proxy/internal/publicid"
+	"sql-proxy/internal/workflow/step"
 )
 
 func TestCompile_BasicWorkflow(t *testing.T) {
@@ -61,6 +66,43 @@ func TestCompile_BasicWorkflow(t *testing.T) {
 	}
 }
 
+func TestCompile_MultiRouteTrigger(t *testing.T) {
+	cfg := &WorkflowConfig{
+		Name: "test_workflow",
+		Triggers: []TriggerConfig{
+			{
+				Type:    "http",
+				Paths:   []string{"/api/a", "/api/b"},
+				Methods: []string{"GET", "POST"},
+				Cache:   &CacheConfig{Enabled: true, Key: "k:{{.trigger.params.id}}"},
+			},
+			{Type: "cron", Schedule: "0 * * * *"},
+		},
+		Steps: []StepConfig{{Type: "response", Template: `{}`}},
+	}
+
+	compiled, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	// 2 paths x 2 methods + 1 cron
+	if len(compiled.Triggers) != 5 {
+		t.Fatalf("expected 5 triggers, got %d", len(compiled.Triggers))
+	}
+	for _, ct := range compiled.Triggers[:4] {
+		if ct.Config.Method == "" || ct.Config.Path == "" {
+			t.Errorf("expected expanded trigger to have method and path, got %+v", ct.Config)
+		}
+		if ct.CacheKey == nil {
+			t.Error("expected cache key template on every expanded trigger")
+		}
+	}
+	if compiled.Triggers[4].Config.Type != "cron" {
+		t.Errorf("expected cron trigger last, got %q", compiled.Triggers[4].Config.Type)
+	}
+}
+
 func TestCompile_ConditionAliases(t *testing.T) {
 	cfg := &WorkflowConfig{
 		Name: "test",
@@ -181,6 +223,164 @@ func TestCompile_BlockWithIteration(t *testing.T) {
 	}
 }
 
+func TestCompile_ResultLimits(t *testing.T) {
+	cfg := &WorkflowConfig{
+		Name:     "test",
+		MaxRows:  1000,
+		OnLimit:  "truncate",
+		Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+		Steps: []StepConfig{
+			{Name: "defaults", Database: "db", SQL: "SELECT * FROM t"},
+			{Name: "own", Database: "db", SQL: "SELECT * FROM t ORDER BY id", MaxResponseBytes: 4096, OnLimit: "paginate", PageOffset: "{{.trigger.params.offset}}"},
+			{Name: "write", Database: "db", SQL: "DELETE FROM t"},
+			{
+				Name:  "block",
+				Steps: []StepConfig{{Name: "nested", Database: "db", SQL: "SELECT * FROM t", MaxRows: 5}},
+			},
+			{Type: "response", Template: "{}"},
+		},
+	}
+
+	compiled, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cs      *CompiledStep
+		want    *step.ResultLimit
+		onLimit string
+	}{
+		{"workflow defaults", compiled.Steps[0], &step.ResultLimit{MaxRows: 1000}, "truncate"},
+		{"step overrides", compiled.Steps[1], &step.ResultLimit{MaxRows: 1000, MaxBytes: 4096}, "paginate"},
+		{"writes are not limited", compiled.Steps[2], nil, ""},
+		{"nested step", compiled.Steps[3].BlockSteps[0], &step.ResultLimit{MaxRows: 5}, "truncate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.cs.ResultLimit, tt.want) {
+				t.Errorf("ResultLimit = %+v, want %+v", tt.cs.ResultLimit, tt.want)
+			}
+			if tt.cs.OnLimit != tt.onLimit {
+				t.Errorf("OnLimit = %q, want %q", tt.cs.OnLimit, tt.onLimit)
+			}
+		})
+	}
+	if compiled.Steps[1].PageOffsetTmpl == nil {
+		t.Error("expected page_offset template to be compiled")
+	}
+}
+
+func TestCompile_PublicIDColumns(t *testing.T) {
+	cfg := &WorkflowConfig{
+		Name:            "test",
+		PublicIDColumns: map[string]string{"id": "task", "owner_id": "user"},
+		Triggers:        []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+		Steps: []StepConfig{
+			{Name: "defaults", Database: "db", SQL: "SELECT * FROM t"},
+			{Name: "own", Database: "db", SQL: "SELECT * FROM t", PublicIDColumns: map[string]string{"parent_id": "task", "owner_id": ""}},
+			{
+				Name:  "block",
+				Steps: []StepConfig{{Name: "nested", Database: "db", SQL: "SELECT * FROM t"}},
+			},
+			{Type: "response", Template: "{}"},
+		},
+	}
+
+	compiled, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		cs   *CompiledStep
+		want map[string]string
+	}{
+		{"workflow defaults", compiled.Steps[0], map[string]string{"id": "task", "owner_id": "user"}},
+		{"step overrides and clears", compiled.Steps[1], map[string]string{"id": "task", "parent_id": "task"}},
+		{"block step", compiled.Steps[2], nil},
+		{"nested step", compiled.Steps[2].BlockSteps[0], map[string]string{"id": "task", "owner_id": "user"}},
+		{"response step", compiled.Steps[3], nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.cs.PublicIDColumns, tt.want) {
+				t.Errorf("PublicIDColumns = %v, want %v", tt.cs.PublicIDColumns, tt.want)
+			}
+		})
+	}
+	if len(cfg.Steps[1].PublicIDColumns) != 2 {
+		t.Error("the step config must not be modified")
+	}
+}
+
+func TestCompile_MaskColumns(t *testing.T) {
+	cfg := &WorkflowConfig{
+		Name:        "test",
+		MaskColumns: map[string]string{"email": "partial", "ssn": "full"},
+		UnmaskWhen:  `trigger.params.role == "admin"`,
+		Triggers:    []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+		Steps: []StepConfig{
+			{Name: "defaults", Database: "db", SQL: "SELECT * FROM t"},
+			{Name: "own", Database: "db", SQL: "SELECT * FROM t", MaskColumns: map[string]string{"card": "last4", "ssn": ""}, UnmaskWhen: "false"},
+			{Type: "response", Template: "{}"},
+		},
+	}
+
+	compiled, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	defaults, own := compiled.Steps[0], compiled.Steps[1]
+	if want := map[string]string{"email": "partial", "ssn": "full"}; !reflect.DeepEqual(defaults.MaskColumns, want) {
+		t.Errorf("defaults MaskColumns = %v, want %v", defaults.MaskColumns, want)
+	}
+	if want := map[string]string{"email": "partial", "card": "last4"}; !reflect.DeepEqual(own.MaskColumns, want) {
+		t.Errorf("own MaskColumns = %v, want %v", own.MaskColumns, want)
+	}
+	env := map[string]any{"trigger": map[string]any{"params": map[string]any{"role": "admin"}}}
+	if ok, _ := EvalCondition(defaults.UnmaskWhen, env); !ok {
+		t.Error("expected the workflow's unmask_when on the defaults step")
+	}
+	if ok, _ := EvalCondition(own.UnmaskWhen, env); ok {
+		t.Error("expected the step's own unmask_when")
+	}
+	if compiled.Steps[2].MaskColumns != nil || compiled.Steps[2].UnmaskWhen != nil {
+		t.Error("expected no masking on the response step")
+	}
+}
+
+func TestCompile_ColumnFilters(t *testing.T) {
+	cfg := &WorkflowConfig{
+		Name:           "test",
+		IncludeColumns: []string{"id", "name"},
+		ExcludeColumns: []string{"*_secret"},
+		Triggers:       []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+		Steps: []StepConfig{
+			{Name: "defaults", Database: "db", SQL: "SELECT * FROM t"},
+			{Name: "own", Database: "db", SQL: "SELECT * FROM t", IncludeColumns: []string{"email"}, ExcludeColumns: []string{"ssn"}},
+			{Type: "response", Template: "{}"},
+		},
+	}
+
+	compiled, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	defaults, own := compiled.Steps[0], compiled.Steps[1]
+	if !reflect.DeepEqual(defaults.IncludeColumns, []string{"id", "name"}) || !reflect.DeepEqual(defaults.ExcludeColumns, []string{"*_secret"}) {
+		t.Errorf("defaults = %v / %v, want the workflow's filters", defaults.IncludeColumns, defaults.ExcludeColumns)
+	}
+	if !reflect.DeepEqual(own.IncludeColumns, []string{"email"}) || !reflect.DeepEqual(own.ExcludeColumns, []string{"*_secret", "ssn"}) {
+		t.Errorf("own = %v / %v, want its include_columns and both exclude_columns", own.IncludeColumns, own.ExcludeColumns)
+	}
+	if compiled.Steps[2].IncludeColumns != nil || compiled.Steps[2].ExcludeColumns != nil {
+		t.Error("expected no column filters on the response step")
+	}
+}
+
 func TestCompile_CacheKeyTemplate(t *testing.T) {
 	cfg := &WorkflowConfig{
 		Name: "test",
@@ -211,6 +411,127 @@ func TestCompile_CacheKeyTemplate(t *testing.T) {
 	}
 }
 
+func TestCompile_CacheTagTemplates(t *testing.T) {
+	cfg := &WorkflowConfig{
+		Name: "test",
+		Triggers: []TriggerConfig{
+			{
+				Type:   "http",
+				Path:   "/users/{id}",
+				Method: "GET",
+				Cache: &CacheConfig{
+					Enabled: true,
+					Key:     "user:{{.trigger.params.id}}",
+					Tags:    []string{"user:{{.trigger.params.id}}", "users"},
+				},
+			},
+		},
+		Steps: []StepConfig{
+			{Name: "bust", Type: "cache_invalidate", Tags: []string{"orders:{{.trigger.params.id}}"}},
+			{Type: "response", Template: "{}"},
+		},
+	}
+
+	compiled, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	if len(compiled.Triggers[0].CacheTags) != 2 {
+		t.Errorf("expected 2 trigger cache tag templates, got %d", len(compiled.Triggers[0].CacheTags))
+	}
+	if len(compiled.Steps[0].TagTmpls) != 1 {
+		t.Errorf("expected 1 invalidate tag template, got %d", len(compiled.Steps[0].TagTmpls))
+	}
+
+	cfg.Steps[0].Tags = []string{"{{.bad"}
+	if _, err := Compile(cfg); err == nil || !strings.Contains(err.Error(), "tags[0] template") {
+		t.Errorf("expected tags[0] template error, got %v", err)
+	}
+}
+
+func TestCompile_Partials(t *testing.T) {
+	partials, err := CompilePartials(map[string]string{
+		"envelope":     `{"success": {{.ok}}, "data": {{template "data" .}}}`,
+		"data":         `{{json .rows}}`,
+		"active_only":  `WHERE active = 1`,
+		"insert_audit": `INSERT INTO audit (msg) VALUES (@msg)`,
+		"title":        `<h1>{{.name}}</h1>`,
+	})
+	if err != nil {
+		t.Fatalf("CompilePartials: %v", err)
+	}
+
+	cfg := &WorkflowConfig{
+		Name:     "test",
+		Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+		Partials: partials,
+		Steps: []StepConfig{
+			{Name: "read", Type: "query", Database: "db", SQL: `SELECT * FROM users {{template "active_only"}}`},
+			{Name: "audit", Type: "query", Database: "db", SQL: `{{template "insert_audit"}}`},
+			{Type: "response", Template: `{{template "envelope" .}}`},
+			{Type: "response", TemplateType: "html", Template: `{{template "title" .}}`},
+		},
+	}
+	cw, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	var sqlBuf bytes.Buffer
+	if err := cw.Steps[0].SQLTmpl.Execute(&sqlBuf, nil); err != nil {
+		t.Fatalf("sql: %v", err)
+	}
+	if sqlBuf.String() != "SELECT * FROM users WHERE active = 1" {
+		t.Errorf("sql = %q", sqlBuf.String())
+	}
+	if cw.Steps[0].IsWrite || !cw.Steps[1].IsWrite {
+		t.Errorf("IsWrite = %v, %v, want false, true (partials count for statement type)", cw.Steps[0].IsWrite, cw.Steps[1].IsWrite)
+	}
+
+	data := map[string]any{"ok": true, "rows": []int{1, 2}, "name": "<b>x</b>"}
+	for i, want := range map[int]string{2: `{"success": true, "data": [1,2]}`, 3: `<h1>&lt;b&gt;x&lt;/b&gt;</h1>`} {
+		var buf bytes.Buffer
+		if err := cw.Steps[i].TemplateTmpl.Execute(&buf, data); err != nil {
+			t.Fatalf("response %d: %v", i, err)
+		}
+		if buf.String() != want {
+			t.Errorf("response %d = %q, want %q", i, buf.String(), want)
+		}
+	}
+}
+
+func TestCompile_PartialErrors(t *testing.T) {
+	if _, err := CompilePartials(map[string]string{"bad": "{{.x"}); err == nil || !strings.Contains(err.Error(), "bad:") {
+		t.Errorf("expected parse error naming the partial, got %v", err)
+	}
+	if _, err := CompilePartials(map[string]string{"outer": `{{template "inner" .}}`}); err == nil || !strings.Contains(err.Error(), `template "inner" not defined`) {
+		t.Errorf("expected undefined partial error, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		partials map[string]string
+		step     StepConfig
+	}{
+		{"no partials", nil, StepConfig{Type: "response", Template: `{{template "envelope" .}}`}},
+		{"unknown partial", map[string]string{"envelope": "{}"}, StepConfig{Type: "response", Template: `{{if .ok}}{{template "envlope" .}}{{end}}`}},
+		{"unknown partial in SQL", map[string]string{"envelope": "{}"}, StepConfig{Name: "q", Type: "query", Database: "db", SQL: `SELECT 1 {{template "where"}}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			partials, err := CompilePartials(tt.partials)
+			if err != nil {
+				t.Fatalf("CompilePartials: %v", err)
+			}
+			cfg := &WorkflowConfig{Name: "test", Partials: partials, Steps: []StepConfig{tt.step}}
+			if _, err := Compile(cfg); err == nil || !strings.Contains(err.Error(), "not defined") {
+				t.Errorf("expected undefined template error, got %v", err)
+			}
+		})
+	}
+}
+
 func TestCompile_InvalidTemplateSyntax(t *testing.T) {
 	tests := []struct {
 		name string
@@ -238,6 +559,28 @@ func TestCompile_InvalidTemplateSyntax(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "invalid fallback template",
+			cfg: &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps: []StepConfig{
+					{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", OnError: "fallback", Fallback: "{{.bad syntax}}"},
+					{Type: "response", Template: "{}"},
+				},
+			},
+		},
+		{
+			name: "invalid set value",
+			cfg: &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps: []StepConfig{
+					{Name: "s", Type: "set", Values: map[string]string{"bad": "1 +"}},
+					{Type: "response", Template: "{}"},
+				},
+			},
+		},
 		{
 			name: "invalid response template",
 			cfg: &WorkflowConfig{
@@ -655,6 +998,34 @@ func TestEmptyAliases(t *testing.T) {
 	}
 }
 
+// TestUsedConditions verifies aliases count as used from step conditions, nested steps, unmask_when and other aliases
+func TestUsedConditions(t *testing.T) {
+	cfg := &WorkflowConfig{
+		Conditions: map[string]string{
+			"found":    "steps.fetch.count > 0",
+			"big":      "found && steps.fetch.count > 100",
+			"admin":    "trigger.headers.role == 'admin'",
+			"nested":   "true",
+			"leftover": "false",
+			"dangling": "leftover || false",
+		},
+		UnmaskWhen: "admin",
+		Steps: []StepConfig{
+			{Name: "fetch", SQL: "SELECT 1"},
+			{Name: "each", Condition: "big", Steps: []StepConfig{{Name: "inner", Condition: "nested"}}},
+		},
+	}
+	used := UsedConditions(cfg)
+	for _, name := range []string{"found", "big", "admin", "nested"} {
+		if !used[name] {
+			t.Errorf("%s not used", name)
+		}
+	}
+	if used["leftover"] || used["dangling"] {
+		t.Errorf("used = %v, want leftover and dangling unused", used)
+	}
+}
+
 func TestEvalCondition(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1329,6 +1700,100 @@ func TestExprFunc_isValidPublicID(t *testing.T) {
 	})
 }
 
+// TestTemplateFuncs_EncryptDecrypt tests encrypt/decrypt with keys set via SetTemplateKeyring.
+func TestTemplateFuncs_EncryptDecrypt(t *testing.T) {
+	run := func(text string, data any) (string, error) {
+		tmpl, err := template.New("test").Funcs(TemplateFuncs).Parse(text)
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, data)
+		return buf.String(), err
+	}
+	data := map[string]any{"ssn": "123-45-6789"}
+
+	SetTemplateKeyring(nil)
+	if _, err := run(`{{encrypt .ssn}}`, data); err == nil || !strings.Contains(err.Error(), "crypto_keys not configured") {
+		t.Errorf("expected crypto_keys not configured error, got %v", err)
+	}
+
+	k, err := keyring.New("k1", []keyring.KeyConfig{{ID: "k1", Key: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}})
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+	SetTemplateKeyring(k)
+	t.Cleanup(func() { SetTemplateKeyring(nil) })
+
+	enc, err := run(`{{encrypt .ssn}}`, data)
+	if err != nil || !strings.HasPrefix(enc, "enc:k1:") {
+		t.Fatalf("encrypt = %q, %v", enc, err)
+	}
+	dec, err := run(`{{decrypt .enc}}`, map[string]any{"enc": []byte(enc)})
+	if err != nil || dec != "123-45-6789" {
+		t.Errorf("decrypt = %q, %v, want 123-45-6789", dec, err)
+	}
+}
+
+// TestTemplateFuncs_Translate tests t and trigger.lang with a catalog set via SetTemplateMessages.
+func TestTemplateFuncs_Translate(t *testing.T) {
+	run := func(text string, data any) (string, error) {
+		tmpl, err := template.New("test").Funcs(TemplateFuncs).Parse(text)
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, data)
+		return buf.String(), err
+	}
+
+	SetTemplateMessages(nil)
+	if _, err := run(`{{t "errors.not_found" "fr"}}`, nil); err == nil || !strings.Contains(err.Error(), "messages not configured") {
+		t.Errorf("expected messages not configured error, got %v", err)
+	}
+	if lang := negotiateLanguage("fr"); lang != "" {
+		t.Errorf("negotiateLanguage without messages = %q, want empty", lang)
+	}
+
+	c, err := i18n.New("en", map[string]map[string]any{
+		"en": {"errors": map[string]any{"not_found": "Not found"}, "count": "%d rows"},
+		"fr": {"errors": map[string]any{"not_found": "Introuvable"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create catalog: %v", err)
+	}
+	SetTemplateMessages(c)
+	t.Cleanup(func() { SetTemplateMessages(nil) })
+
+	trigger := &TriggerData{Type: "http", Lang: negotiateLanguage("fr-CH, en;q=0.5")}
+	wf := &CompiledWorkflow{Config: &WorkflowConfig{Name: "test"}}
+	data := NewContext(context.Background(), wf, trigger, "req-1", nil, nil).BuildTemplateData()
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{{.trigger.lang}}`, "fr"},
+		{`{{t "errors.not_found" .trigger.lang}}`, "Introuvable"},
+		{`{{t "count" .trigger.lang 3}}`, "3 rows"},
+		{`{{t "errors.not_found" .trigger.missing}}`, "Not found"},
+		{`{{t "errors.unknown" .trigger.lang}}`, "errors.unknown"},
+	}
+	for _, tt := range tests {
+		got, err := run(tt.template, data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.template, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+	if lang := DefaultLanguage(); lang != "en" {
+		t.Errorf("DefaultLanguage() = %q, want en", lang)
+	}
+}
+
 // TestExprFuncs_InConditions tests that common functions from tmpl.ExprFuncs
 // are available and work correctly in condition expressions.
 func TestExprFuncs_InConditions(t *testing.T) {
@@ -1,48 +1,235 @@
 package workflow
 
-import "sql-proxy/internal/types"
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"sql-proxy/internal/types"
+)
 
 // Step type constants
 const (
-	StepTypeQuery    = "query"
-	StepTypeHTTPCall = "httpcall"
-	StepTypeResponse = "response"
-	StepTypeBlock    = "block"
-	StepTypeUnknown  = "unknown"
+	StepTypeQuery           = "query"
+	StepTypeHTTPCall        = "httpcall"
+	StepTypeResponse        = "response"
+	StepTypeResponseSSE     = "response_sse"
+	StepTypeRedirect        = "redirect"
+	StepTypePage            = "page"
+	StepTypeCacheInvalidate = "cache_invalidate"
+	StepTypeSet             = "set"
+	StepTypeScript          = "script"
+	StepTypeEmail           = "email"
+	StepTypeNotify          = "notify"
+	StepTypeStorage         = "storage"
+	StepTypeSFTP            = "sftp"
+	StepTypeBulkInsert      = "bulk_insert"
+	StepTypeMQTT            = "mqtt"
+	StepTypeMetric          = "metric"
+	StepTypeBlock           = "block"
+	StepTypeUnknown         = "unknown"
 )
 
 // Trigger type constants
 const (
-	TriggerTypeHTTP = "http"
-	TriggerTypeCron = "cron"
+	TriggerTypeHTTP      = "http"
+	TriggerTypeWebSocket = "websocket"
+	TriggerTypeCron      = "cron"
+	TriggerTypeDBWatch   = "dbwatch"
+	TriggerTypeFileWatch = "filewatch"
+	TriggerTypeMQTT      = "mqtt"
+	TriggerTypeGRPC      = "grpc"
 )
 
 // ParamConfig is re-exported from internal/types for workflow parameters
 type ParamConfig = types.ParamConfig
 
+// ParamValidation is re-exported from internal/types for parameter validation rules
+type ParamValidation = types.ParamValidation
+
 // WorkflowConfig defines a complete workflow with triggers and steps.
 type WorkflowConfig struct {
-	Name       string            `yaml:"name"`
-	TimeoutSec int               `yaml:"timeout_sec,omitempty"`
-	Conditions map[string]string `yaml:"conditions,omitempty"` // Named condition aliases
-	Triggers   []TriggerConfig   `yaml:"triggers"`
-	Steps      []StepConfig      `yaml:"steps"`
+	Name                string                   `yaml:"name"`
+	TimeoutSec          int                      `yaml:"timeout_sec,omitempty"`
+	MaxConcurrent       int                      `yaml:"max_concurrent,omitempty"`         // Maximum concurrent executions (0 = unlimited)
+	MaxConcurrentWaitMs int                      `yaml:"max_concurrent_wait_ms,omitempty"` // How long an execution waits for a free slot before being rejected (0 = reject immediately)
+	Conditions          map[string]string        `yaml:"conditions,omitempty"`             // Named condition aliases
+	MaxRows             int                      `yaml:"max_rows,omitempty"`               // Default max_rows for the workflow's query steps
+	MaxResponseBytes    int                      `yaml:"max_response_bytes,omitempty"`     // Default max_response_bytes for the workflow's query steps
+	OnLimit             string                   `yaml:"on_limit,omitempty"`               // Default on_limit for the workflow's query steps
+	PublicIDColumns     map[string]string        `yaml:"public_id_columns,omitempty"`      // Default public_id_columns for the workflow's query steps
+	MaskColumns         map[string]string        `yaml:"mask_columns,omitempty"`           // Default mask_columns for the workflow's query steps
+	UnmaskWhen          string                   `yaml:"unmask_when,omitempty"`            // Default unmask_when for the workflow's query steps
+	IncludeColumns      []string                 `yaml:"include_columns,omitempty"`        // Default include_columns for the workflow's query steps
+	ExcludeColumns      []string                 `yaml:"exclude_columns,omitempty"`        // Column patterns removed from every query step's results
+	Envelope            *EnvelopeConfig          `yaml:"envelope,omitempty"`               // Shape of built-in responses (default: the top-level response_envelope)
+	StatusMap           map[string]StatusMapping `yaml:"status_map,omitempty"`             // Error class -> status and message of the error response (merged over the top-level status_map)
+	ErrorFormat         string                   `yaml:"error_format,omitempty"`           // envelope (default) or problem_json (default: the top-level error_format)
+	Listeners           []string                 `yaml:"listeners,omitempty"`              // Listeners serving the http and websocket triggers (default: main)
+	Group               string                   `yaml:"group,omitempty"`                  // Route group whose base_path and aliases prefix the http and websocket paths
+	Triggers            []TriggerConfig          `yaml:"triggers"`
+	Steps               []StepConfig             `yaml:"steps"`
+	Partials            *Partials                `yaml:"-"` // Shared templates from the top-level templates section, set before Compile
 }
 
 // TriggerConfig defines how a workflow is initiated.
 type TriggerConfig struct {
-	Type string `yaml:"type"` // "http" | "cron"
+	Type string `yaml:"type"` // "http" | "websocket" | "cron" | "dbwatch" | "filewatch" | "mqtt" | "grpc"
 
-	// HTTP trigger fields
+	// HTTP trigger fields (websocket triggers use path, parameters, rate_limit, and body_schema;
+	// grpc triggers use parameters, rate_limit, and body_schema)
+	// A trigger may declare several methods/paths; Compile expands them into one trigger per route.
 	Path       string               `yaml:"path,omitempty"`
+	Paths      []string             `yaml:"paths,omitempty"` // Alternative to path: register the same trigger on several paths
 	Method     string               `yaml:"method,omitempty"`
+	Methods    []string             `yaml:"methods,omitempty"` // Alternative to method: e.g., [GET, POST]
 	Parameters []ParamConfig        `yaml:"parameters,omitempty"`
 	RateLimit  []RateLimitRefConfig `yaml:"rate_limit,omitempty"`
 	Cache      *CacheConfig         `yaml:"cache,omitempty"`
+	BodySchema any                  `yaml:"body_schema,omitempty"` // JSON Schema for the request body: inline, or a path to a JSON/YAML file
+	Poll       *PollConfig          `yaml:"poll,omitempty"`        // Long polling: hold the request until the response changes
+	Priority   string               `yaml:"priority,omitempty"`    // low, normal, high, or critical: orders the worker pool queue; HTTP requests are load shed by it (critical never)
+
+	Idempotency *IdempotencyConfig `yaml:"idempotency,omitempty"` // Replay the stored response for retried writes with the same Idempotency-Key
+	DebugLog    *DebugLogConfig    `yaml:"debug_log,omitempty"`   // Write redacted request/response payloads to the debug log
+	Fields      *FieldsConfig      `yaml:"fields,omitempty"`      // Let clients trim query step rows with ?fields=a,b,c
+	Middlewares []string           `yaml:"middlewares,omitempty"` // Request stages run in order: rate_limit, cache (default: both, in that order; [] = none)
+	Rewrite     *RewriteConfig     `yaml:"rewrite,omitempty"`     // Adjust headers, query and path parameters before parameters are parsed
+
+	// Deprecated endpoints send Deprecation (and Sunset) headers, are marked deprecated
+	// in the OpenAPI spec, and count their calls in sqlproxy_deprecated_requests_total
+	Deprecated bool   `yaml:"deprecated,omitempty"`
+	Sunset     string `yaml:"sunset,omitempty"` // Date the endpoint goes away: 2025-06-30 or an RFC 3339 timestamp
+
+	// Templates rendered into headers of the endpoint's responses, e.g. Cache-Control or
+	// X-Total-Count from step data. A response step's own headers win over them.
+	ResponseHeaders map[string]string `yaml:"response_headers,omitempty"`
+
+	// Declarative sorting and filtering: ?sort=-created_at and ?filter[status]=active
+	// become .trigger.order_by and .trigger.where SQL fragments with bound values
+	SortableColumns   []string `yaml:"sortable_columns,omitempty"`   // Columns ?sort= may name; prefix "-" sorts descending
+	FilterableColumns []string `yaml:"filterable_columns,omitempty"` // Columns ?filter[col]= may compare for equality
+	DefaultSort       string   `yaml:"default_sort,omitempty"`       // Sort used without ?sort=, e.g. "-created_at,id"
+
+	// WebSocket trigger fields
+	MaxMessageBytes int      `yaml:"max_message_bytes,omitempty"` // Largest inbound message (0 = 64KB)
+	IdleTimeoutSec  int      `yaml:"idle_timeout_sec,omitempty"`  // Close connections silent for this long; pings are sent at half of it (0 = 60)
+	Origins         []string `yaml:"origins,omitempty"`           // Browser origins allowed to connect (default: same host; "*" = any)
 
 	// Cron trigger fields
 	Schedule string            `yaml:"schedule,omitempty"`
 	Params   map[string]string `yaml:"params,omitempty"`
+
+	// DBWatch trigger fields
+	Database    string   `yaml:"database,omitempty"`
+	Table       string   `yaml:"table,omitempty"`
+	Column      string   `yaml:"column,omitempty"`       // Column that increases on every insert/update
+	ColumnType  string   `yaml:"column_type,omitempty"`  // "timestamp" (default), "int", or "rowversion" (SQL Server)
+	Columns     []string `yaml:"columns,omitempty"`      // Columns passed to the workflow (default: all)
+	Where       string   `yaml:"where,omitempty"`        // Extra SQL condition rows must meet
+	IntervalSec int      `yaml:"interval_sec,omitempty"` // Seconds between polls, also for filewatch (0 = 30; filewatch 0 = 10)
+	BatchSize   int      `yaml:"batch_size,omitempty"`   // Rows per execution (0 = 100)
+	Start       string   `yaml:"start,omitempty"`        // Without a stored watermark: "latest" (default, skip existing rows) or "beginning"
+
+	// FileWatch trigger fields
+	Dir          string `yaml:"dir,omitempty"`            // Directory watched for new files
+	Pattern      string `yaml:"pattern,omitempty"`        // Glob matched against file names (default "*")
+	DoneDir      string `yaml:"done_dir,omitempty"`       // Files move here when the workflow succeeds
+	ErrorDir     string `yaml:"error_dir,omitempty"`      // Files move here when the workflow fails
+	MaxFileBytes int64  `yaml:"max_file_bytes,omitempty"` // Larger files move to error_dir unread (0 = 10MB)
+
+	// MQTT trigger fields
+	Broker string   `yaml:"broker,omitempty"` // Name of a broker in the top-level mqtt section
+	Topics []string `yaml:"topics,omitempty"` // Topic filters; + and # wildcards are allowed
+	QoS    int      `yaml:"qos,omitempty"`    // 0 (default, at most once) or 1 (at least once)
+
+	// gRPC trigger fields
+	RPC string `yaml:"rpc,omitempty"` // Optional generated method of sqlproxy.v1.Workflows taking the parameters as typed fields
+}
+
+// HTTPMethods returns the methods declared by method and methods.
+func (t *TriggerConfig) HTTPMethods() []string {
+	if t.Method == "" {
+		return t.Methods
+	}
+	return append([]string{t.Method}, t.Methods...)
+}
+
+// HTTPPaths returns the paths declared by path and paths.
+func (t *TriggerConfig) HTTPPaths() []string {
+	if t.Path == "" {
+		return t.Paths
+	}
+	return append([]string{t.Path}, t.Paths...)
+}
+
+// Expand returns one trigger per method/path combination, each with a single Method and Path.
+// Non-HTTP triggers and HTTP triggers without methods/paths lists are returned unchanged.
+func (t TriggerConfig) Expand() []TriggerConfig {
+	if t.Type != TriggerTypeHTTP || (len(t.Methods) == 0 && len(t.Paths) == 0) {
+		return []TriggerConfig{t}
+	}
+
+	methods, paths := t.HTTPMethods(), t.HTTPPaths()
+	expanded := make([]TriggerConfig, 0, len(methods)*len(paths))
+	for _, path := range paths {
+		for _, method := range methods {
+			route := t
+			route.Method, route.Path = method, path
+			route.Methods, route.Paths = nil, nil
+			expanded = append(expanded, route)
+		}
+	}
+	return expanded
+}
+
+// MainListener names the listener on server.host and server.port
+const MainListener = "main"
+
+// ServedOn reports whether the workflow's http and websocket triggers are served on a listener
+func (wf *WorkflowConfig) ServedOn(listener string) bool {
+	if len(wf.Listeners) == 0 {
+		return listener == MainListener
+	}
+	return slices.Contains(wf.Listeners, listener)
+}
+
+// PreloadRoute returns the route that cache preloading requests: the first GET
+// route of the workflow's http triggers with a cache. Returns nil if there is none.
+func (wf *WorkflowConfig) PreloadRoute() *TriggerConfig {
+	for _, trigger := range wf.Triggers {
+		for _, route := range trigger.Expand() {
+			if route.Type == TriggerTypeHTTP && route.Method == "GET" && route.Cache != nil && route.Cache.Enabled {
+				return &route
+			}
+		}
+	}
+	return nil
+}
+
+// PreloadTarget returns the request target of a cache preload request on route:
+// params named in the path fill it in, the others become the query string.
+func PreloadTarget(route *TriggerConfig, params map[string]string) (string, error) {
+	path := route.Path
+	for name := range extractPathParams(route.Path) {
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("missing path parameter '%s'", name)
+		}
+		path = strings.ReplaceAll(path, "{"+name+"}", url.PathEscape(value))
+	}
+	query := url.Values{}
+	for name, value := range params {
+		if !strings.Contains(route.Path, "{"+name+"}") {
+			query.Set(name, value)
+		}
+	}
+	if len(query) == 0 {
+		return path, nil
+	}
+	return path + "?" + query.Encode(), nil
 }
 
 // RateLimitRefConfig references a rate limit pool or defines inline limits.
@@ -66,11 +253,99 @@ func (r *RateLimitRefConfig) IsInline() bool {
 
 // CacheConfig defines caching for HTTP triggers.
 type CacheConfig struct {
-	Enabled   bool   `yaml:"enabled"`
-	Key       string `yaml:"key"`
-	TTLSec    int    `yaml:"ttl_sec,omitempty"`
-	MaxSizeMB int    `yaml:"max_size_mb,omitempty"`
-	EvictCron string `yaml:"evict_cron,omitempty"`
+	Enabled                 bool     `yaml:"enabled"`
+	Key                     string   `yaml:"key"`
+	TTLSec                  int      `yaml:"ttl_sec,omitempty"`
+	StaleWhileRevalidateSec int      `yaml:"stale_while_revalidate_sec,omitempty"` // Serve stale entries this long past TTL while refreshing in the background
+	MaxSizeMB               int      `yaml:"max_size_mb,omitempty"`
+	EvictCron               string   `yaml:"evict_cron,omitempty"`
+	Tags                    []string `yaml:"tags,omitempty"` // Templates for tags attached to cached responses (e.g., "user:{{.trigger.params.id}}")
+}
+
+// PollConfig makes an HTTP trigger a long-polling change feed. The workflow
+// re-runs every interval until its response differs from the client's cursor,
+// a hash of the response it last received, or the timeout elapses.
+type PollConfig struct {
+	IntervalMs  int    `yaml:"interval_ms,omitempty"`  // Delay between executions (0 = 1000)
+	TimeoutSec  int    `yaml:"timeout_sec,omitempty"`  // How long to wait for a change before answering 304 (0 = 30)
+	CursorParam string `yaml:"cursor_param,omitempty"` // Query parameter carrying the cursor (default "cursor"); If-None-Match works too
+}
+
+// IdempotencyConfig makes a write trigger honour the Idempotency-Key header.
+// The first response for a key is stored in a database table and replayed to
+// retries with the same key instead of running the workflow again.
+type IdempotencyConfig struct {
+	Database string `yaml:"database"`           // Database holding the table
+	Table    string `yaml:"table,omitempty"`    // Default "idempotency_keys"
+	TTLSec   int    `yaml:"ttl_sec,omitempty"`  // How long a response is replayed (0 = 86400)
+	Required bool   `yaml:"required,omitempty"` // Reject requests without the header with 400
+}
+
+// EnvelopeConfig shapes the JSON bodies sql-proxy writes itself: errors, the
+// default success response, and the responses of generated CRUD workflows.
+// Keys set to "-" are left out.
+type EnvelopeConfig struct {
+	Success   string `yaml:"success,omitempty"`    // Success flag key (default "success")
+	Data      string `yaml:"data,omitempty"`       // Data key (default "data")
+	Error     string `yaml:"error,omitempty"`      // Error message key (default "error")
+	Code      string `yaml:"code,omitempty"`       // Error code key (default "code")
+	Count     string `yaml:"count,omitempty"`      // Row count key of generated list responses (default "count")
+	RequestID string `yaml:"request_id,omitempty"` // Request ID key (default "request_id")
+	Meta      string `yaml:"meta,omitempty"`       // Nest the success flag, count, and request ID under this key
+	Flat      bool   `yaml:"flat,omitempty"`       // Success responses are the bare data; errors keep the envelope
+}
+
+// StatusMapping is the response to an error class in status_map.
+type StatusMapping struct {
+	Status  int    `yaml:"status,omitempty"`  // HTTP status (default: the built-in one)
+	Message string `yaml:"message,omitempty"` // Error message (default: the built-in one)
+}
+
+// AlertConfig watches a workflow's failure rate and p95 latency over a sliding
+// window. The alert fires when either exceeds its threshold and resolves when
+// both are back under, sending the notify step each time.
+type AlertConfig struct {
+	Name          string      `yaml:"name"`           // Required, unique
+	Workflow      string      `yaml:"workflow"`       // Required: name of the watched workflow
+	WindowSec     int         `yaml:"window_sec"`     // Sliding window (default: 300)
+	MinExecutions int         `yaml:"min_executions"` // Executions in the window before thresholds apply (default: 10)
+	FailureRate   float64     `yaml:"failure_rate"`   // Fire above this fraction of failed executions (0-1)
+	P95LatencyMs  int64       `yaml:"p95_latency_ms"` // Fire above this p95 duration
+	Notify        *StepConfig `yaml:"notify"`         // Notify step sent on firing and resolving (type may be omitted)
+}
+
+// FieldsConfig lets clients of an HTTP trigger request only the columns they
+// need with ?fields=a,b,c. Only allowed columns can be requested; without the
+// parameter the rows are returned whole.
+type FieldsConfig struct {
+	Allowed []string `yaml:"allowed"` // Columns clients may request
+	Steps   []string `yaml:"steps"`   // Query steps whose rows are trimmed
+}
+
+// RewriteConfig mutates an HTTP trigger's requests before the body schema and
+// parameters are checked, in the order of its fields. Templates and the debug
+// log see the rewritten request.
+type RewriteConfig struct {
+	RemoveHeaders []string          `yaml:"remove_headers,omitempty"` // Headers dropped from the request
+	SetHeaders    map[string]string `yaml:"set_headers,omitempty"`    // Headers set on the request, replacing the client's
+	DefaultQuery  map[string]string `yaml:"default_query,omitempty"`  // Query parameters added when the client sent none
+	PathParams    []PathParamConfig `yaml:"path_params,omitempty"`    // Parameters extracted from the request path
+}
+
+// PathParamConfig extracts a parameter from the request path with a regex. The
+// value is the group named after the parameter, or else the first group; a
+// path the pattern doesn't match leaves the parameter unset.
+type PathParamConfig struct {
+	Name    string `yaml:"name"`    // Declared parameter the value fills, taking precedence like a {path} parameter
+	Pattern string `yaml:"pattern"` // Regex matched against the request path
+}
+
+// DebugLogConfig selects the payloads an HTTP trigger writes to the debug log.
+// Payloads pass through logging.redact first and are only written when the log
+// level is debug.
+type DebugLogConfig struct {
+	Request  bool `yaml:"request,omitempty"`  // Method, path, query, headers, and body of each request
+	Response bool `yaml:"response,omitempty"` // Status, headers, and body of each response
 }
 
 // StepConfig defines a single step or block in a workflow.
@@ -79,10 +354,11 @@ type StepConfig struct {
 	Name      string `yaml:"name,omitempty"`
 	Disabled  bool   `yaml:"disabled,omitempty"`
 	Condition string `yaml:"condition,omitempty"`
-	OnError   string `yaml:"on_error,omitempty"` // "abort" | "continue"
+	OnError   string `yaml:"on_error,omitempty"` // "abort" | "continue" | "fallback"
+	Fallback  string `yaml:"fallback,omitempty"` // Template rendering JSON data to use when on_error is "fallback"
 
 	// Step type (leaf steps only; blocks have steps: instead)
-	Type string `yaml:"type,omitempty"` // "query" | "httpcall" | "response"
+	Type string `yaml:"type,omitempty"` // "query" | "httpcall" | "response" | "response_sse" | "redirect" | "page" | "cache_invalidate" | "set" | "script" | "email" | "notify" | "storage" | "sftp" | "bulk_insert" | "mqtt" | "metric"
 
 	// Caching for query and httpcall steps
 	Cache *StepCacheConfig `yaml:"cache,omitempty"`
@@ -93,12 +369,26 @@ type StepConfig struct {
 	Params map[string]string `yaml:"params,omitempty"`
 
 	// Query step fields
-	Database         string   `yaml:"database,omitempty"`
-	SQL              string   `yaml:"sql,omitempty"`
-	Isolation        string   `yaml:"isolation,omitempty"`
-	LockTimeoutMs    *int     `yaml:"lock_timeout_ms,omitempty"`
-	DeadlockPriority string   `yaml:"deadlock_priority,omitempty"`
-	JSONColumns      []string `yaml:"json_columns,omitempty"`
+	Database         string              `yaml:"database,omitempty"`
+	SQL              string              `yaml:"sql,omitempty"`
+	SQLFile          string              `yaml:"sql_file,omitempty"` // Path to a .sql file, read into SQL by config.Load
+	Isolation        string              `yaml:"isolation,omitempty"`
+	LockTimeoutMs    *int                `yaml:"lock_timeout_ms,omitempty"`
+	DeadlockPriority string              `yaml:"deadlock_priority,omitempty"`
+	JSONColumns      []string            `yaml:"json_columns,omitempty"`
+	Transform        *TransformConfig    `yaml:"transform,omitempty"`
+	Proc             *ProcConfig         `yaml:"proc,omitempty"`               // Stored procedure to call instead of sql
+	ResultSets       []string            `yaml:"result_sets,omitempty"`        // Names for the result sets, in order
+	MaxRows          int                 `yaml:"max_rows,omitempty"`           // Rows to read before on_limit applies (0 = unlimited)
+	MaxResponseBytes int                 `yaml:"max_response_bytes,omitempty"` // Approximate JSON size of the rows before on_limit applies (0 = unlimited)
+	OnLimit          string              `yaml:"on_limit,omitempty"`           // "error" (default) | "truncate" | "paginate"
+	PageOffset       string              `yaml:"page_offset,omitempty"`        // Template for the rows to skip when on_limit is "paginate"
+	PublicIDColumns  map[string]string   `yaml:"public_id_columns,omitempty"`  // Column -> public ID namespace; same-named SQL params are decoded
+	MaskColumns      map[string]string   `yaml:"mask_columns,omitempty"`       // Column -> mask strategy: "full" | "partial" | "hash" | "last4"
+	UnmaskWhen       string              `yaml:"unmask_when,omitempty"`        // Condition under which mask_columns are returned unmasked (e.g., admin callers)
+	IncludeColumns   []string            `yaml:"include_columns,omitempty"`    // Column patterns the results keep (* and ? wildcards); others are removed
+	ExcludeColumns   []string            `yaml:"exclude_columns,omitempty"`    // Column patterns removed from the results
+	Outbox           []OutboxEventConfig `yaml:"outbox,omitempty"`             // Events inserted into the database's outbox in the statement's transaction
 
 	// HTTPCall step fields
 	URL        string            `yaml:"url,omitempty"`
@@ -109,9 +399,73 @@ type StepConfig struct {
 	TimeoutSec int               `yaml:"timeout_sec,omitempty"`
 	Retry      *RetryConfig      `yaml:"retry,omitempty"`
 
-	// Response step fields
-	StatusCode int    `yaml:"status_code,omitempty"`
-	Template   string `yaml:"template,omitempty"`
+	// Response and page step fields (page steps are always html)
+	StatusCode   int    `yaml:"status_code,omitempty"`
+	Template     string `yaml:"template,omitempty"`
+	TemplateFile string `yaml:"template_file,omitempty"` // Path to a template file, read into Template by config.Load
+	TemplateType string `yaml:"template_type,omitempty"` // "text" (default, JSON) or "html" (html/template escaping, text/html)
+	Schema       any    `yaml:"schema,omitempty"`        // JSON Schema for the rendered body, enforced when server.strict_responses is on
+
+	// Redirect step fields (also uses status_code and headers)
+	Location string `yaml:"location,omitempty"` // Template for the Location header: an absolute URL or a path
+
+	// Response SSE step fields (also uses template, or source and batch_size)
+	Event string `yaml:"event,omitempty"` // SSE event name (default "message")
+
+	// Cache invalidate step fields
+	Tags []string `yaml:"tags,omitempty"` // Templates for cache tags to invalidate
+
+	// Set step fields: each value is an expression, or a template if it contains {{
+	Values map[string]string `yaml:"values,omitempty"`
+
+	// Script step fields
+	Script   string `yaml:"script,omitempty"`    // JavaScript function body with trigger, steps and vars in scope; returns the result
+	MemoryMB int    `yaml:"memory_mb,omitempty"` // Heap the script may grow while it runs (0 = DefaultScriptMemoryMB)
+
+	// Email step fields (all support templates; recipient entries may be comma-separated)
+	From    string   `yaml:"from,omitempty"` // Overrides smtp.from
+	To      []string `yaml:"to,omitempty"`
+	Cc      []string `yaml:"cc,omitempty"`
+	Bcc     []string `yaml:"bcc,omitempty"`
+	Subject string   `yaml:"subject,omitempty"`
+	Text    string   `yaml:"text,omitempty"` // Plain text body
+	HTML    string   `yaml:"html,omitempty"` // HTML body (sent as multipart/alternative with text)
+
+	// Notify step fields (also uses url, headers and text; all support templates)
+	Provider string `yaml:"provider,omitempty"` // "slack" | "teams" | "webhook"
+	Title    string `yaml:"title,omitempty"`
+	Blocks   string `yaml:"blocks,omitempty"` // JSON array of provider-specific blocks (Slack blocks, Adaptive Card elements)
+	When     string `yaml:"when,omitempty"`   // "always" (default) | "failure" | "success"
+
+	// Storage step fields (bucket and key support templates; content comes from body or source)
+	Store       string   `yaml:"store,omitempty"`        // Name of a target in the top-level storage section
+	Bucket      string   `yaml:"bucket,omitempty"`       // Bucket name
+	Key         string   `yaml:"key,omitempty"`          // Object key
+	Source      string   `yaml:"source,omitempty"`       // Expression whose value is serialized per format (instead of body)
+	Format      string   `yaml:"format,omitempty"`       // "json" (default) | "ndjson" | "csv"; only with source
+	Columns     []string `yaml:"columns,omitempty"`      // CSV column order (default: sorted column names)
+	ContentType string   `yaml:"content_type,omitempty"` // Default: from format, or from the key's extension for body
+	Encryption  string   `yaml:"encryption,omitempty"`   // Server-side encryption: "AES256" | "aws:kms"
+	KMSKeyID    string   `yaml:"kms_key_id,omitempty"`   // KMS key for aws:kms (default: the bucket's key)
+
+	// SFTP step fields (also uses body or source, format, columns and retry; remote_path supports templates)
+	Server     string `yaml:"server,omitempty"`      // Name of a server in the top-level sftp section
+	RemotePath string `yaml:"remote_path,omitempty"` // File to write (replaced if it exists)
+	CreateDirs bool   `yaml:"create_dirs,omitempty"` // Create missing parent directories
+
+	// MQTT step fields (also uses body or source, format and columns; topic supports templates)
+	Broker string `yaml:"broker,omitempty"` // Name of a broker in the top-level mqtt section
+	Topic  string `yaml:"topic,omitempty"`  // Topic to publish to (no wildcards)
+	QoS    int    `yaml:"qos,omitempty"`    // 0 (default) or 1 (wait for the broker's acknowledgement)
+	Retain bool   `yaml:"retain,omitempty"` // Broker keeps the message for future subscribers
+
+	// Bulk insert step fields (also uses database, source and columns)
+	Table        string `yaml:"table,omitempty"`          // Target table, optionally schema-qualified
+	BatchSize    int    `yaml:"batch_size,omitempty"`     // Rows per INSERT statement (default 500)
+	OnBatchError string `yaml:"on_batch_error,omitempty"` // "abort" (default) | "continue"
+
+	// Metric step fields
+	Metric *MetricConfig `yaml:"metric,omitempty"` // Prometheus metric to record
 
 	// Block fields (steps with nested steps)
 	Iterate *IterateConfig    `yaml:"iterate,omitempty"`
@@ -123,8 +477,52 @@ type StepConfig struct {
 // StepCacheConfig defines caching for query and httpcall steps.
 // Cache key can reference request params and previous step results.
 type StepCacheConfig struct {
-	Key    string `yaml:"key"`               // Template for cache key (e.g., "user:{{.trigger.params.id}}" or "query:{{.steps.auth.data.user_id}}")
-	TTLSec int    `yaml:"ttl_sec,omitempty"` // TTL in seconds (0 = use server default)
+	Key    string   `yaml:"key"`               // Template for cache key (e.g., "user:{{.trigger.params.id}}" or "query:{{.steps.auth.data.user_id}}")
+	TTLSec int      `yaml:"ttl_sec,omitempty"` // TTL in seconds (0 = use server default)
+	Tags   []string `yaml:"tags,omitempty"`    // Templates for tags attached to the cached result
+}
+
+// TransformConfig post-processes query rows before they are stored in the step result.
+// Operations run in field order: parse_json, rename, exclude, group_by/nest_as.
+type TransformConfig struct {
+	ParseJSON []string          `yaml:"parse_json,omitempty"` // Columns holding JSON text to decode
+	Rename    map[string]string `yaml:"rename,omitempty"`     // Column -> new name
+	Exclude   []string          `yaml:"exclude,omitempty"`    // Columns to drop (after rename)
+	GroupBy   string            `yaml:"group_by,omitempty"`   // Column whose equal values are grouped into one row
+	NestAs    string            `yaml:"nest_as,omitempty"`    // Field holding each group's rows
+}
+
+// OutboxEventConfig is an event a query step records in its database's outbox.
+// All fields are templates.
+type OutboxEventConfig struct {
+	Topic   string `yaml:"topic"`             // Required: Kafka topic, AMQP routing key, or X-Outbox-Topic of webhooks
+	Key     string `yaml:"key,omitempty"`     // Events with the same key keep their order on one Kafka partition
+	Payload string `yaml:"payload,omitempty"` // Event body, usually JSON (default {})
+}
+
+// MetricConfig is the Prometheus metric a metric step records. Steps that share
+// a name record into one metric, so they must agree on its type and labels.
+type MetricConfig struct {
+	Name    string            `yaml:"name"`              // Metric name; the sqlproxy_, go_ and process_ prefixes are reserved
+	Type    string            `yaml:"type"`              // "gauge" | "counter" | "histogram"
+	Help    string            `yaml:"help,omitempty"`    // Description shown by /metrics
+	Value   string            `yaml:"value,omitempty"`   // Expression for the number to record (counters default to 1)
+	Labels  map[string]string `yaml:"labels,omitempty"`  // Label name -> template
+	Buckets []float64         `yaml:"buckets,omitempty"` // Histogram buckets (default: the Prometheus defaults)
+}
+
+// ProcConfig calls a stored procedure from a query step.
+type ProcConfig struct {
+	Name   string            `yaml:"name"`             // Procedure name, optionally schema-qualified (e.g., dbo.PlaceOrder)
+	Params []ProcParamConfig `yaml:"params,omitempty"` // In declaration order (MySQL binds positionally)
+}
+
+// ProcParamConfig declares a stored procedure parameter.
+type ProcParamConfig struct {
+	Name      string `yaml:"name"`                // Parameter name without the leading @
+	Direction string `yaml:"direction,omitempty"` // "in" (default) | "out" | "inout"
+	Type      string `yaml:"type,omitempty"`      // Required for out and inout: "int" | "float" | "decimal" | "string" | "bool"
+	Value     string `yaml:"value,omitempty"`     // Expression, or template if it contains {{; default: looked up by name like @params
 }
 
 // IterateConfig defines iteration over a collection.
@@ -134,7 +532,7 @@ type IterateConfig struct {
 	OnError string `yaml:"on_error"` // "abort" | "continue" | "skip"
 }
 
-// RetryConfig defines retry behavior for httpcall steps.
+// RetryConfig defines retry behavior for httpcall and sftp steps.
 type RetryConfig struct {
 	Enabled           bool `yaml:"enabled"`
 	MaxAttempts       int  `yaml:"max_attempts,omitempty"`
@@ -150,7 +548,7 @@ func (s *StepConfig) IsBlock() bool {
 
 // IsQuery returns true if this step is a query step.
 func (s *StepConfig) IsQuery() bool {
-	return s.Type == "query" || (s.Type == "" && s.SQL != "")
+	return s.Type == "query" || (s.Type == "" && (s.SQL != "" || s.Proc != nil))
 }
 
 // IsHTTPCall returns true if this step is an httpcall step.
@@ -158,9 +556,29 @@ func (s *StepConfig) IsHTTPCall() bool {
 	return s.Type == "httpcall" || (s.Type == "" && s.URL != "")
 }
 
-// IsResponse returns true if this step is a response step.
+// IsResponse returns true if this step is a response, redirect or page step.
 func (s *StepConfig) IsResponse() bool {
-	return s.Type == "response"
+	return s.Type == "response" || s.Type == "redirect" || s.Type == "page"
+}
+
+// IsResponseSSE returns true if this step is a response_sse step.
+func (s *StepConfig) IsResponseSSE() bool {
+	return s.Type == "response_sse"
+}
+
+// IsCacheInvalidate returns true if this step is a cache_invalidate step.
+func (s *StepConfig) IsCacheInvalidate() bool {
+	return s.Type == "cache_invalidate"
+}
+
+// IsNotify returns true if this step is a notify step.
+func (s *StepConfig) IsNotify() bool {
+	return s.Type == "notify"
+}
+
+// IsSet returns true if this step is a set step.
+func (s *StepConfig) IsSet() bool {
+	return s.Type == "set" || (s.Type == "" && len(s.Values) > 0 && !s.IsBlock())
 }
 
 // StepType returns the resolved step type.
@@ -171,7 +589,7 @@ func (s *StepConfig) StepType() string {
 	if s.Type != "" {
 		return s.Type
 	}
-	if s.SQL != "" {
+	if s.SQL != "" || s.SQLFile != "" || s.Proc != nil {
 		return StepTypeQuery
 	}
 	if s.URL != "" {
@@ -180,26 +598,143 @@ func (s *StepConfig) StepType() string {
 	if s.Template != "" {
 		return StepTypeResponse
 	}
+	if len(s.Values) > 0 {
+		return StepTypeSet
+	}
+	if s.Script != "" {
+		return StepTypeScript
+	}
 	return StepTypeUnknown
 }
 
 // Valid step types
 var ValidStepTypes = map[string]bool{
-	"query":    true,
-	"httpcall": true,
-	"response": true,
+	"query":            true,
+	"httpcall":         true,
+	"response":         true,
+	"response_sse":     true,
+	"redirect":         true,
+	"page":             true,
+	"cache_invalidate": true,
+	"set":              true,
+	"script":           true,
+	"email":            true,
+	"notify":           true,
+	"storage":          true,
+	"sftp":             true,
+	"bulk_insert":      true,
+	"mqtt":             true,
+	"metric":           true,
 }
 
 // Valid trigger types
 var ValidTriggerTypes = map[string]bool{
-	"http": true,
-	"cron": true,
+	"http":      true,
+	"websocket": true,
+	"cron":      true,
+	"dbwatch":   true,
+	"filewatch": true,
+	"mqtt":      true,
+	"grpc":      true,
+}
+
+// Request stages a trigger's middlewares list orders
+const (
+	MiddlewareRateLimit = "rate_limit"
+	MiddlewareCache     = "cache"
+)
+
+// SunsetTime parses sunset: a date is midnight UTC of that day
+func (t *TriggerConfig) SunsetTime() (time.Time, error) {
+	if d, err := time.Parse(time.DateOnly, t.Sunset); err == nil {
+		return d, nil
+	}
+	return time.Parse(time.RFC3339, t.Sunset)
+}
+
+// DefaultMiddlewares is the stage order of triggers without a middlewares list
+var DefaultMiddlewares = []string{MiddlewareRateLimit, MiddlewareCache}
+
+// RequestMiddlewares returns the stages the trigger's requests pass through, in order.
+func (t *TriggerConfig) RequestMiddlewares() []string {
+	if t.Middlewares == nil {
+		return DefaultMiddlewares
+	}
+	return t.Middlewares
 }
 
+// Column types of dbwatch triggers
+const (
+	WatchColumnTimestamp  = "timestamp"
+	WatchColumnInt        = "int"
+	WatchColumnRowVersion = "rowversion"
+)
+
 // Valid on_error values
 var ValidOnErrorValues = map[string]bool{
 	"abort":    true,
 	"continue": true,
+	"fallback": true,
+}
+
+// Valid on_limit values for query steps
+var ValidOnLimitValues = map[string]bool{
+	"error":    true,
+	"truncate": true,
+	"paginate": true,
+	"":         true, // Default to error
+}
+
+// Valid mask_columns strategies for query steps
+var ValidMaskStrategies = map[string]bool{
+	MaskFull:    true,
+	MaskPartial: true,
+	MaskHash:    true,
+	MaskLast4:   true,
+}
+
+// Valid template_type values for response steps
+var ValidTemplateTypes = map[string]bool{
+	"text": true,
+	"html": true,
+	"":     true, // Default to text
+}
+
+// Valid notify providers
+var ValidNotifyProviders = map[string]bool{
+	"slack":   true,
+	"teams":   true,
+	"webhook": true,
+}
+
+// Valid notify when values
+var ValidNotifyWhen = map[string]bool{
+	"always":  true,
+	"failure": true,
+	"success": true,
+	"":        true, // Default to always
+}
+
+// Valid storage formats
+var ValidStorageFormats = map[string]bool{
+	"json":   true,
+	"ndjson": true,
+	"csv":    true,
+	"":       true, // Default to json
+}
+
+// Valid bulk insert on_batch_error values
+var ValidBatchErrorPolicies = map[string]bool{
+	"abort":    true,
+	"continue": true,
+	"":         true, // Default to abort
+}
+
+// Valid storage encryption values
+var ValidStorageEncryptions = map[string]bool{
+	"AES256":  true,
+	"aws:kms": true,
+	"":        true, // No server-side encryption header
 }
 
 // Valid iterate on_error values
@@ -209,6 +744,23 @@ var ValidIterateOnErrorValues = map[string]bool{
 	"skip":     true,
 }
 
+// Valid stored procedure parameter directions
+var ValidProcDirections = map[string]bool{
+	"in":    true,
+	"out":   true,
+	"inout": true,
+	"":      true, // Default to in
+}
+
+// Valid stored procedure output parameter types
+var ValidProcTypes = map[string]bool{
+	"int":     true,
+	"float":   true,
+	"decimal": true,
+	"string":  true,
+	"bool":    true,
+}
+
 // Valid parse modes for httpcall
 var ValidParseModes = map[string]bool{
 	"json": true,
@@ -1,6 +1,7 @@
 package workflow
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -40,6 +41,11 @@ func TestStepConfig_StepType(t *testing.T) {
 			step:     StepConfig{Template: `{"status": "ok"}`},
 			expected: "response",
 		},
+		{
+			name:     "implicit set from Values",
+			step:     StepConfig{Values: map[string]string{"total": "1 + 2"}},
+			expected: "set",
+		},
 		{
 			name:     "block from nested Steps",
 			step:     StepConfig{Steps: []StepConfig{{SQL: "SELECT 1"}}},
@@ -223,3 +229,99 @@ func TestRateLimitRefConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestTriggerConfig_Expand(t *testing.T) {
+	t.Run("single route unchanged", func(t *testing.T) {
+		trig := TriggerConfig{Type: "http", Path: "/test", Method: "GET"}
+		routes := trig.Expand()
+		if len(routes) != 1 || routes[0].Method != "GET" || routes[0].Path != "/test" {
+			t.Errorf("unexpected routes: %+v", routes)
+		}
+	})
+
+	t.Run("cron unchanged", func(t *testing.T) {
+		trig := TriggerConfig{Type: "cron", Schedule: "0 * * * *"}
+		if routes := trig.Expand(); len(routes) != 1 || routes[0].Schedule != "0 * * * *" {
+			t.Errorf("unexpected routes: %+v", routes)
+		}
+	})
+
+	t.Run("methods and paths", func(t *testing.T) {
+		trig := TriggerConfig{
+			Type:       "http",
+			Paths:      []string{"/a", "/b"},
+			Methods:    []string{"GET", "POST"},
+			Parameters: []ParamConfig{{Name: "id"}},
+		}
+		routes := trig.Expand()
+
+		var got []string
+		for _, r := range routes {
+			got = append(got, r.Method+" "+r.Path)
+			if r.Methods != nil || r.Paths != nil {
+				t.Errorf("expanded route should not keep lists: %+v", r)
+			}
+			if len(r.Parameters) != 1 {
+				t.Errorf("expanded route should keep parameters: %+v", r)
+			}
+		}
+		want := []string{"GET /a", "POST /a", "GET /b", "POST /b"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expand() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("methods with single path", func(t *testing.T) {
+		trig := TriggerConfig{Type: "http", Path: "/a", Methods: []string{"PUT", "PATCH"}}
+		routes := trig.Expand()
+		if len(routes) != 2 || routes[0].Method != "PUT" || routes[1].Method != "PATCH" {
+			t.Errorf("unexpected routes: %+v", routes)
+		}
+	})
+}
+
+func TestWorkflowConfig_PreloadRoute(t *testing.T) {
+	cached := &CacheConfig{Enabled: true, Key: "k"}
+	wf := WorkflowConfig{Triggers: []TriggerConfig{
+		{Type: "cron", Schedule: "* * * * *"},
+		{Type: "http", Path: "/uncached", Method: "GET"},
+		{Type: "http", Paths: []string{"/a", "/b"}, Methods: []string{"POST", "GET"}, Cache: cached},
+	}}
+	if route := wf.PreloadRoute(); route == nil || route.Method != "GET" || route.Path != "/a" {
+		t.Errorf("PreloadRoute() = %+v, want GET /a", route)
+	}
+
+	wf.Triggers = wf.Triggers[:2]
+	if route := wf.PreloadRoute(); route != nil {
+		t.Errorf("PreloadRoute() = %+v, want nil without a cached trigger", route)
+	}
+}
+
+func TestPreloadTarget(t *testing.T) {
+	route := &TriggerConfig{Path: "/api/{region}/sales"}
+	tests := []struct {
+		params  map[string]string
+		want    string
+		wantErr string
+	}{
+		{params: map[string]string{"region": "eu west"}, want: "/api/eu%20west/sales"},
+		{params: map[string]string{"region": "eu", "days": "7", "top": "10"}, want: "/api/eu/sales?days=7&top=10"},
+		{params: map[string]string{"days": "7"}, wantErr: "missing path parameter 'region'"},
+	}
+	for _, tt := range tests {
+		got, err := PreloadTarget(route, tt.params)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("PreloadTarget(%v) error = %v, want %q", tt.params, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("PreloadTarget(%v) = %q, %v; want %q", tt.params, got, err, tt.want)
+		}
+	}
+
+	if got, _ := PreloadTarget(&TriggerConfig{Path: "/api/dashboard"}, nil); got != "/api/dashboard" {
+		t.Errorf("PreloadTarget without params = %q", got)
+	}
+}
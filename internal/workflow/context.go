@@ -2,8 +2,10 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"sync"
+	"text/template"
 	"time"
 
 	"sql-proxy/internal/workflow/step"
@@ -19,6 +21,10 @@ type Context struct {
 	Logger    Logger
 	Variables map[string]string // Global variables from config
 
+	// First failure of the run, exposed as workflow.failed_step and workflow.error
+	failedStep string
+	failure    error
+
 	mu  sync.RWMutex
 	ctx context.Context
 }
@@ -28,7 +34,9 @@ type Logger = step.Logger
 
 // TriggerData contains input data from the trigger.
 type TriggerData struct {
-	Type string // "http" | "cron"
+	Type     string // "http" | "websocket" | "cron" | "dbwatch" | "filewatch" | "mqtt" | "grpc"
+	Lang     string // Message catalog language: negotiated from Accept-Language for HTTP, else the default
+	Priority string // The trigger's priority; "" is the default for its type (see DefaultPriority)
 
 	// HTTP trigger data
 	Params   map[string]any // Query/body parameters
@@ -37,6 +45,10 @@ type TriggerData struct {
 	ClientIP string
 	Method   string
 	Path     string
+	OrderBy  string // ORDER BY fragment from ?sort=, for triggers with sortable_columns
+	Where    string // WHERE fragment from ?filter[col]=, for triggers with filterable_columns
+
+	ResponseHeaders map[string]*template.Template // The trigger's compiled response_headers
 
 	// Cron trigger data
 	ScheduleTime time.Time
@@ -46,23 +58,39 @@ type TriggerData struct {
 // StepResult contains the result of executing a step.
 type StepResult struct {
 	Name       string
-	Type       string // "query" | "httpcall" | "response" | "block"
+	Type       string // "query" | "httpcall" | "response" | "redirect" | "page" | "cache_invalidate" | "set" | "script" | "email" | "notify" | "storage" | "sftp" | "bulk_insert" | "mqtt" | "metric" | "block"
 	Success    bool
 	Error      error
+	TimedOut   bool      // True if the step failed because its timeout_sec or the workflow timeout expired
 	StartTime  time.Time // Currently unused - reserved for future per-step timing
 	DurationMs int64
 	CacheHit   bool // True if result came from cache
+	Fallback   bool // True if Data came from the step's fallback template after a failure
 
 	// Query results
 	Data         []map[string]any
 	Count        int
-	RowsAffected int64 // For INSERT/UPDATE/DELETE operations
+	RowsAffected int64                       // For INSERT/UPDATE/DELETE operations
+	DataSets     [][]map[string]any          // Every result set in order (Data is the first)
+	Sets         map[string][]map[string]any // Result sets named by result_sets
+	Truncated    bool                        // Reading stopped at the step's result limit (on_limit: truncate or paginate)
+	NextOffset   *int                        // page_offset of the next page, when on_limit is "paginate" and rows remain
+
+	// Stored procedure results (query steps with proc:)
+	OutParams  map[string]any // Out and inout parameter values
+	ReturnCode *int64         // Return status, when the database reports one
 
 	// HTTPCall results
 	StatusCode   int
 	Headers      http.Header
 	ResponseBody string
 
+	// Set results; also the upload details of storage and sftp steps and the totals of bulk_insert steps
+	Values map[string]any
+
+	// Script results
+	Output any
+
 	// Block results
 	Iterations   []*IterationResult
 	SuccessCount int
@@ -98,6 +126,31 @@ func (c *Context) SetStepResult(name string, result *StepResult) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.Steps[name] = result
+	if !result.Success && c.failure == nil {
+		c.failedStep = name
+		c.failure = result.Error
+		if c.failure == nil {
+			c.failure = errors.New("step failed")
+		}
+	}
+}
+
+// RecordFailure records a failure that has no step result, such as the workflow timing out.
+// Only the first failure of a run is kept.
+func (c *Context) RecordFailure(stepName string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failure == nil {
+		c.failedStep = stepName
+		c.failure = err
+	}
+}
+
+// Failed reports whether any step has failed so far.
+func (c *Context) Failed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.failure != nil
 }
 
 // BuildExprEnv builds the environment map for expr evaluation.
@@ -119,12 +172,17 @@ func (c *Context) BuildExprEnv() map[string]any {
 	trigger := make(map[string]any)
 	trigger["type"] = c.Trigger.Type
 	trigger["params"] = c.Trigger.Params
-	if c.Trigger.Type == "http" {
+	trigger["lang"] = c.Trigger.Lang
+	if c.Trigger.Type == "http" || c.Trigger.Type == "websocket" || c.Trigger.Type == "grpc" {
 		trigger["headers"] = headerToMap(c.Trigger.Headers)
 		trigger["cookies"] = c.Trigger.Cookies
 		trigger["client_ip"] = c.Trigger.ClientIP
 		trigger["method"] = c.Trigger.Method
 		trigger["path"] = c.Trigger.Path
+		if c.Trigger.Where != "" {
+			trigger["order_by"] = c.Trigger.OrderBy
+			trigger["where"] = c.Trigger.Where
+		}
 	} else {
 		trigger["schedule_time"] = c.Trigger.ScheduleTime
 		trigger["cron"] = c.Trigger.CronExpr
@@ -136,6 +194,12 @@ func (c *Context) BuildExprEnv() map[string]any {
 	workflow["name"] = c.Workflow.Config.Name
 	workflow["start_time"] = c.StartTime
 	workflow["request_id"] = c.RequestID
+	workflow["failed"] = c.failure != nil
+	if c.failure != nil {
+		workflow["failed_step"] = c.failedStep
+		workflow["error"] = c.failure.Error()
+		workflow["error_code"] = errorCode(c.failure)
+	}
 	env["workflow"] = workflow
 
 	// Add global variables
@@ -178,6 +242,26 @@ func addConvenienceShortcuts(m map[string]any, data []map[string]any, count int)
 	m["many"] = count > 1
 }
 
+// dataSets returns a query step's result sets, falling back to its data alone for results
+// that carry no sets (cache hits and fallbacks). Sets are never nil.
+func dataSets(r *StepResult) [][]map[string]any {
+	if len(r.DataSets) == 0 {
+		return [][]map[string]any{nonNilRows(r.Data)}
+	}
+	sets := make([][]map[string]any, len(r.DataSets))
+	for i, set := range r.DataSets {
+		sets[i] = nonNilRows(set)
+	}
+	return sets
+}
+
+func nonNilRows(rows []map[string]any) []map[string]any {
+	if rows == nil {
+		return []map[string]any{}
+	}
+	return rows
+}
+
 func stepResultToMap(r *StepResult) map[string]any {
 	m := map[string]any{
 		"name":        r.Name,
@@ -185,10 +269,13 @@ func stepResultToMap(r *StepResult) map[string]any {
 		"success":     r.Success,
 		"duration_ms": r.DurationMs,
 		"cache_hit":   r.CacheHit,
+		"timed_out":   r.TimedOut,
+		"fallback":    r.Fallback,
 	}
 
 	if r.Error != nil {
 		m["error"] = r.Error.Error()
+		m["error_code"] = errorCode(r.Error)
 	}
 
 	// Query data - always set count for query steps (even if data is nil/empty)
@@ -201,6 +288,44 @@ func stepResultToMap(r *StepResult) map[string]any {
 		m["count"] = r.Count
 		m["rows_affected"] = r.RowsAffected
 		addConvenienceShortcuts(m, r.Data, r.Count)
+		m["data_sets"] = dataSets(r)
+		if r.OutParams != nil {
+			m["output"] = r.OutParams
+		}
+		if r.ReturnCode != nil {
+			m["return_code"] = *r.ReturnCode
+		}
+		if r.Sets != nil {
+			m["sets"] = r.Sets
+		}
+		m["truncated"] = r.Truncated
+		if r.NextOffset != nil {
+			m["next_offset"] = *r.NextOffset
+		}
+	}
+
+	// Cache invalidate data - count is the number of entries removed; email - the number of recipients
+	if r.Type == "cache_invalidate" || r.Type == "email" {
+		m["count"] = r.Count
+	}
+
+	// Set values (and storage/sftp upload, bulk insert, mqtt publish, metric and redirect details) are exposed directly as steps.<name>.<value>
+	if r.Type == "set" || r.Type == "storage" || r.Type == "sftp" || r.Type == "bulk_insert" || r.Type == "mqtt" || r.Type == "metric" || r.Type == "redirect" {
+		for k, v := range r.Values {
+			m[k] = v
+		}
+	}
+
+	// Script output, plus data/count when it is an object or list of objects
+	if r.Type == "script" {
+		m["result"] = r.Output
+		if r.Data != nil {
+			m["data"] = r.Data
+		} else {
+			m["data"] = []map[string]any{}
+		}
+		m["count"] = r.Count
+		addConvenienceShortcuts(m, r.Data, r.Count)
 	}
 
 	// HTTPCall data
@@ -219,6 +344,11 @@ func stepResultToMap(r *StepResult) map[string]any {
 		addConvenienceShortcuts(m, r.Data, r.Count)
 	}
 
+	// Notify steps expose only the webhook's status code
+	if r.Type == "notify" {
+		m["status_code"] = r.StatusCode
+	}
+
 	// Block data
 	if r.Type == "block" {
 		m["success_count"] = r.SuccessCount
@@ -2,7 +2,9 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -139,6 +141,29 @@ func TestContext_BuildExprEnv_HTTPTrigger(t *testing.T) {
 	}
 }
 
+func TestContext_BuildExprEnv_Failure(t *testing.T) {
+	wf := &CompiledWorkflow{Config: &WorkflowConfig{Name: "nightly"}}
+	ctx := NewContext(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", &testLogger{}, nil)
+
+	workflow := ctx.BuildExprEnv()["workflow"].(map[string]any)
+	if workflow["failed"] != false || workflow["failed_step"] != nil {
+		t.Errorf("workflow = %v, want not failed", workflow)
+	}
+
+	ctx.SetStepResult("ok", &StepResult{Name: "ok", Success: true})
+	ctx.SetStepResult("load", &StepResult{Name: "load", Error: errors.New("deadlock")})
+	ctx.SetStepResult("save", &StepResult{Name: "save", Error: errors.New("later")})
+	ctx.RecordFailure("", errors.New("ignored"))
+
+	if !ctx.Failed() {
+		t.Error("Failed() = false after a failed step")
+	}
+	workflow = ctx.BuildExprEnv()["workflow"].(map[string]any)
+	if workflow["failed"] != true || workflow["failed_step"] != "load" || workflow["error"] != "deadlock" {
+		t.Errorf("workflow = %v, want the first failure", workflow)
+	}
+}
+
 func TestContext_BuildExprEnv_CronTrigger(t *testing.T) {
 	wf := &CompiledWorkflow{Config: &WorkflowConfig{Name: "scheduled_workflow"}}
 	schedTime := time.Now()
@@ -387,6 +412,42 @@ func TestStepResultToMap(t *testing.T) {
 		}
 	})
 
+	t.Run("query result with result sets", func(t *testing.T) {
+		r := &StepResult{
+			Type:     "query",
+			Success:  true,
+			Data:     []map[string]any{{"id": 1}},
+			Count:    1,
+			DataSets: [][]map[string]any{{{"id": 1}}, nil, {{"sku": "a"}}},
+		}
+		m := stepResultToMap(r)
+
+		sets, ok := m["data_sets"].([][]map[string]any)
+		if !ok || len(sets) != 3 {
+			t.Fatalf("data_sets = %v, want 3 sets", m["data_sets"])
+		}
+		if sets[1] == nil || len(sets[1]) != 0 {
+			t.Errorf("data_sets[1] = %v, want empty non-nil set", sets[1])
+		}
+		if sets[2][0]["sku"] != "a" {
+			t.Errorf("data_sets[2] = %v, want the third set", sets[2])
+		}
+		if _, ok := m["sets"]; ok {
+			t.Error("sets should be absent without result_sets")
+		}
+	})
+
+	t.Run("query result without result sets", func(t *testing.T) {
+		// Cache hits and fallbacks carry only data
+		r := &StepResult{Type: "query", Success: true, CacheHit: true, Data: []map[string]any{{"id": 1}}, Count: 1}
+		m := stepResultToMap(r)
+
+		sets, ok := m["data_sets"].([][]map[string]any)
+		if !ok || len(sets) != 1 || sets[0][0]["id"] != 1 {
+			t.Errorf("data_sets = %v, want [data]", m["data_sets"])
+		}
+	})
+
 	t.Run("query result with multiple rows", func(t *testing.T) {
 		r := &StepResult{
 			Name:       "fetch_all",
@@ -610,6 +671,42 @@ func TestStepResultToMap(t *testing.T) {
 		}
 	})
 
+	t.Run("set result", func(t *testing.T) {
+		r := &StepResult{
+			Name:    "derived",
+			Type:    "set",
+			Success: true,
+			Values:  map[string]any{"limit": 20, "label": "admin"},
+		}
+		m := stepResultToMap(r)
+
+		if m["limit"] != 20 || m["label"] != "admin" {
+			t.Errorf("values not exposed on the step: %v", m)
+		}
+		if _, ok := m["data"]; ok {
+			t.Error("set step should not have data")
+		}
+	})
+
+	t.Run("script result", func(t *testing.T) {
+		r := &StepResult{
+			Name:    "summary",
+			Type:    "script",
+			Success: true,
+			Output:  []any{map[string]any{"customer": "acme"}},
+			Data:    []map[string]any{{"customer": "acme"}},
+			Count:   1,
+		}
+		m := stepResultToMap(r)
+
+		if _, ok := m["result"].([]any); !ok {
+			t.Errorf("result = %T, want the script output", m["result"])
+		}
+		if m["count"] != 1 || m["one"] != true {
+			t.Errorf("count = %v, one = %v; want row shortcuts", m["count"], m["one"])
+		}
+	})
+
 	t.Run("result with error", func(t *testing.T) {
 		r := &StepResult{
 			Name:    "failed_step",
@@ -622,6 +719,46 @@ func TestStepResultToMap(t *testing.T) {
 		if m["error"] != "database error" {
 			t.Errorf("error = %v, want 'database error'", m["error"])
 		}
+		if m["timed_out"] != false {
+			t.Errorf("timed_out = %v, want false", m["timed_out"])
+		}
+		if m["fallback"] != false {
+			t.Errorf("fallback = %v, want false", m["fallback"])
+		}
+	})
+
+	t.Run("result with fallback data", func(t *testing.T) {
+		r := &StepResult{
+			Name:     "enrich",
+			Type:     "query",
+			Success:  false,
+			Error:    testError{msg: "database error"},
+			Fallback: true,
+			Data:     []map[string]any{},
+		}
+		m := stepResultToMap(r)
+
+		if m["fallback"] != true {
+			t.Errorf("fallback = %v, want true", m["fallback"])
+		}
+		if m["empty"] != true {
+			t.Errorf("empty = %v, want true", m["empty"])
+		}
+	})
+
+	t.Run("result with timeout", func(t *testing.T) {
+		r := &StepResult{
+			Name:     "slow_step",
+			Type:     "httpcall",
+			Success:  false,
+			Error:    testError{msg: "step timed out after 5s"},
+			TimedOut: true,
+		}
+		m := stepResultToMap(r)
+
+		if m["timed_out"] != true {
+			t.Errorf("timed_out = %v, want true", m["timed_out"])
+		}
 	})
 }
 
@@ -798,6 +935,7 @@ func TestBlockContext_BuildTemplateData(t *testing.T) {
 
 // testLogger implements Logger for testing
 type testLogger struct {
+	mu         sync.Mutex
 	debugCalls []logCall
 	infoCalls  []logCall
 	warnCalls  []logCall
@@ -810,18 +948,26 @@ type logCall struct {
 }
 
 func (l *testLogger) Debug(msg string, fields map[string]any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.debugCalls = append(l.debugCalls, logCall{msg, fields})
 }
 
 func (l *testLogger) Info(msg string, fields map[string]any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.infoCalls = append(l.infoCalls, logCall{msg, fields})
 }
 
 func (l *testLogger) Warn(msg string, fields map[string]any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.warnCalls = append(l.warnCalls, logCall{msg, fields})
 }
 
 func (l *testLogger) Error(msg string, fields map[string]any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.errorCalls = append(l.errorCalls, logCall{msg, fields})
 }
 
@@ -0,0 +1,358 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sql-proxy/internal/errtrack"
+	"sql-proxy/internal/workflow/step"
+)
+
+// DBWatch defaults for triggers that leave them unset
+const (
+	DefaultDBWatchInterval  = 30 * time.Second
+	DefaultDBWatchBatchSize = 100
+)
+
+// watermarkColumn is the alias of the watermark in dbwatch queries; it is
+// removed from the rows passed to the workflow
+const watermarkColumn = "_watermark"
+
+// WatermarkStore persists the high-water marks of dbwatch triggers.
+type WatermarkStore interface {
+	Watermark(key string) (string, bool)
+	SetWatermark(key, value string) error
+}
+
+// DBWatcher runs a workflow for the rows of a table that were inserted or
+// updated since the last run. It polls the table ordered by a column that
+// increases on every change and remembers the last value it passed on, the
+// watermark. The watermark only advances when the workflow succeeds, so a
+// failed batch is retried on the next poll: delivery is at least once.
+type DBWatcher struct {
+	executor  *Executor
+	workflow  *CompiledWorkflow
+	trigger   *CompiledTrigger
+	dbType    string
+	store     WatermarkStore
+	variables map[string]string
+
+	key       string
+	watermark *string // nil until loaded or initialized
+}
+
+// NewDBWatcher creates a watcher for a dbwatch trigger on a database of type dbType.
+func NewDBWatcher(executor *Executor, wf *CompiledWorkflow, trigger *CompiledTrigger, dbType string, store WatermarkStore, variables map[string]string) *DBWatcher {
+	cfg := trigger.Config
+	return &DBWatcher{
+		executor:  executor,
+		workflow:  wf,
+		trigger:   trigger,
+		dbType:    dbType,
+		store:     store,
+		variables: variables,
+		key:       fmt.Sprintf("%s/%s.%s.%s", wf.Config.Name, cfg.Database, cfg.Table, cfg.Column),
+	}
+}
+
+// Run polls the table until ctx is cancelled, starting right away.
+func (w *DBWatcher) Run(ctx context.Context) {
+	interval := DefaultDBWatchInterval
+	if w.trigger.Config.IntervalSec > 0 {
+		interval = time.Duration(w.trigger.Config.IntervalSec) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll runs the workflow for every full batch of new rows, then for the last
+// partial one. It stops early on errors, which the next poll retries.
+func (w *DBWatcher) poll(ctx context.Context) {
+	defer func() {
+		if p := recover(); p != nil {
+			errtrack.ReportPanic(p, errtrack.Event{Workflow: w.workflow.Config.Name, Tags: map[string]string{"trigger": "dbwatch"}})
+			w.executor.Logger().Error("dbwatch_panic", map[string]any{
+				"workflow": w.workflow.Config.Name,
+				"panic":    fmt.Sprintf("%v", p),
+			})
+		}
+	}()
+
+	if w.watermark == nil {
+		if err := w.loadWatermark(ctx); err != nil {
+			w.logError("dbwatch_init_failed", "", err)
+			return
+		}
+	}
+
+	batchSize := w.trigger.Config.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultDBWatchBatchSize
+	}
+	for ctx.Err() == nil {
+		n, err := w.runBatch(ctx, batchSize)
+		if err != nil || n < batchSize {
+			return
+		}
+	}
+}
+
+// loadWatermark reads the stored watermark or, without one, starts from the
+// newest row ("latest") or before the first one ("beginning")
+func (w *DBWatcher) loadWatermark(ctx context.Context) error {
+	if mark, ok := w.store.Watermark(w.key); ok {
+		w.watermark = &mark
+		return nil
+	}
+	if w.trigger.Config.Start == "beginning" {
+		w.watermark = new(string)
+		return nil
+	}
+
+	result, err := w.executor.dbManager.ExecuteQuery(ctx, w.trigger.Config.Database, latestWatermarkSQL(w.dbType, w.trigger.Config), nil, step.QueryOptions{})
+	if err != nil {
+		return err
+	}
+	mark := ""
+	if len(result.Rows) > 0 && result.Rows[0][watermarkColumn] != nil {
+		mark = fmt.Sprint(result.Rows[0][watermarkColumn])
+	}
+	if err := w.store.SetWatermark(w.key, mark); err != nil {
+		return err
+	}
+	w.watermark = &mark
+	return nil
+}
+
+// runBatch runs the workflow for the next batch of rows and returns their number
+func (w *DBWatcher) runBatch(ctx context.Context, batchSize int) (int, error) {
+	cfg := w.trigger.Config
+	since := *w.watermark
+	params := map[string]any{"_batch_size": batchSize}
+	if since != "" {
+		if cfg.ColumnType == WatchColumnInt || cfg.ColumnType == WatchColumnRowVersion {
+			n, err := strconv.ParseInt(since, 10, 64)
+			if err != nil {
+				w.logError("dbwatch_invalid_watermark", "", fmt.Errorf("stored watermark %q is not an integer", since))
+				return 0, err
+			}
+			params[watermarkColumn] = n
+		} else {
+			params[watermarkColumn] = since
+		}
+	}
+
+	result, err := w.executor.dbManager.ExecuteQuery(ctx, cfg.Database, watchSQL(w.dbType, cfg, since != ""), params, step.QueryOptions{})
+	if err != nil {
+		w.logError("dbwatch_query_failed", "", err)
+		return 0, err
+	}
+	rows := result.Rows
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	next := fmt.Sprint(rows[len(rows)-1][watermarkColumn])
+	for _, row := range rows {
+		delete(row, watermarkColumn)
+	}
+
+	triggerParams := map[string]any{
+		"rows":      rows,
+		"count":     len(rows),
+		"watermark": next,
+		"since":     nil,
+	}
+	if since != "" {
+		triggerParams["since"] = since
+	}
+	triggerData := &TriggerData{
+		Type:         TriggerTypeDBWatch,
+		Lang:         DefaultLanguage(),
+		Priority:     w.trigger.Config.Priority,
+		Params:       triggerParams,
+		ScheduleTime: time.Now(),
+	}
+
+	execCtx := ctx
+	if w.workflow.Config.TimeoutSec > 0 {
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithTimeout(ctx, time.Duration(w.workflow.Config.TimeoutSec)*time.Second)
+		defer cancel()
+	}
+	requestID := "dbwatch-" + generateRequestID()
+	exec := w.executor.Execute(execCtx, w.workflow, triggerData, requestID, nil, w.variables)
+	if exec.Error != nil {
+		w.logError("dbwatch_batch_failed", requestID, exec.Error)
+		return 0, exec.Error
+	}
+
+	w.watermark = &next
+	if err := w.store.SetWatermark(w.key, next); err != nil {
+		// The batch ran; it is retried after a restart only if the file stays unwritable
+		w.logError("dbwatch_watermark_save_failed", requestID, err)
+	}
+	w.executor.Logger().Info("dbwatch_batch_completed", map[string]any{
+		"workflow":   w.workflow.Config.Name,
+		"request_id": requestID,
+		"rows":       len(rows),
+		"watermark":  next,
+	})
+	return len(rows), nil
+}
+
+func (w *DBWatcher) logError(event, requestID string, err error) {
+	fields := map[string]any{
+		"workflow": w.workflow.Config.Name,
+		"table":    w.trigger.Config.Table,
+		"error":    err.Error(),
+	}
+	if requestID != "" {
+		fields["request_id"] = requestID
+	}
+	w.executor.Logger().Error(event, fields)
+}
+
+// watermarkExpr selects the column as a value that compares and round-trips
+// as text or an integer: timestamps keep their fractional seconds
+func watermarkExpr(dbType string, cfg *TriggerConfig) string {
+	switch cfg.ColumnType {
+	case WatchColumnInt:
+		return cfg.Column
+	case WatchColumnRowVersion:
+		return fmt.Sprintf("CAST(%s AS BIGINT)", cfg.Column)
+	}
+	switch dbType {
+	case "sqlserver":
+		return fmt.Sprintf("CONVERT(varchar(27), %s, 121)", cfg.Column)
+	case "mysql":
+		return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:%%i:%%s.%%f')", cfg.Column)
+	default:
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:%%M:%%f', %s)", cfg.Column)
+	}
+}
+
+// watchSQL builds the query for the next batch: rows after @_watermark, or
+// from the first row when afterWatermark is false
+func watchSQL(dbType string, cfg *TriggerConfig, afterWatermark bool) string {
+	columns := "*"
+	if len(cfg.Columns) > 0 {
+		columns = strings.Join(cfg.Columns, ", ")
+	}
+
+	var conditions []string
+	if afterWatermark {
+		switch {
+		case cfg.ColumnType == WatchColumnRowVersion:
+			conditions = append(conditions, fmt.Sprintf("%s > CAST(@%s AS BINARY(8))", cfg.Column, watermarkColumn))
+		case cfg.ColumnType != WatchColumnInt && dbType == "sqlite":
+			conditions = append(conditions, fmt.Sprintf("%s > @%s", watermarkExpr(dbType, cfg), watermarkColumn))
+		default:
+			conditions = append(conditions, fmt.Sprintf("%s > @%s", cfg.Column, watermarkColumn))
+		}
+	}
+	if cfg.Where != "" {
+		conditions = append(conditions, "("+cfg.Where+")")
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if dbType == "sqlserver" {
+		return fmt.Sprintf("SELECT TOP (@_batch_size) %s, %s AS %s FROM %s%s ORDER BY %s",
+			columns, watermarkExpr(dbType, cfg), watermarkColumn, cfg.Table, where, cfg.Column)
+	}
+	return fmt.Sprintf("SELECT %s, %s AS %s FROM %s%s ORDER BY %s LIMIT @_batch_size",
+		columns, watermarkExpr(dbType, cfg), watermarkColumn, cfg.Table, where, cfg.Column)
+}
+
+// latestWatermarkSQL selects the watermark of the newest row
+func latestWatermarkSQL(dbType string, cfg *TriggerConfig) string {
+	where := ""
+	if cfg.Where != "" {
+		where = " WHERE (" + cfg.Where + ")"
+	}
+	if dbType == "sqlserver" {
+		return fmt.Sprintf("SELECT TOP 1 %s AS %s FROM %s%s ORDER BY %s DESC",
+			watermarkExpr(dbType, cfg), watermarkColumn, cfg.Table, where, cfg.Column)
+	}
+	return fmt.Sprintf("SELECT %s AS %s FROM %s%s ORDER BY %s DESC LIMIT 1",
+		watermarkExpr(dbType, cfg), watermarkColumn, cfg.Table, where, cfg.Column)
+}
+
+// FileWatermarkStore keeps watermarks in a JSON file (server.dbwatch_state_file).
+// The file is rewritten next to its final path and renamed on every change, so
+// a crash never leaves it partial.
+type FileWatermarkStore struct {
+	path  string
+	mu    sync.Mutex
+	marks map[string]string
+}
+
+// NewFileWatermarkStore loads the watermarks in path. A missing file means no
+// trigger has stored one yet.
+func NewFileWatermarkStore(path string) (*FileWatermarkStore, error) {
+	s := &FileWatermarkStore{path: path, marks: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dbwatch state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.marks); err != nil {
+		return nil, fmt.Errorf("failed to parse dbwatch state file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *FileWatermarkStore) Watermark(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mark, ok := s.marks[key]
+	return mark, ok
+}
+
+func (s *FileWatermarkStore) SetWatermark(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marks[key] = value
+
+	data, err := json.MarshalIndent(s.marks, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write dbwatch state file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write dbwatch state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write dbwatch state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write dbwatch state file: %w", err)
+	}
+	return nil
+}
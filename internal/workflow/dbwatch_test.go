@@ -0,0 +1,209 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+func TestWatchSQL(t *testing.T) {
+	tests := []struct {
+		name   string
+		dbType string
+		cfg    TriggerConfig
+		after  bool
+		want   string
+	}{
+		{
+			name:   "sqlite timestamp from the beginning",
+			dbType: "sqlite",
+			cfg:    TriggerConfig{Table: "orders", Column: "updated_at"},
+			want:   "SELECT *, strftime('%Y-%m-%d %H:%M:%f', updated_at) AS _watermark FROM orders ORDER BY updated_at LIMIT @_batch_size",
+		},
+		{
+			name:   "sqlite timestamp after watermark",
+			dbType: "sqlite",
+			cfg:    TriggerConfig{Table: "orders", Column: "updated_at", Columns: []string{"id", "status"}},
+			after:  true,
+			want:   "SELECT id, status, strftime('%Y-%m-%d %H:%M:%f', updated_at) AS _watermark FROM orders WHERE strftime('%Y-%m-%d %H:%M:%f', updated_at) > @_watermark ORDER BY updated_at LIMIT @_batch_size",
+		},
+		{
+			name:   "mysql int with where",
+			dbType: "mysql",
+			cfg:    TriggerConfig{Table: "events", Column: "id", ColumnType: "int", Where: "kind = 'order'"},
+			after:  true,
+			want:   "SELECT *, id AS _watermark FROM events WHERE id > @_watermark AND (kind = 'order') ORDER BY id LIMIT @_batch_size",
+		},
+		{
+			name:   "mysql timestamp",
+			dbType: "mysql",
+			cfg:    TriggerConfig{Table: "orders", Column: "updated_at"},
+			after:  true,
+			want:   "SELECT *, DATE_FORMAT(updated_at, '%Y-%m-%d %H:%i:%s.%f') AS _watermark FROM orders WHERE updated_at > @_watermark ORDER BY updated_at LIMIT @_batch_size",
+		},
+		{
+			name:   "sqlserver rowversion",
+			dbType: "sqlserver",
+			cfg:    TriggerConfig{Table: "dbo.orders", Column: "rv", ColumnType: "rowversion"},
+			after:  true,
+			want:   "SELECT TOP (@_batch_size) *, CAST(rv AS BIGINT) AS _watermark FROM dbo.orders WHERE rv > CAST(@_watermark AS BINARY(8)) ORDER BY rv",
+		},
+		{
+			name:   "sqlserver timestamp",
+			dbType: "sqlserver",
+			cfg:    TriggerConfig{Table: "orders", Column: "modified"},
+			want:   "SELECT TOP (@_batch_size) *, CONVERT(varchar(27), modified, 121) AS _watermark FROM orders ORDER BY modified",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := watchSQL(tt.dbType, &tt.cfg, tt.after); got != tt.want {
+				t.Errorf("watchSQL() =\n  %s\nwant\n  %s", got, tt.want)
+			}
+		})
+	}
+
+	cfg := &TriggerConfig{Table: "events", Column: "id", ColumnType: "int"}
+	if got, want := latestWatermarkSQL("sqlserver", cfg), "SELECT TOP 1 id AS _watermark FROM events ORDER BY id DESC"; got != want {
+		t.Errorf("latestWatermarkSQL(sqlserver) = %s", got)
+	}
+	if got, want := latestWatermarkSQL("sqlite", cfg), "SELECT id AS _watermark FROM events ORDER BY id DESC LIMIT 1"; got != want {
+		t.Errorf("latestWatermarkSQL(sqlite) = %s", got)
+	}
+}
+
+// watchTable serves dbwatch queries over an in-memory table of ids 1..n and
+// records the executions of the workflow's audit step
+type watchTable struct {
+	mu      sync.Mutex
+	n       int
+	fail    bool // fail the audit step
+	batches []map[string]any
+}
+
+func (tbl *watchTable) query(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+	tbl.mu.Lock()
+	defer tbl.mu.Unlock()
+	if strings.HasPrefix(sql, "INSERT") {
+		if tbl.fail {
+			return nil, errors.New("audit table is locked")
+		}
+		tbl.batches = append(tbl.batches, params)
+		return &step.QueryResult{}, nil
+	}
+	if strings.Contains(sql, "DESC") {
+		return &step.QueryResult{Rows: []map[string]any{{"_watermark": int64(tbl.n)}}}, nil
+	}
+
+	after := int64(0)
+	if mark, ok := params["_watermark"].(int64); ok {
+		after = mark
+	}
+	var rows []map[string]any
+	for id := after + 1; id <= int64(tbl.n) && len(rows) < params["_batch_size"].(int); id++ {
+		rows = append(rows, map[string]any{"id": id, "_watermark": id})
+	}
+	return &step.QueryResult{Rows: rows}, nil
+}
+
+func newTestWatcher(t *testing.T, tbl *watchTable, start string, store WatermarkStore) *DBWatcher {
+	t.Helper()
+	exec := NewExecutor(&mockDBManager{queryFunc: tbl.query}, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "sync_events",
+		Triggers: []TriggerConfig{
+			{Type: "dbwatch", Database: "db", Table: "events", Column: "id", ColumnType: "int", BatchSize: 2, Start: start},
+		},
+		Steps: []StepConfig{
+			{Name: "audit", Type: "query", Database: "db", SQL: "INSERT INTO audit (n, since, upto) VALUES (@count, @since, @watermark)"},
+		},
+	})
+	return NewDBWatcher(exec, wf, wf.Triggers[0], "sqlite", store, nil)
+}
+
+func TestDBWatcher_Poll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dbwatch.json")
+	store, err := NewFileWatermarkStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := &watchTable{n: 5}
+	w := newTestWatcher(t, tbl, "beginning", store)
+
+	// Full batches are drained in one poll
+	w.poll(context.Background())
+	want := []string{"2 <nil> 2", "2 2 4", "1 4 5"}
+	if len(tbl.batches) != len(want) {
+		t.Fatalf("batches = %v", tbl.batches)
+	}
+	for i, b := range tbl.batches {
+		if got := fmt.Sprint(b["count"], " ", b["since"], " ", b["watermark"]); got != want[i] {
+			t.Errorf("batch %d = %s, want %s", i, got, want[i])
+		}
+	}
+
+	// Nothing new: the workflow does not run
+	w.poll(context.Background())
+	if len(tbl.batches) != 3 {
+		t.Errorf("expected no execution without new rows, got %d batches", len(tbl.batches))
+	}
+
+	// A failed batch keeps the watermark and is retried
+	tbl.n = 6
+	tbl.fail = true
+	w.poll(context.Background())
+	if mark, _ := store.Watermark(w.key); mark != "5" {
+		t.Errorf("watermark after failure = %q, want 5", mark)
+	}
+	tbl.fail = false
+	w.poll(context.Background())
+	if len(tbl.batches) != 4 || fmt.Sprint(tbl.batches[3]["since"]) != "5" {
+		t.Errorf("retried batch = %v", tbl.batches[3:])
+	}
+
+	// The watermark survives a restart
+	reloaded, err := NewFileWatermarkStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mark, ok := reloaded.Watermark("sync_events/db.events.id"); !ok || mark != "6" {
+		t.Errorf("reloaded watermark = %q, %v", mark, ok)
+	}
+}
+
+func TestDBWatcher_StartLatest(t *testing.T) {
+	store, err := NewFileWatermarkStore(filepath.Join(t.TempDir(), "dbwatch.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := &watchTable{n: 5}
+	w := newTestWatcher(t, tbl, "", store)
+
+	// Existing rows are skipped
+	w.poll(context.Background())
+	if len(tbl.batches) != 0 {
+		t.Fatalf("existing rows were delivered: %v", tbl.batches)
+	}
+	tbl.n = 7
+	w.poll(context.Background())
+	if len(tbl.batches) != 1 || fmt.Sprint(tbl.batches[0]["since"], " ", tbl.batches[0]["watermark"]) != "5 7" {
+		t.Errorf("batches = %v", tbl.batches)
+	}
+}
+
+func TestFileWatermarkStore_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dbwatch.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileWatermarkStore(path); err == nil {
+		t.Error("expected error for a corrupt state file")
+	}
+}
@@ -0,0 +1,120 @@
+package workflow
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"sql-proxy/internal/redact"
+)
+
+// maxDebugLogBody is the largest payload body written to the debug log; larger
+// bodies are logged by size only because a cut-off JSON body cannot be redacted
+const maxDebugLogBody = 64 << 10
+
+// payloadRedactor holds the redaction rules for debug-logged payloads
+var payloadRedactor atomic.Pointer[redact.Redactor]
+
+// SetPayloadRedactor sets the rules applied to payloads before triggers with
+// debug_log write them. Thread-safe. With nil only redact.DefaultHeaders are masked.
+func SetPayloadRedactor(r *redact.Redactor) {
+	payloadRedactor.Store(r)
+}
+
+// logRequestPayload writes the redacted request to the debug log. The body is
+// read and replaced so the trigger can still parse it.
+func (h *HTTPHandler) logRequestPayload(r *http.Request, requestID string) {
+	logger := h.executor.Logger()
+	if logger == nil {
+		return
+	}
+	var body []byte
+	if r.Body != nil {
+		data, err := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), errorReader{err}))
+		body = data
+	}
+
+	redactor := payloadRedactor.Load()
+	logger.Debug("trigger_request_payload", map[string]any{
+		"workflow":   h.workflow.Config.Name,
+		"request_id": requestID,
+		"method":     r.Method,
+		"path":       r.URL.Path,
+		"query":      redactor.Query(r.URL.RawQuery),
+		"headers":    redactor.Headers(r.Header),
+		"body":       debugLogBody(redactor, r.Header.Get("Content-Type"), body, len(body)),
+	})
+}
+
+// logResponsePayload writes the redacted response recorded by rec to the debug log
+func (h *HTTPHandler) logResponsePayload(rec *payloadRecorder, requestID string) {
+	logger := h.executor.Logger()
+	if logger == nil {
+		return
+	}
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	redactor := payloadRedactor.Load()
+	logger.Debug("trigger_response_payload", map[string]any{
+		"workflow":   h.workflow.Config.Name,
+		"request_id": requestID,
+		"status":     status,
+		"headers":    redactor.Headers(rec.Header()),
+		"body":       debugLogBody(redactor, rec.Header().Get("Content-Type"), rec.body.Bytes(), rec.size),
+	})
+}
+
+// debugLogBody redacts a body, or describes it when it is over maxDebugLogBody
+func debugLogBody(redactor *redact.Redactor, contentType string, body []byte, size int) string {
+	if size > maxDebugLogBody {
+		return fmt.Sprintf("[%d bytes, over the %d byte debug log limit]", size, maxDebugLogBody)
+	}
+	return redactor.Body(contentType, body)
+}
+
+// errorReader replays a read error after the buffered body, or io.EOF
+type errorReader struct{ err error }
+
+func (e errorReader) Read([]byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	return 0, io.EOF
+}
+
+// payloadRecorder passes a response through while keeping its status and the
+// first maxDebugLogBody+1 bytes of its body
+type payloadRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+	size   int
+}
+
+func (p *payloadRecorder) WriteHeader(code int) {
+	if p.status == 0 {
+		p.status = code
+	}
+	p.ResponseWriter.WriteHeader(code)
+}
+
+func (p *payloadRecorder) Write(b []byte) (int, error) {
+	if p.status == 0 {
+		p.status = http.StatusOK
+	}
+	if room := maxDebugLogBody + 1 - p.body.Len(); room > 0 {
+		p.body.Write(b[:min(room, len(b))])
+	}
+	p.size += len(b)
+	return p.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (event streams flush through it)
+func (p *payloadRecorder) Unwrap() http.ResponseWriter {
+	return p.ResponseWriter
+}
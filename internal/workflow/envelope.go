@@ -0,0 +1,208 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Default envelope keys
+const (
+	defaultSuccessKey   = "success"
+	defaultDataKey      = "data"
+	defaultErrorKey     = "error"
+	defaultCodeKey      = "code"
+	defaultCountKey     = "count"
+	defaultRequestIDKey = "request_id"
+)
+
+// omitKey leaves an envelope field out
+const omitKey = "-"
+
+// envelopeField is one key of an envelope body
+type envelopeField struct {
+	key   string
+	value any // A value to marshal, template text (json.RawMessage), or a nested []envelopeField
+}
+
+// envelopeBody collects the fields of a body. They are written in the order of
+// the default envelope: the success flag, the data or error, the count and
+// request ID, then extras. With meta the flag, count, and request ID move
+// under the meta key, after the other fields.
+type envelopeBody struct {
+	flag, main, trail, extra []envelopeField
+}
+
+func (b *envelopeBody) fields(metaKey string) []envelopeField {
+	if metaKey == "" {
+		return concatFields(b.flag, b.main, b.trail, b.extra)
+	}
+	fields := concatFields(b.main, b.extra)
+	if meta := concatFields(b.flag, b.trail); len(meta) > 0 {
+		fields = append(fields, envelopeField{metaKey, meta})
+	}
+	return fields
+}
+
+func concatFields(groups ...[]envelopeField) []envelopeField {
+	var fields []envelopeField
+	for _, g := range groups {
+		fields = append(fields, g...)
+	}
+	return fields
+}
+
+// addField appends a field unless its key is omitted
+func addField(fields *[]envelopeField, key string, value any) {
+	if key != "" {
+		*fields = append(*fields, envelopeField{key, value})
+	}
+}
+
+// keyOr returns a configured key, def when unset, or "" when omitted
+func keyOr(configured, def string) string {
+	switch configured {
+	case "":
+		return def
+	case omitKey:
+		return ""
+	}
+	return configured
+}
+
+// envelopeKeys are the resolved keys of an envelope; "" leaves a field out
+type envelopeKeys struct {
+	success, data, err, code, count, requestID, meta string
+}
+
+// keys resolves the envelope's keys; a nil envelope has the defaults
+func (e *EnvelopeConfig) keys() envelopeKeys {
+	if e == nil {
+		e = &EnvelopeConfig{}
+	}
+	return envelopeKeys{
+		success:   keyOr(e.Success, defaultSuccessKey),
+		data:      keyOr(e.Data, defaultDataKey),
+		err:       keyOr(e.Error, defaultErrorKey),
+		code:      keyOr(e.Code, defaultCodeKey),
+		count:     keyOr(e.Count, defaultCountKey),
+		requestID: keyOr(e.RequestID, defaultRequestIDKey),
+		meta:      e.Meta,
+	}
+}
+
+func (e *EnvelopeConfig) flat() bool {
+	return e != nil && e.Flat
+}
+
+// successBody renders a success response. Nil data and an empty request ID are left out.
+func (e *EnvelopeConfig) successBody(data any, requestID string) []byte {
+	if e.flat() {
+		if data == nil {
+			data = struct{}{}
+		}
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(data); err != nil {
+			return []byte("{}\n")
+		}
+		return buf.Bytes()
+	}
+	k := e.keys()
+	var b envelopeBody
+	addField(&b.flag, k.success, true)
+	if data != nil {
+		addField(&b.main, k.data, data)
+	}
+	if requestID != "" {
+		addField(&b.trail, k.requestID, requestID)
+	}
+	return encodeEnvelope(b.fields(k.meta))
+}
+
+// errorBody renders an error response; flat envelopes still wrap errors.
+// The code and extra fields are never nested under meta.
+func (e *EnvelopeConfig) errorBody(message, code, requestID string, extra ...envelopeField) []byte {
+	k := e.keys()
+	var b envelopeBody
+	addField(&b.flag, k.success, false)
+	addField(&b.main, k.err, message)
+	addField(&b.main, k.code, code)
+	if requestID != "" {
+		addField(&b.trail, k.requestID, requestID)
+	}
+	b.extra = extra
+	return encodeEnvelope(b.fields(k.meta))
+}
+
+// SuccessTemplate returns a response template with a success envelope, for
+// generated workflows. data and count are template text rendering JSON
+// values; either may be empty.
+func (e *EnvelopeConfig) SuccessTemplate(data, count string) string {
+	if e.flat() {
+		if data == "" {
+			return "{}"
+		}
+		return data
+	}
+	k := e.keys()
+	var b envelopeBody
+	addField(&b.flag, k.success, json.RawMessage("true"))
+	if data != "" {
+		addField(&b.main, k.data, json.RawMessage(data))
+	}
+	if count != "" {
+		addField(&b.trail, k.count, json.RawMessage(count))
+	}
+	return envelopeTemplate(b.fields(k.meta))
+}
+
+// ErrorTemplate returns a response template with an error envelope and a fixed message and code
+func (e *EnvelopeConfig) ErrorTemplate(message, code string) string {
+	k := e.keys()
+	msg, _ := json.Marshal(message)
+	codeJSON, _ := json.Marshal(code)
+	var b envelopeBody
+	addField(&b.flag, k.success, json.RawMessage("false"))
+	addField(&b.main, k.err, json.RawMessage(msg))
+	addField(&b.main, k.code, json.RawMessage(codeJSON))
+	return envelopeTemplate(b.fields(k.meta))
+}
+
+// encodeEnvelope writes fields as a JSON object in order, ending in a newline like json.Encoder
+func encodeEnvelope(fields []envelopeField) []byte {
+	var buf bytes.Buffer
+	writeEnvelopeObject(&buf, fields, ",", ":")
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// envelopeTemplate writes the fields of a response template
+func envelopeTemplate(fields []envelopeField) string {
+	var buf bytes.Buffer
+	writeEnvelopeObject(&buf, fields, ", ", ": ")
+	return buf.String()
+}
+
+func writeEnvelopeObject(buf *bytes.Buffer, fields []envelopeField, comma, colon string) {
+	buf.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteString(comma)
+		}
+		key, _ := json.Marshal(f.key)
+		buf.Write(key)
+		buf.WriteString(colon)
+		switch v := f.value.(type) {
+		case []envelopeField:
+			writeEnvelopeObject(buf, v, comma, colon)
+		case json.RawMessage:
+			buf.Write(v)
+		default:
+			value, err := json.Marshal(v)
+			if err != nil {
+				value = []byte("null")
+			}
+			buf.Write(value)
+		}
+	}
+	buf.WriteByte('}')
+}
@@ -0,0 +1,74 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"sql-proxy/internal/concurrency"
+	"sql-proxy/internal/workflow/step"
+)
+
+// Error codes of error responses, step results, and the errors_total metric.
+// Codes are stable; messages may change.
+const (
+	CodeMethodNotAllowed    = "SQLPROXY_METHOD_NOT_ALLOWED"
+	CodeParamMissing        = "SQLPROXY_PARAM_MISSING"
+	CodeParamInvalid        = "SQLPROXY_PARAM_INVALID" // Unconvertible value, failed validation rule, or bad ?fields=/?sort=/?filter[...]
+	CodeBodyInvalid         = "SQLPROXY_BODY_INVALID"  // Unreadable body or body_schema violations
+	CodeOriginNotAllowed    = "SQLPROXY_ORIGIN_NOT_ALLOWED"
+	CodeUnsupportedMessage  = "SQLPROXY_UNSUPPORTED_MESSAGE" // Binary WebSocket message
+	CodeRateLimited         = "SQLPROXY_RATE_LIMITED"
+	CodeConcurrencyLimit    = "SQLPROXY_CONCURRENCY_LIMIT"
+	CodeOverloaded          = "SQLPROXY_OVERLOADED" // Shed by server.load_shedding
+	CodeIdempotencyRequired = "SQLPROXY_IDEMPOTENCY_KEY_REQUIRED"
+	CodeIdempotencyInvalid  = "SQLPROXY_IDEMPOTENCY_KEY_INVALID"
+	CodeIdempotencyReused   = "SQLPROXY_IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyPending  = "SQLPROXY_IDEMPOTENCY_IN_PROGRESS"
+	CodeResultTooLarge      = "SQLPROXY_RESULT_TOO_LARGE"
+	CodeDBTimeout           = "SQLPROXY_DB_TIMEOUT"
+	CodeDBDeadlock          = "SQLPROXY_DB_DEADLOCK"
+	CodeDBConstraint        = "SQLPROXY_DB_CONSTRAINT_VIOLATION"
+	CodeTimeout             = "SQLPROXY_TIMEOUT" // A step or the workflow ran out of time outside the database
+	CodeNotFound            = "SQLPROXY_NOT_FOUND"
+	CodeUpstreamError       = "SQLPROXY_UPSTREAM_ERROR" // An httpcall got a non-2xx response
+	CodeStepFailed          = "SQLPROXY_STEP_FAILED"    // Any other step failure
+	CodeInternal            = "SQLPROXY_INTERNAL_ERROR"
+)
+
+// errParamMissing is the error of a missing required parameter
+var errParamMissing = errors.New("missing required parameter")
+
+// errorCode returns the code of a step or workflow error, or "" for nil
+func errorCode(err error) string {
+	var classified *step.ClassifiedError
+	var httpErr *HTTPStatusError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, concurrency.ErrLimitReached):
+		return CodeConcurrencyLimit
+	case errors.Is(err, ErrResultTooLarge):
+		return CodeResultTooLarge
+	case errors.As(err, &classified):
+		switch classified.Class {
+		case step.ErrorClassDeadlock:
+			return CodeDBDeadlock
+		case step.ErrorClassConstraintViolation:
+			return CodeDBConstraint
+		case step.ErrorClassTimeout:
+			return CodeDBTimeout
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, sql.ErrNoRows):
+		return CodeNotFound
+	case errors.As(err, &httpErr):
+		if httpErr.StatusCode == http.StatusNotFound {
+			return CodeNotFound
+		}
+		return CodeUpstreamError
+	}
+	return CodeStepFailed
+}
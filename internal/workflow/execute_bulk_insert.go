@@ -0,0 +1,172 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+const (
+	// DefaultBulkInsertBatchSize is the rows per INSERT when batch_size is not set.
+	DefaultBulkInsertBatchSize = 500
+
+	// maxBulkInsertRows and maxBulkInsertParams cap each INSERT below SQL Server's limits
+	// (1000 rows per VALUES list, 2100 parameters per statement), the strictest of the drivers.
+	maxBulkInsertRows   = 1000
+	maxBulkInsertParams = 2000
+)
+
+// columnNameRegex matches column names that can be used without quoting.
+var columnNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (e *Executor) executeBulkInsertStep(ctx context.Context, cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{}
+
+	fail := func(err error) (*StepResult, error) {
+		result.Error = err
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	value, err := EvalExpression(cs.SourceProg, execData.ExprEnv)
+	if err != nil {
+		return fail(fmt.Errorf("source: %w", err))
+	}
+	var rows []map[string]any
+	if value != nil {
+		var ok bool
+		if rows, ok = toRows(value); !ok {
+			return fail(fmt.Errorf("source must be an object or a list of objects, got %T", value))
+		}
+	}
+
+	columns := cs.Config.Columns
+	if len(columns) == 0 {
+		columns = rowColumns(rows)
+		for _, col := range columns {
+			if !columnNameRegex.MatchString(col) {
+				return fail(fmt.Errorf("source column %q is not a valid column name; list the columns to insert in columns", col))
+			}
+		}
+	}
+	if len(rows) > 0 && len(columns) == 0 {
+		return fail(errors.New("source rows have no columns"))
+	}
+
+	batchSize := bulkInsertBatchSize(cs.Config.BatchSize, len(columns))
+	isWrite, hasReturning := true, false
+	opts := step.QueryOptions{IsWrite: &isWrite, HasReturning: &hasReturning, Comment: queryComment(cs, execData)}
+	if opts.Tenant, err = e.resolveTenant(cs.Config.Database, execData); err != nil {
+		return fail(err)
+	}
+
+	var rowsAffected int64
+	var batches, failed int
+	batchErrors := []string{}
+	for offset := 0; offset < len(rows); offset += batchSize {
+		batch := rows[offset:min(offset+batchSize, len(rows))]
+		batches++
+
+		sql, params := buildBulkInsert(cs.Config.Table, columns, batch)
+		qr, err := e.dbManager.ExecuteQuery(ctx, cs.Config.Database, sql, params, opts)
+		if err != nil {
+			err = fmt.Errorf("batch %d (rows %d-%d): %w", batches, offset+1, offset+len(batch), err)
+			if cs.Config.OnBatchError != "continue" || ctx.Err() != nil {
+				result.RowsAffected = rowsAffected
+				return fail(err)
+			}
+			failed++
+			batchErrors = append(batchErrors, err.Error())
+			e.logger.Warn("bulk_insert_batch_failed", map[string]any{
+				"step":  cs.Config.Name,
+				"batch": batches,
+				"error": err.Error(),
+			})
+			continue
+		}
+		rowsAffected += qr.RowsAffected
+	}
+
+	result.Success = true
+	result.Count = len(rows)
+	result.RowsAffected = rowsAffected
+	result.Values = map[string]any{
+		"rows":           len(rows),
+		"rows_affected":  rowsAffected,
+		"batches":        batches,
+		"failed_batches": failed,
+		"errors":         batchErrors,
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Debug("bulk_insert_step_executed", map[string]any{
+		"step":           cs.Config.Name,
+		"database":       cs.Config.Database,
+		"table":          cs.Config.Table,
+		"rows":           len(rows),
+		"batches":        batches,
+		"failed_batches": failed,
+		"duration_ms":    result.DurationMs,
+	})
+
+	return result, nil
+}
+
+// bulkInsertBatchSize returns the rows per INSERT: the configured size (or the default),
+// lowered so each statement stays within the row and parameter limits.
+func bulkInsertBatchSize(configured, columns int) int {
+	size := configured
+	if size <= 0 {
+		size = DefaultBulkInsertBatchSize
+	}
+	size = min(size, maxBulkInsertRows)
+	if columns > 0 {
+		size = min(size, maxBulkInsertParams/columns)
+	}
+	return max(size, 1)
+}
+
+// buildBulkInsert builds a multi-row INSERT with one @param per value. Columns missing
+// from a row insert NULL, and nested objects and lists are inserted as JSON text.
+func buildBulkInsert(table string, columns []string, rows []map[string]any) (string, map[string]any) {
+	params := make(map[string]any, len(rows)*len(columns))
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+	for i, row := range rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteByte('(')
+		for j, col := range columns {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			name := fmt.Sprintf("r%d_%d", i, j)
+			sb.WriteString("@" + name)
+			params[name] = bulkInsertValue(row[col])
+		}
+		sb.WriteByte(')')
+	}
+	return sb.String(), params
+}
+
+// bulkInsertValue converts nested objects and lists to JSON text.
+func bulkInsertValue(v any) any {
+	switch v.(type) {
+	case map[string]any, []any, []map[string]any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	default:
+		return v
+	}
+}
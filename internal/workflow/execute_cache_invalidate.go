@@ -0,0 +1,40 @@
+package workflow
+
+import (
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+func (e *Executor) executeCacheInvalidateStep(cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{}
+
+	tags, err := evaluateTags(cs.TagTmpls, execData.TemplateData)
+	if err != nil {
+		result.Error = err
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	// Without a cache there is nothing to invalidate; the step still succeeds
+	invalidated := 0
+	if e.cache != nil {
+		for _, tag := range tags {
+			invalidated += e.cache.InvalidateTag(tag)
+		}
+	}
+
+	result.Success = true
+	result.Count = invalidated
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Debug("cache_invalidate_step_executed", map[string]any{
+		"step":        cs.Config.Name,
+		"tags":        tags,
+		"invalidated": invalidated,
+		"duration_ms": result.DurationMs,
+	})
+
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+func (e *Executor) executeEmailStep(ctx context.Context, cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{}
+
+	fail := func(err error) (*StepResult, error) {
+		result.Error = err
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	if e.mailer == nil {
+		return fail(errors.New("email step requires smtp to be configured"))
+	}
+
+	msg, err := renderEmail(cs.EmailTmpls, execData.TemplateData)
+	if err != nil {
+		return fail(err)
+	}
+	recipients := countRecipients(msg.To) + countRecipients(msg.Cc) + countRecipients(msg.Bcc)
+	if recipients == 0 {
+		return fail(errors.New("email has no recipients"))
+	}
+
+	if err := e.mailer.SendEmail(ctx, msg); err != nil {
+		return fail(fmt.Errorf("sending email: %w", err))
+	}
+
+	result.Success = true
+	result.Count = recipients
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Info("email_step_sent", map[string]any{
+		"step":        cs.Config.Name,
+		"recipients":  recipients,
+		"duration_ms": result.DurationMs,
+	})
+
+	return result, nil
+}
+
+// renderEmail executes the email templates against the step data
+func renderEmail(tmpls *CompiledEmail, data map[string]any) (step.EmailMessage, error) {
+	var msg step.EmailMessage
+	render := func(field string, tmpl *template.Template) (string, error) {
+		if tmpl == nil {
+			return "", nil
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("%s template: %w", field, err)
+		}
+		return buf.String(), nil
+	}
+	renderList := func(field string, list []*template.Template) ([]string, error) {
+		out := make([]string, 0, len(list))
+		for _, tmpl := range list {
+			s, err := render(field, tmpl)
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(s) != "" {
+				out = append(out, s)
+			}
+		}
+		return out, nil
+	}
+
+	var err error
+	if msg.From, err = render("from", tmpls.From); err != nil {
+		return msg, err
+	}
+	if msg.To, err = renderList("to", tmpls.To); err != nil {
+		return msg, err
+	}
+	if msg.Cc, err = renderList("cc", tmpls.Cc); err != nil {
+		return msg, err
+	}
+	if msg.Bcc, err = renderList("bcc", tmpls.Bcc); err != nil {
+		return msg, err
+	}
+	if msg.Subject, err = render("subject", tmpls.Subject); err != nil {
+		return msg, err
+	}
+	if msg.Text, err = render("text", tmpls.Text); err != nil {
+		return msg, err
+	}
+	if msg.HTML, err = render("html", tmpls.HTML); err != nil {
+		return msg, err
+	}
+	return msg, nil
+}
+
+// countRecipients counts the non-empty entries of comma-separated address lists
+func countRecipients(list []string) int {
+	n := 0
+	for _, entry := range list {
+		for _, addr := range strings.Split(entry, ",") {
+			if strings.TrimSpace(addr) != "" {
+				n++
+			}
+		}
+	}
+	return n
+}
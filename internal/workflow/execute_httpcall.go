@@ -73,7 +73,7 @@ func (e *Executor) executeHTTPCallStep(ctx context.Context, cs *CompiledStep, ex
 	var lastErr error
 
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		resp, lastErr = e.httpClient.Do(req)
+		resp, lastErr = e.doHTTP(ctx, req)
 		if lastErr == nil && resp.StatusCode < 500 {
 			break
 		}
@@ -137,6 +137,9 @@ func (e *Executor) executeHTTPCallStep(ctx context.Context, cs *CompiledStep, ex
 	result.ResponseBody = string(body)
 	result.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
 	result.DurationMs = time.Since(start).Milliseconds()
+	if !result.Success {
+		result.Error = &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
 
 	if result.Success {
 		switch parse {
@@ -232,3 +235,14 @@ func normalizeJSONResponse(v any) []map[string]any {
 		return []map[string]any{{"value": val}}
 	}
 }
+
+// doHTTP sends an attempt of an httpcall step, unless a fault injected into the
+// step fails it first
+func (e *Executor) doHTTP(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if f, ok := ctx.Value(stepFaultKey{}).(stepFault); ok {
+		if err := e.faults.Step(ctx, f.workflow, f.name); err != nil {
+			return nil, err
+		}
+	}
+	return e.httpClient.Do(req)
+}
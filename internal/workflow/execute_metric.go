@@ -0,0 +1,114 @@
+package workflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sql-proxy/internal/metrics"
+	"sql-proxy/internal/workflow/step"
+)
+
+func (e *Executor) executeMetricStep(cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{}
+	cfg := cs.Config.Metric
+
+	fail := func(err error) (*StepResult, error) {
+		result.Error = err
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	value := 1.0
+	if cs.MetricValueProg != nil {
+		v, err := EvalExpression(cs.MetricValueProg, execData.ExprEnv)
+		if err != nil {
+			return fail(fmt.Errorf("metric.value: %w", err))
+		}
+		if value, err = metricValue(v); err != nil {
+			return fail(fmt.Errorf("metric.value: %w", err))
+		}
+	}
+
+	labels := make(map[string]string, len(cs.MetricLabelTmpls))
+	names := make([]string, 0, len(cs.MetricLabelTmpls))
+	for name, tmpl := range cs.MetricLabelTmpls {
+		v, err := renderTemplateField("metric.labels["+name+"]", tmpl, execData.TemplateData)
+		if err != nil {
+			return fail(err)
+		}
+		labels[name] = v
+		names = append(names, name)
+	}
+
+	def := metrics.WorkflowMetric{Name: cfg.Name, Kind: cfg.Type, Help: cfg.Help, Labels: names, Buckets: cfg.Buckets}
+	if err := metrics.RecordWorkflowMetric(def, labels, value); err != nil {
+		return fail(err)
+	}
+
+	result.Success = true
+	result.Values = map[string]any{
+		"metric": cfg.Name,
+		"value":  value,
+		"labels": labels,
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Debug("metric_step_recorded", map[string]any{
+		"step":        cs.Config.Name,
+		"metric":      cfg.Name,
+		"value":       value,
+		"duration_ms": result.DurationMs,
+	})
+
+	return result, nil
+}
+
+// metricValue converts the value of a metric step's expression to a number.
+// Numeric strings are accepted, since drivers return DECIMAL columns as text.
+func metricValue(v any) (float64, error) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), nil
+	case int8:
+		return float64(n), nil
+	case int16:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint:
+		return float64(n), nil
+	case uint8:
+		return float64(n), nil
+	case uint16:
+		return float64(n), nil
+	case uint32:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case float32:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	case bool:
+		if n {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", n)
+		}
+		return f, nil
+	case []byte:
+		return metricValue(string(n))
+	case nil:
+		return 0, fmt.Errorf("value is nil")
+	}
+	return 0, fmt.Errorf("value of type %T is not a number", v)
+}
@@ -0,0 +1,62 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"sql-proxy/internal/mqtt"
+	"sql-proxy/internal/workflow/step"
+)
+
+func (e *Executor) executeMQTTStep(ctx context.Context, cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{}
+
+	fail := func(err error) (*StepResult, error) {
+		result.Error = err
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	if e.publisher == nil {
+		return fail(errors.New("mqtt step requires mqtt brokers to be configured"))
+	}
+
+	topic, err := renderTemplateField("topic", cs.TopicTmpl, execData.TemplateData)
+	if err != nil {
+		return fail(err)
+	}
+	if err := mqtt.ValidateTopic(topic); err != nil {
+		return fail(err)
+	}
+
+	payload, err := renderStepContent(cs, execData)
+	if err != nil {
+		return fail(err)
+	}
+
+	qos := byte(cs.Config.QoS)
+	if err := e.publisher.Publish(ctx, cs.Config.Broker, topic, payload, qos, cs.Config.Retain); err != nil {
+		return fail(err)
+	}
+
+	result.Success = true
+	result.Values = map[string]any{
+		"broker": cs.Config.Broker,
+		"topic":  topic,
+		"size":   len(payload),
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Debug("mqtt_step_published", map[string]any{
+		"step":        cs.Config.Name,
+		"broker":      cs.Config.Broker,
+		"topic":       topic,
+		"size":        len(payload),
+		"qos":         qos,
+		"duration_ms": result.DurationMs,
+	})
+
+	return result, nil
+}
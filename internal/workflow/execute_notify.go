@@ -0,0 +1,166 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+// maxNotifyErrorBody caps how much of a rejected notification's response body is kept in the error
+const maxNotifyErrorBody = 200
+
+func (e *Executor) executeNotifyStep(ctx context.Context, cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{}
+
+	fail := func(err error) (*StepResult, error) {
+		result.Error = err
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	targetURL, err := renderTemplateField("url", cs.URLTmpl, execData.TemplateData)
+	if err != nil {
+		return fail(err)
+	}
+	title, err := renderTemplateField("title", cs.TitleTmpl, execData.TemplateData)
+	if err != nil {
+		return fail(err)
+	}
+	text, err := renderTemplateField("text", cs.TextTmpl, execData.TemplateData)
+	if err != nil {
+		return fail(err)
+	}
+
+	var blocks []any
+	if cs.BlocksTmpl != nil {
+		rendered, err := renderTemplateField("blocks", cs.BlocksTmpl, execData.TemplateData)
+		if err != nil {
+			return fail(err)
+		}
+		if err := json.Unmarshal([]byte(rendered), &blocks); err != nil {
+			return fail(fmt.Errorf("blocks must render a JSON array: %w", err))
+		}
+	}
+
+	payload, err := buildNotifyPayload(cs.Config.Provider, title, text, blocks, execData.TemplateData)
+	if err != nil {
+		return fail(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(payload))
+	if err != nil {
+		return fail(fmt.Errorf("create request error: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, tmpl := range cs.HeaderTmpls {
+		val, err := renderTemplateField("headers["+name+"]", tmpl, execData.TemplateData)
+		if err != nil {
+			return fail(err)
+		}
+		req.Header.Set(name, val)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxNotifyErrorBody))
+		return fail(fmt.Errorf("%s returned status %d: %s", cs.Config.Provider, resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+
+	result.Success = true
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Debug("notify_step_executed", map[string]any{
+		"step":        cs.Config.Name,
+		"provider":    cs.Config.Provider,
+		"status_code": result.StatusCode,
+		"duration_ms": result.DurationMs,
+	})
+
+	return result, nil
+}
+
+// renderTemplateField executes an optional step template, returning "" when it is nil.
+func renderTemplateField(field string, tmpl *template.Template, data map[string]any) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("%s template error: %w", field, err)
+	}
+	return buf.String(), nil
+}
+
+// buildNotifyPayload builds the JSON body for a provider.
+// Slack gets text plus optional blocks, Teams an Adaptive Card whose body ends with the
+// blocks, and generic webhooks a flat object carrying the workflow metadata and, for
+// alert notifications, the alert state.
+func buildNotifyPayload(provider, title, text string, blocks []any, data map[string]any) ([]byte, error) {
+	switch provider {
+	case "slack":
+		// text is the notification fallback when blocks are present
+		msg := text
+		if title != "" {
+			msg = "*" + title + "*\n" + text
+		}
+		payload := map[string]any{"text": msg}
+		if len(blocks) > 0 {
+			payload["blocks"] = blocks
+		}
+		return json.Marshal(payload)
+
+	case "teams":
+		body := make([]any, 0, len(blocks)+2)
+		if title != "" {
+			body = append(body, map[string]any{"type": "TextBlock", "text": title, "weight": "Bolder", "size": "Medium", "wrap": true})
+		}
+		if text != "" {
+			body = append(body, map[string]any{"type": "TextBlock", "text": text, "wrap": true})
+		}
+		body = append(body, blocks...)
+		return json.Marshal(map[string]any{
+			"type": "message",
+			"attachments": []any{map[string]any{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]any{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+				},
+			}},
+		})
+
+	case "webhook":
+		payload := map[string]any{
+			"title":    title,
+			"text":     text,
+			"workflow": data["workflow"],
+		}
+		if len(blocks) > 0 {
+			payload["blocks"] = blocks
+		}
+		if alert, ok := data["alert"]; ok {
+			payload["alert"] = alert
+		}
+		return json.Marshal(payload)
+
+	default:
+		return nil, fmt.Errorf("unknown notify provider: %s", provider)
+	}
+}
@@ -3,11 +3,17 @@ package workflow
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/google/uuid"
+
 	"sql-proxy/internal/workflow/step"
 )
 
@@ -15,23 +21,81 @@ func (e *Executor) executeQueryStep(ctx context.Context, cs *CompiledStep, execD
 	start := time.Now()
 	result := &StepResult{}
 
-	var sqlBuf bytes.Buffer
-	if err := cs.SQLTmpl.Execute(&sqlBuf, execData.TemplateData); err != nil {
-		result.Error = fmt.Errorf("sql template error: %w", err)
-		result.DurationMs = time.Since(start).Milliseconds()
-		return result, nil
-	}
-	sql := sqlBuf.String()
-
-	params := extractSQLParams(sql, execData.TemplateData)
-
 	opts := step.QueryOptions{
 		Isolation:        cs.Config.Isolation,
 		LockTimeoutMs:    cs.Config.LockTimeoutMs,
 		DeadlockPriority: cs.Config.DeadlockPriority,
 		JSONColumns:      cs.Config.JSONColumns,
-		IsWrite:          &cs.IsWrite,
-		HasReturning:     &cs.HasReturning,
+		Comment:          queryComment(cs, execData),
+	}
+
+	tenant, err := e.resolveTenant(cs.Config.Database, execData)
+	if err != nil {
+		result.Error = err
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+	opts.Tenant = tenant
+
+	var sql string
+	var params map[string]any
+	if cs.Config.Proc != nil {
+		call, err := buildProcCall(cs, execData)
+		if err != nil {
+			result.Error = err
+			result.DurationMs = time.Since(start).Milliseconds()
+			return result, nil
+		}
+		if cs.PublicIDColumns != nil {
+			if err := decodePublicIDProcParams(call, cs.PublicIDColumns); err != nil {
+				result.Error = err
+				result.DurationMs = time.Since(start).Milliseconds()
+				return result, nil
+			}
+		}
+		opts.Proc = call
+	} else {
+		var sqlBuf bytes.Buffer
+		if err := cs.SQLTmpl.Execute(&sqlBuf, execData.TemplateData); err != nil {
+			result.Error = fmt.Errorf("sql template error: %w", err)
+			result.DurationMs = time.Since(start).Milliseconds()
+			return result, nil
+		}
+		sql = sqlBuf.String()
+		params = extractSQLParams(sql, execData.TemplateData)
+		if cs.PublicIDColumns != nil {
+			if err := decodePublicIDParams(params, cs.PublicIDColumns); err != nil {
+				result.Error = err
+				result.DurationMs = time.Since(start).Milliseconds()
+				return result, nil
+			}
+		}
+		opts.IsWrite = &cs.IsWrite
+		opts.HasReturning = &cs.HasReturning
+	}
+
+	if len(cs.OutboxTmpls) > 0 {
+		events, err := renderOutboxEvents(cs.OutboxTmpls, execData)
+		if err != nil {
+			result.Error = err
+			result.DurationMs = time.Since(start).Milliseconds()
+			return result, nil
+		}
+		opts.Outbox = events
+	}
+
+	if cs.ResultLimit != nil {
+		limit := *cs.ResultLimit
+		if cs.PageOffsetTmpl != nil {
+			offset, err := renderPageOffset(cs.PageOffsetTmpl, execData)
+			if err != nil {
+				result.Error = err
+				result.DurationMs = time.Since(start).Milliseconds()
+				return result, nil
+			}
+			limit.Offset = offset
+		}
+		opts.Limit = &limit
 	}
 
 	qr, err := e.dbManager.ExecuteQuery(ctx, cs.Config.Database, sql, params, opts)
@@ -41,22 +105,227 @@ func (e *Executor) executeQueryStep(ctx context.Context, cs *CompiledStep, execD
 		return result, nil
 	}
 
+	if qr.Truncated {
+		if cs.OnLimit == "error" {
+			result.Error = fmt.Errorf("%w (%s)", ErrResultTooLarge, describeLimit(cs.ResultLimit))
+			result.DurationMs = time.Since(start).Milliseconds()
+			return result, nil
+		}
+		result.Truncated = true
+		if cs.OnLimit == "paginate" {
+			next := opts.Limit.Offset + len(qr.Rows)
+			result.NextOffset = &next
+		}
+	}
+
+	// Rows is the first result set, so the later sets are added separately. The
+	// rewrites below all happen before transforms and later steps see the rows.
+	allSets := [][]map[string]any{qr.Rows}
+	if len(qr.ResultSets) > 1 {
+		allSets = append(allSets, qr.ResultSets[1:]...)
+	}
+
+	// Public IDs replace the database's ids
+	if cs.PublicIDColumns != nil {
+		for _, set := range allSets {
+			if err := encodePublicIDColumns(set, cs.PublicIDColumns); err != nil {
+				result.Error = fmt.Errorf("public_id_columns: %w", err)
+				result.DurationMs = time.Since(start).Milliseconds()
+				return result, nil
+			}
+		}
+	}
+
+	if cs.MaskColumns != nil {
+		masked := true
+		if cs.UnmaskWhen != nil {
+			unmask, err := EvalCondition(cs.UnmaskWhen, execData.ExprEnv)
+			if err != nil {
+				result.Error = fmt.Errorf("unmask_when: %w", err)
+				result.DurationMs = time.Since(start).Milliseconds()
+				return result, nil
+			}
+			masked = !unmask
+		}
+		if masked {
+			for _, set := range allSets {
+				maskColumns(set, cs.MaskColumns)
+			}
+		}
+	}
+
+	// Columns outside include_columns or in exclude_columns, then columns the
+	// client did not select with ?fields=, are removed
+	if cs.IncludeColumns != nil || cs.ExcludeColumns != nil {
+		for _, set := range allSets {
+			filterColumns(set, cs.IncludeColumns, cs.ExcludeColumns)
+		}
+	}
+	if fields := selectedFields(ctx, cs.Config.Name); fields != nil {
+		for _, set := range allSets {
+			filterColumns(set, fields, nil)
+		}
+	}
+
+	rows := qr.Rows
+	if cs.Config.Transform != nil {
+		rows, err = applyTransform(cs.Config.Transform, rows)
+		if err != nil {
+			result.Error = fmt.Errorf("transform: %w", err)
+			result.DurationMs = time.Since(start).Milliseconds()
+			return result, nil
+		}
+	}
+
+	// The first result set is the step's data, so it carries the transformed rows
+	sets := [][]map[string]any{rows}
+	if len(qr.ResultSets) > 1 {
+		sets = append(sets, qr.ResultSets[1:]...)
+	}
+
 	result.Success = true
-	result.Data = qr.Rows
-	result.Count = len(qr.Rows)
+	result.Data = rows
+	result.Count = len(rows)
 	result.RowsAffected = qr.RowsAffected
+	result.DataSets = sets
+	if len(cs.Config.ResultSets) > 0 {
+		result.Sets = make(map[string][]map[string]any, len(cs.Config.ResultSets))
+		for i, name := range cs.Config.ResultSets {
+			set := []map[string]any{}
+			if i < len(sets) && sets[i] != nil {
+				set = sets[i]
+			}
+			result.Sets[name] = set
+		}
+	}
+	if cs.Config.Proc != nil {
+		result.OutParams = qr.Outputs
+		if result.OutParams == nil {
+			result.OutParams = map[string]any{}
+		}
+		result.ReturnCode = qr.ReturnCode
+	}
 	result.DurationMs = time.Since(start).Milliseconds()
 
 	e.logger.Debug("query_step_executed", map[string]any{
 		"step":        cs.Config.Name,
 		"database":    cs.Config.Database,
 		"row_count":   result.Count,
+		"set_count":   len(result.DataSets),
 		"duration_ms": result.DurationMs,
 	})
+	if result.Truncated {
+		e.logger.Warn("query_result_truncated", map[string]any{
+			"step":      cs.Config.Name,
+			"database":  cs.Config.Database,
+			"row_count": result.Count,
+			"on_limit":  cs.OnLimit,
+		})
+	}
 
 	return result, nil
 }
 
+// ErrResultTooLarge is returned by query steps whose result exceeds max_rows or
+// max_response_bytes when on_limit is "error".
+var ErrResultTooLarge = errors.New("query result exceeds limit")
+
+// describeLimit names the configured limits for error messages.
+func describeLimit(limit *step.ResultLimit) string {
+	var parts []string
+	if limit.MaxRows > 0 {
+		parts = append(parts, fmt.Sprintf("max_rows %d", limit.MaxRows))
+	}
+	if limit.MaxBytes > 0 {
+		parts = append(parts, fmt.Sprintf("max_response_bytes %d", limit.MaxBytes))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// renderPageOffset evaluates a step's page_offset template. Empty means the first page.
+func renderPageOffset(tmpl *template.Template, execData step.ExecutionData) (int, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, execData.TemplateData); err != nil {
+		return 0, fmt.Errorf("page_offset template error: %w", err)
+	}
+	raw := strings.TrimSpace(buf.String())
+	if raw == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(raw)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("page_offset must be a non-negative integer, got %q", raw)
+	}
+	return offset, nil
+}
+
+// renderOutboxEvents renders a step's outbox events, each with a new time-ordered id
+func renderOutboxEvents(tmpls []CompiledOutboxEvent, execData step.ExecutionData) ([]step.OutboxEvent, error) {
+	events := make([]step.OutboxEvent, len(tmpls))
+	for i, t := range tmpls {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return nil, err
+		}
+		ev := step.OutboxEvent{ID: id.String(), Payload: "{}"}
+		var buf bytes.Buffer
+		if err := t.Topic.Execute(&buf, execData.TemplateData); err != nil {
+			return nil, fmt.Errorf("outbox[%d].topic template error: %w", i, err)
+		}
+		if ev.Topic = strings.TrimSpace(buf.String()); ev.Topic == "" {
+			return nil, fmt.Errorf("outbox[%d].topic rendered empty", i)
+		}
+		if t.Key != nil {
+			buf.Reset()
+			if err := t.Key.Execute(&buf, execData.TemplateData); err != nil {
+				return nil, fmt.Errorf("outbox[%d].key template error: %w", i, err)
+			}
+			ev.Key = strings.TrimSpace(buf.String())
+		}
+		if t.Payload != nil {
+			buf.Reset()
+			if err := t.Payload.Execute(&buf, execData.TemplateData); err != nil {
+				return nil, fmt.Errorf("outbox[%d].payload template error: %w", i, err)
+			}
+			ev.Payload = buf.String()
+		}
+		events[i] = ev
+	}
+	return events, nil
+}
+
+// buildProcCall evaluates the parameter values of a step's stored procedure call.
+func buildProcCall(cs *CompiledStep, execData step.ExecutionData) (*step.ProcCall, error) {
+	proc := cs.Config.Proc
+	call := &step.ProcCall{Name: proc.Name, Params: make([]step.ProcParam, len(proc.Params))}
+	for i, p := range proc.Params {
+		param := step.ProcParam{Name: p.Name, Direction: p.Direction, Type: p.Type}
+		if param.Direction == "" {
+			param.Direction = "in"
+		}
+		if param.Direction != "out" {
+			switch {
+			case cs.ProcExprs[i] != nil:
+				val, err := EvalExpression(cs.ProcExprs[i], execData.ExprEnv)
+				if err != nil {
+					return nil, fmt.Errorf("proc.params[%s]: %w", p.Name, err)
+				}
+				param.Value = val
+			case cs.ProcTmpls[i] != nil:
+				var buf bytes.Buffer
+				if err := cs.ProcTmpls[i].Execute(&buf, execData.TemplateData); err != nil {
+					return nil, fmt.Errorf("proc.params[%s]: %w", p.Name, err)
+				}
+				param.Value = buf.String()
+			default:
+				param.Value = extractSQLParams("@"+p.Name, execData.TemplateData)[p.Name]
+			}
+		}
+		call.Params[i] = param
+	}
+	return call, nil
+}
+
 var sqlParamRegex = regexp.MustCompile(`@([a-zA-Z_][a-zA-Z0-9_]*)`)
 
 // extractSQLParams extracts parameter values from template data for SQL execution.
@@ -116,3 +385,13 @@ func extractSQLParams(sql string, data map[string]any) map[string]any {
 
 	return params
 }
+
+// queryComment identifies a step's statements for databases with sql_comments
+func queryComment(cs *CompiledStep, execData step.ExecutionData) *step.QueryComment {
+	c := &step.QueryComment{Step: cs.Config.Name}
+	if wf, ok := execData.TemplateData["workflow"].(map[string]any); ok {
+		c.Workflow, _ = wf["name"].(string)
+		c.RequestID, _ = wf["request_id"].(string)
+	}
+	return c
+}
@@ -0,0 +1,98 @@
+package workflow
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+// RedirectStatusCodes are the status codes a redirect step may send
+var RedirectStatusCodes = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusSeeOther:          true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
+func (e *Executor) executeRedirectStep(cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{}
+
+	if execData.ResponseWriter == nil {
+		result.Error = fmt.Errorf("redirect step called without ResponseWriter (cron trigger?)")
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	var buf bytes.Buffer
+	if err := cs.URLTmpl.Execute(&buf, execData.TemplateData); err != nil {
+		result.Error = fmt.Errorf("location template error: %w", err)
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+	location := strings.TrimSpace(buf.String())
+	if err := checkLocation(location); err != nil {
+		result.Error = err
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	for name, tmpl := range cs.HeaderTmpls {
+		var headerBuf bytes.Buffer
+		if err := tmpl.Execute(&headerBuf, execData.TemplateData); err != nil {
+			result.Error = fmt.Errorf("header %s template error: %w", name, err)
+			result.DurationMs = time.Since(start).Milliseconds()
+			return result, nil
+		}
+		execData.ResponseWriter.Header().Set(name, headerBuf.String())
+	}
+
+	statusCode := cs.Config.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusFound
+	}
+
+	// The redirect has no body, so the default JSON content type is dropped
+	execData.ResponseWriter.Header().Del("Content-Type")
+	execData.ResponseWriter.Header().Set("Location", location)
+	execData.ResponseWriter.WriteHeader(statusCode)
+
+	result.Success = true
+	result.StatusCode = statusCode
+	result.Values = map[string]any{"location": location}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Debug("redirect_step_executed", map[string]any{
+		"step":        cs.Config.Name,
+		"status_code": statusCode,
+		"location":    location,
+		"duration_ms": result.DurationMs,
+	})
+
+	return result, nil
+}
+
+// checkLocation rejects rendered locations that are empty, not a URL or path,
+// or that name a scheme other than http and https
+func checkLocation(location string) error {
+	if location == "" {
+		return fmt.Errorf("location rendered empty")
+	}
+	if strings.ContainsAny(location, "\r\n") {
+		return fmt.Errorf("location cannot contain line breaks")
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return fmt.Errorf("invalid location: %w", err)
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("location scheme '%s' is not http or https", u.Scheme)
+	}
+	return nil
+}
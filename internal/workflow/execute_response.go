@@ -3,8 +3,10 @@ package workflow
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"sql-proxy/internal/workflow/step"
@@ -27,6 +29,29 @@ func (e *Executor) executeResponseStep(ctx context.Context, cs *CompiledStep, ex
 		return result, nil
 	}
 
+	if e.strictResponses && cs.ResponseSchema != nil {
+		if err := e.checkResponseSchema(cs, buf.Bytes()); err != nil {
+			result.Error = err
+			result.DurationMs = time.Since(start).Milliseconds()
+			return result, nil
+		}
+	}
+
+	// Once response_sse steps have opened an event stream, the response is its final event
+	if stream, ok := execData.ResponseWriter.(*eventStream); ok && stream.open {
+		if err := stream.send(sseEventComplete, buf.Bytes()); err != nil {
+			result.Error = fmt.Errorf("write response error: %w", err)
+			result.DurationMs = time.Since(start).Milliseconds()
+			return result, nil
+		}
+		stream.closed = true
+		result.Success = true
+		result.StatusCode = http.StatusOK
+		result.ResponseBody = buf.String()
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
 	for name, tmpl := range cs.HeaderTmpls {
 		var headerBuf bytes.Buffer
 		if err := tmpl.Execute(&headerBuf, execData.TemplateData); err != nil {
@@ -42,7 +67,14 @@ func (e *Executor) executeResponseStep(ctx context.Context, cs *CompiledStep, ex
 		statusCode = http.StatusOK
 	}
 
-	execData.ResponseWriter.Header().Set("Content-Type", "application/json")
+	// A Content-Type from headers wins over the one of the template type
+	if !hasHeader(cs.Config.Headers, "Content-Type") {
+		contentType := "application/json"
+		if cs.Config.TemplateType == "html" || cs.Config.Type == StepTypePage {
+			contentType = "text/html; charset=utf-8"
+		}
+		execData.ResponseWriter.Header().Set("Content-Type", contentType)
+	}
 	execData.ResponseWriter.WriteHeader(statusCode)
 	if _, err := execData.ResponseWriter.Write(buf.Bytes()); err != nil {
 		result.Error = fmt.Errorf("write response error: %w", err)
@@ -64,3 +96,36 @@ func (e *Executor) executeResponseStep(ctx context.Context, cs *CompiledStep, ex
 
 	return result, nil
 }
+
+// checkResponseSchema validates a rendered response body against the step's schema,
+// logging every violation so template drift shows up before clients notice it.
+func (e *Executor) checkResponseSchema(cs *CompiledStep, body []byte) error {
+	var violations []string
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		violations = []string{"body is not valid JSON: " + err.Error()}
+	} else {
+		for _, v := range cs.ResponseSchema.Validate(value) {
+			violations = append(violations, v.Error())
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	e.logger.Error("response_schema_mismatch", map[string]any{
+		"step":       cs.Config.Name,
+		"violations": violations,
+	})
+	return fmt.Errorf("response does not match schema: %s", strings.Join(violations, "; "))
+}
+
+// hasHeader reports whether headers configures name, case-insensitively
+func hasHeader(headers map[string]string, name string) bool {
+	for h := range headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
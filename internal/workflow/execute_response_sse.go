@@ -0,0 +1,201 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+// DefaultSSEBatchSize is the number of source rows per event when batch_size is not set
+const DefaultSSEBatchSize = 100
+
+// sseWriteTimeout bounds each event write. Every write moves the connection's
+// write deadline, so a stream may outlive the server's write timeout.
+const sseWriteTimeout = 30 * time.Second
+
+// Events that end a stream; response_sse steps cannot use these names
+const (
+	sseEventComplete = "complete"
+	sseEventError    = "error"
+)
+
+// eventStream is the response writer of workflows with response_sse steps.
+// The first event switches the response to text/event-stream; until then it
+// passes everything through, so a workflow can still answer with a plain
+// response step.
+type eventStream struct {
+	http.ResponseWriter
+	rc          *http.ResponseController
+	open        bool // Event stream headers sent
+	closed      bool // Final event sent
+	wroteHeader bool // A plain response was started
+	wf          *WorkflowConfig
+}
+
+func newEventStream(w http.ResponseWriter, wf *WorkflowConfig) *eventStream {
+	return &eventStream{ResponseWriter: w, rc: http.NewResponseController(w), wf: wf}
+}
+
+func (s *eventStream) WriteHeader(code int) {
+	s.wroteHeader = true
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *eventStream) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *eventStream) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// send writes one event, opening the stream first if needed
+func (s *eventStream) send(event string, data []byte) error {
+	if s.closed {
+		return errors.New("event stream already closed")
+	}
+	if !s.open {
+		if s.wroteHeader {
+			return errors.New("response already sent")
+		}
+		h := s.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+		h.Del("Content-Length")
+		s.ResponseWriter.WriteHeader(http.StatusOK)
+		s.open = true
+	}
+
+	var buf bytes.Buffer
+	if event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event)
+	}
+	// Each line of the data gets its own data: field; clients join them with newlines
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		buf.WriteString("data: ")
+		buf.WriteString(strings.TrimSuffix(line, "\r"))
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	// Not every writer supports deadlines (tests, response capture)
+	if err := s.rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if _, err := s.ResponseWriter.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// finish ends an open stream with a complete or error event, as the handler's
+// default response would have reported the outcome. A stream that a response
+// step already closed, or that never opened, is left alone.
+func (s *eventStream) finish(result *ExecuteResult, requestID string) {
+	if !s.open || s.closed {
+		return
+	}
+	event, data := sseEventComplete, s.wf.Envelope.successBody(nil, requestID)
+	if result.Error != nil {
+		status, message := http.StatusInternalServerError, "workflow execution failed"
+		if errors.Is(result.Error, ErrResultTooLarge) {
+			message = "query result too large"
+		} else {
+			// The stream already has its status; the mapped one only appears in problem documents
+			status, message = s.wf.mapStatus(errorClass(result.Error), status, message)
+		}
+		event, data = sseEventError, s.wf.errorBody(status, message, errorCode(result.Error), requestID)
+	}
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	// The client may be gone; there is no one left to report a failed write to
+	_ = s.send(event, data)
+	s.closed = true
+	result.ResponseSent = true
+}
+
+// executeResponseSSEStep sends an event to the client: the rendered template,
+// or the rows of source as JSON arrays of up to batch_size rows each.
+func (e *Executor) executeResponseSSEStep(ctx context.Context, cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{}
+
+	fail := func(err error) (*StepResult, error) {
+		result.Error = err
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	stream, ok := execData.ResponseWriter.(*eventStream)
+	if !ok {
+		return fail(errors.New("response_sse step called without an HTTP request (cron trigger?)"))
+	}
+	event := cs.Config.Event
+	if event == "" {
+		event = "message"
+	}
+
+	events := 0
+	if cs.SourceProg != nil {
+		value, err := EvalExpression(cs.SourceProg, execData.ExprEnv)
+		if err != nil {
+			return fail(fmt.Errorf("source: %w", err))
+		}
+		var rows []map[string]any
+		if value != nil {
+			if rows, ok = toRows(value); !ok {
+				return fail(fmt.Errorf("source must be an object or a list of objects, got %T", value))
+			}
+		}
+		batchSize := cs.Config.BatchSize
+		if batchSize <= 0 {
+			batchSize = DefaultSSEBatchSize
+		}
+		for offset := 0; offset < len(rows); offset += batchSize {
+			if err := ctx.Err(); err != nil {
+				return fail(err)
+			}
+			data, err := json.Marshal(rows[offset:min(offset+batchSize, len(rows))])
+			if err != nil {
+				return fail(fmt.Errorf("encoding rows %d-%d: %w", offset+1, min(offset+batchSize, len(rows)), err))
+			}
+			if err := stream.send(event, data); err != nil {
+				return fail(fmt.Errorf("write event error: %w", err))
+			}
+			events++
+		}
+		result.Count = len(rows)
+	} else {
+		var buf bytes.Buffer
+		if err := cs.TemplateTmpl.Execute(&buf, execData.TemplateData); err != nil {
+			return fail(fmt.Errorf("response_sse template error: %w", err))
+		}
+		if err := stream.send(event, buf.Bytes()); err != nil {
+			return fail(fmt.Errorf("write event error: %w", err))
+		}
+		events = 1
+	}
+
+	result.Success = true
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Debug("response_sse_step_executed", map[string]any{
+		"step":        cs.Config.Name,
+		"event":       event,
+		"events":      events,
+		"duration_ms": result.DurationMs,
+	})
+
+	return result, nil
+}
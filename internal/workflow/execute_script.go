@@ -0,0 +1,185 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+// DefaultScriptTimeout bounds a script's run time when the step sets no timeout_sec.
+const DefaultScriptTimeout = 5 * time.Second
+
+// DefaultScriptMemoryMB bounds how much a script may grow the heap when memory_mb is unset.
+const DefaultScriptMemoryMB = 64
+
+// scriptMaxCallStack bounds script recursion, which would otherwise grow the Go stack until the process dies
+const scriptMaxCallStack = 1000
+
+// scriptSampleInterval is how often heap usage is checked while a script runs
+const scriptSampleInterval = 10 * time.Millisecond
+
+// scriptHeapMetric is the heap memory occupied by objects, live or not yet swept
+const scriptHeapMetric = "/memory/classes/heap/objects:bytes"
+
+// executeScriptStep runs a script step's JavaScript in a fresh runtime. The runtime has only the
+// standard ECMAScript builtins, so scripts have no I/O, and it is interrupted when the step's
+// deadline passes or the heap grows past the step's memory limit.
+func (e *Executor) executeScriptStep(ctx context.Context, cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{}
+
+	// timeout_sec is applied by the executor; without it the script still gets a deadline
+	if cs.Config.TimeoutSec <= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultScriptTimeout)
+		defer cancel()
+	}
+	memoryMB := DefaultScriptMemoryMB
+	if cs.Config.MemoryMB > 0 {
+		memoryMB = cs.Config.MemoryMB
+	}
+
+	value, err := runScript(ctx, cs.ScriptProg, execData.ExprEnv, uint64(memoryMB)<<20)
+	if err != nil {
+		result.Error = err
+	} else {
+		result.Success = true
+		result.Output = value
+		if rows, ok := toRows(value); ok {
+			result.Data = rows
+			result.Count = len(rows)
+		}
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Debug("script_step_executed", map[string]any{
+		"step":        cs.Config.Name,
+		"success":     result.Success,
+		"count":       result.Count,
+		"duration_ms": result.DurationMs,
+	})
+
+	return result, nil
+}
+
+// runScript runs a compiled script and returns its result as Go values. The script sees a copy
+// of the environment's data made through JSON, so it can't change the results of other steps.
+func runScript(ctx context.Context, prog *goja.Program, env map[string]any, memoryLimit uint64) (any, error) {
+	input, err := scriptInput(env)
+	if err != nil {
+		return nil, fmt.Errorf("script: encoding input: %w", err)
+	}
+
+	rt := goja.New()
+	rt.SetMaxCallStackSize(scriptMaxCallStack)
+	parse, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("parse"))
+	globals, err := parse(goja.Undefined(), rt.ToValue(string(input)))
+	if err != nil {
+		return nil, fmt.Errorf("script: decoding input: %w", err)
+	}
+	obj := globals.ToObject(rt)
+	for _, name := range obj.Keys() {
+		if err := rt.Set(name, obj.Get(name)); err != nil {
+			return nil, fmt.Errorf("script: %w", err)
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go watchScript(ctx, rt, heapBytes(), memoryLimit, done)
+
+	fn, err := rt.RunProgram(prog)
+	if err == nil {
+		run, _ := goja.AssertFunction(fn)
+		var out goja.Value
+		if out, err = run(goja.Undefined()); err == nil {
+			return out.Export(), nil
+		}
+	}
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		if cause, ok := interrupted.Value().(error); ok {
+			return nil, fmt.Errorf("script: %w", cause)
+		}
+	}
+	return nil, fmt.Errorf("script: %w", err)
+}
+
+// scriptInput encodes the data of an expression environment as JSON, leaving out its functions
+func scriptInput(env map[string]any) ([]byte, error) {
+	data := make(map[string]any, len(env))
+	for name, v := range env {
+		if _, fn := exprFuncs[name]; !fn {
+			data[name] = v
+		}
+	}
+	return json.Marshal(data)
+}
+
+// watchScript interrupts rt when ctx ends or the heap has grown more than limit bytes past base,
+// until done is closed. The heap is shared with the rest of the process, so a sample over the
+// limit is checked again after a garbage collection before the script is stopped.
+func watchScript(ctx context.Context, rt *goja.Runtime, base, limit uint64, done <-chan struct{}) {
+	ticker := time.NewTicker(scriptSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			rt.Interrupt(ctx.Err())
+			return
+		case <-ticker.C:
+			if heapBytes() <= base+limit {
+				continue
+			}
+			runtime.GC()
+			heap := heapBytes()
+			if heap <= base+limit {
+				// Part of base was garbage; measure from the live heap from now on
+				base = min(base, heap)
+				continue
+			}
+			rt.Interrupt(fmt.Errorf("memory limit of %d MB exceeded", limit>>20))
+			return
+		}
+	}
+}
+
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: scriptHeapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// toRows converts an object or a list of objects into result rows.
+func toRows(value any) ([]map[string]any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		return []map[string]any{v}, true
+	case []map[string]any:
+		return v, true
+	case []any:
+		rows := make([]map[string]any, 0, len(v))
+		for _, item := range v {
+			row, ok := item.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			rows = append(rows, row)
+		}
+		return rows, true
+	}
+	return nil, false
+}
@@ -0,0 +1,45 @@
+package workflow
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+func (e *Executor) executeSetStep(cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{Values: make(map[string]any, len(cs.SetExprs)+len(cs.SetTmpls))}
+
+	// Values are evaluated against the state before this step, so they cannot reference each other
+	for name, prog := range cs.SetExprs {
+		val, err := EvalExpression(prog, execData.ExprEnv)
+		if err != nil {
+			result.Error = fmt.Errorf("values[%s]: %w", name, err)
+			result.DurationMs = time.Since(start).Milliseconds()
+			return result, nil
+		}
+		result.Values[name] = val
+	}
+	for name, tmpl := range cs.SetTmpls {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, execData.TemplateData); err != nil {
+			result.Error = fmt.Errorf("values[%s]: %w", name, err)
+			result.DurationMs = time.Since(start).Milliseconds()
+			return result, nil
+		}
+		result.Values[name] = buf.String()
+	}
+
+	result.Success = true
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Debug("set_step_executed", map[string]any{
+		"step":        cs.Config.Name,
+		"values":      len(result.Values),
+		"duration_ms": result.DurationMs,
+	})
+
+	return result, nil
+}
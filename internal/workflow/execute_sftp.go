@@ -0,0 +1,106 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+func (e *Executor) executeSFTPStep(ctx context.Context, cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{}
+
+	fail := func(err error) (*StepResult, error) {
+		result.Error = err
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	if e.uploader == nil {
+		return fail(errors.New("sftp step requires sftp servers to be configured"))
+	}
+
+	remotePath, err := renderTemplateField("remote_path", cs.RemotePathTmpl, execData.TemplateData)
+	if err != nil {
+		return fail(err)
+	}
+	if remotePath == "" {
+		return fail(errors.New("remote_path must not render empty"))
+	}
+
+	body, err := renderStepContent(cs, execData)
+	if err != nil {
+		return fail(err)
+	}
+
+	// Retry configuration (same defaults as httpcall)
+	maxAttempts := 1
+	if cs.Config.Retry != nil && cs.Config.Retry.Enabled {
+		maxAttempts = cs.Config.Retry.MaxAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = 3
+		}
+	}
+
+	backoff := 1 * time.Second
+	if cs.Config.Retry != nil && cs.Config.Retry.InitialBackoffSec > 0 {
+		backoff = time.Duration(cs.Config.Retry.InitialBackoffSec) * time.Second
+	}
+	maxBackoff := 30 * time.Second
+	if cs.Config.Retry != nil && cs.Config.Retry.MaxBackoffSec > 0 {
+		maxBackoff = time.Duration(cs.Config.Retry.MaxBackoffSec) * time.Second
+	}
+
+	attempt := 1
+	for ; ; attempt++ {
+		err = e.uploader.Upload(ctx, cs.Config.Server, remotePath, body, cs.Config.CreateDirs)
+		if err == nil || attempt >= maxAttempts {
+			break
+		}
+
+		e.logger.Warn("sftp_attempt_failed", map[string]any{
+			"step":    cs.Config.Name,
+			"attempt": attempt,
+			"error":   err.Error(),
+		})
+
+		select {
+		case <-ctx.Done():
+			return fail(ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	if err != nil {
+		if attempt > 1 {
+			err = fmt.Errorf("%w (after %d attempts)", err, attempt)
+		}
+		return fail(err)
+	}
+
+	result.Success = true
+	result.Values = map[string]any{
+		"server":   cs.Config.Server,
+		"path":     remotePath,
+		"size":     len(body),
+		"attempts": attempt,
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Info("sftp_step_uploaded", map[string]any{
+		"step":        cs.Config.Name,
+		"server":      cs.Config.Server,
+		"path":        remotePath,
+		"size":        len(body),
+		"attempts":    attempt,
+		"duration_ms": result.DurationMs,
+	})
+
+	return result, nil
+}
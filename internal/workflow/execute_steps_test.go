@@ -6,10 +6,13 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"text/template"
+	"time"
 
+	"sql-proxy/internal/publicid"
 	"sql-proxy/internal/workflow/step"
 )
 
@@ -104,6 +107,97 @@ func TestExecuteQueryStep_Success(t *testing.T) {
 	}
 }
 
+func TestExecuteQueryStep_Outbox(t *testing.T) {
+	var got []step.OutboxEvent
+	dbm := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			got = opts.Outbox
+			return &step.QueryResult{RowsAffected: 1}, nil
+		},
+	}
+	exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:     "create_order",
+		Triggers: []TriggerConfig{{Type: "http", Path: "/orders", Method: "POST"}},
+		Steps: []StepConfig{{
+			Name:     "insert",
+			Type:     "query",
+			Database: "testdb",
+			SQL:      "INSERT INTO orders (id) VALUES (@id)",
+			Outbox: []OutboxEventConfig{
+				{Topic: "order.created", Key: "{{.trigger.params.id}}", Payload: `{"id": {{.trigger.params.id}}}`},
+				{Topic: "audit"},
+			},
+		}},
+	})
+	execData := step.ExecutionData{
+		TemplateData: map[string]any{"trigger": map[string]any{"params": map[string]any{"id": 42}}},
+	}
+
+	result, err := exec.executeQueryStep(context.Background(), wf.Steps[0], execData)
+	if err != nil || !result.Success {
+		t.Fatalf("step failed: %v %v", err, result.Error)
+	}
+	if len(got) != 2 {
+		t.Fatalf("events = %+v", got)
+	}
+	if got[0].Topic != "order.created" || got[0].Key != "42" || got[0].Payload != `{"id": 42}` {
+		t.Errorf("event = %+v", got[0])
+	}
+	if got[1].Topic != "audit" || got[1].Key != "" || got[1].Payload != "{}" {
+		t.Errorf("default event = %+v", got[1])
+	}
+	// Ids are unique and time-ordered, so the relay's ORDER BY keeps insertion order
+	if got[0].ID == "" || got[0].ID >= got[1].ID {
+		t.Errorf("ids = %s, %s", got[0].ID, got[1].ID)
+	}
+}
+
+func TestExecuteQueryStep_Transform(t *testing.T) {
+	dbm := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{
+				Rows: []map[string]any{
+					{"customer_id": 1, "order_id": 10},
+					{"customer_id": 1, "order_id": 11},
+					{"customer_id": 2, "order_id": 12},
+				},
+			}, nil
+		},
+	}
+	exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+	cs := &CompiledStep{
+		Config: &StepConfig{
+			Name: "orders", Type: "query", Database: "testdb",
+			Transform: &TransformConfig{GroupBy: "customer_id", NestAs: "orders"},
+		},
+		SQLTmpl: template.Must(template.New("test").Parse("SELECT customer_id, order_id FROM orders")),
+	}
+
+	result, err := exec.executeQueryStep(context.Background(), cs, step.ExecutionData{TemplateData: map[string]any{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("Success = false: %v", result.Error)
+	}
+	if result.Count != 2 {
+		t.Errorf("Count = %d, want 2 groups", result.Count)
+	}
+	if orders := result.Data[0]["orders"].([]map[string]any); len(orders) != 2 {
+		t.Errorf("first group has %d orders, want 2", len(orders))
+	}
+
+	cs.Config.Transform = &TransformConfig{ParseJSON: []string{"order_id"}}
+	dbm.queryFunc = func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+		return &step.QueryResult{Rows: []map[string]any{{"order_id": "{bad"}}}, nil
+	}
+	result, _ = exec.executeQueryStep(context.Background(), cs, step.ExecutionData{TemplateData: map[string]any{}})
+	if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), "transform:") {
+		t.Errorf("expected transform error, got success=%v err=%v", result.Success, result.Error)
+	}
+}
+
 func TestExecuteHTTPCallStep_URLTemplateError(t *testing.T) {
 	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
 	cs := &CompiledStep{
@@ -235,6 +329,9 @@ func TestExecuteHTTPCallStep_Non2xxResponse(t *testing.T) {
 	if result.StatusCode != 404 {
 		t.Errorf("StatusCode = %d, want 404", result.StatusCode)
 	}
+	if errorClass(result.Error) != ErrorClassNotFound {
+		t.Errorf("Error = %v, want a not_found HTTPStatusError", result.Error)
+	}
 }
 
 func TestExecuteHTTPCallStep_JSONParse(t *testing.T) {
@@ -690,6 +787,67 @@ func TestExecuteResponseStep_DefaultStatusCode(t *testing.T) {
 	}
 }
 
+func TestExecuteResponseStep_StrictSchema(t *testing.T) {
+	schema, err := CompileSchema(map[string]any{
+		"type":     "object",
+		"required": []any{"id", "name"},
+		"properties": map[string]any{
+			"id":   map[string]any{"type": "integer"},
+			"name": map[string]any{"type": "string"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CompileSchema failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		strict   bool
+		template string
+		wantErr  string
+	}{
+		{"matches", true, `{"id": 1, "name": "a"}`, ""},
+		{"drift", true, `{"id": "1"}`, `response does not match schema: /name: is required; /id: must be integer, got string`},
+		{"not JSON", true, `{"id": 1,}`, "body is not valid JSON"},
+		{"not strict", false, `{"id": "1"}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &testLogger{}
+			exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, logger)
+			exec.SetStrictResponses(tt.strict)
+			cs := &CompiledStep{
+				Config:         &StepConfig{Name: "respond", Type: "response"},
+				TemplateTmpl:   template.Must(template.New("test").Parse(tt.template)),
+				ResponseSchema: schema,
+			}
+
+			recorder := httptest.NewRecorder()
+			result, err := exec.executeResponseStep(context.Background(), cs, step.ExecutionData{ResponseWriter: recorder})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.wantErr == "" {
+				if !result.Success {
+					t.Errorf("Success = false, error %v", result.Error)
+				}
+				return
+			}
+			if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr) {
+				t.Errorf("Error = %v, want %q", result.Error, tt.wantErr)
+			}
+			if recorder.Body.Len() != 0 {
+				t.Errorf("body was written despite mismatch: %s", recorder.Body.String())
+			}
+			if len(logger.errorCalls) != 1 || logger.errorCalls[0].msg != "response_schema_mismatch" {
+				t.Errorf("error logs = %v, want response_schema_mismatch", logger.errorCalls)
+			}
+		})
+	}
+}
+
 func TestExecuteHTTPCallStep_ContextCancelledDuringRetry(t *testing.T) {
 	attempts := 0
 	ctx, cancel := context.WithCancel(context.Background())
@@ -839,6 +997,62 @@ func TestExecuteHTTPCallStep_RetryConnectionError(t *testing.T) {
 	}
 }
 
+// failingFaults fails the first failures step runs it sees
+type failingFaults struct {
+	failures int
+	calls    []string
+}
+
+func (f *failingFaults) Step(ctx context.Context, workflow, name string) error {
+	f.calls = append(f.calls, workflow+"."+name)
+	if len(f.calls) <= f.failures {
+		return errors.New("injected fault")
+	}
+	return nil
+}
+
+func TestExecuteHTTPCallStep_FaultInjection(t *testing.T) {
+	attempts := 0
+	client := &mockHTTPClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			attempts++
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{}`)),
+				Header:     make(http.Header),
+			}, nil
+		},
+	}
+	faults := &failingFaults{failures: 1}
+	exec := NewExecutor(&mockDBManager{}, client, nil, &testLogger{})
+	exec.SetFaultInjector(faults)
+	cs := &CompiledStep{
+		Config: &StepConfig{
+			Name:       "test",
+			Type:       "httpcall",
+			HTTPMethod: "GET",
+			Retry:      &RetryConfig{Enabled: true, MaxAttempts: 2, InitialBackoffSec: 0},
+		},
+		URLTmpl: template.Must(template.New("url").Parse("https://api.example.com")),
+	}
+
+	execData := step.ExecutionData{
+		TemplateData: map[string]any{},
+	}
+
+	ctx := context.WithValue(context.Background(), stepFaultKey{}, stepFault{workflow: "wf", name: "test"})
+	result, err := exec.executeHTTPCallStep(ctx, cs, execData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Errorf("Success = false, want true after the injected fault is retried")
+	}
+	if attempts != 1 || len(faults.calls) != 2 || faults.calls[0] != "wf.test" {
+		t.Errorf("attempts = %d, fault calls = %v, want 1 attempt sent after 2 fault checks", attempts, faults.calls)
+	}
+}
+
 func TestExecuteHTTPCallStep_StepTimeout(t *testing.T) {
 	client := &mockHTTPClient{
 		doFunc: func(req *http.Request) (*http.Response, error) {
@@ -932,6 +1146,105 @@ func TestExecuteResponseStep_CustomHeaders(t *testing.T) {
 	}
 }
 
+func TestExecuteRedirectStep(t *testing.T) {
+	tests := []struct {
+		name       string
+		location   string
+		statusCode int
+		wantStatus int
+		wantErr    string
+	}{
+		{name: "default status", location: "  https://cdn.example.com/{{.id}}.png\n", wantStatus: http.StatusFound},
+		{name: "permanent path", location: "/items/{{.id}}", statusCode: http.StatusMovedPermanently, wantStatus: http.StatusMovedPermanently},
+		{name: "empty", location: "{{if false}}x{{end}}", wantErr: "location rendered empty"},
+		{name: "other scheme", location: "javascript:alert({{.id}})", wantErr: "scheme 'javascript'"},
+		{name: "line break", location: "/a\r\nSet-Cookie: x", wantErr: "line breaks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+			cs := &CompiledStep{
+				Config:  &StepConfig{Name: "go", Type: "redirect", StatusCode: tt.statusCode},
+				URLTmpl: template.Must(template.New("location").Parse(tt.location)),
+				HeaderTmpls: map[string]*template.Template{
+					"Cache-Control": template.Must(template.New("h").Parse("no-store")),
+				},
+			}
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			execData := step.ExecutionData{
+				TemplateData:   map[string]any{"id": 7},
+				ResponseWriter: recorder,
+			}
+
+			result, err := exec.executeRedirectStep(cs, execData)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" {
+				if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", result.Error, tt.wantErr)
+				}
+				if recorder.Header().Get("Location") != "" {
+					t.Error("expected no Location header")
+				}
+				return
+			}
+			if !result.Success || result.StatusCode != tt.wantStatus || recorder.Code != tt.wantStatus {
+				t.Fatalf("result = %+v, code = %d, want %d", result, recorder.Code, tt.wantStatus)
+			}
+			want := strings.TrimSpace(strings.ReplaceAll(tt.location, "{{.id}}", "7"))
+			if got := recorder.Header().Get("Location"); got != want {
+				t.Errorf("Location = %q, want %q", got, want)
+			}
+			if result.Values["location"] != want {
+				t.Errorf("location value = %v", result.Values["location"])
+			}
+			if recorder.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("Cache-Control = %q", recorder.Header().Get("Cache-Control"))
+			}
+			if recorder.Header().Get("Content-Type") != "" || recorder.Body.Len() != 0 {
+				t.Errorf("expected an empty body without Content-Type, got %q %q", recorder.Header().Get("Content-Type"), recorder.Body.String())
+			}
+		})
+	}
+}
+
+// TestExecutePageStep verifies page steps render escaped HTML with text/html
+func TestExecutePageStep(t *testing.T) {
+	cs, err := compileStep(&StepConfig{
+		Name:     "page",
+		Type:     "page",
+		Template: `<h1>{{.name}}</h1><a href="/items?q={{.name}}">again</a>`,
+		Headers:  map[string]string{"Cache-Control": "no-store"},
+	}, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("compileStep: %v", err)
+	}
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+	recorder := httptest.NewRecorder()
+	result, err := exec.executeResponseStep(context.Background(), cs, step.ExecutionData{
+		TemplateData:   map[string]any{"name": "<b>a&b</b>"},
+		ResponseWriter: recorder,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success || recorder.Code != http.StatusOK {
+		t.Fatalf("result = %+v, code = %d", result, recorder.Code)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if recorder.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q", recorder.Header().Get("Cache-Control"))
+	}
+	want := `<h1>&lt;b&gt;a&amp;b&lt;/b&gt;</h1><a href="/items?q=%3cb%3ea%26b%3c%2fb%3e">again</a>`
+	if recorder.Body.String() != want {
+		t.Errorf("body = %q, want %q", recorder.Body.String(), want)
+	}
+}
+
 func TestExecuteQueryStep_PassesQueryOptions(t *testing.T) {
 	lockTimeout := 5000
 	var capturedOpts step.QueryOptions
@@ -958,7 +1271,7 @@ func TestExecuteQueryStep_PassesQueryOptions(t *testing.T) {
 	}
 
 	execData := step.ExecutionData{
-		TemplateData: map[string]any{},
+		TemplateData: map[string]any{"workflow": map[string]any{"name": "orders", "request_id": "req-1"}},
 	}
 
 	_, err := exec.executeQueryStep(context.Background(), cs, execData)
@@ -984,6 +1297,406 @@ func TestExecuteQueryStep_PassesQueryOptions(t *testing.T) {
 	if capturedOpts.HasReturning == nil || *capturedOpts.HasReturning != false {
 		t.Errorf("HasReturning = %v, want false", capturedOpts.HasReturning)
 	}
+	if c := capturedOpts.Comment; c == nil || *c != (step.QueryComment{Workflow: "orders", Step: "test", RequestID: "req-1"}) {
+		t.Errorf("Comment = %+v, want orders/test/req-1", c)
+	}
+}
+
+func TestQueryComment_String(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment step.QueryComment
+		want    string
+	}{
+		{"all fields", step.QueryComment{Workflow: "orders", Step: "fetch", RequestID: "a1b2-c3"}, "/* workflow=orders step=fetch request_id=a1b2-c3 */"},
+		{"no request id", step.QueryComment{Workflow: "orders", Step: "fetch"}, "/* workflow=orders step=fetch */"},
+		{"comment terminator", step.QueryComment{Workflow: "orders", Step: "fetch", RequestID: "x */ DROP TABLE t; /*"}, "/* workflow=orders step=fetch request_id=x____DROP_TABLE_t____ */"},
+		{"non-ascii", step.QueryComment{Workflow: "café", Step: "s"}, "/* workflow=caf_ step=s */"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.comment.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteQueryStep_ResultSets(t *testing.T) {
+	dbm := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			orders := []map[string]any{{"id": 1, "secret": "x"}}
+			return &step.QueryResult{
+				Rows:       orders,
+				ResultSets: [][]map[string]any{orders, {{"sku": "a"}, {"sku": "b"}}},
+			}, nil
+		},
+	}
+	exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+	cs := &CompiledStep{
+		Config: &StepConfig{
+			Name:       "batch",
+			Type:       "query",
+			Database:   "testdb",
+			Transform:  &TransformConfig{Exclude: []string{"secret"}},
+			ResultSets: []string{"orders", "lines", "totals"},
+		},
+		SQLTmpl: template.Must(template.New("test").Parse("SELECT * FROM orders; SELECT * FROM lines")),
+	}
+
+	result, err := exec.executeQueryStep(context.Background(), cs, step.ExecutionData{TemplateData: map[string]any{}})
+	if err != nil || !result.Success {
+		t.Fatalf("step failed: %v %v", err, result.Error)
+	}
+
+	if len(result.DataSets) != 2 {
+		t.Fatalf("DataSets = %v, want 2 sets", result.DataSets)
+	}
+	// The transform shapes the first set, which is also the step's data
+	if _, ok := result.DataSets[0][0]["secret"]; ok {
+		t.Error("data_sets[0] should hold the transformed rows")
+	}
+	if len(result.DataSets[1]) != 2 {
+		t.Errorf("data_sets[1] = %v, want 2 rows", result.DataSets[1])
+	}
+	if len(result.Sets["orders"]) != 1 || len(result.Sets["lines"]) != 2 {
+		t.Errorf("Sets = %v, want orders and lines", result.Sets)
+	}
+	if set, ok := result.Sets["totals"]; !ok || len(set) != 0 {
+		t.Errorf("Sets[totals] = %v, want empty set for a missing result set", set)
+	}
+	if result.OutParams != nil || result.ReturnCode != nil {
+		t.Error("plain queries should not carry procedure outputs")
+	}
+}
+
+func TestExecuteQueryStep_ResultLimit(t *testing.T) {
+	tests := []struct {
+		name           string
+		onLimit        string
+		pageOffset     string
+		truncated      bool
+		wantErr        bool
+		wantOffset     int
+		wantNextOffset int // 0 = no next page
+	}{
+		{name: "within limit", onLimit: "error"},
+		{name: "error", onLimit: "error", truncated: true, wantErr: true},
+		{name: "truncate", onLimit: "truncate", truncated: true},
+		{name: "paginate first page", onLimit: "paginate", truncated: true, wantNextOffset: 2},
+		{name: "paginate offset", onLimit: "paginate", pageOffset: "{{.trigger.params.offset}}", truncated: true, wantOffset: 4, wantNextOffset: 6},
+		{name: "paginate last page", onLimit: "paginate", pageOffset: "{{.trigger.params.offset}}", wantOffset: 4},
+		{name: "bad offset", onLimit: "paginate", pageOffset: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var capturedLimit *step.ResultLimit
+			dbm := &mockDBManager{
+				queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+					capturedLimit = opts.Limit
+					return &step.QueryResult{Rows: []map[string]any{{"id": 1}, {"id": 2}}, Truncated: tt.truncated}, nil
+				},
+			}
+			exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+			cs := &CompiledStep{
+				Config:      &StepConfig{Name: "fetch", Type: "query", Database: "testdb"},
+				SQLTmpl:     template.Must(template.New("test").Parse("SELECT id FROM t ORDER BY id")),
+				ResultLimit: &step.ResultLimit{MaxRows: 2},
+				OnLimit:     tt.onLimit,
+			}
+			if tt.pageOffset != "" {
+				cs.PageOffsetTmpl = template.Must(template.New("page_offset").Parse(tt.pageOffset))
+			}
+			execData := step.ExecutionData{TemplateData: map[string]any{
+				"trigger": map[string]any{"params": map[string]any{"offset": 4}},
+			}}
+
+			result, err := exec.executeQueryStep(context.Background(), cs, execData)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				if result.Success || result.Error == nil {
+					t.Fatal("expected step to fail")
+				}
+				if tt.truncated && !errors.Is(result.Error, ErrResultTooLarge) {
+					t.Errorf("error = %v, want ErrResultTooLarge", result.Error)
+				}
+				return
+			}
+			if !result.Success {
+				t.Fatalf("step failed: %v", result.Error)
+			}
+			if capturedLimit == nil || capturedLimit.MaxRows != 2 || capturedLimit.Offset != tt.wantOffset {
+				t.Errorf("limit = %+v, want max_rows 2 offset %d", capturedLimit, tt.wantOffset)
+			}
+			if result.Truncated != tt.truncated {
+				t.Errorf("Truncated = %v, want %v", result.Truncated, tt.truncated)
+			}
+			switch {
+			case tt.wantNextOffset == 0 && result.NextOffset != nil:
+				t.Errorf("NextOffset = %d, want none", *result.NextOffset)
+			case tt.wantNextOffset != 0 && (result.NextOffset == nil || *result.NextOffset != tt.wantNextOffset):
+				t.Errorf("NextOffset = %v, want %d", result.NextOffset, tt.wantNextOffset)
+			}
+			if cs.ResultLimit.Offset != 0 {
+				t.Error("the compiled limit must not be modified")
+			}
+		})
+	}
+}
+
+func TestExecuteQueryStep_PublicIDColumns(t *testing.T) {
+	enc, err := publicid.NewEncoder("test-secret-key-must-be-32-chars!", []publicid.NamespaceConfig{
+		{Name: "task", Prefix: "tsk"},
+		{Name: "user", Prefix: "usr"},
+	})
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	taskID, _ := enc.Encode("task", 42)
+	userID, _ := enc.Encode("user", 7)
+
+	var capturedParams map[string]any
+	dbm := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			capturedParams = params
+			tasks := []map[string]any{{"id": int64(42), "owner_id": []byte("7"), "title": "a"}, {"id": int64(43), "owner_id": nil, "title": "b"}}
+			owners := []map[string]any{{"owner_id": float64(7)}}
+			return &step.QueryResult{Rows: tasks, ResultSets: [][]map[string]any{tasks, owners}}, nil
+		},
+	}
+	exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+	cs := &CompiledStep{
+		Config:          &StepConfig{Name: "fetch", Type: "query", Database: "testdb", ResultSets: []string{"tasks", "owners"}},
+		SQLTmpl:         template.Must(template.New("test").Parse("SELECT * FROM tasks WHERE id = @id OR owner_id = @owner_id")),
+		PublicIDColumns: map[string]string{"id": "task", "owner_id": "user"},
+	}
+	run := func(params map[string]any) *StepResult {
+		t.Helper()
+		execData := step.ExecutionData{TemplateData: map[string]any{"trigger": map[string]any{"params": params}}}
+		result, err := exec.executeQueryStep(context.Background(), cs, execData)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return result
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		SetTemplateEncoder(nil)
+		result := run(map[string]any{"id": taskID, "owner_id": nil})
+		if result.Error == nil || !strings.Contains(result.Error.Error(), "public_ids not configured") {
+			t.Errorf("error = %v, want public_ids not configured", result.Error)
+		}
+	})
+
+	SetTemplateEncoder(enc)
+	t.Cleanup(func() { SetTemplateEncoder(nil) })
+
+	t.Run("decodes params and encodes rows", func(t *testing.T) {
+		result := run(map[string]any{"id": taskID, "owner_id": nil})
+		if !result.Success {
+			t.Fatalf("step failed: %v", result.Error)
+		}
+		if capturedParams["id"] != int64(42) || capturedParams["owner_id"] != nil {
+			t.Errorf("params = %v, want id 42 and NULL owner_id", capturedParams)
+		}
+		want := []map[string]any{
+			{"id": taskID, "owner_id": userID, "title": "a"},
+			{"id": mustEncode(t, enc, "task", 43), "owner_id": nil, "title": "b"},
+		}
+		if !reflect.DeepEqual(result.Data, want) {
+			t.Errorf("Data = %v, want %v", result.Data, want)
+		}
+		if got := result.Sets["owners"]; len(got) != 1 || got[0]["owner_id"] != userID {
+			t.Errorf("Sets[owners] = %v, want encoded owner_id", got)
+		}
+	})
+
+	t.Run("rejects internal ids", func(t *testing.T) {
+		result := run(map[string]any{"id": 42, "owner_id": nil})
+		if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), "parameter id: expected a public ID") {
+			t.Errorf("error = %v, want a public ID error", result.Error)
+		}
+	})
+
+	t.Run("rejects other namespaces", func(t *testing.T) {
+		result := run(map[string]any{"id": userID, "owner_id": nil})
+		if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), "parameter id") {
+			t.Errorf("error = %v, want a decode error", result.Error)
+		}
+	})
+}
+
+func TestExecuteQueryStep_MaskColumns(t *testing.T) {
+	dbm := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			users := []map[string]any{{"email": "ann@example.com", "card": "4111111111111234", "ssn": "123-45-6789", "name": []byte("Ann"), "phone": nil}}
+			cards := []map[string]any{{"card": int64(98765)}}
+			return &step.QueryResult{Rows: users, ResultSets: [][]map[string]any{users, cards}}, nil
+		},
+	}
+	exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+	unmask, err := compileConditionWithAliases(`trigger.params.role == "admin"`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := &CompiledStep{
+		Config:      &StepConfig{Name: "fetch", Type: "query", Database: "testdb", ResultSets: []string{"users", "cards"}},
+		SQLTmpl:     template.Must(template.New("test").Parse("SELECT * FROM users")),
+		MaskColumns: map[string]string{"email": MaskPartial, "card": MaskLast4, "ssn": MaskFull, "name": MaskHash, "phone": MaskFull},
+		UnmaskWhen:  unmask,
+	}
+	run := func(role string) *StepResult {
+		t.Helper()
+		trigger := map[string]any{"params": map[string]any{"role": role}}
+		execData := step.ExecutionData{TemplateData: map[string]any{"trigger": trigger}, ExprEnv: map[string]any{"trigger": trigger}}
+		result, err := exec.executeQueryStep(context.Background(), cs, execData)
+		if err != nil || !result.Success {
+			t.Fatalf("step failed: %v %v", err, result.Error)
+		}
+		return result
+	}
+
+	t.Run("masked", func(t *testing.T) {
+		result := run("support")
+		want := []map[string]any{{"email": "a***@example.com", "card": "****1234", "ssn": "****", "name": maskValue(MaskHash, "Ann"), "phone": nil}}
+		if !reflect.DeepEqual(result.Data, want) {
+			t.Errorf("Data = %v, want %v", result.Data, want)
+		}
+		if got := result.Sets["cards"]; got[0]["card"] != "****8765" {
+			t.Errorf("Sets[cards] = %v, want masked card", got)
+		}
+	})
+
+	t.Run("unmasked", func(t *testing.T) {
+		result := run("admin")
+		if result.Data[0]["email"] != "ann@example.com" || result.Sets["cards"][0]["card"] != int64(98765) {
+			t.Errorf("Data = %v, want unmasked rows", result.Data)
+		}
+	})
+}
+
+func TestExecuteQueryStep_ColumnFilters(t *testing.T) {
+	dbm := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			users := []map[string]any{{"id": 1, "Name": "Ann", "email": "ann@example.com", "password_hash": "x", "api_key": "k"}}
+			keys := []map[string]any{{"id": 1, "api_key": "k"}}
+			return &step.QueryResult{Rows: users, ResultSets: [][]map[string]any{users, keys}}, nil
+		},
+	}
+	exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+	cs := &CompiledStep{
+		Config:         &StepConfig{Name: "fetch", Type: "query", Database: "testdb", ResultSets: []string{"users", "keys"}},
+		SQLTmpl:        template.Must(template.New("test").Parse("SELECT * FROM users")),
+		IncludeColumns: []string{"id", "name", "e*", "api_*"},
+		ExcludeColumns: []string{"*_key", "*_hash"},
+	}
+	result, err := exec.executeQueryStep(context.Background(), cs, step.ExecutionData{TemplateData: map[string]any{}})
+	if err != nil || !result.Success {
+		t.Fatalf("step failed: %v %v", err, result.Error)
+	}
+	want := []map[string]any{{"id": 1, "Name": "Ann", "email": "ann@example.com"}}
+	if !reflect.DeepEqual(result.Data, want) {
+		t.Errorf("Data = %v, want %v", result.Data, want)
+	}
+	if got := result.Sets["keys"]; !reflect.DeepEqual(got, []map[string]any{{"id": 1}}) {
+		t.Errorf("Sets[keys] = %v, want only id", got)
+	}
+}
+
+func TestMaskValue(t *testing.T) {
+	tests := []struct {
+		strategy, value, want string
+	}{
+		{MaskFull, "secret", "****"},
+		{MaskPartial, "ann@example.com", "a***@example.com"},
+		{MaskPartial, "Johnson", "J***n"},
+		{MaskPartial, "Al", "****"},
+		{MaskLast4, "4111111111111234", "****1234"},
+		{MaskLast4, "1234", "****"},
+		{MaskHash, "Ann", "17239b6e25011033"},
+	}
+	for _, tt := range tests {
+		if got := maskValue(tt.strategy, tt.value); got != tt.want {
+			t.Errorf("maskValue(%s, %q) = %q, want %q", tt.strategy, tt.value, got, tt.want)
+		}
+	}
+}
+
+func mustEncode(t *testing.T, enc *publicid.Encoder, ns string, id int64) string {
+	t.Helper()
+	s, err := enc.Encode(ns, id)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	return s
+}
+
+func TestExecuteQueryStep_Proc(t *testing.T) {
+	var captured *step.ProcCall
+	var capturedSQL string
+	dbm := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			captured = opts.Proc
+			capturedSQL = sql
+			code := int64(0)
+			order := []map[string]any{{"id": int64(7)}}
+			return &step.QueryResult{
+				Rows:       order,
+				ResultSets: [][]map[string]any{order, {{"sku": "a"}, {"sku": "b"}}},
+				Outputs:    map[string]any{"OrderId": int64(7)},
+				ReturnCode: &code,
+			}, nil
+		},
+	}
+	exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "proc",
+		Steps: []StepConfig{
+			{
+				Name:     "place",
+				Database: "db",
+				Params:   map[string]string{"note": "rush"},
+				Proc: &ProcConfig{Name: "dbo.PlaceOrder", Params: []ProcParamConfig{
+					{Name: "customer_id"},
+					{Name: "Note", Value: "{{.params.note}}"},
+					{Name: "Total", Value: "trigger.params.qty * 2"},
+					{Name: "OrderId", Direction: "out", Type: "int"},
+				}},
+				ResultSets: []string{"order", "lines", "missing"},
+			},
+			{Name: "summary", Values: map[string]string{
+				"text": "{{.steps.place.output.OrderId}}/{{.steps.place.return_code}}/{{len .steps.place.sets.lines}}/{{len .steps.place.sets.missing}}",
+			}},
+		},
+	})
+	result := exec.Execute(context.Background(), wf, &TriggerData{Params: map[string]any{"customer_id": 42, "qty": 3}}, "req-1", nil, nil)
+	if !result.Success {
+		t.Fatalf("workflow failed: %v", result.Error)
+	}
+
+	if capturedSQL != "" {
+		t.Errorf("sql = %q, want empty for a proc call", capturedSQL)
+	}
+	want := &step.ProcCall{Name: "dbo.PlaceOrder", Params: []step.ProcParam{
+		{Name: "customer_id", Direction: "in", Value: 42},
+		{Name: "Note", Direction: "in", Value: "rush"},
+		{Name: "Total", Direction: "in", Value: 6},
+		{Name: "OrderId", Direction: "out", Type: "int"},
+	}}
+	if !reflect.DeepEqual(captured, want) {
+		t.Errorf("proc call = %+v, want %+v", captured, want)
+	}
+
+	if got := result.Steps["summary"].Values["text"]; got != "7/0/2/0" {
+		t.Errorf("summary = %v, want 7/0/2/0", got)
+	}
+	if result.Steps["place"].Count != 1 {
+		t.Errorf("count = %d, want rows of the first result set", result.Steps["place"].Count)
+	}
 }
 
 type failingResponseWriter struct {
@@ -993,3 +1706,147 @@ type failingResponseWriter struct {
 func (f *failingResponseWriter) Header() http.Header        { return f.header }
 func (f *failingResponseWriter) Write([]byte) (int, error)  { return 0, errors.New("write failed") }
 func (f *failingResponseWriter) WriteHeader(statusCode int) {}
+
+func TestExecuteNotifyStep_Slack(t *testing.T) {
+	var gotBody, gotContentType string
+	client := &mockHTTPClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			gotBody = string(body)
+			gotContentType = req.Header.Get("Content-Type")
+			if req.URL.String() != "https://hooks.slack.com/services/T0/B0/xyz" {
+				t.Errorf("URL = %s", req.URL)
+			}
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("ok")), Header: make(http.Header)}, nil
+		},
+	}
+	exec := NewExecutor(&mockDBManager{}, client, nil, &testLogger{})
+	cs := &CompiledStep{
+		Config:     &StepConfig{Name: "alert", Type: "notify", Provider: "slack"},
+		URLTmpl:    template.Must(template.New("url").Parse("https://hooks.slack.com/services/{{.vars.slack_path}}")),
+		TitleTmpl:  template.Must(template.New("title").Parse("Low stock")),
+		TextTmpl:   template.Must(template.New("text").Parse("{{.count}} items")),
+		BlocksTmpl: template.Must(template.New("blocks").Parse(`[{"type": "section", "text": {"type": "mrkdwn", "text": "{{.count}} items"}}]`)),
+	}
+	execData := step.ExecutionData{
+		TemplateData: map[string]any{"count": 3, "vars": map[string]string{"slack_path": "T0/B0/xyz"}},
+	}
+
+	result, err := exec.executeNotifyStep(context.Background(), cs, execData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("Success = false: %v", result.Error)
+	}
+	if gotContentType != "application/json" {
+		t.Errorf("Content-Type = %q", gotContentType)
+	}
+	if gotBody != `{"blocks":[{"text":{"text":"3 items","type":"mrkdwn"},"type":"section"}],"text":"*Low stock*\n3 items"}` {
+		t.Errorf("body = %s", gotBody)
+	}
+}
+
+func TestExecuteNotifyStep_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		blocks  string
+		wantErr string
+	}{
+		{name: "rejected", status: 400, wantErr: "slack returned status 400: invalid_payload"},
+		{name: "blocks not an array", status: 200, blocks: `{"type": "section"}`, wantErr: "blocks must render a JSON array"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockHTTPClient{
+				doFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader("invalid_payload")), Header: make(http.Header)}, nil
+				},
+			}
+			exec := NewExecutor(&mockDBManager{}, client, nil, &testLogger{})
+			cs := &CompiledStep{
+				Config:   &StepConfig{Name: "alert", Type: "notify", Provider: "slack"},
+				URLTmpl:  template.Must(template.New("url").Parse("https://hooks.example.com")),
+				TextTmpl: template.Must(template.New("text").Parse("hi")),
+			}
+			if tt.blocks != "" {
+				cs.BlocksTmpl = template.Must(template.New("blocks").Parse(tt.blocks))
+			}
+
+			result, err := exec.executeNotifyStep(context.Background(), cs, step.ExecutionData{TemplateData: map[string]any{}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr) {
+				t.Errorf("Error = %v, want %q", result.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildNotifyPayload(t *testing.T) {
+	blocks := []any{map[string]any{"type": "FactSet"}}
+	data := map[string]any{"workflow": map[string]any{"name": "nightly"}}
+
+	tests := []struct {
+		provider string
+		want     string
+	}{
+		{"slack", `{"blocks":[{"type":"FactSet"}],"text":"*Failed*\nboom"}`},
+		{"teams", `{"attachments":[{"content":{"$schema":"http://adaptivecards.io/schemas/adaptive-card.json","body":[{"size":"Medium","text":"Failed","type":"TextBlock","weight":"Bolder","wrap":true},{"text":"boom","type":"TextBlock","wrap":true},{"type":"FactSet"}],"type":"AdaptiveCard","version":"1.4"},"contentType":"application/vnd.microsoft.card.adaptive"}],"type":"message"}`},
+		{"webhook", `{"blocks":[{"type":"FactSet"}],"text":"boom","title":"Failed","workflow":{"name":"nightly"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			got, err := buildNotifyPayload(tt.provider, "Failed", "boom", blocks, data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("payload =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := buildNotifyPayload("pager", "", "x", nil, data); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestSerializeStorageContent(t *testing.T) {
+	when := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	rows := []any{
+		map[string]any{"id": 1, "tags": []any{"a"}, "at": when},
+		map[string]any{"id": 2, "extra": true},
+	}
+
+	tests := []struct {
+		format  string
+		columns []string
+		want    string
+	}{
+		{"json", nil, `[{"at":"2024-03-01T09:30:00Z","id":1,"tags":["a"]},{"extra":true,"id":2}]`},
+		{"ndjson", nil, "{\"at\":\"2024-03-01T09:30:00Z\",\"id\":1,\"tags\":[\"a\"]}\n{\"extra\":true,\"id\":2}\n"},
+		{"csv", nil, "at,extra,id,tags\n2024-03-01T09:30:00Z,,1,\"[\"\"a\"\"]\"\n,true,2,\n"},
+		{"csv", []string{"id", "at"}, "id,at\n1,2024-03-01T09:30:00Z\n2,\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := serializeStorageContent(rows, tt.format, tt.columns)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := serializeStorageContent([]any{1, 2}, "csv", nil); err == nil {
+		t.Error("expected error for csv of scalar values")
+	}
+}
@@ -0,0 +1,202 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"sort"
+	"time"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+// storageContentTypes are the content types for source formats
+var storageContentTypes = map[string]string{
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+	"csv":    "text/csv; charset=utf-8",
+}
+
+func (e *Executor) executeStorageStep(ctx context.Context, cs *CompiledStep, execData step.ExecutionData) (*StepResult, error) {
+	start := time.Now()
+	result := &StepResult{}
+
+	fail := func(err error) (*StepResult, error) {
+		result.Error = err
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	if e.objects == nil {
+		return fail(errors.New("storage step requires storage to be configured"))
+	}
+
+	bucket, err := renderTemplateField("bucket", cs.BucketTmpl, execData.TemplateData)
+	if err != nil {
+		return fail(err)
+	}
+	key, err := renderTemplateField("key", cs.KeyTmpl, execData.TemplateData)
+	if err != nil {
+		return fail(err)
+	}
+	if bucket == "" || key == "" {
+		return fail(fmt.Errorf("bucket and key must not render empty (bucket %q, key %q)", bucket, key))
+	}
+
+	format := cs.Config.Format
+	if format == "" {
+		format = "json"
+	}
+
+	body, err := renderStepContent(cs, execData)
+	if err != nil {
+		return fail(err)
+	}
+	contentType := cs.Config.ContentType
+	if contentType == "" && cs.SourceProg != nil {
+		contentType = storageContentTypes[format]
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	stored, err := e.objects.PutObject(ctx, cs.Config.Store, step.StorageObject{
+		Bucket:      bucket,
+		Key:         key,
+		Body:        body,
+		ContentType: contentType,
+		Encryption:  cs.Config.Encryption,
+		KMSKeyID:    cs.Config.KMSKeyID,
+	})
+	if err != nil {
+		return fail(err)
+	}
+
+	result.Success = true
+	result.Values = map[string]any{
+		"url":    stored.URL,
+		"bucket": bucket,
+		"key":    key,
+		"size":   len(body),
+		"etag":   stored.ETag,
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	e.logger.Info("storage_step_uploaded", map[string]any{
+		"step":        cs.Config.Name,
+		"store":       cs.Config.Store,
+		"bucket":      bucket,
+		"key":         key,
+		"size":        len(body),
+		"duration_ms": result.DurationMs,
+	})
+
+	return result, nil
+}
+
+// renderStepContent returns the content of a storage or sftp step: the source value
+// serialized per format, or the rendered body.
+func renderStepContent(cs *CompiledStep, execData step.ExecutionData) ([]byte, error) {
+	if cs.SourceProg == nil {
+		body, err := renderTemplateField("body", cs.BodyTmpl, execData.TemplateData)
+		return []byte(body), err
+	}
+	value, err := EvalExpression(cs.SourceProg, execData.ExprEnv)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	format := cs.Config.Format
+	if format == "" {
+		format = "json"
+	}
+	return serializeStorageContent(value, format, cs.Config.Columns)
+}
+
+// serializeStorageContent encodes a source value. json accepts any value; ndjson and csv
+// need an object or a list of objects.
+func serializeStorageContent(value any, format string, columns []string) ([]byte, error) {
+	if format == "json" {
+		return json.Marshal(value)
+	}
+
+	rows, ok := toRows(value)
+	if !ok {
+		return nil, fmt.Errorf("source must be an object or a list of objects for %s, got %T", format, value)
+	}
+
+	var buf bytes.Buffer
+	if format == "ndjson" {
+		enc := json.NewEncoder(&buf)
+		for _, row := range rows {
+			if err := enc.Encode(row); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	}
+
+	if len(columns) == 0 {
+		columns = rowColumns(rows)
+	}
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, col := range columns {
+			record[i] = csvValue(row[col])
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// rowColumns returns the sorted union of the rows' column names.
+func rowColumns(rows []map[string]any) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range rows {
+		for col := range row {
+			if !seen[col] {
+				seen[col] = true
+				columns = append(columns, col)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// csvValue formats a cell: nulls are empty, times RFC 3339, and nested values JSON.
+func csvValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case map[string]any, []any, []map[string]any:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(val)
+	}
+}
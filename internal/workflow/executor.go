@@ -2,15 +2,24 @@ package workflow
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"text/template"
 	"time"
 
+	"sql-proxy/internal/concurrency"
+	"sql-proxy/internal/errtrack"
+	"sql-proxy/internal/loadshed"
+	"sql-proxy/internal/metrics"
 	"sql-proxy/internal/workflow/step"
 )
 
@@ -18,6 +27,8 @@ import (
 type StepCache interface {
 	Get(workflow, key string) ([]map[string]any, bool)
 	Set(workflow, key string, data []map[string]any, ttl time.Duration) bool
+	SetWithTags(workflow, key string, data []map[string]any, ttl time.Duration, tags []string) bool
+	InvalidateTag(tag string) int
 }
 
 // Executor runs compiled workflows.
@@ -26,6 +37,63 @@ type Executor struct {
 	httpClient step.HTTPClient
 	cache      StepCache
 	logger     Logger
+	mailer     step.Mailer           // nil unless smtp is configured
+	objects    step.ObjectStore      // nil unless storage targets are configured
+	uploader   step.FileUploader     // nil unless sftp servers are configured
+	publisher  step.MessagePublisher // nil unless mqtt brokers are configured
+
+	// Validate response bodies against their step's schema (server.strict_responses)
+	strictResponses bool
+
+	// Running executions per workflow name (name -> *atomic.Int64)
+	running sync.Map
+
+	// max_concurrent limits per workflow name (name -> *concurrency.Limiter)
+	limiters sync.Map
+
+	// Tenant key templates of tenant-routed databases (database -> template)
+	tenantKeys map[string]*template.Template
+
+	// Alerts on workflow failure rates and latency, nil unless alerts are configured
+	alerts *AlertMonitor
+
+	// Rejects low-priority HTTP requests under load, nil unless server.load_shedding is configured
+	loadShedder LoadShedder
+
+	// Caps executions across all workflows by trigger priority, nil unless server.worker_pool is configured
+	workerPool *concurrency.Pool
+
+	// Fails or delays steps for resilience testing, nil unless chaos is enabled
+	faults FaultInjector
+}
+
+// FaultInjector fails or delays steps for resilience testing.
+type FaultInjector interface {
+	// Step waits the latency added to a run of the step and returns the error
+	// the run fails with, or nil.
+	Step(ctx context.Context, workflow, name string) error
+}
+
+// stepFaultKey carries the workflow and step whose httpcall attempts get faults
+// injected, as a stepFault
+type stepFaultKey struct{}
+
+type stepFault struct {
+	workflow, name string
+}
+
+// workerPoolPriorities are the worker pool's queues, lowest first
+var workerPoolPriorities = []string{loadshed.PriorityLow, loadshed.PriorityNormal, loadshed.PriorityHigh, loadshed.PriorityCritical}
+
+// DefaultPriority returns the priority of a trigger type's executions when the
+// trigger sets none: normal for request/response triggers, low for background ones.
+func DefaultPriority(triggerType string) string {
+	switch triggerType {
+	case TriggerTypeHTTP, TriggerTypeWebSocket, TriggerTypeGRPC:
+		return loadshed.PriorityNormal
+	default:
+		return loadshed.PriorityLow
+	}
 }
 
 // NewExecutor creates a workflow executor.
@@ -38,11 +106,157 @@ func NewExecutor(dbManager step.DBManager, httpClient step.HTTPClient, cache Ste
 	}
 }
 
+// SetHTTPClient sets the client used by httpcall steps and notifications.
+func (e *Executor) SetHTTPClient(client step.HTTPClient) {
+	e.httpClient = client
+}
+
+// SetMailer sets the mailer used by email steps.
+func (e *Executor) SetMailer(mailer step.Mailer) {
+	e.mailer = mailer
+}
+
+// SetObjectStore sets the object store used by storage steps.
+func (e *Executor) SetObjectStore(store step.ObjectStore) {
+	e.objects = store
+}
+
+// SetFileUploader sets the uploader used by sftp steps.
+func (e *Executor) SetFileUploader(uploader step.FileUploader) {
+	e.uploader = uploader
+}
+
+// SetMessagePublisher sets the publisher used by mqtt steps.
+func (e *Executor) SetMessagePublisher(publisher step.MessagePublisher) {
+	e.publisher = publisher
+}
+
+// SetTenantKeys sets the templates resolving the tenant of each tenant-routed
+// database from a step's template data.
+func (e *Executor) SetTenantKeys(keys map[string]string) error {
+	e.tenantKeys = make(map[string]*template.Template, len(keys))
+	for database, key := range keys {
+		tmpl, err := template.New("tenant_key").Funcs(TemplateFuncs).Parse(key)
+		if err != nil {
+			return fmt.Errorf("database %s: invalid tenant key template: %w", database, err)
+		}
+		e.tenantKeys[database] = tmpl
+	}
+	return nil
+}
+
+// SetStrictResponses enables checking response step output against the step's schema.
+// A body that does not match fails the step instead of being sent.
+func (e *Executor) SetStrictResponses(strict bool) {
+	e.strictResponses = strict
+}
+
+// SetLoadShedder sets the shedder that admits HTTP trigger requests by priority.
+func (e *Executor) SetLoadShedder(shedder LoadShedder) {
+	e.loadShedder = shedder
+}
+
+// SetFaultInjector injects faults into steps: before each run of a step, or
+// each attempt of an httpcall step, so its retries see them.
+func (e *Executor) SetFaultInjector(faults FaultInjector) {
+	e.faults = faults
+}
+
+// injectStepFault returns the error a fault injected into a run of the step
+// fails it with. httpcall steps get faults injected into each attempt instead,
+// through the returned context.
+func (e *Executor) injectStepFault(ctx context.Context, wfCtx *Context, cs *CompiledStep) (context.Context, error) {
+	if e.faults == nil || cs.Config.Name == "" {
+		return ctx, nil
+	}
+	workflow := wfCtx.Workflow.Config.Name
+	if cs.Config.StepType() == "httpcall" {
+		return context.WithValue(ctx, stepFaultKey{}, stepFault{workflow: workflow, name: cs.Config.Name}), nil
+	}
+	return ctx, e.faults.Step(ctx, workflow, cs.Config.Name)
+}
+
+// SetWorkerPool caps the executions running at once across all workflows.
+// Further executions queue by trigger priority: at most queueSize of them
+// (0 = unbounded), each for at most wait (0 = until its context ends).
+func (e *Executor) SetWorkerPool(workers, queueSize int, wait time.Duration) {
+	e.workerPool = concurrency.NewPool(workers, queueSize, wait, workerPoolPriorities)
+}
+
+// WorkerPoolStats returns usage of the worker pool, or nil without one
+func (e *Executor) WorkerPoolStats() *concurrency.PoolStats {
+	if e.workerPool == nil {
+		return nil
+	}
+	stats := e.workerPool.Stats()
+	return &stats
+}
+
 // Logger returns the executor's logger.
 func (e *Executor) Logger() Logger {
 	return e.logger
 }
 
+// RunningWorkflows returns the number of in-progress executions per workflow name.
+// Workflows that have executed at least once are included even when idle.
+func (e *Executor) RunningWorkflows() map[string]int64 {
+	counts := make(map[string]int64)
+	e.running.Range(func(k, v any) bool {
+		counts[k.(string)] = v.(*atomic.Int64).Load()
+		return true
+	})
+	return counts
+}
+
+// trackRunning increments the running count for a workflow and returns the matching decrement
+func (e *Executor) trackRunning(name string) func() {
+	v, _ := e.running.LoadOrStore(name, &atomic.Int64{})
+	counter := v.(*atomic.Int64)
+	counter.Add(1)
+	return func() { counter.Add(-1) }
+}
+
+// limiter returns the max_concurrent limiter for a workflow, or nil if it is unlimited
+func (e *Executor) limiter(cfg *WorkflowConfig) *concurrency.Limiter {
+	if cfg.MaxConcurrent <= 0 {
+		return nil
+	}
+	if v, ok := e.limiters.Load(cfg.Name); ok {
+		return v.(*concurrency.Limiter)
+	}
+	l := concurrency.New(cfg.MaxConcurrent, time.Duration(cfg.MaxConcurrentWaitMs)*time.Millisecond)
+	v, _ := e.limiters.LoadOrStore(cfg.Name, l)
+	return v.(*concurrency.Limiter)
+}
+
+// resolveTenant evaluates the tenant key of a tenant-routed database.
+// Returns "" for other databases.
+func (e *Executor) resolveTenant(database string, execData step.ExecutionData) (string, error) {
+	tmpl := e.tenantKeys[database]
+	if tmpl == nil {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, execData.TemplateData); err != nil {
+		return "", fmt.Errorf("database %s: tenant key template error: %w", database, err)
+	}
+	tenant := strings.TrimSpace(buf.String())
+	if tenant == "" || tenant == "<no value>" {
+		return "", fmt.Errorf("database %s: request has no tenant", database)
+	}
+	return tenant, nil
+}
+
+// ConcurrencyStats returns usage of workflows that have a max_concurrent limit
+func (e *Executor) ConcurrencyStats() map[string]concurrency.Stats {
+	stats := make(map[string]concurrency.Stats)
+	e.limiters.Range(func(k, v any) bool {
+		stats[k.(string)] = v.(*concurrency.Limiter).Stats()
+		return true
+	})
+	return stats
+}
+
 // ExecuteResult contains the result of workflow execution.
 type ExecuteResult struct {
 	Success      bool
@@ -58,6 +272,63 @@ func (e *Executor) Execute(ctx context.Context, wf *CompiledWorkflow, trigger *T
 	result := &ExecuteResult{
 		Steps: make(map[string]*StepResult),
 	}
+	if e.alerts != nil {
+		defer func() { e.alerts.observe(wf.Config.Name, result) }()
+	}
+
+	// Report panics with the step that raised them, then let them reach the caller's recovery
+	var current string
+	defer func() {
+		if p := recover(); p != nil {
+			panic(errtrack.ReportPanic(p, errtrack.Event{Workflow: wf.Config.Name, Step: current, RequestID: requestID}))
+		}
+	}()
+
+	// Enforce max_concurrent before the execution counts as running
+	limiter := e.limiter(wf.Config)
+	if err := limiter.Acquire(ctx); err != nil {
+		if errors.Is(err, concurrency.ErrLimitReached) {
+			metrics.RecordConcurrencyRejected("workflow", wf.Config.Name)
+		}
+		e.logger.Warn("workflow_concurrency_limited", map[string]any{
+			"workflow":       wf.Config.Name,
+			"request_id":     requestID,
+			"max_concurrent": wf.Config.MaxConcurrent,
+			"error":          err.Error(),
+		})
+		result.Error = fmt.Errorf("workflow %s: %w", wf.Config.Name, err)
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result
+	}
+	defer limiter.Release()
+
+	// Wait for a worker, queued by priority, once the workflow's own limit admits the execution
+	priority := cmp.Or(trigger.Priority, DefaultPriority(trigger.Type))
+	if err := e.workerPool.Acquire(ctx, priority); err != nil {
+		if errors.Is(err, concurrency.ErrLimitReached) {
+			metrics.RecordWorkerPoolRejected(priority)
+		}
+		e.logger.Warn("workflow_queue_rejected", map[string]any{
+			"workflow":   wf.Config.Name,
+			"request_id": requestID,
+			"priority":   priority,
+			"error":      err.Error(),
+		})
+		result.Error = fmt.Errorf("workflow %s: %w", wf.Config.Name, err)
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result
+	}
+	defer e.workerPool.Release()
+
+	defer e.trackRunning(wf.Config.Name)()
+
+	// Close an event stream opened by response_sse steps however the run ends
+	var stream *eventStream
+	if wf.Streams && w != nil {
+		stream = newEventStream(w, wf.Config)
+		w = stream
+		defer stream.finish(result, requestID)
+	}
 
 	if wf.Config.TimeoutSec > 0 {
 		var cancel context.CancelFunc
@@ -81,11 +352,23 @@ func (e *Executor) Execute(ctx context.Context, wf *CompiledWorkflow, trigger *T
 		select {
 		case <-ctx.Done():
 			result.Error = ctx.Err()
+			wfCtx.RecordFailure("", ctx.Err())
+			e.runFailureNotifications(ctx, wf, i, wfCtx, w, result)
 			result.DurationMs = time.Since(start).Milliseconds()
 			return result
 		default:
 		}
 
+		if !notifyWhenMatches(compiledStep.Config, wfCtx.Failed()) {
+			e.logger.Debug("step_skipped_when", map[string]any{
+				"workflow":   wf.Config.Name,
+				"step":       compiledStep.Config.Name,
+				"step_index": i,
+				"when":       compiledStep.Config.When,
+			})
+			continue
+		}
+
 		if compiledStep.Condition != nil {
 			env := wfCtx.BuildExprEnv()
 			shouldRun, err := EvalCondition(compiledStep.Condition, env)
@@ -108,17 +391,21 @@ func (e *Executor) Execute(ctx context.Context, wf *CompiledWorkflow, trigger *T
 			}
 		}
 
+		stepName := compiledStep.Config.Name
+		if stepName == "" {
+			stepName = fmt.Sprintf("step_%d", i)
+		}
+
+		current = stepName
 		stepResult, err := e.executeStep(ctx, compiledStep, wfCtx, w)
 		if err != nil {
 			result.Error = err
+			reportStepError(wf, stepName, requestID, err, false)
+			wfCtx.RecordFailure(stepName, err)
+			e.runFailureNotifications(ctx, wf, i+1, wfCtx, w, result)
 			result.DurationMs = time.Since(start).Milliseconds()
 			return result
 		}
-
-		stepName := compiledStep.Config.Name
-		if stepName == "" {
-			stepName = fmt.Sprintf("step_%d", i)
-		}
 		stepResult.Name = stepName
 		stepResult.Type = compiledStep.Config.StepType()
 		wfCtx.SetStepResult(stepName, stepResult)
@@ -129,39 +416,70 @@ func (e *Executor) Execute(ctx context.Context, wf *CompiledWorkflow, trigger *T
 		}
 
 		if !stepResult.Success {
+			metrics.RecordStepError(wf.Config.Name, stepName, stepResult.TimedOut)
+
 			onError := compiledStep.Config.OnError
 			if onError == "" {
 				onError = "abort"
 			}
+			reportStepError(wf, stepName, requestID, stepResult.Error, onError != "abort")
 
 			errMsg := "unknown error"
 			if stepResult.Error != nil {
 				errMsg = stepResult.Error.Error()
 			}
 
+			if onError == "fallback" {
+				if err := applyFallback(compiledStep, stepResult, wfCtx.BuildTemplateData()); err != nil {
+					// A broken fallback leaves nothing to continue with
+					onError = "abort"
+					stepResult.Error = fmt.Errorf("%s (fallback failed: %w)", errMsg, err)
+					errMsg = stepResult.Error.Error()
+				} else {
+					e.logger.Warn("workflow_step_fallback", map[string]any{
+						"workflow":  wf.Config.Name,
+						"step":      stepName,
+						"error":     errMsg,
+						"timed_out": stepResult.TimedOut,
+						"count":     stepResult.Count,
+					})
+					continue
+				}
+			}
+
 			if onError == "abort" {
 				result.Error = stepResult.Error
-				result.DurationMs = time.Since(start).Milliseconds()
 				e.logger.Error("workflow_step_failed", map[string]any{
-					"workflow": wf.Config.Name,
-					"step":     stepName,
-					"error":    errMsg,
-					"on_error": onError,
+					"workflow":  wf.Config.Name,
+					"step":      stepName,
+					"error":     errMsg,
+					"on_error":  onError,
+					"timed_out": stepResult.TimedOut,
 				})
+				e.runFailureNotifications(ctx, wf, i+1, wfCtx, w, result)
+				result.DurationMs = time.Since(start).Milliseconds()
 				return result
 			}
 			e.logger.Warn("workflow_step_failed_continue", map[string]any{
-				"workflow": wf.Config.Name,
-				"step":     stepName,
-				"error":    errMsg,
+				"workflow":  wf.Config.Name,
+				"step":      stepName,
+				"error":     errMsg,
+				"timed_out": stepResult.TimedOut,
 			})
 		}
 	}
 
 	result.Success = true
 	result.DurationMs = time.Since(start).Milliseconds()
+	if stream != nil {
+		stream.finish(result, requestID)
+	}
+	if !result.ResponseSent {
+		// The caller writes the default response, which carries the trigger's headers
+		e.setResponseHeaders(wfCtx, wfCtx.BuildTemplateData(), w)
+	}
 
-	if trigger.Type == "http" && !result.ResponseSent {
+	if (trigger.Type == "http" || trigger.Type == "websocket" || trigger.Type == "grpc") && !result.ResponseSent {
 		e.logger.Warn("workflow_no_response", map[string]any{
 			"workflow":   wf.Config.Name,
 			"request_id": requestID,
@@ -178,6 +496,115 @@ func (e *Executor) Execute(ctx context.Context, wf *CompiledWorkflow, trigger *T
 	return result
 }
 
+// setResponseHeaders renders the trigger's response_headers onto w. A header whose
+// template fails is logged and left out rather than failing the response.
+func (e *Executor) setResponseHeaders(wfCtx *Context, data map[string]any, w http.ResponseWriter) {
+	if w == nil || len(wfCtx.Trigger.ResponseHeaders) == 0 {
+		return
+	}
+	for name, tmpl := range wfCtx.Trigger.ResponseHeaders {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			e.logger.Warn("response_header_error", map[string]any{
+				"workflow":   wfCtx.Workflow.Config.Name,
+				"request_id": wfCtx.RequestID,
+				"header":     name,
+				"error":      err.Error(),
+			})
+			continue
+		}
+		w.Header().Set(name, buf.String())
+	}
+}
+
+// failureNotifyTimeout bounds each when: failure notification sent after the workflow aborted.
+const failureNotifyTimeout = 30 * time.Second
+
+// notifyWhenMatches reports whether a step's when setting allows it to run given the run's
+// failure state. Steps without when always run.
+func notifyWhenMatches(cfg *StepConfig, failed bool) bool {
+	switch cfg.When {
+	case "failure":
+		return failed
+	case "success":
+		return !failed
+	default:
+		return true
+	}
+}
+
+// runFailureNotifications runs the when: failure notify steps from index from onwards after
+// the workflow aborted. Each gets its own deadline, detached from ctx, so that a workflow
+// timeout can still be reported. Their failures are logged but do not change the result.
+func (e *Executor) runFailureNotifications(ctx context.Context, wf *CompiledWorkflow, from int, wfCtx *Context, w http.ResponseWriter, result *ExecuteResult) {
+	for i := from; i < len(wf.Steps); i++ {
+		cs := wf.Steps[i]
+		if cs.Config.Disabled || !cs.Config.IsNotify() || cs.Config.When != "failure" {
+			continue
+		}
+
+		if cs.Condition != nil {
+			shouldRun, err := EvalCondition(cs.Condition, wfCtx.BuildExprEnv())
+			if err != nil || !shouldRun {
+				continue
+			}
+		}
+
+		stepName := cs.Config.Name
+		if stepName == "" {
+			stepName = fmt.Sprintf("step_%d", i)
+		}
+
+		notifyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), failureNotifyTimeout)
+		stepResult, err := e.executeStep(notifyCtx, cs, wfCtx, w)
+		cancel()
+		if err != nil {
+			stepResult = &StepResult{Error: err}
+		}
+		stepResult.Name = stepName
+		stepResult.Type = cs.Config.StepType()
+		wfCtx.SetStepResult(stepName, stepResult)
+		result.Steps[stepName] = stepResult
+
+		if !stepResult.Success {
+			metrics.RecordStepError(wf.Config.Name, stepName, stepResult.TimedOut)
+			reportStepError(wf, stepName, wfCtx.RequestID, stepResult.Error, true)
+			e.logger.Error("workflow_failure_notify_failed", map[string]any{
+				"workflow": wf.Config.Name,
+				"step":     stepName,
+				"error":    fmt.Sprint(stepResult.Error),
+			})
+		}
+	}
+}
+
+// reportStepError sends a step failure to the error tracker, as a template
+// error when the step failed executing a template. Failures the workflow
+// continued past are warnings. Cancelled requests are not reported.
+func reportStepError(wf *CompiledWorkflow, stepName, requestID string, err error, continued bool) {
+	if err == nil || errors.Is(err, context.Canceled) || !errtrack.Enabled() {
+		return
+	}
+	kind := errtrack.KindStepFailure
+	var execErr template.ExecError
+	if errors.As(err, &execErr) {
+		kind = errtrack.KindTemplateError
+	}
+	level := errtrack.LevelError
+	if continued {
+		level = errtrack.LevelWarning
+	}
+	errtrack.Report(errtrack.Event{
+		Kind:      kind,
+		Level:     level,
+		Message:   err.Error(),
+		Workflow:  wf.Config.Name,
+		Step:      stepName,
+		RequestID: requestID,
+		Tags:      map[string]string{"error_code": errorCode(err)},
+	})
+}
+
 func (e *Executor) executeStep(ctx context.Context, cs *CompiledStep, wfCtx *Context, w http.ResponseWriter) (*StepResult, error) {
 	stepType := cs.Config.StepType()
 
@@ -222,17 +649,27 @@ func (e *Executor) executeStep(ctx context.Context, cs *CompiledStep, wfCtx *Con
 				return nil, err
 			}
 
-			if result.Success && result.Data != nil {
+			// Truncated results are not cached: a hit could not report the truncation
+			if result.Success && result.Data != nil && !result.Truncated {
 				ttl := time.Duration(0)
 				if cs.Config.Cache != nil && cs.Config.Cache.TTLSec > 0 {
 					ttl = time.Duration(cs.Config.Cache.TTLSec) * time.Second
 				}
-				e.cache.Set(workflowName, cacheKey, result.Data, ttl)
+				tags, err := evaluateTags(cs.CacheTagTmpls, execData.TemplateData)
+				if err != nil {
+					e.logger.Warn("step_cache_tags_error", map[string]any{
+						"workflow": workflowName,
+						"step":     cs.Config.Name,
+						"error":    err.Error(),
+					})
+				}
+				e.cache.SetWithTags(workflowName, cacheKey, result.Data, ttl, tags)
 				e.logger.Debug("step_cache_set", map[string]any{
 					"workflow":  workflowName,
 					"step":      cs.Config.Name,
 					"cache_key": cacheKey,
 					"ttl_sec":   ttl.Seconds(),
+					"tags":      tags,
 				})
 			}
 
@@ -244,18 +681,116 @@ func (e *Executor) executeStep(ctx context.Context, cs *CompiledStep, wfCtx *Con
 }
 
 func (e *Executor) executeStepByType(ctx context.Context, stepType string, cs *CompiledStep, execData step.ExecutionData, wfCtx *Context, w http.ResponseWriter) (*StepResult, error) {
-	switch stepType {
-	case "query":
-		return e.executeQueryStep(ctx, cs, execData)
-	case "httpcall":
-		return e.executeHTTPCallStep(ctx, cs, execData)
-	case "response":
-		return e.executeResponseStep(ctx, cs, execData)
-	case "block":
-		return e.executeBlockStep(ctx, cs, wfCtx, w)
-	default:
-		return &StepResult{Error: fmt.Errorf("unknown step type: %s", stepType)}, nil
+	return withStepTimeout(ctx, cs, func(ctx context.Context) (*StepResult, error) {
+		ctx, err := e.injectStepFault(ctx, wfCtx, cs)
+		if err != nil {
+			return &StepResult{Error: err}, nil
+		}
+		switch stepType {
+		case "query":
+			return e.executeQueryStep(ctx, cs, execData)
+		case "httpcall":
+			return e.executeHTTPCallStep(ctx, cs, execData)
+		case "response", "page":
+			e.setResponseHeaders(wfCtx, execData.TemplateData, w)
+			return e.executeResponseStep(ctx, cs, execData)
+		case "redirect":
+			e.setResponseHeaders(wfCtx, execData.TemplateData, w)
+			return e.executeRedirectStep(cs, execData)
+		case "response_sse":
+			return e.executeResponseSSEStep(ctx, cs, execData)
+		case "cache_invalidate":
+			return e.executeCacheInvalidateStep(cs, execData)
+		case "set":
+			return e.executeSetStep(cs, execData)
+		case "script":
+			return e.executeScriptStep(ctx, cs, execData)
+		case "email":
+			return e.executeEmailStep(ctx, cs, execData)
+		case "notify":
+			return e.executeNotifyStep(ctx, cs, execData)
+		case "storage":
+			return e.executeStorageStep(ctx, cs, execData)
+		case "sftp":
+			return e.executeSFTPStep(ctx, cs, execData)
+		case "bulk_insert":
+			return e.executeBulkInsertStep(ctx, cs, execData)
+		case "mqtt":
+			return e.executeMQTTStep(ctx, cs, execData)
+		case "metric":
+			return e.executeMetricStep(cs, execData)
+		case "block":
+			return e.executeBlockStep(ctx, cs, wfCtx, w)
+		default:
+			return &StepResult{Error: fmt.Errorf("unknown step type: %s", stepType)}, nil
+		}
+	})
+}
+
+// stepTimeoutError reports a step cut short by its own timeout_sec.
+// It matches context.DeadlineExceeded as well as the error the step failed with.
+type stepTimeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *stepTimeoutError) Error() string {
+	return fmt.Sprintf("step timed out after %s: %v", e.timeout, e.err)
+}
+
+func (e *stepTimeoutError) Unwrap() []error {
+	return []error{context.DeadlineExceeded, e.err}
+}
+
+// withStepTimeout runs a step under its timeout_sec (if set) and flags failures caused by a
+// deadline, whether the step's own or the workflow's, so timeouts can be told apart from errors.
+func withStepTimeout(ctx context.Context, cs *CompiledStep, run func(context.Context) (*StepResult, error)) (*StepResult, error) {
+	stepCtx := ctx
+	if cs.Config.TimeoutSec > 0 {
+		var cancel context.CancelFunc
+		stepCtx, cancel = context.WithTimeout(ctx, time.Duration(cs.Config.TimeoutSec)*time.Second)
+		defer cancel()
 	}
+
+	result, err := run(stepCtx)
+	if err != nil || result == nil || result.Success || result.Error == nil {
+		return result, err
+	}
+	if errors.Is(stepCtx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+		if ctx.Err() == nil {
+			result.Error = &stepTimeoutError{timeout: time.Duration(cs.Config.TimeoutSec) * time.Second, err: result.Error}
+		}
+	}
+	return result, nil
+}
+
+// applyFallback replaces a failed step's data with its rendered fallback template, which must
+// produce a JSON object (one row) or an array of objects. The step keeps its error so templates
+// and logs can still see that it failed.
+func applyFallback(cs *CompiledStep, result *StepResult, data map[string]any) error {
+	if cs.FallbackTmpl == nil {
+		return errors.New("no fallback template")
+	}
+	var buf bytes.Buffer
+	if err := cs.FallbackTmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("rendering fallback: %w", err)
+	}
+
+	var value any
+	if err := json.Unmarshal(buf.Bytes(), &value); err != nil {
+		return fmt.Errorf("fallback is not valid JSON: %w", err)
+	}
+
+	rows, ok := toRows(value)
+	if !ok {
+		return errors.New("fallback must be a JSON object or array of objects")
+	}
+
+	result.Data = rows
+	result.Count = len(rows)
+	result.Fallback = true
+	return nil
 }
 
 func (e *Executor) evaluateCacheKey(tmpl *template.Template, data map[string]any) (string, error) {
@@ -266,6 +801,25 @@ func (e *Executor) evaluateCacheKey(tmpl *template.Template, data map[string]any
 	return buf.String(), nil
 }
 
+// evaluateTags renders cache tag templates, skipping tags that render empty.
+// On error the tags rendered so far are returned along with the error.
+func evaluateTags(tmpls []*template.Template, data any) ([]string, error) {
+	if len(tmpls) == 0 {
+		return nil, nil
+	}
+	tags := make([]string, 0, len(tmpls))
+	for _, tmpl := range tmpls {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return tags, fmt.Errorf("evaluating cache tag: %w", err)
+		}
+		if tag := strings.TrimSpace(buf.String()); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
 // evaluateStepParams evaluates computed param templates and adds results to data["params"].
 // Computed values can be referenced in SQL via @param. The params namespace is checked
 // BEFORE trigger.params, allowing step-level params to override request params.
@@ -404,17 +958,40 @@ func (e *Executor) executeBlockStep(ctx context.Context, cs *CompiledStep, wfCtx
 				ResponseWriter: w,
 			}
 
-			var stepResult *StepResult
-			var err error
-
-			switch nestedStep.Config.StepType() {
-			case "query":
-				stepResult, err = e.executeQueryStep(ctx, nestedStep, execData)
-			case "httpcall":
-				stepResult, err = e.executeHTTPCallStep(ctx, nestedStep, execData)
-			default:
-				err = fmt.Errorf("unsupported step type in block: %s", nestedStep.Config.StepType())
-			}
+			stepResult, err := withStepTimeout(ctx, nestedStep, func(ctx context.Context) (*StepResult, error) {
+				ctx, err := e.injectStepFault(ctx, wfCtx, nestedStep)
+				if err != nil {
+					return &StepResult{Error: err}, nil
+				}
+				switch nestedStep.Config.StepType() {
+				case "query":
+					return e.executeQueryStep(ctx, nestedStep, execData)
+				case "httpcall":
+					return e.executeHTTPCallStep(ctx, nestedStep, execData)
+				case "set":
+					return e.executeSetStep(nestedStep, execData)
+				case "script":
+					return e.executeScriptStep(ctx, nestedStep, execData)
+				case "email":
+					return e.executeEmailStep(ctx, nestedStep, execData)
+				case "notify":
+					return e.executeNotifyStep(ctx, nestedStep, execData)
+				case "storage":
+					return e.executeStorageStep(ctx, nestedStep, execData)
+				case "sftp":
+					return e.executeSFTPStep(ctx, nestedStep, execData)
+				case "bulk_insert":
+					return e.executeBulkInsertStep(ctx, nestedStep, execData)
+				case "mqtt":
+					return e.executeMQTTStep(ctx, nestedStep, execData)
+				case "metric":
+					return e.executeMetricStep(nestedStep, execData)
+				case "response_sse":
+					return e.executeResponseSSEStep(ctx, nestedStep, execData)
+				default:
+					return nil, fmt.Errorf("unsupported step type in block: %s", nestedStep.Config.StepType())
+				}
+			})
 
 			if err != nil {
 				result.Error = err
@@ -433,14 +1010,35 @@ func (e *Executor) executeBlockStep(ctx context.Context, cs *CompiledStep, wfCtx
 			iterResult.Steps[stepName] = stepResult
 
 			if !stepResult.Success {
-				iterResult.Success = false
-				iterResult.Error = stepResult.Error
+				metrics.RecordStepError(wfCtx.Workflow.Config.Name, cs.Config.Name+"."+stepName, stepResult.TimedOut)
 
 				stepOnError := nestedStep.Config.OnError
 				if stepOnError == "" {
 					stepOnError = "abort"
 				}
 
+				if stepOnError == "fallback" {
+					errMsg := "unknown error"
+					if stepResult.Error != nil {
+						errMsg = stepResult.Error.Error()
+					}
+					err := applyFallback(nestedStep, stepResult, execData.TemplateData)
+					if err == nil {
+						e.logger.Warn("block_step_fallback", map[string]any{
+							"block":     cs.Config.Name,
+							"step":      stepName,
+							"iteration": i,
+							"error":     errMsg,
+						})
+						continue
+					}
+					stepResult.Error = fmt.Errorf("%s (fallback failed: %w)", errMsg, err)
+					stepOnError = "abort"
+				}
+
+				iterResult.Success = false
+				iterResult.Error = stepResult.Error
+
 				if stepOnError == "abort" {
 					break
 				}
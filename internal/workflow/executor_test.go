@@ -2,15 +2,24 @@ package workflow
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"text/template"
 	"time"
 
+	"github.com/expr-lang/expr/vm"
+
+	"sql-proxy/internal/concurrency"
+	"sql-proxy/internal/errtrack"
+	"sql-proxy/internal/metrics"
 	"sql-proxy/internal/workflow/step"
 )
 
@@ -109,6 +118,198 @@ func TestExecutor_Execute_SimpleQuery(t *testing.T) {
 	}
 }
 
+func TestExecutor_RunningWorkflows(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			started <- struct{}{}
+			<-release
+			return &step.QueryResult{}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "slow"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "q", Type: "query", Database: "testdb"},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT 1")),
+			},
+		},
+	}
+
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req", nil, nil)
+			done <- struct{}{}
+		}()
+	}
+	<-started
+	<-started
+
+	if got := exec.RunningWorkflows()["slow"]; got != 2 {
+		t.Errorf("running = %d, want 2", got)
+	}
+
+	close(release)
+	<-done
+	<-done
+
+	if got, ok := exec.RunningWorkflows()["slow"]; !ok || got != 0 {
+		t.Errorf("running after completion = %d (present=%v), want 0", got, ok)
+	}
+}
+
+func TestExecutor_MaxConcurrent(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			started <- struct{}{}
+			<-release
+			return &step.QueryResult{}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "report", MaxConcurrent: 1},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "q", Type: "query", Database: "testdb"},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT 1")),
+			},
+		},
+	}
+
+	done := make(chan *ExecuteResult)
+	go func() {
+		done <- exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+	}()
+	<-started
+
+	// Second execution is rejected immediately (no wait configured)
+	result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-2", nil, nil)
+	if !errors.Is(result.Error, concurrency.ErrLimitReached) {
+		t.Errorf("expected ErrLimitReached, got %v", result.Error)
+	}
+	if st := exec.ConcurrencyStats()["report"]; st.Limit != 1 || st.InUse != 1 || st.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	close(release)
+	if r := <-done; r.Error != nil {
+		t.Errorf("first execution failed: %v", r.Error)
+	}
+}
+
+func TestExecutor_MaxConcurrent_Wait(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			if calls.Add(1) == 1 {
+				<-release
+			}
+			return &step.QueryResult{}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "report", MaxConcurrent: 1, MaxConcurrentWaitMs: 2000},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "q", Type: "query", Database: "testdb"},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT 1")),
+			},
+		},
+	}
+
+	go exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.AfterFunc(20*time.Millisecond, func() { close(release) })
+
+	// Queued execution runs once the first one finishes
+	if result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-2", nil, nil); result.Error != nil {
+		t.Errorf("expected queued execution to succeed, got %v", result.Error)
+	}
+}
+
+func TestExecutor_WorkerPool(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			if sql == "SELECT bulk" {
+				started <- struct{}{}
+				<-release
+			}
+			return &step.QueryResult{}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	exec.SetWorkerPool(1, 1, 0)
+
+	query := func(name, sql string) *CompiledWorkflow {
+		return &CompiledWorkflow{
+			Config: &WorkflowConfig{Name: name},
+			Steps: []*CompiledStep{{
+				Config:  &StepConfig{Name: "q", Type: "query", Database: "testdb"},
+				SQLTmpl: template.Must(template.New("sql").Parse(sql)),
+			}},
+		}
+	}
+	bulk, lookup := query("bulk", "SELECT bulk"), query("lookup", "SELECT 1")
+
+	done := make(chan *ExecuteResult, 2)
+	go func() {
+		done <- exec.Execute(context.Background(), bulk, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+	}()
+	<-started
+
+	// The next execution queues behind the busy worker at its trigger's default priority
+	go func() {
+		done <- exec.Execute(context.Background(), lookup, &TriggerData{Type: "http"}, "req-2", nil, nil)
+	}()
+	for exec.WorkerPoolStats().Queued["normal"] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// With the queue full, further executions are rejected
+	result := exec.Execute(context.Background(), lookup, &TriggerData{Type: "http", Priority: "critical"}, "req-3", nil, nil)
+	if !errors.Is(result.Error, concurrency.ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", result.Error)
+	}
+	st := exec.WorkerPoolStats()
+	if st.Workers != 1 || st.Busy != 1 || st.Rejected["critical"] != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	close(release)
+	for range 2 {
+		if r := <-done; r.Error != nil {
+			t.Errorf("execution failed: %v", r.Error)
+		}
+	}
+	if st := exec.WorkerPoolStats(); st.Busy != 0 || st.Queued["normal"] != 0 {
+		t.Errorf("unexpected stats after release: %+v", st)
+	}
+}
+
+func TestDefaultPriority(t *testing.T) {
+	for triggerType, want := range map[string]string{"http": "normal", "grpc": "normal", "websocket": "normal", "cron": "low", "mqtt": "low", "dbwatch": "low", "filewatch": "low"} {
+		if got := DefaultPriority(triggerType); got != want {
+			t.Errorf("DefaultPriority(%s) = %s, want %s", triggerType, got, want)
+		}
+	}
+}
+
 func TestExecutor_Execute_DisabledStep(t *testing.T) {
 	db := &mockDBManager{
 		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
@@ -219,51 +420,965 @@ func TestExecutor_Execute_StepFailure_Abort(t *testing.T) {
 	if result.Error == nil {
 		t.Error("Error should be set")
 	}
-	if len(stepOrder) != 1 {
-		t.Errorf("step order = %v, want [step1] only", stepOrder)
+	if len(stepOrder) != 1 {
+		t.Errorf("step order = %v, want [step1] only", stepOrder)
+	}
+	if result.Steps["step2_fail"].TimedOut {
+		t.Error("TimedOut = true, want false for a plain query error")
+	}
+}
+
+func TestExecutor_Execute_StepFailure_Continue(t *testing.T) {
+	stepOrder := []string{}
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			stepOrder = append(stepOrder, sql)
+			if strings.Contains(sql, "fail") {
+				return nil, errors.New("query failed")
+			}
+			return &step.QueryResult{Rows: []map[string]any{}}, nil
+		},
+	}
+	logger := &testLogger{}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, logger)
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "step1", Type: "query", Database: "db"},
+				SQLTmpl: template.Must(template.New("sql").Parse("step1")),
+			},
+			{
+				Config:  &StepConfig{Name: "step2_fail", Type: "query", Database: "db", OnError: "continue"},
+				SQLTmpl: template.Must(template.New("sql").Parse("fail")),
+			},
+			{
+				Config:  &StepConfig{Name: "step3", Type: "query", Database: "db"},
+				SQLTmpl: template.Must(template.New("sql").Parse("step3")),
+			},
+		},
+	}
+
+	trigger := &TriggerData{Type: "http"}
+	result := exec.Execute(context.Background(), wf, trigger, "req-1", nil, nil)
+
+	if !result.Success {
+		t.Error("Success = false, want true (on_error: continue)")
+	}
+	if len(stepOrder) != 3 {
+		t.Errorf("step order = %v, want [step1, fail, step3]", stepOrder)
+	}
+}
+
+func TestExecutor_Execute_StepFailure_Fallback(t *testing.T) {
+	stepOrder := []string{}
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			stepOrder = append(stepOrder, sql)
+			if strings.Contains(sql, "fail") {
+				return nil, errors.New("query failed")
+			}
+			return &step.QueryResult{Rows: []map[string]any{{"id": 7}}}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "user", Type: "query", Database: "db"},
+				SQLTmpl: template.Must(template.New("sql").Parse("user")),
+			},
+			{
+				Config:       &StepConfig{Name: "enrich", Type: "query", Database: "db", OnError: "fallback"},
+				SQLTmpl:      template.Must(template.New("sql").Parse("fail")),
+				FallbackTmpl: template.Must(template.New("fallback").Parse(`{"user_id": {{.steps.user.row.id}}, "tier": "basic"}`)),
+			},
+			{
+				Config:  &StepConfig{Name: "after", Type: "query", Database: "db"},
+				SQLTmpl: template.Must(template.New("sql").Parse("after")),
+			},
+		},
+	}
+
+	result := exec.Execute(context.Background(), wf, &TriggerData{Type: "http"}, "req-1", nil, nil)
+
+	if !result.Success {
+		t.Fatalf("Success = false, want true (on_error: fallback): %v", result.Error)
+	}
+	if len(stepOrder) != 3 {
+		t.Errorf("step order = %v, want all three steps", stepOrder)
+	}
+	sr := result.Steps["enrich"]
+	if !sr.Fallback || sr.Success || sr.Error == nil {
+		t.Errorf("step result = %+v, want failed step with fallback data", sr)
+	}
+	if sr.Count != 1 || sr.Data[0]["tier"] != "basic" || sr.Data[0]["user_id"] != float64(7) {
+		t.Errorf("Data = %v, want fallback row", sr.Data)
+	}
+}
+
+func TestExecutor_Execute_StepFailure_InvalidFallback(t *testing.T) {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return nil, errors.New("query failed")
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test"},
+		Steps: []*CompiledStep{
+			{
+				Config:       &StepConfig{Name: "enrich", Type: "query", Database: "db", OnError: "fallback"},
+				SQLTmpl:      template.Must(template.New("sql").Parse("fail")),
+				FallbackTmpl: template.Must(template.New("fallback").Parse(`[1, 2]`)),
+			},
+		},
+	}
+
+	result := exec.Execute(context.Background(), wf, &TriggerData{Type: "http"}, "req-1", nil, nil)
+
+	if result.Success {
+		t.Fatal("Success = true, want false when the fallback is unusable")
+	}
+	if !strings.Contains(result.Error.Error(), "fallback failed") {
+		t.Errorf("Error = %v, want fallback failure", result.Error)
+	}
+}
+
+func TestApplyFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		fallback  string
+		wantCount int
+		wantErr   bool
+	}{
+		{"empty array", `[]`, 0, false},
+		{"object", `{"status": "unknown"}`, 1, false},
+		{"array of objects", `[{"a": 1}, {"a": 2}]`, 2, false},
+		{"scalar", `"none"`, 0, true},
+		{"array of scalars", `[1]`, 0, true},
+		{"invalid JSON", `{`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := &CompiledStep{FallbackTmpl: template.Must(template.New("fallback").Parse(tt.fallback))}
+			result := &StepResult{Error: errors.New("failed")}
+			err := applyFallback(cs, result, map[string]any{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyFallback() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if result.Fallback {
+					t.Error("Fallback = true after a failed fallback")
+				}
+				return
+			}
+			if !result.Fallback || result.Count != tt.wantCount || result.Data == nil {
+				t.Errorf("result = %+v, want %d fallback rows", result, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestExecutor_Execute_SetStep(t *testing.T) {
+	var gotSQL string
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			gotSQL = sql
+			return &step.QueryResult{Rows: []map[string]any{}}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	limitExpr, _ := compileExpression("trigger.params.page_size * 2")
+	adminExpr, _ := compileExpression(`trigger.params.role == "admin"`)
+	condition, _ := compileCondition("steps.derived.is_admin")
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test"},
+		Steps: []*CompiledStep{
+			{
+				Config:   &StepConfig{Name: "derived", Type: "set"},
+				SetExprs: map[string]*vm.Program{"limit": limitExpr, "is_admin": adminExpr},
+				SetTmpls: map[string]*template.Template{
+					"label": template.Must(template.New("label").Parse("{{.trigger.params.role}}-users")),
+				},
+			},
+			{
+				Config:    &StepConfig{Name: "fetch", Type: "query", Database: "db"},
+				Condition: condition,
+				SQLTmpl:   template.Must(template.New("sql").Parse("SELECT TOP {{.steps.derived.limit}} * FROM {{.steps.derived.label}}")),
+			},
+		},
+	}
+
+	trigger := &TriggerData{Type: "http", Params: map[string]any{"page_size": 10, "role": "admin"}}
+	result := exec.Execute(context.Background(), wf, trigger, "req-1", nil, nil)
+
+	if !result.Success {
+		t.Fatalf("Success = false: %v", result.Error)
+	}
+	sr := result.Steps["derived"]
+	if sr.Values["limit"] != 20 || sr.Values["is_admin"] != true || sr.Values["label"] != "admin-users" {
+		t.Errorf("Values = %v", sr.Values)
+	}
+	if gotSQL != "SELECT TOP 20 * FROM admin-users" {
+		t.Errorf("SQL = %q, want values from the set step", gotSQL)
+	}
+}
+
+func TestExecutor_Execute_SetStep_Error(t *testing.T) {
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+
+	ratioExpr, _ := compileExpression("trigger.params.name * 2")
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test"},
+		Steps: []*CompiledStep{
+			{
+				Config:   &StepConfig{Name: "derived", Type: "set"},
+				SetExprs: map[string]*vm.Program{"ratio": ratioExpr},
+			},
+		},
+	}
+
+	trigger := &TriggerData{Type: "http", Params: map[string]any{"name": "x"}}
+	result := exec.Execute(context.Background(), wf, trigger, "req-1", nil, nil)
+
+	if result.Success {
+		t.Fatal("Success = true, want false for a failing expression")
+	}
+	if !strings.Contains(result.Error.Error(), "values[ratio]") {
+		t.Errorf("Error = %v, want the failing value named", result.Error)
+	}
+}
+
+func TestExecutor_Execute_MetricStep(t *testing.T) {
+	metrics.Init(nil, "test", "")
+	defer metrics.Clear()
+
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{Rows: []map[string]any{{"depth": "42"}}}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	wf, err := Compile(&WorkflowConfig{
+		Name:     "test",
+		Triggers: []TriggerConfig{{Type: "cron", Schedule: "* * * * *"}},
+		Steps: []StepConfig{
+			{Name: "count", Type: "query", Database: "db", SQL: "SELECT COUNT(*) AS depth FROM exports"},
+			{Name: "depth", Type: "metric", Metric: &MetricConfig{
+				Name:   "test_export_queue_depth",
+				Type:   "gauge",
+				Value:  "steps.count.data[0].depth",
+				Labels: map[string]string{"queue": "{{.trigger.params.queue}}"},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron", Params: map[string]any{"queue": "exports"}}, "req-1", nil, nil)
+	if !result.Success {
+		t.Fatalf("Success = false: %v", result.Error)
+	}
+	if v := result.Steps["depth"].Values["value"]; v != 42.0 {
+		t.Errorf("value = %v, want 42", v)
+	}
+
+	families, err := metrics.Registry().Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != "test_export_queue_depth" {
+			continue
+		}
+		m := f.GetMetric()[0]
+		if m.GetGauge().GetValue() != 42 || m.GetLabel()[0].GetName() != "queue" || m.GetLabel()[0].GetValue() != "exports" {
+			t.Errorf("metric = %v", m)
+		}
+		return
+	}
+	t.Error("test_export_queue_depth not registered")
+}
+
+func TestMetricValue(t *testing.T) {
+	tests := []struct {
+		in      any
+		want    float64
+		wantErr bool
+	}{
+		{int64(3), 3, false},
+		{2.5, 2.5, false},
+		{" 7.25 ", 7.25, false},
+		{[]byte("12"), 12, false},
+		{true, 1, false},
+		{"many", 0, true},
+		{nil, 0, true},
+		{[]any{1}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := metricValue(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("metricValue(%v) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestExecutor_Execute_ScriptStep(t *testing.T) {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{Rows: []map[string]any{
+				{"customer": "acme", "total": 10},
+				{"customer": "globex", "total": 5},
+				{"customer": "acme", "total": 7},
+			}}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	script, err := compileScript("summary", `
+		const byCustomer = {};
+		for (const order of steps.orders.data) {
+			(byCustomer[order.customer] ??= []).push(order);
+		}
+		steps.orders.data.length = 0; // Scripts work on a copy
+		return Object.keys(byCustomer).sort().map(customer => ({
+			customer,
+			orders: byCustomer[customer].length,
+			total: byCustomer[customer].reduce((sum, o) => sum + o.total, 0),
+		}));`)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "orders", Type: "query", Database: "db"},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT customer, total FROM orders")),
+			},
+			{Config: &StepConfig{Name: "summary", Type: "script"}, ScriptProg: script},
+		},
+	}
+
+	result := exec.Execute(context.Background(), wf, &TriggerData{Type: "http"}, "req-1", nil, nil)
+
+	if !result.Success {
+		t.Fatalf("Success = false: %v", result.Error)
+	}
+	sr := result.Steps["summary"]
+	if sr.Count != 2 {
+		t.Fatalf("Count = %d, want 2 (data = %v)", sr.Count, sr.Data)
+	}
+	if sr.Data[0]["customer"] != "acme" || sr.Data[0]["orders"] != int64(2) || sr.Data[0]["total"] != int64(17) {
+		t.Errorf("Data[0] = %v, want acme with 2 orders totalling 17", sr.Data[0])
+	}
+	if len(result.Steps["orders"].Data) != 3 {
+		t.Errorf("orders data = %v, want it unchanged by the script", result.Steps["orders"].Data)
+	}
+}
+
+func TestExecutor_Execute_ScriptStep_Limits(t *testing.T) {
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+
+	run := func(cfg *StepConfig) *StepResult {
+		t.Helper()
+		prog, err := compileScript(cfg.Name, cfg.Script)
+		if err != nil {
+			t.Fatalf("compile: %v", err)
+		}
+		wf := &CompiledWorkflow{
+			Config: &WorkflowConfig{Name: "test"},
+			Steps:  []*CompiledStep{{Config: cfg, ScriptProg: prog}},
+		}
+		return exec.Execute(context.Background(), wf, &TriggerData{Type: "http"}, "req-1", nil, nil).Steps[cfg.Name]
+	}
+
+	// A scalar list is the step's result but not rows
+	r := run(&StepConfig{Name: "list", Type: "script", Script: `return Array.from({length: 1000}, (_, i) => i * 2)`})
+	if out, ok := r.Output.([]any); !r.Success || !ok || len(out) != 1000 || r.Data != nil {
+		t.Errorf("Output = %T, Data = %v, error %v; want 1000 values and no rows", r.Output, r.Data, r.Error)
+	}
+
+	// No I/O is in scope
+	r = run(&StepConfig{Name: "io", Type: "script", Script: `return [typeof require, typeof console, typeof fetch]`})
+	if !r.Success || fmt.Sprint(r.Output) != "[undefined undefined undefined]" {
+		t.Errorf("Output = %v, error %v", r.Output, r.Error)
+	}
+
+	r = run(&StepConfig{Name: "hog", Type: "script", MemoryMB: 8, Script: `const a = []; for (;;) a.push({n: a.length, s: "x" + a.length})`})
+	if r.Success || !strings.Contains(r.Error.Error(), "memory limit of 8 MB exceeded") {
+		t.Errorf("Error = %v, want memory limit error", r.Error)
+	}
+
+	r = run(&StepConfig{Name: "loop", Type: "script", TimeoutSec: 1, Script: `for (;;) {}`})
+	if r.Success || !r.TimedOut {
+		t.Errorf("Success = %v, TimedOut = %v, error %v; want a timeout", r.Success, r.TimedOut, r.Error)
+	}
+
+	r = run(&StepConfig{Name: "recurse", Type: "script", Script: `function f(n) { return f(n + 1) } return f(0)`})
+	if r.Success || r.Error == nil {
+		t.Errorf("Success = %v, want the call stack limit to stop the script", r.Success)
+	}
+}
+
+// mockMailer implements step.Mailer for testing.
+type mockMailer struct {
+	sent []step.EmailMessage
+	err  error
+}
+
+func (m *mockMailer) SendEmail(ctx context.Context, msg step.EmailMessage) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestExecutor_Execute_EmailStep(t *testing.T) {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{Rows: []map[string]any{{"id": 1}, {"id": 2}}}, nil
+		},
+	}
+	mailer := &mockMailer{}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	exec.SetMailer(mailer)
+
+	emailCfg := &StepConfig{
+		Name:    "notify",
+		Type:    "email",
+		To:      []string{"ops@example.com, {{.trigger.params.owner}}", "{{if false}}skipped@example.com{{end}}"},
+		Bcc:     []string{"audit@example.com"},
+		Subject: "{{.steps.orders.count}} new orders",
+		Text:    "{{range .steps.orders.data}}#{{.id}} {{end}}",
+		HTML:    "<p>{{.steps.orders.count}}</p>",
+	}
+	emailTmpls, err := compileEmail(emailCfg)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "orders", Type: "query", Database: "db"},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT id FROM orders")),
+			},
+			{Config: emailCfg, EmailTmpls: emailTmpls},
+		},
+	}
+
+	trigger := &TriggerData{Type: "http", Params: map[string]any{"owner": "owner@example.com"}}
+	result := exec.Execute(context.Background(), wf, trigger, "req-1", nil, nil)
+
+	if !result.Success {
+		t.Fatalf("Success = false: %v", result.Error)
+	}
+	if len(mailer.sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(mailer.sent))
+	}
+	msg := mailer.sent[0]
+	if len(msg.To) != 1 || msg.To[0] != "ops@example.com, owner@example.com" {
+		t.Errorf("To = %q, want the rendered list without the empty entry", msg.To)
+	}
+	if msg.Subject != "2 new orders" || msg.Text != "#1 #2 " || msg.HTML != "<p>2</p>" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if got := result.Steps["notify"].Count; got != 3 {
+		t.Errorf("Count = %d, want 3 recipients", got)
+	}
+}
+
+func TestExecutor_Execute_EmailStep_Errors(t *testing.T) {
+	emailCfg := &StepConfig{Name: "notify", Type: "email", To: []string{"ops@example.com"}, Subject: "s", Text: "t"}
+	emailTmpls, _ := compileEmail(emailCfg)
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test"},
+		Steps:  []*CompiledStep{{Config: emailCfg, EmailTmpls: emailTmpls}},
+	}
+
+	t.Run("no mailer", func(t *testing.T) {
+		exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+		result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+		if result.Success || !strings.Contains(result.Error.Error(), "requires smtp") {
+			t.Errorf("Error = %v, want smtp error", result.Error)
+		}
+	})
+
+	t.Run("send failure", func(t *testing.T) {
+		exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+		exec.SetMailer(&mockMailer{err: errors.New("connection refused")})
+		result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+		if result.Success || !strings.Contains(result.Error.Error(), "sending email: connection refused") {
+			t.Errorf("Error = %v, want send error", result.Error)
+		}
+	})
+}
+
+func TestExecutor_Execute_NotifyWhen(t *testing.T) {
+	var sent []string
+	client := &mockHTTPClient{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			sent = append(sent, string(body))
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("ok")), Header: make(http.Header)}, nil
+		},
+	}
+
+	notify := func(name, when string) StepConfig {
+		return StepConfig{
+			Name: name, Type: "notify", Provider: "webhook", URL: "https://hooks.example.com", When: when,
+			Text: "{{.workflow.failed_step}}: {{.workflow.error}}",
+		}
+	}
+
+	t.Run("failure runs after abort", func(t *testing.T) {
+		sent = nil
+		db := &mockDBManager{
+			queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+				return nil, errors.New("deadlock")
+			},
+		}
+		exec := NewExecutor(db, client, nil, &testLogger{})
+		wf := mustCompile(t, &WorkflowConfig{
+			Name: "nightly",
+			Steps: []StepConfig{
+				{Name: "load", Type: "query", Database: "db", SQL: "SELECT 1"},
+				notify("on_success", "success"),
+				notify("on_failure", "failure"),
+			},
+		})
+
+		result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+
+		if result.Success || result.Error == nil || result.Error.Error() != "deadlock" {
+			t.Errorf("Error = %v, want the original step error", result.Error)
+		}
+		if len(sent) != 1 || !strings.Contains(sent[0], `"text":"load: deadlock"`) {
+			t.Fatalf("sent = %v, want one failure notification", sent)
+		}
+		if sr := result.Steps["on_failure"]; sr == nil || !sr.Success {
+			t.Errorf("on_failure result = %+v", sr)
+		}
+		if _, ok := result.Steps["on_success"]; ok {
+			t.Error("success notification should not run")
+		}
+	})
+
+	t.Run("success skips failure", func(t *testing.T) {
+		sent = nil
+		exec := NewExecutor(&mockDBManager{}, client, nil, &testLogger{})
+		wf := mustCompile(t, &WorkflowConfig{
+			Name: "nightly",
+			Steps: []StepConfig{
+				{Name: "load", Type: "query", Database: "db", SQL: "SELECT 1"},
+				notify("on_failure", "failure"),
+				notify("on_success", "success"),
+			},
+		})
+
+		result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+
+		if !result.Success {
+			t.Fatalf("Success = false: %v", result.Error)
+		}
+		if len(sent) != 1 || result.Steps["on_success"] == nil || result.Steps["on_failure"] != nil {
+			t.Errorf("sent = %v, steps = %v; want only the success notification", sent, result.Steps)
+		}
+	})
+
+	t.Run("failure after continue", func(t *testing.T) {
+		sent = nil
+		db := &mockDBManager{
+			queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+				return nil, errors.New("timeout")
+			},
+		}
+		exec := NewExecutor(db, client, nil, &testLogger{})
+		wf := mustCompile(t, &WorkflowConfig{
+			Name: "nightly",
+			Steps: []StepConfig{
+				{Name: "load", Type: "query", Database: "db", SQL: "SELECT 1", OnError: "continue"},
+				notify("on_failure", "failure"),
+			},
+		})
+
+		result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+
+		if !result.Success {
+			t.Fatalf("Success = false: %v", result.Error)
+		}
+		if len(sent) != 1 || !strings.Contains(sent[0], "load: timeout") {
+			t.Errorf("sent = %v, want failure notification", sent)
+		}
+	})
+}
+
+// mockObjectStore implements step.ObjectStore for testing.
+type mockObjectStore struct {
+	puts []step.StorageObject
+}
+
+func (m *mockObjectStore) PutObject(ctx context.Context, store string, obj step.StorageObject) (*step.StoredObject, error) {
+	if store != "exports" {
+		return nil, fmt.Errorf("unknown store %q", store)
+	}
+	m.puts = append(m.puts, obj)
+	return &step.StoredObject{URL: "https://" + obj.Bucket + ".s3.amazonaws.com/" + obj.Key, ETag: "e1"}, nil
+}
+
+func TestExecutor_Execute_StorageStep(t *testing.T) {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{Rows: []map[string]any{
+				{"id": 1, "name": "Acme, Inc.", "note": nil},
+				{"id": 2, "name": "Globex", "note": "vip"},
+			}}, nil
+		},
+	}
+	store := &mockObjectStore{}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	exec.SetObjectStore(store)
+
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "export",
+		Steps: []StepConfig{
+			{Name: "customers", Type: "query", Database: "db", SQL: "SELECT id, name, note FROM customers"},
+			{
+				Name: "upload", Type: "storage", Store: "exports", Bucket: "reports", Key: "customers/{{.trigger.params.day}}.csv",
+				Source: "steps.customers.data", Format: "csv", Columns: []string{"id", "name", "note"}, Encryption: "AES256",
+			},
+			{
+				Name: "manifest", Type: "storage", Store: "exports", Bucket: "reports", Key: "customers/latest.json",
+				Body: `{"url": "{{.steps.upload.url}}", "size": {{.steps.upload.size}}}`,
+			},
+		},
+	})
+
+	trigger := &TriggerData{Type: "cron", Params: map[string]any{"day": "2024-03-01"}}
+	result := exec.Execute(context.Background(), wf, trigger, "req-1", nil, nil)
+
+	if !result.Success {
+		t.Fatalf("Success = false: %v", result.Error)
+	}
+	if len(store.puts) != 2 {
+		t.Fatalf("puts = %d, want 2", len(store.puts))
+	}
+
+	csvPut := store.puts[0]
+	if csvPut.Key != "customers/2024-03-01.csv" || csvPut.ContentType != "text/csv; charset=utf-8" || csvPut.Encryption != "AES256" {
+		t.Errorf("csv put = %+v", csvPut)
+	}
+	if want := "id,name,note\n1,\"Acme, Inc.\",\n2,Globex,vip\n"; string(csvPut.Body) != want {
+		t.Errorf("csv body = %q, want %q", csvPut.Body, want)
+	}
+
+	manifest := store.puts[1]
+	wantManifest := `{"url": "https://reports.s3.amazonaws.com/customers/2024-03-01.csv", "size": 42}`
+	if string(manifest.Body) != wantManifest || manifest.ContentType != "application/json" {
+		t.Errorf("manifest = %q (%s), want %q", manifest.Body, manifest.ContentType, wantManifest)
+	}
+}
+
+func TestExecutor_Execute_StorageStep_NotConfigured(t *testing.T) {
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:  "export",
+		Steps: []StepConfig{{Name: "upload", Type: "storage", Store: "exports", Bucket: "b", Key: "k", Body: "x"}},
+	})
+
+	result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+
+	if result.Success || !strings.Contains(result.Error.Error(), "requires storage to be configured") {
+		t.Errorf("Error = %v, want storage error", result.Error)
+	}
+}
+
+// mockFileUploader implements step.FileUploader for testing.
+type mockFileUploader struct {
+	failures int // Uploads that fail before one succeeds
+	calls    int
+	paths    []string
+	data     [][]byte
+}
+
+func (m *mockFileUploader) Upload(ctx context.Context, server, remotePath string, data []byte, createDirs bool) error {
+	m.calls++
+	if server != "partner" {
+		return fmt.Errorf("unknown sftp server %q", server)
+	}
+	if m.calls <= m.failures {
+		return errors.New("connection reset by peer")
+	}
+	m.paths = append(m.paths, remotePath)
+	m.data = append(m.data, data)
+	return nil
+}
+
+func TestExecutor_Execute_SFTPStep(t *testing.T) {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{Rows: []map[string]any{{"id": 1, "total": 9.5}, {"id": 2, "total": 12}}}, nil
+		},
+	}
+	uploader := &mockFileUploader{failures: 1}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	exec.SetFileUploader(uploader)
+
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "deliver",
+		Steps: []StepConfig{
+			{Name: "orders", Type: "query", Database: "db", SQL: "SELECT id, total FROM orders"},
+			{
+				Name: "upload", Type: "sftp", Server: "partner", RemotePath: "/in/orders-{{.trigger.params.day}}.csv",
+				Source: "steps.orders.data", Format: "csv", CreateDirs: true,
+				Retry: &RetryConfig{Enabled: true, MaxAttempts: 2, InitialBackoffSec: 1},
+			},
+			{
+				Name: "done", Type: "sftp", Server: "partner", RemotePath: "/in/orders-{{.trigger.params.day}}.done",
+				Body: "{{.steps.upload.size}} bytes, {{.steps.upload.attempts}} attempts",
+			},
+		},
+	})
+
+	trigger := &TriggerData{Type: "cron", Params: map[string]any{"day": "2024-03-01"}}
+	result := exec.Execute(context.Background(), wf, trigger, "req-1", nil, nil)
+
+	if !result.Success {
+		t.Fatalf("Success = false: %v", result.Error)
+	}
+	if uploader.calls != 3 {
+		t.Errorf("upload calls = %d, want 3 (one retry)", uploader.calls)
+	}
+	wantPaths := []string{"/in/orders-2024-03-01.csv", "/in/orders-2024-03-01.done"}
+	if fmt.Sprint(uploader.paths) != fmt.Sprint(wantPaths) {
+		t.Errorf("paths = %v, want %v", uploader.paths, wantPaths)
+	}
+	if want := "id,total\n1,9.5\n2,12\n"; string(uploader.data[0]) != want {
+		t.Errorf("csv = %q, want %q", uploader.data[0], want)
+	}
+	if want := "20 bytes, 2 attempts"; string(uploader.data[1]) != want {
+		t.Errorf("marker = %q, want %q", uploader.data[1], want)
+	}
+}
+
+func TestExecutor_Execute_SFTPStep_Errors(t *testing.T) {
+	t.Run("retries exhausted", func(t *testing.T) {
+		uploader := &mockFileUploader{failures: 5}
+		exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+		exec.SetFileUploader(uploader)
+		wf := mustCompile(t, &WorkflowConfig{
+			Name: "deliver",
+			Steps: []StepConfig{{
+				Name: "upload", Type: "sftp", Server: "partner", RemotePath: "/in/x.txt", Body: "x",
+				Retry: &RetryConfig{Enabled: true, MaxAttempts: 2, InitialBackoffSec: 1},
+			}},
+		})
+
+		result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+
+		if result.Success || result.Error.Error() != "connection reset by peer (after 2 attempts)" {
+			t.Errorf("Error = %v", result.Error)
+		}
+		if uploader.calls != 2 {
+			t.Errorf("upload calls = %d, want 2", uploader.calls)
+		}
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+		wf := mustCompile(t, &WorkflowConfig{
+			Name:  "deliver",
+			Steps: []StepConfig{{Name: "upload", Type: "sftp", Server: "partner", RemotePath: "/in/x.txt", Body: "x"}},
+		})
+
+		result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+
+		if result.Success || !strings.Contains(result.Error.Error(), "requires sftp servers to be configured") {
+			t.Errorf("Error = %v, want sftp error", result.Error)
+		}
+	})
+}
+
+func TestExecutor_Execute_BulkInsertStep(t *testing.T) {
+	var statements []string
+	var batchParams []map[string]any
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			if opts.IsWrite == nil || !*opts.IsWrite {
+				t.Error("bulk insert batches should be classified as writes")
+			}
+			statements = append(statements, sql)
+			batchParams = append(batchParams, params)
+			if len(statements) == 2 && params["r0_0"] == "bad" {
+				return nil, errors.New("constraint violation")
+			}
+			return &step.QueryResult{RowsAffected: int64(len(params) / 2)}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	rows := []any{
+		map[string]any{"id": "a", "meta": map[string]any{"k": 1}},
+		map[string]any{"id": "b"},
+		map[string]any{"id": "bad"},
+		map[string]any{"id": "d"},
+		map[string]any{"id": "e"},
+	}
+	run := func(policy string) *ExecuteResult {
+		statements, batchParams = nil, nil
+		wf := mustCompile(t, &WorkflowConfig{
+			Name: "load",
+			Steps: []StepConfig{
+				{
+					Name: "load", Type: "bulk_insert", Database: "db", Table: "dbo.items",
+					Source: "trigger.params.rows", BatchSize: 2, OnBatchError: policy,
+				},
+				{Name: "summary", Values: map[string]string{"text": "{{.steps.load.rows_affected}}/{{.steps.load.batches}}/{{.steps.load.failed_batches}}"}},
+			},
+		})
+		return exec.Execute(context.Background(), wf, &TriggerData{Type: "http", Params: map[string]any{"rows": rows}}, "req-1", nil, nil)
 	}
+
+	t.Run("continue", func(t *testing.T) {
+		result := run("continue")
+		if !result.Success {
+			t.Fatalf("Success = false: %v", result.Error)
+		}
+		if len(statements) != 3 {
+			t.Fatalf("statements = %d, want 3 batches", len(statements))
+		}
+		if want := "INSERT INTO dbo.items (id, meta) VALUES (@r0_0, @r0_1), (@r1_0, @r1_1)"; statements[0] != want {
+			t.Errorf("sql = %q, want %q", statements[0], want)
+		}
+		if batchParams[0]["r0_1"] != `{"k":1}` || batchParams[0]["r1_1"] != nil {
+			t.Errorf("params = %v, want JSON text and NULL for the missing column", batchParams[0])
+		}
+		if got := result.Steps["summary"].Values["text"]; got != "3/3/1" {
+			t.Errorf("summary = %v, want 3/3/1", got)
+		}
+		errs := result.Steps["load"].Values["errors"].([]string)
+		if len(errs) != 1 || errs[0] != "batch 2 (rows 3-4): constraint violation" {
+			t.Errorf("errors = %v", errs)
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		result := run("")
+		if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), "batch 2 (rows 3-4): constraint violation") {
+			t.Errorf("Error = %v, want batch 2 failure", result.Error)
+		}
+		if len(statements) != 2 {
+			t.Errorf("statements = %d, want 2 (stop at the failing batch)", len(statements))
+		}
+		if result.Steps["load"].RowsAffected != 2 {
+			t.Errorf("RowsAffected = %d, want rows of the batch before the failure", result.Steps["load"].RowsAffected)
+		}
+	})
+
+	t.Run("invalid source", func(t *testing.T) {
+		wf := mustCompile(t, &WorkflowConfig{
+			Name:  "load",
+			Steps: []StepConfig{{Name: "load", Type: "bulk_insert", Database: "db", Table: "items", Source: "trigger.params.rows"}},
+		})
+		for _, tc := range []struct {
+			rows    any
+			wantErr string
+		}{
+			{[]any{1, 2}, "source must be an object or a list of objects"},
+			{[]any{map[string]any{"bad column": 1}}, `source column "bad column" is not a valid column name`},
+		} {
+			result := exec.Execute(context.Background(), wf, &TriggerData{Params: map[string]any{"rows": tc.rows}}, "req-1", nil, nil)
+			if result.Success || !strings.Contains(result.Error.Error(), tc.wantErr) {
+				t.Errorf("Error = %v, want %q", result.Error, tc.wantErr)
+			}
+		}
+	})
 }
 
-func TestExecutor_Execute_StepFailure_Continue(t *testing.T) {
-	stepOrder := []string{}
+func TestExecutor_Execute_TenantRouting(t *testing.T) {
+	tenants := map[string]string{}
 	db := &mockDBManager{
 		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
-			stepOrder = append(stepOrder, sql)
-			if strings.Contains(sql, "fail") {
-				return nil, errors.New("query failed")
-			}
+			tenants[database] = opts.Tenant
 			return &step.QueryResult{Rows: []map[string]any{}}, nil
 		},
 	}
-	logger := &testLogger{}
-	exec := NewExecutor(db, &mockHTTPClient{}, nil, logger)
-
-	wf := &CompiledWorkflow{
-		Config: &WorkflowConfig{Name: "test"},
-		Steps: []*CompiledStep{
-			{
-				Config:  &StepConfig{Name: "step1", Type: "query", Database: "db"},
-				SQLTmpl: template.Must(template.New("sql").Parse("step1")),
-			},
-			{
-				Config:  &StepConfig{Name: "step2_fail", Type: "query", Database: "db", OnError: "continue"},
-				SQLTmpl: template.Must(template.New("sql").Parse("fail")),
-			},
-			{
-				Config:  &StepConfig{Name: "step3", Type: "query", Database: "db"},
-				SQLTmpl: template.Must(template.New("sql").Parse("step3")),
-			},
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	if err := exec.SetTenantKeys(map[string]string{"tenants": `{{header .trigger.headers "X-Tenant-Id"}}`}); err != nil {
+		t.Fatalf("SetTenantKeys failed: %v", err)
+	}
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "orders",
+		Steps: []StepConfig{
+			{Name: "orders", Type: "query", Database: "tenants", SQL: "SELECT * FROM orders"},
+			{Name: "plans", Type: "query", Database: "main", SQL: "SELECT * FROM plans"},
 		},
+	})
+
+	tests := []struct {
+		name       string
+		headers    http.Header
+		wantTenant string
+		wantErr    string
+	}{
+		{"header present", http.Header{"X-Tenant-Id": {"acme"}}, "acme", ""},
+		{"header missing", http.Header{}, "", "database tenants: request has no tenant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clear(tenants)
+			result := exec.Execute(context.Background(), wf, &TriggerData{Type: "http", Headers: tt.headers}, "req-1", nil, nil)
+			if tt.wantErr != "" {
+				if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", result.Error, tt.wantErr)
+				}
+				if _, ok := tenants["tenants"]; ok {
+					t.Error("query ran without a tenant")
+				}
+				return
+			}
+			if !result.Success {
+				t.Fatalf("Success = false: %v", result.Error)
+			}
+			if tenants["tenants"] != tt.wantTenant || tenants["main"] != "" {
+				t.Errorf("tenants = %v, want tenants=%s and no tenant for main", tenants, tt.wantTenant)
+			}
+		})
 	}
 
-	trigger := &TriggerData{Type: "http"}
-	result := exec.Execute(context.Background(), wf, trigger, "req-1", nil, nil)
+	if err := exec.SetTenantKeys(map[string]string{"tenants": "{{"}); err == nil {
+		t.Error("expected error for invalid tenant key template")
+	}
+}
 
-	if !result.Success {
-		t.Error("Success = false, want true (on_error: continue)")
+func TestBulkInsertBatchSize(t *testing.T) {
+	tests := []struct {
+		configured, columns, want int
+	}{
+		{0, 3, DefaultBulkInsertBatchSize},
+		{100, 3, 100},
+		{5000, 1, 1000},
+		{1000, 10, 200},
+		{10, 5000, 1},
 	}
-	if len(stepOrder) != 3 {
-		t.Errorf("step order = %v, want [step1, fail, step3]", stepOrder)
+	for _, tt := range tests {
+		if got := bulkInsertBatchSize(tt.configured, tt.columns); got != tt.want {
+			t.Errorf("bulkInsertBatchSize(%d, %d) = %d, want %d", tt.configured, tt.columns, got, tt.want)
+		}
 	}
 }
 
@@ -299,6 +1414,191 @@ func TestExecutor_Execute_ResponseStep(t *testing.T) {
 	}
 }
 
+func TestExecutor_Execute_HTMLResponseStep(t *testing.T) {
+	logger := &testLogger{}
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, logger)
+
+	cfg := &WorkflowConfig{
+		Name:     "test",
+		Triggers: []TriggerConfig{{Type: "http", Path: "/status", Method: "GET"}},
+		Steps: []StepConfig{{
+			Name:         "page",
+			Type:         "response",
+			TemplateType: "html",
+			Template:     `<h1>{{.trigger.params.name}}</h1><a href="/users?q={{.trigger.params.name}}">{{upper "link"}}</a>`,
+		}},
+	}
+	wf, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	trigger := &TriggerData{Type: "http", Params: map[string]any{"name": `<script>alert("x")</script>`}}
+	result := exec.Execute(context.Background(), wf, trigger, "req-1", recorder, nil)
+	if !result.Success {
+		t.Fatalf("Success = false: %v", result.Error)
+	}
+
+	resp := recorder.Result()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/html", ct)
+	}
+	want := `<h1>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</h1>` +
+		`<a href="/users?q=%3cscript%3ealert%28%22x%22%29%3c%2fscript%3e">LINK</a>`
+	if body := recorder.Body.String(); body != want {
+		t.Errorf("body = %s\nwant   %s", body, want)
+	}
+}
+
+func TestExecutor_Execute_ResponseContentTypeHeader(t *testing.T) {
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+	wf, err := Compile(&WorkflowConfig{
+		Name:     "test",
+		Triggers: []TriggerConfig{{Type: "http", Path: "/missing", Method: "GET"}},
+		Steps: []StepConfig{{
+			Type:       "response",
+			StatusCode: 404,
+			Headers:    map[string]string{"content-type": ProblemContentType},
+			Template:   ProblemTemplate(404, "not found", CodeNotFound),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	result := exec.Execute(context.Background(), wf, &TriggerData{Type: "http"}, "req-1", recorder, nil)
+	if !result.Success {
+		t.Fatalf("Success = false: %v", result.Error)
+	}
+	if ct := recorder.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("Content-Type = %q, want %s", ct, ProblemContentType)
+	}
+	if body := recorder.Body.String(); !strings.Contains(body, `"instance": "urn:request:req-1"`) {
+		t.Errorf("body = %s, want the request ID as instance", body)
+	}
+}
+
+func TestExecutor_Execute_ResponseSSE(t *testing.T) {
+	rows := []map[string]any{{"id": 1}, {"id": 2}, {"id": 3}, {"id": 4}, {"id": 5}}
+	progress := StepConfig{Name: "started", Type: "response_sse", Event: "progress", Template: `{"stage": "fetch"}`}
+	fetch := StepConfig{Name: "fetch", Type: "query", Database: "db", SQL: "SELECT id FROM t"}
+	batches := StepConfig{Name: "rows", Type: "response_sse", Event: "rows", Source: "steps.fetch.data", BatchSize: 2}
+
+	tests := []struct {
+		name     string
+		steps    []StepConfig
+		queryErr error
+		want     string
+	}{
+		{
+			name:  "final response",
+			steps: []StepConfig{progress, fetch, batches, {Type: "response", Template: `{"count": {{.steps.fetch.count}}}`}},
+			want: "event: progress\ndata: {\"stage\": \"fetch\"}\n\n" +
+				"event: rows\ndata: [{\"id\":1},{\"id\":2}]\n\n" +
+				"event: rows\ndata: [{\"id\":3},{\"id\":4}]\n\n" +
+				"event: rows\ndata: [{\"id\":5}]\n\n" +
+				"event: complete\ndata: {\"count\": 5}\n\n",
+		},
+		{
+			name:  "no response step",
+			steps: []StepConfig{progress},
+			want: "event: progress\ndata: {\"stage\": \"fetch\"}\n\n" +
+				"event: complete\ndata: {\"success\":true,\"request_id\":\"req-1\"}\n\n",
+		},
+		{
+			name:     "failure after the stream opened",
+			steps:    []StepConfig{progress, fetch, batches},
+			queryErr: errors.New("connection reset"),
+			want: "event: progress\ndata: {\"stage\": \"fetch\"}\n\n" +
+				"event: error\ndata: {\"success\":false,\"error\":\"workflow execution failed\",\"code\":\"SQLPROXY_STEP_FAILED\",\"request_id\":\"req-1\"}\n\n",
+		},
+		{
+			name:  "multi-line data",
+			steps: []StepConfig{{Name: "note", Type: "response_sse", Template: "line one\nline two\n"}},
+			want: "event: message\ndata: line one\ndata: line two\n\n" +
+				"event: complete\ndata: {\"success\":true,\"request_id\":\"req-1\"}\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbm := &mockDBManager{
+				queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+					return &step.QueryResult{Rows: rows}, tt.queryErr
+				},
+			}
+			exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+			wf, err := Compile(&WorkflowConfig{
+				Name:     "export",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/export", Method: "GET"}},
+				Steps:    tt.steps,
+			})
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			if !wf.Streams {
+				t.Fatal("Streams = false, want true")
+			}
+
+			recorder := httptest.NewRecorder()
+			result := exec.Execute(context.Background(), wf, &TriggerData{Type: "http"}, "req-1", recorder, nil)
+			if !result.ResponseSent {
+				t.Error("ResponseSent = false, want true")
+			}
+			if (result.Error != nil) != (tt.queryErr != nil) {
+				t.Errorf("Error = %v", result.Error)
+			}
+			if ct := recorder.Header().Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Content-Type = %q, want text/event-stream", ct)
+			}
+			if !recorder.Flushed {
+				t.Error("events were not flushed")
+			}
+			if body := recorder.Body.String(); body != tt.want {
+				t.Errorf("body = %q\nwant   %q", body, tt.want)
+			}
+		})
+	}
+
+	t.Run("after a plain response", func(t *testing.T) {
+		exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+		wf, err := Compile(&WorkflowConfig{
+			Name:     "export",
+			Triggers: []TriggerConfig{{Type: "http", Path: "/export", Method: "GET"}},
+			Steps:    []StepConfig{{Type: "response", Template: `{}`}, {Name: "late", Type: "response_sse", Template: `{}`}},
+		})
+		if err != nil {
+			t.Fatalf("compile: %v", err)
+		}
+		recorder := httptest.NewRecorder()
+		result := exec.Execute(context.Background(), wf, &TriggerData{Type: "http"}, "req-1", recorder, nil)
+		if result.Success || !strings.Contains(fmt.Sprint(result.Error), "response already sent") {
+			t.Errorf("Error = %v, want response already sent", result.Error)
+		}
+		if body := recorder.Body.String(); body != "{}" {
+			t.Errorf("body = %q, want the plain response only", body)
+		}
+	})
+
+	t.Run("without an HTTP request", func(t *testing.T) {
+		exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+		wf, err := Compile(&WorkflowConfig{
+			Name:     "export",
+			Triggers: []TriggerConfig{{Type: "cron", Schedule: "* * * * *"}},
+			Steps:    []StepConfig{{Name: "note", Type: "response_sse", Template: `{}`}},
+		})
+		if err != nil {
+			t.Fatalf("compile: %v", err)
+		}
+		result := exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-1", nil, nil)
+		if result.Success || result.ResponseSent {
+			t.Errorf("Success = %v, ResponseSent = %v, want a failed run", result.Success, result.ResponseSent)
+		}
+	})
+}
+
 func TestExecutor_Execute_HTTPCallStep(t *testing.T) {
 	client := &mockHTTPClient{
 		doFunc: func(req *http.Request) (*http.Response, error) {
@@ -393,6 +1693,95 @@ func TestExecutor_Execute_WorkflowTimeout(t *testing.T) {
 	}
 }
 
+func TestExecutor_Execute_StepTimeout(t *testing.T) {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "slow", Type: "query", Database: "db", TimeoutSec: 1},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT pg_sleep(10)")),
+			},
+		},
+	}
+
+	result := exec.Execute(context.Background(), wf, &TriggerData{Type: "http"}, "req-1", nil, nil)
+
+	if result.Success {
+		t.Fatal("Success = true, want false")
+	}
+	sr := result.Steps["slow"]
+	if sr == nil || !sr.TimedOut {
+		t.Fatalf("step result = %+v, want TimedOut", sr)
+	}
+	if !strings.Contains(sr.Error.Error(), "step timed out after 1s") {
+		t.Errorf("Error = %v, want step timeout message", sr.Error)
+	}
+	if !errors.Is(sr.Error, context.DeadlineExceeded) {
+		t.Errorf("Error = %v, want it to wrap context.DeadlineExceeded", sr.Error)
+	}
+}
+
+func TestExecutor_Execute_BlockStep_NestedTimeout(t *testing.T) {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			if strings.Contains(sql, "slow") {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return &step.QueryResult{Rows: []map[string]any{{"id": 1}}}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	overExpr, _ := compileExpression("steps.fetch.data")
+	nested := StepConfig{Name: "slow_step", Type: "query", Database: "db", TimeoutSec: 1}
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "fetch", Type: "query", Database: "db"},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT id FROM items")),
+			},
+			{
+				Config: &StepConfig{Name: "process", Steps: []StepConfig{nested}},
+				Iterate: &CompiledIterate{
+					Config:   &IterateConfig{Over: "steps.fetch.data", As: "item", OnError: "abort"},
+					OverExpr: overExpr,
+				},
+				BlockSteps: []*CompiledStep{
+					{Config: &nested, SQLTmpl: template.Must(template.New("sql").Parse("slow"))},
+				},
+			},
+		},
+	}
+
+	result := exec.Execute(context.Background(), wf, &TriggerData{Type: "http"}, "req-1", nil, nil)
+
+	blockResult := result.Steps["process"]
+	if blockResult == nil || blockResult.Success {
+		t.Fatalf("block result = %+v, want failure", blockResult)
+	}
+	if len(blockResult.Iterations) != 1 {
+		t.Fatalf("len(Iterations) = %d, want 1", len(blockResult.Iterations))
+	}
+	if sr := blockResult.Iterations[0].Steps["slow_step"]; sr == nil || !sr.TimedOut {
+		t.Errorf("nested step result = %+v, want TimedOut", sr)
+	}
+	// The block itself had no deadline, so only the nested step is flagged
+	if blockResult.TimedOut {
+		t.Error("block TimedOut = true, want false")
+	}
+}
+
 func TestExecutor_Execute_HTTPTriggerWithoutResponse(t *testing.T) {
 	logger := &testLogger{}
 	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, logger)
@@ -710,10 +2099,11 @@ func TestExecutor_Execute_LoggingCalls(t *testing.T) {
 // mockStepCache implements StepCache for testing.
 type mockStepCache struct {
 	data map[string][]map[string]any
+	tags map[string][]string // tag -> full keys
 }
 
 func newMockStepCache() *mockStepCache {
-	return &mockStepCache{data: make(map[string][]map[string]any)}
+	return &mockStepCache{data: make(map[string][]map[string]any), tags: make(map[string][]string)}
 }
 
 func (m *mockStepCache) Get(workflow, key string) ([]map[string]any, bool) {
@@ -728,6 +2118,25 @@ func (m *mockStepCache) Set(workflow, key string, data []map[string]any, ttl tim
 	return true
 }
 
+func (m *mockStepCache) SetWithTags(workflow, key string, data []map[string]any, ttl time.Duration, tags []string) bool {
+	for _, tag := range tags {
+		m.tags[tag] = append(m.tags[tag], workflow+":"+key)
+	}
+	return m.Set(workflow, key, data, ttl)
+}
+
+func (m *mockStepCache) InvalidateTag(tag string) int {
+	removed := 0
+	for _, fullKey := range m.tags[tag] {
+		if _, ok := m.data[fullKey]; ok {
+			delete(m.data, fullKey)
+			removed++
+		}
+	}
+	delete(m.tags, tag)
+	return removed
+}
+
 func TestExecutor_StepCache_Hit(t *testing.T) {
 	// Create a mock cache with pre-populated data
 	cache := newMockStepCache()
@@ -1406,3 +2815,153 @@ func TestParseInt64(t *testing.T) {
 		})
 	}
 }
+
+func TestExecutor_StepCache_Tags(t *testing.T) {
+	cache := newMockStepCache()
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{Rows: []map[string]any{{"id": 1}}}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, cache, &testLogger{})
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test_workflow"},
+		Steps: []*CompiledStep{
+			{
+				Config:        &StepConfig{Name: "fetch_user", Type: "query", Database: "testdb"},
+				SQLTmpl:       template.Must(template.New("sql").Parse("SELECT 1")),
+				CacheKeyTmpl:  template.Must(template.New("cache_key").Parse("user:{{.trigger.params.id}}")),
+				CacheTagTmpls: []*template.Template{template.Must(template.New("cache_tag").Parse("user:{{.trigger.params.id}}"))},
+			},
+		},
+	}
+
+	trigger := &TriggerData{Type: "http", Params: map[string]any{"id": 7}}
+	exec.Execute(context.Background(), wf, trigger, "req-1", nil, nil)
+
+	if keys := cache.tags["user:7"]; len(keys) != 1 || keys[0] != "test_workflow:user:7" {
+		t.Errorf("expected entry tagged user:7, got %v", cache.tags)
+	}
+}
+
+func TestExecutor_CacheInvalidateStep(t *testing.T) {
+	cache := newMockStepCache()
+	cache.SetWithTags("get_user", "user:7", []map[string]any{{"id": 7}}, 0, []string{"user:7"})
+	cache.SetWithTags("list_users", "all", []map[string]any{{"id": 7}}, 0, []string{"user:7"})
+	cache.SetWithTags("get_user", "user:8", []map[string]any{{"id": 8}}, 0, []string{"user:8"})
+
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, cache, &testLogger{})
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "update_user"},
+		Steps: []*CompiledStep{
+			{
+				Config:   &StepConfig{Name: "bust", Type: "cache_invalidate"},
+				TagTmpls: []*template.Template{template.Must(template.New("cache_tag").Parse("user:{{.trigger.params.id}}"))},
+			},
+		},
+	}
+
+	trigger := &TriggerData{Type: "cron", Params: map[string]any{"id": 7}}
+	result := exec.Execute(context.Background(), wf, trigger, "req-1", nil, nil)
+
+	stepResult := result.Steps["bust"]
+	if stepResult == nil || !stepResult.Success {
+		t.Fatalf("expected successful bust step, got %+v", stepResult)
+	}
+	if stepResult.Count != 2 {
+		t.Errorf("expected 2 invalidated entries, got %d", stepResult.Count)
+	}
+	if _, hit := cache.Get("get_user", "user:8"); !hit {
+		t.Error("expected entry with other tag to remain")
+	}
+}
+
+func TestExecutor_Execute_ErrorTracking(t *testing.T) {
+	var mu sync.Mutex
+	var reports []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&report)
+		mu.Lock()
+		reports = append(reports, report)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	if err := errtrack.Init(errtrack.Config{Webhook: srv.URL}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer errtrack.Close()
+
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return nil, errors.New("deadlock")
+		},
+	}
+	exec := NewExecutor(db, http.DefaultClient, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "orders",
+		Steps: []StepConfig{
+			{Name: "load", Type: "query", Database: "db", SQL: "SELECT 1", OnError: "continue"},
+			{Name: "respond", Type: "response", Template: `{"id": {{require .trigger.params "id"}}}`},
+		},
+	})
+
+	exec.Execute(context.Background(), wf, &TriggerData{Type: "http", Params: map[string]any{}}, "req-7", httptest.NewRecorder(), nil)
+	errtrack.Close()
+
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports, want 2: %v", len(reports), reports)
+	}
+	for i, want := range []map[string]any{
+		{"kind": "step_failure", "level": "warning", "step": "load"},
+		{"kind": "template_error", "level": "error", "step": "respond"},
+	} {
+		for key, value := range want {
+			if reports[i][key] != value {
+				t.Errorf("report %d: %s = %v, want %v", i, key, reports[i][key], value)
+			}
+		}
+		if reports[i]["workflow"] != "orders" || reports[i]["request_id"] != "req-7" {
+			t.Errorf("report %d lacks workflow and request context: %v", i, reports[i])
+		}
+	}
+}
+
+func TestExecutor_Execute_ReportsPanics(t *testing.T) {
+	var reports atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reports.Add(1)
+	}))
+	defer srv.Close()
+	if err := errtrack.Init(errtrack.Config{Webhook: srv.URL}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer errtrack.Close()
+
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			panic("driver bug")
+		},
+	}
+	exec := NewExecutor(db, http.DefaultClient, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:  "orders",
+		Steps: []StepConfig{{Name: "load", Type: "query", Database: "db", SQL: "SELECT 1"}},
+	})
+
+	var recovered any
+	func() {
+		defer func() { recovered = recover() }()
+		exec.Execute(context.Background(), wf, &TriggerData{Type: "cron"}, "req-8", nil, nil)
+	}()
+	p, ok := recovered.(*errtrack.ReportedPanic)
+	if !ok || p.Value != "driver bug" {
+		t.Fatalf("recovered %#v, want the panic passed on as reported", recovered)
+	}
+	errtrack.ReportPanic(recovered, errtrack.Event{})
+	errtrack.Close()
+	if n := reports.Load(); n != 1 {
+		t.Errorf("got %d reports, want the panic reported once", n)
+	}
+}
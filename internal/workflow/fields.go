@@ -0,0 +1,55 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// FieldsParam is the query parameter clients select fields with
+const FieldsParam = "fields"
+
+type fieldSelectionKey struct{}
+
+// fieldSelection is the columns a request selected and the steps they trim
+type fieldSelection struct {
+	columns []string
+	steps   []string
+}
+
+// parseFields checks a comma-separated field list against the allowed columns.
+// Names match case-insensitively and are returned as configured, without
+// duplicates. It returns nil for an empty list.
+func parseFields(cfg *FieldsConfig, raw string) ([]string, error) {
+	var fields []string
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		i := slices.IndexFunc(cfg.Allowed, func(a string) bool { return strings.EqualFold(a, f) })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown field '%s' (allowed: %s)", f, strings.Join(cfg.Allowed, ", "))
+		}
+		if !slices.Contains(fields, cfg.Allowed[i]) {
+			fields = append(fields, cfg.Allowed[i])
+		}
+	}
+	return fields, nil
+}
+
+// withFieldSelection returns a context carrying the request's field selection
+func withFieldSelection(ctx context.Context, sel *fieldSelection) context.Context {
+	return context.WithValue(ctx, fieldSelectionKey{}, sel)
+}
+
+// selectedFields returns the columns the request kept in a step's rows, or nil
+// when the step's rows are returned whole
+func selectedFields(ctx context.Context, stepName string) []string {
+	sel, _ := ctx.Value(fieldSelectionKey{}).(*fieldSelection)
+	if sel == nil || !slices.Contains(sel.steps, stepName) {
+		return nil
+	}
+	return sel.columns
+}
@@ -0,0 +1,262 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sql-proxy/internal/errtrack"
+)
+
+// FileWatch defaults for triggers that leave them unset
+const (
+	DefaultFileWatchInterval     = 10 * time.Second
+	DefaultFileWatchMaxFileBytes = 10 << 20
+)
+
+// FileWatcher runs a workflow once for every file dropped into a directory.
+// The directory is listed on an interval rather than watched through the OS,
+// so network shares (SMB, NFS) work too. A file is picked up once its size and
+// modification time are unchanged between two polls, which keeps files still
+// being copied in out of the workflow. Afterwards it moves to done_dir or
+// error_dir, so each file runs once.
+type FileWatcher struct {
+	executor  *Executor
+	workflow  *CompiledWorkflow
+	trigger   *CompiledTrigger
+	variables map[string]string
+
+	seen  map[string]fileState // Files listed by the previous poll
+	stuck map[string]fileState // Files that could not be moved; skipped until they change
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// NewFileWatcher creates a watcher for a filewatch trigger.
+func NewFileWatcher(executor *Executor, wf *CompiledWorkflow, trigger *CompiledTrigger, variables map[string]string) *FileWatcher {
+	return &FileWatcher{
+		executor:  executor,
+		workflow:  wf,
+		trigger:   trigger,
+		variables: variables,
+		seen:      make(map[string]fileState),
+		stuck:     make(map[string]fileState),
+	}
+}
+
+// Run polls the directory until ctx is cancelled, starting right away.
+func (w *FileWatcher) Run(ctx context.Context) {
+	interval := DefaultFileWatchInterval
+	if w.trigger.Config.IntervalSec > 0 {
+		interval = time.Duration(w.trigger.Config.IntervalSec) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll runs the workflow for every file that has settled since the last poll
+func (w *FileWatcher) poll(ctx context.Context) {
+	defer func() {
+		if p := recover(); p != nil {
+			errtrack.ReportPanic(p, errtrack.Event{Workflow: w.workflow.Config.Name, Tags: map[string]string{"trigger": "filewatch"}})
+			w.executor.Logger().Error("filewatch_panic", map[string]any{
+				"workflow": w.workflow.Config.Name,
+				"panic":    fmt.Sprintf("%v", p),
+			})
+		}
+	}()
+
+	cfg := w.trigger.Config
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		w.logError("filewatch_list_failed", "", err)
+		return
+	}
+
+	current := make(map[string]fileState, len(entries))
+	var ready []string
+	for _, entry := range entries {
+		name := entry.Name()
+		// Dot files are the usual temporary names of uploads in progress
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || !w.matches(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		current[name] = state
+		if stuck, ok := w.stuck[name]; ok && stuck == state {
+			continue
+		}
+		delete(w.stuck, name)
+		if prev, ok := w.seen[name]; ok && prev == state {
+			ready = append(ready, name)
+		}
+	}
+	w.seen = current
+
+	sort.Strings(ready)
+	for _, name := range ready {
+		if ctx.Err() != nil {
+			return
+		}
+		w.process(ctx, name, current[name])
+		delete(w.seen, name)
+	}
+}
+
+func (w *FileWatcher) matches(name string) bool {
+	pattern := w.trigger.Config.Pattern
+	if pattern == "" {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, name)
+	return ok
+}
+
+// process runs the workflow for one file and moves it according to the outcome.
+// A file whose execution was cancelled by shutdown stays for the next start.
+func (w *FileWatcher) process(ctx context.Context, name string, state fileState) {
+	cfg := w.trigger.Config
+	path := filepath.Join(cfg.Dir, name)
+	requestID := "filewatch-" + generateRequestID()
+
+	maxBytes := cfg.MaxFileBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultFileWatchMaxFileBytes
+	}
+	if state.size > maxBytes {
+		w.logError("filewatch_file_too_large", requestID, fmt.Errorf("%s is %d bytes (max_file_bytes %d)", name, state.size, maxBytes))
+		w.move(name, state, cfg.ErrorDir, requestID)
+		return
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Another consumer took it
+		return
+	}
+	if err != nil {
+		w.logError("filewatch_read_failed", requestID, err)
+		w.stuck[name] = state
+		return
+	}
+
+	triggerData := &TriggerData{
+		Type:     TriggerTypeFileWatch,
+		Lang:     DefaultLanguage(),
+		Priority: w.trigger.Config.Priority,
+		Params: map[string]any{
+			"filename": name,
+			"path":     path,
+			"size":     state.size,
+			"mod_time": state.modTime,
+			"content":  string(content),
+		},
+		ScheduleTime: time.Now(),
+	}
+
+	execCtx := ctx
+	if w.workflow.Config.TimeoutSec > 0 {
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithTimeout(ctx, time.Duration(w.workflow.Config.TimeoutSec)*time.Second)
+		defer cancel()
+	}
+	exec := w.executor.Execute(execCtx, w.workflow, triggerData, requestID, nil, w.variables)
+	if exec.Error != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		w.logError("filewatch_file_failed", requestID, exec.Error)
+		w.move(name, state, cfg.ErrorDir, requestID)
+		return
+	}
+
+	w.executor.Logger().Info("filewatch_file_completed", map[string]any{
+		"workflow":    w.workflow.Config.Name,
+		"request_id":  requestID,
+		"file":        name,
+		"size":        state.size,
+		"duration_ms": exec.DurationMs,
+	})
+	w.move(name, state, cfg.DoneDir, requestID)
+}
+
+// move takes a file out of the watched directory. A file that cannot be moved
+// is not run again until it changes.
+func (w *FileWatcher) move(name string, state fileState, dir, requestID string) {
+	if err := moveFile(filepath.Join(w.trigger.Config.Dir, name), dir); err != nil {
+		w.logError("filewatch_move_failed", requestID, err)
+		w.stuck[name] = state
+	}
+}
+
+// moveFile moves path into dir, adding a timestamp to the name when dir
+// already has a file by that name. Moves across filesystems are copied.
+func moveFile(path, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+	target := filepath.Join(dir, name)
+	if _, err := os.Stat(target); err == nil {
+		target = filepath.Join(dir, name+"."+time.Now().Format("20060102T150405.000000000"))
+	}
+
+	if err := os.Rename(path, target); err == nil {
+		return nil
+	}
+	if err := copyFile(path, target); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+func (w *FileWatcher) logError(event, requestID string, err error) {
+	fields := map[string]any{
+		"workflow": w.workflow.Config.Name,
+		"dir":      w.trigger.Config.Dir,
+		"error":    err.Error(),
+	}
+	if requestID != "" {
+		fields["request_id"] = requestID
+	}
+	w.executor.Logger().Error(event, fields)
+}
@@ -0,0 +1,112 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+// importLog records the import step's executions and fails files containing "bad"
+type importLog struct {
+	mu    sync.Mutex
+	files []map[string]any
+}
+
+func (l *importLog) query(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.files = append(l.files, params)
+	if strings.Contains(params["content"].(string), "bad") {
+		return nil, errors.New("malformed file")
+	}
+	return &step.QueryResult{}, nil
+}
+
+func newTestFileWatcher(t *testing.T, log *importLog, trigger TriggerConfig) *FileWatcher {
+	t.Helper()
+	exec := NewExecutor(&mockDBManager{queryFunc: log.query}, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:     "import",
+		Triggers: []TriggerConfig{trigger},
+		Steps: []StepConfig{
+			{Name: "load", Type: "query", Database: "db", SQL: "INSERT INTO imports (name, body) VALUES (@filename, @content)"},
+		},
+	})
+	return NewFileWatcher(exec, wf, wf.Triggers[0], nil)
+}
+
+func TestFileWatcher_Poll(t *testing.T) {
+	root := t.TempDir()
+	in, done, failed := filepath.Join(root, "in"), filepath.Join(root, "done"), filepath.Join(root, "error")
+	if err := os.Mkdir(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(in, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log := &importLog{}
+	w := newTestFileWatcher(t, log, TriggerConfig{
+		Type: "filewatch", Dir: in, Pattern: "*.csv", DoneDir: done, ErrorDir: failed, MaxFileBytes: 100,
+	})
+
+	write("a.csv", "id,name\n1,alice")
+	write("b.csv", "bad")
+	write("big.csv", strings.Repeat("x", 101))
+	write("notes.txt", "ignored")
+	write(".c.csv", "upload in progress")
+
+	// Files are picked up once they are unchanged between two polls
+	w.poll(context.Background())
+	if len(log.files) != 0 {
+		t.Fatalf("files ran before settling: %v", log.files)
+	}
+	w.poll(context.Background())
+	if len(log.files) != 2 || log.files[0]["filename"] != "a.csv" || log.files[0]["content"] != "id,name\n1,alice" {
+		t.Fatalf("executions = %v", log.files)
+	}
+
+	for _, want := range []string{filepath.Join(done, "a.csv"), filepath.Join(failed, "b.csv"), filepath.Join(failed, "big.csv"), filepath.Join(in, "notes.txt"), filepath.Join(in, ".c.csv")} {
+		if _, err := os.Stat(want); err != nil {
+			t.Errorf("expected %s: %v", want, err)
+		}
+	}
+
+	// A file with a name already in done_dir is kept under a new name
+	write("a.csv", "id,name\n2,bob")
+	w.poll(context.Background())
+	w.poll(context.Background())
+	entries, _ := os.ReadDir(done)
+	if len(entries) != 2 {
+		t.Errorf("done_dir has %d files, want 2", len(entries))
+	}
+	if len(log.files) != 3 {
+		t.Errorf("executions = %d, want 3", len(log.files))
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "report.csv")
+	if err := os.WriteFile(src, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, "archive", "2026")
+	if err := moveFile(src, dir); err != nil {
+		t.Fatalf("moveFile: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "report.csv")); err != nil || string(data) != "data" {
+		t.Errorf("moved file = %q, %v", data, err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("source still exists: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// GRPCHandler serves a workflow's grpc trigger. A call runs the workflow the
+// way an HTTP request does: the request's fields become a JSON body and the
+// call's metadata the request headers, so parameter validation, rate limits,
+// and workflows that authorize on headers behave as for HTTP triggers. The
+// server maps the response back to a gRPC response or status.
+type GRPCHandler struct {
+	http *HTTPHandler
+}
+
+// NewGRPCHandler creates a handler for a workflow grpc trigger.
+func NewGRPCHandler(executor *Executor, wf *CompiledWorkflow, trigger *CompiledTrigger, rateLimiter RateLimiter, trustProxyHeaders bool, version, buildTime string, variables map[string]string) *GRPCHandler {
+	return &GRPCHandler{
+		http: NewHTTPHandler(executor, wf, trigger, rateLimiter, nil, trustProxyHeaders, version, buildTime, variables),
+	}
+}
+
+// Call runs the workflow for one call and returns the status code and body of
+// its response. r is the gRPC request, whose headers and client address the
+// execution sees; params are the decoded request fields.
+func (h *GRPCHandler) Call(r *http.Request, params map[string]any) (int, []byte) {
+	capture := &responseCapture{ResponseWriter: &discardResponseWriter{header: make(http.Header)}}
+	requestID := getOrGenerateRequestID(r)
+
+	body, err := json.Marshal(params)
+	if err != nil {
+		h.http.writeError(capture, http.StatusBadRequest, CodeParamInvalid, err.Error(), requestID)
+		return capture.statusCode, capture.body.Bytes()
+	}
+	req := r.Clone(r.Context())
+	req.Method = http.MethodPost
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	h.http.serve(capture, req, requestID)
+	if capture.statusCode == 0 {
+		return http.StatusOK, capture.body.Bytes()
+	}
+	return capture.statusCode, capture.body.Bytes()
+}
@@ -0,0 +1,58 @@
+package workflow
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGRPCHandler_Call(t *testing.T) {
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "get_machine",
+		Triggers: []TriggerConfig{{
+			Type: "grpc",
+			RPC:  "GetMachine",
+			Parameters: []ParamConfig{
+				{Name: "id", Type: "int", Required: true},
+				{Name: "tags", Type: "string[]"},
+			},
+		}},
+		Steps: []StepConfig{
+			{
+				Name:     "respond",
+				Type:     "response",
+				Template: `{"type": {{json .trigger.type}}, "id": {{.trigger.params.id}}, "tags": {{json .trigger.params.tags}}, "token": {{json .trigger.headers.Authorization}}}`,
+			},
+		},
+	})
+	h := NewGRPCHandler(exec, wf, wf.Triggers[0], nil, false, "", "", nil)
+
+	r := httptest.NewRequest(http.MethodPost, "/sqlproxy.v1.Workflows/GetMachine", nil)
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("Authorization", "Bearer abc")
+
+	status, body := h.Call(r, map[string]any{"id": int64(7), "tags": []any{"a", "b"}})
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, body %s", status, body)
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("body is not JSON: %s", body)
+	}
+	if resp["type"] != "grpc" || resp["id"] != float64(7) || resp["token"] != "Bearer abc" {
+		t.Errorf("response = %v", resp)
+	}
+	// Array parameters reach templates as JSON, as for HTTP triggers
+	if resp["tags"] != `["a","b"]` {
+		t.Errorf("tags = %v", resp["tags"])
+	}
+
+	// Parameter validation runs as for HTTP triggers
+	status, body = h.Call(r, nil)
+	if status != http.StatusBadRequest || !strings.Contains(string(body), "id") {
+		t.Errorf("missing parameter: status = %d, body %s", status, body)
+	}
+}
@@ -2,30 +2,45 @@ package workflow
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
+	"golang.org/x/sync/singleflight"
+
+	"sql-proxy/internal/concurrency"
+	"sql-proxy/internal/loadshed"
 	"sql-proxy/internal/metrics"
 	"sql-proxy/internal/tmpl"
 	"sql-proxy/internal/types"
 	"sql-proxy/internal/workflow/step"
 )
 
+// detachedExecutionTimeout caps executions detached from a client request (stale-while-revalidate
+// refreshes and coalesced cache misses) when the workflow has no timeout
+const detachedExecutionTimeout = 60 * time.Second
+
 // TriggerCache provides caching for workflow trigger responses.
 type TriggerCache interface {
-	// Get retrieves cached response. Returns body, status code, and hit status.
-	Get(workflow, key string) (body []byte, statusCode int, hit bool)
-	// Set stores response in the cache with the given TTL.
-	Set(workflow, key string, body []byte, statusCode int, ttl time.Duration) bool
+	// Get retrieves cached response. Returns body, status code, whether the entry is past
+	// its TTL (stale, still within its stale-while-revalidate window), and hit status.
+	Get(workflow, key string) (body []byte, statusCode int, stale bool, hit bool)
+	// Set stores response in the cache. The entry is fresh for ttl (0 = cache default)
+	// and may then be served stale for staleTTL while it is refreshed. Tags allow the entry
+	// to be invalidated together with other tagged entries.
+	Set(workflow, key string, body []byte, statusCode int, ttl, staleTTL time.Duration, tags []string) bool
 }
 
 // HTTPHandler handles HTTP requests for a workflow trigger.
@@ -39,16 +54,47 @@ type HTTPHandler struct {
 	version           string
 	buildTime         string
 	variables         map[string]string
+
+	// Cache keys with a stale-while-revalidate refresh in flight (key -> struct{})
+	revalidating sync.Map
+
+	// Coalesces concurrent cache misses for the same key into one execution
+	inflight singleflight.Group
+
+	// Guards the last sweep of expired idempotency keys
+	idempotencyMu    sync.Mutex
+	idempotencySwept time.Time
+}
+
+// sharedResponse is a captured workflow response replayed to every coalesced request
+type sharedResponse struct {
+	result    *ExecuteResult
+	capture   *responseCapture
+	requestID string // Of the request that ran the workflow
 }
 
 // RateLimitContext contains all data available for rate limit key evaluation.
 type RateLimitContext = tmpl.RateLimitData
 
+// RateLimitResult is the outcome of a trigger rate limit check.
+type RateLimitResult struct {
+	Allowed       bool
+	RetryAfterSec int           // Seconds to wait before retrying (only set when denied)
+	Delay         time.Duration // How long to hold an allowed request before executing it (leaky bucket pools)
+	Headers       http.Header   // Quota headers added to the response whether or not the request is allowed
+}
+
 // RateLimiter checks rate limits for workflow triggers.
 type RateLimiter interface {
 	// CheckTriggerLimits checks rate limits for a workflow trigger.
-	// Returns (allowed, retryAfterSec, error).
-	CheckTriggerLimits(limits []*CompiledRateLimit, ctx *RateLimitContext) (bool, int, error)
+	CheckTriggerLimits(limits []*CompiledRateLimit, ctx *RateLimitContext) (RateLimitResult, error)
+}
+
+// LoadShedder admits HTTP trigger requests by priority while the process is overloaded.
+type LoadShedder interface {
+	// Admit reports whether a request of the priority may run. An admitted request
+	// calls release when it finishes; a rejected one gets the seconds to wait before retrying.
+	Admit(priority string) (release func(), retryAfterSec int, ok bool)
 }
 
 // NewHTTPHandler creates a handler for a workflow HTTP trigger.
@@ -81,121 +127,468 @@ func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Check method
 	if r.Method != h.trigger.Config.Method {
-		h.writeError(w, http.StatusMethodNotAllowed, "method not allowed", requestID)
+		h.writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed", requestID)
 		return
 	}
 
+	if h.trigger.Config.Deprecated {
+		w.Header().Set("Deprecation", "true")
+		if h.trigger.Sunset != "" {
+			w.Header().Set("Sunset", h.trigger.Sunset)
+		}
+		metrics.RecordDeprecatedCall(h.workflow.Config.Name, r.Method, h.trigger.Config.Path)
+	}
+
+	if shedder := h.executor.loadShedder; shedder != nil {
+		release, retryAfterSec, ok := shedder.Admit(h.trigger.Config.Priority)
+		if !ok {
+			metrics.RecordLoadShed(h.workflow.Config.Name, cmp.Or(h.trigger.Config.Priority, loadshed.PriorityNormal))
+			h.writeOverloadedError(w, retryAfterSec, requestID)
+			return
+		}
+		defer release()
+	}
+
+	if h.trigger.Config.Rewrite != nil {
+		r = h.rewriteRequest(r)
+	}
+
+	if debugLog := h.trigger.Config.DebugLog; debugLog != nil {
+		if debugLog.Request {
+			h.logRequestPayload(r, requestID)
+		}
+		if debugLog.Response {
+			rec := &payloadRecorder{ResponseWriter: w}
+			defer h.logResponsePayload(rec, requestID)
+			w = rec
+		}
+	}
+
+	h.serve(w, r, requestID)
+}
+
+// serve handles a request whose method matched the trigger. WebSocket
+// triggers call it for each message.
+func (h *HTTPHandler) serve(w http.ResponseWriter, r *http.Request, requestID string) {
+	// Validate the raw body against the trigger's schema before extracting parameters
+	if h.trigger.BodySchema != nil {
+		violations, err := checkBody(h.trigger.BodySchema, r)
+		if err != nil {
+			h.writeInvalidRequest(w, CodeBodyInvalid, err.Error(), requestID)
+			return
+		}
+		if len(violations) > 0 {
+			h.writeValidationError(w, CodeBodyInvalid, "request body validation failed", violations, requestID)
+			return
+		}
+	}
+
 	// Parse parameters
 	params, err := h.parseParameters(r)
 	if err != nil {
-		h.writeError(w, http.StatusBadRequest, err.Error(), requestID)
+		code := CodeParamInvalid
+		if errors.Is(err, errParamMissing) {
+			code = CodeParamMissing
+		}
+		h.writeInvalidRequest(w, code, err.Error(), requestID)
+		return
+	}
+	if violations := checkParams(h.trigger.ParamRules, params); len(violations) > 0 {
+		h.writeValidationError(w, CodeParamInvalid, "parameter validation failed", violations, requestID)
 		return
 	}
+	if fieldsCfg := h.trigger.Config.Fields; fieldsCfg != nil {
+		fields, err := parseFields(fieldsCfg, r.URL.Query().Get(FieldsParam))
+		if err != nil {
+			h.writeInvalidRequest(w, CodeParamInvalid, err.Error(), requestID)
+			return
+		}
+		if fields != nil {
+			r = r.WithContext(withFieldSelection(r.Context(), &fieldSelection{columns: fields, steps: fieldsCfg.Steps}))
+		}
+	}
+
+	if h.trigger.Config.SortableColumns != nil || h.trigger.Config.FilterableColumns != nil {
+		q, err := parseListQuery(h.trigger.Config, r.URL.Query())
+		if err != nil {
+			h.writeInvalidRequest(w, CodeParamInvalid, err.Error(), requestID)
+			return
+		}
+		maps.Copy(params, q.params)
+	}
 
 	// Parse cookies once for reuse in rate limits, cache key, and trigger data
 	cookies := parseCookies(r)
 
-	// Check rate limits
+	// Run the request stages in the trigger's order; a stage that answers the request ends it
 	clientIP := resolveClientIP(r, h.trustProxyHeaders)
-	if h.rateLimiter != nil && len(h.trigger.RateLimits) > 0 {
-		rlCtx := &RateLimitContext{
-			ClientIP: clientIP,
-			Params:   params,
-			Headers:  flattenHeaders(r.Header),
-			Query:    flattenQuery(r.URL.Query()),
-			Cookies:  cookies,
-		}
-		allowed, retryAfterSec, err := h.rateLimiter.CheckTriggerLimits(h.trigger.RateLimits, rlCtx)
-		if err != nil {
-			h.writeError(w, http.StatusInternalServerError, "rate limit check failed", requestID)
-			return
+	var cached cachedRequest
+	for _, stage := range h.trigger.Config.RequestMiddlewares() {
+		var done bool
+		switch stage {
+		case MiddlewareRateLimit:
+			done = h.checkRateLimits(w, r, params, cookies, clientIP, requestID)
+		case MiddlewareCache:
+			cached, done = h.checkCache(w, r, params, cookies, clientIP, requestID)
 		}
-		if !allowed {
-			h.writeRateLimitError(w, retryAfterSec, requestID)
+		if done {
 			return
 		}
 	}
 
-	// Check trigger-level cache
-	var cacheKey string
-	cacheEnabled := h.cache != nil && h.trigger.CacheKey != nil
-	if cacheEnabled {
-		var err error
-		cacheKey, err = h.evaluateCacheKey(h.trigger.CacheKey, r, params, clientIP, cookies, requestID)
-		if err != nil {
-			// Log warning and continue without caching
-			if h.executor != nil && h.executor.Logger() != nil {
-				h.executor.Logger().Warn("trigger_cache_key_error", map[string]any{
+	// Build trigger data
+	triggerData := h.buildTriggerData(r, r.Header, params, cookies, clientIP)
+
+	if cached.enabled {
+		h.serveCoalesced(w, r, cached.key, cached.tags, triggerData, requestID)
+		return
+	}
+	if h.trigger.Config.Poll != nil {
+		h.servePoll(w, r, triggerData, requestID)
+		return
+	}
+	if idem := h.trigger.Config.Idempotency; idem != nil && (idem.Required || r.Header.Get(IdempotencyHeader) != "") {
+		h.serveIdempotent(w, r, triggerData, requestID)
+		return
+	}
+
+	// Execute workflow
+	result := h.executor.Execute(r.Context(), h.workflow, triggerData, requestID, w, h.variables)
+
+	// Populate metrics accumulator with execution details
+	if acc := metrics.GetAccumulator(r.Context()); acc != nil {
+		h.populateMetrics(acc, result)
+	}
+
+	// If workflow didn't send a response (no response step executed), send a default response
+	h.writeDefaultResponse(w, result, requestID)
+}
+
+// checkRateLimits applies the trigger's rate limits. It reports whether the
+// request was answered (rejected) or abandoned while delayed.
+func (h *HTTPHandler) checkRateLimits(w http.ResponseWriter, r *http.Request, params map[string]any, cookies map[string]string, clientIP, requestID string) bool {
+	if h.rateLimiter == nil || len(h.trigger.RateLimits) == 0 {
+		return false
+	}
+	rlCtx := &RateLimitContext{
+		ClientIP: clientIP,
+		Params:   params,
+		Headers:  flattenHeaders(r.Header),
+		Query:    flattenQuery(r.URL.Query()),
+		Cookies:  cookies,
+	}
+	rl, err := h.rateLimiter.CheckTriggerLimits(h.trigger.RateLimits, rlCtx)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, CodeInternal, "rate limit check failed", requestID)
+		return true
+	}
+	for name, values := range rl.Headers {
+		w.Header()[name] = values
+	}
+	if !rl.Allowed {
+		h.writeRateLimitError(w, rl.RetryAfterSec, requestID)
+		return true
+	}
+	if rl.Delay > 0 {
+		// Leaky bucket pools space requests out instead of letting a burst through
+		timer := time.NewTimer(rl.Delay)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return true
+		}
+	}
+	return false
+}
+
+// cachedRequest is what the cache stage hands to execution after a miss
+type cachedRequest struct {
+	key     string
+	tags    []string
+	enabled bool
+}
+
+// checkCache serves the request from the trigger cache on a hit, reporting that it
+// was answered. On a miss it returns the key and tags the response is stored under.
+func (h *HTTPHandler) checkCache(w http.ResponseWriter, r *http.Request, params map[string]any, cookies map[string]string, clientIP, requestID string) (cachedRequest, bool) {
+	if h.cache == nil || h.trigger.CacheKey == nil {
+		return cachedRequest{}, false
+	}
+	cacheKey, err := h.evaluateCacheKey(h.trigger.CacheKey, r, params, clientIP, cookies, requestID)
+	if err != nil {
+		// Log warning and continue without caching
+		if h.executor != nil && h.executor.Logger() != nil {
+			h.executor.Logger().Warn("trigger_cache_key_error", map[string]any{
+				"workflow":   h.workflow.Config.Name,
+				"error":      err.Error(),
+				"request_id": requestID,
+			})
+		}
+		return cachedRequest{}, false
+	}
+	// Check cache for hit
+	if body, statusCode, stale, hit := h.cache.Get(h.workflow.Config.Name, cacheKey); hit {
+		if stale {
+			// Serve the stale body now and refresh the entry in the background
+			w.Header().Set("X-Cache", "STALE")
+			h.revalidate(r, cacheKey, params, cookies, clientIP)
+		} else {
+			w.Header().Set("X-Cache", "HIT")
+		}
+		w.WriteHeader(statusCode)
+		_, _ = w.Write(body)
+		return cachedRequest{}, true
+	}
+	w.Header().Set("X-Cache", "MISS")
+	return cachedRequest{
+		key:     cacheKey,
+		tags:    h.evaluateCacheTags(r, params, clientIP, cookies, requestID),
+		enabled: true,
+	}, false
+}
+
+// writeDefaultResponse sends a response when the workflow did not send one itself
+func (h *HTTPHandler) writeDefaultResponse(w http.ResponseWriter, result *ExecuteResult, requestID string) {
+	if result.ResponseSent {
+		return
+	}
+	if errors.Is(result.Error, concurrency.ErrLimitReached) {
+		h.writeError(w, http.StatusTooManyRequests, CodeConcurrencyLimit, "too many concurrent requests", requestID)
+	} else if errors.Is(result.Error, ErrResultTooLarge) {
+		h.writeError(w, http.StatusInternalServerError, CodeResultTooLarge, "query result too large", requestID)
+	} else if result.Error != nil {
+		status, message := h.workflow.Config.mapStatus(errorClass(result.Error), http.StatusInternalServerError, "workflow execution failed")
+		h.writeError(w, status, errorCode(result.Error), message, requestID)
+	} else {
+		// Send empty success response
+		h.writeSuccess(w, nil, requestID)
+	}
+}
+
+// serveCoalesced executes the workflow for a cache miss and caches the response.
+// Concurrent misses for the same cache key share a single execution: the first request
+// runs the workflow and the rest wait for its captured response. The execution is
+// detached from the first client so its disconnect does not fail the others.
+func (h *HTTPHandler) serveCoalesced(w http.ResponseWriter, r *http.Request, cacheKey string, cacheTags []string, triggerData *TriggerData, requestID string) {
+	leader := false
+	triggerData.Headers = triggerData.Headers.Clone()
+	ch := h.inflight.DoChan(cacheKey, func() (resp any, err error) {
+		leader = true
+		// singleflight re-panics on a fresh goroutine, out of reach of the recovery middleware
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("workflow panic: %v", p)
+			}
+		}()
+
+		ctx, cancel := h.detachedContext(r.Context())
+		defer cancel()
+
+		capture := &responseCapture{ResponseWriter: &discardResponseWriter{header: make(http.Header)}}
+		result := h.executor.Execute(ctx, h.workflow, triggerData, requestID, capture, h.variables)
+		h.writeDefaultResponse(capture, result, requestID)
+		h.storeResponse(cacheKey, cacheTags, capture)
+		return &sharedResponse{result: result, capture: capture, requestID: requestID}, nil
+	})
+
+	var resp *sharedResponse
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			if leader && h.executor.Logger() != nil {
+				h.executor.Logger().Error("trigger_execution_panic", map[string]any{
 					"workflow":   h.workflow.Config.Name,
-					"error":      err.Error(),
 					"request_id": requestID,
+					"error":      res.Err.Error(),
 				})
 			}
-			cacheEnabled = false
-		} else {
-			// Check cache for hit
-			if body, statusCode, hit := h.cache.Get(h.workflow.Config.Name, cacheKey); hit {
-				w.Header().Set("X-Cache", "HIT")
-				w.WriteHeader(statusCode)
-				_, _ = w.Write(body)
-				return
-			}
-			w.Header().Set("X-Cache", "MISS")
+			h.writeError(w, http.StatusInternalServerError, CodeInternal, "workflow execution failed", requestID)
+			return
 		}
+		resp = res.Val.(*sharedResponse)
+	case <-r.Context().Done():
+		// Client went away; the shared execution carries on for the others
+		return
 	}
 
-	// Build trigger data
-	triggerData := &TriggerData{
-		Type:     "http",
+	if leader {
+		// Only the executing request accounts for the queries it ran
+		if acc := metrics.GetAccumulator(r.Context()); acc != nil {
+			h.populateMetrics(acc, resp.result)
+		}
+	} else if h.executor.Logger() != nil {
+		h.executor.Logger().Debug("trigger_request_coalesced", map[string]any{
+			"workflow":   h.workflow.Config.Name,
+			"request_id": requestID,
+		})
+	}
+
+	for name, values := range resp.capture.Header() {
+		w.Header()[name] = slices.Clone(values)
+	}
+	body := resp.capture.body.Bytes()
+	if !leader && resp.requestID != requestID {
+		// The response was rendered for the leader; trace it under this request's own ID
+		w.Header().Set("X-Request-ID", requestID)
+		w.Header().Del("Content-Length")
+		body = restampRequestID(body, resp.requestID, requestID)
+	}
+	statusCode := resp.capture.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
+
+// restampRequestID replaces the JSON strings in body that are exactly the request ID from
+// with the request ID to, such as the request_id of an envelope or a template's .workflow.request_id.
+func restampRequestID(body []byte, from, to string) []byte {
+	old, _ := json.Marshal(from)
+	stamped, _ := json.Marshal(to)
+	return bytes.ReplaceAll(body, old, stamped)
+}
+
+// detachedContext derives a context that survives the request being cancelled.
+// It keeps request values and is capped by detachedExecutionTimeout unless the workflow sets its own timeout.
+func (h *HTTPHandler) detachedContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx := context.WithoutCancel(parent)
+	if h.workflow.Config.TimeoutSec > 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, detachedExecutionTimeout)
+}
+
+// buildTriggerData assembles the trigger data passed to the workflow for an HTTP request
+func (h *HTTPHandler) buildTriggerData(r *http.Request, headers http.Header, params map[string]any, cookies map[string]string, clientIP string) *TriggerData {
+	triggerType := TriggerTypeHTTP
+	if h.trigger.Config.Type == TriggerTypeWebSocket || h.trigger.Config.Type == TriggerTypeGRPC {
+		triggerType = h.trigger.Config.Type
+	}
+	data := &TriggerData{
+		Type:     triggerType,
+		Lang:     negotiateLanguage(r.Header.Get("Accept-Language")),
+		Priority: h.trigger.Config.Priority,
 		Params:   params,
-		Headers:  r.Header,
+		Headers:  headers,
 		Cookies:  cookies,
 		ClientIP: clientIP,
 		Method:   r.Method,
 		Path:     r.URL.Path,
-	}
 
-	// Use response capture if caching is enabled
-	var capture *responseCapture
-	responseWriter := w
-	if cacheEnabled {
-		capture = &responseCapture{ResponseWriter: w}
-		responseWriter = capture
+		ResponseHeaders: h.trigger.ResponseHeaders,
+	}
+	// serve has already rejected requests whose sort or filters do not parse
+	if h.trigger.Config.SortableColumns != nil || h.trigger.Config.FilterableColumns != nil {
+		if q, err := parseListQuery(h.trigger.Config, r.URL.Query()); err == nil {
+			data.OrderBy, data.Where = q.orderBy, q.where
+		}
 	}
+	return data
+}
 
-	// Execute workflow
-	result := h.executor.Execute(r.Context(), h.workflow, triggerData, requestID, responseWriter, h.variables)
+// negotiateLanguage picks the catalog language for an Accept-Language header,
+// or "" when no messages are configured
+func negotiateLanguage(header string) string {
+	m := getTemplateMessages()
+	if m == nil {
+		return ""
+	}
+	return m.Negotiate(header)
+}
 
-	// Populate metrics accumulator with execution details
-	if acc := metrics.GetAccumulator(r.Context()); acc != nil {
-		h.populateMetrics(acc, result)
+// DefaultLanguage returns the catalog's default language for triggers without
+// request headers, or "" when no messages are configured
+func DefaultLanguage() string {
+	m := getTemplateMessages()
+	if m == nil {
+		return ""
 	}
+	return m.Default()
+}
 
-	// If workflow didn't send a response (no response step executed), send a default response
-	if !result.ResponseSent {
-		if result.Error != nil {
-			h.writeError(responseWriter, http.StatusInternalServerError, "workflow execution failed", requestID)
-		} else {
-			// Send empty success response
-			h.writeSuccess(responseWriter, nil, requestID)
+// storeResponse caches a captured response if its status is cacheable (2xx/3xx)
+func (h *HTTPHandler) storeResponse(cacheKey string, tags []string, capture *responseCapture) {
+	if capture.statusCode < 200 || capture.statusCode >= 400 {
+		return
+	}
+	var ttl, staleTTL time.Duration
+	if cfg := h.trigger.Config.Cache; cfg != nil {
+		if cfg.TTLSec > 0 {
+			ttl = time.Duration(cfg.TTLSec) * time.Second
+		}
+		if cfg.StaleWhileRevalidateSec > 0 {
+			staleTTL = time.Duration(cfg.StaleWhileRevalidateSec) * time.Second
 		}
 	}
+	h.cache.Set(h.workflow.Config.Name, cacheKey, capture.body.Bytes(), capture.statusCode, ttl, staleTTL, tags)
+}
 
-	// Cache the response if caching is enabled and we have a successful response
-	if cacheEnabled && capture != nil && capture.statusCode >= 200 && capture.statusCode < 400 {
-		ttl := time.Duration(0)
-		if h.trigger.Config.Cache != nil && h.trigger.Config.Cache.TTLSec > 0 {
-			ttl = time.Duration(h.trigger.Config.Cache.TTLSec) * time.Second
-		}
-		h.cache.Set(h.workflow.Config.Name, cacheKey, capture.body.Bytes(), capture.statusCode, ttl)
+// revalidate re-executes the workflow in the background to refresh a stale cache entry.
+// Only one refresh per cache key runs at a time; concurrent stale hits just serve the stale body.
+func (h *HTTPHandler) revalidate(r *http.Request, cacheKey string, params map[string]any, cookies map[string]string, clientIP string) {
+	if _, inFlight := h.revalidating.LoadOrStore(cacheKey, struct{}{}); inFlight {
+		return
 	}
+
+	// Detach from the request so the refresh survives the response being sent
+	ctx, cancel := h.detachedContext(r.Context())
+	triggerData := h.buildTriggerData(r, r.Header.Clone(), params, cookies, clientIP)
+	requestID := generateRequestID()
+	tags := h.evaluateCacheTags(r, params, clientIP, cookies, requestID)
+
+	go func() {
+		defer cancel()
+		defer h.revalidating.Delete(cacheKey)
+		defer func() {
+			if p := recover(); p != nil && h.executor.Logger() != nil {
+				h.executor.Logger().Error("trigger_cache_revalidate_panic", map[string]any{
+					"workflow":   h.workflow.Config.Name,
+					"request_id": requestID,
+					"panic":      fmt.Sprintf("%v", p),
+				})
+			}
+		}()
+
+		capture := &responseCapture{ResponseWriter: &discardResponseWriter{header: make(http.Header)}}
+		result := h.executor.Execute(ctx, h.workflow, triggerData, requestID, capture, h.variables)
+		if result.Error != nil || !result.ResponseSent {
+			if h.executor.Logger() != nil {
+				fields := map[string]any{
+					"workflow":   h.workflow.Config.Name,
+					"request_id": requestID,
+				}
+				if result.Error != nil {
+					fields["error"] = result.Error.Error()
+				}
+				h.executor.Logger().Warn("trigger_cache_revalidate_failed", fields)
+			}
+			return
+		}
+		h.storeResponse(cacheKey, tags, capture)
+	}()
 }
 
+// discardResponseWriter is a ResponseWriter for background executions whose output is only captured
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (d *discardResponseWriter) Header() http.Header         { return d.header }
+func (d *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardResponseWriter) WriteHeader(int)             {}
+
 func (h *HTTPHandler) populateMetrics(acc *metrics.RequestAccumulator, result *ExecuteResult) {
 	if result.Error != nil {
 		acc.Error = result.Error.Error()
+		acc.ErrorCode = errorCode(result.Error)
 		if errors.Is(result.Error, context.DeadlineExceeded) {
 			acc.ErrorType = "timeout"
+		} else if errors.Is(result.Error, concurrency.ErrLimitReached) {
+			acc.ErrorType = "concurrency_limited"
+		} else if errors.Is(result.Error, ErrResultTooLarge) {
+			acc.ErrorType = "result_too_large"
 		} else {
 			acc.ErrorType = "execution_failed"
 		}
@@ -218,14 +611,40 @@ func (h *HTTPHandler) populateMetrics(acc *metrics.RequestAccumulator, result *E
 		} else {
 			acc.RowCount += sr.Count
 		}
-		if sr.Error != nil && acc.Error == "" {
+		if sr.Error != nil && !sr.Fallback && acc.Error == "" {
 			acc.Error = sr.Error.Error()
 			acc.ErrorType = "query_failed"
+			if sr.TimedOut {
+				acc.ErrorType = "query_timeout"
+			}
 		}
 	}
 }
 
 func (h *HTTPHandler) evaluateCacheKey(tmpl *template.Template, r *http.Request, params map[string]any, clientIP string, cookies map[string]string, requestID string) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, cacheTemplateData(r, params, clientIP, cookies, requestID)); err != nil {
+		return "", fmt.Errorf("evaluating trigger cache key: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// evaluateCacheTags renders the trigger's cache tag templates.
+// Tag errors are logged and the response is cached with the tags that did render.
+func (h *HTTPHandler) evaluateCacheTags(r *http.Request, params map[string]any, clientIP string, cookies map[string]string, requestID string) []string {
+	tags, err := evaluateTags(h.trigger.CacheTags, cacheTemplateData(r, params, clientIP, cookies, requestID))
+	if err != nil && h.executor != nil && h.executor.Logger() != nil {
+		h.executor.Logger().Warn("trigger_cache_tags_error", map[string]any{
+			"workflow":   h.workflow.Config.Name,
+			"error":      err.Error(),
+			"request_id": requestID,
+		})
+	}
+	return tags
+}
+
+// cacheTemplateData builds the data for trigger cache key and tag templates
+func cacheTemplateData(r *http.Request, params map[string]any, clientIP string, cookies map[string]string, requestID string) map[string]any {
 	// Build trigger namespace matching response template context
 	trigger := map[string]any{
 		"params":    params,
@@ -236,15 +655,10 @@ func (h *HTTPHandler) evaluateCacheKey(tmpl *template.Template, r *http.Request,
 		"query":     flattenQuery(r.URL.Query()),
 		"cookies":   cookies,
 	}
-	data := map[string]any{
+	return map[string]any{
 		"trigger":   trigger,
 		"RequestID": requestID,
 	}
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("evaluating trigger cache key: %w", err)
-	}
-	return buf.String(), nil
 }
 
 // flattenHeaders converts http.Header to a simple map, keeping only the first
@@ -316,8 +730,11 @@ func (h *HTTPHandler) parseParameters(r *http.Request) (map[string]any, error) {
 		paramTypes[p.Name] = strings.ToLower(p.Type)
 	}
 
-	// Extract path parameters from trigger config path
+	// Extract path parameters from trigger config path and the rewrite rules
 	pathParams := ExtractPathParams(h.trigger.Config.Path)
+	for _, pp := range h.trigger.PathParams {
+		pathParams[pp.Config.Name] = true
+	}
 
 	// Parse JSON body if Content-Type is application/json
 	var jsonParams map[string]any
@@ -377,7 +794,12 @@ func (h *HTTPHandler) parseParameters(r *http.Request) (map[string]any, error) {
 
 		if value == "" {
 			if p.Required {
-				return nil, fmt.Errorf("missing required parameter: %s", p.Name)
+				return nil, fmt.Errorf("%w: %s", errParamMissing, p.Name)
+			}
+			// An omitted non-string param without a default has nothing to convert: it is null
+			if p.Default == "" && paramType(&p) != "string" {
+				params[p.Name] = nil
+				continue
 			}
 			// Use default value (even if it's empty string) for optional params
 			value = p.Default
@@ -393,50 +815,35 @@ func (h *HTTPHandler) parseParameters(r *http.Request) (map[string]any, error) {
 	return params, nil
 }
 
-type httpResponse struct {
-	Success   bool   `json:"success"`
-	Data      any    `json:"data,omitempty"`
-	Error     string `json:"error,omitempty"`
-	RequestID string `json:"request_id,omitempty"`
-}
-
 func (h *HTTPHandler) writeSuccess(w http.ResponseWriter, data any, requestID string) {
-	resp := httpResponse{
-		Success:   true,
-		Data:      data,
-		RequestID: requestID,
-	}
 	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(resp)
+	_, _ = w.Write(h.workflow.Config.Envelope.successBody(data, requestID))
 }
 
-func (h *HTTPHandler) writeError(w http.ResponseWriter, status int, message string, requestID string) {
-	resp := httpResponse{
-		Success:   false,
-		Error:     message,
-		RequestID: requestID,
-	}
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(resp)
+func (h *HTTPHandler) writeError(w http.ResponseWriter, status int, code, message, requestID string) {
+	h.workflow.Config.writeErrorBody(w, status, message, code, requestID)
+}
+
+// writeInvalidRequest writes the error of a bad parameter or request body, with
+// the status and message of the validation entry of status_map
+func (h *HTTPHandler) writeInvalidRequest(w http.ResponseWriter, code, message, requestID string) {
+	status, message := h.workflow.Config.mapStatus(ErrorClassValidation, http.StatusBadRequest, message)
+	h.writeError(w, status, code, message, requestID)
+}
+
+func (h *HTTPHandler) writeValidationError(w http.ResponseWriter, code, message string, violations []ParamViolation, requestID string) {
+	status, message := h.workflow.Config.mapStatus(ErrorClassValidation, http.StatusBadRequest, message)
+	h.workflow.Config.writeErrorBody(w, status, message, code, requestID, envelopeField{"violations", violations})
 }
 
 func (h *HTTPHandler) writeRateLimitError(w http.ResponseWriter, retryAfterSec int, requestID string) {
 	w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfterSec))
-	resp := rateLimitResponse{
-		Success:       false,
-		Error:         "rate limit exceeded",
-		RequestID:     requestID,
-		RetryAfterSec: retryAfterSec,
-	}
-	w.WriteHeader(http.StatusTooManyRequests)
-	_ = json.NewEncoder(w).Encode(resp)
+	h.workflow.Config.writeErrorBody(w, http.StatusTooManyRequests, "rate limit exceeded", CodeRateLimited, requestID, envelopeField{"retry_after_sec", retryAfterSec})
 }
 
-type rateLimitResponse struct {
-	Success       bool   `json:"success"`
-	Error         string `json:"error"`
-	RequestID     string `json:"request_id,omitempty"`
-	RetryAfterSec int    `json:"retry_after_sec"`
+func (h *HTTPHandler) writeOverloadedError(w http.ResponseWriter, retryAfterSec int, requestID string) {
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfterSec))
+	h.workflow.Config.writeErrorBody(w, http.StatusServiceUnavailable, "server overloaded", CodeOverloaded, requestID, envelopeField{"retry_after_sec", retryAfterSec})
 }
 
 func getOrGenerateRequestID(r *http.Request) string {
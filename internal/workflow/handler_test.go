@@ -2,13 +2,20 @@ package workflow
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"text/template"
 	"time"
 
+	"sql-proxy/internal/redact"
 	"sql-proxy/internal/workflow/step"
 )
 
@@ -136,6 +143,103 @@ func TestHTTPHandler_ServeHTTP_VersionWithBuildTime(t *testing.T) {
 	}
 }
 
+// TestHTTPHandler_ResponseHeaders verifies trigger response_headers reach default and response step responses
+func TestHTTPHandler_ResponseHeaders(t *testing.T) {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			if sql == "FAIL" {
+				return nil, fmt.Errorf("boom")
+			}
+			return &step.QueryResult{Rows: []map[string]any{{"id": 1}, {"id": 2}}}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	trigger := TriggerConfig{
+		Type:   "http",
+		Path:   "/items",
+		Method: "GET",
+		ResponseHeaders: map[string]string{
+			"Cache-Control": "public, max-age=60",
+			"X-Total-Count": "{{.steps.items.count}}",
+		},
+	}
+
+	tests := []struct {
+		name      string
+		sql       string
+		respond   *StepConfig
+		wantCache string
+		wantCount string
+	}{
+		{name: "default response", sql: "SELECT", wantCache: "public, max-age=60", wantCount: "2"},
+		{
+			name:      "response step headers win",
+			sql:       "SELECT",
+			respond:   &StepConfig{Name: "respond", Type: "response", Template: `{}`, Headers: map[string]string{"Cache-Control": "no-store"}},
+			wantCache: "no-store",
+			wantCount: "2",
+		},
+		{name: "failed run", sql: "FAIL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := []StepConfig{{Name: "items", Type: "query", Database: "testdb", SQL: tt.sql}}
+			if tt.respond != nil {
+				steps = append(steps, *tt.respond)
+			}
+			wf := mustCompile(t, &WorkflowConfig{Name: "items", Triggers: []TriggerConfig{trigger}, Steps: steps})
+			handler := NewHTTPHandler(exec, wf, wf.Triggers[0], nil, nil, false, "", "", nil)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/items", nil))
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+			if got := rec.Header().Get("X-Total-Count"); got != tt.wantCount {
+				t.Errorf("X-Total-Count = %q, want %q", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestHTTPHandler_Deprecated(t *testing.T) {
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+	tests := []struct {
+		name            string
+		deprecated      bool
+		sunset          string
+		wantDeprecation string
+		wantSunset      string
+	}{
+		{name: "current"},
+		{name: "deprecated", deprecated: true, wantDeprecation: "true"},
+		{name: "sunset date", deprecated: true, sunset: "2025-06-30", wantDeprecation: "true", wantSunset: "Mon, 30 Jun 2025 00:00:00 GMT"},
+		{name: "sunset timestamp", deprecated: true, sunset: "2025-06-30T12:00:00+02:00", wantDeprecation: "true", wantSunset: "Mon, 30 Jun 2025 10:00:00 GMT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := mustCompile(t, &WorkflowConfig{
+				Name:     "items",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/v1/items", Method: "GET", Deprecated: tt.deprecated, Sunset: tt.sunset}},
+				Steps:    []StepConfig{{Type: "response", Template: `{}`}},
+			})
+			handler := NewHTTPHandler(exec, wf, wf.Triggers[0], nil, nil, false, "", "", nil)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/items", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			if got := rec.Header().Get("Deprecation"); got != tt.wantDeprecation {
+				t.Errorf("Deprecation = %q, want %q", got, tt.wantDeprecation)
+			}
+			if got := rec.Header().Get("Sunset"); got != tt.wantSunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.wantSunset)
+			}
+		})
+	}
+}
+
 func TestHTTPHandler_ServeHTTP_RequestID_FromHeader(t *testing.T) {
 	logger := &testLogger{}
 	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, logger)
@@ -215,6 +319,46 @@ func TestHTTPHandler_ParseParameters_QueryString(t *testing.T) {
 	}
 }
 
+func TestHTTPHandler_ParseParameters_OmittedOptional(t *testing.T) {
+	var received map[string]any
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			received = params
+			return &step.QueryResult{}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	minQty := 1.0
+
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:  "test",
+		Steps: []StepConfig{{Name: "insert", Type: "query", Database: "db", SQL: "INSERT INTO t VALUES (@qty, @due, @note, @tag)"}},
+	})
+	trigger := &CompiledTrigger{
+		Config: &TriggerConfig{
+			Method: "POST",
+			Parameters: []ParamConfig{
+				{Name: "qty", Type: "int", Validation: &ParamValidation{Min: &minQty}},
+				{Name: "due", Type: "date"},
+				{Name: "note", Type: "string"},
+				{Name: "tag"}, // untyped params are strings
+			},
+		},
+	}
+	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/test", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	want := map[string]any{"qty": nil, "due": nil, "note": "", "tag": ""}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("params = %v, want %v", received, want)
+	}
+}
+
 func TestHTTPHandler_ParseParameters_MissingRequired(t *testing.T) {
 	logger := &testLogger{}
 	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, logger)
@@ -249,6 +393,170 @@ func TestHTTPHandler_ParseParameters_MissingRequired(t *testing.T) {
 	}
 }
 
+func TestHTTPHandler_ParamValidation(t *testing.T) {
+	queries := 0
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			queries++
+			return &step.QueryResult{Rows: []map[string]any{}}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:  "test",
+		Steps: []StepConfig{{Name: "fetch", Type: "query", Database: "db", SQL: "SELECT 1"}},
+	})
+	minAge, maxLen := 18.0, 5
+	trigger, err := compileTrigger(&TriggerConfig{
+		Method: "GET",
+		Parameters: []ParamConfig{
+			{Name: "age", Type: "int", Required: true, Validation: &ParamValidation{Min: &minAge}},
+			{Name: "code", Type: "string", Validation: &ParamValidation{MaxLength: &maxLen, Pattern: "^[A-Z]+$"}},
+			{Name: "sort", Type: "string", Default: "name", Validation: &ParamValidation{Enum: []string{"name", "date"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("compileTrigger failed: %v", err)
+	}
+	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+	t.Run("all violations reported", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/test?age=12&code=abcdefg&sort=size", nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+		var resp struct {
+			Error      string           `json:"error"`
+			Violations []ParamViolation `json:"violations"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		want := []ParamViolation{
+			{Parameter: "age", Rule: "min", Message: "must be at least 18"},
+			{Parameter: "code", Rule: "max_length", Message: "must be at most 5 characters"},
+			{Parameter: "code", Rule: "pattern", Message: "must match pattern ^[A-Z]+$"},
+			{Parameter: "sort", Rule: "enum", Message: "must be one of: name, date"},
+		}
+		if resp.Error != "parameter validation failed" || !reflect.DeepEqual(resp.Violations, want) {
+			t.Errorf("response = %+v, want violations %+v", resp, want)
+		}
+		if queries != 0 {
+			t.Errorf("steps ran despite validation failure (%d queries)", queries)
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		// code is optional and omitted; sort uses its default
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/test?age=30", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		if queries != 1 {
+			t.Errorf("queries = %d, want 1", queries)
+		}
+	})
+}
+
+func TestHTTPHandler_BodySchema(t *testing.T) {
+	var received map[string]any
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			received = params
+			return &step.QueryResult{Rows: []map[string]any{}}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:  "test",
+		Steps: []StepConfig{{Name: "insert", Type: "query", Database: "db", SQL: "INSERT INTO orders VALUES (@sku, @qty)"}},
+	})
+	trigger, err := compileTrigger(&TriggerConfig{
+		Method:     "POST",
+		Parameters: []ParamConfig{{Name: "sku", Type: "string", Required: true}, {Name: "qty", Type: "int", Required: true}},
+		BodySchema: map[string]any{
+			"type":                 "object",
+			"required":             []any{"sku", "qty"},
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"sku": map[string]any{"type": "string", "pattern": "^[A-Z]+-[0-9]+$"},
+				"qty": map[string]any{"type": "integer", "minimum": 1},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("compileTrigger failed: %v", err)
+	}
+	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("schema violations", func(t *testing.T) {
+		rec := post("application/json", `{"sku":"abc","qty":0,"extra":true}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+		var resp struct {
+			Error      string           `json:"error"`
+			Violations []ParamViolation `json:"violations"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		want := []ParamViolation{
+			{Path: "/qty", Rule: "minimum", Message: "must be >= 1"},
+			{Path: "/sku", Rule: "pattern", Message: "must match pattern ^[A-Z]+-[0-9]+$"},
+			{Path: "/extra", Rule: "additionalProperties", Message: "is not allowed"},
+		}
+		if resp.Error != "request body validation failed" || !reflect.DeepEqual(resp.Violations, want) {
+			t.Errorf("response = %+v, want violations %+v", resp, want)
+		}
+		if received != nil {
+			t.Error("steps ran despite schema failure")
+		}
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		tests := []struct {
+			contentType, body, wantErr string
+		}{
+			{"application/x-www-form-urlencoded", "sku=A-1&qty=1", "request body must be JSON"},
+			{"application/json", "", "request body is required"},
+			{"application/json", "{", "failed to parse JSON body"},
+		}
+		for _, tt := range tests {
+			rec := post(tt.contentType, tt.body)
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.wantErr) {
+				t.Errorf("body %q: status = %d, body = %s, want %q", tt.body, rec.Code, rec.Body.String(), tt.wantErr)
+			}
+		}
+	})
+
+	t.Run("valid body reaches parameters", func(t *testing.T) {
+		rec := post("application/json", `{"sku":"AB-12","qty":3}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		if received["sku"] != "AB-12" || received["qty"] != 3 {
+			t.Errorf("query params = %v", received)
+		}
+	})
+}
+
 func TestHTTPHandler_ParseParameters_JSONBody(t *testing.T) {
 	logger := &testLogger{}
 	db := &mockDBManager{
@@ -393,6 +701,219 @@ func TestHTTPHandler_NoResponse_EmptySuccess(t *testing.T) {
 	}
 }
 
+func TestHTTPHandler_Envelope(t *testing.T) {
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+	minAge := 18.0
+	trigger, err := compileTrigger(&TriggerConfig{
+		Method:     "GET",
+		Parameters: []ParamConfig{{Name: "age", Type: "int", Validation: &ParamValidation{Min: &minAge}}},
+	})
+	if err != nil {
+		t.Fatalf("compileTrigger failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		envelope *EnvelopeConfig
+		request  string // Query string, or POST for a wrong method
+		want     string
+	}{
+		{"default success", nil, "", `{"success":true,"request_id":"r1"}`},
+		{"default error", nil, "POST", `{"success":false,"error":"method not allowed","code":"SQLPROXY_METHOD_NOT_ALLOWED","request_id":"r1"}`},
+		{
+			"renamed and omitted keys",
+			&EnvelopeConfig{Success: "-", Error: "message", Code: "error_code", RequestID: "trace_id"},
+			"POST", `{"message":"method not allowed","error_code":"SQLPROXY_METHOD_NOT_ALLOWED","trace_id":"r1"}`,
+		},
+		{
+			"meta",
+			&EnvelopeConfig{Meta: "meta"},
+			"?age=12", `{"error":"parameter validation failed","code":"SQLPROXY_PARAM_INVALID","violations":[{"parameter":"age","rule":"min","message":"must be at least 18"}],"meta":{"success":false,"request_id":"r1"}}`,
+		},
+		{"flat success", &EnvelopeConfig{Flat: true}, "", `{}`},
+		{"flat error", &EnvelopeConfig{Flat: true, RequestID: "-"}, "POST", `{"success":false,"error":"method not allowed","code":"SQLPROXY_METHOD_NOT_ALLOWED"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := mustCompile(t, &WorkflowConfig{Name: "test", Envelope: tt.envelope})
+			handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+			req := httptest.NewRequest("GET", "/test"+tt.request, nil)
+			if tt.request == "POST" {
+				req = httptest.NewRequest("POST", "/test", nil)
+			}
+			req.Header.Set("X-Request-ID", "r1")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPHandler_StatusMap(t *testing.T) {
+	var queryErr error
+	dbm := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return nil, queryErr
+		},
+	}
+	exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "test",
+		StatusMap: map[string]StatusMapping{
+			ErrorClassDeadlock:            {Status: http.StatusServiceUnavailable, Message: "busy, please retry"},
+			ErrorClassConstraintViolation: {Status: http.StatusConflict},
+			ErrorClassValidation:          {Status: http.StatusUnprocessableEntity},
+		},
+		Steps: []StepConfig{{Name: "save", Type: "query", Database: "db", SQL: "INSERT INTO t VALUES (@id)"}},
+	})
+	trigger := &CompiledTrigger{Config: &TriggerConfig{Method: "GET", Parameters: []ParamConfig{{Name: "id", Type: "int"}}}}
+	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+	tests := []struct {
+		name     string
+		query    string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{"deadlock", "", &step.ClassifiedError{Class: step.ErrorClassDeadlock, Err: testError{msg: "deadlock victim"}}, http.StatusServiceUnavailable, `"error":"busy, please retry"`},
+		{"constraint keeps message", "", &step.ClassifiedError{Class: step.ErrorClassConstraintViolation, Err: testError{msg: "duplicate key"}}, http.StatusConflict, `"error":"workflow execution failed"`},
+		{"unmapped class", "", context.DeadlineExceeded, http.StatusInternalServerError, `"error":"workflow execution failed"`},
+		{"unclassified", "", testError{msg: "syntax error"}, http.StatusInternalServerError, `"error":"workflow execution failed"`},
+		{"validation", "?id=x", nil, http.StatusUnprocessableEntity, `"error":"invalid value for parameter id`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queryErr = tt.err
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/test"+tt.query, nil))
+			if rec.Code != tt.wantCode || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("response = %d %s, want %d %s", rec.Code, rec.Body, tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHTTPHandler_ErrorCodes(t *testing.T) {
+	dbm := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return nil, &step.ClassifiedError{Class: step.ErrorClassTimeout, Err: context.DeadlineExceeded}
+		},
+	}
+	exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:  "test",
+		Steps: []StepConfig{{Name: "fetch", Type: "query", Database: "db", SQL: "SELECT 1"}},
+	})
+	trigger := &CompiledTrigger{Config: &TriggerConfig{Method: "GET", Parameters: []ParamConfig{{Name: "id", Type: "int", Required: true}}}}
+	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+	tests := []struct {
+		method, query string
+		wantCode      string
+	}{
+		{"POST", "", CodeMethodNotAllowed},
+		{"GET", "", CodeParamMissing},
+		{"GET", "?id=x", CodeParamInvalid},
+		{"GET", "?id=1", CodeDBTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantCode, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, "/test"+tt.query, nil))
+			var body struct {
+				Code string `json:"code"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != tt.wantCode {
+				t.Errorf("body = %s, want code %s", rec.Body, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHTTPHandler_ProblemJSON(t *testing.T) {
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+	minAge := 18.0
+	trigger, err := compileTrigger(&TriggerConfig{
+		Method:     "GET",
+		Parameters: []ParamConfig{{Name: "age", Type: "int", Validation: &ParamValidation{Min: &minAge}}},
+	})
+	if err != nil {
+		t.Fatalf("compileTrigger failed: %v", err)
+	}
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:        "test",
+		ErrorFormat: ErrorFormatProblemJSON,
+		StatusMap:   map[string]StatusMapping{ErrorClassValidation: {Status: http.StatusUnprocessableEntity}},
+	})
+	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+	tests := []struct {
+		name, method, query string
+		wantStatus          int
+		wantContentType     string
+		want                string
+	}{
+		{"success keeps envelope", "GET", "", http.StatusOK, "application/json", `{"success":true,"request_id":"r1"}`},
+		{
+			"error", "POST", "", http.StatusMethodNotAllowed, ProblemContentType,
+			`{"type":"about:blank","title":"Method Not Allowed","status":405,"detail":"method not allowed","code":"SQLPROXY_METHOD_NOT_ALLOWED","instance":"urn:request:r1"}`,
+		},
+		{
+			"mapped status with violations", "GET", "?age=12", http.StatusUnprocessableEntity, ProblemContentType,
+			`{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"parameter validation failed","code":"SQLPROXY_PARAM_INVALID","instance":"urn:request:r1","violations":[{"parameter":"age","rule":"min","message":"must be at least 18"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/test"+tt.query, nil)
+			req.Header.Set("X-Request-ID", "r1")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tt.wantContentType {
+				t.Errorf("Content-Type = %s, want %s", ct, tt.wantContentType)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{fmt.Errorf("query failed: %w", &step.ClassifiedError{Class: step.ErrorClassDeadlock, Err: testError{msg: "deadlock"}}), CodeDBDeadlock},
+		{&step.ClassifiedError{Class: step.ErrorClassConstraintViolation, Err: testError{msg: "duplicate"}}, CodeDBConstraint},
+		{&stepTimeoutError{timeout: time.Second, err: testError{msg: "slow"}}, CodeTimeout},
+		{fmt.Errorf("%w (max_rows 10)", ErrResultTooLarge), CodeResultTooLarge},
+		{&HTTPStatusError{StatusCode: 404}, CodeNotFound},
+		{&HTTPStatusError{StatusCode: 502}, CodeUpstreamError},
+		{testError{msg: "syntax error"}, CodeStepFailed},
+	}
+	for _, tt := range tests {
+		if got := errorCode(tt.err); got != tt.want {
+			t.Errorf("errorCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+
+	m := stepResultToMap(&StepResult{Name: "call", Type: "httpcall", Error: &HTTPStatusError{StatusCode: 503}})
+	if m["error"] != "HTTP 503 Service Unavailable" || m["error_code"] != CodeUpstreamError {
+		t.Errorf("step error = %v, error_code = %v", m["error"], m["error_code"])
+	}
+}
+
 func TestGetOrGenerateRequestID(t *testing.T) {
 	t.Run("from X-Request-ID", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/", nil)
@@ -688,44 +1209,57 @@ func TestHTTPHandler_ParseParameters_ArrayType(t *testing.T) {
 
 // mockTriggerCache implements TriggerCache for testing.
 type mockTriggerCache struct {
-	data map[string]struct {
-		body       []byte
-		statusCode int
-	}
+	mu   sync.Mutex
+	data map[string]mockCacheEntry
+}
+
+type mockCacheEntry struct {
+	body       []byte
+	statusCode int
+	stale      bool
+	staleTTL   time.Duration
+	tags       []string
 }
 
 func newMockTriggerCache() *mockTriggerCache {
 	return &mockTriggerCache{
-		data: make(map[string]struct {
-			body       []byte
-			statusCode int
-		}),
+		data: make(map[string]mockCacheEntry),
 	}
 }
 
-func (m *mockTriggerCache) Get(workflow, key string) ([]byte, int, bool) {
+func (m *mockTriggerCache) Get(workflow, key string) ([]byte, int, bool, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	fullKey := workflow + ":" + key
 	entry, ok := m.data[fullKey]
 	if !ok {
-		return nil, 0, false
+		return nil, 0, false, false
 	}
-	return entry.body, entry.statusCode, true
+	return entry.body, entry.statusCode, entry.stale, true
 }
 
-func (m *mockTriggerCache) Set(workflow, key string, body []byte, statusCode int, ttl time.Duration) bool {
+func (m *mockTriggerCache) Set(workflow, key string, body []byte, statusCode int, ttl, staleTTL time.Duration, tags []string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	fullKey := workflow + ":" + key
-	m.data[fullKey] = struct {
-		body       []byte
-		statusCode int
-	}{body: body, statusCode: statusCode}
+	m.data[fullKey] = mockCacheEntry{body: body, statusCode: statusCode, staleTTL: staleTTL, tags: tags}
 	return true
 }
 
+// markStale flags an entry as past its TTL
+func (m *mockTriggerCache) markStale(workflow, key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.data[workflow+":"+key]
+	entry.stale = true
+	m.data[workflow+":"+key] = entry
+}
+
 func TestHTTPHandler_TriggerCache_Hit(t *testing.T) {
 	// Pre-populate cache
 	cache := newMockTriggerCache()
 	cachedBody := []byte(`{"success":true,"data":{"cached":true}}`)
-	cache.Set("test_workflow", "response:user:42", cachedBody, 200, 0)
+	cache.Set("test_workflow", "response:user:42", cachedBody, 200, 0, 0, nil)
 
 	logger := &testLogger{}
 	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, logger)
@@ -822,7 +1356,7 @@ func TestHTTPHandler_TriggerCache_Miss(t *testing.T) {
 	}
 
 	// Should have cached the response
-	cachedBody, cachedStatus, hit := cache.Get("test_workflow", "response:user:42")
+	cachedBody, cachedStatus, _, hit := cache.Get("test_workflow", "response:user:42")
 	if !hit {
 		t.Error("Expected response to be cached")
 	}
@@ -834,64 +1368,613 @@ func TestHTTPHandler_TriggerCache_Miss(t *testing.T) {
 	}
 }
 
-func TestHTTPHandler_TriggerCache_NilCache(t *testing.T) {
-	logger := &testLogger{}
+func TestHTTPHandler_TriggerCache_StaleWhileRevalidate(t *testing.T) {
+	cache := newMockTriggerCache()
+	staleBody := []byte(`{"success":true,"data":"stale"}`)
+	cache.Set("test_workflow", "swr", staleBody, 200, 0, 0, nil)
+	cache.markStale("test_workflow", "swr")
+
+	var queries sync.WaitGroup
+	queries.Add(1)
 	db := &mockDBManager{
 		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
-			return &step.QueryResult{Rows: []map[string]any{{"id": 1}}}, nil
+			defer queries.Done()
+			return &step.QueryResult{Rows: []map[string]any{{"v": "fresh"}}}, nil
 		},
 	}
-	exec := NewExecutor(db, &mockHTTPClient{}, nil, logger)
-
-	// Trigger with cache key but no cache provided
-	cacheKeyTmpl := template.Must(template.New("cache_key").Parse("response:user:{{.trigger.params.id}}"))
-	sqlTmpl := template.Must(template.New("sql").Parse("SELECT * FROM users"))
-	responseTmpl := template.Must(template.New("response").Parse(`{"success":true}`))
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
 
 	wf := &CompiledWorkflow{
 		Config: &WorkflowConfig{Name: "test_workflow"},
 		Steps: []*CompiledStep{
 			{
 				Config:  &StepConfig{Name: "query", Type: "query", Database: "testdb"},
-				SQLTmpl: sqlTmpl,
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT 'fresh' AS v")),
 			},
 			{
 				Config:       &StepConfig{Name: "response", Type: "response", StatusCode: 200},
-				TemplateTmpl: responseTmpl,
+				TemplateTmpl: template.Must(template.New("response").Funcs(TemplateFuncs).Parse(`{"success":true,"data":{{json .steps.query.data}}}`)),
 			},
 		},
 	}
 	trigger := &CompiledTrigger{
 		Config: &TriggerConfig{
 			Method: "GET",
-			Parameters: []ParamConfig{
-				{Name: "id", Type: "int", Required: true},
-			},
-			Cache: &CacheConfig{Enabled: true, Key: "response:user:{{.trigger.params.id}}"},
+			Cache:  &CacheConfig{Enabled: true, Key: "swr", TTLSec: 60, StaleWhileRevalidateSec: 30},
 		},
-		CacheKey: cacheKeyTmpl,
+		CacheKey: template.Must(template.New("cache_key").Parse("swr")),
 	}
 
-	// Pass nil for cache
-	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+	handler := NewHTTPHandler(exec, wf, trigger, nil, cache, false, "", "", nil)
 
-	req := httptest.NewRequest("GET", "/test?id=42", nil)
+	req := httptest.NewRequest("GET", "/test", nil)
 	rec := httptest.NewRecorder()
-
 	handler.ServeHTTP(rec, req)
 
-	// Should still work without cache
-	if rec.Code != 200 {
-		t.Errorf("status = %d, want 200", rec.Code)
+	// Stale body is served immediately
+	if rec.Header().Get("X-Cache") != "STALE" {
+		t.Errorf("X-Cache = %q, want STALE", rec.Header().Get("X-Cache"))
 	}
-	// X-Cache header should not be set when cache is nil
-	if rec.Header().Get("X-Cache") != "" {
-		t.Errorf("X-Cache = %q, want empty", rec.Header().Get("X-Cache"))
+	if rec.Body.String() != string(staleBody) {
+		t.Errorf("body = %q, want stale body", rec.Body.String())
 	}
-}
 
-func TestFlattenHeaders(t *testing.T) {
-	h := http.Header{
+	// Background refresh replaces the entry
+	queries.Wait()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		body, _, stale, hit := cache.Get("test_workflow", "swr")
+		if hit && !stale && strings.Contains(string(body), "fresh") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cache entry was not refreshed: %s (stale=%v)", body, stale)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cache.mu.Lock()
+	staleTTL := cache.data["test_workflow:swr"].staleTTL
+	cache.mu.Unlock()
+	if staleTTL != 30*time.Second {
+		t.Errorf("staleTTL = %v, want 30s", staleTTL)
+	}
+}
+
+func TestHTTPHandler_TriggerCache_Tags(t *testing.T) {
+	cache := newMockTriggerCache()
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test_workflow"},
+		Steps: []*CompiledStep{
+			{
+				Config:       &StepConfig{Name: "response", Type: "response"},
+				TemplateTmpl: template.Must(template.New("response").Parse(`{"ok":true}`)),
+			},
+		},
+	}
+	trigger := &CompiledTrigger{
+		Config: &TriggerConfig{
+			Method:     "GET",
+			Parameters: []ParamConfig{{Name: "id", Type: "int", Required: true}},
+			Cache:      &CacheConfig{Enabled: true, Key: "user:{{.trigger.params.id}}"},
+		},
+		CacheKey: template.Must(template.New("cache_key").Parse("user:{{.trigger.params.id}}")),
+		CacheTags: []*template.Template{
+			template.Must(template.New("cache_tag").Parse("user:{{.trigger.params.id}}")),
+			template.Must(template.New("cache_tag").Parse("users")),
+		},
+	}
+
+	handler := NewHTTPHandler(exec, wf, trigger, nil, cache, false, "", "", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test?id=42", nil))
+
+	cache.mu.Lock()
+	entry, ok := cache.data["test_workflow:user:42"]
+	cache.mu.Unlock()
+	if !ok {
+		t.Fatal("expected response to be cached")
+	}
+	if len(entry.tags) != 2 || entry.tags[0] != "user:42" || entry.tags[1] != "users" {
+		t.Errorf("tags = %v, want [user:42 users]", entry.tags)
+	}
+}
+
+// coalescingTestHandler builds a cached handler whose single query step runs queryFunc
+func coalescingTestHandler(queryFunc func() (*step.QueryResult, error)) *HTTPHandler {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return queryFunc()
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "dashboard"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "query", Type: "query", Database: "testdb"},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT 1")),
+			},
+			{
+				Config:       &StepConfig{Name: "response", Type: "response", Headers: map[string]string{"X-Source": "db"}},
+				TemplateTmpl: template.Must(template.New("response").Funcs(TemplateFuncs).Parse(`{"data":{{json .steps.query.data}},"request_id":{{json .workflow.request_id}}}`)),
+				HeaderTmpls:  map[string]*template.Template{"X-Source": template.Must(template.New("h").Parse("db"))},
+			},
+		},
+	}
+	trigger := &CompiledTrigger{
+		Config:   &TriggerConfig{Method: "GET", Cache: &CacheConfig{Enabled: true, Key: "dashboard"}},
+		CacheKey: template.Must(template.New("cache_key").Parse("dashboard")),
+	}
+	return NewHTTPHandler(exec, wf, trigger, nil, newMockTriggerCache(), false, "", "", nil)
+}
+
+func TestHTTPHandler_TriggerCache_CoalescesConcurrentMisses(t *testing.T) {
+	var queryCount atomic.Int32
+	release := make(chan struct{})
+	handler := coalescingTestHandler(func() (*step.QueryResult, error) {
+		queryCount.Add(1)
+		<-release
+		return &step.QueryResult{Rows: []map[string]any{{"total": 42}}}, nil
+	})
+
+	const requests = 20
+	recs := make([]*httptest.ResponseRecorder, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("GET", "/dashboard", nil)
+			req.Header.Set("X-Request-ID", fmt.Sprintf("req-%d", i))
+			handler.ServeHTTP(recs[i], req)
+		}()
+	}
+
+	// Let every request reach the in-flight execution before it completes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := queryCount.Load(); n != 1 {
+		t.Errorf("query executed %d times, want 1", n)
+	}
+	for i, rec := range recs {
+		if rec.Code != http.StatusOK {
+			t.Errorf("request %d: status = %d, want 200", i, rec.Code)
+		}
+		// Coalesced requests keep their own request IDs
+		if want := fmt.Sprintf(`{"data":[{"total":42}],"request_id":"req-%d"}`, i); rec.Body.String() != want {
+			t.Errorf("request %d: body = %s, want %s", i, rec.Body.String(), want)
+		}
+		if id := rec.Header().Get("X-Request-ID"); id != fmt.Sprintf("req-%d", i) {
+			t.Errorf("request %d: X-Request-ID = %s", i, id)
+		}
+		if rec.Header().Get("X-Source") != "db" {
+			t.Errorf("request %d: expected workflow header to be replayed", i)
+		}
+	}
+}
+
+func TestHTTPHandler_TriggerCache_CoalescedPanic(t *testing.T) {
+	handler := coalescingTestHandler(func() (*step.QueryResult, error) {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/dashboard", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
+
+func TestHTTPHandler_ConcurrencyLimit_Returns429(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			close(started)
+			<-release
+			return &step.QueryResult{}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "report", MaxConcurrent: 1},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "q", Type: "query", Database: "testdb"},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT 1")),
+			},
+		},
+	}
+	handler := NewHTTPHandler(exec, wf, &CompiledTrigger{Config: &TriggerConfig{Method: "GET"}}, nil, nil, false, "", "", nil)
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/report", nil))
+		close(done)
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/report", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "too many concurrent requests") {
+		t.Errorf("unexpected body: %s", rec.Body.String())
+	}
+
+	close(release)
+	<-done
+}
+
+// mockLoadShedder rejects the priorities in shed and counts admitted requests still running
+type mockLoadShedder struct {
+	shed     map[string]bool
+	admitted []string
+	running  int
+}
+
+func (m *mockLoadShedder) Admit(priority string) (func(), int, bool) {
+	if m.shed[priority] {
+		return nil, 7, false
+	}
+	m.admitted = append(m.admitted, priority)
+	m.running++
+	return func() { m.running-- }, 0, true
+}
+
+func TestHTTPHandler_LoadShedding(t *testing.T) {
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+	shedder := &mockLoadShedder{shed: map[string]bool{"low": true}}
+	exec.SetLoadShedder(shedder)
+	wf := &CompiledWorkflow{Config: &WorkflowConfig{Name: "report"}}
+
+	low := NewHTTPHandler(exec, wf, &CompiledTrigger{Config: &TriggerConfig{Method: "GET", Priority: "low"}}, nil, nil, false, "", "", nil)
+	rec := httptest.NewRecorder()
+	low.ServeHTTP(rec, httptest.NewRequest("GET", "/report", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "7" {
+		t.Errorf("Retry-After = %q, want 7", got)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	if body["code"] != CodeOverloaded || body["retry_after_sec"] != float64(7) {
+		t.Errorf("unexpected body: %v", body)
+	}
+
+	normal := NewHTTPHandler(exec, wf, &CompiledTrigger{Config: &TriggerConfig{Method: "GET"}}, nil, nil, false, "", "", nil)
+	rec = httptest.NewRecorder()
+	normal.ServeHTTP(rec, httptest.NewRequest("GET", "/report", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if len(shedder.admitted) != 1 || shedder.admitted[0] != "" || shedder.running != 0 {
+		t.Errorf("admitted = %v with %d running, want one released request", shedder.admitted, shedder.running)
+	}
+}
+
+type mockRateLimiter struct {
+	result RateLimitResult
+}
+
+func (m *mockRateLimiter) CheckTriggerLimits(limits []*CompiledRateLimit, ctx *RateLimitContext) (RateLimitResult, error) {
+	return m.result, nil
+}
+
+func TestHTTPHandler_RateLimitHeaders(t *testing.T) {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "limited"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "q", Type: "query", Database: "testdb"},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT 1")),
+			},
+		},
+	}
+	trigger := &CompiledTrigger{
+		Config:     &TriggerConfig{Method: "GET"},
+		RateLimits: []*CompiledRateLimit{{Config: &RateLimitRefConfig{Pool: "default"}}},
+	}
+	quota := http.Header{"X-Ratelimit-Limit": {"5"}, "X-Ratelimit-Remaining": {"0"}}
+
+	t.Run("allowed", func(t *testing.T) {
+		limiter := &mockRateLimiter{result: RateLimitResult{Allowed: true, Headers: quota}}
+		handler := NewHTTPHandler(exec, wf, trigger, limiter, nil, false, "", "", nil)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/limited", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+		if rec.Header().Get("X-RateLimit-Limit") != "5" {
+			t.Errorf("expected quota headers, got %v", rec.Header())
+		}
+		if rec.Header().Get("Retry-After") != "" {
+			t.Error("unexpected Retry-After on allowed request")
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		limiter := &mockRateLimiter{result: RateLimitResult{RetryAfterSec: 3, Headers: quota}}
+		handler := NewHTTPHandler(exec, wf, trigger, limiter, nil, false, "", "", nil)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/limited", nil))
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("status = %d, want 429", rec.Code)
+		}
+		if rec.Header().Get("X-RateLimit-Remaining") != "0" || rec.Header().Get("Retry-After") != "3" {
+			t.Errorf("unexpected headers: %v", rec.Header())
+		}
+	})
+}
+
+func TestHTTPHandler_RateLimitDelay(t *testing.T) {
+	var executed atomic.Int32
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			executed.Add(1)
+			return &step.QueryResult{}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "smoothed"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "q", Type: "query", Database: "testdb"},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT 1")),
+			},
+		},
+	}
+	trigger := &CompiledTrigger{
+		Config:     &TriggerConfig{Method: "GET"},
+		RateLimits: []*CompiledRateLimit{{Config: &RateLimitRefConfig{Pool: "leaky"}}},
+	}
+
+	t.Run("waits for its slot", func(t *testing.T) {
+		limiter := &mockRateLimiter{result: RateLimitResult{Allowed: true, Delay: 50 * time.Millisecond}}
+		handler := NewHTTPHandler(exec, wf, trigger, limiter, nil, false, "", "", nil)
+
+		start := time.Now()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/smoothed", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("expected request to be held for 50ms, took %v", elapsed)
+		}
+	})
+
+	t.Run("client gives up while queued", func(t *testing.T) {
+		executed.Store(0)
+		limiter := &mockRateLimiter{result: RateLimitResult{Allowed: true, Delay: time.Minute}}
+		handler := NewHTTPHandler(exec, wf, trigger, limiter, nil, false, "", "", nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/smoothed", nil).WithContext(ctx))
+		if executed.Load() != 0 {
+			t.Error("expected workflow not to run after the client went away")
+		}
+	})
+}
+
+// TestHTTPHandler_Middlewares verifies the trigger's middlewares list orders and skips the rate limit and cache stages
+func TestHTTPHandler_Middlewares(t *testing.T) {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "staged"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "q", Type: "query", Database: "testdb"},
+				SQLTmpl: template.Must(template.New("sql").Parse("SELECT 1")),
+			},
+		},
+	}
+	cache := newMockTriggerCache()
+	cache.Set("staged", "all", []byte(`{"cached":true}`), 200, 0, 0, nil)
+	limiter := &mockRateLimiter{result: RateLimitResult{RetryAfterSec: 1}}
+
+	tests := []struct {
+		name        string
+		middlewares []string
+		wantStatus  int
+		wantCache   string
+	}{
+		{name: "default order limits before the cache", wantStatus: http.StatusTooManyRequests},
+		{name: "cache first serves hits unlimited", middlewares: []string{"cache", "rate_limit"}, wantStatus: http.StatusOK, wantCache: "HIT"},
+		{name: "rate limit skipped", middlewares: []string{"cache"}, wantStatus: http.StatusOK, wantCache: "HIT"},
+		{name: "all skipped", middlewares: []string{}, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger := &CompiledTrigger{
+				Config: &TriggerConfig{
+					Method:      "GET",
+					Cache:       &CacheConfig{Enabled: true, Key: "all"},
+					Middlewares: tt.middlewares,
+				},
+				CacheKey:   template.Must(template.New("cache_key").Parse("all")),
+				RateLimits: []*CompiledRateLimit{{Config: &RateLimitRefConfig{Pool: "default"}}},
+			}
+			handler := NewHTTPHandler(exec, wf, trigger, limiter, cache, false, "", "", nil)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/staged", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("X-Cache = %q, want %q", got, tt.wantCache)
+			}
+		})
+	}
+}
+
+func TestHTTPHandler_TriggerCache_NilCache(t *testing.T) {
+	logger := &testLogger{}
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{Rows: []map[string]any{{"id": 1}}}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, logger)
+
+	// Trigger with cache key but no cache provided
+	cacheKeyTmpl := template.Must(template.New("cache_key").Parse("response:user:{{.trigger.params.id}}"))
+	sqlTmpl := template.Must(template.New("sql").Parse("SELECT * FROM users"))
+	responseTmpl := template.Must(template.New("response").Parse(`{"success":true}`))
+
+	wf := &CompiledWorkflow{
+		Config: &WorkflowConfig{Name: "test_workflow"},
+		Steps: []*CompiledStep{
+			{
+				Config:  &StepConfig{Name: "query", Type: "query", Database: "testdb"},
+				SQLTmpl: sqlTmpl,
+			},
+			{
+				Config:       &StepConfig{Name: "response", Type: "response", StatusCode: 200},
+				TemplateTmpl: responseTmpl,
+			},
+		},
+	}
+	trigger := &CompiledTrigger{
+		Config: &TriggerConfig{
+			Method: "GET",
+			Parameters: []ParamConfig{
+				{Name: "id", Type: "int", Required: true},
+			},
+			Cache: &CacheConfig{Enabled: true, Key: "response:user:{{.trigger.params.id}}"},
+		},
+		CacheKey: cacheKeyTmpl,
+	}
+
+	// Pass nil for cache
+	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+	req := httptest.NewRequest("GET", "/test?id=42", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	// Should still work without cache
+	if rec.Code != 200 {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+	// X-Cache header should not be set when cache is nil
+	if rec.Header().Get("X-Cache") != "" {
+		t.Errorf("X-Cache = %q, want empty", rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestHTTPHandler_Poll(t *testing.T) {
+	var executions atomic.Int64
+	var version atomic.Int64
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			executions.Add(1)
+			if version.Load() < 0 {
+				return nil, context.DeadlineExceeded
+			}
+			return &step.QueryResult{Rows: []map[string]any{{"v": version.Load()}}}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "feed",
+		Steps: []StepConfig{
+			{Name: "q", Type: "query", Database: "testdb", SQL: "SELECT v FROM feed"},
+			{Type: "response", Template: `{"v": {{.steps.q.row.v}}}`},
+		},
+	})
+	trigger := &CompiledTrigger{Config: &TriggerConfig{
+		Method: "GET",
+		Poll:   &PollConfig{IntervalMs: 10, TimeoutSec: 1},
+	}}
+	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+	poll := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without a cursor the current response is sent right away
+	rec := poll("/feed", nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"v": 0}` || etag == "" {
+		t.Fatalf("first poll = %d %q etag %q", rec.Code, rec.Body.String(), etag)
+	}
+	cursor := strings.Trim(etag, `"`)
+
+	// With the current cursor the request is held until the response changes
+	executions.Store(0)
+	go func() {
+		for executions.Load() < 3 {
+			time.Sleep(time.Millisecond)
+		}
+		version.Store(1)
+	}()
+	rec = poll("/feed?cursor="+cursor, nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"v": 1}` {
+		t.Fatalf("changed poll = %d %q", rec.Code, rec.Body.String())
+	}
+	if n := executions.Load(); n < 3 {
+		t.Errorf("expected repeated executions, got %d", n)
+	}
+	if got := rec.Header().Get("ETag"); got == etag {
+		t.Error("expected a new ETag after a change")
+	}
+
+	// No change before the timeout: 304 with the same cursor (If-None-Match works too)
+	etag = rec.Header().Get("ETag")
+	start := time.Now()
+	rec = poll("/feed", http.Header{"If-None-Match": {etag}})
+	if rec.Code != http.StatusNotModified || rec.Header().Get("ETag") != etag || rec.Body.Len() != 0 {
+		t.Errorf("timed out poll = %d etag %q body %q", rec.Code, rec.Header().Get("ETag"), rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("poll returned after %v, before the timeout", elapsed)
+	}
+
+	// Failures are sent at once
+	version.Store(-1)
+	rec = poll("/feed?cursor="+cursor, nil)
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("ETag") != "" {
+		t.Errorf("failed poll = %d etag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestFlattenHeaders(t *testing.T) {
+	h := http.Header{
 		"Content-Type":  []string{"application/json"},
 		"Accept":        []string{"text/html", "application/json"},
 		"Authorization": []string{"Bearer token"},
@@ -1033,3 +2116,187 @@ func mustCompile(t *testing.T, cfg *WorkflowConfig) *CompiledWorkflow {
 	}
 	return wf
 }
+
+func TestHTTPHandler_DebugLog(t *testing.T) {
+	redactor, err := redact.New(redact.Config{Fields: []string{"password"}, Patterns: []string{`\d{4}-\d{4}`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetPayloadRedactor(redactor)
+	defer SetPayloadRedactor(nil)
+
+	logger := &testLogger{}
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, logger)
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:  "login",
+		Steps: []StepConfig{{Type: "response", Template: `{"user": "{{.trigger.params.user}}", "card": "1234-5678"}`}},
+	})
+	trigger := &CompiledTrigger{Config: &TriggerConfig{
+		Method:     "POST",
+		Parameters: []ParamConfig{{Name: "user", Type: "string"}, {Name: "password", Type: "string"}},
+		DebugLog:   &DebugLogConfig{Request: true, Response: true},
+	}}
+	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+	req := httptest.NewRequest("POST", "/login?token=abc", strings.NewReader(`{"user": "ann", "password": "hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	// The body is still parsed after being logged
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"user": "ann"`) {
+		t.Fatalf("response = %d %s", rec.Code, rec.Body)
+	}
+
+	calls := map[string]map[string]any{}
+	for _, c := range logger.debugCalls {
+		calls[c.msg] = c.fields
+	}
+	request := calls["trigger_request_payload"]
+	if request == nil {
+		t.Fatal("request payload not logged")
+	}
+	if body := request["body"].(string); !strings.Contains(body, `"password":"[REDACTED]"`) || !strings.Contains(body, `"user":"ann"`) {
+		t.Errorf("request body = %s", body)
+	}
+	if headers := request["headers"].(map[string]string); headers["Authorization"] != redact.Mask {
+		t.Errorf("Authorization = %q", headers["Authorization"])
+	}
+	if request["query"] != "token=abc" || request["method"] != "POST" || request["path"] != "/login" {
+		t.Errorf("request = %v", request)
+	}
+
+	response := calls["trigger_response_payload"]
+	if response == nil {
+		t.Fatal("response payload not logged")
+	}
+	if body := response["body"].(string); strings.Contains(body, "1234-5678") || !strings.Contains(body, redact.Mask) {
+		t.Errorf("response body = %s", body)
+	}
+	if response["status"] != http.StatusOK || response["request_id"] != rec.Header().Get("X-Request-ID") {
+		t.Errorf("response = %v", response)
+	}
+}
+
+func TestHTTPHandler_Fields(t *testing.T) {
+	dbm := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{Rows: []map[string]any{{"id": 1, "name": "Ann", "email": "ann@example.com", "bio": "long"}}}, nil
+		},
+	}
+	exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "users",
+		Steps: []StepConfig{
+			{Name: "fetch", Type: "query", Database: "db", SQL: "SELECT * FROM users"},
+			{Type: "response", Template: `{{json .steps.fetch.data}}`},
+		},
+	})
+	trigger := &CompiledTrigger{Config: &TriggerConfig{
+		Method: "GET",
+		Fields: &FieldsConfig{Allowed: []string{"id", "name", "email"}, Steps: []string{"fetch"}},
+	}}
+	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+	tests := []struct {
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{"", http.StatusOK, `[{"bio":"long","email":"ann@example.com","id":1,"name":"Ann"}]`},
+		{"?fields=NAME,%20id,id", http.StatusOK, `[{"id":1,"name":"Ann"}]`},
+		{"?fields=bio", http.StatusBadRequest, "unknown field 'bio' (allowed: id, name, email)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/users"+tt.query, nil))
+			if rec.Code != tt.wantCode || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("response = %d %s, want %d %s", rec.Code, rec.Body, tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHTTPHandler_SortAndFilter(t *testing.T) {
+	var gotSQL string
+	var gotParams map[string]any
+	dbm := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			gotSQL, gotParams = sql, params
+			return &step.QueryResult{Rows: []map[string]any{}}, nil
+		},
+	}
+	exec := NewExecutor(dbm, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "orders",
+		Steps: []StepConfig{
+			{Name: "fetch", Type: "query", Database: "db", SQL: "SELECT * FROM orders WHERE {{.trigger.where}} {{.trigger.order_by}}"},
+			{Type: "response", Template: `{{json .steps.fetch.data}}`},
+		},
+	})
+	trigger := &CompiledTrigger{Config: &TriggerConfig{
+		Method:            "GET",
+		SortableColumns:   []string{"created_at", "id"},
+		FilterableColumns: []string{"status", "customer_id"},
+		DefaultSort:       "-id",
+	}}
+	handler := NewHTTPHandler(exec, wf, trigger, nil, nil, false, "", "", nil)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantCode   int
+		wantSQL    string
+		wantParams map[string]any
+		wantError  string
+	}{
+		{
+			name:       "defaults",
+			wantCode:   http.StatusOK,
+			wantSQL:    "SELECT * FROM orders WHERE 1=1 ORDER BY id DESC",
+			wantParams: map[string]any{},
+		},
+		{
+			name:       "sort and filters",
+			query:      "?sort=-Created_At,+id&filter[customer_id]=7&filter[status]=active",
+			wantCode:   http.StatusOK,
+			wantSQL:    "SELECT * FROM orders WHERE status = @filter_status AND customer_id = @filter_customer_id ORDER BY created_at DESC, id ASC",
+			wantParams: map[string]any{"filter_status": "active", "filter_customer_id": "7"},
+		},
+		{
+			name:       "injection stays a value",
+			query:      "?filter[status]=" + url.QueryEscape("x' OR 1=1 --"),
+			wantCode:   http.StatusOK,
+			wantSQL:    "SELECT * FROM orders WHERE status = @filter_status ORDER BY id DESC",
+			wantParams: map[string]any{"filter_status": "x' OR 1=1 --"},
+		},
+		{name: "unknown sort column", query: "?sort=total", wantCode: http.StatusBadRequest, wantError: "unknown sort column 'total' (sortable: created_at, id)"},
+		{name: "repeated sort column", query: "?sort=id,-id", wantCode: http.StatusBadRequest, wantError: "sort column 'id' is given more than once"},
+		{name: "unknown filter", query: "?filter[total]=1", wantCode: http.StatusBadRequest, wantError: "unknown filter 'total'"},
+		{name: "repeated filter", query: "?filter[status]=a&filter[status]=b", wantCode: http.StatusBadRequest, wantError: "filter 'status' can only be given once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSQL, gotParams = "", nil
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/orders"+tt.query, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantError != "" {
+				if !strings.Contains(rec.Body.String(), tt.wantError) {
+					t.Errorf("body = %s, want %q", rec.Body, tt.wantError)
+				}
+				return
+			}
+			if gotSQL != tt.wantSQL {
+				t.Errorf("SQL = %q, want %q", gotSQL, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(gotParams, tt.wantParams) {
+				t.Errorf("params = %v, want %v", gotParams, tt.wantParams)
+			}
+		})
+	}
+}
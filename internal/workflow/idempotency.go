@@ -0,0 +1,281 @@
+package workflow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"sql-proxy/internal/metrics"
+	"sql-proxy/internal/workflow/step"
+)
+
+// Idempotency defaults for triggers whose idempotency section leaves them unset
+const (
+	DefaultIdempotencyTable = "idempotency_keys"
+	DefaultIdempotencyTTL   = 24 * time.Hour
+)
+
+// IdempotencyHeader carries the client's key for a write request
+const IdempotencyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds keys so they fit the table's key column
+const maxIdempotencyKeyLength = 255
+
+// idempotencySweepInterval is how often a handler deletes expired keys
+const idempotencySweepInterval = time.Hour
+
+// idempotencyRecord is a stored key; statusCode is 0 while the first request runs
+type idempotencyRecord struct {
+	requestHash string
+	statusCode  int
+	headers     http.Header
+	body        []byte
+}
+
+// serveIdempotent executes the workflow once per Idempotency-Key. The key is
+// claimed with a pending row before the workflow runs, so a concurrent retry
+// gets 409 instead of running the write a second time. The response is then
+// stored and replayed to retries until the key expires. Failed executions and
+// 5xx responses release the key so the client can retry them. Requests
+// without a key only get here when the trigger requires one.
+func (h *HTTPHandler) serveIdempotent(w http.ResponseWriter, r *http.Request, triggerData *TriggerData, requestID string) {
+	cfg := h.trigger.Config.Idempotency
+	key := r.Header.Get(IdempotencyHeader)
+	if key == "" {
+		h.writeError(w, http.StatusBadRequest, CodeIdempotencyRequired, "Idempotency-Key header is required", requestID)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		h.writeError(w, http.StatusBadRequest, CodeIdempotencyInvalid, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength), requestID)
+		return
+	}
+
+	ctx := r.Context()
+	table := h.idempotencyTable()
+	hash := idempotencyRequestHash(r, triggerData.Params)
+	ids := map[string]any{"workflow": h.workflow.Config.Name, "key": key}
+	h.sweepIdempotencyKeys(r, table)
+
+	rec, err := h.lookupIdempotencyKey(r, table, ids)
+	if err == nil && rec == nil {
+		// Claim the key. A failed insert means another request claimed it first.
+		now := time.Now().UTC()
+		if _, err = h.executor.dbManager.ExecuteQuery(ctx, cfg.Database, idempotencyDeleteExpiredSQL(table), withParams(ids, "now", now), step.QueryOptions{}); err == nil {
+			claim := withParams(ids, "request_hash", hash)
+			claim["now"] = now
+			claim["expires_at"] = now.Add(h.idempotencyLockTTL())
+			if _, insertErr := h.executor.dbManager.ExecuteQuery(ctx, cfg.Database, idempotencyInsertSQL(table), claim, step.QueryOptions{}); insertErr != nil {
+				if rec, err = h.lookupIdempotencyKey(r, table, ids); err == nil && rec == nil {
+					err = insertErr
+				}
+			}
+		}
+	}
+	if err != nil {
+		h.logIdempotencyError("idempotency_store_failed", requestID, err)
+		h.writeError(w, http.StatusInternalServerError, CodeInternal, "idempotency check failed", requestID)
+		return
+	}
+
+	if rec != nil {
+		switch {
+		case rec.requestHash != hash:
+			h.writeError(w, http.StatusUnprocessableEntity, CodeIdempotencyReused, "Idempotency-Key was already used with a different request", requestID)
+		case rec.statusCode == 0:
+			w.Header().Set("Retry-After", "1")
+			h.writeError(w, http.StatusConflict, CodeIdempotencyPending, "a request with this Idempotency-Key is still in progress", requestID)
+		default:
+			for name, values := range rec.headers {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(rec.statusCode)
+			_, _ = w.Write(rec.body)
+		}
+		return
+	}
+
+	capture := &responseCapture{ResponseWriter: &discardResponseWriter{header: make(http.Header)}}
+	result := h.executor.Execute(ctx, h.workflow, triggerData, requestID, capture, h.variables)
+	if acc := metrics.GetAccumulator(ctx); acc != nil {
+		h.populateMetrics(acc, result)
+	}
+	h.writeDefaultResponse(capture, result, requestID)
+	statusCode := capture.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	// Use a context that outlives a disconnected client so the key is never left claimed
+	storeCtx, cancel := h.detachedContext(ctx)
+	defer cancel()
+	if result.Error != nil || statusCode >= 500 {
+		if _, err := h.executor.dbManager.ExecuteQuery(storeCtx, cfg.Database, idempotencyReleaseSQL(table), ids, step.QueryOptions{}); err != nil {
+			h.logIdempotencyError("idempotency_release_failed", requestID, err)
+		}
+	} else {
+		headers, _ := json.Marshal(capture.Header())
+		params := withParams(ids, "status_code", statusCode)
+		params["headers"] = string(headers)
+		params["body"] = capture.body.String()
+		params["expires_at"] = time.Now().UTC().Add(h.idempotencyTTL())
+		if _, err := h.executor.dbManager.ExecuteQuery(storeCtx, cfg.Database, idempotencyCompleteSQL(table), params, step.QueryOptions{}); err != nil {
+			h.logIdempotencyError("idempotency_store_failed", requestID, err)
+		}
+	}
+
+	for name, values := range capture.Header() {
+		w.Header()[name] = slices.Clone(values)
+	}
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(capture.body.Bytes())
+}
+
+// lookupIdempotencyKey returns the unexpired record for a key, or nil
+func (h *HTTPHandler) lookupIdempotencyKey(r *http.Request, table string, ids map[string]any) (*idempotencyRecord, error) {
+	result, err := h.executor.dbManager.ExecuteQuery(r.Context(), h.trigger.Config.Idempotency.Database, idempotencySelectSQL(table),
+		withParams(ids, "now", time.Now().UTC()), step.QueryOptions{})
+	if err != nil || len(result.Rows) == 0 {
+		return nil, err
+	}
+	row := result.Rows[0]
+	rec := &idempotencyRecord{requestHash: columnText(row["request_hash"]), body: []byte(columnText(row["body"]))}
+	if row["status_code"] != nil {
+		if rec.statusCode, err = strconv.Atoi(columnText(row["status_code"])); err != nil {
+			return nil, fmt.Errorf("invalid stored status code: %w", err)
+		}
+	}
+	if headers := columnText(row["headers"]); headers != "" {
+		if err := json.Unmarshal([]byte(headers), &rec.headers); err != nil {
+			return nil, fmt.Errorf("invalid stored headers: %w", err)
+		}
+	}
+	return rec, nil
+}
+
+// sweepIdempotencyKeys deletes expired keys at most once per idempotencySweepInterval
+func (h *HTTPHandler) sweepIdempotencyKeys(r *http.Request, table string) {
+	h.idempotencyMu.Lock()
+	if time.Since(h.idempotencySwept) < idempotencySweepInterval {
+		h.idempotencyMu.Unlock()
+		return
+	}
+	h.idempotencySwept = time.Now()
+	h.idempotencyMu.Unlock()
+
+	if _, err := h.executor.dbManager.ExecuteQuery(r.Context(), h.trigger.Config.Idempotency.Database, idempotencySweepSQL(table),
+		map[string]any{"now": time.Now().UTC()}, step.QueryOptions{}); err != nil {
+		h.logIdempotencyError("idempotency_sweep_failed", "", err)
+	}
+}
+
+func (h *HTTPHandler) idempotencyTable() string {
+	if table := h.trigger.Config.Idempotency.Table; table != "" {
+		return table
+	}
+	return DefaultIdempotencyTable
+}
+
+func (h *HTTPHandler) idempotencyTTL() time.Duration {
+	if ttl := h.trigger.Config.Idempotency.TTLSec; ttl > 0 {
+		return time.Duration(ttl) * time.Second
+	}
+	return DefaultIdempotencyTTL
+}
+
+// idempotencyLockTTL is how long a claimed key stays pending before a crashed
+// execution stops blocking retries
+func (h *HTTPHandler) idempotencyLockTTL() time.Duration {
+	if h.workflow.Config.TimeoutSec > 0 {
+		return time.Duration(h.workflow.Config.TimeoutSec) * time.Second
+	}
+	return detachedExecutionTimeout
+}
+
+func (h *HTTPHandler) logIdempotencyError(event, requestID string, err error) {
+	if h.executor.Logger() == nil {
+		return
+	}
+	fields := map[string]any{
+		"workflow": h.workflow.Config.Name,
+		"error":    err.Error(),
+	}
+	if requestID != "" {
+		fields["request_id"] = requestID
+	}
+	h.executor.Logger().Error(event, fields)
+}
+
+// idempotencyRequestHash fingerprints a request so a key reused for a
+// different request is rejected instead of replaying the wrong response
+func idempotencyRequestHash(r *http.Request, params map[string]any) string {
+	encoded, _ := json.Marshal(params)
+	sum := sha256.Sum256([]byte(r.Method + " " + r.URL.Path + "\n" + string(encoded)))
+	return hex.EncodeToString(sum[:])
+}
+
+// withParams copies base and adds one more parameter
+func withParams(base map[string]any, name string, value any) map[string]any {
+	params := make(map[string]any, len(base)+1)
+	for k, v := range base {
+		params[k] = v
+	}
+	params[name] = value
+	return params
+}
+
+// columnText returns a column value as a string, "" for NULL
+func columnText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// IdempotencyStatements returns the statements an idempotent trigger runs on
+// table, for checking them against the database's statement policy.
+func IdempotencyStatements(table string) []string {
+	return []string{
+		idempotencySelectSQL(table),
+		idempotencyDeleteExpiredSQL(table),
+		idempotencyInsertSQL(table),
+		idempotencyCompleteSQL(table),
+		idempotencyReleaseSQL(table),
+		idempotencySweepSQL(table),
+	}
+}
+
+func idempotencySelectSQL(table string) string {
+	return "SELECT request_hash, status_code, headers, body FROM " + table + " WHERE workflow = @workflow AND idempotency_key = @key AND expires_at > @now"
+}
+
+func idempotencyDeleteExpiredSQL(table string) string {
+	return "DELETE FROM " + table + " WHERE workflow = @workflow AND idempotency_key = @key AND expires_at <= @now"
+}
+
+func idempotencyInsertSQL(table string) string {
+	return "INSERT INTO " + table + " (workflow, idempotency_key, request_hash, created_at, expires_at) VALUES (@workflow, @key, @request_hash, @now, @expires_at)"
+}
+
+func idempotencyCompleteSQL(table string) string {
+	return "UPDATE " + table + " SET status_code = @status_code, headers = @headers, body = @body, expires_at = @expires_at WHERE workflow = @workflow AND idempotency_key = @key"
+}
+
+func idempotencyReleaseSQL(table string) string {
+	return "DELETE FROM " + table + " WHERE workflow = @workflow AND idempotency_key = @key AND status_code IS NULL"
+}
+
+func idempotencySweepSQL(table string) string {
+	return "DELETE FROM " + table + " WHERE expires_at <= @now"
+}
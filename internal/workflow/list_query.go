@@ -0,0 +1,106 @@
+package workflow
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Query parameters of sortable_columns and filterable_columns
+const (
+	SortParam   = "sort"   // ?sort=-created_at,name
+	FilterParam = "filter" // ?filter[status]=active
+)
+
+// FilterParamPrefix names the SQL parameters that carry filter values: filter[status] is bound as @filter_status
+const FilterParamPrefix = "filter_"
+
+// listQuery holds the SQL fragments built from ?sort= and ?filter[col]=. Only
+// configured column names reach the SQL; filter values are bound parameters.
+type listQuery struct {
+	orderBy string         // "ORDER BY created_at DESC, name ASC", or "" without a sort
+	where   string         // "status = @filter_status AND ...", or "1=1" without filters
+	params  map[string]any // Filter values by SQL parameter name
+}
+
+// parseListQuery builds the sort and filter fragments of an HTTP trigger with
+// sortable_columns or filterable_columns. Column names match
+// case-insensitively; unknown columns are errors.
+func parseListQuery(cfg *TriggerConfig, query url.Values) (*listQuery, error) {
+	q := &listQuery{where: "1=1", params: make(map[string]any)}
+
+	sortSpec := query.Get(SortParam)
+	if sortSpec == "" {
+		sortSpec = cfg.DefaultSort
+	}
+	if sortSpec != "" {
+		orderBy, err := parseSort(sortSpec, cfg.SortableColumns)
+		if err != nil {
+			return nil, err
+		}
+		q.orderBy = orderBy
+	}
+
+	filters := make(map[string]string)
+	for key, values := range query {
+		if !strings.HasPrefix(key, FilterParam+"[") || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		name := key[len(FilterParam)+1 : len(key)-1]
+		col, ok := findColumn(cfg.FilterableColumns, name)
+		if !ok {
+			return nil, fmt.Errorf("unknown filter '%s' (filterable: %s)", name, strings.Join(cfg.FilterableColumns, ", "))
+		}
+		if _, dup := filters[col]; dup || len(values) > 1 {
+			return nil, fmt.Errorf("filter '%s' can only be given once", name)
+		}
+		filters[col] = values[0]
+	}
+	var conds []string
+	for _, col := range cfg.FilterableColumns {
+		if value, ok := filters[col]; ok {
+			conds = append(conds, col+" = @"+FilterParamPrefix+col)
+			q.params[FilterParamPrefix+col] = value
+		}
+	}
+	if len(conds) > 0 {
+		q.where = strings.Join(conds, " AND ")
+	}
+	return q, nil
+}
+
+// parseSort turns "-created_at,name" into "ORDER BY created_at DESC, name ASC"
+func parseSort(spec string, sortable []string) (string, error) {
+	var terms []string
+	var seen []string
+	for _, term := range strings.Split(spec, ",") {
+		term = strings.TrimSpace(term)
+		dir := "ASC"
+		switch {
+		case strings.HasPrefix(term, "-"):
+			term, dir = term[1:], "DESC"
+		case strings.HasPrefix(term, "+"):
+			term = term[1:]
+		}
+		col, ok := findColumn(sortable, term)
+		if !ok {
+			return "", fmt.Errorf("unknown sort column '%s' (sortable: %s)", term, strings.Join(sortable, ", "))
+		}
+		if slices.Contains(seen, col) {
+			return "", fmt.Errorf("sort column '%s' is given more than once", col)
+		}
+		seen = append(seen, col)
+		terms = append(terms, col+" "+dir)
+	}
+	return "ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+// findColumn returns the configured spelling of a column name
+func findColumn(columns []string, name string) (string, bool) {
+	i := slices.IndexFunc(columns, func(c string) bool { return strings.EqualFold(c, name) })
+	if i < 0 {
+		return "", false
+	}
+	return columns[i], true
+}
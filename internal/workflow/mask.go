@@ -0,0 +1,55 @@
+package workflow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Mask strategies for mask_columns
+const (
+	MaskFull    = "full"    // Replace the whole value
+	MaskPartial = "partial" // Keep the first and last characters, or an email's first character and domain
+	MaskHash    = "hash"    // Replace with a stable hash, so masked values can still be compared and grouped
+	MaskLast4   = "last4"   // Keep the last four characters
+)
+
+// maskFill replaces the hidden part of a masked value
+const maskFill = "****"
+
+// maskColumns masks the mapped columns of rows in place. NULLs stay NULL.
+func maskColumns(rows []map[string]any, columns map[string]string) {
+	for _, row := range rows {
+		for col, strategy := range columns {
+			if val, ok := row[col]; ok && val != nil {
+				row[col] = maskValue(strategy, columnText(val))
+			}
+		}
+	}
+}
+
+// maskValue applies a mask strategy to a value
+func maskValue(strategy, s string) string {
+	r := []rune(s)
+	switch strategy {
+	case MaskPartial:
+		if at := strings.LastIndex(s, "@"); at > 0 {
+			local := []rune(s[:at])
+			return string(local[0]) + "***" + s[at:]
+		}
+		if len(r) < 4 {
+			return maskFill
+		}
+		return string(r[0]) + "***" + string(r[len(r)-1])
+	case MaskHash:
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:8])
+	case MaskLast4:
+		if len(r) <= 4 {
+			return maskFill
+		}
+		return maskFill + string(r[len(r)-4:])
+	default:
+		return maskFill
+	}
+}
@@ -0,0 +1,85 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"sql-proxy/internal/mqtt"
+)
+
+// MQTTHandler runs a workflow for the messages on an mqtt trigger's topics.
+// The server owns the broker connections and passes each message to the
+// handlers whose filters match its topic.
+type MQTTHandler struct {
+	executor  *Executor
+	workflow  *CompiledWorkflow
+	trigger   *CompiledTrigger
+	variables map[string]string
+}
+
+// NewMQTTHandler creates a handler for a workflow mqtt trigger.
+func NewMQTTHandler(executor *Executor, wf *CompiledWorkflow, trigger *CompiledTrigger, variables map[string]string) *MQTTHandler {
+	return &MQTTHandler{
+		executor:  executor,
+		workflow:  wf,
+		trigger:   trigger,
+		variables: variables,
+	}
+}
+
+// Matches reports whether one of the trigger's topic filters matches topic.
+func (h *MQTTHandler) Matches(topic string) bool {
+	for _, filter := range h.trigger.Config.Topics {
+		if mqtt.Match(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleMessage runs the workflow for one message. Failures are logged: MQTT
+// has no way to reject a message, so it is acknowledged either way.
+func (h *MQTTHandler) HandleMessage(ctx context.Context, msg mqtt.Message) {
+	params := map[string]any{
+		"topic":        msg.Topic,
+		"topic_levels": strings.Split(msg.Topic, "/"),
+		"payload":      string(msg.Payload),
+		"qos":          int(msg.QoS),
+		"retained":     msg.Retained,
+		"data":         nil,
+	}
+	// JSON payloads are also available parsed
+	var data any
+	if json.Unmarshal(msg.Payload, &data) == nil {
+		params["data"] = data
+	}
+	triggerData := &TriggerData{
+		Type:         TriggerTypeMQTT,
+		Lang:         DefaultLanguage(),
+		Priority:     h.trigger.Config.Priority,
+		Params:       params,
+		ScheduleTime: time.Now(),
+	}
+
+	if h.workflow.Config.TimeoutSec > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(h.workflow.Config.TimeoutSec)*time.Second)
+		defer cancel()
+	}
+	requestID := "mqtt-" + generateRequestID()
+	exec := h.executor.Execute(ctx, h.workflow, triggerData, requestID, nil, h.variables)
+	fields := map[string]any{
+		"workflow":    h.workflow.Config.Name,
+		"request_id":  requestID,
+		"topic":       msg.Topic,
+		"duration_ms": exec.DurationMs,
+	}
+	if exec.Error != nil {
+		fields["error"] = exec.Error.Error()
+		h.executor.Logger().Error("mqtt_message_failed", fields)
+		return
+	}
+	h.executor.Logger().Debug("mqtt_message_completed", fields)
+}
@@ -0,0 +1,151 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"sql-proxy/internal/mqtt"
+	"sql-proxy/internal/workflow/step"
+)
+
+// TestMQTTHandler_HandleMessage tests topic matching and that a message's topic levels, payload and JSON data reach the workflow
+func TestMQTTHandler_HandleMessage(t *testing.T) {
+	var inserted []map[string]any
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			inserted = append(inserted, params)
+			return &step.QueryResult{}, nil
+		},
+	}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:     "readings",
+		Triggers: []TriggerConfig{{Type: "mqtt", Broker: "plant", Topics: []string{"factory/+/temperature", "factory/alarms/#"}}},
+		Steps: []StepConfig{{
+			Name: "store", Type: "query", Database: "db",
+			SQL: "INSERT INTO readings (line, celsius, raw) VALUES (@line, @celsius, @payload)",
+			Params: map[string]string{
+				"line":    "{{index .trigger.params.topic_levels 1}}",
+				"celsius": "{{.trigger.params.data.c}}",
+				"payload": "{{.trigger.params.payload}}",
+			},
+		}},
+	})
+	h := NewMQTTHandler(exec, wf, wf.Triggers[0], nil)
+
+	for topic, want := range map[string]bool{
+		"factory/line-1/temperature": true,
+		"factory/alarms/line-1/jam":  true,
+		"factory/line-1/pressure":    false,
+	} {
+		if got := h.Matches(topic); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", topic, got, want)
+		}
+	}
+
+	h.HandleMessage(context.Background(), mqtt.Message{Topic: "factory/line-1/temperature", Payload: []byte(`{"c": 21.5}`), QoS: 1})
+	if len(inserted) != 1 {
+		t.Fatalf("executions = %d, want 1", len(inserted))
+	}
+	if got := fmt.Sprint(inserted[0]["line"], " ", inserted[0]["celsius"], " ", inserted[0]["payload"]); got != `line-1 21.5 {"c": 21.5}` {
+		t.Errorf("params = %s", got)
+	}
+}
+
+// mockPublisher implements step.MessagePublisher for testing.
+type mockPublisher struct {
+	err      error
+	topics   []string
+	payloads []string
+	qos      []byte
+	retain   []bool
+}
+
+func (m *mockPublisher) Publish(ctx context.Context, broker, topic string, payload []byte, qos byte, retain bool) error {
+	if m.err != nil {
+		return m.err
+	}
+	if broker != "plant" {
+		return fmt.Errorf("unknown mqtt broker %q", broker)
+	}
+	m.topics = append(m.topics, topic)
+	m.payloads = append(m.payloads, string(payload))
+	m.qos = append(m.qos, qos)
+	m.retain = append(m.retain, retain)
+	return nil
+}
+
+// TestExecutor_Execute_MQTTStep tests publishing serialized rows and a rendered body with their QoS and retain flags
+func TestExecutor_Execute_MQTTStep(t *testing.T) {
+	db := &mockDBManager{
+		queryFunc: func(ctx context.Context, database, sql string, params map[string]any, opts step.QueryOptions) (*step.QueryResult, error) {
+			return &step.QueryResult{Rows: []map[string]any{{"line": "line-1", "setpoint": 21}}}, nil
+		},
+	}
+	publisher := &mockPublisher{}
+	exec := NewExecutor(db, &mockHTTPClient{}, nil, &testLogger{})
+	exec.SetMessagePublisher(publisher)
+
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "setpoints",
+		Steps: []StepConfig{
+			{Name: "rows", Type: "query", Database: "db", SQL: "SELECT line, setpoint FROM setpoints"},
+			{Name: "send", Type: "mqtt", Broker: "plant", Topic: "factory/{{.trigger.params.line}}/setpoint", Source: "steps.rows.data", QoS: 1, Retain: true},
+			{Name: "log", Type: "mqtt", Broker: "plant", Topic: "audit", Body: "{{.steps.send.topic}} {{.steps.send.size}}"},
+		},
+	})
+
+	trigger := &TriggerData{Type: "cron", Params: map[string]any{"line": "line-1"}}
+	result := exec.Execute(context.Background(), wf, trigger, "req-1", nil, nil)
+
+	if !result.Success {
+		t.Fatalf("Success = false: %v", result.Error)
+	}
+	if fmt.Sprint(publisher.topics) != "[factory/line-1/setpoint audit]" {
+		t.Errorf("topics = %v", publisher.topics)
+	}
+	if want := `[{"line":"line-1","setpoint":21}]`; publisher.payloads[0] != want {
+		t.Errorf("payload = %q, want %q", publisher.payloads[0], want)
+	}
+	if publisher.qos[0] != 1 || !publisher.retain[0] || publisher.retain[1] {
+		t.Errorf("qos = %v, retain = %v", publisher.qos, publisher.retain)
+	}
+	if want := "factory/line-1/setpoint 33"; publisher.payloads[1] != want {
+		t.Errorf("log = %q, want %q", publisher.payloads[1], want)
+	}
+}
+
+// TestExecutor_Execute_MQTTStep_Errors tests a missing publisher, a failed publish and a wildcard in the rendered topic
+func TestExecutor_Execute_MQTTStep_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		publisher step.MessagePublisher
+		topic     string
+		wantErr   string
+	}{
+		{"not configured", nil, "status", "requires mqtt brokers to be configured"},
+		{"publish fails", &mockPublisher{err: mqtt.ErrNotConnected}, "status", mqtt.ErrNotConnected.Error()},
+		{"wildcard in rendered topic", &mockPublisher{}, "status/{{.trigger.params.id}}", "wildcards are not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+			if tt.publisher != nil {
+				exec.SetMessagePublisher(tt.publisher)
+			}
+			wf := mustCompile(t, &WorkflowConfig{
+				Name:  "status",
+				Steps: []StepConfig{{Name: "send", Type: "mqtt", Broker: "plant", Topic: tt.topic, Body: "up"}},
+			})
+
+			trigger := &TriggerData{Type: "cron", Params: map[string]any{"id": "#"}}
+			result := exec.Execute(context.Background(), wf, trigger, "req-1", nil, nil)
+
+			if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr) {
+				t.Errorf("Error = %v, want %q", result.Error, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/expr-lang/expr/vm"
+
+	"sql-proxy/internal/decimal"
+	"sql-proxy/internal/types"
+)
+
+// CompiledParamRules holds a parameter's validation rules with the pattern and expression compiled.
+type CompiledParamRules struct {
+	Param   *ParamConfig
+	Pattern *regexp.Regexp
+	Enum    map[string]bool // Canonical values (see paramValueString)
+	Expr    *vm.Program
+}
+
+// ParamViolation is one failed validation rule, returned to the client in a 400 response.
+// Parameter rules set Parameter; body schema violations set Path (a JSON pointer) instead.
+type ParamViolation struct {
+	Parameter string `json:"parameter,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Rule      string `json:"rule"` // min, max, min_length, max_length, pattern, enum, expr, or a JSON Schema keyword
+	Message   string `json:"message"`
+}
+
+// compileParamRules compiles the validation rules of a trigger's parameters.
+func compileParamRules(params []ParamConfig) ([]*CompiledParamRules, error) {
+	var compiled []*CompiledParamRules
+	for i := range params {
+		p := &params[i]
+		v := p.Validation
+		if v == nil {
+			continue
+		}
+		rules := &CompiledParamRules{Param: p}
+		if v.Pattern != "" {
+			re, err := regexp.Compile(v.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("parameters[%s].validation.pattern: %w", p.Name, err)
+			}
+			rules.Pattern = re
+		}
+		if len(v.Enum) > 0 {
+			rules.Enum = make(map[string]bool, len(v.Enum))
+			baseType := types.ArrayBaseType(paramType(p))
+			for _, allowed := range v.Enum {
+				value, err := types.ConvertValue(allowed, baseType)
+				if err != nil {
+					return nil, fmt.Errorf("parameters[%s].validation.enum: invalid value %q: %w", p.Name, allowed, err)
+				}
+				rules.Enum[paramValueString(value)] = true
+			}
+		}
+		if v.Expr != "" {
+			prog, err := compileExprWithType(v.Expr, true)
+			if err != nil {
+				return nil, fmt.Errorf("parameters[%s].validation.expr: %w", p.Name, err)
+			}
+			rules.Expr = prog
+		}
+		compiled = append(compiled, rules)
+	}
+	return compiled, nil
+}
+
+// checkParams evaluates validation rules against parsed parameters and returns every violation.
+// Optional string parameters that were not supplied (empty) are not checked.
+func checkParams(rules []*CompiledParamRules, params map[string]any) []ParamViolation {
+	var violations []ParamViolation
+	for _, rule := range rules {
+		p := rule.Param
+		value, ok := params[p.Name]
+		if !ok || value == nil {
+			continue
+		}
+		if s, isString := value.(string); isString && s == "" && !p.Required {
+			continue
+		}
+
+		add := func(ruleName, format string, args ...any) {
+			violations = append(violations, ParamViolation{Parameter: p.Name, Rule: ruleName, Message: fmt.Sprintf(format, args...)})
+		}
+		v := p.Validation
+		typ := paramType(p)
+
+		if types.IsArrayType(typ) {
+			// Array values arrive as JSON strings; decode them to check the elements
+			var elems []any
+			if s, isString := value.(string); isString && json.Unmarshal([]byte(s), &elems) == nil {
+				value = elems
+			}
+			if v.MinLength != nil && len(elems) < *v.MinLength {
+				add("min_length", "must have at least %d elements", *v.MinLength)
+			}
+			if v.MaxLength != nil && len(elems) > *v.MaxLength {
+				add("max_length", "must have at most %d elements", *v.MaxLength)
+			}
+			for i, elem := range elems {
+				checkParamValue(rule, elem, fmt.Sprintf("element %d ", i), add)
+			}
+		} else {
+			if s, isString := value.(string); isString && typ != "json" {
+				n := utf8.RuneCountInString(s)
+				if v.MinLength != nil && n < *v.MinLength {
+					add("min_length", "must be at least %d characters", *v.MinLength)
+				}
+				if v.MaxLength != nil && n > *v.MaxLength {
+					add("max_length", "must be at most %d characters", *v.MaxLength)
+				}
+			}
+			checkParamValue(rule, value, "", add)
+		}
+
+		if rule.Expr != nil {
+			passed, err := EvalCondition(rule.Expr, map[string]any{"value": value, "params": params})
+			switch {
+			case err != nil:
+				add("expr", "validation expression failed: %v", err)
+			case !passed && v.Message != "":
+				add("expr", "%s", v.Message)
+			case !passed:
+				add("expr", "must satisfy %s", v.Expr)
+			}
+		}
+	}
+	return violations
+}
+
+// checkParamValue applies the per-value rules (min, max, pattern, enum) to a scalar value.
+func checkParamValue(rule *CompiledParamRules, value any, label string, add func(rule, format string, args ...any)) {
+	v := rule.Param.Validation
+	if n, ok := paramNumber(value); ok {
+		if v.Min != nil && n < *v.Min {
+			add("min", "%smust be at least %s", label, formatParamNumber(*v.Min))
+		}
+		if v.Max != nil && n > *v.Max {
+			add("max", "%smust be at most %s", label, formatParamNumber(*v.Max))
+		}
+	} else if s, ok := value.(string); ok && paramType(rule.Param) == "decimal" {
+		// Decimal values are strings; compare them exactly
+		if d, err := decimal.Parse(s); err == nil {
+			if v.Min != nil && compareDecimal(d, *v.Min) < 0 {
+				add("min", "%smust be at least %s", label, formatParamNumber(*v.Min))
+			}
+			if v.Max != nil && compareDecimal(d, *v.Max) > 0 {
+				add("max", "%smust be at most %s", label, formatParamNumber(*v.Max))
+			}
+		}
+	}
+	if s, ok := value.(string); ok && rule.Pattern != nil && !rule.Pattern.MatchString(s) {
+		add("pattern", "%smust match pattern %s", label, v.Pattern)
+	}
+	if rule.Enum != nil && !rule.Enum[paramValueString(value)] {
+		add("enum", "%smust be one of: %s", label, strings.Join(v.Enum, ", "))
+	}
+}
+
+// paramType returns the lower-cased parameter type, defaulting to string.
+func paramType(p *ParamConfig) string {
+	if p.Type == "" {
+		return "string"
+	}
+	return strings.ToLower(p.Type)
+}
+
+func paramNumber(value any) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// compareDecimal compares d with a min or max bound from the config
+func compareDecimal(d decimal.Decimal, bound float64) int {
+	b, err := decimal.FromFloat(bound)
+	if err != nil {
+		return 0
+	}
+	return d.Cmp(b)
+}
+
+func formatParamNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// paramValueString returns the canonical string form used to compare a value with enum entries,
+// so that 1, 1.0 and "1" from the config all match the parsed value 1.
+func paramValueString(value any) string {
+	switch val := value.(type) {
+	case string:
+		return val
+	case int:
+		return strconv.Itoa(val)
+	case float64:
+		return formatParamNumber(val)
+	case bool:
+		return strconv.FormatBool(val)
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,101 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckParams(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	n := func(v int) *int { return &v }
+
+	tests := []struct {
+		name   string
+		param  ParamConfig
+		value  any
+		params map[string]any
+		want   []string // "rule: message"
+	}{
+		{"min ok", ParamConfig{Name: "p", Type: "int", Validation: &ParamValidation{Min: f(1)}}, 1, nil, nil},
+		{"min", ParamConfig{Name: "p", Type: "int", Validation: &ParamValidation{Min: f(1)}}, 0, nil, []string{"min: must be at least 1"}},
+		{"max float", ParamConfig{Name: "p", Type: "float", Validation: &ParamValidation{Max: f(9.5)}}, 9.75, nil, []string{"max: must be at most 9.5"}},
+		{"min decimal", ParamConfig{Name: "p", Type: "decimal", Validation: &ParamValidation{Min: f(0.01)}}, "0.0099999999999999999", nil, []string{"min: must be at least 0.01"}},
+		{"max decimal ok", ParamConfig{Name: "p", Type: "decimal", Validation: &ParamValidation{Max: f(0.1)}}, "0.1000", nil, nil},
+		{"min_length runes", ParamConfig{Name: "p", Validation: &ParamValidation{MinLength: n(3)}}, "日本", nil, []string{"min_length: must be at least 3 characters"}},
+		{"max_length", ParamConfig{Name: "p", Type: "string", Validation: &ParamValidation{MaxLength: n(2)}}, "abc", nil, []string{"max_length: must be at most 2 characters"}},
+		{"pattern", ParamConfig{Name: "p", Type: "string", Validation: &ParamValidation{Pattern: `^\d{4}$`}}, "12a4", nil, []string{`pattern: must match pattern ^\d{4}$`}},
+		{"enum int", ParamConfig{Name: "p", Type: "int", Validation: &ParamValidation{Enum: []string{"10", "20"}}}, 20, nil, nil},
+		{"enum miss", ParamConfig{Name: "p", Type: "string", Validation: &ParamValidation{Enum: []string{"asc", "desc"}}}, "up", nil, []string{"enum: must be one of: asc, desc"}},
+		{"optional empty skipped", ParamConfig{Name: "p", Type: "string", Validation: &ParamValidation{MinLength: n(3)}}, "", nil, nil},
+		{"required empty checked", ParamConfig{Name: "p", Type: "string", Required: true, Validation: &ParamValidation{Pattern: "."}}, "", nil, []string{"pattern: must match pattern ."}},
+		{
+			"array length and elements",
+			ParamConfig{Name: "p", Type: "int[]", Validation: &ParamValidation{MaxLength: n(2), Min: f(1), Enum: []string{"1", "2", "3"}}},
+			"[1,0,3]", nil,
+			[]string{"max_length: must have at most 2 elements", "min: element 1 must be at least 1", "enum: element 1 must be one of: 1, 2, 3"},
+		},
+		{
+			"string array pattern",
+			ParamConfig{Name: "p", Type: "string[]", Validation: &ParamValidation{Pattern: "^[a-z]+$"}},
+			`["ok","NO"]`, nil,
+			[]string{"pattern: element 1 must match pattern ^[a-z]+$"},
+		},
+		{
+			"expr with message",
+			ParamConfig{Name: "end", Type: "int", Validation: &ParamValidation{Expr: "value >= params.start", Message: "end must not be before start"}},
+			3, map[string]any{"start": 5},
+			[]string{"expr: end must not be before start"},
+		},
+		{
+			"expr default message",
+			ParamConfig{Name: "p", Type: "int[]", Validation: &ParamValidation{Expr: "len(value) % 2 == 0"}},
+			"[1,2,3]", nil,
+			[]string{"expr: must satisfy len(value) % 2 == 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := compileParamRules([]ParamConfig{tt.param})
+			if err != nil {
+				t.Fatalf("compileParamRules failed: %v", err)
+			}
+			params := map[string]any{tt.param.Name: tt.value}
+			for k, v := range tt.params {
+				params[k] = v
+			}
+
+			var got []string
+			for _, v := range checkParams(rules, params) {
+				if v.Parameter != tt.param.Name {
+					t.Errorf("violation parameter = %s, want %s", v.Parameter, tt.param.Name)
+				}
+				got = append(got, v.Rule+": "+v.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("violations =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCompileParamRules_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		param   ParamConfig
+		wantErr string
+	}{
+		{"bad pattern", ParamConfig{Name: "p", Validation: &ParamValidation{Pattern: "("}}, "parameters[p].validation.pattern"},
+		{"bad enum", ParamConfig{Name: "p", Type: "int", Validation: &ParamValidation{Enum: []string{"x"}}}, "parameters[p].validation.enum"},
+		{"bad expr", ParamConfig{Name: "p", Validation: &ParamValidation{Expr: "value >"}}, "parameters[p].validation.expr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileParamRules([]ParamConfig{tt.param})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("compileParamRules() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package workflow
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"maps"
+	"regexp"
+	"slices"
+	"text/template"
+	"text/template/parse"
+)
+
+// Partials are the named templates of the top-level templates: section. They are
+// parsed once, and response and SQL templates invoke them with {{template "name" .}}.
+type Partials struct {
+	defs map[string]string
+	text *template.Template
+	html *htmltemplate.Template
+}
+
+// CompilePartials parses the named templates. Partials may invoke each other.
+func CompilePartials(defs map[string]string) (*Partials, error) {
+	p := &Partials{
+		defs: defs,
+		text: template.New("").Funcs(TemplateFuncs),
+		html: htmltemplate.New("").Funcs(htmltemplate.FuncMap(TemplateFuncs)),
+	}
+	for _, name := range slices.Sorted(maps.Keys(defs)) {
+		if name == "" {
+			return nil, fmt.Errorf("template name cannot be empty")
+		}
+		if _, err := p.text.New(name).Parse(defs[name]); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if _, err := p.html.New(name).Parse(defs[name]); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(defs)) {
+		if err := checkTemplateRefs(p.text.Lookup(name).Tree, func(name string) bool { return p.text.Lookup(name) != nil }); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return p, nil
+}
+
+// parseText parses a text template that may invoke the partials
+func (p *Partials) parseText(name, text string) (*template.Template, error) {
+	set := template.New(name).Funcs(TemplateFuncs)
+	if p != nil {
+		clone, err := p.text.Clone()
+		if err != nil {
+			return nil, err
+		}
+		set = clone.New(name)
+	}
+	tmpl, err := set.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTemplateRefs(tmpl.Tree, func(name string) bool { return tmpl.Lookup(name) != nil }); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// parseHTML parses an html/template that may invoke the partials, which are then
+// escaped for the context they are invoked in
+func (p *Partials) parseHTML(name, text string) (*htmltemplate.Template, error) {
+	set := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(TemplateFuncs))
+	if p != nil {
+		clone, err := p.html.Clone()
+		if err != nil {
+			return nil, err
+		}
+		set = clone.New(name)
+	}
+	tmpl, err := set.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTemplateRefs(tmpl.Tree, func(name string) bool { return tmpl.Lookup(name) != nil }); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+var partialCallRegex = regexp.MustCompile(`\{\{-?\s*template\s+"([^"]+)"[^}]*\}\}`)
+
+// expandText replaces {{template "name" ...}} calls with the partials' source, so
+// statement type detection sees the SQL a partial contributes
+func (p *Partials) expandText(text string) string {
+	if p == nil {
+		return text
+	}
+	for range 10 { // Partials may invoke partials; cycles stop here
+		expanded := partialCallRegex.ReplaceAllStringFunc(text, func(call string) string {
+			return p.defs[partialCallRegex.FindStringSubmatch(call)[1]]
+		})
+		if expanded == text {
+			break
+		}
+		text = expanded
+	}
+	return text
+}
+
+// checkTemplateRefs reports {{template}} calls naming templates that are not defined.
+// text/template only notices them when the call executes.
+func checkTemplateRefs(tree *parse.Tree, defined func(string) bool) error {
+	if tree == nil {
+		return nil
+	}
+	var missing string
+	var walk func(parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.IfNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			if missing == "" && !defined(n.Name) {
+				missing = n.Name
+			}
+		}
+	}
+	walk(tree.Root)
+	if missing != "" {
+		return fmt.Errorf("template %q not defined in templates", missing)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package workflow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"sql-proxy/internal/metrics"
+)
+
+// Long-polling defaults for triggers whose poll section leaves them unset
+const (
+	DefaultPollInterval    = time.Second
+	DefaultPollTimeout     = 30 * time.Second
+	DefaultPollCursorParam = "cursor"
+)
+
+// pollWriteSlack is added to the poll timeout for the response's write deadline
+const pollWriteSlack = 10 * time.Second
+
+// servePoll runs the workflow until its response differs from the client's
+// cursor, then sends it with the new cursor as its ETag. When nothing changes
+// before the timeout the client gets 304 Not Modified. Responses other than
+// 200 are sent right away: a failing workflow is not polled.
+func (h *HTTPHandler) servePoll(w http.ResponseWriter, r *http.Request, triggerData *TriggerData, requestID string) {
+	cfg := h.trigger.Config.Poll
+	interval := DefaultPollInterval
+	if cfg.IntervalMs > 0 {
+		interval = time.Duration(cfg.IntervalMs) * time.Millisecond
+	}
+	timeout := DefaultPollTimeout
+	if cfg.TimeoutSec > 0 {
+		timeout = time.Duration(cfg.TimeoutSec) * time.Second
+	}
+
+	cursor := pollCursor(r, cfg.CursorParam)
+
+	// The request may be held longer than the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + pollWriteSlack)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.writeError(w, http.StatusInternalServerError, CodeInternal, "poll setup failed", requestID)
+		return
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	executions := 0
+	for {
+		capture := &responseCapture{ResponseWriter: &discardResponseWriter{header: make(http.Header)}}
+		result := h.executor.Execute(r.Context(), h.workflow, triggerData, requestID, capture, h.variables)
+		h.writeDefaultResponse(capture, result, requestID)
+		executions++
+
+		statusCode := capture.statusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		etag := responseCursor(capture.body.Bytes())
+		if statusCode != http.StatusOK || etag != cursor {
+			if acc := metrics.GetAccumulator(r.Context()); acc != nil {
+				h.populateMetrics(acc, result)
+			}
+			for name, values := range capture.Header() {
+				w.Header()[name] = slices.Clone(values)
+			}
+			if statusCode == http.StatusOK {
+				w.Header().Set("ETag", `"`+etag+`"`)
+			}
+			w.WriteHeader(statusCode)
+			_, _ = w.Write(capture.body.Bytes())
+			h.logPoll(requestID, executions, true)
+			return
+		}
+
+		wait := time.NewTimer(interval)
+		select {
+		case <-wait.C:
+		case <-deadline.C:
+			wait.Stop()
+			w.Header().Del("Content-Type")
+			w.Header().Set("ETag", `"`+cursor+`"`)
+			w.WriteHeader(http.StatusNotModified)
+			h.logPoll(requestID, executions, false)
+			return
+		case <-r.Context().Done():
+			wait.Stop()
+			return
+		}
+	}
+}
+
+func (h *HTTPHandler) logPoll(requestID string, executions int, changed bool) {
+	if h.executor.Logger() == nil {
+		return
+	}
+	h.executor.Logger().Debug("trigger_poll_completed", map[string]any{
+		"workflow":   h.workflow.Config.Name,
+		"request_id": requestID,
+		"executions": executions,
+		"changed":    changed,
+	})
+}
+
+// pollCursor returns the cursor the client sent in the query string or, failing
+// that, in If-None-Match
+func pollCursor(r *http.Request, param string) string {
+	if param == "" {
+		param = DefaultPollCursorParam
+	}
+	if cursor := r.URL.Query().Get(param); cursor != "" {
+		return cursor
+	}
+	etag := strings.TrimPrefix(strings.TrimSpace(r.Header.Get("If-None-Match")), "W/")
+	return strings.Trim(etag, `"`)
+}
+
+// responseCursor identifies a response body
+func responseCursor(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:16])
+}
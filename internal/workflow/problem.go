@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Values of error_format
+const (
+	ErrorFormatEnvelope    = "envelope"     // The response envelope (default)
+	ErrorFormatProblemJSON = "problem_json" // RFC 7807 application/problem+json documents
+)
+
+// ValidErrorFormats are the values error_format accepts
+var ValidErrorFormats = []string{ErrorFormatEnvelope, ErrorFormatProblemJSON}
+
+// ProblemContentType is the media type of problem_json error responses
+const ProblemContentType = "application/problem+json"
+
+// problemInstancePrefix makes a request ID the URI of a problem occurrence
+const problemInstancePrefix = "urn:request:"
+
+func (wf *WorkflowConfig) problemJSON() bool {
+	return wf.ErrorFormat == ErrorFormatProblemJSON
+}
+
+// errorBody renders an error response in the workflow's error format
+func (wf *WorkflowConfig) errorBody(status int, message, code, requestID string, extra ...envelopeField) []byte {
+	if wf.problemJSON() {
+		return problemBody(status, message, code, requestID, extra...)
+	}
+	return wf.Envelope.errorBody(message, code, requestID, extra...)
+}
+
+// writeErrorBody writes an error response in the workflow's error format
+func (wf *WorkflowConfig) writeErrorBody(w http.ResponseWriter, status int, message, code, requestID string, extra ...envelopeField) {
+	if wf.problemJSON() {
+		w.Header().Set("Content-Type", ProblemContentType)
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(wf.errorBody(status, message, code, requestID, extra...))
+}
+
+// problemBody renders an RFC 7807 problem document. The type is about:blank, so
+// the title is the status text; the error code and extra fields are extension members.
+func problemBody(status int, message, code, requestID string, extra ...envelopeField) []byte {
+	fields := problemFields(status, message, code)
+	if requestID != "" {
+		fields = append(fields, envelopeField{"instance", problemInstancePrefix + requestID})
+	}
+	return encodeEnvelope(append(fields, extra...))
+}
+
+// ProblemTemplate returns a response template with an RFC 7807 problem document,
+// for generated workflows. The instance is the request ID.
+func ProblemTemplate(status int, message, code string) string {
+	fields := problemFields(status, message, code)
+	fields = append(fields, envelopeField{"instance", json.RawMessage(`{{json (printf "` + problemInstancePrefix + `%s" .workflow.request_id)}}`)})
+	return envelopeTemplate(fields)
+}
+
+func problemFields(status int, message, code string) []envelopeField {
+	return []envelopeField{
+		{"type", "about:blank"},
+		{"title", http.StatusText(status)},
+		{"status", status},
+		{"detail", message},
+		{"code", code},
+	}
+}
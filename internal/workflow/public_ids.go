@@ -0,0 +1,106 @@
+package workflow
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+// decodePublicIDParams replaces the values of SQL parameters named like a public
+// ID column with the internal ids they encode. Nil values are left as NULL; any
+// other value must be a public ID of the column's namespace, so clients cannot
+// bypass the mapping by sending internal ids.
+func decodePublicIDParams(params map[string]any, columns map[string]string) error {
+	enc := getTemplateEncoder()
+	for name, ns := range columns {
+		val, ok := params[name]
+		if !ok || val == nil {
+			continue
+		}
+		id, err := decodePublicIDValue(enc, ns, name, val)
+		if err != nil {
+			return err
+		}
+		params[name] = id
+	}
+	return nil
+}
+
+// decodePublicIDProcParams decodes the in and inout parameters of a stored
+// procedure call like decodePublicIDParams
+func decodePublicIDProcParams(call *step.ProcCall, columns map[string]string) error {
+	enc := getTemplateEncoder()
+	for i, p := range call.Params {
+		ns, ok := columns[p.Name]
+		if !ok || p.Direction == "out" || p.Value == nil {
+			continue
+		}
+		id, err := decodePublicIDValue(enc, ns, p.Name, p.Value)
+		if err != nil {
+			return err
+		}
+		call.Params[i].Value = id
+	}
+	return nil
+}
+
+// decodePublicIDValue decodes one parameter value for decodePublicIDParams
+func decodePublicIDValue(enc PublicIDEncoder, ns, name string, val any) (int64, error) {
+	if enc == nil {
+		return 0, fmt.Errorf("public_id_columns: public_ids not configured")
+	}
+	s, ok := val.(string)
+	if !ok {
+		return 0, fmt.Errorf("parameter %s: expected a public ID, got %T", name, val)
+	}
+	id, err := enc.Decode(ns, s)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s: %w", name, err)
+	}
+	return id, nil
+}
+
+// encodePublicIDColumns replaces internal ids in the mapped columns of rows
+// with their public IDs, in place. NULLs stay NULL.
+func encodePublicIDColumns(rows []map[string]any, columns map[string]string) error {
+	enc := getTemplateEncoder()
+	if enc == nil {
+		return fmt.Errorf("public_id_columns: public_ids not configured")
+	}
+	for _, row := range rows {
+		for col, ns := range columns {
+			val, ok := row[col]
+			if !ok || val == nil {
+				continue
+			}
+			id, err := rowID(val)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", col, err)
+			}
+			publicID, err := enc.Encode(ns, id)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", col, err)
+			}
+			row[col] = publicID
+		}
+	}
+	return nil
+}
+
+// rowID converts a column value to an id. Drivers may return integers as
+// strings or bytes (MySQL) or as whole floats.
+func rowID(v any) (int64, error) {
+	switch n := v.(type) {
+	case []byte:
+		return strconv.ParseInt(string(n), 10, 64)
+	case string:
+		return strconv.ParseInt(n, 10, 64)
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+			return 0, fmt.Errorf("value %v is not an integer id", n)
+		}
+	}
+	return toInt64(v)
+}
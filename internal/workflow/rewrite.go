@@ -0,0 +1,73 @@
+package workflow
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// CompiledPathParam holds a rewrite path_params entry with its compiled pattern.
+type CompiledPathParam struct {
+	Config  *PathParamConfig
+	Pattern *regexp.Regexp
+	Group   int // Submatch holding the value
+}
+
+// compilePathParams compiles a trigger's rewrite path_params patterns
+func compilePathParams(cfgs []PathParamConfig) ([]*CompiledPathParam, error) {
+	var compiled []*CompiledPathParam
+	for i := range cfgs {
+		re, err := pathParamPattern(&cfgs[i])
+		if err != nil {
+			return nil, fmt.Errorf("rewrite.path_params[%d]: %w", i, err)
+		}
+		group := re.SubexpIndex(cfgs[i].Name)
+		if group < 0 {
+			group = 1
+		}
+		compiled = append(compiled, &CompiledPathParam{Config: &cfgs[i], Pattern: re, Group: group})
+	}
+	return compiled, nil
+}
+
+// pathParamPattern compiles a path_params pattern, which must capture the value in a group
+func pathParamPattern(cfg *PathParamConfig) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("pattern must capture the value in a group")
+	}
+	return re, nil
+}
+
+// rewriteRequest returns a copy of r with the trigger's rewrite rules applied
+func (h *HTTPHandler) rewriteRequest(r *http.Request) *http.Request {
+	rw := h.trigger.Config.Rewrite
+	r = r.Clone(r.Context())
+
+	for _, name := range rw.RemoveHeaders {
+		r.Header.Del(name)
+	}
+	for name, value := range rw.SetHeaders {
+		r.Header.Set(name, value)
+	}
+
+	if len(rw.DefaultQuery) > 0 {
+		query := r.URL.Query()
+		for name, value := range rw.DefaultQuery {
+			if !query.Has(name) {
+				query.Set(name, value)
+			}
+		}
+		r.URL.RawQuery = query.Encode()
+	}
+
+	for _, pp := range h.trigger.PathParams {
+		if m := pp.Pattern.FindStringSubmatch(r.URL.Path); m != nil && m[pp.Group] != "" {
+			r.SetPathValue(pp.Config.Name, m[pp.Group])
+		}
+	}
+	return r
+}
@@ -0,0 +1,122 @@
+package workflow
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPHandler_Rewrite(t *testing.T) {
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name: "rewrite",
+		Triggers: []TriggerConfig{{
+			Type:   "http",
+			Path:   "/products/",
+			Method: "GET",
+			Parameters: []ParamConfig{
+				{Name: "sku", Type: "string", Required: true},
+				{Name: "limit", Type: "int"},
+			},
+			Rewrite: &RewriteConfig{
+				RemoveHeaders: []string{"X-Debug"},
+				SetHeaders:    map[string]string{"X-Tenant": "acme"},
+				DefaultQuery:  map[string]string{"limit": "50"},
+				PathParams:    []PathParamConfig{{Name: "sku", Pattern: `^/products/(?P<sku>[A-Z]{3}-\d+)`}},
+			},
+		}},
+		Steps: []StepConfig{{
+			Name: "respond",
+			Type: "response",
+			Template: `{"sku": {{json .trigger.params.sku}}, "limit": {{.trigger.params.limit}}, ` +
+				`"tenant": {{json (getOr .trigger.headers "X-Tenant" "")}}, "debug": {{json (getOr .trigger.headers "X-Debug" "")}}}`,
+		}},
+	})
+	handler := NewHTTPHandler(exec, wf, wf.Triggers[0], nil, nil, false, "", "", nil)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		want       map[string]any
+	}{
+		{
+			name:       "defaults and extraction",
+			target:     "/products/ABC-12/reviews",
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"sku": "ABC-12", "limit": float64(50), "tenant": "acme", "debug": ""},
+		},
+		{
+			name:       "client query kept",
+			target:     "/products/XYZ-9?limit=5",
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"sku": "XYZ-9", "limit": float64(5), "tenant": "acme", "debug": ""},
+		},
+		{
+			name:       "path does not match",
+			target:     "/products/unknown",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req.Header.Set("X-Tenant", "spoofed")
+			req.Header.Set("X-Debug", "1")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if req.Header.Get("X-Tenant") != "spoofed" {
+				t.Error("expected the client's request to be left unchanged")
+			}
+			if tt.want == nil {
+				return
+			}
+			var got map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestCompilePathParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       PathParamConfig
+		wantGroup int
+		wantErr   string
+	}{
+		{name: "first group", cfg: PathParamConfig{Name: "id", Pattern: `^/v(\d+)/items/(\d+)`}, wantGroup: 1},
+		{name: "named group", cfg: PathParamConfig{Name: "id", Pattern: `^/v(\d+)/items/(?P<id>\d+)`}, wantGroup: 2},
+		{name: "no group", cfg: PathParamConfig{Name: "id", Pattern: `^/items/\d+`}, wantErr: "must capture the value in a group"},
+		{name: "invalid", cfg: PathParamConfig{Name: "id", Pattern: `(`}, wantErr: "invalid pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, err := compilePathParams([]PathParamConfig{tt.cfg})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if compiled[0].Group != tt.wantGroup {
+				t.Errorf("group = %d, want %d", compiled[0].Group, tt.wantGroup)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"sql-proxy/internal/jsonschema"
+)
+
+// CompileSchema compiles a schema declared inline (a YAML mapping) or as a path to a
+// JSON or YAML file. config.Load resolves relative paths against the config file.
+func CompileSchema(v any) (*jsonschema.Schema, error) {
+	path, isPath := v.(string)
+	if !isPath {
+		return jsonschema.Compile(v)
+	}
+	if path == "" {
+		return nil, errors.New("schema file path is empty")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+	// YAML is a superset of JSON, so one decoder handles both
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse schema file %s: %w", path, err)
+	}
+	schema, err := jsonschema.Compile(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return schema, nil
+}
+
+// checkBody validates a request's JSON body against a schema and restores the body so
+// parameter extraction can read it again. An error means the body is missing or not JSON.
+func checkBody(schema *jsonschema.Schema, r *http.Request) ([]ParamViolation, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return nil, errors.New("request body must be JSON (Content-Type: application/json)")
+	}
+	var data []byte
+	if r.Body != nil {
+		var err error
+		if data, err = io.ReadAll(r.Body); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("request body is required")
+	}
+
+	var body any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON body: %w", err)
+	}
+
+	var violations []ParamViolation
+	for _, e := range schema.Validate(body) {
+		violations = append(violations, ParamViolation{Path: e.Path, Rule: e.Keyword, Message: e.Message})
+	}
+	return violations, nil
+}
@@ -0,0 +1,71 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"sql-proxy/internal/workflow/step"
+)
+
+// Error classes of status_map
+const (
+	ErrorClassValidation          = "validation"                       // Bad parameters or request body
+	ErrorClassNotFound            = "not_found"                        // A lookup found nothing, or an httpcall got 404
+	ErrorClassDeadlock            = step.ErrorClassDeadlock            // Deadlock victim or lock conflict
+	ErrorClassTimeout             = step.ErrorClassTimeout             // A step, its query, or the workflow ran out of time
+	ErrorClassConstraintViolation = step.ErrorClassConstraintViolation // Unique, foreign key, check, or NOT NULL constraint
+)
+
+// ValidErrorClasses are the keys status_map accepts
+var ValidErrorClasses = []string{
+	ErrorClassValidation,
+	ErrorClassNotFound,
+	ErrorClassDeadlock,
+	ErrorClassTimeout,
+	ErrorClassConstraintViolation,
+}
+
+// HTTPStatusError is the error of an httpcall step that got a non-2xx response
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// errorClass returns the status_map class of a workflow error, or "" when it has none
+func errorClass(err error) string {
+	var classified *step.ClassifiedError
+	var httpErr *HTTPStatusError
+	switch {
+	case errors.As(err, &classified):
+		return classified.Class
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrorClassNotFound
+	case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound:
+		return ErrorClassNotFound
+	}
+	return ""
+}
+
+// mapStatus returns the status and message of an error response of class,
+// after the workflow's status_map
+func (wf *WorkflowConfig) mapStatus(class string, status int, message string) (int, string) {
+	m, ok := wf.StatusMap[class]
+	if !ok {
+		return status, message
+	}
+	if m.Status != 0 {
+		status = m.Status
+	}
+	if m.Message != "" {
+		message = m.Message
+	}
+	return status, message
+}
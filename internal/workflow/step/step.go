@@ -3,6 +3,7 @@ package step
 import (
 	"context"
 	"net/http"
+	"strings"
 )
 
 // ExecutionData provides template and context data needed for step execution.
@@ -21,6 +22,14 @@ type DBManager interface {
 type QueryResult struct {
 	Rows         []map[string]any
 	RowsAffected int64
+	ResultSets   [][]map[string]any // Every result set in order (Rows is the first)
+
+	// Stored procedure results
+	Outputs    map[string]any // Out and inout parameter values by name
+	ReturnCode *int64         // Procedure return status (SQL Server only)
+
+	// Truncated is set when reading stopped at QueryOptions.Limit with rows left unread
+	Truncated bool
 }
 
 // QueryOptions contains options for query execution.
@@ -34,6 +43,98 @@ type QueryOptions struct {
 	// When non-nil, drivers use these instead of re-parsing the SQL at request time.
 	IsWrite      *bool
 	HasReturning *bool
+
+	// Proc, when set, calls a stored procedure instead of executing the SQL.
+	Proc *ProcCall
+
+	// Tenant selects the tenant's connection on a tenant-routed database.
+	Tenant string
+
+	// Limit, when set, caps how much of the result is read into memory.
+	Limit *ResultLimit
+
+	// Outbox events are inserted in the same transaction as the statement.
+	Outbox []OutboxEvent
+
+	// Comment identifies the statement's origin, for databases with sql_comments.
+	Comment *QueryComment
+}
+
+// QueryComment identifies the workflow step that issued a statement.
+type QueryComment struct {
+	Workflow  string
+	Step      string
+	RequestID string
+}
+
+// String renders the comment as /* workflow=... step=... request_id=... */. Characters
+// other than letters, digits and _ . : - are replaced by _, so a client-supplied
+// request ID can't close the comment.
+func (c *QueryComment) String() string {
+	var b strings.Builder
+	b.WriteString("/*")
+	for _, kv := range [][2]string{{"workflow", c.Workflow}, {"step", c.Step}, {"request_id", c.RequestID}} {
+		if kv[1] == "" {
+			continue
+		}
+		b.WriteString(" " + kv[0] + "=")
+		for _, r := range kv[1] {
+			if r == '_' || r == '.' || r == ':' || r == '-' ||
+				'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+				b.WriteRune(r)
+			} else {
+				b.WriteByte('_')
+			}
+		}
+	}
+	b.WriteString(" */")
+	return b.String()
+}
+
+// OutboxEvent is an event recorded in a database's outbox table.
+type OutboxEvent struct {
+	ID      string // Idempotency key, also the row's primary key
+	Topic   string
+	Key     string
+	Payload string
+}
+
+// ResultLimit caps the rows a query reads, across all of its result sets.
+type ResultLimit struct {
+	MaxRows  int // Rows to keep (0 = unlimited)
+	MaxBytes int // Approximate JSON size of the kept rows (0 = unlimited)
+	Offset   int // Leading rows to skip before keeping any
+}
+
+// Classes of database errors, reported through ClassifiedError
+const (
+	ErrorClassDeadlock            = "deadlock"             // Deadlock victim or lock conflict; the statement can be retried
+	ErrorClassConstraintViolation = "constraint_violation" // Unique, foreign key, check, or NOT NULL constraint
+	ErrorClassTimeout             = "timeout"              // Query or lock wait ran out of time
+)
+
+// ClassifiedError marks a query error with its class. It reads and unwraps as the original error.
+type ClassifiedError struct {
+	Class string
+	Err   error
+}
+
+func (e *ClassifiedError) Error() string { return e.Err.Error() }
+
+func (e *ClassifiedError) Unwrap() error { return e.Err }
+
+// ProcCall is a stored procedure invocation.
+type ProcCall struct {
+	Name   string
+	Params []ProcParam // In declaration order
+}
+
+// ProcParam is a bound stored procedure parameter.
+type ProcParam struct {
+	Name      string
+	Direction string // "in" | "out" | "inout"
+	Type      string // Value type of out and inout parameters: "int" | "float" | "decimal" | "string" | "bool"
+	Value     any    // Input value (in and inout)
 }
 
 // HTTPClient interface for HTTP operations.
@@ -41,6 +142,53 @@ type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// Mailer sends email for email steps.
+type Mailer interface {
+	SendEmail(ctx context.Context, msg EmailMessage) error
+}
+
+// EmailMessage is a rendered email. Recipient entries may hold comma-separated addresses.
+type EmailMessage struct {
+	From    string // Empty uses the configured default sender
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// ObjectStore uploads objects for storage steps.
+type ObjectStore interface {
+	PutObject(ctx context.Context, store string, obj StorageObject) (*StoredObject, error)
+}
+
+// StorageObject is a rendered object to upload.
+type StorageObject struct {
+	Bucket      string
+	Key         string
+	Body        []byte
+	ContentType string
+	Encryption  string // "" | "AES256" | "aws:kms"
+	KMSKeyID    string
+}
+
+// StoredObject describes an uploaded object.
+type StoredObject struct {
+	URL  string
+	ETag string
+}
+
+// FileUploader uploads files for sftp steps.
+type FileUploader interface {
+	Upload(ctx context.Context, server, remotePath string, data []byte, createDirs bool) error
+}
+
+// MessagePublisher publishes messages for mqtt steps.
+type MessagePublisher interface {
+	Publish(ctx context.Context, broker, topic string, payload []byte, qos byte, retain bool) error
+}
+
 // Logger interface for step logging.
 type Logger interface {
 	Debug(msg string, fields map[string]any)
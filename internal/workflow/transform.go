@@ -0,0 +1,82 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// applyTransform post-processes query rows in a fixed order: parse_json, rename, exclude,
+// then group_by/nest_as. Rows are modified in place; grouping returns new parent rows.
+func applyTransform(t *TransformConfig, rows []map[string]any) ([]map[string]any, error) {
+	for _, row := range rows {
+		for _, col := range t.ParseJSON {
+			if err := parseJSONValue(row, col); err != nil {
+				return nil, err
+			}
+		}
+		for from, to := range t.Rename {
+			if val, ok := row[from]; ok {
+				delete(row, from)
+				row[to] = val
+			}
+		}
+		for _, col := range t.Exclude {
+			delete(row, col)
+		}
+	}
+
+	if t.GroupBy == "" {
+		return rows, nil
+	}
+	return groupRows(rows, t.GroupBy, t.NestAs), nil
+}
+
+// parseJSONValue decodes a string or byte column holding JSON; empty and non-text values are left as-is
+func parseJSONValue(row map[string]any, col string) error {
+	var raw []byte
+	switch v := row[col].(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return nil
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var parsed any
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return fmt.Errorf("parse_json column '%s': invalid JSON: %w", col, err)
+	}
+	row[col] = parsed
+	return nil
+}
+
+// groupRows collapses rows sharing a group_by value into one row per group, in first-seen order:
+// {group_by: value, nest_as: [rows without the group_by column]}
+func groupRows(rows []map[string]any, groupBy, nestAs string) []map[string]any {
+	groups := make([]map[string]any, 0)
+	index := make(map[string]int)
+
+	for _, row := range rows {
+		val := row[groupBy]
+		if b, ok := val.([]byte); ok {
+			val = string(b)
+		}
+		// Keyed by type and value so 1 and "1" stay distinct and unhashable values are safe
+		key := fmt.Sprintf("%T:%v", val, val)
+
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, map[string]any{groupBy: val, nestAs: []map[string]any{}})
+		}
+
+		delete(row, groupBy)
+		groups[i][nestAs] = append(groups[i][nestAs].([]map[string]any), row)
+	}
+	return groups
+}
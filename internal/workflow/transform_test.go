@@ -0,0 +1,76 @@
+package workflow
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyTransform(t *testing.T) {
+	rows := []map[string]any{
+		{"customer_id": int64(1), "cust_name": "Acme", "order_id": 10, "meta": `{"rush": true}`, "internal": "x"},
+		{"customer_id": int64(2), "cust_name": "Globex", "order_id": 11, "meta": []byte(`{"rush": false}`), "internal": "y"},
+		{"customer_id": int64(1), "cust_name": "Acme", "order_id": 12, "meta": "", "internal": "z"},
+	}
+	cfg := &TransformConfig{
+		ParseJSON: []string{"meta"},
+		Rename:    map[string]string{"cust_name": "name"},
+		Exclude:   []string{"internal"},
+		GroupBy:   "customer_id",
+		NestAs:    "orders",
+	}
+
+	got, err := applyTransform(cfg, rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []map[string]any{
+		{"customer_id": int64(1), "orders": []map[string]any{
+			{"name": "Acme", "order_id": 10, "meta": map[string]any{"rush": true}},
+			{"name": "Acme", "order_id": 12, "meta": ""},
+		}},
+		{"customer_id": int64(2), "orders": []map[string]any{
+			{"name": "Globex", "order_id": 11, "meta": map[string]any{"rush": false}},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("applyTransform() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestApplyTransform_NoGrouping(t *testing.T) {
+	rows := []map[string]any{{"a": 1, "b": 2}}
+	got, err := applyTransform(&TransformConfig{Rename: map[string]string{"a": "alpha"}, Exclude: []string{"b"}}, rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []map[string]any{{"alpha": 1}}) {
+		t.Errorf("applyTransform() = %v", got)
+	}
+}
+
+func TestApplyTransform_GroupKeyTypes(t *testing.T) {
+	rows := []map[string]any{
+		{"k": 1, "v": "a"},
+		{"k": "1", "v": "b"},
+		{"v": "c"},
+		{"k": []byte("x"), "v": "d"},
+		{"k": "x", "v": "e"},
+	}
+	got, _ := applyTransform(&TransformConfig{GroupBy: "k", NestAs: "items"}, rows)
+	if len(got) != 4 {
+		t.Fatalf("got %d groups, want 4 (1, \"1\", missing, \"x\"): %v", len(got), got)
+	}
+	if got[3]["k"] != "x" || len(got[3]["items"].([]map[string]any)) != 2 {
+		t.Errorf("byte and string keys should share a group, got %v", got[3])
+	}
+}
+
+func TestApplyTransform_InvalidJSON(t *testing.T) {
+	rows := []map[string]any{{"meta": "{not json"}}
+	_, err := applyTransform(&TransformConfig{ParseJSON: []string{"meta"}}, rows)
+	if err == nil || !strings.Contains(err.Error(), "parse_json column 'meta'") {
+		t.Errorf("expected parse_json error, got %v", err)
+	}
+}
@@ -2,13 +2,23 @@ package workflow
 
 import (
 	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/robfig/cron/v3"
 
+	"sql-proxy/internal/loadshed"
+	"sql-proxy/internal/metrics"
+	"sql-proxy/internal/mqtt"
 	"sql-proxy/internal/sqlutil"
+	"sql-proxy/internal/types"
 )
 
 // ValidationResult holds workflow validation results.
@@ -29,8 +39,14 @@ func (r *ValidationResult) addWarning(format string, args ...any) {
 
 // ValidationContext provides external resources for validation.
 type ValidationContext struct {
-	Databases      map[string]bool // Database name -> isReadOnly
-	RateLimitPools map[string]bool // Rate limit pool names
+	Databases      map[string]bool   // Database name -> isReadOnly
+	DatabaseTypes  map[string]string // Database name -> type (sqlserver, mysql, sqlite)
+	RateLimitPools map[string]bool   // Rate limit pool names
+	SMTP           bool              // Whether smtp is configured (required by email steps)
+	Stores         map[string]bool   // Storage target names
+	SFTPServers    map[string]bool   // SFTP server names
+	MQTTBrokers    map[string]bool   // MQTT broker names
+	Outboxes       map[string]bool   // Databases with an outbox
 }
 
 // Validate validates a workflow configuration.
@@ -44,13 +60,47 @@ func Validate(cfg *WorkflowConfig, ctx *ValidationContext) *ValidationResult {
 		return r
 	}
 
+	// Validate concurrency limit
+	if cfg.MaxConcurrent < 0 {
+		r.addError("%s: max_concurrent cannot be negative", prefix)
+	}
+	if cfg.MaxConcurrentWaitMs < 0 {
+		r.addError("%s: max_concurrent_wait_ms cannot be negative", prefix)
+	}
+	if cfg.MaxConcurrentWaitMs > 0 && cfg.MaxConcurrent == 0 {
+		r.addWarning("%s: max_concurrent_wait_ms has no effect without max_concurrent", prefix)
+	}
+
+	// Validate default result limits for query steps
+	validateResultLimits(cfg.MaxRows, cfg.MaxResponseBytes, cfg.OnLimit, prefix, r)
+	validatePublicIDColumns(cfg.PublicIDColumns, prefix, r)
+	if cfg.Envelope != nil {
+		validateEnvelope(cfg.Envelope, prefix+".envelope", r)
+	}
+	validateStatusMap(cfg.StatusMap, prefix+".status_map", r)
+	if cfg.ErrorFormat != "" && !slices.Contains(ValidErrorFormats, cfg.ErrorFormat) {
+		r.addError("%s: invalid error_format '%s' (must be one of: %s)", prefix, cfg.ErrorFormat, strings.Join(ValidErrorFormats, ", "))
+	}
+	validateMaskColumns(cfg.MaskColumns, cfg.UnmaskWhen, prefix, false, r)
+	if cfg.UnmaskWhen != "" && len(cfg.MaskColumns) == 0 {
+		r.addWarning("%s: unmask_when has no effect without mask_columns", prefix)
+	}
+	validateColumnPatterns(cfg.IncludeColumns, "include_columns", prefix, r)
+	validateColumnPatterns(cfg.ExcludeColumns, "exclude_columns", prefix, r)
+
 	// Validate triggers
 	if len(cfg.Triggers) == 0 {
 		r.addError("%s: at least one trigger is required", prefix)
 	}
 	hasHTTPTrigger := false
+	hasWebSocketTrigger := false
 	hasCronTrigger := false
+	hasDBWatchTrigger := false
+	hasFileWatchTrigger := false
+	hasMQTTTrigger := false
+	grpcTriggers := 0
 	httpRoutes := make(map[string]bool) // "METHOD /path" -> seen
+	streams := hasStepType(cfg.Steps, StepTypeResponseSSE)
 
 	for i, trig := range cfg.Triggers {
 		trigPrefix := fmt.Sprintf("%s.triggers[%d]", prefix, i)
@@ -59,13 +109,48 @@ func Validate(cfg *WorkflowConfig, ctx *ValidationContext) *ValidationResult {
 		switch trig.Type {
 		case "http":
 			hasHTTPTrigger = true
-			route := trig.Method + " " + trig.Path
+			if streams && trig.Cache != nil && trig.Cache.Enabled {
+				r.addError("%s: cache cannot be used with response_sse steps (event streams are not cached)", trigPrefix)
+			}
+			if streams && trig.Poll != nil {
+				r.addError("%s: poll cannot be used with response_sse steps", trigPrefix)
+			}
+			if streams && trig.Idempotency != nil {
+				r.addError("%s: idempotency cannot be used with response_sse steps (event streams are not stored)", trigPrefix)
+			}
+			for _, routeCfg := range trig.Expand() {
+				route := routeCfg.Method + " " + routeCfg.Path
+				if httpRoutes[route] {
+					r.addError("%s: duplicate route '%s'", trigPrefix, route)
+				}
+				httpRoutes[route] = true
+			}
+		case "websocket":
+			hasWebSocketTrigger = true
+			route := "GET " + trig.Path
 			if httpRoutes[route] {
 				r.addError("%s: duplicate route '%s'", trigPrefix, route)
 			}
 			httpRoutes[route] = true
+			if streams {
+				r.addError("%s: response_sse steps cannot be used with websocket triggers (responses are sent as messages)", trigPrefix)
+			}
 		case "cron":
 			hasCronTrigger = true
+		case "dbwatch":
+			hasDBWatchTrigger = true
+		case "filewatch":
+			hasFileWatchTrigger = true
+		case "mqtt":
+			hasMQTTTrigger = true
+		case "grpc":
+			grpcTriggers++
+			if grpcTriggers > 1 {
+				r.addError("%s: a workflow can have only one grpc trigger", trigPrefix)
+			}
+			if streams {
+				r.addError("%s: response_sse steps cannot be used with grpc triggers (calls are unary)", trigPrefix)
+			}
 		}
 	}
 
@@ -107,12 +192,33 @@ func Validate(cfg *WorkflowConfig, ctx *ValidationContext) *ValidationResult {
 		}
 	}
 
+	validateColumnFilters(cfg, cfg.Steps, prefix, r)
+	queryStepNames := make(map[string]bool)
+	collectQueryStepNames(cfg.Steps, queryStepNames)
+	for i, trig := range cfg.Triggers {
+		if trig.Fields == nil {
+			continue
+		}
+		for _, name := range trig.Fields.Steps {
+			if !queryStepNames[name] {
+				r.addError("%s.triggers[%d].fields: '%s' is not a query step", prefix, i, name)
+			}
+		}
+	}
+
 	// Response step validation
-	if hasHTTPTrigger && !hasResponseStep {
+	if hasHTTPTrigger && !hasResponseStep && !streams {
 		r.addWarning("%s: HTTP trigger but no response step - will return 500 if reached", prefix)
 	}
-	if hasCronTrigger && !hasHTTPTrigger && hasResponseStep {
-		r.addError("%s: response steps are only valid for HTTP triggers", prefix)
+	backgroundOnly := (hasCronTrigger || hasDBWatchTrigger || hasFileWatchTrigger || hasMQTTTrigger) && !hasHTTPTrigger && !hasWebSocketTrigger && grpcTriggers == 0
+	if backgroundOnly && hasResponseStep {
+		r.addError("%s: response steps are only valid for HTTP, websocket, and grpc triggers", prefix)
+	}
+	if backgroundOnly && streams {
+		r.addError("%s: response_sse steps are only valid for HTTP triggers", prefix)
+	}
+	if (hasWebSocketTrigger || grpcTriggers > 0) && hasStepType(cfg.Steps, StepTypeRedirect) {
+		r.addError("%s: redirect steps are only valid for HTTP triggers", prefix)
 	}
 
 	// Check for multiple unconditional response steps
@@ -149,25 +255,330 @@ func Validate(cfg *WorkflowConfig, ctx *ValidationContext) *ValidationResult {
 
 func validateTrigger(cfg *TriggerConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
 	if cfg.Type == "" {
-		r.addError("%s: type is required (http or cron)", prefix)
+		r.addError("%s: type is required (http, websocket, cron, dbwatch, filewatch, mqtt, or grpc)", prefix)
 		return
 	}
 	if !ValidTriggerTypes[cfg.Type] {
-		r.addError("%s: invalid type '%s' (must be http or cron)", prefix, cfg.Type)
+		r.addError("%s: invalid type '%s' (must be http, websocket, cron, dbwatch, filewatch, mqtt, or grpc)", prefix, cfg.Type)
 		return
 	}
+	if cfg.Idempotency != nil && cfg.Type != TriggerTypeHTTP {
+		r.addError("%s: idempotency is only supported for http triggers", prefix)
+	}
+	if !loadshed.ValidPriority(cfg.Priority) {
+		r.addError("%s: invalid priority '%s' (must be low, normal, high, or critical)", prefix, cfg.Priority)
+	}
+	if cfg.Fields != nil {
+		if cfg.Type != TriggerTypeHTTP {
+			r.addError("%s: fields is only supported for http triggers", prefix)
+		} else {
+			validateFields(cfg, prefix+".fields", r)
+		}
+	}
+	if cfg.SortableColumns != nil || cfg.FilterableColumns != nil || cfg.DefaultSort != "" {
+		if cfg.Type != TriggerTypeHTTP {
+			r.addError("%s: sortable_columns, filterable_columns, and default_sort are only supported for http triggers", prefix)
+		} else {
+			validateListQuery(cfg, prefix, r)
+		}
+	}
+	if len(cfg.ResponseHeaders) > 0 {
+		if cfg.Type != TriggerTypeHTTP {
+			r.addError("%s: response_headers is only supported for http triggers", prefix)
+		}
+		for name, val := range cfg.ResponseHeaders {
+			if name == "" {
+				r.addError("%s.response_headers: header name cannot be empty", prefix)
+				continue
+			}
+			if _, err := template.New(name).Funcs(TemplateFuncs).Parse(val); err != nil {
+				r.addError("%s.response_headers[%s]: invalid template: %v", prefix, name, err)
+			}
+		}
+	}
+	if cfg.Rewrite != nil {
+		if cfg.Type != TriggerTypeHTTP {
+			r.addError("%s: rewrite is only supported for http triggers", prefix)
+		} else {
+			validateRewrite(cfg, prefix+".rewrite", r)
+		}
+	}
+	if cfg.Deprecated || cfg.Sunset != "" {
+		if cfg.Type != TriggerTypeHTTP {
+			r.addError("%s: deprecated and sunset are only supported for http triggers", prefix)
+		}
+		if cfg.Sunset != "" {
+			if !cfg.Deprecated {
+				r.addError("%s: sunset requires deprecated: true", prefix)
+			}
+			if sunset, err := cfg.SunsetTime(); err != nil {
+				r.addError("%s: sunset must be a date (2025-06-30) or an RFC 3339 timestamp, got: %s", prefix, cfg.Sunset)
+			} else if sunset.Before(time.Now()) {
+				r.addWarning("%s: sunset %s has passed; remove the endpoint or move the date", prefix, cfg.Sunset)
+			}
+		}
+	}
+	if cfg.DebugLog != nil {
+		if cfg.Type != TriggerTypeHTTP {
+			r.addError("%s: debug_log is only supported for http triggers", prefix)
+		} else if !cfg.DebugLog.Request && !cfg.DebugLog.Response {
+			r.addWarning("%s: debug_log logs nothing without request or response", prefix)
+		}
+	}
 
 	switch cfg.Type {
 	case "http":
 		validateHTTPTrigger(cfg, prefix, ctx, r)
+	case "websocket":
+		validateWebSocketTrigger(cfg, prefix, ctx, r)
 	case "cron":
 		validateCronTrigger(cfg, prefix, r)
+	case "dbwatch":
+		validateDBWatchTrigger(cfg, prefix, ctx, r)
+	case "filewatch":
+		validateFileWatchTrigger(cfg, prefix, r)
+	case "mqtt":
+		validateMQTTTrigger(cfg, prefix, ctx, r)
+	case "grpc":
+		validateGRPCTrigger(cfg, prefix, ctx, r)
 	}
 }
 
 func validateHTTPTrigger(cfg *TriggerConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
-	if cfg.Path == "" {
+	if cfg.Path != "" && len(cfg.Paths) > 0 {
+		r.addError("%s: path and paths are mutually exclusive", prefix)
+	}
+	if cfg.Method != "" && len(cfg.Methods) > 0 {
+		r.addError("%s: method and methods are mutually exclusive", prefix)
+	}
+
+	if len(cfg.HTTPPaths()) == 0 {
 		r.addError("%s: path is required for http trigger", prefix)
+	}
+	for _, p := range listedValues(prefix, "paths", cfg.Path, cfg.Paths) {
+		if !strings.HasPrefix(p.value, "/") {
+			r.addError("%s: path must start with '/'", p.field)
+		}
+		if strings.HasPrefix(p.value, "/_/") {
+			r.addError("%s: path cannot start with '/_/' (reserved)", p.field)
+		}
+	}
+
+	if len(cfg.HTTPMethods()) == 0 {
+		r.addError("%s: method is required for http trigger", prefix)
+	}
+	for _, m := range listedValues(prefix, "methods", cfg.Method, cfg.Methods) {
+		if !isValidHTTPMethod(m.value) {
+			r.addError("%s: method must be GET, POST, PUT, DELETE, PATCH, HEAD, or OPTIONS", m.field)
+		}
+	}
+
+	validateRequestFields(cfg, prefix, ctx, r)
+
+	if cfg.Poll != nil {
+		validatePoll(cfg, prefix+".poll", r)
+	}
+	if cfg.Idempotency != nil {
+		validateIdempotency(cfg, prefix+".idempotency", ctx, r)
+	}
+
+	// Validate cache
+	if cfg.Cache != nil && cfg.Cache.Enabled {
+		cachePrefix := prefix + ".cache"
+		if cfg.Cache.Key == "" {
+			r.addError("%s: key is required when cache is enabled", cachePrefix)
+		}
+		if cfg.Cache.TTLSec < 0 {
+			r.addError("%s: ttl_sec cannot be negative", cachePrefix)
+		}
+		if cfg.Cache.StaleWhileRevalidateSec < 0 {
+			r.addError("%s: stale_while_revalidate_sec cannot be negative", cachePrefix)
+		}
+		validateCacheTags(cfg.Cache.Tags, cachePrefix+".tags", r)
+		if cfg.Cache.EvictCron != "" {
+			if err := validateCronExpr(cfg.Cache.EvictCron); err != nil {
+				r.addError("%s: invalid evict_cron: %v", cachePrefix, err)
+			}
+		}
+	}
+}
+
+func validatePoll(cfg *TriggerConfig, prefix string, r *ValidationResult) {
+	poll := cfg.Poll
+	if poll.IntervalMs < 0 {
+		r.addError("%s: interval_ms cannot be negative", prefix)
+	}
+	if poll.TimeoutSec < 0 {
+		r.addError("%s: timeout_sec cannot be negative", prefix)
+	}
+	interval, timeout := DefaultPollInterval, DefaultPollTimeout
+	if poll.IntervalMs > 0 {
+		interval = time.Duration(poll.IntervalMs) * time.Millisecond
+	}
+	if poll.TimeoutSec > 0 {
+		timeout = time.Duration(poll.TimeoutSec) * time.Second
+	}
+	if interval >= timeout {
+		r.addError("%s: interval_ms must be shorter than timeout_sec", prefix)
+	}
+	if cfg.Cache != nil && cfg.Cache.Enabled {
+		r.addError("%s: poll cannot be used with cache", prefix)
+	}
+	for _, m := range cfg.HTTPMethods() {
+		if m != "GET" {
+			r.addWarning("%s: polling a %s trigger re-runs the workflow, and its writes, on every interval", prefix, m)
+		}
+	}
+}
+
+func validateFields(cfg *TriggerConfig, prefix string, r *ValidationResult) {
+	fields := cfg.Fields
+	if len(fields.Allowed) == 0 {
+		r.addError("%s: allowed is required", prefix)
+	}
+	seen := make(map[string]bool, len(fields.Allowed))
+	for _, col := range fields.Allowed {
+		switch {
+		case strings.TrimSpace(col) == "":
+			r.addError("%s: allowed has an empty column name", prefix)
+		case strings.ContainsAny(col, ",*?[\\"):
+			r.addError("%s: allowed column '%s' cannot contain commas or wildcards", prefix, col)
+		case seen[strings.ToLower(col)]:
+			r.addError("%s: duplicate allowed column '%s'", prefix, col)
+		}
+		seen[strings.ToLower(col)] = true
+	}
+	if len(fields.Steps) == 0 {
+		r.addError("%s: steps is required", prefix)
+	}
+	for _, p := range cfg.Parameters {
+		if p.Name == FieldsParam {
+			r.addError("%s: parameter '%s' conflicts with field selection", prefix, FieldsParam)
+		}
+	}
+	if cfg.Cache != nil && cfg.Cache.Enabled && !strings.Contains(cfg.Cache.Key, FieldsParam) {
+		r.addWarning("%s: cache.key should include .trigger.query.fields, or every field selection shares one cached response", prefix)
+	}
+}
+
+func validateListQuery(cfg *TriggerConfig, prefix string, r *ValidationResult) {
+	for _, list := range []struct {
+		field   string
+		columns []string
+	}{{"sortable_columns", cfg.SortableColumns}, {"filterable_columns", cfg.FilterableColumns}} {
+		seen := make(map[string]bool, len(list.columns))
+		for _, col := range list.columns {
+			if !setValueNameRegex.MatchString(col) {
+				r.addError("%s: %s column '%s' must be a letter or underscore followed by letters, digits, or underscores", prefix, list.field, col)
+			} else if seen[strings.ToLower(col)] {
+				r.addError("%s: duplicate %s column '%s'", prefix, list.field, col)
+			}
+			seen[strings.ToLower(col)] = true
+		}
+	}
+	if cfg.DefaultSort != "" {
+		if _, err := parseSort(cfg.DefaultSort, cfg.SortableColumns); err != nil {
+			r.addError("%s: default_sort: %v", prefix, err)
+		}
+	}
+	for _, p := range cfg.Parameters {
+		if (p.Name == SortParam && cfg.SortableColumns != nil) || (strings.HasPrefix(p.Name, FilterParamPrefix) && cfg.FilterableColumns != nil) {
+			r.addError("%s: parameter '%s' conflicts with sortable_columns or filterable_columns", prefix, p.Name)
+		}
+	}
+	if cfg.Cache != nil && cfg.Cache.Enabled && !strings.Contains(cfg.Cache.Key, ".trigger.query") {
+		r.addWarning("%s: cache.key should include .trigger.query, or every sort and filter shares one cached response", prefix)
+	}
+}
+
+// validateEnvelope checks an envelope's keys
+func validateEnvelope(e *EnvelopeConfig, prefix string, r *ValidationResult) {
+	seen := make(map[string]string)
+	for _, f := range []struct{ field, key string }{
+		{"success", e.Success}, {"data", e.Data}, {"error", e.Error}, {"code", e.Code}, {"count", e.Count}, {"request_id", e.RequestID}, {"meta", e.Meta},
+	} {
+		key := f.key
+		if f.field != "meta" {
+			key = keyOr(f.key, f.field)
+		}
+		if key == "" || key == omitKey {
+			continue
+		}
+		if strings.TrimSpace(key) != key {
+			r.addError("%s: %s key '%s' cannot have surrounding spaces", prefix, f.field, key)
+		}
+		if other, dup := seen[key]; dup {
+			r.addError("%s: %s and %s use the same key '%s'", prefix, other, f.field, key)
+		}
+		seen[key] = f.field
+	}
+	if e.Flat && e.Meta != "" {
+		r.addWarning("%s: meta has no effect on success responses with flat; errors still use it", prefix)
+	}
+}
+
+// validateStatusMap checks status_map's classes and statuses
+func validateStatusMap(m map[string]StatusMapping, prefix string, r *ValidationResult) {
+	for class, mapping := range m {
+		if !slices.Contains(ValidErrorClasses, class) {
+			r.addError("%s: unknown error class '%s' (must be one of: %s)", prefix, class, strings.Join(ValidErrorClasses, ", "))
+			continue
+		}
+		if mapping.Status != 0 && (mapping.Status < 400 || mapping.Status > 599) {
+			r.addError("%s.%s: status must be between 400 and 599, got %d", prefix, class, mapping.Status)
+		}
+		if mapping.Status == 0 && mapping.Message == "" {
+			r.addWarning("%s.%s: has neither status nor message and changes nothing", prefix, class)
+		}
+	}
+}
+
+// collectQueryStepNames adds the names of query steps, including those nested in blocks
+func collectQueryStepNames(steps []StepConfig, names map[string]bool) {
+	for i := range steps {
+		if steps[i].StepType() == "query" && steps[i].Name != "" {
+			names[steps[i].Name] = true
+		}
+		collectQueryStepNames(steps[i].Steps, names)
+	}
+}
+
+func validateIdempotency(cfg *TriggerConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
+	idem := cfg.Idempotency
+	if idem.Database == "" {
+		r.addError("%s: database is required", prefix)
+	} else if ctx != nil {
+		if readOnly, exists := ctx.Databases[idem.Database]; !exists {
+			r.addError("%s: unknown database '%s'", prefix, idem.Database)
+		} else if readOnly {
+			r.addError("%s: database '%s' is read-only", prefix, idem.Database)
+		}
+	}
+	if idem.Table != "" && !qualifiedNameRegex.MatchString(idem.Table) {
+		r.addError("%s: invalid table name '%s' (use name, schema.name or database.schema.name)", prefix, idem.Table)
+	}
+	if idem.TTLSec < 0 {
+		r.addError("%s: ttl_sec cannot be negative", prefix)
+	}
+	for _, m := range cfg.HTTPMethods() {
+		if m == "GET" || m == "HEAD" || m == "OPTIONS" {
+			r.addError("%s: idempotency applies to write methods (POST, PUT, PATCH, DELETE), not %s", prefix, m)
+		}
+	}
+	if cfg.Cache != nil && cfg.Cache.Enabled {
+		r.addError("%s: idempotency cannot be used with cache", prefix)
+	}
+	if cfg.Poll != nil {
+		r.addError("%s: idempotency cannot be used with poll", prefix)
+	}
+}
+
+func validateWebSocketTrigger(cfg *TriggerConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
+	if len(cfg.Paths) > 0 {
+		r.addError("%s: paths is not supported for websocket triggers (use one trigger per path)", prefix)
+	}
+	if cfg.Path == "" {
+		r.addError("%s: path is required for websocket trigger", prefix)
 	} else {
 		if !strings.HasPrefix(cfg.Path, "/") {
 			r.addError("%s: path must start with '/'", prefix)
@@ -176,15 +587,43 @@ func validateHTTPTrigger(cfg *TriggerConfig, prefix string, ctx *ValidationConte
 			r.addError("%s: path cannot start with '/_/' (reserved)", prefix)
 		}
 	}
-
-	if cfg.Method == "" {
-		r.addError("%s: method is required for http trigger", prefix)
-	} else if !isValidHTTPMethod(cfg.Method) {
-		r.addError("%s: method must be GET, POST, PUT, DELETE, PATCH, HEAD, or OPTIONS", prefix)
+	if (cfg.Method != "" && cfg.Method != "GET") || len(cfg.Methods) > 0 {
+		r.addError("%s: websocket triggers are opened with GET; method cannot be set to anything else", prefix)
+	}
+	if cfg.Cache != nil && cfg.Cache.Enabled {
+		r.addError("%s: cache is not supported for websocket triggers", prefix)
+	}
+	if cfg.Poll != nil {
+		r.addError("%s: poll is not supported for websocket triggers", prefix)
+	}
+	if cfg.MaxMessageBytes < 0 {
+		r.addError("%s: max_message_bytes cannot be negative", prefix)
+	}
+	if cfg.IdleTimeoutSec < 0 {
+		r.addError("%s: idle_timeout_sec cannot be negative", prefix)
+	}
+	for i, origin := range cfg.Origins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			r.addError("%s.origins[%d]: must be '*' or a scheme and host such as https://app.example.com", prefix, i)
+		}
 	}
 
-	// Extract path parameters from path (e.g., /api/items/{id})
-	pathParams := extractPathParams(cfg.Path)
+	validateRequestFields(cfg, prefix, ctx, r)
+}
+
+// validateRequestFields validates the parameters, body schema, and rate limits
+// shared by http and websocket triggers
+func validateRequestFields(cfg *TriggerConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
+	// Extract path parameters from all paths (e.g., /api/items/{id})
+	pathParams := make(map[string]bool)
+	for _, path := range cfg.HTTPPaths() {
+		for name := range extractPathParams(path) {
+			pathParams[name] = true
+		}
+	}
 
 	// Validate parameters
 	paramNames := make(map[string]bool)
@@ -205,6 +644,8 @@ func validateHTTPTrigger(cfg *TriggerConfig, prefix string, ctx *ValidationConte
 
 		if param.Type != "" && !isValidParamType(param.Type) {
 			r.addError("%s: invalid type '%s'", paramPrefix, param.Type)
+		} else if param.Validation != nil {
+			validateParamRules(&param, paramPrefix+".validation", r)
 		}
 
 		// Path parameters must be required (can't have optional path segments)
@@ -220,18 +661,13 @@ func validateHTTPTrigger(cfg *TriggerConfig, prefix string, ctx *ValidationConte
 		}
 	}
 
-	// Validate cache
-	if cfg.Cache != nil && cfg.Cache.Enabled {
-		cachePrefix := prefix + ".cache"
-		if cfg.Cache.Key == "" {
-			r.addError("%s: key is required when cache is enabled", cachePrefix)
-		}
-		if cfg.Cache.TTLSec < 0 {
-			r.addError("%s: ttl_sec cannot be negative", cachePrefix)
+	if cfg.BodySchema != nil {
+		if _, err := CompileSchema(cfg.BodySchema); err != nil {
+			r.addError("%s.body_schema: %v", prefix, err)
 		}
-		if cfg.Cache.EvictCron != "" {
-			if err := validateCronExpr(cfg.Cache.EvictCron); err != nil {
-				r.addError("%s: invalid evict_cron: %v", cachePrefix, err)
+		for _, m := range cfg.HTTPMethods() {
+			if m == "GET" || m == "HEAD" {
+				r.addWarning("%s: body_schema on a %s trigger rejects requests without a JSON body", prefix, m)
 			}
 		}
 	}
@@ -241,6 +677,102 @@ func validateHTTPTrigger(cfg *TriggerConfig, prefix string, ctx *ValidationConte
 		rlPrefix := fmt.Sprintf("%s.rate_limit[%d]", prefix, i)
 		validateRateLimit(&rl, rlPrefix, ctx, r)
 	}
+
+	if cfg.Middlewares != nil {
+		validateMiddlewares(cfg, prefix+".middlewares", r)
+	}
+}
+
+// validateRewrite checks a trigger's request rewrite rules
+func validateRewrite(cfg *TriggerConfig, prefix string, r *ValidationResult) {
+	rw := cfg.Rewrite
+	for i, name := range rw.RemoveHeaders {
+		if name == "" {
+			r.addError("%s.remove_headers[%d]: header name cannot be empty", prefix, i)
+		}
+	}
+	for name := range rw.SetHeaders {
+		if name == "" {
+			r.addError("%s.set_headers: header name cannot be empty", prefix)
+		}
+	}
+	for name := range rw.DefaultQuery {
+		if name == "" {
+			r.addError("%s.default_query: parameter name cannot be empty", prefix)
+		}
+	}
+
+	declared := make(map[string]bool, len(cfg.Parameters))
+	for _, p := range cfg.Parameters {
+		declared[p.Name] = true
+	}
+	pathParams := make(map[string]bool)
+	for _, routePath := range cfg.HTTPPaths() {
+		maps.Copy(pathParams, extractPathParams(routePath))
+	}
+	seen := make(map[string]bool, len(rw.PathParams))
+	for i, pp := range rw.PathParams {
+		ppPrefix := fmt.Sprintf("%s.path_params[%d]", prefix, i)
+		switch {
+		case pp.Name == "":
+			r.addError("%s: name is required", ppPrefix)
+		case !declared[pp.Name]:
+			r.addError("%s: '%s' must be defined in parameters", ppPrefix, pp.Name)
+		case pathParams[pp.Name]:
+			r.addError("%s: '%s' is already a path parameter", ppPrefix, pp.Name)
+		case seen[pp.Name]:
+			r.addError("%s: duplicate parameter '%s'", ppPrefix, pp.Name)
+		}
+		seen[pp.Name] = true
+		if pp.Pattern == "" {
+			r.addError("%s: pattern is required", ppPrefix)
+		} else if _, err := pathParamPattern(&pp); err != nil {
+			r.addError("%s: %v", ppPrefix, err)
+		}
+	}
+}
+
+// validateMiddlewares checks a trigger's stage list and warns about configured stages it leaves out
+func validateMiddlewares(cfg *TriggerConfig, prefix string, r *ValidationResult) {
+	configured := map[string]bool{
+		MiddlewareRateLimit: len(cfg.RateLimit) > 0,
+		MiddlewareCache:     cfg.Cache != nil && cfg.Cache.Enabled,
+	}
+	seen := make(map[string]bool, len(cfg.Middlewares))
+	for i, m := range cfg.Middlewares {
+		switch {
+		case !slices.Contains(DefaultMiddlewares, m):
+			r.addError("%s[%d]: unknown middleware '%s' (must be %s)", prefix, i, m, strings.Join(DefaultMiddlewares, " or "))
+		case seen[m]:
+			r.addError("%s[%d]: duplicate middleware '%s'", prefix, i, m)
+		case !configured[m]:
+			r.addWarning("%s[%d]: middleware '%s' does nothing without %s configured on the trigger", prefix, i, m, m)
+		}
+		seen[m] = true
+	}
+	for _, m := range DefaultMiddlewares {
+		if configured[m] && !seen[m] {
+			r.addWarning("%s: %s is configured but skipped, as it is not listed", prefix, m)
+		}
+	}
+}
+
+// listedValue is one value of a field that accepts a single value or a list (method/methods, path/paths)
+type listedValue struct {
+	field string // Error prefix, e.g. "workflow[x].triggers[0].methods[1]"
+	value string
+}
+
+// listedValues flattens a single field and its list form into values with error prefixes
+func listedValues(prefix, listField, single string, list []string) []listedValue {
+	values := make([]listedValue, 0, len(list)+1)
+	if single != "" {
+		values = append(values, listedValue{field: prefix, value: single})
+	}
+	for i, v := range list {
+		values = append(values, listedValue{field: fmt.Sprintf("%s.%s[%d]", prefix, listField, i), value: v})
+	}
+	return values
 }
 
 func validateCronTrigger(cfg *TriggerConfig, prefix string, r *ValidationResult) {
@@ -251,15 +783,188 @@ func validateCronTrigger(cfg *TriggerConfig, prefix string, r *ValidationResult)
 	}
 
 	// Cron triggers shouldn't have HTTP-specific fields
-	if cfg.Path != "" {
+	if cfg.Path != "" || len(cfg.Paths) > 0 {
 		r.addWarning("%s: path is ignored for cron trigger", prefix)
 	}
-	if cfg.Method != "" {
+	if cfg.Method != "" || len(cfg.Methods) > 0 {
 		r.addWarning("%s: method is ignored for cron trigger", prefix)
 	}
 	if cfg.Cache != nil {
 		r.addWarning("%s: cache is ignored for cron trigger", prefix)
 	}
+	if cfg.BodySchema != nil {
+		r.addWarning("%s: body_schema is ignored for cron trigger", prefix)
+	}
+}
+
+func validateDBWatchTrigger(cfg *TriggerConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
+	if cfg.Database == "" {
+		r.addError("%s: database is required for dbwatch trigger", prefix)
+	} else if ctx != nil {
+		if _, exists := ctx.Databases[cfg.Database]; !exists {
+			r.addError("%s: unknown database '%s'", prefix, cfg.Database)
+		}
+	}
+	if cfg.Table == "" {
+		r.addError("%s: table is required for dbwatch trigger", prefix)
+	} else if !qualifiedNameRegex.MatchString(cfg.Table) {
+		r.addError("%s: invalid table name '%s' (use name, schema.name or database.schema.name)", prefix, cfg.Table)
+	}
+	if cfg.Column == "" {
+		r.addError("%s: column is required for dbwatch trigger", prefix)
+	} else if !columnNameRegex.MatchString(cfg.Column) {
+		r.addError("%s: invalid column name '%s'", prefix, cfg.Column)
+	}
+	for i, col := range cfg.Columns {
+		if !columnNameRegex.MatchString(col) {
+			r.addError("%s.columns[%d]: invalid column name '%s'", prefix, i, col)
+		} else if col == watermarkColumn {
+			r.addError("%s.columns[%d]: '%s' is reserved for the watermark", prefix, i, col)
+		}
+	}
+
+	switch cfg.ColumnType {
+	case "", WatchColumnTimestamp, WatchColumnInt:
+	case WatchColumnRowVersion:
+		if ctx != nil && cfg.Database != "" {
+			if dbType, ok := ctx.DatabaseTypes[cfg.Database]; ok && dbType != "sqlserver" {
+				r.addError("%s: column_type rowversion requires a sqlserver database ('%s' is %s)", prefix, cfg.Database, dbType)
+			}
+		}
+	default:
+		r.addError("%s: invalid column_type '%s' (must be timestamp, int, or rowversion)", prefix, cfg.ColumnType)
+	}
+
+	switch cfg.Start {
+	case "", "latest", "beginning":
+	default:
+		r.addError("%s: invalid start '%s' (must be latest or beginning)", prefix, cfg.Start)
+	}
+	if cfg.IntervalSec < 0 {
+		r.addError("%s: interval_sec cannot be negative", prefix)
+	}
+	if cfg.BatchSize < 0 {
+		r.addError("%s: batch_size cannot be negative", prefix)
+	}
+
+	if cfg.Path != "" || len(cfg.Paths) > 0 {
+		r.addWarning("%s: path is ignored for dbwatch trigger", prefix)
+	}
+	if cfg.Method != "" || len(cfg.Methods) > 0 {
+		r.addWarning("%s: method is ignored for dbwatch trigger", prefix)
+	}
+	if cfg.Cache != nil {
+		r.addWarning("%s: cache is ignored for dbwatch trigger", prefix)
+	}
+	if cfg.Poll != nil {
+		r.addError("%s: poll is not supported for dbwatch triggers", prefix)
+	}
+}
+
+func validateFileWatchTrigger(cfg *TriggerConfig, prefix string, r *ValidationResult) {
+	if cfg.Dir == "" {
+		r.addError("%s: dir is required for filewatch trigger", prefix)
+	}
+	if cfg.Pattern != "" {
+		if _, err := filepath.Match(cfg.Pattern, ""); err != nil {
+			r.addError("%s: invalid pattern '%s': %v", prefix, cfg.Pattern, err)
+		} else if strings.Contains(cfg.Pattern, "/") {
+			r.addError("%s: pattern is matched against file names and cannot contain '/'", prefix)
+		}
+	}
+	for _, target := range []struct{ field, dir string }{{"done_dir", cfg.DoneDir}, {"error_dir", cfg.ErrorDir}} {
+		if target.dir == "" {
+			r.addError("%s: %s is required for filewatch trigger", prefix, target.field)
+		} else if cfg.Dir != "" && filepath.Clean(target.dir) == filepath.Clean(cfg.Dir) {
+			r.addError("%s: %s must differ from dir (files would run again)", prefix, target.field)
+		}
+	}
+	if cfg.IntervalSec < 0 {
+		r.addError("%s: interval_sec cannot be negative", prefix)
+	}
+	if cfg.MaxFileBytes < 0 {
+		r.addError("%s: max_file_bytes cannot be negative", prefix)
+	}
+
+	if cfg.Path != "" || len(cfg.Paths) > 0 {
+		r.addWarning("%s: path is ignored for filewatch trigger", prefix)
+	}
+	if cfg.Method != "" || len(cfg.Methods) > 0 {
+		r.addWarning("%s: method is ignored for filewatch trigger", prefix)
+	}
+	if cfg.Cache != nil {
+		r.addWarning("%s: cache is ignored for filewatch trigger", prefix)
+	}
+	if cfg.Poll != nil {
+		r.addError("%s: poll is not supported for filewatch triggers", prefix)
+	}
+}
+
+func validateMQTTTrigger(cfg *TriggerConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
+	if cfg.Broker == "" {
+		r.addError("%s: broker is required for mqtt trigger", prefix)
+	} else if ctx != nil && !ctx.MQTTBrokers[cfg.Broker] {
+		r.addError("%s: unknown mqtt broker '%s'", prefix, cfg.Broker)
+	}
+	if len(cfg.Topics) == 0 {
+		r.addError("%s: topics is required for mqtt trigger", prefix)
+	}
+	for i, filter := range cfg.Topics {
+		if err := mqtt.ValidateFilter(filter); err != nil {
+			r.addError("%s.topics[%d]: invalid topic filter '%s': %v", prefix, i, filter, err)
+		}
+	}
+	if cfg.QoS < 0 || cfg.QoS > 1 {
+		r.addError("%s: qos must be 0 or 1", prefix)
+	}
+
+	if cfg.Path != "" || len(cfg.Paths) > 0 {
+		r.addWarning("%s: path is ignored for mqtt trigger", prefix)
+	}
+	if cfg.Method != "" || len(cfg.Methods) > 0 {
+		r.addWarning("%s: method is ignored for mqtt trigger", prefix)
+	}
+	if cfg.Cache != nil {
+		r.addWarning("%s: cache is ignored for mqtt trigger", prefix)
+	}
+	if cfg.Poll != nil {
+		r.addError("%s: poll is not supported for mqtt triggers", prefix)
+	}
+}
+
+// rpcNameRegex matches generated gRPC method names, which are upper camel case by convention
+var rpcNameRegex = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+// protoFieldRegex matches parameter names usable as protobuf field names
+var protoFieldRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func validateGRPCTrigger(cfg *TriggerConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
+	if cfg.RPC != "" {
+		if !rpcNameRegex.MatchString(cfg.RPC) {
+			r.addError("%s: invalid rpc '%s' (use an upper camel case name such as GetMachine)", prefix, cfg.RPC)
+		} else if cfg.RPC == "Execute" {
+			r.addError("%s: rpc 'Execute' is reserved for the generic method", prefix)
+		}
+		for i, param := range cfg.Parameters {
+			if param.Name != "" && !protoFieldRegex.MatchString(param.Name) {
+				r.addError("%s.parameters[%d]: '%s' is not a valid field name for rpc %s", prefix, i, param.Name, cfg.RPC)
+			}
+		}
+	}
+	if cfg.Path != "" || len(cfg.Paths) > 0 {
+		r.addWarning("%s: path is ignored for grpc trigger", prefix)
+	}
+	if cfg.Method != "" || len(cfg.Methods) > 0 {
+		r.addWarning("%s: method is ignored for grpc trigger", prefix)
+	}
+	if cfg.Cache != nil && cfg.Cache.Enabled {
+		r.addError("%s: cache is not supported for grpc triggers", prefix)
+	}
+	if cfg.Poll != nil {
+		r.addError("%s: poll is not supported for grpc triggers", prefix)
+	}
+
+	validateRequestFields(cfg, prefix, ctx, r)
 }
 
 func validateRateLimit(cfg *RateLimitRefConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
@@ -302,16 +1007,93 @@ func validateStep(cfg *StepConfig, prefix string, stepIndex int, stepNames map[s
 
 	// Validate on_error
 	if cfg.OnError != "" && !ValidOnErrorValues[cfg.OnError] {
-		r.addError("%s: on_error must be 'abort' or 'continue'", prefix)
+		r.addError("%s: on_error must be 'abort', 'continue', or 'fallback'", prefix)
+	}
+
+	if cfg.Cache != nil {
+		validateCacheTags(cfg.Cache.Tags, prefix+".cache.tags", r)
 	}
 
 	// Determine step type
 	stepType := cfg.StepType()
 	if stepType == "unknown" {
-		r.addError("%s: must specify type or provide type-specific fields (sql, url, template, or steps)", prefix)
+		r.addError("%s: must specify type or provide type-specific fields (sql, url, template, values, script, or steps)", prefix)
 		return
 	}
 
+	// Step timeouts bound the steps that wait on a database or remote service
+	if cfg.TimeoutSec < 0 {
+		r.addError("%s: timeout_sec cannot be negative", prefix)
+	} else if cfg.TimeoutSec > 0 && stepType != "query" && stepType != "httpcall" && stepType != "script" && stepType != "email" && stepType != "notify" && stepType != "storage" && stepType != "sftp" && stepType != "bulk_insert" && stepType != "mqtt" && stepType != "block" {
+		r.addError("%s: timeout_sec is only supported for query, httpcall, script, email, notify, storage, sftp, bulk_insert, mqtt, and block steps", prefix)
+	}
+
+	if (cfg.MaxRows != 0 || cfg.MaxResponseBytes != 0 || cfg.OnLimit != "" || cfg.PageOffset != "") && stepType != "query" {
+		r.addError("%s: max_rows, max_response_bytes, on_limit, and page_offset are only supported for query steps", prefix)
+	}
+	if cfg.PublicIDColumns != nil && stepType != "query" {
+		r.addError("%s: public_id_columns is only supported for query steps", prefix)
+	}
+	if len(cfg.Outbox) > 0 && stepType != "query" {
+		r.addError("%s: outbox is only supported for query steps", prefix)
+	}
+	validatePublicIDColumns(cfg.PublicIDColumns, prefix, r)
+	if (cfg.MaskColumns != nil || cfg.UnmaskWhen != "") && stepType != "query" {
+		r.addError("%s: mask_columns and unmask_when are only supported for query steps", prefix)
+	}
+	validateMaskColumns(cfg.MaskColumns, cfg.UnmaskWhen, prefix, true, r)
+	if (cfg.IncludeColumns != nil || cfg.ExcludeColumns != nil) && stepType != "query" {
+		r.addError("%s: include_columns and exclude_columns are only supported for query steps", prefix)
+	}
+	validateColumnPatterns(cfg.IncludeColumns, "include_columns", prefix, r)
+	validateColumnPatterns(cfg.ExcludeColumns, "exclude_columns", prefix, r)
+	if cfg.UnmaskWhen != "" && cfg.Cache != nil {
+		r.addWarning("%s: cached results are shared by masked and unmasked callers unless cache.key tells them apart", prefix)
+	}
+
+	if cfg.TemplateType != "" && stepType != "response" {
+		r.addError("%s: template_type is only supported for response steps", prefix)
+	}
+
+	if cfg.TemplateFile != "" && stepType != "response" && stepType != "page" {
+		r.addError("%s: template_file is only supported for response and page steps", prefix)
+	}
+
+	if cfg.Location != "" && stepType != "redirect" {
+		r.addError("%s: location is only supported for redirect steps", prefix)
+	}
+
+	if cfg.Event != "" && stepType != "response_sse" {
+		r.addError("%s: event is only supported for response_sse steps", prefix)
+	}
+
+	if cfg.When != "" && stepType != "notify" {
+		r.addError("%s: when is only supported for notify steps", prefix)
+	}
+
+	if cfg.Metric != nil && stepType != "metric" {
+		r.addError("%s: metric is only supported for metric steps", prefix)
+	}
+
+	// Fallback data stands in for the rows or response of a failed data step
+	if cfg.OnError == "fallback" {
+		if stepType != "query" && stepType != "httpcall" {
+			r.addError("%s: on_error 'fallback' is only supported for query and httpcall steps", prefix)
+		}
+		if cfg.Fallback == "" {
+			r.addError("%s: fallback is required when on_error is 'fallback'", prefix)
+		}
+	} else if cfg.Fallback != "" {
+		r.addError("%s: fallback requires on_error: fallback", prefix)
+	}
+
+	if cfg.Transform != nil {
+		if stepType != "query" {
+			r.addError("%s: transform is only supported for query steps", prefix)
+		}
+		validateTransform(cfg.Transform, prefix+".transform", r)
+	}
+
 	// Block validation: steps with nested steps cannot have type or leaf-specific fields
 	if cfg.IsBlock() {
 		if cfg.Type != "" {
@@ -320,56 +1102,324 @@ func validateStep(cfg *StepConfig, prefix string, stepIndex int, stepNames map[s
 		if cfg.SQL != "" {
 			r.addError("%s: step with nested steps cannot have sql", prefix)
 		}
+		if cfg.Proc != nil {
+			r.addError("%s: step with nested steps cannot have proc", prefix)
+		}
 		if cfg.URL != "" {
 			r.addError("%s: step with nested steps cannot have url", prefix)
 		}
 		if cfg.Template != "" {
 			r.addError("%s: step with nested steps cannot have template", prefix)
 		}
+		if len(cfg.Values) > 0 {
+			r.addError("%s: step with nested steps cannot have values", prefix)
+		}
+		if cfg.Script != "" {
+			r.addError("%s: step with nested steps cannot have script", prefix)
+		}
+		if len(cfg.To) > 0 || cfg.Subject != "" {
+			r.addError("%s: step with nested steps cannot have email fields", prefix)
+		}
+		if cfg.Provider != "" {
+			r.addError("%s: step with nested steps cannot have provider", prefix)
+		}
+		if cfg.Store != "" {
+			r.addError("%s: step with nested steps cannot have store", prefix)
+		}
+		if cfg.Server != "" {
+			r.addError("%s: step with nested steps cannot have server", prefix)
+		}
+		if cfg.Broker != "" {
+			r.addError("%s: step with nested steps cannot have broker", prefix)
+		}
+		if cfg.Table != "" {
+			r.addError("%s: step with nested steps cannot have table", prefix)
+		}
+	}
+
+	// Leaf step validation: iterate requires nested steps
+	if !cfg.IsBlock() && cfg.Iterate != nil {
+		r.addError("%s: iterate requires nested steps", prefix)
+	}
+
+	// Type-specific validation
+	switch stepType {
+	case "query":
+		validateQueryStep(cfg, prefix, ctx, r)
+		if cfg.Proc != nil {
+			validateProc(cfg.Proc, prefix+".proc", stepIndex, stepNames, aliases, r)
+		}
+	case "httpcall":
+		validateHTTPCallStep(cfg, prefix, r)
+	case "response":
+		validateResponseStep(cfg, prefix, r)
+	case "redirect":
+		validateRedirectStep(cfg, prefix, r)
+	case "page":
+		validatePageStep(cfg, prefix, r)
+	case "response_sse":
+		validateResponseSSEStep(cfg, prefix, stepIndex, stepNames, aliases, r)
+	case "cache_invalidate":
+		validateCacheInvalidateStep(cfg, prefix, r)
+	case "set":
+		validateSetStep(cfg, prefix, stepIndex, stepNames, aliases, r)
+	case "script":
+		validateScriptStep(cfg, prefix, r)
+	case "email":
+		validateEmailStep(cfg, prefix, ctx, r)
+	case "notify":
+		validateNotifyStep(cfg, prefix, r)
+	case "storage":
+		validateStorageStep(cfg, prefix, stepIndex, stepNames, aliases, ctx, r)
+	case "sftp":
+		validateSFTPStep(cfg, prefix, stepIndex, stepNames, aliases, ctx, r)
+	case "bulk_insert":
+		validateBulkInsertStep(cfg, prefix, stepIndex, stepNames, aliases, ctx, r)
+	case "mqtt":
+		validateMQTTStep(cfg, prefix, stepIndex, stepNames, aliases, ctx, r)
+	case "metric":
+		validateMetricStep(cfg, prefix, stepIndex, stepNames, aliases, r)
+	case "block":
+		validateBlockStep(cfg, prefix, stepNames, aliases, ctx, r)
+	}
+}
+
+func validateQueryStep(cfg *StepConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
+	if cfg.Database == "" {
+		r.addError("%s: database is required for query step", prefix)
+	} else if ctx != nil {
+		isReadOnly, exists := ctx.Databases[cfg.Database]
+		if !exists {
+			r.addError("%s: unknown database '%s'", prefix, cfg.Database)
+		} else if isReadOnly && sqlutil.RequiresWriteAccess(cfg.SQL) {
+			r.addError("%s: SQL contains write operation but database '%s' is read-only", prefix, cfg.Database)
+		}
+	}
+
+	switch {
+	case cfg.Proc != nil:
+		if cfg.SQL != "" {
+			r.addError("%s: sql and proc are mutually exclusive", prefix)
+		}
+		if ctx != nil && ctx.DatabaseTypes[cfg.Database] == "sqlite" {
+			r.addError("%s: database '%s' is sqlite, which does not support stored procedures", prefix, cfg.Database)
+		}
+		if cfg.Cache != nil {
+			r.addError("%s: cache is not supported with proc (output parameters and result sets are not cached)", prefix)
+		}
+	case cfg.SQL == "" && cfg.SQLFile != "":
+		r.addError("%s: sql_file '%s' is empty", prefix, cfg.SQLFile)
+	case cfg.SQL == "":
+		r.addError("%s: sql is required for query step", prefix)
+	case containsTemplateInterpolation(cfg.SQL):
+		r.addError("%s: SQL contains template interpolation ({{...}}) which is not allowed - use @param style parameters for safe parameterized queries", prefix)
+	}
+
+	if len(cfg.ResultSets) > 0 {
+		if cfg.Cache != nil && cfg.Proc == nil {
+			r.addError("%s: cache is not supported with result_sets (only the first result set is cached)", prefix)
+		}
+		seen := make(map[string]bool, len(cfg.ResultSets))
+		for i, name := range cfg.ResultSets {
+			if !setValueNameRegex.MatchString(name) {
+				r.addError("%s.result_sets[%d]: name must be a letter or underscore followed by letters, digits, or underscores", prefix, i)
+			} else if seen[name] {
+				r.addError("%s.result_sets[%d]: duplicate name '%s'", prefix, i, name)
+			}
+			seen[name] = true
+		}
+	}
+
+	if len(cfg.Outbox) > 0 {
+		if cfg.Proc != nil {
+			r.addError("%s: outbox is not supported with proc", prefix)
+		}
+		if cfg.Cache != nil {
+			r.addError("%s: cache is not supported with outbox (a cached result would skip the events)", prefix)
+		}
+		if ctx != nil && cfg.Database != "" && !ctx.Outboxes[cfg.Database] {
+			r.addError("%s: outbox requires an outbox section for database '%s'", prefix, cfg.Database)
+		}
+		for i, ev := range cfg.Outbox {
+			if strings.TrimSpace(ev.Topic) == "" {
+				r.addError("%s.outbox[%d]: topic is required", prefix, i)
+			}
+			for _, f := range []struct{ name, text string }{{"topic", ev.Topic}, {"key", ev.Key}, {"payload", ev.Payload}} {
+				if _, err := template.New(f.name).Funcs(TemplateFuncs).Parse(f.text); err != nil {
+					r.addError("%s.outbox[%d].%s: invalid template: %v", prefix, i, f.name, err)
+				}
+			}
+		}
+		if cfg.SQL != "" && !sqlutil.IsWriteQuery(cfg.SQL) {
+			r.addWarning("%s: outbox events are recorded for every run, but the SQL is not a write", prefix)
+		}
+	}
+
+	validateResultLimits(cfg.MaxRows, cfg.MaxResponseBytes, cfg.OnLimit, prefix, r)
+	if (cfg.MaxRows > 0 || cfg.MaxResponseBytes > 0) && cfg.SQL != "" && sqlutil.IsWriteQuery(cfg.SQL) && !sqlutil.HasReturningClause(cfg.SQL) {
+		r.addWarning("%s: max_rows and max_response_bytes have no effect on write queries without RETURNING or OUTPUT", prefix)
+	}
+	if cfg.OnLimit == "paginate" {
+		if cfg.Proc != nil || len(cfg.ResultSets) > 0 {
+			r.addError("%s: on_limit 'paginate' is not supported with proc or result_sets", prefix)
+		}
+		if cfg.SQL != "" && !orderByRegex.MatchString(cfg.SQL) {
+			r.addWarning("%s: on_limit 'paginate' without ORDER BY can return overlapping pages", prefix)
+		}
+	}
+	if cfg.PageOffset != "" && cfg.OnLimit != "" && cfg.OnLimit != "paginate" {
+		r.addError("%s: page_offset requires on_limit 'paginate'", prefix)
+	}
+
+	// Validate session settings
+	if cfg.Isolation != "" && !isValidIsolation(cfg.Isolation) {
+		r.addError("%s: invalid isolation level '%s'", prefix, cfg.Isolation)
+	}
+	if cfg.DeadlockPriority != "" && !isValidDeadlockPriority(cfg.DeadlockPriority) {
+		r.addError("%s: invalid deadlock_priority '%s'", prefix, cfg.DeadlockPriority)
+	}
+}
+
+// orderByRegex detects an ORDER BY clause, which stable pages need
+var orderByRegex = regexp.MustCompile(`(?i)\border\s+by\b`)
+
+// validateResultLimits checks max_rows, max_response_bytes, and on_limit, set on a
+// query step or as a workflow's defaults.
+func validateResultLimits(maxRows, maxBytes int, onLimit, prefix string, r *ValidationResult) {
+	if maxRows < 0 {
+		r.addError("%s: max_rows cannot be negative", prefix)
+	}
+	if maxBytes < 0 {
+		r.addError("%s: max_response_bytes cannot be negative", prefix)
+	}
+	if !ValidOnLimitValues[onLimit] {
+		r.addError("%s: on_limit must be 'error', 'truncate', or 'paginate'", prefix)
+	}
+}
+
+// validatePublicIDColumns checks the column names of public_id_columns, set on a
+// query step or as a workflow's defaults. Namespaces are checked against the
+// public_ids section by the config validator.
+func validatePublicIDColumns(columns map[string]string, prefix string, r *ValidationResult) {
+	for col := range columns {
+		if strings.TrimSpace(col) == "" {
+			r.addError("%s: public_id_columns has an empty column name", prefix)
+		}
 	}
+}
 
-	// Leaf step validation: iterate requires nested steps
-	if !cfg.IsBlock() && cfg.Iterate != nil {
-		r.addError("%s: iterate requires nested steps", prefix)
+// validateMaskColumns checks mask_columns and unmask_when, set on a query step
+// or as a workflow's defaults. Only a step may map a column to "" to opt out.
+func validateMaskColumns(columns map[string]string, unmaskWhen, prefix string, isStep bool, r *ValidationResult) {
+	for col, strategy := range columns {
+		if strings.TrimSpace(col) == "" {
+			r.addError("%s: mask_columns has an empty column name", prefix)
+		} else if !ValidMaskStrategies[strategy] && (strategy != "" || !isStep) {
+			r.addError("%s: mask_columns[%s] must be 'full', 'partial', 'hash', or 'last4'", prefix, col)
+		}
 	}
-
-	// Type-specific validation
-	switch stepType {
-	case "query":
-		validateQueryStep(cfg, prefix, ctx, r)
-	case "httpcall":
-		validateHTTPCallStep(cfg, prefix, r)
-	case "response":
-		validateResponseStep(cfg, prefix, r)
-	case "block":
-		validateBlockStep(cfg, prefix, stepNames, aliases, ctx, r)
+	if unmaskWhen != "" {
+		if err := validateExprSyntax(unmaskWhen); err != nil {
+			r.addError("%s.unmask_when: invalid expression: %v", prefix, err)
+		}
 	}
 }
 
-func validateQueryStep(cfg *StepConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
-	if cfg.Database == "" {
-		r.addError("%s: database is required for query step", prefix)
-	} else if ctx != nil {
-		isReadOnly, exists := ctx.Databases[cfg.Database]
-		if !exists {
-			r.addError("%s: unknown database '%s'", prefix, cfg.Database)
-		} else if isReadOnly && sqlutil.RequiresWriteAccess(cfg.SQL) {
-			r.addError("%s: SQL contains write operation but database '%s' is read-only", prefix, cfg.Database)
+// validateColumnPatterns checks the patterns of include_columns or exclude_columns
+func validateColumnPatterns(patterns []string, field, prefix string, r *ValidationResult) {
+	for _, p := range patterns {
+		if strings.TrimSpace(p) == "" {
+			r.addError("%s: %s has an empty pattern", prefix, field)
+		} else if _, err := path.Match(p, ""); err != nil {
+			r.addError("%s: %s has an invalid pattern '%s'", prefix, field, p)
 		}
 	}
+}
 
-	if cfg.SQL == "" {
-		r.addError("%s: sql is required for query step", prefix)
-	} else if containsTemplateInterpolation(cfg.SQL) {
-		r.addError("%s: SQL contains template interpolation ({{...}}) which is not allowed - use @param style parameters for safe parameterized queries", prefix)
+// selectStarRegex detects SELECT * and SELECT t.*, whose columns change with the table
+var selectStarRegex = regexp.MustCompile(`(?i)\bselect\s+(distinct\s+)?(\w+\.)?\*`)
+
+// validateColumnFilters warns about query steps whose include_columns and
+// exclude_columns, combined with the workflow's, defeat their purpose or drop a
+// column the step maps in public_id_columns, mask_columns, or json_columns.
+func validateColumnFilters(wf *WorkflowConfig, steps []StepConfig, prefix string, r *ValidationResult) {
+	for i := range steps {
+		cfg := &steps[i]
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		stepPrefix := fmt.Sprintf("%s.steps[%s]", prefix, name)
+		validateColumnFilters(wf, cfg.Steps, stepPrefix, r)
+		if cfg.StepType() != "query" {
+			continue
+		}
+
+		include, exclude := resolveColumnFilters(wf, cfg)
+		if len(include) == 0 && len(exclude) > 0 && selectStarRegex.MatchString(cfg.SQL) {
+			r.addWarning("%s: SELECT * with only exclude_columns returns any column added to the table later; list the columns in include_columns", stepPrefix)
+		}
+		mapped := map[string][]string{"json_columns": cfg.JSONColumns}
+		for field, columns := range map[string]map[string]string{"public_id_columns": cfg.PublicIDColumns, "mask_columns": cfg.MaskColumns} {
+			for col := range columns {
+				mapped[field] = append(mapped[field], col)
+			}
+		}
+		for field, columns := range mapped {
+			for _, col := range columns {
+				if col != "" && !columnKept(col, include, exclude) {
+					r.addWarning("%s: column '%s' in %s is removed by include_columns or exclude_columns", stepPrefix, col, field)
+				}
+			}
+		}
 	}
+}
 
-	// Validate session settings
-	if cfg.Isolation != "" && !isValidIsolation(cfg.Isolation) {
-		r.addError("%s: invalid isolation level '%s'", prefix, cfg.Isolation)
+// qualifiedNameRegex matches a procedure or table name with optional database and schema
+// qualifiers. Names are sent as-is, so quoting and whitespace are not allowed.
+var qualifiedNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$#]*(\.[A-Za-z_][A-Za-z0-9_$#]*){0,2}$`)
+
+func validateProc(proc *ProcConfig, prefix string, stepIndex int, stepNames map[string]int, aliases map[string]string, r *ValidationResult) {
+	if proc.Name == "" {
+		r.addError("%s: name is required", prefix)
+	} else if !qualifiedNameRegex.MatchString(proc.Name) {
+		r.addError("%s: invalid procedure name '%s' (use name, schema.name or database.schema.name)", prefix, proc.Name)
 	}
-	if cfg.DeadlockPriority != "" && !isValidDeadlockPriority(cfg.DeadlockPriority) {
-		r.addError("%s: invalid deadlock_priority '%s'", prefix, cfg.DeadlockPriority)
+
+	seen := make(map[string]bool, len(proc.Params))
+	for i, p := range proc.Params {
+		paramPrefix := fmt.Sprintf("%s.params[%d]", prefix, i)
+		if p.Name == "" {
+			r.addError("%s: name is required", paramPrefix)
+		} else if !setValueNameRegex.MatchString(p.Name) {
+			r.addError("%s: name must be a letter or underscore followed by letters, digits, or underscores", paramPrefix)
+		} else if seen[strings.ToLower(p.Name)] {
+			r.addError("%s: duplicate parameter '%s'", paramPrefix, p.Name)
+		}
+		seen[strings.ToLower(p.Name)] = true
+
+		if !ValidProcDirections[p.Direction] {
+			r.addError("%s: invalid direction '%s' (must be in, out, or inout)", paramPrefix, p.Direction)
+		}
+		if p.Type != "" && !ValidProcTypes[p.Type] {
+			r.addError("%s: invalid type '%s' (must be int, float, decimal, string, or bool)", paramPrefix, p.Type)
+		} else if p.Type == "" && (p.Direction == "out" || p.Direction == "inout") {
+			r.addError("%s: type is required for %s parameters", paramPrefix, p.Direction)
+		}
+
+		switch {
+		case p.Value == "" || (isSetTemplate(p.Value) && p.Direction != "out"):
+		case p.Direction == "out":
+			r.addError("%s: value is not allowed for out parameters", paramPrefix)
+		default:
+			if _, err := compileExpression(p.Value); err != nil {
+				r.addError("%s: invalid expression: %v", paramPrefix, err)
+			} else if err := ValidateDivisions(p.Value); err != nil {
+				r.addError("%s: invalid expression: %v", paramPrefix, err)
+			} else {
+				validateStepRefs(p.Value, paramPrefix, stepIndex, stepNames, aliases, r)
+			}
+		}
 	}
 }
 
@@ -386,16 +1436,21 @@ func validateHTTPCallStep(cfg *StepConfig, prefix string, r *ValidationResult) {
 		r.addError("%s: invalid parse mode '%s' (must be json, text, or form)", prefix, cfg.Parse)
 	}
 
-	if cfg.Retry != nil {
-		if cfg.Retry.MaxAttempts < 0 {
-			r.addError("%s.retry: max_attempts cannot be negative", prefix)
-		}
-		if cfg.Retry.InitialBackoffSec < 0 {
-			r.addError("%s.retry: initial_backoff_sec cannot be negative", prefix)
-		}
-		if cfg.Retry.MaxBackoffSec < 0 {
-			r.addError("%s.retry: max_backoff_sec cannot be negative", prefix)
-		}
+	validateRetry(cfg.Retry, prefix, r)
+}
+
+func validateRetry(retry *RetryConfig, prefix string, r *ValidationResult) {
+	if retry == nil {
+		return
+	}
+	if retry.MaxAttempts < 0 {
+		r.addError("%s.retry: max_attempts cannot be negative", prefix)
+	}
+	if retry.InitialBackoffSec < 0 {
+		r.addError("%s.retry: initial_backoff_sec cannot be negative", prefix)
+	}
+	if retry.MaxBackoffSec < 0 {
+		r.addError("%s.retry: max_backoff_sec cannot be negative", prefix)
 	}
 }
 
@@ -407,6 +1462,91 @@ func validateResponseStep(cfg *StepConfig, prefix string, r *ValidationResult) {
 	if cfg.StatusCode != 0 && (cfg.StatusCode < 100 || cfg.StatusCode > 599) {
 		r.addError("%s: status_code must be 100-599", prefix)
 	}
+
+	if !ValidTemplateTypes[cfg.TemplateType] {
+		r.addError("%s: invalid template_type '%s' (must be text or html)", prefix, cfg.TemplateType)
+	}
+
+	if cfg.Schema != nil {
+		if _, err := CompileSchema(cfg.Schema); err != nil {
+			r.addError("%s.schema: %v", prefix, err)
+		}
+		if cfg.TemplateType == "html" {
+			r.addError("%s: schema requires a JSON response and cannot be used with template_type: html", prefix)
+		}
+	}
+}
+
+func validateRedirectStep(cfg *StepConfig, prefix string, r *ValidationResult) {
+	if cfg.Location == "" {
+		r.addError("%s: location is required for redirect step", prefix)
+	} else if _, err := template.New("location").Funcs(TemplateFuncs).Parse(cfg.Location); err != nil {
+		r.addError("%s: invalid location template: %v", prefix, err)
+	}
+	if cfg.StatusCode != 0 && !RedirectStatusCodes[cfg.StatusCode] {
+		r.addError("%s: status_code must be 301, 302, 303, 307, or 308 for redirect step", prefix)
+	}
+	if cfg.Template != "" {
+		r.addError("%s: template is not supported for redirect steps (redirects have no body)", prefix)
+	}
+}
+
+func validatePageStep(cfg *StepConfig, prefix string, r *ValidationResult) {
+	if cfg.Template == "" {
+		r.addError("%s: template or template_file is required for page step", prefix)
+	}
+	if cfg.StatusCode != 0 && (cfg.StatusCode < 100 || cfg.StatusCode > 599) {
+		r.addError("%s: status_code must be 100-599", prefix)
+	}
+	if cfg.Schema != nil {
+		r.addError("%s: schema requires a JSON response and is not supported for page steps", prefix)
+	}
+}
+
+func validateResponseSSEStep(cfg *StepConfig, prefix string, stepIndex int, stepNames map[string]int, aliases map[string]string, r *ValidationResult) {
+	if (cfg.Template == "") == (cfg.Source == "") {
+		r.addError("%s: response_sse step requires exactly one of template or source", prefix)
+	}
+	if cfg.Source != "" {
+		if _, err := compileExpression(cfg.Source); err != nil {
+			r.addError("%s.source: invalid expression: %v", prefix, err)
+		} else {
+			validateStepRefs(cfg.Source, prefix+".source", stepIndex, stepNames, aliases, r)
+		}
+	}
+
+	if cfg.BatchSize < 0 {
+		r.addError("%s: batch_size cannot be negative", prefix)
+	} else if cfg.BatchSize > 0 && cfg.Source == "" {
+		r.addWarning("%s: batch_size has no effect without source", prefix)
+	}
+
+	switch {
+	case strings.ContainsAny(cfg.Event, "\r\n"):
+		r.addError("%s: event cannot contain line breaks", prefix)
+	case cfg.Event == sseEventComplete || cfg.Event == sseEventError:
+		r.addError("%s: event '%s' is reserved for the end of the stream", prefix, cfg.Event)
+	}
+
+	if cfg.StatusCode != 0 || len(cfg.Headers) > 0 {
+		r.addError("%s: status_code and headers are not supported for response_sse steps (the stream is always 200)", prefix)
+	}
+}
+
+func validateCacheInvalidateStep(cfg *StepConfig, prefix string, r *ValidationResult) {
+	if len(cfg.Tags) == 0 {
+		r.addError("%s: tags is required for cache_invalidate step", prefix)
+	}
+	validateCacheTags(cfg.Tags, prefix+".tags", r)
+}
+
+// validateCacheTags checks that cache tag templates are non-empty
+func validateCacheTags(tags []string, prefix string, r *ValidationResult) {
+	for i, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			r.addError("%s[%d]: tag cannot be empty", prefix, i)
+		}
+	}
 }
 
 func validateBlockStep(cfg *StepConfig, prefix string, parentStepNames map[string]int, aliases map[string]string, ctx *ValidationContext, r *ValidationResult) {
@@ -452,6 +1592,11 @@ func validateBlockStep(cfg *StepConfig, prefix string, parentStepNames map[strin
 			continue
 		}
 
+		// when is evaluated against the whole run, so it only applies to top-level steps
+		if step.When != "" {
+			r.addError("%s: when is not supported in blocks (use condition)", stepPrefix)
+		}
+
 		validateStep(&step, stepPrefix, i, nestedNames, aliases, ctx, r)
 	}
 
@@ -468,6 +1613,345 @@ func validateBlockStep(cfg *StepConfig, prefix string, parentStepNames map[strin
 	}
 }
 
+// setValueNameRegex matches value names usable as steps.<name>.<value> in expressions.
+var setValueNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedSetValueNames are step result fields that set values cannot shadow.
+var reservedSetValueNames = map[string]bool{
+	"name": true, "type": true, "success": true, "error": true, "duration_ms": true,
+	"cache_hit": true, "timed_out": true, "fallback": true,
+}
+
+func validateTransform(t *TransformConfig, prefix string, r *ValidationResult) {
+	for from, to := range t.Rename {
+		if from == "" || to == "" {
+			r.addError("%s.rename: column names cannot be empty", prefix)
+		}
+	}
+	if t.GroupBy != "" && t.NestAs == "" {
+		r.addError("%s: group_by requires nest_as", prefix)
+	}
+	if t.NestAs != "" && t.GroupBy == "" {
+		r.addError("%s: nest_as requires group_by", prefix)
+	}
+	if t.GroupBy != "" && t.GroupBy == t.NestAs {
+		r.addError("%s: nest_as must differ from group_by", prefix)
+	}
+	for _, col := range t.Exclude {
+		if col != "" && col == t.GroupBy {
+			r.addError("%s: cannot exclude the group_by column '%s'", prefix, col)
+		}
+	}
+}
+
+func validateSetStep(cfg *StepConfig, prefix string, stepIndex int, stepNames map[string]int, aliases map[string]string, r *ValidationResult) {
+	if len(cfg.Values) == 0 {
+		r.addError("%s: values is required for set step", prefix)
+	}
+
+	for name, val := range cfg.Values {
+		valPrefix := fmt.Sprintf("%s.values[%s]", prefix, name)
+		if !setValueNameRegex.MatchString(name) {
+			r.addError("%s: name must be a letter or underscore followed by letters, digits, or underscores", valPrefix)
+		} else if reservedSetValueNames[name] {
+			r.addError("%s: name is reserved for step results", valPrefix)
+		}
+		if isSetTemplate(val) {
+			continue
+		}
+		if _, err := compileExpression(val); err != nil {
+			r.addError("%s: invalid expression: %v", valPrefix, err)
+		} else if err := ValidateDivisions(val); err != nil {
+			r.addError("%s: invalid expression: %v", valPrefix, err)
+		} else {
+			validateStepRefs(val, valPrefix, stepIndex, stepNames, aliases, r)
+		}
+	}
+}
+
+func validateScriptStep(cfg *StepConfig, prefix string, r *ValidationResult) {
+	if cfg.Script == "" {
+		r.addError("%s: script is required for script step", prefix)
+	} else if _, err := compileScript(cfg.Name, cfg.Script); err != nil {
+		r.addError("%s.script: invalid script: %v", prefix, err)
+	}
+
+	if cfg.MemoryMB < 0 {
+		r.addError("%s: memory_mb cannot be negative", prefix)
+	}
+}
+
+func validateEmailStep(cfg *StepConfig, prefix string, ctx *ValidationContext, r *ValidationResult) {
+	if ctx != nil && !ctx.SMTP {
+		r.addError("%s: email step requires smtp to be configured", prefix)
+	}
+	if len(cfg.To) == 0 {
+		r.addError("%s: to is required for email step", prefix)
+	}
+	if cfg.Subject == "" {
+		r.addError("%s: subject is required for email step", prefix)
+	}
+	if cfg.Text == "" && cfg.HTML == "" {
+		r.addError("%s: text or html is required for email step", prefix)
+	}
+	if _, err := compileEmail(cfg); err != nil {
+		r.addError("%s: %v", prefix, err)
+	}
+}
+
+func validateNotifyStep(cfg *StepConfig, prefix string, r *ValidationResult) {
+	if cfg.Provider == "" {
+		r.addError("%s: provider is required for notify step", prefix)
+	} else if !ValidNotifyProviders[cfg.Provider] {
+		r.addError("%s: invalid provider '%s' (must be slack, teams, or webhook)", prefix, cfg.Provider)
+	}
+	if cfg.URL == "" {
+		r.addError("%s: url is required for notify step", prefix)
+	}
+	if cfg.Text == "" && cfg.Blocks == "" {
+		r.addError("%s: text or blocks is required for notify step", prefix)
+	}
+	if !ValidNotifyWhen[cfg.When] {
+		r.addError("%s: invalid when '%s' (must be always, failure, or success)", prefix, cfg.When)
+	}
+	for _, f := range []struct{ name, text string }{
+		{"url", cfg.URL}, {"title", cfg.Title}, {"text", cfg.Text}, {"blocks", cfg.Blocks},
+	} {
+		if _, err := template.New(f.name).Funcs(TemplateFuncs).Parse(f.text); err != nil {
+			r.addError("%s.%s: invalid template: %v", prefix, f.name, err)
+		}
+	}
+}
+
+func validateStorageStep(cfg *StepConfig, prefix string, stepIndex int, stepNames map[string]int, aliases map[string]string, ctx *ValidationContext, r *ValidationResult) {
+	if cfg.Store == "" {
+		r.addError("%s: store is required for storage step", prefix)
+	} else if ctx != nil && !ctx.Stores[cfg.Store] {
+		r.addError("%s: unknown store '%s'", prefix, cfg.Store)
+	}
+	if cfg.Bucket == "" {
+		r.addError("%s: bucket is required for storage step", prefix)
+	}
+	if cfg.Key == "" {
+		r.addError("%s: key is required for storage step", prefix)
+	}
+
+	validateStepContent(cfg, "storage", prefix, stepIndex, stepNames, aliases, r)
+
+	if !ValidStorageEncryptions[cfg.Encryption] {
+		r.addError("%s: invalid encryption '%s' (must be AES256 or aws:kms)", prefix, cfg.Encryption)
+	}
+	if cfg.KMSKeyID != "" && cfg.Encryption != "aws:kms" {
+		r.addError("%s: kms_key_id requires encryption: aws:kms", prefix)
+	}
+
+	for _, f := range []struct{ name, text string }{
+		{"bucket", cfg.Bucket}, {"key", cfg.Key}, {"body", cfg.Body},
+	} {
+		if _, err := template.New(f.name).Funcs(TemplateFuncs).Parse(f.text); err != nil {
+			r.addError("%s.%s: invalid template: %v", prefix, f.name, err)
+		}
+	}
+}
+
+func validateSFTPStep(cfg *StepConfig, prefix string, stepIndex int, stepNames map[string]int, aliases map[string]string, ctx *ValidationContext, r *ValidationResult) {
+	if cfg.Server == "" {
+		r.addError("%s: server is required for sftp step", prefix)
+	} else if ctx != nil && !ctx.SFTPServers[cfg.Server] {
+		r.addError("%s: unknown sftp server '%s'", prefix, cfg.Server)
+	}
+	if cfg.RemotePath == "" {
+		r.addError("%s: remote_path is required for sftp step", prefix)
+	}
+
+	validateStepContent(cfg, "sftp", prefix, stepIndex, stepNames, aliases, r)
+	validateRetry(cfg.Retry, prefix, r)
+
+	for _, f := range []struct{ name, text string }{
+		{"remote_path", cfg.RemotePath}, {"body", cfg.Body},
+	} {
+		if _, err := template.New(f.name).Funcs(TemplateFuncs).Parse(f.text); err != nil {
+			r.addError("%s.%s: invalid template: %v", prefix, f.name, err)
+		}
+	}
+}
+
+func validateMQTTStep(cfg *StepConfig, prefix string, stepIndex int, stepNames map[string]int, aliases map[string]string, ctx *ValidationContext, r *ValidationResult) {
+	if cfg.Broker == "" {
+		r.addError("%s: broker is required for mqtt step", prefix)
+	} else if ctx != nil && !ctx.MQTTBrokers[cfg.Broker] {
+		r.addError("%s: unknown mqtt broker '%s'", prefix, cfg.Broker)
+	}
+	if cfg.Topic == "" {
+		r.addError("%s: topic is required for mqtt step", prefix)
+	} else if !strings.Contains(cfg.Topic, "{{") {
+		if err := mqtt.ValidateTopic(cfg.Topic); err != nil {
+			r.addError("%s: invalid topic '%s': %v", prefix, cfg.Topic, err)
+		}
+	}
+	if cfg.QoS < 0 || cfg.QoS > 1 {
+		r.addError("%s: qos must be 0 or 1", prefix)
+	}
+
+	validateStepContent(cfg, "mqtt", prefix, stepIndex, stepNames, aliases, r)
+
+	for _, f := range []struct{ name, text string }{
+		{"topic", cfg.Topic}, {"body", cfg.Body},
+	} {
+		if _, err := template.New(f.name).Funcs(TemplateFuncs).Parse(f.text); err != nil {
+			r.addError("%s.%s: invalid template: %v", prefix, f.name, err)
+		}
+	}
+}
+
+// Metric and label names of metric steps
+var (
+	metricNameRegex  = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	metricLabelRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// reservedMetricPrefixes belong to sql-proxy's own metrics and the Go and process collectors
+var reservedMetricPrefixes = []string{"sqlproxy_", "go_", "process_"}
+
+// ValidMetricTypes are the types a metric step can record
+var ValidMetricTypes = []string{metrics.KindGauge, metrics.KindCounter, metrics.KindHistogram}
+
+func validateMetricStep(cfg *StepConfig, prefix string, stepIndex int, stepNames map[string]int, aliases map[string]string, r *ValidationResult) {
+	m := cfg.Metric
+	if m == nil {
+		r.addError("%s: metric is required for metric step", prefix)
+		return
+	}
+	prefix += ".metric"
+
+	if m.Name == "" {
+		r.addError("%s: name is required", prefix)
+	} else if !metricNameRegex.MatchString(m.Name) {
+		r.addError("%s: invalid name '%s' (letters, digits, underscores and colons, not starting with a digit)", prefix, m.Name)
+	} else {
+		for _, reserved := range reservedMetricPrefixes {
+			if strings.HasPrefix(m.Name, reserved) {
+				r.addError("%s: name '%s' uses the reserved prefix '%s'", prefix, m.Name, reserved)
+			}
+		}
+	}
+
+	switch m.Type {
+	case "":
+		r.addError("%s: type is required (%s)", prefix, strings.Join(ValidMetricTypes, ", "))
+	case metrics.KindCounter:
+		if !strings.HasSuffix(m.Name, "_total") {
+			r.addWarning("%s: counter names conventionally end in _total", prefix)
+		}
+	case metrics.KindGauge, metrics.KindHistogram:
+		if m.Value == "" {
+			r.addError("%s: value is required for a %s", prefix, m.Type)
+		}
+	default:
+		r.addError("%s: invalid type '%s' (must be one of: %s)", prefix, m.Type, strings.Join(ValidMetricTypes, ", "))
+	}
+
+	if m.Value != "" {
+		if _, err := compileExpression(m.Value); err != nil {
+			r.addError("%s.value: invalid expression: %v", prefix, err)
+		} else if err := ValidateDivisions(m.Value); err != nil {
+			r.addError("%s.value: invalid expression: %v", prefix, err)
+		} else {
+			validateStepRefs(m.Value, prefix+".value", stepIndex, stepNames, aliases, r)
+		}
+	}
+
+	for name, val := range m.Labels {
+		if !metricLabelRegex.MatchString(name) || strings.HasPrefix(name, "__") {
+			r.addError("%s.labels: invalid label name '%s'", prefix, name)
+		}
+		if _, err := template.New(name).Funcs(TemplateFuncs).Parse(val); err != nil {
+			r.addError("%s.labels[%s]: invalid template: %v", prefix, name, err)
+		}
+	}
+
+	if len(m.Buckets) > 0 {
+		if m.Type != metrics.KindHistogram {
+			r.addError("%s: buckets are only supported for histograms", prefix)
+		}
+		for i := 1; i < len(m.Buckets); i++ {
+			if m.Buckets[i] <= m.Buckets[i-1] {
+				r.addError("%s: buckets must be in increasing order", prefix)
+				break
+			}
+		}
+	}
+}
+
+// validateBulkInsertStep checks the database, table, source, columns and batching of a bulk_insert step.
+func validateBulkInsertStep(cfg *StepConfig, prefix string, stepIndex int, stepNames map[string]int, aliases map[string]string, ctx *ValidationContext, r *ValidationResult) {
+	if cfg.Database == "" {
+		r.addError("%s: database is required for bulk_insert step", prefix)
+	} else if ctx != nil {
+		isReadOnly, exists := ctx.Databases[cfg.Database]
+		if !exists {
+			r.addError("%s: unknown database '%s'", prefix, cfg.Database)
+		} else if isReadOnly {
+			r.addError("%s: database '%s' is read-only", prefix, cfg.Database)
+		}
+	}
+
+	if cfg.Table == "" {
+		r.addError("%s: table is required for bulk_insert step", prefix)
+	} else if !qualifiedNameRegex.MatchString(cfg.Table) {
+		r.addError("%s: invalid table name '%s' (use table, schema.table or database.schema.table)", prefix, cfg.Table)
+	}
+
+	if cfg.Source == "" {
+		r.addError("%s: source is required for bulk_insert step", prefix)
+	} else if _, err := compileExpression(cfg.Source); err != nil {
+		r.addError("%s.source: invalid expression: %v", prefix, err)
+	} else {
+		validateStepRefs(cfg.Source, prefix+".source", stepIndex, stepNames, aliases, r)
+	}
+
+	seen := make(map[string]bool, len(cfg.Columns))
+	for i, col := range cfg.Columns {
+		if !columnNameRegex.MatchString(col) {
+			r.addError("%s.columns[%d]: invalid column name '%s'", prefix, i, col)
+		} else if seen[strings.ToLower(col)] {
+			r.addError("%s.columns[%d]: duplicate column '%s'", prefix, i, col)
+		}
+		seen[strings.ToLower(col)] = true
+	}
+
+	if cfg.BatchSize < 0 {
+		r.addError("%s: batch_size cannot be negative", prefix)
+	} else if cfg.BatchSize > maxBulkInsertRows {
+		r.addWarning("%s: batch_size %d exceeds %d; batches are capped at %d rows", prefix, cfg.BatchSize, maxBulkInsertRows, maxBulkInsertRows)
+	}
+	if !ValidBatchErrorPolicies[cfg.OnBatchError] {
+		r.addError("%s: invalid on_batch_error '%s' (must be abort or continue)", prefix, cfg.OnBatchError)
+	}
+}
+
+// validateStepContent checks the body or source (with format and columns) of storage, sftp, and mqtt steps.
+func validateStepContent(cfg *StepConfig, stepType, prefix string, stepIndex int, stepNames map[string]int, aliases map[string]string, r *ValidationResult) {
+	if (cfg.Body == "") == (cfg.Source == "") {
+		r.addError("%s: exactly one of body or source is required for %s step", prefix, stepType)
+	}
+	if cfg.Source != "" {
+		if _, err := compileExpression(cfg.Source); err != nil {
+			r.addError("%s.source: invalid expression: %v", prefix, err)
+		} else {
+			validateStepRefs(cfg.Source, prefix+".source", stepIndex, stepNames, aliases, r)
+		}
+	} else if cfg.Format != "" {
+		r.addError("%s: format requires source", prefix)
+	}
+	if !ValidStorageFormats[cfg.Format] {
+		r.addError("%s: invalid format '%s' (must be json, ndjson, or csv)", prefix, cfg.Format)
+	}
+	if len(cfg.Columns) > 0 && cfg.Format != "csv" {
+		r.addError("%s: columns requires format: csv", prefix)
+	}
+}
+
 // minEveryInterval is the shortest interval an @every descriptor can express.
 const minEveryInterval = time.Second
 
@@ -621,11 +2105,65 @@ func containsTemplateInterpolation(sql string) bool {
 // Helper validation functions
 
 var validParamTypes = map[string]bool{
-	"string": true, "int": true, "integer": true, "float": true, "double": true,
+	"string": true, "int": true, "integer": true, "float": true, "double": true, "decimal": true,
 	"bool": true, "boolean": true, "datetime": true, "date": true, "json": true,
 	"int[]": true, "string[]": true, "float[]": true, "bool[]": true,
 }
 
+// validateParamRules checks that a parameter's validation rules are well formed and fit its type.
+func validateParamRules(p *ParamConfig, prefix string, r *ValidationResult) {
+	v := p.Validation
+	typ := paramType(p)
+	baseType := types.ArrayBaseType(typ)
+	isArray := types.IsArrayType(typ)
+
+	numeric := baseType == "int" || baseType == "integer" || baseType == "float" || baseType == "double" || typ == "decimal"
+	if (v.Min != nil || v.Max != nil) && !numeric {
+		r.addError("%s: min and max require a numeric type (int, float, decimal, or an array of them)", prefix)
+	}
+	if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+		r.addError("%s: min cannot be greater than max", prefix)
+	}
+
+	if (v.MinLength != nil || v.MaxLength != nil) && typ != "string" && !isArray {
+		r.addError("%s: min_length and max_length require a string or array type", prefix)
+	}
+	if (v.MinLength != nil && *v.MinLength < 0) || (v.MaxLength != nil && *v.MaxLength < 0) {
+		r.addError("%s: min_length and max_length cannot be negative", prefix)
+	}
+	if v.MinLength != nil && v.MaxLength != nil && *v.MinLength > *v.MaxLength {
+		r.addError("%s: min_length cannot be greater than max_length", prefix)
+	}
+
+	if v.Pattern != "" {
+		if baseType != "string" {
+			r.addError("%s: pattern requires a string type (string or string[])", prefix)
+		} else if _, err := regexp.Compile(v.Pattern); err != nil {
+			r.addError("%s: invalid pattern: %v", prefix, err)
+		}
+	}
+
+	if len(v.Enum) > 0 {
+		if typ == "json" {
+			r.addError("%s: enum is not supported for json parameters (use expr)", prefix)
+		} else {
+			for _, allowed := range v.Enum {
+				if _, err := types.ConvertValue(allowed, baseType); err != nil {
+					r.addError("%s: enum value '%s' is not a valid %s", prefix, allowed, baseType)
+				}
+			}
+		}
+	}
+
+	if v.Expr != "" {
+		if _, err := compileExprWithType(v.Expr, true); err != nil {
+			r.addError("%s: invalid expr: %v", prefix, err)
+		}
+	} else if v.Message != "" {
+		r.addError("%s: message requires expr", prefix)
+	}
+}
+
 func isValidParamType(t string) bool {
 	return validParamTypes[strings.ToLower(t)]
 }
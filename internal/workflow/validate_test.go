@@ -1,6 +1,9 @@
 package workflow
 
 import (
+	"cmp"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -87,6 +90,158 @@ func TestValidate_MissingSteps(t *testing.T) {
 	}
 }
 
+func TestValidate_MaxConcurrent(t *testing.T) {
+	cfg := &WorkflowConfig{
+		Name:                "test",
+		MaxConcurrent:       -1,
+		MaxConcurrentWaitMs: -5,
+		Triggers:            []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+		Steps:               []StepConfig{{Type: "response", Template: "{}"}},
+	}
+
+	result := Validate(cfg, nil)
+	if !containsError(result.Errors, "max_concurrent cannot be negative") {
+		t.Errorf("expected max_concurrent error, got: %v", result.Errors)
+	}
+	if !containsError(result.Errors, "max_concurrent_wait_ms cannot be negative") {
+		t.Errorf("expected max_concurrent_wait_ms error, got: %v", result.Errors)
+	}
+
+	cfg.MaxConcurrent = 0
+	cfg.MaxConcurrentWaitMs = 100
+	result = Validate(cfg, nil)
+	if !result.Valid {
+		t.Errorf("expected valid config, got: %v", result.Errors)
+	}
+	if !containsError(result.Warnings, "has no effect without max_concurrent") {
+		t.Errorf("expected wait warning, got: %v", result.Warnings)
+	}
+}
+
+func TestValidate_ResultLimits(t *testing.T) {
+	cfg := &WorkflowConfig{
+		Name:             "test",
+		MaxRows:          -1,
+		MaxResponseBytes: -1,
+		OnLimit:          "drop",
+		Triggers:         []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+		Steps:            []StepConfig{{Type: "response", Template: "{}"}},
+	}
+
+	result := Validate(cfg, nil)
+	for _, want := range []string{"max_rows cannot be negative", "max_response_bytes cannot be negative", "on_limit must be"} {
+		if !containsError(result.Errors, want) {
+			t.Errorf("expected error containing %q, got: %v", want, result.Errors)
+		}
+	}
+
+	cfg.MaxRows = 100
+	cfg.MaxResponseBytes = 0
+	cfg.OnLimit = "truncate"
+	cfg.Steps = []StepConfig{
+		{Name: "page", Database: "db", SQL: "SELECT * FROM t", OnLimit: "paginate", PageOffset: "{{.trigger.params.offset}}"},
+		{Name: "write", Database: "db", SQL: "UPDATE t SET a = 1", MaxRows: 10},
+		{Name: "returning", Database: "db", SQL: "UPDATE t SET a = 2 RETURNING id", MaxRows: 10},
+		{Type: "response", Template: "{}"},
+	}
+	result = Validate(cfg, nil)
+	if !result.Valid {
+		t.Fatalf("expected valid config, got: %v", result.Errors)
+	}
+	if !containsWarning(result.Warnings, "without ORDER BY can return overlapping pages") {
+		t.Errorf("expected ORDER BY warning, got: %v", result.Warnings)
+	}
+	if !containsWarning(result.Warnings, "steps[write]: max_rows and max_response_bytes have no effect") {
+		t.Errorf("expected write query warning, got: %v", result.Warnings)
+	}
+	// Limits cut the rows a write returns
+	if containsWarning(result.Warnings, "steps[returning]: max_rows") {
+		t.Errorf("expected no warning for a write with RETURNING, got: %v", result.Warnings)
+	}
+}
+
+func TestValidate_MaskColumns(t *testing.T) {
+	cfg := &WorkflowConfig{
+		Name:        "test",
+		MaskColumns: map[string]string{"email": "", "": "full"},
+		UnmaskWhen:  "role ==",
+		Triggers:    []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+		Steps:       []StepConfig{{Type: "response", Template: "{}"}},
+	}
+
+	result := Validate(cfg, nil)
+	for _, want := range []string{"mask_columns[email] must be", "mask_columns has an empty column name", "unmask_when: invalid expression"} {
+		if !containsError(result.Errors, want) {
+			t.Errorf("expected error containing %q, got: %v", want, result.Errors)
+		}
+	}
+
+	cfg.MaskColumns = nil
+	cfg.UnmaskWhen = `trigger.params.role == "admin"`
+	cfg.Steps = []StepConfig{
+		{Name: "users", Database: "db", SQL: "SELECT * FROM users", MaskColumns: map[string]string{"email": "partial", "phone": ""}, UnmaskWhen: "true", Cache: &StepCacheConfig{Key: "users"}},
+		{Type: "response", Template: "{}"},
+	}
+	result = Validate(cfg, nil)
+	if !result.Valid {
+		t.Fatalf("expected valid config, got: %v", result.Errors)
+	}
+	if !containsWarning(result.Warnings, "unmask_when has no effect without mask_columns") {
+		t.Errorf("expected unmask_when warning, got: %v", result.Warnings)
+	}
+	if !containsWarning(result.Warnings, "shared by masked and unmasked callers") {
+		t.Errorf("expected cache warning, got: %v", result.Warnings)
+	}
+}
+
+func TestValidate_ColumnFilters(t *testing.T) {
+	cfg := &WorkflowConfig{
+		Name:           "test",
+		IncludeColumns: []string{"[a-"},
+		ExcludeColumns: []string{" "},
+		Triggers:       []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+		Steps: []StepConfig{
+			{Name: "call", Type: "httpcall", URL: "http://example.com", ExcludeColumns: []string{"x"}},
+			{Type: "response", Template: "{}"},
+		},
+	}
+
+	result := Validate(cfg, nil)
+	for _, want := range []string{
+		"include_columns has an invalid pattern '[a-'",
+		"exclude_columns has an empty pattern",
+		"steps[call]: include_columns and exclude_columns are only supported for query steps",
+	} {
+		if !containsError(result.Errors, want) {
+			t.Errorf("expected error containing %q, got: %v", want, result.Errors)
+		}
+	}
+
+	cfg.IncludeColumns = nil
+	cfg.ExcludeColumns = []string{"*_hash"}
+	cfg.Steps = []StepConfig{
+		{Name: "users", Database: "db", SQL: "SELECT u.* FROM users u", MaskColumns: map[string]string{"password_hash": "full"}},
+		{Name: "orders", Database: "db", SQL: "SELECT * FROM orders", IncludeColumns: []string{"id", "total"}, JSONColumns: []string{"lines"}},
+		{Type: "response", Template: "{}"},
+	}
+	result = Validate(cfg, nil)
+	if !result.Valid {
+		t.Fatalf("expected valid config, got: %v", result.Errors)
+	}
+	for _, want := range []string{
+		"steps[users]: SELECT * with only exclude_columns",
+		"steps[users]: column 'password_hash' in mask_columns is removed",
+		"steps[orders]: column 'lines' in json_columns is removed",
+	} {
+		if !containsWarning(result.Warnings, want) {
+			t.Errorf("expected warning containing %q, got: %v", want, result.Warnings)
+		}
+	}
+	if containsWarning(result.Warnings, "steps[orders]: SELECT *") {
+		t.Errorf("unexpected SELECT * warning with include_columns: %v", result.Warnings)
+	}
+}
+
 func TestValidate_HTTPTrigger(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -118,6 +273,26 @@ func TestValidate_HTTPTrigger(t *testing.T) {
 			trigger:     TriggerConfig{Type: "http", Path: "/test", Method: "INVALID"},
 			expectError: "method must be GET, POST, PUT, DELETE, PATCH, HEAD, or OPTIONS",
 		},
+		{
+			name:        "invalid method in methods list",
+			trigger:     TriggerConfig{Type: "http", Path: "/test", Methods: []string{"GET", "FETCH"}},
+			expectError: "triggers[0].methods[1]: method must be GET",
+		},
+		{
+			name:        "reserved path in paths list",
+			trigger:     TriggerConfig{Type: "http", Paths: []string{"/test", "/_/test"}, Method: "GET"},
+			expectError: "triggers[0].paths[1]: path cannot start with '/_/'",
+		},
+		{
+			name:        "method and methods together",
+			trigger:     TriggerConfig{Type: "http", Path: "/test", Method: "GET", Methods: []string{"POST"}},
+			expectError: "method and methods are mutually exclusive",
+		},
+		{
+			name:        "path and paths together",
+			trigger:     TriggerConfig{Type: "http", Path: "/test", Paths: []string{"/other"}, Method: "GET"},
+			expectError: "path and paths are mutually exclusive",
+		},
 	}
 
 	for _, tt := range tests {
@@ -138,274 +313,1789 @@ func TestValidate_HTTPTrigger(t *testing.T) {
 	}
 }
 
-func TestValidate_CronTrigger(t *testing.T) {
+func TestValidate_MultiRouteTrigger(t *testing.T) {
+	t.Run("valid methods and paths", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name: "test",
+			Triggers: []TriggerConfig{{
+				Type:       "http",
+				Paths:      []string{"/api/items/{id}", "/api/v2/items/{id}"},
+				Methods:    []string{"GET", "POST"},
+				Parameters: []ParamConfig{{Name: "id", Type: "int", Required: true}},
+			}},
+			Steps: []StepConfig{{Type: "response", Template: "{}"}},
+		}
+		result := Validate(cfg, nil)
+		if !result.Valid {
+			t.Errorf("expected valid workflow, got errors: %v", result.Errors)
+		}
+	})
+
+	t.Run("duplicate method within trigger", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name:     "test",
+			Triggers: []TriggerConfig{{Type: "http", Path: "/test", Methods: []string{"GET", "GET"}}},
+			Steps:    []StepConfig{{Type: "response", Template: "{}"}},
+		}
+		result := Validate(cfg, nil)
+		if !containsError(result.Errors, "duplicate route 'GET /test'") {
+			t.Errorf("expected duplicate route error, got: %v", result.Errors)
+		}
+	})
+
+	t.Run("clash with another trigger", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name: "test",
+			Triggers: []TriggerConfig{
+				{Type: "http", Path: "/test", Methods: []string{"GET", "POST"}},
+				{Type: "http", Paths: []string{"/other", "/test"}, Method: "POST"},
+			},
+			Steps: []StepConfig{{Type: "response", Template: "{}"}},
+		}
+		result := Validate(cfg, nil)
+		if !containsError(result.Errors, "triggers[1]: duplicate route 'POST /test'") {
+			t.Errorf("expected duplicate route error, got: %v", result.Errors)
+		}
+	})
+
+	t.Run("path parameter missing in one path", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name: "test",
+			Triggers: []TriggerConfig{{
+				Type:   "http",
+				Paths:  []string{"/api/items", "/api/items/{id}"},
+				Method: "GET",
+			}},
+			Steps: []StepConfig{{Type: "response", Template: "{}"}},
+		}
+		result := Validate(cfg, nil)
+		if !containsError(result.Errors, "path parameter '{id}' must be defined") {
+			t.Errorf("expected undefined path parameter error, got: %v", result.Errors)
+		}
+	})
+}
+
+func TestValidate_WebSocketTrigger(t *testing.T) {
 	tests := []struct {
 		name        string
-		trigger     TriggerConfig
+		triggers    []TriggerConfig
+		steps       []StepConfig
 		expectError string
 	}{
 		{
-			name:        "missing schedule",
-			trigger:     TriggerConfig{Type: "cron"},
-			expectError: "schedule is required",
+			name:        "missing path",
+			triggers:    []TriggerConfig{{Type: "websocket"}},
+			expectError: "path is required for websocket trigger",
 		},
 		{
-			name:        "invalid cron expression",
-			trigger:     TriggerConfig{Type: "cron", Schedule: "invalid"},
-			expectError: "invalid schedule",
+			name:        "reserved path prefix",
+			triggers:    []TriggerConfig{{Type: "websocket", Path: "/_/ws"}},
+			expectError: "path cannot start with '/_/'",
+		},
+		{
+			name:        "paths list",
+			triggers:    []TriggerConfig{{Type: "websocket", Paths: []string{"/a", "/b"}}},
+			expectError: "paths is not supported for websocket triggers",
+		},
+		{
+			name:        "non-GET method",
+			triggers:    []TriggerConfig{{Type: "websocket", Path: "/ws", Method: "POST"}},
+			expectError: "websocket triggers are opened with GET",
+		},
+		{
+			name:        "cache",
+			triggers:    []TriggerConfig{{Type: "websocket", Path: "/ws", Cache: &CacheConfig{Enabled: true, Key: "k"}}},
+			expectError: "cache is not supported for websocket triggers",
+		},
+		{
+			name:        "negative max_message_bytes",
+			triggers:    []TriggerConfig{{Type: "websocket", Path: "/ws", MaxMessageBytes: -1}},
+			expectError: "max_message_bytes cannot be negative",
+		},
+		{
+			name:        "invalid origin",
+			triggers:    []TriggerConfig{{Type: "websocket", Path: "/ws", Origins: []string{"*", "app.example.com"}}},
+			expectError: "origins[1]: must be '*' or a scheme and host",
+		},
+		{
+			name:        "undefined path parameter",
+			triggers:    []TriggerConfig{{Type: "websocket", Path: "/ws/{room}"}},
+			expectError: "path parameter '{room}' must be defined",
+		},
+		{
+			name:        "clash with GET route",
+			triggers:    []TriggerConfig{{Type: "http", Path: "/ws", Method: "GET"}, {Type: "websocket", Path: "/ws"}},
+			expectError: "triggers[1]: duplicate route 'GET /ws'",
+		},
+		{
+			name:        "response_sse",
+			triggers:    []TriggerConfig{{Type: "websocket", Path: "/ws"}},
+			steps:       []StepConfig{{Name: "e", Type: "response_sse", Template: "{}"}},
+			expectError: "response_sse steps cannot be used with websocket triggers",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cfg := &WorkflowConfig{
-				Name:     "test",
-				Triggers: []TriggerConfig{tt.trigger},
-				Steps:    []StepConfig{{Name: "run", Type: "query", Database: "db", SQL: "SELECT 1"}},
-			}
-			ctx := &ValidationContext{Databases: map[string]bool{"db": true}}
-			result := Validate(cfg, ctx)
-			if result.Valid {
-				t.Error("expected validation to fail")
+			steps := tt.steps
+			if steps == nil {
+				steps = []StepConfig{{Type: "response", Template: "{}"}}
 			}
+			result := Validate(&WorkflowConfig{Name: "test", Triggers: tt.triggers, Steps: steps}, nil)
 			if !containsError(result.Errors, tt.expectError) {
 				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
 			}
 		})
 	}
 
-	t.Run("valid cron expression", func(t *testing.T) {
+	t.Run("valid with response step and cron trigger", func(t *testing.T) {
 		cfg := &WorkflowConfig{
 			Name: "test",
 			Triggers: []TriggerConfig{
-				{Type: "cron", Schedule: "0 8 * * *"},
-			},
-			Steps: []StepConfig{
-				{Name: "run", Type: "query", Database: "db", SQL: "SELECT 1"},
+				{Type: "websocket", Path: "/ws/{room}", Method: "GET", Origins: []string{"https://app.example.com"},
+					Parameters: []ParamConfig{{Name: "room", Type: "string", Required: true}}},
+				{Type: "cron", Schedule: "0 * * * *"},
 			},
+			Steps: []StepConfig{{Type: "response", Template: "{}"}},
 		}
-		ctx := &ValidationContext{Databases: map[string]bool{"db": true}}
-		result := Validate(cfg, ctx)
-		if !result.Valid {
-			t.Errorf("expected valid, got errors: %v", result.Errors)
+		if result := Validate(cfg, nil); !result.Valid {
+			t.Errorf("expected valid workflow, got errors: %v", result.Errors)
 		}
 	})
 }
 
-// TestValidateCronExpr verifies the accepted schedule dialect: five fields plus descriptors
-func TestValidateCronExpr(t *testing.T) {
+func TestValidate_Poll(t *testing.T) {
 	tests := []struct {
 		name        string
-		expr        string
-		expectError string // empty means the expression must be accepted
+		trigger     TriggerConfig
+		steps       []StepConfig
+		expectError string
 	}{
-		// Five-field expressions
-		{"daily at 8", "0 8 * * *", ""},
-		{"every five minutes", "*/5 * * * *", ""},
-		{"mondays", "0 8 * * 1", ""},
-
-		// Named descriptors
-		{"hourly", "@hourly", ""},
-		{"daily", "@daily", ""},
-		{"midnight", "@midnight", ""},
-		{"weekly", "@weekly", ""},
-		{"monthly", "@monthly", ""},
-		{"yearly", "@yearly", ""},
-		{"annually", "@annually", ""},
-
-		// @every with intervals the scheduler runs exactly as written
-		{"every hour", "@every 1h", ""},
-		{"every 30 minutes", "@every 30m", ""},
-		{"every 90 seconds", "@every 90s", ""},
-		{"compound duration", "@every 1h30m", ""},
+		{
+			name:        "negative interval",
+			trigger:     TriggerConfig{Type: "http", Path: "/feed", Method: "GET", Poll: &PollConfig{IntervalMs: -1}},
+			expectError: "poll: interval_ms cannot be negative",
+		},
+		{
+			name:        "interval not shorter than timeout",
+			trigger:     TriggerConfig{Type: "http", Path: "/feed", Method: "GET", Poll: &PollConfig{IntervalMs: 5000, TimeoutSec: 5}},
+			expectError: "interval_ms must be shorter than timeout_sec",
+		},
+		{
+			name:        "with cache",
+			trigger:     TriggerConfig{Type: "http", Path: "/feed", Method: "GET", Poll: &PollConfig{}, Cache: &CacheConfig{Enabled: true, Key: "k"}},
+			expectError: "poll cannot be used with cache",
+		},
+		{
+			name:        "with response_sse",
+			trigger:     TriggerConfig{Type: "http", Path: "/feed", Method: "GET", Poll: &PollConfig{}},
+			steps:       []StepConfig{{Name: "e", Type: "response_sse", Template: "{}"}},
+			expectError: "poll cannot be used with response_sse steps",
+		},
+		{
+			name:        "websocket trigger",
+			trigger:     TriggerConfig{Type: "websocket", Path: "/feed", Poll: &PollConfig{}},
+			expectError: "poll is not supported for websocket triggers",
+		},
+	}
 
-		// @every intervals the scheduler would silently alter
-		{"sub-second interval", "@every 100ms", "below the 1s minimum"},
-		{"zero interval", "@every 0s", "below the 1s minimum"},
-		{"negative interval", "@every -1h", "below the 1s minimum"},
-		{"fractional seconds", "@every 1500ms", "whole number of seconds"},
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := tt.steps
+			if steps == nil {
+				steps = []StepConfig{{Type: "response", Template: "{}"}}
+			}
+			result := Validate(&WorkflowConfig{Name: "test", Triggers: []TriggerConfig{tt.trigger}, Steps: steps}, nil)
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
 
-		// Timezone prefixes, which the parser consumes before the schedule
-		{"tz with descriptor", "TZ=UTC @daily", ""},
-		{"tz with five fields", "TZ=UTC 0 8 * * *", ""},
-		{"cron_tz with five fields", "CRON_TZ=America/New_York 0 8 * * *", ""},
-		{"tz with valid every", "TZ=UTC @every 1h", ""},
-		{"tz does not bypass every minimum", "TZ=UTC @every 100ms", "below the 1s minimum"},
-		{"cron_tz does not bypass whole seconds", "CRON_TZ=America/New_York @every 1500ms", "whole number of seconds"},
-		{"tz without schedule", "TZ=UTC", "must be followed by a schedule"},
-		{"cron_tz without schedule", "CRON_TZ=UTC", "must be followed by a schedule"},
-		{"unknown timezone", "TZ=Bad/Zone 0 8 * * *", "unknown time zone"},
+	t.Run("warns on non-GET", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name:     "test",
+			Triggers: []TriggerConfig{{Type: "http", Path: "/feed", Method: "POST", Poll: &PollConfig{IntervalMs: 500}}},
+			Steps:    []StepConfig{{Type: "response", Template: "{}"}},
+		}
+		result := Validate(cfg, nil)
+		if !result.Valid {
+			t.Fatalf("expected valid config, got: %v", result.Errors)
+		}
+		if !containsWarning(result.Warnings, "polling a POST trigger") {
+			t.Errorf("expected non-GET warning, got: %v", result.Warnings)
+		}
+	})
+}
 
-		// Rejected forms
-		{"six fields", "0 0 8 * * *", "expected exactly 5 fields"},
-		{"seconds column", "*/30 * * * * *", "expected exactly 5 fields"},
-		{"unknown descriptor", "@reboot", "unrecognized descriptor"},
-		{"uppercase descriptor", "@DAILY", "unrecognized descriptor"},
-		{"gibberish", "invalid", "expected exactly 5 fields"},
-		{"empty", "", "empty spec string"},
+func TestValidate_Idempotency(t *testing.T) {
+	ctx := &ValidationContext{Databases: map[string]bool{"app": false, "reports": true}}
+	valid := TriggerConfig{Type: "http", Path: "/orders", Method: "POST", Idempotency: &IdempotencyConfig{Database: "app"}}
+	tests := []struct {
+		name        string
+		modify      func(*TriggerConfig)
+		steps       []StepConfig
+		expectError string
+	}{
+		{name: "valid"},
+		{name: "missing database", modify: func(c *TriggerConfig) { c.Idempotency.Database = "" }, expectError: "idempotency: database is required"},
+		{name: "unknown database", modify: func(c *TriggerConfig) { c.Idempotency.Database = "nope" }, expectError: "unknown database 'nope'"},
+		{name: "read-only database", modify: func(c *TriggerConfig) { c.Idempotency.Database = "reports" }, expectError: "database 'reports' is read-only"},
+		{name: "invalid table", modify: func(c *TriggerConfig) { c.Idempotency.Table = "keys; DROP TABLE x" }, expectError: "invalid table name"},
+		{name: "negative ttl", modify: func(c *TriggerConfig) { c.Idempotency.TTLSec = -1 }, expectError: "ttl_sec cannot be negative"},
+		{name: "read method", modify: func(c *TriggerConfig) { c.Method, c.Methods = "", []string{"POST", "GET"} }, expectError: "applies to write methods (POST, PUT, PATCH, DELETE), not GET"},
+		{name: "with cache", modify: func(c *TriggerConfig) { c.Cache = &CacheConfig{Enabled: true, Key: "k"} }, expectError: "idempotency cannot be used with cache"},
+		{
+			name:        "with response_sse",
+			steps:       []StepConfig{{Name: "e", Type: "response_sse", Template: "{}"}},
+			expectError: "idempotency cannot be used with response_sse steps",
+		},
+		{
+			name:        "grpc trigger",
+			modify:      func(c *TriggerConfig) { c.Type, c.Path, c.Method = "grpc", "", "" },
+			expectError: "idempotency is only supported for http triggers",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateCronExpr(tt.expr)
+			trig := valid
+			idem := *valid.Idempotency
+			trig.Idempotency = &idem
+			if tt.modify != nil {
+				tt.modify(&trig)
+			}
+			steps := tt.steps
+			if steps == nil {
+				steps = []StepConfig{{Type: "response", Template: "{}"}}
+			}
+			result := Validate(&WorkflowConfig{Name: "test", Triggers: []TriggerConfig{trig}, Steps: steps}, ctx)
 			if tt.expectError == "" {
-				if err != nil {
-					t.Errorf("validateCronExpr(%q) = %v, want accepted", tt.expr, err)
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
 				}
 				return
 			}
-			if err == nil {
-				t.Fatalf("validateCronExpr(%q) = nil, want error containing %q", tt.expr, tt.expectError)
-			}
-			if !strings.Contains(err.Error(), tt.expectError) {
-				t.Errorf("validateCronExpr(%q) = %q, want error containing %q", tt.expr, err, tt.expectError)
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
 			}
 		})
 	}
 }
 
-// TestValidateCronExpr_MatchesScheduler verifies validation never accepts a schedule the scheduler cannot run
-func TestValidateCronExpr_MatchesScheduler(t *testing.T) {
-	// Expressions the validator rejects that the scheduler would otherwise accept.
-	// Each is a deliberate rule; anything else showing up here is accidental
-	// strictness that would reject a config the service could actually run.
-	intentionallyStricter := map[string]string{
-		"@every 100ms":        "clamped up to 1s by cron.Every",
-		"@every 1500ms":       "truncated to 1s by cron.Every",
-		"@every -1h":          "clamped up to 1s by cron.Every",
-		"@every 0s":           "clamped up to 1s by cron.Every",
-		"TZ=UTC @every 100ms": "clamped up to 1s by cron.Every",
+func TestValidate_Priority(t *testing.T) {
+	tests := []struct {
+		name        string
+		trigger     TriggerConfig
+		expectError string
+	}{
+		{name: "http", trigger: TriggerConfig{Type: "http", Path: "/r", Method: "GET", Priority: "low"}},
+		{name: "critical", trigger: TriggerConfig{Type: "http", Path: "/r", Method: "GET", Priority: "critical"}},
+		{name: "unknown", trigger: TriggerConfig{Type: "http", Path: "/r", Method: "GET", Priority: "urgent"}, expectError: "invalid priority 'urgent'"},
+		{name: "cron", trigger: TriggerConfig{Type: "cron", Schedule: "* * * * *", Priority: "high"}},
+		{name: "unknown cron", trigger: TriggerConfig{Type: "cron", Schedule: "* * * * *", Priority: "bulk"}, expectError: "invalid priority 'bulk'"},
 	}
 
-	corpus := []string{
-		// Five-field, including range, list and step forms
-		"0 8 * * *", "*/5 * * * *", "0 0 1 1 *", "0 9 * * 1-5", "*/30 9-17 * * 1-5",
-		"0 0 1,15 * *", "5 4 * * sun", "0 8 * * *  ", "  0 8 * * *",
-		// Descriptors
-		"@hourly", "@daily", "@midnight", "@weekly", "@monthly", "@yearly", "@annually",
-		"@every 1s", "@every 1h", "@every 90s", "@every 1h30m", "@every 24h",
-		"@every 100ms", "@every 1500ms", "@every -1h", "@every 0s", "@every abc", "@every",
-		// Timezone prefixes, including the forms that make the upstream parser panic
-		"TZ=UTC 0 8 * * *", "TZ=America/New_York @daily", "CRON_TZ=UTC @every 1h",
-		"TZ=UTC", "CRON_TZ=UTC", "TZ= 0 8 * * *", "TZ=Bad/Zone 0 8 * * *",
-		"TZ=UTC @every 100ms", "TZ=UTC TZ=UTC @daily", "TZ=UTC\t@daily",
-		" TZ=UTC @daily", "tz=UTC @daily",
-		// Malformed
-		"@reboot", "@DAILY", "@Every 1h", "", " ", "0 0 8 * * *", "*/30 * * * * *",
-		"invalid", "* * * * * * *",
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Validate(&WorkflowConfig{Name: "test", Triggers: []TriggerConfig{tt.trigger}, Steps: []StepConfig{{Name: "s", Type: "set", Values: map[string]string{"n": "1"}}}}, &ValidationContext{})
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
+				}
+				return
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
 	}
+}
 
-	for _, expr := range corpus {
-		t.Run(expr, func(t *testing.T) {
-			validatorErr := validateCronExpr(expr)
+func TestValidate_Fields(t *testing.T) {
+	valid := TriggerConfig{Type: "http", Path: "/users", Method: "GET", Fields: &FieldsConfig{Allowed: []string{"id", "name"}, Steps: []string{"fetch"}}}
+	tests := []struct {
+		name        string
+		modify      func(*TriggerConfig)
+		expectError string
+	}{
+		{name: "valid"},
+		{name: "missing allowed", modify: func(c *TriggerConfig) { c.Fields.Allowed = nil }, expectError: "fields: allowed is required"},
+		{name: "wildcard column", modify: func(c *TriggerConfig) { c.Fields.Allowed = []string{"*_id"} }, expectError: "cannot contain commas or wildcards"},
+		{name: "duplicate column", modify: func(c *TriggerConfig) { c.Fields.Allowed = []string{"id", "ID"} }, expectError: "duplicate allowed column 'ID'"},
+		{name: "missing steps", modify: func(c *TriggerConfig) { c.Fields.Steps = nil }, expectError: "fields: steps is required"},
+		{name: "not a query step", modify: func(c *TriggerConfig) { c.Fields.Steps = []string{"render"} }, expectError: "fields: 'render' is not a query step"},
+		{name: "parameter conflict", modify: func(c *TriggerConfig) { c.Parameters = []ParamConfig{{Name: "fields", Type: "string"}} }, expectError: "parameter 'fields' conflicts"},
+		{name: "grpc trigger", modify: func(c *TriggerConfig) { c.Type, c.Path, c.Method = "grpc", "", "" }, expectError: "fields is only supported for http triggers"},
+	}
 
-			var schedulerErr error
-			var panicked any
-			func() {
-				defer func() { panicked = recover() }()
-				_, schedulerErr = cron.New().AddFunc(expr, func() {})
-			}()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trig := valid
+			fields := *valid.Fields
+			trig.Fields = &fields
+			if tt.modify != nil {
+				tt.modify(&trig)
+			}
+			steps := []StepConfig{
+				{Name: "fetch", Type: "query", Database: "db", SQL: "SELECT * FROM users"},
+				{Name: "render", Type: "response", Template: "{}"},
+			}
+			result := Validate(&WorkflowConfig{Name: "test", Triggers: []TriggerConfig{trig}, Steps: steps}, nil)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
+				}
+				return
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
 
-			if validatorErr == nil {
-				if panicked != nil {
-					t.Errorf("validation accepted %q but the scheduler panics: %v", expr, panicked)
+	trig := valid
+	trig.Cache = &CacheConfig{Enabled: true, Key: "users"}
+	result := Validate(&WorkflowConfig{Name: "test", Triggers: []TriggerConfig{trig}, Steps: []StepConfig{
+		{Name: "fetch", Type: "query", Database: "db", SQL: "SELECT * FROM users"},
+		{Type: "response", Template: "{}"},
+	}}, nil)
+	if !containsWarning(result.Warnings, "cache.key should include .trigger.query.fields") {
+		t.Errorf("expected cache key warning, got: %v", result.Warnings)
+	}
+}
+
+func TestValidate_SortAndFilter(t *testing.T) {
+	valid := TriggerConfig{Type: "http", Path: "/orders", Method: "GET", SortableColumns: []string{"created_at", "id"}, FilterableColumns: []string{"status"}, DefaultSort: "-created_at,id"}
+	tests := []struct {
+		name        string
+		modify      func(*TriggerConfig)
+		expectError string
+	}{
+		{name: "valid"},
+		{name: "invalid column", modify: func(c *TriggerConfig) { c.SortableColumns = []string{"created_at; DROP"} }, expectError: "sortable_columns column 'created_at; DROP' must be a letter"},
+		{name: "duplicate column", modify: func(c *TriggerConfig) { c.FilterableColumns = []string{"status", "Status"} }, expectError: "duplicate filterable_columns column 'Status'"},
+		{name: "unknown default_sort", modify: func(c *TriggerConfig) { c.DefaultSort = "total" }, expectError: "default_sort: unknown sort column 'total'"},
+		{name: "sort parameter", modify: func(c *TriggerConfig) { c.Parameters = []ParamConfig{{Name: "sort", Type: "string"}} }, expectError: "parameter 'sort' conflicts"},
+		{name: "filter parameter", modify: func(c *TriggerConfig) { c.Parameters = []ParamConfig{{Name: "filter_status", Type: "string"}} }, expectError: "parameter 'filter_status' conflicts"},
+		{name: "cron trigger", modify: func(c *TriggerConfig) { c.Type, c.Path, c.Method, c.Schedule = "cron", "", "", "0 * * * *" }, expectError: "only supported for http triggers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trig := valid
+			if tt.modify != nil {
+				tt.modify(&trig)
+			}
+			result := Validate(&WorkflowConfig{Name: "test", Triggers: []TriggerConfig{trig}, Steps: []StepConfig{{Type: "response", Template: "{}"}}}, nil)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
 				}
-				if schedulerErr != nil {
-					t.Errorf("validation accepted %q but the scheduler rejects it: %v", expr, schedulerErr)
+				return
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+func TestValidate_Envelope(t *testing.T) {
+	tests := []struct {
+		name        string
+		envelope    EnvelopeConfig
+		expectError string
+	}{
+		{name: "defaults"},
+		{name: "renamed and omitted", envelope: EnvelopeConfig{Success: "-", Data: "items", Meta: "meta"}},
+		{name: "surrounding spaces", envelope: EnvelopeConfig{Data: " items"}, expectError: "data key ' items' cannot have surrounding spaces"},
+		{name: "renamed onto a default", envelope: EnvelopeConfig{Data: "error"}, expectError: "data and error use the same key 'error'"},
+		{name: "meta collides", envelope: EnvelopeConfig{Meta: "request_id"}, expectError: "request_id and meta use the same key 'request_id'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope := tt.envelope
+			result := Validate(&WorkflowConfig{
+				Name:     "test",
+				Envelope: &envelope,
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps:    []StepConfig{{Type: "response", Template: "{}"}},
+			}, nil)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
 				}
 				return
 			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
 
-			// Rejected by validation. That is only expected when the scheduler also
-			// refuses it, or when it is one of the documented stricter rules.
-			if schedulerErr == nil && panicked == nil {
-				if _, ok := intentionallyStricter[expr]; !ok {
-					t.Errorf("validation rejected %q (%v) but the scheduler accepts it; "+
-						"if this strictness is intended, add it to intentionallyStricter",
-						expr, validatorErr)
+	result := Validate(&WorkflowConfig{
+		Name:     "test",
+		Envelope: &EnvelopeConfig{Flat: true, Meta: "meta"},
+		Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+		Steps:    []StepConfig{{Type: "response", Template: "{}"}},
+	}, nil)
+	if !containsError(result.Warnings, "meta has no effect on success responses with flat") {
+		t.Errorf("expected flat/meta warning, got: %v", result.Warnings)
+	}
+}
+
+func TestValidate_StatusMap(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusMap   map[string]StatusMapping
+		expectError string
+	}{
+		{name: "valid", statusMap: map[string]StatusMapping{"deadlock": {Status: 503}, "not_found": {Status: 404, Message: "no such order"}}},
+		{name: "unknown class", statusMap: map[string]StatusMapping{"conflict": {Status: 409}}, expectError: "unknown error class 'conflict'"},
+		{name: "success status", statusMap: map[string]StatusMapping{"timeout": {Status: 200}}, expectError: "status_map.timeout: status must be between 400 and 599"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Validate(&WorkflowConfig{
+				Name:      "test",
+				StatusMap: tt.statusMap,
+				Triggers:  []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps:     []StepConfig{{Type: "response", Template: "{}"}},
+			}, nil)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
+				}
+				return
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+func TestValidate_ErrorFormat(t *testing.T) {
+	for format, wantValid := range map[string]bool{"": true, ErrorFormatEnvelope: true, ErrorFormatProblemJSON: true, "problem+json": false} {
+		result := Validate(&WorkflowConfig{
+			Name:        "test",
+			ErrorFormat: format,
+			Triggers:    []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+			Steps:       []StepConfig{{Type: "response", Template: "{}"}},
+		}, nil)
+		if result.Valid != wantValid {
+			t.Errorf("error_format %q: valid = %v, want %v (errors: %v)", format, result.Valid, wantValid, result.Errors)
+		}
+	}
+}
+
+func TestValidate_DBWatchTrigger(t *testing.T) {
+	ctx := &ValidationContext{
+		Databases:     map[string]bool{"app": false, "sales": true},
+		DatabaseTypes: map[string]string{"app": "sqlite", "sales": "sqlserver"},
+	}
+	valid := TriggerConfig{Type: "dbwatch", Database: "app", Table: "main.orders", Column: "updated_at"}
+	tests := []struct {
+		name        string
+		modify      func(*TriggerConfig)
+		steps       []StepConfig
+		expectError string
+	}{
+		{name: "valid"},
+		{name: "rowversion on sqlserver", modify: func(c *TriggerConfig) { c.Database, c.ColumnType = "sales", "rowversion" }},
+		{name: "missing database", modify: func(c *TriggerConfig) { c.Database = "" }, expectError: "database is required for dbwatch trigger"},
+		{name: "unknown database", modify: func(c *TriggerConfig) { c.Database = "nope" }, expectError: "unknown database 'nope'"},
+		{name: "missing table", modify: func(c *TriggerConfig) { c.Table = "" }, expectError: "table is required"},
+		{name: "invalid table", modify: func(c *TriggerConfig) { c.Table = "orders; DROP TABLE x" }, expectError: "invalid table name"},
+		{name: "missing column", modify: func(c *TriggerConfig) { c.Column = "" }, expectError: "column is required"},
+		{name: "invalid columns", modify: func(c *TriggerConfig) { c.Columns = []string{"id", "a b"} }, expectError: "columns[1]: invalid column name"},
+		{name: "reserved column", modify: func(c *TriggerConfig) { c.Columns = []string{"_watermark"} }, expectError: "reserved for the watermark"},
+		{name: "invalid column_type", modify: func(c *TriggerConfig) { c.ColumnType = "uuid" }, expectError: "invalid column_type 'uuid'"},
+		{name: "rowversion on sqlite", modify: func(c *TriggerConfig) { c.ColumnType = "rowversion" }, expectError: "rowversion requires a sqlserver database"},
+		{name: "invalid start", modify: func(c *TriggerConfig) { c.Start = "now" }, expectError: "invalid start 'now'"},
+		{name: "negative interval", modify: func(c *TriggerConfig) { c.IntervalSec = -1 }, expectError: "interval_sec cannot be negative"},
+		{name: "negative batch size", modify: func(c *TriggerConfig) { c.BatchSize = -1 }, expectError: "batch_size cannot be negative"},
+		{
+			name:        "response step",
+			steps:       []StepConfig{{Type: "response", Template: "{}"}},
+			expectError: "response steps are only valid for HTTP, websocket, and grpc triggers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger := valid
+			if tt.modify != nil {
+				tt.modify(&trigger)
+			}
+			steps := tt.steps
+			if steps == nil {
+				steps = []StepConfig{{Name: "q", Type: "query", Database: "app", SQL: "SELECT 1"}}
+			}
+			result := Validate(&WorkflowConfig{Name: "test", Triggers: []TriggerConfig{trigger}, Steps: steps}, ctx)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid config, got: %v", result.Errors)
+				}
+				return
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+func TestValidate_FileWatchTrigger(t *testing.T) {
+	valid := TriggerConfig{Type: "filewatch", Dir: "/data/in", Pattern: "*.csv", DoneDir: "/data/done", ErrorDir: "/data/error"}
+	tests := []struct {
+		name        string
+		modify      func(*TriggerConfig)
+		expectError string
+	}{
+		{name: "valid"},
+		{name: "missing dir", modify: func(c *TriggerConfig) { c.Dir = "" }, expectError: "dir is required for filewatch trigger"},
+		{name: "missing done_dir", modify: func(c *TriggerConfig) { c.DoneDir = "" }, expectError: "done_dir is required"},
+		{name: "missing error_dir", modify: func(c *TriggerConfig) { c.ErrorDir = "" }, expectError: "error_dir is required"},
+		{name: "done_dir is dir", modify: func(c *TriggerConfig) { c.DoneDir = "/data/in/" }, expectError: "done_dir must differ from dir"},
+		{name: "invalid pattern", modify: func(c *TriggerConfig) { c.Pattern = "[a-" }, expectError: "invalid pattern"},
+		{name: "pattern with directory", modify: func(c *TriggerConfig) { c.Pattern = "sub/*.csv" }, expectError: "cannot contain '/'"},
+		{name: "negative max_file_bytes", modify: func(c *TriggerConfig) { c.MaxFileBytes = -1 }, expectError: "max_file_bytes cannot be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger := valid
+			if tt.modify != nil {
+				tt.modify(&trigger)
+			}
+			steps := []StepConfig{{Name: "q", Type: "query", Database: "app", SQL: "SELECT 1"}}
+			result := Validate(&WorkflowConfig{Name: "test", Triggers: []TriggerConfig{trigger}, Steps: steps}, nil)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid config, got: %v", result.Errors)
+				}
+				return
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+func TestValidate_MQTTTrigger(t *testing.T) {
+	brokers := &ValidationContext{Databases: map[string]bool{"app": false}, MQTTBrokers: map[string]bool{"plant": true}}
+	valid := TriggerConfig{Type: "mqtt", Broker: "plant", Topics: []string{"factory/+/temperature"}, QoS: 1}
+	tests := []struct {
+		name        string
+		modify      func(*TriggerConfig)
+		expectError string
+	}{
+		{name: "valid"},
+		{name: "missing broker", modify: func(c *TriggerConfig) { c.Broker = "" }, expectError: "broker is required for mqtt trigger"},
+		{name: "unknown broker", modify: func(c *TriggerConfig) { c.Broker = "office" }, expectError: "unknown mqtt broker 'office'"},
+		{name: "missing topics", modify: func(c *TriggerConfig) { c.Topics = nil }, expectError: "topics is required for mqtt trigger"},
+		{name: "invalid filter", modify: func(c *TriggerConfig) { c.Topics = []string{"factory/#/temperature"} }, expectError: "invalid topic filter"},
+		{name: "qos 2", modify: func(c *TriggerConfig) { c.QoS = 2 }, expectError: "qos must be 0 or 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger := valid
+			if tt.modify != nil {
+				tt.modify(&trigger)
+			}
+			steps := []StepConfig{{Name: "q", Type: "query", Database: "app", SQL: "SELECT 1"}}
+			result := Validate(&WorkflowConfig{Name: "test", Triggers: []TriggerConfig{trigger}, Steps: steps}, brokers)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid config, got: %v", result.Errors)
+				}
+				return
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+func TestValidate_GRPCTrigger(t *testing.T) {
+	ctx := &ValidationContext{Databases: map[string]bool{"app": false}}
+	valid := TriggerConfig{Type: "grpc", RPC: "GetMachine", Parameters: []ParamConfig{{Name: "machine_id", Type: "int", Required: true}}}
+	tests := []struct {
+		name        string
+		modify      func(*TriggerConfig)
+		expectError string
+	}{
+		{name: "valid"},
+		{name: "execute only", modify: func(c *TriggerConfig) { c.RPC = "" }},
+		{name: "lower case rpc", modify: func(c *TriggerConfig) { c.RPC = "getMachine" }, expectError: "invalid rpc 'getMachine'"},
+		{name: "reserved rpc", modify: func(c *TriggerConfig) { c.RPC = "Execute" }, expectError: "rpc 'Execute' is reserved"},
+		{name: "invalid field name", modify: func(c *TriggerConfig) { c.Parameters[0].Name = "machine-id" }, expectError: "not a valid field name for rpc GetMachine"},
+		{name: "cache", modify: func(c *TriggerConfig) { c.Cache = &CacheConfig{Enabled: true, Key: "k"} }, expectError: "cache is not supported for grpc triggers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger := valid
+			trigger.Parameters = append([]ParamConfig(nil), valid.Parameters...)
+			if tt.modify != nil {
+				tt.modify(&trigger)
+			}
+			steps := []StepConfig{{Name: "q", Type: "query", Database: "app", SQL: "SELECT 1"}}
+			result := Validate(&WorkflowConfig{Name: "test", Triggers: []TriggerConfig{trigger}, Steps: steps}, ctx)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid config, got: %v", result.Errors)
 				}
+				return
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+
+	// One grpc trigger per workflow
+	result := Validate(&WorkflowConfig{
+		Name:     "test",
+		Triggers: []TriggerConfig{{Type: "grpc"}, {Type: "grpc", RPC: "Other"}},
+		Steps:    []StepConfig{{Name: "q", Type: "query", Database: "app", SQL: "SELECT 1"}},
+	}, ctx)
+	if result.Valid {
+		t.Error("expected error for two grpc triggers")
+	}
+}
+
+func TestValidate_CronTrigger(t *testing.T) {
+	tests := []struct {
+		name        string
+		trigger     TriggerConfig
+		expectError string
+	}{
+		{
+			name:        "missing schedule",
+			trigger:     TriggerConfig{Type: "cron"},
+			expectError: "schedule is required",
+		},
+		{
+			name:        "invalid cron expression",
+			trigger:     TriggerConfig{Type: "cron", Schedule: "invalid"},
+			expectError: "invalid schedule",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{tt.trigger},
+				Steps:    []StepConfig{{Name: "run", Type: "query", Database: "db", SQL: "SELECT 1"}},
+			}
+			ctx := &ValidationContext{Databases: map[string]bool{"db": true}}
+			result := Validate(cfg, ctx)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+
+	t.Run("valid cron expression", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name: "test",
+			Triggers: []TriggerConfig{
+				{Type: "cron", Schedule: "0 8 * * *"},
+			},
+			Steps: []StepConfig{
+				{Name: "run", Type: "query", Database: "db", SQL: "SELECT 1"},
+			},
+		}
+		ctx := &ValidationContext{Databases: map[string]bool{"db": true}}
+		result := Validate(cfg, ctx)
+		if !result.Valid {
+			t.Errorf("expected valid, got errors: %v", result.Errors)
+		}
+	})
+}
+
+// TestValidateCronExpr verifies the accepted schedule dialect: five fields plus descriptors
+func TestValidateCronExpr(t *testing.T) {
+	tests := []struct {
+		name        string
+		expr        string
+		expectError string // empty means the expression must be accepted
+	}{
+		// Five-field expressions
+		{"daily at 8", "0 8 * * *", ""},
+		{"every five minutes", "*/5 * * * *", ""},
+		{"mondays", "0 8 * * 1", ""},
+
+		// Named descriptors
+		{"hourly", "@hourly", ""},
+		{"daily", "@daily", ""},
+		{"midnight", "@midnight", ""},
+		{"weekly", "@weekly", ""},
+		{"monthly", "@monthly", ""},
+		{"yearly", "@yearly", ""},
+		{"annually", "@annually", ""},
+
+		// @every with intervals the scheduler runs exactly as written
+		{"every hour", "@every 1h", ""},
+		{"every 30 minutes", "@every 30m", ""},
+		{"every 90 seconds", "@every 90s", ""},
+		{"compound duration", "@every 1h30m", ""},
+
+		// @every intervals the scheduler would silently alter
+		{"sub-second interval", "@every 100ms", "below the 1s minimum"},
+		{"zero interval", "@every 0s", "below the 1s minimum"},
+		{"negative interval", "@every -1h", "below the 1s minimum"},
+		{"fractional seconds", "@every 1500ms", "whole number of seconds"},
+
+		// Timezone prefixes, which the parser consumes before the schedule
+		{"tz with descriptor", "TZ=UTC @daily", ""},
+		{"tz with five fields", "TZ=UTC 0 8 * * *", ""},
+		{"cron_tz with five fields", "CRON_TZ=America/New_York 0 8 * * *", ""},
+		{"tz with valid every", "TZ=UTC @every 1h", ""},
+		{"tz does not bypass every minimum", "TZ=UTC @every 100ms", "below the 1s minimum"},
+		{"cron_tz does not bypass whole seconds", "CRON_TZ=America/New_York @every 1500ms", "whole number of seconds"},
+		{"tz without schedule", "TZ=UTC", "must be followed by a schedule"},
+		{"cron_tz without schedule", "CRON_TZ=UTC", "must be followed by a schedule"},
+		{"unknown timezone", "TZ=Bad/Zone 0 8 * * *", "unknown time zone"},
+
+		// Rejected forms
+		{"six fields", "0 0 8 * * *", "expected exactly 5 fields"},
+		{"seconds column", "*/30 * * * * *", "expected exactly 5 fields"},
+		{"unknown descriptor", "@reboot", "unrecognized descriptor"},
+		{"uppercase descriptor", "@DAILY", "unrecognized descriptor"},
+		{"gibberish", "invalid", "expected exactly 5 fields"},
+		{"empty", "", "empty spec string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCronExpr(tt.expr)
+			if tt.expectError == "" {
+				if err != nil {
+					t.Errorf("validateCronExpr(%q) = %v, want accepted", tt.expr, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("validateCronExpr(%q) = nil, want error containing %q", tt.expr, tt.expectError)
+			}
+			if !strings.Contains(err.Error(), tt.expectError) {
+				t.Errorf("validateCronExpr(%q) = %q, want error containing %q", tt.expr, err, tt.expectError)
+			}
+		})
+	}
+}
+
+// TestValidateCronExpr_MatchesScheduler verifies validation never accepts a schedule the scheduler cannot run
+func TestValidateCronExpr_MatchesScheduler(t *testing.T) {
+	// Expressions the validator rejects that the scheduler would otherwise accept.
+	// Each is a deliberate rule; anything else showing up here is accidental
+	// strictness that would reject a config the service could actually run.
+	intentionallyStricter := map[string]string{
+		"@every 100ms":        "clamped up to 1s by cron.Every",
+		"@every 1500ms":       "truncated to 1s by cron.Every",
+		"@every -1h":          "clamped up to 1s by cron.Every",
+		"@every 0s":           "clamped up to 1s by cron.Every",
+		"TZ=UTC @every 100ms": "clamped up to 1s by cron.Every",
+	}
+
+	corpus := []string{
+		// Five-field, including range, list and step forms
+		"0 8 * * *", "*/5 * * * *", "0 0 1 1 *", "0 9 * * 1-5", "*/30 9-17 * * 1-5",
+		"0 0 1,15 * *", "5 4 * * sun", "0 8 * * *  ", "  0 8 * * *",
+		// Descriptors
+		"@hourly", "@daily", "@midnight", "@weekly", "@monthly", "@yearly", "@annually",
+		"@every 1s", "@every 1h", "@every 90s", "@every 1h30m", "@every 24h",
+		"@every 100ms", "@every 1500ms", "@every -1h", "@every 0s", "@every abc", "@every",
+		// Timezone prefixes, including the forms that make the upstream parser panic
+		"TZ=UTC 0 8 * * *", "TZ=America/New_York @daily", "CRON_TZ=UTC @every 1h",
+		"TZ=UTC", "CRON_TZ=UTC", "TZ= 0 8 * * *", "TZ=Bad/Zone 0 8 * * *",
+		"TZ=UTC @every 100ms", "TZ=UTC TZ=UTC @daily", "TZ=UTC\t@daily",
+		" TZ=UTC @daily", "tz=UTC @daily",
+		// Malformed
+		"@reboot", "@DAILY", "@Every 1h", "", " ", "0 0 8 * * *", "*/30 * * * * *",
+		"invalid", "* * * * * * *",
+	}
+
+	for _, expr := range corpus {
+		t.Run(expr, func(t *testing.T) {
+			validatorErr := validateCronExpr(expr)
+
+			var schedulerErr error
+			var panicked any
+			func() {
+				defer func() { panicked = recover() }()
+				_, schedulerErr = cron.New().AddFunc(expr, func() {})
+			}()
+
+			if validatorErr == nil {
+				if panicked != nil {
+					t.Errorf("validation accepted %q but the scheduler panics: %v", expr, panicked)
+				}
+				if schedulerErr != nil {
+					t.Errorf("validation accepted %q but the scheduler rejects it: %v", expr, schedulerErr)
+				}
+				return
+			}
+
+			// Rejected by validation. That is only expected when the scheduler also
+			// refuses it, or when it is one of the documented stricter rules.
+			if schedulerErr == nil && panicked == nil {
+				if _, ok := intentionallyStricter[expr]; !ok {
+					t.Errorf("validation rejected %q (%v) but the scheduler accepts it; "+
+						"if this strictness is intended, add it to intentionallyStricter",
+						expr, validatorErr)
+				}
+			}
+		})
+	}
+}
+
+// TestValidate_EvictCronSchedule verifies cache evict_cron accepts the same dialect as triggers
+func TestValidate_EvictCronSchedule(t *testing.T) {
+	tests := []struct {
+		name        string
+		evictCron   string
+		expectError string // empty means the config must validate
+	}{
+		{"five fields", "0 3 * * *", ""},
+		{"descriptor", "@daily", ""},
+		{"every", "@every 30m", ""},
+		{"timezone prefix", "TZ=UTC @daily", ""},
+		{"sub-second every", "@every 100ms", "invalid evict_cron"},
+		{"timezone without schedule", "TZ=UTC", "invalid evict_cron"},
+		{"gibberish", "not-a-schedule", "invalid evict_cron"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name: "test",
+				Triggers: []TriggerConfig{{
+					Type: "http", Path: "/test", Method: "GET",
+					Cache: &CacheConfig{Enabled: true, Key: "k", EvictCron: tt.evictCron},
+				}},
+				Steps: []StepConfig{
+					{Name: "run", Type: "query", Database: "db", SQL: "SELECT 1"},
+					{Type: "response", Template: "{}"},
+				},
+			}
+			ctx := &ValidationContext{Databases: map[string]bool{"db": false}}
+			result := Validate(cfg, ctx)
+
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
+				}
+				return
+			}
+			if result.Valid {
+				t.Fatal("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+func TestValidate_StaleWhileRevalidate(t *testing.T) {
+	for _, swr := range []int{30, -1} {
+		cfg := &WorkflowConfig{
+			Name: "test",
+			Triggers: []TriggerConfig{{
+				Type:   "http",
+				Path:   "/test",
+				Method: "GET",
+				Cache:  &CacheConfig{Enabled: true, Key: "k", TTLSec: 60, StaleWhileRevalidateSec: swr},
+			}},
+			Steps: []StepConfig{{Type: "response", Template: "{}"}},
+		}
+		result := Validate(cfg, nil)
+		hasErr := containsError(result.Errors, "stale_while_revalidate_sec cannot be negative")
+		if hasErr != (swr < 0) {
+			t.Errorf("stale_while_revalidate_sec=%d: unexpected errors: %v", swr, result.Errors)
+		}
+	}
+}
+
+func TestValidate_QueryStep(t *testing.T) {
+	tests := []struct {
+		name        string
+		step        StepConfig
+		ctx         *ValidationContext
+		expectError string
+	}{
+		{
+			name:        "missing database",
+			step:        StepConfig{Name: "q", Type: "query", SQL: "SELECT 1"},
+			expectError: "database is required",
+		},
+		{
+			name:        "unknown database",
+			step:        StepConfig{Name: "q", Type: "query", Database: "unknown", SQL: "SELECT 1"},
+			ctx:         &ValidationContext{Databases: map[string]bool{"primary": true}},
+			expectError: "unknown database 'unknown'",
+		},
+		{
+			name:        "missing SQL",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db"},
+			ctx:         &ValidationContext{Databases: map[string]bool{"db": true}},
+			expectError: "sql is required",
+		},
+		{
+			name:        "empty sql_file",
+			step:        StepConfig{Name: "q", Database: "db", SQLFile: "/etc/queries/empty.sql"},
+			ctx:         &ValidationContext{Databases: map[string]bool{"db": true}},
+			expectError: "sql_file '/etc/queries/empty.sql' is empty",
+		},
+		{
+			name:        "write on readonly",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "INSERT INTO t VALUES (1)"},
+			ctx:         &ValidationContext{Databases: map[string]bool{"db": true}},
+			expectError: "write operation but database 'db' is read-only",
+		},
+		{
+			name:        "fallback without template",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", OnError: "fallback"},
+			expectError: "fallback is required when on_error is 'fallback'",
+		},
+		{
+			name:        "fallback without on_error",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", Fallback: "[]"},
+			expectError: "fallback requires on_error: fallback",
+		},
+		{
+			name:        "group_by without nest_as",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", Transform: &TransformConfig{GroupBy: "id"}},
+			expectError: "transform: group_by requires nest_as",
+		},
+		{
+			name:        "nest_as without group_by",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", Transform: &TransformConfig{NestAs: "items"}},
+			expectError: "transform: nest_as requires group_by",
+		},
+		{
+			name:        "exclude group_by column",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", Transform: &TransformConfig{GroupBy: "id", NestAs: "items", Exclude: []string{"id"}}},
+			expectError: "cannot exclude the group_by column 'id'",
+		},
+		{
+			name:        "result_sets with cache",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", ResultSets: []string{"a"}, Cache: &StepCacheConfig{Key: "k"}},
+			expectError: "cache is not supported with result_sets",
+		},
+		{
+			name:        "invalid result set name",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", ResultSets: []string{"line-items"}},
+			expectError: "result_sets[0]: name must be a letter or underscore",
+		},
+		{
+			name:        "negative timeout",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", TimeoutSec: -1},
+			expectError: "timeout_sec cannot be negative",
+		},
+		{
+			name:        "negative max_rows",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", MaxRows: -1},
+			expectError: "max_rows cannot be negative",
+		},
+		{
+			name:        "invalid on_limit",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", MaxRows: 10, OnLimit: "drop"},
+			expectError: "on_limit must be 'error', 'truncate', or 'paginate'",
+		},
+		{
+			name:        "paginate with result_sets",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1 ORDER BY 1", MaxRows: 10, OnLimit: "paginate", ResultSets: []string{"a"}},
+			expectError: "on_limit 'paginate' is not supported with proc or result_sets",
+		},
+		{
+			name:        "page_offset without paginate",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", MaxRows: 10, OnLimit: "truncate", PageOffset: "{{.trigger.params.offset}}"},
+			expectError: "page_offset requires on_limit 'paginate'",
+		},
+		{
+			name:        "max_rows on response step",
+			step:        StepConfig{Type: "response", Template: "{}", MaxRows: 10},
+			expectError: "only supported for query steps",
+		},
+		{
+			name:        "public_id_columns on httpcall step",
+			step:        StepConfig{Name: "call", Type: "httpcall", URL: "http://example.com", PublicIDColumns: map[string]string{"id": "user"}},
+			expectError: "public_id_columns is only supported for query steps",
+		},
+		{
+			name:        "public_id_columns with empty column",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", PublicIDColumns: map[string]string{"": "user"}},
+			expectError: "public_id_columns has an empty column name",
+		},
+		{
+			name:        "mask_columns on httpcall step",
+			step:        StepConfig{Name: "call", Type: "httpcall", URL: "http://example.com", MaskColumns: map[string]string{"email": "full"}},
+			expectError: "mask_columns and unmask_when are only supported for query steps",
+		},
+		{
+			name:        "invalid mask strategy",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", MaskColumns: map[string]string{"email": "blur"}},
+			expectError: "mask_columns[email] must be 'full', 'partial', 'hash', or 'last4'",
+		},
+		{
+			name:        "invalid unmask_when",
+			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1", MaskColumns: map[string]string{"email": "full"}, UnmaskWhen: "role =="},
+			expectError: "unmask_when: invalid expression",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps:    []StepConfig{tt.step, {Type: "response", Template: "{}"}},
+			}
+			result := Validate(cfg, tt.ctx)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+func TestValidate_QueryStepOutbox(t *testing.T) {
+	ctx := &ValidationContext{Databases: map[string]bool{"db": false, "other": false}, Outboxes: map[string]bool{"db": true}}
+	valid := StepConfig{
+		Name:     "q",
+		Type:     "query",
+		Database: "db",
+		SQL:      "INSERT INTO orders (id) VALUES (@id)",
+		Outbox:   []OutboxEventConfig{{Topic: "order.created", Key: "{{.trigger.params.id}}", Payload: `{"id": {{.trigger.params.id}}}`}},
+	}
+	with := func(modify func(*StepConfig)) StepConfig {
+		step := valid
+		modify(&step)
+		return step
+	}
+
+	tests := []struct {
+		name        string
+		step        StepConfig
+		expectError string
+	}{
+		{"database without outbox", with(func(s *StepConfig) { s.Database = "other" }), "outbox requires an outbox section for database 'other'"},
+		{"missing topic", with(func(s *StepConfig) { s.Outbox = []OutboxEventConfig{{Payload: "{}"}} }), "outbox[0]: topic is required"},
+		{"invalid payload template", with(func(s *StepConfig) { s.Outbox = []OutboxEventConfig{{Topic: "t", Payload: "{{.x"}} }), "outbox[0].payload: invalid template"},
+		{"with proc", with(func(s *StepConfig) { s.SQL, s.Proc = "", &ProcConfig{Name: "dbo.PlaceOrder"} }), "outbox is not supported with proc"},
+		{"with cache", with(func(s *StepConfig) { s.Cache = &StepCacheConfig{Key: "k"} }), "cache is not supported with outbox"},
+		{"on httpcall step", StepConfig{Name: "call", Type: "httpcall", URL: "http://example.com", Outbox: valid.Outbox}, "outbox is only supported for query steps"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "POST"}},
+				Steps:    []StepConfig{tt.step, {Type: "response", Template: "{}"}},
+			}
+			result := Validate(cfg, ctx)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name:     "test",
+			Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "POST"}},
+			Steps:    []StepConfig{valid, {Type: "response", Template: "{}"}},
+		}
+		result := Validate(cfg, ctx)
+		if !result.Valid || len(result.Warnings) > 0 {
+			t.Errorf("expected valid without warnings, got: %v %v", result.Errors, result.Warnings)
+		}
+	})
+}
+
+func TestValidate_ProcStep(t *testing.T) {
+	ctx := &ValidationContext{
+		Databases:     map[string]bool{"mssql": false, "lite": false},
+		DatabaseTypes: map[string]string{"mssql": "sqlserver", "lite": "sqlite"},
+	}
+	proc := func(p ProcConfig) *ProcConfig { return &p }
+
+	valid := StepConfig{
+		Name:     "place",
+		Database: "mssql",
+		Proc: proc(ProcConfig{Name: "dbo.PlaceOrder", Params: []ProcParamConfig{
+			{Name: "CustomerId"},
+			{Name: "Total", Value: "trigger.params.total * 100", Type: "int"},
+			{Name: "Note", Value: "{{.trigger.params.note}}"},
+			{Name: "OrderId", Direction: "out", Type: "int"},
+			{Name: "Attempts", Direction: "inout", Type: "int", Value: "1"},
+		}}),
+		ResultSets: []string{"order", "lines"},
+	}
+	cfg := &WorkflowConfig{
+		Name:     "test",
+		Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "POST"}},
+		Steps:    []StepConfig{valid, {Type: "response", Template: "{}"}},
+	}
+	if result := Validate(cfg, ctx); !result.Valid {
+		t.Fatalf("expected valid proc step, got: %v", result.Errors)
+	}
+
+	tests := []struct {
+		name        string
+		step        StepConfig
+		expectError string
+	}{
+		{
+			name:        "missing name",
+			step:        StepConfig{Name: "p", Database: "mssql", Proc: proc(ProcConfig{})},
+			expectError: "proc: name is required",
+		},
+		{
+			name:        "invalid name",
+			step:        StepConfig{Name: "p", Database: "mssql", Proc: proc(ProcConfig{Name: "dbo.Place; DROP TABLE x"})},
+			expectError: "invalid procedure name",
+		},
+		{
+			name:        "sql and proc",
+			step:        StepConfig{Name: "p", Database: "mssql", SQL: "SELECT 1", Proc: proc(ProcConfig{Name: "p"})},
+			expectError: "sql and proc are mutually exclusive",
+		},
+		{
+			name:        "sqlite database",
+			step:        StepConfig{Name: "p", Database: "lite", Proc: proc(ProcConfig{Name: "p"})},
+			expectError: "does not support stored procedures",
+		},
+		{
+			name:        "cache",
+			step:        StepConfig{Name: "p", Database: "mssql", Proc: proc(ProcConfig{Name: "p"}), Cache: &StepCacheConfig{Key: "k"}},
+			expectError: "cache is not supported with proc",
+		},
+		{
+			name:        "invalid direction",
+			step:        StepConfig{Name: "p", Database: "mssql", Proc: proc(ProcConfig{Name: "p", Params: []ProcParamConfig{{Name: "a", Direction: "output"}}})},
+			expectError: "proc.params[0]: invalid direction 'output'",
+		},
+		{
+			name:        "out without type",
+			step:        StepConfig{Name: "p", Database: "mssql", Proc: proc(ProcConfig{Name: "p", Params: []ProcParamConfig{{Name: "a", Direction: "out"}}})},
+			expectError: "type is required for out parameters",
+		},
+		{
+			name:        "invalid type",
+			step:        StepConfig{Name: "p", Database: "mssql", Proc: proc(ProcConfig{Name: "p", Params: []ProcParamConfig{{Name: "a", Type: "money"}}})},
+			expectError: "invalid type 'money'",
+		},
+		{
+			name:        "out with value",
+			step:        StepConfig{Name: "p", Database: "mssql", Proc: proc(ProcConfig{Name: "p", Params: []ProcParamConfig{{Name: "a", Direction: "out", Type: "int", Value: "1"}}})},
+			expectError: "value is not allowed for out parameters",
+		},
+		{
+			name:        "duplicate param",
+			step:        StepConfig{Name: "p", Database: "mssql", Proc: proc(ProcConfig{Name: "p", Params: []ProcParamConfig{{Name: "Id"}, {Name: "id"}}})},
+			expectError: "duplicate parameter 'id'",
+		},
+		{
+			name:        "invalid value expression",
+			step:        StepConfig{Name: "p", Database: "mssql", Proc: proc(ProcConfig{Name: "p", Params: []ProcParamConfig{{Name: "a", Value: "trigger.params.("}}})},
+			expectError: "proc.params[0]: invalid expression",
+		},
+		{
+			name:        "duplicate result set",
+			step:        StepConfig{Name: "p", Database: "mssql", Proc: proc(ProcConfig{Name: "p"}), ResultSets: []string{"a", "a"}},
+			expectError: "result_sets[1]: duplicate name 'a'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps:    []StepConfig{tt.step, {Type: "response", Template: "{}"}},
+			}
+			result := Validate(cfg, ctx)
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+// TestValidate_ReadOnlyWriteDetection verifies the read-only check is literal-aware in both directions
+func TestValidate_ReadOnlyWriteDetection(t *testing.T) {
+	tests := []struct {
+		name      string
+		sql       string
+		wantValid bool
+	}{
+		// Must be REJECTED: these can mutate a read-only database
+		{"insert", "INSERT INTO t VALUES (1)", false},
+		{"merge", "MERGE INTO t USING s ON t.id = s.id WHEN MATCHED THEN UPDATE SET t.x = s.x", false},
+		{"exec procedure", "EXEC sp_rebuild_index", false},
+		{"execute procedure", "EXECUTE sp_rebuild_index", false},
+		{"call procedure", "CALL rebuild_index()", false},
+		{"cte with insert", "WITH c AS (SELECT 1) INSERT INTO t SELECT * FROM c", false},
+		{"leading semicolon cte", ";WITH c AS (SELECT 1) INSERT INTO t SELECT * FROM c", false},
+		{"cte inside batch", "SELECT 1; WITH c AS (SELECT 1) INSERT INTO t SELECT * FROM c", false},
+		{"write hidden after leading read", "SELECT 1; DROP TABLE users", false},
+		{"write inside if block", "IF EXISTS (SELECT 1 FROM t) BEGIN INSERT INTO t VALUES (1) END", false},
+		{"write inside while block", "WHILE (1=1) BEGIN DELETE FROM t END", false},
+		{"procedure call inside if", "IF (1=1) EXEC sp_rebuild", false},
+
+		// Must be ACCEPTED: a write keyword appearing in a literal or comment is not a write.
+		// Rejecting these would refuse to start the server on a valid config.
+		{"delete inside string literal", "SELECT * FROM notes WHERE body = 'DELETE ME'", true},
+		{"create inside line comment", "SELECT id FROM t -- CREATE a report", true},
+		{"update inside block comment", "SELECT /* UPDATE users SET x = 1 */ id FROM t", true},
+		{"exec inside string literal", "SELECT * FROM audit WHERE cmd = 'EXEC sp_evil'", true},
+		{"write keyword as column name", "SELECT executed_at, created_at FROM jobs", true},
+		{"semicolon inside string literal", "SELECT * FROM t WHERE s = 'a; DROP TABLE users'", true},
+		{"quoted identifier", "SELECT [insert] FROM t", true},
+		{"read only cte", "WITH c AS (SELECT 1) SELECT * FROM c", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps: []StepConfig{
+					{Name: "q", Type: "query", Database: "db", SQL: tt.sql},
+					{Type: "response", Template: "{}"},
+				},
+			}
+			ctx := &ValidationContext{Databases: map[string]bool{"db": true}} // true = read-only
+			result := Validate(cfg, ctx)
+
+			if tt.wantValid && !result.Valid {
+				t.Errorf("expected valid, got errors: %v", result.Errors)
+			}
+			if !tt.wantValid {
+				if result.Valid {
+					t.Error("expected validation to fail")
+				} else if !containsError(result.Errors, "write operation but database 'db' is read-only") {
+					t.Errorf("expected read-only error, got: %v", result.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_HTTPCallStep(t *testing.T) {
+	tests := []struct {
+		name        string
+		step        StepConfig
+		expectError string
+	}{
+		{
+			name:        "missing URL",
+			step:        StepConfig{Name: "h", Type: "httpcall"},
+			expectError: "url is required",
+		},
+		{
+			name:        "invalid HTTP method",
+			step:        StepConfig{Name: "h", Type: "httpcall", URL: "http://example.com", HTTPMethod: "INVALID"},
+			expectError: "invalid http_method",
+		},
+		{
+			name:        "invalid parse mode",
+			step:        StepConfig{Name: "h", Type: "httpcall", URL: "http://example.com", Parse: "xml"},
+			expectError: "invalid parse mode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps:    []StepConfig{tt.step, {Type: "response", Template: "{}"}},
+			}
+			result := Validate(cfg, nil)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+func TestValidate_ResponseStep(t *testing.T) {
+	tests := []struct {
+		name        string
+		step        StepConfig
+		expectError string
+	}{
+		{
+			name:        "missing template",
+			step:        StepConfig{Type: "response"},
+			expectError: "template is required",
+		},
+		{
+			name:        "invalid status code",
+			step:        StepConfig{Type: "response", Template: "{}", StatusCode: 999},
+			expectError: "status_code must be 100-599",
+		},
+		{
+			name:        "fallback not supported",
+			step:        StepConfig{Type: "response", Template: "{}", OnError: "fallback", Fallback: "{}"},
+			expectError: "on_error 'fallback' is only supported for query and httpcall steps",
+		},
+		{
+			name:        "transform not supported",
+			step:        StepConfig{Type: "response", Template: "{}", Transform: &TransformConfig{Exclude: []string{"a"}}},
+			expectError: "transform is only supported for query steps",
+		},
+		{
+			name:        "timeout not supported",
+			step:        StepConfig{Type: "response", Template: "{}", TimeoutSec: 5},
+			expectError: "timeout_sec is only supported for query, httpcall, script, email, notify, storage, sftp, bulk_insert, mqtt, and block steps",
+		},
+		{
+			name:        "invalid template type",
+			step:        StepConfig{Type: "response", Template: "{}", TemplateType: "xml"},
+			expectError: "invalid template_type 'xml'",
+		},
+		{
+			name:        "schema with html template",
+			step:        StepConfig{Type: "response", Template: "<p>ok</p>", TemplateType: "html", Schema: map[string]any{"type": "object"}},
+			expectError: "schema requires a JSON response",
+		},
+		{
+			name:        "template type on query step",
+			step:        StepConfig{Type: "query", Database: "db", SQL: "SELECT 1", TemplateType: "html"},
+			expectError: "template_type is only supported for response steps",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps:    []StepConfig{tt.step},
+			}
+			result := Validate(cfg, nil)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+func TestValidate_ResponseSSEStep(t *testing.T) {
+	tests := []struct {
+		name        string
+		triggers    []TriggerConfig
+		steps       []StepConfig
+		expectError string
+	}{
+		{
+			name:        "missing template and source",
+			steps:       []StepConfig{{Name: "e", Type: "response_sse"}},
+			expectError: "requires exactly one of template or source",
+		},
+		{
+			name:        "template and source",
+			steps:       []StepConfig{{Name: "e", Type: "response_sse", Template: "{}", Source: "trigger.params"}},
+			expectError: "requires exactly one of template or source",
+		},
+		{
+			name:        "invalid source",
+			steps:       []StepConfig{{Name: "e", Type: "response_sse", Source: "steps.("}},
+			expectError: "source: invalid expression",
+		},
+		{
+			name:        "negative batch_size",
+			steps:       []StepConfig{{Name: "e", Type: "response_sse", Source: "trigger.params", BatchSize: -1}},
+			expectError: "batch_size cannot be negative",
+		},
+		{
+			name:        "reserved event",
+			steps:       []StepConfig{{Name: "e", Type: "response_sse", Template: "{}", Event: "complete"}},
+			expectError: "event 'complete' is reserved",
+		},
+		{
+			name:        "event with line break",
+			steps:       []StepConfig{{Name: "e", Type: "response_sse", Template: "{}", Event: "a\nb"}},
+			expectError: "event cannot contain line breaks",
+		},
+		{
+			name:        "status code",
+			steps:       []StepConfig{{Name: "e", Type: "response_sse", Template: "{}", StatusCode: 202}},
+			expectError: "status_code and headers are not supported for response_sse steps",
+		},
+		{
+			name:        "event on response step",
+			steps:       []StepConfig{{Type: "response", Template: "{}", Event: "done"}},
+			expectError: "event is only supported for response_sse steps",
+		},
+		{
+			name:        "cron trigger",
+			triggers:    []TriggerConfig{{Type: "cron", Schedule: "0 * * * *"}},
+			steps:       []StepConfig{{Name: "e", Type: "response_sse", Template: "{}"}},
+			expectError: "response_sse steps are only valid for HTTP triggers",
+		},
+		{
+			name:        "trigger cache",
+			triggers:    []TriggerConfig{{Type: "http", Path: "/test", Method: "GET", Cache: &CacheConfig{Enabled: true, Key: "k"}}},
+			steps:       []StepConfig{{Name: "b", Steps: []StepConfig{{Name: "e", Type: "response_sse", Template: "{}"}}}},
+			expectError: "cache cannot be used with response_sse steps",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			triggers := tt.triggers
+			if triggers == nil {
+				triggers = []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}}
+			}
+			cfg := &WorkflowConfig{Name: "test", Triggers: triggers, Steps: tt.steps}
+			result := Validate(cfg, nil)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+
+	t.Run("streams without a response step", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name:     "test",
+			Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+			Steps: []StepConfig{
+				{Name: "fetch", Type: "query", Database: "db", SQL: "SELECT 1"},
+				{Name: "rows", Type: "response_sse", Event: "rows", Source: "steps.fetch.data", BatchSize: 50},
+			},
+		}
+		result := Validate(cfg, nil)
+		if !result.Valid {
+			t.Fatalf("unexpected errors: %v", result.Errors)
+		}
+		if containsWarning(result.Warnings, "no response step") {
+			t.Errorf("unexpected warning: %v", result.Warnings)
+		}
+	})
+}
+
+func TestValidate_CacheInvalidateStep(t *testing.T) {
+	tests := []struct {
+		name        string
+		step        StepConfig
+		expectError string
+	}{
+		{
+			name:        "missing tags",
+			step:        StepConfig{Type: "cache_invalidate"},
+			expectError: "tags is required for cache_invalidate step",
+		},
+		{
+			name:        "empty tag",
+			step:        StepConfig{Type: "cache_invalidate", Tags: []string{"user:1", " "}},
+			expectError: "tags[1]: tag cannot be empty",
+		},
+		{
+			name:        "empty step cache tag",
+			step:        StepConfig{Type: "query", Database: "db", SQL: "SELECT 1", Cache: &StepCacheConfig{Key: "k", Tags: []string{""}}},
+			expectError: "cache.tags[0]: tag cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps:    []StepConfig{tt.step},
+			}
+			result := Validate(cfg, nil)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name: "test",
+			Triggers: []TriggerConfig{{
+				Type: "http", Path: "/test", Method: "GET",
+				Cache: &CacheConfig{Enabled: true, Key: "k", Tags: []string{"user:{{.trigger.params.id}}"}},
+			}},
+			Steps: []StepConfig{
+				{Name: "bust", Type: "cache_invalidate", Tags: []string{"user:{{.trigger.params.id}}"}},
+				{Type: "response", Template: "{}"},
+			},
+		}
+		result := Validate(cfg, nil)
+		if !result.Valid {
+			t.Errorf("expected valid config, got: %v", result.Errors)
+		}
+	})
+}
+
+func TestValidate_SetStep(t *testing.T) {
+	tests := []struct {
+		name        string
+		steps       []StepConfig
+		expectError string
+	}{
+		{
+			name:        "missing values",
+			steps:       []StepConfig{{Name: "s", Type: "set"}},
+			expectError: "values is required for set step",
+		},
+		{
+			name:        "invalid value name",
+			steps:       []StepConfig{{Name: "s", Type: "set", Values: map[string]string{"start-date": "1"}}},
+			expectError: "values[start-date]: name must be a letter or underscore",
+		},
+		{
+			name:        "reserved value name",
+			steps:       []StepConfig{{Name: "s", Type: "set", Values: map[string]string{"success": "true"}}},
+			expectError: "values[success]: name is reserved for step results",
+		},
+		{
+			name:        "invalid expression",
+			steps:       []StepConfig{{Name: "s", Type: "set", Values: map[string]string{"total": "1 +"}}},
+			expectError: "values[total]: invalid expression",
+		},
+		{
+			name: "forward reference",
+			steps: []StepConfig{
+				{Name: "s", Type: "set", Values: map[string]string{"n": "steps.q.count"}},
+				{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1"},
+			},
+			expectError: "values[n]: references unknown step 'q'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps:    append(tt.steps, StepConfig{Name: "respond", Type: "response", Template: "{}"}),
+			}
+			result := Validate(cfg, nil)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+
+	cfg := &WorkflowConfig{
+		Name:     "test",
+		Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+		Steps: []StepConfig{
+			{Name: "derived", Values: map[string]string{
+				"is_admin": `trigger.params.role == "admin"`,
+				"label":    `{{.trigger.params.name}} ({{.trigger.params.role}})`,
+			}},
+			{Name: "respond", Type: "response", Template: "{}", Condition: "steps.derived.is_admin"},
+		},
+	}
+	if result := Validate(cfg, nil); !result.Valid {
+		t.Errorf("expected valid set step, got: %v", result.Errors)
+	}
+}
+
+func TestValidate_ScriptStep(t *testing.T) {
+	tests := []struct {
+		name        string
+		step        StepConfig
+		expectError string
+	}{
+		{
+			name:        "missing script",
+			step:        StepConfig{Name: "s", Type: "script"},
+			expectError: "script is required for script step",
+		},
+		{
+			name:        "invalid script",
+			step:        StepConfig{Name: "s", Type: "script", Script: "return steps.map(s =>"},
+			expectError: "script: invalid script",
+		},
+		{
+			name:        "negative memory",
+			step:        StepConfig{Name: "s", Type: "script", Script: "return 1", MemoryMB: -1},
+			expectError: "memory_mb cannot be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps:    []StepConfig{tt.step, {Name: "respond", Type: "response", Template: "{}"}},
+			}
+			result := Validate(cfg, nil)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
 			}
 		})
 	}
 }
 
-// TestValidate_EvictCronSchedule verifies cache evict_cron accepts the same dialect as triggers
-func TestValidate_EvictCronSchedule(t *testing.T) {
+func TestValidate_EmailStep(t *testing.T) {
+	smtp := &ValidationContext{SMTP: true}
 	tests := []struct {
 		name        string
-		evictCron   string
-		expectError string // empty means the config must validate
+		step        StepConfig
+		ctx         *ValidationContext
+		expectError string
 	}{
-		{"five fields", "0 3 * * *", ""},
-		{"descriptor", "@daily", ""},
-		{"every", "@every 30m", ""},
-		{"timezone prefix", "TZ=UTC @daily", ""},
-		{"sub-second every", "@every 100ms", "invalid evict_cron"},
-		{"timezone without schedule", "TZ=UTC", "invalid evict_cron"},
-		{"gibberish", "not-a-schedule", "invalid evict_cron"},
+		{
+			name:        "missing to",
+			step:        StepConfig{Name: "e", Type: "email", Subject: "s", Text: "t"},
+			expectError: "to is required for email step",
+		},
+		{
+			name:        "missing subject",
+			step:        StepConfig{Name: "e", Type: "email", To: []string{"a@example.com"}, Text: "t"},
+			expectError: "subject is required for email step",
+		},
+		{
+			name:        "missing body",
+			step:        StepConfig{Name: "e", Type: "email", To: []string{"a@example.com"}, Subject: "s"},
+			expectError: "text or html is required for email step",
+		},
+		{
+			name:        "invalid template",
+			step:        StepConfig{Name: "e", Type: "email", To: []string{"a@example.com"}, Subject: "{{.x", Text: "t"},
+			expectError: "subject template",
+		},
+		{
+			name:        "smtp not configured",
+			step:        StepConfig{Name: "e", Type: "email", To: []string{"a@example.com"}, Subject: "s", Text: "t"},
+			ctx:         &ValidationContext{},
+			expectError: "email step requires smtp to be configured",
+		},
+		{
+			name:        "negative timeout",
+			step:        StepConfig{Name: "e", Type: "email", To: []string{"a@example.com"}, Subject: "s", Text: "t", TimeoutSec: -1},
+			ctx:         smtp,
+			expectError: "timeout_sec cannot be negative",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := &WorkflowConfig{
-				Name: "test",
-				Triggers: []TriggerConfig{{
-					Type: "http", Path: "/test", Method: "GET",
-					Cache: &CacheConfig{Enabled: true, Key: "k", EvictCron: tt.evictCron},
-				}},
-				Steps: []StepConfig{
-					{Name: "run", Type: "query", Database: "db", SQL: "SELECT 1"},
-					{Type: "response", Template: "{}"},
-				},
-			}
-			ctx := &ValidationContext{Databases: map[string]bool{"db": false}}
-			result := Validate(cfg, ctx)
-
-			if tt.expectError == "" {
-				if !result.Valid {
-					t.Errorf("expected valid, got errors: %v", result.Errors)
-				}
-				return
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "cron", Schedule: "0 8 * * *"}},
+				Steps:    []StepConfig{tt.step},
 			}
+			result := Validate(cfg, tt.ctx)
 			if result.Valid {
-				t.Fatal("expected validation to fail")
+				t.Error("expected validation to fail")
 			}
 			if !containsError(result.Errors, tt.expectError) {
 				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
 			}
 		})
 	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name:     "test",
+			Triggers: []TriggerConfig{{Type: "cron", Schedule: "0 8 * * *"}},
+			Steps: []StepConfig{{
+				Name: "e", Type: "email", To: []string{"{{.vars.team}}"}, Subject: "Report", HTML: "<b>ok</b>", TimeoutSec: 10,
+			}},
+		}
+		if result := Validate(cfg, smtp); !result.Valid {
+			t.Errorf("expected valid, got: %v", result.Errors)
+		}
+	})
 }
 
-func TestValidate_QueryStep(t *testing.T) {
+func TestValidate_NotifyStep(t *testing.T) {
 	tests := []struct {
 		name        string
 		step        StepConfig
-		ctx         *ValidationContext
 		expectError string
 	}{
 		{
-			name:        "missing database",
-			step:        StepConfig{Name: "q", Type: "query", SQL: "SELECT 1"},
-			expectError: "database is required",
+			name:        "missing provider",
+			step:        StepConfig{Name: "n", Type: "notify", URL: "https://hooks.example.com", Text: "hi"},
+			expectError: "provider is required for notify step",
 		},
 		{
-			name:        "unknown database",
-			step:        StepConfig{Name: "q", Type: "query", Database: "unknown", SQL: "SELECT 1"},
-			ctx:         &ValidationContext{Databases: map[string]bool{"primary": true}},
-			expectError: "unknown database 'unknown'",
+			name:        "invalid provider",
+			step:        StepConfig{Name: "n", Type: "notify", Provider: "pagerduty", URL: "https://hooks.example.com", Text: "hi"},
+			expectError: "invalid provider 'pagerduty'",
 		},
 		{
-			name:        "missing SQL",
-			step:        StepConfig{Name: "q", Type: "query", Database: "db"},
-			ctx:         &ValidationContext{Databases: map[string]bool{"db": true}},
-			expectError: "sql is required",
+			name:        "missing url",
+			step:        StepConfig{Name: "n", Type: "notify", Provider: "slack", Text: "hi"},
+			expectError: "url is required for notify step",
 		},
 		{
-			name:        "write on readonly",
-			step:        StepConfig{Name: "q", Type: "query", Database: "db", SQL: "INSERT INTO t VALUES (1)"},
-			ctx:         &ValidationContext{Databases: map[string]bool{"db": true}},
-			expectError: "write operation but database 'db' is read-only",
+			name:        "missing text and blocks",
+			step:        StepConfig{Name: "n", Type: "notify", Provider: "teams", URL: "https://hooks.example.com", Title: "t"},
+			expectError: "text or blocks is required for notify step",
+		},
+		{
+			name:        "invalid when",
+			step:        StepConfig{Name: "n", Type: "notify", Provider: "slack", URL: "https://hooks.example.com", Text: "hi", When: "error"},
+			expectError: "invalid when 'error'",
+		},
+		{
+			name:        "invalid blocks template",
+			step:        StepConfig{Name: "n", Type: "notify", Provider: "slack", URL: "https://hooks.example.com", Blocks: "[{{.x]"},
+			expectError: "blocks: invalid template",
+		},
+		{
+			name:        "when on other step type",
+			step:        StepConfig{Name: "n", Type: "query", Database: "db", SQL: "SELECT 1", When: "failure"},
+			expectError: "when is only supported for notify steps",
+		},
+		{
+			name: "when in block",
+			step: StepConfig{Name: "b", Steps: []StepConfig{
+				{Name: "n", Type: "notify", Provider: "slack", URL: "https://hooks.example.com", Text: "hi", When: "failure"},
+			}},
+			expectError: "when is not supported in blocks",
 		},
 	}
 
@@ -413,10 +2103,10 @@ func TestValidate_QueryStep(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := &WorkflowConfig{
 				Name:     "test",
-				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
-				Steps:    []StepConfig{tt.step, {Type: "response", Template: "{}"}},
+				Triggers: []TriggerConfig{{Type: "cron", Schedule: "0 8 * * *"}},
+				Steps:    []StepConfig{tt.step},
 			}
-			result := Validate(cfg, tt.ctx)
+			result := Validate(cfg, nil)
 			if result.Valid {
 				t.Error("expected validation to fail")
 			}
@@ -425,99 +2115,178 @@ func TestValidate_QueryStep(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name:     "test",
+			Triggers: []TriggerConfig{{Type: "cron", Schedule: "0 8 * * *"}},
+			Steps: []StepConfig{{
+				Name: "n", Type: "notify", Provider: "teams", URL: "{{.vars.teams_url}}", Title: "Failed",
+				Text: "{{.workflow.error}}", When: "failure", TimeoutSec: 5,
+			}},
+		}
+		if result := Validate(cfg, nil); !result.Valid {
+			t.Errorf("expected valid, got: %v", result.Errors)
+		}
+	})
 }
 
-// TestValidate_ReadOnlyWriteDetection verifies the read-only check is literal-aware in both directions
-func TestValidate_ReadOnlyWriteDetection(t *testing.T) {
+func TestValidate_StorageStep(t *testing.T) {
+	stores := &ValidationContext{Stores: map[string]bool{"exports": true}}
+	valid := StepConfig{Name: "s", Type: "storage", Store: "exports", Bucket: "b", Key: "k.json", Body: "{}"}
+	with := func(modify func(*StepConfig)) StepConfig {
+		step := valid
+		modify(&step)
+		return step
+	}
+
 	tests := []struct {
-		name      string
-		sql       string
-		wantValid bool
+		name        string
+		step        StepConfig
+		expectError string
 	}{
-		// Must be REJECTED: these can mutate a read-only database
-		{"insert", "INSERT INTO t VALUES (1)", false},
-		{"merge", "MERGE INTO t USING s ON t.id = s.id WHEN MATCHED THEN UPDATE SET t.x = s.x", false},
-		{"exec procedure", "EXEC sp_rebuild_index", false},
-		{"execute procedure", "EXECUTE sp_rebuild_index", false},
-		{"call procedure", "CALL rebuild_index()", false},
-		{"cte with insert", "WITH c AS (SELECT 1) INSERT INTO t SELECT * FROM c", false},
-		{"leading semicolon cte", ";WITH c AS (SELECT 1) INSERT INTO t SELECT * FROM c", false},
-		{"cte inside batch", "SELECT 1; WITH c AS (SELECT 1) INSERT INTO t SELECT * FROM c", false},
-		{"write hidden after leading read", "SELECT 1; DROP TABLE users", false},
-		{"write inside if block", "IF EXISTS (SELECT 1 FROM t) BEGIN INSERT INTO t VALUES (1) END", false},
-		{"write inside while block", "WHILE (1=1) BEGIN DELETE FROM t END", false},
-		{"procedure call inside if", "IF (1=1) EXEC sp_rebuild", false},
-
-		// Must be ACCEPTED: a write keyword appearing in a literal or comment is not a write.
-		// Rejecting these would refuse to start the server on a valid config.
-		{"delete inside string literal", "SELECT * FROM notes WHERE body = 'DELETE ME'", true},
-		{"create inside line comment", "SELECT id FROM t -- CREATE a report", true},
-		{"update inside block comment", "SELECT /* UPDATE users SET x = 1 */ id FROM t", true},
-		{"exec inside string literal", "SELECT * FROM audit WHERE cmd = 'EXEC sp_evil'", true},
-		{"write keyword as column name", "SELECT executed_at, created_at FROM jobs", true},
-		{"semicolon inside string literal", "SELECT * FROM t WHERE s = 'a; DROP TABLE users'", true},
-		{"quoted identifier", "SELECT [insert] FROM t", true},
-		{"read only cte", "WITH c AS (SELECT 1) SELECT * FROM c", true},
+		{"missing store", with(func(s *StepConfig) { s.Store = "" }), "store is required for storage step"},
+		{"unknown store", with(func(s *StepConfig) { s.Store = "archive" }), "unknown store 'archive'"},
+		{"missing bucket", with(func(s *StepConfig) { s.Bucket = "" }), "bucket is required for storage step"},
+		{"missing key", with(func(s *StepConfig) { s.Key = "" }), "key is required for storage step"},
+		{"no content", with(func(s *StepConfig) { s.Body = "" }), "exactly one of body or source"},
+		{"body and source", with(func(s *StepConfig) { s.Source = "[]" }), "exactly one of body or source"},
+		{"format with body", with(func(s *StepConfig) { s.Format = "csv" }), "format requires source"},
+		{"invalid format", with(func(s *StepConfig) { s.Body, s.Source, s.Format = "", "[]", "xml" }), "invalid format 'xml'"},
+		{"columns without csv", with(func(s *StepConfig) { s.Body, s.Source, s.Columns = "", "[]", []string{"id"} }), "columns requires format: csv"},
+		{"invalid source", with(func(s *StepConfig) { s.Body, s.Source = "", "steps.(" }), "source: invalid expression"},
+		{"invalid encryption", with(func(s *StepConfig) { s.Encryption = "aes" }), "invalid encryption 'aes'"},
+		{"kms key without kms", with(func(s *StepConfig) { s.Encryption, s.KMSKeyID = "AES256", "k1" }), "kms_key_id requires encryption: aws:kms"},
+		{"invalid key template", with(func(s *StepConfig) { s.Key = "{{.x" }), "key: invalid template"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := &WorkflowConfig{
 				Name:     "test",
-				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
-				Steps: []StepConfig{
-					{Name: "q", Type: "query", Database: "db", SQL: tt.sql},
-					{Type: "response", Template: "{}"},
-				},
+				Triggers: []TriggerConfig{{Type: "cron", Schedule: "0 2 * * *"}},
+				Steps:    []StepConfig{tt.step},
 			}
-			ctx := &ValidationContext{Databases: map[string]bool{"db": true}} // true = read-only
-			result := Validate(cfg, ctx)
+			result := Validate(cfg, stores)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
 
-			if tt.wantValid && !result.Valid {
-				t.Errorf("expected valid, got errors: %v", result.Errors)
+	t.Run("valid", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name:     "test",
+			Triggers: []TriggerConfig{{Type: "cron", Schedule: "0 2 * * *"}},
+			Steps: []StepConfig{
+				{Name: "rows", Type: "query", Database: "db", SQL: "SELECT 1"},
+				{
+					Name: "s", Type: "storage", Store: "exports", Bucket: "b", Key: "{{.workflow.request_id}}.csv",
+					Source: "steps.rows.data", Format: "csv", Encryption: "aws:kms", KMSKeyID: "k1", TimeoutSec: 30,
+				},
+			},
+		}
+		ctx := &ValidationContext{Databases: map[string]bool{"db": false}, Stores: stores.Stores}
+		if result := Validate(cfg, ctx); !result.Valid {
+			t.Errorf("expected valid, got: %v", result.Errors)
+		}
+	})
+}
+
+func TestValidate_SFTPStep(t *testing.T) {
+	servers := &ValidationContext{SFTPServers: map[string]bool{"partner": true}}
+	valid := StepConfig{Name: "s", Type: "sftp", Server: "partner", RemotePath: "/in/{{.workflow.request_id}}.json", Body: "{}"}
+	with := func(modify func(*StepConfig)) StepConfig {
+		step := valid
+		modify(&step)
+		return step
+	}
+
+	tests := []struct {
+		name        string
+		step        StepConfig
+		expectError string
+	}{
+		{"missing server", with(func(s *StepConfig) { s.Server = "" }), "server is required for sftp step"},
+		{"unknown server", with(func(s *StepConfig) { s.Server = "vendor" }), "unknown sftp server 'vendor'"},
+		{"missing remote_path", with(func(s *StepConfig) { s.RemotePath = "" }), "remote_path is required for sftp step"},
+		{"no content", with(func(s *StepConfig) { s.Body = "" }), "exactly one of body or source is required for sftp step"},
+		{"format with body", with(func(s *StepConfig) { s.Format = "csv" }), "format requires source"},
+		{"negative retry", with(func(s *StepConfig) { s.Retry = &RetryConfig{Enabled: true, MaxAttempts: -1} }), "retry: max_attempts cannot be negative"},
+		{"invalid path template", with(func(s *StepConfig) { s.RemotePath = "{{.x" }), "remote_path: invalid template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "cron", Schedule: "0 2 * * *"}},
+				Steps:    []StepConfig{tt.step},
 			}
-			if !tt.wantValid {
-				if result.Valid {
-					t.Error("expected validation to fail")
-				} else if !containsError(result.Errors, "write operation but database 'db' is read-only") {
-					t.Errorf("expected read-only error, got: %v", result.Errors)
-				}
+			result := Validate(cfg, servers)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
 			}
 		})
 	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name:     "test",
+			Triggers: []TriggerConfig{{Type: "cron", Schedule: "0 2 * * *"}},
+			Steps: []StepConfig{
+				{Name: "rows", Type: "query", Database: "db", SQL: "SELECT 1"},
+				{
+					Name: "s", Type: "sftp", Server: "partner", RemotePath: "/in/rows.csv", CreateDirs: true,
+					Source: "steps.rows.data", Format: "csv", TimeoutSec: 60,
+					Retry: &RetryConfig{Enabled: true, MaxAttempts: 5},
+				},
+			},
+		}
+		ctx := &ValidationContext{Databases: map[string]bool{"db": false}, SFTPServers: servers.SFTPServers}
+		if result := Validate(cfg, ctx); !result.Valid {
+			t.Errorf("expected valid, got: %v", result.Errors)
+		}
+	})
 }
 
-func TestValidate_HTTPCallStep(t *testing.T) {
+func TestValidate_MQTTStep(t *testing.T) {
+	brokers := &ValidationContext{MQTTBrokers: map[string]bool{"plant": true}}
+	valid := StepConfig{Name: "s", Type: "mqtt", Broker: "plant", Topic: "factory/{{.trigger.params.line}}/setpoint", Body: "{}"}
+	with := func(modify func(*StepConfig)) StepConfig {
+		step := valid
+		modify(&step)
+		return step
+	}
+
 	tests := []struct {
 		name        string
 		step        StepConfig
 		expectError string
 	}{
-		{
-			name:        "missing URL",
-			step:        StepConfig{Name: "h", Type: "httpcall"},
-			expectError: "url is required",
-		},
-		{
-			name:        "invalid HTTP method",
-			step:        StepConfig{Name: "h", Type: "httpcall", URL: "http://example.com", HTTPMethod: "INVALID"},
-			expectError: "invalid http_method",
-		},
-		{
-			name:        "invalid parse mode",
-			step:        StepConfig{Name: "h", Type: "httpcall", URL: "http://example.com", Parse: "xml"},
-			expectError: "invalid parse mode",
-		},
+		{"missing broker", with(func(s *StepConfig) { s.Broker = "" }), "broker is required for mqtt step"},
+		{"unknown broker", with(func(s *StepConfig) { s.Broker = "office" }), "unknown mqtt broker 'office'"},
+		{"missing topic", with(func(s *StepConfig) { s.Topic = "" }), "topic is required for mqtt step"},
+		{"wildcard topic", with(func(s *StepConfig) { s.Topic = "factory/+/setpoint" }), "invalid topic 'factory/+/setpoint'"},
+		{"no content", with(func(s *StepConfig) { s.Body = "" }), "exactly one of body or source is required for mqtt step"},
+		{"qos 2", with(func(s *StepConfig) { s.QoS = 2 }), "qos must be 0 or 1"},
+		{"invalid topic template", with(func(s *StepConfig) { s.Topic = "{{.x" }), "topic: invalid template"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := &WorkflowConfig{
 				Name:     "test",
-				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
-				Steps:    []StepConfig{tt.step, {Type: "response", Template: "{}"}},
+				Triggers: []TriggerConfig{{Type: "cron", Schedule: "0 2 * * *"}},
+				Steps:    []StepConfig{tt.step},
 			}
-			result := Validate(cfg, nil)
+			result := Validate(cfg, brokers)
 			if result.Valid {
 				t.Error("expected validation to fail")
 			}
@@ -526,42 +2295,144 @@ func TestValidate_HTTPCallStep(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name:     "test",
+			Triggers: []TriggerConfig{{Type: "cron", Schedule: "0 2 * * *"}},
+			Steps:    []StepConfig{with(func(s *StepConfig) { s.QoS, s.Retain, s.TimeoutSec = 1, true, 10 })},
+		}
+		if result := Validate(cfg, brokers); !result.Valid {
+			t.Errorf("expected valid, got: %v", result.Errors)
+		}
+	})
 }
 
-func TestValidate_ResponseStep(t *testing.T) {
+func TestValidate_MetricStep(t *testing.T) {
+	valid := StepConfig{Name: "depth", Type: "metric", Metric: &MetricConfig{
+		Name:   "export_queue_depth",
+		Type:   "gauge",
+		Value:  "steps.count.data[0].depth",
+		Labels: map[string]string{"queue": "{{.trigger.params.queue}}"},
+	}}
+	with := func(modify func(*MetricConfig)) StepConfig {
+		step := valid
+		m := *valid.Metric
+		modify(&m)
+		step.Metric = &m
+		return step
+	}
+
+	tests := []struct {
+		name        string
+		step        StepConfig
+		expectError string
+	}{
+		{"missing metric", StepConfig{Name: "depth", Type: "metric"}, "metric is required for metric step"},
+		{"missing name", with(func(m *MetricConfig) { m.Name = "" }), "metric: name is required"},
+		{"invalid name", with(func(m *MetricConfig) { m.Name = "queue-depth" }), "invalid name 'queue-depth'"},
+		{"reserved prefix", with(func(m *MetricConfig) { m.Name = "sqlproxy_queue_depth" }), "uses the reserved prefix 'sqlproxy_'"},
+		{"invalid type", with(func(m *MetricConfig) { m.Type = "summary" }), "invalid type 'summary'"},
+		{"gauge without value", with(func(m *MetricConfig) { m.Value = "" }), "value is required for a gauge"},
+		{"invalid value", with(func(m *MetricConfig) { m.Value = "steps.count.data[" }), "metric.value: invalid expression"},
+		{"invalid label name", with(func(m *MetricConfig) { m.Labels = map[string]string{"__queue": "x"} }), "invalid label name '__queue'"},
+		{"invalid label template", with(func(m *MetricConfig) { m.Labels = map[string]string{"queue": "{{.x"} }), "labels[queue]: invalid template"},
+		{"buckets on a gauge", with(func(m *MetricConfig) { m.Buckets = []float64{1, 10} }), "buckets are only supported for histograms"},
+		{"unordered buckets", with(func(m *MetricConfig) { m.Type, m.Buckets = "histogram", []float64{10, 1} }), "buckets must be in increasing order"},
+		{"metric on another step", StepConfig{Name: "s", Type: "set", Values: map[string]string{"x": "1"}, Metric: valid.Metric}, "metric is only supported for metric steps"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "cron", Schedule: "* * * * *"}},
+				Steps:    []StepConfig{{Name: "count", Type: "query", Database: "db", SQL: "SELECT 1 AS depth"}, tt.step},
+			}
+			result := Validate(cfg, nil)
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+
+	t.Run("counter without value", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name:     "test",
+			Triggers: []TriggerConfig{{Type: "cron", Schedule: "* * * * *"}},
+			Steps:    []StepConfig{{Name: "runs", Type: "metric", Metric: &MetricConfig{Name: "export_runs", Type: "counter"}}},
+		}
+		result := Validate(cfg, nil)
+		if !result.Valid {
+			t.Errorf("expected valid, got: %v", result.Errors)
+		}
+		if !containsError(result.Warnings, "counter names conventionally end in _total") {
+			t.Errorf("expected _total warning, got: %v", result.Warnings)
+		}
+	})
+}
+
+func TestValidate_BulkInsertStep(t *testing.T) {
+	ctx := &ValidationContext{Databases: map[string]bool{"db": false, "replica": true}}
+	valid := StepConfig{Name: "load", Type: "bulk_insert", Database: "db", Table: "dbo.Readings", Source: "trigger.params.rows"}
+	with := func(modify func(*StepConfig)) StepConfig {
+		step := valid
+		modify(&step)
+		return step
+	}
+
 	tests := []struct {
 		name        string
 		step        StepConfig
 		expectError string
 	}{
-		{
-			name:        "missing template",
-			step:        StepConfig{Type: "response"},
-			expectError: "template is required",
-		},
-		{
-			name:        "invalid status code",
-			step:        StepConfig{Type: "response", Template: "{}", StatusCode: 999},
-			expectError: "status_code must be 100-599",
-		},
+		{"missing database", with(func(s *StepConfig) { s.Database = "" }), "database is required for bulk_insert step"},
+		{"unknown database", with(func(s *StepConfig) { s.Database = "other" }), "unknown database 'other'"},
+		{"read-only database", with(func(s *StepConfig) { s.Database = "replica" }), "database 'replica' is read-only"},
+		{"missing table", with(func(s *StepConfig) { s.Table = "" }), "table is required for bulk_insert step"},
+		{"invalid table", with(func(s *StepConfig) { s.Table = "readings; DROP TABLE x" }), "invalid table name"},
+		{"missing source", with(func(s *StepConfig) { s.Source = "" }), "source is required for bulk_insert step"},
+		{"invalid source", with(func(s *StepConfig) { s.Source = "trigger.params.(" }), "source: invalid expression"},
+		{"invalid column", with(func(s *StepConfig) { s.Columns = []string{"id", "recorded at"} }), "columns[1]: invalid column name 'recorded at'"},
+		{"duplicate column", with(func(s *StepConfig) { s.Columns = []string{"id", "ID"} }), "columns[1]: duplicate column 'ID'"},
+		{"negative batch_size", with(func(s *StepConfig) { s.BatchSize = -1 }), "batch_size cannot be negative"},
+		{"invalid on_batch_error", with(func(s *StepConfig) { s.OnBatchError = "skip" }), "invalid on_batch_error 'skip'"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := &WorkflowConfig{
 				Name:     "test",
-				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
-				Steps:    []StepConfig{tt.step},
-			}
-			result := Validate(cfg, nil)
-			if result.Valid {
-				t.Error("expected validation to fail")
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "POST"}},
+				Steps:    []StepConfig{tt.step, {Type: "response", Template: "{}"}},
 			}
+			result := Validate(cfg, ctx)
 			if !containsError(result.Errors, tt.expectError) {
 				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
 			}
 		})
 	}
+
+	t.Run("valid", func(t *testing.T) {
+		step := with(func(s *StepConfig) {
+			s.Columns = []string{"machine_id", "value"}
+			s.BatchSize = 2000
+			s.OnBatchError = "continue"
+			s.TimeoutSec = 60
+		})
+		cfg := &WorkflowConfig{
+			Name:     "test",
+			Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "POST"}},
+			Steps:    []StepConfig{step, {Type: "response", Template: "{}"}},
+		}
+		result := Validate(cfg, ctx)
+		if !result.Valid {
+			t.Errorf("expected valid, got: %v", result.Errors)
+		}
+		if !containsWarning(result.Warnings, "batches are capped at 1000 rows") {
+			t.Errorf("expected batch_size warning, got: %v", result.Warnings)
+		}
+	})
 }
 
 func TestValidate_BlockStep(t *testing.T) {
@@ -1064,6 +2935,136 @@ func containsWarning(warnings []string, substr string) bool {
 	return false
 }
 
+func TestValidate_ParamValidation(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	n := func(v int) *int { return &v }
+
+	tests := []struct {
+		name        string
+		param       ParamConfig
+		expectError string
+	}{
+		{"min on string", ParamConfig{Name: "p", Type: "string", Validation: &ParamValidation{Min: f(1)}}, "min and max require a numeric type"},
+		{"min above max", ParamConfig{Name: "p", Type: "int", Validation: &ParamValidation{Min: f(5), Max: f(1)}}, "min cannot be greater than max"},
+		{"length on int", ParamConfig{Name: "p", Type: "int", Validation: &ParamValidation{MaxLength: n(3)}}, "min_length and max_length require a string or array type"},
+		{"negative length", ParamConfig{Name: "p", Type: "string", Validation: &ParamValidation{MinLength: n(-1)}}, "cannot be negative"},
+		{"min_length above max_length", ParamConfig{Name: "p", Type: "string", Validation: &ParamValidation{MinLength: n(5), MaxLength: n(2)}}, "min_length cannot be greater than max_length"},
+		{"pattern on int", ParamConfig{Name: "p", Type: "int", Validation: &ParamValidation{Pattern: "^1"}}, "pattern requires a string type"},
+		{"invalid pattern", ParamConfig{Name: "p", Type: "string", Validation: &ParamValidation{Pattern: "("}}, "invalid pattern"},
+		{"enum on json", ParamConfig{Name: "p", Type: "json", Validation: &ParamValidation{Enum: []string{"{}"}}}, "enum is not supported for json parameters"},
+		{"enum wrong type", ParamConfig{Name: "p", Type: "int[]", Validation: &ParamValidation{Enum: []string{"1", "two"}}}, "enum value 'two' is not a valid int"},
+		{"invalid expr", ParamConfig{Name: "p", Validation: &ParamValidation{Expr: "value >"}}, "invalid expr"},
+		{"message without expr", ParamConfig{Name: "p", Validation: &ParamValidation{Message: "bad"}}, "message requires expr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET", Parameters: []ParamConfig{tt.param}}},
+				Steps:    []StepConfig{{Type: "response", Template: "{}"}},
+			}
+			result := Validate(cfg, nil)
+			if result.Valid {
+				t.Error("expected validation to fail")
+			}
+			if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := &WorkflowConfig{
+			Name: "test",
+			Triggers: []TriggerConfig{{
+				Type: "http", Path: "/test", Method: "GET",
+				Parameters: []ParamConfig{
+					{Name: "age", Type: "int", Validation: &ParamValidation{Min: f(0), Max: f(150)}},
+					{Name: "ids", Type: "int[]", Validation: &ParamValidation{MaxLength: n(100), Min: f(1)}},
+					{Name: "code", Type: "string", Validation: &ParamValidation{MinLength: n(2), Pattern: "^[A-Z]+$", Enum: []string{"US", "DE"}}},
+					{Name: "until", Type: "date", Validation: &ParamValidation{Expr: "value > now()", Message: "until must be in the future"}},
+				},
+			}},
+			Steps: []StepConfig{{Type: "response", Template: "{}"}},
+		}
+		if result := Validate(cfg, nil); !result.Valid {
+			t.Errorf("expected valid, got errors: %v", result.Errors)
+		}
+	})
+}
+
+func TestValidate_BodySchema(t *testing.T) {
+	schemaFile := filepath.Join(t.TempDir(), "order.yaml")
+	if err := os.WriteFile(schemaFile, []byte("type: object\nrequired: [sku]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		trigger       TriggerConfig
+		expectError   string
+		expectWarning string
+	}{
+		{"inline", TriggerConfig{Type: "http", Path: "/test", Method: "POST", BodySchema: map[string]any{"type": "object"}}, "", ""},
+		{"file", TriggerConfig{Type: "http", Path: "/test", Method: "POST", BodySchema: schemaFile}, "", ""},
+		{"missing file", TriggerConfig{Type: "http", Path: "/test", Method: "POST", BodySchema: "/nonexistent/schema.json"}, "failed to read schema file", ""},
+		{"invalid schema", TriggerConfig{Type: "http", Path: "/test", Method: "POST", BodySchema: map[string]any{"type": "text"}}, `body_schema: /type: unknown type "text"`, ""},
+		{"unsupported keyword", TriggerConfig{Type: "http", Path: "/test", Method: "POST", BodySchema: map[string]any{"if": true}}, "keyword is not supported", ""},
+		{"GET trigger", TriggerConfig{Type: "http", Path: "/test", Methods: []string{"GET", "POST"}, BodySchema: map[string]any{}}, "", "body_schema on a GET trigger"},
+		{"cron trigger", TriggerConfig{Type: "cron", Schedule: "0 * * * *", BodySchema: map[string]any{}}, "", "body_schema is ignored for cron trigger"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := []StepConfig{{Type: "response", Template: "{}"}}
+			if tt.trigger.Type == "cron" {
+				steps = []StepConfig{{Name: "s", Type: "set", Values: map[string]string{"x": "1"}}}
+			}
+			cfg := &WorkflowConfig{Name: "test", Triggers: []TriggerConfig{tt.trigger}, Steps: steps}
+			result := Validate(cfg, nil)
+			if tt.expectError == "" && !result.Valid {
+				t.Errorf("expected valid, got errors: %v", result.Errors)
+			}
+			if tt.expectError != "" && !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+			if tt.expectWarning != "" && !containsWarning(result.Warnings, tt.expectWarning) {
+				t.Errorf("expected warning containing %q, got: %v", tt.expectWarning, result.Warnings)
+			}
+		})
+	}
+}
+
+func TestValidate_ResponseSchema(t *testing.T) {
+	tests := []struct {
+		name        string
+		schema      any
+		expectError string
+	}{
+		{"inline", map[string]any{"type": "object", "required": []any{"data"}}, ""},
+		{"invalid", map[string]any{"required": "data"}, "steps[#0].schema: /required: must be an array of strings"},
+		{"missing file", "/nonexistent/response.json", "failed to read schema file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps:    []StepConfig{{Type: "response", Template: "{}", Schema: tt.schema}},
+			}
+			result := Validate(cfg, nil)
+			if tt.expectError == "" && !result.Valid {
+				t.Errorf("expected valid, got errors: %v", result.Errors)
+			}
+			if tt.expectError != "" && !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
 func TestValidate_SQLTemplateInjection(t *testing.T) {
 	ctx := &ValidationContext{Databases: map[string]bool{"db": false}}
 
@@ -1264,6 +3265,258 @@ func TestValidate_RateLimitErrors(t *testing.T) {
 	}
 }
 
+// TestValidate_ResponseHeaders verifies trigger response_headers are checked
+func TestValidate_ResponseHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		triggerType string
+		headers     map[string]string
+		expectError string
+	}{
+		{name: "valid", headers: map[string]string{"Cache-Control": "max-age=60", "X-Id": "{{.trigger.params.id}}"}},
+		{name: "not http", triggerType: "websocket", headers: map[string]string{"X-Id": "1"}, expectError: "response_headers is only supported for http triggers"},
+		{name: "empty name", headers: map[string]string{"": "x"}, expectError: "header name cannot be empty"},
+		{name: "invalid template", headers: map[string]string{"X-Id": "{{.trigger"}, expectError: "response_headers[X-Id]: invalid template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name: "test",
+				Triggers: []TriggerConfig{{
+					Type:            cmp.Or(tt.triggerType, "http"),
+					Path:            "/test",
+					Method:          "GET",
+					ResponseHeaders: tt.headers,
+				}},
+				Steps: []StepConfig{{Type: "response", Template: "{}"}},
+			}
+
+			result := Validate(cfg, nil)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
+				}
+			} else if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+// TestValidate_Rewrite verifies request rewrite rules are checked against the trigger's parameters
+func TestValidate_Rewrite(t *testing.T) {
+	tests := []struct {
+		name        string
+		triggerType string
+		rewrite     RewriteConfig
+		expectError string
+	}{
+		{name: "valid", rewrite: RewriteConfig{
+			RemoveHeaders: []string{"X-Debug"},
+			SetHeaders:    map[string]string{"X-Tenant": "acme"},
+			DefaultQuery:  map[string]string{"limit": "50"},
+			PathParams:    []PathParamConfig{{Name: "sku", Pattern: `^/products/([A-Z]+)`}},
+		}},
+		{name: "not http", triggerType: "websocket", expectError: "rewrite is only supported for http triggers"},
+		{name: "empty header", rewrite: RewriteConfig{SetHeaders: map[string]string{"": "x"}}, expectError: "header name cannot be empty"},
+		{name: "undeclared", rewrite: RewriteConfig{PathParams: []PathParamConfig{{Name: "other", Pattern: `(x)`}}}, expectError: "'other' must be defined in parameters"},
+		{name: "path parameter", rewrite: RewriteConfig{PathParams: []PathParamConfig{{Name: "id", Pattern: `(x)`}}}, expectError: "'id' is already a path parameter"},
+		{name: "duplicate", rewrite: RewriteConfig{PathParams: []PathParamConfig{{Name: "sku", Pattern: `(x)`}, {Name: "sku", Pattern: `(y)`}}}, expectError: "duplicate parameter 'sku'"},
+		{name: "missing pattern", rewrite: RewriteConfig{PathParams: []PathParamConfig{{Name: "sku"}}}, expectError: "pattern is required"},
+		{name: "no group", rewrite: RewriteConfig{PathParams: []PathParamConfig{{Name: "sku", Pattern: `^/products/`}}}, expectError: "must capture the value in a group"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name: "test",
+				Triggers: []TriggerConfig{{
+					Type:   cmp.Or(tt.triggerType, "http"),
+					Path:   "/products/{id}",
+					Method: "GET",
+					Parameters: []ParamConfig{
+						{Name: "id", Type: "int", Required: true},
+						{Name: "sku", Type: "string"},
+					},
+					Rewrite: &tt.rewrite,
+				}},
+				Steps: []StepConfig{{Type: "response", Template: "{}"}},
+			}
+
+			result := Validate(cfg, nil)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
+				}
+			} else if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+// TestValidate_Middlewares verifies trigger middleware lists are checked against the configured stages
+func TestValidate_Middlewares(t *testing.T) {
+	tests := []struct {
+		name        string
+		middlewares []string
+		cache       bool
+		expectError string
+		expectWarn  string
+	}{
+		{name: "reordered", middlewares: []string{"cache", "rate_limit"}, cache: true},
+		{name: "unknown", middlewares: []string{"cors"}, expectError: "unknown middleware 'cors'"},
+		{name: "duplicate", middlewares: []string{"rate_limit", "rate_limit"}, expectError: "duplicate middleware 'rate_limit'"},
+		{name: "not configured", middlewares: []string{"rate_limit", "cache"}, expectWarn: "middleware 'cache' does nothing"},
+		{name: "configured but skipped", middlewares: []string{}, expectWarn: "rate_limit is configured but skipped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger := TriggerConfig{
+				Type:        "http",
+				Path:        "/test",
+				Method:      "GET",
+				RateLimit:   []RateLimitRefConfig{{RequestsPerSecond: 10, Burst: 20, Key: "{{.trigger.client_ip}}"}},
+				Middlewares: tt.middlewares,
+			}
+			if tt.cache {
+				trigger.Cache = &CacheConfig{Enabled: true, Key: "test"}
+			}
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{trigger},
+				Steps:    []StepConfig{{Type: "response", Template: "{}"}},
+			}
+
+			result := Validate(cfg, nil)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
+				}
+			} else if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+			if tt.expectWarn != "" && !containsWarning(result.Warnings, tt.expectWarn) {
+				t.Errorf("expected warning containing %q, got: %v", tt.expectWarn, result.Warnings)
+			}
+			if tt.expectWarn == "" && tt.expectError == "" && len(result.Warnings) > 0 {
+				t.Errorf("unexpected warnings: %v", result.Warnings)
+			}
+		})
+	}
+}
+
+// TestValidate_RedirectStep verifies redirect step fields and where redirects may be used
+func TestValidate_RedirectStep(t *testing.T) {
+	tests := []struct {
+		name        string
+		triggerType string
+		step        StepConfig
+		expectError string
+	}{
+		{name: "valid", step: StepConfig{Type: "redirect", StatusCode: 308, Location: "https://example.com/{{.trigger.params.id}}"}},
+		{name: "missing location", step: StepConfig{Type: "redirect"}, expectError: "location is required for redirect step"},
+		{name: "invalid template", step: StepConfig{Type: "redirect", Location: "{{.x"}, expectError: "invalid location template"},
+		{name: "status", step: StepConfig{Type: "redirect", Location: "/", StatusCode: 200}, expectError: "status_code must be 301, 302, 303, 307, or 308"},
+		{name: "template", step: StepConfig{Type: "redirect", Location: "/", Template: "{}"}, expectError: "template is not supported for redirect steps"},
+		{name: "location on response", step: StepConfig{Type: "response", Template: "{}", Location: "/"}, expectError: "location is only supported for redirect steps"},
+		{name: "websocket", triggerType: "websocket", step: StepConfig{Type: "redirect", Location: "/"}, expectError: "redirect steps are only valid for HTTP triggers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name: "test",
+				Triggers: []TriggerConfig{{
+					Type:   cmp.Or(tt.triggerType, "http"),
+					Path:   "/test",
+					Method: "GET",
+				}},
+				Steps: []StepConfig{tt.step},
+			}
+
+			result := Validate(cfg, nil)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
+				}
+			} else if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+// TestValidate_PageStep verifies page step fields and template_file placement
+func TestValidate_PageStep(t *testing.T) {
+	tests := []struct {
+		name        string
+		step        StepConfig
+		expectError string
+	}{
+		{name: "valid", step: StepConfig{Type: "page", StatusCode: 404, Template: "<h1>{{.trigger.params.q}}</h1>"}},
+		{name: "missing template", step: StepConfig{Type: "page"}, expectError: "template or template_file is required for page step"},
+		{name: "status", step: StepConfig{Type: "page", Template: "x", StatusCode: 700}, expectError: "status_code must be 100-599"},
+		{name: "schema", step: StepConfig{Type: "page", Template: "x", Schema: map[string]any{"type": "object"}}, expectError: "schema requires a JSON response and is not supported for page steps"},
+		{name: "template_type", step: StepConfig{Type: "page", Template: "x", TemplateType: "html"}, expectError: "template_type is only supported for response steps"},
+		{name: "template_file on query", step: StepConfig{Type: "query", Database: "db", SQL: "SELECT 1", TemplateFile: "x.html"}, expectError: "template_file is only supported for response and page steps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{{Type: "http", Path: "/test", Method: "GET"}},
+				Steps:    []StepConfig{tt.step},
+			}
+
+			result := Validate(cfg, nil)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
+				}
+			} else if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+		})
+	}
+}
+
+// TestValidate_Deprecation verifies deprecated and sunset trigger fields
+func TestValidate_Deprecation(t *testing.T) {
+	tests := []struct {
+		name          string
+		trigger       TriggerConfig
+		expectError   string
+		expectWarning string
+	}{
+		{name: "valid", trigger: TriggerConfig{Type: "http", Path: "/v1/items", Method: "GET", Deprecated: true, Sunset: "2999-12-31"}},
+		{name: "valid timestamp", trigger: TriggerConfig{Type: "http", Path: "/v1/items", Method: "GET", Deprecated: true, Sunset: "2999-12-31T23:59:59Z"}},
+		{name: "sunset without deprecated", trigger: TriggerConfig{Type: "http", Path: "/v1/items", Method: "GET", Sunset: "2999-12-31"}, expectError: "sunset requires deprecated: true"},
+		{name: "invalid sunset", trigger: TriggerConfig{Type: "http", Path: "/v1/items", Method: "GET", Deprecated: true, Sunset: "30/06/2025"}, expectError: "sunset must be a date (2025-06-30) or an RFC 3339 timestamp"},
+		{name: "past sunset", trigger: TriggerConfig{Type: "http", Path: "/v1/items", Method: "GET", Deprecated: true, Sunset: "2020-01-01"}, expectWarning: "sunset 2020-01-01 has passed"},
+		{name: "cron trigger", trigger: TriggerConfig{Type: "cron", Schedule: "0 * * * *", Deprecated: true}, expectError: "deprecated and sunset are only supported for http triggers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &WorkflowConfig{
+				Name:     "test",
+				Triggers: []TriggerConfig{tt.trigger},
+				Steps:    []StepConfig{{Name: "q", Type: "query", Database: "db", SQL: "SELECT 1"}},
+			}
+
+			result := Validate(cfg, nil)
+			if tt.expectError == "" {
+				if !result.Valid {
+					t.Errorf("expected valid, got errors: %v", result.Errors)
+				}
+			} else if !containsError(result.Errors, tt.expectError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectError, result.Errors)
+			}
+			if tt.expectWarning != "" && !containsWarning(result.Warnings, tt.expectWarning) {
+				t.Errorf("expected warning containing %q, got: %v", tt.expectWarning, result.Warnings)
+			}
+		})
+	}
+}
+
 // TestValidate_HTTPCallRetry verifies httpcall retry configuration validation
 func TestValidate_HTTPCallRetry(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,182 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"sql-proxy/internal/websocket"
+)
+
+// DefaultWebSocketIdleTimeout closes connections that send nothing, not even
+// a pong, for this long when idle_timeout_sec is not set
+const DefaultWebSocketIdleTimeout = 60 * time.Second
+
+// WebSocketHandler serves a websocket trigger. Each text message on a
+// connection runs the workflow once with the message, a JSON object, as the
+// request body; the response the workflow writes is sent back as a text
+// message. Messages on one connection run one at a time, in order. The
+// upgrade request's headers, cookies, and client IP are the trigger data of
+// every execution, so workflows authorize connections the way they authorize
+// HTTP requests.
+type WebSocketHandler struct {
+	http *HTTPHandler
+
+	mu       sync.Mutex
+	conns    map[*websocket.Conn]context.CancelFunc
+	shutdown bool
+}
+
+// NewWebSocketHandler creates a handler for a workflow websocket trigger.
+func NewWebSocketHandler(executor *Executor, wf *CompiledWorkflow, trigger *CompiledTrigger, rateLimiter RateLimiter, trustProxyHeaders bool, version, buildTime string, variables map[string]string) *WebSocketHandler {
+	return &WebSocketHandler{
+		http:  NewHTTPHandler(executor, wf, trigger, rateLimiter, nil, trustProxyHeaders, version, buildTime, variables),
+		conns: make(map[*websocket.Conn]context.CancelFunc),
+	}
+}
+
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cfg := h.http.trigger.Config
+	if !websocket.CheckOrigin(r, cfg.Origins) {
+		h.http.writeError(w, http.StatusForbidden, CodeOriginNotAllowed, "origin not allowed", getOrGenerateRequestID(r))
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		// Handshake errors were answered by Upgrade
+		return
+	}
+
+	// Executions outlive the upgrade request; the connection ends them
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	defer cancel()
+	if !h.track(conn, cancel) {
+		_ = conn.Close(websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+	defer h.untrack(conn)
+
+	conn.SetMaxMessageSize(int64(cfg.MaxMessageBytes))
+	idleTimeout := DefaultWebSocketIdleTimeout
+	if cfg.IdleTimeoutSec > 0 {
+		idleTimeout = time.Duration(cfg.IdleTimeoutSec) * time.Second
+	}
+	conn.SetReadTimeout(idleTimeout)
+	go keepAlive(ctx, conn, idleTimeout/2)
+
+	logger := h.http.executor.Logger()
+	logger.Debug("websocket_connected", map[string]any{
+		"workflow": h.http.workflow.Config.Name,
+		"path":     r.URL.Path,
+		"remote":   conn.RemoteAddr().String(),
+	})
+
+	messages := 0
+	for {
+		op, data, err := conn.ReadMessage()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				_ = conn.Close(websocket.CloseGoingAway, "idle timeout")
+			} else if !websocket.IsClosed(err) {
+				_ = conn.Close(websocket.CloseInternalError, "")
+			}
+			logger.Debug("websocket_disconnected", map[string]any{
+				"workflow": h.http.workflow.Config.Name,
+				"path":     r.URL.Path,
+				"messages": messages,
+				"reason":   err.Error(),
+			})
+			return
+		}
+		messages++
+
+		reply := h.handleMessage(ctx, r, op, data)
+		if err := conn.WriteMessage(websocket.OpText, reply); err != nil {
+			_ = conn.Close(websocket.CloseGoingAway, "")
+			return
+		}
+	}
+}
+
+// handleMessage runs the workflow for one message and returns the response body
+func (h *WebSocketHandler) handleMessage(ctx context.Context, upgrade *http.Request, op int, data []byte) []byte {
+	mw := &messageWriter{header: make(http.Header)}
+	requestID := generateRequestID()
+	if op != websocket.OpText {
+		h.http.writeError(mw, http.StatusBadRequest, CodeUnsupportedMessage, "binary messages are not supported", requestID)
+		return mw.body.Bytes()
+	}
+
+	r := upgrade.Clone(ctx)
+	r.Body = http.NoBody
+	r.ContentLength = 0
+	if len(bytes.TrimSpace(data)) > 0 {
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		r.ContentLength = int64(len(data))
+		r.Header.Set("Content-Type", "application/json")
+	}
+	h.http.serve(mw, r, requestID)
+	return mw.body.Bytes()
+}
+
+// keepAlive pings the peer so that a live connection never hits the idle timeout
+func keepAlive(ctx context.Context, conn *websocket.Conn, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := conn.Ping(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *WebSocketHandler) track(conn *websocket.Conn, cancel context.CancelFunc) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.shutdown {
+		return false
+	}
+	h.conns[conn] = cancel
+	return true
+}
+
+func (h *WebSocketHandler) untrack(conn *websocket.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns, conn)
+}
+
+// Shutdown closes all connections with "going away" and cancels their
+// executions. http.Server.Shutdown does not track upgraded connections.
+func (h *WebSocketHandler) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shutdown = true
+	for conn, cancel := range h.conns {
+		cancel()
+		_ = conn.Close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// messageWriter collects the response to one message. Status codes and
+// headers have no equivalent in a message and are dropped.
+type messageWriter struct {
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (m *messageWriter) Header() http.Header         { return m.header }
+func (m *messageWriter) WriteHeader(int)             {}
+func (m *messageWriter) Write(b []byte) (int, error) { return m.body.Write(b) }
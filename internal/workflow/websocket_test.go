@@ -0,0 +1,209 @@
+package workflow
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sql-proxy/internal/websocket"
+)
+
+// wsTestConn is a minimal websocket client for handler tests
+type wsTestConn struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, path string, header http.Header) (*wsTestConn, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("write handshake: %v", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	return &wsTestConn{t: t, conn: conn, br: br}, resp
+}
+
+func (c *wsTestConn) send(op int, payload string) {
+	c.t.Helper()
+	mask := [4]byte{7, 1, 9, 3}
+	buf := []byte{0x80 | byte(op), 0x80 | byte(len(payload))}
+	buf = append(buf, mask[:]...)
+	for i := 0; i < len(payload); i++ {
+		buf = append(buf, payload[i]^mask[i%4])
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		c.t.Fatalf("write frame: %v", err)
+	}
+}
+
+// receive returns the next frame, skipping pings
+func (c *wsTestConn) receive() (int, []byte) {
+	c.t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(c.br, head[:]); err != nil {
+			c.t.Fatalf("read frame: %v", err)
+		}
+		n := int(head[1] & 0x7f)
+		if n == 126 {
+			var ext [2]byte
+			io.ReadFull(c.br, ext[:])
+			n = int(binary.BigEndian.Uint16(ext[:]))
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			c.t.Fatalf("read payload: %v", err)
+		}
+		if op := int(head[0] & 0x0f); op != websocket.OpPing {
+			return op, payload
+		}
+	}
+}
+
+func (c *wsTestConn) receiveJSON() map[string]any {
+	c.t.Helper()
+	op, payload := c.receive()
+	if op != websocket.OpText {
+		c.t.Fatalf("expected text message, got op %d %q", op, payload)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(payload, &m); err != nil {
+		c.t.Fatalf("reply is not JSON: %q", payload)
+	}
+	return m
+}
+
+func newWebSocketTestServer(t *testing.T, trigger TriggerConfig) (*httptest.Server, *WebSocketHandler) {
+	t.Helper()
+	exec := NewExecutor(&mockDBManager{}, &mockHTTPClient{}, nil, &testLogger{})
+	wf := mustCompile(t, &WorkflowConfig{
+		Name:     "chat",
+		Triggers: []TriggerConfig{trigger},
+		Steps: []StepConfig{
+			{
+				Name:      "echo",
+				Type:      "response",
+				Condition: "trigger.params.text != ''",
+				Template:  `{"type": {{json .trigger.type}}, "room": {{json .trigger.params.room}}, "text": {{json .trigger.params.text}}, "token": {{json .trigger.headers.Authorization}}}`,
+			},
+		},
+	})
+	h := NewWebSocketHandler(exec, wf, wf.Triggers[0], nil, false, "", "", nil)
+	mux := http.NewServeMux()
+	mux.Handle("GET "+trigger.Path, h)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, h
+}
+
+func TestWebSocketHandler_Messages(t *testing.T) {
+	srv, _ := newWebSocketTestServer(t, TriggerConfig{
+		Type: "websocket",
+		Path: "/ws/{room}",
+		Parameters: []ParamConfig{
+			{Name: "room", Type: "string", Required: true},
+			{Name: "text", Type: "string"},
+		},
+	})
+
+	c, resp := dialWebSocket(t, srv, "/ws/lobby", http.Header{"Authorization": {"Bearer abc"}})
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	// Each message is its own execution with the upgrade request's headers and path
+	for _, text := range []string{"hello", "again"} {
+		c.send(websocket.OpText, `{"text": "`+text+`"}`)
+		reply := c.receiveJSON()
+		want := map[string]any{"type": "websocket", "room": "lobby", "text": text, "token": "Bearer abc"}
+		for k, v := range want {
+			if reply[k] != v {
+				t.Errorf("reply[%s] = %v, want %v (reply %v)", k, reply[k], v, reply)
+			}
+		}
+	}
+
+	// No response step ran: the default response is sent
+	c.send(websocket.OpText, ``)
+	if reply := c.receiveJSON(); reply["success"] != true || reply["request_id"] == "" {
+		t.Errorf("default reply = %v", reply)
+	}
+
+	c.send(websocket.OpText, `not json`)
+	if reply := c.receiveJSON(); !strings.Contains(reply["error"].(string), "failed to parse JSON body") {
+		t.Errorf("invalid JSON reply = %v", reply)
+	}
+
+	c.send(websocket.OpBinary, `{}`)
+	if reply := c.receiveJSON(); reply["error"] != "binary messages are not supported" {
+		t.Errorf("binary reply = %v", reply)
+	}
+}
+
+func TestWebSocketHandler_Origin(t *testing.T) {
+	srv, _ := newWebSocketTestServer(t, TriggerConfig{
+		Type:    "websocket",
+		Path:    "/ws",
+		Origins: []string{"https://app.example.com"},
+	})
+
+	_, resp := dialWebSocket(t, srv, "/ws", http.Header{"Origin": {"https://evil.example.com"}})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", resp.StatusCode)
+	}
+	_, resp = dialWebSocket(t, srv, "/ws", http.Header{"Origin": {"https://app.example.com"}})
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("status = %d, want 101", resp.StatusCode)
+	}
+}
+
+func TestWebSocketHandler_Shutdown(t *testing.T) {
+	srv, h := newWebSocketTestServer(t, TriggerConfig{Type: "websocket", Path: "/ws"})
+
+	c, _ := dialWebSocket(t, srv, "/ws", nil)
+	// A round trip guarantees the connection is tracked
+	c.send(websocket.OpText, `{}`)
+	c.receiveJSON()
+
+	h.Shutdown()
+	op, payload := c.receive()
+	if op != websocket.OpClose || binary.BigEndian.Uint16(payload) != websocket.CloseGoingAway {
+		t.Errorf("expected going away close, got op %d %q", op, payload)
+	}
+
+	// New connections are turned away after shutdown
+	c2, resp := dialWebSocket(t, srv, "/ws", nil)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if op, _ := c2.receive(); op != websocket.OpClose {
+		t.Errorf("expected close after shutdown, got op %d", op)
+	}
+}
@@ -0,0 +1,84 @@
+package workflowtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// normalize converts a value decoded from YAML to the types JSON decodes to,
+// so numbers compare as float64
+func normalize(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// matchJSON compares got with want and describes each difference. Objects match
+// when every key of want matches (and, if exact, got has no other keys), arrays
+// when they have the same length and their elements match, and other values
+// when equal.
+func matchJSON(path string, want, got any, exact bool) []string {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: got %s, want an object", path, describe(got))}
+		}
+		var diffs []string
+		for _, k := range slices.Sorted(maps.Keys(w)) {
+			gv, ok := g[k]
+			if !ok {
+				diffs = append(diffs, fmt.Sprintf("%s.%s: missing", path, k))
+				continue
+			}
+			diffs = append(diffs, matchJSON(path+"."+k, w[k], gv, exact)...)
+		}
+		if exact {
+			for _, k := range slices.Sorted(maps.Keys(g)) {
+				if _, ok := w[k]; !ok {
+					diffs = append(diffs, fmt.Sprintf("%s.%s: unexpected", path, k))
+				}
+			}
+		}
+		return diffs
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: got %s, want an array", path, describe(got))}
+		}
+		if len(g) != len(w) {
+			return []string{fmt.Sprintf("%s: got %d elements, want %d", path, len(g), len(w))}
+		}
+		var diffs []string
+		for i := range w {
+			diffs = append(diffs, matchJSON(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], exact)...)
+		}
+		return diffs
+	default:
+		if !reflect.DeepEqual(want, got) {
+			return []string{fmt.Sprintf("%s: got %s, want %s", path, describe(got), describe(want))}
+		}
+		return nil
+	}
+}
+
+// describe formats a JSON value for a failure
+func describe(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if len(data) > 100 {
+		return string(data[:100]) + "..."
+	}
+	return string(data)
+}
@@ -0,0 +1,467 @@
+// Package workflowtest runs declared test cases against the workflows of a
+// config. Each case sends a request through the server's handlers, with every
+// database and HTTP call answered by mocks, and checks the response.
+//
+// Test files are YAML files holding a list of cases:
+//
+//	tests:
+//	  - name: returns the user
+//	    request: {method: GET, path: "/api/users?id=1"}
+//	    databases:
+//	      primary:
+//	        - match: "FROM users WHERE id = @id"
+//	          rows: [{id: 1, name: alice}]
+//	    expect:
+//	      status: 200
+//	      body: {data: [{name: alice}]}
+package workflowtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"sql-proxy/internal/cassette"
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/db"
+	"sql-proxy/internal/redact"
+	"sql-proxy/internal/server"
+)
+
+// File is a test file
+type File struct {
+	Tests []Case `yaml:"tests"`
+
+	Path string `yaml:"-"`
+}
+
+// Case is a request to a workflow, the mocks answering its database and HTTP
+// calls, and the expected response
+type Case struct {
+	Name      string                       `yaml:"name"`
+	Request   Request                      `yaml:"request"`
+	Databases map[string][]db.MockResponse `yaml:"databases"` // Responses by database name (see the mock database type)
+	HTTP      []HTTPMock                   `yaml:"http"`      // Responses to httpcall steps
+	Cassette  string                       `yaml:"cassette"`  // Cassette answering the calls no mock matches, relative to the test file
+	Expect    Expect                       `yaml:"expect"`
+}
+
+// Request is the HTTP request a case sends to the main listener
+type Request struct {
+	Method  string            `yaml:"method"` // Default: GET
+	Path    string            `yaml:"path"`   // Path and query string
+	Headers map[string]string `yaml:"headers"`
+	Body    any               `yaml:"body"` // Sent as is if a string, as JSON otherwise
+}
+
+// HTTPMock answers the outgoing requests matching it. The first matching mock
+// of a case is used.
+type HTTPMock struct {
+	Method  string            `yaml:"method"` // Any method if empty
+	URL     string            `yaml:"url"`    // Regular expression searched in the full URL
+	Status  int               `yaml:"status"` // Default: 200
+	Headers map[string]string `yaml:"headers"`
+	Body    any               `yaml:"body"` // Sent as is if a string, as JSON otherwise
+
+	url *regexp.Regexp
+}
+
+// Expect is the response a case expects
+type Expect struct {
+	Status  int               `yaml:"status"` // Default: 200
+	Headers map[string]string `yaml:"headers"`
+	Body    any               `yaml:"body"`   // JSON the body must contain (extra keys are allowed), or the exact text if a string and the body is not JSON
+	Golden  string            `yaml:"golden"` // File holding the exact JSON body, relative to the test file
+}
+
+// Result is the outcome of a case
+type Result struct {
+	File     string
+	Name     string
+	Failures []string // Empty if the case passed
+	Duration time.Duration
+}
+
+// Passed reports whether the case passed
+func (r Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Options select the cases to run and how
+type Options struct {
+	Run    *regexp.Regexp // Only cases whose name matches (nil: all)
+	Update bool           // Write golden files from the responses instead of comparing them
+	Record bool           // Send the calls no mock matches and record them to the case's cassette
+}
+
+// Load reads the test file at path, or every .yaml and .yml file of the
+// directory at path, in name order
+func Load(path string) ([]*File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+	if info.IsDir() {
+		paths = nil
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, matches...)
+		}
+		slices.Sort(paths)
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no test files (*.yaml, *.yml) in %s", path)
+		}
+	}
+
+	files := make([]*File, 0, len(paths))
+	for _, p := range paths {
+		f, err := loadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func loadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{Path: path}
+	if err := yaml.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for i := range f.Tests {
+		c := &f.Tests[i]
+		prefix := fmt.Sprintf("%s: tests[%d]", path, i)
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case %d", i+1)
+		}
+		if !strings.HasPrefix(c.Request.Path, "/") {
+			return nil, fmt.Errorf("%s: request.path must start with /", prefix)
+		}
+		for j := range c.HTTP {
+			m := &c.HTTP[j]
+			re, err := regexp.Compile(m.URL)
+			if err != nil {
+				return nil, fmt.Errorf("%s: http[%d]: invalid url: %w", prefix, j, err)
+			}
+			m.url = re
+		}
+		if c.Cassette != "" && !filepath.IsAbs(c.Cassette) {
+			c.Cassette = filepath.Join(filepath.Dir(path), c.Cassette)
+		}
+		if c.Expect.Golden != "" && !filepath.IsAbs(c.Expect.Golden) {
+			c.Expect.Golden = filepath.Join(filepath.Dir(path), c.Expect.Golden)
+		}
+	}
+	return f, nil
+}
+
+// Run runs the cases of files against the workflows of cfg, each on a server
+// of its own whose databases are all mocks. Databases of type mock keep the
+// responses of their fixture file after those of the case.
+//
+// Servers are created as secondary instances: the caller sets up logging.
+func Run(cfg *config.Config, files []*File, opts Options) ([]Result, error) {
+	dir, err := os.MkdirTemp("", "sql-proxy-test-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	var results []Result
+	for _, f := range files {
+		for i := range f.Tests {
+			c := &f.Tests[i]
+			if opts.Run != nil && !opts.Run.MatchString(c.Name) {
+				continue
+			}
+			start := time.Now()
+			failures := runCase(cfg, c, filepath.Join(dir, fmt.Sprintf("case-%d", len(results))), opts)
+			results = append(results, Result{File: f.Path, Name: c.Name, Failures: failures, Duration: time.Since(start)})
+		}
+	}
+	return results, nil
+}
+
+// runCase runs a case and returns its failures
+func runCase(cfg *config.Config, c *Case, dir string, opts Options) []string {
+	caseCfg, err := caseConfig(cfg, c, dir)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	client, err := caseClient(cfg, c, opts.Record)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	srv, err := server.New(caseCfg, false)
+	if err != nil {
+		return []string{fmt.Sprintf("server: %v", err)}
+	}
+	defer func() { _ = srv.Shutdown(context.Background()) }()
+	srv.SetHTTPClient(client)
+
+	req, err := c.Request.build()
+	if err != nil {
+		return []string{err.Error()}
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	return c.Expect.check(rec.Result(), opts.Update)
+}
+
+// caseClient returns the client answering the outgoing requests of the case:
+// its mocks, then its cassette, replayed or, with record, recorded. The
+// cassette is masked like http_recording does.
+func caseClient(cfg *config.Config, c *Case, record bool) (*mockClient, error) {
+	client := &mockClient{mocks: c.HTTP}
+	if c.Cassette == "" {
+		return client, nil
+	}
+	var redactCfg config.RedactConfig
+	if cfg.HTTPRecording != nil {
+		redactCfg = cfg.HTTPRecording.Redact
+	}
+	redactor, err := redact.New(redactCfg)
+	if err != nil {
+		return nil, fmt.Errorf("http_recording.redact: %w", err)
+	}
+	if record {
+		client.next = cassette.NewRecorder(http.DefaultClient, c.Cassette, redactor)
+		return client, nil
+	}
+	player, err := cassette.NewPlayer(c.Cassette, redactor)
+	if err != nil {
+		return nil, fmt.Errorf("%v (run with -record to create it)", err)
+	}
+	client.next = player
+	return client, nil
+}
+
+// caseConfig returns a copy of cfg for the case: every database is a mock
+// answering from a fixture file written to dir, and metrics and background
+// work that outlives a request are off
+func caseConfig(cfg *config.Config, c *Case, dir string) (*config.Config, error) {
+	for name := range c.Databases {
+		if !slices.ContainsFunc(cfg.Databases, func(d config.DatabaseConfig) bool { return d.Name == name }) {
+			return nil, fmt.Errorf("databases: unknown database '%s'", name)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	out := *cfg
+	out.Server.SecondaryInstance = true
+	out.Server.DatabaseStateFile = ""
+	out.Server.PIDFile = ""
+	out.Metrics.Enabled = false
+	out.Debug.Enabled = false
+	out.HTTPRecording = nil
+	out.Databases = make([]config.DatabaseConfig, len(cfg.Databases))
+	for i, dbCfg := range cfg.Databases {
+		fixtures := &db.MockFixtures{Responses: c.Databases[dbCfg.Name]}
+		if dbCfg.Type == "mock" {
+			base, err := db.LoadMockFixtures(dbCfg.Fixtures)
+			if err != nil {
+				return nil, err
+			}
+			fixtures.Responses = append(fixtures.Responses, base.Responses...)
+			fixtures.Tables = base.Tables
+		}
+		data, err := yaml.Marshal(fixtures)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, dbCfg.Name+".yaml")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return nil, err
+		}
+		out.Databases[i] = config.DatabaseConfig{
+			Name:          dbCfg.Name,
+			Type:          "mock",
+			Fixtures:      path,
+			ReadOnly:      dbCfg.ReadOnly,
+			SQLComments:   dbCfg.SQLComments,
+			MaxConcurrent: dbCfg.MaxConcurrent,
+			Policy:        dbCfg.Policy,
+		}
+	}
+	return &out, nil
+}
+
+// build returns the request to send
+func (r Request) build() (*http.Request, error) {
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	body, isJSON, err := encodeBody(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("request.body: %w", err)
+	}
+	req := httptest.NewRequest(method, r.Path, bytes.NewReader(body))
+	if isJSON {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// encodeBody returns a string body as is and anything else as JSON
+func encodeBody(body any) ([]byte, bool, error) {
+	switch b := body.(type) {
+	case nil:
+		return nil, false, nil
+	case string:
+		return []byte(b), false, nil
+	default:
+		data, err := json.Marshal(b)
+		return data, true, err
+	}
+}
+
+// check compares the response with the expectation and returns the differences.
+// With update, the golden file is written from the body instead.
+func (e Expect) check(resp *http.Response, update bool) []string {
+	var failures []string
+	status := e.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if resp.StatusCode != status {
+		failures = append(failures, fmt.Sprintf("status: got %d, want %d", resp.StatusCode, status))
+	}
+	for k, want := range e.Headers {
+		if got := resp.Header.Get(k); got != want {
+			failures = append(failures, fmt.Sprintf("header %s: got %q, want %q", k, got, want))
+		}
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var got any
+	gotErr := json.Unmarshal(body, &got)
+
+	if e.Body != nil {
+		if text, ok := e.Body.(string); ok && gotErr != nil {
+			if string(body) != text {
+				failures = append(failures, fmt.Sprintf("body: got %q, want %q", body, text))
+			}
+		} else if gotErr != nil {
+			failures = append(failures, fmt.Sprintf("body is not JSON: %q", truncate(body)))
+		} else {
+			failures = append(failures, matchJSON("body", normalize(e.Body), got, false)...)
+		}
+	}
+
+	if e.Golden != "" {
+		failures = append(failures, e.checkGolden(body, got, gotErr, update)...)
+	}
+	return failures
+}
+
+// checkGolden compares the body with the golden file, or writes it with update
+func (e Expect) checkGolden(body []byte, got any, gotErr error, update bool) []string {
+	if gotErr != nil {
+		return []string{fmt.Sprintf("body is not JSON: %q", truncate(body))}
+	}
+	if update {
+		data, _ := json.MarshalIndent(got, "", "  ")
+		if err := os.MkdirAll(filepath.Dir(e.Golden), 0755); err != nil {
+			return []string{err.Error()}
+		}
+		if err := os.WriteFile(e.Golden, append(data, '\n'), 0644); err != nil {
+			return []string{err.Error()}
+		}
+		return nil
+	}
+	data, err := os.ReadFile(e.Golden)
+	if err != nil {
+		return []string{fmt.Sprintf("golden: %v (run with -update to create it)", err)}
+	}
+	var want any
+	if err := json.Unmarshal(data, &want); err != nil {
+		return []string{fmt.Sprintf("golden %s: %v", e.Golden, err)}
+	}
+	return matchJSON("body", want, got, true)
+}
+
+// truncate shortens a body quoted in a failure
+func truncate(b []byte) string {
+	const max = 200
+	if len(b) > max {
+		return string(b[:max]) + "..."
+	}
+	return string(b)
+}
+
+// mockClient answers outgoing requests from the mocks of a case, or passes
+// them to next
+type mockClient struct {
+	mocks []HTTPMock
+	next  cassette.Client
+}
+
+func (c *mockClient) Do(req *http.Request) (*http.Response, error) {
+	url := req.URL.String()
+	for _, m := range c.mocks {
+		if (m.Method == "" || strings.EqualFold(m.Method, req.Method)) && m.url.MatchString(url) {
+			return m.response(req)
+		}
+	}
+	if c.next != nil {
+		return c.next.Do(req)
+	}
+	return nil, fmt.Errorf("no http mock matches %s %s", req.Method, url)
+}
+
+// response returns the mock's response to req
+func (m HTTPMock) response(req *http.Request) (*http.Response, error) {
+	body, isJSON, err := encodeBody(m.Body)
+	if err != nil {
+		return nil, fmt.Errorf("http mock %s: %w", m.URL, err)
+	}
+	status := m.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := make(http.Header)
+	if isJSON {
+		header.Set("Content-Type", "application/json")
+	}
+	for k, v := range m.Headers {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,298 @@
+package workflowtest
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/workflow"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	os.Exit(m.Run())
+}
+
+// testConfig returns a config with a workflow querying a database and one
+// calling an HTTP API
+func testConfig() *config.Config {
+	readOnly := false
+	return &config.Config{
+		Server: config.ServerConfig{
+			Host:              "127.0.0.1",
+			Port:              8080,
+			DefaultTimeoutSec: 30,
+			MaxTimeoutSec:     300,
+			Version:           "test",
+		},
+		Databases: []config.DatabaseConfig{
+			{Name: "primary", Type: "sqlserver", Host: "db.invalid", Database: "app", ReadOnly: &readOnly},
+		},
+		Workflows: []workflow.WorkflowConfig{
+			{
+				Name: "get_user",
+				Triggers: []workflow.TriggerConfig{{
+					Type:       "http",
+					Path:       "/api/users",
+					Method:     "GET",
+					Parameters: []workflow.ParamConfig{{Name: "id", Type: "int", Required: true}},
+				}},
+				Steps: []workflow.StepConfig{
+					{Name: "fetch", Type: "query", Database: "primary", SQL: "SELECT id, name FROM users WHERE id = @id"},
+					{Type: "response", Template: `{"count": {{len .steps.fetch.data}}, "data": {{json .steps.fetch.data}}}`},
+				},
+			},
+			{
+				Name: "weather",
+				Triggers: []workflow.TriggerConfig{{
+					Type:   "http",
+					Path:   "/api/weather",
+					Method: "GET",
+				}},
+				Steps: []workflow.StepConfig{
+					{Name: "call", Type: "httpcall", URL: "https://weather.example.com/today", Parse: "json"},
+					{Type: "response", Template: `{"forecast": {{json .steps.call.data}}}`},
+				},
+			},
+		},
+	}
+}
+
+// writeTests writes a test file to a temporary directory and loads it
+func writeTests(t *testing.T, content string) (string, []*File) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cases.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return dir, files
+}
+
+// TestRun verifies cases pass or fail on the response their mocked databases and HTTP calls produce
+func TestRun(t *testing.T) {
+	_, files := writeTests(t, `
+tests:
+  - name: user found
+    request: {path: "/api/users?id=1"}
+    databases:
+      primary:
+        - match: "FROM users WHERE id = @id"
+          params: {id: 1}
+          rows: [{id: 1, name: alice}]
+    expect:
+      status: 200
+      body: {count: 1, data: [{name: alice}]}
+  - name: wrong expectation
+    request: {path: "/api/users?id=2"}
+    databases:
+      primary:
+        - match: "FROM users"
+          rows: []
+    expect:
+      body: {count: 1}
+  - name: missing parameter
+    request: {path: "/api/users"}
+    expect:
+      status: 400
+  - name: weather
+    request: {path: "/api/weather"}
+    http:
+      - url: "weather\\.example\\.com/today$"
+        body: {sky: clear}
+    expect:
+      body: {forecast: [{sky: clear}]}
+`)
+	results, err := Run(testConfig(), files, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	for _, i := range []int{0, 2, 3} {
+		if !results[i].Passed() {
+			t.Errorf("%s: failures %v", results[i].Name, results[i].Failures)
+		}
+	}
+	if results[1].Passed() || !strings.Contains(strings.Join(results[1].Failures, "\n"), "body.count: got 0, want 1") {
+		t.Errorf("wrong expectation: failures %v", results[1].Failures)
+	}
+}
+
+// TestRun_Filter verifies only the cases matching Options.Run run
+func TestRun_Filter(t *testing.T) {
+	_, files := writeTests(t, `
+tests:
+  - name: first
+    request: {path: "/api/users"}
+    expect: {status: 400}
+  - name: second
+    request: {path: "/api/users"}
+    expect: {status: 400}
+`)
+	results, err := Run(testConfig(), files, Options{Run: regexp.MustCompile("^sec")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "second" {
+		t.Errorf("results = %+v, want only second", results)
+	}
+}
+
+// TestRun_Golden verifies golden files are written with Update and compared exactly otherwise
+func TestRun_Golden(t *testing.T) {
+	dir, files := writeTests(t, `
+tests:
+  - name: golden
+    request: {path: "/api/users?id=1"}
+    databases:
+      primary:
+        - match: "FROM users"
+          rows: [{id: 1, name: alice}]
+    expect:
+      golden: golden/user.json
+`)
+	cfg := testConfig()
+
+	results, _ := Run(cfg, files, Options{})
+	if results[0].Passed() || !strings.Contains(results[0].Failures[0], "-update") {
+		t.Errorf("missing golden file: failures %v", results[0].Failures)
+	}
+
+	results, _ = Run(cfg, files, Options{Update: true})
+	if !results[0].Passed() {
+		t.Fatalf("update: failures %v", results[0].Failures)
+	}
+	golden := filepath.Join(dir, "golden", "user.json")
+	if _, err := os.Stat(golden); err != nil {
+		t.Fatalf("golden file not written: %v", err)
+	}
+
+	results, _ = Run(cfg, files, Options{})
+	if !results[0].Passed() {
+		t.Errorf("compare: failures %v", results[0].Failures)
+	}
+
+	if err := os.WriteFile(golden, []byte(`{"count": 1, "data": [{"id": 1}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, _ = Run(cfg, files, Options{})
+	if results[0].Passed() || !strings.Contains(strings.Join(results[0].Failures, "\n"), "body.data[0].name: unexpected") {
+		t.Errorf("changed golden file: failures %v", results[0].Failures)
+	}
+}
+
+// TestRun_UnmatchedHTTP verifies an outgoing request without a matching mock fails the call
+func TestRun_UnmatchedHTTP(t *testing.T) {
+	_, files := writeTests(t, `
+tests:
+  - name: no mock
+    request: {path: "/api/weather"}
+    http:
+      - url: "other\\.example\\.com"
+        body: {}
+    expect:
+      status: 200
+`)
+	results, err := Run(testConfig(), files, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Passed() {
+		t.Error("expected the case to fail without a matching http mock")
+	}
+}
+
+// TestRun_Cassette verifies calls are recorded to the case's cassette with Record and replayed from it otherwise
+func TestRun_Cassette(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"sky": "rain"}`))
+	}))
+	cfg := testConfig()
+	cfg.Workflows[1].Steps[0].URL = api.URL + "/today"
+
+	dir, files := writeTests(t, `
+tests:
+  - name: weather
+    request: {path: "/api/weather"}
+    cassette: cassettes/weather.yaml
+    expect:
+      body: {forecast: [{sky: rain}]}
+`)
+	results, _ := Run(cfg, files, Options{})
+	if results[0].Passed() || !strings.Contains(results[0].Failures[0], "-record") {
+		t.Errorf("missing cassette: failures %v", results[0].Failures)
+	}
+
+	results, _ = Run(cfg, files, Options{Record: true})
+	if !results[0].Passed() {
+		t.Fatalf("record: failures %v", results[0].Failures)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cassettes", "weather.yaml")); err != nil {
+		t.Fatalf("cassette not written: %v", err)
+	}
+
+	api.Close()
+	results, _ = Run(cfg, files, Options{})
+	if !results[0].Passed() {
+		t.Errorf("replay: failures %v", results[0].Failures)
+	}
+}
+
+// TestLoad_Invalid verifies test files are checked when loaded
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"relative path", "tests:\n  - request: {path: api}\n", "request.path must start with /"},
+		{"invalid url", "tests:\n  - request: {path: /a}\n    http: [{url: \"(\"}]\n", "invalid url"},
+		{"not yaml", "tests: [", "failed to parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cases.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := Load(t.TempDir()); err == nil {
+		t.Error("expected error for a directory without test files")
+	}
+}
+
+// TestMatchJSON verifies subset and exact matching of JSON values
+func TestMatchJSON(t *testing.T) {
+	got := map[string]any{"a": float64(1), "b": []any{"x", "y"}, "c": nil}
+	if diffs := matchJSON("body", normalize(map[string]any{"a": 1}), got, false); len(diffs) != 0 {
+		t.Errorf("subset: %v", diffs)
+	}
+	if diffs := matchJSON("body", normalize(map[string]any{"a": 1}), got, true); len(diffs) != 2 {
+		t.Errorf("exact: %v, want b and c unexpected", diffs)
+	}
+	if diffs := matchJSON("body", []any{"x"}, got["b"], false); len(diffs) != 1 || !strings.Contains(diffs[0], "got 2 elements, want 1") {
+		t.Errorf("array length: %v", diffs)
+	}
+	if diffs := matchJSON("body", map[string]any{"d": "x"}, got, false); len(diffs) != 1 || diffs[0] != "body.d: missing" {
+		t.Errorf("missing key: %v", diffs)
+	}
+}
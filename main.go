@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"sql-proxy/internal/config"
 	"sql-proxy/internal/service"
@@ -29,11 +30,29 @@ var (
 	stop         = flag.Bool("stop", false, "Stop the system service")
 	restart      = flag.Bool("restart", false, "Restart the system service")
 	status       = flag.Bool("status", false, "Show system service status")
+	upgradeFlag  = flag.Bool("upgrade", false, "Replace the running server with this executable and config without dropping requests")
 	validateOnly = flag.Bool("validate", false, "Validate configuration and exit")
+	lintOnly     = flag.Bool("lint", false, "Validate configuration and best-practice rules without connecting to databases; exit 1 on errors")
 	showVersion  = flag.Bool("version", false, "Print version and exit")
+
+	serviceAccount  = flag.String("service-account", "", "Account the installed service runs as (overrides service.account)")
+	servicePassword = flag.String("service-password", "", "Password of -service-account (Windows)")
+	eventLog        = flag.Bool("event-log", false, "Also log to the Windows Event Log when running as a Windows service")
 )
 
 func main() {
+	// Subcommands run instead of serving
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "test":
+			os.Exit(runTests(os.Args[2:]))
+		case "graph":
+			os.Exit(runGraph(os.Args[2:]))
+		case "diff":
+			os.Exit(runDiff(os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 
 	// Handle version flag
@@ -55,7 +74,24 @@ func main() {
 			log.Fatalf("Failed to get absolute config path: %v", err)
 		}
 
-		if err := service.Install(*serviceName, exePath, absConfigPath); err != nil {
+		// The service section of the config (of the first instance of an
+		// instances file) supplies the defaults of the install flags
+		cfgs, err := config.LoadInstances(absConfigPath)
+		if err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		cfg := cfgs[0]
+		opts := service.InstallOptions{
+			Account:  cfg.Service.Account,
+			Password: *servicePassword,
+			EventLog: *eventLog,
+			Recovery: cfg.Service.Recovery,
+		}
+		if *serviceAccount != "" {
+			opts.Account = *serviceAccount
+		}
+
+		if err := service.Install(*serviceName, exePath, absConfigPath, opts); err != nil {
 			log.Fatalf("Failed to install service: %v", err)
 		}
 		return
@@ -99,21 +135,48 @@ func main() {
 		return
 	}
 
-	// Load configuration
-	cfg, err := config.Load(*configPath)
+	// Load configuration: a config file, or an instances file listing several
+	cfgs, err := config.LoadInstances(*configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
 		os.Exit(1)
 	}
 
+	if *upgradeFlag {
+		// The new process would refuse an invalid config, leaving the old one running
+		if result := validate.Instances(cfgs); !result.Valid {
+			printValidationResult(cfgs, result)
+			os.Exit(1)
+		}
+		if err := service.Upgrade(cfgs[0].Server.PIDFile); err != nil {
+			log.Fatalf("Failed to upgrade: %v", err)
+		}
+		return
+	}
+
 	// Set runtime info (not from config file)
-	cfg.Server.Version = Version
-	cfg.Server.BuildTime = BuildTime
+	for _, cfg := range cfgs {
+		cfg.Server.Version = Version
+		cfg.Server.BuildTime = BuildTime
+		if *eventLog {
+			cfg.Logging.EventLog.Enabled = true
+		}
+	}
 
 	// Handle validation mode
 	if *validateOnly {
-		result := validate.Run(cfg)
-		printValidationResult(cfg, result)
+		result := validate.Instances(cfgs)
+		printValidationResult(cfgs, result)
+		if result.Valid {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+
+	// Handle lint mode: no database connections, for CI
+	if *lintOnly {
+		result := validate.CheckInstances(cfgs)
+		printLintResult(result)
 		if result.Valid {
 			os.Exit(0)
 		}
@@ -124,23 +187,72 @@ func main() {
 	interactive := !*daemon
 	if interactive {
 		fmt.Printf("SQL Proxy Service %s\n", Version)
-		fmt.Printf("Loaded %d workflows\n", len(cfg.Workflows))
+		for _, cfg := range cfgs {
+			if cfg.Server.Instance != "" {
+				fmt.Printf("Instance %s: loaded %d workflows\n", cfg.Server.Instance, len(cfg.Workflows))
+			} else {
+				fmt.Printf("Loaded %d workflows\n", len(cfg.Workflows))
+			}
+		}
 	}
 
 	// Set service name before running (needed for Windows service mode)
 	service.SetServiceName(*serviceName)
 
 	// Run the service
-	if err := service.Run(cfg, interactive); err != nil {
+	if err := service.Run(cfgs, interactive); err != nil {
 		log.Fatalf("Service error: %v", err)
 	}
 }
 
-func printValidationResult(cfg *config.Config, result *validate.Result) {
+func printValidationResult(cfgs []*config.Config, result *validate.Result) {
 	fmt.Println("SQL Proxy Configuration Validator")
 	fmt.Println("==================================")
-	fmt.Printf("Config file: %s\n\n", *configPath)
+	fmt.Printf("Config file: %s\n", *configPath)
+
+	for _, cfg := range cfgs {
+		fmt.Println()
+		if cfg.Server.Instance != "" {
+			fmt.Printf("Instance: %s\n", cfg.Server.Instance)
+		}
+		printConfigSummary(cfg)
+	}
+
+	if len(result.Warnings) > 0 {
+		fmt.Println("\nWarnings:")
+		for _, w := range result.Warnings {
+			fmt.Printf("  [WARN] %s\n", w)
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		fmt.Println("\nErrors:")
+		for _, e := range result.Errors {
+			fmt.Printf("  [ERROR] %s\n", e)
+		}
+	}
 
+	fmt.Println()
+	if result.Valid {
+		fmt.Println("Configuration valid")
+	} else {
+		fmt.Println("Configuration invalid")
+	}
+}
+
+// printLintResult prints the errors and warnings of a config, one per line, for CI logs
+func printLintResult(result *validate.Result) {
+	for _, e := range result.Errors {
+		fmt.Printf("%s: [ERROR] %s\n", *configPath, e)
+	}
+	for _, w := range result.Warnings {
+		fmt.Printf("%s: [WARN] %s\n", *configPath, w)
+	}
+	fmt.Printf("%d errors, %d warnings\n", len(result.Errors), len(result.Warnings))
+}
+
+// printConfigSummary prints the databases, CRUD tables and workflow routes of a config
+func printConfigSummary(cfg *config.Config) {
 	fmt.Printf("Server: %s:%d\n", cfg.Server.Host, cfg.Server.Port)
 	fmt.Printf("Databases: %d configured\n", len(cfg.Databases))
 	for _, db := range cfg.Databases {
@@ -152,45 +264,50 @@ func printValidationResult(cfg *config.Config, result *validate.Result) {
 		if dbType == "" {
 			dbType = "sqlserver"
 		}
-		if dbType == "sqlite" {
+		if db.TenantRouting != nil {
+			fmt.Printf("  - %s: %s, routed per tenant (%d static) (%s)\n", db.Name, dbType, len(db.TenantRouting.Tenants), mode)
+		} else if dbType == "sqlite" {
 			fmt.Printf("  - %s: sqlite:%s (%s)\n", db.Name, db.Path, mode)
+		} else if dbType == "mock" {
+			fmt.Printf("  - %s: mock:%s (%s)\n", db.Name, db.Fixtures, mode)
 		} else {
 			fmt.Printf("  - %s: %s@%s/%s (%s)\n", db.Name, db.User, db.Host, db.Database, mode)
 		}
 	}
 	fmt.Printf("Workflows: %d configured\n", len(cfg.Workflows))
+	if len(cfg.Crud) > 0 {
+		fmt.Printf("CRUD tables: %d configured\n", len(cfg.Crud))
+		for _, c := range cfg.Crud {
+			fmt.Printf("  - %s: %s.%s at %s\n", c.Name, c.Database, c.Table, c.BasePath())
+		}
+	}
 
 	if len(cfg.Workflows) > 0 {
 		fmt.Println("\nWorkflows:")
 		for _, wf := range cfg.Workflows {
 			for _, trigger := range wf.Triggers {
-				if trigger.Type == "http" && trigger.Path != "" {
-					fmt.Printf("  %s %s - %s (%d params)\n", trigger.Method, trigger.Path, wf.Name, len(trigger.Parameters))
-				} else if trigger.Type == "cron" {
-					fmt.Printf("  [cron] %s - %s\n", trigger.Schedule, wf.Name)
+				for _, route := range trigger.Expand() {
+					if route.Type == "http" && route.Path != "" {
+						fmt.Printf("  %s %s - %s (%d params)\n", route.Method, route.Path, wf.Name, len(route.Parameters))
+					} else if route.Type == "websocket" {
+						fmt.Printf("  [websocket] %s - %s (%d params)\n", route.Path, wf.Name, len(route.Parameters))
+					} else if route.Type == "cron" {
+						fmt.Printf("  [cron] %s - %s\n", route.Schedule, wf.Name)
+					} else if route.Type == "dbwatch" {
+						fmt.Printf("  [dbwatch] %s.%s.%s - %s\n", route.Database, route.Table, route.Column, wf.Name)
+					} else if route.Type == "filewatch" {
+						fmt.Printf("  [filewatch] %s - %s\n", filepath.Join(route.Dir, route.Pattern), wf.Name)
+					} else if route.Type == "mqtt" {
+						fmt.Printf("  [mqtt] %s %s - %s\n", route.Broker, strings.Join(route.Topics, ","), wf.Name)
+					} else if route.Type == "grpc" {
+						rpc := "Execute"
+						if route.RPC != "" {
+							rpc = route.RPC
+						}
+						fmt.Printf("  [grpc] %s - %s (%d params)\n", rpc, wf.Name, len(route.Parameters))
+					}
 				}
 			}
 		}
 	}
-
-	if len(result.Warnings) > 0 {
-		fmt.Println("\nWarnings:")
-		for _, w := range result.Warnings {
-			fmt.Printf("  [WARN] %s\n", w)
-		}
-	}
-
-	if len(result.Errors) > 0 {
-		fmt.Println("\nErrors:")
-		for _, e := range result.Errors {
-			fmt.Printf("  [ERROR] %s\n", e)
-		}
-	}
-
-	fmt.Println()
-	if result.Valid {
-		fmt.Println("Configuration valid")
-	} else {
-		fmt.Println("Configuration invalid")
-	}
 }
@@ -0,0 +1,9 @@
+//go:build plugins
+
+package main
+
+// Plugins compiled in with `make build-plugins` (go build -tags plugins).
+// Add a blank import for each plugin package; see the funcs package.
+import (
+	_ "sql-proxy/examples/plugins/iban"
+)
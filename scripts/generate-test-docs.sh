@@ -105,17 +105,49 @@ process_package "internal/db" "Database"
 process_package "internal/validate" "Validation"
 process_package "internal/server" "Server"
 process_package "internal/logging" "Logging"
+process_package "internal/redact" "Redaction"
+process_package "internal/cassette" "HTTP Cassettes"
 process_package "internal/metrics" "Metrics"
+process_package "internal/errtrack" "Error Tracking"
 process_package "internal/openapi" "OpenAPI"
 process_package "internal/service" "Service"
 process_package "internal/cache" "Cache"
 process_package "internal/tmpl" "Template Engine"
 process_package "internal/ratelimit" "Rate Limiting"
+process_package "internal/concurrency" "Concurrency Limits"
+process_package "internal/loadshed" "Load Shedding"
+process_package "internal/compress" "Response Compression"
+process_package "internal/static" "Static Files"
+process_package "internal/systemd" "systemd Integration"
+process_package "internal/upgrade" "Zero-Downtime Upgrade"
+process_package "internal/policy" "Statement Policies"
 process_package "internal/types" "Types"
 process_package "internal/publicid" "Public IDs"
+process_package "internal/keyring" "Crypto Keys"
+process_package "internal/mail" "Mail"
+process_package "internal/objstore" "Object Storage"
+process_package "internal/sftp" "SFTP"
+process_package "internal/mqtt" "MQTT"
+process_package "internal/grpc" "gRPC"
+process_package "internal/kafka" "Kafka"
+process_package "internal/amqp" "AMQP"
+process_package "internal/outbox" "Outbox"
+process_package "internal/websocket" "WebSocket"
+process_package "internal/jsonschema" "JSON Schema"
+process_package "internal/jq" "jq Queries"
+process_package "internal/jwt" "JWT"
+process_package "internal/decimal" "Decimal Arithmetic"
+process_package "internal/locale" "Locale Formatting"
+process_package "internal/i18n" "Localized Messages"
 process_package "internal/sqlutil" "SQL Utilities"
+process_package "internal/crud" "CRUD Generation"
 process_package "internal/workflow" "Workflow"
 process_package "internal/workflow/step" "Workflow Steps"
+process_package "internal/workflowtest" "Workflow Tests"
+process_package "internal/chaos" "Fault Injection"
+process_package "internal/graph" "Workflow Graphs"
+process_package "internal/configdiff" "Config Diff"
+process_package "examples/plugins/iban" "Example Plugin (IBAN)"
 process_package "e2e" "End-to-End"
 
 # Add footer
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"time"
+
+	"sql-proxy/internal/config"
+	"sql-proxy/internal/logging"
+	"sql-proxy/internal/workflowtest"
+)
+
+// runTests runs the test subcommand: sql-proxy test -config config.yaml -tests tests/.
+// It returns the exit code.
+func runTests(args []string) int {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.yaml", "Path to configuration file")
+	testsPath := fs.String("tests", "tests", "Test file, or directory of test files (*.yaml, *.yml)")
+	run := fs.String("run", "", "Only run the cases whose name matches this regular expression")
+	update := fs.Bool("update", false, "Write golden files from the responses instead of comparing them")
+	record := fs.Bool("record", false, "Send the HTTP calls no mock matches and record them to the cassettes of the cases")
+	verbose := fs.Bool("v", false, "List passing cases too")
+	logPath := fs.String("log", "", "Write the server log of the cases to this file (default: discarded)")
+	_ = fs.Parse(args)
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		return 2
+	}
+	cfg.Server.Version = Version
+	cfg.Server.BuildTime = BuildTime
+
+	files, err := workflowtest.Load(*testsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Test error: %v\n", err)
+		return 2
+	}
+
+	opts := workflowtest.Options{Update: *update, Record: *record}
+	if *run != "" {
+		if opts.Run, err = regexp.Compile(*run); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -run: %v\n", err)
+			return 2
+		}
+	}
+
+	if *logPath != "" {
+		if err := logging.Init(cfg.Logging.Level, *logPath, cfg.Logging.MaxSizeMB, cfg.Logging.MaxBackups, cfg.Logging.MaxAgeDays); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open log: %v\n", err)
+			return 2
+		}
+	} else {
+		slog.SetDefault(slog.New(slog.DiscardHandler))
+	}
+
+	start := time.Now()
+	results, err := workflowtest.Run(cfg, files, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Test error: %v\n", err)
+		return 2
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Passed() {
+			if *verbose {
+				fmt.Printf("PASS  %s: %s (%s)\n", r.File, r.Name, r.Duration.Round(time.Millisecond))
+			}
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL  %s: %s (%s)\n", r.File, r.Name, r.Duration.Round(time.Millisecond))
+		for _, f := range r.Failures {
+			fmt.Printf("        %s\n", f)
+		}
+	}
+
+	fmt.Printf("\n%d passed, %d failed (%s)\n", len(results)-failed, failed, time.Since(start).Round(time.Millisecond))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}